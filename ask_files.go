@@ -0,0 +1,243 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// fileChunkBytes is the most text of one file staged as a single context
+// item by AskAboutFiles; larger files are split into several.
+const fileChunkBytes = 32 << 10
+
+// ErrNoAnswer is returned by AskAboutFiles when the session ends without a
+// result.
+var ErrNoAnswer = errors.New("session ended without an answer")
+
+// FileAnswer is the answer to a question asked with AskAboutFiles.
+type FileAnswer struct {
+	// Text is the final answer.
+	Text string
+	// Citations are the file references in Text, in order of first
+	// appearance.
+	Citations []FileCitation
+	Result    *ResultMessage
+}
+
+// FileCitation refers to lines of a file the question was asked about.
+// EndLine equals StartLine for a single line.
+type FileCitation struct {
+	Path      string
+	StartLine int
+	EndLine   int
+}
+
+// String formats the citation as path:line or path:start-end.
+func (c FileCitation) String() string {
+	if c.EndLine > c.StartLine {
+		return fmt.Sprintf("%s:%d-%d", c.Path, c.StartLine, c.EndLine)
+	}
+	return fmt.Sprintf("%s:%d", c.Path, c.StartLine)
+}
+
+// fileChunk is a range of lines of a staged file.
+type fileChunk struct {
+	path       string
+	start, end int
+	text       string // the lines, each prefixed with its number
+}
+
+// AskAboutFiles answers question about the files at paths. The files are
+// read immediately and sent with line numbers as context ahead of the
+// question, split into chunks when large; WithContextLimit bounds how much
+// is sent. The agent is restricted to read-only tools, as with
+// WithReadOnly, and asked to cite the lines its answer relies on, which are
+// parsed into the answer's citations.
+//
+// Example:
+//
+//	answer, err := claudecode.AskAboutFiles(ctx, "Where are retries configured?",
+//	    []string{"client.go", "options.go"})
+//	if err != nil {
+//	    return err
+//	}
+//	fmt.Println(answer.Text)
+//	for _, citation := range answer.Citations {
+//	    fmt.Println(citation)
+//	}
+func AskAboutFiles(ctx context.Context, question string, paths []string, opts ...Option) (*FileAnswer, error) {
+	return askAboutFiles(ctx, question, paths, func(fn func(Client) error, opts ...Option) error {
+		return WithClient(ctx, fn, opts...)
+	}, opts)
+}
+
+// AskAboutFilesWithTransport is AskAboutFiles with a custom transport, for
+// testing.
+func AskAboutFilesWithTransport(
+	ctx context.Context,
+	transport Transport,
+	question string,
+	paths []string,
+	opts ...Option,
+) (*FileAnswer, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("transport is required")
+	}
+	return askAboutFiles(ctx, question, paths, func(fn func(Client) error, opts ...Option) error {
+		return WithClientTransport(ctx, transport, fn, opts...)
+	}, opts)
+}
+
+func askAboutFiles(
+	ctx context.Context,
+	question string,
+	paths []string,
+	run func(fn func(Client) error, opts ...Option) error,
+	opts []Option,
+) (*FileAnswer, error) {
+	if strings.TrimSpace(question) == "" {
+		return nil, fmt.Errorf("question is required")
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("at least one file is required")
+	}
+	var chunks []fileChunk
+	for _, path := range paths {
+		fileChunks, err := readFileChunks(path, fileChunkBytes)
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, fileChunks...)
+	}
+
+	var answer *FileAnswer
+	opts = append(append([]Option(nil), opts...), WithReadOnly())
+	err := run(func(client Client) error {
+		for _, chunk := range chunks {
+			label := fmt.Sprintf("%s lines %d-%d", chunk.path, chunk.start, chunk.end)
+			if err := client.AddContextText(label, chunk.text); err != nil {
+				return err
+			}
+		}
+		if err := client.Query(ctx, fileQuestionPrompt(question)); err != nil {
+			return err
+		}
+
+		var text []string
+		iter := client.ReceiveResponse(ctx)
+		if iter == nil {
+			return fmt.Errorf("client not connected")
+		}
+		for {
+			msg, err := iter.Next(ctx)
+			if errors.Is(err, ErrNoMoreMessages) {
+				return ErrNoAnswer
+			}
+			if err != nil {
+				return err
+			}
+			switch msg := msg.(type) {
+			case *AssistantMessage:
+				for _, block := range msg.Content {
+					if textBlock, ok := block.(*TextBlock); ok {
+						text = append(text, textBlock.Text)
+					}
+				}
+			case *ResultMessage:
+				if msg.IsError {
+					return fmt.Errorf("question failed: %s", msg.Subtype)
+				}
+				answer = &FileAnswer{Text: strings.Join(text, "\n"), Result: msg}
+				if msg.Result != nil {
+					answer.Text = *msg.Result
+				}
+				answer.Citations = parseFileCitations(answer.Text, paths)
+				return nil
+			}
+		}
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// fileQuestionPrompt asks question about the staged files, requesting
+// citations AskAboutFiles can parse.
+func fileQuestionPrompt(question string) string {
+	return question + "\n\nAnswer from the files provided as context. " +
+		"Cite the lines your answer relies on as path:line or path:start-end, " +
+		"using the paths and line numbers shown in the context."
+}
+
+// readFileChunks reads the text file at path and splits it into chunks of
+// whole lines of at most limit bytes, numbering each line. A line longer
+// than limit is a chunk of its own.
+func readFileChunks(path string, limit int) ([]fileChunk, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("file %s is not valid UTF-8 text", path)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var chunks []fileChunk
+	var sb strings.Builder
+	start := 1
+	for i, line := range lines {
+		numbered := strconv.Itoa(i+1) + ": " + line
+		if sb.Len() > 0 && sb.Len()+len(numbered) > limit {
+			chunks = append(chunks, fileChunk{path: path, start: start, end: i, text: sb.String()})
+			sb.Reset()
+			start = i + 1
+		}
+		sb.WriteString(numbered)
+	}
+	chunks = append(chunks, fileChunk{path: path, start: start, end: len(lines), text: sb.String()})
+	return chunks, nil
+}
+
+// parseFileCitations finds references to lines of paths in text, such as
+// "client.go:42" or "client.go:10-20". Longer paths are matched first, so
+// "internal/client.go:1" is not also read as "client.go:1".
+func parseFileCitations(text string, paths []string) []FileCitation {
+	sorted := append([]string(nil), paths...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	alternatives := make([]string, len(sorted))
+	for i, path := range sorted {
+		alternatives[i] = regexp.QuoteMeta(path)
+	}
+	pattern := regexp.MustCompile(`(?:^|[^\w./-])(` + strings.Join(alternatives, "|") + `):(\d+)(?:-(\d+))?`)
+
+	var citations []FileCitation
+	seen := make(map[FileCitation]bool)
+	for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+		citation := FileCitation{Path: match[1]}
+		citation.StartLine, _ = strconv.Atoi(match[2])
+		citation.EndLine = citation.StartLine
+		if match[3] != "" {
+			if end, err := strconv.Atoi(match[3]); err == nil && end > citation.StartLine {
+				citation.EndLine = end
+			}
+		}
+		if !seen[citation] {
+			seen[citation] = true
+			citations = append(citations, citation)
+		}
+	}
+	return citations
+}
@@ -0,0 +1,139 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAskAboutFiles(t *testing.T) {
+	ctx, cancel := setupAskFilesTestContext(t)
+	defer cancel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "retry.go")
+	writeTestFile(t, path, "package retry\n\nconst maxAttempts = 3\n")
+
+	answer := "Retries are capped at three attempts (" + path + ":3), see also " + path + ":1-3 and " + path + ":3."
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "Looking at the file."}}, Model: "claude-sonnet"},
+		&ResultMessage{Subtype: "success", Result: &answer},
+	}))
+	result, err := AskAboutFilesWithTransport(ctx, transport, "How many retries?", []string{path})
+	if err != nil {
+		t.Fatalf("AskAboutFiles failed: %v", err)
+	}
+	if result.Text != answer || result.Result == nil {
+		t.Errorf("Expected the result text as the answer, got %+v", result)
+	}
+	want := []FileCitation{{Path: path, StartLine: 3, EndLine: 3}, {Path: path, StartLine: 1, EndLine: 3}}
+	if !reflect.DeepEqual(result.Citations, want) {
+		t.Errorf("Expected citations %v, got %v", want, result.Citations)
+	}
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if len(transport.sentMessages) != 1 {
+		t.Fatalf("Expected one prompt, got %d", len(transport.sentMessages))
+	}
+	sent, _ := json.Marshal(transport.sentMessages[0].Message)
+	for _, fragment := range []string{"lines 1-3", "3: const maxAttempts = 3", "How many retries?", "path:line"} {
+		if !strings.Contains(string(sent), fragment) {
+			t.Errorf("Expected the prompt to contain %q, got %s", fragment, sent)
+		}
+	}
+}
+
+func TestAskAboutFilesErrors(t *testing.T) {
+	ctx, cancel := setupAskFilesTestContext(t)
+	defer cancel()
+
+	dir := t.TempDir()
+	path, binary := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.bin")
+	writeTestFile(t, path, "text\n")
+	writeTestFile(t, binary, "\xff\xfe")
+
+	tests := []struct {
+		name     string
+		question string
+		paths    []string
+	}{
+		{"no question", " ", []string{path}},
+		{"no files", "Why?", nil},
+		{"missing file", "Why?", []string{filepath.Join(dir, "missing.txt")}},
+		{"binary file", "Why?", []string{binary}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := newClientMockTransport()
+			if _, err := AskAboutFilesWithTransport(ctx, transport, test.question, test.paths); err == nil {
+				t.Error("Expected an error")
+			}
+			if transport.connected {
+				t.Error("Expected no connection for invalid input")
+			}
+		})
+	}
+
+	failed := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		&ResultMessage{Subtype: "error_max_turns", IsError: true},
+	}))
+	if _, err := AskAboutFilesWithTransport(ctx, failed, "Why?", []string{path}); err == nil || !strings.Contains(err.Error(), "error_max_turns") {
+		t.Errorf("Expected the failed result to be reported, got %v", err)
+	}
+
+	if _, err := AskAboutFilesWithTransport(ctx, nil, "Why?", []string{path}); err == nil {
+		t.Error("Expected an error without a transport")
+	}
+
+	short, cancelShort := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelShort()
+	if _, err := AskAboutFilesWithTransport(short, newClientMockTransport(), "Why?", []string{path}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context to end the wait, got %v", err)
+	}
+}
+
+func TestReadFileChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.txt")
+	writeTestFile(t, path, "aaaa\nbbbb\ncccc\ndddd")
+	chunks, err := readFileChunks(path, 16)
+	if err != nil {
+		t.Fatalf("readFileChunks failed: %v", err)
+	}
+	want := []fileChunk{
+		{path: path, start: 1, end: 2, text: "1: aaaa\n2: bbbb\n"},
+		{path: path, start: 3, end: 4, text: "3: cccc\n4: dddd"},
+	}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("Expected %+v, got %+v", want, chunks)
+	}
+}
+
+func TestParseFileCitations(t *testing.T) {
+	paths := []string{"client.go", "internal/client.go"}
+	text := "See internal/client.go:10-20, client.go:5 and `client.go:7`. Not myclient.go:9 or client.go:x."
+	want := []FileCitation{
+		{Path: "internal/client.go", StartLine: 10, EndLine: 20},
+		{Path: "client.go", StartLine: 5, EndLine: 5},
+		{Path: "client.go", StartLine: 7, EndLine: 7},
+	}
+	if got := parseFileCitations(text, paths); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := want[0].String(); got != "internal/client.go:10-20" {
+		t.Errorf("Expected a range citation, got %q", got)
+	}
+	if got := want[1].String(); got != "client.go:5" {
+		t.Errorf("Expected a line citation, got %q", got)
+	}
+}
+
+func setupAskFilesTestContext(t *testing.T) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
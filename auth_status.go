@@ -0,0 +1,218 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// AuthMethod is how the CLI authenticates to the API.
+type AuthMethod string
+
+const (
+	// AuthMethodBedrock and AuthMethodVertex route requests through a cloud
+	// provider, with its own credentials.
+	AuthMethodBedrock AuthMethod = "bedrock"
+	AuthMethodVertex  AuthMethod = "vertex"
+	// AuthMethodAuthToken is a bearer token from ANTHROPIC_AUTH_TOKEN.
+	AuthMethodAuthToken AuthMethod = "auth_token"
+	// AuthMethodAPIKey is an API key from ANTHROPIC_API_KEY or a Console
+	// login.
+	AuthMethodAPIKey AuthMethod = "api_key"
+	// AuthMethodOAuthToken is a long-lived token from CLAUDE_CODE_OAUTH_TOKEN,
+	// created with `claude setup-token`.
+	AuthMethodOAuthToken AuthMethod = "oauth_token"
+	// AuthMethodOAuth is a claude.ai login.
+	AuthMethodOAuth AuthMethod = "oauth"
+)
+
+// AuthInfo describes the credentials the CLI will use.
+type AuthInfo struct {
+	Method AuthMethod
+	// Source is where the credentials were found: an environment variable
+	// or a file.
+	Source string
+	// Email and Organization identify the account of a login, when the CLI
+	// recorded them.
+	Email        string
+	Organization string
+	// SubscriptionType is the plan of a claude.ai login, such as "pro" or
+	// "max".
+	SubscriptionType string
+	// ExpiresAt is when the access token of a claude.ai login expires, or
+	// zero if unknown. The CLI refreshes it while a refresh token is stored.
+	ExpiresAt time.Time
+	// ConfigDir is the CLI config directory holding the login.
+	ConfigDir string
+}
+
+// credentialsFile is the file in the CLI config directory holding a
+// claude.ai login.
+const credentialsFile = ".credentials.json"
+
+// storedCredentials is the CLI's .credentials.json.
+type storedCredentials struct {
+	ClaudeAIOAuth *struct {
+		AccessToken      string `json:"accessToken"`
+		RefreshToken     string `json:"refreshToken"`
+		ExpiresAt        int64  `json:"expiresAt"` // Unix milliseconds
+		SubscriptionType string `json:"subscriptionType"`
+	} `json:"claudeAiOauth"`
+}
+
+// globalConfig is the part of the CLI's .claude.json describing the login.
+type globalConfig struct {
+	PrimaryAPIKey string `json:"primaryApiKey"`
+	OAuthAccount  *struct {
+		EmailAddress     string `json:"emailAddress"`
+		OrganizationName string `json:"organizationName"`
+	} `json:"oauthAccount"`
+}
+
+// AuthStatus reports the credentials the CLI would use with opts, so a
+// service can fail at startup with an actionable message rather than on its
+// first query. It returns an *AuthRequiredError when there are none and an
+// *AuthExpiredError when the stored login expired without a refresh token.
+//
+// The check reads the environment and the CLI config directory, following
+// the CLI's order: a cloud provider, ANTHROPIC_AUTH_TOKEN,
+// ANTHROPIC_API_KEY, CLAUDE_CODE_OAUTH_TOKEN, then the stored login. It
+// starts no CLI and makes no API call, so it cannot tell a revoked key
+// from a valid one.
+//
+//	info, err := claudecode.AuthStatus(ctx)
+//	var expired *claudecode.AuthExpiredError
+//	if errors.As(err, &expired) {
+//	    log.Fatalf("re-authenticate the service account: %v", err)
+//	}
+func AuthStatus(ctx context.Context, opts ...Option) (AuthInfo, error) {
+	return authStatus(ctx, NewOptions(opts...))
+}
+
+func authStatus(ctx context.Context, options *Options) (AuthInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return AuthInfo{}, err
+	}
+	cliEnv := options.CLIEnv()
+	env := func(name string) string {
+		if value, ok := cliEnv[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	}
+	configDir := cliConfigDir(options)
+	info := AuthInfo{ConfigDir: configDir}
+
+	switch {
+	case envTruthy(env("CLAUDE_CODE_USE_BEDROCK")):
+		info.Method, info.Source = AuthMethodBedrock, "CLAUDE_CODE_USE_BEDROCK"
+		return info, nil
+	case envTruthy(env("CLAUDE_CODE_USE_VERTEX")):
+		info.Method, info.Source = AuthMethodVertex, "CLAUDE_CODE_USE_VERTEX"
+		return info, nil
+	}
+	for _, source := range []struct {
+		name   string
+		method AuthMethod
+	}{
+		{"ANTHROPIC_AUTH_TOKEN", AuthMethodAuthToken},
+		{"ANTHROPIC_API_KEY", AuthMethodAPIKey},
+		{"CLAUDE_CODE_OAUTH_TOKEN", AuthMethodOAuthToken},
+	} {
+		if env(source.name) != "" {
+			info.Method, info.Source = source.method, source.name
+			return info, nil
+		}
+	}
+
+	if configDir == "" {
+		return info, NewAuthRequiredError("~/.claude")
+	}
+	config, configPath, err := readGlobalConfig(configDir)
+	if err != nil {
+		return info, err
+	}
+	if config.OAuthAccount != nil {
+		info.Email = config.OAuthAccount.EmailAddress
+		info.Organization = config.OAuthAccount.OrganizationName
+	}
+
+	credentialsPath := filepath.Join(configDir, credentialsFile)
+	var credentials storedCredentials
+	if err := readJSONFile(credentialsPath, &credentials); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return info, fmt.Errorf("read CLI credentials: %w", err)
+	}
+	if oauth := credentials.ClaudeAIOAuth; oauth != nil && (oauth.AccessToken != "" || oauth.RefreshToken != "") {
+		info.Method, info.Source = AuthMethodOAuth, credentialsPath
+		info.SubscriptionType = oauth.SubscriptionType
+		if oauth.ExpiresAt > 0 {
+			info.ExpiresAt = time.UnixMilli(oauth.ExpiresAt)
+		}
+		now := SystemClock{}.Now()
+		if options.Clock != nil {
+			now = options.Clock.Now()
+		}
+		if oauth.RefreshToken == "" && !info.ExpiresAt.IsZero() && !now.Before(info.ExpiresAt) {
+			return info, NewAuthExpiredError(info.ExpiresAt, credentialsPath)
+		}
+		return info, nil
+	}
+	if config.PrimaryAPIKey != "" {
+		info.Method, info.Source = AuthMethodAPIKey, configPath
+		return info, nil
+	}
+	// On macOS the CLI keeps a claude.ai login in the keychain
+	if config.OAuthAccount != nil && runtime.GOOS == "darwin" {
+		info.Method, info.Source = AuthMethodOAuth, "macOS keychain"
+		return info, nil
+	}
+	return AuthInfo{ConfigDir: configDir}, NewAuthRequiredError(configDir)
+}
+
+// globalConfigPath returns the path of the CLI's .claude.json, which sits
+// in the config directory when CLAUDE_CONFIG_DIR moves it and in the home
+// directory otherwise.
+func globalConfigPath(configDir string) string {
+	if home, err := os.UserHomeDir(); err == nil && filepath.Clean(configDir) == filepath.Join(home, ".claude") {
+		return filepath.Join(home, ".claude.json")
+	}
+	return filepath.Join(configDir, ".claude.json")
+}
+
+// readGlobalConfig reads the CLI's .claude.json. A missing file reads as
+// empty.
+func readGlobalConfig(configDir string) (globalConfig, string, error) {
+	path := globalConfigPath(configDir)
+	var config globalConfig
+	if err := readJSONFile(path, &config); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return globalConfig{}, path, fmt.Errorf("read CLI config: %w", err)
+	}
+	return config, path, nil
+}
+
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// envTruthy reports whether an environment flag is set the way the CLI
+// reads it.
+func envTruthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// clearAuthEnv unsets the environment variables AuthStatus reads.
+func clearAuthEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"CLAUDE_CODE_USE_BEDROCK", "CLAUDE_CODE_USE_VERTEX", "ANTHROPIC_AUTH_TOKEN",
+		"ANTHROPIC_API_KEY", "CLAUDE_CODE_OAUTH_TOKEN", "CLAUDE_CONFIG_DIR",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestAuthStatus(t *testing.T) {
+	const account = `{"oauthAccount": {"emailAddress": "dev@example.com", "organizationName": "Example"}}`
+	// The manual clock reads 2025-01-01; expiry times are Unix milliseconds
+	const future, past = "1767225600000", "1704067200000"
+	tests := []struct {
+		name        string
+		env         map[string]string
+		config      string
+		credentials string
+		want        AuthInfo
+	}{
+		{
+			name: "bedrock",
+			env:  map[string]string{"CLAUDE_CODE_USE_BEDROCK": "1", "ANTHROPIC_API_KEY": "sk-ant-test"},
+			want: AuthInfo{Method: AuthMethodBedrock, Source: "CLAUDE_CODE_USE_BEDROCK"},
+		},
+		{
+			name:   "api key before login",
+			env:    map[string]string{"ANTHROPIC_API_KEY": "sk-ant-test"},
+			config: account,
+			want:   AuthInfo{Method: AuthMethodAPIKey, Source: "ANTHROPIC_API_KEY"},
+		},
+		{
+			name: "oauth token",
+			env:  map[string]string{"CLAUDE_CODE_OAUTH_TOKEN": "sk-ant-oat-test"},
+			want: AuthInfo{Method: AuthMethodOAuthToken, Source: "CLAUDE_CODE_OAUTH_TOKEN"},
+		},
+		{
+			name:        "claude.ai login",
+			config:      account,
+			credentials: `{"claudeAiOauth": {"accessToken": "a", "refreshToken": "r", "expiresAt": ` + future + `, "subscriptionType": "max"}}`,
+			want: AuthInfo{
+				Method: AuthMethodOAuth, Source: ".credentials.json", Email: "dev@example.com", Organization: "Example",
+				SubscriptionType: "max", ExpiresAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:        "expired access token refreshed",
+			credentials: `{"claudeAiOauth": {"accessToken": "a", "refreshToken": "r", "expiresAt": ` + past + `}}`,
+			want: AuthInfo{
+				Method: AuthMethodOAuth, Source: ".credentials.json", ExpiresAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:   "console login",
+			config: `{"primaryApiKey": "sk-ant-test", "oauthAccount": {"emailAddress": "dev@example.com"}}`,
+			want:   AuthInfo{Method: AuthMethodAPIKey, Source: ".claude.json", Email: "dev@example.com"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearAuthEnv(t)
+			dir := t.TempDir()
+			if test.config != "" {
+				writeTestFile(t, filepath.Join(dir, ".claude.json"), test.config)
+			}
+			if test.credentials != "" {
+				writeTestFile(t, filepath.Join(dir, ".credentials.json"), test.credentials)
+			}
+			env := map[string]string{"CLAUDE_CONFIG_DIR": dir}
+			for name, value := range test.env {
+				env[name] = value
+			}
+
+			info, err := AuthStatus(context.Background(), WithEnv(env), WithClock(newManualClock()))
+			assertNoError(t, err)
+			test.want.ConfigDir = dir
+			if filepath.Ext(test.want.Source) == ".json" {
+				test.want.Source = filepath.Join(dir, test.want.Source)
+			}
+			if !info.ExpiresAt.Equal(test.want.ExpiresAt) {
+				t.Errorf("Expected expiry %v, got %v", test.want.ExpiresAt, info.ExpiresAt)
+			}
+			info.ExpiresAt, test.want.ExpiresAt = time.Time{}, time.Time{}
+			if info != test.want {
+				t.Errorf("Expected %+v, got %+v", test.want, info)
+			}
+		})
+	}
+}
+
+func TestAuthStatusErrors(t *testing.T) {
+	t.Run("not logged in", func(t *testing.T) {
+		clearAuthEnv(t)
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, ".claude.json"), `{"numStartups": 3}`)
+
+		_, err := AuthStatus(context.Background(), WithEnvVar("CLAUDE_CONFIG_DIR", dir))
+		var required *AuthRequiredError
+		if !errors.As(err, &required) {
+			t.Fatalf("Expected *AuthRequiredError, got %v", err)
+		}
+		if required.ConfigDir != dir {
+			t.Errorf("Expected config dir %s, got %s", dir, required.ConfigDir)
+		}
+	})
+
+	t.Run("expired login", func(t *testing.T) {
+		clearAuthEnv(t)
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, ".credentials.json"), `{"claudeAiOauth": {"accessToken": "a", "expiresAt": 1704067200000}}`)
+
+		info, err := AuthStatus(context.Background(), WithEnvVar("CLAUDE_CONFIG_DIR", dir), WithClock(newManualClock()))
+		var expired *AuthExpiredError
+		if !errors.As(err, &expired) {
+			t.Fatalf("Expected *AuthExpiredError, got %v", err)
+		}
+		if !expired.ExpiresAt.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Unexpected expiry %v", expired.ExpiresAt)
+		}
+		if info.Method != AuthMethodOAuth {
+			t.Errorf("Expected the expired login to be described, got %+v", info)
+		}
+	})
+
+	t.Run("malformed credentials", func(t *testing.T) {
+		clearAuthEnv(t)
+		dir := t.TempDir()
+		writeTestFile(t, filepath.Join(dir, ".credentials.json"), `{"claudeAiOauth": `)
+
+		_, err := AuthStatus(context.Background(), WithEnvVar("CLAUDE_CONFIG_DIR", dir))
+		if err == nil {
+			t.Fatal("Expected an error for malformed credentials")
+		}
+		var required *AuthRequiredError
+		if errors.As(err, &required) {
+			t.Errorf("Expected a read error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,430 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/severity1/claude-code-sdk-go/internal/subprocess"
+)
+
+// DefaultMaxReconnects is how many times WithAutoReconnect reconnects a
+// connection when the policy sets no limit.
+const DefaultMaxReconnects = 3
+
+// ErrCLIRestarted is returned by the response iterator of a turn that was
+// in progress when the CLI exited to restart. With WithAutoReconnect the
+// session continues in a new CLI process; send the prompt again to redo the
+// turn.
+var ErrCLIRestarted = errors.New("CLI restarted during the turn")
+
+// exitCodeTransport is a transport reporting how its CLI process exited,
+// like the subprocess transport.
+type exitCodeTransport interface {
+	ExitCode() (int, bool)
+}
+
+// reconnectingTransport forwards the messages and errors of a transport
+// and, when its CLI exits to restart, of the transports replacing it, so
+// the client's channels outlive the restart.
+type reconnectingTransport struct {
+	policy AutoReconnectPolicy
+	clock  Clock
+	// dial returns a transport resuming sessionID, or a new session if it
+	// is empty. The transport it replaces is closed.
+	dial func(sessionID string) (Transport, error)
+
+	mu sync.Mutex
+	// ctx is the client's lifecycle context, which new transports connect
+	// with so they outlive the Connect call
+	ctx context.Context
+	// restarting is called when the CLI exited to restart. It ends the
+	// current turn, reporting whether one was in progress.
+	restarting func() bool
+	current    Transport
+	ready      chan struct{} // closed once current is usable
+	sessionID  string
+	attempts   int
+	running    bool
+	closed     bool
+
+	msgs     chan Message
+	errs     chan error
+	stop     chan struct{}
+	finished chan struct{}
+}
+
+func newReconnectingTransport(transport Transport, policy AutoReconnectPolicy, clock Clock, sessionID string,
+	dial func(string) (Transport, error),
+) *reconnectingTransport {
+	ready := make(chan struct{})
+	close(ready)
+	return &reconnectingTransport{
+		policy:    policy,
+		clock:     clock,
+		dial:      dial,
+		ctx:       context.Background(),
+		current:   transport,
+		ready:     ready,
+		sessionID: sessionID,
+		msgs:      make(chan Message),
+		errs:      make(chan error),
+		stop:      make(chan struct{}),
+		finished:  make(chan struct{}),
+	}
+}
+
+// onRestart sets the client's lifecycle context, which new transports
+// connect with, and the function ending the current turn when the CLI
+// exits to restart.
+func (t *reconnectingTransport) onRestart(ctx context.Context, restarting func() bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ctx = ctx
+	t.restarting = restarting
+}
+
+// Connect connects the transport and starts forwarding its output.
+func (t *reconnectingTransport) Connect(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.running {
+		return fmt.Errorf("transport already connected")
+	}
+	if err := t.current.Connect(ctx); err != nil {
+		return err
+	}
+	msgs, errs := t.current.ReceiveMessages(ctx)
+	t.running = true
+	go t.run(msgs, errs)
+	return nil
+}
+
+// ReceiveMessages returns the channels carrying the output of every CLI
+// process of the connection.
+func (t *reconnectingTransport) ReceiveMessages(_ context.Context) (<-chan Message, <-chan error) {
+	return t.msgs, t.errs
+}
+
+// SendMessage sends message to the current CLI process, waiting for it
+// during a reconnect.
+func (t *reconnectingTransport) SendMessage(ctx context.Context, message StreamMessage) error {
+	transport, err := t.await(ctx)
+	if err != nil {
+		return err
+	}
+	return transport.SendMessage(ctx, message)
+}
+
+// Interrupt interrupts the current CLI process, waiting for it during a
+// reconnect.
+func (t *reconnectingTransport) Interrupt(ctx context.Context) error {
+	transport, err := t.await(ctx)
+	if err != nil {
+		return err
+	}
+	return transport.Interrupt(ctx)
+}
+
+// SendControlRequest sends req to the current CLI process, waiting for it
+// during a reconnect.
+func (t *reconnectingTransport) SendControlRequest(ctx context.Context, req *ControlRequest) error {
+	transport, err := t.await(ctx)
+	if err != nil {
+		return err
+	}
+	ctrlTransport, ok := transport.(ControlRequestTransport)
+	if !ok {
+		return fmt.Errorf("transport does not support control requests")
+	}
+	return ctrlTransport.SendControlRequest(ctx, req)
+}
+
+// SendControlResponse answers a control request of the current CLI process.
+// A request of a process that exited to restart needs no answer.
+func (t *reconnectingTransport) SendControlResponse(ctx context.Context, resp *ControlResponse) error {
+	transport, err := t.await(ctx)
+	if err != nil {
+		return err
+	}
+	ctrlTransport, ok := transport.(ControlResponseTransport)
+	if !ok {
+		return fmt.Errorf("transport does not support control responses")
+	}
+	return ctrlTransport.SendControlResponse(ctx, resp)
+}
+
+// SupportsControlRequests reports whether the current transport supports
+// control requests.
+func (t *reconnectingTransport) SupportsControlRequests() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ctrlTransport, ok := t.current.(ControlRequestTransport)
+	return ok && ctrlTransport.SupportsControlRequests()
+}
+
+// GetValidator returns the validator of the current transport.
+func (t *reconnectingTransport) GetValidator() *StreamValidator {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current.GetValidator()
+}
+
+// Close stops forwarding and closes the current transport.
+func (t *reconnectingTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	current, running := t.current, t.running
+	t.mu.Unlock()
+
+	close(t.stop)
+	err := current.Close()
+	if running {
+		<-t.finished
+	}
+	return err
+}
+
+// await returns the current transport once it is usable.
+func (t *reconnectingTransport) await(ctx context.Context) (Transport, error) {
+	t.mu.Lock()
+	ready := t.ready
+	t.mu.Unlock()
+
+	select {
+	case <-ready:
+	case <-t.stop:
+		return nil, ErrClientClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current, nil
+}
+
+// run forwards the output of the transport, reconnecting each time its CLI
+// exits to restart.
+func (t *reconnectingTransport) run(msgs <-chan Message, errs <-chan error) {
+	defer close(t.finished)
+	defer close(t.msgs)
+	defer close(t.errs)
+
+	for {
+		var ok bool
+		if errs, ok = t.forward(msgs, errs); !ok {
+			return
+		}
+		code, restart := t.restartCode()
+		if !restart {
+			// Errors may still follow the end of the messages
+			t.forwardErrors(errs)
+			return
+		}
+		if msgs, errs, ok = t.reconnect(code); !ok {
+			return
+		}
+	}
+}
+
+// forward forwards messages and errors until the messages end, returning
+// the errors if they have not ended, or false once Close is called.
+func (t *reconnectingTransport) forward(msgs <-chan Message, errs <-chan error) (<-chan error, bool) {
+	for {
+		select {
+		case <-t.stop:
+			return nil, false
+		case msg, ok := <-msgs:
+			if !ok {
+				return errs, true
+			}
+			t.track(msg)
+			select {
+			case t.msgs <- msg:
+			case <-t.stop:
+				return nil, false
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			select {
+			case t.errs <- err:
+			case <-t.stop:
+				return nil, false
+			}
+		}
+	}
+}
+
+func (t *reconnectingTransport) forwardErrors(errs <-chan error) {
+	for errs != nil {
+		select {
+		case <-t.stop:
+			return
+		case err, ok := <-errs:
+			if !ok {
+				return
+			}
+			select {
+			case t.errs <- err:
+			case <-t.stop:
+				return
+			}
+		}
+	}
+}
+
+// track records the session to resume from the messages reporting it.
+func (t *reconnectingTransport) track(msg Message) {
+	var sessionID string
+	switch m := msg.(type) {
+	case *SystemMessage:
+		if info, ok := m.Init(); ok {
+			sessionID = info.SessionID
+		}
+	case *ResultMessage:
+		sessionID = m.SessionID
+	}
+	if sessionID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sessionID = sessionID
+}
+
+// restartCode returns the exit code of a CLI that exited to restart, and
+// reports whether it may be reconnected.
+func (t *reconnectingTransport) restartCode() (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	exiter, ok := t.current.(exitCodeTransport)
+	if !ok || t.closed {
+		return 0, false
+	}
+	code, exited := exiter.ExitCode()
+	if !exited || !t.restartsOn(code) {
+		return 0, false
+	}
+	limit := t.policy.MaxAttempts
+	if limit <= 0 {
+		limit = DefaultMaxReconnects
+	}
+	return code, t.attempts < limit
+}
+
+func (t *reconnectingTransport) restartsOn(code int) bool {
+	for _, restart := range t.policy.ExitCodes {
+		if code == restart {
+			return true
+		}
+	}
+	return false
+}
+
+// reconnect replaces the transport of a CLI that exited with code by one
+// resuming its session, returning the new transport's output. Sends wait
+// until it is connected. It reports false if the new transport could not
+// connect or Close was called.
+func (t *reconnectingTransport) reconnect(code int) (<-chan Message, <-chan error, bool) {
+	t.mu.Lock()
+	t.attempts++
+	ready := make(chan struct{})
+	t.ready = ready
+	ctx, old, restarting := t.ctx, t.current, t.restarting
+	event := ReconnectEvent{
+		Stage:     ReconnectStarted,
+		SessionID: t.sessionID,
+		ExitCode:  code,
+		Attempt:   t.attempts,
+		Time:      t.clock.Now(),
+	}
+	t.mu.Unlock()
+	started := event.Time
+	// Waiting sends fail with the old transport if no new one connects
+	defer close(ready)
+
+	if restarting != nil {
+		event.TurnInterrupted = restarting()
+	}
+	t.notify(event)
+	if event.TurnInterrupted {
+		select {
+		case t.errs <- fmt.Errorf("%w (exit code %d)", ErrCLIRestarted, code):
+		case <-t.stop:
+			return nil, nil, false
+		}
+	}
+
+	_ = old.Close()
+	next, err := t.dial(event.SessionID)
+	if err == nil {
+		err = next.Connect(ctx)
+	}
+	var msgs <-chan Message
+	var errs <-chan error
+	if err == nil {
+		msgs, errs = next.ReceiveMessages(ctx)
+	}
+
+	t.mu.Lock()
+	closed := t.closed
+	if err == nil && !closed {
+		t.current = next
+	}
+	t.mu.Unlock()
+	if err == nil && closed {
+		_ = next.Close()
+		return nil, nil, false
+	}
+
+	event.Time = t.clock.Now()
+	event.Duration = event.Time.Sub(started)
+	if err != nil {
+		event.Stage, event.Err = ReconnectFailed, err
+		t.notify(event)
+		return nil, nil, false
+	}
+	event.Stage = ReconnectCompleted
+	t.notify(event)
+	return msgs, errs, true
+}
+
+func (t *reconnectingTransport) notify(event ReconnectEvent) {
+	if t.policy.OnEvent != nil {
+		t.policy.OnEvent(event)
+	}
+}
+
+// reconnectDialer returns the dial function of a client's reconnecting
+// transport: a custom transport is reconnected as is, and otherwise the
+// CLI is found again, since an update may have moved it, and started
+// resuming the session.
+func (c *ClientImpl) reconnectDialer() func(string) (Transport, error) {
+	if c.customTransport != nil {
+		transport := c.customTransport
+		return func(string) (Transport, error) { return transport, nil }
+	}
+	options := c.options
+	return func(sessionID string) (Transport, error) {
+		resumed := *options
+		resumed.ContinueConversation = false
+		resumed.ForkSession = false
+		resumed.Resume = nil
+		if sessionID != "" {
+			resumed.Resume = &sessionID
+		}
+		cliPath, err := findCLI(&resumed)
+		if err != nil {
+			return nil, fmt.Errorf("claude CLI not found: %w", err)
+		}
+		return subprocess.New(cliPath, &resumed, false, "sdk-go-client"), nil
+	}
+}
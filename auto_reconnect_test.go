@@ -0,0 +1,168 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// restartExitCode is the exit code the tests' CLI restarts with.
+const restartExitCode = 75
+
+// exitingTransport is a mock transport whose CLI can exit with a code.
+type exitingTransport struct {
+	*clientMockTransport
+	exitCode int
+	exited   bool
+}
+
+func (e *exitingTransport) Connect(ctx context.Context) error {
+	e.mu.Lock()
+	e.exited = false
+	e.mu.Unlock()
+	return e.clientMockTransport.Connect(ctx)
+}
+
+func (e *exitingTransport) ExitCode() (int, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.exitCode, e.exited
+}
+
+// exit ends the output of the CLI, which exited with code.
+func (e *exitingTransport) exit(code int) {
+	e.mu.Lock()
+	e.exitCode, e.exited = code, true
+	e.mu.Unlock()
+	_ = e.clientMockTransport.Close()
+}
+
+// deliver sends msg once the transport is receiving messages again.
+func (e *exitingTransport) deliver(ctx context.Context, t *testing.T, msg Message) {
+	t.Helper()
+	for {
+		e.mu.Lock()
+		if e.msgChan != nil {
+			e.msgChan <- msg
+			e.mu.Unlock()
+			return
+		}
+		e.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			t.Fatal("Transport did not reconnect")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func sessionInitMessage(sessionID string) *SystemMessage {
+	return &SystemMessage{
+		Subtype: SystemSubtypeInit,
+		Data:    map[string]any{"type": "system", "subtype": "init", "session_id": sessionID},
+	}
+}
+
+func TestAutoReconnectResumesSession(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	events := make(chan ReconnectEvent, 4)
+	transport := &exitingTransport{clientMockTransport: newClientMockTransport()}
+	client := NewClientWithTransport(transport, WithAutoReconnect(AutoReconnectPolicy{
+		ExitCodes: []int{restartExitCode},
+		OnEvent:   func(event ReconnectEvent) { events <- event },
+	}))
+	// The new process outlives the context Connect was called with
+	connectCtx, connectCancel := context.WithCancel(ctx)
+	connectClientSafely(connectCtx, t, client)
+	connectCancel()
+	defer disconnectClientSafely(t, client)
+
+	iter := client.ReceiveResponse(ctx)
+	transport.deliver(ctx, t, sessionInitMessage("session-1"))
+	if _, err := iter.Next(ctx); err != nil {
+		t.Fatalf("Expected the init message, got %v", err)
+	}
+	assertNoError(t, client.Query(ctx, "Fix the build"))
+	transport.exit(restartExitCode)
+
+	// The interrupted turn ends with ErrCLIRestarted
+	if _, err := iter.Next(ctx); !errors.Is(err, ErrCLIRestarted) {
+		t.Fatalf("Expected ErrCLIRestarted, got %v", err)
+	}
+	started, completed := <-events, <-events
+	if started.Stage != ReconnectStarted || started.SessionID != "session-1" || started.ExitCode != restartExitCode ||
+		started.Attempt != 1 || !started.TurnInterrupted {
+		t.Errorf("Unexpected started event: %+v", started)
+	}
+	if completed.Stage != ReconnectCompleted || completed.Err != nil {
+		t.Errorf("Unexpected completed event: %+v", completed)
+	}
+
+	// The session continues behind the same channels
+	assertNoError(t, client.Query(ctx, "Try again"))
+	transport.deliver(ctx, t, &ResultMessage{Subtype: "success", SessionID: "session-1"})
+	awaitClientResult(ctx, t, client)
+	if exits := atomic.LoadInt32(&client.(*ClientImpl).processExits); exits != 1 {
+		t.Errorf("Expected 1 process exit, got %d", exits)
+	}
+}
+
+func TestAutoReconnectEndsStream(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy AutoReconnectPolicy
+		codes  []int
+	}{
+		{"not a listed code", AutoReconnectPolicy{ExitCodes: []int{restartExitCode}}, []int{1}},
+		{"attempts exhausted", AutoReconnectPolicy{ExitCodes: []int{restartExitCode}, MaxAttempts: 1}, []int{restartExitCode, restartExitCode}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var reconnects int32
+			test.policy.OnEvent = func(event ReconnectEvent) {
+				if event.Stage == ReconnectCompleted {
+					atomic.AddInt32(&reconnects, 1)
+				}
+			}
+			transport := &exitingTransport{clientMockTransport: newClientMockTransport()}
+			client := NewClientWithTransport(transport, WithAutoReconnect(test.policy))
+			connectClientSafely(ctx, t, client)
+			defer disconnectClientSafely(t, client)
+
+			msgs := client.ReceiveMessages(ctx)
+			for i, code := range test.codes {
+				if i > 0 {
+					// Wait for the previous reconnect
+					transport.deliver(ctx, t, sessionInitMessage("session-1"))
+					<-msgs
+				}
+				transport.exit(code)
+			}
+			select {
+			case _, ok := <-msgs:
+				if ok {
+					t.Fatal("Expected no message")
+				}
+			case <-ctx.Done():
+				t.Fatal("Expected the message stream to end")
+			}
+			if n := atomic.LoadInt32(&reconnects); int(n) != len(test.codes)-1 {
+				t.Errorf("Expected %d reconnects, got %d", len(test.codes)-1, n)
+			}
+		})
+	}
+}
+
+func TestAutoReconnectRequiresExitCodes(t *testing.T) {
+	client := NewClientWithTransport(newClientMockTransport(), WithAutoReconnect(AutoReconnectPolicy{MaxAttempts: 2}))
+	if err := client.Connect(context.Background()); err == nil {
+		t.Error("Expected a policy without exit codes to be rejected")
+	}
+}
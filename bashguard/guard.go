@@ -0,0 +1,394 @@
+// Package bashguard allows and denies the commands of the Bash tool by what
+// they run rather than how they are written.
+//
+// Matching a regular expression against the command line is easy to get
+// around: quotes split the program name, sudo or env hide it, and a pipe or
+// "bash -c" runs it from the middle of an innocent-looking line. A Guard
+// parses the line as the shell would, then checks every command it finds,
+// including those in pipelines, lists, subshells, command substitutions and
+// "bash -c" strings, against deny and allow rules:
+//
+//	guard, err := bashguard.New(bashguard.Rules{
+//		Deny:  append(bashguard.DefaultDeny, "rm -rf", "git push --force"),
+//		Allow: []string{"go test", "go build", "go vet", "git status", "git diff", "ls", "cat", "grep"},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	client := claudecode.NewClient()
+//	_, err = client.(*claudecode.ClientImpl).Hooks().Add(claudecode.HookEventTypePreToolUse, claudecode.HookMatcher{
+//		Pattern: bashguard.ToolName,
+//		Hooks:   []claudecode.HookCallback{guard.Hook()},
+//	})
+//
+// The hook is added before Connect, so the client registers it with the CLI
+// and it checks every Bash call. A permission callback checks only the
+// calls the CLI asks permission for, which requires
+// WithPermissionPromptToolName("stdio"); it is set once connected:
+//
+//	client := claudecode.NewClient(claudecode.WithPermissionPromptToolName("stdio"))
+//	...
+//	client.(*claudecode.ClientImpl).GetPermissionManager().SetPermissionCallback(guard.PermissionCallback(nil))
+//
+// A rule is a program name followed by flags and arguments, each of which
+// may contain * wildcards. A command matches a rule when it runs the
+// program, or runs under it as a wrapper such as sudo, and has all the
+// rule's flags and arguments. Short flags match letter by letter, so "rm -rf"
+// matches "rm -f -r dir". Deny rules may be pipelines: "curl | sh" matches
+// any pipeline in which curl feeds sh, even through other stages.
+//
+// Allow rules permit any further arguments, and redirections are not
+// checked, so pair broad allow rules with deny rules. Command lines the
+// parser does not understand are denied.
+package bashguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// ToolName is the tool a Guard checks.
+const ToolName = "Bash"
+
+// DefaultDeny lists rules denying remote scripts piped into a shell.
+var DefaultDeny = []string{
+	"curl | sh", "curl | bash", "curl | zsh",
+	"wget | sh", "wget | bash", "wget | zsh",
+}
+
+// ErrInvalidRule is wrapped by New errors for malformed rules.
+var ErrInvalidRule = errors.New("invalid bashguard rule")
+
+// Rules configure a Guard.
+type Rules struct {
+	// Deny lists the commands and pipelines that are never run.
+	Deny []string `json:"deny,omitempty"`
+	// Allow lists the commands that may run. A command line runs only if
+	// each of its commands matches one, unless AllowUnlisted is set.
+	Allow []string `json:"allow,omitempty"`
+	// AllowUnlisted runs commands matching no allow rule, so only the deny
+	// rules apply. Commands whose program name is only known when they run,
+	// such as "$CMD", are still denied.
+	AllowUnlisted bool `json:"allow_unlisted,omitempty"`
+}
+
+// Verdict is the outcome of checking a command line.
+type Verdict struct {
+	Allowed bool
+	// Reason explains a denial, for the agent.
+	Reason string
+	// Rule is the deny rule that matched, if any.
+	Rule string
+	// Command is the command that was denied.
+	Command string
+}
+
+// Guard checks Bash commands against rules. It is safe for concurrent use.
+type Guard struct {
+	deny          []rule
+	allow         []rule
+	allowUnlisted bool
+}
+
+// New returns a Guard applying rules, or an error wrapping ErrInvalidRule.
+func New(rules Rules) (*Guard, error) {
+	g := &Guard{allowUnlisted: rules.AllowUnlisted}
+	for _, source := range rules.Deny {
+		r, err := parseRule(source)
+		if err != nil {
+			return nil, err
+		}
+		g.deny = append(g.deny, r)
+	}
+	for _, source := range rules.Allow {
+		r, err := parseRule(source)
+		if err != nil {
+			return nil, err
+		}
+		if len(r.stages) > 1 {
+			return nil, fmt.Errorf("%w: allow rule %q is a pipeline; allow each command instead", ErrInvalidRule, source)
+		}
+		g.allow = append(g.allow, r)
+	}
+	return g, nil
+}
+
+// Check decides whether command may run. Deny rules are checked first,
+// then each command must match an allow rule.
+func (g *Guard) Check(command string) Verdict {
+	script, err := Parse(command)
+	if err != nil {
+		return Verdict{Reason: fmt.Sprintf("command could not be checked: %v", err)}
+	}
+	commands := script.Commands()
+	for _, r := range g.deny {
+		if len(r.stages) == 1 {
+			for _, c := range commands {
+				if r.stages[0].matches(c, false) {
+					return denied(c, r.source)
+				}
+			}
+			continue
+		}
+		for _, pipeline := range script.Pipelines {
+			if r.matchesPipeline(pipeline) {
+				return Verdict{
+					Reason:  fmt.Sprintf("pipeline denied by rule %q", r.source),
+					Rule:    r.source,
+					Command: pipelineString(pipeline),
+				}
+			}
+		}
+	}
+	for _, c := range commands {
+		if g.allowed(c) {
+			continue
+		}
+		if g.allowUnlisted && !dynamic(c.Name) {
+			continue
+		}
+		reason := fmt.Sprintf("%q is not an allowed command", c.String())
+		if dynamic(c.Name) {
+			reason = fmt.Sprintf("%q runs a program only known when it runs", c.String())
+		}
+		return Verdict{Reason: reason, Command: c.String()}
+	}
+	return Verdict{Allowed: true}
+}
+
+func (g *Guard) allowed(c Command) bool {
+	for _, r := range g.allow {
+		if r.stages[0].matches(c, true) {
+			return true
+		}
+	}
+	return false
+}
+
+func denied(c Command, rule string) Verdict {
+	return Verdict{
+		Reason:  fmt.Sprintf("%q denied by rule %q", c.String(), rule),
+		Rule:    rule,
+		Command: c.String(),
+	}
+}
+
+// PermissionCallback returns a permission callback that denies the Bash
+// commands the guard denies. next, if not nil, decides everything else;
+// otherwise it is allowed.
+func (g *Guard) PermissionCallback(next claudecode.CanUseToolFunc) claudecode.CanUseToolFunc {
+	return func(ctx context.Context, toolName string, input map[string]any, permContext claudecode.ToolPermissionContext) (claudecode.PermissionResult, error) {
+		if toolName == ToolName {
+			if verdict := g.checkInput(input); !verdict.Allowed {
+				return claudecode.NewPermissionResultDeny(verdict.Reason), nil
+			}
+		}
+		if next == nil {
+			return claudecode.NewPermissionResultAllow(), nil
+		}
+		return next(ctx, toolName, input, permContext)
+	}
+}
+
+// Hook returns a PreToolUse hook that stops denied Bash commands, with the
+// reason as its message. Other tools and events are ignored.
+func (g *Guard) Hook() claudecode.HookCallback {
+	return func(_ context.Context, input interface{}, _ claudecode.HookContext) (claudecode.HookOutput, error) {
+		var pre claudecode.PreToolUseHookInput
+		switch in := input.(type) {
+		case claudecode.PreToolUseHookInput:
+			pre = in
+		case *claudecode.PreToolUseHookInput:
+			pre = *in
+		default:
+			return claudecode.HookOutput{Behavior: claudecode.HookBehaviorContinue}, nil
+		}
+		if pre.ToolName == ToolName {
+			if verdict := g.checkInput(pre.ToolInput); !verdict.Allowed {
+				return claudecode.HookOutput{Behavior: claudecode.HookBehaviorStop, Message: verdict.Reason}, nil
+			}
+		}
+		return claudecode.HookOutput{Behavior: claudecode.HookBehaviorContinue}, nil
+	}
+}
+
+// checkInput checks the command of a Bash tool call.
+func (g *Guard) checkInput(input map[string]any) Verdict {
+	command, ok := input["command"].(string)
+	if !ok {
+		return Verdict{Reason: "Bash call without a command"}
+	}
+	return g.Check(command)
+}
+
+// rule is a parsed deny or allow rule.
+type rule struct {
+	source string
+	stages []commandRule
+}
+
+// commandRule matches a single command.
+type commandRule struct {
+	name  string
+	flags []string
+	words []string
+}
+
+func parseRule(source string) (rule, error) {
+	r := rule{source: source}
+	for _, stage := range strings.Split(source, "|") {
+		fields := strings.Fields(stage)
+		if len(fields) == 0 {
+			return rule{}, fmt.Errorf("%w: %q has an empty command", ErrInvalidRule, source)
+		}
+		c := commandRule{name: path.Base(fields[0])}
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "-") && field != "-" && field != "--" {
+				c.flags = append(c.flags, field)
+			} else {
+				c.words = append(c.words, field)
+			}
+		}
+		r.stages = append(r.stages, c)
+	}
+	return r, nil
+}
+
+// matchesPipeline reports whether the rule's stages appear in order among
+// the stages of pipeline.
+func (r rule) matchesPipeline(pipeline Pipeline) bool {
+	next := 0
+	for _, stage := range pipeline.Stages {
+		if next == len(r.stages) {
+			break
+		}
+		for _, c := range stage {
+			if r.stages[next].matches(c, false) {
+				next++
+				break
+			}
+		}
+	}
+	return next == len(r.stages)
+}
+
+// matches reports whether c runs the rule's program with all its flags and
+// arguments. Arguments may appear anywhere among the command's for deny
+// rules; for allow rules, the first must be the command's first, so "go
+// test" does not allow "go run test.go".
+func (r commandRule) matches(c Command, strict bool) bool {
+	if !glob(r.name, c.Name) {
+		// A wrapper such as sudo is matched by name alone
+		for _, wrapper := range c.Wrappers {
+			if len(r.flags) == 0 && len(r.words) == 0 && glob(r.name, wrapper) {
+				return true
+			}
+		}
+		return false
+	}
+	flags, words := splitArgs(c.Args)
+	for _, flag := range r.flags {
+		if !hasFlag(flags, flag) {
+			return false
+		}
+	}
+	if len(r.words) == 0 {
+		return true
+	}
+	if strict && (len(words) == 0 || !glob(r.words[0], words[0])) {
+		return false
+	}
+	next := 0
+	for _, word := range words {
+		if next < len(r.words) && glob(r.words[next], word) {
+			next++
+		}
+	}
+	return next == len(r.words)
+}
+
+// splitArgs separates flags from other arguments. Everything after "--" is
+// an argument.
+func splitArgs(args []string) (flags, words []string) {
+	for i, arg := range args {
+		if arg == "--" {
+			return flags, append(words, args[i+1:]...)
+		}
+		if strings.HasPrefix(arg, "-") && arg != "-" {
+			flags = append(flags, arg)
+		} else {
+			words = append(words, arg)
+		}
+	}
+	return flags, words
+}
+
+// hasFlag reports whether flags include want. A short flag cluster such as
+// "-rf" needs each of its letters, in any cluster; a long flag also matches
+// "--flag=value".
+func hasFlag(flags []string, want string) bool {
+	if strings.HasPrefix(want, "--") {
+		for _, flag := range flags {
+			if glob(want, flag) || strings.HasPrefix(flag, want+"=") {
+				return true
+			}
+		}
+		return false
+	}
+	for _, letter := range want[1:] {
+		found := false
+		for _, flag := range flags {
+			if !strings.HasPrefix(flag, "--") && strings.ContainsRune(flag[1:], letter) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// glob reports whether s matches pattern, in which * matches any text,
+// including slashes.
+func glob(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+// dynamic reports whether a program name comes from an expansion.
+func dynamic(name string) bool {
+	return strings.ContainsAny(name, "$`")
+}
+
+func pipelineString(pipeline Pipeline) string {
+	var stages []string
+	for _, stage := range pipeline.Stages {
+		var commands []string
+		for _, c := range stage {
+			commands = append(commands, c.String())
+		}
+		stages = append(stages, strings.Join(commands, "; "))
+	}
+	return strings.Join(stages, " | ")
+}
@@ -0,0 +1,827 @@
+package bashguard
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// maxNesting bounds how deep subshells, substitutions and "bash -c"
+// strings are parsed.
+const maxNesting = 16
+
+// ErrUnsupported is wrapped by Parse errors for shell syntax it does not
+// understand, such as case statements and function definitions.
+var ErrUnsupported = errors.New("unsupported shell syntax")
+
+// Command is a simple command.
+type Command struct {
+	// Name is the program run, without its directory, after variable
+	// assignments, reserved words such as "if" and "!", and wrappers such
+	// as sudo, env, timeout and xargs.
+	Name string
+	// Args are the words after Name, with quotes and escapes removed.
+	// Substitutions are left as "$(…)".
+	Args []string
+	// Redirects are the files the command reads and writes.
+	Redirects []Redirect
+	// Wrappers are the wrapper commands Name runs under, outermost first.
+	Wrappers []string
+}
+
+// String returns the command's words joined by spaces.
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Redirect is a redirection of a command, such as "> out.txt".
+type Redirect struct {
+	Op     string
+	Target string
+}
+
+// Pipeline is commands joined by pipes. A stage holds one command, or all
+// the commands of a subshell or { group }.
+type Pipeline struct {
+	Stages [][]Command
+}
+
+// Script is a parsed command line.
+type Script struct {
+	// Pipelines holds every pipeline of the command line, including those
+	// nested in subshells, groups, command and process substitutions,
+	// unquoted here-documents, "bash -c" strings and eval.
+	Pipelines []Pipeline
+
+	commands []Command
+}
+
+// Commands returns every simple command of the script, in the order they
+// were parsed.
+func (s *Script) Commands() []Command {
+	return append([]Command(nil), s.commands...)
+}
+
+// Parse parses a Bash command line. It handles quoting, escapes, pipes,
+// lists joined by ;, &, && and ||, subshells, { groups }, command and
+// process substitutions, redirections, here-documents and the clauses of
+// if, for and while, which is what agents write. Anything else fails with
+// an error wrapping ErrUnsupported.
+func Parse(command string) (*Script, error) {
+	script := &Script{}
+	if err := parseInto(script, command, 0); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+func parseInto(script *Script, src string, depth int) error {
+	if depth > maxNesting {
+		return fmt.Errorf("%w: nested more than %d levels", ErrUnsupported, maxNesting)
+	}
+	tokens, extra, err := lex(src)
+	if err != nil {
+		return err
+	}
+	p := &parser{tokens: tokens, script: script, depth: depth}
+	if err := p.list(""); err != nil {
+		return err
+	}
+	if p.pos < len(p.tokens) {
+		return fmt.Errorf("%w: unexpected %q", ErrUnsupported, p.tokens[p.pos].text)
+	}
+	for _, sub := range extra {
+		if err := parseInto(script, sub, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// token is a word or an operator.
+type token struct {
+	text   string
+	isWord bool
+	// subs are the sources of substitutions in a word.
+	subs []string
+}
+
+// heredoc is a here-document whose body follows the current line.
+type heredoc struct {
+	delimiter string
+	quoted    bool
+	stripTabs bool
+}
+
+// lex splits src into tokens. It returns the bodies of unquoted
+// here-documents separately, since their substitutions run too.
+func lex(src string) ([]token, []string, error) {
+	l := &lexer{src: src}
+	if err := l.run(); err != nil {
+		return nil, nil, err
+	}
+	return l.tokens, l.extra, nil
+}
+
+type lexer struct {
+	src      string
+	tokens   []token
+	extra    []string
+	word     strings.Builder
+	inWord   bool
+	subs     []string
+	heredocs []heredoc
+}
+
+func (l *lexer) flush() {
+	if l.inWord {
+		l.tokens = append(l.tokens, token{text: l.word.String(), isWord: true, subs: l.subs})
+	}
+	l.word.Reset()
+	l.inWord = false
+	l.subs = nil
+}
+
+func (l *lexer) op(text string) {
+	l.flush()
+	l.tokens = append(l.tokens, token{text: text})
+}
+
+func (l *lexer) run() error {
+	src := l.src
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.flush()
+		case c == '\n':
+			l.op(";")
+			next, err := l.readHeredocs(i + 1)
+			if err != nil {
+				return err
+			}
+			i = next - 1
+		case c == '#' && !l.inWord:
+			for i+1 < len(src) && src[i+1] != '\n' {
+				i++
+			}
+		case c == '\\':
+			if i+1 < len(src) {
+				i++
+				if src[i] != '\n' {
+					l.word.WriteByte(src[i])
+					l.inWord = true
+				}
+			}
+		case c == '\'':
+			end := strings.IndexByte(src[i+1:], '\'')
+			if end < 0 {
+				return errors.New("unterminated single quote")
+			}
+			l.word.WriteString(src[i+1 : i+1+end])
+			l.inWord = true
+			i += end + 1
+		case c == '"':
+			next, err := l.doubleQuoted(i + 1)
+			if err != nil {
+				return err
+			}
+			l.inWord = true
+			i = next
+		case c == '$' || c == '`':
+			next, err := l.expansion(i)
+			if err != nil {
+				return err
+			}
+			l.inWord = true
+			i = next
+		case c == '|':
+			switch {
+			case strings.HasPrefix(src[i:], "||"):
+				l.op("||")
+				i++
+			case strings.HasPrefix(src[i:], "|&"):
+				l.op("|")
+				i++
+			default:
+				l.op("|")
+			}
+		case c == '&':
+			switch {
+			case strings.HasPrefix(src[i:], "&&"):
+				l.op("&&")
+				i++
+			case strings.HasPrefix(src[i:], "&>>"):
+				l.op("&>>")
+				i += 2
+			case strings.HasPrefix(src[i:], "&>"):
+				l.op("&>")
+				i++
+			default:
+				l.op("&")
+			}
+		case c == ';':
+			if strings.HasPrefix(src[i:], ";;") {
+				return fmt.Errorf("%w: case statement", ErrUnsupported)
+			}
+			l.op(";")
+		case c == '(':
+			if l.inWord {
+				return fmt.Errorf("%w: function definition or array", ErrUnsupported)
+			}
+			l.op("(")
+		case c == ')':
+			l.op(")")
+		case c == '<' || c == '>':
+			next, err := l.redirect(i)
+			if err != nil {
+				return err
+			}
+			i = next
+		default:
+			l.word.WriteByte(c)
+			l.inWord = true
+		}
+	}
+	l.flush()
+	return nil
+}
+
+// doubleQuoted reads a double-quoted string starting after its opening
+// quote, returning the index of the closing quote.
+func (l *lexer) doubleQuoted(i int) (int, error) {
+	src := l.src
+	for ; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '"':
+			return i, nil
+		case '\\':
+			if i+1 < len(src) && strings.IndexByte("$`\"\\\n", src[i+1]) >= 0 {
+				i++
+				if src[i] != '\n' {
+					l.word.WriteByte(src[i])
+				}
+			} else {
+				l.word.WriteByte(c)
+			}
+		case '$', '`':
+			next, err := l.expansion(i)
+			if err != nil {
+				return 0, err
+			}
+			i = next
+		default:
+			l.word.WriteByte(c)
+		}
+	}
+	return 0, errors.New("unterminated double quote")
+}
+
+// expansion reads a $ or backtick expansion at i, returning the index of
+// its last byte. Command substitutions are recorded for parsing; variables
+// and arithmetic are kept as written.
+func (l *lexer) expansion(i int) (int, error) {
+	src := l.src
+	if src[i] == '`' {
+		var inner strings.Builder
+		for j := i + 1; j < len(src); j++ {
+			switch {
+			case src[j] == '\\' && j+1 < len(src):
+				j++
+				inner.WriteByte(src[j])
+			case src[j] == '`':
+				l.subs = append(l.subs, inner.String())
+				l.word.WriteString("$(…)")
+				return j, nil
+			default:
+				inner.WriteByte(src[j])
+			}
+		}
+		return 0, errors.New("unterminated backquote")
+	}
+
+	switch {
+	case strings.HasPrefix(src[i:], "$(("):
+		end, err := matchParen(src, i+1)
+		if err != nil {
+			return 0, err
+		}
+		l.word.WriteString(src[i : end+1])
+		return end, nil
+	case strings.HasPrefix(src[i:], "$("):
+		end, err := matchParen(src, i+1)
+		if err != nil {
+			return 0, err
+		}
+		l.subs = append(l.subs, src[i+2:end])
+		l.word.WriteString("$(…)")
+		return end, nil
+	case strings.HasPrefix(src[i:], "${"):
+		end := strings.IndexByte(src[i:], '}')
+		if end < 0 {
+			return 0, errors.New("unterminated parameter expansion")
+		}
+		l.word.WriteString(src[i : i+end+1])
+		return i + end, nil
+	}
+	l.word.WriteByte('$')
+	return i, nil
+}
+
+// redirect reads a redirection operator at i, returning the index of its
+// last byte.
+func (l *lexer) redirect(i int) (int, error) {
+	src := l.src
+	// A file descriptor number before the operator belongs to it
+	if l.inWord && strings.Trim(l.word.String(), "0123456789") == "" && len(l.subs) == 0 {
+		l.word.Reset()
+		l.inWord = false
+	}
+	rest := src[i:]
+	switch {
+	case strings.HasPrefix(rest, "<(") || strings.HasPrefix(rest, ">("):
+		end, err := matchParen(src, i+1)
+		if err != nil {
+			return 0, err
+		}
+		l.flush()
+		l.subs = append(l.subs, src[i+2:end])
+		l.word.WriteString(src[i:i+1] + "(…)")
+		l.inWord = true
+		return end, nil
+	case strings.HasPrefix(rest, "<<<"):
+		l.op("<<<")
+		return i + 2, nil
+	case strings.HasPrefix(rest, "<<"):
+		stripTabs := strings.HasPrefix(rest, "<<-")
+		start := i + 2
+		if stripTabs {
+			start++
+		}
+		l.op("<<")
+		delimiter, quoted, end := readDelimiter(src, start)
+		if delimiter == "" {
+			return 0, errors.New("here-document without a delimiter")
+		}
+		l.tokens = append(l.tokens, token{text: delimiter, isWord: true})
+		l.heredocs = append(l.heredocs, heredoc{delimiter: delimiter, quoted: quoted, stripTabs: stripTabs})
+		return end - 1, nil
+	}
+	for _, op := range []string{">>", ">&", ">|", "<&", "<>", ">", "<"} {
+		if strings.HasPrefix(rest, op) {
+			l.op(op)
+			return i + len(op) - 1, nil
+		}
+	}
+	return i, nil
+}
+
+// readDelimiter reads the delimiter word of a here-document, reporting
+// whether any of it was quoted, which turns off expansions in the body.
+func readDelimiter(src string, i int) (delimiter string, quoted bool, end int) {
+	for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+		i++
+	}
+	var b strings.Builder
+	for ; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\'' || c == '"':
+			quoted = true
+			n := strings.IndexByte(src[i+1:], c)
+			if n < 0 {
+				b.WriteString(src[i+1:])
+				return b.String(), true, len(src)
+			}
+			b.WriteString(src[i+1 : i+1+n])
+			i += n + 1
+		case c == '\\' && i+1 < len(src):
+			quoted = true
+			i++
+			b.WriteByte(src[i])
+		case strings.IndexByte(" \t\n;&|<>()", c) >= 0:
+			return b.String(), quoted, i
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), quoted, i
+}
+
+// readHeredocs skips the bodies of the pending here-documents, starting at
+// the line at i, and returns the index after them.
+func (l *lexer) readHeredocs(i int) (int, error) {
+	for _, doc := range l.heredocs {
+		var body string
+		body, i = heredocBody(l.src, i, doc)
+		if !doc.quoted {
+			subs, err := substitutions(body)
+			if err != nil {
+				return 0, err
+			}
+			l.extra = append(l.extra, subs...)
+		}
+	}
+	l.heredocs = nil
+	return i, nil
+}
+
+// substitutions returns the command substitutions in text that undergoes
+// expansion but not word splitting, such as a here-document body.
+func substitutions(text string) ([]string, error) {
+	l := &lexer{src: text}
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '$', '`':
+			next, err := l.expansion(i)
+			if err != nil {
+				return nil, err
+			}
+			i = next
+		}
+	}
+	return l.subs, nil
+}
+
+// matchParen returns the index of the parenthesis closing the one at i,
+// skipping quoted text and here-document bodies.
+func matchParen(src string, i int) (int, error) {
+	depth := 0
+	var delimiters []heredoc
+	for j := i; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '\'':
+			end := strings.IndexByte(src[j+1:], '\'')
+			if end < 0 {
+				return 0, errors.New("unterminated single quote")
+			}
+			j += end + 1
+		case '"':
+			for j++; j < len(src) && src[j] != '"'; j++ {
+				if src[j] == '\\' {
+					j++
+				}
+			}
+		case '<':
+			if strings.HasPrefix(src[j:], "<<<") {
+				j += 2
+			} else if strings.HasPrefix(src[j:], "<<") {
+				start := j + 2
+				stripTabs := strings.HasPrefix(src[j:], "<<-")
+				if stripTabs {
+					start++
+				}
+				delimiter, _, end := readDelimiter(src, start)
+				delimiters = append(delimiters, heredoc{delimiter: delimiter, stripTabs: stripTabs})
+				j = end - 1
+			}
+		case '\n':
+			for _, doc := range delimiters {
+				_, next := heredocBody(src, j+1, doc)
+				j = next - 1
+			}
+			delimiters = nil
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return j, nil
+			}
+		}
+	}
+	return 0, errors.New("unterminated parenthesis")
+}
+
+// heredocBody returns the body of doc starting at i and the index after
+// its closing delimiter line.
+func heredocBody(src string, i int, doc heredoc) (string, int) {
+	var body strings.Builder
+	for i < len(src) {
+		end := strings.IndexByte(src[i:], '\n')
+		line := src[i:]
+		if end >= 0 {
+			line = src[i : i+end]
+		}
+		i += len(line) + 1
+		check := line
+		if doc.stripTabs {
+			check = strings.TrimLeft(line, "\t")
+		}
+		if check == doc.delimiter {
+			break
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	if i > len(src) {
+		i = len(src)
+	}
+	return body.String(), i
+}
+
+// parser builds pipelines from tokens.
+type parser struct {
+	tokens []token
+	pos    int
+	script *Script
+	depth  int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// list parses pipelines joined by ;, &, && and || until the end of input
+// or the token end, which is left unread.
+func (p *parser) list(end string) error {
+	for {
+		tok, ok := p.peek()
+		if !ok {
+			return nil
+		}
+		if end != "" && tok.text == end && (tok.isWord == (end == "}")) {
+			return nil
+		}
+		if !tok.isWord && (tok.text == ";" || tok.text == "&" || tok.text == "&&" || tok.text == "||") {
+			p.pos++
+			continue
+		}
+		if !tok.isWord && tok.text == ")" {
+			return fmt.Errorf("%w: unexpected )", ErrUnsupported)
+		}
+		if err := p.pipeline(); err != nil {
+			return err
+		}
+	}
+}
+
+// pipeline parses stages joined by pipes and records the pipeline.
+func (p *parser) pipeline() error {
+	var pipeline Pipeline
+	for {
+		stage, err := p.stage()
+		if err != nil {
+			return err
+		}
+		if len(stage) > 0 {
+			pipeline.Stages = append(pipeline.Stages, stage)
+		}
+		tok, ok := p.peek()
+		if !ok || tok.isWord || tok.text != "|" {
+			break
+		}
+		p.pos++
+	}
+	if len(pipeline.Stages) > 0 {
+		p.script.Pipelines = append(p.script.Pipelines, pipeline)
+	}
+	return nil
+}
+
+// stage parses a subshell, a { group } or a simple command.
+func (p *parser) stage() ([]Command, error) {
+	tok, _ := p.peek()
+	if (!tok.isWord && tok.text == "(") || (tok.isWord && tok.text == "{") {
+		end := ")"
+		if tok.isWord {
+			end = "}"
+		}
+		p.pos++
+		first := len(p.script.Pipelines)
+		if err := p.list(end); err != nil {
+			return nil, err
+		}
+		if _, ok := p.peek(); !ok {
+			return nil, fmt.Errorf("unterminated %s", tok.text)
+		}
+		p.pos++
+		var commands []Command
+		for _, nested := range p.script.Pipelines[first:] {
+			for _, stage := range nested.Stages {
+				commands = append(commands, stage...)
+			}
+		}
+		// Redirections of the group apply to its commands as a whole
+		if err := p.skipRedirects(); err != nil {
+			return nil, err
+		}
+		return commands, nil
+	}
+
+	var words []string
+	var redirects []Redirect
+	for {
+		tok, ok := p.peek()
+		if !ok {
+			break
+		}
+		if tok.isWord {
+			p.pos++
+			words = append(words, tok.text)
+			if err := p.nested(tok.subs); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !isRedirect(tok.text) {
+			break
+		}
+		p.pos++
+		target, ok := p.peek()
+		if !ok || !target.isWord {
+			return nil, fmt.Errorf("redirection %s without a target", tok.text)
+		}
+		p.pos++
+		if err := p.nested(target.subs); err != nil {
+			return nil, err
+		}
+		redirects = append(redirects, Redirect{Op: tok.text, Target: target.text})
+	}
+
+	command, nested, ok := normalize(words)
+	for _, src := range nested {
+		if err := parseInto(p.script, src, p.depth+1); err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		return nil, nil
+	}
+	command.Redirects = redirects
+	p.script.commands = append(p.script.commands, command)
+	return []Command{command}, nil
+}
+
+func (p *parser) skipRedirects() error {
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.isWord || !isRedirect(tok.text) {
+			return nil
+		}
+		p.pos += 2
+		if p.pos > len(p.tokens) {
+			return fmt.Errorf("redirection %s without a target", tok.text)
+		}
+	}
+}
+
+// nested parses the substitutions of a word.
+func (p *parser) nested(subs []string) error {
+	for _, src := range subs {
+		if err := parseInto(p.script, src, p.depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isRedirect(op string) bool {
+	switch op {
+	case ">", ">>", "<", ">&", "<&", ">|", "<>", "&>", "&>>", "<<", "<<<":
+		return true
+	}
+	return false
+}
+
+// reservedWords start clauses rather than name programs. The words ending
+// clauses form no command of their own.
+var (
+	reservedWords = map[string]bool{
+		"if": true, "then": true, "else": true, "elif": true, "do": true,
+		"while": true, "until": true, "!": true,
+	}
+	closingWords = map[string]bool{"fi": true, "done": true}
+)
+
+// wrapperFlags lists the flags taking a value of each wrapper command,
+// which runs the command given after its own arguments.
+var wrapperFlags = map[string]map[string]bool{
+	"sudo":    {"-u": true, "-g": true, "-p": true, "-C": true, "-D": true, "-U": true, "-r": true, "-t": true, "-T": true, "-h": true},
+	"doas":    {"-u": true, "-C": true},
+	"env":     {"-u": true, "-C": true, "--unset": true, "--chdir": true},
+	"command": {},
+	"builtin": {},
+	"exec":    {"-a": true},
+	"nohup":   {},
+	"time":    {"-f": true, "-o": true},
+	"nice":    {"-n": true, "--adjustment": true},
+	"ionice":  {"-c": true, "-n": true, "-p": true},
+	"timeout": {"-s": true, "-k": true, "--signal": true, "--kill-after": true},
+	"stdbuf":  {"-i": true, "-o": true, "-e": true},
+	"xargs":   {"-I": true, "-n": true, "-P": true, "-L": true, "-d": true, "-E": true, "-s": true, "-a": true},
+}
+
+// shells run the string after -c.
+var shells = map[string]bool{"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true}
+
+// normalize turns the words of a simple command into a Command, returning
+// the sources it runs as commands of their own, such as the string of
+// "bash -c". It reports false for words that form no command.
+func normalize(words []string) (Command, []string, bool) {
+	var nested, wrappers []string
+	for {
+		// Variable assignments and reserved words before the program
+		for len(words) > 0 && (isAssignment(words[0]) || reservedWords[words[0]]) {
+			words = words[1:]
+		}
+		if len(words) == 0 || closingWords[words[0]] || words[0] == "}" {
+			return Command{}, nested, false
+		}
+		if words[0] == "for" || words[0] == "select" {
+			// The loop header: the words after "in" are data
+			return Command{}, nested, false
+		}
+
+		name := path.Base(words[0])
+		flags, isWrapper := wrapperFlags[name]
+		if !isWrapper {
+			break
+		}
+		rest := words[1:]
+		for len(rest) > 0 {
+			arg := rest[0]
+			if arg == "--" {
+				rest = rest[1:]
+				break
+			}
+			if name == "env" && isAssignment(arg) {
+				rest = rest[1:]
+				continue
+			}
+			if name == "env" && (arg == "-S" || arg == "--split-string") && len(rest) > 1 {
+				nested = append(nested, rest[1])
+				rest = rest[2:]
+				continue
+			}
+			if !strings.HasPrefix(arg, "-") || arg == "-" {
+				break
+			}
+			rest = rest[1:]
+			if flags[arg] && len(rest) > 0 {
+				rest = rest[1:]
+			}
+		}
+		// timeout takes its duration before the command
+		if name == "timeout" && len(rest) > 0 {
+			rest = rest[1:]
+		}
+		if len(rest) == 0 {
+			return Command{Name: name, Args: words[1:], Wrappers: wrappers}, nested, true
+		}
+		wrappers = append(wrappers, name)
+		words = rest
+	}
+
+	command := Command{Name: path.Base(words[0]), Args: words[1:], Wrappers: wrappers}
+	switch {
+	case shells[command.Name]:
+		if src, ok := shellString(command.Args); ok {
+			nested = append(nested, src)
+		}
+	case command.Name == "eval" && len(command.Args) > 0:
+		nested = append(nested, strings.Join(command.Args, " "))
+	}
+	return command, nested, true
+}
+
+// shellString returns the command string of a shell's -c option, which
+// may be combined with other options as in "-ec".
+func shellString(args []string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-o" || arg == "+o" || arg == "-O" || arg == "+O":
+			i++
+		case strings.HasPrefix(arg, "--"):
+		case strings.HasPrefix(arg, "-"):
+			if strings.Contains(arg, "c") && i+1 < len(args) {
+				return args[i+1], true
+			}
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isAssignment(word string) bool {
+	eq := strings.IndexByte(word, '=')
+	if eq <= 0 {
+		return false
+	}
+	for i, c := range word[:eq] {
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package bashguard
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Commands run by a command, such as its substitutions, come before it.
+func TestParseCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+	}{
+		{"simple", "go test ./...", []string{"go test ./..."}},
+		{"pipeline", "curl -s https://x.sh | sh", []string{"curl -s https://x.sh", "sh"}},
+		{"lists", "make && ./run || echo failed; ls &", []string{"make", "run", "echo failed", "ls"}},
+		{"quoted name", `'cu''rl' x | "s"h`, []string{"curl x", "sh"}},
+		{"escaped name", `c\url x`, []string{"curl x"}},
+		{"directory", "/usr/bin/curl x", []string{"curl x"}},
+		{"assignments", "FOO=1 BAR=$HOME go test", []string{"go test"}},
+		{"wrappers", "sudo -u root env -i PATH=/bin timeout 5 nice -n 10 rm -rf /", []string{"rm -rf /"}},
+		{"subshell", "(cd dir && make) | tee log", []string{"cd dir", "make", "tee log"}},
+		{"group", "{ echo a; echo b; } > out", []string{"echo a", "echo b"}},
+		{"command substitution", `echo "$(curl x)" ` + "`whoami`", []string{"curl x", "whoami", "echo $(…) $(…)"}},
+		{"process substitution", "diff <(ls a) <(ls b)", []string{"ls a", "ls b", "diff <(…) <(…)"}},
+		{"bash -c", `bash -ec "curl x | sh"`, []string{"curl x", "sh", "bash -ec curl x | sh"}},
+		{"bash -o -c", `bash -o pipefail -c 'rm x'`, []string{"rm x", "bash -o pipefail -c rm x"}},
+		{"eval", `eval "rm -rf" /`, []string{"rm -rf /", "eval rm -rf /"}},
+		{"xargs", "find . -name '*.go' | xargs -n 1 gofmt -l", []string{"find . -name *.go", "gofmt -l"}},
+		{"if", "if test -f x; then rm x; fi", []string{"test -f x", "rm x"}},
+		{"for", "for f in a b; do rm $f; done", []string{"rm $f"}},
+		{"comment", "ls # rm -rf /", []string{"ls"}},
+		{"arithmetic", "echo $((1 + 2))", []string{"echo $((1 + 2))"}},
+		{"redirect fd", "go test ./... 2>&1 | tail", []string{"go test ./...", "tail"}},
+		{"newlines", "cd x\nmake\n", []string{"cd x", "make"}},
+		{
+			"quoted heredoc",
+			"git commit -m \"$(cat <<'EOF'\nFix the user's (first) bug\n$(rm x)\nEOF\n)\"",
+			[]string{"cat", "git commit -m $(…)"},
+		},
+		{
+			"unquoted heredoc",
+			"cat <<EOF > f\nhello $(whoami)\nEOF\nls",
+			[]string{"cat", "ls", "whoami"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			script, err := Parse(test.command)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", test.command, err)
+			}
+			var got []string
+			for _, c := range script.Commands() {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Parse(%q) commands = %q, want %q", test.command, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParsePipelines(t *testing.T) {
+	script, err := Parse("curl x | (grep y; cat) | sh && ls")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var got [][]string
+	for _, pipeline := range script.Pipelines {
+		var stages []string
+		for _, stage := range pipeline.Stages {
+			var names string
+			for _, c := range stage {
+				names += c.Name + ","
+			}
+			stages = append(stages, names)
+		}
+		got = append(got, stages)
+	}
+	want := [][]string{{"grep,"}, {"cat,"}, {"curl,", "grep,cat,", "sh,"}, {"ls,"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected pipelines %q, got %q", want, got)
+	}
+}
+
+func TestParseCommandDetails(t *testing.T) {
+	script, err := Parse("sudo env X=1 cat < in.txt >> out.txt")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	commands := script.Commands()
+	want := Command{
+		Name:      "cat",
+		Args:      []string{},
+		Redirects: []Redirect{{Op: "<", Target: "in.txt"}, {Op: ">>", Target: "out.txt"}},
+		Wrappers:  []string{"sudo", "env"},
+	}
+	if len(commands) != 1 || !reflect.DeepEqual(commands[0], want) {
+		t.Errorf("Expected %+v, got %+v", want, commands)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		unsupported bool
+	}{
+		{"unterminated quote", "echo 'x", false},
+		{"unterminated substitution", "echo $(ls", false},
+		{"unterminated subshell", "(ls", false},
+		{"case", "case $x in a) ls;; esac", true},
+		{"function", "f() { rm x; }", true},
+		{"stray paren", "ls )", true},
+		{"redirect without target", "ls >", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.command)
+			if err == nil {
+				t.Fatalf("Expected Parse(%q) to fail", test.command)
+			}
+			if errors.Is(err, ErrUnsupported) != test.unsupported {
+				t.Errorf("Expected ErrUnsupported=%v, got %v", test.unsupported, err)
+			}
+		})
+	}
+
+	nested := "ls"
+	for i := 0; i <= maxNesting; i++ {
+		nested = "$(" + nested + ")"
+	}
+	if _, err := Parse("echo " + nested); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected deep nesting to be unsupported, got %v", err)
+	}
+}
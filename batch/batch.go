@@ -0,0 +1,430 @@
+// Package batch runs many agent runs with bounded parallelism, for prompt
+// sweeps and scheduled jobs.
+//
+// A Runner executes Jobs with claudecode.Run, a few at a time. It reports
+// each run's progress to a callback, keeps total spend within a budget and
+// a rate, and records every finished job in a state file, so a batch that
+// was interrupted can be started again and only runs what is left:
+//
+//	runner := &batch.Runner{
+//		Concurrency: 8,
+//		StateFile:   "sweep.state.json",
+//		MaxCostUSD:  25,
+//		SpendRate:   batch.SpendRate{USD: 2, Per: time.Minute},
+//		OnProgress: func(p batch.Progress) {
+//			log.Printf("%d/%d %s %s", p.Completed, p.Total, p.JobID, p.Status)
+//		},
+//	}
+//	report, err := runner.Run(ctx, jobs)
+//
+// Jobs are identified by ID across restarts, so IDs must be stable and
+// unique. Jobs that finished, successfully or not, are not run again;
+// jobs that returned an error, including those canceled by an interruption,
+// are.
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// DefaultConcurrency is how many jobs run at once when
+// Runner.Concurrency is not set.
+const DefaultConcurrency = 4
+
+// stateVersion is the version of the state file format.
+const stateVersion = 1
+
+// ErrBudgetExceeded is returned by Runner.Run when jobs were left unrun
+// because total spend reached MaxCostUSD.
+var ErrBudgetExceeded = errors.New("batch budget exceeded")
+
+// Status is the state of a job.
+type Status string
+
+// Job statuses. StatusSucceeded and StatusFailed are final and kept across
+// restarts; StatusError jobs run again on the next start.
+const (
+	StatusPending   Status = "pending"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusError     Status = "error"
+)
+
+// done reports whether a job with the status needs no further run.
+func (s Status) done() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// Job is one run of a batch.
+type Job struct {
+	ID   string
+	Spec claudecode.RunSpec
+}
+
+// RunFunc runs one job. claudecode.Run is the default.
+type RunFunc func(ctx context.Context, spec claudecode.RunSpec) (*claudecode.RunResult, error)
+
+// SpendRate limits how fast a batch spends: no job starts while the runs
+// finished within the last Per cost USD or more.
+type SpendRate struct {
+	USD float64
+	Per time.Duration
+}
+
+// Outcome is the recorded result of a job, as kept in the state file.
+type Outcome struct {
+	ID           string        `json:"id"`
+	Status       Status        `json:"status"`
+	Text         string        `json:"text,omitempty"`
+	SessionID    string        `json:"session_id,omitempty"`
+	FilesChanged []string      `json:"files_changed,omitempty"`
+	CostUSD      float64       `json:"cost_usd"`
+	Duration     time.Duration `json:"duration"`
+	Error        string        `json:"error,omitempty"`
+	FinishedAt   time.Time     `json:"finished_at"`
+}
+
+// Progress reports a job starting or finishing.
+type Progress struct {
+	JobID  string
+	Status Status
+	// Result and Err are those of a finished run. Result is nil for a
+	// starting job and may be nil for a run that failed with an error.
+	Result *claudecode.RunResult
+	Err    error
+	// Completed counts the jobs with a final status, including those
+	// restored from the state file, out of Total.
+	Completed int
+	Total     int
+	// CostUSD is the batch's total spend so far.
+	CostUSD float64
+}
+
+// Report is the outcome of a batch.
+type Report struct {
+	// Outcomes has one entry per job, in job order. Jobs left unrun are
+	// StatusPending.
+	Outcomes []Outcome
+	// Restored counts the jobs whose outcome came from the state file.
+	Restored int
+	CostUSD  float64
+}
+
+// Succeeded returns the number of jobs that succeeded.
+func (r *Report) Succeeded() int {
+	n := 0
+	for _, outcome := range r.Outcomes {
+		if outcome.Status == StatusSucceeded {
+			n++
+		}
+	}
+	return n
+}
+
+// Runner runs batches of jobs. The zero value runs DefaultConcurrency jobs
+// at a time without limits or a state file.
+type Runner struct {
+	Concurrency int
+	// StateFile, when set, records each finished job; Run restores it on
+	// start and skips the jobs already done.
+	StateFile string
+	// MaxCostUSD stops starting jobs once the total spend, including that
+	// of restored jobs, reaches it. Zero means no budget. Runs in flight
+	// are not stopped, so the total may end above the budget.
+	MaxCostUSD float64
+	// SpendRate, when both fields are set, delays job starts to keep
+	// spend within the rate.
+	SpendRate SpendRate
+	// OnProgress is called when a job starts and when it finishes. Calls
+	// are serialized.
+	OnProgress func(Progress)
+	// RunJob runs each job; the default is claudecode.Run.
+	RunJob RunFunc
+}
+
+// batchRun is the state of one Runner.Run call.
+type batchRun struct {
+	runner   *Runner
+	outcomes []Outcome
+	index    map[string]int
+	state    map[string]Outcome
+
+	mu        sync.Mutex
+	completed int
+	cost      float64
+	spends    []spend
+	saveErr   error
+}
+
+// spend is the cost of a run finished at a time, for the spend rate.
+type spend struct {
+	at  time.Time
+	usd float64
+}
+
+// Run runs jobs and returns their outcomes. It returns when all jobs
+// finished, ctx ended or the budget was reached, after waiting for the jobs
+// in flight. Jobs that fail are reported in their outcomes, not as an
+// error; errors are returned for invalid jobs, an unreadable or unwritable
+// state file, ctx ending and an exceeded budget, along with the report.
+func (r *Runner) Run(ctx context.Context, jobs []Job) (*Report, error) {
+	b := &batchRun{
+		runner:   r,
+		outcomes: make([]Outcome, len(jobs)),
+		index:    make(map[string]int, len(jobs)),
+	}
+	for i, job := range jobs {
+		if job.ID == "" {
+			return nil, fmt.Errorf("job %d has no ID", i)
+		}
+		if _, ok := b.index[job.ID]; ok {
+			return nil, fmt.Errorf("duplicate job ID %q", job.ID)
+		}
+		b.index[job.ID] = i
+		b.outcomes[i] = Outcome{ID: job.ID, Status: StatusPending}
+	}
+
+	state, err := loadState(r.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	b.state = state
+	report := &Report{}
+	for i, job := range jobs {
+		if outcome, ok := state[job.ID]; ok && outcome.Status.done() {
+			b.outcomes[i] = outcome
+			b.completed++
+			b.cost += outcome.CostUSD
+			report.Restored++
+		}
+	}
+
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	run := r.RunJob
+	if run == nil {
+		run = claudecode.Run
+	}
+
+	var runErr error
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	for i, job := range jobs {
+		if b.outcomes[i].Status.done() {
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() == nil {
+			runErr = b.waitToStart(ctx)
+		} else {
+			runErr = ctx.Err()
+		}
+		if runErr != nil {
+			break
+		}
+
+		wg.Add(1)
+		b.started(job.ID)
+		go func(job Job) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result, err := run(ctx, job.Spec)
+			b.finished(job.ID, result, err)
+		}(job)
+	}
+	wg.Wait()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	report.Outcomes = b.outcomes
+	report.CostUSD = b.cost
+	if runErr == nil {
+		runErr = b.saveErr
+	}
+	return report, runErr
+}
+
+// waitToStart blocks until the budget and spend rate allow another job.
+func (b *batchRun) waitToStart(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		if b.runner.MaxCostUSD > 0 && b.cost >= b.runner.MaxCostUSD {
+			cost := b.cost
+			b.mu.Unlock()
+			return fmt.Errorf("%w: spent $%.4f of $%.4f", ErrBudgetExceeded, cost, b.runner.MaxCostUSD)
+		}
+		wait := b.rateWait(time.Now())
+		b.mu.Unlock()
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// rateWait returns how long to wait before spend within the rate's window
+// drops below its limit. b.mu must be held.
+func (b *batchRun) rateWait(now time.Time) time.Duration {
+	rate := b.runner.SpendRate
+	if rate.USD <= 0 || rate.Per <= 0 {
+		return 0
+	}
+	windowStart := now.Add(-rate.Per)
+	kept := b.spends[:0]
+	total := 0.0
+	for _, s := range b.spends {
+		if s.at.After(windowStart) {
+			kept = append(kept, s)
+			total += s.usd
+		}
+	}
+	b.spends = kept
+
+	// Wait for the oldest spends to leave the window until below the limit
+	for _, s := range b.spends {
+		if total < rate.USD {
+			break
+		}
+		total -= s.usd
+		if total < rate.USD {
+			return s.at.Add(rate.Per).Sub(now)
+		}
+	}
+	return 0
+}
+
+func (b *batchRun) started(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.outcomes[b.index[id]].Status = StatusRunning
+	b.progress(Progress{JobID: id, Status: StatusRunning})
+}
+
+func (b *batchRun) finished(id string, result *claudecode.RunResult, err error) {
+	outcome := Outcome{ID: id, FinishedAt: time.Now()}
+	switch {
+	case err != nil:
+		outcome.Status = StatusError
+		outcome.Error = err.Error()
+	case result != nil && result.Success:
+		outcome.Status = StatusSucceeded
+	default:
+		outcome.Status = StatusFailed
+	}
+	if result != nil {
+		outcome.Text = result.Text
+		outcome.SessionID = result.SessionID
+		outcome.FilesChanged = result.FilesChanged
+		outcome.CostUSD = result.CostUSD
+		outcome.Duration = result.Duration
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.outcomes[b.index[id]] = outcome
+	b.cost += outcome.CostUSD
+	b.spends = append(b.spends, spend{at: outcome.FinishedAt, usd: outcome.CostUSD})
+	if outcome.Status.done() {
+		b.completed++
+	}
+	b.state[id] = outcome
+	if err := saveState(b.runner.StateFile, b.state); err != nil && b.saveErr == nil {
+		b.saveErr = err
+	}
+	b.progress(Progress{JobID: id, Status: outcome.Status, Result: result, Err: err})
+}
+
+// progress reports p with the batch totals. b.mu must be held, which
+// serializes the callbacks.
+func (b *batchRun) progress(p Progress) {
+	if b.runner.OnProgress == nil {
+		return
+	}
+	p.Completed = b.completed
+	p.Total = len(b.outcomes)
+	p.CostUSD = b.cost
+	b.runner.OnProgress(p)
+}
+
+// stateFile is the format of the state file.
+type stateFile struct {
+	Version  int                `json:"version"`
+	Outcomes map[string]Outcome `json:"outcomes"`
+}
+
+// loadState reads the outcomes recorded in path. A missing file, or no
+// path, is an empty state.
+func loadState(path string) (map[string]Outcome, error) {
+	outcomes := make(map[string]Outcome)
+	if path == "" {
+		return outcomes, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return outcomes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading batch state: %w", err)
+	}
+	var state stateFile
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("decoding batch state %s: %w", path, err)
+	}
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf("batch state %s has unsupported version %d", path, state.Version)
+	}
+	for id, outcome := range state.Outcomes {
+		outcomes[id] = outcome
+	}
+	return outcomes, nil
+}
+
+// saveState writes outcomes to path through a temporary file, so an
+// interruption never leaves a partial state.
+func saveState(path string, outcomes map[string]Outcome) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(stateFile{Version: stateVersion, Outcomes: outcomes}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding batch state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing batch state: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("writing batch state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestRunnerRun(t *testing.T) {
+	runs := &fakeRuns{failing: map[string]bool{"c": true}, erroring: map[string]bool{"d": true}, delay: 10 * time.Millisecond}
+	var mu sync.Mutex
+	var progress []Progress
+	runner := &Runner{
+		Concurrency: 2,
+		RunJob:      runs.run,
+		OnProgress: func(p Progress) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, p)
+		},
+	}
+
+	report, err := runner.Run(context.Background(), jobs("a", "b", "c", "d", "e"))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want := []Status{StatusSucceeded, StatusSucceeded, StatusFailed, StatusError, StatusSucceeded}
+	for i, status := range want {
+		if outcome := report.Outcomes[i]; outcome.Status != status || outcome.ID != string(rune('a'+i)) {
+			t.Errorf("Expected outcome %d to be %s, got %+v", i, status, outcome)
+		}
+	}
+	if report.Outcomes[3].Error == "" || report.Outcomes[0].Text != "done a" {
+		t.Errorf("Expected the error and text recorded, got %+v", report.Outcomes)
+	}
+	if report.Succeeded() != 3 || report.CostUSD < 0.0399 || report.CostUSD > 0.0401 {
+		t.Errorf("Expected 3 successes costing $0.04, got %d and %v", report.Succeeded(), report.CostUSD)
+	}
+	if runs.maxActive != 2 {
+		t.Errorf("Expected at most 2 concurrent runs, got %d", runs.maxActive)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(progress) != 10 {
+		t.Fatalf("Expected a start and finish per job, got %d", len(progress))
+	}
+	last := progress[len(progress)-1]
+	if last.Completed != 4 || last.Total != 5 {
+		t.Errorf("Expected 4 of 5 completed at the end, got %+v", last)
+	}
+}
+
+func TestRunnerResume(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	runs := &fakeRuns{erroring: map[string]bool{"b": true}}
+	runner := &Runner{StateFile: stateFile, RunJob: runs.run}
+	if _, err := runner.Run(context.Background(), jobs("a", "b", "c")); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	runs = &fakeRuns{}
+	runner.RunJob = runs.run
+	report, err := runner.Run(context.Background(), jobs("a", "b", "c", "d"))
+	if err != nil {
+		t.Fatalf("Resumed run failed: %v", err)
+	}
+	if got := runs.ran(); len(got) != 2 || !got["b"] || !got["d"] {
+		t.Errorf("Expected only b and d to run again, got %v", got)
+	}
+	if report.Restored != 2 || report.Succeeded() != 4 {
+		t.Errorf("Expected 2 restored jobs and 4 successes, got %+v", report)
+	}
+	if report.CostUSD < 0.0399 || report.CostUSD > 0.0401 {
+		t.Errorf("Expected the restored cost counted, got %v", report.CostUSD)
+	}
+	if _, err := os.Stat(stateFile); err != nil {
+		t.Errorf("Expected the state file to exist: %v", err)
+	}
+}
+
+func TestRunnerBudget(t *testing.T) {
+	runs := &fakeRuns{}
+	runner := &Runner{Concurrency: 1, MaxCostUSD: 0.025, RunJob: runs.run}
+	report, err := runner.Run(context.Background(), jobs("a", "b", "c", "d", "e"))
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	if report.Succeeded() != 3 || report.Outcomes[3].Status != StatusPending || report.Outcomes[4].Status != StatusPending {
+		t.Errorf("Expected 3 runs before the budget stopped the batch, got %+v", report.Outcomes)
+	}
+}
+
+func TestRunnerCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runs := &fakeRuns{}
+	report, err := (&Runner{RunJob: runs.run}).Run(ctx, jobs("a", "b"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if len(runs.ran()) != 0 || report.Outcomes[0].Status != StatusPending {
+		t.Errorf("Expected no runs, got %v and %+v", runs.ran(), report.Outcomes)
+	}
+}
+
+func TestRunnerInvalid(t *testing.T) {
+	runner := &Runner{RunJob: (&fakeRuns{}).run}
+	if _, err := runner.Run(context.Background(), jobs("a", "a")); err == nil {
+		t.Error("Expected an error for duplicate IDs")
+	}
+	if _, err := runner.Run(context.Background(), jobs("")); err == nil {
+		t.Error("Expected an error for an empty ID")
+	}
+
+	runner.StateFile = filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(runner.StateFile, []byte(`{"version": 99}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Run(context.Background(), jobs("a")); err == nil {
+		t.Error("Expected an error for an unsupported state version")
+	}
+}
+
+func TestRateWait(t *testing.T) {
+	now := time.Now()
+	b := &batchRun{runner: &Runner{SpendRate: SpendRate{USD: 1, Per: time.Minute}}}
+	b.spends = []spend{
+		{at: now.Add(-2 * time.Minute), usd: 5},
+		{at: now.Add(-50 * time.Second), usd: 0.6},
+		{at: now.Add(-10 * time.Second), usd: 0.6},
+	}
+	if wait := b.rateWait(now); wait != 10*time.Second {
+		t.Errorf("Expected to wait for the oldest spend in the window, got %v", wait)
+	}
+	if len(b.spends) != 2 {
+		t.Errorf("Expected spends outside the window dropped, got %d", len(b.spends))
+	}
+	b.spends = b.spends[1:]
+	if wait := b.rateWait(now); wait != 0 {
+		t.Errorf("Expected no wait under the limit, got %v", wait)
+	}
+}
+
+// fakeRuns succeeds each job at $0.01 unless it is failing or erroring.
+type fakeRuns struct {
+	failing  map[string]bool
+	erroring map[string]bool
+	delay    time.Duration
+
+	mu        sync.Mutex
+	started   map[string]bool
+	active    int
+	maxActive int
+}
+
+func (f *fakeRuns) run(ctx context.Context, spec claudecode.RunSpec) (*claudecode.RunResult, error) {
+	id := spec.Prompt
+	f.mu.Lock()
+	if f.started == nil {
+		f.started = make(map[string]bool)
+	}
+	f.started[id] = true
+	f.active++
+	if f.active > f.maxActive {
+		f.maxActive = f.active
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.active--
+		f.mu.Unlock()
+	}()
+
+	time.Sleep(f.delay)
+	if f.erroring[id] {
+		return nil, errors.New("cli crashed")
+	}
+	return &claudecode.RunResult{Text: "done " + id, CostUSD: 0.01, Success: !f.failing[id]}, nil
+}
+
+func (f *fakeRuns) ran() map[string]bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ran := make(map[string]bool, len(f.started))
+	for id := range f.started {
+		ran[id] = true
+	}
+	return ran
+}
+
+// jobs returns a job per ID, prompting with the ID.
+func jobs(ids ...string) []Job {
+	jobs := make([]Job, len(ids))
+	for i, id := range ids {
+		jobs[i] = Job{ID: id, Spec: claudecode.RunSpec{Prompt: id}}
+	}
+	return jobs
+}
@@ -0,0 +1,328 @@
+package changes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FilePatch is the net change to one file.
+type FilePatch struct {
+	// Path is the file as named by the changes.
+	Path string
+	// Name is the path shown in diffs.
+	Name string
+	// Before is the content before the changes; Existed is false when the
+	// changes created the file.
+	Before  string
+	Existed bool
+	// After is the current content.
+	After string
+}
+
+// Diff returns the unified diff of the patch, or "" when the file is
+// unchanged.
+func (p FilePatch) Diff() string {
+	oldName, newName := "a/"+p.Name, "b/"+p.Name
+	if !p.Existed {
+		oldName = ""
+	}
+	return unifiedDiff(oldName, newName, p.Before, p.After)
+}
+
+// fileState is a file's content; exists is false for a missing file.
+type fileState struct {
+	content string
+	exists  bool
+}
+
+// Patches compares each file the changes touch with its state before the
+// changes, worked out by undoing them in memory from the current content.
+func Patches(changes []Change, opts ...Option) ([]FilePatch, error) {
+	c := newConfig(opts)
+	var patches []FilePatch
+	for _, path := range Paths(changes) {
+		current, err := readState(c.resolve(path))
+		if err != nil {
+			return nil, err
+		}
+		before := current
+		forFile := changesFor(changes, path)
+		for i := len(forFile) - 1; i >= 0; i-- {
+			if before, err = undo(before, forFile[i]); err != nil {
+				return nil, err
+			}
+		}
+		patches = append(patches, FilePatch{
+			Path:    path,
+			Name:    c.displayName(path),
+			Before:  before.content,
+			Existed: before.exists,
+			After:   current.content,
+		})
+	}
+	return patches, nil
+}
+
+// Diff returns a unified diff of every file the changes touch, against its
+// state before the changes.
+func Diff(changes []Change, opts ...Option) (string, error) {
+	patches, err := Patches(changes, opts...)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, patch := range patches {
+		b.WriteString(patch.Diff())
+	}
+	return b.String(), nil
+}
+
+// Revert restores every file the changes touch to its state before the
+// changes, removing files they created. Nothing is written unless every
+// file can be restored.
+func Revert(changes []Change, opts ...Option) error {
+	c := newConfig(opts)
+	patches, err := Patches(changes, opts...)
+	if err != nil {
+		return err
+	}
+	for _, patch := range patches {
+		path := c.resolve(patch.Path)
+		if !patch.Existed {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		if err := writeFile(path, patch.Before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply makes the changes to the files they name, in order. Nothing is
+// written unless every change fits; a change that does not is reported as
+// a ConflictError.
+func Apply(changes []Change, opts ...Option) error {
+	c := newConfig(opts)
+	updated := make(map[string]fileState)
+	paths := Paths(changes)
+	for _, path := range paths {
+		state, err := readState(c.resolve(path))
+		if err != nil {
+			return err
+		}
+		for _, change := range changesFor(changes, path) {
+			if state, err = apply(state, change); err != nil {
+				return err
+			}
+		}
+		updated[path] = state
+	}
+	for _, path := range paths {
+		if err := writeFile(c.resolve(path), updated[path].content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func changesFor(changes []Change, path string) []Change {
+	var forFile []Change
+	for _, change := range changes {
+		if change.Path == path {
+			forFile = append(forFile, change)
+		}
+	}
+	return forFile
+}
+
+func readState(path string) (fileState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fileState{}, nil
+	}
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{content: string(data), exists: true}, nil
+}
+
+// writeFile writes content, keeping the mode of an existing file.
+func writeFile(path, content string) error {
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	} else if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), mode)
+}
+
+// apply returns the file state after change.
+func apply(state fileState, change Change) (fileState, error) {
+	conflict := func(format string, args ...any) (fileState, error) {
+		return fileState{}, &ConflictError{Change: change, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	switch change.Kind {
+	case KindEdit:
+		if change.OldString == "" {
+			// An edit with nothing to replace creates the file
+			if state.exists {
+				return conflict("file already exists")
+			}
+			return fileState{content: change.NewString, exists: true}, nil
+		}
+		if !state.exists {
+			return conflict("file does not exist")
+		}
+		count := strings.Count(state.content, change.OldString)
+		switch {
+		case count == 0:
+			return conflict("old string not found")
+		case change.ReplaceAll:
+			return fileState{content: strings.ReplaceAll(state.content, change.OldString, change.NewString), exists: true}, nil
+		case count > 1:
+			return conflict("old string found %d times", count)
+		}
+		return fileState{content: strings.Replace(state.content, change.OldString, change.NewString, 1), exists: true}, nil
+	case KindWrite:
+		if change.Created && state.exists {
+			return conflict("file already exists")
+		}
+		return fileState{content: change.Content, exists: true}, nil
+	case KindNotebookEdit:
+		if !state.exists {
+			return conflict("notebook does not exist")
+		}
+		content, err := editNotebook(state.content, change)
+		if err != nil {
+			return conflict("%v", err)
+		}
+		return fileState{content: content, exists: true}, nil
+	}
+	return conflict("unknown change kind")
+}
+
+// undo returns the file state before change, given the state after it.
+func undo(state fileState, change Change) (fileState, error) {
+	conflict := func(reason string) (fileState, error) {
+		return fileState{}, &ConflictError{Change: change, Reason: reason}
+	}
+	unknown := func() (fileState, error) {
+		return fileState{}, &UnknownOriginalError{Change: change}
+	}
+	if !state.exists {
+		return conflict("file does not exist")
+	}
+
+	switch change.Kind {
+	case KindEdit:
+		if change.OldString == "" {
+			if state.content != change.NewString {
+				return conflict("file changed since it was created")
+			}
+			return fileState{}, nil
+		}
+		if change.NewString == "" {
+			// Nothing marks where the text was removed
+			return unknown()
+		}
+		count := strings.Count(state.content, change.NewString)
+		switch {
+		case count == 0:
+			return conflict("new string not found")
+		case change.ReplaceAll:
+			return fileState{content: strings.ReplaceAll(state.content, change.NewString, change.OldString), exists: true}, nil
+		case count > 1:
+			// The replacement cannot be told apart from the other matches
+			return unknown()
+		}
+		return fileState{content: strings.Replace(state.content, change.NewString, change.OldString, 1), exists: true}, nil
+	case KindWrite:
+		if !change.Created {
+			return unknown()
+		}
+		if state.content != change.Content {
+			return conflict("file changed since it was created")
+		}
+		return fileState{}, nil
+	}
+	return unknown()
+}
+
+// editNotebook applies a NotebookEdit to the JSON of a Jupyter notebook.
+func editNotebook(content string, change Change) (string, error) {
+	var notebook map[string]any
+	if err := json.Unmarshal([]byte(content), &notebook); err != nil {
+		return "", fmt.Errorf("invalid notebook: %w", err)
+	}
+	cells, _ := notebook["cells"].([]any)
+
+	index := -1
+	if change.CellID != "" {
+		for i, item := range cells {
+			if cell, _ := item.(map[string]any); cell != nil && cell["id"] == change.CellID {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return "", fmt.Errorf("cell %q not found", change.CellID)
+		}
+	}
+
+	switch change.EditMode {
+	case EditModeInsert:
+		// New cells go after the named cell, or first
+		cellType := change.CellType
+		if cellType == "" {
+			cellType = "code"
+		}
+		cell := map[string]any{"cell_type": cellType, "metadata": map[string]any{}, "source": sourceLines(change.NewSource)}
+		if cellType == "code" {
+			cell["outputs"] = []any{}
+			cell["execution_count"] = nil
+		}
+		cells = append(cells[:index+1], append([]any{cell}, cells[index+1:]...)...)
+	case EditModeDelete:
+		if index < 0 {
+			return "", errors.New("delete needs a cell ID")
+		}
+		cells = append(cells[:index], cells[index+1:]...)
+	default:
+		if index < 0 {
+			return "", errors.New("replace needs a cell ID")
+		}
+		cell, _ := cells[index].(map[string]any)
+		cell["source"] = sourceLines(change.NewSource)
+		if change.CellType != "" {
+			cell["cell_type"] = change.CellType
+		}
+	}
+	notebook["cells"] = cells
+
+	data, err := json.MarshalIndent(notebook, "", " ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// sourceLines splits cell source the way Jupyter stores it: one string per
+// line, each keeping its newline.
+func sourceLines(source string) []any {
+	lines := splitLines(source)
+	out := make([]any, len(lines))
+	for i, line := range lines {
+		out[i] = line
+	}
+	return out
+}
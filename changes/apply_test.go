@@ -0,0 +1,161 @@
+package changes
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/severity1/claude-code-sdk-go/internal/testfs"
+)
+
+func TestApplyDiffRevert(t *testing.T) {
+	dir := t.TempDir()
+	testfs.Write(t, dir, "main.go", "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n")
+
+	edits := []Change{
+		{Kind: KindEdit, ToolUseID: "toolu_1", Path: "main.go", OldString: "\"hi\"", NewString: "greeting()"},
+		{Kind: KindWrite, ToolUseID: "toolu_2", Path: "greet/greet.go", Content: "package greet\n", Created: true},
+		{Kind: KindEdit, ToolUseID: "toolu_3", Path: "main.go", OldString: "package main\n", NewString: "package main\n\n// Entry point.\n"},
+	}
+	if err := Apply(edits, WithBaseDir(dir)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if got := testfs.Read(t, dir, "main.go"); got != "package main\n\n// Entry point.\n\nfunc main() {\n\tprintln(greeting())\n}\n" {
+		t.Errorf("Unexpected main.go after Apply:\n%s", got)
+	}
+
+	diff, err := Diff(edits, WithBaseDir(dir))
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	want := "--- a/main.go\n+++ b/main.go\n@@ -1,5 +1,7 @@\n package main\n \n+// Entry point.\n+\n func main() {\n-\tprintln(\"hi\")\n+\tprintln(greeting())\n }\n" +
+		"--- /dev/null\n+++ b/greet/greet.go\n@@ -0,0 +1 @@\n+package greet\n"
+	if diff != want {
+		t.Errorf("Unexpected diff:\n%s\nwant:\n%s", diff, want)
+	}
+
+	if err := Revert(edits, WithBaseDir(dir)); err != nil {
+		t.Fatalf("Revert failed: %v", err)
+	}
+	if got := testfs.Read(t, dir, "main.go"); got != "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n" {
+		t.Errorf("Expected main.go restored, got:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "greet", "greet.go")); !os.IsNotExist(err) {
+		t.Errorf("Expected the created file to be removed, got %v", err)
+	}
+}
+
+func TestApplyConflicts(t *testing.T) {
+	tests := []struct {
+		name   string
+		change Change
+	}{
+		{"old_string_missing", Change{Kind: KindEdit, Path: "a.txt", OldString: "absent", NewString: "x"}},
+		{"old_string_ambiguous", Change{Kind: KindEdit, Path: "a.txt", OldString: "o", NewString: "x"}},
+		{"file_missing", Change{Kind: KindEdit, Path: "missing.txt", OldString: "a", NewString: "b"}},
+		{"created_file_exists", Change{Kind: KindWrite, Path: "a.txt", Content: "new", Created: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			testfs.Write(t, dir, "a.txt", "foo boo\n")
+			// A change that fits is not written when a later one conflicts
+			fits := Change{Kind: KindWrite, Path: "b.txt", Content: "b", Created: true}
+
+			err := Apply([]Change{fits, test.change}, WithBaseDir(dir))
+			var conflict *ConflictError
+			if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) {
+				t.Fatalf("Expected a ConflictError, got %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "b.txt")); !os.IsNotExist(err) {
+				t.Error("Expected no file to be written")
+			}
+		})
+	}
+}
+
+func TestRevertUnknownOriginal(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		change  Change
+	}{
+		{"overwrite", "new", Change{Kind: KindWrite, Path: "a.txt", Content: "new"}},
+		{"deleted_text", "ac", Change{Kind: KindEdit, Path: "a.txt", OldString: "b", NewString: ""}},
+		{"ambiguous_new_text", "x x", Change{Kind: KindEdit, Path: "a.txt", OldString: "y", NewString: "x"}},
+		{"notebook", "{}", Change{Kind: KindNotebookEdit, Path: "a.txt", CellID: "c1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			testfs.Write(t, dir, "a.txt", test.content)
+
+			err := Revert([]Change{test.change}, WithBaseDir(dir))
+			var unknown *UnknownOriginalError
+			if !errors.As(err, &unknown) || !errors.Is(err, ErrUnknownOriginal) {
+				t.Fatalf("Expected an UnknownOriginalError, got %v", err)
+			}
+			if got := testfs.Read(t, dir, "a.txt"); got != test.content {
+				t.Errorf("Expected the file untouched, got %q", got)
+			}
+		})
+	}
+}
+
+func TestPatchesDetectLaterModification(t *testing.T) {
+	dir := t.TempDir()
+	testfs.Write(t, dir, "a.txt", "edited by hand\n")
+
+	_, err := Patches([]Change{{Kind: KindEdit, Path: "a.txt", OldString: "old", NewString: "new"}}, WithBaseDir(dir))
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected a conflict for a file changed after the conversation, got %v", err)
+	}
+}
+
+func TestApplyNotebookEdits(t *testing.T) {
+	dir := t.TempDir()
+	testfs.Write(t, dir, "nb.ipynb", `{"cells":[{"cell_type":"code","id":"c1","metadata":{},"outputs":[],"source":["x = 1\n"]}],"metadata":{},"nbformat":4,"nbformat_minor":5}`)
+
+	edits := []Change{
+		{Kind: KindNotebookEdit, Path: "nb.ipynb", CellID: "c1", EditMode: EditModeReplace, NewSource: "x = 2\nprint(x)"},
+		{Kind: KindNotebookEdit, Path: "nb.ipynb", CellID: "c1", EditMode: EditModeInsert, CellType: "markdown", NewSource: "# Notes"},
+		{Kind: KindNotebookEdit, Path: "nb.ipynb", EditMode: EditModeInsert, NewSource: "import os"},
+	}
+	if err := Apply(edits, WithBaseDir(dir)); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	var notebook struct {
+		Cells []struct {
+			CellType string   `json:"cell_type"`
+			ID       string   `json:"id"`
+			Source   []string `json:"source"`
+		} `json:"cells"`
+		Nbformat int `json:"nbformat"`
+	}
+	if err := json.Unmarshal([]byte(testfs.Read(t, dir, "nb.ipynb")), &notebook); err != nil {
+		t.Fatalf("Invalid notebook: %v", err)
+	}
+	if len(notebook.Cells) != 3 || notebook.Nbformat != 4 {
+		t.Fatalf("Expected 3 cells, got %+v", notebook)
+	}
+	if got := strings.Join(notebook.Cells[0].Source, ""); got != "import os" || notebook.Cells[0].CellType != "code" {
+		t.Errorf("Expected the new first cell, got %+v", notebook.Cells[0])
+	}
+	if got := notebook.Cells[1].Source; len(got) != 2 || got[0] != "x = 2\n" || notebook.Cells[1].ID != "c1" {
+		t.Errorf("Expected the replaced cell, got %+v", notebook.Cells[1])
+	}
+	if notebook.Cells[2].CellType != "markdown" {
+		t.Errorf("Expected the markdown cell after c1, got %+v", notebook.Cells[2])
+	}
+
+	err := Apply([]Change{{Kind: KindNotebookEdit, Path: "nb.ipynb", CellID: "c9", EditMode: EditModeDelete}}, WithBaseDir(dir))
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected a conflict for an unknown cell, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+// Package changes extracts the file modifications an agent made during a
+// conversation, for reviewing, replaying or undoing autonomous edits.
+//
+// Extract reads the Edit, MultiEdit, Write and NotebookEdit tool calls that
+// succeeded. Diff and Patches compare the files as they are now with their
+// state before the conversation, worked out by undoing the changes in
+// memory. Revert undoes the changes on disk, and Apply replays them, for
+// example on another checkout:
+//
+//	edits := changes.Extract(messages)
+//	diff, err := changes.Diff(edits, changes.WithBaseDir(repoDir))
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Print(diff) // review, then keep or changes.Revert(edits)
+//
+// An edit is undone by swapping its strings back, and a file the agent
+// created by removing it. Some changes do not record enough to be undone:
+// overwriting an existing file, editing a notebook, and edits that deleted
+// text or whose new text now appears more than once. Diff, Patches and
+// Revert report them as UnknownOriginalError.
+package changes
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Kind is the kind of file modification.
+type Kind string
+
+const (
+	// KindEdit replaces OldString with NewString.
+	KindEdit Kind = "edit"
+	// KindWrite replaces the whole file with Content.
+	KindWrite Kind = "write"
+	// KindNotebookEdit changes one cell of a Jupyter notebook.
+	KindNotebookEdit Kind = "notebook_edit"
+)
+
+// Notebook edit modes.
+const (
+	EditModeReplace = "replace"
+	EditModeInsert  = "insert"
+	EditModeDelete  = "delete"
+)
+
+// Change is one file modification made by a tool call. A MultiEdit call
+// yields one Change per edit, all with its ToolUseID.
+type Change struct {
+	Kind      Kind
+	ToolUseID string
+	Path      string
+
+	// Edit
+	OldString  string
+	NewString  string
+	ReplaceAll bool
+
+	// Write. Created is set when the CLI reported creating the file rather
+	// than overwriting it.
+	Content string
+	Created bool
+
+	// NotebookEdit
+	CellID    string
+	CellType  string
+	EditMode  string
+	NewSource string
+}
+
+// ErrConflict is returned when a file no longer matches a change, for
+// example because it was modified after the conversation.
+var ErrConflict = errors.New("file does not match the change")
+
+// ConflictError reports a change that does not fit the file it names.
+type ConflictError struct {
+	Change Change
+	Reason string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %s (%s): %s", e.Change.Kind, e.Change.Path, e.Change.ToolUseID, e.Reason)
+}
+
+// Unwrap returns ErrConflict.
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// ErrUnknownOriginal is returned when a file's content before the
+// conversation cannot be worked out.
+var ErrUnknownOriginal = errors.New("original content unknown")
+
+// UnknownOriginalError reports a change that cannot be undone because the
+// conversation does not record what it replaced.
+type UnknownOriginalError struct {
+	Change Change
+}
+
+func (e *UnknownOriginalError) Error() string {
+	return fmt.Sprintf("%s %s (%s): %v", e.Change.Kind, e.Change.Path, e.Change.ToolUseID, ErrUnknownOriginal)
+}
+
+// Unwrap returns ErrUnknownOriginal.
+func (e *UnknownOriginalError) Unwrap() error {
+	return ErrUnknownOriginal
+}
+
+// Option configures how changes are mapped to files.
+type Option func(*config)
+
+type config struct {
+	baseDir string
+}
+
+// WithBaseDir resolves relative paths against dir, and names files in diffs
+// relative to dir so the patch applies with git apply or patch -p1 from
+// there. Without it, relative paths resolve against the working directory.
+func WithBaseDir(dir string) Option {
+	return func(c *config) {
+		c.baseDir = dir
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// resolve returns the file a change path refers to.
+func (c *config) resolve(path string) string {
+	if filepath.IsAbs(path) || c.baseDir == "" {
+		return path
+	}
+	return filepath.Join(c.baseDir, path)
+}
+
+// displayName returns the path shown in diffs, relative to the base
+// directory when the file is inside it.
+func (c *config) displayName(path string) string {
+	path = c.resolve(path)
+	if c.baseDir != "" {
+		if rel, err := filepath.Rel(c.baseDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
+
+// createdPrefix starts the CLI's result for a Write that created its file.
+const createdPrefix = "File created successfully"
+
+// Extract returns the file modifications made by tool calls in messages, in
+// order. Calls without a result, and calls whose result is an error, made no
+// change and are left out.
+func Extract(messages []claudecode.Message) []Change {
+	results := make(map[string]*claudecode.ToolResultBlock)
+	for _, msg := range messages {
+		if user, ok := msg.(*claudecode.UserMessage); ok {
+			blocks, _ := user.Content.([]claudecode.ContentBlock)
+			for _, block := range blocks {
+				if result, ok := block.(*claudecode.ToolResultBlock); ok {
+					results[result.ToolUseID] = result
+				}
+			}
+		}
+	}
+
+	var changes []Change
+	for _, msg := range messages {
+		assistant, ok := msg.(*claudecode.AssistantMessage)
+		if !ok {
+			continue
+		}
+		for _, block := range assistant.Content {
+			use, ok := block.(*claudecode.ToolUseBlock)
+			if !ok {
+				continue
+			}
+			result := results[use.ToolUseID]
+			if result == nil || result.Failed() {
+				continue
+			}
+			changes = append(changes, fromToolUse(use, result)...)
+		}
+	}
+	return changes
+}
+
+// fromToolUse returns the changes a successful tool call made.
+func fromToolUse(use *claudecode.ToolUseBlock, result *claudecode.ToolResultBlock) []Change {
+	input := use.Input
+	str := func(key string) string {
+		s, _ := input[key].(string)
+		return s
+	}
+	path := str("file_path")
+
+	switch use.Name {
+	case "Edit":
+		replaceAll, _ := input["replace_all"].(bool)
+		return []Change{{
+			Kind: KindEdit, ToolUseID: use.ToolUseID, Path: path,
+			OldString: str("old_string"), NewString: str("new_string"), ReplaceAll: replaceAll,
+		}}
+	case "MultiEdit":
+		edits, _ := input["edits"].([]any)
+		changes := make([]Change, 0, len(edits))
+		for _, item := range edits {
+			edit, _ := item.(map[string]any)
+			oldString, _ := edit["old_string"].(string)
+			newString, _ := edit["new_string"].(string)
+			replaceAll, _ := edit["replace_all"].(bool)
+			changes = append(changes, Change{
+				Kind: KindEdit, ToolUseID: use.ToolUseID, Path: path,
+				OldString: oldString, NewString: newString, ReplaceAll: replaceAll,
+			})
+		}
+		return changes
+	case "Write":
+		text, _ := result.Content.(string)
+		return []Change{{
+			Kind: KindWrite, ToolUseID: use.ToolUseID, Path: path,
+			Content: str("content"), Created: strings.HasPrefix(text, createdPrefix),
+		}}
+	case "NotebookEdit":
+		mode := str("edit_mode")
+		if mode == "" {
+			mode = EditModeReplace
+		}
+		return []Change{{
+			Kind: KindNotebookEdit, ToolUseID: use.ToolUseID, Path: str("notebook_path"),
+			CellID: str("cell_id"), CellType: str("cell_type"), EditMode: mode, NewSource: str("new_source"),
+		}}
+	}
+	return nil
+}
+
+// Paths returns the files changes touch, in the order first touched.
+func Paths(changes []Change) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, change := range changes {
+		if !seen[change.Path] {
+			seen[change.Path] = true
+			paths = append(paths, change.Path)
+		}
+	}
+	return paths
+}
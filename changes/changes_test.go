@@ -0,0 +1,103 @@
+package changes
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestExtract(t *testing.T) {
+	isError := true
+	messages := []claudecode.Message{
+		&claudecode.UserMessage{Content: "Refactor the config loader"},
+		&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+			&claudecode.TextBlock{Text: "On it."},
+			toolUse("toolu_1", "Edit", map[string]any{"file_path": "/repo/config.go", "old_string": "a", "new_string": "b", "replace_all": true}),
+			toolUse("toolu_2", "Write", map[string]any{"file_path": "/repo/new.go", "content": "package repo\n"}),
+			toolUse("toolu_3", "Write", map[string]any{"file_path": "/repo/old.go", "content": "package old\n"}),
+			toolUse("toolu_4", "Edit", map[string]any{"file_path": "/repo/failed.go", "old_string": "x", "new_string": "y"}),
+			toolUse("toolu_5", "Read", map[string]any{"file_path": "/repo/config.go"}),
+			toolUse("toolu_6", "Edit", map[string]any{"file_path": "/repo/pending.go", "old_string": "x", "new_string": "y"}),
+		}},
+		&claudecode.UserMessage{Content: []claudecode.ContentBlock{
+			toolResult("toolu_1", "The file /repo/config.go has been updated."),
+			toolResult("toolu_2", "File created successfully at: /repo/new.go"),
+			toolResult("toolu_3", "The file /repo/old.go has been updated."),
+			&claudecode.ToolResultBlock{ToolUseID: "toolu_4", Content: "String to replace not found", IsError: &isError},
+			toolResult("toolu_5", "package repo"),
+		}},
+		&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+			toolUse("toolu_7", "MultiEdit", map[string]any{"file_path": "/repo/config.go", "edits": []any{
+				map[string]any{"old_string": "one", "new_string": "1"},
+				map[string]any{"old_string": "two", "new_string": "2"},
+			}}),
+			toolUse("toolu_8", "NotebookEdit", map[string]any{"notebook_path": "/repo/nb.ipynb", "cell_id": "c1", "new_source": "print(1)"}),
+		}},
+		&claudecode.UserMessage{Content: []claudecode.ContentBlock{
+			toolResult("toolu_7", "Applied 2 edits"),
+			toolResult("toolu_8", "Updated cell c1"),
+		}},
+	}
+
+	got := Extract(messages)
+	want := []Change{
+		{Kind: KindEdit, ToolUseID: "toolu_1", Path: "/repo/config.go", OldString: "a", NewString: "b", ReplaceAll: true},
+		{Kind: KindWrite, ToolUseID: "toolu_2", Path: "/repo/new.go", Content: "package repo\n", Created: true},
+		{Kind: KindWrite, ToolUseID: "toolu_3", Path: "/repo/old.go", Content: "package old\n"},
+		{Kind: KindEdit, ToolUseID: "toolu_7", Path: "/repo/config.go", OldString: "one", NewString: "1"},
+		{Kind: KindEdit, ToolUseID: "toolu_7", Path: "/repo/config.go", OldString: "two", NewString: "2"},
+		{Kind: KindNotebookEdit, ToolUseID: "toolu_8", Path: "/repo/nb.ipynb", CellID: "c1", EditMode: EditModeReplace, NewSource: "print(1)"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d changes, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Change %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	paths := Paths(got)
+	if len(paths) != 4 || paths[0] != "/repo/config.go" || paths[3] != "/repo/nb.ipynb" {
+		t.Errorf("Expected paths in first-touched order, got %v", paths)
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	base := t.TempDir()
+	tests := []struct {
+		name    string
+		baseDir string
+		path    string
+		want    string
+	}{
+		{"inside_base", base, filepath.Join(base, "pkg", "a.go"), "pkg/a.go"},
+		{"relative", base, filepath.Join("pkg", "a.go"), "pkg/a.go"},
+		{"no_base", "", filepath.Join("pkg", "a.go"), "pkg/a.go"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newConfig([]Option{WithBaseDir(test.baseDir)})
+			if got := c.displayName(test.path); got != test.want {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+
+	// Files outside the base keep their full path
+	outside := filepath.Join(filepath.Dir(base), "other.go")
+	if got := newConfig([]Option{WithBaseDir(base)}).displayName(outside); strings.HasPrefix(got, "..") || !strings.HasSuffix(got, "/other.go") {
+		t.Errorf("Expected the full path of a file outside the base, got %q", got)
+	}
+}
+
+func toolUse(id, name string, input map[string]any) *claudecode.ToolUseBlock {
+	return &claudecode.ToolUseBlock{ToolUseID: id, Name: name, Input: input}
+}
+
+func toolResult(id, content string) *claudecode.ToolResultBlock {
+	return &claudecode.ToolResultBlock{ToolUseID: id, Content: content}
+}
@@ -0,0 +1,228 @@
+package changes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines around each hunk.
+const contextLines = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is one line of an edit script. Lines keep their newline, so a last
+// line without one differs from the same line with one.
+type op struct {
+	kind opKind
+	line string
+}
+
+// unifiedDiff returns the unified diff turning before into after, or "" when
+// they are equal. Empty names are shown as /dev/null.
+func unifiedDiff(oldName, newName, before, after string) string {
+	if before == after {
+		return ""
+	}
+	ops := diffLines(splitLines(before), splitLines(after))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", orDevNull(oldName), orDevNull(newName))
+	for _, h := range hunks(ops) {
+		b.WriteString(h)
+	}
+	return b.String()
+}
+
+func orDevNull(name string) string {
+	if name == "" {
+		return "/dev/null"
+	}
+	return name
+}
+
+// splitLines splits s after each newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns a shortest edit script from a to b, using Myers'
+// algorithm.
+func diffLines(a, b []string) []op {
+	n, m := len(a), len(b)
+	limit := n + m
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace []frontier
+
+	for d := 0; d <= limit; d++ {
+		// Round d only reads diagonals -d-1 to d+1 of the previous round
+		lo := offset - d - 1
+		trace = append(trace, frontier{base: lo, v: append([]int(nil), v[lo:offset+d+2]...)})
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset)
+			}
+		}
+	}
+	return nil
+}
+
+// frontier is the part of the furthest-reaching x values, indexed from
+// base, that one round of diffLines started from.
+type frontier struct {
+	base int
+	v    []int
+}
+
+func (f frontier) at(i int) int {
+	return f.v[i-f.base]
+}
+
+// backtrack walks the saved frontiers back from the end of both inputs.
+func backtrack(trace []frontier, a, b []string, offset int) []op {
+	x, y := len(a), len(b)
+	var ops []op
+	for d := len(trace) - 1; d >= 0; d-- {
+		f := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && f.at(offset+k-1) < f.at(offset+k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := f.at(offset + prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{opEqual, a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, op{opInsert, b[y]})
+			} else {
+				x--
+				ops = append(ops, op{opDelete, a[x]})
+			}
+		}
+	}
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// hunks groups an edit script into unified diff hunks.
+func hunks(ops []op) []string {
+	var out []string
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == opEqual {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		// Extend while changes are separated by at most twice the context
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != opEqual {
+				end = i + 1
+			} else if i-end >= 2*contextLines {
+				break
+			}
+		}
+
+		first := start - contextLines
+		if first < 0 {
+			first = 0
+		}
+		last := end + contextLines
+		if last > len(ops) {
+			last = len(ops)
+		}
+		out = append(out, formatHunk(ops, first, last))
+		start = end
+	}
+	return out
+}
+
+// formatHunk renders ops[first:last] with its line ranges.
+func formatHunk(ops []op, first, last int) string {
+	oldStart, newStart := 1, 1
+	for _, o := range ops[:first] {
+		if o.kind != opInsert {
+			oldStart++
+		}
+		if o.kind != opDelete {
+			newStart++
+		}
+	}
+
+	var body strings.Builder
+	oldCount, newCount := 0, 0
+	for _, o := range ops[first:last] {
+		prefix := " "
+		switch o.kind {
+		case opEqual:
+			oldCount++
+			newCount++
+		case opDelete:
+			prefix = "-"
+			oldCount++
+		case opInsert:
+			prefix = "+"
+			newCount++
+		}
+		body.WriteString(prefix)
+		body.WriteString(o.line)
+		if !strings.HasSuffix(o.line, "\n") {
+			body.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+
+	// An empty range starts at the line before it
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+	return fmt.Sprintf("@@ -%s +%s @@\n%s", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount), body.String())
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
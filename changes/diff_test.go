@@ -0,0 +1,114 @@
+package changes
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	ten := numberedLines(1, 10)
+	tests := []struct {
+		name          string
+		before, after string
+		oldName       string
+		want          string
+	}{
+		{
+			name:    "change_in_middle",
+			before:  ten,
+			after:   strings.Replace(ten, "line 5\n", "line five\n", 1),
+			oldName: "a/f.txt",
+			want:    "--- a/f.txt\n+++ b/f.txt\n@@ -2,7 +2,7 @@\n line 2\n line 3\n line 4\n-line 5\n+line five\n line 6\n line 7\n line 8\n",
+		},
+		{
+			name:    "insert_at_start",
+			before:  "b\nc\n",
+			after:   "a\nb\nc\n",
+			oldName: "a/f.txt",
+			want:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,3 @@\n+a\n b\n c\n",
+		},
+		{
+			name:   "created",
+			before: "",
+			after:  "a\nb\n",
+			want:   "--- /dev/null\n+++ b/f.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "no_newline_at_end",
+			before:  "a\nb",
+			after:   "a\nb\n",
+			oldName: "a/f.txt",
+			want:    "--- a/f.txt\n+++ b/f.txt\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name:    "distant_changes",
+			before:  numberedLines(1, 20),
+			after:   strings.NewReplacer("line 2\n", "two\n", "line 19\n", "nineteen\n").Replace(numberedLines(1, 20)),
+			oldName: "a/f.txt",
+			want: "--- a/f.txt\n+++ b/f.txt\n" +
+				"@@ -1,5 +1,5 @@\n line 1\n-line 2\n+two\n line 3\n line 4\n line 5\n" +
+				"@@ -16,5 +16,5 @@\n line 16\n line 17\n line 18\n-line 19\n+nineteen\n line 20\n",
+		},
+		{
+			name:    "unchanged",
+			before:  ten,
+			after:   ten,
+			oldName: "a/f.txt",
+			want:    "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := unifiedDiff(test.oldName, "b/f.txt", test.before, test.after)
+			if got != test.want {
+				t.Errorf("Unexpected diff:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesReconstructsInputs(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"a\n", "b\n", "c\n", "d\n"}
+	random := func() []string {
+		lines := make([]string, rng.Intn(30))
+		for i := range lines {
+			lines[i] = words[rng.Intn(len(words))]
+		}
+		return lines
+	}
+
+	for i := 0; i < 200; i++ {
+		a, b := random(), random()
+		var gotA, gotB []string
+		edits := 0
+		for _, o := range diffLines(a, b) {
+			if o.kind != opInsert {
+				gotA = append(gotA, o.line)
+			}
+			if o.kind != opDelete {
+				gotB = append(gotB, o.line)
+			}
+			if o.kind != opEqual {
+				edits++
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("Edit script does not reproduce its inputs:\n%q\n%q", a, b)
+		}
+		if edits > len(a)+len(b) {
+			t.Fatalf("Edit script has %d edits for %d lines", edits, len(a)+len(b))
+		}
+	}
+}
+
+func numberedLines(from, to int) string {
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
@@ -0,0 +1,282 @@
+// Package chaos injects faults into a Transport, so tests can check that
+// retry, reconnect and stream validation logic copes with an unreliable
+// CLI:
+//
+//	transport := chaos.NewTransport(inner, chaos.FaultConfig{
+//		DropEveryNth:    5,
+//		CorruptJSONRate: 0.1,
+//		DisconnectAfter: 20,
+//	})
+//	client := claudecode.NewClientWithTransport(transport)
+//
+// Faults are applied to received messages, as they would arrive from a
+// misbehaving CLI: messages go missing, arrive late, turn into the
+// JSONDecodeError a truncated line produces, or stop when the process dies
+// mid-turn. Middleware returns the same faults as a TransportMiddleware.
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// FaultConfig selects the faults to inject. The zero value injects none.
+type FaultConfig struct {
+	// DropEveryNth, if positive, discards every nth received message.
+	DropEveryNth int
+	// DelayJitter delays each received message by a random duration up
+	// to this long.
+	DelayJitter time.Duration
+	// CorruptJSONRate is the share of received messages, from 0 to 1,
+	// replaced by the JSONDecodeError a truncated line produces.
+	CorruptJSONRate float64
+	// DisconnectAfter, if positive, disconnects once that many messages
+	// have been received: the inner transport is closed, a ConnectionError
+	// is reported and both channels close, as when the CLI exits mid-turn.
+	DisconnectAfter int
+	// Rand makes the faults reproducible. By default a source seeded with
+	// the current time is used.
+	Rand *rand.Rand
+}
+
+// Stats counts the faults a Transport has injected.
+type Stats struct {
+	Received     int // messages received from the inner transport
+	Dropped      int
+	Corrupted    int
+	Disconnected bool
+}
+
+// Transport wraps a Transport with faults. Control requests pass through
+// unchanged when the inner transport supports them.
+type Transport struct {
+	inner  claudecode.Transport
+	config FaultConfig
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	stats Stats
+}
+
+// NewTransport returns inner wrapped with the faults in config.
+func NewTransport(inner claudecode.Transport, config FaultConfig) *Transport {
+	source := config.Rand
+	if source == nil {
+		source = rand.New(rand.NewSource(time.Now().UnixNano())) // #nosec G404 - Faults need not be unpredictable
+	}
+	return &Transport{inner: inner, config: config, rand: source}
+}
+
+// Middleware returns NewTransport as a TransportMiddleware, for use with
+// claudecode.WrapTransport. Each wrapped transport counts messages on its
+// own; with config.Rand set, they share the source.
+func Middleware(config FaultConfig) claudecode.TransportMiddleware {
+	return func(t claudecode.Transport) claudecode.Transport {
+		return NewTransport(t, config)
+	}
+}
+
+// Stats returns the faults injected so far.
+func (t *Transport) Stats() Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// Connect connects the inner transport.
+func (t *Transport) Connect(ctx context.Context) error {
+	return t.inner.Connect(ctx)
+}
+
+// SendMessage sends through the inner transport, or fails once the
+// transport has been disconnected.
+func (t *Transport) SendMessage(ctx context.Context, message claudecode.StreamMessage) error {
+	if t.Stats().Disconnected {
+		return claudecode.NewConnectionError("chaos: transport disconnected", nil)
+	}
+	return t.inner.SendMessage(ctx, message)
+}
+
+// ReceiveMessages relays the inner transport's messages and errors with
+// faults applied. Injected errors are sent on the error channel in order
+// with the messages around them.
+func (t *Transport) ReceiveMessages(ctx context.Context) (<-chan claudecode.Message, <-chan error) {
+	msgs, errs := t.inner.ReceiveMessages(ctx)
+	outMsgs := make(chan claudecode.Message, cap(msgs))
+	outErrs := make(chan error, cap(errs))
+	go t.relay(ctx, msgs, errs, outMsgs, outErrs)
+	return outMsgs, outErrs
+}
+
+func (t *Transport) relay(
+	ctx context.Context,
+	msgs <-chan claudecode.Message,
+	errs <-chan error,
+	outMsgs chan<- claudecode.Message,
+	outErrs chan<- error,
+) {
+	defer close(outMsgs)
+	defer close(outErrs)
+
+	sendErr := func(err error) bool {
+		select {
+		case outErrs <- err:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for msgs != nil || errs != nil {
+		select {
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if !sendErr(err) {
+				return
+			}
+		case msg, ok := <-msgs:
+			if !ok {
+				msgs = nil
+				continue
+			}
+			if !t.deliver(ctx, msg, outMsgs, sendErr) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver applies faults to msg and sends what remains of it. It returns
+// false when relaying should stop.
+func (t *Transport) deliver(
+	ctx context.Context,
+	msg claudecode.Message,
+	outMsgs chan<- claudecode.Message,
+	sendErr func(error) bool,
+) bool {
+	t.mu.Lock()
+	t.stats.Received++
+	received := t.stats.Received
+	drop := t.config.DropEveryNth > 0 && received%t.config.DropEveryNth == 0
+	corrupt := !drop && t.config.CorruptJSONRate > 0 && t.rand.Float64() < t.config.CorruptJSONRate
+	var delay time.Duration
+	if t.config.DelayJitter > 0 {
+		delay = time.Duration(t.rand.Int63n(int64(t.config.DelayJitter)))
+	}
+	switch {
+	case drop:
+		t.stats.Dropped++
+	case corrupt:
+		t.stats.Corrupted++
+	}
+	t.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+
+	switch {
+	case drop:
+	case corrupt:
+		if !sendErr(truncated(msg)) {
+			return false
+		}
+	default:
+		select {
+		case outMsgs <- msg:
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	if t.config.DisconnectAfter > 0 && received >= t.config.DisconnectAfter {
+		t.disconnect(sendErr)
+		return false
+	}
+	return true
+}
+
+// disconnect closes the inner transport and reports the lost connection.
+func (t *Transport) disconnect(sendErr func(error) bool) {
+	t.mu.Lock()
+	received := t.stats.Received
+	t.stats.Disconnected = true
+	t.mu.Unlock()
+
+	cause := t.inner.Close()
+	sendErr(claudecode.NewConnectionError(
+		fmt.Sprintf("chaos: transport disconnected after %d messages", received), cause))
+}
+
+// truncated returns the error the parser reports for msg's line cut in
+// half.
+func truncated(msg claudecode.Message) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		line = []byte(fmt.Sprintf(`{"type":%q`, msg.Type()))
+	}
+	fragment := string(line[:len(line)/2])
+	return claudecode.NewJSONDecodeError(fragment, len(fragment), io.ErrUnexpectedEOF)
+}
+
+// Interrupt interrupts through the inner transport.
+func (t *Transport) Interrupt(ctx context.Context) error {
+	return t.inner.Interrupt(ctx)
+}
+
+// Close closes the inner transport. After a disconnect it has already been
+// closed, and Close does nothing.
+func (t *Transport) Close() error {
+	if t.Stats().Disconnected {
+		return nil
+	}
+	return t.inner.Close()
+}
+
+// GetValidator returns the inner transport's validator, which sees each
+// message before faults are applied.
+func (t *Transport) GetValidator() *claudecode.StreamValidator {
+	return t.inner.GetValidator()
+}
+
+// SendControlRequest forwards req to the inner transport.
+func (t *Transport) SendControlRequest(ctx context.Context, req *claudecode.ControlRequest) error {
+	ctrl, ok := t.inner.(claudecode.ControlRequestTransport)
+	if !ok {
+		return fmt.Errorf("transport does not support control requests")
+	}
+	return ctrl.SendControlRequest(ctx, req)
+}
+
+// SendControlResponse forwards resp to the inner transport.
+func (t *Transport) SendControlResponse(ctx context.Context, resp *claudecode.ControlResponse) error {
+	ctrl, ok := t.inner.(claudecode.ControlResponseTransport)
+	if !ok {
+		return fmt.Errorf("transport does not support control responses")
+	}
+	return ctrl.SendControlResponse(ctx, resp)
+}
+
+// SupportsControlRequests reports whether the inner transport does.
+func (t *Transport) SupportsControlRequests() bool {
+	ctrl, ok := t.inner.(claudecode.ControlRequestTransport)
+	return ok && ctrl.SupportsControlRequests()
+}
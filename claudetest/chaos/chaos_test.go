@@ -0,0 +1,199 @@
+package chaos_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+	"github.com/severity1/claude-code-sdk-go/claudetest/chaos"
+)
+
+func TestDropEveryNth(t *testing.T) {
+	inner := newMockTransport(6)
+	transport := chaos.NewTransport(inner, chaos.FaultConfig{DropEveryNth: 3})
+
+	msgs, errs := receiveAll(t, transport)
+	if got := texts(msgs); got != "1,2,4,5" {
+		t.Errorf("Expected every third message dropped, got %s", got)
+	}
+	if len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+	if stats := transport.Stats(); stats.Received != 6 || stats.Dropped != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCorruptJSON(t *testing.T) {
+	inner := newMockTransport(4)
+	transport := chaos.NewTransport(inner, chaos.FaultConfig{CorruptJSONRate: 1})
+
+	msgs, errs := receiveAll(t, transport)
+	if len(msgs) != 0 || len(errs) != 4 {
+		t.Fatalf("Expected every message corrupted, got %d messages and %d errors", len(msgs), len(errs))
+	}
+	var decodeErr *claudecode.JSONDecodeError
+	if !errors.As(errs[0], &decodeErr) {
+		t.Errorf("Expected a JSONDecodeError, got %T: %v", errs[0], errs[0])
+	}
+	if transport.Stats().Corrupted != 4 {
+		t.Errorf("Expected 4 corrupted messages, got %+v", transport.Stats())
+	}
+}
+
+func TestCorruptJSONRateIsReproducible(t *testing.T) {
+	run := func() string {
+		transport := chaos.NewTransport(newMockTransport(20), chaos.FaultConfig{
+			CorruptJSONRate: 0.5,
+			DelayJitter:     time.Millisecond,
+			Rand:            rand.New(rand.NewSource(42)), // #nosec G404 - Deterministic test faults
+		})
+		msgs, _ := receiveAll(t, transport)
+		return texts(msgs)
+	}
+
+	first := run()
+	if first == "" || first == texts(mockMessages(20)) {
+		t.Fatalf("Expected some messages corrupted, got %q", first)
+	}
+	if second := run(); second != first {
+		t.Errorf("Expected the same seed to corrupt the same messages, got %q and %q", first, second)
+	}
+}
+
+func TestDisconnectAfter(t *testing.T) {
+	inner := newMockTransport(5)
+	transport := chaos.NewTransport(inner, chaos.FaultConfig{DisconnectAfter: 2})
+
+	msgs, errs := receiveAll(t, transport)
+	if got := texts(msgs); got != "1,2" {
+		t.Errorf("Expected two messages before the disconnect, got %s", got)
+	}
+	var connErr *claudecode.ConnectionError
+	if len(errs) != 1 || !errors.As(errs[0], &connErr) {
+		t.Fatalf("Expected one ConnectionError, got %v", errs)
+	}
+	if !inner.isClosed() {
+		t.Error("Expected the inner transport closed")
+	}
+	if !transport.Stats().Disconnected {
+		t.Error("Expected the disconnect recorded")
+	}
+	if err := transport.SendMessage(context.Background(), claudecode.StreamMessage{Type: "user"}); !errors.As(err, &connErr) {
+		t.Errorf("Expected sends to fail after the disconnect, got %v", err)
+	}
+}
+
+func TestMiddlewareKeepsControlSupport(t *testing.T) {
+	wrapped := claudecode.WrapTransport(newMockTransport(0), chaos.Middleware(chaos.FaultConfig{}))
+	ctrl, ok := wrapped.(claudecode.ControlRequestTransport)
+	if !ok {
+		t.Fatal("Expected the chaos transport to implement ControlRequestTransport")
+	}
+	if ctrl.SupportsControlRequests() {
+		t.Error("Expected no control support without it underneath")
+	}
+	if err := ctrl.SendControlRequest(context.Background(), &claudecode.ControlRequest{}); err == nil {
+		t.Error("Expected control requests to fail without support underneath")
+	}
+}
+
+// mockTransport delivers numbered assistant messages, then closes its
+// channels.
+type mockTransport struct {
+	mu       sync.Mutex
+	messages []claudecode.Message
+	closed   bool
+}
+
+func newMockTransport(count int) *mockTransport {
+	return &mockTransport{messages: mockMessages(count)}
+}
+
+func (m *mockTransport) Connect(context.Context) error { return nil }
+
+func (m *mockTransport) SendMessage(context.Context, claudecode.StreamMessage) error { return nil }
+
+func (m *mockTransport) ReceiveMessages(context.Context) (<-chan claudecode.Message, <-chan error) {
+	msgs := make(chan claudecode.Message, len(m.messages))
+	for _, msg := range m.messages {
+		msgs <- msg
+	}
+	close(msgs)
+	errs := make(chan error)
+	close(errs)
+	return msgs, errs
+}
+
+func (m *mockTransport) Interrupt(context.Context) error { return nil }
+
+func (m *mockTransport) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+func (m *mockTransport) GetValidator() *claudecode.StreamValidator { return nil }
+
+func (m *mockTransport) isClosed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+func mockMessages(count int) []claudecode.Message {
+	messages := make([]claudecode.Message, count)
+	for i := range messages {
+		messages[i] = &claudecode.AssistantMessage{
+			Content: []claudecode.ContentBlock{&claudecode.TextBlock{Text: strconv.Itoa(i + 1)}},
+		}
+	}
+	return messages
+}
+
+// receiveAll drains transport until both of its channels close.
+func receiveAll(t *testing.T, transport claudecode.Transport) ([]claudecode.Message, []error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msgChan, errChan := transport.ReceiveMessages(ctx)
+	var msgs []claudecode.Message
+	var errs []error
+	for msgChan != nil || errChan != nil {
+		select {
+		case msg, ok := <-msgChan:
+			if !ok {
+				msgChan = nil
+				continue
+			}
+			msgs = append(msgs, msg)
+		case err, ok := <-errChan:
+			if !ok {
+				errChan = nil
+				continue
+			}
+			errs = append(errs, err)
+		case <-ctx.Done():
+			t.Fatal("Timed out receiving messages")
+		}
+	}
+	return msgs, errs
+}
+
+func texts(msgs []claudecode.Message) string {
+	var out string
+	for i, msg := range msgs {
+		if i > 0 {
+			out += ","
+		}
+		out += msg.(*claudecode.AssistantMessage).Content[0].(*claudecode.TextBlock).Text
+	}
+	return out
+}
@@ -0,0 +1,9 @@
+// Command fakecli emulates the Claude CLI for integration tests. See package
+// github.com/severity1/claude-code-sdk-go/claudetest/fakecli.
+package main
+
+import "github.com/severity1/claude-code-sdk-go/claudetest/fakecli"
+
+func main() {
+	fakecli.Main()
+}
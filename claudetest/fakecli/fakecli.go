@@ -0,0 +1,436 @@
+// Package fakecli emulates the Claude CLI's stream-json protocol for
+// integration tests that run without the real CLI or API credentials.
+//
+// New builds a small binary that answers the SDK's user messages with
+// scripted turns. Point the SDK at it with WithCLIPath, so tests exercise
+// the real subprocess transport and the application code around it:
+//
+//	cli := fakecli.New(t, fakecli.Script{
+//		Turns: []fakecli.Turn{fakecli.Reply("Hello from the fake CLI")},
+//	})
+//	client := claudecode.NewClient(claudecode.WithCLIPath(cli.Path))
+//
+// The binary answers every control request it receives with a success
+// response echoing the request, and a Turn can send control requests of its
+// own, such as can_use_tool or hook_callback, and wait for the response.
+// Everything the SDK writes to stdin is recorded, see CLI.Inputs.
+//
+// Scripts can also be run without New: build the fakecli command and name
+// the script file in the FAKECLI_SCRIPT environment variable.
+package fakecli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// EnvScript names the environment variable holding the script path. Without
+// it, the binary reads the script from its own path with ".json" appended.
+const EnvScript = "FAKECLI_SCRIPT"
+
+// mainPackage is the import path of the fakecli command.
+const mainPackage = "github.com/severity1/claude-code-sdk-go/claudetest/fakecli/cmd/fakecli"
+
+const (
+	transcriptSuffix = ".transcript"
+	argsRecordType   = "fakecli_args"
+	maxInputLineSize = 10 * 1024 * 1024
+)
+
+// errNoResponse is returned when stdin closes while a control request waits.
+var errNoResponse = errors.New("stdin closed before the control response arrived")
+
+// CLI is a fake CLI binary built for one test.
+type CLI struct {
+	// Path is the binary to pass to WithCLIPath.
+	Path string
+
+	scriptPath string
+}
+
+// New builds the fake CLI into a temporary directory and installs script
+// next to it. The build needs the go command and the module that imports
+// this package.
+func New(t testing.TB, script Script) *CLI {
+	t.Helper()
+
+	dir := t.TempDir()
+	name := "claude"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	path := filepath.Join(dir, name)
+
+	out, err := exec.Command("go", "build", "-o", path, mainPackage).CombinedOutput()
+	if err != nil {
+		t.Fatalf("fakecli: build failed: %v\n%s", err, out)
+	}
+
+	data, err := json.Marshal(script)
+	if err != nil {
+		t.Fatalf("fakecli: encode script: %v", err)
+	}
+	scriptPath := path + ".json"
+	if err := os.WriteFile(scriptPath, data, 0o600); err != nil {
+		t.Fatalf("fakecli: write script: %v", err)
+	}
+	return &CLI{Path: path, scriptPath: scriptPath}
+}
+
+// Args returns the arguments of the most recent invocation.
+func (c *CLI) Args(t testing.TB) []string {
+	t.Helper()
+	var args []string
+	for _, record := range c.transcript(t) {
+		if record["type"] != argsRecordType {
+			continue
+		}
+		args = args[:0]
+		list, _ := record["args"].([]any)
+		for _, arg := range list {
+			s, _ := arg.(string)
+			args = append(args, s)
+		}
+	}
+	return args
+}
+
+// Inputs returns the messages written to stdin across all invocations, in
+// order: user messages, control requests and control responses.
+func (c *CLI) Inputs(t testing.TB) []map[string]any {
+	t.Helper()
+	var inputs []map[string]any
+	for _, record := range c.transcript(t) {
+		if record["type"] != argsRecordType {
+			inputs = append(inputs, record)
+		}
+	}
+	return inputs
+}
+
+func (c *CLI) transcript(t testing.TB) []map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(c.scriptPath + transcriptSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("fakecli: read transcript: %v", err)
+	}
+
+	var records []map[string]any
+	for _, line := range strings.Split(string(data), "\n") {
+		var record map[string]any
+		if json.Unmarshal([]byte(line), &record) == nil {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Main runs the fake CLI as a program. It is the body of the fakecli command.
+func Main() {
+	path := os.Getenv(EnvScript)
+	if path == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			fail(err)
+		}
+		path = exe + ".json"
+	}
+
+	script, err := LoadScript(path)
+	if err != nil {
+		fail(err)
+	}
+
+	transcript, err := os.OpenFile(path+transcriptSuffix, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		fail(err)
+	}
+	defer transcript.Close()
+	if err := writeJSONLine(transcript, map[string]any{"type": argsRecordType, "args": os.Args[1:]}); err != nil {
+		fail(err)
+	}
+	stdin := io.TeeReader(os.Stdin, transcript)
+
+	if err := Run(script, os.Args[1:], stdin, os.Stdout); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "fakecli:", err)
+	os.Exit(1)
+}
+
+// LoadScript reads a JSON-encoded Script from path.
+func LoadScript(path string) (Script, error) {
+	var script Script
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return script, fmt.Errorf("read script: %w", err)
+	}
+	if err := json.Unmarshal(data, &script); err != nil {
+		return script, fmt.Errorf("decode script %s: %w", path, err)
+	}
+	return script, nil
+}
+
+// Run plays script for a CLI invoked with args. With --print and a prompt
+// argument it plays one turn and returns; otherwise it plays a turn for
+// each user message read from stdin until stdin closes.
+func Run(script Script, args []string, stdin io.Reader, stdout io.Writer) error {
+	oneShot, version := parseArgs(args)
+	if version {
+		v := script.Version
+		if v == "" {
+			v = DefaultVersion
+		}
+		_, err := fmt.Fprintln(stdout, v)
+		return err
+	}
+
+	s := newSession(script, stdout)
+	go s.read(stdin)
+
+	if oneShot {
+		return s.play()
+	}
+	for range s.prompts {
+		if err := s.play(); err != nil {
+			return err
+		}
+	}
+	return s.readErr
+}
+
+// parseArgs reports whether args carry the prompt of a one-shot query and
+// whether the version was requested. All other flags are ignored.
+func parseArgs(args []string) (oneShot, version bool) {
+	streaming := false
+	for i, arg := range args {
+		switch arg {
+		case "--version", "-v":
+			version = true
+		case "--input-format":
+			streaming = i+1 < len(args) && args[i+1] == "stream-json"
+		case "--print", "-p":
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				oneShot = true
+			}
+		}
+	}
+	return oneShot && !streaming, version
+}
+
+// session is one run of the fake CLI.
+type session struct {
+	script  Script
+	turn    int
+	started bool
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	prompts chan map[string]any
+	eof     chan struct{}
+	readErr error
+
+	mu        sync.Mutex
+	pending   map[string]chan map[string]any
+	requestID int
+}
+
+func newSession(script Script, out io.Writer) *session {
+	if script.SessionID == "" {
+		script.SessionID = DefaultSessionID
+	}
+	if script.Model == "" {
+		script.Model = DefaultModel
+	}
+	return &session{
+		script:  script,
+		out:     out,
+		prompts: make(chan map[string]any, 64),
+		eof:     make(chan struct{}),
+		pending: make(map[string]chan map[string]any),
+	}
+}
+
+// read dispatches stdin lines: user messages start turns, control requests
+// are answered and control responses are handed to the waiting step.
+func (s *session) read(r io.Reader) {
+	defer close(s.eof)
+	defer close(s.prompts)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxInputLineSize)
+	for scanner.Scan() {
+		var msg map[string]any
+		if json.Unmarshal(scanner.Bytes(), &msg) != nil {
+			continue
+		}
+		switch msg["type"] {
+		case "user":
+			s.prompts <- msg
+		case "control_request":
+			s.answer(msg)
+		case "control_response":
+			s.deliver(msg)
+		}
+	}
+	s.readErr = scanner.Err()
+}
+
+// answer echoes a control request back as its success response.
+func (s *session) answer(msg map[string]any) {
+	request, _ := msg["request"].(map[string]any)
+	_ = s.write(map[string]any{
+		"type": "control_response",
+		"response": map[string]any{
+			"subtype":    "success",
+			"request_id": msg["request_id"],
+			"response":   request,
+		},
+	})
+}
+
+func (s *session) deliver(msg map[string]any) {
+	response, _ := msg["response"].(map[string]any)
+	id, _ := response["request_id"].(string)
+
+	s.mu.Lock()
+	waiting, ok := s.pending[id]
+	delete(s.pending, id)
+	s.mu.Unlock()
+	if ok {
+		waiting <- response
+	}
+}
+
+// play runs the next turn. Turns are played in order whatever the prompt.
+func (s *session) play() error {
+	if !s.started && !s.script.NoInit {
+		if err := s.write(s.initMessage()); err != nil {
+			return err
+		}
+	}
+	s.started = true
+
+	turn := Steps(ErrorResult("fakecli: no scripted turn left"))
+	if s.turn < len(s.script.Turns) {
+		turn = s.script.Turns[s.turn]
+	}
+	s.turn++
+
+	for _, step := range turn.Steps {
+		switch {
+		case step.Message != nil:
+			if err := s.write(s.fill(step.Message)); err != nil {
+				return err
+			}
+		case step.Request != nil:
+			if err := s.request(step.Request); err != nil {
+				return err
+			}
+		case step.Sleep > 0:
+			time.Sleep(step.Sleep)
+		}
+	}
+	return nil
+}
+
+func (s *session) initMessage() map[string]any {
+	cwd, _ := os.Getwd()
+	return map[string]any{
+		"type":                "system",
+		"subtype":             "init",
+		"session_id":          s.script.SessionID,
+		"model":               s.script.Model,
+		"cwd":                 cwd,
+		"tools":               []any{},
+		"mcp_servers":         []any{},
+		"permissionMode":      "default",
+		"slash_commands":      []any{},
+		"apiKeySource":        "none",
+		"output_style":        "default",
+		"claude_code_version": strings.Fields(DefaultVersion)[0],
+	}
+}
+
+// fill adds the session ID, and the model of assistant messages, to a copy
+// of msg when the script left them out.
+func (s *session) fill(msg map[string]any) map[string]any {
+	filled := make(map[string]any, len(msg)+1)
+	for key, value := range msg {
+		filled[key] = value
+	}
+	if _, ok := filled["session_id"]; !ok {
+		filled["session_id"] = s.script.SessionID
+	}
+	if inner, ok := filled["message"].(map[string]any); ok && filled["type"] == "assistant" {
+		if _, ok := inner["model"]; !ok {
+			withModel := make(map[string]any, len(inner)+1)
+			for key, value := range inner {
+				withModel[key] = value
+			}
+			withModel["model"] = s.script.Model
+			filled["message"] = withModel
+		}
+	}
+	return filled
+}
+
+// request sends a control request and waits for its response.
+func (s *session) request(request map[string]any) error {
+	s.mu.Lock()
+	s.requestID++
+	id := fmt.Sprintf("fakecli_req_%d", s.requestID)
+	waiting := make(chan map[string]any, 1)
+	s.pending[id] = waiting
+	s.mu.Unlock()
+
+	err := s.write(map[string]any{"type": "control_request", "request_id": id, "request": request})
+	if err != nil {
+		return err
+	}
+	select {
+	case <-waiting:
+		return nil
+	case <-s.eof:
+		// The response may have been the last line read
+		select {
+		case <-waiting:
+			return nil
+		default:
+			return fmt.Errorf("control request %s: %w", request["subtype"], errNoResponse)
+		}
+	}
+}
+
+func (s *session) write(msg map[string]any) error {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	return writeJSONLine(s.out, msg)
+}
+
+func writeJSONLine(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,273 @@
+package fakecli_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+	"github.com/severity1/claude-code-sdk-go/claudetest/fakecli"
+)
+
+var streamingArgs = []string{"--output-format", "stream-json", "--verbose", "--input-format", "stream-json"}
+
+func TestRunPlaysTurnsInOrder(t *testing.T) {
+	run := startRun(t, fakecli.Script{
+		SessionID: "session-1",
+		Turns: []fakecli.Turn{
+			fakecli.Reply("first"),
+			fakecli.Steps(fakecli.ToolUse("tool-1", "Read", nil), fakecli.ToolResult("tool-1", "data", false), fakecli.Result("second")),
+		},
+	}, streamingArgs)
+
+	run.send(t, userMessage("one"))
+	run.expect(t, "system", "assistant", "result")
+	run.send(t, userMessage("two"))
+	got := run.expect(t, "assistant", "user", "result")
+	if got[2]["result"] != "second" || got[2]["session_id"] != "session-1" {
+		t.Errorf("Unexpected result: %v", got[2])
+	}
+	if model := got[0]["message"].(map[string]any)["model"]; model != fakecli.DefaultModel {
+		t.Errorf("Expected default model, got %v", model)
+	}
+
+	// Prompts beyond the script get an error result
+	run.send(t, userMessage("three"))
+	if last := run.expect(t, "result")[0]; last["is_error"] != true {
+		t.Errorf("Expected error result, got %v", last)
+	}
+	run.finish(t)
+}
+
+func TestRunEchoesControlRequests(t *testing.T) {
+	run := startRun(t, fakecli.Script{}, streamingArgs)
+
+	run.send(t, map[string]any{
+		"type":       "control_request",
+		"request_id": "req_1",
+		"request":    map[string]any{"subtype": "set_model", "model": "other"},
+	})
+	response := run.expect(t, "control_response")[0]["response"].(map[string]any)
+	if response["subtype"] != "success" || response["request_id"] != "req_1" {
+		t.Errorf("Unexpected control response: %v", response)
+	}
+	if echoed := response["response"].(map[string]any); echoed["model"] != "other" {
+		t.Errorf("Expected the request to be echoed, got %v", echoed)
+	}
+	run.finish(t)
+}
+
+func TestRunWaitsForControlResponse(t *testing.T) {
+	run := startRun(t, fakecli.Script{
+		NoInit: true,
+		Turns: []fakecli.Turn{fakecli.Steps(
+			fakecli.HookCallback("hook_0", map[string]any{"hook_event_name": "PreToolUse"}),
+			fakecli.Result("done"),
+		)},
+	}, streamingArgs)
+
+	run.send(t, userMessage("go"))
+	request := run.expect(t, "control_request")[0]
+	if body := request["request"].(map[string]any); body["subtype"] != "hook_callback" || body["callback_id"] != "hook_0" {
+		t.Fatalf("Unexpected control request: %v", request)
+	}
+	run.expectNothing(t)
+
+	run.send(t, map[string]any{
+		"type": "control_response",
+		"response": map[string]any{
+			"subtype":    "success",
+			"request_id": request["request_id"],
+			"response":   map[string]any{"continue": true},
+		},
+	})
+	run.expect(t, "result")
+	run.finish(t)
+}
+
+func TestRunOneShotAndVersion(t *testing.T) {
+	run := startRun(t, fakecli.Script{Turns: []fakecli.Turn{fakecli.Reply("hi")}},
+		[]string{"--output-format", "stream-json", "--verbose", "--print", "hello"})
+	run.expect(t, "system", "assistant", "result")
+	run.finish(t)
+
+	var out strings.Builder
+	if err := fakecli.Run(fakecli.Script{Version: "1.2.3"}, []string{"--version"}, strings.NewReader(""), &out); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if out.String() != "1.2.3\n" {
+		t.Errorf("Expected version output, got %q", out.String())
+	}
+}
+
+func TestClientWithFakeCLI(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the fake CLI binary")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cli := fakecli.New(t, fakecli.Script{
+		SessionID: "fake-session",
+		Turns: []fakecli.Turn{fakecli.Steps(
+			fakecli.ToolUse("tool-1", "Bash", map[string]any{"command": "ls"}),
+			fakecli.ToolResult("tool-1", "main.go", false),
+			fakecli.AssistantText("There is one file."),
+			fakecli.Result("There is one file."),
+		)},
+	})
+
+	err := claudecode.WithClient(ctx, func(client claudecode.Client) error {
+		if err := client.Query(ctx, "List the files"); err != nil {
+			return err
+		}
+		var texts []string
+		for msg := range client.ReceiveMessages(ctx) {
+			switch m := msg.(type) {
+			case *claudecode.AssistantMessage:
+				for _, block := range m.Content {
+					if text, ok := block.(*claudecode.TextBlock); ok {
+						texts = append(texts, text.Text)
+					}
+				}
+			case *claudecode.ResultMessage:
+				if m.SessionID != "fake-session" {
+					t.Errorf("Expected scripted session ID, got %q", m.SessionID)
+				}
+				if len(texts) != 1 || texts[0] != "There is one file." {
+					t.Errorf("Unexpected assistant text: %q", texts)
+				}
+				return nil
+			}
+		}
+		t.Error("Stream ended before the result")
+		return nil
+	}, claudecode.WithCLIPath(cli.Path), claudecode.WithModel("test-model"), claudecode.WithStrictOrdering())
+	if err != nil {
+		t.Fatalf("WithClient failed: %v", err)
+	}
+
+	inputs := cli.Inputs(t)
+	if len(inputs) != 1 || inputs[0]["message"].(map[string]any)["content"] != "List the files" {
+		t.Errorf("Expected the prompt on stdin, got %v", inputs)
+	}
+	if args := strings.Join(cli.Args(t), " "); !strings.Contains(args, "--model test-model") {
+		t.Errorf("Expected options as flags, got %q", args)
+	}
+
+	// One-shot queries pass the prompt as an argument instead
+	iterator, err := claudecode.Query(ctx, "Again", claudecode.WithCLIPath(cli.Path))
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	defer iterator.Close()
+	for {
+		msg, err := iterator.Next(ctx)
+		if err != nil {
+			t.Fatalf("Expected a result before the stream ended, got %v", err)
+		}
+		if _, ok := msg.(*claudecode.ResultMessage); ok {
+			break
+		}
+	}
+}
+
+// fakeRun is an in-process run of the fake CLI.
+type fakeRun struct {
+	stdin *io.PipeWriter
+	lines chan map[string]any
+	done  chan error
+}
+
+func startRun(t *testing.T, script fakecli.Script, args []string) *fakeRun {
+	t.Helper()
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	run := &fakeRun{stdin: stdinWriter, lines: make(chan map[string]any, 16), done: make(chan error, 1)}
+
+	go func() {
+		err := fakecli.Run(script, args, stdinReader, stdoutWriter)
+		_ = stdoutWriter.Close()
+		run.done <- err
+	}()
+	go func() {
+		defer close(run.lines)
+		scanner := bufio.NewScanner(stdoutReader)
+		for scanner.Scan() {
+			var line map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Errorf("Invalid output line %q: %v", scanner.Text(), err)
+				continue
+			}
+			run.lines <- line
+		}
+	}()
+	t.Cleanup(func() {
+		_ = stdinWriter.Close()
+		_ = stdoutReader.Close()
+	})
+	return run
+}
+
+func (r *fakeRun) send(t *testing.T, msg map[string]any) {
+	t.Helper()
+	data, _ := json.Marshal(msg)
+	if _, err := r.stdin.Write(append(data, '\n')); err != nil {
+		t.Fatalf("Failed to write stdin: %v", err)
+	}
+}
+
+// expect reads one output line per type and checks the types in order.
+func (r *fakeRun) expect(t *testing.T, types ...string) []map[string]any {
+	t.Helper()
+	lines := make([]map[string]any, 0, len(types))
+	for _, want := range types {
+		select {
+		case line, ok := <-r.lines:
+			if !ok {
+				t.Fatalf("Output ended, expected %q", want)
+			}
+			if line["type"] != want {
+				t.Fatalf("Expected %q, got %v", want, line)
+			}
+			lines = append(lines, line)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for %q", want)
+		}
+	}
+	return lines
+}
+
+func (r *fakeRun) expectNothing(t *testing.T) {
+	t.Helper()
+	select {
+	case line := <-r.lines:
+		t.Fatalf("Expected no output, got %v", line)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// finish closes stdin and checks the run ends cleanly.
+func (r *fakeRun) finish(t *testing.T) {
+	t.Helper()
+	_ = r.stdin.Close()
+	select {
+	case err := <-r.done:
+		if err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not end after stdin closed")
+	}
+}
+
+func userMessage(content string) map[string]any {
+	return map[string]any{
+		"type":    "user",
+		"message": map[string]any{"role": "user", "content": content},
+	}
+}
@@ -0,0 +1,156 @@
+package fakecli
+
+import "time"
+
+const (
+	// DefaultSessionID is the session ID reported when Script.SessionID is empty.
+	DefaultSessionID = "fakecli-session"
+	// DefaultModel is the model reported when Script.Model is empty.
+	DefaultModel = "fakecli-model"
+	// DefaultVersion is printed for --version when Script.Version is empty.
+	DefaultVersion = "2.0.0 (fakecli)"
+)
+
+// Script describes how the fake CLI answers. Each user message the SDK sends
+// plays the next Turn; a one-shot query plays the first Turn for its prompt
+// argument. User messages beyond the last Turn get an error result.
+type Script struct {
+	// SessionID is added to every message that does not set its own.
+	SessionID string `json:"session_id,omitempty"`
+	// Model is reported in the init message and assistant messages.
+	Model string `json:"model,omitempty"`
+	// Version is printed for --version.
+	Version string `json:"version,omitempty"`
+	// NoInit suppresses the system init message the real CLI sends before
+	// answering its first user message.
+	NoInit bool `json:"no_init,omitempty"`
+	// Turns are played in order, one per user message.
+	Turns []Turn `json:"turns"`
+}
+
+// Turn is the scripted answer to one user message.
+type Turn struct {
+	Steps []Step `json:"steps"`
+}
+
+// Step is one action within a Turn. Exactly one field should be set.
+type Step struct {
+	// Message is written to stdout as a stream-json line.
+	Message map[string]any `json:"message,omitempty"`
+	// Request is sent to the SDK as a control_request, and the turn waits
+	// for the matching control_response.
+	Request map[string]any `json:"request,omitempty"`
+	// Sleep pauses the turn.
+	Sleep time.Duration `json:"sleep,omitempty"`
+}
+
+// Reply returns a Turn that answers with text and a successful result.
+func Reply(text string) Turn {
+	return Turn{Steps: []Step{AssistantText(text), Result(text)}}
+}
+
+// Steps returns a Turn playing steps in order.
+func Steps(steps ...Step) Turn {
+	return Turn{Steps: steps}
+}
+
+// Message returns a Step writing msg verbatim.
+func Message(msg map[string]any) Step {
+	return Step{Message: msg}
+}
+
+// AssistantText returns a Step writing an assistant message with one text block.
+func AssistantText(text string) Step {
+	return assistant(map[string]any{"type": "text", "text": text})
+}
+
+// ToolUse returns a Step writing an assistant message that calls a tool.
+func ToolUse(id, name string, input map[string]any) Step {
+	if input == nil {
+		input = map[string]any{}
+	}
+	return assistant(map[string]any{"type": "tool_use", "id": id, "name": name, "input": input})
+}
+
+// ToolResult returns a Step writing the user message that carries a tool's result.
+func ToolResult(toolUseID, content string, isError bool) Step {
+	return Message(map[string]any{
+		"type": "user",
+		"message": map[string]any{
+			"role": "user",
+			"content": []any{map[string]any{
+				"type":        "tool_result",
+				"tool_use_id": toolUseID,
+				"content":     content,
+				"is_error":    isError,
+			}},
+		},
+	})
+}
+
+// Result returns a Step writing a successful result message.
+func Result(text string) Step {
+	return result("success", text, false)
+}
+
+// ErrorResult returns a Step writing a failed result message.
+func ErrorResult(text string) Step {
+	return result("error_during_execution", text, true)
+}
+
+// Request returns a Step sending a control request of the given subtype.
+func Request(subtype string, data map[string]any) Step {
+	request := map[string]any{"subtype": subtype}
+	for key, value := range data {
+		request[key] = value
+	}
+	return Step{Request: request}
+}
+
+// CanUseTool returns a Step asking the SDK for permission to run a tool.
+func CanUseTool(toolName, toolUseID string, input map[string]any) Step {
+	if input == nil {
+		input = map[string]any{}
+	}
+	return Request("can_use_tool", map[string]any{
+		"tool_name":              toolName,
+		"tool_use_id":            toolUseID,
+		"input":                  input,
+		"permission_suggestions": []any{},
+	})
+}
+
+// HookCallback returns a Step invoking the SDK hook registered as callbackID.
+func HookCallback(callbackID string, input map[string]any) Step {
+	return Request("hook_callback", map[string]any{
+		"callback_id": callbackID,
+		"input":       input,
+	})
+}
+
+// Sleep returns a Step pausing the turn for d.
+func Sleep(d time.Duration) Step {
+	return Step{Sleep: d}
+}
+
+func assistant(block map[string]any) Step {
+	// The model is filled in when the step is played
+	return Message(map[string]any{
+		"type":    "assistant",
+		"message": map[string]any{"role": "assistant", "content": []any{block}},
+	})
+}
+
+func result(subtype, text string, isError bool) Step {
+	return Message(map[string]any{
+		"type":            "result",
+		"subtype":         subtype,
+		"is_error":        isError,
+		"result":          text,
+		"duration_ms":     0,
+		"duration_api_ms": 0,
+		"num_turns":       1,
+		"total_cost_usd":  0,
+		"usage":           map[string]any{"input_tokens": 0, "output_tokens": 0},
+	})
+}
@@ -0,0 +1,117 @@
+// Package fakeclock provides a Clock whose time only moves when a test
+// advances it, so tests can check timeouts without sleeping:
+//
+//	clock := fakeclock.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+//	client := claudecode.NewClientWithTransport(transport, claudecode.WithClock(clock))
+//	go func() {
+//		clock.BlockUntilTimers(1) // the client is waiting
+//		clock.Advance(30 * time.Second)
+//	}()
+//	err := client.SetModel(ctx, "claude-test") // times out at once
+package fakeclock
+
+import (
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Clock is a claudecode.Clock controlled by the test. It is safe for
+// concurrent use.
+type Clock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	timers  []*timer
+}
+
+var _ claudecode.Clock = (*Clock)(nil)
+
+// New returns a Clock reading now until it is advanced.
+func New(now time.Time) *Clock {
+	c := &Clock{now: now}
+	c.changed = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the clock's time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer returns a timer firing once the clock has been advanced by d.
+// A timer for zero or less fires at once.
+func (c *Clock) NewTimer(d time.Duration) claudecode.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &timer{clock: c, when: c.now.Add(d), ch: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.ch <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.changed.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers due by then.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.when.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	c.timers = pending
+	c.changed.Broadcast()
+}
+
+// Timers returns the number of timers waiting to fire.
+func (c *Clock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntilTimers waits until at least n timers are waiting to fire, so a
+// test advances the clock only once the code under test has started its
+// timeout.
+func (c *Clock) BlockUntilTimers(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.changed.Wait()
+	}
+}
+
+type timer struct {
+	clock *Clock
+	when  time.Time
+	ch    chan time.Time
+}
+
+func (t *timer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *timer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.changed.Broadcast()
+			return true
+		}
+	}
+	return false
+}
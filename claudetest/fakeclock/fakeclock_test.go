@@ -0,0 +1,85 @@
+package fakeclock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/severity1/claude-code-sdk-go/claudetest/fakeclock"
+)
+
+var start = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestAdvanceFiresDueTimers(t *testing.T) {
+	clock := fakeclock.New(start)
+	short := clock.NewTimer(time.Second)
+	long := clock.NewTimer(time.Minute)
+
+	clock.Advance(30 * time.Second)
+	if got := clock.Now(); !got.Equal(start.Add(30 * time.Second)) {
+		t.Errorf("Expected the clock to move forward, got %v", got)
+	}
+	select {
+	case fired := <-short.C():
+		if !fired.Equal(start.Add(30 * time.Second)) {
+			t.Errorf("Expected the timer to receive the clock's time, got %v", fired)
+		}
+	default:
+		t.Error("Expected the due timer to fire")
+	}
+	select {
+	case <-long.C():
+		t.Error("Expected the later timer not to fire yet")
+	default:
+	}
+	if clock.Timers() != 1 {
+		t.Errorf("Expected 1 waiting timer, got %d", clock.Timers())
+	}
+}
+
+func TestStop(t *testing.T) {
+	clock := fakeclock.New(start)
+	timer := clock.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Error("Expected Stop to stop a waiting timer")
+	}
+	if timer.Stop() {
+		t.Error("Expected a second Stop to report false")
+	}
+	clock.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Error("Expected a stopped timer not to fire")
+	default:
+	}
+}
+
+func TestNonPositiveTimerFiresAtOnce(t *testing.T) {
+	clock := fakeclock.New(start)
+	select {
+	case <-clock.NewTimer(0).C():
+	default:
+		t.Error("Expected a zero timer to fire at once")
+	}
+}
+
+func TestBlockUntilTimers(t *testing.T) {
+	clock := fakeclock.New(start)
+	done := make(chan struct{})
+	go func() {
+		clock.BlockUntilTimers(2)
+		close(done)
+	}()
+
+	clock.NewTimer(time.Second)
+	select {
+	case <-done:
+		t.Fatal("Expected BlockUntilTimers to wait for the second timer")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.NewTimer(time.Second)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("BlockUntilTimers did not return")
+	}
+}
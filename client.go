@@ -2,37 +2,69 @@ package claudecode
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/pprof"
 	"sync"
+	"sync/atomic"
 
-	"github.com/severity1/claude-code-sdk-go/internal/cli"
 	"github.com/severity1/claude-code-sdk-go/internal/subprocess"
 )
 
 const defaultSessionID = "default"
 
+// clientChannelBufferSize is the buffer size for the client's message and error channels.
+const clientChannelBufferSize = 10
+
+// ErrInitTimeout is returned by Connect when WithInitTimeout is set and the
+// CLI does not send its init message in time.
+var ErrInitTimeout = errors.New("init message not received")
+
+// ErrClientClosed indicates an operation was abandoned because the client
+// was disconnected, for example a control request still waiting for its
+// response.
+var ErrClientClosed = errors.New("client closed")
+
 // Client provides bidirectional streaming communication with Claude Code CLI.
+// Accessors for the session's details, such as InitInfo, Turns and Hooks,
+// are methods of *ClientImpl, which NewClient returns.
 type Client interface {
 	Connect(ctx context.Context, prompt ...StreamMessage) error
 	Disconnect() error
-	Query(ctx context.Context, prompt string) error
-	QueryWithSession(ctx context.Context, prompt string, sessionID string) error
+	Query(ctx context.Context, prompt string, opts ...Option) error
+	QueryWithSession(ctx context.Context, prompt string, sessionID string, opts ...Option) error
 	QueryStream(ctx context.Context, messages <-chan StreamMessage) error
+	SendUserMessage(ctx context.Context, msg UserMessage) error
 	ReceiveMessages(ctx context.Context) <-chan Message
 	ReceiveResponse(ctx context.Context) MessageIterator
 	Interrupt(ctx context.Context) error
 	GetStreamIssues() []StreamIssue
 	GetStreamStats() StreamStats
 
+	// Context injection: files and snippets sent ahead of the next prompt
+	AddContextFile(path string) error
+	AddContextText(label, text string) error
+	ContextSize() int
+
 	// Control protocol methods for runtime configuration
 	SetPermissionMode(ctx context.Context, mode PermissionMode) error
 	SetModel(ctx context.Context, model string) error
 	RewindFiles(ctx context.Context, userMessageID string) error
 
+	// PermissionMode returns the permission mode the CLI is currently using.
+	PermissionMode() PermissionMode
+
 	// Permission and control support queries
 	HasPermissionSupport() bool
 	HasControlSupport() bool
+
+	// Wait blocks until the client is disconnected and every goroutine it
+	// started has exited.
+	Wait()
 }
 
 // ClientImpl implements the Client interface.
@@ -45,35 +77,135 @@ type ClientImpl struct {
 	msgChan         <-chan Message
 	errChan         <-chan error
 
+	// Goroutines of the current (or most recent) connection
+	lifecycle *lifecycle
+
+	// Tool call statistics, kept across reconnects
+	tools *toolTracker
+
+	// Tools reported by the CLI for the current session
+	initInfo *initTracker
+
+	// Slots for running tools, with WithMaxConcurrentTools
+	toolSlots *toolLimiter
+
+	// Whether the CLI of the current connection sends its tool events
+	toolEvents bool
+
+	// Tool calls denied with WithDryRun, kept across reconnects
+	dryRun *dryRunRecorder
+
+	// Path policy of the current connection, and the tool calls it denied,
+	// kept across reconnects
+	pathPolicy     PathPolicy
+	pathViolations *pathViolationRecorder
+
+	// Temporary working directory of the current (or most recent)
+	// connection, with WithEphemeralWorkspace
+	workspace *workspace
+
+	// CLI config directory of the client, with WithIsolatedConfig, kept
+	// across reconnects until removed
+	isolatedConfig *isolatedConfig
+
+	// Context sent ahead of the next query's prompt
+	pendingContext *ContextBuilder
+
+	// Key/value state of the current session, replaced when it ends
+	state *SessionState
+
+	// Usage totals of the current session, reported when it ends
+	session *sessionTracker
+
+	// Turn of the current query and the queries queued behind it
+	turns *turnState
+
+	// Interrupt of the current turn, with WithQueryDeadlinePolicy
+	deadline *turnDeadline
+
+	// Completed turns, kept across reconnects
+	turnLog *turnLog
+
+	// Results of turns sent with an idempotency key, with
+	// WithIdempotencyStore
+	idempotency *idempotentTurns
+
+	// CLI processes that exited while connected, kept across reconnects
+	processExits int32
+
 	// Control protocol integration
-	controlProtocol    ControlProtocol
-	permissionManager  PermissionManager
-	hookSystem         HookSystem
+	controlProtocol   ControlProtocol
+	permissionManager PermissionManager
+	hookSystem        HookSystem
+
+	// Runtime settings: defaults are the client-level values (updated by
+	// SetModel and SetPermissionMode), active values are what the CLI is
+	// currently using after any query-level overrides.
+	defaultModel          *string
+	activeModel           *string
+	defaultPermissionMode *PermissionMode
+	activePermissionMode  *PermissionMode
+
+	// Overrides of the running query, reverted when its turn ends
+	overrides overrideState
+	// WithMaxTurns of the running query
+	turnLimit *turnLimit
 }
 
 // NewClient creates a new Client with the given options.
 func NewClient(opts ...Option) Client {
 	options := NewOptions(opts...)
 	client := &ClientImpl{
-		options: options,
+		options:        options,
+		pendingContext: newContextBuilderFromOptions(options),
 	}
 	return client
 }
 
-// initControlSystems initializes the control systems after transport is available
-func (c *ClientImpl) initControlSystems() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// clock returns the clock set with WithClock, or the system clock.
+func (c *ClientImpl) clock() Clock {
+	if c.options != nil && c.options.Clock != nil {
+		return c.options.Clock
+	}
+	return SystemClock{}
+}
 
+// initControlSystems initializes the control systems after transport is available.
+// Must be called with c.mu held.
+func (c *ClientImpl) initControlSystems() {
 	if c.controlProtocol == nil && c.transport != nil {
-		c.controlProtocol = NewControlProtocol(c.transport)
+		var ids IDGenerator
+		if c.options != nil {
+			ids = c.options.IDGenerator
+		}
+		c.controlProtocol = newControlProtocol(c.transport, c.clock(), ids)
 	}
-	if c.permissionManager == nil {
-		c.permissionManager = NewPermissionManager()
+	c.ensurePermissionManager()
+	hooks := c.ensureHookSystem()
+	if c.controlProtocol == nil || c.options == nil {
+		return
 	}
-	if c.hookSystem == nil {
-		c.hookSystem = NewHookSystem()
+	c.controlProtocol.RegisterHandler(ControlRequestTypeHookCallback,
+		withSessionStateHandler(c.ensureState(), newHookCallbackHandler(hooks, c.toolSlots)))
+	if c.options.DryRun {
+		if c.dryRun == nil {
+			c.dryRun = &dryRunRecorder{}
+		}
+		c.controlProtocol.RegisterHandler(ControlRequestTypeCanUseTool,
+			withSessionStateHandler(c.ensureState(), newDryRunHandler(c.dryRun, c.options.Locale)))
+		return
+	}
+	if c.options.PlanReviewer == nil && c.pathPolicy == nil && !promptsOverStdio(c.options) {
+		return
 	}
+	handler := newCanUseToolHandler(c.options.PlanReviewer, c.permissionManager, c.options.Locale)
+	if c.pathPolicy != nil {
+		if c.pathViolations == nil {
+			c.pathViolations = &pathViolationRecorder{}
+		}
+		handler = guardToolPaths(c.pathPolicy, c.pathViolations, c.options, c.permissionManager, handler)
+	}
+	c.controlProtocol.RegisterHandler(ControlRequestTypeCanUseTool, withSessionStateHandler(c.ensureState(), handler))
 }
 
 // NewClientWithTransport creates a new Client with a custom transport (for testing).
@@ -82,6 +214,7 @@ func NewClientWithTransport(transport Transport, opts ...Option) Client {
 	return &ClientImpl{
 		customTransport: transport,
 		options:         options,
+		pendingContext:  newContextBuilderFromOptions(options),
 	}
 }
 
@@ -122,6 +255,9 @@ func NewClientWithTransport(transport Transport, opts ...Option) Client {
 // even if fn returns an error or panics. This provides 100% functional parity with Python SDK's
 // 'async with ClaudeSDKClient()' pattern while using idiomatic Go resource management.
 //
+// After disconnecting, the Stop and SessionEnd hooks and the WithFinalizer callback run exactly once.
+// SessionEnd reports whether fn panicked or ctx was canceled; a panic in fn is then re-raised.
+//
 // Parameters:
 //   - ctx: Context for connection management and cancellation
 //   - fn: Function to execute with the connected client
@@ -129,28 +265,32 @@ func NewClientWithTransport(transport Transport, opts ...Option) Client {
 //
 // Returns an error if connection fails or if fn returns an error.
 // Disconnect errors are handled gracefully without overriding the original error from fn.
+// When WithClient returns, no goroutines started by the SDK for this client remain.
 func WithClient(ctx context.Context, fn func(Client) error, opts ...Option) error {
 	if ctx.Err() != nil {
 		return ctx.Err()
 	}
 
-	client := NewClient(opts...)
+	client := NewClient(opts...).(*ClientImpl)
 
 	if err := client.Connect(ctx); err != nil {
 		return fmt.Errorf("failed to connect client: %w", err)
 	}
 
+	panicked := true
 	defer func() {
 		// Following Go idiom: cleanup errors don't override the original error
 		// This matches patterns in database/sql, os.File, and other stdlib packages
-		if disconnectErr := client.Disconnect(); disconnectErr != nil {
+		if disconnectErr := client.disconnect(withClientEndReason(ctx, panicked)); disconnectErr != nil {
 			// Log cleanup errors but don't return them to preserve the original error
 			// This follows the standard Go pattern for resource cleanup
 			_ = disconnectErr // Explicitly acknowledge we're ignoring this error
 		}
 	}()
 
-	return fn(client)
+	err := fn(client)
+	panicked = false
+	return err
 }
 
 // WithClientTransport provides Go-idiomatic resource management with a custom transport for testing.
@@ -176,21 +316,24 @@ func WithClientTransport(ctx context.Context, transport Transport, fn func(Clien
 		return ctx.Err()
 	}
 
-	client := NewClientWithTransport(transport, opts...)
+	client := NewClientWithTransport(transport, opts...).(*ClientImpl)
 
 	if err := client.Connect(ctx); err != nil {
 		return fmt.Errorf("failed to connect client: %w", err)
 	}
 
+	panicked := true
 	defer func() {
 		// Following Go idiom: cleanup errors don't override the original error
-		if disconnectErr := client.Disconnect(); disconnectErr != nil {
+		if disconnectErr := client.disconnect(withClientEndReason(ctx, panicked)); disconnectErr != nil {
 			// Log cleanup errors but don't return them to preserve the original error
 			_ = disconnectErr // Explicitly acknowledge we're ignoring this error
 		}
 	}()
 
-	return fn(client)
+	err := fn(client)
+	panicked = false
+	return err
 }
 
 // validateOptions validates the client configuration options
@@ -211,24 +354,47 @@ func (c *ClientImpl) validateOptions() error {
 		return fmt.Errorf("max_turns must be non-negative, got: %d", c.options.MaxTurns)
 	}
 
+	// Validate settings sources
+	if c.options.Settings != nil && c.options.SettingsJSON != nil {
+		return fmt.Errorf("WithSettings and WithSettingsJSON cannot both be set")
+	}
+
 	// Validate permission mode
-	if c.options.PermissionMode != nil {
-		validModes := map[PermissionMode]bool{
-			PermissionModeDefault:           true,
-			PermissionModeAcceptEdits:       true,
-			PermissionModePlan:              true,
-			PermissionModeBypassPermissions: true,
-		}
-		if !validModes[*c.options.PermissionMode] {
-			return fmt.Errorf("invalid permission mode: %s", string(*c.options.PermissionMode))
-		}
+	if c.options.PermissionMode != nil && !c.options.PermissionMode.IsValid() {
+		return fmt.Errorf("invalid permission mode: %s", string(*c.options.PermissionMode))
 	}
 
-	return nil
+	// Idempotency keys identify single queries
+	if c.options.IdempotencyKey != "" {
+		return fmt.Errorf("WithIdempotencyKey can only be set per query on a Client")
+	}
+
+	if err := validateDryRun(c.options); err != nil {
+		return err
+	}
+	if err := validatePathPolicy(c.options); err != nil {
+		return err
+	}
+
+	// Validate context limits
+	if c.options.ContextMaxBytes != nil && *c.options.ContextMaxBytes <= 0 {
+		return fmt.Errorf("context limit must be positive, got: %d", *c.options.ContextMaxBytes)
+	}
+	if c.options.ContextTruncation != "" && !c.options.ContextTruncation.IsValid() {
+		return fmt.Errorf("invalid context truncation policy: %s", string(c.options.ContextTruncation))
+	}
+
+	return c.options.Validate()
 }
 
 // Connect establishes a connection to the Claude Code CLI.
-func (c *ClientImpl) Connect(ctx context.Context, _ ...StreamMessage) error {
+//
+// Prompt messages are sent once the CLI is running and start the first
+// turn. The CLI reports the session it started in an init message, which
+// it only sends after reading its first input; with WithInitTimeout set,
+// Connect waits for that message and fails with ErrInitTimeout if it does
+// not arrive in time, so pass the opening prompt to Connect in that case.
+func (c *ClientImpl) Connect(ctx context.Context, prompt ...StreamMessage) error {
 	// Check context before acquiring lock
 	if ctx.Err() != nil {
 		return ctx.Err()
@@ -247,12 +413,56 @@ func (c *ClientImpl) Connect(ctx context.Context, _ ...StreamMessage) error {
 		return fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	// Each connection gets a fresh workspace, removed if it fails to start
+	connected := false
+	if c.options.EphemeralWorkspace != nil {
+		ws, err := newWorkspace(*c.options.EphemeralWorkspace, c.options)
+		if err != nil {
+			return err
+		}
+		c.workspace = ws
+		defer func() {
+			if !connected {
+				ws.restore(c.options)
+				_ = ws.remove(SessionEndDisconnect, false)
+				c.workspace = nil
+			}
+		}()
+	}
+	// The client's config directory, created by its first connection or
+	// after the previous one was removed
+	if c.options.IsolatedConfig != nil {
+		created := false
+		if c.isolatedConfig == nil || !c.isolatedConfig.usable() {
+			ic, err := newIsolatedConfig(*c.options.IsolatedConfig, c.options)
+			if err != nil {
+				return err
+			}
+			c.isolatedConfig, created = ic, true
+		}
+		ic := c.isolatedConfig
+		ic.attach(c.options)
+		defer func() {
+			if !connected {
+				ic.restore(c.options)
+				if created {
+					_ = ic.remove(SessionEndDisconnect, ConfigCleanupOnDisconnect)
+				}
+			}
+		}()
+	}
+	policy, err := connectionPathPolicy(c.options)
+	if err != nil {
+		return err
+	}
+	c.pathPolicy = policy
+
 	// Use custom transport if provided, otherwise create default
 	if c.customTransport != nil {
 		c.transport = c.customTransport
 	} else {
 		// Create default subprocess transport directly (like Python SDK)
-		cliPath, err := cli.FindCLI()
+		cliPath, err := findCLI(c.options)
 		if err != nil {
 			return fmt.Errorf("claude CLI not found: %w", err)
 		}
@@ -261,69 +471,524 @@ func (c *ClientImpl) Connect(ctx context.Context, _ ...StreamMessage) error {
 		c.transport = subprocess.New(cliPath, c.options, false, "sdk-go-client")
 	}
 
-	// Connect the transport
-	if err := c.transport.Connect(ctx); err != nil {
+	// With WithAutoReconnect, a CLI exiting to restart is replaced by one
+	// resuming the session behind the same channels
+	var reconnecting *reconnectingTransport
+	if policy := c.options.AutoReconnect; policy != nil {
+		reconnecting = newReconnectingTransport(c.transport, *policy, c.clock(), resumedSessionID(c.options), c.reconnectDialer())
+		c.transport = reconnecting
+	}
+
+	// Connect the transport. Goroutines it starts inherit the reader labels.
+	var transportMsgs <-chan Message
+	var transportErrs <-chan error
+	pprof.Do(ctx, goroutineLabels(resumedSessionID(c.options), GoroutineRoleReader), func(ctx context.Context) {
+		if err = c.transport.Connect(ctx); err != nil {
+			return
+		}
+		// Forward transport output through channels owned by the client, so
+		// Disconnect can close them regardless of how the transport behaves.
+		transportMsgs, transportErrs = c.transport.ReceiveMessages(ctx)
+	})
+	if err != nil {
 		return fmt.Errorf("failed to connect transport: %w", err)
 	}
+	msgChan := make(chan Message, clientChannelBufferSize)
+	errChan := make(chan error, clientChannelBufferSize)
+	c.msgChan, c.errChan = msgChan, errChan
+	c.lifecycle = newLifecycle()
+	if c.tools == nil {
+		var observer ToolObserver
+		if c.options != nil {
+			observer = c.options.ToolObserver
+		}
+		c.tools = newToolTracker(observer)
+	}
+	c.tools.resetPending()
+	if c.turnLog == nil {
+		c.turnLog = newTurnLog(c.options.TurnObserver)
+	}
+	c.turnLog.resetCurrent()
+	if c.idempotency == nil && c.options.IdempotencyStore != nil {
+		c.idempotency = newIdempotentTurns(c.options.IdempotencyStore)
+	}
+	c.initInfo = newInitTracker()
+	c.lifecycle.sessionID = connectionSessionID(c.initInfo, resumedSessionID(c.options))
+	c.session = newSessionTracker(c.clock().Now(), c.options.SessionTags)
+	c.turns = newTurnState()
+	c.deadline = &turnDeadline{}
+	c.turnLimit = &turnLimit{}
+	c.toolSlots = nil
+	if c.options.MaxConcurrentTools > 0 {
+		c.toolSlots = newToolLimiter(c.options.MaxConcurrentTools)
+	}
+	tools, initInfo, session, turns, toolSlots, deadline := c.tools, c.initInfo, c.session, c.turns, c.toolSlots, c.deadline
+	limit := c.turnLimit
+	turnLog, keys := c.turnLog, c.idempotency
+	if keys != nil {
+		keys.connect(c.lifecycle.ctx, c.lifecycle.spawn(GoroutineRoleWriter))
+	}
+	if reconnecting != nil {
+		reconnecting.onRestart(c.lifecycle.ctx, func() bool {
+			atomic.AddInt32(&c.processExits, 1)
+			atomic.AddInt32(&processExits, 1)
+			deadline.disarm()
+			limit.disarm()
+			tools.resetPending()
+			turnLog.resetCurrent()
+			if keys != nil {
+				keys.abandon()
+			}
+			if toolSlots != nil {
+				toolSlots.releaseAll()
+			}
+			return turns.interrupt()
+		})
+	}
+	// Control systems are ready before the first message is routed to them
+	c.initControlSystems()
+
+	observers := c.options.MessageObservers
+	mcpServers, mcpObserver := c.options.McpServers, c.options.McpObserver
+	ws, ic := c.workspace, c.isolatedConfig
+	protocol, _ := c.controlProtocol.(*controlProtocol)
+	controlCtx, answer := c.lifecycle.ctx, c.lifecycle.spawn(GoroutineRoleHook)
+	revert := c.lifecycle.spawn(GoroutineRoleWriter)
+	observe := func(msg Message) bool {
+		// Control messages go to the protocol, never to consumers
+		if protocol != nil && protocol.dispatch(controlCtx, msg, answer) {
+			return false
+		}
+		if keys != nil && keys.track(msg) {
+			// A stored result only ends the turn of the retried query
+			turns.track(msg)
+			return true
+		}
+		if ws != nil {
+			ws.track(msg)
+		}
+		if ic != nil {
+			ic.track(msg)
+		}
+		initInfo.track(msg)
+		if system, ok := msg.(*SystemMessage); ok {
+			if info, ok := system.Init(); ok {
+				labelGoroutine(info.SessionID, GoroutineRoleReader)
+			}
+		}
+		if mcpObserver != nil {
+			observeMcpServers(msg, mcpServers, mcpObserver)
+		}
+		tools.track(msg)
+		session.track(msg)
+		if _, ok := msg.(*ResultMessage); ok {
+			// Revert before the next query, queued or not, can be sent
+			if reverted := c.overrides.endTurn(); reverted != nil {
+				revert(func(<-chan struct{}) { c.revertQueryOverrides(controlCtx, reverted) })
+			}
+		}
+		turns.track(msg)
+		deadline.track(msg)
+		limit.track(msg)
+		turnLog.track(msg)
+		if toolSlots != nil {
+			toolSlots.track(msg)
+		}
+		for _, observer := range observers {
+			observer(msg)
+		}
+		return true
+	}
+	if timeouts := c.options.ToolTimeouts; len(timeouts) > 0 {
+		transport, lcCtx := c.transport, c.lifecycle.ctx
+		interrupt := func() {
+			ctx, cancel := context.WithTimeout(lcCtx, toolTimeoutInterruptTimeout)
+			defer cancel()
+			_ = transport.Interrupt(ctx)
+		}
+		source := transportMsgs
+		limited := make(chan Message)
+		transportMsgs = limited
+		c.lifecycle.Go(GoroutineRoleMonitor, func(done <-chan struct{}) { enforceToolTimeouts(done, source, limited, timeouts, interrupt) })
+	}
+	if keys != nil {
+		source := transportMsgs
+		merged := make(chan Message)
+		transportMsgs = merged
+		c.lifecycle.Go(GoroutineRoleReader, func(done <-chan struct{}) { mergeReplays(done, source, keys.replays, merged) })
+	}
+	streamEnded := make(chan struct{})
+	c.lifecycle.Go(GoroutineRoleReader, func(done <-chan struct{}) {
+		defer close(streamEnded)
+		forward(done, transportMsgs, msgChan, observe)
+		// The stream ended, so the running turn gets no ResultMessage
+		turns.end()
+		if keys != nil {
+			keys.abandon()
+		}
+		if session.transportEnded(done) {
+			atomic.AddInt32(&c.processExits, 1)
+			atomic.AddInt32(&processExits, 1)
+		}
+	})
+	lcCtx, sessionID := c.lifecycle.ctx, c.lifecycle.sessionID
+	c.lifecycle.Go(GoroutineRoleWriter, func(done <-chan struct{}) {
+		c.dispatchQueries(lcCtx, done, transportErrs, errChan, turns, sessionID)
+	})
+	if progress := c.options.ToolProgress; progress != nil {
+		interval := c.options.ToolProgressInterval
+		c.lifecycle.Go(GoroutineRoleMonitor, func(done <-chan struct{}) { reportToolProgress(done, tools, interval, progress) })
+	}
 
-	// Get message channels
-	c.msgChan, c.errChan = c.transport.ReceiveMessages(ctx)
+	// The CLI starts with the client-level model and permission mode
+	c.defaultModel = c.options.Model
+	c.activeModel = c.options.Model
+	c.defaultPermissionMode = c.options.PermissionMode
+	c.activePermissionMode = c.options.PermissionMode
 
-	// Initialize control systems after transport is ready
-	c.initControlSystems()
+	if err := c.registerToolHooks(ctx); err != nil {
+		c.abortConnect()
+		return err
+	}
+	if err := c.startSession(ctx, prompt, streamEnded); err != nil {
+		c.abortConnect()
+		return err
+	}
 
 	c.connected = true
+	connected = true
+	registerClient(c)
 	return nil
 }
 
+// registerToolHooks asks the CLI to send its tool events to the client
+// when the client has tool hooks or a tool limit, so both apply to tools
+// the CLI runs without asking permission. Must be called with c.mu held.
+func (c *ClientImpl) registerToolHooks(ctx context.Context) error {
+	c.toolEvents = false
+	if c.controlProtocol == nil || !c.controlProtocol.HasControlSupport() {
+		return nil
+	}
+	if c.toolSlots == nil && !c.ensureHookSystem().hasToolHooks() {
+		return nil
+	}
+	if _, err := c.controlProtocol.SendRequest(ctx, toolHooksRequest()); err != nil {
+		return fmt.Errorf("failed to register tool hooks: %w", err)
+	}
+	c.toolEvents = true
+	return nil
+}
+
+// startSession sends the prompt messages passed to Connect and, with
+// WithInitTimeout or WithMcpStrict, waits for the CLI's init message. Must
+// be called with c.mu held.
+func (c *ClientImpl) startSession(ctx context.Context, prompt []StreamMessage, streamEnded <-chan struct{}) error {
+	if len(prompt) > 0 {
+		c.turns.begin()
+		for _, msg := range prompt {
+			if err := c.transport.SendMessage(ctx, msg); err != nil {
+				return fmt.Errorf("failed to send initial prompt: %w", err)
+			}
+		}
+	}
+
+	timeout := c.options.InitTimeout
+	if timeout <= 0 && c.options.McpStrict {
+		timeout = defaultMcpStrictTimeout
+	}
+	if timeout <= 0 {
+		return nil
+	}
+	timer := c.clock().NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-c.initInfo.received:
+		if c.options.McpStrict {
+			return checkMcpServers(c.initInfo.info(), c.options.McpServers)
+		}
+		return nil
+	case <-timer.C():
+		return fmt.Errorf("%w: no init message within %s", ErrInitTimeout, timeout)
+	case <-streamEnded:
+		return fmt.Errorf("%w: CLI output ended before the init message", ErrInitTimeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// abortConnect tears down a connection whose startup failed. The session
+// never started, so no Stop or SessionEnd hooks run. Must be called with
+// c.mu held.
+func (c *ClientImpl) abortConnect() {
+	lc, protocol, transport := c.lifecycle, c.controlProtocol, c.transport
+	if c.toolSlots != nil {
+		c.toolSlots.close()
+	}
+	c.transport = nil
+	c.msgChan = nil
+	c.errChan = nil
+	c.controlProtocol = nil
+
+	lc.stop()
+	if protocol != nil {
+		_ = protocol.Close()
+	}
+	_ = transport.Close()
+	lc.finish()
+}
+
 // Disconnect closes the connection to the Claude Code CLI.
+//
+// The channels returned by ReceiveMessages are closed, pending control
+// requests fail with ErrClientClosed, and the CLI process is terminated.
+// Disconnect is idempotent and does not hold the client lock while the
+// transport shuts down, so it is safe to call concurrently with other
+// methods and from hook or permission callbacks. When called concurrently,
+// only the first call waits for the shutdown.
+//
+// Once teardown completes, the Stop hooks run, then the SessionEnd hooks,
+// then the finalizer set with WithFinalizer, exactly once per connection.
 func (c *ClientImpl) Disconnect() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	return c.disconnect(SessionEndDisconnect)
+}
 
-	if c.transport != nil && c.connected {
-		if err := c.transport.Close(); err != nil {
-			return fmt.Errorf("failed to close transport: %w", err)
+// disconnect tears down the connection and ends the session for reason.
+func (c *ClientImpl) disconnect(reason SessionEndReason) error {
+	c.mu.Lock()
+	if !c.connected {
+		c.mu.Unlock()
+		return nil
+	}
+	transport := c.transport
+	protocol := c.controlProtocol
+	toolSlots := c.toolSlots
+	lc := c.lifecycle
+	session := c.session
+	hooks := c.hookSystem
+	state := c.ensureState()
+	clock := c.clock()
+	var finalizer Finalizer
+	var cwd string
+	var keepWorkspace bool
+	var configCleanup ConfigCleanup
+	if c.options != nil {
+		finalizer = c.options.Finalizer
+		keepWorkspace = c.options.KeepWorkspaceOnFailure
+		configCleanup = c.options.ConfigCleanup
+		if c.options.Cwd != nil {
+			cwd = *c.options.Cwd
 		}
 	}
+	ws := c.workspace
+	if ws != nil {
+		ws.restore(c.options)
+	}
+	ic := c.isolatedConfig
+	if ic != nil {
+		ic.restore(c.options)
+	}
+
 	c.connected = false
 	c.transport = nil
 	c.msgChan = nil
 	c.errChan = nil
-	return nil
+	// A reconnect gets a control protocol bound to its new transport, and
+	// a new session its own state
+	c.controlProtocol = nil
+	c.state = nil
+	c.mu.Unlock()
+	unregisterClient(c)
+
+	lc.stop()
+	if protocol != nil {
+		_ = protocol.Close()
+	}
+	if toolSlots != nil {
+		toolSlots.close()
+	}
+
+	var err error
+	if transport != nil {
+		if closeErr := transport.Close(); closeErr != nil {
+			err = fmt.Errorf("failed to close transport: %w", closeErr)
+		}
+	}
+
+	lc.finish()
+	summary := session.finish(reason, clock.Now())
+	runSessionEnd(hooks, state, finalizer, summary, cwd)
+
+	// The workspace outlives the hooks and finalizer, which may inspect it
+	if ws != nil {
+		if removeErr := ws.remove(summary.Reason, keepWorkspace); removeErr != nil && err == nil {
+			err = fmt.Errorf("failed to remove workspace: %w", removeErr)
+		}
+	}
+	if ic != nil {
+		if removeErr := ic.remove(summary.Reason, configCleanup); removeErr != nil && err == nil {
+			err = fmt.Errorf("failed to remove config directory: %w", removeErr)
+		}
+	}
+	return err
+}
+
+// Wait blocks until the client is disconnected and every goroutine it
+// started has exited, including the CLI process being reaped. It returns
+// immediately if the client was never connected.
+func (c *ClientImpl) Wait() {
+	c.mu.RLock()
+	lc := c.lifecycle
+	c.mu.RUnlock()
+
+	if lc != nil {
+		<-lc.exited
+	}
+}
+
+// lifecycle ties together the goroutines of one connection. done is closed
+// (and ctx canceled) when the connection starts shutting down, and exited
+// once the transport is closed and every goroutine started through Go has
+// returned.
+type lifecycle struct {
+	done     chan struct{}
+	exited   chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+
+	// sessionID returns the connection's session ID for goroutine labels,
+	// or "" until it is known
+	sessionID func() string
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{
+		done:      make(chan struct{}),
+		exited:    make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+		sessionID: func() string { return "" },
+	}
+}
+
+// Go runs fn in a goroutine tracked by the lifecycle, labeled with role
+// and the session ID for goroutine profiles. fn must return promptly once
+// done is closed.
+func (l *lifecycle) Go(role string, fn func(done <-chan struct{})) {
+	l.wg.Add(1)
+	sessionID := l.sessionID()
+	go func() {
+		defer l.wg.Done()
+		labelGoroutine(sessionID, role)
+		fn(l.done)
+	}()
+}
+
+// spawn returns a function starting goroutines with role, for code taking
+// a plain spawn function.
+func (l *lifecycle) spawn(role string) func(func(done <-chan struct{})) {
+	return func(fn func(done <-chan struct{})) {
+		l.Go(role, fn)
+	}
+}
+
+// stop signals all goroutines to exit.
+func (l *lifecycle) stop() {
+	l.stopOnce.Do(func() {
+		l.cancel()
+		close(l.done)
+	})
+}
+
+// finish waits for all goroutines to exit and releases Wait callers.
+func (l *lifecycle) finish() {
+	l.wg.Wait()
+	close(l.exited)
+}
+
+// forward copies values from in to out until in is closed or done is
+// closed, then closes out. A non-nil observe sees each value before it is
+// delivered, and returns false to drop it.
+func forward[T any](done <-chan struct{}, in <-chan T, out chan<- T, observe func(T) bool) {
+	defer close(out)
+
+	for {
+		select {
+		case <-done:
+			return
+		case v, ok := <-in:
+			if !ok {
+				return
+			}
+			if observe != nil && !observe(v) {
+				continue
+			}
+			select {
+			case out <- v:
+			case <-done:
+				return
+			}
+		}
+	}
 }
 
 // Query sends a simple text query using the default session.
-// This is equivalent to QueryWithSession(ctx, prompt, "default").
+// This is equivalent to QueryWithSession(ctx, prompt, "default", opts...).
+//
+// Query-level options override the client-level configuration for this turn
+// only. WithModel and WithPermissionMode are applied through the control
+// protocol and reverted when the turn's ResultMessage arrives. WithMaxTurns
+// limits the model's responses in the turn: the client interrupts the turn
+// when tool results arrive after the last one. With WithIdempotencyKey, a
+// retried query whose key has a stored result gets that ResultMessage as
+// its turn instead of running again.
+//
+// A turn lasts until its ResultMessage arrives, or until the transport
+// fails or its stream ends. Calling Query during a turn, from any session,
+// returns ErrTurnInProgress unless WithQueryQueueing is enabled, in which
+// case the prompt is sent when the turn ends. Use SendUserMessage to add
+// input to the running turn instead. Earlier versions sent overlapping
+// queries to the CLI; read each response up to its ResultMessage before
+// the next Query, or enable WithQueryQueueing.
 //
 // Example:
 //
 //	client.Query(ctx, "What is Go?")
-func (c *ClientImpl) Query(ctx context.Context, prompt string) error {
-	return c.queryWithSession(ctx, prompt, defaultSessionID)
+//	for msg := range client.ReceiveMessages(ctx) {
+//	    if _, ok := msg.(*claudecode.ResultMessage); ok {
+//	        break
+//	    }
+//	}
+//	client.Query(ctx, "Review this design", claudecode.WithModel("opus"))
+func (c *ClientImpl) Query(ctx context.Context, prompt string, opts ...Option) error {
+	return c.queryWithSession(ctx, prompt, defaultSessionID, opts)
 }
 
 // QueryWithSession sends a simple text query using the specified session ID.
 // Each session maintains its own conversation context, allowing for isolated
 // conversations within the same client connection.
 //
-// If sessionID is empty, it defaults to "default".
+// If sessionID is empty, it defaults to "default". Query-level options behave
+// as described for Query.
 //
 // Example:
 //
 //	client.QueryWithSession(ctx, "Remember this", "my-session")
 //	client.QueryWithSession(ctx, "What did I just say?", "my-session") // Remembers context
 //	client.Query(ctx, "What did I just say?")                          // Won't remember, different session
-func (c *ClientImpl) QueryWithSession(ctx context.Context, prompt string, sessionID string) error {
+func (c *ClientImpl) QueryWithSession(ctx context.Context, prompt string, sessionID string, opts ...Option) error {
 	// Use default session if empty session ID provided
 	if sessionID == "" {
 		sessionID = defaultSessionID
 	}
-	return c.queryWithSession(ctx, prompt, sessionID)
+	return c.queryWithSession(ctx, prompt, sessionID, opts)
 }
 
 // queryWithSession is the internal implementation for sending queries with session management.
-func (c *ClientImpl) queryWithSession(ctx context.Context, prompt string, sessionID string) error {
+func (c *ClientImpl) queryWithSession(ctx context.Context, prompt string, sessionID string, opts []Option) error {
 	// Check context before proceeding
 	if ctx.Err() != nil {
 		return ctx.Err()
@@ -333,6 +998,7 @@ func (c *ClientImpl) queryWithSession(ctx context.Context, prompt string, sessio
 	c.mu.RLock()
 	connected := c.connected
 	transport := c.transport
+	turns, keys := c.turns, c.idempotency
 	c.mu.RUnlock()
 
 	if !connected || transport == nil {
@@ -344,35 +1010,396 @@ func (c *ClientImpl) queryWithSession(ctx context.Context, prompt string, sessio
 		return ctx.Err()
 	}
 
+	if queryIdempotencyKey(opts) != "" && keys == nil {
+		return fmt.Errorf("WithIdempotencyKey requires a client with WithIdempotencyStore")
+	}
+
+	// One turn at a time: queue or reject a query while another streams
+	if !turns.begin() {
+		if c.options == nil || !c.options.QueryQueueing {
+			return ErrTurnInProgress
+		}
+		if _, err := parseQueryOptions(opts); err != nil {
+			return err
+		}
+		turns.enqueue(queuedQuery{prompt: prompt, sessionID: sessionID, opts: opts})
+		return nil
+	}
+
+	if err := c.sendQuery(ctx, prompt, sessionID, opts); err != nil {
+		turns.end()
+		return err
+	}
+	return nil
+}
+
+// sendQuery applies the query's overrides and writes its prompt, with any
+// pending context, to the transport. A query whose idempotency key has a
+// stored result gets that result instead; the key is released if the
+// query cannot be sent.
+func (c *ClientImpl) sendQuery(ctx context.Context, prompt string, sessionID string, opts []Option) (err error) {
+	c.mu.RLock()
+	transport := c.transport
+	options := c.options
+	turnLog, keys := c.turnLog, c.idempotency
+	lc, deadline, limit := c.lifecycle, c.deadline, c.turnLimit
+	c.mu.RUnlock()
+
+	if transport == nil {
+		return fmt.Errorf("client not connected")
+	}
+	key := queryIdempotencyKey(opts)
+	if key != "" && keys != nil {
+		stored, err := claimIdempotencyKey(ctx, keys.store, key)
+		if err != nil {
+			return err
+		}
+		if stored != nil {
+			return keys.replay(ctx, stored)
+		}
+		defer func() {
+			if err != nil {
+				keys.fail(ctx, key)
+			}
+		}()
+	}
+
+	// Apply query-level overrides
+	overrides, err := c.applyQueryOptions(ctx, prompt, opts)
+	if err != nil {
+		return err
+	}
+
+	plan, hasDeadline := planDeadline(ctx, options.QueryDeadline, c.clock().Now())
+	if hasDeadline {
+		prompt = announceDeadline(prompt, options.QueryDeadline, plan, options.Locale)
+	}
+
+	// Bundle any pending context ahead of the prompt
+	msg := &UserMessage{Content: prompt}
+	blocks, consumed := c.pendingContext.build(prompt)
+	if consumed > 0 {
+		msg.Content = blocks
+	}
+	if err := interceptPrompt(ctx, options, msg); err != nil {
+		return err
+	}
+	content := msg.Content
+	if _, ok := content.(string); !ok {
+		wire, err := userContentToWire(content)
+		if err != nil {
+			return err
+		}
+		content = wire
+	}
+
 	// Create user message in Python SDK compatible format
 	streamMsg := StreamMessage{
 		Type: "user",
 		Message: map[string]interface{}{
 			"role":    "user",
-			"content": prompt,
+			"content": content,
 		},
 		ParentToolUseID: nil,
 		SessionID:       sessionID,
 	}
 
+	// Arm the deadline first so the turn cannot end before it is armed
+	if hasDeadline && lc != nil && deadline != nil {
+		deadline.arm(lc.spawn(GoroutineRoleMonitor), c.clock(), plan.budget, deadlineInterrupt(lc.ctx, transport))
+	}
+	if overrides.maxTurns > 0 && lc != nil && limit != nil {
+		limit.arm(overrides.maxTurns, lc.spawn(GoroutineRoleMonitor), deadlineInterrupt(lc.ctx, transport))
+	}
+
 	// Send message via transport (without holding mutex to avoid blocking other operations)
-	return transport.SendMessage(ctx, streamMsg)
+	turnLog.prompt(msg, key)
+	if key != "" && keys != nil {
+		keys.start(key)
+	}
+	if err := transport.SendMessage(ctx, streamMsg); err != nil {
+		turnLog.discardPrompt()
+		if hasDeadline && deadline != nil {
+			deadline.disarm()
+		}
+		return err
+	}
+	c.pendingContext.consume(consumed)
+	return nil
 }
 
-// QueryStream sends a stream of messages.
-func (c *ClientImpl) QueryStream(ctx context.Context, messages <-chan StreamMessage) error {
-	// Check connection status with read lock
+// AddContextFile adds the contents of the file at path to the context sent
+// ahead of the next query's prompt. The file is read immediately.
+//
+// Context is sent as labeled text blocks before the prompt and discarded
+// once sent. WithContextLimit bounds its size.
+//
+// Example:
+//
+//	if err := client.AddContextFile("internal/parser/json.go"); err != nil {
+//	    return err
+//	}
+//	client.AddContextText("failing test", testOutput)
+//	client.Query(ctx, "Why does the parser test fail?")
+func (c *ClientImpl) AddContextFile(path string) error {
+	return c.pendingContext.AddFile(path)
+}
+
+// AddContextText adds a labeled text snippet to the context sent ahead of
+// the next query's prompt.
+func (c *ClientImpl) AddContextText(label, text string) error {
+	return c.pendingContext.AddText(label, text)
+}
+
+// ContextSize returns the bytes of context waiting for the next query,
+// before any truncation.
+func (c *ClientImpl) ContextSize() int {
+	return c.pendingContext.Size()
+}
+
+// SendUserMessage sends additional user content on the default session.
+// Unlike Query it is intended for use while a turn is still active: the CLI's
+// stream-json input queues the message, so interactive apps can add
+// clarifications or pasted files without waiting for the ResultMessage.
+//
+// Content may be a string or a []ContentBlock. UUID and ParentToolUseID are
+// forwarded when set.
+//
+// Example:
+//
+//	client.Query(ctx, "Refactor the parser")
+//	client.SendUserMessage(ctx, claudecode.UserMessage{Content: "Keep the public API unchanged"})
+func (c *ClientImpl) SendUserMessage(ctx context.Context, msg UserMessage) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
 	c.mu.RLock()
 	connected := c.connected
 	transport := c.transport
+	options := c.options
+	turnLog := c.turnLog
 	c.mu.RUnlock()
 
 	if !connected || transport == nil {
 		return fmt.Errorf("client not connected")
 	}
 
-	// Send messages from channel in a goroutine
-	go func() {
+	if err := interceptPrompt(ctx, options, &msg); err != nil {
+		return err
+	}
+	content, err := userContentToWire(msg.Content)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"role":    "user",
+		"content": content,
+	}
+	if msg.UUID != nil {
+		payload["uuid"] = *msg.UUID
+	}
+
+	streamMsg := StreamMessage{
+		Type:            "user",
+		Message:         payload,
+		ParentToolUseID: msg.ParentToolUseID,
+		SessionID:       defaultSessionID,
+	}
+
+	turnLog.prompt(&msg, "")
+	if err := transport.SendMessage(ctx, streamMsg); err != nil {
+		turnLog.discardPrompt()
+		return err
+	}
+	return nil
+}
+
+// userContentToWire converts UserMessage content into the stream-json shape.
+// Content blocks are encoded with their "type" discriminator set from
+// BlockType, since blocks built in Go code usually leave MessageType empty.
+func userContentToWire(content interface{}) (interface{}, error) {
+	switch c := content.(type) {
+	case string:
+		if c == "" {
+			return nil, fmt.Errorf("user message content must not be empty")
+		}
+		return c, nil
+	case []ContentBlock:
+		if len(c) == 0 {
+			return nil, fmt.Errorf("user message content must not be empty")
+		}
+		blocks := make([]map[string]any, 0, len(c))
+		for i, block := range c {
+			if block == nil {
+				return nil, fmt.Errorf("user message content block %d is nil", i)
+			}
+			data, err := json.Marshal(block)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode content block %d: %w", i, err)
+			}
+			var wire map[string]any
+			if err := json.Unmarshal(data, &wire); err != nil {
+				return nil, fmt.Errorf("failed to encode content block %d: %w", i, err)
+			}
+			wire["type"] = block.BlockType()
+			blocks = append(blocks, wire)
+		}
+		return blocks, nil
+	default:
+		return nil, fmt.Errorf("unsupported user message content type: %T", content)
+	}
+}
+
+// queryOverrides holds the settings a single query overrides.
+type queryOverrides struct {
+	model          *string
+	permissionMode *PermissionMode
+	maxTurns       int
+	routeFlags     []string
+	idempotencyKey string
+}
+
+// parseQueryOptions applies opts to empty options to find out which settings
+// they override. Only settings the CLI can change mid-session are accepted.
+func parseQueryOptions(opts []Option) (queryOverrides, error) {
+	probe := &Options{}
+	for _, opt := range opts {
+		opt(probe)
+	}
+
+	overrides := queryOverrides{
+		model:          probe.Model,
+		permissionMode: probe.PermissionMode,
+		maxTurns:       probe.MaxTurns,
+		routeFlags:     probe.RouteFlags,
+		idempotencyKey: probe.IdempotencyKey,
+	}
+
+	probe.Model = nil
+	probe.PermissionMode = nil
+	probe.MaxTurns = 0
+	probe.RouteFlags = nil
+	probe.IdempotencyKey = ""
+	if !reflect.DeepEqual(probe, &Options{}) {
+		return overrides, fmt.Errorf("unsupported query option: only WithModel, WithPermissionMode, WithMaxTurns, " +
+			"WithRouteFlags and WithIdempotencyKey can be set per query")
+	}
+
+	if overrides.maxTurns < 0 {
+		return overrides, fmt.Errorf("max_turns must be non-negative, got: %d", overrides.maxTurns)
+	}
+
+	if overrides.permissionMode != nil && !overrides.permissionMode.IsValid() {
+		return overrides, fmt.Errorf("invalid permission mode: %s", string(*overrides.permissionMode))
+	}
+
+	return overrides, nil
+}
+
+// queryIdempotencyKey returns the idempotency key set among a query's
+// options, or "".
+func queryIdempotencyKey(opts []Option) string {
+	probe := &Options{}
+	for _, opt := range opts {
+		opt(probe)
+	}
+	return probe.IdempotencyKey
+}
+
+// applyQueryOptions brings the CLI's model and permission mode in line with
+// the client defaults plus the given query-level overrides, routing the
+// prompt to a model when a ModelRouter is set, and returns the overrides.
+// Control requests are only sent for settings that actually change, so
+// queries without overrides never touch the control protocol.
+func (c *ClientImpl) applyQueryOptions(ctx context.Context, prompt string, opts []Option) (queryOverrides, error) {
+	overrides, err := parseQueryOptions(opts)
+	if err != nil {
+		return overrides, err
+	}
+
+	// The previous turn's overrides are reverted first
+	if err := c.overrides.waitRevert(ctx); err != nil {
+		return overrides, err
+	}
+	c.overrides.mu.Lock()
+	defer c.overrides.mu.Unlock()
+
+	c.mu.RLock()
+	targetModel := c.defaultModel
+	targetMode := c.defaultPermissionMode
+	options, session := c.options, c.session
+	c.mu.RUnlock()
+
+	if overrides.model != nil {
+		targetModel = overrides.model
+	} else if options != nil && options.ModelRouter != nil {
+		flags := append(append([]string(nil), options.RouteFlags...), overrides.routeFlags...)
+		var spent float64
+		if session != nil {
+			spent = session.costUSD()
+		}
+		targetModel = routeModel(ctx, options, prompt, targetModel, flags, spent)
+	}
+	if overrides.permissionMode != nil {
+		targetMode = overrides.permissionMode
+	}
+
+	if err := c.alignSettings(ctx, targetModel, targetMode); err != nil {
+		return overrides, err
+	}
+	c.overrides.setOverridden(overrides.model != nil || overrides.permissionMode != nil)
+	return overrides, nil
+}
+
+// alignSettings makes the CLI use model and mode, sending control requests
+// only for the settings it is not using yet. Must be called with
+// c.overrides.mu held.
+func (c *ClientImpl) alignSettings(ctx context.Context, model *string, mode *PermissionMode) error {
+	c.mu.RLock()
+	activeModel, activeMode := c.activeModel, c.activePermissionMode
+	c.mu.RUnlock()
+
+	if !reflect.DeepEqual(model, activeModel) {
+		if err := c.sendSetModel(ctx, model); err != nil {
+			return fmt.Errorf("failed to apply query model: %w", err)
+		}
+		c.mu.Lock()
+		c.activeModel = model
+		c.mu.Unlock()
+	}
+
+	if !reflect.DeepEqual(mode, activeMode) {
+		wire := PermissionModeDefault
+		if mode != nil {
+			wire = *mode
+		}
+		if err := c.sendSetPermissionMode(ctx, wire); err != nil {
+			return fmt.Errorf("failed to apply query permission mode: %w", err)
+		}
+		c.mu.Lock()
+		c.activePermissionMode = mode
+		c.mu.Unlock()
+	}
+
+	return nil
+}
+
+// QueryStream sends a stream of messages.
+func (c *ClientImpl) QueryStream(ctx context.Context, messages <-chan StreamMessage) error {
+	// Check connection status with read lock
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if !c.connected || c.transport == nil {
+		return fmt.Errorf("client not connected")
+	}
+	transport := c.transport
+
+	// Send messages from channel in a goroutine that stops on Disconnect.
+	// Starting it under the read lock guarantees Disconnect waits for it.
+	c.lifecycle.Go(GoroutineRoleWriter, func(done <-chan struct{}) {
 		for {
 			select {
 			case msg, ok := <-messages:
@@ -385,9 +1412,11 @@ func (c *ClientImpl) QueryStream(ctx context.Context, messages <-chan StreamMess
 				}
 			case <-ctx.Done():
 				return
+			case <-done:
+				return
 			}
 		}
-	}()
+	})
 
 	return nil
 }
@@ -451,7 +1480,10 @@ func (c *ClientImpl) Interrupt(ctx context.Context) error {
 	return transport.Interrupt(ctx)
 }
 
-// clientIterator implements MessageIterator for client message reception
+// clientIterator implements MessageIterator for client message reception.
+// An error ends the iteration unless the stream goes on after it: a
+// MessageTooLargeError for a skipped line, a StreamIntegrityError, or an
+// error parsing a line.
 type clientIterator struct {
 	msgChan <-chan Message
 	errChan <-chan error
@@ -463,22 +1495,44 @@ func (ci *clientIterator) Next(ctx context.Context) (Message, error) {
 		return nil, ErrNoMoreMessages
 	}
 
-	select {
-	case msg, ok := <-ci.msgChan:
-		if !ok {
+	for {
+		select {
+		case msg, ok := <-ci.msgChan:
+			if !ok {
+				ci.closed = true
+				return nil, ErrNoMoreMessages
+			}
+			return msg, nil
+		case err, ok := <-ci.errChan:
+			if !ok {
+				// No more errors; keep reading messages
+				ci.errChan = nil
+				continue
+			}
+			if !recoverableStreamError(err) {
+				ci.closed = true
+			}
+			return nil, err
+		case <-ctx.Done():
 			ci.closed = true
-			return nil, ErrNoMoreMessages
+			return nil, ctx.Err()
 		}
-		return msg, nil
-	case err := <-ci.errChan:
-		ci.closed = true
-		return nil, err
-	case <-ctx.Done():
-		ci.closed = true
-		return nil, ctx.Err()
 	}
 }
 
+// recoverableStreamError reports whether the stream goes on after err, so
+// the iterator that returned it can be read further.
+// In strict ordering mode the message stream ends after a
+// StreamIntegrityError, which the next read reports.
+func recoverableStreamError(err error) bool {
+	var tooLarge *MessageTooLargeError
+	var integrity *StreamIntegrityError
+	var decode *JSONDecodeError
+	var parse *MessageParseError
+	return errors.As(err, &tooLarge) || errors.As(err, &integrity) ||
+		errors.As(err, &decode) || errors.As(err, &parse)
+}
+
 func (ci *clientIterator) Close() error {
 	ci.closed = true
 	return nil
@@ -503,6 +1557,194 @@ func (c *ClientImpl) GetStreamIssues() []StreamIssue {
 	return validator.GetIssues()
 }
 
+// ToolStats returns call counts, error rates and latency percentiles for
+// each tool used since the client was created, keyed by tool name. Latency
+// is measured from the tool use to its result arriving at the client.
+func (c *ClientImpl) ToolStats() map[string]ToolStats {
+	c.mu.RLock()
+	tools := c.tools
+	c.mu.RUnlock()
+
+	if tools == nil {
+		return map[string]ToolStats{}
+	}
+	return tools.stats()
+}
+
+// Turns returns the turns completed since the client was created, oldest
+// first. Each holds its prompt, the agent's messages, the tool calls and
+// the result, so they are kept in memory for the client's lifetime.
+func (c *ClientImpl) Turns() []Turn {
+	c.mu.RLock()
+	turnLog := c.turnLog
+	c.mu.RUnlock()
+
+	if turnLog == nil {
+		return nil
+	}
+	return turnLog.completed()
+}
+
+// EffectiveToolPolicy returns the allowed and disallowed tools configured on
+// the client and, once the CLI's init message has arrived, the tools it made
+// available. Allowed tools missing from the session are listed in
+// Unavailable, which catches misspelled names before the agent is denied.
+func (c *ClientImpl) EffectiveToolPolicy() ToolPolicy {
+	c.mu.RLock()
+	options, initInfo := c.options, c.initInfo
+	c.mu.RUnlock()
+
+	return initInfo.policy(options)
+}
+
+// InitInfo returns the model, working directory, tools, MCP servers,
+// permission mode and slash commands the CLI reported when the current
+// session started. It returns nil until the init message arrives, which the
+// CLI sends after reading the first prompt.
+func (c *ClientImpl) InitInfo() *InitInfo {
+	c.mu.RLock()
+	initInfo := c.initInfo
+	c.mu.RUnlock()
+
+	return initInfo.info()
+}
+
+// DryRunReport returns the tool calls the agent attempted since the client
+// was created, when WithDryRun is enabled. Each was denied instead of run.
+func (c *ClientImpl) DryRunReport() DryRunReport {
+	c.mu.RLock()
+	dryRun := c.dryRun
+	c.mu.RUnlock()
+
+	return dryRun.report()
+}
+
+// PathViolations returns the file tool calls the path policy denied since
+// the client was created, oldest first.
+func (c *ClientImpl) PathViolations() []PathViolation {
+	c.mu.RLock()
+	recorder := c.pathViolations
+	c.mu.RUnlock()
+
+	return recorder.list()
+}
+
+// Workspace returns the directory of the current or most recent connection
+// with WithEphemeralWorkspace. After a disconnect it has been removed,
+// unless it was kept because the session failed.
+func (c *ClientImpl) Workspace() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.workspace == nil {
+		return ""
+	}
+	return c.workspace.dir
+}
+
+// ConfigDir returns the CLI config directory of the client with
+// WithIsolatedConfig. After a disconnect it has been removed, unless the
+// cleanup policy kept it.
+func (c *ClientImpl) ConfigDir() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.isolatedConfig == nil {
+		return ""
+	}
+	return c.isolatedConfig.dir
+}
+
+// Memories returns the memory files of the current session: the CLAUDE.md
+// files the CLI reported in its init message, followed by the files added
+// with WithMemoryFiles. Before the init message arrives, only the added
+// files are listed.
+func (c *ClientImpl) Memories() []MemoryFile {
+	c.mu.RLock()
+	options := c.options
+	c.mu.RUnlock()
+
+	return memoryFiles(options, c.InitInfo())
+}
+
+// workingDir returns the CLI's working directory: the one from its init
+// message, or the configured one before the message arrives.
+func (c *ClientImpl) workingDir() string {
+	c.mu.RLock()
+	options, initInfo := c.options, c.initInfo
+	c.mu.RUnlock()
+
+	if cwd := initInfo.workingDir(); cwd != "" {
+		return cwd
+	}
+	if options != nil && options.Cwd != nil {
+		return *options.Cwd
+	}
+	cwd, _ := os.Getwd()
+	return cwd
+}
+
+// hookContext describes the current session, or the one that just ended,
+// to hooks.
+func (c *ClientImpl) hookContext() HookContext {
+	c.mu.RLock()
+	options, initInfo, activeModel := c.options, c.initInfo, c.activeModel
+	c.mu.RUnlock()
+
+	hookCtx := HookContext{
+		Cwd:            c.workingDir(),
+		PermissionMode: c.PermissionMode(),
+	}
+	if info := initInfo.info(); info != nil {
+		hookCtx.SessionID = info.SessionID
+		hookCtx.Model = info.Model
+	}
+	if activeModel != nil {
+		hookCtx.Model = *activeModel
+	}
+	if hookCtx.SessionID != "" {
+		hookCtx.TranscriptPath = transcriptPath(options, hookCtx.Cwd, hookCtx.SessionID)
+	}
+	return hookCtx
+}
+
+// transcriptPath returns where the CLI writes the transcript of a session:
+// a file named for the session in the config directory's projects folder,
+// in a directory named for the working directory with every character but
+// letters and digits replaced by dashes.
+func transcriptPath(options *Options, cwd, sessionID string) string {
+	configDir := cliConfigDir(options)
+	if configDir == "" {
+		return ""
+	}
+	project := []byte(cwd)
+	for i, ch := range project {
+		if !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9') {
+			project[i] = '-'
+		}
+	}
+	return filepath.Join(configDir, "projects", string(project), sessionID+".jsonl")
+}
+
+// cliConfigDir returns the CLI's config directory: the one set with
+// WithConfigDir, CLAUDE_CONFIG_DIR from the options' environment or the
+// process's, or ~/.claude. It returns "" when the home directory is unknown.
+func cliConfigDir(options *Options) string {
+	if options != nil {
+		if configDir := options.CLIEnv()["CLAUDE_CONFIG_DIR"]; configDir != "" {
+			return configDir
+		}
+	}
+	if configDir := os.Getenv("CLAUDE_CONFIG_DIR"); configDir != "" {
+		return configDir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".claude")
+}
+
 // GetStreamStats returns statistics about the message stream.
 // This includes counts of tools requested/received and pending tools.
 func (c *ClientImpl) GetStreamStats() StreamStats {
@@ -524,6 +1766,39 @@ func (c *ClientImpl) GetStreamStats() StreamStats {
 
 // SetPermissionMode changes permission mode during conversation
 func (c *ClientImpl) SetPermissionMode(ctx context.Context, mode PermissionMode) error {
+	if !mode.IsValid() {
+		return fmt.Errorf("invalid permission mode: %s", string(mode))
+	}
+	if err := c.sendSetPermissionMode(ctx, mode); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.defaultPermissionMode = &mode
+	c.activePermissionMode = &mode
+	c.mu.Unlock()
+	return nil
+}
+
+// PermissionMode returns the permission mode the CLI is currently using.
+// It reflects the client-level mode, any successful SetPermissionMode call,
+// and query-level overrides while their query is active. PermissionModeDefault
+// is returned when no mode was configured.
+func (c *ClientImpl) PermissionMode() PermissionMode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.activePermissionMode != nil {
+		return *c.activePermissionMode
+	}
+	if c.options != nil && c.options.PermissionMode != nil {
+		return *c.options.PermissionMode
+	}
+	return PermissionModeDefault
+}
+
+// sendSetPermissionMode sends a set_permission_mode control request
+func (c *ClientImpl) sendSetPermissionMode(ctx context.Context, mode PermissionMode) error {
 	c.mu.RLock()
 	controlProtocol := c.controlProtocol
 	c.mu.RUnlock()
@@ -545,6 +1820,20 @@ func (c *ClientImpl) SetPermissionMode(ctx context.Context, mode PermissionMode)
 
 // SetModel changes the AI model during conversation
 func (c *ClientImpl) SetModel(ctx context.Context, model string) error {
+	if err := c.sendSetModel(ctx, &model); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.defaultModel = &model
+	c.activeModel = &model
+	c.mu.Unlock()
+	return nil
+}
+
+// sendSetModel sends a set_model control request.
+// A nil model asks the CLI to switch back to its default model.
+func (c *ClientImpl) sendSetModel(ctx context.Context, model *string) error {
 	c.mu.RLock()
 	controlProtocol := c.controlProtocol
 	c.mu.RUnlock()
@@ -553,10 +1842,15 @@ func (c *ClientImpl) SetModel(ctx context.Context, model string) error {
 		return fmt.Errorf("control protocol not available")
 	}
 
+	var value any
+	if model != nil {
+		value = *model
+	}
+
 	req := &ControlRequest{
 		Subtype: ControlRequestTypeSetModel,
 		Data: map[string]any{
-			"model": model,
+			"model": value,
 		},
 	}
 
@@ -611,6 +1905,53 @@ func (c *ClientImpl) HasControlSupport() bool {
 	return controlProtocol.HasControlSupport()
 }
 
+// State returns the key/value store of the current session, or of the next
+// one before Connect. Once a session ends, after its SessionEnd hooks and
+// finalizer, the next session starts with an empty store; the store
+// returned earlier keeps the ended session's values.
+func (c *ClientImpl) State() *SessionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ensureState()
+}
+
+// ensureState creates the session state on first use. Must be called with
+// c.mu held.
+func (c *ClientImpl) ensureState() *SessionState {
+	if c.state == nil {
+		c.state = NewSessionState()
+	}
+	return c.state
+}
+
+// Permissions returns the stack of permission callbacks overriding the
+// client's callback. It is the same before Connect, while connected and
+// across reconnects.
+func (c *ClientImpl) Permissions() *PermissionStack {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &PermissionStack{pm: c.ensurePermissionManager()}
+}
+
+// ensurePermissionManager creates the permission manager on first use.
+// Must be called with c.mu held.
+func (c *ClientImpl) ensurePermissionManager() *permissionManager {
+	if c.permissionManager == nil {
+		pm := &permissionManager{}
+		if c.options != nil {
+			pm.locale = c.options.Locale
+		}
+		c.permissionManager = pm
+	}
+	return c.permissionManager.(*permissionManager)
+}
+
+// promptsOverStdio reports whether the CLI asks the SDK for permission to
+// use tools, with WithPermissionPromptToolName("stdio").
+func promptsOverStdio(options *Options) bool {
+	return options.PermissionPromptToolName != nil && *options.PermissionPromptToolName == "stdio"
+}
+
 // GetPermissionManager returns the permission manager for advanced usage
 func (c *ClientImpl) GetPermissionManager() PermissionManager {
 	c.mu.RLock()
@@ -619,6 +1960,38 @@ func (c *ClientImpl) GetPermissionManager() PermissionManager {
 	return pm
 }
 
+// Hooks returns the registry of the client's hooks. It is the same before
+// Connect, while connected and across reconnects.
+func (c *ClientImpl) Hooks() *HookRegistry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &HookRegistry{hs: c.ensureHookSystem(), client: c}
+}
+
+// HookStats returns run counts and durations of the client's hooks, by
+// event and pattern, sorted by event then pattern. Statistics are kept
+// across reconnects.
+func (c *ClientImpl) HookStats() []HookStats {
+	c.mu.Lock()
+	hs := c.ensureHookSystem()
+	c.mu.Unlock()
+	return hs.metrics.stats()
+}
+
+// ensureHookSystem creates the hook system on first use. Must be called
+// with c.mu held.
+func (c *ClientImpl) ensureHookSystem() *hookSystem {
+	if c.hookSystem == nil {
+		hs := newHookSystem(c.clock())
+		hs.session = c.hookContext
+		if c.options != nil {
+			hs.metrics.reportSlow(c.options.SlowHookThreshold, c.options.SlowHookReporter)
+		}
+		c.hookSystem = hs
+	}
+	return c.hookSystem.(*hookSystem)
+}
+
 // GetHookSystem returns the hook system for advanced usage
 func (c *ClientImpl) GetHookSystem() HookSystem {
 	c.mu.RLock()
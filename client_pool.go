@@ -0,0 +1,260 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrPoolClosed indicates the ClientPool has been closed.
+var ErrPoolClosed = errors.New("client pool closed")
+
+// ClientFactory creates an unconnected client for a ClientPool.
+type ClientFactory func() Client
+
+// ClientPool manages a bounded set of connected clients shared between
+// concurrent callers, so servers don't spawn one CLI process per request.
+//
+// Clients are handed out exclusively: a client acquired by one caller is not
+// given to another until it is released. Acquire takes an affinity key
+// (typically a session ID); later acquisitions with the same key prefer the
+// client that served it before, so the conversation context kept by that CLI
+// process is reused.
+//
+// Example:
+//
+//	pool := claudecode.NewClientPool(4, claudecode.WithModel("sonnet"))
+//	defer pool.Close()
+//
+//	client, err := pool.Acquire(ctx, sessionID)
+//	if err != nil {
+//		return err
+//	}
+//	defer pool.Release(client)
+//	err = client.QueryWithSession(ctx, prompt, sessionID)
+type ClientPool struct {
+	factory    ClientFactory
+	maxClients int
+
+	mu       sync.Mutex
+	clients  []*pooledClient
+	affinity map[string]*pooledClient
+	changed  chan struct{} // closed and replaced whenever a client is released
+	closed   bool
+}
+
+// pooledClient tracks the state of a client owned by the pool.
+type pooledClient struct {
+	client Client
+	busy   bool
+	keys   int // number of affinity keys bound to this client
+}
+
+// NewClientPool creates a pool of up to maxClients clients built with opts.
+// Clients are created and connected lazily on Acquire.
+func NewClientPool(maxClients int, opts ...Option) *ClientPool {
+	return NewClientPoolWithFactory(maxClients, func() Client {
+		return NewClient(opts...)
+	})
+}
+
+// NewClientPoolWithFactory creates a pool that builds clients with factory.
+// This is useful for custom transports and testing.
+func NewClientPoolWithFactory(maxClients int, factory ClientFactory) *ClientPool {
+	if maxClients < 1 {
+		maxClients = 1
+	}
+	return &ClientPool{
+		factory:    factory,
+		maxClients: maxClients,
+		affinity:   make(map[string]*pooledClient),
+		changed:    make(chan struct{}),
+	}
+}
+
+// Acquire returns a connected client for exclusive use until Release.
+// If affinityKey was served before, Acquire waits for that client rather than
+// picking another one. An empty affinityKey takes any idle client.
+func (p *ClientPool) Acquire(ctx context.Context, affinityKey string) (Client, error) {
+	for {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		pc, create := p.pickLocked(affinityKey)
+		if pc != nil {
+			pc.busy = true
+			p.bindLocked(affinityKey, pc)
+			p.mu.Unlock()
+
+			if create {
+				// Pooled clients outlive the caller that created them, and the
+				// subprocess transport ties the CLI process to the Connect
+				// context, so connect with a context of their own.
+				if err := pc.client.Connect(context.Background()); err != nil {
+					p.remove(pc)
+					return nil, fmt.Errorf("failed to connect pooled client: %w", err)
+				}
+			}
+			return pc.client, nil
+		}
+
+		changed := p.changed
+		p.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Release returns a client to the pool so it can serve other callers.
+func (p *ClientPool) Release(client Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pc := p.findLocked(client); pc != nil {
+		pc.busy = false
+		p.notifyLocked()
+	}
+}
+
+// Discard disconnects a client and removes it from the pool.
+// Use it instead of Release when the client is in an unknown state, for
+// example after a turn could not be drained.
+func (p *ClientPool) Discard(client Client) {
+	p.mu.Lock()
+	pc := p.findLocked(client)
+	p.mu.Unlock()
+
+	if pc != nil {
+		p.remove(pc)
+	}
+}
+
+// Size returns the number of clients currently in the pool.
+func (p *ClientPool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.clients)
+}
+
+// Close disconnects all clients. Clients still in use are disconnected too;
+// callers holding them will see "client not connected" errors.
+func (p *ClientPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	clients := p.clients
+	p.clients = nil
+	p.affinity = make(map[string]*pooledClient)
+	p.notifyLocked()
+	p.mu.Unlock()
+
+	var firstErr error
+	for _, pc := range clients {
+		if err := pc.client.Disconnect(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// pickLocked chooses a client for affinityKey. It returns (nil, false) when
+// the caller has to wait, and create=true when the client is new.
+// Must be called with p.mu held.
+func (p *ClientPool) pickLocked(affinityKey string) (pc *pooledClient, create bool) {
+	if affinityKey != "" {
+		if bound, ok := p.affinity[affinityKey]; ok {
+			if bound.busy {
+				return nil, false
+			}
+			return bound, false
+		}
+	}
+
+	// Prefer the idle client with the fewest bound sessions
+	var best *pooledClient
+	for _, candidate := range p.clients {
+		if candidate.busy {
+			continue
+		}
+		if best == nil || candidate.keys < best.keys {
+			best = candidate
+		}
+	}
+	if best != nil && (best.keys == 0 || len(p.clients) >= p.maxClients) {
+		return best, false
+	}
+
+	if len(p.clients) < p.maxClients {
+		pc = &pooledClient{client: p.factory()}
+		p.clients = append(p.clients, pc)
+		return pc, true
+	}
+
+	return best, false
+}
+
+// bindLocked records that affinityKey is served by pc.
+// Must be called with p.mu held.
+func (p *ClientPool) bindLocked(affinityKey string, pc *pooledClient) {
+	if affinityKey == "" {
+		return
+	}
+	if _, ok := p.affinity[affinityKey]; ok {
+		return
+	}
+	p.affinity[affinityKey] = pc
+	pc.keys++
+}
+
+// findLocked returns the pool entry for client.
+// Must be called with p.mu held.
+func (p *ClientPool) findLocked(client Client) *pooledClient {
+	for _, pc := range p.clients {
+		if pc.client == client {
+			return pc
+		}
+	}
+	return nil
+}
+
+// remove disconnects pc and drops it and its affinity bindings from the pool.
+func (p *ClientPool) remove(pc *pooledClient) {
+	p.mu.Lock()
+	for i, candidate := range p.clients {
+		if candidate == pc {
+			p.clients = append(p.clients[:i], p.clients[i+1:]...)
+			break
+		}
+	}
+	for key, bound := range p.affinity {
+		if bound == pc {
+			delete(p.affinity, key)
+		}
+	}
+	p.notifyLocked()
+	p.mu.Unlock()
+
+	_ = pc.client.Disconnect()
+}
+
+// notifyLocked wakes all goroutines waiting in Acquire.
+// Must be called with p.mu held.
+func (p *ClientPool) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
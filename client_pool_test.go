@@ -0,0 +1,211 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientPoolAffinity(t *testing.T) {
+	ctx, cancel := setupPoolTestContext(t, 5*time.Second)
+	defer cancel()
+
+	pool, transports := setupPoolForTest(t, 2)
+	defer pool.Close()
+
+	alice := acquirePoolClient(ctx, t, pool, "alice")
+	bob := acquirePoolClient(ctx, t, pool, "bob")
+	if alice == bob {
+		t.Fatal("Expected concurrent sessions to get different clients")
+	}
+	pool.Release(alice)
+	pool.Release(bob)
+
+	if again := acquirePoolClient(ctx, t, pool, "alice"); again != alice {
+		t.Error("Expected alice to get her previous client back")
+	}
+	if got := pool.Size(); got != 2 {
+		t.Errorf("Expected pool size 2, got %d", got)
+	}
+	if got := len(transports.all()); got != 2 {
+		t.Errorf("Expected 2 transports created, got %d", got)
+	}
+}
+
+func TestClientPoolWaitsForBoundClient(t *testing.T) {
+	ctx, cancel := setupPoolTestContext(t, 5*time.Second)
+	defer cancel()
+
+	pool, _ := setupPoolForTest(t, 2)
+	defer pool.Close()
+
+	first := acquirePoolClient(ctx, t, pool, "alice")
+
+	acquired := make(chan Client, 1)
+	go func() {
+		client, err := pool.Acquire(ctx, "alice")
+		if err != nil {
+			t.Errorf("Acquire failed: %v", err)
+		}
+		acquired <- client
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected second acquire for alice to wait for her busy client")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pool.Release(first)
+	if got := <-acquired; got != first {
+		t.Error("Expected waiting acquire to receive alice's client")
+	}
+}
+
+func TestClientPoolMaxClients(t *testing.T) {
+	ctx, cancel := setupPoolTestContext(t, 5*time.Second)
+	defer cancel()
+
+	pool, _ := setupPoolForTest(t, 1)
+	defer pool.Close()
+
+	held := acquirePoolClient(ctx, t, pool, "")
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer waitCancel()
+	if _, err := pool.Acquire(waitCtx, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected DeadlineExceeded while pool is exhausted, got %v", err)
+	}
+
+	pool.Release(held)
+	if got := acquirePoolClient(ctx, t, pool, "other"); got != held {
+		t.Error("Expected the released client to be reused")
+	}
+}
+
+func TestClientPoolDiscard(t *testing.T) {
+	ctx, cancel := setupPoolTestContext(t, 5*time.Second)
+	defer cancel()
+
+	pool, transports := setupPoolForTest(t, 1)
+	defer pool.Close()
+
+	client := acquirePoolClient(ctx, t, pool, "alice")
+	pool.Discard(client)
+
+	if got := pool.Size(); got != 0 {
+		t.Errorf("Expected empty pool after Discard, got size %d", got)
+	}
+	if !transports.closed(0) {
+		t.Error("Expected discarded client's transport to be closed")
+	}
+
+	if replacement := acquirePoolClient(ctx, t, pool, "alice"); replacement == client {
+		t.Error("Expected a new client after Discard")
+	}
+}
+
+func TestClientPoolClose(t *testing.T) {
+	ctx, cancel := setupPoolTestContext(t, 5*time.Second)
+	defer cancel()
+
+	pool, transports := setupPoolForTest(t, 1)
+	acquirePoolClient(ctx, t, pool, "")
+
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := pool.Acquire(ctx, "")
+		waiting <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	if err := pool.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := pool.Close(); err != nil {
+		t.Fatalf("second Close failed: %v", err)
+	}
+
+	if err := <-waiting; !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Expected waiting Acquire to fail with ErrPoolClosed, got %v", err)
+	}
+	if !transports.closed(0) {
+		t.Error("Expected Close to disconnect pooled clients")
+	}
+	if _, err := pool.Acquire(ctx, ""); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Expected ErrPoolClosed after Close, got %v", err)
+	}
+}
+
+func TestClientPoolConnectError(t *testing.T) {
+	ctx, cancel := setupPoolTestContext(t, 5*time.Second)
+	defer cancel()
+
+	connectErr := errors.New("connect failed")
+	pool := NewClientPoolWithFactory(1, func() Client {
+		return NewClientWithTransport(newMockTransportWithError("connect", connectErr))
+	})
+	defer pool.Close()
+
+	if _, err := pool.Acquire(ctx, "alice"); !errors.Is(err, connectErr) {
+		t.Errorf("Expected connect error, got %v", err)
+	}
+	if got := pool.Size(); got != 0 {
+		t.Errorf("Expected failed client to be removed, got size %d", got)
+	}
+}
+
+// Mock Transport Tracking
+
+type poolTransports struct {
+	mu         sync.Mutex
+	transports []*clientMockTransport
+}
+
+func (p *poolTransports) add(transport *clientMockTransport) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transports = append(p.transports, transport)
+}
+
+func (p *poolTransports) all() []*clientMockTransport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*clientMockTransport(nil), p.transports...)
+}
+
+func (p *poolTransports) closed(index int) bool {
+	transport := p.all()[index]
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	return transport.closed
+}
+
+// Helper functions
+
+func setupPoolTestContext(t *testing.T, timeout time.Duration) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+func setupPoolForTest(t *testing.T, maxClients int) (*ClientPool, *poolTransports) {
+	t.Helper()
+	transports := &poolTransports{}
+	pool := NewClientPoolWithFactory(maxClients, func() Client {
+		transport := newClientMockTransport()
+		transports.add(transport)
+		return NewClientWithTransport(transport)
+	})
+	return pool, transports
+}
+
+func acquirePoolClient(ctx context.Context, t *testing.T, pool *ClientPool, key string) Client {
+	t.Helper()
+	client, err := pool.Acquire(ctx, key)
+	if err != nil {
+		t.Fatalf("Acquire(%q) failed: %v", key, err)
+	}
+	return client
+}
@@ -2,10 +2,18 @@ package claudecode
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -250,11 +258,13 @@ func TestClientConcurrency(t *testing.T) {
 	ctx, cancel := setupClientTestContext(t, 30*time.Second)
 	defer cancel()
 
-	transport := newClientMockTransport()
-	client := setupClientForTest(t, transport)
+	// Overlapping queries are queued and sent one turn at a time
+	transport := newClientMockTransportWithOptions(WithClientAutoResult())
+	client := NewClientWithTransport(transport, WithQueryQueueing(true))
 	defer disconnectClientSafely(t, client)
 
 	connectClientSafely(ctx, t, client)
+	drainClientMessages(ctx, client)
 
 	// Run concurrent queries
 	const numGoroutines = 10
@@ -286,6 +296,7 @@ func TestClientConcurrency(t *testing.T) {
 
 	// Verify all messages were sent
 	expectedMessages := numGoroutines * queriesPerGoroutine
+	waitForSentMessages(t, transport, expectedMessages)
 	assertClientMessageCount(t, transport, expectedMessages)
 }
 
@@ -330,11 +341,19 @@ func TestClientConfiguration(t *testing.T) {
 		{"validation_error_invalid_cwd", []Option{
 			WithCwd("/nonexistent/test/directory"),
 		}, verifyValidationError},
+		{"validation_error_conflicting_settings", []Option{
+			WithSettings("settings.json"),
+			WithSettingsJSON([]byte(`{"env":{}}`)),
+		}, verifyValidationError},
+		{"validation_error_conflicting_tools", []Option{
+			WithAllowedTools("Read", "Bash"),
+			WithDisallowedTools("Bash"),
+		}, verifyValidationError},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			transport := newClientMockTransport()
+			transport := newClientMockTransportWithOptions(WithClientAutoResult())
 			client := NewClientWithTransport(transport, test.options...)
 			defer disconnectClientSafely(t, client)
 
@@ -567,7 +586,7 @@ func TestClientSessionID(t *testing.T) {
 	ctx, cancel := setupClientTestContext(t, 10*time.Second)
 	defer cancel()
 
-	transport := newClientMockTransport()
+	transport := newClientMockTransportWithOptions(WithClientAutoResult())
 	client := setupClientForTest(t, transport)
 	defer disconnectClientSafely(t, client)
 
@@ -576,6 +595,7 @@ func TestClientSessionID(t *testing.T) {
 	// Test query with default session ID
 	err := client.Query(ctx, "test message")
 	assertNoError(t, err)
+	awaitClientResult(ctx, t, client)
 
 	// Verify default session ID was used
 	sentMsg, ok := transport.getSentMessage(0)
@@ -589,6 +609,7 @@ func TestClientSessionID(t *testing.T) {
 	// Test query with custom session ID
 	err = client.QueryWithSession(ctx, "test message 2", "custom-session")
 	assertNoError(t, err)
+	awaitClientResult(ctx, t, client)
 
 	// Verify custom session ID was used
 	sentMsg, ok = transport.getSentMessage(1)
@@ -602,6 +623,7 @@ func TestClientSessionID(t *testing.T) {
 	// Test query with empty session ID (should use default)
 	err = client.QueryWithSession(ctx, "test message 3", "")
 	assertNoError(t, err)
+	awaitClientResult(ctx, t, client)
 
 	// Verify default session ID was used for empty string
 	sentMsg, ok = transport.getSentMessage(2)
@@ -622,11 +644,12 @@ func TestClientMultipleSessions(t *testing.T) {
 	ctx, cancel := setupClientTestContext(t, 15*time.Second)
 	defer cancel()
 
-	transport := newClientMockTransport()
-	client := setupClientForTest(t, transport)
+	transport := newClientMockTransportWithOptions(WithClientAutoResult())
+	client := NewClientWithTransport(transport, WithQueryQueueing(true))
 	defer disconnectClientSafely(t, client)
 
 	connectClientSafely(ctx, t, client)
+	drainClientMessages(ctx, client)
 
 	// Test concurrent operations with different session IDs
 	const numSessions = 3
@@ -660,6 +683,7 @@ func TestClientMultipleSessions(t *testing.T) {
 
 	// Verify all messages were sent
 	expectedMessageCount := numSessions * queriesPerSession
+	waitForSentMessages(t, transport, expectedMessageCount)
 	assertClientMessageCount(t, transport, expectedMessageCount)
 
 	// Verify session IDs were properly propagated
@@ -689,6 +713,7 @@ func TestClientMultipleSessions(t *testing.T) {
 	// Test session isolation: different sessions should not interfere
 	err := client.QueryWithSession(ctx, "final test", "session-1")
 	assertNoError(t, err)
+	waitForSentMessages(t, transport, expectedMessageCount+1)
 
 	// Verify the final message used correct session ID
 	finalMsg, ok := transport.getSentMessage(expectedMessageCount)
@@ -844,7 +869,7 @@ func TestClientGracefulShutdown(t *testing.T) {
 	defer cancel()
 
 	// Test option precedence (T160)
-	transport := newClientMockTransport()
+	transport := newClientMockTransportWithOptions(WithClientAutoResult())
 	client := NewClientWithTransport(transport,
 		WithSystemPrompt("first"),
 		WithSystemPrompt("second"), // Should override first
@@ -857,6 +882,7 @@ func TestClientGracefulShutdown(t *testing.T) {
 	// Test protocol compliance (T163) - messages should be properly formatted
 	err := client.Query(ctx, "test message")
 	assertNoError(t, err)
+	awaitClientResult(ctx, t, client)
 
 	sentMsg, ok := transport.getSentMessage(0)
 	if !ok {
@@ -870,6 +896,7 @@ func TestClientGracefulShutdown(t *testing.T) {
 	for i := 0; i < 5; i++ {
 		err := client.Query(ctx, fmt.Sprintf("memory test %d", i))
 		assertNoError(t, err)
+		awaitClientResult(ctx, t, client)
 	}
 
 	// Test graceful shutdown (T154) - disconnect should clean up resources
@@ -981,6 +1008,7 @@ type clientMockTransport struct {
 	testMessages []Message
 	msgChan      chan Message
 	errChan      chan error
+	autoResult   bool // Answer each user message with a ResultMessage
 
 	// Error injection for testing
 	connectError   error
@@ -1032,6 +1060,12 @@ func (c *clientMockTransport) SendMessage(ctx context.Context, message StreamMes
 		return fmt.Errorf("not connected")
 	}
 	c.sentMessages = append(c.sentMessages, message)
+	if c.autoResult && message.Type == userMessageType && c.msgChan != nil {
+		select {
+		case c.msgChan <- &ResultMessage{Subtype: "success", SessionID: message.SessionID}:
+		default:
+		}
+	}
 	return nil
 }
 
@@ -1169,6 +1203,10 @@ func WithClientResponseMessages(messages []Message) ClientMockTransportOption {
 	return func(t *clientMockTransport) { t.testMessages = messages }
 }
 
+func WithClientAutoResult() ClientMockTransportOption {
+	return func(t *clientMockTransport) { t.autoResult = true }
+}
+
 // Factory Functions - streamlined creation methods
 func newClientMockTransport() *clientMockTransport {
 	return &clientMockTransport{}
@@ -1216,6 +1254,58 @@ func connectClientSafely(ctx context.Context, t *testing.T, client Client) {
 	}
 }
 
+// writeTestFile writes content to path, creating its parent directories.
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// awaitClientResult reads messages until the current turn's ResultMessage,
+// so the next Query can start.
+func awaitClientResult(ctx context.Context, t *testing.T, client Client) {
+	t.Helper()
+	msgs := client.ReceiveMessages(ctx)
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				t.Fatal("Message channel closed before ResultMessage")
+			}
+			if _, ok := msg.(*ResultMessage); ok {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for ResultMessage")
+		}
+	}
+}
+
+// drainClientMessages discards messages until the client disconnects, so
+// auto results never fill the client's buffer.
+func drainClientMessages(ctx context.Context, client Client) {
+	go func() {
+		for range client.ReceiveMessages(ctx) {
+		}
+	}()
+}
+
+// waitForSentMessages waits until the transport has sent count messages.
+func waitForSentMessages(t *testing.T, transport *clientMockTransport, count int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for transport.getSentMessageCount() < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d sent messages, got %d", count, transport.getSentMessageCount())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
 func disconnectClientSafely(t *testing.T, client Client) {
 	t.Helper()
 	if err := client.Disconnect(); err != nil {
@@ -1306,6 +1396,7 @@ func verifyClientConfiguration(t *testing.T, client Client, transport *clientMoc
 			err = client.Query(ctx, queryText)
 		}
 		assertNoError(t, err)
+		awaitClientResult(ctx, t, client)
 	}
 
 	assertClientMessageCount(t, transport, config.messageCount)
@@ -1415,7 +1506,9 @@ func verifyValidationError(t *testing.T, client Client, _ *clientMockTransport)
 		// Verify it's a validation error (contains expected validation messages)
 		errStr := err.Error()
 		if !strings.Contains(errStr, "max_turns must be non-negative") &&
-			!strings.Contains(errStr, "working directory does not exist") {
+			!strings.Contains(errStr, "working directory does not exist") &&
+			!strings.Contains(errStr, "cannot both be set") &&
+			!strings.Contains(errStr, "cannot be both allowed and disallowed") {
 			t.Errorf("Expected validation error, got: %v", err)
 		}
 	}
@@ -1913,6 +2006,84 @@ func TestWithClient(t *testing.T) {
 	}
 }
 
+func TestClientIteratorContinuesAfterMessageTooLarge(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	msgChan := make(chan Message, 1)
+	errChan := make(chan error, 1)
+	iter := &clientIterator{msgChan: msgChan, errChan: errChan}
+
+	errChan <- NewMessageTooLargeError(2<<20, 1<<20)
+	_, err := iter.Next(ctx)
+	var tooLarge *MessageTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Expected MessageTooLargeError, got %v", err)
+	}
+
+	// The oversized line was skipped; the messages after it are delivered
+	msgChan <- &ResultMessage{Subtype: "success"}
+	msg, err := iter.Next(ctx)
+	if err != nil {
+		t.Fatalf("Expected reading to continue, got %v", err)
+	}
+	if _, ok := msg.(*ResultMessage); !ok {
+		t.Errorf("Expected the result, got %T", msg)
+	}
+}
+
+func TestClientReceiveResponseContinuesAfterIntegrityError(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	transport := newClientMockTransportWithOptions(
+		WithClientAsyncError(NewStreamIntegrityError(StreamIssue{Type: "duplicate_message", Description: "seen twice"})),
+		WithClientResponseMessages([]Message{
+			&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "hi"}}, Model: "claude-sonnet-4"},
+			&ResultMessage{Subtype: "success"},
+		}),
+	)
+	client := setupClientForTest(t, transport)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	// The error arrives apart from the messages, possibly after the result,
+	// so read until both have been seen
+	iter := client.ReceiveResponse(ctx)
+	var integrityErrors, messages int
+	for done := false; !done || integrityErrors == 0; {
+		msg, err := iter.Next(ctx)
+		var integrity *StreamIntegrityError
+		if errors.As(err, &integrity) {
+			integrityErrors++
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected the response to continue, got %v", err)
+		}
+		messages++
+		if _, ok := msg.(*ResultMessage); ok {
+			done = true
+		}
+	}
+	if integrityErrors != 1 || messages != 2 {
+		t.Errorf("Expected 1 integrity error and 2 messages, got %d and %d", integrityErrors, messages)
+	}
+}
+
+func TestClientValidatesSharedOptions(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	client := NewClientWithTransport(newClientMockTransport(), WithMaxMessageSize(0))
+	if err := client.Connect(ctx); err == nil || !strings.Contains(err.Error(), "MaxMessageSize") {
+		t.Errorf("Expected Connect to reject the options, got %v", err)
+	}
+	if err := validateQueryOptions(NewOptions(WithMaxMessageSize(0))); err == nil {
+		t.Error("Expected one-shot queries to reject the options")
+	}
+}
+
 // TestClientIteratorNextErrorPaths tests error scenarios in clientIterator.Next() method
 // Targets the missing 45.5% coverage in Next function error paths
 func TestClientIteratorNextErrorPaths(t *testing.T) {
@@ -2249,3 +2420,855 @@ func TestClientQueryNotConnectedError(t *testing.T) {
 		t.Errorf("Expected 'not connected' error, got: %v", err)
 	}
 }
+
+func TestClientQueryLevelOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		queries      [][]Option
+		clientOpts   []Option
+		wantRequests []map[string]any
+		wantErr      string
+	}{
+		{
+			name:         "no overrides sends no control requests",
+			queries:      [][]Option{nil, nil},
+			wantRequests: nil,
+		},
+		{
+			name:    "model override applies for one turn and is reverted",
+			queries: [][]Option{{WithModel("opus")}, nil},
+			wantRequests: []map[string]any{
+				{"model": "opus"},
+				{"model": nil},
+			},
+		},
+		{
+			name:       "model override reverts to client model",
+			clientOpts: []Option{WithModel("sonnet")},
+			queries:    [][]Option{{WithModel("opus")}, nil},
+			wantRequests: []map[string]any{
+				{"model": "opus"},
+				{"model": "sonnet"},
+			},
+		},
+		{
+			name:    "override reverted when its turn ends",
+			queries: [][]Option{{WithModel("opus")}},
+			wantRequests: []map[string]any{
+				{"model": "opus"},
+				{"model": nil},
+			},
+		},
+		{
+			name:    "consecutive identical overrides are applied per turn",
+			queries: [][]Option{{WithModel("opus")}, {WithModel("opus")}},
+			wantRequests: []map[string]any{
+				{"model": "opus"},
+				{"model": nil},
+				{"model": "opus"},
+				{"model": nil},
+			},
+		},
+		{
+			name:         "max turns needs no control request",
+			queries:      [][]Option{{WithMaxTurns(2)}},
+			wantRequests: nil,
+		},
+		{
+			name:    "permission mode override",
+			queries: [][]Option{{WithPermissionMode(PermissionModePlan)}, nil},
+			wantRequests: []map[string]any{
+				{"mode": "plan"},
+				{"mode": "default"},
+			},
+		},
+		{
+			name:    "unsupported option rejected",
+			queries: [][]Option{{WithSystemPrompt("be brief")}},
+			wantErr: "unsupported query option",
+		},
+		{
+			name:    "invalid permission mode rejected",
+			queries: [][]Option{{WithPermissionMode(PermissionMode("bogus"))}},
+			wantErr: "invalid permission mode",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := setupClientTestContext(t, 5*time.Second)
+			defer cancel()
+
+			transport := newClientControlMockTransport()
+			transport.autoResult = true
+			client := NewClientWithTransport(transport, test.clientOpts...)
+			connectClientSafely(ctx, t, client)
+			defer disconnectClientSafely(t, client)
+
+			var err error
+			for _, opts := range test.queries {
+				if err = client.Query(ctx, "test", opts...); err != nil {
+					break
+				}
+				awaitClientResult(ctx, t, client)
+			}
+			assertNoError(t, client.(*ClientImpl).overrides.waitRevert(ctx))
+
+			if test.wantErr != "" {
+				assertClientError(t, err, true, test.wantErr)
+				if transport.getSentMessageCount() != 0 {
+					t.Error("Expected no message to be sent when query options are rejected")
+				}
+				return
+			}
+			assertNoError(t, err)
+
+			requests := transport.getControlRequests()
+			if len(requests) != len(test.wantRequests) {
+				t.Fatalf("Expected %d control requests, got %d", len(test.wantRequests), len(requests))
+			}
+			for i, want := range test.wantRequests {
+				for key, value := range want {
+					if requests[i].Data[key] != value {
+						t.Errorf("Request %d: expected %s=%v, got %v", i, key, value, requests[i].Data[key])
+					}
+				}
+			}
+			assertClientMessageCount(t, transport.clientMockTransport, len(test.queries))
+		})
+	}
+}
+
+func TestClientQueryMaxTurns(t *testing.T) {
+	toolUse := &AssistantMessage{Content: []ContentBlock{&ToolUseBlock{ToolUseID: "t", Name: "Bash"}}}
+	text := &AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "running"}}}
+	tests := []struct {
+		name           string
+		maxTurns       int
+		messages       []Message
+		wantInterrupts int32
+	}{
+		{"within the limit", 2, []Message{text, toolUse, toolResultMessage("t", false), toolUse}, 0},
+		{"tool results after the last response", 1, []Message{text, toolUse, toolResultMessage("t", false)}, 1},
+		{"no limit", 0, []Message{toolUse, toolResultMessage("t", false), toolUse, toolResultMessage("t", false)}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := setupClientTestContext(t, 5*time.Second)
+			defer cancel()
+
+			transport := &interruptCountingTransport{clientMockTransport: newClientMockTransport()}
+			client := NewClientWithTransport(transport, WithMaxTurns(10))
+			connectClientSafely(ctx, t, client)
+
+			assertNoError(t, client.Query(ctx, "fix it", WithMaxTurns(test.maxTurns)))
+			transport.mu.Lock()
+			for _, msg := range test.messages {
+				transport.msgChan <- msg
+			}
+			transport.mu.Unlock()
+			deliverTurnResult(t, transport.clientMockTransport)
+			awaitClientResult(ctx, t, client)
+			disconnectClientSafely(t, client)
+			client.Wait()
+
+			if n := atomic.LoadInt32(&transport.interrupts); n != test.wantInterrupts {
+				t.Errorf("Expected %d interrupts, got %d", test.wantInterrupts, n)
+			}
+		})
+	}
+}
+
+func TestClientSetModelBecomesQueryDefault(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	transport := newClientControlMockTransport()
+	client := NewClientWithTransport(transport)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	assertNoError(t, client.SetModel(ctx, "haiku"))
+	assertNoError(t, client.Query(ctx, "first"))
+
+	// SetModel changes the default, so a plain Query must not revert it
+	if got := len(transport.getControlRequests()); got != 1 {
+		t.Errorf("Expected 1 control request, got %d", got)
+	}
+}
+
+func TestClientPermissionMode(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	transport := newClientControlMockTransport()
+	transport.autoResult = true
+	client := NewClientWithTransport(transport, WithPermissionMode(PermissionModeAcceptEdits))
+
+	// The configured mode is reported before connecting
+	if got := client.PermissionMode(); got != PermissionModeAcceptEdits {
+		t.Errorf("Expected %q before connect, got %q", PermissionModeAcceptEdits, got)
+	}
+
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	assertNoError(t, client.SetPermissionMode(ctx, PermissionModePlan))
+	if got := client.PermissionMode(); got != PermissionModePlan {
+		t.Errorf("Expected %q after SetPermissionMode, got %q", PermissionModePlan, got)
+	}
+
+	// A query-level override is the active mode until the next query
+	assertNoError(t, client.Query(ctx, "careful", WithPermissionMode(PermissionModeBypassPermissions)))
+	if got := client.PermissionMode(); got != PermissionModeBypassPermissions {
+		t.Errorf("Expected %q during override, got %q", PermissionModeBypassPermissions, got)
+	}
+	awaitClientResult(ctx, t, client)
+	assertNoError(t, client.Query(ctx, "normal"))
+	if got := client.PermissionMode(); got != PermissionModePlan {
+		t.Errorf("Expected %q after override, got %q", PermissionModePlan, got)
+	}
+
+	// Unknown modes are rejected without a control round-trip
+	sent := len(transport.getControlRequests())
+	assertClientError(t, client.SetPermissionMode(ctx, "auto"), true, "invalid permission mode")
+	if got := len(transport.getControlRequests()); got != sent {
+		t.Errorf("Expected no control request for invalid mode, got %d new", got-sent)
+	}
+	if got := client.PermissionMode(); got != PermissionModePlan {
+		t.Errorf("Expected mode unchanged after invalid SetPermissionMode, got %q", got)
+	}
+}
+
+func TestClientPermissionModeDefault(t *testing.T) {
+	client := setupClientForTest(t, newClientMockTransport())
+	if got := client.PermissionMode(); got != PermissionModeDefault {
+		t.Errorf("Expected %q when no mode is configured, got %q", PermissionModeDefault, got)
+	}
+}
+
+// clientControlMockTransport adds control request support to clientMockTransport.
+// Each control request is answered immediately with a success response on
+// the message stream, as the CLI answers it.
+type clientControlMockTransport struct {
+	*clientMockTransport
+	controlMu       sync.Mutex
+	controlRequests []*ControlRequest
+}
+
+func newClientControlMockTransport() *clientControlMockTransport {
+	return &clientControlMockTransport{clientMockTransport: newClientMockTransport()}
+}
+
+func (c *clientControlMockTransport) SendControlRequest(_ context.Context, req *ControlRequest) error {
+	c.controlMu.Lock()
+	c.controlRequests = append(c.controlRequests, req)
+	c.controlMu.Unlock()
+	return c.respond(&ControlResponse{ID: req.ID, Subtype: ControlResponseTypeSuccess})
+}
+
+// respond writes a control response to the message stream.
+func (c *clientControlMockTransport) respond(response *ControlResponse) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.msgChan == nil {
+		return fmt.Errorf("transport not connected")
+	}
+	c.msgChan <- &ControlResponseMessage{Response: response}
+	return nil
+}
+
+func (c *clientControlMockTransport) SupportsControlRequests() bool {
+	return true
+}
+
+func (c *clientControlMockTransport) getControlRequests() []*ControlRequest {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+	requests := make([]*ControlRequest, len(c.controlRequests))
+	copy(requests, c.controlRequests)
+	return requests
+}
+
+func TestClientCassetteReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A cassette recorded for the prompt "hi", replayed without a CLI
+	prompt, _ := json.Marshal(map[string]any{"role": "user", "content": "hi"})
+	sum := sha256.Sum256(prompt)
+	cassette, _ := json.Marshal(map[string]any{
+		"version": 1,
+		"interactions": []map[string]any{
+			{"lines": []string{}},
+			{"prompt": "sha256:" + hex.EncodeToString(sum[:]), "lines": []string{
+				`{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Hello"}],"model":"claude-3"}}`,
+				`{"type":"result","subtype":"success","duration_ms":1,"duration_api_ms":1,"is_error":false,"num_turns":1,"session_id":"s1"}`,
+			}},
+		},
+	})
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := os.WriteFile(path, cassette, 0o600); err != nil {
+		t.Fatalf("Failed to write cassette: %v", err)
+	}
+
+	client := NewClient(WithCassette(path, CassetteReplay), WithCLIPath(filepath.Join(t.TempDir(), "no-such-cli")))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	if err := client.Query(ctx, "hi"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	iter := client.ReceiveResponse(ctx)
+	msg, err := iter.Next(ctx)
+	if err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	assistant, ok := msg.(*AssistantMessage)
+	if !ok || assistant.Content[0].(*TextBlock).Text != "Hello" {
+		t.Errorf("Expected the recorded answer, got %#v", msg)
+	}
+	// The turn stays open until its result is read
+	if msg, err := iter.Next(ctx); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	} else if _, ok := msg.(*ResultMessage); !ok {
+		t.Fatalf("Expected the recorded result, got %#v", msg)
+	}
+
+	if err := client.Query(ctx, "something else"); !errors.Is(err, ErrCassetteMiss) {
+		t.Errorf("Expected ErrCassetteMiss, got %v", err)
+	}
+}
+
+func TestClientSendUserMessage(t *testing.T) {
+	uuid := "msg-uuid-1"
+	parentID := "toolu_123"
+
+	tests := []struct {
+		name     string
+		msg      UserMessage
+		wantErr  string
+		validate func(*testing.T, StreamMessage)
+	}{
+		{
+			name: "string content",
+			msg:  UserMessage{Content: "Also keep it short"},
+			validate: func(t *testing.T, sent StreamMessage) {
+				t.Helper()
+				payload := sent.Message.(map[string]interface{})
+				if payload["content"] != "Also keep it short" {
+					t.Errorf("Expected string content, got %v", payload["content"])
+				}
+				if sent.SessionID != defaultSessionID {
+					t.Errorf("Expected default session, got %q", sent.SessionID)
+				}
+			},
+		},
+		{
+			name: "content blocks get type discriminator",
+			msg: UserMessage{
+				Content: []ContentBlock{
+					&TextBlock{Text: "pasted file"},
+					&ToolResultBlock{ToolUseID: "toolu_1", Content: "ok"},
+				},
+				UUID:            &uuid,
+				ParentToolUseID: &parentID,
+			},
+			validate: func(t *testing.T, sent StreamMessage) {
+				t.Helper()
+				payload := sent.Message.(map[string]interface{})
+				blocks, ok := payload["content"].([]map[string]any)
+				if !ok || len(blocks) != 2 {
+					t.Fatalf("Expected 2 wire blocks, got %#v", payload["content"])
+				}
+				if blocks[0]["type"] != ContentBlockTypeText || blocks[0]["text"] != "pasted file" {
+					t.Errorf("Unexpected text block: %v", blocks[0])
+				}
+				if blocks[1]["type"] != ContentBlockTypeToolResult || blocks[1]["tool_use_id"] != "toolu_1" {
+					t.Errorf("Unexpected tool result block: %v", blocks[1])
+				}
+				if payload["uuid"] != uuid {
+					t.Errorf("Expected uuid %q, got %v", uuid, payload["uuid"])
+				}
+				if sent.ParentToolUseID == nil || *sent.ParentToolUseID != parentID {
+					t.Errorf("Expected parent tool use ID %q, got %v", parentID, sent.ParentToolUseID)
+				}
+			},
+		},
+		{
+			name:    "empty content rejected",
+			msg:     UserMessage{Content: ""},
+			wantErr: "must not be empty",
+		},
+		{
+			name:    "unsupported content rejected",
+			msg:     UserMessage{Content: 42},
+			wantErr: "unsupported user message content type",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := setupClientTestContext(t, 5*time.Second)
+			defer cancel()
+
+			transport := newClientMockTransport()
+			client := setupClientForTest(t, transport)
+			connectClientSafely(ctx, t, client)
+			defer disconnectClientSafely(t, client)
+
+			// A turn is in flight; follow-up content must not wait for its result
+			assertNoError(t, client.Query(ctx, "Refactor the parser"))
+
+			err := client.SendUserMessage(ctx, test.msg)
+			if test.wantErr != "" {
+				assertClientError(t, err, true, test.wantErr)
+				assertClientMessageCount(t, transport, 1)
+				return
+			}
+			assertNoError(t, err)
+			assertClientMessageCount(t, transport, 2)
+
+			sent, _ := transport.getSentMessage(1)
+			if sent.Type != "user" {
+				t.Errorf("Expected type user, got %q", sent.Type)
+			}
+			test.validate(t, sent)
+		})
+	}
+}
+
+func TestClientSendUserMessageNotConnected(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	client := setupClientForTest(t, newClientMockTransport())
+	err := client.SendUserMessage(ctx, UserMessage{Content: "hello"})
+	assertClientError(t, err, true, "not connected")
+}
+
+func TestClientDisconnectIdempotent(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	transport := newClientMockTransport()
+	client := setupClientForTest(t, transport)
+
+	// Disconnecting a client that never connected is a no-op
+	assertNoError(t, client.Disconnect())
+
+	connectClientSafely(ctx, t, client)
+	for i := 0; i < 3; i++ {
+		assertNoError(t, client.Disconnect())
+	}
+	assertClientDisconnected(t, transport)
+
+	// The client can be reconnected after repeated disconnects
+	connectClientSafely(ctx, t, client)
+	assertNoError(t, client.Query(ctx, "again"))
+	assertNoError(t, client.Disconnect())
+}
+
+func TestClientDisconnectClosesReceiveChannels(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	// The transport never closes its channels on its own
+	transport := &clientUnclosedMockTransport{clientMockTransport: newClientMockTransport()}
+	client := setupClientForTest(t, transport)
+	connectClientSafely(ctx, t, client)
+
+	msgChan := client.ReceiveMessages(ctx)
+	iter := client.ReceiveResponse(ctx)
+
+	assertNoError(t, client.Disconnect())
+
+	select {
+	case _, ok := <-msgChan:
+		if ok {
+			t.Error("Expected message channel to be closed")
+		}
+	case <-ctx.Done():
+		t.Fatal("Message channel was not closed by Disconnect")
+	}
+
+	msg, err := iter.Next(ctx)
+	if msg != nil || !errors.Is(err, ErrNoMoreMessages) {
+		t.Errorf("Expected ErrNoMoreMessages after Disconnect, got msg=%v err=%v", msg, err)
+	}
+}
+
+func TestClientDisconnectFailsPendingControlRequests(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	transport := newClientSilentControlMockTransport()
+	client := setupClientForTest(t, transport)
+	connectClientSafely(ctx, t, client)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- client.SetModel(ctx, "haiku")
+	}()
+
+	select {
+	case <-transport.sent:
+	case <-ctx.Done():
+		t.Fatal("Control request was never sent")
+	}
+	assertNoError(t, client.Disconnect())
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrClientClosed) {
+			t.Errorf("Expected ErrClientClosed, got %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Pending control request was not failed by Disconnect")
+	}
+}
+
+func TestClientDisconnectReentrant(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	// Simulates a callback that fires while the transport shuts down and
+	// calls back into the client, including a nested Disconnect.
+	transport := &clientReentrantMockTransport{clientMockTransport: newClientMockTransport()}
+	client := setupClientForTest(t, transport)
+	transport.onClose = func() {
+		_ = client.Interrupt(ctx)
+		_ = client.Query(ctx, "late")
+		_ = client.Disconnect()
+	}
+	connectClientSafely(ctx, t, client)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Disconnect()
+	}()
+
+	select {
+	case err := <-done:
+		assertNoError(t, err)
+	case <-ctx.Done():
+		t.Fatal("Disconnect deadlocked when called back from transport Close")
+	}
+
+	// Disconnect from a goroutine consuming messages, as a message handler would
+	connectClientSafely(ctx, t, client)
+	transport.onClose = nil
+	msgChan := client.ReceiveMessages(ctx)
+	assertNoError(t, transport.inject(&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "bye"}}}))
+
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		for range msgChan {
+			_ = client.Disconnect()
+		}
+	}()
+
+	select {
+	case <-handled:
+	case <-ctx.Done():
+		t.Fatal("Disconnect from message handler did not close the channel")
+	}
+}
+
+func TestClientConcurrentCloseStress(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 30*time.Second)
+	defer cancel()
+
+	const iterations = 50
+	const callers = 4
+
+	for i := 0; i < iterations; i++ {
+		transport := newClientControlMockTransport()
+		client := setupClientForTest(t, transport)
+		connectClientSafely(ctx, t, client)
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for j := 0; j < callers; j++ {
+			wg.Add(4)
+			go func() {
+				defer wg.Done()
+				<-start
+				_ = client.Query(ctx, "stress")
+			}()
+			go func() {
+				defer wg.Done()
+				<-start
+				_ = client.Interrupt(ctx)
+			}()
+			go func() {
+				defer wg.Done()
+				<-start
+				for range client.ReceiveMessages(ctx) {
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				<-start
+				if err := client.Disconnect(); err != nil {
+					t.Errorf("Disconnect failed: %v", err)
+				}
+			}()
+		}
+		close(start)
+
+		finished := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			t.Fatalf("Iteration %d deadlocked", i)
+		}
+		assertClientDisconnected(t, transport.clientMockTransport)
+	}
+}
+
+// clientUnclosedMockTransport returns channels that are never closed.
+type clientUnclosedMockTransport struct {
+	*clientMockTransport
+}
+
+func (c *clientUnclosedMockTransport) ReceiveMessages(_ context.Context) (<-chan Message, <-chan error) {
+	return make(chan Message), make(chan error)
+}
+
+// clientSilentControlMockTransport accepts control requests but never answers them.
+type clientSilentControlMockTransport struct {
+	*clientMockTransport
+	sent chan struct{}
+}
+
+func newClientSilentControlMockTransport() *clientSilentControlMockTransport {
+	return &clientSilentControlMockTransport{
+		clientMockTransport: newClientMockTransport(),
+		sent:                make(chan struct{}, 1),
+	}
+}
+
+func (c *clientSilentControlMockTransport) SendControlRequest(_ context.Context, _ *ControlRequest) error {
+	select {
+	case c.sent <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (c *clientSilentControlMockTransport) SupportsControlRequests() bool {
+	return true
+}
+
+// clientReentrantMockTransport calls onClose before closing, without holding its lock.
+type clientReentrantMockTransport struct {
+	*clientMockTransport
+	onClose func()
+}
+
+func (c *clientReentrantMockTransport) Close() error {
+	if c.onClose != nil {
+		c.onClose()
+	}
+	return c.clientMockTransport.Close()
+}
+
+func (c *clientReentrantMockTransport) inject(msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.msgChan == nil {
+		return fmt.Errorf("transport not receiving")
+	}
+	c.msgChan <- msg
+	return nil
+}
+
+func TestWithClientLeavesNoGoroutines(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	baseline := currentGoroutineIDs(t)
+
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "hi"}}, Model: "claude-sonnet-4-5"},
+	}))
+	// Never closed: the writer goroutine must still exit when the client disconnects
+	stream := make(chan StreamMessage)
+
+	err := WithClientTransport(ctx, transport, func(client Client) error {
+		if err := client.QueryStream(ctx, stream); err != nil {
+			return err
+		}
+		if err := client.Query(ctx, "hello"); err != nil {
+			return err
+		}
+		<-client.ReceiveMessages(ctx)
+		return nil
+	})
+	assertNoError(t, err)
+
+	assertNoLeakedGoroutines(t, baseline)
+}
+
+func TestClientWait(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	baseline := currentGoroutineIDs(t)
+	client := setupClientForTest(t, newClientMockTransport())
+
+	// Never connected: nothing to wait for
+	waitClientWithin(ctx, t, client)
+
+	connectClientSafely(ctx, t, client)
+	assertNoError(t, client.QueryStream(ctx, make(chan StreamMessage)))
+
+	waited := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+		t.Fatal("Wait returned while the client was still connected")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assertNoError(t, client.Disconnect())
+	select {
+	case <-waited:
+	case <-ctx.Done():
+		t.Fatal("Wait did not return after Disconnect")
+	}
+
+	// Wait after Disconnect returns immediately
+	waitClientWithin(ctx, t, client)
+	assertNoLeakedGoroutines(t, baseline)
+}
+
+func TestClientMessageObservers(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	var seen []string
+	record := func(prefix string) MessageObserver {
+		return func(msg Message) {
+			seen = append(seen, prefix+msg.Type())
+		}
+	}
+
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "hi"}}, Model: "m"},
+		&ResultMessage{Subtype: "success"},
+	}))
+	client := NewClientWithTransport(transport, WithMessageObserver(record("first:")), WithMessageObserver(record("second:")))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	for msg := range client.ReceiveMessages(ctx) {
+		if _, ok := msg.(*ResultMessage); ok {
+			// Observers have seen a message by the time it is delivered
+			want := "first:assistant second:assistant first:result second:result"
+			if got := strings.Join(seen, " "); got != want {
+				t.Errorf("Expected observers in order before delivery, got %q", got)
+			}
+			return
+		}
+	}
+	t.Error("Stream ended before the result")
+}
+
+func waitClientWithin(ctx context.Context, t *testing.T, client Client) {
+	t.Helper()
+	waited := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-ctx.Done():
+		t.Fatal("Wait blocked unexpectedly")
+	}
+}
+
+// currentGoroutineIDs returns the IDs of all running goroutines, used as the
+// baseline for assertNoLeakedGoroutines.
+func currentGoroutineIDs(t *testing.T) map[int]bool {
+	t.Helper()
+	ids := make(map[int]bool)
+	for _, stack := range goroutineStacks() {
+		ids[goroutineID(stack)] = true
+	}
+	return ids
+}
+
+// assertNoLeakedGoroutines fails if goroutines running SDK code were started
+// after baseline was taken and are still running. Goroutines get a short
+// grace period to finish unwinding.
+func assertNoLeakedGoroutines(t *testing.T, baseline map[int]bool) {
+	t.Helper()
+	var leaked []string
+	for attempt := 0; attempt < 50; attempt++ {
+		leaked = leaked[:0]
+		for _, stack := range goroutineStacks() {
+			if !baseline[goroutineID(stack)] && runsSDKCode(stack) {
+				leaked = append(leaked, stack)
+			}
+		}
+		if len(leaked) == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Found %d leaked SDK goroutines:\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+}
+
+// goroutineStacks returns the stack of every goroutine except the caller's.
+func goroutineStacks() []string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	// The first stack is always the calling goroutine
+	return strings.Split(strings.TrimSpace(string(buf)), "\n\n")[1:]
+}
+
+func goroutineID(stack string) int {
+	// Stacks start with "goroutine <id> [<state>]:"
+	fields := strings.Fields(stack)
+	if len(fields) < 2 {
+		return -1
+	}
+	id, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return -1
+	}
+	return id
+}
+
+// runsSDKCode reports whether any frame of stack is non-test SDK code.
+func runsSDKCode(stack string) bool {
+	lines := strings.Split(stack, "\n")
+	for i := 1; i+1 < len(lines); i += 2 {
+		function := strings.TrimPrefix(lines[i], "created by ")
+		file := strings.TrimSpace(lines[i+1])
+		if strings.HasPrefix(function, "github.com/severity1/claude-code-sdk-go") &&
+			!strings.Contains(file, "_test.go:") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,331 @@
+// Package codegen has the agent write code under verification, starting
+// with tests for a Go package.
+//
+// GenerateTests points the agent at a package directory with a tool policy
+// that lets it read anything but write only _test.go files in that
+// directory. After each turn a verifier, go test by default, checks the
+// result; failures are sent back to the agent to fix, a bounded number of
+// times:
+//
+//	report, err := codegen.GenerateTests(ctx, "./internal/parser",
+//		codegen.WithInstructions("Use table-driven tests"),
+//		codegen.WithClientOptions(claudecode.WithModel("sonnet")),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(report.Created, report.Passed)
+package codegen
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// DefaultFixAttempts is how many times failing tests are sent back to the
+// agent when WithFixAttempts is not used.
+const DefaultFixAttempts = 2
+
+// maxFailureOutput bounds the verifier output sent back to the agent; the
+// end of the output, where go test reports failures, is kept.
+const maxFailureOutput = 8 << 10
+
+// testFileSuffix ends the names of the files the agent may write.
+const testFileSuffix = "_test.go"
+
+// VerifyFunc checks the package in dir, returning whether it passed and the
+// output to show the agent when it did not. An error means verification
+// could not run, and ends generation.
+type VerifyFunc func(ctx context.Context, dir string) (passed bool, output string, err error)
+
+// Report is the outcome of GenerateTests.
+type Report struct {
+	// Dir is the package directory.
+	Dir string
+	// Created and Modified are the test files the agent created or
+	// changed, relative to Dir and sorted.
+	Created  []string
+	Modified []string
+	// Passed reports whether the last verification passed, and Output is
+	// its output.
+	Passed bool
+	Output string
+	// Verifications counts the verifier runs, the first one included.
+	Verifications int
+	CostUSD       float64
+}
+
+// Option configures GenerateTests.
+type Option func(*generator)
+
+// WithClientOptions adds options for the agent's client. The tool policy
+// and working directory are applied after them.
+func WithClientOptions(opts ...claudecode.Option) Option {
+	return func(g *generator) {
+		g.clientOptions = append(g.clientOptions, opts...)
+	}
+}
+
+// WithInstructions adds instructions to the prompt, such as a testing style
+// or the behavior to cover.
+func WithInstructions(instructions string) Option {
+	return func(g *generator) {
+		g.instructions = instructions
+	}
+}
+
+// WithFixAttempts sets how many times failing tests are sent back to the
+// agent. Zero reports the first failure without asking for a fix.
+func WithFixAttempts(n int) Option {
+	return func(g *generator) {
+		if n < 0 {
+			n = 0
+		}
+		g.fixAttempts = n
+	}
+}
+
+// WithVerifier replaces go test as the check run after each turn.
+func WithVerifier(verify VerifyFunc) Option {
+	return func(g *generator) {
+		g.verify = verify
+	}
+}
+
+// WithTransport runs the agent over transport instead of the CLI, for
+// testing.
+func WithTransport(transport claudecode.Transport) Option {
+	return func(g *generator) {
+		g.transport = transport
+	}
+}
+
+type generator struct {
+	clientOptions []claudecode.Option
+	instructions  string
+	fixAttempts   int
+	verify        VerifyFunc
+	transport     claudecode.Transport
+}
+
+// GenerateTests has the agent write tests for the Go package in the
+// directory pkgPath, and runs them. The agent may read any file but only
+// create or edit _test.go files directly in the package directory: the CLI
+// is given matching permission rules and the client denies other writes.
+//
+// A failed verification is not an error: the report says whether the tests
+// passed after the last attempt. Errors are returned when the session or
+// the verifier fails, along with the report so far.
+func GenerateTests(ctx context.Context, pkgPath string, opts ...Option) (*Report, error) {
+	g := &generator{fixAttempts: DefaultFixAttempts, verify: GoTest}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	dir, err := filepath.Abs(pkgPath)
+	if err != nil {
+		return nil, fmt.Errorf("resolving package path: %w", err)
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("package directory: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("package path %s is not a directory", pkgPath)
+	}
+	before, err := testFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Dir: dir}
+	clientOpts := append(append([]claudecode.Option(nil), g.clientOptions...),
+		claudecode.WithCwd(dir),
+		claudecode.WithAllowedTools("Read", "Glob", "Grep", "Write(*"+testFileSuffix+")", "Edit(*"+testFileSuffix+")"),
+		claudecode.WithDisallowedTools("Bash", "NotebookEdit", "WebFetch", "WebSearch"),
+	)
+	run := func(fn func(claudecode.Client) error) error {
+		if g.transport != nil {
+			return claudecode.WithClientTransport(ctx, g.transport, fn, clientOpts...)
+		}
+		return claudecode.WithClient(ctx, fn, clientOpts...)
+	}
+
+	err = run(func(client claudecode.Client) error {
+		if impl, ok := client.(*claudecode.ClientImpl); ok {
+			impl.GetPermissionManager().SetPermissionCallback(testFilePolicy(dir))
+		}
+		prompt := generatePrompt(g.instructions)
+		for attempt := 0; ; attempt++ {
+			cost, err := runTurn(ctx, client, prompt)
+			report.CostUSD += cost
+			if err != nil {
+				return err
+			}
+
+			report.Passed, report.Output, err = g.verify(ctx, dir)
+			report.Verifications++
+			if err != nil {
+				return fmt.Errorf("verifying tests: %w", err)
+			}
+			if report.Passed || attempt >= g.fixAttempts {
+				return nil
+			}
+			prompt = fixPrompt(report.Output)
+		}
+	})
+
+	after, filesErr := testFiles(dir)
+	if filesErr == nil {
+		report.Created, report.Modified = changedFiles(before, after)
+	}
+	if err != nil {
+		return report, err
+	}
+	return report, filesErr
+}
+
+// runTurn sends prompt and waits for the turn's result, returning its cost.
+func runTurn(ctx context.Context, client claudecode.Client, prompt string) (float64, error) {
+	if err := client.Query(ctx, prompt); err != nil {
+		return 0, err
+	}
+	iter := client.ReceiveResponse(ctx)
+	if iter == nil {
+		return 0, fmt.Errorf("client not connected")
+	}
+	for {
+		msg, err := iter.Next(ctx)
+		if errors.Is(err, claudecode.ErrNoMoreMessages) {
+			return 0, claudecode.ErrNoResult
+		}
+		if err != nil {
+			return 0, err
+		}
+		result, ok := msg.(*claudecode.ResultMessage)
+		if !ok {
+			continue
+		}
+		cost := 0.0
+		if result.TotalCostUSD != nil {
+			cost = *result.TotalCostUSD
+		}
+		if result.IsError {
+			return cost, fmt.Errorf("%w: %s", claudecode.ErrFailedResult, result.Subtype)
+		}
+		return cost, nil
+	}
+}
+
+// generatePrompt asks for tests of the package in the working directory.
+func generatePrompt(instructions string) string {
+	prompt := "Write Go tests for the package in the current directory. " +
+		"Read its source first, then create or extend _test.go files in this directory. " +
+		"Do not change any other file. Cover the exported API and its error cases; " +
+		"the tests are run with go test when you finish."
+	if instructions != "" {
+		prompt += "\n\n" + instructions
+	}
+	return prompt
+}
+
+// fixPrompt sends a failed verification back to the agent.
+func fixPrompt(output string) string {
+	if len(output) > maxFailureOutput {
+		output = "...\n" + output[len(output)-maxFailureOutput:]
+	}
+	return "The tests failed:\n\n```\n" + strings.TrimSpace(output) + "\n```\n\n" +
+		"Fix the tests. Only _test.go files may be changed; if a failure shows a bug in the package, " +
+		"skip that test with t.Skip and explain the bug in the skip message."
+}
+
+// testFilePolicy denies writes to anything but _test.go files directly in
+// dir, and tools outside the read-only set.
+func testFilePolicy(dir string) claudecode.CanUseToolFunc {
+	return func(_ context.Context, toolName string, input map[string]any, _ claudecode.ToolPermissionContext) (claudecode.PermissionResult, error) {
+		switch toolName {
+		case "Read", "Glob", "Grep", "LS":
+			return claudecode.NewPermissionResultAllow(), nil
+		case "Write", "Edit", "MultiEdit":
+			path, _ := input["file_path"].(string)
+			if writableTestFile(dir, path) {
+				return claudecode.NewPermissionResultAllow(), nil
+			}
+			return claudecode.NewPermissionResultDeny(fmt.Sprintf("only %s files in %s may be written", testFileSuffix, dir)), nil
+		}
+		return claudecode.NewPermissionResultDeny(fmt.Sprintf("%s is not available while generating tests", toolName)), nil
+	}
+}
+
+// writableTestFile reports whether path, relative to dir unless absolute,
+// is a _test.go file directly in dir.
+func writableTestFile(dir, path string) bool {
+	if path == "" || !strings.HasSuffix(path, testFileSuffix) {
+		return false
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return filepath.Dir(filepath.Clean(path)) == filepath.Clean(dir)
+}
+
+// testFiles returns the checksums of the _test.go files in dir by name.
+func testFiles(dir string) (map[string][sha256.Size]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing package directory: %w", err)
+	}
+	files := make(map[string][sha256.Size]byte)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), testFileSuffix) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading test file: %w", err)
+		}
+		files[entry.Name()] = sha256.Sum256(data)
+	}
+	return files, nil
+}
+
+// changedFiles compares test files before and after generation.
+func changedFiles(before, after map[string][sha256.Size]byte) (created, modified []string) {
+	for name, sum := range after {
+		previous, existed := before[name]
+		switch {
+		case !existed:
+			created = append(created, name)
+		case previous != sum:
+			modified = append(modified, name)
+		}
+	}
+	sort.Strings(created)
+	sort.Strings(modified)
+	return created, modified
+}
+
+// GoTest is the default verifier: it runs go test in dir. Failing tests and
+// build errors fail verification; an error is returned only when go cannot
+// be run.
+func GoTest(ctx context.Context, dir string) (bool, string, error) {
+	cmd := exec.CommandContext(ctx, "go", "test", ".")
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return true, string(output), nil
+	case errors.As(err, &exitErr) && ctx.Err() == nil:
+		return false, string(output), nil
+	default:
+		return false, string(output), fmt.Errorf("running go test: %w", err)
+	}
+}
@@ -0,0 +1,226 @@
+package codegen
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestGenerateTests(t *testing.T) {
+	ctx, cancel := setupCodegenTestContext(t)
+	defer cancel()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "parse.go", "package parse\n")
+	writeFile(t, dir, "old_test.go", "package parse\n")
+	writeFile(t, dir, "kept_test.go", "package parse\n")
+
+	transport := newTurnTransport(
+		func() { writeFile(t, dir, "parse_test.go", "package parse\n// first\n") },
+		func() { writeFile(t, dir, "old_test.go", "package parse\n// fixed\n") },
+	)
+	verifier := &scriptedVerifier{results: []bool{false, true}}
+	report, err := GenerateTests(ctx, dir,
+		WithTransport(transport),
+		WithVerifier(verifier.verify),
+		WithInstructions("Use table-driven tests"))
+	if err != nil {
+		t.Fatalf("GenerateTests failed: %v", err)
+	}
+
+	if !report.Passed || report.Verifications != 2 || report.Output != "run 2" {
+		t.Errorf("Expected a passing second verification, got %+v", report)
+	}
+	if !reflect.DeepEqual(report.Created, []string{"parse_test.go"}) || !reflect.DeepEqual(report.Modified, []string{"old_test.go"}) {
+		t.Errorf("Expected parse_test.go created and old_test.go modified, got %v and %v", report.Created, report.Modified)
+	}
+	if report.CostUSD < 0.0199 || report.CostUSD > 0.0201 {
+		t.Errorf("Expected the cost of both turns, got %v", report.CostUSD)
+	}
+
+	prompts := transport.prompts()
+	if len(prompts) != 2 {
+		t.Fatalf("Expected a prompt and a fix prompt, got %d", len(prompts))
+	}
+	if !strings.Contains(prompts[0], "Use table-driven tests") {
+		t.Errorf("Expected the instructions in the prompt, got %q", prompts[0])
+	}
+	if !strings.Contains(prompts[1], "run 1") {
+		t.Errorf("Expected the failure output in the fix prompt, got %q", prompts[1])
+	}
+}
+
+func TestGenerateTestsFailures(t *testing.T) {
+	ctx, cancel := setupCodegenTestContext(t)
+	defer cancel()
+	dir := t.TempDir()
+
+	verifier := &scriptedVerifier{results: []bool{false}}
+	report, err := GenerateTests(ctx, dir, WithTransport(newTurnTransport(nil)), WithVerifier(verifier.verify), WithFixAttempts(0))
+	if err != nil || report.Passed || report.Verifications != 1 {
+		t.Errorf("Expected a failed report without fixes, got %+v, %v", report, err)
+	}
+
+	verifyErr := errors.New("go not found")
+	failing := func(context.Context, string) (bool, string, error) { return false, "", verifyErr }
+	report, err = GenerateTests(ctx, dir, WithTransport(newTurnTransport(nil)), WithVerifier(failing))
+	if !errors.Is(err, verifyErr) || report == nil {
+		t.Errorf("Expected the verifier error with a report, got %+v, %v", report, err)
+	}
+
+	transport := newTurnTransport(nil)
+	transport.isError = true
+	if _, err := GenerateTests(ctx, dir, WithTransport(transport), WithVerifier(verifier.verify)); !errors.Is(err, claudecode.ErrFailedResult) {
+		t.Errorf("Expected ErrFailedResult, got %v", err)
+	}
+
+	if _, err := GenerateTests(ctx, filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
+
+func TestTestFilePolicy(t *testing.T) {
+	dir := t.TempDir()
+	policy := testFilePolicy(dir)
+	tests := []struct {
+		tool  string
+		path  string
+		allow bool
+	}{
+		{"Read", filepath.Join(dir, "parse.go"), true},
+		{"Write", filepath.Join(dir, "parse_test.go"), true},
+		{"Edit", "parse_test.go", true},
+		{"Write", filepath.Join(dir, "parse.go"), false},
+		{"Write", filepath.Join(dir, "sub", "parse_test.go"), false},
+		{"Edit", "../other_test.go", false},
+		{"Bash", "", false},
+	}
+	for _, test := range tests {
+		result, err := policy(context.Background(), test.tool, map[string]any{"file_path": test.path}, claudecode.ToolPermissionContext{})
+		if err != nil {
+			t.Fatalf("policy failed: %v", err)
+		}
+		_, allowed := result.(*claudecode.PermissionResultAllow)
+		if allowed != test.allow {
+			t.Errorf("%s %s: expected allowed=%v", test.tool, test.path, test.allow)
+		}
+	}
+}
+
+func TestGoTest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	ctx, cancel := setupCodegenTestContext(t)
+	defer cancel()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "go.mod", "module example.com/sum\n\ngo 1.18\n")
+	writeFile(t, dir, "sum.go", "package sum\n\nfunc Sum(a, b int) int { return a + b }\n")
+	writeFile(t, dir, "sum_test.go", "package sum\n\nimport \"testing\"\n\nfunc TestSum(t *testing.T) {\n\tif Sum(1, 2) != 3 {\n\t\tt.Fatal(\"wrong sum\")\n\t}\n}\n")
+	if passed, output, err := GoTest(ctx, dir); err != nil || !passed {
+		t.Fatalf("Expected passing tests, got %v, %v: %s", passed, err, output)
+	}
+
+	writeFile(t, dir, "sum_test.go", "package sum\n\nimport \"testing\"\n\nfunc TestSum(t *testing.T) {\n\tt.Fatal(\"wrong sum\")\n}\n")
+	passed, output, err := GoTest(ctx, dir)
+	if err != nil || passed || !strings.Contains(output, "wrong sum") {
+		t.Errorf("Expected a failure reporting the test, got %v, %v: %s", passed, err, output)
+	}
+}
+
+// turnTransport answers each prompt by running the next action and sending
+// a result, as the CLI would after the agent's tool calls.
+type turnTransport struct {
+	mu      sync.Mutex
+	actions []func()
+	sent    []string
+	isError bool
+	msgChan chan claudecode.Message
+	errChan chan error
+}
+
+func newTurnTransport(actions ...func()) *turnTransport {
+	return &turnTransport{
+		actions: actions,
+		msgChan: make(chan claudecode.Message, 10),
+		errChan: make(chan error, 1),
+	}
+}
+
+func (tt *turnTransport) Connect(context.Context) error { return nil }
+
+func (tt *turnTransport) SendMessage(_ context.Context, message claudecode.StreamMessage) error {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	if msg, ok := message.Message.(map[string]interface{}); ok {
+		prompt, _ := msg["content"].(string)
+		tt.sent = append(tt.sent, prompt)
+	}
+	if len(tt.actions) > 0 {
+		if action := tt.actions[0]; action != nil {
+			action()
+		}
+		tt.actions = tt.actions[1:]
+	}
+	cost := 0.01
+	tt.msgChan <- &claudecode.ResultMessage{Subtype: "success", IsError: tt.isError, TotalCostUSD: &cost}
+	return nil
+}
+
+func (tt *turnTransport) ReceiveMessages(context.Context) (<-chan claudecode.Message, <-chan error) {
+	return tt.msgChan, tt.errChan
+}
+
+func (tt *turnTransport) Interrupt(context.Context) error { return nil }
+
+func (tt *turnTransport) Close() error { return nil }
+
+func (tt *turnTransport) GetValidator() *claudecode.StreamValidator { return nil }
+
+func (tt *turnTransport) prompts() []string {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	return append([]string(nil), tt.sent...)
+}
+
+// scriptedVerifier passes or fails in the order of results, repeating the
+// last, with "run N" as output.
+type scriptedVerifier struct {
+	results []bool
+	runs    int
+}
+
+func (v *scriptedVerifier) verify(context.Context, string) (bool, string, error) {
+	v.runs++
+	i := v.runs - 1
+	if i >= len(v.results) {
+		i = len(v.results) - 1
+	}
+	return v.results[i], "run " + strconv.Itoa(v.runs), nil
+}
+
+func setupCodegenTestContext(t *testing.T) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
@@ -0,0 +1,312 @@
+// Package compress shrinks long prompts before they are sent, to cut the
+// cost of log-heavy workflows.
+//
+// A Compressor strips ANSI escape codes and trailing whitespace, elides
+// binary data and long base64 runs, and replaces content repeated within a
+// prompt, such as a file pasted twice, with a short note. Prompts shorter
+// than MinBytes are sent unchanged:
+//
+//	compressor := &compress.Compressor{}
+//	client := claudecode.NewClient(compress.WithCompression(compressor))
+//	// ...
+//	stats := compressor.Stats()
+//	log.Printf("saved %d bytes, about %d tokens", stats.BytesSaved(), stats.TokensSaved())
+//
+// The unit of repetition is a fenced code block, a <context> element as
+// sent by ContextBuilder, or a paragraph; repeats shorter than
+// MinDuplicateBytes are kept.
+package compress
+
+import (
+	"crypto/sha256"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Defaults for Compressor fields that are not set.
+const (
+	// DefaultMinBytes is the prompt size below which prompts are not
+	// compressed.
+	DefaultMinBytes = 4 << 10
+	// DefaultMinDuplicateBytes is the smallest repeated content that is
+	// elided.
+	DefaultMinDuplicateBytes = 256
+	// DefaultMinBlobBytes is the shortest base64 run that is elided.
+	DefaultMinBlobBytes = 512
+)
+
+// BytesPerToken is the rough number of prompt bytes per token used to
+// estimate the tokens saved.
+const BytesPerToken = 4
+
+// Compressor compresses prompts and keeps statistics on the savings. The
+// zero value uses the defaults. It is safe for concurrent use.
+type Compressor struct {
+	// MinBytes is the prompt size below which prompts are sent unchanged;
+	// the default is DefaultMinBytes.
+	MinBytes int
+	// MinDuplicateBytes is the smallest repeated content that is elided;
+	// the default is DefaultMinDuplicateBytes.
+	MinDuplicateBytes int
+	// MinBlobBytes is the shortest base64 run that is elided; the default
+	// is DefaultMinBlobBytes. Binary data is elided regardless of length.
+	MinBlobBytes int
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// Stats reports what a Compressor has done.
+type Stats struct {
+	// Prompts is the number of prompts compressed; prompts below the
+	// threshold are not counted.
+	Prompts int
+	// BytesIn and BytesOut are the sizes of those prompts before and after
+	// compression.
+	BytesIn  int
+	BytesOut int
+	// ANSICodes, Duplicates and Blobs count the escape codes removed and
+	// the repeats and blobs elided.
+	ANSICodes  int
+	Duplicates int
+	Blobs      int
+}
+
+// BytesSaved returns the number of bytes compression removed.
+func (s Stats) BytesSaved() int {
+	return s.BytesIn - s.BytesOut
+}
+
+// TokensSaved estimates the number of tokens compression removed.
+func (s Stats) TokensSaved() int {
+	return s.BytesSaved() / BytesPerToken
+}
+
+// Stats returns the statistics accumulated so far.
+func (c *Compressor) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Compress returns text compressed, or unchanged when it is shorter than
+// MinBytes.
+func (c *Compressor) Compress(text string) string {
+	out := c.CompressAll([]string{text})
+	return out[0]
+}
+
+// CompressAll compresses texts that are sent together, such as the text
+// blocks of one prompt, so content repeated across them is elided. They
+// are returned unchanged when their total size is below MinBytes.
+func (c *Compressor) CompressAll(texts []string) []string {
+	total := 0
+	for _, text := range texts {
+		total += len(text)
+	}
+	out := make([]string, len(texts))
+	copy(out, texts)
+	if total < orDefault(c.MinBytes, DefaultMinBytes) {
+		return out
+	}
+
+	run := &pass{c: c, seen: make(map[[sha256.Size]byte]bool)}
+	size := 0
+	for i, text := range out {
+		text = run.stripANSI(text)
+		text = trimTrailingSpace(text)
+		text = run.elideBlobs(text)
+		text = run.dedupe(text)
+		out[i] = text
+		size += len(text)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Prompts++
+	c.stats.BytesIn += total
+	c.stats.BytesOut += size
+	c.stats.ANSICodes += run.ansi
+	c.stats.Duplicates += run.duplicates
+	c.stats.Blobs += run.blobs
+	return out
+}
+
+// pass holds the state of compressing one prompt.
+type pass struct {
+	c          *Compressor
+	seen       map[[sha256.Size]byte]bool
+	ansi       int
+	duplicates int
+	blobs      int
+}
+
+// ansiPattern matches CSI sequences, such as colors and cursor movement,
+// OSC sequences, such as hyperlinks and titles, and other two-byte escapes.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+func (p *pass) stripANSI(text string) string {
+	if !strings.Contains(text, "\x1b") {
+		return text
+	}
+	return ansiPattern.ReplaceAllStringFunc(text, func(string) string {
+		p.ansi++
+		return ""
+	})
+}
+
+// trimTrailingSpace removes whitespace, including carriage returns, from
+// the end of each line.
+func trimTrailingSpace(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// base64Pattern returns a pattern matching base64 or hex runs of at least
+// size characters, alone or as the payload of a data URI.
+func base64Pattern(size int) *regexp.Regexp {
+	return regexp.MustCompile(`[A-Za-z0-9+/_-]{` + strconv.Itoa(size) + `,}={0,2}`)
+}
+
+// elideBlobs replaces long base64 runs and lines of binary data with a
+// note of their size. Consecutive binary lines become one note.
+func (p *pass) elideBlobs(text string) string {
+	text = base64Pattern(orDefault(p.c.MinBlobBytes, DefaultMinBlobBytes)).ReplaceAllStringFunc(text, func(blob string) string {
+		p.blobs++
+		return elided("base64 data", len(blob))
+	})
+
+	lines := strings.Split(text, "\n")
+	out := lines[:0]
+	binary := 0
+	for _, line := range lines {
+		if isBinary(line) {
+			binary += len(line) + 1
+			continue
+		}
+		if binary > 0 {
+			p.blobs++
+			out = append(out, elided("binary data", binary-1))
+			binary = 0
+		}
+		out = append(out, line)
+	}
+	if binary > 0 {
+		p.blobs++
+		out = append(out, elided("binary data", binary-1))
+	}
+	return strings.Join(out, "\n")
+}
+
+// isBinary reports whether line looks like binary data rather than text:
+// more than a tenth of its characters are invalid UTF-8 or control
+// characters other than tabs.
+func isBinary(line string) bool {
+	bad, total := 0, 0
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if (r == utf8.RuneError && size == 1) || (unicode.IsControl(r) && r != '\t') {
+			bad++
+		}
+		total++
+		i += size
+	}
+	return bad > 0 && bad*10 > total
+}
+
+// dedupe replaces the body of each unit of text that already appeared in
+// the prompt with a note.
+func (p *pass) dedupe(text string) string {
+	minSize := orDefault(p.c.MinDuplicateBytes, DefaultMinDuplicateBytes)
+	var lines []string
+	for _, u := range splitUnits(text) {
+		lines = append(lines, u.open...)
+		body := strings.Join(u.body, "\n")
+		if len(body) >= minSize {
+			sum := sha256.Sum256([]byte(body))
+			if p.seen[sum] {
+				p.duplicates++
+				lines = append(lines, elided("duplicate of earlier content", len(body)))
+				lines = append(lines, u.close...)
+				continue
+			}
+			p.seen[sum] = true
+		}
+		lines = append(lines, u.body...)
+		lines = append(lines, u.close...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// unit is a piece of text compared as a whole: a fenced code block or
+// <context> element, whose delimiting lines are kept, or a run of other
+// lines up to a blank line.
+type unit struct {
+	open  []string
+	body  []string
+	close []string
+}
+
+func splitUnits(text string) []unit {
+	lines := strings.Split(text, "\n")
+	var units []unit
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		if end := closingLine(lines, i); end > i {
+			units = append(units, unit{open: lines[i : i+1], body: lines[i+1 : end], close: lines[end : end+1]})
+			i = end + 1
+			continue
+		}
+		if line == "" {
+			units = append(units, unit{body: lines[i : i+1]})
+			i++
+			continue
+		}
+		start := i
+		for i < len(lines) && lines[i] != "" && (i == start || closingLine(lines, i) < 0) {
+			i++
+		}
+		units = append(units, unit{body: lines[start:i]})
+	}
+	return units
+}
+
+// closingLine returns the index of the line closing the fence or <context>
+// element opened at lines[i], or -1 when lines[i] opens none or it is not
+// closed.
+func closingLine(lines []string, i int) int {
+	line := lines[i]
+	var closing func(string) bool
+	switch {
+	case strings.HasPrefix(line, "```"):
+		closing = func(l string) bool { return l == "```" }
+	case strings.HasPrefix(line, "<context") && strings.HasSuffix(line, ">"):
+		closing = func(l string) bool { return l == "</context>" }
+	default:
+		return -1
+	}
+	for j := i + 1; j < len(lines); j++ {
+		if closing(lines[j]) {
+			return j
+		}
+	}
+	return -1
+}
+
+func elided(what string, size int) string {
+	return "[" + what + " elided: " + strconv.Itoa(size) + " bytes]"
+}
+
+func orDefault(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
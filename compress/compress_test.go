@@ -0,0 +1,97 @@
+package compress
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompressBelowThreshold(t *testing.T) {
+	c := &Compressor{}
+	text := "\x1b[31mred\x1b[0m   \n"
+	if got := c.Compress(text); got != text {
+		t.Errorf("Expected a short prompt unchanged, got %q", got)
+	}
+	if stats := c.Stats(); stats.Prompts != 0 {
+		t.Errorf("Expected no compressed prompts, got %+v", stats)
+	}
+}
+
+func TestCompressStripsANSIAndTrailingSpace(t *testing.T) {
+	c := &Compressor{MinBytes: 1}
+	text := "\x1b[1;32mPASS\x1b[0m  \r\n\x1b]0;title\x07ok\t\nplain"
+	if got := c.Compress(text); got != "PASS\nok\nplain" {
+		t.Errorf("Unexpected result: %q", got)
+	}
+	stats := c.Stats()
+	if stats.ANSICodes != 3 || stats.Prompts != 1 || stats.BytesSaved() != len(text)-len("PASS\nok\nplain") {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestCompressElidesBlobs(t *testing.T) {
+	c := &Compressor{MinBytes: 1, MinBlobBytes: 16}
+	blob := strings.Repeat("QUJD", 8)
+	text := "image: data:image/png;base64," + blob + "==\nbefore\n\x00\x01\x02\x03bin\n\xff\xfe\xfd\nafter\nhash 0123456789abcd"
+
+	want := "image: data:image/png;base64,[base64 data elided: 34 bytes]\nbefore\n[binary data elided: 11 bytes]\nafter\nhash 0123456789abcd"
+	if got := c.Compress(text); got != want {
+		t.Errorf("Unexpected result:\n%q\nwant\n%q", got, want)
+	}
+	if stats := c.Stats(); stats.Blobs != 2 {
+		t.Errorf("Expected two blobs elided, got %+v", stats)
+	}
+}
+
+func TestCompressDedupes(t *testing.T) {
+	c := &Compressor{MinBytes: 1, MinDuplicateBytes: 20}
+	file := "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}"
+	text := strings.Join([]string{
+		"<context label=\"main.go\">", file, "</context>",
+		"",
+		"```go", file, "```",
+		"",
+		"short",
+		"",
+		"short",
+		"",
+		"```",
+		"```",
+	}, "\n")
+
+	want := strings.Join([]string{
+		"<context label=\"main.go\">", file, "</context>",
+		"",
+		"```go", "[duplicate of earlier content elided: 47 bytes]", "```",
+		"",
+		"short",
+		"",
+		"short",
+		"",
+		"```",
+		"```",
+	}, "\n")
+	if got := c.Compress(text); got != want {
+		t.Errorf("Unexpected result:\n%s\nwant\n%s", got, want)
+	}
+	if stats := c.Stats(); stats.Duplicates != 1 {
+		t.Errorf("Expected one duplicate, got %+v", stats)
+	}
+}
+
+func TestCompressAllDedupesAcrossTexts(t *testing.T) {
+	c := &Compressor{MinBytes: 1, MinDuplicateBytes: 10}
+	paragraph := "line one of the log\nline two of the log"
+	got := c.CompressAll([]string{paragraph, "note\n\n" + paragraph})
+	if got[0] != paragraph || got[1] != "note\n\n[duplicate of earlier content elided: 39 bytes]" {
+		t.Errorf("Unexpected result: %q", got)
+	}
+
+	// Each call starts afresh
+	if got := c.Compress(paragraph); got != paragraph {
+		t.Errorf("Expected no duplicate across prompts, got %q", got)
+	}
+	stats := c.Stats()
+	if stats.Prompts != 2 || stats.TokensSaved() != stats.BytesSaved()/BytesPerToken {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
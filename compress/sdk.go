@@ -0,0 +1,40 @@
+package compress
+
+import (
+	"context"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// WithCompression compresses every prompt the client or query sends,
+// including context added with ContextBuilder. Interceptors added before
+// it see the original prompt; those added after see the compressed one.
+func WithCompression(c *Compressor) claudecode.Option {
+	return func(o *claudecode.Options) {
+		o.PromptInterceptors = append(o.PromptInterceptors, c.PromptInterceptor())
+	}
+}
+
+// PromptInterceptor returns an interceptor that compresses prompts, in text
+// and in text blocks. Other blocks, such as images, are left alone.
+func (c *Compressor) PromptInterceptor() claudecode.PromptInterceptor {
+	return func(_ context.Context, msg *claudecode.UserMessage) error {
+		switch content := msg.Content.(type) {
+		case string:
+			msg.Content = c.Compress(content)
+		case []claudecode.ContentBlock:
+			var blocks []*claudecode.TextBlock
+			var texts []string
+			for _, block := range content {
+				if text, ok := block.(*claudecode.TextBlock); ok {
+					blocks = append(blocks, text)
+					texts = append(texts, text.Text)
+				}
+			}
+			for i, text := range c.CompressAll(texts) {
+				blocks[i].Text = text
+			}
+		}
+		return nil
+	}
+}
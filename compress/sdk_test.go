@@ -0,0 +1,44 @@
+package compress
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestWithCompression(t *testing.T) {
+	c := &Compressor{MinBytes: 1}
+	options := claudecode.NewOptions(WithCompression(c))
+	if len(options.PromptInterceptors) != 1 {
+		t.Fatalf("Expected a prompt interceptor, got %d", len(options.PromptInterceptors))
+	}
+
+	msg := &claudecode.UserMessage{Content: "\x1b[33mwarn\x1b[0m  "}
+	if err := options.PromptInterceptors[0](context.Background(), msg); err != nil {
+		t.Fatalf("Interceptor failed: %v", err)
+	}
+	if msg.Content != "warn" {
+		t.Errorf("Expected the prompt compressed, got %q", msg.Content)
+	}
+}
+
+func TestPromptInterceptorBlocks(t *testing.T) {
+	c := &Compressor{MinBytes: 1, MinDuplicateBytes: 10}
+	log := strings.Repeat("error: connection refused\n", 3)
+	first := &claudecode.TextBlock{Text: log}
+	other := &claudecode.ToolResultBlock{ToolUseID: "toolu_1", Content: log}
+	second := &claudecode.TextBlock{Text: log}
+	msg := &claudecode.UserMessage{Content: []claudecode.ContentBlock{first, other, second}}
+
+	if err := c.PromptInterceptor()(context.Background(), msg); err != nil {
+		t.Fatalf("Interceptor failed: %v", err)
+	}
+	if first.Text != log {
+		t.Errorf("Expected the first copy kept, got %q", first.Text)
+	}
+	if !strings.HasPrefix(second.Text, "[duplicate of earlier content elided") {
+		t.Errorf("Expected the second copy elided, got %q", second.Text)
+	}
+}
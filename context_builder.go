@@ -0,0 +1,192 @@
+package claudecode
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// defaultContextMaxBytes is the context size limit when WithContextLimit is
+// not used.
+const defaultContextMaxBytes = 256 << 10
+
+// ErrContextLimitExceeded indicates context was rejected because it would
+// exceed the limit set with WithContextLimit and the ContextReject policy.
+var ErrContextLimitExceeded = errors.New("context limit exceeded")
+
+// ContextBuilder collects files and text snippets to send as content blocks
+// ahead of a prompt. Sizes are the bytes of the added text, excluding the
+// label each item is wrapped in. It is safe for concurrent use.
+type ContextBuilder struct {
+	mu       sync.Mutex
+	maxBytes int
+	policy   ContextTruncationPolicy
+	items    []contextItem
+	size     int
+	cache    *CacheControl
+}
+
+type contextItem struct {
+	label string
+	text  string
+}
+
+// NewContextBuilder creates a ContextBuilder that keeps at most maxBytes of
+// context, applying policy when more is added. A non-positive maxBytes uses
+// the default limit and an empty policy truncates.
+func NewContextBuilder(maxBytes int, policy ContextTruncationPolicy) *ContextBuilder {
+	if maxBytes <= 0 {
+		maxBytes = defaultContextMaxBytes
+	}
+	if policy == "" {
+		policy = ContextTruncate
+	}
+	return &ContextBuilder{maxBytes: maxBytes, policy: policy}
+}
+
+// newContextBuilderFromOptions creates the builder configured by options.
+func newContextBuilderFromOptions(options *Options) *ContextBuilder {
+	if options == nil {
+		return NewContextBuilder(0, "")
+	}
+	maxBytes := 0
+	if options.ContextMaxBytes != nil {
+		maxBytes = *options.ContextMaxBytes
+	}
+	b := NewContextBuilder(maxBytes, options.ContextTruncation)
+	b.cache = options.ContextCache
+	return b
+}
+
+// SetCacheControl marks the last context block Build returns with cc, so
+// the context is cached for prompts that repeat it. A nil cc turns caching
+// off.
+func (b *ContextBuilder) SetCacheControl(cc *CacheControl) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cache = cc
+}
+
+// AddFile reads the file at path and adds its contents, labeled with the
+// path. The file is read immediately; binary files are rejected.
+func (b *ContextBuilder) AddFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read context file: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return fmt.Errorf("context file %s is not valid UTF-8 text", path)
+	}
+	return b.AddText(path, string(data))
+}
+
+// AddText adds a text snippet under label. Empty text is ignored.
+func (b *ContextBuilder) AddText(label, text string) error {
+	if text == "" {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.policy == ContextReject && b.size+len(text) > b.maxBytes {
+		return fmt.Errorf("%w: adding %q (%d bytes) to %d bytes exceeds %d bytes",
+			ErrContextLimitExceeded, label, len(text), b.size, b.maxBytes)
+	}
+	b.items = append(b.items, contextItem{label: label, text: text})
+	b.size += len(text)
+	return nil
+}
+
+// Size returns the number of bytes of context added so far, before any
+// truncation.
+func (b *ContextBuilder) Size() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// Reset discards all added context.
+func (b *ContextBuilder) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items = nil
+	b.size = 0
+}
+
+// Build returns the added context as text blocks, limited by the builder's
+// truncation policy, followed by the prompt. The context is kept; use Reset
+// to discard it.
+func (b *ContextBuilder) Build(prompt string) []ContentBlock {
+	blocks, _ := b.build(prompt)
+	return blocks
+}
+
+// build is Build that also returns how many items it consumed, so they can
+// be removed once the blocks were sent.
+func (b *ContextBuilder) build(prompt string) ([]ContentBlock, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	blocks := make([]ContentBlock, 0, len(b.items)+1)
+	remaining := b.maxBytes
+	for _, item := range b.items {
+		text := item.text
+		switch {
+		case len(text) <= remaining:
+			remaining -= len(text)
+		case b.policy == ContextDrop || remaining == 0:
+			continue
+		default:
+			text = truncateContext(text, remaining)
+			remaining = 0
+		}
+		blocks = append(blocks, &TextBlock{Text: formatContextItem(item.label, text)})
+	}
+	if b.cache != nil && len(blocks) > 0 {
+		cc := *b.cache
+		blocks[len(blocks)-1].(*TextBlock).CacheControl = &cc
+	}
+	if prompt != "" {
+		blocks = append(blocks, &TextBlock{Text: prompt})
+	}
+	return blocks, len(b.items)
+}
+
+// consume removes the first n items, leaving items added since build.
+func (b *ContextBuilder) consume(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, item := range b.items[:n] {
+		b.size -= len(item.text)
+	}
+	b.items = append([]contextItem(nil), b.items[n:]...)
+}
+
+// truncateContext cuts text to at most limit bytes on a rune boundary and
+// notes how much was left out.
+func truncateContext(text string, limit int) string {
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n[truncated %d bytes]", text[:cut], len(text)-cut)
+}
+
+// formatContextItem wraps text in a labeled context element.
+func formatContextItem(label, text string) string {
+	var sb strings.Builder
+	sb.WriteString("<context")
+	if label != "" {
+		fmt.Fprintf(&sb, " label=%q", label)
+	}
+	sb.WriteString(">\n")
+	sb.WriteString(text)
+	if !strings.HasSuffix(text, "\n") {
+		sb.WriteString("\n")
+	}
+	sb.WriteString("</context>")
+	return sb.String()
+}
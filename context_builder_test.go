@@ -0,0 +1,240 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContextBuilderTruncationPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ContextTruncationPolicy
+		want   []string
+	}{
+		{
+			name:   "truncate cuts the crossing item and drops the rest",
+			policy: ContextTruncate,
+			want: []string{
+				"<context label=\"a\">\naaaa\n</context>",
+				"<context label=\"b\">\nbb\n[truncated 4 bytes]\n</context>",
+				"prompt",
+			},
+		},
+		{
+			name:   "drop skips items that do not fit",
+			policy: ContextDrop,
+			want: []string{
+				"<context label=\"a\">\naaaa\n</context>",
+				"<context label=\"c\">\nc\n</context>",
+				"prompt",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := NewContextBuilder(6, test.policy)
+			addContextTextForTest(t, builder, "a", "aaaa")
+			addContextTextForTest(t, builder, "b", "bbbbbb")
+			addContextTextForTest(t, builder, "c", "c")
+
+			if builder.Size() != 11 {
+				t.Errorf("Expected size 11 before truncation, got %d", builder.Size())
+			}
+			assertContextBlocks(t, builder.Build("prompt"), test.want)
+		})
+	}
+}
+
+func TestContextBuilderReject(t *testing.T) {
+	builder := NewContextBuilder(6, ContextReject)
+	addContextTextForTest(t, builder, "a", "aaaa")
+
+	err := builder.AddText("b", "bbb")
+	if !errors.Is(err, ErrContextLimitExceeded) {
+		t.Fatalf("Expected ErrContextLimitExceeded, got %v", err)
+	}
+	if builder.Size() != 4 {
+		t.Errorf("Expected rejected text to be left out, got size %d", builder.Size())
+	}
+	addContextTextForTest(t, builder, "c", "cc")
+}
+
+func TestContextBuilderTruncatesOnRuneBoundary(t *testing.T) {
+	builder := NewContextBuilder(2, ContextTruncate)
+	addContextTextForTest(t, builder, "", "héllo")
+
+	assertContextBlocks(t, builder.Build(""), []string{"<context>\nh\n[truncated 5 bytes]\n</context>"})
+}
+
+func TestContextBuilderAddFile(t *testing.T) {
+	dir := t.TempDir()
+	textPath := filepath.Join(dir, "notes.md")
+	binaryPath := filepath.Join(dir, "image.bin")
+	writeTestFile(t, textPath, "# Notes\n")
+	writeTestFile(t, binaryPath, "\xff\xfe\x00")
+
+	builder := NewContextBuilder(0, "")
+	if err := builder.AddFile(textPath); err != nil {
+		t.Fatalf("AddFile failed: %v", err)
+	}
+	if err := builder.AddFile(binaryPath); err == nil || !strings.Contains(err.Error(), "not valid UTF-8") {
+		t.Errorf("Expected binary file to be rejected, got %v", err)
+	}
+	if err := builder.AddFile(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("Expected missing file to fail")
+	}
+
+	assertContextBlocks(t, builder.Build(""), []string{"<context label=\"" + textPath + "\">\n# Notes\n</context>"})
+}
+
+func TestContextBuilderConsumeKeepsNewItems(t *testing.T) {
+	builder := NewContextBuilder(0, "")
+	addContextTextForTest(t, builder, "sent", "first")
+
+	_, consumed := builder.build("prompt")
+	addContextTextForTest(t, builder, "late", "second")
+	builder.consume(consumed)
+
+	if builder.Size() != len("second") {
+		t.Errorf("Expected only the late item to remain, got size %d", builder.Size())
+	}
+	assertContextBlocks(t, builder.Build(""), []string{"<context label=\"late\">\nsecond\n</context>"})
+}
+
+func TestClientQuerySendsPendingContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	transport := newClientMockTransportWithOptions(WithClientAutoResult())
+	client := NewClientWithTransport(transport)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	if err := client.AddContextText("stack trace", "panic: boom"); err != nil {
+		t.Fatalf("AddContextText failed: %v", err)
+	}
+	if client.ContextSize() != len("panic: boom") {
+		t.Errorf("Unexpected context size %d", client.ContextSize())
+	}
+
+	if err := client.Query(ctx, "What failed?"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	sent, _ := transport.getSentMessage(0)
+	blocks, ok := sent.Message.(map[string]interface{})["content"].([]map[string]any)
+	if !ok || len(blocks) != 2 {
+		t.Fatalf("Expected context and prompt blocks, got %#v", sent.Message)
+	}
+	if blocks[0]["type"] != ContentBlockTypeText || !strings.Contains(blocks[0]["text"].(string), "panic: boom") {
+		t.Errorf("Unexpected context block: %v", blocks[0])
+	}
+	if blocks[1]["text"] != "What failed?" {
+		t.Errorf("Expected prompt last, got %v", blocks[1])
+	}
+	if client.ContextSize() != 0 {
+		t.Errorf("Expected context to be cleared after sending, got %d bytes", client.ContextSize())
+	}
+	awaitClientResult(ctx, t, client)
+
+	// Without pending context the prompt is sent as a plain string
+	if err := client.Query(ctx, "And now?"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	sent, _ = transport.getSentMessage(1)
+	if content := sent.Message.(map[string]interface{})["content"]; content != "And now?" {
+		t.Errorf("Expected plain string prompt, got %#v", content)
+	}
+}
+
+func TestClientQueryKeepsContextOnSendFailure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	transport := newClientMockTransportWithOptions(WithClientSendError(errors.New("pipe closed")))
+	client := NewClientWithTransport(transport)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	if err := client.AddContextText("diff", "+ added line"); err != nil {
+		t.Fatalf("AddContextText failed: %v", err)
+	}
+	if err := client.Query(ctx, "Review this"); err == nil {
+		t.Fatal("Expected send failure")
+	}
+	if client.ContextSize() == 0 {
+		t.Error("Expected context to be kept for the next attempt")
+	}
+}
+
+func addContextTextForTest(t *testing.T, builder *ContextBuilder, label, text string) {
+	t.Helper()
+	if err := builder.AddText(label, text); err != nil {
+		t.Fatalf("AddText(%q) failed: %v", label, err)
+	}
+}
+
+func assertContextBlocks(t *testing.T, blocks []ContentBlock, want []string) {
+	t.Helper()
+	if len(blocks) != len(want) {
+		t.Fatalf("Expected %d blocks, got %d: %#v", len(want), len(blocks), blocks)
+	}
+	for i, block := range blocks {
+		text, ok := block.(*TextBlock)
+		if !ok || text.Text != want[i] {
+			t.Errorf("Block %d: expected %q, got %#v", i, want[i], block)
+		}
+	}
+}
+
+func TestContextBuilderCacheControl(t *testing.T) {
+	builder := NewContextBuilder(0, "")
+	addContextTextForTest(t, builder, "a", "first")
+	addContextTextForTest(t, builder, "b", "second")
+	builder.SetCacheControl(&CacheControl{Type: CacheControlEphemeral, TTL: "1h"})
+
+	blocks := builder.Build("prompt")
+	if len(blocks) != 3 {
+		t.Fatalf("Expected two context blocks and the prompt, got %d", len(blocks))
+	}
+	for i, want := range []bool{false, true, false} {
+		if got := blocks[i].(*TextBlock).CacheControl != nil; got != want {
+			t.Errorf("Block %d: expected cache control %v, got %v", i, want, got)
+		}
+	}
+
+	builder.SetCacheControl(nil)
+	if blocks := builder.Build(""); blocks[1].(*TextBlock).CacheControl != nil {
+		t.Error("Expected no cache control once turned off")
+	}
+}
+
+func TestClientQuerySendsContextCacheControl(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	transport := newClientMockTransportWithOptions(WithClientAutoResult())
+	client := NewClientWithTransport(transport, WithContextCaching("5m"))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	if err := client.AddContextText("docs", "a long design document"); err != nil {
+		t.Fatalf("AddContextText failed: %v", err)
+	}
+	if err := client.Query(ctx, "Summarize"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	sent, _ := transport.getSentMessage(0)
+	blocks := sent.Message.(map[string]interface{})["content"].([]map[string]any)
+	cacheControl, ok := blocks[0]["cache_control"].(map[string]any)
+	if !ok || cacheControl["type"] != CacheControlEphemeral || cacheControl["ttl"] != "5m" {
+		t.Errorf("Expected the context block marked cacheable, got %v", blocks[0])
+	}
+	if _, ok := blocks[1]["cache_control"]; ok {
+		t.Errorf("Expected the prompt block unmarked, got %v", blocks[1])
+	}
+}
@@ -5,29 +5,29 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
 )
 
 // ControlRequestType represents the type of control request
-type ControlRequestType string
+type ControlRequestType = shared.ControlRequestType
 
 const (
-	ControlRequestTypeInitialize         ControlRequestType = "initialize"
+	ControlRequestTypeInitialize        ControlRequestType = "initialize"
 	ControlRequestTypeCanUseTool        ControlRequestType = "can_use_tool"
+	ControlRequestTypeHookCallback      ControlRequestType = "hook_callback"
 	ControlRequestTypeSetPermissionMode ControlRequestType = "set_permission_mode"
-	ControlRequestTypeSetModel           ControlRequestType = "set_model"
-	ControlRequestTypeInterrupt           ControlRequestType = "interrupt"
-	ControlRequestTypeRewindFiles         ControlRequestType = "rewind_files"
+	ControlRequestTypeSetModel          ControlRequestType = "set_model"
+	ControlRequestTypeInterrupt         ControlRequestType = "interrupt"
+	ControlRequestTypeRewindFiles       ControlRequestType = "rewind_files"
+	ControlRequestTypeSupportedModels   ControlRequestType = "supported_models"
 )
 
 // ControlRequest represents a control protocol request
-type ControlRequest struct {
-	ID     string                    `json:"id"`
-	Subtype ControlRequestType        `json:"subtype"`
-	Data    map[string]any            `json:"data,omitempty"`
-}
+type ControlRequest = shared.ControlRequest
 
 // ControlResponseType represents the type of control response
-type ControlResponseType string
+type ControlResponseType = shared.ControlResponseType
 
 const (
 	ControlResponseTypeSuccess ControlResponseType = "success"
@@ -35,18 +35,10 @@ const (
 )
 
 // ControlResponse represents a control protocol response
-type ControlResponse struct {
-	ID     string                    `json:"id"`
-	Subtype ControlResponseType       `json:"subtype"`
-	Data    map[string]any            `json:"data,omitempty"`
-	Error   *ControlResponseError     `json:"error,omitempty"`
-}
+type ControlResponse = shared.ControlResponse
 
 // ControlResponseError represents a control protocol error
-type ControlResponseError struct {
-	Message string `json:"message"`
-	Code    string `json:"code,omitempty"`
-}
+type ControlResponseError = shared.ControlResponseError
 
 // ControlProtocol manages bidirectional control communication
 type ControlProtocol interface {
@@ -61,6 +53,9 @@ type ControlProtocol interface {
 
 	// HasControlSupport returns true if control protocol is enabled
 	HasControlSupport() bool
+
+	// Close fails pending and future requests with ErrClientClosed
+	Close() error
 }
 
 // ControlRequestHandler handles incoming control requests
@@ -102,33 +97,56 @@ type controlProtocol struct {
 	transport          Transport
 	pendingResponses   map[string]*PendingControlResponse
 	pendingResponsesMu sync.RWMutex
-	handlers          map[ControlRequestType]ControlRequestHandler
+	handlers           map[ControlRequestType]ControlRequestHandler
 	handlersMu         sync.RWMutex
-	requestID          int64
-	requestIDMu        sync.Mutex
+	clock              Clock
+	ids                IDGenerator
+	closed             chan struct{}
+	closeOnce          sync.Once
 }
 
+// controlRequestTimeout bounds the wait for the response to a control
+// request.
+const controlRequestTimeout = 30 * time.Second
+
 // NewControlProtocol creates a new control protocol instance
 func NewControlProtocol(transport Transport) ControlProtocol {
+	return newControlProtocol(transport, nil, nil)
+}
+
+// newControlProtocol creates a control protocol timing out requests with
+// clock and naming them with ids. Nil selects the system clock and
+// sequential IDs.
+func newControlProtocol(transport Transport, clock Clock, ids IDGenerator) *controlProtocol {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	if ids == nil {
+		ids = &SequentialIDs{}
+	}
 	return &controlProtocol{
 		transport:        transport,
 		pendingResponses: make(map[string]*PendingControlResponse),
 		handlers:         make(map[ControlRequestType]ControlRequestHandler),
+		clock:            clock,
+		ids:              ids,
+		closed:           make(chan struct{}),
 	}
 }
 
 // SendRequest sends a control request and waits for response
 func (cp *controlProtocol) SendRequest(ctx context.Context, req *ControlRequest) (*ControlResponse, error) {
+	select {
+	case <-cp.closed:
+		return nil, ErrClientClosed
+	default:
+	}
+
 	if !cp.HasControlSupport() {
 		return nil, fmt.Errorf("control protocol not supported by transport")
 	}
 
-	// Generate unique request ID
-	cp.requestIDMu.Lock()
-	cp.requestID++
-	reqID := fmt.Sprintf("sdk-ctrl-%d", cp.requestID)
-	cp.requestIDMu.Unlock()
-
+	reqID := cp.ids.NewID("sdk-ctrl")
 	req.ID = reqID
 
 	// Register pending response
@@ -148,17 +166,21 @@ func (cp *controlProtocol) SendRequest(ctx context.Context, req *ControlRequest)
 		return nil, fmt.Errorf("transport does not support control requests")
 	}
 
+	defer cp.cleanupPendingResponse(reqID)
+
 	if err := ctrlTransport.SendControlRequest(ctx, req); err != nil {
-		cp.cleanupPendingResponse(reqID)
 		return nil, fmt.Errorf("failed to send control request: %w", err)
 	}
 
 	// Wait for response with timeout
-	timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+	timer := cp.clock.NewTimer(controlRequestTimeout)
+	defer timer.Stop()
 
 	select {
 	case response := <-pending.ResponseChan:
+		if response == nil {
+			return nil, fmt.Errorf("control request %s was abandoned", reqID)
+		}
 		if response.Error != nil {
 			return nil, fmt.Errorf("control request failed: %s", response.Error.Message)
 		}
@@ -167,7 +189,13 @@ func (cp *controlProtocol) SendRequest(ctx context.Context, req *ControlRequest)
 	case <-pending.TimeoutChan:
 		return nil, fmt.Errorf("control request timeout after 30 seconds")
 
-	case <-timeoutCtx.Done():
+	case <-timer.C():
+		return nil, fmt.Errorf("control request timeout after 30 seconds")
+
+	case <-cp.closed:
+		return nil, ErrClientClosed
+
+	case <-ctx.Done():
 		return nil, fmt.Errorf("context cancelled while waiting for control response")
 	}
 }
@@ -203,26 +231,28 @@ func (cp *controlProtocol) HasControlSupport() bool {
 
 // HandleControlResponse processes incoming control responses
 func (cp *controlProtocol) HandleControlResponse(response *ControlResponse) error {
-	cp.pendingResponsesMu.RLock()
+	cp.pendingResponsesMu.Lock()
 	pending, exists := cp.pendingResponses[response.ID]
-	cp.pendingResponsesMu.RUnlock()
+	if exists {
+		delete(cp.pendingResponses, response.ID)
+	}
+	cp.pendingResponsesMu.Unlock()
 
 	if !exists {
 		return fmt.Errorf("received response for unknown request ID: %s", response.ID)
 	}
 
-	cp.pendingResponsesMu.Lock()
-	defer cp.pendingResponsesMu.Unlock()
+	pending.mu.Lock()
+	defer pending.mu.Unlock()
 
 	if pending.Done {
 		return fmt.Errorf("response already processed for request ID: %s", response.ID)
 	}
 
 	pending.Done = true
-	close(pending.ResponseChan)
-	close(pending.TimeoutChan)
-
-	cp.cleanupPendingResponse(response.ID)
+	// ResponseChan is buffered, so delivery never blocks even if the
+	// requester has already given up waiting.
+	pending.ResponseChan <- response
 	return nil
 }
 
@@ -240,7 +270,7 @@ func (cp *controlProtocol) HandleControlRequest(ctx context.Context, req *Contro
 	data, err := handler(ctx, req.Data)
 	if err != nil {
 		return &ControlResponse{
-			ID:   req.ID,
+			ID:      req.ID,
 			Subtype: ControlResponseTypeError,
 			Error: &ControlResponseError{
 				Message: err.Error(),
@@ -249,22 +279,66 @@ func (cp *controlProtocol) HandleControlRequest(ctx context.Context, req *Contro
 	}
 
 	return &ControlResponse{
-		ID:   req.ID,
+		ID:      req.ID,
 		Subtype: ControlResponseTypeSuccess,
 		Data:    data,
 	}, nil
 }
 
-// cleanupPendingResponse removes and cleans up a pending response
+// dispatch hands msg to the protocol if it is a control message, reporting
+// whether it was. Each request from the CLI is answered in a goroutine
+// started with spawn, so a slow permission callback does not hold up the
+// stream.
+func (cp *controlProtocol) dispatch(ctx context.Context, msg Message, spawn func(func(done <-chan struct{}))) bool {
+	switch m := msg.(type) {
+	case *ControlResponseMessage:
+		if m.Response != nil {
+			// Responses nobody waits for any more are dropped
+			_ = cp.HandleControlResponse(m.Response)
+		}
+		return true
+	case *ControlRequestMessage:
+		if req := m.Request; req != nil {
+			spawn(func(<-chan struct{}) { cp.answer(ctx, req) })
+		}
+		return true
+	}
+	return false
+}
+
+// answer runs the handler of a control request from the CLI and writes the
+// response back. Requests without a handler get an error response, so the
+// CLI never waits for an answer that will not come.
+func (cp *controlProtocol) answer(ctx context.Context, req *ControlRequest) {
+	response, err := cp.HandleControlRequest(ctx, req)
+	if err != nil {
+		response = &ControlResponse{
+			ID:      req.ID,
+			Subtype: ControlResponseTypeError,
+			Error:   &ControlResponseError{Message: err.Error()},
+		}
+	}
+	responder, ok := cp.transport.(ControlResponseTransport)
+	if !ok {
+		return
+	}
+	// A failed write means the CLI is gone, which the stream reports
+	_ = responder.SendControlResponse(ctx, response)
+}
+
+// Close fails all pending and future requests with ErrClientClosed.
+// It is safe to call multiple times.
+func (cp *controlProtocol) Close() error {
+	cp.closeOnce.Do(func() {
+		close(cp.closed)
+	})
+	return nil
+}
+
+// cleanupPendingResponse removes a pending response that is no longer awaited
 func (cp *controlProtocol) cleanupPendingResponse(requestID string) {
 	cp.pendingResponsesMu.Lock()
 	defer cp.pendingResponsesMu.Unlock()
 
-	if pending, exists := cp.pendingResponses[requestID]; exists {
-		if !pending.Done {
-			close(pending.ResponseChan)
-			close(pending.TimeoutChan)
-		}
-		delete(cp.pendingResponses, requestID)
-	}
-}
\ No newline at end of file
+	delete(cp.pendingResponses, requestID)
+}
@@ -7,16 +7,17 @@ import (
 	"testing"
 	"time"
 
+	"github.com/severity1/claude-code-sdk-go/claudetest/fakecli"
 	"github.com/severity1/claude-code-sdk-go/internal/shared"
 )
 
 // TestControlProtocol tests the control protocol implementation.
 func TestControlProtocol(t *testing.T) {
 	tests := []struct {
-		name       string
-		setupFunc  func(*MockControlTransport) ControlProtocol
-		testFunc   func(*testing.T, ControlProtocol, *MockControlTransport)
-		expectErr  bool
+		name      string
+		setupFunc func(*MockControlTransport) ControlProtocol
+		testFunc  func(*testing.T, ControlProtocol, *MockControlTransport)
+		expectErr bool
 	}{
 		{
 			name: "HasControlSupport returns true when transport supports it",
@@ -106,12 +107,12 @@ func TestControlProtocol(t *testing.T) {
 
 // MockControlTransport implements Transport and ControlRequestTransport for testing.
 type MockControlTransport struct {
-	mu                sync.Mutex
-	connected         bool
-	supportsControl   bool
-	sentRequests      []*ControlRequest
-	lastRequestID     string
-	responseChan       chan *ControlResponse
+	mu              sync.Mutex
+	connected       bool
+	supportsControl bool
+	sentRequests    []*ControlRequest
+	lastRequestID   string
+	responseChan    chan *ControlResponse
 }
 
 // NewMockControlTransport creates a new mock control transport.
@@ -238,4 +239,192 @@ func (m *MockControlTransport) SendResponse(resp *ControlResponse) {
 	case m.responseChan <- resp:
 	default:
 	}
-}
\ No newline at end of file
+}
+
+func TestControlProtocolClockAndIDs(t *testing.T) {
+	transport := NewMockControlTransport()
+	transport.supportsControl = true
+	if err := transport.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	clock := newManualClock()
+	cp := newControlProtocol(transport, clock, fixedIDs("ctrl-fixed"))
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := cp.SendRequest(context.Background(), &ControlRequest{Subtype: ControlRequestTypeInterrupt})
+		errs <- err
+	}()
+
+	timer := clock.waitForTimer(t)
+	if timer.d != controlRequestTimeout {
+		t.Errorf("Expected a %v timer, got %v", controlRequestTimeout, timer.d)
+	}
+	timer.fire()
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("Expected a timeout error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SendRequest did not time out when the clock fired")
+	}
+	sent := transport.GetSentRequests()
+	if len(sent) != 1 || sent[0].ID != "ctrl-fixed" {
+		t.Errorf("Expected the request ID from the generator, got %+v", sent)
+	}
+}
+
+// fixedIDs is an IDGenerator returning the same ID.
+type fixedIDs string
+
+func (f fixedIDs) NewID(string) string {
+	return string(f)
+}
+
+// manualClock is a Clock whose timers fire when a test fires them.
+type manualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers chan *manualTimer
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{
+		now:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		timers: make(chan *manualTimer, 100),
+	}
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *manualClock) NewTimer(d time.Duration) Timer {
+	timer := &manualTimer{d: d, ch: make(chan time.Time, 1)}
+	c.timers <- timer
+	return timer
+}
+
+// waitForTimer returns the next timer the code under test creates.
+func (c *manualClock) waitForTimer(t *testing.T) *manualTimer {
+	t.Helper()
+	select {
+	case timer := <-c.timers:
+		return timer
+	case <-time.After(5 * time.Second):
+		t.Fatal("No timer was created")
+		return nil
+	}
+}
+
+type manualTimer struct {
+	d  time.Duration
+	ch chan time.Time
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *manualTimer) Stop() bool {
+	return true
+}
+
+func (t *manualTimer) fire() {
+	t.ch <- time.Time{}
+}
+
+func TestClientAnswersCLIControlRequests(t *testing.T) {
+	cli := newFakeCLI(t, fakecli.Steps(
+		fakecli.CanUseTool("Bash", "toolu_1", map[string]any{"command": "ls"}),
+		fakecli.Request("unknown_request", nil),
+		fakecli.Result("done"),
+	))
+	ctx, cancel := setupClientTestContext(t, time.Minute)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli.Path), WithPermissionPromptToolName("stdio"))
+	connectClientSafely(ctx, t, client)
+	client.(*ClientImpl).GetPermissionManager().SetPermissionCallback(
+		func(_ context.Context, toolName string, _ map[string]any, _ ToolPermissionContext) (PermissionResult, error) {
+			return NewPermissionResultDeny("no " + toolName), nil
+		})
+	runFakeCLITurn(ctx, t, client, "list the files")
+
+	// The SDK's own requests reach the CLI and its responses come back
+	assertNoError(t, client.SetModel(ctx, "other-model"))
+	disconnectClientSafely(t, client)
+
+	responses := controlResponses(t, cli)
+	permission := responses["fakecli_req_1"]
+	if permission["subtype"] != "success" {
+		t.Fatalf("Expected the permission check answered, got %v", permission)
+	}
+	assertPermissionResponse(t, permission["response"].(map[string]any), "deny", "no Bash")
+	if unknown := responses["fakecli_req_2"]; unknown["subtype"] != "error" {
+		t.Errorf("Expected a request without a handler to get an error response, got %v", unknown)
+	}
+}
+
+// newFakeCLI builds a fake CLI playing turns, one per prompt. Building it
+// needs the go command, so the test is skipped in short mode.
+func newFakeCLI(t *testing.T, turns ...fakecli.Turn) *fakecli.CLI {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds the fake CLI binary")
+	}
+	return fakecli.New(t, fakecli.Script{Turns: turns})
+}
+
+// runFakeCLITurn sends prompt and returns the messages of the turn, up to
+// its result. Control messages must not reach consumers.
+func runFakeCLITurn(ctx context.Context, t *testing.T, client Client, prompt string) []Message {
+	t.Helper()
+	assertNoError(t, client.Query(ctx, prompt))
+	msgs := client.ReceiveMessages(ctx)
+	var received []Message
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				t.Fatalf("Stream ended before the result, got %d messages", len(received))
+			}
+			switch msg.(type) {
+			case *ControlRequestMessage, *ControlResponseMessage:
+				t.Errorf("Expected control messages to be handled, got %T", msg)
+			}
+			received = append(received, msg)
+			if _, ok := msg.(*ResultMessage); ok {
+				return received
+			}
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for the result, got %d messages", len(received))
+		}
+	}
+}
+
+// controlResponses returns the control responses the SDK wrote to the
+// fake CLI, by request ID.
+func controlResponses(t *testing.T, cli *fakecli.CLI) map[string]map[string]any {
+	t.Helper()
+	responses := make(map[string]map[string]any)
+	for _, input := range cli.Inputs(t) {
+		if input["type"] != shared.MessageTypeControlResponse {
+			continue
+		}
+		response, _ := input["response"].(map[string]any)
+		id, _ := response["request_id"].(string)
+		responses[id] = response
+	}
+	return responses
+}
@@ -0,0 +1,151 @@
+package claudecode
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// ProcessStats are counters across every client of the process, for quick
+// production introspection. Publish them with the expvarstats package or
+// read them with ReadProcessStats.
+type ProcessStats struct {
+	// ActiveClients is the number of connected clients.
+	ActiveClients int `json:"active_clients"`
+	// InFlightTurns is the number of turns awaiting their ResultMessage,
+	// and QueuedQueries the number of queries queued behind them.
+	InFlightTurns int `json:"in_flight_turns"`
+	QueuedQueries int `json:"queued_queries"`
+	// BufferedMessages is the number of messages received from the CLI and
+	// not yet read by the application.
+	BufferedMessages int `json:"buffered_messages"`
+	// ProcessExits is the number of CLI processes that exited while their
+	// client was connected, each needing a reconnect to continue.
+	ProcessExits int `json:"process_exits"`
+}
+
+var (
+	liveClientsMu sync.Mutex
+	// liveClients holds the connected clients.
+	liveClients = map[*ClientImpl]struct{}{}
+
+	// processExits counts the CLI processes that exited on their own.
+	processExits int32
+)
+
+// ReadProcessStats returns the current counters of every client.
+func ReadProcessStats() ProcessStats {
+	liveClientsMu.Lock()
+	clients := make([]*ClientImpl, 0, len(liveClients))
+	for c := range liveClients {
+		clients = append(clients, c)
+	}
+	liveClientsMu.Unlock()
+
+	stats := ProcessStats{
+		ActiveClients: len(clients),
+		ProcessExits:  int(atomic.LoadInt32(&processExits)),
+	}
+	for _, c := range clients {
+		turn := c.turnStats()
+		stats.QueuedQueries += turn.QueuedQueries
+		stats.BufferedMessages += turn.BufferedMessages
+		if turn.TurnInFlight {
+			stats.InFlightTurns++
+		}
+	}
+	return stats
+}
+
+// registerClient counts c as connected until unregisterClient.
+func registerClient(c *ClientImpl) {
+	liveClientsMu.Lock()
+	defer liveClientsMu.Unlock()
+	liveClients[c] = struct{}{}
+}
+
+func unregisterClient(c *ClientImpl) {
+	liveClientsMu.Lock()
+	defer liveClientsMu.Unlock()
+	delete(liveClients, c)
+}
+
+// clientDebugStats is the JSON served by ClientImpl.DebugHandler.
+type clientDebugStats struct {
+	Connected bool   `json:"connected"`
+	SessionID string `json:"session_id,omitempty"`
+	Model     string `json:"model,omitempty"`
+	turnDebugStats
+	ProcessExits  int                  `json:"process_exits"`
+	Turns         int                  `json:"turns"`
+	Tools         map[string]ToolStats `json:"tools"`
+	InFlightTools []InFlightTool       `json:"in_flight_tools"`
+	Hooks         []HookStats          `json:"hooks"`
+	Stream        StreamStats          `json:"stream"`
+	Process       ProcessStats         `json:"process"`
+}
+
+// turnDebugStats is the state of a client's current turn.
+type turnDebugStats struct {
+	TurnInFlight     bool `json:"turn_in_flight"`
+	QueuedQueries    int  `json:"queued_queries"`
+	BufferedMessages int  `json:"buffered_messages"`
+}
+
+// turnStats returns the state of the current turn and the messages waiting
+// to be read.
+func (c *ClientImpl) turnStats() turnDebugStats {
+	c.mu.RLock()
+	turns, msgChan := c.turns, c.msgChan
+	c.mu.RUnlock()
+
+	var stats turnDebugStats
+	if turns != nil {
+		turns.mu.Lock()
+		stats.TurnInFlight = turns.active
+		stats.QueuedQueries = len(turns.queue)
+		turns.mu.Unlock()
+	}
+	stats.BufferedMessages = len(msgChan)
+	return stats
+}
+
+// DebugHandler returns an http.Handler serving the client's live stats as
+// JSON: its connection, current turn, buffered messages, CLI process exits,
+// tool, hook and stream stats, and the ProcessStats of every client. Mount
+// it on an internal address only; it reports the session ID and model.
+//
+//	http.Handle("/debug/claude", client.(*claudecode.ClientImpl).DebugHandler())
+func (c *ClientImpl) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(c.debugStats())
+	})
+}
+
+func (c *ClientImpl) debugStats() clientDebugStats {
+	c.mu.RLock()
+	stats := clientDebugStats{
+		Connected:    c.connected,
+		ProcessExits: int(atomic.LoadInt32(&c.processExits)),
+	}
+	if c.activeModel != nil {
+		stats.Model = *c.activeModel
+	}
+	c.mu.RUnlock()
+
+	if info := c.InitInfo(); info != nil {
+		stats.SessionID = info.SessionID
+	}
+	stats.turnDebugStats = c.turnStats()
+	stats.Turns = len(c.Turns())
+	stats.Tools = c.ToolStats()
+	stats.InFlightTools = c.InFlightTools()
+	stats.Hooks = c.HookStats()
+	stats.Stream = c.GetStreamStats()
+	stats.Process = ReadProcessStats()
+	return stats
+}
@@ -0,0 +1,97 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadProcessStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	before := ReadProcessStats()
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "working"}}},
+	}))
+	client := NewClientWithTransport(transport, WithQueryQueueing(true))
+	connectClientSafely(ctx, t, client)
+
+	assertNoError(t, client.Query(ctx, "Fix the build"))
+	assertNoError(t, client.Query(ctx, "Then run the tests"))
+	waitForBufferedMessages(ctx, t, client.(*ClientImpl), 1)
+
+	stats := ReadProcessStats()
+	if stats.ActiveClients != before.ActiveClients+1 {
+		t.Errorf("Expected one more active client, got %d then %d", before.ActiveClients, stats.ActiveClients)
+	}
+	if stats.InFlightTurns != before.InFlightTurns+1 || stats.QueuedQueries != before.QueuedQueries+1 {
+		t.Errorf("Expected a turn in flight and a query queued, got %+v", stats)
+	}
+	if stats.BufferedMessages < before.BufferedMessages+1 {
+		t.Errorf("Expected the unread message to be counted, got %+v", stats)
+	}
+
+	disconnectClientSafely(t, client)
+	if after := ReadProcessStats(); after.ActiveClients != before.ActiveClients {
+		t.Errorf("Expected the client to be gone after Disconnect, got %d", after.ActiveClients)
+	}
+}
+
+func TestClientDebugHandler(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	before := ReadProcessStats()
+	transport := newClientMockTransport()
+	client := NewClientWithTransport(transport, WithModel("claude-sonnet-4-5"))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	// The CLI exiting on its own closes the transport's stream
+	_ = transport.Close()
+	impl := client.(*ClientImpl)
+	for atomic.LoadInt32(&impl.processExits) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("Expected the process exit to be noticed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	impl.DebugHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/claude", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected JSON, got %q", ct)
+	}
+	var got struct {
+		Connected    bool         `json:"connected"`
+		Model        string       `json:"model"`
+		TurnInFlight bool         `json:"turn_in_flight"`
+		ProcessExits int          `json:"process_exits"`
+		Process      ProcessStats `json:"process"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Expected JSON stats, got %q: %v", rec.Body.String(), err)
+	}
+	if !got.Connected || got.Model != "claude-sonnet-4-5" || got.TurnInFlight {
+		t.Errorf("Expected a connected idle client, got %+v", got)
+	}
+	if got.ProcessExits != 1 || got.Process.ProcessExits != before.ProcessExits+1 {
+		t.Errorf("Expected the process exit to be counted, got %+v", got)
+	}
+}
+
+func waitForBufferedMessages(ctx context.Context, t *testing.T, client *ClientImpl, n int) {
+	t.Helper()
+	for client.turnStats().BufferedMessages < n {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Expected %d buffered messages", n)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -23,6 +23,13 @@
 //	)
 //	defer client.Close()
 //
+// Messages from the CLI arrive in a guaranteed order: the init
+// SystemMessage precedes every assistant, user and result message, each
+// ToolResultBlock follows the ToolUseBlock it answers, and each turn ends
+// with exactly one ResultMessage. WithStrictOrdering turns a violation into
+// an error that ends the stream, so consumers need not defend against
+// impossible orderings.
+//
 // The SDK provides 100% feature parity with the Python SDK while embracing
 // Go idioms and patterns.
 package claudecode
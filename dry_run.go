@@ -0,0 +1,83 @@
+package claudecode
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DryRunCall is a tool call the agent attempted during a dry run.
+type DryRunCall struct {
+	ToolUseID string
+	ToolName  string
+	Input     map[string]any
+	Time      time.Time
+}
+
+// DryRunReport lists the tool calls the agent attempted during a dry run,
+// in the order it asked for them: its plan of action.
+type DryRunReport struct {
+	Calls []DryRunCall
+}
+
+// Tools returns the names of the tools the agent tried to use, each once,
+// in the order first tried.
+func (r DryRunReport) Tools() []string {
+	seen := make(map[string]bool)
+	var tools []string
+	for _, call := range r.Calls {
+		if !seen[call.ToolName] {
+			seen[call.ToolName] = true
+			tools = append(tools, call.ToolName)
+		}
+	}
+	return tools
+}
+
+// dryRunRecorder collects the tool calls denied during a dry run.
+type dryRunRecorder struct {
+	mu    sync.Mutex
+	calls []DryRunCall
+}
+
+func (dr *dryRunRecorder) record(call DryRunCall) {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	dr.calls = append(dr.calls, call)
+}
+
+func (dr *dryRunRecorder) report() DryRunReport {
+	if dr == nil {
+		return DryRunReport{}
+	}
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	return DryRunReport{Calls: append([]DryRunCall(nil), dr.calls...)}
+}
+
+// newDryRunHandler answers every can_use_tool request with a denial telling
+// the agent to carry on, in locale, after recording the call.
+func newDryRunHandler(recorder *dryRunRecorder, locale string) ControlRequestHandler {
+	return func(_ context.Context, data map[string]any) (map[string]any, error) {
+		toolName, _ := data["tool_name"].(string)
+		input, _ := data["input"].(map[string]any)
+		toolUseID, _ := data["tool_use_id"].(string)
+
+		recorder.record(DryRunCall{ToolUseID: toolUseID, ToolName: toolName, Input: input, Time: time.Now()})
+		return permissionResponse(NewPermissionResultDeny(LocalizedMessage(locale, MessageDryRun, toolName)), input), nil
+	}
+}
+
+// validateDryRun rejects permission modes that let tools run without
+// asking, which a dry run cannot intercept.
+func validateDryRun(options *Options) error {
+	if !options.DryRun || options.PermissionMode == nil {
+		return nil
+	}
+	switch mode := *options.PermissionMode; mode {
+	case PermissionModeAcceptEdits, PermissionModeBypassPermissions:
+		return fmt.Errorf("dry run cannot be combined with permission mode %s", mode)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package claudecode
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/severity1/claude-code-sdk-go/claudetest/fakecli"
+)
+
+func TestClientDryRunDeniesAndRecordsToolCalls(t *testing.T) {
+	ctx, cancel := setupDryRunTestContext(t)
+	defer cancel()
+
+	// A plan reviewer and tool limit do not apply to dry runs
+	reviewer := func(context.Context, Plan) (PlanDecision, error) {
+		return PlanDecision{Approved: true}, nil
+	}
+	client := NewClientWithTransport(newClientControlMockTransport(),
+		WithDryRun(true), WithPlanReviewer(reviewer), WithMaxConcurrentTools(1)).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+	protocol := client.GetControlProtocol().(*controlProtocol)
+
+	calls := []map[string]any{
+		{"tool_name": "Write", "tool_use_id": "toolu_1", "input": map[string]any{"file_path": "main.go"}},
+		{"tool_name": "Bash", "tool_use_id": "toolu_2", "input": map[string]any{"command": "go test ./..."}},
+		{"tool_name": "Write", "tool_use_id": "toolu_3", "input": map[string]any{"file_path": "main_test.go"}},
+		{"tool_name": ToolNameExitPlanMode, "input": map[string]any{"plan": "1. write"}},
+	}
+	for _, data := range calls {
+		response, err := protocol.HandleControlRequest(ctx, &ControlRequest{Subtype: ControlRequestTypeCanUseTool, Data: data})
+		if err != nil {
+			t.Fatalf("HandleControlRequest failed: %v", err)
+		}
+		assertPermissionResponse(t, response.Data, "deny", "")
+		message, _ := response.Data["message"].(string)
+		if !strings.Contains(message, data["tool_name"].(string)) || !strings.Contains(message, "continue planning") {
+			t.Errorf("Expected a dry run message naming the tool, got %q", message)
+		}
+	}
+
+	report := client.DryRunReport()
+	if len(report.Calls) != 4 {
+		t.Fatalf("Expected 4 recorded calls, got %d", len(report.Calls))
+	}
+	second := report.Calls[1]
+	if second.ToolName != "Bash" || second.ToolUseID != "toolu_2" || second.Input["command"] != "go test ./..." || second.Time.IsZero() {
+		t.Errorf("Unexpected recorded call: %+v", second)
+	}
+	if got := strings.Join(report.Tools(), ","); got != "Write,Bash,ExitPlanMode" {
+		t.Errorf("Expected tools in first-use order, got %s", got)
+	}
+
+	// The report is a copy
+	report.Calls[0].ToolName = "changed"
+	if client.DryRunReport().Calls[0].ToolName != "Write" {
+		t.Error("Expected DryRunReport to return a copy")
+	}
+}
+
+func TestClientDryRunWithCLI(t *testing.T) {
+	input := map[string]any{"file_path": "main.go", "content": "package main"}
+	cli := newFakeCLI(t, fakecli.Steps(
+		fakecli.ToolUse("toolu_1", "Write", input),
+		fakecli.CanUseTool("Write", "toolu_1", input),
+		fakecli.ToolResult("toolu_1", "denied", true),
+		fakecli.Result("I would write main.go"),
+	))
+	ctx, cancel := setupClientTestContext(t, time.Minute)
+	defer cancel()
+
+	client := NewClient(WithCLIPath(cli.Path), WithDryRun(true), WithAllowedTools("Write")).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	runFakeCLITurn(ctx, t, client, "write main.go")
+	report := client.DryRunReport()
+	disconnectClientSafely(t, client)
+
+	args := strings.Join(cli.Args(t), " ")
+	if !strings.Contains(args, "--permission-prompt-tool stdio") || strings.Contains(args, "--allowed-tools") {
+		t.Errorf("Expected every tool call checked over stdio, got %q", args)
+	}
+	response, _ := controlResponses(t, cli)["fakecli_req_1"]["response"].(map[string]any)
+	assertPermissionResponse(t, response, "deny", "")
+	if len(report.Calls) != 1 || report.Calls[0].ToolUseID != "toolu_1" || report.Calls[0].Input["file_path"] != "main.go" {
+		t.Errorf("Expected the Write call recorded, got %+v", report.Calls)
+	}
+}
+
+func TestClientDryRunReportEmptyWithoutDryRun(t *testing.T) {
+	client := NewClientWithTransport(newClientControlMockTransport()).(*ClientImpl)
+	if report := client.DryRunReport(); len(report.Calls) != 0 {
+		t.Errorf("Expected an empty report, got %+v", report)
+	}
+}
+
+func TestDryRunValidation(t *testing.T) {
+	ctx, cancel := setupDryRunTestContext(t)
+	defer cancel()
+
+	for _, mode := range []PermissionMode{PermissionModeAcceptEdits, PermissionModeBypassPermissions} {
+		client := NewClientWithTransport(newClientControlMockTransport(), WithDryRun(true), WithPermissionMode(mode))
+		if err := client.Connect(ctx); err == nil || !strings.Contains(err.Error(), "dry run") {
+			t.Errorf("Expected %s to be rejected with a dry run, got %v", mode, err)
+		}
+	}
+
+	client := NewClientWithTransport(newClientControlMockTransport(), WithDryRun(true), WithPermissionMode(PermissionModePlan))
+	connectClientSafely(ctx, t, client)
+	disconnectClientSafely(t, client)
+
+	if _, err := QueryWithTransport(ctx, "plan it", newClientControlMockTransport(), WithDryRun(true)); err == nil {
+		t.Error("Expected one-shot queries to reject dry runs")
+	}
+}
+
+func setupDryRunTestContext(t *testing.T) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
@@ -0,0 +1,352 @@
+// Package egress restricts the domains the WebFetch and WebSearch tools may
+// reach and keeps a record of every outbound request for compliance review.
+//
+// A Policy checks the URL of each WebFetch call and the allowed_domains of
+// each WebSearch call against allow and deny rules, and logs each request,
+// allowed or not:
+//
+//	policy, err := egress.New(egress.Rules{
+//		Allow: []string{"go.dev", "*.go.dev", "pkg.go.dev", "github.com", "*.githubusercontent.com"},
+//		Deny:  []string{"gist.github.com"},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	policy.SetLog(auditFile)
+//	_ = client.(*claudecode.ClientImpl).GetHookSystem().AddHook(string(claudecode.HookEventTypePreToolUse), policy.Hook())
+//
+// or, as a permission callback:
+//
+//	client.(*claudecode.ClientImpl).GetPermissionManager().SetPermissionCallback(policy.PermissionCallback(nil))
+//
+// Install one or the other: each logs the requests it checks.
+//
+// A rule is a domain name, matched case-insensitively and without a port.
+// "example.com" matches only that host; "*.example.com" matches its
+// subdomains at any depth but not example.com itself; "*" matches every
+// host. A * elsewhere matches within a single label, so "docs-*.example.com"
+// matches "docs-v2.example.com". Deny rules win over allow rules, and when
+// there are allow rules a host must match one of them.
+//
+// Search results come from pages on any domain, so with allow rules a
+// WebSearch call is denied unless it names its allowed_domains, each of
+// which the policy must allow.
+package egress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Tools a Policy checks.
+const (
+	ToolWebFetch  = "WebFetch"
+	ToolWebSearch = "WebSearch"
+)
+
+// ErrInvalidRule is wrapped by New errors for malformed rules.
+var ErrInvalidRule = errors.New("invalid egress rule")
+
+// Rules configure a Policy.
+type Rules struct {
+	// Allow lists the domains tools may reach. When empty, every domain
+	// not denied may be reached.
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists the domains tools may never reach.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// Verdict is the outcome of checking a host or URL.
+type Verdict struct {
+	Allowed bool
+	// Reason explains a denial, for the agent.
+	Reason string
+	// Rule is the deny rule that matched, if any.
+	Rule string
+	// Host is the host that was checked.
+	Host string
+}
+
+// Request is an outbound request a tool call asked for, as logged.
+type Request struct {
+	Time     time.Time `json:"time"`
+	ToolName string    `json:"tool_name"`
+	// URL is the page a WebFetch call fetches.
+	URL string `json:"url,omitempty"`
+	// Query and Domains are the search and allowed_domains of a WebSearch
+	// call.
+	Query   string   `json:"query,omitempty"`
+	Domains []string `json:"domains,omitempty"`
+	Allowed bool     `json:"allowed"`
+	Reason  string   `json:"reason,omitempty"`
+	Rule    string   `json:"rule,omitempty"`
+}
+
+// Policy checks and logs the requests of web tools. It is safe for
+// concurrent use.
+type Policy struct {
+	allow []string
+	deny  []string
+	now   func() time.Time
+
+	mu       sync.Mutex
+	log      io.Writer
+	requests []Request
+}
+
+// New returns a Policy applying rules, or an error wrapping ErrInvalidRule.
+func New(rules Rules) (*Policy, error) {
+	p := &Policy{now: time.Now}
+	for _, list := range []struct {
+		rules []string
+		into  *[]string
+	}{{rules.Allow, &p.allow}, {rules.Deny, &p.deny}} {
+		for _, rule := range list.rules {
+			normalized, err := parseRule(rule)
+			if err != nil {
+				return nil, err
+			}
+			*list.into = append(*list.into, normalized)
+		}
+	}
+	return p, nil
+}
+
+// SetLog writes each request the policy checks to w as a line of JSON. If
+// a write fails, the request is denied, so nothing goes out unrecorded.
+func (p *Policy) SetLog(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log = w
+}
+
+// Requests returns the requests checked so far, oldest first.
+func (p *Policy) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Request(nil), p.requests...)
+}
+
+// CheckHost decides whether host may be reached.
+func (p *Policy) CheckHost(host string) Verdict {
+	host = normalizeHost(host)
+	if host == "" {
+		return Verdict{Reason: "request without a host"}
+	}
+	for _, rule := range p.deny {
+		if matchDomain(rule, host) {
+			return Verdict{Reason: fmt.Sprintf("%s denied by rule %q", host, rule), Rule: rule, Host: host}
+		}
+	}
+	if len(p.allow) == 0 {
+		return Verdict{Allowed: true, Host: host}
+	}
+	for _, rule := range p.allow {
+		if matchDomain(rule, host) {
+			return Verdict{Allowed: true, Host: host}
+		}
+	}
+	return Verdict{Reason: fmt.Sprintf("%s is not an allowed domain", host), Host: host}
+}
+
+// CheckURL decides whether the page at rawURL may be fetched.
+func (p *Policy) CheckURL(rawURL string) Verdict {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Verdict{Reason: fmt.Sprintf("URL could not be checked: %v", err)}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Verdict{Reason: fmt.Sprintf("%q is not an http or https URL", rawURL)}
+	}
+	return p.CheckHost(u.Hostname())
+}
+
+// CheckTool checks and logs a tool call. Calls to tools other than WebFetch
+// and WebSearch are allowed and not logged.
+func (p *Policy) CheckTool(toolName string, input map[string]any) Verdict {
+	var request Request
+	var verdict Verdict
+	switch toolName {
+	case ToolWebFetch:
+		request.URL, _ = input["url"].(string)
+		verdict = p.CheckURL(request.URL)
+	case ToolWebSearch:
+		request.Query, _ = input["query"].(string)
+		request.Domains = stringList(input["allowed_domains"])
+		verdict = p.checkSearch(request.Domains)
+	default:
+		return Verdict{Allowed: true}
+	}
+	request.ToolName = toolName
+	request.Allowed = verdict.Allowed
+	request.Reason = verdict.Reason
+	request.Rule = verdict.Rule
+	if err := p.record(request); err != nil {
+		return Verdict{Reason: fmt.Sprintf("request could not be logged: %v", err), Host: verdict.Host}
+	}
+	return verdict
+}
+
+// checkSearch decides whether a search limited to domains may run.
+func (p *Policy) checkSearch(domains []string) Verdict {
+	if len(domains) == 0 {
+		if len(p.allow) > 0 {
+			return Verdict{Reason: "web searches must set allowed_domains to domains the egress policy allows"}
+		}
+		return Verdict{Allowed: true}
+	}
+	for _, domain := range domains {
+		if verdict := p.CheckHost(domain); !verdict.Allowed {
+			return verdict
+		}
+	}
+	return Verdict{Allowed: true}
+}
+
+// record appends request to the policy's requests and log.
+func (p *Policy) record(request Request) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	request.Time = p.now()
+	if p.log != nil {
+		line, err := json.Marshal(request)
+		if err == nil {
+			_, err = p.log.Write(append(line, '\n'))
+		}
+		if err != nil {
+			request.Allowed = false
+			request.Reason = fmt.Sprintf("request could not be logged: %v", err)
+			p.requests = append(p.requests, request)
+			return err
+		}
+	}
+	p.requests = append(p.requests, request)
+	return nil
+}
+
+// PermissionCallback returns a permission callback that denies the web
+// requests the policy denies. next, if not nil, decides everything else;
+// otherwise it is allowed.
+func (p *Policy) PermissionCallback(next claudecode.CanUseToolFunc) claudecode.CanUseToolFunc {
+	return func(ctx context.Context, toolName string, input map[string]any, permContext claudecode.ToolPermissionContext) (claudecode.PermissionResult, error) {
+		if verdict := p.CheckTool(toolName, input); !verdict.Allowed {
+			return claudecode.NewPermissionResultDeny(verdict.Reason), nil
+		}
+		if next == nil {
+			return claudecode.NewPermissionResultAllow(), nil
+		}
+		return next(ctx, toolName, input, permContext)
+	}
+}
+
+// Hook returns a PreToolUse hook that stops denied web requests, with the
+// reason as its message. Other tools and events are ignored.
+func (p *Policy) Hook() claudecode.HookCallback {
+	return func(_ context.Context, input interface{}, _ claudecode.HookContext) (claudecode.HookOutput, error) {
+		var pre claudecode.PreToolUseHookInput
+		switch in := input.(type) {
+		case claudecode.PreToolUseHookInput:
+			pre = in
+		case *claudecode.PreToolUseHookInput:
+			pre = *in
+		default:
+			return claudecode.HookOutput{Behavior: claudecode.HookBehaviorContinue}, nil
+		}
+		if verdict := p.CheckTool(pre.ToolName, pre.ToolInput); !verdict.Allowed {
+			return claudecode.HookOutput{Behavior: claudecode.HookBehaviorStop, Message: verdict.Reason}, nil
+		}
+		return claudecode.HookOutput{Behavior: claudecode.HookBehaviorContinue}, nil
+	}
+}
+
+// parseRule normalizes a domain rule and checks its wildcards.
+func parseRule(rule string) (string, error) {
+	normalized := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(rule)), ".")
+	if normalized == "" || strings.ContainsAny(normalized, "/:@[] ") {
+		return "", fmt.Errorf("%w: %q is not a domain", ErrInvalidRule, rule)
+	}
+	for _, label := range strings.Split(normalized, ".") {
+		if label == "" {
+			return "", fmt.Errorf("%w: %q has an empty label", ErrInvalidRule, rule)
+		}
+		if _, err := path.Match(label, ""); err != nil {
+			return "", fmt.Errorf("%w: %q: %v", ErrInvalidRule, rule, err)
+		}
+	}
+	return normalized, nil
+}
+
+// matchDomain reports whether host matches rule. A leading "*." matches
+// one or more labels; other wildcards match within a label.
+func matchDomain(rule, host string) bool {
+	if rule == "*" {
+		return true
+	}
+	if suffix, ok := cutPrefix(rule, "*."); ok {
+		ruleLabels := strings.Split(suffix, ".")
+		hostLabels := strings.Split(host, ".")
+		if len(hostLabels) <= len(ruleLabels) {
+			return false
+		}
+		return matchLabels(ruleLabels, hostLabels[len(hostLabels)-len(ruleLabels):])
+	}
+	return matchLabels(strings.Split(rule, "."), strings.Split(host, "."))
+}
+
+func matchLabels(rule, host []string) bool {
+	if len(rule) != len(host) {
+		return false
+	}
+	for i := range rule {
+		if ok, _ := path.Match(rule[i], host[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeHost lowercases host and drops any port, brackets and trailing
+// dot, so "Example.COM.:443" and "example.com" are the same host.
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return strings.TrimSuffix(host, ".")
+}
+
+// stringList reads a JSON array of strings from a tool input.
+func stringList(v any) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []any:
+		var out []string
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
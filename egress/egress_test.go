@@ -0,0 +1,222 @@
+package egress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestPolicyCheckURL(t *testing.T) {
+	policy := newTestPolicy(t, Rules{
+		Allow: []string{"go.dev", "*.go.dev", "GitHub.com", "docs-*.example.com"},
+		Deny:  []string{"private.go.dev"},
+	})
+
+	tests := []struct {
+		url      string
+		allowed  bool
+		wantRule string
+	}{
+		{"https://go.dev/doc", true, ""},
+		{"https://pkg.go.dev/net/url", true, ""},
+		{"https://a.b.go.dev", true, ""},
+		{"http://GITHUB.com:443/x", true, ""},
+		{"https://github.com./x", true, ""},
+		{"https://docs-v2.example.com", true, ""},
+		{"https://private.go.dev/", false, "private.go.dev"},
+		{"https://notgo.dev", false, ""},
+		{"https://api.github.com", false, ""},
+		{"https://docs.example.com", false, ""},
+		{"https://go.dev@evil.com/", false, ""},
+		{"file:///etc/passwd", false, ""},
+		{"go.dev/doc", false, ""},
+		{"://bad", false, ""},
+	}
+	for _, test := range tests {
+		verdict := policy.CheckURL(test.url)
+		if verdict.Allowed != test.allowed || verdict.Rule != test.wantRule {
+			t.Errorf("CheckURL(%q) = %+v, want allowed=%v rule=%q", test.url, verdict, test.allowed, test.wantRule)
+		}
+		if !verdict.Allowed && verdict.Reason == "" {
+			t.Errorf("CheckURL(%q) denied without a reason", test.url)
+		}
+	}
+}
+
+func TestPolicyDenyOnly(t *testing.T) {
+	policy := newTestPolicy(t, Rules{Deny: []string{"*.internal", "169.254.169.254"}})
+	for host, allowed := range map[string]bool{
+		"example.com":     true,
+		"db.internal":     false,
+		"internal":        true,
+		"169.254.169.254": false,
+	} {
+		if verdict := policy.CheckHost(host); verdict.Allowed != allowed {
+			t.Errorf("CheckHost(%q) = %+v, want allowed=%v", host, verdict, allowed)
+		}
+	}
+	if verdict := policy.CheckTool(ToolWebSearch, map[string]any{"query": "go generics"}); !verdict.Allowed {
+		t.Errorf("Expected unrestricted searches without allow rules, got %+v", verdict)
+	}
+}
+
+func TestPolicyCheckSearch(t *testing.T) {
+	policy := newTestPolicy(t, Rules{Allow: []string{"go.dev", "*.go.dev"}})
+
+	tests := []struct {
+		input   map[string]any
+		allowed bool
+	}{
+		{map[string]any{"query": "generics"}, false},
+		{map[string]any{"query": "generics", "allowed_domains": []any{"go.dev", "pkg.go.dev"}}, true},
+		{map[string]any{"query": "generics", "allowed_domains": []string{"go.dev", "reddit.com"}}, false},
+	}
+	for _, test := range tests {
+		if verdict := policy.CheckTool(ToolWebSearch, test.input); verdict.Allowed != test.allowed {
+			t.Errorf("CheckTool(WebSearch, %v) = %+v, want allowed=%v", test.input, verdict, test.allowed)
+		}
+	}
+}
+
+func TestPolicyLog(t *testing.T) {
+	policy := newTestPolicy(t, Rules{Allow: []string{"go.dev"}})
+	policy.now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+	var log bytes.Buffer
+	policy.SetLog(&log)
+
+	policy.CheckTool(ToolWebFetch, map[string]any{"url": "https://go.dev/doc", "prompt": "summarize"})
+	policy.CheckTool(ToolWebFetch, map[string]any{"url": "https://evil.com"})
+	policy.CheckTool(ToolWebSearch, map[string]any{"query": "q", "allowed_domains": []any{"go.dev"}})
+	policy.CheckTool("Read", map[string]any{"file_path": "x"})
+
+	requests := policy.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("Expected the 3 web requests to be recorded, got %+v", requests)
+	}
+	if !requests[0].Allowed || requests[0].URL != "https://go.dev/doc" || requests[1].Allowed || requests[2].Query != "q" {
+		t.Errorf("Unexpected requests: %+v", requests)
+	}
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 log lines, got %q", log.String())
+	}
+	var logged Request
+	if err := json.Unmarshal([]byte(lines[1]), &logged); err != nil {
+		t.Fatalf("Log line is not JSON: %v", err)
+	}
+	if logged.ToolName != ToolWebFetch || logged.URL != "https://evil.com" || logged.Allowed || logged.Reason == "" || !logged.Time.Equal(policy.now()) {
+		t.Errorf("Unexpected log line: %+v", logged)
+	}
+}
+
+func TestPolicyLogFailureDenies(t *testing.T) {
+	policy := newTestPolicy(t, Rules{})
+	policy.SetLog(failingWriter{})
+
+	verdict := policy.CheckTool(ToolWebFetch, map[string]any{"url": "https://go.dev"})
+	if verdict.Allowed || !strings.Contains(verdict.Reason, "could not be logged") {
+		t.Errorf("Expected an unlogged request to be denied, got %+v", verdict)
+	}
+	if requests := policy.Requests(); len(requests) != 1 || requests[0].Allowed {
+		t.Errorf("Expected the request to be recorded as denied, got %+v", requests)
+	}
+}
+
+func TestNewInvalidRules(t *testing.T) {
+	for _, rules := range []Rules{
+		{Allow: []string{""}},
+		{Allow: []string{"https://go.dev"}},
+		{Deny: []string{"go.dev/path"}},
+		{Deny: []string{"a..b"}},
+		{Deny: []string{"[a.com"}},
+	} {
+		if _, err := New(rules); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Expected %+v to be rejected, got %v", rules, err)
+		}
+	}
+}
+
+func TestPolicyPermissionCallback(t *testing.T) {
+	policy := newTestPolicy(t, Rules{Allow: []string{"go.dev"}})
+	ctx := context.Background()
+
+	callback := policy.PermissionCallback(nil)
+	result, err := callback(ctx, ToolWebFetch, map[string]any{"url": "https://evil.com"}, claudecode.ToolPermissionContext{})
+	if err != nil {
+		t.Fatalf("Callback failed: %v", err)
+	}
+	deny, ok := result.(*claudecode.PermissionResultDeny)
+	if !ok || !strings.Contains(deny.Message(), "evil.com") {
+		t.Errorf("Expected the fetch to be denied, got %#v", result)
+	}
+	result, _ = callback(ctx, ToolWebFetch, map[string]any{"url": "https://go.dev"}, claudecode.ToolPermissionContext{})
+	if _, ok := result.(*claudecode.PermissionResultAllow); !ok {
+		t.Errorf("Expected the fetch to be allowed, got %#v", result)
+	}
+
+	var nextCalls []string
+	next := func(_ context.Context, toolName string, _ map[string]any, _ claudecode.ToolPermissionContext) (claudecode.PermissionResult, error) {
+		nextCalls = append(nextCalls, toolName)
+		return claudecode.NewPermissionResultDeny("next"), nil
+	}
+	chained := policy.PermissionCallback(next)
+	_, _ = chained(ctx, ToolWebFetch, map[string]any{"url": "https://evil.com"}, claudecode.ToolPermissionContext{})
+	_, _ = chained(ctx, ToolWebFetch, map[string]any{"url": "https://go.dev"}, claudecode.ToolPermissionContext{})
+	_, _ = chained(ctx, "Bash", map[string]any{"command": "curl https://evil.com"}, claudecode.ToolPermissionContext{})
+	if strings.Join(nextCalls, ",") != "WebFetch,Bash" {
+		t.Errorf("Expected next to decide what the policy allows, got %v", nextCalls)
+	}
+}
+
+func TestPolicyHook(t *testing.T) {
+	policy := newTestPolicy(t, Rules{Deny: []string{"evil.com"}})
+	hook := policy.Hook()
+	ctx := context.Background()
+
+	output, err := hook(ctx, &claudecode.PreToolUseHookInput{
+		ToolName:  ToolWebFetch,
+		ToolInput: map[string]any{"url": "https://evil.com/payload"},
+	}, claudecode.HookContext{})
+	if err != nil {
+		t.Fatalf("Hook failed: %v", err)
+	}
+	if output.Behavior != claudecode.HookBehaviorStop || !strings.Contains(output.Message, "evil.com") {
+		t.Errorf("Expected the hook to stop the fetch, got %+v", output)
+	}
+
+	for _, input := range []interface{}{
+		claudecode.PreToolUseHookInput{ToolName: ToolWebFetch, ToolInput: map[string]any{"url": "https://go.dev"}},
+		claudecode.PreToolUseHookInput{ToolName: "Read", ToolInput: map[string]any{"file_path": "x"}},
+		claudecode.PostToolUseHookInput{ToolName: ToolWebFetch},
+	} {
+		output, err := hook(ctx, input, claudecode.HookContext{})
+		if err != nil || output.Behavior != claudecode.HookBehaviorContinue {
+			t.Errorf("Expected %T to continue, got %+v, %v", input, output, err)
+		}
+	}
+	if len(policy.Requests()) != 2 {
+		t.Errorf("Expected the hook to log both fetches, got %+v", policy.Requests())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func newTestPolicy(t *testing.T, rules Rules) *Policy {
+	t.Helper()
+	policy, err := New(rules)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return policy
+}
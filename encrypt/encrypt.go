@@ -0,0 +1,203 @@
+// Package encrypt keeps conversations encrypted at rest, since they often
+// contain proprietary code.
+//
+// Data is sealed with AES-GCM under keys from a KeyProvider. Each sealed
+// value names the key that sealed it, so keys can be rotated: new data is
+// sealed with the current key and data sealed with older keys still opens
+// as long as the provider has them.
+//
+//	keys, err := encrypt.NewKeyring("2025-01", key2501)
+//	if err != nil {
+//		return err
+//	}
+//	store := sessionstore.Encrypt(sessionstore.NewRedisStore(redisAdapter{rdb}, ""), keys)
+//
+// Transcripts and audit trails are written through NewWriter and read
+// back through NewReader:
+//
+//	f, err := os.Create("run.md.enc")
+//	// ...
+//	w, err := encrypt.NewWriter(ctx, f, keys)
+//	// ...
+//	_, err = io.WriteString(w, transcript.ExportMarkdown(messages))
+//	// ...
+//	err = w.Close() // seals the end of the stream; does not close f
+//
+// Keys are 16, 24 or 32 bytes long, for AES-128, AES-192 and AES-256.
+package encrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrDecrypt is wrapped by the errors of data that cannot be opened: data
+// that was altered or cut short, or sealed with a key the provider does not
+// have.
+var ErrDecrypt = errors.New("cannot decrypt")
+
+// ErrUnknownKey is wrapped by KeyProvider errors for key IDs the provider
+// does not have.
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// sealVersion is the first byte of sealed values.
+const sealVersion = 1
+
+// KeyProvider supplies encryption keys by ID. Implementations backed by a
+// key management service should cache keys. They must be safe for
+// concurrent use.
+type KeyProvider interface {
+	// CurrentKey returns the key new data is sealed with, and its ID.
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// Key returns the key with id, or an error wrapping ErrUnknownKey.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// Keyring is a KeyProvider holding its keys in memory.
+type Keyring struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// NewKeyring returns a Keyring whose current key is key, with ID id.
+func NewKeyring(id string, key []byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	if err := k.Rotate(id, key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Rotate adds key, with ID id, and makes it the current key. Earlier keys
+// are kept to open what they sealed. Rotating to an existing ID with a
+// different key is an error.
+func (k *Keyring) Rotate(id string, key []byte) error {
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("encryption key ID %q must be 1 to 255 bytes long", id)
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return fmt.Errorf("encryption key %s: %w", id, err)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if existing, ok := k.keys[id]; ok && string(existing) != string(key) {
+		return fmt.Errorf("encryption key %s already exists", id)
+	}
+	k.keys[id] = append([]byte(nil), key...)
+	k.current = id
+	return nil
+}
+
+// Remove drops the key with id, once nothing sealed with it remains. The
+// current key cannot be removed.
+func (k *Keyring) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if id == k.current {
+		return fmt.Errorf("encryption key %s is the current key", id)
+	}
+	delete(k.keys, id)
+	return nil
+}
+
+// CurrentKey returns the key added last.
+func (k *Keyring) CurrentKey(context.Context) (string, []byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current, k.keys[k.current], nil
+}
+
+// Key returns the key with id.
+func (k *Keyring) Key(_ context.Context, id string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+// Seal encrypts plaintext with the provider's current key. additionalData,
+// which may be nil, is authenticated but not stored: Open needs the same
+// value, so sealed data cannot be moved to where other data was expected.
+func Seal(ctx context.Context, keys KeyProvider, plaintext, additionalData []byte) ([]byte, error) {
+	id, aead, err := currentAEAD(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	out := append([]byte{sealVersion, byte(len(id))}, id...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts data from Seal, looking up the key that sealed it.
+func Open(ctx context.Context, keys KeyProvider, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < 2 || sealed[0] != sealVersion {
+		return nil, fmt.Errorf("%w: not sealed data", ErrDecrypt)
+	}
+	idEnd := 2 + int(sealed[1])
+	if len(sealed) < idEnd {
+		return nil, fmt.Errorf("%w: truncated data", ErrDecrypt)
+	}
+	aead, err := keyAEAD(ctx, keys, string(sealed[2:idEnd]))
+	if err != nil {
+		return nil, err
+	}
+	rest := sealed[idEnd:]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: truncated data", ErrDecrypt)
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return plaintext, nil
+}
+
+// currentAEAD returns the provider's current key as an AES-GCM cipher.
+func currentAEAD(ctx context.Context, keys KeyProvider) (string, cipher.AEAD, error) {
+	id, key, err := keys.CurrentKey(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("getting encryption key: %w", err)
+	}
+	if id == "" || len(id) > 255 {
+		return "", nil, fmt.Errorf("encryption key ID %q must be 1 to 255 bytes long", id)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", nil, fmt.Errorf("encryption key %s: %w", id, err)
+	}
+	return id, aead, nil
+}
+
+// keyAEAD returns the provider's key with id as an AES-GCM cipher.
+func keyAEAD(ctx context.Context, keys KeyProvider, id string) (cipher.AEAD, error) {
+	key, err := keys.Key(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key %s: %w", id, err)
+	}
+	return aead, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,97 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSealAndOpen(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeyring(t)
+
+	sealed, err := Seal(ctx, keys, []byte("func main() {}"), []byte("run-1"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("main")) {
+		t.Error("Expected the plaintext not to appear in sealed data")
+	}
+	plaintext, err := Open(ctx, keys, sealed, []byte("run-1"))
+	if err != nil || string(plaintext) != "func main() {}" {
+		t.Errorf("Expected the plaintext back, got %q, %v", plaintext, err)
+	}
+
+	altered := append([]byte(nil), sealed...)
+	altered[len(altered)-1] ^= 1
+	for name, open := range map[string]func() ([]byte, error){
+		"altered":     func() ([]byte, error) { return Open(ctx, keys, altered, []byte("run-1")) },
+		"other data":  func() ([]byte, error) { return Open(ctx, keys, sealed, []byte("run-2")) },
+		"truncated":   func() ([]byte, error) { return Open(ctx, keys, sealed[:10], []byte("run-1")) },
+		"not sealed":  func() ([]byte, error) { return Open(ctx, keys, []byte("plain text"), nil) },
+		"unknown key": func() ([]byte, error) { return Open(ctx, newTestKeyringWithID(t, "other"), sealed, []byte("run-1")) },
+	} {
+		if _, err := open(); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: expected ErrDecrypt, got %v", name, err)
+		}
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeyring(t)
+	old, err := Seal(ctx, keys, []byte("old"), nil)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	if err := keys.Rotate("2025-02", bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if id, _, _ := keys.CurrentKey(ctx); id != "2025-02" {
+		t.Errorf("Expected the new key to be current, got %s", id)
+	}
+	if plaintext, err := Open(ctx, keys, old, nil); err != nil || string(plaintext) != "old" {
+		t.Errorf("Expected data sealed with the old key to open, got %q, %v", plaintext, err)
+	}
+	if err := keys.Remove("2025-02"); err == nil {
+		t.Error("Expected the current key not to be removable")
+	}
+	if err := keys.Remove("2025-01"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := keys.Key(ctx, "2025-01"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected the removed key to be unknown, got %v", err)
+	}
+	if _, err := Open(ctx, keys, old, nil); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected data sealed with a removed key not to open, got %v", err)
+	}
+}
+
+func TestKeyringInvalidKeys(t *testing.T) {
+	if _, err := NewKeyring("k", []byte("short")); err == nil {
+		t.Error("Expected a key of the wrong size to be rejected")
+	}
+	if _, err := NewKeyring("", make([]byte, 32)); err == nil {
+		t.Error("Expected an empty key ID to be rejected")
+	}
+	keys := newTestKeyring(t)
+	if err := keys.Rotate("2025-01", bytes.Repeat([]byte{9}, 32)); err == nil {
+		t.Error("Expected reusing a key ID for another key to be rejected")
+	}
+}
+
+func newTestKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	return newTestKeyringWithID(t, "2025-01")
+}
+
+func newTestKeyringWithID(t *testing.T, id string) *Keyring {
+	t.Helper()
+	keys, err := NewKeyring(id, bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("NewKeyring failed: %v", err)
+	}
+	return keys
+}
@@ -0,0 +1,221 @@
+package encrypt
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// streamMagic starts encrypted streams.
+const streamMagic = "CCE1"
+
+// chunkSize is the most plaintext sealed in one chunk of a stream.
+const chunkSize = 64 << 10
+
+// prefixSize is the size of the random part of chunk nonces; the rest is
+// the chunk's number and a flag marking the last chunk, so chunks cannot be
+// reordered, dropped or cut off at the end without Open failing.
+const prefixSize = 7
+
+// Writer encrypts a stream in chunks with the provider's current key.
+// Close must be called to seal the end of the stream; a stream without it
+// reads as truncated.
+type Writer struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  [prefixSize]byte
+	counter uint32
+	buf     []byte
+	err     error
+}
+
+// NewWriter writes the stream's header to w and returns a Writer
+// encrypting what is written to it.
+func NewWriter(ctx context.Context, w io.Writer, keys KeyProvider) (*Writer, error) {
+	id, aead, err := currentAEAD(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	sw := &Writer{w: w, aead: aead}
+	if _, err := rand.Read(sw.prefix[:]); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	header := append([]byte(streamMagic), byte(len(id)))
+	header = append(header, id...)
+	header = append(header, sw.prefix[:]...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// Write encrypts p, writing full chunks as they fill.
+func (sw *Writer) Write(p []byte) (int, error) {
+	if sw.err != nil {
+		return 0, sw.err
+	}
+	sw.buf = append(sw.buf, p...)
+	for len(sw.buf) > chunkSize {
+		if err := sw.writeChunk(sw.buf[:chunkSize], false); err != nil {
+			return 0, err
+		}
+		sw.buf = sw.buf[chunkSize:]
+	}
+	return len(p), nil
+}
+
+// Flush writes what has been written so far as a chunk, so it is on w
+// should the process stop before Close.
+func (sw *Writer) Flush() error {
+	if sw.err != nil {
+		return sw.err
+	}
+	if len(sw.buf) == 0 {
+		return nil
+	}
+	if err := sw.writeChunk(sw.buf, false); err != nil {
+		return err
+	}
+	sw.buf = sw.buf[:0]
+	return nil
+}
+
+// Close writes the last chunk. It does not close the underlying writer.
+func (sw *Writer) Close() error {
+	if sw.err != nil {
+		return sw.err
+	}
+	if err := sw.writeChunk(sw.buf, true); err != nil {
+		return err
+	}
+	sw.buf = nil
+	sw.err = errors.New("encrypt: write to closed Writer")
+	return nil
+}
+
+func (sw *Writer) writeChunk(plaintext []byte, last bool) error {
+	if sw.counter == ^uint32(0) {
+		sw.err = errors.New("encrypt: stream too long")
+		return sw.err
+	}
+	sealed := sw.aead.Seal(nil, chunkNonce(sw.prefix, sw.counter, last), plaintext, nil)
+	sw.counter++
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(sealed)))
+	if _, err := sw.w.Write(append(length[:], sealed...)); err != nil {
+		sw.err = err
+		return err
+	}
+	return nil
+}
+
+// Reader decrypts a stream written by a Writer.
+type Reader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	prefix  [prefixSize]byte
+	counter uint32
+	buf     []byte
+	done    bool
+	err     error
+}
+
+// NewReader reads the stream's header from r, looking up the key that
+// encrypted it, and returns a Reader decrypting the rest.
+func NewReader(ctx context.Context, r io.Reader, keys KeyProvider) (*Reader, error) {
+	header := make([]byte, len(streamMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: reading stream header: %v", ErrDecrypt, err)
+	}
+	if string(header[:len(streamMagic)]) != streamMagic {
+		return nil, fmt.Errorf("%w: not an encrypted stream", ErrDecrypt)
+	}
+	rest := make([]byte, int(header[len(streamMagic)])+prefixSize)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, fmt.Errorf("%w: reading stream header: %v", ErrDecrypt, err)
+	}
+	id := string(rest[:len(rest)-prefixSize])
+	aead, err := keyAEAD(ctx, keys, id)
+	if err != nil {
+		return nil, err
+	}
+	sr := &Reader{r: r, aead: aead}
+	copy(sr.prefix[:], rest[len(id):])
+	return sr, nil
+}
+
+// Read decrypts the stream. Only a stream that was closed ends with
+// io.EOF; errors for altered or truncated streams wrap ErrDecrypt.
+func (sr *Reader) Read(p []byte) (int, error) {
+	for len(sr.buf) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		if sr.done {
+			sr.err = sr.checkEnd()
+			continue
+		}
+		sr.err = sr.readChunk()
+	}
+	n := copy(p, sr.buf)
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+func (sr *Reader) readChunk() error {
+	var length [4]byte
+	if _, err := io.ReadFull(sr.r, length[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%w: stream is truncated", ErrDecrypt)
+		}
+		return err
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if int(size) > chunkSize+sr.aead.Overhead() {
+		return fmt.Errorf("%w: chunk of %d bytes is too large", ErrDecrypt, size)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(sr.r, sealed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%w: stream is truncated", ErrDecrypt)
+		}
+		return err
+	}
+	for _, last := range []bool{false, true} {
+		plaintext, err := sr.aead.Open(nil, chunkNonce(sr.prefix, sr.counter, last), sealed, nil)
+		if err == nil {
+			sr.counter++
+			sr.buf = plaintext
+			sr.done = last
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: chunk %d was altered or is out of place", ErrDecrypt, sr.counter)
+}
+
+// checkEnd returns io.EOF if nothing follows the last chunk.
+func (sr *Reader) checkEnd() error {
+	var extra [1]byte
+	n, err := sr.r.Read(extra[:])
+	if n > 0 {
+		return fmt.Errorf("%w: data after the end of the stream", ErrDecrypt)
+	}
+	if err == nil || err == io.EOF {
+		return io.EOF
+	}
+	return err
+}
+
+func chunkNonce(prefix [prefixSize]byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, prefixSize+5)
+	copy(nonce, prefix[:])
+	binary.BigEndian.PutUint32(nonce[prefixSize:], counter)
+	if last {
+		nonce[prefixSize+4] = 1
+	}
+	return nonce
+}
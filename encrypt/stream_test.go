@@ -0,0 +1,109 @@
+package encrypt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeyring(t)
+
+	for _, size := range []int{0, 10, chunkSize, chunkSize + 1, 3*chunkSize + 17} {
+		plaintext := bytes.Repeat([]byte("transcript "), size/11+1)[:size]
+		encrypted := encryptStream(t, keys, plaintext)
+
+		r, err := NewReader(ctx, bytes.NewReader(encrypted), keys)
+		if err != nil {
+			t.Fatalf("NewReader failed: %v", err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("Size %d: expected the plaintext back, got %d bytes, %v", size, len(got), err)
+		}
+	}
+}
+
+func TestStreamFlush(t *testing.T) {
+	keys := newTestKeyring(t)
+	var buf bytes.Buffer
+	w, err := NewWriter(context.Background(), &buf, keys)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	_, _ = io.WriteString(w, "line 1\n")
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	flushed := buf.Len()
+	_, _ = io.WriteString(w, "line 2\n")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := io.WriteString(w, "late"); err == nil {
+		t.Error("Expected writes after Close to fail")
+	}
+
+	r, _ := NewReader(context.Background(), bytes.NewReader(buf.Bytes()), keys)
+	if got, err := io.ReadAll(r); err != nil || string(got) != "line 1\nline 2\n" {
+		t.Errorf("Expected both lines back, got %q, %v", got, err)
+	}
+
+	// A stream cut after a flush keeps what was flushed but reads as truncated
+	r, _ = NewReader(context.Background(), bytes.NewReader(buf.Bytes()[:flushed]), keys)
+	got, err := io.ReadAll(r)
+	if string(got) != "line 1\n" || !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Expected the flushed line and ErrDecrypt, got %q, %v", got, err)
+	}
+}
+
+func TestStreamTampering(t *testing.T) {
+	keys := newTestKeyring(t)
+	plaintext := []byte(strings.Repeat("x", 2*chunkSize+5))
+	encrypted := encryptStream(t, keys, plaintext)
+	headerSize := len(streamMagic) + 1 + len("2025-01") + prefixSize
+	chunk := 4 + chunkSize + 16
+
+	flipped := append([]byte(nil), encrypted...)
+	flipped[headerSize+10] ^= 1
+	swapped := append(append(append([]byte(nil), encrypted[:headerSize]...),
+		encrypted[headerSize+chunk:headerSize+2*chunk]...), encrypted[headerSize:headerSize+chunk]...)
+	swapped = append(swapped, encrypted[headerSize+2*chunk:]...)
+
+	for name, data := range map[string][]byte{
+		"altered":       flipped,
+		"reordered":     swapped,
+		"truncated":     encrypted[:headerSize+chunk],
+		"extended":      append(append([]byte(nil), encrypted...), 0),
+		"not encrypted": plaintext,
+		"no last chunk": encrypted[:len(encrypted)-25],
+	} {
+		r, err := NewReader(context.Background(), bytes.NewReader(data), keys)
+		if err == nil {
+			_, err = io.ReadAll(r)
+		}
+		if !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: expected ErrDecrypt, got %v", name, err)
+		}
+	}
+}
+
+func encryptStream(t *testing.T, keys KeyProvider, plaintext []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(context.Background(), &buf, keys)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
@@ -25,6 +25,26 @@ type JSONDecodeError = shared.JSONDecodeError
 // MessageParseError represents errors parsing message content.
 type MessageParseError = shared.MessageParseError
 
+// MessageTooLargeError indicates a CLI message exceeded the maximum message size.
+type MessageTooLargeError = shared.MessageTooLargeError
+
+// StreamIntegrityError indicates CLI messages were lost, duplicated or reordered.
+type StreamIntegrityError = shared.StreamIntegrityError
+
+// ProtocolError reports a CLI message that does not match the schema of its
+// protocol version, with WithStrictProtocol.
+type ProtocolError = shared.ProtocolError
+
+// AuthRequiredError indicates the CLI has no credentials.
+type AuthRequiredError = shared.AuthRequiredError
+
+// AuthExpiredError indicates the CLI's stored login has expired.
+type AuthExpiredError = shared.AuthExpiredError
+
+// PartialResult is returned when a query fails after messages arrived, and
+// keeps the messages received before the failure.
+type PartialResult = shared.PartialResult
+
 // NewConnectionError creates a new connection error.
 var NewConnectionError = shared.NewConnectionError
 
@@ -39,3 +59,25 @@ var NewJSONDecodeError = shared.NewJSONDecodeError
 
 // NewMessageParseError creates a new message parse error.
 var NewMessageParseError = shared.NewMessageParseError
+
+// NewMessageTooLargeError creates a new message too large error.
+var NewMessageTooLargeError = shared.NewMessageTooLargeError
+
+// NewStreamIntegrityError creates a new stream integrity error.
+var NewStreamIntegrityError = shared.NewStreamIntegrityError
+
+// NewProtocolError creates a new protocol error.
+var NewProtocolError = shared.NewProtocolError
+
+// NewAuthRequiredError creates a new auth required error.
+var NewAuthRequiredError = shared.NewAuthRequiredError
+
+// NewAuthExpiredError creates a new auth expired error.
+var NewAuthExpiredError = shared.NewAuthExpiredError
+
+// NewPartialResult creates a new partial result error.
+var NewPartialResult = shared.NewPartialResult
+
+// ErrCassetteMiss is returned when a replayed cassette holds no recording
+// for a prompt.
+var ErrCassetteMiss = shared.ErrCassetteMiss
@@ -0,0 +1,470 @@
+// Package eval compares prompts, models and other options by running test
+// cases and scoring the answers.
+//
+// A Suite runs each Case under each Variant, scores every run with its
+// Scorers, and reports scores, pass rates and cost per variant. Scorers are
+// plain functions, such as Contains or Regexp, or an LLM judge that asks a
+// second agent to grade the answer:
+//
+//	suite := &eval.Suite{
+//		Cases: []eval.Case{
+//			{Name: "capital", Prompt: "What is the capital of France?", Expected: "Paris"},
+//		},
+//		Variants: eval.Matrix(
+//			eval.Axis{Name: "model", Values: []eval.Variant{
+//				{Name: "sonnet", Options: []claudecode.Option{claudecode.WithModel("sonnet")}},
+//				{Name: "opus", Options: []claudecode.Option{claudecode.WithModel("opus")}},
+//			}},
+//		),
+//		Scorers: []eval.Scorer{
+//			{Name: "exact", Score: eval.ContainsExpected},
+//			{Name: "judge", Score: (&eval.Judge{Rubric: "Is the answer correct and concise?"}).Score},
+//		},
+//	}
+//	report, err := suite.Run(ctx)
+//	report.WriteCSV(os.Stdout)
+package eval
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// DefaultConcurrency is how many runs a Suite makes at once when
+// Suite.Concurrency is not set.
+const DefaultConcurrency = 4
+
+// defaultVariant names the single variant of a suite without variants.
+const defaultVariant = "default"
+
+// RunFunc runs one case. claudecode.Run is the default.
+type RunFunc func(ctx context.Context, spec claudecode.RunSpec) (*claudecode.RunResult, error)
+
+// Case is one input of an evaluation.
+type Case struct {
+	Name   string
+	Prompt string
+	// Expected is the reference answer or property scorers compare
+	// against; its meaning is up to them.
+	Expected string
+	// Metadata is passed to scorers untouched.
+	Metadata map[string]any
+	// Options are added after the variant's options.
+	Options []claudecode.Option
+}
+
+// Variant is one configuration the cases run under.
+type Variant struct {
+	Name    string
+	Options []claudecode.Option
+}
+
+// Axis is a dimension of a variant matrix, such as models or system
+// prompts.
+type Axis struct {
+	Name   string
+	Values []Variant
+}
+
+// Matrix returns a variant for each combination of the axes' values.
+// Variant names join the value names with "/", in axis order, and options
+// are applied in axis order.
+func Matrix(axes ...Axis) []Variant {
+	variants := []Variant{{}}
+	for _, axis := range axes {
+		next := make([]Variant, 0, len(variants)*len(axis.Values))
+		for _, variant := range variants {
+			for _, value := range axis.Values {
+				name := value.Name
+				if variant.Name != "" {
+					name = variant.Name + "/" + value.Name
+				}
+				options := append(append([]claudecode.Option(nil), variant.Options...), value.Options...)
+				next = append(next, Variant{Name: name, Options: options})
+			}
+		}
+		variants = next
+	}
+	if len(axes) == 0 {
+		return nil
+	}
+	return variants
+}
+
+// Score is a scorer's verdict on one run.
+type Score struct {
+	// Value is the score, conventionally from 0 to 1.
+	Value  float64 `json:"value"`
+	Pass   bool    `json:"pass"`
+	Reason string  `json:"reason,omitempty"`
+	// CostUSD is what scoring cost, for scorers that query an agent.
+	CostUSD float64 `json:"cost_usd,omitempty"`
+	// Error is set when the scorer failed; the score then counts as a
+	// failing zero.
+	Error string `json:"error,omitempty"`
+}
+
+// ScoreFunc scores the result of running c.
+type ScoreFunc func(ctx context.Context, c Case, result *claudecode.RunResult) (Score, error)
+
+// Scorer is a named ScoreFunc.
+type Scorer struct {
+	Name  string
+	Score ScoreFunc
+}
+
+// Result is one case run under one variant.
+type Result struct {
+	Case     string        `json:"case"`
+	Variant  string        `json:"variant"`
+	Text     string        `json:"text"`
+	Success  bool          `json:"success"`
+	CostUSD  float64       `json:"cost_usd"`
+	Duration time.Duration `json:"duration"`
+	// Error is set when the run itself failed; no scorers run then.
+	Error  string           `json:"error,omitempty"`
+	Scores map[string]Score `json:"scores"`
+}
+
+// Passed reports whether the run completed and every scorer passed it.
+func (r Result) Passed() bool {
+	if r.Error != "" {
+		return false
+	}
+	for _, score := range r.Scores {
+		if !score.Pass {
+			return false
+		}
+	}
+	return true
+}
+
+// scoringCost returns what the scorers of r cost.
+func (r Result) scoringCost() float64 {
+	cost := 0.0
+	for _, score := range r.Scores {
+		cost += score.CostUSD
+	}
+	return cost
+}
+
+// VariantSummary aggregates a variant's results.
+type VariantSummary struct {
+	Variant string `json:"variant"`
+	Cases   int    `json:"cases"`
+	// Passed counts the cases where the run completed and every scorer
+	// passed.
+	Passed int `json:"passed"`
+	// MeanScores is each scorer's mean value over the completed runs.
+	MeanScores map[string]float64 `json:"mean_scores"`
+	// CostUSD is what the runs cost and ScoringCostUSD what scoring them
+	// cost.
+	CostUSD        float64       `json:"cost_usd"`
+	ScoringCostUSD float64       `json:"scoring_cost_usd"`
+	Duration       time.Duration `json:"duration"`
+}
+
+// Report is the outcome of a Suite run.
+type Report struct {
+	// Results are ordered by case, then variant.
+	Results   []Result         `json:"results"`
+	Summaries []VariantSummary `json:"summaries"`
+	// CostUSD is the total of runs and scoring.
+	CostUSD float64 `json:"cost_usd"`
+}
+
+// Suite is an evaluation: cases, the variants to run them under, and how
+// to score them.
+type Suite struct {
+	Cases []Case
+	// Variants default to a single variant without options.
+	Variants []Variant
+	Scorers  []Scorer
+	// Concurrency bounds the runs in flight; the default is
+	// DefaultConcurrency.
+	Concurrency int
+	// Runner runs the cases; the default is claudecode.Run.
+	Runner RunFunc
+}
+
+// run is one case under one variant.
+type run struct {
+	index   int
+	c       Case
+	variant Variant
+}
+
+// Run runs every case under every variant and scores the results. Failed
+// runs and scorers are recorded in the report; Run only returns an error
+// for an invalid suite or when ctx ends, along with the results so far.
+func (s *Suite) Run(ctx context.Context) (*Report, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	variants := s.Variants
+	if len(variants) == 0 {
+		variants = []Variant{{Name: defaultVariant}}
+	}
+	runner := s.Runner
+	if runner == nil {
+		runner = claudecode.Run
+	}
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	runs := make(chan run)
+	results := make([]Result, len(s.Cases)*len(variants))
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range runs {
+				results[r.index] = s.runCase(ctx, runner, r.c, r.variant)
+			}
+		}()
+	}
+
+	sent := 0
+feed:
+	for _, c := range s.Cases {
+		for _, variant := range variants {
+			select {
+			case runs <- run{index: sent, c: c, variant: variant}:
+				sent++
+			case <-ctx.Done():
+				break feed
+			}
+		}
+	}
+	close(runs)
+	wg.Wait()
+
+	report := &Report{Results: results[:sent]}
+	report.summarize(variants)
+	if sent < len(results) {
+		return report, ctx.Err()
+	}
+	return report, nil
+}
+
+// validate rejects suites whose results could not be told apart.
+func (s *Suite) validate() error {
+	if len(s.Cases) == 0 {
+		return errors.New("suite has no cases")
+	}
+	names := make(map[string]bool)
+	for i, c := range s.Cases {
+		if c.Name == "" {
+			return fmt.Errorf("case %d has no name", i)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate case name %q", c.Name)
+		}
+		names[c.Name] = true
+	}
+	names = make(map[string]bool)
+	for _, variant := range s.Variants {
+		if names[variant.Name] {
+			return fmt.Errorf("duplicate variant name %q", variant.Name)
+		}
+		names[variant.Name] = true
+	}
+	names = make(map[string]bool)
+	for _, scorer := range s.Scorers {
+		if scorer.Name == "" || scorer.Score == nil {
+			return errors.New("scorers need a name and a score function")
+		}
+		if names[scorer.Name] {
+			return fmt.Errorf("duplicate scorer name %q", scorer.Name)
+		}
+		names[scorer.Name] = true
+	}
+	return nil
+}
+
+// runCase runs c under variant and scores the result.
+func (s *Suite) runCase(ctx context.Context, runner RunFunc, c Case, variant Variant) Result {
+	result := Result{Case: c.Name, Variant: variant.Name, Scores: make(map[string]Score, len(s.Scorers))}
+	options := append(append([]claudecode.Option(nil), variant.Options...), c.Options...)
+	runResult, err := runner(ctx, claudecode.RunSpec{Prompt: c.Prompt, Options: options})
+	if runResult != nil {
+		result.Text = runResult.Text
+		result.Success = runResult.Success
+		result.CostUSD = runResult.CostUSD
+		result.Duration = runResult.Duration
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	for _, scorer := range s.Scorers {
+		score, err := scorer.Score(ctx, c, runResult)
+		if err != nil {
+			score = Score{CostUSD: score.CostUSD, Error: err.Error()}
+		}
+		result.Scores[scorer.Name] = score
+	}
+	return result
+}
+
+// summarize computes the per-variant summaries and total cost.
+func (r *Report) summarize(variants []Variant) {
+	byVariant := make(map[string]*VariantSummary, len(variants))
+	counts := make(map[string]map[string]int)
+	r.Summaries = make([]VariantSummary, len(variants))
+	for i, variant := range variants {
+		r.Summaries[i] = VariantSummary{Variant: variant.Name, MeanScores: make(map[string]float64)}
+		byVariant[variant.Name] = &r.Summaries[i]
+		counts[variant.Name] = make(map[string]int)
+	}
+
+	r.CostUSD = 0
+	for _, result := range r.Results {
+		summary := byVariant[result.Variant]
+		summary.Cases++
+		if result.Passed() {
+			summary.Passed++
+		}
+		summary.CostUSD += result.CostUSD
+		summary.ScoringCostUSD += result.scoringCost()
+		summary.Duration += result.Duration
+		for name, score := range result.Scores {
+			summary.MeanScores[name] += score.Value
+			counts[result.Variant][name]++
+		}
+		r.CostUSD += result.CostUSD + result.scoringCost()
+	}
+	for i := range r.Summaries {
+		summary := &r.Summaries[i]
+		for name, total := range summary.MeanScores {
+			summary.MeanScores[name] = total / float64(counts[summary.Variant][name])
+		}
+	}
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV writes one row per result: the case, variant, outcome and
+// costs, then a score and pass column per scorer, in name order.
+func (r *Report) WriteCSV(w io.Writer) error {
+	scorerSet := make(map[string]bool)
+	for _, result := range r.Results {
+		for name := range result.Scores {
+			scorerSet[name] = true
+		}
+	}
+	scorers := make([]string, 0, len(scorerSet))
+	for name := range scorerSet {
+		scorers = append(scorers, name)
+	}
+	sort.Strings(scorers)
+
+	writer := csv.NewWriter(w)
+	header := []string{"case", "variant", "success", "passed", "cost_usd", "scoring_cost_usd", "duration_ms", "error"}
+	for _, name := range scorers {
+		header = append(header, "score:"+name, "pass:"+name)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, result := range r.Results {
+		row := []string{
+			result.Case,
+			result.Variant,
+			strconv.FormatBool(result.Success),
+			strconv.FormatBool(result.Passed()),
+			strconv.FormatFloat(result.CostUSD, 'f', -1, 64),
+			strconv.FormatFloat(result.scoringCost(), 'f', -1, 64),
+			strconv.FormatInt(result.Duration.Milliseconds(), 10),
+			result.Error,
+		}
+		for _, name := range scorers {
+			score, ok := result.Scores[name]
+			if !ok {
+				row = append(row, "", "")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(score.Value, 'f', -1, 64), strconv.FormatBool(score.Pass))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Succeeded passes runs whose result is not an error.
+func Succeeded(_ context.Context, _ Case, result *claudecode.RunResult) (Score, error) {
+	if result.Success {
+		return Score{Value: 1, Pass: true}, nil
+	}
+	return Score{Reason: "run failed"}, nil
+}
+
+// ContainsExpected passes answers containing the case's Expected text,
+// ignoring case.
+func ContainsExpected(_ context.Context, c Case, result *claudecode.RunResult) (Score, error) {
+	if c.Expected == "" {
+		return Score{}, fmt.Errorf("case %s has no expected text", c.Name)
+	}
+	if strings.Contains(strings.ToLower(result.Text), strings.ToLower(c.Expected)) {
+		return Score{Value: 1, Pass: true}, nil
+	}
+	return Score{Reason: fmt.Sprintf("answer does not contain %q", c.Expected)}, nil
+}
+
+// Contains scores the fraction of substrings the answer contains, ignoring
+// case, and passes answers containing all of them.
+func Contains(substrings ...string) ScoreFunc {
+	return func(_ context.Context, _ Case, result *claudecode.RunResult) (Score, error) {
+		if len(substrings) == 0 {
+			return Score{Value: 1, Pass: true}, nil
+		}
+		text := strings.ToLower(result.Text)
+		var missing []string
+		for _, s := range substrings {
+			if !strings.Contains(text, strings.ToLower(s)) {
+				missing = append(missing, strconv.Quote(s))
+			}
+		}
+		score := Score{Value: float64(len(substrings)-len(missing)) / float64(len(substrings)), Pass: len(missing) == 0}
+		if len(missing) > 0 {
+			score.Reason = "missing " + strings.Join(missing, ", ")
+		}
+		return score, nil
+	}
+}
+
+// Regexp passes answers matching pattern. It panics if pattern does not
+// compile, like regexp.MustCompile.
+func Regexp(pattern string) ScoreFunc {
+	re := regexp.MustCompile(pattern)
+	return func(_ context.Context, _ Case, result *claudecode.RunResult) (Score, error) {
+		if re.MatchString(result.Text) {
+			return Score{Value: 1, Pass: true}, nil
+		}
+		return Score{Reason: fmt.Sprintf("answer does not match %s", pattern)}, nil
+	}
+}
@@ -0,0 +1,225 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestSuiteRun(t *testing.T) {
+	runs := &fakeRuns{answers: map[string]string{
+		"sonnet/terse:capital": "Paris",
+		"sonnet/long:capital":  "The capital of France is Paris.",
+		"opus/terse:capital":   "Paris",
+		"opus/long:capital":    "It is Lyon.",
+	}, erroring: map[string]bool{"sonnet/terse:math": true}}
+	suite := &Suite{
+		Cases: []Case{
+			{Name: "capital", Prompt: "What is the capital of France?", Expected: "Paris"},
+			{Name: "math", Prompt: "What is 2+2?", Expected: "4"},
+		},
+		Variants: Matrix(
+			Axis{Name: "model", Values: []Variant{
+				{Name: "sonnet", Options: []claudecode.Option{claudecode.WithModel("sonnet")}},
+				{Name: "opus", Options: []claudecode.Option{claudecode.WithModel("opus")}},
+			}},
+			Axis{Name: "style", Values: []Variant{
+				{Name: "terse", Options: []claudecode.Option{claudecode.WithAppendSystemPrompt("Be terse.")}},
+				{Name: "long"},
+			}},
+		),
+		Scorers: []Scorer{
+			{Name: "expected", Score: ContainsExpected},
+			{Name: "ok", Score: Succeeded},
+		},
+		Runner: runs.run,
+	}
+
+	report, err := suite.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(report.Results) != 8 {
+		t.Fatalf("Expected 8 results, got %d", len(report.Results))
+	}
+	first := report.Results[0]
+	if first.Case != "capital" || first.Variant != "sonnet/terse" || !first.Passed() {
+		t.Errorf("Expected a passing first result, got %+v", first)
+	}
+	errored := report.Results[4]
+	if errored.Case != "math" || errored.Variant != "sonnet/terse" || errored.Error == "" || len(errored.Scores) != 0 {
+		t.Errorf("Expected an errored run without scores, got %+v", errored)
+	}
+
+	opus := report.Summaries[3]
+	if opus.Variant != "opus/long" || opus.Cases != 2 || opus.Passed != 1 || opus.MeanScores["expected"] != 0.5 {
+		t.Errorf("Expected opus/long to fail the capital case, got %+v", opus)
+	}
+	sonnet := report.Summaries[0]
+	if sonnet.Passed != 1 || sonnet.MeanScores["expected"] != 1 || sonnet.CostUSD < 0.0099 || sonnet.CostUSD > 0.0101 {
+		t.Errorf("Expected sonnet/terse to pass its completed case, got %+v", sonnet)
+	}
+	if report.CostUSD < 0.0699 || report.CostUSD > 0.0701 {
+		t.Errorf("Expected the cost of the 7 completed runs, got %v", report.CostUSD)
+	}
+
+	options := claudecode.NewOptions(runs.options["opus/terse:capital"]...)
+	if options.Model == nil || *options.Model != "opus" || options.AppendSystemPrompt == nil {
+		t.Errorf("Expected the variant's options, got %+v", options)
+	}
+}
+
+func TestSuiteRunDefaultVariant(t *testing.T) {
+	runs := &fakeRuns{}
+	suite := &Suite{Cases: []Case{{Name: "only", Prompt: "hi"}}, Runner: runs.run}
+	report, err := suite.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(report.Results) != 1 || report.Results[0].Variant != "default" || report.Summaries[0].Variant != "default" {
+		t.Errorf("Expected a default variant, got %+v", report)
+	}
+}
+
+func TestSuiteValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		suite Suite
+	}{
+		{"no cases", Suite{}},
+		{"unnamed case", Suite{Cases: []Case{{Prompt: "hi"}}}},
+		{"duplicate case", Suite{Cases: []Case{{Name: "a"}, {Name: "a"}}}},
+		{"duplicate variant", Suite{Cases: []Case{{Name: "a"}}, Variants: []Variant{{Name: "v"}, {Name: "v"}}}},
+		{"scorer without function", Suite{Cases: []Case{{Name: "a"}}, Scorers: []Scorer{{Name: "s"}}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := test.suite.Run(context.Background()); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestSuiteRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	suite := &Suite{Cases: []Case{{Name: "a"}, {Name: "b"}}, Runner: (&fakeRuns{}).run}
+	report, err := suite.Run(ctx)
+	if !errors.Is(err, context.Canceled) || report == nil {
+		t.Errorf("Expected context.Canceled with a report, got %+v, %v", report, err)
+	}
+}
+
+func TestScorers(t *testing.T) {
+	ctx := context.Background()
+	result := &claudecode.RunResult{Text: "The answer is 42, and PARIS is a city.", Success: true}
+
+	if score, _ := Contains("42", "paris", "rome")(ctx, Case{}, result); score.Pass || score.Value < 0.66 || score.Value > 0.67 || !strings.Contains(score.Reason, `"rome"`) {
+		t.Errorf("Expected two of three substrings, got %+v", score)
+	}
+	if score, _ := Regexp(`\b\d+\b`)(ctx, Case{}, result); !score.Pass {
+		t.Errorf("Expected the pattern to match, got %+v", score)
+	}
+	if _, err := ContainsExpected(ctx, Case{Name: "x"}, result); err == nil {
+		t.Error("Expected an error without expected text")
+	}
+	if score, _ := Succeeded(ctx, Case{}, &claudecode.RunResult{}); score.Pass {
+		t.Error("Expected a failed run to fail")
+	}
+}
+
+func TestReportWriters(t *testing.T) {
+	report := &Report{Results: []Result{
+		{Case: "a", Variant: "v", Success: true, CostUSD: 0.01, Scores: map[string]Score{
+			"judge": {Value: 0.8, Pass: true, CostUSD: 0.002},
+			"exact": {Value: 0, Pass: false},
+		}},
+		{Case: "b", Variant: "v", Error: "cli crashed", Scores: map[string]Score{}},
+	}}
+	report.summarize([]Variant{{Name: "v"}})
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	wantHeader := "case,variant,success,passed,cost_usd,scoring_cost_usd,duration_ms,error,score:exact,pass:exact,score:judge,pass:judge"
+	if got := strings.Join(rows[0], ","); got != wantHeader {
+		t.Errorf("Expected header %q, got %q", wantHeader, got)
+	}
+	if got := strings.Join(rows[1], ","); got != "a,v,true,false,0.01,0.002,0,,0,false,0.8,true" {
+		t.Errorf("Unexpected row %q", got)
+	}
+	if rows[2][7] != "cli crashed" || rows[2][8] != "" {
+		t.Errorf("Expected the error and empty scores, got %q", rows[2])
+	}
+
+	buf.Reset()
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(decoded.Summaries) != 1 || decoded.Summaries[0].ScoringCostUSD != 0.002 || decoded.CostUSD != 0.012 {
+		t.Errorf("Unexpected summary %+v", decoded.Summaries)
+	}
+}
+
+// fakeRuns answers each run by its variant's model and style, keyed as
+// "model/style:case", at $0.01 a run.
+type fakeRuns struct {
+	answers  map[string]string
+	erroring map[string]bool
+
+	mu      sync.Mutex
+	options map[string][]claudecode.Option
+}
+
+func (f *fakeRuns) run(_ context.Context, spec claudecode.RunSpec) (*claudecode.RunResult, error) {
+	options := claudecode.NewOptions(spec.Options...)
+	key := runKey(options, spec.Prompt)
+
+	f.mu.Lock()
+	if f.options == nil {
+		f.options = make(map[string][]claudecode.Option)
+	}
+	f.options[key] = spec.Options
+	f.mu.Unlock()
+
+	if f.erroring[key] {
+		return nil, errors.New("cli crashed")
+	}
+	answer, ok := f.answers[key]
+	if !ok {
+		answer = "4"
+	}
+	return &claudecode.RunResult{Text: answer, Success: true, CostUSD: 0.01}, nil
+}
+
+func runKey(options *claudecode.Options, prompt string) string {
+	model, style := "", "long"
+	if options.Model != nil {
+		model = *options.Model
+	}
+	if options.AppendSystemPrompt != nil {
+		style = "terse"
+	}
+	name := "math"
+	if strings.Contains(prompt, "capital") {
+		name = "capital"
+	}
+	return model + "/" + style + ":" + name
+}
@@ -0,0 +1,121 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// DefaultPassScore is the score a Judge requires to pass an answer when
+// Judge.PassScore is not set.
+const DefaultPassScore = 0.5
+
+// Judge scores answers by asking a second agent to grade them against a
+// rubric, the LLM-as-judge approach. Its Score method is a ScoreFunc.
+type Judge struct {
+	// Rubric tells the judge what a good answer is.
+	Rubric string
+	// Options configure the judge's queries, typically its model. The
+	// judge is restricted to read-only tools after them.
+	Options []claudecode.Option
+	// PassScore is the lowest passing score; the default is
+	// DefaultPassScore.
+	PassScore float64
+	// Runner runs the judge's queries; the default is claudecode.Run.
+	Runner RunFunc
+}
+
+// verdict is the judge's answer.
+type verdict struct {
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+// verdictSchema is the JSON Schema of the judge's answer.
+func verdictSchema() map[string]any {
+	return map[string]any{
+		"type":     "object",
+		"required": []any{"score", "reason"},
+		"properties": map[string]any{
+			"score":  map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+			"reason": map[string]any{"type": "string"},
+		},
+	}
+}
+
+// Score asks the judge to grade the answer to c, scoring it from 0 to 1.
+// The judge's cost is reported in the score, also when grading fails.
+func (j *Judge) Score(ctx context.Context, c Case, result *claudecode.RunResult) (Score, error) {
+	runner := j.Runner
+	if runner == nil {
+		runner = claudecode.Run
+	}
+	options := append(append([]claudecode.Option(nil), j.Options...),
+		claudecode.WithJSONSchema(verdictSchema()), claudecode.WithReadOnly())
+	judged, err := runner(ctx, claudecode.RunSpec{Prompt: j.prompt(c, result), Options: options})
+	if err != nil {
+		return Score{}, fmt.Errorf("judge: %w", err)
+	}
+	score := Score{CostUSD: judged.CostUSD}
+	if !judged.Success {
+		return score, fmt.Errorf("judge run failed")
+	}
+
+	var v verdict
+	if err := decodeVerdict(judged, &v); err != nil {
+		return score, fmt.Errorf("judge: %w", err)
+	}
+	if v.Score < 0 || v.Score > 1 {
+		return score, fmt.Errorf("judge score %v is outside 0 to 1", v.Score)
+	}
+	passScore := j.PassScore
+	if passScore == 0 {
+		passScore = DefaultPassScore
+	}
+	score.Value = v.Score
+	score.Pass = v.Score >= passScore
+	score.Reason = v.Reason
+	return score, nil
+}
+
+// prompt asks the judge to grade the answer.
+func (j *Judge) prompt(c Case, result *claudecode.RunResult) string {
+	var sb strings.Builder
+	sb.WriteString("You are grading an AI assistant's answer. Do not answer the question yourself.\n\n")
+	fmt.Fprintf(&sb, "Question:\n%s\n\n", c.Prompt)
+	if c.Expected != "" {
+		fmt.Fprintf(&sb, "Reference answer:\n%s\n\n", c.Expected)
+	}
+	fmt.Fprintf(&sb, "Answer to grade:\n%s\n\n", result.Text)
+	if j.Rubric != "" {
+		fmt.Fprintf(&sb, "Rubric:\n%s\n\n", j.Rubric)
+	}
+	sb.WriteString(`Reply with only a JSON object {"score": <0 to 1>, "reason": "<one sentence>"}.`)
+	return sb.String()
+}
+
+// decodeVerdict reads the verdict from the structured output, or from the
+// result text, which may be wrapped in a Markdown code fence.
+func decodeVerdict(result *claudecode.RunResult, v *verdict) error {
+	var data []byte
+	if result.Result != nil && result.Result.StructuredOutput != nil {
+		encoded, err := json.Marshal(result.Result.StructuredOutput)
+		if err != nil {
+			return err
+		}
+		data = encoded
+	} else {
+		text := strings.TrimSpace(result.Text)
+		if start, end := strings.Index(text, "{"), strings.LastIndex(text, "}"); start >= 0 && end > start {
+			text = text[start : end+1]
+		}
+		data = []byte(text)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decoding verdict: %w", err)
+	}
+	return nil
+}
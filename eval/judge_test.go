@@ -0,0 +1,80 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestJudgeScore(t *testing.T) {
+	var prompt string
+	var options *claudecode.Options
+	judge := &Judge{
+		Rubric:  "Correct and concise",
+		Options: []claudecode.Option{claudecode.WithModel("haiku")},
+		Runner: func(_ context.Context, spec claudecode.RunSpec) (*claudecode.RunResult, error) {
+			prompt = spec.Prompt
+			options = claudecode.NewOptions(spec.Options...)
+			return &claudecode.RunResult{
+				Success: true,
+				CostUSD: 0.003,
+				Result:  &claudecode.ResultMessage{StructuredOutput: map[string]any{"score": 0.75, "reason": "Correct but wordy"}},
+			}, nil
+		},
+	}
+	c := Case{Name: "capital", Prompt: "What is the capital of France?", Expected: "Paris"}
+	score, err := judge.Score(context.Background(), c, &claudecode.RunResult{Text: "It is Paris, a lovely city."})
+	if err != nil {
+		t.Fatalf("Score failed: %v", err)
+	}
+	if score.Value != 0.75 || !score.Pass || score.Reason != "Correct but wordy" || score.CostUSD != 0.003 {
+		t.Errorf("Unexpected score %+v", score)
+	}
+	for _, fragment := range []string{"What is the capital of France?", "Reference answer:\nParis", "It is Paris", "Correct and concise"} {
+		if !strings.Contains(prompt, fragment) {
+			t.Errorf("Expected the judge prompt to contain %q", fragment)
+		}
+	}
+	if options.Model == nil || *options.Model != "haiku" || options.OutputFormat == nil {
+		t.Errorf("Expected the judge's options and a schema, got %+v", options)
+	}
+}
+
+func TestJudgeScoreText(t *testing.T) {
+	reply := func(text string, success bool) RunFunc {
+		return func(context.Context, claudecode.RunSpec) (*claudecode.RunResult, error) {
+			return &claudecode.RunResult{Text: text, Success: success, CostUSD: 0.001}, nil
+		}
+	}
+	ctx := context.Background()
+
+	judge := &Judge{PassScore: 0.9, Runner: reply("```json\n{\"score\": 0.8, \"reason\": \"ok\"}\n```", true)}
+	if score, err := judge.Score(ctx, Case{}, &claudecode.RunResult{}); err != nil || score.Pass || score.Value != 0.8 {
+		t.Errorf("Expected a fenced verdict below the pass score, got %+v, %v", score, err)
+	}
+
+	judge.Runner = reply(`{"score": 7}`, true)
+	if _, err := judge.Score(ctx, Case{}, &claudecode.RunResult{}); err == nil {
+		t.Error("Expected an error for a score out of range")
+	}
+
+	judge.Runner = reply("no verdict", true)
+	if score, err := judge.Score(ctx, Case{}, &claudecode.RunResult{}); err == nil || score.CostUSD != 0.001 {
+		t.Errorf("Expected an error reporting the judge's cost, got %+v, %v", score, err)
+	}
+
+	judge.Runner = reply("", false)
+	if _, err := judge.Score(ctx, Case{}, &claudecode.RunResult{}); err == nil {
+		t.Error("Expected an error for a failed judge run")
+	}
+
+	judge.Runner = func(context.Context, claudecode.RunSpec) (*claudecode.RunResult, error) {
+		return nil, errors.New("cli missing")
+	}
+	if _, err := judge.Score(ctx, Case{}, &claudecode.RunResult{}); err == nil {
+		t.Error("Expected the run error")
+	}
+}
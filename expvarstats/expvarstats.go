@@ -0,0 +1,29 @@
+// Package expvarstats publishes the agent clients' ProcessStats through
+// expvar, so the /debug/vars endpoint reports them without OpenTelemetry
+// or Prometheus:
+//
+//	expvarstats.Publish(expvarstats.DefaultName)
+//	go http.ListenAndServe("localhost:6060", nil)
+//
+// It lives apart from the claudecode package because importing expvar
+// registers /debug/vars on http.DefaultServeMux.
+package expvarstats
+
+import (
+	"expvar"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// DefaultName is the expvar name the stats are conventionally published
+// under.
+const DefaultName = "claudecode"
+
+// Publish publishes claudecode.ReadProcessStats under name, read afresh
+// each time the variables are served. Like expvar.Publish, it panics if
+// name is already in use.
+func Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return claudecode.ReadProcessStats()
+	}))
+}
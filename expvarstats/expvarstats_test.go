@@ -0,0 +1,25 @@
+package expvarstats
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestPublish(t *testing.T) {
+	Publish("claudecode_test")
+
+	v := expvar.Get("claudecode_test")
+	if v == nil {
+		t.Fatal("Expected the stats to be published")
+	}
+	var stats claudecode.ProcessStats
+	if err := json.Unmarshal([]byte(v.String()), &stats); err != nil {
+		t.Fatalf("Expected the stats as JSON, got %q: %v", v.String(), err)
+	}
+	if stats != claudecode.ReadProcessStats() {
+		t.Errorf("Expected %+v, got %+v", claudecode.ReadProcessStats(), stats)
+	}
+}
@@ -0,0 +1,338 @@
+// Package gitops checkpoints an agent's work in git, as a safety net that
+// does not depend on the CLI's own file checkpoints.
+//
+// A Checkpointer moves the repository onto a working branch before the
+// session starts and commits everything after each turn, with the turn's
+// summary and cost in the commit message. RollbackToTurn resets the
+// working tree to the state after an earlier turn:
+//
+//	checkpoints := gitops.New(repoDir)
+//	client := claudecode.NewClient(
+//		claudecode.WithCwd(repoDir),
+//		gitops.WithGitCheckpointing(checkpoints),
+//	)
+//	// ... run turns ...
+//	if err := checkpoints.RollbackToTurn(ctx, 1); err != nil {
+//		return err
+//	}
+//
+// Checkpointing runs the git command in the repository, so git must be on
+// PATH. Uncommitted changes present when the session starts are committed
+// first, as turn 0, so rolling back to turn 0 restores them.
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// DefaultBranchPrefix starts the name of generated working branches.
+const DefaultBranchPrefix = "claude/session-"
+
+// maxResultBytes bounds the turn result quoted in a commit message.
+const maxResultBytes = 2000
+
+// ErrNoCheckpoint is returned by RollbackToTurn for a turn without a
+// checkpoint: one that has not happened, was rolled back, or was reverted
+// because it failed.
+var ErrNoCheckpoint = errors.New("no checkpoint for turn")
+
+// Checkpoint is the commit recording the state after a turn.
+type Checkpoint struct {
+	// Turn counts the turns of the session; 0 is its start.
+	Turn    int
+	Commit  string
+	Summary string
+}
+
+// Option configures a Checkpointer.
+type Option func(*Checkpointer)
+
+// WithBranch names the working branch. By default it is
+// DefaultBranchPrefix followed by the session's start time.
+func WithBranch(name string) Option {
+	return func(c *Checkpointer) {
+		c.branch = name
+	}
+}
+
+// WithAuthor sets the author of checkpoint commits, overriding the
+// repository's user.name and user.email.
+func WithAuthor(name, email string) Option {
+	return func(c *Checkpointer) {
+		c.author = []string{"-c", "user.name=" + name, "-c", "user.email=" + email}
+	}
+}
+
+// WithRevertOnFailure resets the working tree to the last checkpoint when
+// a turn ends in an error, instead of committing its changes.
+func WithRevertOnFailure() Option {
+	return func(c *Checkpointer) {
+		c.revertOnFailure = true
+	}
+}
+
+// Checkpointer commits a repository after each turn of a session. It is
+// safe for concurrent use.
+type Checkpointer struct {
+	repoPath        string
+	branch          string
+	author          []string
+	revertOnFailure bool
+	now             func() time.Time
+
+	mu          sync.Mutex
+	started     bool
+	baseBranch  string
+	turn        int
+	checkpoints []Checkpoint
+	err         error
+}
+
+// New returns a Checkpointer for the git repository at repoPath. Nothing
+// is changed until the session starts or Begin is called.
+func New(repoPath string, opts ...Option) *Checkpointer {
+	c := &Checkpointer{repoPath: repoPath, now: time.Now}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithGitCheckpointing checkpoints the client's session with c. The working
+// branch is created before the first prompt is sent, and a prompt is
+// rejected if that fails. Each ResultMessage is committed before it is
+// delivered, so the checkpoint exists by the time the caller sees the turn
+// end; errors committing are reported by Err.
+func WithGitCheckpointing(c *Checkpointer) claudecode.Option {
+	return func(o *claudecode.Options) {
+		claudecode.WithPromptInterceptor(func(ctx context.Context, _ *claudecode.UserMessage) error {
+			return c.Begin(ctx)
+		})(o)
+		claudecode.WithMessageObserver(c.observe)(o)
+	}
+}
+
+// observe checkpoints each turn result. Prompts passed to Connect skip the
+// prompt interceptors, so the session may also start here.
+func (c *Checkpointer) observe(msg claudecode.Message) {
+	ctx := context.Background()
+	err := c.Begin(ctx)
+	if result, ok := msg.(*claudecode.ResultMessage); ok && err == nil {
+		err = c.Checkpoint(ctx, result)
+	}
+	if err != nil {
+		c.mu.Lock()
+		if c.err == nil {
+			c.err = err
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Err returns the first error from automatic checkpointing, if any.
+func (c *Checkpointer) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Begin switches the repository to the working branch and records turn 0,
+// committing any uncommitted changes. Later calls do nothing.
+func (c *Checkpointer) Begin(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return nil
+	}
+
+	if _, err := c.git(ctx, "rev-parse", "--git-dir"); err != nil {
+		return err
+	}
+	base, _ := c.git(ctx, "rev-parse", "--abbrev-ref", "HEAD")
+	branch := c.branch
+	if branch == "" {
+		branch = DefaultBranchPrefix + c.now().Format("20060102-150405")
+	}
+	if _, err := c.git(ctx, "checkout", "-b", branch); err != nil {
+		return err
+	}
+
+	// Turn 0 is the current commit, or a new one holding uncommitted work
+	status, err := c.git(ctx, "status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	_, headErr := c.git(ctx, "rev-parse", "--verify", "HEAD")
+	summary := "Session start"
+	if status != "" || headErr != nil {
+		summary = "Changes before the session"
+		if err := c.commit(ctx, "Checkpoint before session\n\nUncommitted changes present when the session started."); err != nil {
+			return err
+		}
+	}
+	head, err := c.git(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+
+	c.started = true
+	c.branch = branch
+	c.baseBranch = base
+	c.checkpoints = []Checkpoint{{Turn: 0, Commit: head, Summary: summary}}
+	return nil
+}
+
+// Checkpoint records the end of a turn. The working tree is committed with
+// a message summarising result, or, with WithRevertOnFailure and a failed
+// turn, reset to the last checkpoint.
+func (c *Checkpointer) Checkpoint(ctx context.Context, result *claudecode.ResultMessage) error {
+	if err := c.Begin(ctx); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.turn++
+	if result.IsError && c.revertOnFailure {
+		return c.reset(ctx, c.checkpoints[len(c.checkpoints)-1].Commit)
+	}
+
+	summary := resultSummary(result)
+	if err := c.commit(ctx, commitMessage(c.turn, summary, result)); err != nil {
+		return err
+	}
+	head, err := c.git(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	c.checkpoints = append(c.checkpoints, Checkpoint{Turn: c.turn, Commit: head, Summary: summary})
+	return nil
+}
+
+// RollbackToTurn resets the working tree and branch to the checkpoint of
+// turn n, discarding later commits and uncommitted changes, and removing
+// untracked files that are not ignored. Turns after n are forgotten.
+func (c *Checkpointer) RollbackToTurn(ctx context.Context, n int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, checkpoint := range c.checkpoints {
+		if checkpoint.Turn != n {
+			continue
+		}
+		if err := c.reset(ctx, checkpoint.Commit); err != nil {
+			return err
+		}
+		c.checkpoints = c.checkpoints[:i+1]
+		c.turn = n
+		return nil
+	}
+	return fmt.Errorf("%w %d", ErrNoCheckpoint, n)
+}
+
+// Checkpoints returns the checkpoints of the session, oldest first.
+func (c *Checkpointer) Checkpoints() []Checkpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Checkpoint(nil), c.checkpoints...)
+}
+
+// Branch returns the working branch, once the session has started.
+func (c *Checkpointer) Branch() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.started {
+		return ""
+	}
+	return c.branch
+}
+
+// BaseBranch returns the branch checked out when the session started, or
+// "HEAD" if none was.
+func (c *Checkpointer) BaseBranch() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.baseBranch
+}
+
+func (c *Checkpointer) commit(ctx context.Context, message string) error {
+	if _, err := c.git(ctx, "add", "-A"); err != nil {
+		return err
+	}
+	// Empty commits keep one checkpoint per turn
+	_, err := c.git(ctx, "commit", "--allow-empty", "--no-verify", "-q", "-m", message)
+	return err
+}
+
+func (c *Checkpointer) reset(ctx context.Context, commit string) error {
+	if _, err := c.git(ctx, "reset", "-q", "--hard", commit); err != nil {
+		return err
+	}
+	_, err := c.git(ctx, "clean", "-q", "-fd")
+	return err
+}
+
+// git runs a git command in the repository and returns its trimmed output.
+func (c *Checkpointer) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append(append([]string(nil), c.author...), args...)...)
+	cmd.Dir = c.repoPath
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// resultSummary returns the first line of the turn's result, shortened to
+// fit a commit subject, or its subtype when there is no result text.
+func resultSummary(result *claudecode.ResultMessage) string {
+	text := ""
+	if result.Result != nil {
+		text = strings.TrimSpace(*result.Result)
+	}
+	if line, _, _ := strings.Cut(text, "\n"); line != "" {
+		if runes := []rune(line); len(runes) > 60 {
+			line = string(runes[:57]) + "..."
+		}
+		return line
+	}
+	return result.Subtype
+}
+
+// commitMessage describes a turn: its summary, result text and cost.
+func commitMessage(turn int, summary string, result *claudecode.ResultMessage) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Turn %d: %s\n", turn, summary)
+	if result.Result != nil {
+		if text := strings.TrimSpace(*result.Result); text != "" {
+			if len(text) > maxResultBytes {
+				text = strings.ToValidUTF8(text[:maxResultBytes], "") + "\n..."
+			}
+			fmt.Fprintf(&b, "\n%s\n", text)
+		}
+	}
+
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "Result: %s\n", result.Subtype)
+	if result.TotalCostUSD != nil {
+		fmt.Fprintf(&b, "Cost: $%.4f\n", *result.TotalCostUSD)
+	}
+	if result.DurationMs > 0 {
+		fmt.Fprintf(&b, "Duration: %s\n", (time.Duration(result.DurationMs) * time.Millisecond).Round(100*time.Millisecond))
+	}
+	if result.SessionID != "" {
+		fmt.Fprintf(&b, "Session: %s\n", result.SessionID)
+	}
+	return b.String()
+}
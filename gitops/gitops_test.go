@@ -0,0 +1,226 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+	"github.com/severity1/claude-code-sdk-go/internal/testfs"
+)
+
+func TestCheckpointAndRollback(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	testfs.Write(t, repo, "main.go", "package main\n")
+	runGit(t, repo, "add", "-A")
+	runGit(t, repo, "commit", "-q", "-m", "initial")
+
+	checkpoints := New(repo, WithAuthor("Test", "test@example.com"))
+	checkpoints.now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+	if err := checkpoints.Begin(ctx); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if branch := currentBranch(t, repo); branch != "claude/session-20250102-030405" || checkpoints.Branch() != branch {
+		t.Errorf("Expected the working branch, got %q", branch)
+	}
+	if checkpoints.BaseBranch() != "main" {
+		t.Errorf("Expected base branch main, got %q", checkpoints.BaseBranch())
+	}
+
+	testfs.Write(t, repo, "main.go", "package main\n\nfunc main() {}\n")
+	if err := checkpoints.Checkpoint(ctx, result("Added a main function.\nDetails follow.", false)); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	message := runGit(t, repo, "log", "-1", "--format=%B")
+	for _, want := range []string{"Turn 1: Added a main function.", "Result: success", "Cost: $0.0125", "Duration: 2.5s", "Session: session-1"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("Expected commit message to contain %q, got:\n%s", want, message)
+		}
+	}
+
+	testfs.Write(t, repo, "extra.go", "package main\n")
+	if err := checkpoints.Checkpoint(ctx, result("Added extra.go", false)); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	testfs.Write(t, repo, "scratch.txt", "uncommitted")
+
+	if err := checkpoints.RollbackToTurn(ctx, 1); err != nil {
+		t.Fatalf("RollbackToTurn failed: %v", err)
+	}
+	if testfs.Exists(repo, "extra.go") || testfs.Exists(repo, "scratch.txt") {
+		t.Error("Expected files from later turns to be removed")
+	}
+	if got := testfs.Read(t, repo, "main.go"); got != "package main\n\nfunc main() {}\n" {
+		t.Errorf("Expected main.go after turn 1, got %q", got)
+	}
+	if got := checkpoints.Checkpoints(); len(got) != 2 || got[1].Summary != "Added a main function." {
+		t.Errorf("Expected checkpoints up to turn 1, got %+v", got)
+	}
+
+	if err := checkpoints.RollbackToTurn(ctx, 2); !errors.Is(err, ErrNoCheckpoint) {
+		t.Errorf("Expected ErrNoCheckpoint for a forgotten turn, got %v", err)
+	}
+	if err := checkpoints.RollbackToTurn(ctx, 0); err != nil {
+		t.Fatalf("RollbackToTurn(0) failed: %v", err)
+	}
+	if got := testfs.Read(t, repo, "main.go"); got != "package main\n" {
+		t.Errorf("Expected the original main.go, got %q", got)
+	}
+}
+
+func TestBeginCommitsUncommittedWork(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	testfs.Write(t, repo, "draft.txt", "work in progress")
+
+	checkpoints := New(repo, WithAuthor("Test", "test@example.com"), WithBranch("agent-work"))
+	if err := checkpoints.Begin(ctx); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if currentBranch(t, repo) != "agent-work" {
+		t.Errorf("Expected the named branch, got %q", currentBranch(t, repo))
+	}
+	if got := checkpoints.Checkpoints(); len(got) != 1 || got[0].Summary != "Changes before the session" {
+		t.Fatalf("Expected a turn 0 checkpoint holding the draft, got %+v", got)
+	}
+
+	testfs.Write(t, repo, "draft.txt", "overwritten by the agent")
+	if err := checkpoints.RollbackToTurn(ctx, 0); err != nil {
+		t.Fatalf("RollbackToTurn failed: %v", err)
+	}
+	if got := testfs.Read(t, repo, "draft.txt"); got != "work in progress" {
+		t.Errorf("Expected the draft restored, got %q", got)
+	}
+}
+
+func TestRevertOnFailure(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	testfs.Write(t, repo, "a.txt", "a")
+	checkpoints := New(repo, WithAuthor("Test", "test@example.com"), WithRevertOnFailure())
+
+	testfs.Write(t, repo, "half-done.txt", "broken")
+	if err := checkpoints.Begin(ctx); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	testfs.Write(t, repo, "a.txt", "broken edit")
+	if err := checkpoints.Checkpoint(ctx, result("Ran out of turns", true)); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if got := testfs.Read(t, repo, "a.txt"); got != "a" {
+		t.Errorf("Expected the failed turn reverted, got %q", got)
+	}
+
+	// The failed turn counts but has no checkpoint
+	testfs.Write(t, repo, "a.txt", "fixed")
+	if err := checkpoints.Checkpoint(ctx, result("Fixed", false)); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	got := checkpoints.Checkpoints()
+	if len(got) != 2 || got[1].Turn != 2 {
+		t.Errorf("Expected checkpoints for turns 0 and 2, got %+v", got)
+	}
+	if err := checkpoints.RollbackToTurn(ctx, 1); !errors.Is(err, ErrNoCheckpoint) {
+		t.Errorf("Expected ErrNoCheckpoint for the reverted turn, got %v", err)
+	}
+}
+
+func TestWithGitCheckpointing(t *testing.T) {
+	repo := newTestRepo(t)
+	checkpoints := New(repo, WithAuthor("Test", "test@example.com"))
+	options := claudecode.NewOptions(WithGitCheckpointing(checkpoints))
+
+	if len(options.PromptInterceptors) != 1 || len(options.MessageObservers) != 1 {
+		t.Fatalf("Expected a prompt interceptor and a message observer, got %d and %d",
+			len(options.PromptInterceptors), len(options.MessageObservers))
+	}
+	if err := options.PromptInterceptors[0](context.Background(), &claudecode.UserMessage{Content: "go"}); err != nil {
+		t.Fatalf("Interceptor failed: %v", err)
+	}
+	if checkpoints.Branch() == "" {
+		t.Error("Expected the session to start before the first prompt")
+	}
+
+	testfs.Write(t, repo, "out.txt", "result")
+	options.MessageObservers[0](&claudecode.AssistantMessage{Model: "m"})
+	options.MessageObservers[0](result("Wrote out.txt", false))
+	if err := checkpoints.Err(); err != nil {
+		t.Fatalf("Unexpected checkpointing error: %v", err)
+	}
+	if got := checkpoints.Checkpoints(); len(got) != 2 || got[1].Summary != "Wrote out.txt" {
+		t.Errorf("Expected a checkpoint for the result, got %+v", got)
+	}
+
+	// Errors are kept for Err
+	broken := New(t.TempDir())
+	claudecode.NewOptions(WithGitCheckpointing(broken)).MessageObservers[0](result("x", false))
+	if broken.Err() == nil {
+		t.Error("Expected an error outside a repository")
+	}
+}
+
+func TestResultSummary(t *testing.T) {
+	long := strings.Repeat("é", 70)
+	tests := []struct {
+		name   string
+		result *claudecode.ResultMessage
+		want   string
+	}{
+		{"first_line", result("Done.\nMore detail", false), "Done."},
+		{"shortened", result(long, false), strings.Repeat("é", 57) + "..."},
+		{"no_text", &claudecode.ResultMessage{Subtype: "error_max_turns"}, "error_max_turns"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := resultSummary(test.result); got != test.want {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	repo := t.TempDir()
+	runGit(t, repo, "init", "-q", "-b", "main")
+	runGit(t, repo, "config", "user.name", "Test")
+	runGit(t, repo, "config", "user.email", "test@example.com")
+	runGit(t, repo, "config", "commit.gpgsign", "false")
+	return repo
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+func currentBranch(t *testing.T, repo string) string {
+	t.Helper()
+	return strings.TrimSpace(runGit(t, repo, "rev-parse", "--abbrev-ref", "HEAD"))
+}
+
+func result(text string, isError bool) *claudecode.ResultMessage {
+	cost := 0.0125
+	subtype := "success"
+	if isError {
+		subtype = "error_during_execution"
+	}
+	return &claudecode.ResultMessage{
+		Subtype: subtype, IsError: isError, Result: &text, TotalCostUSD: &cost,
+		DurationMs: 2500, SessionID: "session-1",
+	}
+}
@@ -0,0 +1,747 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: claudecode/v1/bridge.proto
+
+package claudecodev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type QueryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Prompt        string                 `protobuf:"bytes,1,opt,name=prompt,proto3" json:"prompt,omitempty"`
+	SessionId     string                 `protobuf:"bytes,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryRequest) Reset() {
+	*x = QueryRequest{}
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryRequest) ProtoMessage() {}
+
+func (x *QueryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryRequest.ProtoReflect.Descriptor instead.
+func (*QueryRequest) Descriptor() ([]byte, []int) {
+	return file_claudecode_v1_bridge_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *QueryRequest) GetPrompt() string {
+	if x != nil {
+		return x.Prompt
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*Event_Delta
+	//	*Event_Thinking
+	//	*Event_ToolUse
+	//	*Event_Result
+	//	*Event_Error
+	Payload       isEvent_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_claudecode_v1_bridge_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Event) GetPayload() isEvent_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Event) GetDelta() *TextEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Delta); ok {
+			return x.Delta
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetThinking() *TextEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Thinking); ok {
+			return x.Thinking
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetToolUse() *ToolUseEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_ToolUse); ok {
+			return x.ToolUse
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetResult() *ResultEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetError() *ErrorEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+type isEvent_Payload interface {
+	isEvent_Payload()
+}
+
+type Event_Delta struct {
+	Delta *TextEvent `protobuf:"bytes,1,opt,name=delta,proto3,oneof"`
+}
+
+type Event_Thinking struct {
+	Thinking *TextEvent `protobuf:"bytes,2,opt,name=thinking,proto3,oneof"`
+}
+
+type Event_ToolUse struct {
+	ToolUse *ToolUseEvent `protobuf:"bytes,3,opt,name=tool_use,json=toolUse,proto3,oneof"`
+}
+
+type Event_Result struct {
+	Result *ResultEvent `protobuf:"bytes,4,opt,name=result,proto3,oneof"`
+}
+
+type Event_Error struct {
+	Error *ErrorEvent `protobuf:"bytes,5,opt,name=error,proto3,oneof"`
+}
+
+func (*Event_Delta) isEvent_Payload() {}
+
+func (*Event_Thinking) isEvent_Payload() {}
+
+func (*Event_ToolUse) isEvent_Payload() {}
+
+func (*Event_Result) isEvent_Payload() {}
+
+func (*Event_Error) isEvent_Payload() {}
+
+type TextEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TextEvent) Reset() {
+	*x = TextEvent{}
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TextEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TextEvent) ProtoMessage() {}
+
+func (x *TextEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TextEvent.ProtoReflect.Descriptor instead.
+func (*TextEvent) Descriptor() ([]byte, []int) {
+	return file_claudecode_v1_bridge_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TextEvent) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type ToolUseEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// JSON-encoded tool input.
+	InputJson     string `protobuf:"bytes,3,opt,name=input_json,json=inputJson,proto3" json:"input_json,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ToolUseEvent) Reset() {
+	*x = ToolUseEvent{}
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ToolUseEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ToolUseEvent) ProtoMessage() {}
+
+func (x *ToolUseEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ToolUseEvent.ProtoReflect.Descriptor instead.
+func (*ToolUseEvent) Descriptor() ([]byte, []int) {
+	return file_claudecode_v1_bridge_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ToolUseEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ToolUseEvent) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ToolUseEvent) GetInputJson() string {
+	if x != nil {
+		return x.InputJson
+	}
+	return ""
+}
+
+type ResultEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	IsError       bool                   `protobuf:"varint,2,opt,name=is_error,json=isError,proto3" json:"is_error,omitempty"`
+	NumTurns      int32                  `protobuf:"varint,3,opt,name=num_turns,json=numTurns,proto3" json:"num_turns,omitempty"`
+	DurationMs    int32                  `protobuf:"varint,4,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	TotalCostUsd  *float64               `protobuf:"fixed64,5,opt,name=total_cost_usd,json=totalCostUsd,proto3,oneof" json:"total_cost_usd,omitempty"`
+	Result        *string                `protobuf:"bytes,6,opt,name=result,proto3,oneof" json:"result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResultEvent) Reset() {
+	*x = ResultEvent{}
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResultEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResultEvent) ProtoMessage() {}
+
+func (x *ResultEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResultEvent.ProtoReflect.Descriptor instead.
+func (*ResultEvent) Descriptor() ([]byte, []int) {
+	return file_claudecode_v1_bridge_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ResultEvent) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *ResultEvent) GetIsError() bool {
+	if x != nil {
+		return x.IsError
+	}
+	return false
+}
+
+func (x *ResultEvent) GetNumTurns() int32 {
+	if x != nil {
+		return x.NumTurns
+	}
+	return 0
+}
+
+func (x *ResultEvent) GetDurationMs() int32 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *ResultEvent) GetTotalCostUsd() float64 {
+	if x != nil && x.TotalCostUsd != nil {
+		return *x.TotalCostUsd
+	}
+	return 0
+}
+
+func (x *ResultEvent) GetResult() string {
+	if x != nil && x.Result != nil {
+		return *x.Result
+	}
+	return ""
+}
+
+type ErrorEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ErrorEvent) Reset() {
+	*x = ErrorEvent{}
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ErrorEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorEvent) ProtoMessage() {}
+
+func (x *ErrorEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorEvent.ProtoReflect.Descriptor instead.
+func (*ErrorEvent) Descriptor() ([]byte, []int) {
+	return file_claudecode_v1_bridge_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ErrorEvent) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type InterruptRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InterruptRequest) Reset() {
+	*x = InterruptRequest{}
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InterruptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InterruptRequest) ProtoMessage() {}
+
+func (x *InterruptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InterruptRequest.ProtoReflect.Descriptor instead.
+func (*InterruptRequest) Descriptor() ([]byte, []int) {
+	return file_claudecode_v1_bridge_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *InterruptRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type InterruptResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// False when the session had no turn in flight.
+	Interrupted   bool `protobuf:"varint,1,opt,name=interrupted,proto3" json:"interrupted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InterruptResponse) Reset() {
+	*x = InterruptResponse{}
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InterruptResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InterruptResponse) ProtoMessage() {}
+
+func (x *InterruptResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InterruptResponse.ProtoReflect.Descriptor instead.
+func (*InterruptResponse) Descriptor() ([]byte, []int) {
+	return file_claudecode_v1_bridge_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *InterruptResponse) GetInterrupted() bool {
+	if x != nil {
+		return x.Interrupted
+	}
+	return false
+}
+
+type SetModelRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId string                 `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// An empty model restores the pool's default model.
+	Model         string `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetModelRequest) Reset() {
+	*x = SetModelRequest{}
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetModelRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetModelRequest) ProtoMessage() {}
+
+func (x *SetModelRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetModelRequest.ProtoReflect.Descriptor instead.
+func (*SetModelRequest) Descriptor() ([]byte, []int) {
+	return file_claudecode_v1_bridge_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SetModelRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *SetModelRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+type SetModelResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetModelResponse) Reset() {
+	*x = SetModelResponse{}
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetModelResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetModelResponse) ProtoMessage() {}
+
+func (x *SetModelResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_claudecode_v1_bridge_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetModelResponse.ProtoReflect.Descriptor instead.
+func (*SetModelResponse) Descriptor() ([]byte, []int) {
+	return file_claudecode_v1_bridge_proto_rawDescGZIP(), []int{9}
+}
+
+var File_claudecode_v1_bridge_proto protoreflect.FileDescriptor
+
+const file_claudecode_v1_bridge_proto_rawDesc = "" +
+	"\n" +
+	"\x1aclaudecode/v1/bridge.proto\x12\rclaudecode.v1\"E\n" +
+	"\fQueryRequest\x12\x16\n" +
+	"\x06prompt\x18\x01 \x01(\tR\x06prompt\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\tR\tsessionId\"\x9f\x02\n" +
+	"\x05Event\x120\n" +
+	"\x05delta\x18\x01 \x01(\v2\x18.claudecode.v1.TextEventH\x00R\x05delta\x126\n" +
+	"\bthinking\x18\x02 \x01(\v2\x18.claudecode.v1.TextEventH\x00R\bthinking\x128\n" +
+	"\btool_use\x18\x03 \x01(\v2\x1b.claudecode.v1.ToolUseEventH\x00R\atoolUse\x124\n" +
+	"\x06result\x18\x04 \x01(\v2\x1a.claudecode.v1.ResultEventH\x00R\x06result\x121\n" +
+	"\x05error\x18\x05 \x01(\v2\x19.claudecode.v1.ErrorEventH\x00R\x05errorB\t\n" +
+	"\apayload\"\x1f\n" +
+	"\tTextEvent\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\"Q\n" +
+	"\fToolUseEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"input_json\x18\x03 \x01(\tR\tinputJson\"\xeb\x01\n" +
+	"\vResultEvent\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x19\n" +
+	"\bis_error\x18\x02 \x01(\bR\aisError\x12\x1b\n" +
+	"\tnum_turns\x18\x03 \x01(\x05R\bnumTurns\x12\x1f\n" +
+	"\vduration_ms\x18\x04 \x01(\x05R\n" +
+	"durationMs\x12)\n" +
+	"\x0etotal_cost_usd\x18\x05 \x01(\x01H\x00R\ftotalCostUsd\x88\x01\x01\x12\x1b\n" +
+	"\x06result\x18\x06 \x01(\tH\x01R\x06result\x88\x01\x01B\x11\n" +
+	"\x0f_total_cost_usdB\t\n" +
+	"\a_result\"&\n" +
+	"\n" +
+	"ErrorEvent\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\"1\n" +
+	"\x10InterruptRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\"5\n" +
+	"\x11InterruptResponse\x12 \n" +
+	"\vinterrupted\x18\x01 \x01(\bR\vinterrupted\"F\n" +
+	"\x0fSetModelRequest\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\tR\tsessionId\x12\x14\n" +
+	"\x05model\x18\x02 \x01(\tR\x05model\"\x12\n" +
+	"\x10SetModelResponse2\xa8\x02\n" +
+	"\n" +
+	"ClaudeCode\x12<\n" +
+	"\x05Query\x12\x1b.claudecode.v1.QueryRequest\x1a\x14.claudecode.v1.Event0\x01\x12?\n" +
+	"\x06Stream\x12\x1b.claudecode.v1.QueryRequest\x1a\x14.claudecode.v1.Event(\x010\x01\x12N\n" +
+	"\tInterrupt\x12\x1f.claudecode.v1.InterruptRequest\x1a .claudecode.v1.InterruptResponse\x12K\n" +
+	"\bSetModel\x12\x1e.claudecode.v1.SetModelRequest\x1a\x1f.claudecode.v1.SetModelResponseBAZ?github.com/severity1/claude-code-sdk-go/grpcbridge/claudecodev1b\x06proto3"
+
+var (
+	file_claudecode_v1_bridge_proto_rawDescOnce sync.Once
+	file_claudecode_v1_bridge_proto_rawDescData []byte
+)
+
+func file_claudecode_v1_bridge_proto_rawDescGZIP() []byte {
+	file_claudecode_v1_bridge_proto_rawDescOnce.Do(func() {
+		file_claudecode_v1_bridge_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_claudecode_v1_bridge_proto_rawDesc), len(file_claudecode_v1_bridge_proto_rawDesc)))
+	})
+	return file_claudecode_v1_bridge_proto_rawDescData
+}
+
+var file_claudecode_v1_bridge_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_claudecode_v1_bridge_proto_goTypes = []any{
+	(*QueryRequest)(nil),      // 0: claudecode.v1.QueryRequest
+	(*Event)(nil),             // 1: claudecode.v1.Event
+	(*TextEvent)(nil),         // 2: claudecode.v1.TextEvent
+	(*ToolUseEvent)(nil),      // 3: claudecode.v1.ToolUseEvent
+	(*ResultEvent)(nil),       // 4: claudecode.v1.ResultEvent
+	(*ErrorEvent)(nil),        // 5: claudecode.v1.ErrorEvent
+	(*InterruptRequest)(nil),  // 6: claudecode.v1.InterruptRequest
+	(*InterruptResponse)(nil), // 7: claudecode.v1.InterruptResponse
+	(*SetModelRequest)(nil),   // 8: claudecode.v1.SetModelRequest
+	(*SetModelResponse)(nil),  // 9: claudecode.v1.SetModelResponse
+}
+var file_claudecode_v1_bridge_proto_depIdxs = []int32{
+	2, // 0: claudecode.v1.Event.delta:type_name -> claudecode.v1.TextEvent
+	2, // 1: claudecode.v1.Event.thinking:type_name -> claudecode.v1.TextEvent
+	3, // 2: claudecode.v1.Event.tool_use:type_name -> claudecode.v1.ToolUseEvent
+	4, // 3: claudecode.v1.Event.result:type_name -> claudecode.v1.ResultEvent
+	5, // 4: claudecode.v1.Event.error:type_name -> claudecode.v1.ErrorEvent
+	0, // 5: claudecode.v1.ClaudeCode.Query:input_type -> claudecode.v1.QueryRequest
+	0, // 6: claudecode.v1.ClaudeCode.Stream:input_type -> claudecode.v1.QueryRequest
+	6, // 7: claudecode.v1.ClaudeCode.Interrupt:input_type -> claudecode.v1.InterruptRequest
+	8, // 8: claudecode.v1.ClaudeCode.SetModel:input_type -> claudecode.v1.SetModelRequest
+	1, // 9: claudecode.v1.ClaudeCode.Query:output_type -> claudecode.v1.Event
+	1, // 10: claudecode.v1.ClaudeCode.Stream:output_type -> claudecode.v1.Event
+	7, // 11: claudecode.v1.ClaudeCode.Interrupt:output_type -> claudecode.v1.InterruptResponse
+	9, // 12: claudecode.v1.ClaudeCode.SetModel:output_type -> claudecode.v1.SetModelResponse
+	9, // [9:13] is the sub-list for method output_type
+	5, // [5:9] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_claudecode_v1_bridge_proto_init() }
+func file_claudecode_v1_bridge_proto_init() {
+	if File_claudecode_v1_bridge_proto != nil {
+		return
+	}
+	file_claudecode_v1_bridge_proto_msgTypes[1].OneofWrappers = []any{
+		(*Event_Delta)(nil),
+		(*Event_Thinking)(nil),
+		(*Event_ToolUse)(nil),
+		(*Event_Result)(nil),
+		(*Event_Error)(nil),
+	}
+	file_claudecode_v1_bridge_proto_msgTypes[4].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_claudecode_v1_bridge_proto_rawDesc), len(file_claudecode_v1_bridge_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_claudecode_v1_bridge_proto_goTypes,
+		DependencyIndexes: file_claudecode_v1_bridge_proto_depIdxs,
+		MessageInfos:      file_claudecode_v1_bridge_proto_msgTypes,
+	}.Build()
+	File_claudecode_v1_bridge_proto = out.File
+	file_claudecode_v1_bridge_proto_goTypes = nil
+	file_claudecode_v1_bridge_proto_depIdxs = nil
+}
@@ -0,0 +1,249 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: claudecode/v1/bridge.proto
+
+package claudecodev1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ClaudeCode_Query_FullMethodName     = "/claudecode.v1.ClaudeCode/Query"
+	ClaudeCode_Stream_FullMethodName    = "/claudecode.v1.ClaudeCode/Stream"
+	ClaudeCode_Interrupt_FullMethodName = "/claudecode.v1.ClaudeCode/Interrupt"
+	ClaudeCode_SetModel_FullMethodName  = "/claudecode.v1.ClaudeCode/SetModel"
+)
+
+// ClaudeCodeClient is the client API for ClaudeCode service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ClaudeCode exposes a pool of Claude Code CLI processes managed by a Go
+// runner. Turns for the same session_id are routed to the same CLI process.
+type ClaudeCodeClient interface {
+	// Query runs a single turn and streams its events until the result.
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	// Stream runs one turn per request on a long-lived stream. Turns run in
+	// the order they are received.
+	Stream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[QueryRequest, Event], error)
+	// Interrupt stops the turn in flight for a session.
+	Interrupt(ctx context.Context, in *InterruptRequest, opts ...grpc.CallOption) (*InterruptResponse, error)
+	// SetModel sets the model used for later turns of a session.
+	SetModel(ctx context.Context, in *SetModelRequest, opts ...grpc.CallOption) (*SetModelResponse, error)
+}
+
+type claudeCodeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewClaudeCodeClient(cc grpc.ClientConnInterface) ClaudeCodeClient {
+	return &claudeCodeClient{cc}
+}
+
+func (c *claudeCodeClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ClaudeCode_ServiceDesc.Streams[0], ClaudeCode_Query_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[QueryRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClaudeCode_QueryClient = grpc.ServerStreamingClient[Event]
+
+func (c *claudeCodeClient) Stream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[QueryRequest, Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ClaudeCode_ServiceDesc.Streams[1], ClaudeCode_Stream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[QueryRequest, Event]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClaudeCode_StreamClient = grpc.BidiStreamingClient[QueryRequest, Event]
+
+func (c *claudeCodeClient) Interrupt(ctx context.Context, in *InterruptRequest, opts ...grpc.CallOption) (*InterruptResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InterruptResponse)
+	err := c.cc.Invoke(ctx, ClaudeCode_Interrupt_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *claudeCodeClient) SetModel(ctx context.Context, in *SetModelRequest, opts ...grpc.CallOption) (*SetModelResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetModelResponse)
+	err := c.cc.Invoke(ctx, ClaudeCode_SetModel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ClaudeCodeServer is the server API for ClaudeCode service.
+// All implementations must embed UnimplementedClaudeCodeServer
+// for forward compatibility.
+//
+// ClaudeCode exposes a pool of Claude Code CLI processes managed by a Go
+// runner. Turns for the same session_id are routed to the same CLI process.
+type ClaudeCodeServer interface {
+	// Query runs a single turn and streams its events until the result.
+	Query(*QueryRequest, grpc.ServerStreamingServer[Event]) error
+	// Stream runs one turn per request on a long-lived stream. Turns run in
+	// the order they are received.
+	Stream(grpc.BidiStreamingServer[QueryRequest, Event]) error
+	// Interrupt stops the turn in flight for a session.
+	Interrupt(context.Context, *InterruptRequest) (*InterruptResponse, error)
+	// SetModel sets the model used for later turns of a session.
+	SetModel(context.Context, *SetModelRequest) (*SetModelResponse, error)
+	mustEmbedUnimplementedClaudeCodeServer()
+}
+
+// UnimplementedClaudeCodeServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedClaudeCodeServer struct{}
+
+func (UnimplementedClaudeCodeServer) Query(*QueryRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Error(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedClaudeCodeServer) Stream(grpc.BidiStreamingServer[QueryRequest, Event]) error {
+	return status.Error(codes.Unimplemented, "method Stream not implemented")
+}
+func (UnimplementedClaudeCodeServer) Interrupt(context.Context, *InterruptRequest) (*InterruptResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Interrupt not implemented")
+}
+func (UnimplementedClaudeCodeServer) SetModel(context.Context, *SetModelRequest) (*SetModelResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetModel not implemented")
+}
+func (UnimplementedClaudeCodeServer) mustEmbedUnimplementedClaudeCodeServer() {}
+func (UnimplementedClaudeCodeServer) testEmbeddedByValue()                    {}
+
+// UnsafeClaudeCodeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ClaudeCodeServer will
+// result in compilation errors.
+type UnsafeClaudeCodeServer interface {
+	mustEmbedUnimplementedClaudeCodeServer()
+}
+
+func RegisterClaudeCodeServer(s grpc.ServiceRegistrar, srv ClaudeCodeServer) {
+	// If the following call panics, it indicates UnimplementedClaudeCodeServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ClaudeCode_ServiceDesc, srv)
+}
+
+func _ClaudeCode_Query_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ClaudeCodeServer).Query(m, &grpc.GenericServerStream[QueryRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClaudeCode_QueryServer = grpc.ServerStreamingServer[Event]
+
+func _ClaudeCode_Stream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ClaudeCodeServer).Stream(&grpc.GenericServerStream[QueryRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ClaudeCode_StreamServer = grpc.BidiStreamingServer[QueryRequest, Event]
+
+func _ClaudeCode_Interrupt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InterruptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClaudeCodeServer).Interrupt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClaudeCode_Interrupt_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClaudeCodeServer).Interrupt(ctx, req.(*InterruptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ClaudeCode_SetModel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetModelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ClaudeCodeServer).SetModel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ClaudeCode_SetModel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ClaudeCodeServer).SetModel(ctx, req.(*SetModelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ClaudeCode_ServiceDesc is the grpc.ServiceDesc for ClaudeCode service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ClaudeCode_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "claudecode.v1.ClaudeCode",
+	HandlerType: (*ClaudeCodeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Interrupt",
+			Handler:    _ClaudeCode_Interrupt_Handler,
+		},
+		{
+			MethodName: "SetModel",
+			Handler:    _ClaudeCode_SetModel_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Query",
+			Handler:       _ClaudeCode_Query_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Stream",
+			Handler:       _ClaudeCode_Stream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "claudecode/v1/bridge.proto",
+}
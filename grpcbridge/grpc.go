@@ -0,0 +1,102 @@
+package grpcbridge
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/severity1/claude-code-sdk-go/grpcbridge/claudecodev1"
+)
+
+// Register serves srv as the ClaudeCode service on registrar, such as a
+// *grpc.Server.
+func Register(registrar grpc.ServiceRegistrar, srv *Server) {
+	pb.RegisterClaudeCodeServer(registrar, &service{srv: srv})
+}
+
+// service adapts Server to the generated ClaudeCodeServer interface.
+type service struct {
+	pb.UnimplementedClaudeCodeServer
+	srv *Server
+}
+
+func (s *service) Query(req *pb.QueryRequest, stream pb.ClaudeCode_QueryServer) error {
+	send := func(ev *Event) error { return stream.Send(toProtoEvent(ev)) }
+	return statusError(s.srv.Query(stream.Context(), fromProtoQuery(req), send))
+}
+
+func (s *service) Stream(stream pb.ClaudeCode_StreamServer) error {
+	recv := func() (*QueryRequest, error) {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return fromProtoQuery(req), nil
+	}
+	send := func(ev *Event) error { return stream.Send(toProtoEvent(ev)) }
+	return statusError(s.srv.Stream(stream.Context(), recv, send))
+}
+
+func (s *service) Interrupt(ctx context.Context, req *pb.InterruptRequest) (*pb.InterruptResponse, error) {
+	resp, err := s.srv.Interrupt(ctx, &InterruptRequest{SessionID: req.GetSessionId()})
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.InterruptResponse{Interrupted: resp.Interrupted}, nil
+}
+
+func (s *service) SetModel(ctx context.Context, req *pb.SetModelRequest) (*pb.SetModelResponse, error) {
+	if _, err := s.srv.SetModel(ctx, &SetModelRequest{SessionID: req.GetSessionId(), Model: req.GetModel()}); err != nil {
+		return nil, statusError(err)
+	}
+	return &pb.SetModelResponse{}, nil
+}
+
+// statusError maps Server errors to gRPC status codes.
+func statusError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrPromptRequired):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return err
+}
+
+func fromProtoQuery(req *pb.QueryRequest) *QueryRequest {
+	return &QueryRequest{Prompt: req.GetPrompt(), SessionID: req.GetSessionId()}
+}
+
+func toProtoEvent(ev *Event) *pb.Event {
+	switch {
+	case ev.Delta != nil:
+		return &pb.Event{Payload: &pb.Event_Delta{Delta: &pb.TextEvent{Text: ev.Delta.Text}}}
+	case ev.Thinking != nil:
+		return &pb.Event{Payload: &pb.Event_Thinking{Thinking: &pb.TextEvent{Text: ev.Thinking.Text}}}
+	case ev.ToolUse != nil:
+		return &pb.Event{Payload: &pb.Event_ToolUse{ToolUse: &pb.ToolUseEvent{
+			Id:        ev.ToolUse.ID,
+			Name:      ev.ToolUse.Name,
+			InputJson: ev.ToolUse.InputJSON,
+		}}}
+	case ev.Result != nil:
+		return &pb.Event{Payload: &pb.Event_Result{Result: &pb.ResultEvent{
+			SessionId:    ev.Result.SessionID,
+			IsError:      ev.Result.IsError,
+			NumTurns:     ev.Result.NumTurns,
+			DurationMs:   ev.Result.DurationMs,
+			TotalCostUsd: ev.Result.TotalCostUSD,
+			Result:       ev.Result.Result,
+		}}}
+	case ev.Error != nil:
+		return &pb.Event{Payload: &pb.Event_Error{Error: &pb.ErrorEvent{Message: ev.Error.Message}}}
+	}
+	return &pb.Event{}
+}
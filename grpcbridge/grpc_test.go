@@ -0,0 +1,90 @@
+package grpcbridge
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/severity1/claude-code-sdk-go/grpcbridge/claudecodev1"
+)
+
+func TestGRPCQuery(t *testing.T) {
+	ctx, cancel := setupServerTestContext(t, 5*time.Second)
+	defer cancel()
+
+	srv, _, _ := setupServerForTest(t)
+	client := dialGRPCForTest(t, srv)
+
+	stream, err := client.Query(ctx, &pb.QueryRequest{Prompt: "hello", SessionId: "alice"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var events []*pb.Event
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		events = append(events, ev)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	if got := events[0].GetDelta().GetText(); got != "echo: hello" {
+		t.Errorf("Expected delta event, got %v", events[0])
+	}
+	if got := events[1].GetToolUse().GetInputJson(); got != `{"path":"hello"}` {
+		t.Errorf("Expected tool_use event with JSON input, got %v", events[1])
+	}
+	if got := events[2].GetResult().GetSessionId(); got != "alice" {
+		t.Errorf("Expected result event for alice, got %v", events[2])
+	}
+}
+
+func TestGRPCQueryRequiresPrompt(t *testing.T) {
+	ctx, cancel := setupServerTestContext(t, 5*time.Second)
+	defer cancel()
+
+	srv, _, _ := setupServerForTest(t)
+	stream, err := dialGRPCForTest(t, srv).Query(ctx, &pb.QueryRequest{Prompt: " "})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
+
+// dialGRPCForTest serves srv over an in-memory connection and returns a
+// client for it.
+func dialGRPCForTest(t *testing.T, srv *Server) pb.ClaudeCodeClient {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	Register(server, srv)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return pb.NewClaudeCodeClient(conn)
+}
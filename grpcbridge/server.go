@@ -0,0 +1,267 @@
+// Package grpcbridge serves the ClaudeCode gRPC service defined in
+// proto/claudecode/v1/bridge.proto, so non-Go services can drive a central
+// Go-managed pool of Claude Code CLI processes.
+//
+// Server runs the service's turns with plain Go types that mirror the
+// protobuf messages, and Register serves it on a gRPC server:
+//
+//	pool := claudecode.NewClientPool(4)
+//	defer pool.Close()
+//
+//	s := grpc.NewServer()
+//	grpcbridge.Register(s, grpcbridge.NewServer(pool))
+//	_ = s.Serve(listener)
+//
+// The package is a module of its own, which keeps gRPC out of the SDK's
+// dependencies. The generated stubs in claudecodev1 are rebuilt with go
+// generate, which needs protoc, protoc-gen-go and protoc-gen-go-grpc.
+package grpcbridge
+
+//go:generate protoc -I proto --go_out=. --go_opt=module=github.com/severity1/claude-code-sdk-go/grpcbridge --go-grpc_out=. --go-grpc_opt=module=github.com/severity1/claude-code-sdk-go/grpcbridge claudecode/v1/bridge.proto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+	"github.com/severity1/claude-code-sdk-go/internal/bridge"
+)
+
+// DefaultDrainTimeout bounds how long a canceled turn is drained before its
+// client is discarded.
+const DefaultDrainTimeout = 10 * time.Second
+
+// ErrPromptRequired is returned for requests without a prompt. The gRPC
+// service reports it as codes.InvalidArgument.
+var ErrPromptRequired = errors.New("prompt is required")
+
+// QueryRequest mirrors claudecode.v1.QueryRequest.
+type QueryRequest struct {
+	Prompt    string
+	SessionID string
+}
+
+// Event mirrors claudecode.v1.Event. Exactly one field is set.
+type Event struct {
+	Delta    *TextEvent
+	Thinking *TextEvent
+	ToolUse  *ToolUseEvent
+	Result   *ResultEvent
+	Error    *ErrorEvent
+}
+
+// TextEvent mirrors claudecode.v1.TextEvent.
+type TextEvent struct {
+	Text string
+}
+
+// ToolUseEvent mirrors claudecode.v1.ToolUseEvent.
+type ToolUseEvent struct {
+	ID        string
+	Name      string
+	InputJSON string
+}
+
+// ResultEvent mirrors claudecode.v1.ResultEvent.
+type ResultEvent struct {
+	SessionID    string
+	IsError      bool
+	NumTurns     int32
+	DurationMs   int32
+	TotalCostUSD *float64
+	Result       *string
+}
+
+// ErrorEvent mirrors claudecode.v1.ErrorEvent.
+type ErrorEvent struct {
+	Message string
+}
+
+// InterruptRequest mirrors claudecode.v1.InterruptRequest.
+type InterruptRequest struct {
+	SessionID string
+}
+
+// InterruptResponse mirrors claudecode.v1.InterruptResponse.
+type InterruptResponse struct {
+	Interrupted bool
+}
+
+// SetModelRequest mirrors claudecode.v1.SetModelRequest.
+type SetModelRequest struct {
+	SessionID string
+	Model     string
+}
+
+// SetModelResponse mirrors claudecode.v1.SetModelResponse.
+type SetModelResponse struct{}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithDrainTimeout sets how long a canceled turn is drained before the
+// client is discarded from the pool.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.drainTimeout = d
+	}
+}
+
+// Server implements the ClaudeCode service on top of a ClientPool.
+type Server struct {
+	pool         *claudecode.ClientPool
+	drainTimeout time.Duration
+
+	mu     sync.Mutex
+	active map[string]claudecode.Client // session ID -> client running its turn
+	models map[string]string            // session ID -> model set with SetModel
+}
+
+// NewServer creates a Server that draws clients from pool.
+func NewServer(pool *claudecode.ClientPool, opts ...Option) *Server {
+	s := &Server{
+		pool:         pool,
+		drainTimeout: DefaultDrainTimeout,
+		active:       make(map[string]claudecode.Client),
+		models:       make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Query runs one turn and sends its events until the result. Canceling ctx
+// interrupts the turn.
+func (s *Server) Query(ctx context.Context, req *QueryRequest, send func(*Event) error) error {
+	if req == nil || strings.TrimSpace(req.Prompt) == "" {
+		return ErrPromptRequired
+	}
+	return s.runTurn(ctx, req, send)
+}
+
+// Stream runs one turn per received request, in order, until recv returns
+// io.EOF. Failed turns are reported as error events and don't end the stream.
+func (s *Server) Stream(ctx context.Context, recv func() (*QueryRequest, error), send func(*Event) error) error {
+	for {
+		req, err := recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if req == nil || strings.TrimSpace(req.Prompt) == "" {
+			err = ErrPromptRequired
+		} else {
+			err = s.runTurn(ctx, req, send)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if sendErr := send(&Event{Error: &ErrorEvent{Message: err.Error()}}); sendErr != nil {
+				return sendErr
+			}
+		}
+	}
+}
+
+// Interrupt stops the turn in flight for a session. The turn's stream still
+// ends with its result event.
+func (s *Server) Interrupt(ctx context.Context, req *InterruptRequest) (*InterruptResponse, error) {
+	s.mu.Lock()
+	client, ok := s.active[req.SessionID]
+	s.mu.Unlock()
+
+	if !ok {
+		return &InterruptResponse{Interrupted: false}, nil
+	}
+	if err := client.Interrupt(ctx); err != nil {
+		return nil, fmt.Errorf("failed to interrupt session %q: %w", req.SessionID, err)
+	}
+	return &InterruptResponse{Interrupted: true}, nil
+}
+
+// SetModel sets the model for later turns of a session. Pooled clients are
+// shared between sessions, so the model is applied per query rather than
+// on a client.
+func (s *Server) SetModel(_ context.Context, req *SetModelRequest) (*SetModelResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.Model == "" {
+		delete(s.models, req.SessionID)
+	} else {
+		s.models[req.SessionID] = req.Model
+	}
+	return &SetModelResponse{}, nil
+}
+
+// runTurn sends one prompt on a pooled client and sends the turn's events
+// until its result.
+func (s *Server) runTurn(ctx context.Context, req *QueryRequest, send func(*Event) error) error {
+	turn := bridge.Turn{
+		Prompt:       req.Prompt,
+		SessionID:    req.SessionID,
+		DrainTimeout: s.drainTimeout,
+		Started: func(client claudecode.Client) func() {
+			s.mu.Lock()
+			s.active[req.SessionID] = client
+			s.mu.Unlock()
+			return func() {
+				s.mu.Lock()
+				if s.active[req.SessionID] == client {
+					delete(s.active, req.SessionID)
+				}
+				s.mu.Unlock()
+			}
+		},
+	}
+	s.mu.Lock()
+	if model, ok := s.models[req.SessionID]; ok {
+		turn.Options = append(turn.Options, claudecode.WithModel(model))
+	}
+	s.mu.Unlock()
+
+	return bridge.Run(ctx, s.pool, turn, func(ev bridge.Event) error {
+		return send(wireEvent(ev))
+	})
+}
+
+// wireEvent converts a turn event into the Event sent to callers.
+func wireEvent(ev bridge.Event) *Event {
+	switch ev.Kind {
+	case bridge.EventDelta:
+		return &Event{Delta: &TextEvent{Text: ev.Text}}
+	case bridge.EventThinking:
+		return &Event{Thinking: &TextEvent{Text: ev.Text}}
+	case bridge.EventToolUse:
+		input, err := json.Marshal(ev.ToolUse.Input)
+		if err != nil {
+			input = []byte("{}")
+		}
+		return &Event{ToolUse: &ToolUseEvent{
+			ID:        ev.ToolUse.ToolUseID,
+			Name:      ev.ToolUse.Name,
+			InputJSON: string(input),
+		}}
+	default:
+		m := ev.Result
+		return &Event{Result: &ResultEvent{
+			SessionID:    m.SessionID,
+			IsError:      m.IsError,
+			NumTurns:     int32(m.NumTurns),
+			DurationMs:   int32(m.DurationMs),
+			TotalCostUSD: m.TotalCostUSD,
+			Result:       m.Result,
+		}}
+	}
+}
@@ -0,0 +1,313 @@
+package grpcbridge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestServerQueryStreamsEvents(t *testing.T) {
+	ctx, cancel := setupServerTestContext(t, 5*time.Second)
+	defer cancel()
+
+	srv, transport, _ := setupServerForTest(t)
+
+	events := collectEvents(t, func(send func(*Event) error) error {
+		return srv.Query(ctx, &QueryRequest{Prompt: "hello", SessionID: "alice"}, send)
+	})
+
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	if events[0].Delta == nil || events[0].Delta.Text != "echo: hello" {
+		t.Errorf("Expected delta event, got %+v", events[0])
+	}
+	if events[1].ToolUse == nil || events[1].ToolUse.InputJSON != `{"path":"hello"}` {
+		t.Errorf("Expected tool_use event with JSON input, got %+v", events[1])
+	}
+	if events[2].Result == nil || events[2].Result.SessionID != "alice" {
+		t.Errorf("Expected result event for alice, got %+v", events[2])
+	}
+	if got := transport.lastSessionID(); got != "alice" {
+		t.Errorf("Expected session ID alice, got %q", got)
+	}
+}
+
+func TestServerQueryRequiresPrompt(t *testing.T) {
+	ctx, cancel := setupServerTestContext(t, 5*time.Second)
+	defer cancel()
+
+	srv, _, _ := setupServerForTest(t)
+	err := srv.Query(ctx, &QueryRequest{Prompt: " "}, func(*Event) error { return nil })
+	if !errors.Is(err, ErrPromptRequired) {
+		t.Errorf("Expected ErrPromptRequired, got %v", err)
+	}
+}
+
+func TestServerStream(t *testing.T) {
+	ctx, cancel := setupServerTestContext(t, 5*time.Second)
+	defer cancel()
+
+	srv, _, _ := setupServerForTest(t)
+
+	requests := []*QueryRequest{
+		{Prompt: "first", SessionID: "alice"},
+		{Prompt: ""},
+		{Prompt: "second", SessionID: "alice"},
+	}
+	recv := func() (*QueryRequest, error) {
+		if len(requests) == 0 {
+			return nil, io.EOF
+		}
+		req := requests[0]
+		requests = requests[1:]
+		return req, nil
+	}
+
+	events := collectEvents(t, func(send func(*Event) error) error {
+		return srv.Stream(ctx, recv, send)
+	})
+
+	var results, errorEvents int
+	for _, ev := range events {
+		if ev.Result != nil {
+			results++
+		}
+		if ev.Error != nil {
+			errorEvents++
+		}
+	}
+	if results != 2 || errorEvents != 1 {
+		t.Errorf("Expected 2 results and 1 error event, got %d and %d", results, errorEvents)
+	}
+}
+
+func TestServerInterrupt(t *testing.T) {
+	ctx, cancel := setupServerTestContext(t, 5*time.Second)
+	defer cancel()
+
+	srv, transport, _ := setupServerForTest(t)
+
+	resp, err := srv.Interrupt(ctx, &InterruptRequest{SessionID: "alice"})
+	if err != nil || resp.Interrupted {
+		t.Fatalf("Expected no interrupt without a turn in flight, got %+v, %v", resp, err)
+	}
+
+	done := make(chan []*Event, 1)
+	go func() {
+		var events []*Event
+		_ = srv.Query(ctx, &QueryRequest{Prompt: "hang", SessionID: "alice"}, func(ev *Event) error {
+			events = append(events, ev)
+			return nil
+		})
+		done <- events
+	}()
+	transport.waitForPrompt(t, "hang")
+
+	resp, err = srv.Interrupt(ctx, &InterruptRequest{SessionID: "alice"})
+	if err != nil || !resp.Interrupted {
+		t.Fatalf("Expected turn to be interrupted, got %+v, %v", resp, err)
+	}
+
+	select {
+	case events := <-done:
+		if len(events) != 1 || events[0].Result == nil || !events[0].Result.IsError {
+			t.Errorf("Expected interrupted turn to end with an error result, got %+v", events)
+		}
+	case <-ctx.Done():
+		t.Fatal("interrupted turn did not finish")
+	}
+}
+
+func TestServerSetModel(t *testing.T) {
+	ctx, cancel := setupServerTestContext(t, 5*time.Second)
+	defer cancel()
+
+	srv, _, client := setupServerForTest(t)
+
+	if _, err := srv.SetModel(ctx, &SetModelRequest{SessionID: "alice", Model: "opus"}); err != nil {
+		t.Fatalf("SetModel failed: %v", err)
+	}
+	runQuery(ctx, t, srv, "alice")
+	runQuery(ctx, t, srv, "bob")
+	if _, err := srv.SetModel(ctx, &SetModelRequest{SessionID: "alice"}); err != nil {
+		t.Fatalf("SetModel reset failed: %v", err)
+	}
+	runQuery(ctx, t, srv, "alice")
+
+	want := []string{"opus", "", ""}
+	got := client.queryModels()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d queries, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("query %d: expected model %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+// Mock Client and Transport Implementation
+
+// serverMockClient records the per-query model override and forwards
+// queries to the wrapped client without options.
+type serverMockClient struct {
+	claudecode.Client
+	mu     sync.Mutex
+	models []string
+}
+
+func (c *serverMockClient) QueryWithSession(ctx context.Context, prompt, sessionID string, opts ...claudecode.Option) error {
+	options := &claudecode.Options{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	model := ""
+	if options.Model != nil {
+		model = *options.Model
+	}
+
+	c.mu.Lock()
+	c.models = append(c.models, model)
+	c.mu.Unlock()
+
+	return c.Client.QueryWithSession(ctx, prompt, sessionID)
+}
+
+func (c *serverMockClient) queryModels() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.models...)
+}
+
+// serverMockTransport answers each prompt with an echo, a tool use and a
+// result. The prompt "hang" produces no output until Interrupt is called.
+type serverMockTransport struct {
+	mu         sync.Mutex
+	msgChan    chan claudecode.Message
+	errChan    chan error
+	prompts    []string
+	sessionIDs []string
+	hanging    bool
+}
+
+func newServerMockTransport() *serverMockTransport {
+	return &serverMockTransport{
+		msgChan: make(chan claudecode.Message, 10),
+		errChan: make(chan error, 1),
+	}
+}
+
+func (s *serverMockTransport) Connect(_ context.Context) error { return nil }
+
+func (s *serverMockTransport) SendMessage(_ context.Context, message claudecode.StreamMessage) error {
+	payload, _ := message.Message.(map[string]interface{})
+	prompt, _ := payload["content"].(string)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prompts = append(s.prompts, prompt)
+	s.sessionIDs = append(s.sessionIDs, message.SessionID)
+
+	if prompt == "hang" {
+		s.hanging = true
+		return nil
+	}
+	s.msgChan <- &claudecode.AssistantMessage{
+		Content: []claudecode.ContentBlock{
+			&claudecode.TextBlock{Text: "echo: " + prompt},
+			&claudecode.ToolUseBlock{ToolUseID: "toolu_1", Name: "Read", Input: map[string]any{"path": prompt}},
+		},
+		Model: "claude-sonnet-4-5",
+	}
+	s.msgChan <- &claudecode.ResultMessage{Subtype: "success", SessionID: message.SessionID}
+	return nil
+}
+
+func (s *serverMockTransport) ReceiveMessages(_ context.Context) (<-chan claudecode.Message, <-chan error) {
+	return s.msgChan, s.errChan
+}
+
+func (s *serverMockTransport) Interrupt(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hanging {
+		s.hanging = false
+		s.msgChan <- &claudecode.ResultMessage{Subtype: "error_during_execution", IsError: true}
+	}
+	return nil
+}
+
+func (s *serverMockTransport) Close() error { return nil }
+
+func (s *serverMockTransport) GetValidator() *claudecode.StreamValidator {
+	return &claudecode.StreamValidator{}
+}
+
+func (s *serverMockTransport) lastSessionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sessionIDs) == 0 {
+		return ""
+	}
+	return s.sessionIDs[len(s.sessionIDs)-1]
+}
+
+func (s *serverMockTransport) waitForPrompt(t *testing.T, prompt string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		for _, p := range s.prompts {
+			if p == prompt {
+				s.mu.Unlock()
+				return
+			}
+		}
+		s.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("prompt %q was never sent", prompt)
+}
+
+// Helper functions
+
+func setupServerTestContext(t *testing.T, timeout time.Duration) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+func setupServerForTest(t *testing.T) (*Server, *serverMockTransport, *serverMockClient) {
+	t.Helper()
+	transport := newServerMockTransport()
+	client := &serverMockClient{Client: claudecode.NewClientWithTransport(transport)}
+	pool := claudecode.NewClientPoolWithFactory(1, func() claudecode.Client {
+		return client
+	})
+	t.Cleanup(func() { _ = pool.Close() })
+	return NewServer(pool), transport, client
+}
+
+func collectEvents(t *testing.T, run func(send func(*Event) error) error) []*Event {
+	t.Helper()
+	var events []*Event
+	if err := run(func(ev *Event) error {
+		events = append(events, ev)
+		return nil
+	}); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	return events
+}
+
+func runQuery(ctx context.Context, t *testing.T, srv *Server, sessionID string) {
+	t.Helper()
+	collectEvents(t, func(send func(*Event) error) error {
+		return srv.Query(ctx, &QueryRequest{Prompt: "hi", SessionID: sessionID}, send)
+	})
+}
@@ -0,0 +1,109 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Callback IDs the client registers with the CLI for tool events. Each
+// stands for all of the client's hooks of its event; the hook system picks
+// those whose pattern matches the tool.
+const (
+	preToolUseCallbackID  = "sdk_pre_tool_use"
+	postToolUseCallbackID = "sdk_post_tool_use"
+)
+
+// toolHooksRequest returns the initialize request asking the CLI to send
+// every PreToolUse and PostToolUse event to the client as a hook_callback.
+func toolHooksRequest() *ControlRequest {
+	matcher := func(callbackID string) []any {
+		return []any{map[string]any{"matcher": nil, "hookCallbackIds": []any{callbackID}}}
+	}
+	return &ControlRequest{
+		Subtype: ControlRequestTypeInitialize,
+		Data: map[string]any{
+			"hooks": map[string]any{
+				string(HookEventTypePreToolUse):  matcher(preToolUseCallbackID),
+				string(HookEventTypePostToolUse): matcher(postToolUseCallbackID),
+			},
+		},
+	}
+}
+
+// newHookCallbackHandler answers the CLI's hook_callback requests by running
+// the client's hooks for the event. With a limiter, a tool its PreToolUse
+// hooks allow waits for a free slot before the CLI runs it, and its
+// PostToolUse event gives the slot back.
+func newHookCallbackHandler(hooks HookSystem, limiter *toolLimiter) ControlRequestHandler {
+	return func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		callbackID, _ := data["callback_id"].(string)
+		toolUseID, _ := data["tool_use_id"].(string)
+
+		switch callbackID {
+		case preToolUseCallbackID:
+			var input PreToolUseHookInput
+			if err := decodeHookInput(data["input"], &input); err != nil {
+				return nil, err
+			}
+			output, err := hooks.ExecuteHooks(ctx, HookEventTypePreToolUse, input)
+			if err != nil {
+				return nil, err
+			}
+			if output.Behavior == HookBehaviorStop {
+				return denyToolOutput(output.Message), nil
+			}
+			if limiter != nil {
+				if err := limiter.acquire(ctx, toolUseID); err != nil {
+					return nil, err
+				}
+			}
+			return map[string]any{}, nil
+
+		case postToolUseCallbackID:
+			if limiter != nil {
+				limiter.release(toolUseID)
+			}
+			var input PostToolUseHookInput
+			if err := decodeHookInput(data["input"], &input); err != nil {
+				return nil, err
+			}
+			output, err := hooks.ExecuteHooks(ctx, HookEventTypePostToolUse, input)
+			if err != nil {
+				return nil, err
+			}
+			if output.Behavior == HookBehaviorStop {
+				// The tool already ran; the reason goes back to the model
+				return map[string]any{"decision": "block", "reason": output.Message}, nil
+			}
+			return map[string]any{}, nil
+		}
+		return nil, fmt.Errorf("unknown hook callback: %q", callbackID)
+	}
+}
+
+// decodeHookInput reads the event input of a hook_callback request into v.
+func decodeHookInput(input any, v any) error {
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return fmt.Errorf("invalid hook input: %w", err)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("invalid hook input: %w", err)
+	}
+	return nil
+}
+
+// denyToolOutput is the hook output keeping the CLI from running a tool,
+// with reason shown to the model.
+func denyToolOutput(reason string) map[string]any {
+	return map[string]any{
+		"decision": "block",
+		"reason":   reason,
+		"hookSpecificOutput": map[string]any{
+			"hookEventName":            string(HookEventTypePreToolUse),
+			"permissionDecision":       "deny",
+			"permissionDecisionReason": reason,
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package claudecode
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// HookRegistry adds and removes a client's hooks, before or after Connect.
+// Hooks run in the SDK, so a hook added while connected applies from the
+// next event without restarting or re-initializing the CLI; events
+// already being handled keep the hooks they started with.
+//
+// The CLI sends its PreToolUse and PostToolUse events to the client only
+// when the client connects with a tool hook or WithMaxConcurrentTools, so
+// adding a tool hook to a client connected without either fails with
+// ErrToolEventsNotRegistered; add it before Connect or reconnect.
+type HookRegistry struct {
+	hs *hookSystem
+	// client, if set, is checked for tool events while connected
+	client *ClientImpl
+}
+
+// ErrToolEventsNotRegistered is returned by HookRegistry.Add for a tool hook
+// added while connected to a CLI that does not send its tool events.
+var ErrToolEventsNotRegistered = errors.New("the CLI was not asked to send tool events on connect")
+
+// HookHandle removes the hooks added by one HookRegistry.Add.
+type HookHandle struct {
+	hs   *hookSystem
+	id   uint64
+	once sync.Once
+}
+
+// Add registers matcher's hooks for event. A matcher Pattern names the tool
+// of PreToolUse and PostToolUse events whose hooks run; empty or "*"
+// matches every tool, and is the only pattern accepted for other events.
+// A matcher Timeout bounds each of its hooks, within the 30 second limit
+// on the hooks of one event.
+//
+//	handle, err := client.(*claudecode.ClientImpl).Hooks().Add(claudecode.HookEventTypePreToolUse, claudecode.HookMatcher{
+//		Pattern: "Bash",
+//		Hooks:   []claudecode.HookCallback{auditBash},
+//	})
+//	...
+//	handle.Remove()
+func (r *HookRegistry) Add(event HookEventType, matcher HookMatcher) (*HookHandle, error) {
+	if err := validateHookMatcher(event, matcher); err != nil {
+		return nil, err
+	}
+	matcher.Hooks = append([]HookCallback(nil), matcher.Hooks...)
+	if r.client != nil {
+		// Hold off Connect, which registers tool events for the hooks it sees
+		r.client.mu.RLock()
+		defer r.client.mu.RUnlock()
+		if (event == HookEventTypePreToolUse || event == HookEventTypePostToolUse) &&
+			r.client.connected && !r.client.toolEvents {
+			return nil, fmt.Errorf("%s hook: %w", event, ErrToolEventsNotRegistered)
+		}
+	}
+	return &HookHandle{hs: r.hs, id: r.hs.addEntry(event, matcher)}, nil
+}
+
+// Remove unregisters the handle's hooks. Calling it again does nothing.
+func (h *HookHandle) Remove() {
+	h.once.Do(func() {
+		h.hs.removeEntry(h.id)
+	})
+}
+
+// validateHookMatcher checks a matcher added for event.
+func validateHookMatcher(event HookEventType, matcher HookMatcher) error {
+	switch event {
+	case HookEventTypePreToolUse, HookEventTypePostToolUse:
+	case HookEventTypeUserPromptSubmit, HookEventTypeStop, HookEventTypeSubagentStop,
+		HookEventTypePreCompact, HookEventTypeSessionEnd:
+		if matcher.Pattern != "" && matcher.Pattern != "*" {
+			return fmt.Errorf("hook pattern %q: %s events have no tool to match", matcher.Pattern, event)
+		}
+	default:
+		return fmt.Errorf("unknown hook event %q", event)
+	}
+	if len(matcher.Hooks) == 0 {
+		return errors.New("hook matcher has no hooks")
+	}
+	for _, hook := range matcher.Hooks {
+		if hook == nil {
+			return errors.New("hook matcher has a nil hook")
+		}
+	}
+	if matcher.Timeout < 0 {
+		return fmt.Errorf("hook timeout %v is negative", matcher.Timeout)
+	}
+	return nil
+}
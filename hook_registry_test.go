@@ -0,0 +1,206 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hookCalls records the hooks that ran, by name.
+type hookCalls struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (h *hookCalls) hook(name string) HookCallback {
+	return func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.names = append(h.names, name)
+		return HookOutput{Behavior: HookBehaviorContinue}, nil
+	}
+}
+
+func (h *hookCalls) take() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	names := h.names
+	h.names = nil
+	return names
+}
+
+func TestHookRegistryOnLiveClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	calls := &hookCalls{}
+	client := NewClientWithTransport(newClientMockTransport()).(*ClientImpl)
+	before, err := client.Hooks().Add(HookEventTypeStop, HookMatcher{Hooks: []HookCallback{calls.hook("before")}})
+	if err != nil {
+		t.Fatalf("Add before Connect failed: %v", err)
+	}
+	connectClientSafely(ctx, t, client)
+
+	if _, err := client.Hooks().Add(HookEventTypeStop, HookMatcher{Hooks: []HookCallback{calls.hook("live")}}); err != nil {
+		t.Fatalf("Add while connected failed: %v", err)
+	}
+	removed, err := client.Hooks().Add(HookEventTypeStop, HookMatcher{Hooks: []HookCallback{calls.hook("removed")}})
+	if err != nil {
+		t.Fatalf("Add while connected failed: %v", err)
+	}
+	removed.Remove()
+	removed.Remove()
+
+	if err := client.Disconnect(); err != nil {
+		t.Fatalf("Disconnect failed: %v", err)
+	}
+	client.Wait()
+	if got := calls.take(); !reflect.DeepEqual(got, []string{"before", "live"}) {
+		t.Errorf("Expected the hooks added before and after Connect, got %v", got)
+	}
+
+	before.Remove()
+	connectClientSafely(ctx, t, client)
+	_ = client.Disconnect()
+	client.Wait()
+	if got := calls.take(); !reflect.DeepEqual(got, []string{"live"}) {
+		t.Errorf("Expected hooks to persist across reconnects until removed, got %v", got)
+	}
+}
+
+func TestHookRegistryToolHooksWhileConnected(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Connected without tool hooks, the CLI keeps its tool events
+	client := NewClientWithTransport(newClientControlMockTransport()).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	calls := &hookCalls{}
+	bash := HookMatcher{Pattern: "Bash", Hooks: []HookCallback{calls.hook("bash")}}
+	if _, err := client.Hooks().Add(HookEventTypePreToolUse, bash); !errors.Is(err, ErrToolEventsNotRegistered) {
+		t.Errorf("Expected ErrToolEventsNotRegistered, got %v", err)
+	}
+	if _, err := client.Hooks().Add(HookEventTypeStop, HookMatcher{Hooks: []HookCallback{calls.hook("stop")}}); err != nil {
+		t.Errorf("Expected other hooks to be added while connected, got %v", err)
+	}
+	disconnectClientSafely(t, client)
+	calls.take()
+
+	// Connected with a tool hook, more can be added and run
+	client = NewClientWithTransport(newClientControlMockTransport()).(*ClientImpl)
+	if _, err := client.Hooks().Add(HookEventTypePostToolUse, bash); err != nil {
+		t.Fatalf("Add before Connect failed: %v", err)
+	}
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+	if _, err := client.Hooks().Add(HookEventTypePreToolUse, bash); err != nil {
+		t.Fatalf("Add while connected failed: %v", err)
+	}
+	protocol := client.GetControlProtocol().(*controlProtocol)
+	if _, err := protocol.HandleControlRequest(ctx, toolHookRequest("cli-1", preToolUseCallbackID, "tool-1", "ls")); err != nil {
+		t.Fatalf("HandleControlRequest failed: %v", err)
+	}
+	if got := calls.take(); !reflect.DeepEqual(got, []string{"bash"}) {
+		t.Errorf("Expected the tool hook added while connected to run, got %v", got)
+	}
+}
+
+func TestHookRegistryToolPatterns(t *testing.T) {
+	calls := &hookCalls{}
+	hs := newHookSystem(SystemClock{})
+	registry := &HookRegistry{hs: hs}
+	for _, matcher := range []HookMatcher{
+		{Pattern: "Bash", Hooks: []HookCallback{calls.hook("bash")}},
+		{Pattern: "*", Hooks: []HookCallback{calls.hook("any")}},
+		{Hooks: []HookCallback{calls.hook("empty")}},
+	} {
+		if _, err := registry.Add(HookEventTypePreToolUse, matcher); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+	if _, err := registry.Add(HookEventTypePostToolUse, HookMatcher{Hooks: []HookCallback{calls.hook("post")}}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	ctx := context.Background()
+	_, _ = hs.ExecuteHooks(ctx, HookEventTypePreToolUse, &PreToolUseHookInput{ToolName: "Bash"})
+	if got := calls.take(); !reflect.DeepEqual(got, []string{"bash", "any", "empty"}) {
+		t.Errorf("Bash: got %v", got)
+	}
+	_, _ = hs.ExecuteHooks(ctx, HookEventTypePreToolUse, PreToolUseHookInput{ToolName: "Read"})
+	if got := calls.take(); !reflect.DeepEqual(got, []string{"any", "empty"}) {
+		t.Errorf("Read: got %v", got)
+	}
+	_, _ = hs.ExecuteHooks(ctx, HookEventTypePostToolUse, PostToolUseHookInput{ToolName: "Bash"})
+	if got := calls.take(); !reflect.DeepEqual(got, []string{"post"}) {
+		t.Errorf("PostToolUse: got %v", got)
+	}
+}
+
+func TestHookRegistryMatcherTimeout(t *testing.T) {
+	clock := newManualClock()
+	hs := newHookSystem(clock)
+	registry := &HookRegistry{hs: hs}
+	_, err := registry.Add(HookEventTypeStop, HookMatcher{
+		Timeout: time.Second,
+		Hooks: []HookCallback{func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+			<-ctx.Done()
+			return HookOutput{Behavior: HookBehaviorStop}, nil
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	results := make(chan *HookOutput, 1)
+	go func() {
+		result, _ := hs.ExecuteHooks(context.Background(), HookEventTypeStop, StopHookInput{})
+		results <- result
+	}()
+	if timer := clock.waitForTimer(t); timer.d != hookTimeout {
+		t.Fatalf("Expected the event timer first, got %v", timer.d)
+	}
+	timer := clock.waitForTimer(t)
+	if timer.d != time.Second {
+		t.Fatalf("Expected the matcher's timer, got %v", timer.d)
+	}
+	timer.fire()
+
+	select {
+	case result := <-results:
+		if result.Behavior != HookBehaviorContinue {
+			t.Errorf("Expected a timed out hook to continue, got %s", result.Behavior)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Matcher timeout did not end the hook")
+	}
+}
+
+func TestHookRegistryAddValidation(t *testing.T) {
+	registry := &HookRegistry{hs: newHookSystem(SystemClock{})}
+	noop := (&hookCalls{}).hook("noop")
+	tests := []struct {
+		name    string
+		event   HookEventType
+		matcher HookMatcher
+	}{
+		{"unknown event", "Whenever", HookMatcher{Hooks: []HookCallback{noop}}},
+		{"no hooks", HookEventTypeStop, HookMatcher{}},
+		{"nil hook", HookEventTypeStop, HookMatcher{Hooks: []HookCallback{nil}}},
+		{"tool pattern on a toolless event", HookEventTypeStop, HookMatcher{Pattern: "Bash", Hooks: []HookCallback{noop}}},
+		{"negative timeout", HookEventTypePreToolUse, HookMatcher{Timeout: -time.Second, Hooks: []HookCallback{noop}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := registry.Add(test.event, test.matcher); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+	if registry.hs.HasHooks() {
+		t.Error("Expected rejected matchers not to be registered")
+	}
+}
@@ -0,0 +1,173 @@
+package claudecode
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxHookLatencySamples bounds the durations kept per matcher for
+// percentiles. Once reached, the oldest samples are replaced.
+const maxHookLatencySamples = 1000
+
+// HookStats summarizes the runs of the hooks registered with one pattern
+// for one event.
+type HookStats struct {
+	Event   HookEventType
+	Pattern string
+	// Runs is the number of hook runs, each hook counted separately.
+	Runs int
+	// Failures is the number of runs that returned an error or panicked,
+	// and Timeouts the number abandoned at their timeout.
+	Failures int
+	Timeouts int
+	// Slow is the number of runs over the WithSlowHookThreshold threshold,
+	// including those that timed out.
+	Slow int
+	// Total is the time spent in all runs.
+	Total time.Duration
+
+	// Duration percentiles of the most recent runs.
+	LatencyP50 time.Duration
+	LatencyP90 time.Duration
+	LatencyP99 time.Duration
+	LatencyMax time.Duration
+}
+
+// hookOutcome is how a hook run ended.
+type hookOutcome int
+
+const (
+	hookReturned hookOutcome = iota
+	hookFailed
+	hookTimedOut
+)
+
+// hookRun is one timed hook run.
+type hookRun struct {
+	event    HookEventType
+	pattern  string
+	toolName string
+	end      time.Time
+	duration time.Duration
+	outcome  hookOutcome
+}
+
+// hookStatsKey identifies the hooks of one pattern for one event.
+type hookStatsKey struct {
+	event   HookEventType
+	pattern string
+}
+
+type hookRecord struct {
+	runs      int
+	failures  int
+	timeouts  int
+	slow      int
+	total     time.Duration
+	latencies []time.Duration
+	next      int // ring position once latencies is full
+}
+
+// hookMetrics records hook run durations and reports slow runs.
+type hookMetrics struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	reporter  SlowHookReporter
+	records   map[hookStatsKey]*hookRecord
+}
+
+func newHookMetrics() *hookMetrics {
+	return &hookMetrics{records: make(map[hookStatsKey]*hookRecord)}
+}
+
+// reportSlow sets the threshold over which runs are reported to reporter.
+func (hm *hookMetrics) reportSlow(threshold time.Duration, reporter SlowHookReporter) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.threshold = threshold
+	hm.reporter = reporter
+}
+
+// record adds a run, reporting it if it was slow or timed out, whatever its
+// measured duration. A panicking reporter does not interrupt the event's
+// remaining hooks.
+func (hm *hookMetrics) record(run hookRun) {
+	hm.mu.Lock()
+	key := hookStatsKey{event: run.event, pattern: run.pattern}
+	record, ok := hm.records[key]
+	if !ok {
+		record = &hookRecord{}
+		hm.records[key] = record
+	}
+	record.runs++
+	record.total += run.duration
+	switch run.outcome {
+	case hookFailed:
+		record.failures++
+	case hookTimedOut:
+		record.timeouts++
+	}
+	if len(record.latencies) < maxHookLatencySamples {
+		record.latencies = append(record.latencies, run.duration)
+	} else {
+		record.latencies[record.next] = run.duration
+		record.next = (record.next + 1) % maxHookLatencySamples
+	}
+	threshold, reporter := hm.threshold, hm.reporter
+	slow := threshold > 0 && (run.duration > threshold || run.outcome == hookTimedOut)
+	if slow {
+		record.slow++
+	}
+	hm.mu.Unlock()
+
+	if !slow || reporter == nil {
+		return
+	}
+	defer func() {
+		_ = recover()
+	}()
+	reporter(SlowHookReport{
+		Event:     string(run.event),
+		Pattern:   run.pattern,
+		ToolName:  run.toolName,
+		Time:      run.end,
+		Duration:  run.duration,
+		Threshold: threshold,
+		TimedOut:  run.outcome == hookTimedOut,
+	})
+}
+
+// stats returns a snapshot of the statistics, sorted by event and pattern.
+func (hm *hookMetrics) stats() []HookStats {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	stats := make([]HookStats, 0, len(hm.records))
+	for key, record := range hm.records {
+		s := HookStats{
+			Event:    key.event,
+			Pattern:  key.pattern,
+			Runs:     record.runs,
+			Failures: record.failures,
+			Timeouts: record.timeouts,
+			Slow:     record.slow,
+			Total:    record.total,
+		}
+		sorted := make([]time.Duration, len(record.latencies))
+		copy(sorted, record.latencies)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		s.LatencyP50 = percentile(sorted, 0.50)
+		s.LatencyP90 = percentile(sorted, 0.90)
+		s.LatencyP99 = percentile(sorted, 0.99)
+		s.LatencyMax = sorted[len(sorted)-1]
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Event != stats[j].Event {
+			return stats[i].Event < stats[j].Event
+		}
+		return stats[i].Pattern < stats[j].Pattern
+	})
+	return stats
+}
@@ -0,0 +1,128 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// sleepingHook is a hook that advances clock by d, as if it ran for d.
+func sleepingHook(clock *manualClock, d time.Duration) HookCallback {
+	return func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+		clock.advance(d)
+		return HookOutput{Behavior: HookBehaviorContinue}, nil
+	}
+}
+
+func TestHookMetrics(t *testing.T) {
+	clock := newManualClock()
+	hs := newHookSystem(clock)
+	var reports []SlowHookReport
+	hs.metrics.reportSlow(time.Second, func(r SlowHookReport) { reports = append(reports, r) })
+
+	hs.AddHook("Bash", sleepingHook(clock, 100*time.Millisecond), sleepingHook(clock, 3*time.Second))
+	hs.AddHook("PreToolUse", func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+		return HookOutput{}, errors.New("audit log unavailable")
+	})
+	registry := &HookRegistry{hs: hs}
+	if _, err := registry.Add(HookEventTypeStop, HookMatcher{Hooks: []HookCallback{
+		func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+			panic("hook bug")
+		},
+	}}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, _ = hs.ExecuteHooks(ctx, HookEventTypePreToolUse, PreToolUseHookInput{ToolName: "Bash"})
+	}
+	_, _ = hs.ExecuteHooks(ctx, HookEventTypeStop, StopHookInput{})
+
+	stats := hs.metrics.stats()
+	if len(stats) != 3 {
+		t.Fatalf("Expected stats for 3 event patterns, got %+v", stats)
+	}
+	bash := stats[0]
+	if bash.Event != HookEventTypePreToolUse || bash.Pattern != "Bash" {
+		t.Fatalf("Expected PreToolUse/Bash first, got %s/%s", bash.Event, bash.Pattern)
+	}
+	if bash.Runs != 4 || bash.Slow != 2 || bash.Total != 2*(3100*time.Millisecond) {
+		t.Errorf("Unexpected Bash stats: %+v", bash)
+	}
+	if bash.LatencyP50 != 100*time.Millisecond || bash.LatencyMax != 3*time.Second {
+		t.Errorf("Unexpected Bash percentiles: %+v", bash)
+	}
+	if failing := stats[1]; failing.Pattern != "PreToolUse" || failing.Runs != 2 || failing.Failures != 2 {
+		t.Errorf("Expected failing hooks to be counted, got %+v", failing)
+	}
+	if stop := stats[2]; stop.Event != HookEventTypeStop || stop.Pattern != "" || stop.Failures != 1 {
+		t.Errorf("Expected the panicking Stop hook to be counted as a failure, got %+v", stop)
+	}
+
+	if len(reports) != 2 {
+		t.Fatalf("Expected 2 slow hook reports, got %+v", reports)
+	}
+	if r := reports[0]; r.Event != "PreToolUse" || r.Pattern != "Bash" || r.ToolName != "Bash" ||
+		r.Duration != 3*time.Second || r.Threshold != time.Second || r.TimedOut {
+		t.Errorf("Unexpected report: %+v", r)
+	}
+}
+
+func TestHookMetricsTimeout(t *testing.T) {
+	clock := newManualClock()
+	hs := newHookSystem(clock)
+	var report SlowHookReport
+	hs.metrics.reportSlow(time.Second, func(r SlowHookReport) { report = r })
+	advanced := make(chan struct{})
+	hs.AddHook("Bash", func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+		clock.advance(hookTimeout)
+		close(advanced)
+		<-ctx.Done()
+		return HookOutput{}, nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = hs.ExecuteHooks(context.Background(), HookEventTypePreToolUse, PreToolUseHookInput{ToolName: "Bash"})
+	}()
+	timer := clock.waitForTimer(t)
+	<-advanced
+	timer.fire()
+	<-done
+
+	if stats := hs.metrics.stats(); len(stats) != 1 || stats[0].Timeouts != 1 {
+		t.Errorf("Expected one timeout, got %+v", stats)
+	}
+	if !report.TimedOut || report.Duration != hookTimeout {
+		t.Errorf("Expected the timed out run to be reported, got %+v", report)
+	}
+}
+
+func TestClientHookStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clock := newManualClock()
+	var reports []SlowHookReport
+	client := NewClientWithTransport(newClientMockTransport(), WithClock(clock),
+		WithSlowHookThreshold(time.Second, func(r SlowHookReport) { reports = append(reports, r) })).(*ClientImpl)
+	if stats := client.HookStats(); len(stats) != 0 {
+		t.Errorf("Expected no stats before hooks run, got %+v", stats)
+	}
+	if _, err := client.Hooks().Add(HookEventTypeSessionEnd, HookMatcher{Hooks: []HookCallback{sleepingHook(clock, 2*time.Second)}}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	connectClientSafely(ctx, t, client)
+	disconnectClientSafely(t, client)
+
+	stats := client.HookStats()
+	if len(stats) != 1 || stats[0].Event != HookEventTypeSessionEnd || stats[0].Runs != 1 || stats[0].Slow != 1 {
+		t.Errorf("Expected the SessionEnd run, got %+v", stats)
+	}
+	if len(reports) != 1 || reports[0].Event != "SessionEnd" {
+		t.Errorf("Expected a slow SessionEnd report, got %+v", reports)
+	}
+}
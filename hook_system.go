@@ -3,7 +3,9 @@ package claudecode
 import (
 	"context"
 	"fmt"
+	"runtime/pprof"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,9 +16,10 @@ const (
 	HookEventTypePreToolUse       HookEventType = "PreToolUse"
 	HookEventTypePostToolUse      HookEventType = "PostToolUse"
 	HookEventTypeUserPromptSubmit HookEventType = "UserPromptSubmit"
-	HookEventTypeStop              HookEventType = "Stop"
-	HookEventTypeSubagentStop      HookEventType = "SubagentStop"
-	HookEventTypePreCompact        HookEventType = "PreCompact"
+	HookEventTypeStop             HookEventType = "Stop"
+	HookEventTypeSubagentStop     HookEventType = "SubagentStop"
+	HookEventTypePreCompact       HookEventType = "PreCompact"
+	HookEventTypeSessionEnd       HookEventType = "SessionEnd"
 )
 
 // HookBehavior represents hook execution behavior
@@ -29,71 +32,89 @@ const (
 
 // BaseHookInput contains fields common to all hook events
 type BaseHookInput struct {
-	SessionID     string `json:"session_id"`
-	TranscriptPath string `json:"transcript_path"`
-	Cwd           string `json:"cwd"`
+	SessionID      string  `json:"session_id"`
+	TranscriptPath string  `json:"transcript_path"`
+	Cwd            string  `json:"cwd"`
 	PermissionMode *string `json:"permission_mode,omitempty"`
 }
 
+// hookBase returns the common fields of the input embedding b.
+func (b BaseHookInput) hookBase() BaseHookInput {
+	return b
+}
+
 // PreToolUseHookInput represents input data for PreToolUse events
 type PreToolUseHookInput struct {
 	BaseHookInput
-	HookEventName HookEventType `json:"hook_event_name"`
-	ToolName     string                 `json:"tool_name"`
-	ToolInput    map[string]any           `json:"tool_input"`
+	HookEventName HookEventType  `json:"hook_event_name"`
+	ToolName      string         `json:"tool_name"`
+	ToolInput     map[string]any `json:"tool_input"`
 }
 
 // PostToolUseHookInput represents input data for PostToolUse events
 type PostToolUseHookInput struct {
 	BaseHookInput
-	HookEventName HookEventType `json:"hook_event_name"`
-	ToolName     string                 `json:"tool_name"`
-	ToolInput    map[string]any           `json:"tool_input"`
-	ToolResponse any                     `json:"tool_response"`
+	HookEventName HookEventType  `json:"hook_event_name"`
+	ToolName      string         `json:"tool_name"`
+	ToolInput     map[string]any `json:"tool_input"`
+	ToolResponse  any            `json:"tool_response"`
 }
 
 // UserPromptSubmitHookInput represents input data for UserPromptSubmit events
 type UserPromptSubmitHookInput struct {
 	BaseHookInput
 	HookEventName HookEventType `json:"hook_event_name"`
-	Prompt       string `json:"prompt"`
+	Prompt        string        `json:"prompt"`
 }
 
 // StopHookInput represents input data for Stop events
 type StopHookInput struct {
 	BaseHookInput
-	HookEventName HookEventType `json:"hook_event_name"`
-	StopHookActive bool `json:"stop_hook_active"`
+	HookEventName  HookEventType `json:"hook_event_name"`
+	StopHookActive bool          `json:"stop_hook_active"`
 }
 
 // SubagentStopHookInput represents input data for SubagentStop events
 type SubagentStopHookInput struct {
 	BaseHookInput
-	HookEventName HookEventType `json:"hook_event_name"`
-	StopHookActive bool `json:"stop_hook_active"`
+	HookEventName  HookEventType `json:"hook_event_name"`
+	StopHookActive bool          `json:"stop_hook_active"`
 }
 
 // PreCompactHookInput represents input data for PreCompact events
 type PreCompactHookInput struct {
 	BaseHookInput
-	HookEventName HookEventType `json:"hook_event_name"`
-	Trigger      string `json:"trigger"` // "manual" or "auto"
-	CustomInstructions *string `json:"custom_instructions,omitempty"`
+	HookEventName      HookEventType `json:"hook_event_name"`
+	Trigger            string        `json:"trigger"` // "manual" or "auto"
+	CustomInstructions *string       `json:"custom_instructions,omitempty"`
+}
+
+// SessionEndHookInput represents input data for SessionEnd events
+type SessionEndHookInput struct {
+	BaseHookInput
+	HookEventName HookEventType    `json:"hook_event_name"`
+	Reason        SessionEndReason `json:"reason"`
 }
 
 // HookOutput represents the result of a hook execution
 type HookOutput struct {
-	Behavior    HookBehavior   `json:"behavior"`
-	Message     string         `json:"message,omitempty"`
+	Behavior    HookBehavior       `json:"behavior"`
+	Message     string             `json:"message,omitempty"`
 	Permissions []PermissionUpdate `json:"permissions,omitempty"`
-	Context     map[string]any `json:"context,omitempty"`
+	Context     map[string]any     `json:"context,omitempty"`
 }
 
-// HookContext provides execution context for hooks
+// HookContext describes the session a hook runs for. Fields are empty when
+// they are not known yet, such as the session ID before the CLI's init
+// message arrives.
 type HookContext struct {
-	SessionID     string `json:"session_id"`
+	SessionID      string `json:"session_id"`
 	TranscriptPath string `json:"transcript_path"`
-	Cwd           string `json:"cwd"`
+	Cwd            string `json:"cwd"`
+	// PermissionMode and Model are what the CLI is using when the event
+	// fires, after any SetPermissionMode or SetModel call.
+	PermissionMode PermissionMode `json:"permission_mode,omitempty"`
+	Model          string         `json:"model,omitempty"`
 }
 
 // HookCallback defines the function signature for hook callbacks
@@ -105,9 +126,9 @@ type HookCallback func(
 
 // HookMatcher defines pattern matching for hook registration
 type HookMatcher struct {
-	Pattern   string         `json:"pattern"`
-	Hooks     []HookCallback `json:"-"`
-	Timeout   time.Duration    `json:"timeout,omitempty"`
+	Pattern string         `json:"pattern"`
+	Hooks   []HookCallback `json:"-"`
+	Timeout time.Duration  `json:"timeout,omitempty"`
 }
 
 // HookSystem manages hook registration and execution
@@ -125,17 +146,81 @@ type HookSystem interface {
 	HasHooks() bool
 }
 
-// hookSystem implements HookSystem
+// hookSystem implements HookSystem. Hooks run in the order their patterns
+// were first registered, and in registration order within a pattern.
+//
+// Registrations are copy-on-write: AddHook and RemoveHook publish a new
+// hookSnapshot, and ExecuteHooks runs the snapshot it loaded without a lock,
+// so a slow hook never holds up registration and a registration made while
+// hooks run takes effect from the next event.
 type hookSystem struct {
+	snapshot atomic.Value // *hookSnapshot
+	clock    Clock
+	mu       sync.Mutex // serializes writers
+	lastID   uint64     // of entries, guarded by mu
+
+	// session describes the client's session to hooks; set before the
+	// hook system is shared, it may be nil
+	session func() HookContext
+
+	// metrics times hook runs
+	metrics *hookMetrics
+}
+
+// hookSnapshot is a registration state. It is never modified once
+// published.
+type hookSnapshot struct {
 	matchers map[string][]HookCallback
-	mu        sync.RWMutex
+	patterns []string
+	// entries are the hooks added for one event through a HookRegistry,
+	// which run after the hooks added by pattern.
+	entries []hookEntry
+}
+
+// hookEntry is a HookMatcher added for an event.
+type hookEntry struct {
+	id      uint64
+	event   HookEventType
+	matcher HookMatcher
 }
 
+// matches reports whether the entry's hooks run for an event with input.
+func (e hookEntry) matches(eventType HookEventType, input interface{}) bool {
+	if e.event != eventType {
+		return false
+	}
+	if e.matcher.Pattern == "" || e.matcher.Pattern == "*" {
+		return true
+	}
+	return hookInputToolName(input) == e.matcher.Pattern
+}
+
+// scheduledHook is a hook to run for an event, with the pattern it was
+// registered with and the timeout of its matcher, if any.
+type scheduledHook struct {
+	callback HookCallback
+	pattern  string
+	timeout  time.Duration
+}
+
+// hookTimeout bounds the hooks run for one event.
+const hookTimeout = 30 * time.Second
+
 // NewHookSystem creates a new hook system
 func NewHookSystem() HookSystem {
-	return &hookSystem{
-		matchers: make(map[string][]HookCallback),
-	}
+	return newHookSystem(SystemClock{})
+}
+
+// newHookSystem creates a hook system timing out hooks with clock.
+func newHookSystem(clock Clock) *hookSystem {
+	hs := &hookSystem{clock: clock, metrics: newHookMetrics()}
+	hs.snapshot.Store(&hookSnapshot{matchers: make(map[string][]HookCallback)})
+	return hs
+}
+
+// load returns the current registrations.
+func (hs *hookSystem) load() *hookSnapshot {
+	return hs.snapshot.Load().(*hookSnapshot)
 }
 
 // AddHook registers hooks for a specific pattern
@@ -143,7 +228,21 @@ func (hs *hookSystem) AddHook(pattern string, hooks ...HookCallback) error {
 	hs.mu.Lock()
 	defer hs.mu.Unlock()
 
-	hs.matchers[pattern] = append(hs.matchers[pattern], hooks...)
+	current := hs.load()
+	next := &hookSnapshot{
+		matchers: make(map[string][]HookCallback, len(current.matchers)+1),
+		patterns: current.patterns,
+		entries:  current.entries,
+	}
+	for p, callbacks := range current.matchers {
+		next.matchers[p] = callbacks
+	}
+	if _, ok := current.matchers[pattern]; !ok {
+		next.patterns = append(current.patterns[:len(current.patterns):len(current.patterns)], pattern)
+	}
+	existing := current.matchers[pattern]
+	next.matchers[pattern] = append(existing[:len(existing):len(existing)], hooks...)
+	hs.snapshot.Store(next)
 	return nil
 }
 
@@ -152,20 +251,87 @@ func (hs *hookSystem) RemoveHook(pattern string) error {
 	hs.mu.Lock()
 	defer hs.mu.Unlock()
 
-	delete(hs.matchers, pattern)
+	current := hs.load()
+	if _, ok := current.matchers[pattern]; !ok {
+		return nil
+	}
+	next := &hookSnapshot{
+		matchers: make(map[string][]HookCallback, len(current.matchers)),
+		patterns: make([]string, 0, len(current.patterns)),
+		entries:  current.entries,
+	}
+	for p, callbacks := range current.matchers {
+		if p != pattern {
+			next.matchers[p] = callbacks
+		}
+	}
+	for _, p := range current.patterns {
+		if p != pattern {
+			next.patterns = append(next.patterns, p)
+		}
+	}
+	hs.snapshot.Store(next)
 	return nil
 }
 
+// addEntry registers matcher's hooks for event, returning the ID that
+// removes them.
+func (hs *hookSystem) addEntry(event HookEventType, matcher HookMatcher) uint64 {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	hs.lastID++
+	current := hs.load()
+	next := *current
+	next.entries = append(current.entries[:len(current.entries):len(current.entries)],
+		hookEntry{id: hs.lastID, event: event, matcher: matcher})
+	hs.snapshot.Store(&next)
+	return hs.lastID
+}
+
+// removeEntry removes the hooks registered by addEntry under id, reporting
+// whether they were registered.
+func (hs *hookSystem) removeEntry(id uint64) bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	current := hs.load()
+	for i, entry := range current.entries {
+		if entry.id != id {
+			continue
+		}
+		next := *current
+		next.entries = make([]hookEntry, 0, len(current.entries)-1)
+		next.entries = append(next.entries, current.entries[:i]...)
+		next.entries = append(next.entries, current.entries[i+1:]...)
+		hs.snapshot.Store(&next)
+		return true
+	}
+	return false
+}
+
 // ExecuteHooks executes hooks for a specific event type
 func (hs *hookSystem) ExecuteHooks(ctx context.Context, eventType HookEventType, input interface{}) (*HookOutput, error) {
-	hs.mu.RLock()
-	defer hs.mu.RUnlock()
+	registered := hs.load()
 
 	// Find matching hooks for this event type
-	var matchingHooks []HookCallback
-	for pattern, hooks := range hs.matchers {
-		if hs.patternMatches(eventType, pattern) {
-			matchingHooks = append(matchingHooks, hooks...)
+	var matchingHooks []scheduledHook
+	for _, pattern := range registered.patterns {
+		if hs.patternMatches(eventType, pattern, input) {
+			for _, hook := range registered.matchers[pattern] {
+				matchingHooks = append(matchingHooks, scheduledHook{callback: hook, pattern: pattern})
+			}
+		}
+	}
+	for _, entry := range registered.entries {
+		if entry.matches(eventType, input) {
+			for _, hook := range entry.matcher.Hooks {
+				matchingHooks = append(matchingHooks, scheduledHook{
+					callback: hook,
+					pattern:  entry.matcher.Pattern,
+					timeout:  entry.matcher.Timeout,
+				})
+			}
 		}
 	}
 
@@ -174,11 +340,12 @@ func (hs *hookSystem) ExecuteHooks(ctx context.Context, eventType HookEventType,
 	}
 
 	// Execute hooks sequentially with timeout protection
-	timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	timeoutCtx, cancel := withClockTimeout(ctx, hs.clock, hookTimeout)
 	defer cancel()
 
+	hookCtx := hs.createHookContext(input)
 	for _, hook := range matchingHooks {
-		output, err := hook(timeoutCtx, input, hs.createHookContext(eventType))
+		output, err := hs.runScheduled(timeoutCtx, eventType, hook, input, hookCtx)
 		if err != nil {
 			return nil, fmt.Errorf("hook execution failed: %w", err)
 		}
@@ -205,31 +372,159 @@ func (hs *hookSystem) ExecuteHooks(ctx context.Context, eventType HookEventType,
 
 // HasHooks returns true if any hooks are registered
 func (hs *hookSystem) HasHooks() bool {
-	hs.mu.RLock()
-	defer hs.mu.RUnlock()
+	registered := hs.load()
+	return len(registered.matchers) > 0 || len(registered.entries) > 0
+}
+
+// hasToolHooks reports whether any registered hook can run for a
+// PreToolUse or PostToolUse event.
+func (hs *hookSystem) hasToolHooks() bool {
+	registered := hs.load()
+	for _, pattern := range registered.patterns {
+		switch HookEventType(pattern) {
+		case HookEventTypeUserPromptSubmit, HookEventTypeStop, HookEventTypeSubagentStop,
+			HookEventTypePreCompact, HookEventTypeSessionEnd:
+		default:
+			return true
+		}
+	}
+	for _, entry := range registered.entries {
+		if entry.event == HookEventTypePreToolUse || entry.event == HookEventTypePostToolUse {
+			return true
+		}
+	}
+	return false
+}
+
+// createHookContext describes the session to the hooks of an event, filling
+// what the client does not know from the event's input.
+func (hs *hookSystem) createHookContext(input interface{}) HookContext {
+	var hookCtx HookContext
+	if hs.session != nil {
+		hookCtx = hs.session()
+	}
+	based, ok := input.(interface{ hookBase() BaseHookInput })
+	if !ok {
+		return hookCtx
+	}
+	base := based.hookBase()
+	if hookCtx.SessionID == "" {
+		hookCtx.SessionID = base.SessionID
+	}
+	if hookCtx.TranscriptPath == "" {
+		hookCtx.TranscriptPath = base.TranscriptPath
+	}
+	if hookCtx.Cwd == "" {
+		hookCtx.Cwd = base.Cwd
+	}
+	if hookCtx.PermissionMode == "" && base.PermissionMode != nil {
+		hookCtx.PermissionMode = PermissionMode(*base.PermissionMode)
+	}
+	return hookCtx
+}
 
-	return len(hs.matchers) > 0
+// runScheduled runs hook for an event, within its matcher's timeout if it
+// has one, and records how long it took.
+func (hs *hookSystem) runScheduled(ctx context.Context, eventType HookEventType, hook scheduledHook, input interface{}, hookCtx HookContext) (HookOutput, error) {
+	if hook.timeout > 0 {
+		timeoutCtx, cancel := withClockTimeout(ctx, hs.clock, hook.timeout)
+		defer cancel()
+		ctx = timeoutCtx
+	}
+	start := hs.clock.Now()
+	output, outcome, err := hs.runHook(ctx, hook.callback, input, hookCtx)
+	end := hs.clock.Now()
+	if err != nil {
+		outcome = hookFailed
+	}
+	hs.metrics.record(hookRun{
+		event:    eventType,
+		pattern:  hook.pattern,
+		toolName: hookInputToolName(input),
+		end:      end,
+		duration: end.Sub(start),
+		outcome:  outcome,
+	})
+	return output, err
 }
 
-// createHookContext creates hook execution context
-func (hs *hookSystem) createHookContext(eventType HookEventType) HookContext {
-	// TODO: Extract actual context from session/client
-	return HookContext{
-		SessionID:     "unknown", // Extract from client
-		TranscriptPath: "unknown", // Extract from client
-		Cwd:           "unknown", // Extract from client
+// runHook executes a single hook, treating a panic or an expired context as
+// a "continue" result so one misbehaving hook cannot wedge the conversation.
+// The outcome tells these apart from the hook returning.
+func (hs *hookSystem) runHook(ctx context.Context, hook HookCallback, input interface{}, hookCtx HookContext) (HookOutput, hookOutcome, error) {
+	type hookResult struct {
+		output   HookOutput
+		err      error
+		panicked bool
+	}
+	resultChan := make(chan hookResult, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				resultChan <- hookResult{output: HookOutput{Behavior: HookBehaviorContinue}, panicked: true}
+			}
+		}()
+		pprof.Do(ctx, goroutineLabels(hookCtx.SessionID, GoroutineRoleHook), func(ctx context.Context) {
+			output, err := hook(ctx, input, hookCtx)
+			resultChan <- hookResult{output: output, err: err}
+		})
+	}()
+
+	select {
+	case result := <-resultChan:
+		if result.panicked {
+			return result.output, hookFailed, nil
+		}
+		return result.output, hookReturned, result.err
+	case <-ctx.Done():
+		return HookOutput{Behavior: HookBehaviorContinue}, hookTimedOut, nil
 	}
 }
 
-// patternMatches checks if an event type matches a pattern
-func (hs *hookSystem) patternMatches(eventType HookEventType, pattern string) bool {
+// withClockTimeout returns a copy of ctx canceled once clock reaches d from
+// now, like context.WithTimeout.
+func withClockTimeout(ctx context.Context, clock Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	timeoutCtx, cancel := context.WithCancel(ctx)
+	timer := clock.NewTimer(d)
+	go func() {
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+			cancel()
+		case <-timeoutCtx.Done():
+		}
+	}()
+	return timeoutCtx, cancel
+}
+
+// patternMatches checks if an event matches a pattern.
+// A pattern matches on the wildcard "*", the event type name, or, for tool
+// events, the name of the tool being used.
+func (hs *hookSystem) patternMatches(eventType HookEventType, pattern string, input interface{}) bool {
 	switch pattern {
 	case "*":
 		return true // Wildcard matches all events
 	case string(eventType):
 		return true // Exact match
+	}
+
+	toolName := hookInputToolName(input)
+	return toolName != "" && toolName == pattern
+}
+
+// hookInputToolName extracts the tool name from tool-related hook inputs.
+func hookInputToolName(input interface{}) string {
+	switch in := input.(type) {
+	case PreToolUseHookInput:
+		return in.ToolName
+	case *PreToolUseHookInput:
+		return in.ToolName
+	case PostToolUseHookInput:
+		return in.ToolName
+	case *PostToolUseHookInput:
+		return in.ToolName
 	default:
-		// TODO: Support more sophisticated pattern matching
-		return false
+		return ""
 	}
-}
\ No newline at end of file
+}
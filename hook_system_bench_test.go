@@ -0,0 +1,112 @@
+package claudecode
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+// benchHookPatterns is the number of tool patterns registered in the
+// hook system benchmarks, besides the one the event matches.
+const benchHookPatterns = 20
+
+func newBenchHookSystem(b *testing.B) *hookSystem {
+	b.Helper()
+	hs := newHookSystem(SystemClock{})
+	noop := func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+		return HookOutput{Behavior: HookBehaviorContinue}, nil
+	}
+	for i := 0; i < benchHookPatterns; i++ {
+		if err := hs.AddHook("tool_"+strconv.Itoa(i), noop); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := hs.AddHook("Bash", noop, noop); err != nil {
+		b.Fatal(err)
+	}
+	return hs
+}
+
+var benchHookInput = PreToolUseHookInput{ToolName: "Bash"}
+
+func BenchmarkExecuteHooks(b *testing.B) {
+	hs := newBenchHookSystem(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := hs.ExecuteHooks(ctx, HookEventTypePreToolUse, benchHookInput); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkExecuteHooksParallel runs events from every processor at once.
+func BenchmarkExecuteHooksParallel(b *testing.B) {
+	hs := newBenchHookSystem(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := hs.ExecuteHooks(ctx, HookEventTypePreToolUse, benchHookInput); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkExecuteHooksWithRegistration runs events while one goroutine
+// keeps adding and removing a hook, as a client registering hooks mid
+// conversation would.
+func BenchmarkExecuteHooksWithRegistration(b *testing.B) {
+	hs := newBenchHookSystem(b)
+	ctx := context.Background()
+	noop := func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+		return HookOutput{Behavior: HookBehaviorContinue}, nil
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_ = hs.AddHook("Read", noop)
+			_ = hs.RemoveHook("Read")
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := hs.ExecuteHooks(ctx, HookEventTypePreToolUse, benchHookInput); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	<-done
+}
+
+// BenchmarkAddRemoveHook measures registration, which copies the
+// registered hooks.
+func BenchmarkAddRemoveHook(b *testing.B) {
+	hs := newBenchHookSystem(b)
+	noop := func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+		return HookOutput{Behavior: HookBehaviorContinue}, nil
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = hs.AddHook("Read", noop)
+		_ = hs.RemoveHook("Read")
+	}
+}
@@ -2,6 +2,8 @@ package claudecode
 
 import (
 	"context"
+	"path/filepath"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -277,19 +279,54 @@ func TestHookSystem(t *testing.T) {
 	})
 }
 
+// TestHookSystemOrdering tests that hooks run in the order their patterns
+// were first registered.
+func TestHookSystemOrdering(t *testing.T) {
+	hs := NewHookSystem()
+	var order []string
+	recordHook := func(name string) HookCallback {
+		return func(context.Context, interface{}, HookContext) (HookOutput, error) {
+			order = append(order, name)
+			return HookOutput{Behavior: HookBehaviorContinue}, nil
+		}
+	}
+
+	_ = hs.AddHook("PreToolUse", recordHook("event-1"))
+	_ = hs.AddHook("*", recordHook("wildcard"))
+	_ = hs.AddHook("Bash", recordHook("tool"))
+	_ = hs.AddHook("PreToolUse", recordHook("event-2"))
+	_ = hs.AddHook("Audit", recordHook("unused"))
+
+	// Removing and re-adding a pattern moves it to the end
+	_ = hs.RemoveHook("*")
+	_ = hs.AddHook("*", recordHook("wildcard"))
+
+	want := []string{"event-1", "event-2", "tool", "wildcard"}
+	for i := 0; i < 20; i++ {
+		order = nil
+		_, err := hs.ExecuteHooks(context.Background(), HookEventTypePreToolUse, PreToolUseHookInput{ToolName: "Bash"})
+		if err != nil {
+			t.Fatalf("ExecuteHooks failed: %v", err)
+		}
+		if !reflect.DeepEqual(order, want) {
+			t.Fatalf("Expected hooks in registration order %v, got %v", want, order)
+		}
+	}
+}
+
 // TestHookInputTypes tests hook input data structures.
 func TestHookInputTypes(t *testing.T) {
 	t.Run("PreToolUseHookInput", func(t *testing.T) {
 		input := PreToolUseHookInput{
 			BaseHookInput: BaseHookInput{
-				SessionID:     "session123",
+				SessionID:      "session123",
 				TranscriptPath: "/path/to/transcript",
-				Cwd:           "/working/dir",
+				Cwd:            "/working/dir",
 				PermissionMode: func() *string { s := "accept"; return &s }(),
 			},
 			HookEventName: HookEventTypePreToolUse,
-			ToolName:     "test_tool",
-			ToolInput:    map[string]any{"arg": "value"},
+			ToolName:      "test_tool",
+			ToolInput:     map[string]any{"arg": "value"},
 		}
 
 		if input.SessionID != "session123" {
@@ -322,9 +359,9 @@ func TestHookInputTypes(t *testing.T) {
 				SessionID: "session123",
 			},
 			HookEventName: HookEventTypePostToolUse,
-			ToolName:     "test_tool",
-			ToolInput:    map[string]any{"arg": "value"},
-			ToolResponse: response,
+			ToolName:      "test_tool",
+			ToolInput:     map[string]any{"arg": "value"},
+			ToolResponse:  response,
 		}
 
 		// Convert ToolResponse to map for comparison
@@ -349,7 +386,7 @@ func TestHookInputTypes(t *testing.T) {
 				SessionID: "session123",
 			},
 			HookEventName: HookEventTypeUserPromptSubmit,
-			Prompt:       "Test prompt message",
+			Prompt:        "Test prompt message",
 		}
 
 		if input.Prompt != "Test prompt message" {
@@ -378,7 +415,7 @@ func TestHookInputTypes(t *testing.T) {
 				SessionID: "session123",
 			},
 			HookEventName:      HookEventTypePreCompact,
-			Trigger:           "manual",
+			Trigger:            "manual",
 			CustomInstructions: &instructions,
 		}
 
@@ -447,9 +484,9 @@ func TestHookOutput(t *testing.T) {
 func TestHookContext(t *testing.T) {
 	t.Run("HookContext fields", func(t *testing.T) {
 		context := HookContext{
-			SessionID:     "session123",
+			SessionID:      "session123",
 			TranscriptPath: "/path/to/transcript",
-			Cwd:           "/working/dir",
+			Cwd:            "/working/dir",
 		}
 
 		if context.SessionID != "session123" {
@@ -462,4 +499,181 @@ func TestHookContext(t *testing.T) {
 			t.Errorf("Expected Cwd '/working/dir', got: %s", context.Cwd)
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestHookSystemTimeoutUsesClock(t *testing.T) {
+	clock := newManualClock()
+	hs := newHookSystem(clock)
+	canceled := make(chan struct{})
+	hs.AddHook("test_tool", func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+		<-ctx.Done()
+		close(canceled)
+		return HookOutput{Behavior: HookBehaviorStop}, nil
+	})
+
+	results := make(chan *HookOutput, 1)
+	go func() {
+		result, err := hs.ExecuteHooks(context.Background(), HookEventTypePreToolUse, PreToolUseHookInput{ToolName: "test_tool"})
+		if err != nil {
+			t.Errorf("ExecuteHooks failed: %v", err)
+		}
+		results <- result
+	}()
+
+	timer := clock.waitForTimer(t)
+	if timer.d != hookTimeout {
+		t.Errorf("Expected a %v timer, got %v", hookTimeout, timer.d)
+	}
+	timer.fire()
+
+	select {
+	case result := <-results:
+		if result == nil || result.Behavior != HookBehaviorContinue {
+			t.Errorf("Expected a timed out hook to continue, got %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ExecuteHooks did not time out when the clock fired")
+	}
+	<-canceled
+}
+
+func TestHookSystemRegistrationDuringExecution(t *testing.T) {
+	hs := NewHookSystem()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls []string
+	var mu sync.Mutex
+	record := func(name string) HookCallback {
+		return func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+			return HookOutput{Behavior: HookBehaviorContinue}, nil
+		}
+	}
+	hs.AddHook("test_tool", func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+		close(started)
+		<-release
+		return HookOutput{Behavior: HookBehaviorContinue}, nil
+	}, record("second"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := hs.ExecuteHooks(context.Background(), HookEventTypePreToolUse, PreToolUseHookInput{ToolName: "test_tool"}); err != nil {
+			t.Errorf("ExecuteHooks failed: %v", err)
+		}
+	}()
+	<-started
+
+	registered := make(chan struct{})
+	go func() {
+		defer close(registered)
+		hs.AddHook("test_tool", record("added"))
+		hs.AddHook("*", record("wildcard"))
+		hs.RemoveHook("*")
+	}()
+	select {
+	case <-registered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Registration blocked behind a running hook")
+	}
+	close(release)
+	<-done
+
+	mu.Lock()
+	got := append([]string(nil), calls...)
+	calls = nil
+	mu.Unlock()
+	if !reflect.DeepEqual(got, []string{"second"}) {
+		t.Errorf("Expected the running event to keep the hooks it started with, got %v", got)
+	}
+}
+
+func TestHookSystemSnapshotsAreIndependent(t *testing.T) {
+	hs := newHookSystem(SystemClock{})
+	noop := func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+		return HookOutput{Behavior: HookBehaviorContinue}, nil
+	}
+	hs.AddHook("a", noop)
+	before := hs.load()
+	hs.AddHook("a", noop)
+	hs.AddHook("b", noop)
+	hs.RemoveHook("a")
+
+	if len(before.matchers["a"]) != 1 || len(before.patterns) != 1 {
+		t.Errorf("Expected an earlier snapshot to be unchanged, got %d hooks and patterns %v",
+			len(before.matchers["a"]), before.patterns)
+	}
+	after := hs.load()
+	if !reflect.DeepEqual(after.patterns, []string{"b"}) || len(after.matchers) != 1 {
+		t.Errorf("Expected only pattern b to remain, got %v", after.patterns)
+	}
+}
+
+func TestHookContextFromInput(t *testing.T) {
+	mode := "acceptEdits"
+	hs := newHookSystem(SystemClock{})
+	got := hs.createHookContext(&PreToolUseHookInput{BaseHookInput: BaseHookInput{
+		SessionID:      "session-1",
+		TranscriptPath: "/tmp/session-1.jsonl",
+		Cwd:            "/work",
+		PermissionMode: &mode,
+	}})
+	want := HookContext{
+		SessionID:      "session-1",
+		TranscriptPath: "/tmp/session-1.jsonl",
+		Cwd:            "/work",
+		PermissionMode: PermissionModeAcceptEdits,
+	}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	hs.session = func() HookContext { return HookContext{SessionID: "from-client", Model: "claude-test"} }
+	got = hs.createHookContext(StopHookInput{BaseHookInput: BaseHookInput{SessionID: "from-input", Cwd: "/work"}})
+	if got.SessionID != "from-client" || got.Cwd != "/work" || got.Model != "claude-test" {
+		t.Errorf("Expected the client's context filled in from the input, got %+v", got)
+	}
+}
+
+func TestClientHookContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	configDir := t.TempDir()
+	init := initMessage("Bash")
+	init.Data["session_id"] = "session-42"
+	init.Data["cwd"] = "/home/dev/my_app"
+	init.Data["model"] = "claude-sonnet-4-5"
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		init,
+		&ResultMessage{Subtype: "success", SessionID: "session-42"},
+	}))
+	client := NewClientWithTransport(transport, WithPermissionMode(PermissionModePlan),
+		WithEnvVar("CLAUDE_CONFIG_DIR", configDir)).(*ClientImpl)
+
+	var got HookContext
+	if _, err := client.Hooks().Add(HookEventTypeSessionEnd, HookMatcher{Hooks: []HookCallback{
+		func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+			got = hookCtx
+			return HookOutput{Behavior: HookBehaviorContinue}, nil
+		},
+	}}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	connectClientSafely(ctx, t, client)
+	awaitClientResult(ctx, t, client)
+	disconnectClientSafely(t, client)
+
+	want := HookContext{
+		SessionID:      "session-42",
+		TranscriptPath: filepath.Join(configDir, "projects", "-home-dev-my-app", "session-42.jsonl"),
+		Cwd:            "/home/dev/my_app",
+		PermissionMode: PermissionModePlan,
+		Model:          "claude-sonnet-4-5",
+	}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
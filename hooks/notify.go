@@ -0,0 +1,186 @@
+// Package hooks is a library of reusable client hooks for common tasks,
+// such as reporting what an autonomous agent does to a team's chat.
+//
+//	client := claudecode.NewClient(
+//		hooks.NotifySlack(os.Getenv("SLACK_WEBHOOK_URL"), hooks.EventToolDenied, hooks.EventError),
+//	)
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Event selects what a notifier reports.
+type Event string
+
+const (
+	// EventToolDenied reports the tool calls denied during a turn.
+	EventToolDenied Event = "tool_denied"
+	// EventSessionEnd reports a session's turns, cost and duration when the
+	// client tears it down.
+	EventSessionEnd Event = "session_end"
+	// EventError reports turns that ended in an error and API errors.
+	EventError Event = "error"
+)
+
+// notifyTimeout bounds each chat webhook request.
+const notifyTimeout = 10 * time.Second
+
+// NotifySlack posts a short summary of the selected events, or all of
+// them, to a Slack incoming webhook.
+//
+// Notifications are sent in the background and are best effort: a failed
+// post is dropped. The notifier chains the finalizer set so far, so it
+// must come after WithFinalizer, and like other message observers it
+// applies to the Client and not to the Query function.
+func NotifySlack(webhookURL string, events ...Event) claudecode.Option {
+	return notify(webhookURL, func(text string) any {
+		return map[string]string{"text": text}
+	}, events)
+}
+
+// NotifyDiscord posts a short summary of the selected events, or all of
+// them, to a Discord webhook. It works like NotifySlack.
+func NotifyDiscord(webhookURL string, events ...Event) claudecode.Option {
+	return notify(webhookURL, func(text string) any {
+		return map[string]string{"content": text}
+	}, events)
+}
+
+// notifier turns client messages into chat notifications.
+type notifier struct {
+	url     string
+	payload func(text string) any
+	events  map[Event]bool
+	client  *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+
+	// wg tracks posts in flight, for tests.
+	wg sync.WaitGroup
+}
+
+func notify(url string, payload func(string) any, events []Event) claudecode.Option {
+	return func(o *claudecode.Options) {
+		n := newNotifier(url, payload, events)
+		o.MessageObservers = append(o.MessageObservers, n.observe)
+		next := o.Finalizer
+		o.Finalizer = func(summary claudecode.SessionSummary) {
+			n.sessionEnded(summary)
+			if next != nil {
+				next(summary)
+			}
+		}
+	}
+}
+
+func newNotifier(url string, payload func(string) any, events []Event) *notifier {
+	n := &notifier{url: url, payload: payload, client: &http.Client{Timeout: notifyTimeout}}
+	if len(events) > 0 {
+		n.events = make(map[Event]bool, len(events))
+		for _, event := range events {
+			n.events[event] = true
+		}
+	}
+	return n
+}
+
+func (n *notifier) observe(msg claudecode.Message) {
+	switch m := msg.(type) {
+	case *claudecode.SystemMessage:
+		if info, ok := m.Init(); ok {
+			n.setSession(info.SessionID)
+		}
+	case *claudecode.AssistantMessage:
+		if m.Error != nil {
+			n.post(EventError, fmt.Sprintf("Agent %s hit an API error: %s", n.sessionLabel(), *m.Error))
+		}
+	case *claudecode.ResultMessage:
+		n.setSession(m.SessionID)
+		if len(m.PermissionDenials) > 0 {
+			tools := make([]string, len(m.PermissionDenials))
+			for i, denial := range m.PermissionDenials {
+				tools[i] = "`" + denial.ToolName + "`"
+			}
+			n.post(EventToolDenied, fmt.Sprintf("Agent %s was denied %s: %s",
+				n.sessionLabel(), plural(len(tools), "tool call"), strings.Join(tools, ", ")))
+		}
+		if m.IsError {
+			text := fmt.Sprintf("Agent %s turn failed: %s after %s", n.sessionLabel(), m.Subtype, plural(m.NumTurns, "turn"))
+			if m.TotalCostUSD != nil {
+				text += fmt.Sprintf(" ($%.4f)", *m.TotalCostUSD)
+			}
+			n.post(EventError, text)
+		}
+	}
+}
+
+func (n *notifier) sessionEnded(summary claudecode.SessionSummary) {
+	if summary.SessionID != "" {
+		n.setSession(summary.SessionID)
+	}
+	n.post(EventSessionEnd, fmt.Sprintf("Agent %s ended (%s): %s, $%.4f, %s",
+		n.sessionLabel(), summary.Reason, plural(summary.NumTurns, "turn"), summary.TotalCostUSD,
+		summary.Duration.Round(100*time.Millisecond)))
+	n.setSession("")
+}
+
+func (n *notifier) setSession(id string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sessionID = id
+}
+
+// sessionLabel names the current session in a notification.
+func (n *notifier) sessionLabel() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.sessionID == "" {
+		return "session"
+	}
+	return "session `" + n.sessionID + "`"
+}
+
+// post sends text in the background if event is selected.
+func (n *notifier) post(event Event, text string) {
+	if n.events != nil && !n.events[event] {
+		return
+	}
+	body, err := json.Marshal(n.payload(text))
+	if err != nil {
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, n.url, bytes.NewReader(body))
+		if err != nil {
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := n.client.Do(req)
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+	}()
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,134 @@
+package hooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestNotifierMessages(t *testing.T) {
+	server, received := chatServer(t)
+	defer server.Close()
+
+	n := newNotifier(server.URL, func(text string) any { return map[string]string{"text": text} }, nil)
+	cost := 0.5
+	rateLimit := claudecode.AssistantMessageErrorRateLimit
+
+	n.observe(&claudecode.SystemMessage{Subtype: claudecode.SystemSubtypeInit, Data: map[string]any{"session_id": "sess-1"}})
+	n.observe(&claudecode.AssistantMessage{Error: &rateLimit})
+	n.observe(&claudecode.ResultMessage{
+		Subtype:   "error_max_turns",
+		IsError:   true,
+		SessionID: "sess-1",
+		NumTurns:  10,
+		PermissionDenials: []claudecode.PermissionDenial{
+			{ToolName: "Bash"}, {ToolName: "Write"},
+		},
+		TotalCostUSD: &cost,
+	})
+	n.sessionEnded(claudecode.SessionSummary{
+		SessionID:    "sess-1",
+		Reason:       claudecode.SessionEndDisconnect,
+		NumTurns:     1,
+		TotalCostUSD: 0.5,
+		Duration:     12345 * time.Millisecond,
+	})
+	n.wg.Wait()
+
+	want := map[string]bool{
+		"Agent session `sess-1` hit an API error: rate_limit":                          true,
+		"Agent session `sess-1` was denied 2 tool calls: `Bash`, `Write`":              true,
+		"Agent session `sess-1` turn failed: error_max_turns after 10 turns ($0.5000)": true,
+		"Agent session `sess-1` ended (disconnect): 1 turn, $0.5000, 12.3s":            true,
+	}
+	got := received()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d notifications, got %d: %v", len(want), len(got), got)
+	}
+	for _, payload := range got {
+		if !want[payload["text"]] {
+			t.Errorf("Unexpected notification %q", payload["text"])
+		}
+	}
+}
+
+func TestNotifierEventSelection(t *testing.T) {
+	server, received := chatServer(t)
+	defer server.Close()
+
+	n := newNotifier(server.URL, func(text string) any { return map[string]string{"content": text} }, []Event{EventToolDenied})
+	n.observe(&claudecode.ResultMessage{Subtype: "error_during_execution", IsError: true})
+	n.sessionEnded(claudecode.SessionSummary{})
+	n.observe(&claudecode.ResultMessage{PermissionDenials: []claudecode.PermissionDenial{{ToolName: "Bash"}}})
+	n.wg.Wait()
+
+	got := received()
+	if len(got) != 1 || got[0]["content"] != "Agent session was denied 1 tool call: `Bash`" {
+		t.Errorf("Expected only the denial, got %v", got)
+	}
+}
+
+func TestNotifyOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		option func(url string, events ...Event) claudecode.Option
+		field  string
+	}{
+		{"slack", NotifySlack, "text"},
+		{"discord", NotifyDiscord, "content"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, received := chatServer(t)
+			defer server.Close()
+
+			var finalized int
+			options := claudecode.NewOptions(
+				claudecode.WithFinalizer(func(claudecode.SessionSummary) { finalized++ }),
+				test.option(server.URL, EventSessionEnd),
+			)
+			if len(options.MessageObservers) != 1 {
+				t.Fatalf("Expected a message observer, got %d", len(options.MessageObservers))
+			}
+			options.Finalizer(claudecode.SessionSummary{SessionID: "sess-2", Reason: claudecode.SessionEndProcessExit})
+			if finalized != 1 {
+				t.Errorf("Expected the earlier finalizer to run, got %d calls", finalized)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for len(received()) == 0 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			got := received()
+			if len(got) != 1 || got[0][test.field] != "Agent session `sess-2` ended (process_exit): 0 turns, $0.0000, 0s" {
+				t.Errorf("Expected a session summary in %q, got %v", test.field, got)
+			}
+		})
+	}
+}
+
+// chatServer records the JSON payloads posted to it.
+func chatServer(t *testing.T) (*httptest.Server, func() []map[string]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var payloads []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Decoding payload failed: %v", err)
+		}
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+	}))
+	return server, func() []map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]map[string]string(nil), payloads...)
+	}
+}
@@ -0,0 +1,288 @@
+// Package httpbridge exposes the Claude Code SDK over HTTP.
+//
+// NewChatHandler serves a Server-Sent Events endpoint and NewWebSocketHandler
+// serves the same event stream over a WebSocket. Both draw clients from a
+// claudecode.ClientPool and keep session affinity, so follow-up prompts for a
+// session reach the CLI process that holds its conversation context.
+//
+// Browsers send any site's requests to these endpoints, so requests from
+// pages of other origins are rejected unless listed with WithAllowedOrigins.
+//
+// Example:
+//
+//	pool := claudecode.NewClientPool(4)
+//	defer pool.Close()
+//
+//	http.Handle("/chat", httpbridge.NewChatHandler(pool))
+//	http.Handle("/chat/ws", httpbridge.NewWebSocketHandler(pool))
+package httpbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+	"github.com/severity1/claude-code-sdk-go/internal/bridge"
+)
+
+const (
+	// DefaultSessionHeader is the request header carrying the session ID.
+	DefaultSessionHeader = "X-Session-ID"
+	// DefaultMaxPromptBytes is the default limit for request bodies.
+	DefaultMaxPromptBytes = 1024 * 1024
+	// DefaultDrainTimeout bounds how long an interrupted turn is drained
+	// before its client is discarded.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+// Event types emitted to HTTP clients.
+const (
+	EventDelta    = "delta"
+	EventThinking = "thinking"
+	EventToolUse  = "tool_use"
+	EventResult   = "result"
+	EventError    = "error"
+)
+
+// ChatRequest is the JSON body accepted by the handlers.
+type ChatRequest struct {
+	Prompt    string `json:"prompt"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// Event is a single item of the response stream.
+type Event struct {
+	Type string `json:"type"`
+	Data any    `json:"data,omitempty"`
+}
+
+// TextData is the payload of delta and thinking events.
+type TextData struct {
+	Text string `json:"text"`
+}
+
+// ToolUseData is the payload of tool_use events.
+type ToolUseData struct {
+	ID    string         `json:"id"`
+	Name  string         `json:"name"`
+	Input map[string]any `json:"input,omitempty"`
+}
+
+// ResultData is the payload of result events.
+type ResultData struct {
+	SessionID    string   `json:"session_id"`
+	IsError      bool     `json:"is_error"`
+	NumTurns     int      `json:"num_turns"`
+	DurationMs   int      `json:"duration_ms"`
+	TotalCostUSD *float64 `json:"total_cost_usd,omitempty"`
+	Result       *string  `json:"result,omitempty"`
+}
+
+// ErrorData is the payload of error events.
+type ErrorData struct {
+	Message string `json:"message"`
+}
+
+// Option configures the handlers.
+type Option func(*config)
+
+type config struct {
+	sessionHeader  string
+	maxPromptBytes int64
+	drainTimeout   time.Duration
+	allowedOrigins []string
+}
+
+// WithSessionHeader sets the header used to read the session ID when the
+// request body doesn't carry one.
+func WithSessionHeader(name string) Option {
+	return func(c *config) {
+		c.sessionHeader = name
+	}
+}
+
+// WithMaxPromptBytes limits the size of request bodies and WebSocket messages.
+func WithMaxPromptBytes(n int64) Option {
+	return func(c *config) {
+		c.maxPromptBytes = n
+	}
+}
+
+// WithDrainTimeout sets how long an interrupted turn is drained before the
+// client is discarded from the pool.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.drainTimeout = d
+	}
+}
+
+// WithAllowedOrigins lists the origins, such as "https://app.example.com",
+// whose pages may use the handlers besides their own. Requests carrying any
+// other Origin header are rejected with 403 Forbidden; requests without one
+// don't come from browsers and are accepted.
+func WithAllowedOrigins(origins ...string) Option {
+	return func(c *config) {
+		c.allowedOrigins = append(c.allowedOrigins, origins...)
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		sessionHeader:  DefaultSessionHeader,
+		maxPromptBytes: DefaultMaxPromptBytes,
+		drainTimeout:   DefaultDrainTimeout,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// allowsOrigin reports whether r comes from a page that may use the
+// handlers: its own origin or one of the allowed origins.
+func (c *config) allowsOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range c.allowedOrigins {
+		if strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// chatHandler serves the Server-Sent Events endpoint.
+type chatHandler struct {
+	pool *claudecode.ClientPool
+	cfg  *config
+}
+
+// NewChatHandler returns an http.Handler that accepts POSTed ChatRequest
+// bodies and streams the turn back as Server-Sent Events. Each event is sent
+// as "event: <type>" with a JSON "data:" line.
+//
+// The session ID comes from the request body or the session header. When
+// the HTTP client goes away the turn is interrupted.
+func NewChatHandler(pool *claudecode.ClientPool, opts ...Option) http.Handler {
+	return &chatHandler{pool: pool, cfg: newConfig(opts)}
+}
+
+func (h *chatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.cfg.allowsOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	req, err := decodeChatRequest(io.LimitReader(r.Body, h.cfg.maxPromptBytes+1), h.cfg.maxPromptBytes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.SessionID == "" {
+		req.SessionID = r.Header.Get(h.cfg.sessionHeader)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	emit := func(ev Event) error {
+		if err := writeSSE(w, ev); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if err := runTurn(r.Context(), h.pool, h.cfg, req, emit); err != nil && r.Context().Err() == nil {
+		_ = emit(Event{Type: EventError, Data: ErrorData{Message: err.Error()}})
+	}
+}
+
+// decodeChatRequest parses and validates a ChatRequest.
+func decodeChatRequest(r io.Reader, maxBytes int64) (ChatRequest, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ChatRequest{}, fmt.Errorf("failed to read request: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return ChatRequest{}, fmt.Errorf("request exceeds %d bytes", maxBytes)
+	}
+
+	var req ChatRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return ChatRequest{}, fmt.Errorf("invalid request body: %w", err)
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		return ChatRequest{}, errors.New("prompt is required")
+	}
+	return req, nil
+}
+
+// writeSSE writes one Server-Sent Event.
+func writeSSE(w io.Writer, ev Event) error {
+	data, err := json.Marshal(ev.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+	return err
+}
+
+// runTurn sends one prompt on a pooled client and emits the turn's events
+// until its result.
+func runTurn(ctx context.Context, pool *claudecode.ClientPool, cfg *config, req ChatRequest, emit func(Event) error) error {
+	turn := bridge.Turn{Prompt: req.Prompt, SessionID: req.SessionID, DrainTimeout: cfg.drainTimeout}
+	return bridge.Run(ctx, pool, turn, func(ev bridge.Event) error {
+		return emit(wireEvent(ev))
+	})
+}
+
+// wireEvent converts a turn event into the Event sent to HTTP clients.
+func wireEvent(ev bridge.Event) Event {
+	switch ev.Kind {
+	case bridge.EventDelta:
+		return Event{Type: EventDelta, Data: TextData{Text: ev.Text}}
+	case bridge.EventThinking:
+		return Event{Type: EventThinking, Data: TextData{Text: ev.Text}}
+	case bridge.EventToolUse:
+		return Event{Type: EventToolUse, Data: ToolUseData{
+			ID:    ev.ToolUse.ToolUseID,
+			Name:  ev.ToolUse.Name,
+			Input: ev.ToolUse.Input,
+		}}
+	default:
+		m := ev.Result
+		return Event{Type: EventResult, Data: ResultData{
+			SessionID:    m.SessionID,
+			IsError:      m.IsError,
+			NumTurns:     m.NumTurns,
+			DurationMs:   m.DurationMs,
+			TotalCostUSD: m.TotalCostUSD,
+			Result:       m.Result,
+		}}
+	}
+}
@@ -0,0 +1,298 @@
+package httpbridge
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestChatHandlerStreamsEvents(t *testing.T) {
+	pool, transport := setupBridgePool(t)
+	server := httptest.NewServer(NewChatHandler(pool))
+	defer server.Close()
+
+	resp := postChat(t, server.URL, `{"prompt":"hello","session_id":"alice"}`, nil)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %q", ct)
+	}
+
+	events := readSSEEvents(t, resp)
+	assertEventTypes(t, events, EventDelta, EventResult)
+	if got := decodeTextData(t, events[0]); got != "echo: hello" {
+		t.Errorf("Expected delta %q, got %q", "echo: hello", got)
+	}
+	if got := transport.lastSessionID(); got != "alice" {
+		t.Errorf("Expected session ID alice, got %q", got)
+	}
+}
+
+func TestChatHandlerSessionHeader(t *testing.T) {
+	pool, transport := setupBridgePool(t)
+	server := httptest.NewServer(NewChatHandler(pool, WithSessionHeader("X-Conversation")))
+	defer server.Close()
+
+	resp := postChat(t, server.URL, `{"prompt":"hi"}`, map[string]string{"X-Conversation": "bob"})
+	defer resp.Body.Close()
+	readSSEEvents(t, resp)
+
+	if got := transport.lastSessionID(); got != "bob" {
+		t.Errorf("Expected session ID from header, got %q", got)
+	}
+}
+
+func TestChatHandlerRejectsBadRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		body       string
+		wantStatus int
+	}{
+		{"method_not_allowed", http.MethodGet, "", "", http.StatusMethodNotAllowed},
+		{"other_origin", http.MethodPost, "https://evil.example.com", `{"prompt":"hi"}`, http.StatusForbidden},
+		{"invalid_json", http.MethodPost, "", `{"prompt":`, http.StatusBadRequest},
+		{"empty_prompt", http.MethodPost, "", `{"prompt":"  "}`, http.StatusBadRequest},
+		{"too_large", http.MethodPost, "", `{"prompt":"` + strings.Repeat("x", 64) + `"}`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pool, _ := setupBridgePool(t)
+			handler := NewChatHandler(pool, WithMaxPromptBytes(32))
+
+			req := httptest.NewRequest(test.method, "/chat", strings.NewReader(test.body))
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != test.wantStatus {
+				t.Errorf("Expected status %d, got %d", test.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestChatHandlerCancellationInterruptsTurn(t *testing.T) {
+	pool, transport := setupBridgePool(t)
+	server := httptest.NewServer(NewChatHandler(pool))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL,
+		strings.NewReader(`{"prompt":"hang","session_id":"alice"}`))
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	transport.waitForPrompt(t, "hang")
+	cancel()
+
+	transport.waitForInterrupt(t)
+
+	// The drained client goes back to the pool and serves the next turn
+	next := postChat(t, server.URL, `{"prompt":"again","session_id":"alice"}`, nil)
+	defer next.Body.Close()
+	assertEventTypes(t, readSSEEvents(t, next), EventDelta, EventResult)
+	if got := pool.Size(); got != 1 {
+		t.Errorf("Expected interrupted client to be reused, pool size %d", got)
+	}
+}
+
+// Mock Transport Implementation
+
+// bridgeMockTransport answers each prompt with an echo and a result. The
+// prompt "hang" produces no output until Interrupt is called.
+type bridgeMockTransport struct {
+	mu          sync.Mutex
+	msgChan     chan claudecode.Message
+	errChan     chan error
+	prompts     []string
+	sessionIDs  []string
+	interrupted chan struct{}
+	hanging     bool
+}
+
+func newBridgeMockTransport() *bridgeMockTransport {
+	return &bridgeMockTransport{
+		msgChan:     make(chan claudecode.Message, 10),
+		errChan:     make(chan error, 1),
+		interrupted: make(chan struct{}, 1),
+	}
+}
+
+func (b *bridgeMockTransport) Connect(_ context.Context) error { return nil }
+
+func (b *bridgeMockTransport) SendMessage(_ context.Context, message claudecode.StreamMessage) error {
+	payload, _ := message.Message.(map[string]interface{})
+	prompt, _ := payload["content"].(string)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prompts = append(b.prompts, prompt)
+	b.sessionIDs = append(b.sessionIDs, message.SessionID)
+
+	if prompt == "hang" {
+		b.hanging = true
+		return nil
+	}
+	b.msgChan <- &claudecode.AssistantMessage{
+		Content: []claudecode.ContentBlock{&claudecode.TextBlock{Text: "echo: " + prompt}},
+		Model:   "claude-sonnet-4-5",
+	}
+	b.msgChan <- &claudecode.ResultMessage{Subtype: "success", SessionID: message.SessionID}
+	return nil
+}
+
+func (b *bridgeMockTransport) ReceiveMessages(_ context.Context) (<-chan claudecode.Message, <-chan error) {
+	return b.msgChan, b.errChan
+}
+
+func (b *bridgeMockTransport) Interrupt(_ context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.hanging {
+		b.hanging = false
+		b.msgChan <- &claudecode.ResultMessage{Subtype: "error_during_execution", IsError: true}
+	}
+	select {
+	case b.interrupted <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (b *bridgeMockTransport) Close() error { return nil }
+
+func (b *bridgeMockTransport) GetValidator() *claudecode.StreamValidator {
+	return &claudecode.StreamValidator{}
+}
+
+func (b *bridgeMockTransport) lastSessionID() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.sessionIDs) == 0 {
+		return ""
+	}
+	return b.sessionIDs[len(b.sessionIDs)-1]
+}
+
+func (b *bridgeMockTransport) waitForPrompt(t *testing.T, prompt string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		for _, p := range b.prompts {
+			if p == prompt {
+				b.mu.Unlock()
+				return
+			}
+		}
+		b.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("prompt %q was never sent", prompt)
+}
+
+func (b *bridgeMockTransport) waitForInterrupt(t *testing.T) {
+	t.Helper()
+	select {
+	case <-b.interrupted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn was not interrupted")
+	}
+}
+
+// Helper functions
+
+func setupBridgePool(t *testing.T) (*claudecode.ClientPool, *bridgeMockTransport) {
+	t.Helper()
+	transport := newBridgeMockTransport()
+	pool := claudecode.NewClientPoolWithFactory(1, func() claudecode.Client {
+		return claudecode.NewClientWithTransport(transport)
+	})
+	t.Cleanup(func() { _ = pool.Close() })
+	return pool, transport
+}
+
+func postChat(t *testing.T, url, body string, headers map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	return resp
+}
+
+// readSSEEvents reads events until the result or error event.
+func readSSEEvents(t *testing.T, resp *http.Response) []Event {
+	t.Helper()
+	var events []Event
+	var eventType string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			events = append(events, Event{Type: eventType, Data: json.RawMessage(strings.TrimPrefix(line, "data: "))})
+			if eventType == EventResult || eventType == EventError {
+				return events
+			}
+		}
+	}
+	t.Fatalf("stream ended without result event: %v", scanner.Err())
+	return nil
+}
+
+func assertEventTypes(t *testing.T, events []Event, want ...string) {
+	t.Helper()
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i, ev := range events {
+		if ev.Type != want[i] {
+			t.Errorf("event %d: expected type %q, got %q", i, want[i], ev.Type)
+		}
+	}
+}
+
+func decodeTextData(t *testing.T, ev Event) string {
+	t.Helper()
+	raw, ok := ev.Data.(json.RawMessage)
+	if !ok {
+		t.Fatalf("event data is %T, not raw JSON", ev.Data)
+	}
+	var data TextData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatalf("failed to decode text data: %v", err)
+	}
+	return data.Text
+}
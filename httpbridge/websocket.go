@@ -0,0 +1,331 @@
+package httpbridge
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1" //nolint:gosec // G505: SHA-1 is mandated by RFC 6455 for the handshake
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// websocketGUID is the fixed GUID from RFC 6455 used to compute Sec-WebSocket-Accept.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes (RFC 6455 section 5.2).
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// WebSocket close codes (RFC 6455 section 7.4.1).
+const (
+	closeNormal         = 1000
+	closeProtocolError  = 1002
+	closeUnsupported    = 1003
+	closeMessageTooBig  = 1009
+	maxControlFrameSize = 125
+)
+
+// errWebSocketClosed is returned by readMessage after the peer closed the connection.
+var errWebSocketClosed = errors.New("websocket closed")
+
+// webSocketHandler serves the WebSocket endpoint.
+type webSocketHandler struct {
+	pool *claudecode.ClientPool
+	cfg  *config
+}
+
+// NewWebSocketHandler returns an http.Handler that upgrades the request to a
+// WebSocket. Each text message from the peer is a ChatRequest; turns run one
+// at a time and every Event is sent back as a JSON text message. Closing the
+// socket interrupts the turn in flight.
+//
+// The session ID of a request falls back to the session header of the
+// handshake request.
+func NewWebSocketHandler(pool *claudecode.ClientPool, opts ...Option) http.Handler {
+	return &webSocketHandler{pool: pool, cfg: newConfig(opts)}
+}
+
+func (h *webSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Browsers don't apply the same-origin policy to WebSockets
+	if !h.cfg.allowsOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer ws.conn.Close()
+
+	defaultSession := r.Header.Get(h.cfg.sessionHeader)
+
+	// The reader goroutine owns all reads; a read failure or close frame
+	// cancels ctx, which interrupts the turn in flight.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	requests := make(chan ChatRequest)
+	go func() {
+		defer cancel()
+		defer close(requests)
+		for {
+			data, err := ws.readMessage(h.cfg.maxPromptBytes)
+			if err != nil {
+				return
+			}
+			req, err := decodeChatRequest(bytes.NewReader(data), h.cfg.maxPromptBytes)
+			if err != nil {
+				_ = ws.writeJSON(Event{Type: EventError, Data: ErrorData{Message: err.Error()}})
+				continue
+			}
+			if req.SessionID == "" {
+				req.SessionID = defaultSession
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// requests is closed once the peer is gone, and readMessage has already
+	// answered a close frame by then.
+	emit := func(ev Event) error { return ws.writeJSON(ev) }
+	for req := range requests {
+		err := runTurn(ctx, h.pool, h.cfg, req, emit)
+		if err != nil && ctx.Err() == nil {
+			_ = ws.writeJSON(Event{Type: EventError, Data: ErrorData{Message: err.Error()}})
+		}
+	}
+}
+
+// wsConn is a minimal server-side WebSocket connection.
+type wsConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+}
+
+// upgradeWebSocket validates the handshake and hijacks the connection.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Method != http.MethodGet {
+		return nil, errors.New("websocket upgrade requires GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, errors.New("not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, errors.New("unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("connection cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hijack connection: %w", err)
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + webSocketAccept(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to complete handshake: %w", err)
+	}
+
+	return &wsConn{conn: conn, reader: rw.Reader}, nil
+}
+
+// webSocketAccept computes the Sec-WebSocket-Accept value for key.
+func webSocketAccept(key string) string {
+	h := sha1.New() //nolint:gosec // G401: required by RFC 6455
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContainsToken reports whether a comma-separated header contains token.
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readMessage reads the next complete text or binary message, answering
+// pings and assembling fragmented messages along the way.
+func (c *wsConn) readMessage(maxBytes int64) ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame(maxBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			_ = c.writeClose(closeNormal, "")
+			return nil, errWebSocketClosed
+		case opText, opBinary:
+			if message != nil {
+				_ = c.writeClose(closeProtocolError, "unexpected data frame")
+				return nil, errors.New("websocket: new message before previous finished")
+			}
+			message = payload
+		case opContinuation:
+			if message == nil {
+				_ = c.writeClose(closeProtocolError, "unexpected continuation")
+				return nil, errors.New("websocket: continuation without message")
+			}
+			message = append(message, payload...)
+		default:
+			_ = c.writeClose(closeUnsupported, "unsupported opcode")
+			return nil, fmt.Errorf("websocket: unsupported opcode %d", opcode)
+		}
+
+		if int64(len(message)) > maxBytes {
+			_ = c.writeClose(closeMessageTooBig, "message too big")
+			return nil, fmt.Errorf("websocket: message exceeds %d bytes", maxBytes)
+		}
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// readFrame reads a single frame and unmasks its payload.
+func (c *wsConn) readFrame(maxBytes int64) (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	if !masked {
+		_ = c.writeClose(closeProtocolError, "client frames must be masked")
+		return false, 0, nil, errors.New("websocket: unmasked client frame")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= opClose && length > maxControlFrameSize {
+		_ = c.writeClose(closeProtocolError, "control frame too big")
+		return false, 0, nil, errors.New("websocket: control frame too big")
+	}
+	if length > uint64(maxBytes) {
+		_ = c.writeClose(closeMessageTooBig, "message too big")
+		return false, 0, nil, fmt.Errorf("websocket: frame exceeds %d bytes", maxBytes)
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeJSON sends v as a single text message.
+func (c *wsConn) writeJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	return c.writeFrame(opText, data)
+}
+
+// writeClose sends a close frame with the given status code.
+func (c *wsConn) writeClose(code uint16, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	payload = append(payload, reason...)
+	return c.writeFrame(opClose, payload)
+}
+
+// writeFrame writes a single unfragmented, unmasked frame.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := make([]byte, 0, 10)
+	header = append(header, 0x80|opcode)
+
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
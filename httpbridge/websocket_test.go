@@ -0,0 +1,222 @@
+package httpbridge
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebSocketAccept(t *testing.T) {
+	// Example from RFC 6455 section 1.3
+	if got := webSocketAccept("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Expected RFC 6455 accept value, got %q", got)
+	}
+}
+
+func TestWebSocketHandlerRoundTrip(t *testing.T) {
+	pool, transport := setupBridgePool(t)
+	server := httptest.NewServer(NewWebSocketHandler(pool))
+	defer server.Close()
+
+	conn, reader := dialWebSocket(t, server.URL, map[string]string{DefaultSessionHeader: "alice"})
+	defer conn.Close()
+
+	writeClientFrame(t, conn, opText, []byte(`{"prompt":"first"}`))
+	events := readWebSocketTurn(t, reader)
+	assertEventTypes(t, events, EventDelta, EventResult)
+	if got := decodeTextData(t, events[0]); got != "echo: first" {
+		t.Errorf("Expected delta %q, got %q", "echo: first", got)
+	}
+	if got := transport.lastSessionID(); got != "alice" {
+		t.Errorf("Expected handshake session ID, got %q", got)
+	}
+
+	// A second turn on the same socket, with its own session ID
+	writeClientFrame(t, conn, opText, []byte(`{"prompt":"second","session_id":"bob"}`))
+	assertEventTypes(t, readWebSocketTurn(t, reader), EventDelta, EventResult)
+	if got := transport.lastSessionID(); got != "bob" {
+		t.Errorf("Expected request session ID, got %q", got)
+	}
+}
+
+func TestWebSocketHandlerInvalidMessage(t *testing.T) {
+	pool, _ := setupBridgePool(t)
+	server := httptest.NewServer(NewWebSocketHandler(pool))
+	defer server.Close()
+
+	conn, reader := dialWebSocket(t, server.URL, nil)
+	defer conn.Close()
+
+	writeClientFrame(t, conn, opText, []byte(`not json`))
+	assertEventTypes(t, readWebSocketTurn(t, reader), EventError)
+}
+
+func TestWebSocketHandlerPingAndClose(t *testing.T) {
+	pool, _ := setupBridgePool(t)
+	server := httptest.NewServer(NewWebSocketHandler(pool))
+	defer server.Close()
+
+	conn, reader := dialWebSocket(t, server.URL, nil)
+	defer conn.Close()
+
+	writeClientFrame(t, conn, opPing, []byte("ping"))
+	if opcode, payload := readServerFrame(t, reader); opcode != opPong || string(payload) != "ping" {
+		t.Errorf("Expected pong echoing payload, got opcode %d payload %q", opcode, payload)
+	}
+
+	writeClientFrame(t, conn, opClose, []byte{0x03, 0xE8})
+	if opcode, _ := readServerFrame(t, reader); opcode != opClose {
+		t.Errorf("Expected close frame, got opcode %d", opcode)
+	}
+}
+
+func TestWebSocketHandlerRejectsPlainRequest(t *testing.T) {
+	pool, _ := setupBridgePool(t)
+	rec := httptest.NewRecorder()
+	NewWebSocketHandler(pool).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestWebSocketHandlerChecksOrigin(t *testing.T) {
+	pool, _ := setupBridgePool(t)
+	handler := NewWebSocketHandler(pool, WithAllowedOrigins("https://app.example.com"))
+
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		{"other_origin", "https://evil.example.com", http.StatusForbidden},
+		{"allowed_origin", "https://app.example.com", http.StatusBadRequest},
+		{"same_origin", "http://example.com", http.StatusBadRequest},
+		{"no_origin", "", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Requests passing the check fail the handshake, which they lack
+			req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != test.want {
+				t.Errorf("Expected status %d, got %d", test.want, rec.Code)
+			}
+		})
+	}
+}
+
+// Helper functions
+
+func dialWebSocket(t *testing.T, serverURL string, headers map[string]string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(serverURL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	request := "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: " + key + "\r\n"
+	for name, value := range headers {
+		request += name + ": " + value + "\r\n"
+	}
+	if _, err := conn.Write([]byte(request + "\r\n")); err != nil {
+		t.Fatalf("handshake write failed: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("handshake read failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != webSocketAccept(key) {
+		t.Fatalf("Unexpected Sec-WebSocket-Accept %q", got)
+	}
+	return conn, reader
+}
+
+func writeClientFrame(t *testing.T, conn net.Conn, opcode byte, payload []byte) {
+	t.Helper()
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	frame := []byte{0x80 | opcode}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, 0x80|byte(len(payload)))
+	default:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("frame write failed: %v", err)
+	}
+}
+
+func readServerFrame(t *testing.T, reader *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		t.Fatalf("frame read failed: %v", err)
+	}
+	if header[1]&0x80 != 0 {
+		t.Fatal("server frames must not be masked")
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, _ = io.ReadFull(reader, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, _ = io.ReadFull(reader, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		t.Fatalf("payload read failed: %v", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// readWebSocketTurn reads events until the result or error event.
+func readWebSocketTurn(t *testing.T, reader *bufio.Reader) []Event {
+	t.Helper()
+	var events []Event
+	for {
+		opcode, payload := readServerFrame(t, reader)
+		if opcode != opText {
+			t.Fatalf("Expected text frame, got opcode %d", opcode)
+		}
+		var raw struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(payload, &raw); err != nil {
+			t.Fatalf("invalid event JSON: %v", err)
+		}
+		events = append(events, Event{Type: raw.Type, Data: raw.Data})
+		if raw.Type == EventResult || raw.Type == EventError {
+			return events
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrQueryInProgress is returned by a query whose idempotency key is held
+// by a query that has not completed yet, on this client or on another one
+// sharing the IdempotencyStore.
+var ErrQueryInProgress = errors.New("query with this idempotency key in progress")
+
+// claimIdempotencyKey claims key in store for a query about to run. It
+// returns the stored result of a completed query with the key, or
+// ErrQueryInProgress while another query holds it.
+func claimIdempotencyKey(ctx context.Context, store IdempotencyStore, key string) (*ResultMessage, error) {
+	result, claimed, err := store.Claim(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("claiming idempotency key: %w", err)
+	}
+	if result != nil {
+		return result, nil
+	}
+	if !claimed {
+		return nil, ErrQueryInProgress
+	}
+	return nil, nil
+}
+
+// settleIdempotencyKey stores result under key, or releases key when the
+// query ended without a successful result, so its retry runs.
+func settleIdempotencyKey(ctx context.Context, store IdempotencyStore, key string, result *ResultMessage) {
+	if result != nil && !result.IsError {
+		_ = store.Complete(ctx, key, result)
+		return
+	}
+	_ = store.Abandon(ctx, key)
+}
+
+// idempotentTurns saves the result of each turn sent with an idempotency
+// key in the client's IdempotencyStore, and replays stored results in
+// place of the turns of retried queries.
+type idempotentTurns struct {
+	store IdempotencyStore
+	// replays carries stored results into the message stream
+	replays chan Message
+
+	mu sync.Mutex
+	// Key of the running turn, or ""
+	running string
+	// Stored results on their way to consumers
+	replayed map[Message]bool
+	// settle runs store calls, which must not hold up the message stream
+	settle func(key string, result *ResultMessage)
+}
+
+func newIdempotentTurns(store IdempotencyStore) *idempotentTurns {
+	return &idempotentTurns{
+		store:    store,
+		replays:  make(chan Message),
+		replayed: make(map[Message]bool),
+	}
+}
+
+// connect sets up the turns of a new connection, whose store calls run
+// through spawn with ctx.
+func (it *idempotentTurns) connect(ctx context.Context, spawn func(func(done <-chan struct{}))) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.running = ""
+	it.settle = func(key string, result *ResultMessage) {
+		spawn(func(<-chan struct{}) { settleIdempotencyKey(ctx, it.store, key, result) })
+	}
+}
+
+// start records the key of the turn being sent.
+func (it *idempotentTurns) start(key string) {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.running = key
+}
+
+// fail releases the key of a turn that could not be sent.
+func (it *idempotentTurns) fail(ctx context.Context, key string) {
+	it.mu.Lock()
+	if it.running == key {
+		it.running = ""
+	}
+	it.mu.Unlock()
+	_ = it.store.Abandon(ctx, key)
+}
+
+// replay delivers a stored result to consumers as the result of the turn
+// the client began for the retried query.
+func (it *idempotentTurns) replay(ctx context.Context, result *ResultMessage) error {
+	it.mu.Lock()
+	it.replayed[result] = true
+	it.mu.Unlock()
+	select {
+	case it.replays <- result:
+		return nil
+	case <-ctx.Done():
+		it.mu.Lock()
+		delete(it.replayed, result)
+		it.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// track settles the key of the running turn when its result arrives. It
+// reports whether msg is a replayed result, which only ends the turn.
+func (it *idempotentTurns) track(msg Message) bool {
+	result, ok := msg.(*ResultMessage)
+	if !ok {
+		return false
+	}
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.replayed[msg] {
+		delete(it.replayed, msg)
+		return true
+	}
+	if it.running != "" {
+		it.settle(it.running, result)
+		it.running = ""
+	}
+	return false
+}
+
+// abandon releases the key of a turn the CLI will not finish, after a
+// restart or when the stream ends.
+func (it *idempotentTurns) abandon() {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.running != "" {
+		it.settle(it.running, nil)
+		it.running = ""
+	}
+}
+
+// mergeReplays copies messages from in and replays to out until in is
+// closed or done is closed, then closes out.
+func mergeReplays(done <-chan struct{}, in <-chan Message, replays <-chan Message, out chan<- Message) {
+	defer close(out)
+
+	for {
+		var msg Message
+		select {
+		case <-done:
+			return
+		case m, ok := <-in:
+			if !ok {
+				return
+			}
+			msg = m
+		case msg = <-replays:
+		}
+		select {
+		case out <- msg:
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientIdempotencyKey(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	store := newFakeIdempotencyStore()
+	transport := newClientMockTransport()
+	client := NewClientWithTransport(transport, WithIdempotencyStore(store))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	// Another client sharing the store sees the running query's claim
+	other := NewClientWithTransport(newClientMockTransport(), WithIdempotencyStore(store))
+	connectClientSafely(ctx, t, other)
+	defer disconnectClientSafely(t, other)
+
+	assertNoError(t, client.Query(ctx, "Summarize the incident", WithIdempotencyKey("req-1")))
+	if err := other.Query(ctx, "Summarize the incident", WithIdempotencyKey("req-1")); !errors.Is(err, ErrQueryInProgress) {
+		t.Fatalf("Expected ErrQueryInProgress, got %v", err)
+	}
+	deliverTurnResult(t, transport)
+	awaitClientResult(ctx, t, client)
+	store.waitResult(t, "req-1")
+
+	// The retry gets the stored result as its turn, without running
+	assertNoError(t, other.Query(ctx, "Summarize the incident", WithIdempotencyKey("req-1")))
+	iter := other.ReceiveResponse(ctx)
+	msg, err := iter.Next(ctx)
+	if result, ok := msg.(*ResultMessage); !ok || err != nil || result.Subtype != "success" {
+		t.Fatalf("Expected the stored result, got %+v, %v", msg, err)
+	}
+	if sent := other.(*ClientImpl).transport.(*clientMockTransport).getSentMessageCount(); sent != 0 {
+		t.Errorf("Expected the retry not to run, got %d prompts sent", sent)
+	}
+	assertNoError(t, other.Query(ctx, "Next question"))
+}
+
+func TestClientIdempotencyKeyReleasedOnError(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	store := newFakeIdempotencyStore()
+	transport := newClientMockTransport()
+	client := NewClientWithTransport(transport, WithIdempotencyStore(store))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	assertNoError(t, client.Query(ctx, "Deploy", WithIdempotencyKey("req-1")))
+	transport.mu.Lock()
+	transport.msgChan <- &ResultMessage{Subtype: "error_during_execution", IsError: true}
+	transport.mu.Unlock()
+	awaitClientResult(ctx, t, client)
+	store.waitReleased(t, "req-1")
+
+	assertNoError(t, client.Query(ctx, "Deploy", WithIdempotencyKey("req-1")))
+	if sent := transport.getSentMessageCount(); sent != 2 {
+		t.Errorf("Expected the retry of a failed query to run, got %d prompts sent", sent)
+	}
+}
+
+func TestQueryIdempotencyKey(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	store := newFakeIdempotencyStore()
+	transport := newQueryMockTransport(WithQueryAssistantResponse("4"), WithQueryResultMessage(false, 10, 1))
+	iter, err := QueryWithTransport(ctx, "What is 2+2?", transport,
+		WithIdempotencyStore(store), WithIdempotencyKey("req-1"))
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for {
+		if _, err := iter.Next(ctx); err != nil {
+			break
+		}
+	}
+	_ = iter.Close()
+	store.waitResult(t, "req-1")
+
+	retry := newQueryMockTransport(WithQueryAssistantResponse("5"))
+	iter, err = QueryWithTransport(ctx, "What is 2+2?", retry,
+		WithIdempotencyStore(store), WithIdempotencyKey("req-1"))
+	if err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	defer iter.Close()
+	msg, err := iter.Next(ctx)
+	if _, ok := msg.(*ResultMessage); !ok || err != nil {
+		t.Fatalf("Expected the stored result, got %+v, %v", msg, err)
+	}
+	if _, err := iter.Next(ctx); !errors.Is(err, ErrNoMoreMessages) {
+		t.Errorf("Expected only the stored result, got %v", err)
+	}
+	if retry.connected {
+		t.Error("Expected the retry not to run")
+	}
+}
+
+func TestIdempotencyKeyValidation(t *testing.T) {
+	err := validateQueryOptions(NewOptions(WithIdempotencyKey("req-1")))
+	if err == nil || !strings.Contains(err.Error(), "WithIdempotencyStore") {
+		t.Errorf("Expected a key without a store to be rejected, got %v", err)
+	}
+	store := newFakeIdempotencyStore()
+	if err := validateQueryOptions(NewOptions(WithIdempotencyKey("req-1"), WithIdempotencyStore(store))); err != nil {
+		t.Errorf("Expected one-shot queries to accept idempotency keys, got %v", err)
+	}
+
+	client := NewClientWithTransport(newClientMockTransport(), WithIdempotencyKey("req-1"), WithIdempotencyStore(store))
+	if err := client.Connect(context.Background()); err == nil {
+		t.Error("Expected a client-level idempotency key to be rejected")
+	}
+
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+	client = NewClientWithTransport(newClientMockTransport())
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+	if err := client.Query(ctx, "Deploy", WithIdempotencyKey("req-1")); err == nil {
+		t.Error("Expected a key on a client without a store to be rejected")
+	}
+}
+
+// fakeIdempotencyStore keeps idempotency keys in memory.
+type fakeIdempotencyStore struct {
+	mu      sync.Mutex
+	claimed map[string]bool
+	results map[string]*ResultMessage
+}
+
+func newFakeIdempotencyStore() *fakeIdempotencyStore {
+	return &fakeIdempotencyStore{claimed: make(map[string]bool), results: make(map[string]*ResultMessage)}
+}
+
+func (s *fakeIdempotencyStore) Claim(_ context.Context, key string) (*ResultMessage, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if result, ok := s.results[key]; ok {
+		stored := *result
+		return &stored, false, nil
+	}
+	if s.claimed[key] {
+		return nil, false, nil
+	}
+	s.claimed[key] = true
+	return nil, true, nil
+}
+
+func (s *fakeIdempotencyStore) Complete(_ context.Context, key string, result *ResultMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claimed, key)
+	stored := *result
+	s.results[key] = &stored
+	return nil
+}
+
+func (s *fakeIdempotencyStore) Abandon(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claimed, key)
+	return nil
+}
+
+// waitResult waits for the result of the query with key to be stored.
+func (s *fakeIdempotencyStore) waitResult(t *testing.T, key string) {
+	t.Helper()
+	s.wait(t, "the result stored", func() bool { return s.results[key] != nil })
+}
+
+// waitReleased waits for key to be released.
+func (s *fakeIdempotencyStore) waitReleased(t *testing.T, key string) {
+	t.Helper()
+	s.wait(t, "the key released", func() bool { return !s.claimed[key] && s.results[key] == nil })
+}
+
+func (s *fakeIdempotencyStore) wait(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		ok := done()
+		s.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Expected %s", what)
+}
@@ -0,0 +1,60 @@
+package claudecode
+
+import "sync"
+
+// initTracker records the CLI's init message for the current connection.
+type initTracker struct {
+	mu       sync.Mutex
+	init     *InitInfo
+	received chan struct{} // closed when the init message arrives
+}
+
+func newInitTracker() *initTracker {
+	return &initTracker{received: make(chan struct{})}
+}
+
+// track records an init system message.
+func (it *initTracker) track(msg Message) {
+	system, ok := msg.(*SystemMessage)
+	if !ok {
+		return
+	}
+	info, ok := system.Init()
+	if !ok {
+		return
+	}
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.init == nil {
+		close(it.received)
+	}
+	it.init = &info
+}
+
+// info returns a copy of the init message, or nil before it arrives.
+func (it *initTracker) info() *InitInfo {
+	if it == nil {
+		return nil
+	}
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	if it.init == nil {
+		return nil
+	}
+	info := *it.init
+	info.Tools = append([]string(nil), info.Tools...)
+	info.McpServers = append([]McpServerStatus(nil), info.McpServers...)
+	info.SlashCommands = append([]string(nil), info.SlashCommands...)
+	info.Agents = append([]string(nil), info.Agents...)
+	info.MemoryFiles = append([]MemoryFile(nil), info.MemoryFiles...)
+	return &info
+}
+
+// workingDir returns the working directory the CLI reported, if any.
+func (it *initTracker) workingDir() string {
+	if info := it.info(); info != nil {
+		return info.Cwd
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClientInitInfo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	init := initMessage("Read", "Bash")
+	init.Data["model"] = "claude-sonnet-4-5"
+	init.Data["permissionMode"] = "plan"
+	init.Data["mcp_servers"] = []any{map[string]any{"name": "github", "status": "failed"}}
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		init,
+		&ResultMessage{Subtype: "success"},
+	}))
+	client := NewClientWithTransport(transport).(*ClientImpl)
+	if client.InitInfo() != nil {
+		t.Error("Expected no init info before connecting")
+	}
+
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+	awaitClientResult(ctx, t, client)
+
+	info := client.InitInfo()
+	if info == nil {
+		t.Fatal("Expected init info after the init message")
+	}
+	if info.Model != "claude-sonnet-4-5" || info.PermissionMode != PermissionModePlan || len(info.Tools) != 2 {
+		t.Errorf("Unexpected init info: %+v", info)
+	}
+	if len(info.McpServers) != 1 || info.McpServers[0].Status != "failed" {
+		t.Errorf("Expected failed MCP server to be reported, got %+v", info.McpServers)
+	}
+
+	// Callers get a copy
+	info.Tools[0] = "Changed"
+	if client.InitInfo().Tools[0] != "Read" {
+		t.Error("Expected InitInfo to return a copy")
+	}
+}
+
+func TestClientConnectInitTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	transport := newClientMockTransport()
+	client := NewClientWithTransport(transport, WithInitTimeout(20*time.Millisecond))
+
+	err := client.Connect(ctx)
+	if !errors.Is(err, ErrInitTimeout) {
+		t.Fatalf("Expected ErrInitTimeout, got %v", err)
+	}
+	if !transport.closed {
+		t.Error("Expected the transport to be closed after a failed start")
+	}
+	if err := client.Query(ctx, "hello"); err == nil {
+		t.Error("Expected client to be disconnected")
+	}
+	client.Wait()
+}
+
+func TestClientConnectWaitsForInitAfterPrompt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	transport := &initOnPromptTransport{newClientMockTransport()}
+	client := NewClientWithTransport(transport, WithInitTimeout(2*time.Second)).(*ClientImpl)
+
+	prompt := StreamMessage{
+		Type:      userMessageType,
+		Message:   map[string]interface{}{"role": "user", "content": "hello"},
+		SessionID: defaultSessionID,
+	}
+	if err := client.Connect(ctx, prompt); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer disconnectClientSafely(t, client)
+
+	if client.InitInfo() == nil {
+		t.Error("Expected init info once Connect returns")
+	}
+	assertClientMessageCount(t, transport.clientMockTransport, 1)
+	if err := client.Query(ctx, "second"); !errors.Is(err, ErrTurnInProgress) {
+		t.Errorf("Expected the prompt to hold the turn, got %v", err)
+	}
+}
+
+// initOnPromptTransport sends the init message once it reads a prompt, as
+// the CLI does.
+type initOnPromptTransport struct {
+	*clientMockTransport
+}
+
+func (i *initOnPromptTransport) SendMessage(ctx context.Context, message StreamMessage) error {
+	if err := i.clientMockTransport.SendMessage(ctx, message); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	msgChan := i.msgChan
+	i.mu.Unlock()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		msgChan <- initMessage("Read")
+	}()
+	return nil
+}
@@ -0,0 +1,154 @@
+// Package bridge runs turns on pooled clients for the packages exposing the
+// SDK to other processes, such as httpbridge and grpcbridge, which only
+// differ in how they encode events on the wire.
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// EventKind identifies what an Event carries.
+type EventKind int
+
+// Kinds of events a turn produces.
+const (
+	EventDelta EventKind = iota
+	EventThinking
+	EventToolUse
+	EventResult
+)
+
+// Event is one item of a turn's output.
+type Event struct {
+	Kind EventKind
+	// Text is set for EventDelta and EventThinking
+	Text string
+	// ToolUse is set for EventToolUse
+	ToolUse *claudecode.ToolUseBlock
+	// Result is set for EventResult, which ends the turn
+	Result *claudecode.ResultMessage
+}
+
+// Turn is one prompt to run on a pooled client.
+type Turn struct {
+	Prompt    string
+	SessionID string
+	Options   []claudecode.Option
+	// DrainTimeout bounds how long an interrupted turn is drained before
+	// its client is discarded
+	DrainTimeout time.Duration
+	// Started, if set, is called with the client running the turn before
+	// the prompt is sent; the function it returns is called when the turn
+	// ends
+	Started func(client claudecode.Client) (ended func())
+}
+
+// Run sends the turn's prompt on a client acquired from pool and passes its
+// events to emit until the result. If ctx ends or emit fails first, the
+// turn is interrupted and drained so the client can be reused; clients that
+// can't be drained are discarded.
+func Run(ctx context.Context, pool *claudecode.ClientPool, turn Turn, emit func(Event) error) error {
+	client, err := pool.Acquire(ctx, turn.SessionID)
+	if err != nil {
+		return fmt.Errorf("no client available: %w", err)
+	}
+	if turn.Started != nil {
+		defer turn.Started(client)()
+	}
+
+	if err := client.QueryWithSession(ctx, turn.Prompt, turn.SessionID, turn.Options...); err != nil {
+		pool.Discard(client)
+		return fmt.Errorf("failed to send prompt: %w", err)
+	}
+
+	iter := client.ReceiveResponse(ctx)
+	if iter == nil {
+		pool.Discard(client)
+		return errors.New("client not connected")
+	}
+
+	for {
+		msg, err := iter.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				recoverClient(pool, client, turn.DrainTimeout)
+				return ctx.Err()
+			}
+			pool.Discard(client)
+			return err
+		}
+
+		done := false
+		for _, ev := range Events(msg) {
+			if ev.Kind == EventResult {
+				done = true
+			}
+			if err := emit(ev); err != nil {
+				if done {
+					pool.Release(client)
+				} else {
+					recoverClient(pool, client, turn.DrainTimeout)
+				}
+				return err
+			}
+		}
+		if done {
+			pool.Release(client)
+			return nil
+		}
+	}
+}
+
+// recoverClient interrupts the in-flight turn and drains it, returning the
+// client to the pool only if the turn's ResultMessage was seen.
+func recoverClient(pool *claudecode.ClientPool, client claudecode.Client, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_ = client.Interrupt(ctx)
+
+	iter := client.ReceiveResponse(ctx)
+	if iter == nil {
+		pool.Discard(client)
+		return
+	}
+	for {
+		msg, err := iter.Next(ctx)
+		if err != nil {
+			pool.Discard(client)
+			return
+		}
+		if _, ok := msg.(*claudecode.ResultMessage); ok {
+			pool.Release(client)
+			return
+		}
+	}
+}
+
+// Events converts an SDK message into zero or more events.
+func Events(msg claudecode.Message) []Event {
+	switch m := msg.(type) {
+	case *claudecode.AssistantMessage:
+		events := make([]Event, 0, len(m.Content))
+		for _, block := range m.Content {
+			switch b := block.(type) {
+			case *claudecode.TextBlock:
+				events = append(events, Event{Kind: EventDelta, Text: b.Text})
+			case *claudecode.ThinkingBlock:
+				events = append(events, Event{Kind: EventThinking, Text: b.Thinking})
+			case *claudecode.ToolUseBlock:
+				events = append(events, Event{Kind: EventToolUse, ToolUse: b})
+			}
+		}
+		return events
+	case *claudecode.ResultMessage:
+		return []Event{{Kind: EventResult, Result: m}}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+package bridge
+
+import (
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestEvents(t *testing.T) {
+	toolUse := &claudecode.ToolUseBlock{ToolUseID: "t1", Name: "Bash"}
+	result := &claudecode.ResultMessage{SessionID: "s1"}
+
+	tests := []struct {
+		name string
+		msg  claudecode.Message
+		want []Event
+	}{
+		{
+			name: "assistant_blocks",
+			msg: &claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+				&claudecode.ThinkingBlock{Thinking: "hmm"},
+				&claudecode.TextBlock{Text: "hi"},
+				toolUse,
+			}},
+			want: []Event{
+				{Kind: EventThinking, Text: "hmm"},
+				{Kind: EventDelta, Text: "hi"},
+				{Kind: EventToolUse, ToolUse: toolUse},
+			},
+		},
+		{"result", result, []Event{{Kind: EventResult, Result: result}}},
+		{"other", &claudecode.SystemMessage{Subtype: "init"}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Events(test.msg)
+			if len(got) != len(test.want) {
+				t.Fatalf("Expected %d events, got %d", len(test.want), len(got))
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("Event %d: expected %+v, got %+v", i, test.want[i], got[i])
+				}
+			}
+		})
+	}
+}
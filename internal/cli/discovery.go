@@ -147,13 +147,25 @@ func addOptionsToCommand(cmd []string, options *shared.Options) []string {
 	cmd = addBetasFlag(cmd, options)
 	cmd = addSandboxFlags(cmd, options)
 	cmd = addOutputFormatFlags(cmd, options)
+	cmd = addDebugFlags(cmd, options)
 	cmd = addExtraFlags(cmd, options)
 	return cmd
 }
 
 func addToolControlFlags(cmd []string, options *shared.Options) []string {
-	if len(options.AllowedTools) > 0 {
-		cmd = append(cmd, "--allowed-tools", strings.Join(options.AllowedTools, ","))
+	// Allowed tools would run without asking, so a dry run leaves them out,
+	// and a path policy leaves out the file tools it checks
+	allowed := options.AllowedTools
+	if guardsPaths(options) {
+		allowed = nil
+		for _, tool := range options.AllowedTools {
+			if name, _, _ := strings.Cut(tool, "("); !shared.IsPathTool(name) {
+				allowed = append(allowed, tool)
+			}
+		}
+	}
+	if len(allowed) > 0 && !options.DryRun {
+		cmd = append(cmd, "--allowed-tools", strings.Join(allowed, ","))
 	}
 	if len(options.DisallowedTools) > 0 {
 		cmd = append(cmd, "--disallowed-tools", strings.Join(options.DisallowedTools, ","))
@@ -201,7 +213,8 @@ func addModelAndPromptFlags(cmd []string, options *shared.Options) []string {
 	// if options.MaxThinkingTokens > 0 {
 	//	cmd = append(cmd, "--max-thinking-tokens", fmt.Sprintf("%d", options.MaxThinkingTokens))
 	// }
-	// NOTE: User and MaxBufferSize are internal SDK options without CLI flag mappings
+	// NOTE: User, MaxBufferSize, MaxMessageSize and MaxInlineResultBytes are internal SDK options
+	// without CLI flag mappings
 	return cmd
 }
 
@@ -211,10 +224,19 @@ func addPermissionFlags(cmd []string, options *shared.Options) []string {
 	}
 	if options.PermissionPromptToolName != nil {
 		cmd = append(cmd, "--permission-prompt-tool", *options.PermissionPromptToolName)
+	} else if options.DryRun || guardsPaths(options) {
+		// A dry run answers every permission check over the control
+		// protocol, and a path policy checks file tools there
+		cmd = append(cmd, "--permission-prompt-tool", "stdio")
 	}
 	return cmd
 }
 
+// guardsPaths reports whether the SDK checks the paths of file tools.
+func guardsPaths(options *shared.Options) bool {
+	return options.PathPolicy != nil || options.ConfinePaths
+}
+
 func addSessionFlags(cmd []string, options *shared.Options) []string {
 	if options.ContinueConversation {
 		cmd = append(cmd, "--continue")
@@ -235,15 +257,11 @@ func addSessionFlags(cmd []string, options *shared.Options) []string {
 	}
 	// Always pass --setting-sources (Python SDK parity)
 	// Empty slice results in empty string value
-	sourcesValue := ""
-	if len(options.SettingSources) > 0 {
-		strs := make([]string, len(options.SettingSources))
-		for i, s := range options.SettingSources {
-			strs[i] = string(s)
-		}
-		sourcesValue = strings.Join(strs, ",")
+	strs := make([]string, 0, len(options.SettingSources))
+	for _, s := range options.SettingSources {
+		strs = append(strs, string(s))
 	}
-	cmd = append(cmd, "--setting-sources", sourcesValue)
+	cmd = append(cmd, "--setting-sources", strings.Join(strs, ","))
 	return cmd
 }
 
@@ -359,6 +377,13 @@ func addOutputFormatFlags(cmd []string, options *shared.Options) []string {
 	return append(cmd, "--json-schema", string(schemaData))
 }
 
+func addDebugFlags(cmd []string, options *shared.Options) []string {
+	if options.Debug {
+		cmd = append(cmd, "--debug-to-stderr")
+	}
+	return cmd
+}
+
 func addExtraFlags(cmd []string, options *shared.Options) []string {
 	for flag, value := range options.ExtraArgs {
 		if value == nil {
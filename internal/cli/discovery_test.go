@@ -179,6 +179,15 @@ func TestBetasFlagSupport(t *testing.T) {
 	}
 }
 
+// TestDebugFlagSupport tests that debug output is requested on stderr
+func TestDebugFlagSupport(t *testing.T) {
+	cmd := BuildCommand("/usr/local/bin/claude", &shared.Options{Debug: true}, false)
+	assertContainsArg(t, cmd, "--debug-to-stderr")
+
+	cmd = BuildCommand("/usr/local/bin/claude", &shared.Options{}, false)
+	assertNotContainsArg(t, cmd, "--debug-to-stderr")
+}
+
 // TestBuildCommandWithPrompt tests CLI command construction with prompt argument
 func TestBuildCommandWithPrompt(t *testing.T) {
 	tests := []struct {
@@ -772,6 +781,27 @@ func TestAddPermissionFlagsComplete(t *testing.T) {
 				"--permission-prompt-tool": "security-tool",
 			},
 		},
+		{
+			name:    "dry_run_prompts_over_stdio",
+			options: &shared.Options{DryRun: true},
+			expect: map[string]string{
+				"--permission-prompt-tool": "stdio",
+			},
+		},
+		{
+			name:    "confined_paths_prompt_over_stdio",
+			options: &shared.Options{ConfinePaths: true},
+			expect: map[string]string{
+				"--permission-prompt-tool": "stdio",
+			},
+		},
+		{
+			name:    "dry_run_keeps_custom_prompt_tool",
+			options: &shared.Options{DryRun: true, PermissionPromptToolName: stringPtr("custom-tool")},
+			expect: map[string]string{
+				"--permission-prompt-tool": "custom-tool",
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -785,6 +815,24 @@ func TestAddPermissionFlagsComplete(t *testing.T) {
 	}
 }
 
+// TestDryRunOmitsAllowedTools tests that a dry run does not pre-approve tools
+func TestDryRunOmitsAllowedTools(t *testing.T) {
+	options := &shared.Options{AllowedTools: []string{"Read"}, DisallowedTools: []string{"Bash"}, DryRun: true}
+	cmd := BuildCommand("/usr/local/bin/claude", options, false)
+
+	assertNotContainsArg(t, cmd, "--allowed-tools")
+	assertContainsArgs(t, cmd, "--disallowed-tools", "Bash")
+}
+
+// TestPathPolicyOmitsAllowedFileTools tests that a path policy does not
+// pre-approve the file tools it checks
+func TestPathPolicyOmitsAllowedFileTools(t *testing.T) {
+	options := &shared.Options{AllowedTools: []string{"Read", "Edit(docs/**)", "WebFetch", "Bash(git log:*)"}, ConfinePaths: true}
+	cmd := BuildCommand("/usr/local/bin/claude", options, false)
+
+	assertContainsArgs(t, cmd, "--allowed-tools", "WebFetch,Bash(git log:*)")
+}
+
 // TestWorkingDirectoryValidationStatError tests stat error handling
 func TestWorkingDirectoryValidationStatError(t *testing.T) {
 	// Test with a path that will cause os.Stat to return a non-IsNotExist error
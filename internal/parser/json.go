@@ -2,11 +2,16 @@
 package parser
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"strings"
 	"sync"
 
+	"github.com/severity1/claude-code-sdk-go/internal/protocol"
 	"github.com/severity1/claude-code-sdk-go/internal/shared"
 )
 
@@ -18,15 +23,77 @@ const (
 // Parser handles JSON message parsing with speculative parsing and buffer management.
 // It implements the same speculative parsing strategy as the Python SDK.
 type Parser struct {
-	buffer        strings.Builder
-	maxBufferSize int
-	mu            sync.Mutex // Thread safety
+	buffer               strings.Builder
+	maxBufferSize        int
+	maxInlineResultBytes int             // 0 keeps all tool result images inline
+	files                []string        // Image files written for tool results
+	spilled              map[string]bool // Files of StringSpiller strings in the buffer
+	recycle              bool            // Build user and assistant messages from pools
+	codec                shared.JSONCodec
+	adapter              *protocol.Adapter   // Adapts other CLI versions' message shapes
+	protocol             *protocol.Validator // Checks messages against the CLI's schema
+	mu                   sync.Mutex          // Thread safety
 }
 
 // New creates a new JSON parser with default buffer size.
 func New() *Parser {
 	return &Parser{
 		maxBufferSize: MaxBufferSize,
+		codec:         shared.StdJSONCodec{},
+		adapter:       protocol.NewAdapter(),
+	}
+}
+
+// NewWithMaxBufferSize creates a new JSON parser that accumulates at most
+// maxBufferSize bytes of an incomplete message.
+func NewWithMaxBufferSize(maxBufferSize int) *Parser {
+	if maxBufferSize <= 0 {
+		maxBufferSize = MaxBufferSize
+	}
+	return &Parser{
+		maxBufferSize: maxBufferSize,
+		codec:         shared.StdJSONCodec{},
+		adapter:       protocol.NewAdapter(),
+	}
+}
+
+// SetMaxInlineResultBytes sets the size above which base64 images in tool
+// results are decoded to temporary files instead of kept in memory.
+// A limit of 0 keeps all images inline.
+func (p *Parser) SetMaxInlineResultBytes(limit int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxInlineResultBytes = limit
+}
+
+// SetMessageRecycling makes the parser build user and assistant messages,
+// and their content blocks, from pools that Message.Release returns them to.
+func (p *Parser) SetMessageRecycling(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.recycle = enabled
+}
+
+// SetJSONCodec sets the codec that decodes complete lines. A nil codec
+// restores encoding/json.
+func (p *Parser) SetJSONCodec(codec shared.JSONCodec) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if codec == nil {
+		codec = shared.StdJSONCodec{}
+	}
+	p.codec = codec
+}
+
+// SetStrictProtocol makes the parser check each message against the schema
+// of the CLI's protocol version. A message that does not match is dropped
+// and reported as a shared.ProtocolError.
+func (p *Parser) SetStrictProtocol(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.protocol = nil
+	if enabled {
+		p.protocol = protocol.NewValidator()
 	}
 }
 
@@ -44,26 +111,51 @@ func (p *Parser) ProcessLine(line string) ([]shared.Message, error) {
 	var messages []shared.Message
 
 	// Handle multiple JSON objects on single line by splitting on newlines
-	jsonLines := strings.Split(line, "\n")
-	for _, jsonLine := range jsonLines {
+	for rest, more := line, true; more; {
+		var jsonLine string
+		jsonLine, rest, more = strings.Cut(rest, "\n")
 		jsonLine = strings.TrimSpace(jsonLine)
 		if jsonLine == "" {
 			continue
 		}
 
-		// Process each JSON line with speculative parsing (unlocked version)
+		// Process each JSON line with speculative parsing (unlocked version).
+		// A line may yield a message and an error for an earlier fragment.
 		msg, err := p.processJSONLineUnlocked(jsonLine)
-		if err != nil {
-			return messages, err
-		}
 		if msg != nil {
 			messages = append(messages, msg)
 		}
+		if err != nil {
+			return messages, err
+		}
 	}
 
 	return messages, nil
 }
 
+// ProcessSpilledLine processes a line built by a StringSpiller, whose long
+// strings are in files. Only markers naming those files are resolved, so
+// output imitating a marker cannot make the parser read other files.
+func (p *Parser) ProcessSpilledLine(line string, files []string) ([]shared.Message, error) {
+	p.mu.Lock()
+	if p.spilled == nil {
+		p.spilled = make(map[string]bool)
+	}
+	for _, path := range files {
+		p.spilled[path] = true
+	}
+	p.mu.Unlock()
+
+	messages, err := p.ProcessLine(line)
+
+	p.mu.Lock()
+	if p.buffer.Len() == 0 {
+		p.spilled = nil
+	}
+	p.mu.Unlock()
+	return messages, err
+}
+
 // ParseMessage parses a raw JSON object into the appropriate Message type.
 // Implements type discrimination based on the "type" field.
 func (p *Parser) ParseMessage(data map[string]any) (shared.Message, error) {
@@ -81,6 +173,10 @@ func (p *Parser) ParseMessage(data map[string]any) (shared.Message, error) {
 		return p.parseSystemMessage(data)
 	case shared.MessageTypeResult:
 		return p.parseResultMessage(data)
+	case shared.MessageTypeControlRequest:
+		return p.parseControlRequest(data)
+	case shared.MessageTypeControlResponse:
+		return p.parseControlResponse(data)
 	default:
 		return nil, shared.NewMessageParseError(
 			fmt.Sprintf("unknown message type: %s", msgType),
@@ -114,33 +210,130 @@ func (p *Parser) processJSONLine(jsonLine string) (shared.Message, error) {
 
 // processJSONLineUnlocked is the unlocked version of processJSONLine.
 // Must be called with mutex already held.
+//
+// Input that can never become valid JSON is reported as a JSONDecodeError
+// and discarded, rather than accumulated until the buffer overflows. When a
+// complete message arrives while a fragment is buffered, the fragment was
+// truncated: it is reported and the new line is parsed on its own, so the
+// result can carry both a message and an error.
 func (p *Parser) processJSONLineUnlocked(jsonLine string) (shared.Message, error) {
-	p.buffer.WriteString(jsonLine)
+	if p.buffer.Len() > 0 && isCompleteMessage(jsonLine) {
+		fragment := p.buffer.String()
+		p.buffer.Reset()
+		msg, err := p.processJSONLineUnlocked(jsonLine)
+		truncated := shared.NewJSONDecodeError(fragment, len(fragment), io.ErrUnexpectedEOF)
+		if err != nil {
+			return msg, fmt.Errorf("%w; then %v", truncated, err)
+		}
+		return msg, truncated
+	}
+
+	// Lines usually hold a whole message, so the buffer is only used once
+	// a line turns out to be incomplete
+	content := jsonLine
+	if p.buffer.Len() > 0 {
+		p.buffer.WriteString(jsonLine)
+		content = p.buffer.String()
+	}
 
 	// Check buffer size limit
-	if p.buffer.Len() > p.maxBufferSize {
-		bufferSize := p.buffer.Len()
+	if len(content) > p.maxBufferSize {
 		p.buffer.Reset()
 		return nil, shared.NewJSONDecodeError(
 			"buffer overflow",
 			0,
-			fmt.Errorf("buffer size %d exceeds limit %d", bufferSize, p.maxBufferSize),
+			fmt.Errorf("buffer size %d exceeds limit %d", len(content), p.maxBufferSize),
 		)
 	}
 
 	// Attempt speculative JSON parsing
-	var rawData map[string]any
-	bufferContent := p.buffer.String()
-
-	if err := json.Unmarshal([]byte(bufferContent), &rawData); err != nil {
-		// JSON is incomplete - continue accumulating
-		// This is NOT an error condition in speculative parsing!
-		return nil, nil
+	rawData, err := decodeObject(p.codec, content)
+	if err != nil {
+		if isIncomplete(content) {
+			// JSON is incomplete - continue accumulating
+			// This is NOT an error condition in speculative parsing!
+			if p.buffer.Len() == 0 {
+				p.buffer.WriteString(jsonLine)
+			}
+			return nil, nil
+		}
+		p.buffer.Reset()
+		return nil, shared.NewJSONDecodeError(content, jsonErrorOffset(err), err)
 	}
 
 	// Successfully parsed complete JSON - reset buffer and parse message
 	p.buffer.Reset()
-	return p.ParseMessage(rawData)
+	if len(p.spilled) > 0 && strings.Contains(content, spillMarkerJSON) {
+		restored := len(content)
+		if _, err := p.resolveSpilled(rawData, false, &restored); err != nil {
+			return nil, shared.NewJSONDecodeError("spilled string", 0, err)
+		}
+	}
+	p.adapter.Adapt(rawData)
+	if p.protocol != nil {
+		if err := p.protocol.Validate(rawData); err != nil {
+			return nil, err
+		}
+	}
+	msg, err := p.ParseMessage(rawData)
+	if err != nil {
+		// Drop the typed nil the parse functions return alongside errors
+		return nil, err
+	}
+	return msg, nil
+}
+
+// maxPooledLineSize bounds the line buffers kept for reuse.
+const maxPooledLineSize = 4 * 1024 * 1024
+
+// lineBuffers holds the byte copies of lines handed to the codec, which
+// must not retain its input.
+var lineBuffers = sync.Pool{New: func() any { return new([]byte) }}
+
+// decodeObject decodes content as a JSON object with codec.
+func decodeObject(codec shared.JSONCodec, content string) (map[string]any, error) {
+	bufPtr := lineBuffers.Get().(*[]byte)
+	buf := append((*bufPtr)[:0], content...)
+
+	var data map[string]any
+	err := codec.Unmarshal(buf, &data)
+
+	if cap(buf) <= maxPooledLineSize {
+		*bufPtr = buf
+		lineBuffers.Put(bufPtr)
+	}
+	return data, err
+}
+
+// isIncomplete reports whether content, which failed to decode, ends in the
+// middle of a JSON value rather than being invalid. Only the decoder tells
+// the two apart; json.Unmarshal reports both as syntax errors.
+func isIncomplete(content string) bool {
+	var discard any
+	err := json.NewDecoder(strings.NewReader(content)).Decode(&discard)
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isCompleteMessage reports whether line alone is a JSON object with a type
+// field, the shape of every message the CLI writes.
+func isCompleteMessage(line string) bool {
+	var probe struct {
+		Type *string `json:"type"`
+	}
+	return json.Unmarshal([]byte(line), &probe) == nil && probe.Type != nil
+}
+
+// jsonErrorOffset returns the input offset of a JSON decoding error, if known.
+func jsonErrorOffset(err error) int {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return int(syntaxErr.Offset)
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return int(typeErr.Offset)
+	}
+	return 0
 }
 
 // parseUserMessage parses a user message from raw JSON data.
@@ -177,13 +370,21 @@ func (p *Parser) parseUserMessage(data map[string]any) (*shared.UserMessage, err
 		}, nil
 	case []any:
 		// Array of content blocks
-		blocks := make([]shared.ContentBlock, len(c))
-		for i, blockData := range c {
-			block, err := p.parseContentBlock(blockData)
+		if p.recycle {
+			msg := shared.AcquireUserMessage()
+			blocks, err := p.parseRecycledBlocks(c, nil)
+			msg.Content = blocks
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse content block %d: %w", i, err)
+				msg.Release()
+				return nil, err
 			}
-			blocks[i] = block
+			msg.UUID = uuid
+			msg.ParentToolUseID = parentToolUseID
+			return msg, nil
+		}
+		blocks, err := p.parseContentBlocks(c)
+		if err != nil {
+			return nil, err
 		}
 		return &shared.UserMessage{
 			Content:         blocks,
@@ -212,13 +413,20 @@ func (p *Parser) parseAssistantMessage(data map[string]any) (*shared.AssistantMe
 		return nil, shared.NewMessageParseError("assistant message missing model field", data)
 	}
 
-	blocks := make([]shared.ContentBlock, len(contentArray))
-	for i, blockData := range contentArray {
-		block, err := p.parseContentBlock(blockData)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse content block %d: %w", i, err)
-		}
-		blocks[i] = block
+	var msg *shared.AssistantMessage
+	var blocks []shared.ContentBlock
+	var err error
+	if p.recycle {
+		msg = shared.AcquireAssistantMessage()
+		blocks, err = p.parseRecycledBlocks(contentArray, msg.Content)
+		msg.Content = blocks
+	} else {
+		msg = &shared.AssistantMessage{}
+		blocks, err = p.parseContentBlocks(contentArray)
+	}
+	if err != nil {
+		msg.Release()
+		return nil, err
 	}
 
 	// Parse optional error field
@@ -228,11 +436,16 @@ func (p *Parser) parseAssistantMessage(data map[string]any) (*shared.AssistantMe
 		errorPtr = &errType
 	}
 
-	return &shared.AssistantMessage{
-		Content: blocks,
-		Model:   model,
-		Error:   errorPtr,
-	}, nil
+	var parentToolUseID *string
+	if ptid, ok := data["parent_tool_use_id"].(string); ok {
+		parentToolUseID = &ptid
+	}
+
+	msg.Content = blocks
+	msg.Model = model
+	msg.Error = errorPtr
+	msg.ParentToolUseID = parentToolUseID
+	return msg, nil
 }
 
 // parseSystemMessage parses a system message from raw JSON data.
@@ -248,6 +461,47 @@ func (p *Parser) parseSystemMessage(data map[string]any) (*shared.SystemMessage,
 	}, nil
 }
 
+// parseControlRequest parses a control request from the CLI. The fields of
+// the request other than its subtype become the request's data.
+func (p *Parser) parseControlRequest(data map[string]any) (*shared.ControlRequestMessage, error) {
+	id, _ := data["request_id"].(string)
+	request, _ := data["request"].(map[string]any)
+	subtype, _ := request["subtype"].(string)
+	if id == "" || subtype == "" {
+		return nil, shared.NewMessageParseError("control request missing request_id or subtype field", data)
+	}
+
+	fields := make(map[string]any, len(request))
+	for key, value := range request {
+		if key != "subtype" {
+			fields[key] = value
+		}
+	}
+	return &shared.ControlRequestMessage{Request: &shared.ControlRequest{
+		ID:      id,
+		Subtype: shared.ControlRequestType(subtype),
+		Data:    fields,
+	}}, nil
+}
+
+// parseControlResponse parses the CLI's response to a control request.
+func (p *Parser) parseControlResponse(data map[string]any) (*shared.ControlResponseMessage, error) {
+	response, _ := data["response"].(map[string]any)
+	id, _ := response["request_id"].(string)
+	subtype, _ := response["subtype"].(string)
+	if id == "" || subtype == "" {
+		return nil, shared.NewMessageParseError("control response missing request_id or subtype field", data)
+	}
+
+	resp := &shared.ControlResponse{ID: id, Subtype: shared.ControlResponseType(subtype)}
+	resp.Data, _ = response["response"].(map[string]any)
+	if subtype == "error" {
+		message, _ := response["error"].(string)
+		resp.Error = &shared.ControlResponseError{Message: message}
+	}
+	return &shared.ControlResponseMessage{Response: resp}, nil
+}
+
 // parseResultMessage parses a result message from raw JSON data.
 func (p *Parser) parseResultMessage(data map[string]any) (*shared.ResultMessage, error) {
 	result := &shared.ResultMessage{}
@@ -295,7 +549,11 @@ func (p *Parser) parseResultMessage(data map[string]any) (*shared.ResultMessage,
 	}
 
 	if usage, ok := data["usage"].(map[string]any); ok {
-		result.Usage = &usage
+		result.Usage = parseUsage(usage)
+	}
+
+	if denials, ok := data["permission_denials"].([]any); ok {
+		result.PermissionDenials = parsePermissionDenials(denials)
 	}
 
 	if resultData, ok := data["result"]; ok {
@@ -312,6 +570,69 @@ func (p *Parser) parseResultMessage(data map[string]any) (*shared.ResultMessage,
 	return result, nil
 }
 
+// parseUsage reads the token counts of a result's usage object. Counts the
+// CLI omits are left at zero.
+func parseUsage(data map[string]any) *shared.Usage {
+	count := func(key string) int {
+		if n, ok := data[key].(float64); ok {
+			return int(n)
+		}
+		return 0
+	}
+	return &shared.Usage{
+		InputTokens:   count("input_tokens"),
+		OutputTokens:  count("output_tokens"),
+		CacheCreation: count("cache_creation_input_tokens"),
+		CacheRead:     count("cache_read_input_tokens"),
+	}
+}
+
+// parsePermissionDenials reads the tool calls a result reports as denied,
+// skipping malformed entries.
+func parsePermissionDenials(data []any) []shared.PermissionDenial {
+	denials := make([]shared.PermissionDenial, 0, len(data))
+	for _, item := range data {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		denial := shared.PermissionDenial{}
+		denial.ToolName, _ = entry["tool_name"].(string)
+		denial.ToolUseID, _ = entry["tool_use_id"].(string)
+		denial.ToolInput, _ = entry["tool_input"].(map[string]any)
+		denials = append(denials, denial)
+	}
+	return denials
+}
+
+// parseContentBlocks parses the content blocks of a message. Text blocks,
+// the most common kind, share one backing array instead of being allocated
+// one by one.
+func (p *Parser) parseContentBlocks(items []any) ([]shared.ContentBlock, error) {
+	blocks := make([]shared.ContentBlock, len(items))
+	var texts []shared.TextBlock
+	for i, item := range items {
+		if data, ok := item.(map[string]any); ok && data["type"] == shared.ContentBlockTypeText {
+			if text, ok := data["text"].(string); ok {
+				if texts == nil {
+					texts = make([]shared.TextBlock, 0, len(items)-i)
+				}
+				// Appending within capacity keeps earlier pointers valid
+				texts = append(texts, shared.TextBlock{Text: text})
+				blocks[i] = &texts[len(texts)-1]
+				continue
+			}
+		}
+
+		block, err := p.parseContentBlock(item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse content block %d: %w", i, err)
+		}
+		blocks[i] = block
+	}
+	return blocks, nil
+}
+
 // parseContentBlock parses a content block based on its type field.
 func (p *Parser) parseContentBlock(blockData any) (shared.ContentBlock, error) {
 	data, ok := blockData.(map[string]any)
@@ -346,7 +667,9 @@ func (p *Parser) parseTextBlock(data map[string]any) (shared.ContentBlock, error
 	if !ok {
 		return nil, shared.NewMessageParseError("text block missing text field", data)
 	}
-	return &shared.TextBlock{Text: text}, nil
+	block := p.newTextBlock()
+	block.Text = text
+	return block, nil
 }
 
 func (p *Parser) parseThinkingBlock(data map[string]any) (shared.ContentBlock, error) {
@@ -355,10 +678,10 @@ func (p *Parser) parseThinkingBlock(data map[string]any) (shared.ContentBlock, e
 		return nil, shared.NewMessageParseError("thinking block missing thinking field", data)
 	}
 	signature, _ := data["signature"].(string) // Optional field
-	return &shared.ThinkingBlock{
-		Thinking:  thinking,
-		Signature: signature,
-	}, nil
+	block := p.newThinkingBlock()
+	block.Thinking = thinking
+	block.Signature = signature
+	return block, nil
 }
 
 func (p *Parser) parseToolUseBlock(data map[string]any) (shared.ContentBlock, error) {
@@ -374,11 +697,11 @@ func (p *Parser) parseToolUseBlock(data map[string]any) (shared.ContentBlock, er
 	if input == nil {
 		input = make(map[string]any)
 	}
-	return &shared.ToolUseBlock{
-		ToolUseID: id,
-		Name:      name,
-		Input:     input,
-	}, nil
+	block := p.newToolUseBlock()
+	block.ToolUseID = id
+	block.Name = name
+	block.Input = input
+	return block, nil
 }
 
 func (p *Parser) parseToolResultBlock(data map[string]any) (shared.ContentBlock, error) {
@@ -394,11 +717,109 @@ func (p *Parser) parseToolResultBlock(data map[string]any) (shared.ContentBlock,
 		}
 	}
 
-	return &shared.ToolResultBlock{
-		ToolUseID: toolUseID,
-		Content:   data["content"],
-		IsError:   isError,
-	}, nil
+	content := data["content"]
+	if p.maxInlineResultBytes > 0 {
+		if err := p.spillLargeImages(content); err != nil {
+			return nil, fmt.Errorf("failed to spill tool result image: %w", err)
+		}
+	}
+
+	block := p.newToolResultBlock()
+	block.ToolUseID = toolUseID
+	block.Content = content
+	block.IsError = isError
+	return block, nil
+}
+
+// spillLargeImages replaces base64 image sources larger than
+// maxInlineResultBytes with file sources pointing at the decoded image in a
+// temporary file.
+func (p *Parser) spillLargeImages(content any) error {
+	blocks, ok := content.([]any)
+	if !ok {
+		return nil
+	}
+
+	for _, item := range blocks {
+		block, ok := item.(map[string]any)
+		if !ok || block["type"] != shared.ContentBlockTypeImage {
+			continue
+		}
+		source, ok := block["source"].(map[string]any)
+		if !ok || source["type"] != shared.ImageSourceTypeBase64 {
+			continue
+		}
+		encoded, _ := source["data"].(string)
+		if len(encoded) <= p.maxInlineResultBytes {
+			continue
+		}
+
+		mediaType, _ := source["media_type"].(string)
+		path, err := writeImageFile(strings.NewReader(encoded), mediaType)
+		if err != nil {
+			return err
+		}
+		p.files = append(p.files, path)
+		block["source"] = fileImageSource(path, mediaType)
+	}
+	return nil
+}
+
+// fileImageSource is the source of an image spilled to path.
+func fileImageSource(path, mediaType string) map[string]any {
+	return map[string]any{
+		"type":       shared.ImageSourceTypeFile,
+		"media_type": mediaType,
+		"path":       path,
+	}
+}
+
+// RemoveSpilledFiles removes the image files written for tool results so
+// far. The transport calls it when it closes.
+func (p *Parser) RemoveSpilledFiles() {
+	p.mu.Lock()
+	files := p.files
+	p.files = nil
+	p.mu.Unlock()
+	for _, path := range files {
+		_ = os.Remove(path)
+	}
+}
+
+// writeImageFile decodes base64 image data into a new temporary file.
+func writeImageFile(encoded io.Reader, mediaType string) (string, error) {
+	file, err := os.CreateTemp("", "claude-tool-result-*"+imageExtension(mediaType))
+	if err != nil {
+		return "", err
+	}
+
+	decoder := base64.NewDecoder(base64.StdEncoding, encoded)
+	_, copyErr := io.Copy(file, decoder)
+	closeErr := file.Close()
+	if copyErr != nil || closeErr != nil {
+		_ = os.Remove(file.Name())
+		if copyErr != nil {
+			return "", copyErr
+		}
+		return "", closeErr
+	}
+	return file.Name(), nil
+}
+
+// imageExtension returns a file extension for common image media types.
+func imageExtension(mediaType string) string {
+	switch mediaType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ""
+	}
 }
 
 // ParseMessages is a convenience function to parse multiple JSON lines.
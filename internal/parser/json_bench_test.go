@@ -0,0 +1,247 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+// Allocation budgets for the hot message path, enforced by
+// TestDecodeAllocationBudgets. Decoding JSON into map[string]any accounts
+// for nearly all allocations, roughly eight to ten per object depending on
+// the Go version, so budgets scale with the values in a message. The
+// parser's own overhead is a few allocations per line; the budgets catch it
+// growing.
+const (
+	// assistantBlockCount is the number of text blocks in the benchmark
+	// assistant message.
+	assistantBlockCount = 100
+	// assistantAllocBudget allows eleven allocations per text block.
+	assistantAllocBudget = assistantBlockCount*11 + 20
+	// toolResultAllocBudget covers a 1MB tool result. Its content is copied
+	// once, into the result string; line buffers are reused.
+	toolResultAllocBudget = 55
+	// streamMessageAllocBudget is the average per message of a mixed
+	// stream of tool calls, tool results and turn results.
+	streamMessageAllocBudget = 50
+)
+
+const streamMessageCount = 10000
+
+// benchCodecs are the JSON codecs BenchmarkDecodeCLITraffic compares. The
+// module has no dependencies, so only encoding/json is listed; to measure
+// another codec, add it here in a local checkout, for example
+// {"sonic", sonic.ConfigStd} or {"jsoniter",
+// jsoniter.ConfigCompatibleWithStandardLibrary}.
+var benchCodecs = []struct {
+	name  string
+	codec shared.JSONCodec
+}{
+	{"encoding_json", shared.StdJSONCodec{}},
+}
+
+// raceEnabled is set in race builds, where allocation counts are skewed.
+var raceEnabled bool
+
+func BenchmarkDecodeAssistantMessage(b *testing.B) {
+	line := assistantLine(assistantBlockCount)
+	parser := New()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(line)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.ProcessLine(line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeAssistantMessageRecycled(b *testing.B) {
+	line := assistantLine(assistantBlockCount)
+	parser := New()
+	parser.SetMessageRecycling(true)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(line)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		messages, err := parser.ProcessLine(line)
+		if err != nil {
+			b.Fatal(err)
+		}
+		messages[0].Release()
+	}
+}
+
+func BenchmarkDecodeLargeToolResult(b *testing.B) {
+	line := toolResultLine(1024 * 1024)
+	parser := NewWithMaxBufferSize(2 * len(line))
+	b.ReportAllocs()
+	b.SetBytes(int64(len(line)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.ProcessLine(line); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeStream(b *testing.B) {
+	lines := streamLines(streamMessageCount)
+	parser := New()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			if _, err := parser.ProcessLine(line); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkDecodeCLITraffic decodes a session recorded from the CLI: init,
+// tool calls with their results, thinking and the turn result.
+func BenchmarkDecodeCLITraffic(b *testing.B) {
+	data, err := os.ReadFile("testdata/cli_traffic.jsonl")
+	if err != nil {
+		b.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+
+	for _, bc := range benchCodecs {
+		b.Run(bc.name, func(b *testing.B) {
+			parser := New()
+			parser.SetJSONCodec(bc.codec)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, line := range lines {
+					if _, err := parser.ProcessLine(line); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeAllocationBudgets(t *testing.T) {
+	if testing.Short() || raceEnabled {
+		t.Skip("allocation budgets are measured in full test runs without the race detector")
+	}
+
+	tests := []struct {
+		name   string
+		parser *Parser
+		lines  []string
+		budget float64
+	}{
+		{
+			name:   "assistant_message_100_blocks",
+			parser: New(),
+			lines:  []string{assistantLine(assistantBlockCount)},
+			budget: assistantAllocBudget,
+		},
+		{
+			name:   "tool_result_1mb",
+			parser: NewWithMaxBufferSize(4 * 1024 * 1024),
+			lines:  []string{toolResultLine(1024 * 1024)},
+			budget: toolResultAllocBudget,
+		},
+		{
+			name:   "stream_per_message",
+			parser: New(),
+			lines:  streamLines(300),
+			budget: streamMessageAllocBudget * 300,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			allocs := testing.AllocsPerRun(10, func() {
+				for _, line := range test.lines {
+					if _, err := test.parser.ProcessLine(line); err != nil {
+						t.Fatal(err)
+					}
+				}
+			})
+			if allocs > test.budget {
+				t.Errorf("Decoding allocated %.0f times, budget is %.0f", allocs, test.budget)
+			}
+		})
+	}
+}
+
+func assistantLine(blocks int) string {
+	content := make([]any, blocks)
+	for i := range content {
+		content[i] = map[string]any{"type": "text", "text": fmt.Sprintf("Paragraph %d of the answer.", i)}
+	}
+	return benchLine(map[string]any{
+		"type":       "assistant",
+		"session_id": "bench-session",
+		"message":    map[string]any{"role": "assistant", "model": "claude-sonnet-4-5", "content": content},
+	})
+}
+
+func toolResultLine(size int) string {
+	return benchLine(map[string]any{
+		"type":       "user",
+		"session_id": "bench-session",
+		"message": map[string]any{"role": "user", "content": []any{map[string]any{
+			"type":        "tool_result",
+			"tool_use_id": "toolu_bench",
+			"content":     strings.Repeat("x", size),
+			"is_error":    false,
+		}}},
+	})
+}
+
+// streamLines returns a realistic mix: tool calls, their results and the
+// occasional turn result.
+func streamLines(count int) []string {
+	toolUse := benchLine(map[string]any{
+		"type": "assistant",
+		"message": map[string]any{"model": "claude-sonnet-4-5", "content": []any{
+			map[string]any{"type": "text", "text": "Let me look at that file."},
+			map[string]any{"type": "tool_use", "id": "toolu_1", "name": "Read", "input": map[string]any{"file_path": "/src/main.go"}},
+		}},
+	})
+	toolResult := benchLine(map[string]any{
+		"type": "user",
+		"message": map[string]any{"content": []any{map[string]any{
+			"type": "tool_result", "tool_use_id": "toolu_1", "content": strings.Repeat("package main\n", 20),
+		}}},
+	})
+	result := benchLine(map[string]any{
+		"type": "result", "subtype": "success", "duration_ms": 1200, "duration_api_ms": 900,
+		"is_error": false, "num_turns": 3, "session_id": "bench-session", "total_cost_usd": 0.01,
+		"usage": map[string]any{"input_tokens": 1200, "output_tokens": 300},
+	})
+
+	lines := make([]string, count)
+	for i := range lines {
+		switch {
+		case i%50 == 49:
+			lines[i] = result
+		case i%2 == 0:
+			lines[i] = toolUse
+		default:
+			lines[i] = toolResult
+		}
+	}
+	return lines
+}
+
+func benchLine(msg map[string]any) string {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,197 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+// fuzzSeeds covers the stream shapes the parser must survive: valid
+// messages, truncated lines, control traffic interleaved with messages,
+// invalid UTF-8 and duplicate keys. More seeds live in testdata/fuzz.
+var fuzzSeeds = []string{
+	`{"type":"assistant","message":{"content":[{"type":"text","text":"hi"}],"model":"m"}}`,
+	`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":"ok","is_error":false}]}}`,
+	`{"type":"system","subtype":"init","session_id":"s1"}`,
+	`{"type":"result","subtype":"success","duration_ms":1,"duration_api_ms":1,"is_error":false,"num_turns":1,"session_id":"s1","usage":{"input_tokens":3}}`,
+	`{"type":"assistant","message":{"content":[{"type":"text","te`,
+	`{"type":"control_response","response":{"subtype":"success","request_id":"req_1","response":{}}}`,
+	`{"type":"control_request","request_id":"r","request":{"subtype":"can_use_tool","tool_name":"Bash"}}`,
+	"{\"type\":\"user\",\"message\":{\"content\":\"bad \xff\xfe utf-8\"}}",
+	`{"type":"system","type":"result","subtype":"x"}`,
+	`{"type":"result","subtype":"success","subtype":1}`,
+	`[1,2,3]`,
+	`null`,
+	`{"a":}`,
+	`{"type":"system","subtype":"s"} trailing`,
+}
+
+func FuzzProcessLine(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		parser := NewWithMaxBufferSize(64 * 1024)
+		messages, err := parser.ProcessLine(line)
+		assertFuzzResult(t, parser, messages, err)
+	})
+}
+
+func FuzzProcessLineSequence(f *testing.F) {
+	for _, first := range fuzzSeeds {
+		f.Add(first, fuzzSeeds[0])
+	}
+	f.Add(`{"type":"user","message":`, `{"content":"split"}}`)
+
+	f.Fuzz(func(t *testing.T, first, second string) {
+		parser := NewWithMaxBufferSize(64 * 1024)
+		for _, line := range []string{first, second} {
+			messages, err := parser.ProcessLine(line)
+			assertFuzzResult(t, parser, messages, err)
+		}
+
+		// Whatever came before, a complete message is never lost
+		messages, err := parser.ProcessLine(fuzzSeeds[2])
+		assertFuzzResult(t, parser, messages, err)
+		if len(messages) == 0 || messages[len(messages)-1].Type() != shared.MessageTypeSystem {
+			t.Fatalf("Expected the system message after %q, %q; got %v (err %v)", first, second, messages, err)
+		}
+	})
+}
+
+// assertFuzzResult checks the parser's invariants for any input.
+func assertFuzzResult(t *testing.T, parser *Parser, messages []shared.Message, err error) {
+	t.Helper()
+	if err != nil && !isTypedParseError(err) {
+		t.Fatalf("Expected a typed parse error, got %T: %v", err, err)
+	}
+	for _, msg := range messages {
+		if msg == nil {
+			t.Fatal("Parser returned a nil message")
+		}
+		assertValidUTF8Text(t, msg)
+	}
+	if parser.BufferSize() > parser.maxBufferSize {
+		t.Fatalf("Buffer grew to %d bytes, limit %d", parser.BufferSize(), parser.maxBufferSize)
+	}
+}
+
+func isTypedParseError(err error) bool {
+	var decodeErr *shared.JSONDecodeError
+	var parseErr *shared.MessageParseError
+	return errors.As(err, &decodeErr) || errors.As(err, &parseErr)
+}
+
+func assertValidUTF8Text(t *testing.T, msg shared.Message) {
+	t.Helper()
+	var blocks []shared.ContentBlock
+	switch m := msg.(type) {
+	case *shared.AssistantMessage:
+		blocks = m.Content
+	case *shared.UserMessage:
+		if text, ok := m.Content.(string); ok && !utf8.ValidString(text) {
+			t.Fatalf("User message content is not valid UTF-8: %q", text)
+		}
+		blocks, _ = m.Content.([]shared.ContentBlock)
+	}
+	for _, block := range blocks {
+		if text, ok := block.(*shared.TextBlock); ok && !utf8.ValidString(text.Text) {
+			t.Fatalf("Text block is not valid UTF-8: %q", text.Text)
+		}
+	}
+}
+
+func TestMalformedInputReportsTypedErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []string
+		wantTypes []string
+		wantErr   string
+	}{
+		{
+			name:    "invalid_syntax",
+			lines:   []string{`{"type":}`},
+			wantErr: "json_decode_error",
+		},
+		{
+			name:    "non_object",
+			lines:   []string{`["type","user"]`},
+			wantErr: "json_decode_error",
+		},
+		{
+			name:    "trailing_data",
+			lines:   []string{`{"type":"system","subtype":"s"} garbage`},
+			wantErr: "json_decode_error",
+		},
+		{
+			name:      "truncated_line_then_message",
+			lines:     []string{`{"type":"assistant","message":{"content":[`, `{"type":"system","subtype":"s"}`},
+			wantTypes: []string{shared.MessageTypeSystem},
+			wantErr:   "json_decode_error",
+		},
+		{
+			name:    "control_response_without_id",
+			lines:   []string{`{"type":"control_response","response":{"subtype":"success"}}`},
+			wantErr: "message_parse_error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parser := setupParserTest(t)
+			var messages []shared.Message
+			var lastErr error
+			for _, line := range test.lines {
+				parsed, err := parser.ProcessLine(line)
+				messages = append(messages, parsed...)
+				if err != nil {
+					lastErr = err
+				}
+			}
+
+			if len(messages) != len(test.wantTypes) {
+				t.Fatalf("Expected %d messages, got %d", len(test.wantTypes), len(messages))
+			}
+			for i, msg := range messages {
+				assertMessageType(t, msg, test.wantTypes[i])
+			}
+			assertTypedParseError(t, lastErr, test.wantErr)
+			assertBufferEmpty(t, parser)
+		})
+	}
+}
+
+func TestInvalidUTF8AndDuplicateKeys(t *testing.T) {
+	parser := setupParserTest(t)
+
+	messages, err := parser.ProcessLine("{\"type\":\"user\",\"message\":{\"content\":\"a\xffb\"}}")
+	assertNoParseError(t, err)
+	assertMessageCount(t, messages, 1)
+	if content := messages[0].(*shared.UserMessage).Content; content != "a�b" {
+		t.Errorf("Expected invalid bytes to be replaced, got %q", content)
+	}
+
+	// The last occurrence of a duplicated key wins
+	messages, err = parser.ProcessLine(`{"type":"user","type":"system","subtype":"s"}`)
+	assertNoParseError(t, err)
+	assertMessageCount(t, messages, 1)
+	assertMessageType(t, messages[0], shared.MessageTypeSystem)
+}
+
+func assertTypedParseError(t *testing.T, err error, wantType string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("Expected %s, got nil", wantType)
+	}
+	var typed interface{ Type() string }
+	if !errors.As(err, &typed) || typed.Type() != wantType {
+		t.Errorf("Expected %s, got %T: %v", wantType, err, err)
+	}
+	if strings.TrimSpace(err.Error()) == "" {
+		t.Error("Expected a descriptive error message")
+	}
+}
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 	"testing"
@@ -123,6 +126,25 @@ func TestParseValidMessages(t *testing.T) {
 			},
 			expectedType: shared.MessageTypeAssistant,
 		},
+		{
+			name: "assistant_message_with_parent_tool_use_id",
+			data: map[string]any{
+				"type":               "assistant",
+				"parent_tool_use_id": "task-1",
+				"message": map[string]any{
+					"content": []any{map[string]any{"type": "text", "text": "From subagent"}},
+					"model":   "claude-3-sonnet",
+				},
+			},
+			expectedType: shared.MessageTypeAssistant,
+			validate: func(t *testing.T, msg shared.Message) {
+				t.Helper()
+				am := msg.(*shared.AssistantMessage)
+				if am.GetParentToolUseID() != "task-1" {
+					t.Errorf("expected ParentToolUseID 'task-1', got %v", am.ParentToolUseID)
+				}
+			},
+		},
 		// Issue #23: AssistantMessage error field tests
 		{
 			name: "assistant_message_with_rate_limit_error",
@@ -216,6 +238,40 @@ func TestParseValidMessages(t *testing.T) {
 			},
 			expectedType: shared.MessageTypeResult,
 		},
+		{
+			name: "control_request",
+			data: map[string]any{
+				"type":       "control_request",
+				"request_id": "req_1",
+				"request":    map[string]any{"subtype": "can_use_tool", "tool_name": "Bash", "input": map[string]any{"command": "ls"}},
+			},
+			expectedType: shared.MessageTypeControlRequest,
+			validate: func(t *testing.T, msg shared.Message) {
+				t.Helper()
+				req := msg.(*shared.ControlRequestMessage).Request
+				if req.ID != "req_1" || req.Subtype != "can_use_tool" || req.Data["tool_name"] != "Bash" {
+					t.Errorf("unexpected request %+v", req)
+				}
+				if _, ok := req.Data["subtype"]; ok {
+					t.Error("expected the subtype left out of the request data")
+				}
+			},
+		},
+		{
+			name: "control_response_error",
+			data: map[string]any{
+				"type":     "control_response",
+				"response": map[string]any{"subtype": "error", "request_id": "sdk-ctrl-1", "error": "unknown model"},
+			},
+			expectedType: shared.MessageTypeControlResponse,
+			validate: func(t *testing.T, msg shared.Message) {
+				t.Helper()
+				resp := msg.(*shared.ControlResponseMessage).Response
+				if resp.ID != "sdk-ctrl-1" || resp.Error == nil || resp.Error.Message != "unknown model" {
+					t.Errorf("unexpected response %+v", resp)
+				}
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -248,6 +304,11 @@ func TestParseErrors(t *testing.T) {
 			data:        map[string]any{"type": "unknown_type", "content": "test"},
 			expectError: "unknown message type: unknown_type",
 		},
+		{
+			name:        "control_request_missing_subtype",
+			data:        map[string]any{"type": "control_request", "request_id": "req_1", "request": map[string]any{}},
+			expectError: "control request missing request_id or subtype field",
+		},
 		{
 			name:        "user_message_missing_message_field",
 			data:        map[string]any{"type": "user"},
@@ -520,6 +581,32 @@ func TestLargeMessageHandling(t *testing.T) {
 	assertBufferEmpty(t, parser)
 }
 
+// TestNewWithMaxBufferSize tests parsers with a custom buffer limit
+func TestNewWithMaxBufferSize(t *testing.T) {
+	content := strings.Repeat("X", 2*MaxBufferSize)
+	largeJSON := fmt.Sprintf(`{"type": "user", "message": {"content": %q}}`, content)
+
+	// Default parser rejects the message
+	_, err := setupParserTest(t).ProcessLine(largeJSON)
+	if err == nil {
+		t.Fatal("Expected default parser to reject message over MaxBufferSize")
+	}
+
+	// Larger limit accepts it
+	parser := NewWithMaxBufferSize(3 * MaxBufferSize)
+	messages, err := parser.ProcessLine(largeJSON)
+	assertNoParseError(t, err)
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	assertBufferEmpty(t, parser)
+
+	// Non-positive limits fall back to the default
+	if got := NewWithMaxBufferSize(0).maxBufferSize; got != MaxBufferSize {
+		t.Errorf("Expected default limit %d, got %d", MaxBufferSize, got)
+	}
+}
+
 // TestEmptyAndWhitespaceHandling tests handling of empty lines
 func TestEmptyAndWhitespaceHandling(t *testing.T) {
 	parser := setupParserTest(t)
@@ -881,6 +968,78 @@ func TestContentBlockErrorConditions(t *testing.T) {
 	}
 }
 
+// TestToolResultImageSpilling tests that large tool result images are written to files
+func TestToolResultImageSpilling(t *testing.T) {
+	small := base64.StdEncoding.EncodeToString([]byte("tiny"))
+	large := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("P", 300)))
+
+	blockData := func() map[string]any {
+		return map[string]any{
+			"type":        "tool_result",
+			"tool_use_id": "t1",
+			"content": []any{
+				map[string]any{"type": "image", "source": map[string]any{
+					"type": "base64", "media_type": "image/png", "data": small,
+				}},
+				map[string]any{"type": "image", "source": map[string]any{
+					"type": "base64", "media_type": "image/png", "data": large,
+				}},
+			},
+		}
+	}
+
+	t.Run("inline_by_default", func(t *testing.T) {
+		block, err := setupParserTest(t).parseContentBlock(blockData())
+		assertNoParseError(t, err)
+		for i, image := range block.(*shared.ToolResultBlock).Images() {
+			if image.IsFile() {
+				t.Errorf("image %d: expected inline image without a limit", i)
+			}
+		}
+	})
+
+	t.Run("large_image_spilled", func(t *testing.T) {
+		parser := setupParserTest(t)
+		parser.SetMaxInlineResultBytes(100)
+
+		block, err := parser.parseContentBlock(blockData())
+		assertNoParseError(t, err)
+		images := block.(*shared.ToolResultBlock).Images()
+		if len(images) != 2 {
+			t.Fatalf("Expected 2 images, got %d", len(images))
+		}
+		if images[0].IsFile() {
+			t.Error("Expected small image to stay inline")
+		}
+		if !images[1].IsFile() {
+			t.Fatal("Expected large image to be spilled to a file")
+		}
+		defer images[1].Remove()
+
+		if !strings.HasSuffix(images[1].File, ".png") {
+			t.Errorf("Expected .png file, got %q", images[1].File)
+		}
+		data, err := images[1].Data()
+		if err != nil {
+			t.Fatalf("Data failed: %v", err)
+		}
+		if string(data) != strings.Repeat("P", 300) {
+			t.Errorf("Expected decoded image bytes, got %d bytes", len(data))
+		}
+	})
+
+	t.Run("invalid_base64", func(t *testing.T) {
+		parser := setupParserTest(t)
+		parser.SetMaxInlineResultBytes(1)
+
+		data := blockData()
+		data["content"].([]any)[1].(map[string]any)["source"].(map[string]any)["data"] = "!!not base64!!"
+		if _, err := parser.parseContentBlock(data); err == nil {
+			t.Error("Expected error for invalid base64 image data")
+		}
+	})
+}
+
 // TestContentBlockOptionalFields tests optional field handling
 func TestContentBlockOptionalFields(t *testing.T) {
 	parser := setupParserTest(t)
@@ -1311,3 +1470,144 @@ func TestResultMessageStructuredOutputWithOtherFields(t *testing.T) {
 		t.Errorf("Expected confidence = 0.95, got %v", output["confidence"])
 	}
 }
+
+// TestResultMessageTypedMetadata tests usage and permission denials are parsed into typed fields
+func TestResultMessageTypedMetadata(t *testing.T) {
+	parser := setupParserTest(t)
+
+	msg, err := parser.ParseMessage(map[string]any{
+		"type":            "result",
+		"subtype":         "success",
+		"duration_ms":     2500.0,
+		"duration_api_ms": 1800.0,
+		"is_error":        false,
+		"num_turns":       3.0,
+		"session_id":      "s123",
+		"total_cost_usd":  0.0125,
+		"usage": map[string]any{
+			"input_tokens":                120.0,
+			"output_tokens":               45.0,
+			"cache_creation_input_tokens": 300.0,
+			"cache_read_input_tokens":     900.0,
+			"service_tier":                "standard",
+		},
+		"permission_denials": []any{
+			map[string]any{
+				"tool_name":   "Bash",
+				"tool_use_id": "toolu_01",
+				"tool_input":  map[string]any{"command": "rm -rf build"},
+			},
+			"malformed",
+		},
+	})
+	assertNoParseError(t, err)
+
+	result := msg.(*shared.ResultMessage)
+	if result.DurationMs != 2500 || result.DurationAPIMs != 1800 || result.NumTurns != 3 {
+		t.Errorf("Unexpected durations or turns: %d, %d, %d", result.DurationMs, result.DurationAPIMs, result.NumTurns)
+	}
+	wantUsage := shared.Usage{InputTokens: 120, OutputTokens: 45, CacheCreation: 300, CacheRead: 900}
+	if result.Usage == nil || *result.Usage != wantUsage {
+		t.Errorf("Expected usage %+v, got %+v", wantUsage, result.Usage)
+	}
+	if len(result.PermissionDenials) != 1 {
+		t.Fatalf("Expected 1 permission denial, got %d", len(result.PermissionDenials))
+	}
+	denial := result.PermissionDenials[0]
+	if denial.ToolName != "Bash" || denial.ToolUseID != "toolu_01" || denial.ToolInput["command"] != "rm -rf build" {
+		t.Errorf("Unexpected permission denial: %+v", denial)
+	}
+}
+
+func TestSetJSONCodec(t *testing.T) {
+	parser := setupParserTest(t)
+	codecErr := errors.New("codec failure")
+	codec := &stubCodec{err: codecErr}
+	parser.SetJSONCodec(codec)
+
+	// Complete lines go through the codec and its errors are reported
+	_, err := parser.ProcessLine(`{"type":"system","subtype":"init"}`)
+	if !errors.Is(err, codecErr) {
+		t.Errorf("Expected the codec error, got %v", err)
+	}
+	if codec.calls != 1 {
+		t.Errorf("Expected one codec call, got %d", codec.calls)
+	}
+
+	parser.SetJSONCodec(nil)
+	messages, err := parser.ProcessLine(`{"type":"system","subtype":"init"}`)
+	assertNoParseError(t, err)
+	assertMessageCount(t, messages, 1)
+	if codec.calls != 1 {
+		t.Errorf("Expected a nil codec to restore encoding/json, got %d codec calls", codec.calls)
+	}
+}
+
+// stubCodec fails every decode with err.
+type stubCodec struct {
+	err   error
+	calls int
+}
+
+func (c *stubCodec) Marshal(v any) ([]byte, error) {
+	return nil, c.err
+}
+
+func (c *stubCodec) Unmarshal(data []byte, v any) error {
+	c.calls++
+	return c.err
+}
+
+func TestSetStrictProtocol(t *testing.T) {
+	parser := setupParserTest(t)
+	parser.SetStrictProtocol(true)
+
+	// Recorded CLI traffic matches its protocol
+	data, err := os.ReadFile("testdata/cli_traffic.jsonl")
+	if err != nil {
+		t.Fatalf("Failed to read testdata: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		_, err := parser.ProcessLine(line)
+		assertNoParseError(t, err)
+	}
+
+	// A mismatched message is dropped and reported
+	messages, err := parser.ProcessLine(`{"type":"result","subtype":"success","duration_ms":"10","duration_api_ms":8,"is_error":false,"num_turns":1,"session_id":"s1"}`)
+	var protocolErr *shared.ProtocolError
+	if !errors.As(err, &protocolErr) || protocolErr.Field != "duration_ms" || protocolErr.Version != "2.0.14" {
+		t.Errorf("Expected a ProtocolError for duration_ms from CLI 2.0.14, got %v", err)
+	}
+	assertMessageCount(t, messages, 0)
+
+	parser.SetStrictProtocol(false)
+	messages, err = parser.ProcessLine(`{"type":"result","subtype":"success","duration_ms":10,"duration_api_ms":8,"is_error":false,"num_turns":1,"session_id":"s1","usage":{"input_tokens":"3"}}`)
+	assertNoParseError(t, err)
+	assertMessageCount(t, messages, 1)
+}
+
+func TestProtocolShims(t *testing.T) {
+	const legacyResult = `{"type":"result","subtype":"success","duration_ms":10,"duration_api_ms":8,"is_error":false,"num_turns":1,"session_id":"s1","cost_usd":0.01,"total_cost":0.25}`
+
+	// A CLI predating claude_code_version reports the cost as total_cost
+	parser := setupParserTest(t)
+	_, err := parser.ProcessLine(`{"type":"system","subtype":"init","session_id":"s1"}`)
+	assertNoParseError(t, err)
+	messages, err := parser.ProcessLine(legacyResult)
+	assertNoParseError(t, err)
+	assertMessageCount(t, messages, 1)
+	result, ok := messages[0].(*shared.ResultMessage)
+	if !ok || result.TotalCostUSD == nil || *result.TotalCostUSD != 0.25 {
+		t.Errorf("Expected the legacy total_cost as TotalCostUSD, got %+v", messages[0])
+	}
+
+	// Current CLIs are not adapted
+	parser = setupParserTest(t)
+	_, err = parser.ProcessLine(`{"type":"system","subtype":"init","session_id":"s1","claude_code_version":"2.0.14"}`)
+	assertNoParseError(t, err)
+	messages, err = parser.ProcessLine(legacyResult)
+	assertNoParseError(t, err)
+	if result := messages[0].(*shared.ResultMessage); result.TotalCostUSD != nil {
+		t.Errorf("Expected no shim for CLI 2.0.14, got TotalCostUSD %v", *result.TotalCostUSD)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build race
+
+package parser
+
+func init() {
+	// The race detector randomly drops sync.Pool items, adding allocations
+	raceEnabled = true
+}
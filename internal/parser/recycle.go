@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+// parseRecycledBlocks parses content blocks taken from the shared pools,
+// appending them to blocks[:0] to reuse the slice of a recycled message. On
+// error, the blocks parsed so far are returned so releasing the message
+// returns them too.
+func (p *Parser) parseRecycledBlocks(items []any, blocks []shared.ContentBlock) ([]shared.ContentBlock, error) {
+	blocks = blocks[:0]
+	for i, item := range items {
+		block, err := p.parseContentBlock(item)
+		if err != nil {
+			return blocks, fmt.Errorf("failed to parse content block %d: %w", i, err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+func (p *Parser) newTextBlock() *shared.TextBlock {
+	if p.recycle {
+		return shared.AcquireTextBlock()
+	}
+	return &shared.TextBlock{}
+}
+
+func (p *Parser) newThinkingBlock() *shared.ThinkingBlock {
+	if p.recycle {
+		return shared.AcquireThinkingBlock()
+	}
+	return &shared.ThinkingBlock{}
+}
+
+func (p *Parser) newToolUseBlock() *shared.ToolUseBlock {
+	if p.recycle {
+		return shared.AcquireToolUseBlock()
+	}
+	return &shared.ToolUseBlock{}
+}
+
+func (p *Parser) newToolResultBlock() *shared.ToolResultBlock {
+	if p.recycle {
+		return shared.AcquireToolResultBlock()
+	}
+	return &shared.ToolResultBlock{}
+}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+func TestMessageRecycling(t *testing.T) {
+	parser := New()
+	parser.SetMessageRecycling(true)
+
+	for i := 0; i < 3; i++ {
+		messages, err := parser.ProcessLine(`{"type":"assistant","message":{"model":"m","content":[` +
+			`{"type":"text","text":"hi"},{"type":"thinking","thinking":"hmm","signature":"s"},` +
+			`{"type":"tool_use","id":"tool-1","name":"Read","input":{"file_path":"a.go"}}]}}`)
+		if err != nil || len(messages) != 1 {
+			t.Fatalf("ProcessLine failed: %v %v", messages, err)
+		}
+		msg := messages[0].(*shared.AssistantMessage)
+		if msg.Model != "m" || len(msg.Content) != 3 || msg.Content[0].(*shared.TextBlock).Text != "hi" {
+			t.Fatalf("Unexpected assistant message: %+v", msg)
+		}
+		if toolUse := msg.Content[2].(*shared.ToolUseBlock); toolUse.Input["file_path"] != "a.go" {
+			t.Errorf("Unexpected tool use: %+v", toolUse)
+		}
+		msg.Release()
+
+		messages, err = parser.ProcessLine(`{"type":"user","uuid":"u1","message":{"content":[` +
+			`{"type":"tool_result","tool_use_id":"tool-1","content":"data"}]}}`)
+		if err != nil || len(messages) != 1 {
+			t.Fatalf("ProcessLine failed: %v %v", messages, err)
+		}
+		user := messages[0].(*shared.UserMessage)
+		blocks := user.Content.([]shared.ContentBlock)
+		if user.UUID == nil || *user.UUID != "u1" || blocks[0].(*shared.ToolResultBlock).ToolUseID != "tool-1" {
+			t.Fatalf("Unexpected user message: %+v", user)
+		}
+		user.Release()
+	}
+}
+
+func TestMessageRecyclingReleasesOnError(t *testing.T) {
+	parser := New()
+	parser.SetMessageRecycling(true)
+
+	_, err := parser.ProcessLine(`{"type":"assistant","message":{"model":"m","content":[` +
+		`{"type":"text","text":"hi"},{"type":"bogus"}]}}`)
+	if err == nil {
+		t.Fatal("Expected an error for an unknown block type")
+	}
+
+	// The parser keeps working with the pools afterwards
+	messages, err := parser.ProcessLine(`{"type":"assistant","message":{"model":"m","content":[{"type":"text","text":"ok"}]}}`)
+	if err != nil {
+		t.Fatalf("ProcessLine failed: %v", err)
+	}
+	msg := messages[0].(*shared.AssistantMessage)
+	if len(msg.Content) != 1 || msg.Content[0].(*shared.TextBlock).Text != "ok" {
+		t.Errorf("Unexpected message after error: %+v", msg)
+	}
+	msg.Release()
+}
@@ -0,0 +1,295 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+// spillMarker starts the decoded value of a string that a StringSpiller
+// moved to a file; the file's path follows it. spillMarkerJSON is the same
+// marker as it appears in a line.
+const (
+	spillMarker     = "\x00claude-sdk-spill:"
+	spillMarkerJSON = `\u0000claude-sdk-spill:`
+)
+
+// StringSpiller builds a JSON line from the chunks it is read in, moving
+// every string longer than its limit to a temporary file as it arrives, so
+// large base64 images never sit in memory. The string is replaced in the
+// line by a marker naming its file, which Parser resolves.
+type StringSpiller struct {
+	limit int
+	line  []byte
+	// files holds the spilled strings until Cleanup; those from lineFiles
+	// on belong to the line being built
+	files     []string
+	lineFiles int
+
+	inString bool
+	escaped  bool
+	// start is the offset in line of the current string's content
+	start int
+	// file receives the current string once it outgrows limit
+	file *os.File
+}
+
+// NewStringSpiller creates a StringSpiller moving strings longer than limit
+// bytes to files.
+func NewStringSpiller(limit int) *StringSpiller {
+	return &StringSpiller{limit: limit}
+}
+
+// Write adds the next chunk of the line.
+func (s *StringSpiller) Write(chunk []byte) error {
+	for len(chunk) > 0 {
+		if !s.inString {
+			quote := bytes.IndexByte(chunk, '"')
+			if quote < 0 {
+				s.line = append(s.line, chunk...)
+				return nil
+			}
+			s.line = append(s.line, chunk[:quote+1]...)
+			s.inString, s.escaped, s.start = true, false, len(s.line)
+			chunk = chunk[quote+1:]
+			continue
+		}
+
+		end := s.stringEnd(chunk)
+		content := chunk
+		if end >= 0 {
+			content = chunk[:end]
+		}
+		if s.file != nil {
+			if _, err := s.file.Write(content); err != nil {
+				return err
+			}
+		} else {
+			s.line = append(s.line, content...)
+			if len(s.line)-s.start > s.limit {
+				if err := s.openFile(); err != nil {
+					return err
+				}
+			}
+		}
+		if end < 0 {
+			return nil
+		}
+
+		if err := s.closeFile(); err != nil {
+			return err
+		}
+		s.line = append(s.line, '"')
+		s.inString = false
+		chunk = chunk[end+1:]
+	}
+	return nil
+}
+
+// stringEnd returns the offset in chunk of the quote closing the current
+// string, or -1.
+func (s *StringSpiller) stringEnd(chunk []byte) int {
+	for i, b := range chunk {
+		switch {
+		case s.escaped:
+			s.escaped = false
+		case b == '\\':
+			s.escaped = true
+		case b == '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// openFile moves the current string's content to a new file.
+func (s *StringSpiller) openFile() error {
+	file, err := os.CreateTemp("", "claude-spill-*")
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.files = append(s.files, file.Name())
+	if _, err := file.Write(s.line[s.start:]); err != nil {
+		return err
+	}
+	s.line = s.line[:s.start]
+	return nil
+}
+
+// closeFile ends the spilled string, if any, writing its marker in place.
+func (s *StringSpiller) closeFile() error {
+	if s.file == nil {
+		return nil
+	}
+	file := s.file
+	s.file = nil
+	if err := file.Close(); err != nil {
+		return err
+	}
+	path, err := json.Marshal(file.Name())
+	if err != nil {
+		return err
+	}
+	s.line = append(s.line, spillMarkerJSON...)
+	s.line = append(s.line, path[1:len(path)-1]...)
+	return nil
+}
+
+// Len returns the length of the line so far, without spilled strings.
+func (s *StringSpiller) Len() int {
+	return len(s.line)
+}
+
+// Finish returns the line, with the files of its spilled strings, and
+// starts the next one. The files are kept until Cleanup.
+func (s *StringSpiller) Finish() ([]byte, []string, error) {
+	if err := s.closeFile(); err != nil {
+		s.Discard()
+		return nil, nil, err
+	}
+	line, files := s.line, s.files[s.lineFiles:]
+	s.line, s.inString, s.lineFiles = nil, false, len(s.files)
+	return line, files, nil
+}
+
+// Cleanup removes the files of the lines returned by Finish, once they are
+// parsed.
+func (s *StringSpiller) Cleanup() {
+	for _, path := range s.files {
+		_ = os.Remove(path)
+	}
+	s.files, s.lineFiles = nil, 0
+}
+
+// Discard drops the line being built and removes its spilled files.
+func (s *StringSpiller) Discard() {
+	if s.file != nil {
+		_ = s.file.Close()
+		s.file = nil
+	}
+	for _, path := range s.files[s.lineFiles:] {
+		_ = os.Remove(path)
+	}
+	s.files = s.files[:s.lineFiles]
+	s.line, s.inString = nil, false
+}
+
+// resolveSpilled replaces the strings in value that a StringSpiller moved
+// to files given to ProcessSpilledLine. Base64 images in tool results are decoded from the file into
+// image files when the parser spills images; other strings are read back
+// into memory and added to restored, which counts towards the buffer limit.
+func (p *Parser) resolveSpilled(value any, inToolResult bool, restored *int) (any, error) {
+	switch v := value.(type) {
+	case string:
+		path := strings.TrimPrefix(v, spillMarker)
+		if path == v || !p.spilled[path] {
+			return v, nil
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		*restored += len(raw)
+		if *restored > p.maxBufferSize {
+			return nil, fmt.Errorf("buffer size %d exceeds limit %d", *restored, p.maxBufferSize)
+		}
+		var s string
+		if err := json.Unmarshal(append(append([]byte{'"'}, raw...), '"'), &s); err != nil {
+			return nil, err
+		}
+		return s, nil
+
+	case []any:
+		for i, item := range v {
+			resolved, err := p.resolveSpilled(item, inToolResult, restored)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+
+	case map[string]any:
+		if inToolResult && p.maxInlineResultBytes > 0 && v["type"] == shared.ContentBlockTypeImage {
+			source, _ := v["source"].(map[string]any)
+			data, _ := source["data"].(string)
+			spilled := strings.TrimPrefix(data, spillMarker)
+			if source["type"] == shared.ImageSourceTypeBase64 && spilled != data && p.spilled[spilled] {
+				mediaType, _ := source["media_type"].(string)
+				path, err := writeSpilledImage(spilled, mediaType)
+				if err != nil {
+					return nil, err
+				}
+				p.files = append(p.files, path)
+				v["source"] = fileImageSource(path, mediaType)
+				return v, nil
+			}
+		}
+		inToolResult = inToolResult || v["type"] == shared.ContentBlockTypeToolResult
+		for key, item := range v {
+			resolved, err := p.resolveSpilled(item, inToolResult, restored)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+// writeSpilledImage decodes the base64 image data a StringSpiller wrote to
+// spilled into a new temporary file.
+func writeSpilledImage(spilled, mediaType string) (string, error) {
+	file, err := os.Open(spilled)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return writeImageFile(&jsonStringReader{r: bufio.NewReader(file)}, mediaType)
+}
+
+// jsonStringReader reads the content of a JSON string, without its quotes,
+// undoing the escapes base64 text can carry.
+type jsonStringReader struct {
+	r *bufio.Reader
+}
+
+func (j *jsonStringReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		b, err := j.r.ReadByte()
+		if err != nil {
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+		if b == '\\' {
+			escape, err := j.r.ReadByte()
+			if err != nil {
+				return n, io.ErrUnexpectedEOF
+			}
+			switch escape {
+			case '/', '\\', '"':
+				b = escape
+			case 'n':
+				b = '\n'
+			case 'r':
+				b = '\r'
+			default:
+				return n, fmt.Errorf("unexpected escape \\%c in image data", escape)
+			}
+		}
+		p[n] = b
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+// spillLine feeds line to a new StringSpiller in chunks of size bytes and
+// finishes it.
+func spillLine(t *testing.T, line string, limit, size int) (*StringSpiller, string, []string) {
+	t.Helper()
+	spill := NewStringSpiller(limit)
+	for rest := line; rest != ""; {
+		n := size
+		if n > len(rest) {
+			n = len(rest)
+		}
+		if err := spill.Write([]byte(rest[:n])); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		rest = rest[n:]
+	}
+	out, files, err := spill.Finish()
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	return spill, string(out), files
+}
+
+// TestStringSpiller tests that long strings leave the line as they are read
+func TestStringSpiller(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	long := strings.Repeat(`ab\"c`, 40)
+	line := `{"short":"x\"y","long":"` + long + `","n":1}`
+
+	spill, out, files := spillLine(t, line, 50, 7)
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 spilled string, got %d", len(files))
+	}
+	if want := `{"short":"x\"y","long":"` + spillMarkerJSON; !strings.HasPrefix(out, want) {
+		t.Errorf("Expected the long string replaced by a marker, got %s", out)
+	}
+	if !strings.HasSuffix(out, `","n":1}`) {
+		t.Errorf("Expected the rest of the line kept, got %s", out)
+	}
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Reading spilled string failed: %v", err)
+	}
+	if string(raw) != long {
+		t.Errorf("Expected the raw string in its file, got %q", raw)
+	}
+
+	spill.Cleanup()
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected Cleanup to remove the spilled string, got %v", err)
+	}
+}
+
+// TestProcessSpilledLine tests that spilled strings are restored, and
+// spilled tool result images written to image files
+func TestProcessSpilledLine(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	image := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("P??", 200)))
+	escaped := strings.ReplaceAll(image, "/", `\/`)
+	text := strings.Repeat("long text ", 20)
+	line := `{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":[` +
+		`{"type":"text","text":"` + text + `"},` +
+		`{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + escaped + `"}}]}]}}`
+
+	spill, out, files := spillLine(t, line, 100, 16)
+	defer spill.Cleanup()
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 spilled strings, got %d", len(files))
+	}
+
+	parser := setupParserTest(t)
+	parser.SetMaxInlineResultBytes(100)
+	messages, err := parser.ProcessSpilledLine(out, files)
+	assertNoParseError(t, err)
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	block := messages[0].(*shared.UserMessage).Content.([]shared.ContentBlock)[0].(*shared.ToolResultBlock)
+	if got := block.Content.([]any)[0].(map[string]any)["text"]; got != text {
+		t.Errorf("Expected the spilled text restored, got %q", got)
+	}
+	images := block.Images()
+	if len(images) != 1 || !images[0].IsFile() {
+		t.Fatalf("Expected the image in a file, got %+v", images)
+	}
+	data, err := images[0].Data()
+	if err != nil {
+		t.Fatalf("Data failed: %v", err)
+	}
+	if string(data) != strings.Repeat("P??", 200) {
+		t.Errorf("Expected decoded image bytes, got %d bytes", len(data))
+	}
+
+	parser.RemoveSpilledFiles()
+	if _, err := os.Stat(images[0].File); !os.IsNotExist(err) {
+		t.Errorf("Expected RemoveSpilledFiles to remove the image, got %v", err)
+	}
+}
+
+// TestProcessSpilledLineIgnoresForeignMarkers tests that only the files the
+// spiller wrote are read
+func TestProcessSpilledLineIgnoresForeignMarkers(t *testing.T) {
+	secret, err := os.CreateTemp(t.TempDir(), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = secret.WriteString("do not read")
+	_ = secret.Close()
+
+	text := spillMarkerJSON + secret.Name()
+	line := `{"type":"assistant","message":{"role":"assistant","model":"claude-3","content":[{"type":"text","text":"` + text + `"}]}}`
+	messages, err := setupParserTest(t).ProcessSpilledLine(line, nil)
+	assertNoParseError(t, err)
+	got := messages[0].(*shared.AssistantMessage).Content[0].(*shared.TextBlock).Text
+	if got != spillMarker+secret.Name() {
+		t.Errorf("Expected the imitated marker left alone, got %q", got)
+	}
+}
@@ -0,0 +1,251 @@
+// Package protocol tracks the CLI's stream-json protocol across versions:
+// it adapts the messages of older and newer CLIs to the shape the parser
+// reads, and validates messages against JSON Schemas of the CLI's output,
+// one schema for each range of CLI versions.
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schema is the subset of JSON Schema the embedded schemas use: type,
+// properties, required, items, enum, const (strings only), local $ref to
+// $defs, allOf and if/then. Unknown keywords are ignored, and properties
+// not listed are allowed, so fields added by newer CLIs pass.
+type schema struct {
+	Ref        string             `json:"$ref"`
+	Types      typeList           `json:"type"`
+	Properties map[string]*schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Items      *schema            `json:"items"`
+	Enum       []string           `json:"enum"`
+	Const      *string            `json:"const"`
+	AllOf      []*schema          `json:"allOf"`
+	If         *schema            `json:"if"`
+	Then       *schema            `json:"then"`
+	Defs       map[string]*schema `json:"$defs"`
+
+	// Resolved when the schema is compiled
+	target *schema
+	names  []string
+}
+
+// typeList is the type keyword: one JSON type or a list of them.
+type typeList []string
+
+func (t *typeList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = typeList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// mismatch is the first place a value departs from its schema.
+type mismatch struct {
+	field    string
+	expected string
+	got      string
+}
+
+// parseSchema decodes a schema document and resolves its references.
+func parseSchema(data []byte) (*schema, error) {
+	var root schema
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if err := root.compile(&root); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// compile resolves the references of s and its subschemas against root
+// and sorts property names, so mismatches are reported in a stable order.
+func (s *schema) compile(root *schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/$defs/")
+		target, ok := root.Defs[name]
+		if name == s.Ref || !ok {
+			return fmt.Errorf("unresolved reference %q", s.Ref)
+		}
+		s.target = target
+	}
+	for name := range s.Properties {
+		s.names = append(s.names, name)
+	}
+	sort.Strings(s.names)
+
+	children := []*schema{s.Items, s.If, s.Then}
+	children = append(children, s.AllOf...)
+	for _, name := range s.names {
+		children = append(children, s.Properties[name])
+	}
+	for _, def := range s.Defs {
+		children = append(children, def)
+	}
+	for _, child := range children {
+		if err := child.compile(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate returns the first mismatch between value and s, or nil. field
+// is the path of value within the message.
+func (s *schema) validate(value any, field string) *mismatch {
+	if s.target != nil {
+		return s.target.validate(value, field)
+	}
+	if len(s.Types) > 0 && !s.Types.matches(value) {
+		return &mismatch{field: field, expected: s.Types.String(), got: typeName(value)}
+	}
+	if s.Const != nil {
+		if str, ok := value.(string); !ok || str != *s.Const {
+			return &mismatch{field: field, expected: strconv.Quote(*s.Const), got: describe(value)}
+		}
+	}
+	if len(s.Enum) > 0 && !s.allows(value) {
+		return &mismatch{field: field, expected: "one of " + strings.Join(s.Enum, ", "), got: describe(value)}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return &mismatch{field: join(field, name), expected: s.Properties[name].expected(), got: "missing"}
+			}
+		}
+		for _, name := range s.names {
+			if item, ok := v[name]; ok {
+				if m := s.Properties[name].validate(item, join(field, name)); m != nil {
+					return m
+				}
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				if m := s.Items.validate(item, fmt.Sprintf("%s[%d]", field, i)); m != nil {
+					return m
+				}
+			}
+		}
+	}
+
+	for _, sub := range s.AllOf {
+		if m := sub.validate(value, field); m != nil {
+			return m
+		}
+	}
+	if s.If != nil && s.Then != nil && s.If.validate(value, field) == nil {
+		return s.Then.validate(value, field)
+	}
+	return nil
+}
+
+// allows reports whether value is one of the enum's strings.
+func (s *schema) allows(value any) bool {
+	str, ok := value.(string)
+	if !ok {
+		return false
+	}
+	for _, allowed := range s.Enum {
+		if str == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// expected describes the values s allows, for a missing field.
+func (s *schema) expected() string {
+	switch {
+	case s == nil:
+		return "a value"
+	case s.target != nil:
+		return s.target.expected()
+	case len(s.Types) > 0:
+		return s.Types.String()
+	case s.Const != nil:
+		return strconv.Quote(*s.Const)
+	default:
+		return "a value"
+	}
+}
+
+func (t typeList) matches(value any) bool {
+	got := typeName(value)
+	for _, want := range t {
+		if want == got || (want == "number" && got == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func (t typeList) String() string {
+	return strings.Join(t, " or ")
+}
+
+// typeName returns the JSON Schema type of a decoded JSON value, telling
+// integers apart from other numbers.
+func typeName(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case int, int64:
+		return "integer"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// describe names a value for a const or enum mismatch: strings are quoted,
+// other values reported by type.
+func describe(value any) string {
+	if str, ok := value.(string); ok {
+		return strconv.Quote(str)
+	}
+	return typeName(value)
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
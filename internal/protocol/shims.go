@@ -0,0 +1,144 @@
+package protocol
+
+import (
+	"sync"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+// shim adapts the messages of one type from a range of CLI versions to the
+// shape the parser reads.
+type shim struct {
+	// change describes the difference the shim undoes
+	change  string
+	msgType string
+	// since (inclusive) and before (exclusive) bound the CLI versions the
+	// shim applies to; nil leaves that side open
+	since, before []int
+	adapt         func(data map[string]any)
+}
+
+// shims lists the CLI message shapes that differ from the current one,
+// oldest first. Add an entry when a CLI release renames or moves a field,
+// instead of teaching the parser both shapes.
+var shims = []shim{
+	{
+		change:  "result cost reported as total_cost",
+		msgType: shared.MessageTypeResult,
+		before:  []int{1, 0, 0},
+		adapt:   renameField("total_cost", "total_cost_usd"),
+	},
+}
+
+// appliesTo reports whether the shim adapts messages from version.
+func (s shim) appliesTo(version []int) bool {
+	if s.since != nil && compareVersions(version, s.since) < 0 {
+		return false
+	}
+	return s.before == nil || compareVersions(version, s.before) < 0
+}
+
+// shimsFor returns the shims for a CLI version.
+func shimsFor(version []int) []shim {
+	var active []shim
+	for _, s := range shims {
+		if s.appliesTo(version) {
+			active = append(active, s)
+		}
+	}
+	return active
+}
+
+// renameField returns an adaptation moving a field to its current name.
+// A message that already has the current field is left unchanged.
+func renameField(from, to string) func(map[string]any) {
+	return func(data map[string]any) {
+		moveField(data, []string{from}, []string{to})
+	}
+}
+
+// moveField moves the value at the path from to the path to, creating the
+// objects to leads through. It does nothing when from is missing or to is
+// already set.
+func moveField(data map[string]any, from, to []string) {
+	parent := lookupObject(data, from[:len(from)-1], false)
+	if parent == nil {
+		return
+	}
+	value, ok := parent[from[len(from)-1]]
+	if !ok {
+		return
+	}
+	target := lookupObject(data, to[:len(to)-1], true)
+	if target == nil {
+		return
+	}
+	if _, taken := target[to[len(to)-1]]; taken {
+		return
+	}
+	delete(parent, from[len(from)-1])
+	target[to[len(to)-1]] = value
+}
+
+// lookupObject returns the object at path within data, creating missing
+// objects if create is set. It returns nil when a value on the path is not
+// an object.
+func lookupObject(data map[string]any, path []string, create bool) map[string]any {
+	for _, name := range path {
+		next, ok := data[name].(map[string]any)
+		if !ok {
+			if _, exists := data[name]; exists || !create {
+				return nil
+			}
+			next = map[string]any{}
+			data[name] = next
+		}
+		data = next
+	}
+	return data
+}
+
+// Adapter rewrites the messages of a stream into the shape the parser
+// reads, with the shims for the CLI version its init message reports. An
+// init message without claude_code_version comes from a CLI predating the
+// field, treated as older than every versioned shim. Messages before the
+// init message are left unchanged.
+type Adapter struct {
+	mu      sync.Mutex
+	version string
+	active  []shim
+}
+
+// NewAdapter returns an Adapter awaiting the init message.
+func NewAdapter() *Adapter {
+	return &Adapter{}
+}
+
+// Version returns the CLI version from the init message, or "" before it
+// arrives or when it reports none.
+func (a *Adapter) Version() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.version
+}
+
+// Adapt rewrites data in place.
+func (a *Adapter) Adapt(data map[string]any) {
+	a.mu.Lock()
+	if isInit(data) {
+		a.version, _ = data["claude_code_version"].(string)
+		a.active = shimsFor(parseVersion(a.version))
+	}
+	active := a.active
+	a.mu.Unlock()
+
+	if len(active) == 0 {
+		return
+	}
+	msgType, _ := data["type"].(string)
+	for _, s := range active {
+		if s.msgType == msgType {
+			s.adapt(data)
+		}
+	}
+}
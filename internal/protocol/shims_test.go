@@ -0,0 +1,140 @@
+package protocol
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+func TestShimVersionRange(t *testing.T) {
+	s := shim{since: []int{1, 5}, before: []int{2}}
+	tests := []struct {
+		version string
+		expect  bool
+	}{
+		{"", false},
+		{"1.4.9", false},
+		{"1.5", true},
+		{"1.9.3 (Claude Code)", true},
+		{"2.0.0", false},
+	}
+	for _, test := range tests {
+		if got := s.appliesTo(parseVersion(test.version)); got != test.expect {
+			t.Errorf("Expected %q in range %v, got %v", test.version, test.expect, got)
+		}
+	}
+	if !(shim{}).appliesTo(parseVersion("3.1.0")) {
+		t.Error("Expected an unbounded shim to apply to every version")
+	}
+}
+
+func TestMoveField(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   map[string]any
+		from   []string
+		to     []string
+		expect map[string]any
+	}{
+		{
+			name:   "rename",
+			data:   map[string]any{"total_cost": 0.5},
+			from:   []string{"total_cost"},
+			to:     []string{"total_cost_usd"},
+			expect: map[string]any{"total_cost_usd": 0.5},
+		},
+		{
+			name:   "into new object",
+			data:   map[string]any{"model": "claude-test"},
+			from:   []string{"model"},
+			to:     []string{"metadata", "model"},
+			expect: map[string]any{"metadata": map[string]any{"model": "claude-test"}},
+		},
+		{
+			name:   "out of object",
+			data:   map[string]any{"metadata": map[string]any{"model": "claude-test"}},
+			from:   []string{"metadata", "model"},
+			to:     []string{"model"},
+			expect: map[string]any{"metadata": map[string]any{}, "model": "claude-test"},
+		},
+		{
+			name:   "current field kept",
+			data:   map[string]any{"total_cost": 0.5, "total_cost_usd": 0.7},
+			from:   []string{"total_cost"},
+			to:     []string{"total_cost_usd"},
+			expect: map[string]any{"total_cost": 0.5, "total_cost_usd": 0.7},
+		},
+		{
+			name:   "path through non-object",
+			data:   map[string]any{"model": "claude-test", "metadata": "none"},
+			from:   []string{"model"},
+			to:     []string{"metadata", "model"},
+			expect: map[string]any{"model": "claude-test", "metadata": "none"},
+		},
+		{
+			name:   "missing field",
+			data:   map[string]any{},
+			from:   []string{"metadata", "model"},
+			to:     []string{"model"},
+			expect: map[string]any{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			moveField(test.data, test.from, test.to)
+			if !reflect.DeepEqual(test.data, test.expect) {
+				t.Errorf("Expected %v, got %v", test.expect, test.data)
+			}
+		})
+	}
+}
+
+func TestAdapterSelectsShimsByVersion(t *testing.T) {
+	saved := shims
+	defer func() { shims = saved }()
+	shims = []shim{
+		{
+			change:  "model moved into metadata",
+			msgType: shared.MessageTypeAssistant,
+			since:   []int{3},
+			adapt: func(data map[string]any) {
+				moveField(data, []string{"metadata", "model"}, []string{"message", "model"})
+			},
+		},
+	}
+	assistant := func() map[string]any {
+		return map[string]any{
+			"type":     shared.MessageTypeAssistant,
+			"message":  map[string]any{"content": []any{}},
+			"metadata": map[string]any{"model": "claude-test"},
+		}
+	}
+	initMessage := func(version string) map[string]any {
+		return map[string]any{"type": shared.MessageTypeSystem, "subtype": shared.SystemSubtypeInit, "claude_code_version": version}
+	}
+
+	adapter := NewAdapter()
+	before := assistant()
+	adapter.Adapt(before)
+	if _, moved := before["message"].(map[string]any)["model"]; moved {
+		t.Error("Expected messages before the init message unchanged")
+	}
+
+	adapter.Adapt(initMessage("3.0.1"))
+	if adapter.Version() != "3.0.1" {
+		t.Errorf("Expected version 3.0.1, got %q", adapter.Version())
+	}
+	newer := assistant()
+	adapter.Adapt(newer)
+	if model := newer["message"].(map[string]any)["model"]; model != "claude-test" {
+		t.Errorf("Expected the model moved back into message, got %v", newer)
+	}
+
+	adapter.Adapt(initMessage("2.0.14"))
+	older := assistant()
+	adapter.Adapt(older)
+	if _, moved := older["message"].(map[string]any)["model"]; moved {
+		t.Error("Expected no shim for CLI 2.0.14")
+	}
+}
@@ -0,0 +1,180 @@
+package protocol
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+// schemaFiles holds a schema per protocol revision, named after the first
+// CLI version sending it.
+//
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// versionedSchema is the schema of the CLIs from minVersion on.
+type versionedSchema struct {
+	minVersion []int
+	schema     *schema
+}
+
+var (
+	loadOnce sync.Once
+	// schemas is sorted by minVersion, oldest first
+	schemas []versionedSchema
+	loadErr error
+)
+
+// loadSchemas parses the embedded schemas once.
+func loadSchemas() ([]versionedSchema, error) {
+	loadOnce.Do(func() {
+		entries, err := schemaFiles.ReadDir("schemas")
+		if err != nil {
+			loadErr = err
+			return
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			data, err := schemaFiles.ReadFile(path.Join("schemas", name))
+			if err != nil {
+				loadErr = err
+				return
+			}
+			s, err := parseSchema(data)
+			if err != nil {
+				loadErr = fmt.Errorf("schema %s: %w", name, err)
+				return
+			}
+			schemas = append(schemas, versionedSchema{
+				minVersion: parseVersion(strings.TrimSuffix(name, ".json")),
+				schema:     s,
+			})
+		}
+		sort.Slice(schemas, func(i, j int) bool {
+			return compareVersions(schemas[i].minVersion, schemas[j].minVersion) < 0
+		})
+	})
+	return schemas, loadErr
+}
+
+// Validator checks each message of a stream against the schema of the
+// CLI's version, learned from its init message. Until the init message
+// arrives, messages are checked against the newest schema.
+type Validator struct {
+	mu      sync.Mutex
+	version string
+	schema  *schema
+}
+
+// NewValidator returns a Validator using the newest schema.
+func NewValidator() *Validator {
+	loaded, err := loadSchemas()
+	if err != nil {
+		// The schemas are embedded; tests keep them valid
+		panic(fmt.Sprintf("protocol: invalid embedded schema: %v", err))
+	}
+	return &Validator{schema: loaded[len(loaded)-1].schema}
+}
+
+// Version returns the CLI version from the init message, or "" before it
+// arrives.
+func (v *Validator) Version() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.version
+}
+
+// Validate returns a *shared.ProtocolError describing the first field of
+// data that does not match the schema, or nil.
+func (v *Validator) Validate(data map[string]any) error {
+	v.mu.Lock()
+	if version := initVersion(data); version != "" {
+		v.version = version
+		v.schema = schemaFor(version)
+	}
+	version, s := v.version, v.schema
+	v.mu.Unlock()
+
+	m := s.validate(data, "")
+	if m == nil {
+		return nil
+	}
+	msgType, _ := data["type"].(string)
+	return shared.NewProtocolError(version, msgType, m.field, m.expected, m.got)
+}
+
+// initVersion returns the CLI version an init message reports.
+func initVersion(data map[string]any) string {
+	if !isInit(data) {
+		return ""
+	}
+	version, _ := data["claude_code_version"].(string)
+	return version
+}
+
+// isInit reports whether data is the CLI's init message.
+func isInit(data map[string]any) bool {
+	return data["type"] == shared.MessageTypeSystem && data["subtype"] == shared.SystemSubtypeInit
+}
+
+// schemaFor returns the newest schema whose CLIs include version, or the
+// newest schema for a version it cannot parse.
+func schemaFor(version string) *schema {
+	loaded, _ := loadSchemas()
+	parsed := parseVersion(version)
+	if len(parsed) == 0 {
+		return loaded[len(loaded)-1].schema
+	}
+	for i := len(loaded) - 1; i > 0; i-- {
+		if compareVersions(loaded[i].minVersion, parsed) <= 0 {
+			return loaded[i].schema
+		}
+	}
+	return loaded[0].schema
+}
+
+// parseVersion returns the numeric components of a version such as
+// "2.0.14" or "2.1.0 (Claude Code)", stopping at the first that is not a
+// number.
+func parseVersion(version string) []int {
+	fields := strings.Fields(version)
+	if len(fields) == 0 {
+		return nil
+	}
+	var parts []int
+	for _, part := range strings.Split(strings.TrimPrefix(fields[0], "v"), ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+// compareVersions compares versions component by component, missing
+// components counting as zero.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,170 @@
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+func TestEmbeddedSchemasLoad(t *testing.T) {
+	loaded, err := loadSchemas()
+	if err != nil {
+		t.Fatalf("Embedded schemas do not load: %v", err)
+	}
+	if len(loaded) < 2 {
+		t.Fatalf("Expected a schema per protocol revision, got %d", len(loaded))
+	}
+	for i := 1; i < len(loaded); i++ {
+		if compareVersions(loaded[i-1].minVersion, loaded[i].minVersion) >= 0 {
+			t.Errorf("Expected schemas sorted by version, got %v before %v", loaded[i-1].minVersion, loaded[i].minVersion)
+		}
+	}
+}
+
+func TestValidateMismatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		field    string
+		expected string
+		got      string
+	}{
+		{
+			name:    "valid result",
+			message: `{"type":"result","subtype":"success","duration_ms":10,"duration_api_ms":8,"is_error":false,"num_turns":1,"session_id":"s1","usage":{"input_tokens":3},"new_field":true}`,
+		},
+		{
+			name:     "missing required field",
+			message:  `{"type":"result","subtype":"success","duration_ms":10,"is_error":false,"num_turns":1,"session_id":"s1"}`,
+			field:    "duration_api_ms",
+			expected: "integer",
+			got:      "missing",
+		},
+		{
+			name:     "nested type change",
+			message:  `{"type":"result","subtype":"success","duration_ms":10,"duration_api_ms":8,"is_error":false,"num_turns":1,"session_id":"s1","usage":{"input_tokens":"3"}}`,
+			field:    "usage.input_tokens",
+			expected: "integer",
+			got:      "string",
+		},
+		{
+			name:     "fractional count",
+			message:  `{"type":"result","subtype":"success","duration_ms":10.5,"duration_api_ms":8,"is_error":false,"num_turns":1,"session_id":"s1"}`,
+			field:    "duration_ms",
+			expected: "integer",
+			got:      "number",
+		},
+		{
+			name:     "content block field",
+			message:  `{"type":"assistant","message":{"model":"claude-test","content":[{"type":"text","text":"hi"},{"type":"tool_use","name":"Bash","id":7}]}}`,
+			field:    "message.content[1].id",
+			expected: "string",
+			got:      "integer",
+		},
+		{
+			name:     "wrong role",
+			message:  `{"type":"user","message":{"role":"assistant","content":"hi"}}`,
+			field:    "message.role",
+			expected: `"user"`,
+			got:      `"assistant"`,
+		},
+		{
+			name:     "nullable field",
+			message:  `{"type":"user","parent_tool_use_id":false,"message":{"content":"hi"}}`,
+			field:    "parent_tool_use_id",
+			expected: "string or null",
+			got:      "boolean",
+		},
+		{
+			name:    "unknown message type",
+			message: `{"type":"control_request","request":{}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := NewValidator().Validate(decode(t, test.message))
+			if test.field == "" {
+				if err != nil {
+					t.Fatalf("Expected the message to match, got %v", err)
+				}
+				return
+			}
+			var protocolErr *shared.ProtocolError
+			if !errors.As(err, &protocolErr) {
+				t.Fatalf("Expected a ProtocolError, got %v", err)
+			}
+			if protocolErr.Field != test.field || protocolErr.Expected != test.expected || protocolErr.Got != test.got {
+				t.Errorf("Expected %s: expected %s, got %s; got %+v", test.field, test.expected, test.got, protocolErr)
+			}
+		})
+	}
+}
+
+func TestValidatorNegotiatesVersion(t *testing.T) {
+	denials := `{"type":"result","subtype":"success","duration_ms":10,"duration_api_ms":8,"is_error":false,"num_turns":1,"session_id":"s1","permission_denials":[{"tool_name":"Bash"}]}`
+
+	// The 1.x protocol has no permission denials to check
+	v := NewValidator()
+	if err := v.Validate(decode(t, `{"type":"system","subtype":"init","session_id":"s1","claude_code_version":"1.0.98"}`)); err != nil {
+		t.Fatalf("Expected the init message to match, got %v", err)
+	}
+	if v.Version() != "1.0.98" {
+		t.Errorf("Expected version 1.0.98, got %q", v.Version())
+	}
+	if err := v.Validate(decode(t, denials)); err != nil {
+		t.Errorf("Expected the 1.x schema to accept the result, got %v", err)
+	}
+
+	v = NewValidator()
+	if err := v.Validate(decode(t, `{"type":"system","subtype":"init","session_id":"s1","claude_code_version":"2.0.14"}`)); err != nil {
+		t.Fatalf("Expected the init message to match, got %v", err)
+	}
+	err := v.Validate(decode(t, denials))
+	var protocolErr *shared.ProtocolError
+	if !errors.As(err, &protocolErr) || protocolErr.Field != "permission_denials[0].tool_use_id" || protocolErr.Version != "2.0.14" {
+		t.Errorf("Expected the 2.x schema to reject the denial, got %v", err)
+	}
+	expect := `result message from CLI 2.0.14 does not match its protocol: permission_denials[0].tool_use_id: expected string, got missing`
+	if err != nil && err.Error() != expect {
+		t.Errorf("Expected %q, got %q", expect, err.Error())
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		expect  []int
+	}{
+		{"2.0.14", []int{2, 0, 14}},
+		{"2.1.0 (Claude Code)", []int{2, 1, 0}},
+		{"v1.0.98-beta", []int{1, 0}},
+		{"dev", nil},
+	}
+	for _, test := range tests {
+		got := parseVersion(test.version)
+		if compareVersions(got, test.expect) != 0 || len(got) != len(test.expect) {
+			t.Errorf("parseVersion(%q) = %v, expected %v", test.version, got, test.expect)
+		}
+	}
+	if schemaFor("dev") != schemaFor("99.0.0") {
+		t.Error("Expected an unparsable version to use the newest schema")
+	}
+}
+
+func TestParseSchemaUnresolvedReference(t *testing.T) {
+	if _, err := parseSchema([]byte(`{"items":{"$ref":"#/$defs/missing"}}`)); err == nil {
+		t.Error("Expected an unresolved reference to fail")
+	}
+}
+
+func decode(t *testing.T, message string) map[string]any {
+	t.Helper()
+	var data map[string]any
+	if err := json.Unmarshal([]byte(message), &data); err != nil {
+		t.Fatalf("Invalid test message: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,74 @@
+package shared
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Clock tells the client the time and times out its waits. Replacing it
+// lets tests control timestamps and fire timeouts without sleeping. Its
+// methods are called from several goroutines.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer made by a Clock, like time.Timer.
+type Timer interface {
+	// C returns the channel that receives the time when the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, reporting whether it stopped it.
+	Stop() bool
+}
+
+// SystemClock is the default Clock, backed by the time package.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer returns a time.Timer firing after d.
+func (SystemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// IDGenerator makes the IDs of the requests the client sends. Its method is
+// called from several goroutines.
+type IDGenerator interface {
+	// NewID returns an ID starting with prefix that it has not returned
+	// before.
+	NewID(prefix string) string
+}
+
+// SequentialIDs is the default IDGenerator. It numbers the IDs of each
+// prefix from 1, as in "sdk-ctrl-1". The zero value is ready to use.
+type SequentialIDs struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// NewID returns prefix followed by a dash and the next number for prefix.
+func (s *SequentialIDs) NewID(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = make(map[string]int64)
+	}
+	s.counts[prefix]++
+	return prefix + "-" + strconv.FormatInt(s.counts[prefix], 10)
+}
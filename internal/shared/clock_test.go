@@ -0,0 +1,54 @@
+package shared
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSequentialIDs(t *testing.T) {
+	var ids SequentialIDs
+	got := []string{ids.NewID("a"), ids.NewID("a"), ids.NewID("b"), ids.NewID("a")}
+	want := []string{"a-1", "a-2", "b-1", "a-3"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ID %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestSequentialIDsConcurrent(t *testing.T) {
+	var ids SequentialIDs
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := ids.NewID("req")
+			mu.Lock()
+			defer mu.Unlock()
+			if seen[id] {
+				t.Errorf("Duplicate ID %q", id)
+			}
+			seen[id] = true
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 50 {
+		t.Errorf("Expected 50 distinct IDs, got %d", len(seen))
+	}
+}
+
+func TestSystemClockTimer(t *testing.T) {
+	timer := SystemClock{}.NewTimer(time.Millisecond)
+	select {
+	case <-timer.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timer did not fire")
+	}
+	if timer.Stop() {
+		t.Error("Expected Stop to report a fired timer as not stopped")
+	}
+}
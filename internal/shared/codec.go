@@ -0,0 +1,27 @@
+package shared
+
+import "encoding/json"
+
+// JSONCodec encodes and decodes the JSON exchanged with the CLI. Its methods
+// follow encoding/json.Marshal and Unmarshal, so drop-in replacements such
+// as sonic.ConfigStd or jsoniter.ConfigCompatibleWithStandardLibrary satisfy
+// it directly. Unmarshal must decode objects into map[string]any with
+// numbers as float64, and must not retain data after it returns. Both
+// methods are called from several goroutines.
+type JSONCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// StdJSONCodec is the default JSONCodec, backed by encoding/json.
+type StdJSONCodec struct{}
+
+// Marshal encodes v with json.Marshal.
+func (StdJSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes data into v with json.Unmarshal.
+func (StdJSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
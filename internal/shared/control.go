@@ -0,0 +1,111 @@
+package shared
+
+// Control message type constants. Control messages travel both ways on the
+// CLI's stream: the SDK sends requests such as set_model, and the CLI sends
+// requests such as can_use_tool, each answered by a control response.
+const (
+	MessageTypeControlRequest  = "control_request"
+	MessageTypeControlResponse = "control_response"
+)
+
+// ControlRequestType represents the type of control request
+type ControlRequestType string
+
+// ControlRequest represents a control protocol request
+type ControlRequest struct {
+	ID      string             `json:"id"`
+	Subtype ControlRequestType `json:"subtype"`
+	Data    map[string]any     `json:"data,omitempty"`
+}
+
+// ControlResponseType represents the type of control response
+type ControlResponseType string
+
+// ControlResponse represents a control protocol response
+type ControlResponse struct {
+	ID      string                `json:"id"`
+	Subtype ControlResponseType   `json:"subtype"`
+	Data    map[string]any        `json:"data,omitempty"`
+	Error   *ControlResponseError `json:"error,omitempty"`
+}
+
+// ControlResponseError represents a control protocol error
+type ControlResponseError struct {
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+}
+
+// ControlRequestMessage carries a control request from the CLI. The client
+// answers it and does not deliver it to consumers.
+type ControlRequestMessage struct {
+	Request *ControlRequest
+}
+
+// Type returns the message type for ControlRequestMessage.
+func (m *ControlRequestMessage) Type() string {
+	return MessageTypeControlRequest
+}
+
+// Release does nothing; control messages are not pooled.
+func (m *ControlRequestMessage) Release() {}
+
+// ControlResponseMessage carries the CLI's response to a control request
+// sent by the SDK. The client hands it to the waiting request and does not
+// deliver it to consumers.
+type ControlResponseMessage struct {
+	Response *ControlResponse
+}
+
+// Type returns the message type for ControlResponseMessage.
+func (m *ControlResponseMessage) Type() string {
+	return MessageTypeControlResponse
+}
+
+// Release does nothing; control messages are not pooled.
+func (m *ControlResponseMessage) Release() {}
+
+// IsControlMessage reports whether msg is a control request or response.
+func IsControlMessage(msg Message) bool {
+	switch msg.(type) {
+	case *ControlRequestMessage, *ControlResponseMessage:
+		return true
+	}
+	return false
+}
+
+// ControlRequestFrame returns the stream-json line of req, in the shape the
+// CLI reads.
+func ControlRequestFrame(req *ControlRequest) map[string]any {
+	request := make(map[string]any, len(req.Data)+1)
+	for key, value := range req.Data {
+		request[key] = value
+	}
+	request["subtype"] = string(req.Subtype)
+	return map[string]any{
+		"type":       MessageTypeControlRequest,
+		"request_id": req.ID,
+		"request":    request,
+	}
+}
+
+// ControlResponseFrame returns the stream-json line of resp, in the shape
+// the CLI reads.
+func ControlResponseFrame(resp *ControlResponse) map[string]any {
+	response := map[string]any{
+		"subtype":    string(resp.Subtype),
+		"request_id": resp.ID,
+	}
+	if resp.Error != nil {
+		response["error"] = resp.Error.Message
+	} else {
+		data := resp.Data
+		if data == nil {
+			data = map[string]any{}
+		}
+		response["response"] = data
+	}
+	return map[string]any{
+		"type":     MessageTypeControlResponse,
+		"response": response,
+	}
+}
@@ -1,7 +1,11 @@
 // Package shared provides shared types and interfaces used across internal packages.
 package shared
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // SDKError is the base interface for all Claude Code SDK errors.
 type SDKError interface {
@@ -157,3 +161,177 @@ func NewMessageParseError(message string, data any) *MessageParseError {
 		Data:      data,
 	}
 }
+
+// MessageTooLargeError indicates a CLI output line exceeded the configured
+// maximum message size. The line is discarded and reading continues.
+type MessageTooLargeError struct {
+	BaseError
+	Size  int
+	Limit int
+}
+
+// Type returns the error type for MessageTooLargeError.
+func (e *MessageTooLargeError) Type() string {
+	return "message_too_large_error"
+}
+
+// NewMessageTooLargeError creates a new MessageTooLargeError.
+func NewMessageTooLargeError(size, limit int) *MessageTooLargeError {
+	return &MessageTooLargeError{
+		BaseError: BaseError{message: fmt.Sprintf("message size %d exceeds limit %d", size, limit)},
+		Size:      size,
+		Limit:     limit,
+	}
+}
+
+// StreamIntegrityError indicates messages from the CLI were lost, duplicated
+// or delivered out of order. It is reported only when sequence tracking or
+// strict ordering is enabled. The stream continues after it, except in
+// strict ordering mode.
+type StreamIntegrityError struct {
+	BaseError
+	Issue StreamIssue
+}
+
+// Type returns the error type for StreamIntegrityError.
+func (e *StreamIntegrityError) Type() string {
+	return "stream_integrity_error"
+}
+
+// NewStreamIntegrityError creates a new StreamIntegrityError.
+func NewStreamIntegrityError(issue StreamIssue) *StreamIntegrityError {
+	message := fmt.Sprintf("stream integrity violation (%s): %s", issue.Type, issue.Description)
+	if issue.ToolUseID != "" {
+		message = fmt.Sprintf("%s [tool_use_id=%s]", message, issue.ToolUseID)
+	}
+	return &StreamIntegrityError{
+		BaseError: BaseError{message: message},
+		Issue:     issue,
+	}
+}
+
+// ProtocolError reports a CLI message that does not match the schema of
+// the CLI's protocol version, with WithStrictProtocol. The message is not
+// delivered and the stream continues.
+type ProtocolError struct {
+	BaseError
+	// Version is the CLI version from the init message, or empty before
+	// it arrives.
+	Version string
+	// MessageType is the type of the message, such as "result".
+	MessageType string
+	// Field is the path of the first mismatched field, such as
+	// "usage.input_tokens" or "message.content[1].id".
+	Field string
+	// Expected describes what the schema allows, and Got what the CLI
+	// sent: a JSON type, a quoted string, or "missing".
+	Expected string
+	Got      string
+}
+
+// Type returns the error type for ProtocolError.
+func (e *ProtocolError) Type() string {
+	return "protocol_error"
+}
+
+// NewProtocolError creates a new ProtocolError.
+func NewProtocolError(version, messageType, field, expected, got string) *ProtocolError {
+	cli := "CLI"
+	if version != "" {
+		cli = "CLI " + version
+	}
+	if messageType == "" {
+		messageType = "unknown"
+	}
+	return &ProtocolError{
+		BaseError: BaseError{message: fmt.Sprintf("%s message from %s does not match its protocol: %s: expected %s, got %s",
+			messageType, cli, field, expected, got)},
+		Version:     version,
+		MessageType: messageType,
+		Field:       field,
+		Expected:    expected,
+		Got:         got,
+	}
+}
+
+// AuthRequiredError indicates the CLI has no credentials: no API key or
+// token in the environment and no stored login.
+type AuthRequiredError struct {
+	BaseError
+	// ConfigDir is the CLI config directory searched for a stored login.
+	ConfigDir string
+}
+
+// Type returns the error type for AuthRequiredError.
+func (e *AuthRequiredError) Type() string {
+	return "auth_required_error"
+}
+
+// NewAuthRequiredError creates a new AuthRequiredError.
+func NewAuthRequiredError(configDir string) *AuthRequiredError {
+	return &AuthRequiredError{
+		BaseError: BaseError{message: fmt.Sprintf("claude CLI is not authenticated (no login in %s): "+
+			"run `claude` and /login, or set ANTHROPIC_API_KEY, or CLAUDE_CODE_OAUTH_TOKEN from `claude setup-token`",
+			configDir)},
+		ConfigDir: configDir,
+	}
+}
+
+// AuthExpiredError indicates the CLI's stored login has expired and cannot
+// be refreshed.
+type AuthExpiredError struct {
+	BaseError
+	// ExpiresAt is when the access token expired.
+	ExpiresAt time.Time
+	// Source is the file holding the expired credentials.
+	Source string
+}
+
+// Type returns the error type for AuthExpiredError.
+func (e *AuthExpiredError) Type() string {
+	return "auth_expired_error"
+}
+
+// NewAuthExpiredError creates a new AuthExpiredError.
+func NewAuthExpiredError(expiresAt time.Time, source string) *AuthExpiredError {
+	return &AuthExpiredError{
+		BaseError: BaseError{message: fmt.Sprintf("claude CLI login in %s expired at %s: run `claude` and /login again",
+			source, expiresAt.UTC().Format(time.RFC3339))},
+		ExpiresAt: expiresAt,
+		Source:    source,
+	}
+}
+
+// PartialResult is returned when a query fails after messages arrived, for
+// example because the CLI crashed or hit a rate limit. It keeps the messages
+// received before the failure so partial generations are not lost, and
+// unwraps to the error that ended the query.
+type PartialResult struct {
+	BaseError
+	Messages []Message
+}
+
+// Type returns the error type for PartialResult.
+func (e *PartialResult) Type() string {
+	return "partial_result"
+}
+
+// Text returns the text the agent produced before the failure, from the
+// top-level assistant messages, joined by newlines.
+func (e *PartialResult) Text() string {
+	var parts []string
+	for _, msg := range e.Messages {
+		if assistant, ok := msg.(*AssistantMessage); ok && assistant.ParentToolUseID == nil {
+			parts = append(parts, assistant.texts()...)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// NewPartialResult creates a new PartialResult.
+func NewPartialResult(messages []Message, cause error) *PartialResult {
+	return &PartialResult{
+		BaseError: BaseError{message: fmt.Sprintf("query failed after %d messages", len(messages)), cause: cause},
+		Messages:  messages,
+	}
+}
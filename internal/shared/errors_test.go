@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestErrorTypes tests all error types using table-driven approach
@@ -56,6 +57,55 @@ func TestErrorTypes(t *testing.T) {
 			expectedType: "message_parse_error",
 			validateFunc: validateMessageParseError,
 		},
+		{
+			name: "message_too_large_error",
+			createError: func() SDKError {
+				return NewMessageTooLargeError(2048, 1024)
+			},
+			expectedType: "message_too_large_error",
+			validateFunc: validateMessageTooLargeError,
+		},
+		{
+			name: "stream_integrity_error",
+			createError: func() SDKError {
+				return NewStreamIntegrityError(StreamIssue{
+					Type:        StreamIssueUnknownParent,
+					Description: "Message references a parent tool use that was never received",
+					ToolUseID:   "toolu_9",
+				})
+			},
+			expectedType: "stream_integrity_error",
+			validateFunc: validateStreamIntegrityError,
+		},
+		{
+			name: "auth_required_error",
+			createError: func() SDKError {
+				return NewAuthRequiredError("/home/dev/.claude")
+			},
+			expectedType: "auth_required_error",
+			validateFunc: validateAuthRequiredError,
+		},
+		{
+			name: "auth_expired_error",
+			createError: func() SDKError {
+				return NewAuthExpiredError(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), "/home/dev/.claude/.credentials.json")
+			},
+			expectedType: "auth_expired_error",
+			validateFunc: validateAuthExpiredError,
+		},
+		{
+			name: "partial_result",
+			createError: func() SDKError {
+				parent := "toolu_1"
+				return NewPartialResult([]Message{
+					&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "first"}, &ToolUseBlock{Name: "Task"}}},
+					&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "subagent"}}, ParentToolUseID: &parent},
+					&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "second"}}},
+				}, errPartialCause)
+			},
+			expectedType: "partial_result",
+			validateFunc: validatePartialResult,
+		},
 	}
 
 	for _, test := range tests {
@@ -339,7 +389,84 @@ func validateMessageParseError(t *testing.T, err SDKError) {
 	}
 }
 
+func validateMessageTooLargeError(t *testing.T, err SDKError) {
+	t.Helper()
+	tooLarge, ok := err.(*MessageTooLargeError)
+	if !ok {
+		t.Fatalf("Expected *MessageTooLargeError, got %T", err)
+	}
+	if tooLarge.Size != 2048 || tooLarge.Limit != 1024 {
+		t.Errorf("Expected size 2048 and limit 1024, got %d and %d", tooLarge.Size, tooLarge.Limit)
+	}
+	if !strings.Contains(err.Error(), "2048") {
+		t.Errorf("Expected error message to include the size, got %q", err.Error())
+	}
+}
+
+func validateStreamIntegrityError(t *testing.T, err SDKError) {
+	t.Helper()
+	integrityErr, ok := err.(*StreamIntegrityError)
+	if !ok {
+		t.Fatalf("Expected *StreamIntegrityError, got %T", err)
+	}
+	if integrityErr.Issue.Type != StreamIssueUnknownParent {
+		t.Errorf("Expected issue type %q, got %q", StreamIssueUnknownParent, integrityErr.Issue.Type)
+	}
+	if !strings.Contains(err.Error(), "toolu_9") {
+		t.Errorf("Expected error message to include the tool use ID, got %q", err.Error())
+	}
+}
+
+var errPartialCause = errors.New("rate limited")
+
+func validatePartialResult(t *testing.T, err SDKError) {
+	t.Helper()
+	partial, ok := err.(*PartialResult)
+	if !ok {
+		t.Fatalf("Expected *PartialResult, got %T", err)
+	}
+	if !errors.Is(err, errPartialCause) {
+		t.Errorf("Expected the error to unwrap to its cause, got %v", err)
+	}
+	if len(partial.Messages) != 3 || partial.Text() != "first\nsecond" {
+		t.Errorf("Expected the top-level text of the messages, got %q", partial.Text())
+	}
+	if !strings.Contains(err.Error(), "3 messages") || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("Expected error message to include the count and cause, got %q", err.Error())
+	}
+}
+
 // floatPtr creates a float64 pointer for testing
 func floatPtr(f float64) *float64 {
 	return &f
 }
+
+func validateAuthRequiredError(t *testing.T, err SDKError) {
+	t.Helper()
+	authErr, ok := err.(*AuthRequiredError)
+	if !ok {
+		t.Fatalf("Expected *AuthRequiredError, got %T", err)
+	}
+	if authErr.ConfigDir != "/home/dev/.claude" {
+		t.Errorf("Expected config dir /home/dev/.claude, got %q", authErr.ConfigDir)
+	}
+	for _, want := range []string{"/login", "ANTHROPIC_API_KEY", "claude setup-token"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error message to mention %q, got %q", want, err.Error())
+		}
+	}
+}
+
+func validateAuthExpiredError(t *testing.T, err SDKError) {
+	t.Helper()
+	authErr, ok := err.(*AuthExpiredError)
+	if !ok {
+		t.Fatalf("Expected *AuthExpiredError, got %T", err)
+	}
+	if !authErr.ExpiresAt.Equal(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected expiry %v", authErr.ExpiresAt)
+	}
+	if !strings.Contains(err.Error(), "2026-03-01T12:00:00Z") || !strings.Contains(err.Error(), "/login") {
+		t.Errorf("Expected error message to include the expiry and how to log in, got %q", err.Error())
+	}
+}
@@ -1,7 +1,11 @@
 package shared
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"os"
+	"strings"
 )
 
 // Message type constants
@@ -18,6 +22,15 @@ const (
 	ContentBlockTypeThinking   = "thinking"
 	ContentBlockTypeToolUse    = "tool_use"
 	ContentBlockTypeToolResult = "tool_result"
+	ContentBlockTypeImage      = "image"
+)
+
+// Image source type constants
+const (
+	// ImageSourceTypeBase64 marks image data carried inline as base64.
+	ImageSourceTypeBase64 = "base64"
+	// ImageSourceTypeFile marks image data spilled to a file by the SDK.
+	ImageSourceTypeFile = "file"
 )
 
 // AssistantMessageError represents error types in assistant messages.
@@ -36,6 +49,10 @@ const (
 // Message represents any message type in the Claude Code protocol.
 type Message interface {
 	Type() string
+
+	// Release returns the message to a pool when message recycling is
+	// enabled, and does nothing otherwise. See WithMessageRecycling.
+	Release()
 }
 
 // ContentBlock represents any content block within a message.
@@ -49,6 +66,8 @@ type UserMessage struct {
 	Content         interface{} `json:"content"` // string or []ContentBlock
 	UUID            *string     `json:"uuid,omitempty"`
 	ParentToolUseID *string     `json:"parent_tool_use_id,omitempty"`
+
+	recycled bool
 }
 
 // Type returns the message type for UserMessage.
@@ -87,10 +106,13 @@ func (m *UserMessage) MarshalJSON() ([]byte, error) {
 
 // AssistantMessage represents a message from the assistant.
 type AssistantMessage struct {
-	MessageType string                 `json:"type"`
-	Content     []ContentBlock         `json:"content"`
-	Model       string                 `json:"model"`
-	Error       *AssistantMessageError `json:"error,omitempty"`
+	MessageType     string                 `json:"type"`
+	Content         []ContentBlock         `json:"content"`
+	Model           string                 `json:"model"`
+	Error           *AssistantMessageError `json:"error,omitempty"`
+	ParentToolUseID *string                `json:"parent_tool_use_id,omitempty"`
+
+	recycled bool
 }
 
 // Type returns the message type for AssistantMessage.
@@ -111,11 +133,31 @@ func (m *AssistantMessage) GetError() AssistantMessageError {
 	return ""
 }
 
+// GetParentToolUseID returns the parent tool use ID or empty string if nil.
+// It is set on messages produced by a subagent running inside a tool use.
+func (m *AssistantMessage) GetParentToolUseID() string {
+	if m.ParentToolUseID != nil {
+		return *m.ParentToolUseID
+	}
+	return ""
+}
+
 // IsRateLimited returns true if the error is a rate limit error.
 func (m *AssistantMessage) IsRateLimited() bool {
 	return m.Error != nil && *m.Error == AssistantMessageErrorRateLimit
 }
 
+// texts returns the text of the message's text blocks.
+func (m *AssistantMessage) texts() []string {
+	var texts []string
+	for _, block := range m.Content {
+		if text, ok := block.(*TextBlock); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return texts
+}
+
 // MarshalJSON implements custom JSON marshaling for AssistantMessage
 func (m *AssistantMessage) MarshalJSON() ([]byte, error) {
 	type assistantMessage AssistantMessage
@@ -136,6 +178,125 @@ type SystemMessage struct {
 	Data        map[string]any `json:"-"` // Preserve all original data
 }
 
+// SystemSubtypeInit is the subtype of the system message the CLI sends when
+// a session starts.
+const SystemSubtypeInit = "init"
+
+// InitInfo describes the session the CLI started, as reported in its init
+// system message.
+type InitInfo struct {
+	SessionID         string
+	Model             string
+	Cwd               string
+	Tools             []string
+	McpServers        []McpServerStatus
+	PermissionMode    PermissionMode
+	SlashCommands     []string
+	APIKeySource      string
+	ClaudeCodeVersion string
+	OutputStyle       string
+	Agents            []string
+	MemoryFiles       []MemoryFile
+}
+
+// McpServerStatus is the connection state of an MCP server at startup,
+// such as "connected" or "failed".
+type McpServerStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// MemorySource identifies where a memory file comes from.
+type MemorySource string
+
+// Memory file sources. The CLI reports the first three in its init message;
+// MemorySourceInjected marks a file added with the MemoryFiles option.
+const (
+	MemorySourceUser     MemorySource = "user"
+	MemorySourceProject  MemorySource = "project"
+	MemorySourceLocal    MemorySource = "local"
+	MemorySourceInjected MemorySource = "injected"
+)
+
+// MemoryFile is a CLAUDE.md file whose instructions the agent sees.
+type MemoryFile struct {
+	Path   string       `json:"path"`
+	Source MemorySource `json:"source"`
+}
+
+// MCP server states. The CLI reports the first four; McpStatusMissing marks
+// a configured server it did not report at all.
+const (
+	McpStatusConnected = "connected"
+	McpStatusFailed    = "failed"
+	McpStatusNeedsAuth = "needs-auth"
+	McpStatusPending   = "pending"
+	McpStatusMissing   = "missing"
+)
+
+// Init returns the typed contents of an init system message. It reports
+// false for other system messages.
+func (m *SystemMessage) Init() (InitInfo, bool) {
+	if m.Subtype != SystemSubtypeInit {
+		return InitInfo{}, false
+	}
+
+	info := InitInfo{
+		Tools:         stringList(m.Data["tools"]),
+		SlashCommands: stringList(m.Data["slash_commands"]),
+		Agents:        stringList(m.Data["agents"]),
+	}
+	info.SessionID, _ = m.Data["session_id"].(string)
+	info.Model, _ = m.Data["model"].(string)
+	info.Cwd, _ = m.Data["cwd"].(string)
+	info.APIKeySource, _ = m.Data["apiKeySource"].(string)
+	info.ClaudeCodeVersion, _ = m.Data["claude_code_version"].(string)
+	info.OutputStyle, _ = m.Data["output_style"].(string)
+	if mode, ok := m.Data["permissionMode"].(string); ok {
+		info.PermissionMode = PermissionMode(mode)
+	}
+	servers, _ := m.Data["mcp_servers"].([]any)
+	for _, item := range servers {
+		server, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		status := McpServerStatus{}
+		status.Name, _ = server["name"].(string)
+		status.Status, _ = server["status"].(string)
+		info.McpServers = append(info.McpServers, status)
+	}
+	files, _ := m.Data["memory_files"].([]any)
+	for _, item := range files {
+		file, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		memory := MemoryFile{}
+		memory.Path, _ = file["path"].(string)
+		if source, ok := file["source"].(string); ok {
+			memory.Source = MemorySource(source)
+		}
+		if memory.Path != "" {
+			info.MemoryFiles = append(info.MemoryFiles, memory)
+		}
+	}
+	return info, true
+}
+
+// stringList returns the strings of a decoded JSON array, skipping other
+// values.
+func stringList(value any) []string {
+	items, _ := value.([]any)
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
 // Type returns the message type for SystemMessage.
 func (m *SystemMessage) Type() string {
 	return MessageTypeSystem
@@ -154,17 +315,79 @@ func (m *SystemMessage) MarshalJSON() ([]byte, error) {
 
 // ResultMessage represents the final result of a conversation turn.
 type ResultMessage struct {
-	MessageType      string          `json:"type"`
-	Subtype          string          `json:"subtype"`
-	DurationMs       int             `json:"duration_ms"`
-	DurationAPIMs    int             `json:"duration_api_ms"`
-	IsError          bool            `json:"is_error"`
-	NumTurns         int             `json:"num_turns"`
-	SessionID        string          `json:"session_id"`
-	TotalCostUSD     *float64        `json:"total_cost_usd,omitempty"`
-	Usage            *map[string]any `json:"usage,omitempty"`
-	Result           *string         `json:"result,omitempty"`
-	StructuredOutput any             `json:"structured_output,omitempty"`
+	MessageType       string             `json:"type"`
+	Subtype           string             `json:"subtype"`
+	DurationMs        int                `json:"duration_ms"`
+	DurationAPIMs     int                `json:"duration_api_ms"`
+	IsError           bool               `json:"is_error"`
+	NumTurns          int                `json:"num_turns"`
+	SessionID         string             `json:"session_id"`
+	TotalCostUSD      *float64           `json:"total_cost_usd,omitempty"`
+	Usage             *Usage             `json:"usage,omitempty"`
+	Result            *string            `json:"result,omitempty"`
+	StructuredOutput  any                `json:"structured_output,omitempty"`
+	PermissionDenials []PermissionDenial `json:"permission_denials,omitempty"`
+
+	// Resumed is set by the SDK when a query continued or resumed an
+	// earlier session; it is not part of the CLI's result message.
+	Resumed *ResumedSession `json:"resumed,omitempty"`
+}
+
+// Usage reports the tokens a conversation turn consumed.
+type Usage struct {
+	InputTokens   int `json:"input_tokens"`
+	OutputTokens  int `json:"output_tokens"`
+	CacheCreation int `json:"cache_creation_input_tokens"`
+	CacheRead     int `json:"cache_read_input_tokens"`
+}
+
+// TotalInputTokens returns the input tokens of the turn, including those
+// written to and read from the prompt cache.
+func (u *Usage) TotalInputTokens() int {
+	return u.InputTokens + u.CacheCreation + u.CacheRead
+}
+
+// CacheHitRatio returns the fraction of input tokens read from the prompt
+// cache, or 0 when there were none.
+func (u *Usage) CacheHitRatio() float64 {
+	return cacheHitRatio(u.CacheRead, u.TotalInputTokens())
+}
+
+func cacheHitRatio(read, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(read) / float64(total)
+}
+
+// CacheControlEphemeral is the cache control type for prompt caching.
+const CacheControlEphemeral = "ephemeral"
+
+// CacheControl marks content as a prompt caching breakpoint: the API caches
+// the prompt up to it so later requests with the same prefix read it from
+// the cache at a lower price.
+type CacheControl struct {
+	Type string `json:"type"`
+	// TTL is how long the cache entry lives, "5m" or "1h"; empty uses the
+	// API's default of five minutes.
+	TTL string `json:"ttl,omitempty"`
+}
+
+// PermissionDenial records a tool call the CLI refused during the turn.
+type PermissionDenial struct {
+	ToolName  string         `json:"tool_name"`
+	ToolUseID string         `json:"tool_use_id"`
+	ToolInput map[string]any `json:"tool_input,omitempty"`
+}
+
+// ResumedSession describes the earlier session a query picked up.
+type ResumedSession struct {
+	// SessionID identifies the session the CLI resumed, as reported in
+	// its init message.
+	SessionID string `json:"session_id"`
+	// NumTurns is the number of turns the session had before the query,
+	// counted from its transcript; 0 if the transcript could not be read.
+	NumTurns int `json:"num_turns"`
 }
 
 // Type returns the message type for ResultMessage.
@@ -189,6 +412,11 @@ func (m *ResultMessage) MarshalJSON() ([]byte, error) {
 type TextBlock struct {
 	MessageType string `json:"type"`
 	Text        string `json:"text"`
+	// CacheControl marks the prompt up to and including this block as
+	// cacheable. It is only honored on blocks sent to the CLI.
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+
+	recycled bool
 }
 
 // BlockType returns the content block type for TextBlock.
@@ -201,6 +429,8 @@ type ThinkingBlock struct {
 	MessageType string `json:"type"`
 	Thinking    string `json:"thinking"`
 	Signature   string `json:"signature"`
+
+	recycled bool
 }
 
 // BlockType returns the content block type for ThinkingBlock.
@@ -214,6 +444,8 @@ type ToolUseBlock struct {
 	ToolUseID   string         `json:"tool_use_id"`
 	Name        string         `json:"name"`
 	Input       map[string]any `json:"input"`
+
+	recycled bool
 }
 
 // BlockType returns the content block type for ToolUseBlock.
@@ -227,9 +459,156 @@ type ToolResultBlock struct {
 	ToolUseID   string      `json:"tool_use_id"`
 	Content     interface{} `json:"content"` // string or structured data
 	IsError     *bool       `json:"is_error,omitempty"`
+
+	recycled bool
 }
 
 // BlockType returns the content block type for ToolResultBlock.
 func (b *ToolResultBlock) BlockType() string {
 	return ContentBlockTypeToolResult
 }
+
+// Images returns the image blocks of a structured tool result, such as
+// screenshots. Image data is not decoded until ImageContent.Data is called.
+func (b *ToolResultBlock) Images() []ImageContent {
+	blocks, ok := b.Content.([]any)
+	if !ok {
+		return nil
+	}
+
+	var images []ImageContent
+	for _, item := range blocks {
+		block, ok := item.(map[string]any)
+		if !ok || block["type"] != ContentBlockTypeImage {
+			continue
+		}
+		source, ok := block["source"].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		image := ImageContent{}
+		image.MediaType, _ = source["media_type"].(string)
+		switch source["type"] {
+		case ImageSourceTypeBase64:
+			image.data, _ = source["data"].(string)
+		case ImageSourceTypeFile:
+			image.File, _ = source["path"].(string)
+		default:
+			continue
+		}
+		images = append(images, image)
+	}
+	return images
+}
+
+// ImageContent is an image carried in a tool result. Small images keep
+// their base64 data inline; images larger than the MaxInlineResultBytes
+// option are written to a temporary file named by File, which is removed
+// when the transport closes and may be deleted earlier with Remove.
+type ImageContent struct {
+	MediaType string
+	File      string // Set when the image was spilled to disk
+	data      string // base64 data, empty when spilled
+}
+
+// IsFile reports whether the image data lives in File rather than in memory.
+func (i ImageContent) IsFile() bool {
+	return i.File != ""
+}
+
+// Data returns the decoded image bytes, reading File for spilled images.
+func (i ImageContent) Data() ([]byte, error) {
+	if i.File != "" {
+		return os.ReadFile(i.File)
+	}
+	if i.data == "" {
+		return nil, errors.New("image has no data")
+	}
+	return base64.StdEncoding.DecodeString(i.data)
+}
+
+// Remove deletes the file of a spilled image. It is a no-op for inline
+// images and for files already removed.
+func (i ImageContent) Remove() error {
+	if i.File == "" {
+		return nil
+	}
+	if err := os.Remove(i.File); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ToolErrorKind classifies why a tool call failed.
+type ToolErrorKind string
+
+const (
+	// ToolErrorNone means the tool call succeeded.
+	ToolErrorNone ToolErrorKind = ""
+	// ToolErrorPermissionDenied means the tool call was refused, either by
+	// the permission system or by the operating system.
+	ToolErrorPermissionDenied ToolErrorKind = "permission_denied"
+	// ToolErrorFileNotFound means the tool referenced a missing path.
+	ToolErrorFileNotFound ToolErrorKind = "file_not_found"
+	// ToolErrorTimeout means the tool call ran out of time.
+	ToolErrorTimeout ToolErrorKind = "timeout"
+	// ToolErrorOther covers failures that match no known shape.
+	ToolErrorOther ToolErrorKind = "other"
+)
+
+// toolErrorPatterns maps lowercase fragments of the CLI's error messages to
+// their kind. Timeouts are checked first since a timed out command may
+// mention paths or permissions in its output.
+var toolErrorPatterns = []struct {
+	kind      ToolErrorKind
+	fragments []string
+}{
+	{ToolErrorTimeout, []string{"timed out", "deadline exceeded"}},
+	{ToolErrorPermissionDenied, []string{
+		"permission denied", "operation not permitted", "eacces", "eperm",
+		"requested permissions", "haven't granted",
+	}},
+	{ToolErrorFileNotFound, []string{
+		"no such file", "does not exist", "enoent", "file not found",
+	}},
+}
+
+// Failed reports whether the tool call failed.
+func (b *ToolResultBlock) Failed() bool {
+	return b.IsError != nil && *b.IsError
+}
+
+// Text returns the text of the tool result, joining text blocks of
+// structured content.
+func (b *ToolResultBlock) Text() string {
+	text, _ := toolResultText(b.Content)
+	return text
+}
+
+// ErrorText returns the text of a failed tool result, joining text blocks
+// of structured content. It is empty for successful results.
+func (b *ToolResultBlock) ErrorText() string {
+	if !b.Failed() {
+		return ""
+	}
+	return b.Text()
+}
+
+// ErrorKind classifies a failed tool result from its error text. It returns
+// ToolErrorNone for successful results and ToolErrorOther when the text
+// matches no known shape.
+func (b *ToolResultBlock) ErrorKind() ToolErrorKind {
+	if !b.Failed() {
+		return ToolErrorNone
+	}
+	text := strings.ToLower(b.ErrorText())
+	for _, pattern := range toolErrorPatterns {
+		for _, fragment := range pattern.fragments {
+			if strings.Contains(text, fragment) {
+				return pattern.kind
+			}
+		}
+	}
+	return ToolErrorOther
+}
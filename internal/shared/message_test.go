@@ -1,7 +1,12 @@
 package shared
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -182,6 +187,22 @@ func TestJSONMarshaling(t *testing.T) {
 
 	assertJSONField(t, jsonData, "type", MessageTypeUser)
 	assertJSONField(t, jsonData, "content", "Hello, Claude!")
+
+	// Test ResultMessage includes resumed session metadata when set
+	resultMsg := &ResultMessage{
+		Subtype:   "success",
+		SessionID: "s1",
+		Resumed:   &ResumedSession{SessionID: "s0", NumTurns: 3},
+	}
+
+	jsonData, err = json.Marshal(resultMsg)
+	if err != nil {
+		t.Fatalf("Failed to marshal ResultMessage: %v", err)
+	}
+
+	if !strings.Contains(string(jsonData), `"resumed":{"session_id":"s0","num_turns":3}`) {
+		t.Errorf("Expected resumed metadata in %s", jsonData)
+	}
 }
 
 // TestInterfaceCompliance tests interface implementation for all types
@@ -581,3 +602,195 @@ func TestAssistantMessageErrorJSONMarshaling(t *testing.T) {
 		t.Error("Expected 'error' field to be omitted when nil")
 	}
 }
+
+func TestToolResultBlockImages(t *testing.T) {
+	pngBytes := []byte{0x89, 'P', 'N', 'G'}
+	spilled := filepath.Join(t.TempDir(), "shot.png")
+	if err := os.WriteFile(spilled, pngBytes, 0o600); err != nil {
+		t.Fatalf("failed to write image file: %v", err)
+	}
+
+	block := &ToolResultBlock{
+		ToolUseID: "tool_1",
+		Content: []any{
+			map[string]any{"type": "text", "text": "screenshot taken"},
+			map[string]any{"type": "image", "source": map[string]any{
+				"type":       ImageSourceTypeBase64,
+				"media_type": "image/png",
+				"data":       base64.StdEncoding.EncodeToString(pngBytes),
+			}},
+			map[string]any{"type": "image", "source": map[string]any{
+				"type":       ImageSourceTypeFile,
+				"media_type": "image/png",
+				"path":       spilled,
+			}},
+			map[string]any{"type": "image", "source": map[string]any{"type": "url"}},
+		},
+	}
+
+	images := block.Images()
+	if len(images) != 2 {
+		t.Fatalf("Expected 2 images, got %d", len(images))
+	}
+
+	for i, image := range images {
+		if image.MediaType != "image/png" {
+			t.Errorf("image %d: expected media type image/png, got %q", i, image.MediaType)
+		}
+		data, err := image.Data()
+		if err != nil {
+			t.Fatalf("image %d: Data failed: %v", i, err)
+		}
+		if string(data) != string(pngBytes) {
+			t.Errorf("image %d: unexpected data %v", i, data)
+		}
+	}
+
+	if images[0].IsFile() || !images[1].IsFile() {
+		t.Error("Expected only the second image to be file-backed")
+	}
+	if err := images[0].Remove(); err != nil {
+		t.Errorf("Remove on inline image should be a no-op, got %v", err)
+	}
+	if err := images[1].Remove(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := os.Stat(spilled); !os.IsNotExist(err) {
+		t.Error("Expected spilled file to be removed")
+	}
+}
+
+func TestToolResultBlockImagesStringContent(t *testing.T) {
+	block := &ToolResultBlock{ToolUseID: "tool_1", Content: "plain text result"}
+	if images := block.Images(); images != nil {
+		t.Errorf("Expected no images for string content, got %v", images)
+	}
+}
+
+func TestToolResultBlockErrorKind(t *testing.T) {
+	failed, succeeded := true, false
+	tests := []struct {
+		name    string
+		content any
+		isError *bool
+		want    ToolErrorKind
+	}{
+		{"success", "Permission denied", &succeeded, ToolErrorNone},
+		{"unset is_error", "No such file or directory", nil, ToolErrorNone},
+		{"shell permission", "bash: ./deploy.sh: Permission denied", &failed, ToolErrorPermissionDenied},
+		{"permission system", "Claude requested permissions to use Bash, but you haven't granted it yet.", &failed, ToolErrorPermissionDenied},
+		{"missing file", "File does not exist.", &failed, ToolErrorFileNotFound},
+		{"enoent", "ENOENT: no such file or directory, open 'x.go'", &failed, ToolErrorFileNotFound},
+		{"command timeout", "Command timed out after 2m 0.0s", &failed, ToolErrorTimeout},
+		{"timeout in a path", "no such file: timeout.go", &failed, ToolErrorFileNotFound},
+		{"deadline", "context deadline exceeded", &failed, ToolErrorTimeout},
+		{"structured content", []any{
+			map[string]any{"type": "text", "text": "cat: notes.txt: No such file or directory"},
+		}, &failed, ToolErrorFileNotFound},
+		{"unrecognized", "exit status 2", &failed, ToolErrorOther},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			block := &ToolResultBlock{ToolUseID: "tool_1", Content: test.content, IsError: test.isError}
+			if got := block.ErrorKind(); got != test.want {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+			if block.Failed() != (test.want != ToolErrorNone) {
+				t.Errorf("Expected Failed() to be %v", test.want != ToolErrorNone)
+			}
+		})
+	}
+}
+
+func TestToolResultBlockErrorText(t *testing.T) {
+	failed := true
+	block := &ToolResultBlock{IsError: &failed, Content: []any{
+		map[string]any{"type": "text", "text": "first"},
+		map[string]any{"type": "image"},
+		map[string]any{"type": "text", "text": "second"},
+	}}
+	if text := block.ErrorText(); text != "first\nsecond" {
+		t.Errorf("Expected joined text blocks, got %q", text)
+	}
+
+	block.IsError = nil
+	if text := block.ErrorText(); text != "" {
+		t.Errorf("Expected no error text for a successful result, got %q", text)
+	}
+}
+
+func TestSystemMessageInit(t *testing.T) {
+	msg := &SystemMessage{Subtype: SystemSubtypeInit, Data: map[string]any{
+		"type":                "system",
+		"subtype":             "init",
+		"session_id":          "session-1",
+		"model":               "claude-sonnet-4-5",
+		"cwd":                 "/work",
+		"tools":               []any{"Read", "Bash", 7},
+		"mcp_servers":         []any{map[string]any{"name": "github", "status": "connected"}, "bad"},
+		"permissionMode":      "acceptEdits",
+		"slash_commands":      []any{"compact", "review"},
+		"apiKeySource":        "ANTHROPIC_API_KEY",
+		"claude_code_version": "2.0.0",
+		"output_style":        "default",
+		"agents":              []any{"reviewer"},
+		"memory_files": []any{
+			map[string]any{"path": "/work/CLAUDE.md", "source": "project"},
+			map[string]any{"source": "user"},
+		},
+	}}
+
+	info, ok := msg.Init()
+	if !ok {
+		t.Fatal("Expected init message to be recognized")
+	}
+	want := InitInfo{
+		SessionID:         "session-1",
+		Model:             "claude-sonnet-4-5",
+		Cwd:               "/work",
+		Tools:             []string{"Read", "Bash"},
+		McpServers:        []McpServerStatus{{Name: "github", Status: "connected"}},
+		PermissionMode:    PermissionModeAcceptEdits,
+		SlashCommands:     []string{"compact", "review"},
+		APIKeySource:      "ANTHROPIC_API_KEY",
+		ClaudeCodeVersion: "2.0.0",
+		OutputStyle:       "default",
+		Agents:            []string{"reviewer"},
+		MemoryFiles:       []MemoryFile{{Path: "/work/CLAUDE.md", Source: MemorySourceProject}},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("Expected %+v, got %+v", want, info)
+	}
+
+	if _, ok := (&SystemMessage{Subtype: "compact_boundary"}).Init(); ok {
+		t.Error("Expected other system messages not to parse as init")
+	}
+}
+
+func TestUsageCacheHitRatio(t *testing.T) {
+	usage := &Usage{InputTokens: 10, OutputTokens: 50, CacheCreation: 30, CacheRead: 60}
+	if total := usage.TotalInputTokens(); total != 100 {
+		t.Errorf("Expected 100 input tokens, got %d", total)
+	}
+	if ratio := usage.CacheHitRatio(); ratio != 0.6 {
+		t.Errorf("Expected a cache hit ratio of 0.6, got %v", ratio)
+	}
+	if ratio := (&Usage{}).CacheHitRatio(); ratio != 0 {
+		t.Errorf("Expected no ratio without input tokens, got %v", ratio)
+	}
+}
+
+func TestTextBlockCacheControlJSON(t *testing.T) {
+	data, err := json.Marshal(&TextBlock{Text: "context", CacheControl: &CacheControl{Type: CacheControlEphemeral}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"cache_control":{"type":"ephemeral"}`) {
+		t.Errorf("Expected the cache control encoded, got %s", data)
+	}
+	data, _ = json.Marshal(&TextBlock{Text: "prompt"})
+	if strings.Contains(string(data), "cache_control") {
+		t.Errorf("Expected no cache control, got %s", data)
+	}
+}
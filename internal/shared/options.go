@@ -1,8 +1,13 @@
 package shared
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"time"
 )
 
 const (
@@ -24,6 +29,457 @@ const (
 	PermissionModeBypassPermissions PermissionMode = "bypassPermissions"
 )
 
+// PermissionModes returns every permission mode the CLI accepts.
+func PermissionModes() []PermissionMode {
+	return []PermissionMode{
+		PermissionModeDefault,
+		PermissionModeAcceptEdits,
+		PermissionModePlan,
+		PermissionModeBypassPermissions,
+	}
+}
+
+// IsValid reports whether m is one of the permission modes the CLI accepts.
+func (m PermissionMode) IsValid() bool {
+	switch m {
+	case PermissionModeDefault, PermissionModeAcceptEdits, PermissionModePlan, PermissionModeBypassPermissions:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParsePermissionMode converts s to a PermissionMode, rejecting unknown modes.
+func ParsePermissionMode(s string) (PermissionMode, error) {
+	mode := PermissionMode(s)
+	if !mode.IsValid() {
+		return "", fmt.Errorf("invalid permission mode: %q", s)
+	}
+	return mode, nil
+}
+
+// Plan is the plan the agent presents when it leaves plan mode.
+type Plan struct {
+	// Steps are the plan's list items, or its non-heading lines when the
+	// plan contains no list.
+	Steps []string
+	// Raw is the plan text exactly as the agent wrote it.
+	Raw string
+}
+
+// PlanDecision is a PlanReviewer's verdict on a plan.
+type PlanDecision struct {
+	Approved bool
+	// Feedback is returned to the agent when the plan is rejected so it can
+	// revise the plan.
+	Feedback string
+}
+
+// PlanReviewer approves or rejects a plan before the agent starts executing it.
+type PlanReviewer func(ctx context.Context, plan Plan) (PlanDecision, error)
+
+// PathPolicy checks the paths of a file tool call before the tool runs. An
+// error denies the call and is shown to the agent.
+type PathPolicy interface {
+	CheckTool(toolName string, input map[string]any) error
+}
+
+// PathTools lists the file tools whose paths a PathPolicy checks.
+var PathTools = []string{"Read", "Write", "Edit", "MultiEdit", "NotebookEdit", "NotebookRead", "Glob", "Grep", "LS"}
+
+// IsPathTool reports whether name is one of PathTools.
+func IsPathTool(name string) bool {
+	for _, tool := range PathTools {
+		if tool == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolEventType identifies the stage of a tool call reported to a ToolObserver.
+type ToolEventType string
+
+const (
+	// ToolEventStarted is reported when the agent requests a tool use.
+	ToolEventStarted ToolEventType = "started"
+	// ToolEventCompleted is reported when the tool's result arrives.
+	ToolEventCompleted ToolEventType = "completed"
+)
+
+// ToolEvent describes a tool call starting or completing.
+type ToolEvent struct {
+	Type      ToolEventType
+	ToolUseID string
+	ToolName  string
+	Time      time.Time
+	// IsError and Duration are set on completed events only. Duration is
+	// the time between the tool use and its result arriving.
+	IsError  bool
+	Duration time.Duration
+}
+
+// ToolObserver receives tool call events, for example to export metrics.
+type ToolObserver func(ToolEvent)
+
+// SlowHookReport describes a hook that ran longer than the threshold set
+// with WithSlowHookThreshold.
+type SlowHookReport struct {
+	// Event is the hook event, such as "PreToolUse", and Pattern the
+	// pattern the hook was registered with.
+	Event   string
+	Pattern string
+	// ToolName is the tool of PreToolUse and PostToolUse events.
+	ToolName  string
+	Time      time.Time
+	Duration  time.Duration
+	Threshold time.Duration
+	// TimedOut is set when the hook was abandoned at its timeout.
+	TimedOut bool
+}
+
+// SlowHookReporter receives reports of slow hooks.
+type SlowHookReporter func(SlowHookReport)
+
+// QueryDeadlinePolicy adapts a query to the deadline of its context.
+type QueryDeadlinePolicy struct {
+	// Margin is how long before the deadline the turn is interrupted,
+	// leaving the CLI time to end it and report its result.
+	Margin time.Duration
+	// AnnounceDeadline tells the agent in the prompt how long it has, so it
+	// can plan its work to fit.
+	AnnounceDeadline bool
+}
+
+// AutoReconnectPolicy controls how a client recovers when the CLI exits to
+// restart itself, as after an auto-update.
+type AutoReconnectPolicy struct {
+	// ExitCodes are the exit codes of a CLI exiting to be restarted. They
+	// are required: the CLI documents no exit code of its own for
+	// restarts, so list the codes your CLI version or wrapper uses.
+	ExitCodes []int
+	// MaxAttempts limits the reconnects of a connection; zero means the
+	// SDK's default.
+	MaxAttempts int
+	// OnEvent receives each reconnect as it starts and ends.
+	OnEvent ReconnectObserver `json:"-"`
+}
+
+// ReconnectStage identifies the stage of a reconnect reported to a
+// ReconnectObserver.
+type ReconnectStage string
+
+const (
+	// ReconnectStarted is reported when the CLI exits to restart, before
+	// the new process starts.
+	ReconnectStarted ReconnectStage = "started"
+	// ReconnectCompleted is reported once the new process is connected.
+	ReconnectCompleted ReconnectStage = "completed"
+	// ReconnectFailed is reported when the new process could not start;
+	// the client's message stream then ends as for any CLI exit.
+	ReconnectFailed ReconnectStage = "failed"
+)
+
+// ReconnectEvent describes a reconnect after the CLI exited to restart.
+type ReconnectEvent struct {
+	Stage ReconnectStage
+	// SessionID is the session resumed in the new process, empty when the
+	// CLI exited before reporting one.
+	SessionID string
+	ExitCode  int
+	// Attempt numbers the reconnects of a connection from 1.
+	Attempt int
+	Time    time.Time
+	// TurnInterrupted is set when a turn was in progress. It ended without
+	// a ResultMessage; its prompt is not resent.
+	TurnInterrupted bool
+	// Duration is the pause in the session, and Err why the new process
+	// could not start. Both are set on completed and failed events only.
+	Duration time.Duration
+	Err      error
+}
+
+// ReconnectObserver receives reconnect events, for example to tell users
+// about a brief pause.
+type ReconnectObserver func(ReconnectEvent)
+
+// InFlightTool is a tool call waiting for its result.
+type InFlightTool struct {
+	ToolUseID string
+	ToolName  string
+	Input     map[string]any
+	Started   time.Time
+	Elapsed   time.Duration
+	// Timeout is how long the CLI lets the call run, when known: for Bash,
+	// the command's timeout or the CLI's default of two minutes.
+	Timeout time.Duration
+	// Progress estimates how far along the call is, from 0 to 1, or is -1
+	// when it cannot be estimated. Calls with a Timeout report the fraction
+	// of it elapsed, which bounds how long is left rather than predicting
+	// when the call ends.
+	Progress float64
+}
+
+// ToolProgressFunc receives the tool calls in flight, oldest first.
+type ToolProgressFunc func([]InFlightTool)
+
+// TurnObserver receives each turn once its ResultMessage arrives.
+type TurnObserver func(Turn)
+
+// McpServerHealth is the state of an MCP server in the current session.
+type McpServerHealth struct {
+	Name string
+	// Status is one of the McpStatus values, or empty until the CLI's init
+	// message arrives.
+	Status string
+	// Tools counts the tools the server provides.
+	Tools int
+	// Configured reports whether the server was set with the client's
+	// options, rather than picked up from the CLI's settings.
+	Configured bool
+}
+
+// Available reports whether the server is connected.
+func (h McpServerHealth) Available() bool {
+	return h.Status == McpStatusConnected
+}
+
+// McpObserver receives MCP servers that are unavailable when a session
+// starts.
+type McpObserver func(McpServerHealth)
+
+// MessageObserver sees each message the CLI sends before it is delivered.
+type MessageObserver func(Message)
+
+// RouteFlagDeep asks a ModelRouter for its most capable model.
+const RouteFlagDeep = "deep"
+
+// RouteRequest describes a request to a ModelRouter.
+type RouteRequest struct {
+	Prompt string
+	// Model is the model the request uses without routing, or "" for the
+	// CLI's default.
+	Model string
+	// Tools lists the tools the request may use, from WithAllowedTools, or
+	// is empty when they are not restricted.
+	Tools []string
+	// Flags are the hints set with WithRouteFlags, such as RouteFlagDeep.
+	Flags []string
+	// RemainingBudgetUSD is what is left of WithMaxBudgetUSD, or nil
+	// without a budget.
+	RemainingBudgetUSD *float64
+}
+
+// HasFlag reports whether flag was set for the request.
+func (r RouteRequest) HasFlag(flag string) bool {
+	for _, f := range r.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelRouter picks the model for each request.
+type ModelRouter interface {
+	// Route returns the model for req, or "" to keep req.Model.
+	Route(ctx context.Context, req RouteRequest) string
+}
+
+// ModelRouterFunc adapts a function to a ModelRouter.
+type ModelRouterFunc func(ctx context.Context, req RouteRequest) string
+
+// Route calls f.
+func (f ModelRouterFunc) Route(ctx context.Context, req RouteRequest) string {
+	return f(ctx, req)
+}
+
+// IdempotencyStore keeps the results of queries sent with an idempotency
+// key, so a retried query is answered with the first one's result. A store
+// shared by the instances of a service deduplicates retries across them.
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Claim reserves key for a query about to run, and reports whether it
+	// did. For a key whose query completed, it returns the stored result
+	// instead.
+	Claim(ctx context.Context, key string) (result *ResultMessage, claimed bool, err error)
+	// Complete stores the result of the query holding key.
+	Complete(ctx context.Context, key string, result *ResultMessage) error
+	// Abandon frees the key of a query that failed, so its retry runs.
+	Abandon(ctx context.Context, key string) error
+}
+
+// PromptInterceptor inspects an outgoing prompt before it is sent to the
+// CLI. It may rewrite msg.Content in place, or return an error to reject
+// the prompt.
+type PromptInterceptor func(ctx context.Context, msg *UserMessage) error
+
+// ConfigCleanup says when an isolated config directory is removed.
+type ConfigCleanup string
+
+const (
+	// ConfigCleanupOnDisconnect removes the directory when the client
+	// disconnects, so each connection starts afresh.
+	ConfigCleanupOnDisconnect ConfigCleanup = "on_disconnect"
+	// ConfigCleanupOnSuccess removes the directory when the client
+	// disconnects, unless a turn ended in an error or the session ended
+	// without Disconnect.
+	ConfigCleanupOnSuccess ConfigCleanup = "on_success"
+	// ConfigCleanupNever keeps the directory for the caller to remove.
+	ConfigCleanupNever ConfigCleanup = "never"
+)
+
+// IsValid reports whether c is a known cleanup policy.
+func (c ConfigCleanup) IsValid() bool {
+	switch c {
+	case ConfigCleanupOnDisconnect, ConfigCleanupOnSuccess, ConfigCleanupNever:
+		return true
+	}
+	return false
+}
+
+// SessionEndReason describes why a client session ended.
+type SessionEndReason string
+
+const (
+	// SessionEndDisconnect means the client was disconnected normally.
+	SessionEndDisconnect SessionEndReason = "disconnect"
+	// SessionEndContextCanceled means the context passed to WithClient was
+	// canceled or timed out.
+	SessionEndContextCanceled SessionEndReason = "context_canceled"
+	// SessionEndPanic means the function passed to WithClient panicked.
+	SessionEndPanic SessionEndReason = "panic"
+	// SessionEndProcessExit means the CLI process exited before the client
+	// was disconnected.
+	SessionEndProcessExit SessionEndReason = "process_exit"
+)
+
+// SessionSummary describes a finished client session. Token counts and
+// cost are totals over every ResultMessage received.
+type SessionSummary struct {
+	SessionID string
+	Reason    SessionEndReason
+	// Duration is the time from connecting until teardown completed.
+	Duration time.Duration
+	NumTurns int
+
+	InputTokens              int
+	OutputTokens             int
+	CacheCreationInputTokens int
+	CacheReadInputTokens     int
+	TotalCostUSD             float64
+
+	// Tags are the session tags set with WithSessionTags.
+	Tags map[string]string
+}
+
+// CacheHitRatio returns the fraction of the session's input tokens read
+// from the prompt cache, or 0 when there were none.
+func (s SessionSummary) CacheHitRatio() float64 {
+	return cacheHitRatio(s.CacheReadInputTokens, s.InputTokens+s.CacheCreationInputTokens+s.CacheReadInputTokens)
+}
+
+// Finalizer receives the summary of a client session after it was torn down.
+type Finalizer func(SessionSummary)
+
+// DebugRecord is one line of the CLI's stderr output.
+type DebugRecord struct {
+	// Time is the timestamp the CLI logged, or when the SDK read the line
+	// if the CLI did not log one.
+	Time time.Time
+	// Level is the bracketed level tag, such as "DEBUG" or "ERROR", or
+	// empty for untagged output.
+	Level   string
+	Message string
+	Raw     string
+}
+
+// StderrCallback receives CLI stderr output line by line as it is written.
+type StderrCallback func(DebugRecord)
+
+// CLICommand is the CLI process a transport is about to start.
+type CLICommand struct {
+	// Args are the CLI path followed by its arguments.
+	Args []string
+	// Dir is the working directory, or empty for the current one.
+	Dir string
+	// Env holds the variables set for the CLI on top of the inherited
+	// environment.
+	Env []string
+	// Files are temporary files the SDK wrote for the CLI to read, such as
+	// its MCP config.
+	Files []string
+}
+
+// CommandWrapper rewrites the command a transport starts, for example to
+// run the CLI in a container.
+type CommandWrapper func(cmd CLICommand) (CLICommand, error)
+
+// CassetteMode selects whether a cassette records a session or replays it.
+type CassetteMode string
+
+const (
+	// CassetteRecord runs the CLI and records its output, replacing the
+	// cassette.
+	CassetteRecord CassetteMode = "record"
+	// CassetteReplay replays the cassette without starting the CLI.
+	CassetteReplay CassetteMode = "replay"
+	// CassetteRecordOrReplay replays the cassette if it exists and records
+	// it otherwise.
+	CassetteRecordOrReplay CassetteMode = "record_or_replay"
+)
+
+// ErrCassetteMiss is returned when a replayed cassette holds no recording
+// for a prompt.
+var ErrCassetteMiss = errors.New("no recording for prompt in cassette")
+
+// ParseDebugRecord parses a CLI stderr line of the form
+// "[<RFC 3339 time>] [LEVEL] message", where both prefixes are optional.
+// Lines without a timestamp are stamped with now.
+func ParseDebugRecord(line string, now time.Time) DebugRecord {
+	record := DebugRecord{Time: now, Raw: line}
+	rest := strings.TrimSpace(line)
+
+	if field, after, found := strings.Cut(rest, " "); found {
+		if ts, err := time.Parse(time.RFC3339Nano, field); err == nil {
+			record.Time = ts
+			rest = strings.TrimSpace(after)
+		}
+	}
+	if strings.HasPrefix(rest, "[") {
+		if end := strings.IndexByte(rest, ']'); end > 1 {
+			record.Level = rest[1:end]
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+	}
+	record.Message = rest
+	return record
+}
+
+// ContextTruncationPolicy decides what happens when context added to the
+// next prompt exceeds its size limit.
+type ContextTruncationPolicy string
+
+const (
+	// ContextTruncate keeps items in the order they were added and cuts
+	// the item that crosses the limit; later items are dropped.
+	ContextTruncate ContextTruncationPolicy = "truncate"
+	// ContextDrop leaves out whole items that do not fit, keeping later
+	// items that still do.
+	ContextDrop ContextTruncationPolicy = "drop"
+	// ContextReject refuses to add an item that would exceed the limit.
+	ContextReject ContextTruncationPolicy = "reject"
+)
+
+// IsValid reports whether p is a known truncation policy.
+func (p ContextTruncationPolicy) IsValid() bool {
+	switch p {
+	case ContextTruncate, ContextDrop, ContextReject:
+		return true
+	}
+	return false
+}
+
 // SdkBeta represents a beta feature identifier.
 // See https://docs.anthropic.com/en/api/beta-headers
 type SdkBeta string
@@ -157,28 +613,83 @@ type Options struct {
 	Betas []SdkBeta `json:"betas,omitempty"`
 
 	// System Prompts & Model
-	SystemPrompt       *string `json:"system_prompt,omitempty"`
-	AppendSystemPrompt *string `json:"append_system_prompt,omitempty"`
-	Model              *string `json:"model,omitempty"`
-	FallbackModel      *string `json:"fallback_model,omitempty"`
-	MaxThinkingTokens  int     `json:"max_thinking_tokens,omitempty"`
+	SystemPrompt       *string     `json:"system_prompt,omitempty"`
+	AppendSystemPrompt *string     `json:"append_system_prompt,omitempty"`
+	Model              *string     `json:"model,omitempty"`
+	FallbackModel      *string     `json:"fallback_model,omitempty"`
+	MaxThinkingTokens  int         `json:"max_thinking_tokens,omitempty"`
+	ModelRouter        ModelRouter `json:"-"` // Not serialized
+	RouteFlags         []string    `json:"route_flags,omitempty"`
 
 	// Budget & Billing
-	MaxBudgetUSD *float64 `json:"max_budget_usd,omitempty"`
-	User         *string  `json:"user,omitempty"`
+	MaxBudgetUSD *float64          `json:"max_budget_usd,omitempty"`
+	User         *string           `json:"user,omitempty"`
+	SessionTags  map[string]string `json:"session_tags,omitempty"`
 
 	// Buffer Configuration (internal)
-	MaxBufferSize *int `json:"max_buffer_size,omitempty"`
+	MaxBufferSize        *int      `json:"max_buffer_size,omitempty"`
+	MaxMessageSize       *int      `json:"max_message_size,omitempty"`
+	MaxInlineResultBytes *int      `json:"max_inline_result_bytes,omitempty"`
+	MessageRecycling     bool      `json:"message_recycling,omitempty"`
+	JSONCodec            JSONCodec `json:"-"` // Not serialized
+
+	// Context injected into the next prompt
+	ContextMaxBytes   *int                    `json:"context_max_bytes,omitempty"`
+	ContextTruncation ContextTruncationPolicy `json:"context_truncation,omitempty"`
+	ContextCache      *CacheControl           `json:"context_cache,omitempty"`
 
 	// Permission & Safety System
-	PermissionMode           *PermissionMode `json:"permission_mode,omitempty"`
-	PermissionPromptToolName *string         `json:"permission_prompt_tool_name,omitempty"`
+	PermissionMode           *PermissionMode          `json:"permission_mode,omitempty"`
+	PermissionPromptToolName *string                  `json:"permission_prompt_tool_name,omitempty"`
+	PlanReviewer             PlanReviewer             `json:"-"` // Not serialized
+	MaxConcurrentTools       int                      `json:"max_concurrent_tools,omitempty"`
+	ToolTimeouts             map[string]time.Duration `json:"tool_timeouts,omitempty"`
+	DryRun                   bool                     `json:"dry_run,omitempty"`
+	PathPolicy               PathPolicy               `json:"-"` // Not serialized
+	// ConfinePaths confines the file tools to the working directory and the
+	// added directories when PathPolicy is not set.
+	ConfinePaths bool `json:"confine_paths,omitempty"`
+
+	// Observability
+	ToolObserver          ToolObserver      `json:"-"` // Not serialized
+	SlowHookThreshold     time.Duration     `json:"slow_hook_threshold,omitempty"`
+	SlowHookReporter      SlowHookReporter  `json:"-"` // Not serialized
+	ToolProgress          ToolProgressFunc  `json:"-"` // Not serialized
+	ToolProgressInterval  time.Duration     `json:"tool_progress_interval,omitempty"`
+	TurnObserver          TurnObserver      `json:"-"` // Not serialized
+	MessageObservers      []MessageObserver `json:"-"` // Not serialized
+	StreamIntegrityChecks bool              `json:"stream_integrity_checks,omitempty"`
+	StrictOrdering        bool              `json:"strict_ordering,omitempty"`
+	StrictProtocol        bool              `json:"strict_protocol,omitempty"`
+	Finalizer             Finalizer         `json:"-"` // Not serialized
+	Debug                 bool              `json:"debug,omitempty"`
+	StderrCallback        StderrCallback    `json:"-"` // Not serialized
+
+	// Locale selects the language of the SDK's messages, such as tool
+	// denials sent to the agent.
+	Locale string `json:"locale,omitempty"`
+
+	// Query Dispatch
+	QueryDeadline      *QueryDeadlinePolicy `json:"query_deadline,omitempty"`
+	QueryQueueing      bool                 `json:"query_queueing,omitempty"`
+	PartialText        bool                 `json:"partial_text,omitempty"`
+	PromptInterceptors []PromptInterceptor  `json:"-"` // Not serialized
+	// IdempotencyKey identifies a query so a retry of it returns the first
+	// one's result from IdempotencyStore instead of running again. It is
+	// set per query.
+	IdempotencyKey   string           `json:"idempotency_key,omitempty"`
+	IdempotencyStore IdempotencyStore `json:"-"` // Not serialized
+
+	// Session Startup
+	InitTimeout   time.Duration        `json:"init_timeout,omitempty"`
+	AutoReconnect *AutoReconnectPolicy `json:"auto_reconnect,omitempty"`
 
 	// Session & State Management
 	ContinueConversation bool            `json:"continue_conversation,omitempty"`
 	Resume               *string         `json:"resume,omitempty"`
 	MaxTurns             int             `json:"max_turns,omitempty"`
 	Settings             *string         `json:"settings,omitempty"`
+	SettingsJSON         []byte          `json:"-"` // Written to a temporary file
 	ForkSession          bool            `json:"fork_session,omitempty"`
 	SettingSources       []SettingSource `json:"setting_sources,omitempty"`
 
@@ -189,8 +700,22 @@ type Options struct {
 	Cwd     *string  `json:"cwd,omitempty"`
 	AddDirs []string `json:"add_dirs,omitempty"`
 
+	// EphemeralWorkspace runs each connection in a new temporary directory,
+	// copied from the template directory it names unless that is empty.
+	// KeepWorkspaceOnFailure keeps the directory of a failed session.
+	EphemeralWorkspace     *string `json:"ephemeral_workspace,omitempty"`
+	KeepWorkspaceOnFailure bool    `json:"keep_workspace_on_failure,omitempty"`
+
+	// Memory (CLAUDE.md) files. MemoryFiles are read when the CLI starts
+	// and appended to the system prompt; NoProjectMemory rejects the project
+	// and local setting sources, which load the project's CLAUDE.md.
+	MemoryFiles     []string `json:"memory_files,omitempty"`
+	NoProjectMemory bool     `json:"no_project_memory,omitempty"`
+
 	// MCP Integration
-	McpServers map[string]McpServerConfig `json:"mcp_servers,omitempty"`
+	McpServers  map[string]McpServerConfig `json:"mcp_servers,omitempty"`
+	McpStrict   bool                       `json:"mcp_strict,omitempty"`
+	McpObserver McpObserver                `json:"-"` // Not serialized
 
 	// Sandbox Configuration
 	Sandbox *SandboxSettings `json:"sandbox,omitempty"`
@@ -205,6 +730,19 @@ type Options struct {
 	// These are merged with the system environment variables.
 	ExtraEnv map[string]string `json:"extra_env,omitempty"`
 
+	// APIKey and OAuthToken authenticate the CLI, replacing any API key or
+	// token in the inherited environment. ConfigDir is the CLI's config
+	// directory, holding its login and session transcripts.
+	APIKey     string `json:"-"` // Not serialized
+	OAuthToken string `json:"-"` // Not serialized
+	ConfigDir  string `json:"config_dir,omitempty"`
+
+	// IsolatedConfig gives each client its own config directory, created in
+	// the base directory it names, or the system temporary directory if that
+	// is empty. ConfigCleanup says when the directory is removed.
+	IsolatedConfig *string       `json:"isolated_config,omitempty"`
+	ConfigCleanup  ConfigCleanup `json:"config_cleanup,omitempty"`
+
 	// OutputFormat specifies structured output format with JSON schema.
 	// When set, Claude's response will conform to the provided schema.
 	OutputFormat *OutputFormat `json:"output_format,omitempty"`
@@ -212,10 +750,24 @@ type Options struct {
 	// CLI Path (for testing and custom installations)
 	CLIPath *string `json:"cli_path,omitempty"`
 
+	// CommandWrapper rewrites the CLI command before it starts.
+	CommandWrapper CommandWrapper `json:"-"` // Not serialized
+
+	// Cassette records the CLI's output to CassettePath, or replays it
+	// from there without starting the CLI.
+	CassettePath string       `json:"cassette_path,omitempty"`
+	CassetteMode CassetteMode `json:"cassette_mode,omitempty"`
+
 	// DebugWriter specifies where to write debug output from the CLI subprocess.
-	// If nil (default), stderr is isolated to a temporary file to prevent deadlocks.
+	// If nil (default), stderr is isolated to a temporary file to prevent deadlocks,
+	// unless StderrCallback is set.
 	// Common values: os.Stderr, io.Discard, or a custom io.Writer.
 	DebugWriter io.Writer `json:"-"` // Not serialized
+
+	// Clock and IDGenerator replace the system clock and the sequential
+	// request IDs, for deterministic tests.
+	Clock       Clock       `json:"-"` // Not serialized
+	IDGenerator IDGenerator `json:"-"` // Not serialized
 }
 
 // McpServerType represents the type of MCP server.
@@ -284,21 +836,160 @@ func (o *Options) Validate() error {
 		return fmt.Errorf("MaxTurns must be non-negative, got %d", o.MaxTurns)
 	}
 
-	// Validate tool conflicts (same tool in both allowed and disallowed)
-	allowedSet := make(map[string]bool)
-	for _, tool := range o.AllowedTools {
-		allowedSet[tool] = true
+	// Validate PermissionMode
+	if o.PermissionMode != nil && !o.PermissionMode.IsValid() {
+		return fmt.Errorf("invalid permission mode: %q", *o.PermissionMode)
+	}
+
+	// Validate SettingsJSON
+	if o.SettingsJSON != nil {
+		if o.Settings != nil {
+			return fmt.Errorf("settings path and SettingsJSON cannot both be set")
+		}
+		var settings map[string]any
+		if err := json.Unmarshal(o.SettingsJSON, &settings); err != nil || settings == nil {
+			return fmt.Errorf("SettingsJSON must be a JSON object")
+		}
+	}
+
+	// Validate MaxMessageSize
+	if o.MaxMessageSize != nil && *o.MaxMessageSize <= 0 {
+		return fmt.Errorf("MaxMessageSize must be positive, got %d", *o.MaxMessageSize)
+	}
+
+	// Validate MaxInlineResultBytes
+	if o.MaxInlineResultBytes != nil && *o.MaxInlineResultBytes <= 0 {
+		return fmt.Errorf("MaxInlineResultBytes must be positive, got %d", *o.MaxInlineResultBytes)
+	}
+
+	// Validate context limits
+	if o.ContextMaxBytes != nil && *o.ContextMaxBytes <= 0 {
+		return fmt.Errorf("ContextMaxBytes must be positive, got %d", *o.ContextMaxBytes)
+	}
+	if o.ContextTruncation != "" && !o.ContextTruncation.IsValid() {
+		return fmt.Errorf("invalid context truncation policy: %q", o.ContextTruncation)
+	}
+	if o.ContextCache != nil && o.ContextCache.TTL != "" && o.ContextCache.TTL != "5m" && o.ContextCache.TTL != "1h" {
+		return fmt.Errorf("invalid context cache TTL: %q (must be 5m or 1h)", o.ContextCache.TTL)
 	}
 
-	for _, tool := range o.DisallowedTools {
-		if allowedSet[tool] {
-			return fmt.Errorf("tool '%s' cannot be in both AllowedTools and DisallowedTools", tool)
+	// Validate session tags
+	for key := range o.SessionTags {
+		if key == "" {
+			return fmt.Errorf("session tag keys must not be empty")
 		}
 	}
 
+	// Validate MaxConcurrentTools
+	if o.MaxConcurrentTools < 0 {
+		return fmt.Errorf("MaxConcurrentTools must be non-negative, got %d", o.MaxConcurrentTools)
+	}
+
+	// Validate AutoReconnect: the CLI documents no exit code for restarts,
+	// so the caller names the ones to reconnect on
+	if o.AutoReconnect != nil && len(o.AutoReconnect.ExitCodes) == 0 {
+		return fmt.Errorf("AutoReconnect requires ExitCodes")
+	}
+
+	// Validate ToolTimeouts
+	for name, timeout := range o.ToolTimeouts {
+		if name == "" {
+			return fmt.Errorf("tool timeout names must not be empty")
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("tool timeout for %s must be positive, got %s", name, timeout)
+		}
+	}
+
+	// Validate ToolProgressInterval
+	if o.ToolProgressInterval < 0 {
+		return fmt.Errorf("ToolProgressInterval must be non-negative, got %s", o.ToolProgressInterval)
+	}
+
+	// Validate SlowHookThreshold
+	if o.SlowHookThreshold < 0 {
+		return fmt.Errorf("SlowHookThreshold must be non-negative, got %s", o.SlowHookThreshold)
+	}
+
+	// Validate InitTimeout
+	if o.InitTimeout < 0 {
+		return fmt.Errorf("InitTimeout must be non-negative, got %s", o.InitTimeout)
+	}
+
+	// Validate credentials
+	if o.APIKey != "" && o.OAuthToken != "" {
+		return fmt.Errorf("APIKey and OAuthToken cannot both be set")
+	}
+
+	// Validate ConfigCleanup
+	if o.ConfigCleanup != "" && !o.ConfigCleanup.IsValid() {
+		return fmt.Errorf("invalid config cleanup policy: %q", o.ConfigCleanup)
+	}
+
+	// Validate memory files
+	for _, path := range o.MemoryFiles {
+		if path == "" {
+			return fmt.Errorf("memory file path must not be empty")
+		}
+	}
+	if o.NoProjectMemory {
+		for _, source := range o.SettingSources {
+			if source == SettingSourceProject || source == SettingSourceLocal {
+				return fmt.Errorf("no project memory conflicts with the %q setting source, which loads the project's CLAUDE.md", source)
+			}
+		}
+	}
+
+	// Validate tool conflicts (same tool in both allowed and disallowed)
+	if tool, ok := ToolConflict(o.AllowedTools, o.DisallowedTools); ok {
+		return fmt.Errorf("tool %q cannot be both allowed and disallowed", tool)
+	}
+
 	return nil
 }
 
+// ToolConflict returns the first tool present in both allowed and disallowed.
+func ToolConflict(allowed, disallowed []string) (string, bool) {
+	seen := make(map[string]bool, len(allowed))
+	for _, tool := range allowed {
+		seen[tool] = true
+	}
+	for _, tool := range disallowed {
+		if seen[tool] {
+			return tool, true
+		}
+	}
+	return "", false
+}
+
+// CredentialEnvVars are the environment variables carrying the CLI's API
+// credentials, cleared when APIKey or OAuthToken replaces them.
+var CredentialEnvVars = []string{"ANTHROPIC_API_KEY", "ANTHROPIC_AUTH_TOKEN", "CLAUDE_CODE_OAUTH_TOKEN"}
+
+// CLIEnv returns the variables set for the CLI on top of the system
+// environment: ExtraEnv, with the credentials and config directory of the
+// options over it. An empty value unsets an inherited variable.
+func (o *Options) CLIEnv() map[string]string {
+	env := make(map[string]string, len(o.ExtraEnv)+len(CredentialEnvVars)+1)
+	for key, value := range o.ExtraEnv {
+		env[key] = value
+	}
+	if o.APIKey != "" || o.OAuthToken != "" {
+		for _, key := range CredentialEnvVars {
+			env[key] = ""
+		}
+		if o.APIKey != "" {
+			env["ANTHROPIC_API_KEY"] = o.APIKey
+		} else {
+			env["CLAUDE_CODE_OAUTH_TOKEN"] = o.OAuthToken
+		}
+	}
+	if o.ConfigDir != "" {
+		env["CLAUDE_CONFIG_DIR"] = o.ConfigDir
+	}
+	return env
+}
+
 // NewOptions creates Options with default values.
 func NewOptions() *Options {
 	return &Options{
@@ -1,7 +1,9 @@
 package shared
 
 import (
+	"reflect"
 	"testing"
+	"time"
 )
 
 // TestOptionsDefaults tests Options struct default values using table-driven approach
@@ -101,7 +103,7 @@ func TestOptionsValidation(t *testing.T) {
 				return opts
 			},
 			wantErr: true,
-			errMsg:  "tool 'Write' cannot be in both AllowedTools and DisallowedTools",
+			errMsg:  `tool "Write" cannot be both allowed and disallowed`,
 		},
 		{
 			name: "negative_max_turns",
@@ -113,6 +115,50 @@ func TestOptionsValidation(t *testing.T) {
 			wantErr: true,
 			errMsg:  "MaxTurns must be non-negative, got -5",
 		},
+		{
+			name: "non_positive_max_message_size",
+			setup: func() *Options {
+				opts := NewOptions()
+				size := 0
+				opts.MaxMessageSize = &size
+				return opts
+			},
+			wantErr: true,
+			errMsg:  "MaxMessageSize must be positive, got 0",
+		},
+		{
+			name: "settings_json_not_object",
+			setup: func() *Options {
+				opts := NewOptions()
+				opts.SettingsJSON = []byte(`"hooks"`)
+				return opts
+			},
+			wantErr: true,
+			errMsg:  "SettingsJSON must be a JSON object",
+		},
+		{
+			name: "settings_path_and_json",
+			setup: func() *Options {
+				opts := NewOptions()
+				path := "settings.json"
+				opts.Settings = &path
+				opts.SettingsJSON = []byte(`{}`)
+				return opts
+			},
+			wantErr: true,
+			errMsg:  "settings path and SettingsJSON cannot both be set",
+		},
+		{
+			name: "unknown_permission_mode",
+			setup: func() *Options {
+				opts := NewOptions()
+				mode := PermissionMode("yolo")
+				opts.PermissionMode = &mode
+				return opts
+			},
+			wantErr: true,
+			errMsg:  `invalid permission mode: "yolo"`,
+		},
 	}
 
 	for _, test := range tests {
@@ -184,6 +230,32 @@ func TestPermissionModeConstants(t *testing.T) {
 	}
 }
 
+// TestPermissionModeValidation tests that only the CLI's permission modes are accepted
+func TestPermissionModeValidation(t *testing.T) {
+	modes := PermissionModes()
+	if len(modes) != 4 {
+		t.Fatalf("Expected 4 permission modes, got %d", len(modes))
+	}
+	for _, mode := range modes {
+		if !mode.IsValid() {
+			t.Errorf("Expected %q to be valid", mode)
+		}
+		parsed, err := ParsePermissionMode(string(mode))
+		if err != nil || parsed != mode {
+			t.Errorf("ParsePermissionMode(%q) = %q, %v", mode, parsed, err)
+		}
+	}
+
+	for _, input := range []string{"", "Plan", "accept_edits", "bypass"} {
+		if PermissionMode(input).IsValid() {
+			t.Errorf("Expected %q to be invalid", input)
+		}
+		if _, err := ParsePermissionMode(input); err == nil {
+			t.Errorf("Expected ParsePermissionMode(%q) to fail", input)
+		}
+	}
+}
+
 // TestSettingSourceConstants tests setting source constant values
 func TestSettingSourceConstants(t *testing.T) {
 	tests := []struct {
@@ -288,3 +360,94 @@ func TestSandboxSettingsTypes(t *testing.T) {
 		t.Error("Expected IgnoreViolations to be set")
 	}
 }
+
+func TestParseDebugRecord(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	logged := time.Date(2025, 1, 2, 3, 4, 5, 678000000, time.UTC)
+
+	tests := []struct {
+		name string
+		line string
+		want DebugRecord
+	}{
+		{
+			name: "timestamp and level",
+			line: "2025-01-02T03:04:05.678Z [DEBUG] Loading settings",
+			want: DebugRecord{Time: logged, Level: "DEBUG", Message: "Loading settings"},
+		},
+		{
+			name: "level only",
+			line: "[ERROR] MCP server failed to start",
+			want: DebugRecord{Time: now, Level: "ERROR", Message: "MCP server failed to start"},
+		},
+		{
+			name: "plain output",
+			line: "Warning: something happened",
+			want: DebugRecord{Time: now, Message: "Warning: something happened"},
+		},
+		{
+			name: "empty brackets are not a level",
+			line: "[] list",
+			want: DebugRecord{Time: now, Message: "[] list"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.want.Raw = test.line
+			got := ParseDebugRecord(test.line, now)
+			if !got.Time.Equal(test.want.Time) || got.Level != test.want.Level ||
+				got.Message != test.want.Message || got.Raw != test.want.Raw {
+				t.Errorf("Expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestCLIEnv(t *testing.T) {
+	options := &Options{ExtraEnv: map[string]string{"DEBUG": "1", "CLAUDE_CODE_OAUTH_TOKEN": "inherited"}}
+	if env := options.CLIEnv(); !reflect.DeepEqual(env, options.ExtraEnv) {
+		t.Errorf("Expected ExtraEnv unchanged, got %v", env)
+	}
+
+	options.OAuthToken = "sk-ant-oat-test"
+	options.ConfigDir = "/srv/bot/claude"
+	want := map[string]string{
+		"DEBUG":                   "1",
+		"ANTHROPIC_API_KEY":       "",
+		"ANTHROPIC_AUTH_TOKEN":    "",
+		"CLAUDE_CODE_OAUTH_TOKEN": "sk-ant-oat-test",
+		"CLAUDE_CONFIG_DIR":       "/srv/bot/claude",
+	}
+	if env := options.CLIEnv(); !reflect.DeepEqual(env, want) {
+		t.Errorf("Expected %v, got %v", want, env)
+	}
+
+	options.APIKey = "sk-ant-test"
+	if err := options.Validate(); err == nil {
+		t.Error("Expected an error with both an API key and an OAuth token")
+	}
+}
+
+func TestToolConflict(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowed    []string
+		disallowed []string
+		conflict   string
+	}{
+		{"no overlap", []string{"Read", "Write"}, []string{"Bash"}, ""},
+		{"overlap", []string{"Read", "Bash"}, []string{"WebFetch", "Bash"}, "Bash"},
+		{"rules are distinct entries", []string{"Bash(git:*)"}, []string{"Bash"}, ""},
+		{"empty lists", nil, nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tool, ok := ToolConflict(test.allowed, test.disallowed)
+			if ok != (test.conflict != "") || tool != test.conflict {
+				t.Errorf("Expected conflict %q, got %q (ok=%v)", test.conflict, tool, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package shared
+
+import "sync"
+
+// recyclePool is a typed sync.Pool for message structs.
+type recyclePool[T any] struct {
+	pool sync.Pool
+}
+
+func (p *recyclePool[T]) get() *T {
+	if v, ok := p.pool.Get().(*T); ok {
+		return v
+	}
+	return new(T)
+}
+
+func (p *recyclePool[T]) put(v *T) {
+	p.pool.Put(v)
+}
+
+var (
+	assistantMessages recyclePool[AssistantMessage]
+	userMessages      recyclePool[UserMessage]
+	textBlocks        recyclePool[TextBlock]
+	thinkingBlocks    recyclePool[ThinkingBlock]
+	toolUseBlocks     recyclePool[ToolUseBlock]
+	toolResultBlocks  recyclePool[ToolResultBlock]
+)
+
+// AcquireAssistantMessage returns an AssistantMessage whose Release returns
+// it, and its recycled blocks, to a pool. Its Content keeps the capacity of
+// its previous use.
+func AcquireAssistantMessage() *AssistantMessage {
+	m := assistantMessages.get()
+	m.recycled = true
+	return m
+}
+
+// AcquireUserMessage returns a UserMessage whose Release returns it, and its
+// recycled blocks, to a pool.
+func AcquireUserMessage() *UserMessage {
+	m := userMessages.get()
+	m.recycled = true
+	return m
+}
+
+// AcquireTextBlock returns a TextBlock released along with its message.
+func AcquireTextBlock() *TextBlock {
+	b := textBlocks.get()
+	b.recycled = true
+	return b
+}
+
+// AcquireThinkingBlock returns a ThinkingBlock released along with its message.
+func AcquireThinkingBlock() *ThinkingBlock {
+	b := thinkingBlocks.get()
+	b.recycled = true
+	return b
+}
+
+// AcquireToolUseBlock returns a ToolUseBlock released along with its message.
+func AcquireToolUseBlock() *ToolUseBlock {
+	b := toolUseBlocks.get()
+	b.recycled = true
+	return b
+}
+
+// AcquireToolResultBlock returns a ToolResultBlock released along with its
+// message.
+func AcquireToolResultBlock() *ToolResultBlock {
+	b := toolResultBlocks.get()
+	b.recycled = true
+	return b
+}
+
+// Release returns a recycled message and its blocks to their pools. It does
+// nothing unless message recycling is enabled. After Release, neither the
+// message nor any of its blocks may be used; strings copied out of them stay
+// valid. Release must be called at most once.
+func (m *AssistantMessage) Release() {
+	if !m.recycled {
+		return
+	}
+	content := releaseBlocks(m.Content)
+	*m = AssistantMessage{Content: content}
+	assistantMessages.put(m)
+}
+
+// Release returns a recycled message and its blocks to their pools, with the
+// same rules as AssistantMessage.Release.
+func (m *UserMessage) Release() {
+	if !m.recycled {
+		return
+	}
+	if blocks, ok := m.Content.([]ContentBlock); ok {
+		releaseBlocks(blocks)
+	}
+	*m = UserMessage{}
+	userMessages.put(m)
+}
+
+// Release does nothing: system messages are never recycled.
+func (m *SystemMessage) Release() {}
+
+// Release does nothing: result messages are never recycled.
+func (m *ResultMessage) Release() {}
+
+// releaseBlocks returns the recycled blocks to their pools and returns the
+// emptied slice for reuse.
+func releaseBlocks(blocks []ContentBlock) []ContentBlock {
+	for i, block := range blocks {
+		switch b := block.(type) {
+		case *TextBlock:
+			if b.recycled {
+				*b = TextBlock{}
+				textBlocks.put(b)
+			}
+		case *ThinkingBlock:
+			if b.recycled {
+				*b = ThinkingBlock{}
+				thinkingBlocks.put(b)
+			}
+		case *ToolUseBlock:
+			if b.recycled {
+				*b = ToolUseBlock{}
+				toolUseBlocks.put(b)
+			}
+		case *ToolResultBlock:
+			if b.recycled {
+				*b = ToolResultBlock{}
+				toolResultBlocks.put(b)
+			}
+		}
+		blocks[i] = nil
+	}
+	return blocks[:0]
+}
+
+// Detach returns a message that stays valid after msg is released: msg
+// itself unless it is recycled, otherwise a copy sharing no recycled
+// structs with it. Strings, maps and other values are shared.
+func Detach(msg Message) Message {
+	switch m := msg.(type) {
+	case *AssistantMessage:
+		if !m.recycled {
+			return m
+		}
+		detached := *m
+		detached.recycled = false
+		detached.Content = detachBlocks(m.Content)
+		return &detached
+	case *UserMessage:
+		if !m.recycled {
+			return m
+		}
+		detached := *m
+		detached.recycled = false
+		if blocks, ok := m.Content.([]ContentBlock); ok {
+			detached.Content = detachBlocks(blocks)
+		}
+		return &detached
+	}
+	return msg
+}
+
+// detachBlocks copies blocks into a new slice, replacing recycled blocks
+// with copies that are not.
+func detachBlocks(blocks []ContentBlock) []ContentBlock {
+	detached := make([]ContentBlock, len(blocks))
+	for i, block := range blocks {
+		switch b := block.(type) {
+		case *TextBlock:
+			c := *b
+			c.recycled = false
+			detached[i] = &c
+		case *ThinkingBlock:
+			c := *b
+			c.recycled = false
+			detached[i] = &c
+		case *ToolUseBlock:
+			c := *b
+			c.recycled = false
+			detached[i] = &c
+		case *ToolResultBlock:
+			c := *b
+			c.recycled = false
+			detached[i] = &c
+		default:
+			detached[i] = block
+		}
+	}
+	return detached
+}
@@ -0,0 +1,116 @@
+package shared
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestReleaseWithoutRecyclingIsNoOp(t *testing.T) {
+	block := &TextBlock{Text: "hello"}
+	msg := &AssistantMessage{Content: []ContentBlock{block}, Model: "model"}
+	msg.Release()
+	msg.Release()
+
+	if msg.Model != "model" || len(msg.Content) != 1 || block.Text != "hello" {
+		t.Errorf("Expected a non-recycled message to be untouched, got %+v", msg)
+	}
+
+	user := &UserMessage{Content: []ContentBlock{&ToolResultBlock{ToolUseID: "tool-1"}}}
+	user.Release()
+	if blocks := user.Content.([]ContentBlock); blocks[0].(*ToolResultBlock).ToolUseID != "tool-1" {
+		t.Errorf("Expected a non-recycled user message to be untouched, got %+v", user)
+	}
+}
+
+func TestReleaseWithoutRecyclingIsRaceFree(t *testing.T) {
+	msg := &AssistantMessage{
+		Content: []ContentBlock{&TextBlock{Text: "hello"}, &ToolUseBlock{ToolUseID: "tool-1", Name: "Read"}},
+		Model:   "model",
+	}
+
+	// Readers sharing a message may each call Release when recycling is off
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for _, block := range msg.Content {
+					_ = block.BlockType()
+				}
+				msg.Release()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if msg.Content[0].(*TextBlock).Text != "hello" {
+		t.Error("Expected content to survive concurrent Release calls")
+	}
+}
+
+func TestReleaseResetsRecycledMessages(t *testing.T) {
+	isError := true
+	msg := AcquireAssistantMessage()
+	text := AcquireTextBlock()
+	text.Text = "hello"
+	result := AcquireToolResultBlock()
+	result.ToolUseID = "tool-1"
+	result.IsError = &isError
+	msg.Content = append(msg.Content, text, result)
+	msg.Model = "model"
+
+	copied := text.Text
+	msg.Release()
+
+	if copied != "hello" {
+		t.Errorf("Expected copied strings to stay valid, got %q", copied)
+	}
+	if msg.Model != "" || len(msg.Content) != 0 || text.Text != "" || result.IsError != nil {
+		t.Errorf("Expected released values to be reset, got %+v %+v %+v", msg, text, result)
+	}
+
+	// Acquired values never carry data from an earlier use
+	again := AcquireAssistantMessage()
+	if again.Model != "" || len(again.Content) != 0 || !again.recycled {
+		t.Errorf("Expected a reset recycled message, got %+v", again)
+	}
+	again.Release()
+
+	user := AcquireUserMessage()
+	user.Content = []ContentBlock{AcquireToolUseBlock(), AcquireThinkingBlock()}
+	user.Release()
+	if user.Content != nil {
+		t.Errorf("Expected released user message to be reset, got %+v", user)
+	}
+}
+
+func TestDetach(t *testing.T) {
+	plain := &AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "plain"}}}
+	if Detach(plain) != Message(plain) {
+		t.Error("Expected a message that is not recycled to be returned as is")
+	}
+
+	msg := AcquireAssistantMessage()
+	text := AcquireTextBlock()
+	text.Text = "hello"
+	msg.Content = append(msg.Content, text)
+	msg.Model = "model"
+	detached := Detach(msg).(*AssistantMessage)
+	msg.Release()
+
+	if detached.Model != "model" || len(detached.Content) != 1 || detached.Content[0].(*TextBlock).Text != "hello" {
+		t.Errorf("Expected the detached copy to survive Release, got %+v", detached)
+	}
+	detached.Release()
+
+	user := AcquireUserMessage()
+	result := AcquireToolResultBlock()
+	result.ToolUseID = "tool-1"
+	user.Content = []ContentBlock{result}
+	detachedUser := Detach(user).(*UserMessage)
+	user.Release()
+	if blocks := detachedUser.Content.([]ContentBlock); blocks[0].(*ToolResultBlock).ToolUseID != "tool-1" {
+		t.Errorf("Expected the detached user message to survive Release, got %+v", detachedUser)
+	}
+}
@@ -0,0 +1,104 @@
+package shared
+
+import "sync"
+
+// Stream issue types reported by the Sequencer.
+const (
+	// StreamIssueMessageBeforeInit indicates a conversation message arrived
+	// before the CLI's init message.
+	StreamIssueMessageBeforeInit = "message_before_init"
+	// StreamIssueDuplicateResult indicates a second ResultMessage arrived
+	// for a turn that had already ended.
+	StreamIssueDuplicateResult = "duplicate_result"
+)
+
+// Sequencer enforces the ordering guarantees of the CLI's output:
+//
+//   - The SystemMessage with subtype "init" arrives before any assistant,
+//     user or result message. Other system messages may precede it.
+//   - A ToolResultBlock arrives after the ToolUseBlock it answers.
+//   - Each turn ends with exactly one ResultMessage: a turn starts with
+//     the first assistant or user message after the previous result.
+//
+// Unlike the StreamValidator, which records what it finds and lets the
+// stream continue, the Sequencer is meant to stop the stream at the first
+// violation.
+type Sequencer struct {
+	mu       sync.Mutex
+	init     bool
+	turnOpen bool
+	results  int
+	toolUses map[string]bool
+}
+
+// NewSequencer creates a Sequencer for a new stream.
+func NewSequencer() *Sequencer {
+	return &Sequencer{toolUses: make(map[string]bool)}
+}
+
+// Check records msg and returns the first guarantee it violates, or nil.
+func (s *Sequencer) Check(msg Message) *StreamIntegrityError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch m := msg.(type) {
+	case *SystemMessage:
+		if m.Subtype == SystemSubtypeInit {
+			s.init = true
+		}
+		return nil
+	case *AssistantMessage:
+		if err := s.openTurn(); err != nil {
+			return err
+		}
+		for _, block := range m.Content {
+			if toolUse, ok := block.(*ToolUseBlock); ok {
+				s.toolUses[toolUse.ToolUseID] = true
+			}
+		}
+	case *UserMessage:
+		if err := s.openTurn(); err != nil {
+			return err
+		}
+		blocks, _ := m.Content.([]ContentBlock)
+		for _, block := range blocks {
+			if result, ok := block.(*ToolResultBlock); ok && !s.toolUses[result.ToolUseID] {
+				return NewStreamIntegrityError(StreamIssue{
+					Type:        StreamIssueResultBeforeUse,
+					Description: "Tool result arrived before its tool use",
+					ToolUseID:   result.ToolUseID,
+				})
+			}
+		}
+	case *ResultMessage:
+		if !s.init {
+			return beforeInit()
+		}
+		if !s.turnOpen && s.results > 0 {
+			return NewStreamIntegrityError(StreamIssue{
+				Type:        StreamIssueDuplicateResult,
+				Description: "Result arrived for a turn that had already ended",
+			})
+		}
+		s.turnOpen = false
+		s.results++
+	}
+	return nil
+}
+
+// openTurn checks that a conversation message may arrive and marks the
+// current turn as started. Must be called with s.mu held.
+func (s *Sequencer) openTurn() *StreamIntegrityError {
+	if !s.init {
+		return beforeInit()
+	}
+	s.turnOpen = true
+	return nil
+}
+
+func beforeInit() *StreamIntegrityError {
+	return NewStreamIntegrityError(StreamIssue{
+		Type:        StreamIssueMessageBeforeInit,
+		Description: "Message arrived before the init message",
+	})
+}
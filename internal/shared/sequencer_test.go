@@ -0,0 +1,80 @@
+package shared
+
+import "testing"
+
+// TestSequencerGuarantees checks each ordering guarantee documented on
+// Sequencer and WithStrictOrdering.
+func TestSequencerGuarantees(t *testing.T) {
+	initMsg := &SystemMessage{Subtype: SystemSubtypeInit}
+	text := &AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "hi"}}}
+	toolUse := &AssistantMessage{Content: []ContentBlock{&ToolUseBlock{ToolUseID: "tool_1", Name: "Read"}}}
+	toolResult := &UserMessage{Content: []ContentBlock{&ToolResultBlock{ToolUseID: "tool_1", Content: "ok"}}}
+	result := &ResultMessage{Subtype: "success"}
+
+	tests := []struct {
+		name     string
+		messages []Message
+		// wantIssue is the issue of the last message, or "" for none
+		wantIssue string
+	}{
+		{
+			name:     "ordered turns",
+			messages: []Message{initMsg, toolUse, toolResult, text, result, initMsg, text, result},
+		},
+		{
+			name:     "system messages before init",
+			messages: []Message{&SystemMessage{Subtype: "hook_response"}, initMsg, text, result},
+		},
+		{
+			name:     "result without messages",
+			messages: []Message{initMsg, result},
+		},
+		{
+			name:      "assistant message before init",
+			messages:  []Message{text},
+			wantIssue: StreamIssueMessageBeforeInit,
+		},
+		{
+			name:      "result before init",
+			messages:  []Message{result},
+			wantIssue: StreamIssueMessageBeforeInit,
+		},
+		{
+			name:      "tool result before its use",
+			messages:  []Message{initMsg, toolResult},
+			wantIssue: StreamIssueResultBeforeUse,
+		},
+		{
+			name:      "two results for one turn",
+			messages:  []Message{initMsg, text, result, result},
+			wantIssue: StreamIssueDuplicateResult,
+		},
+		{
+			name:      "second result after init only",
+			messages:  []Message{initMsg, text, result, initMsg, result},
+			wantIssue: StreamIssueDuplicateResult,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sequencer := NewSequencer()
+			last := len(test.messages) - 1
+			for i, msg := range test.messages[:last] {
+				if err := sequencer.Check(msg); err != nil {
+					t.Fatalf("Message %d: unexpected violation %v", i, err)
+				}
+			}
+
+			err := sequencer.Check(test.messages[last])
+			switch {
+			case test.wantIssue == "" && err != nil:
+				t.Errorf("Expected no violation, got %v", err)
+			case test.wantIssue != "" && err == nil:
+				t.Errorf("Expected a %s violation", test.wantIssue)
+			case test.wantIssue != "" && err.Issue.Type != test.wantIssue:
+				t.Errorf("Expected a %s violation, got %s", test.wantIssue, err.Issue.Type)
+			}
+		})
+	}
+}
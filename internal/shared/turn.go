@@ -0,0 +1,51 @@
+package shared
+
+import (
+	"strings"
+	"time"
+)
+
+// Turn is one exchange with the agent: the prompt and everything the CLI
+// sent in response, up to and including the turn's ResultMessage.
+type Turn struct {
+	// Number counts the client's turns from 1.
+	Number int
+	// Prompt is the user message that started the turn, as sent after
+	// prompt interceptors and context blocks. It is nil for prompts the
+	// client did not send itself, such as those passed to Connect or
+	// QueryStream.
+	Prompt *UserMessage
+	// IdempotencyKey is the key the turn's query was sent with, set with
+	// WithIdempotencyKey.
+	IdempotencyKey string
+	// Assistant holds the agent's messages in the order received,
+	// including those of subagents.
+	Assistant []*AssistantMessage
+	// ToolCalls holds the tool uses requested during the turn, in order.
+	ToolCalls []ToolCall
+	// Result is the ResultMessage that ended the turn.
+	Result *ResultMessage
+	// Started is when the prompt was sent or, without one, when the first
+	// message of the turn arrived. Ended is when the result arrived.
+	Started time.Time
+	Ended   time.Time
+}
+
+// ToolCall is a tool use paired with its result.
+type ToolCall struct {
+	Use *ToolUseBlock
+	// Result is nil when the turn ended before the tool returned.
+	Result *ToolResultBlock
+}
+
+// Text returns the text of the turn's assistant messages, joined by
+// newlines. Subagent messages are left out.
+func (t *Turn) Text() string {
+	var parts []string
+	for _, msg := range t.Assistant {
+		if msg.ParentToolUseID == nil {
+			parts = append(parts, msg.texts()...)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -13,8 +13,32 @@ type StreamValidator struct {
 	hasResultMessage bool            // Whether we've seen a result message
 	streamEnded      bool            // Whether stream has ended
 	issues           []StreamIssue   // Validation issues found
+
+	// Sequence tracking (optional, see EnableSequenceTracking)
+	sequenceTracking bool
+	seenMessageUUIDs map[string]bool // UUIDs of user messages already delivered
+	messagesTracked  int             // Messages seen while sequence tracking
+	integrityIssues  int             // Issues found by sequence tracking
 }
 
+// Stream issue types reported by sequence tracking.
+const (
+	// StreamIssueDuplicateToolUse indicates a tool use ID was delivered twice.
+	StreamIssueDuplicateToolUse = "duplicate_tool_use"
+	// StreamIssueDuplicateToolResult indicates a tool result was delivered twice.
+	StreamIssueDuplicateToolResult = "duplicate_tool_result"
+	// StreamIssueResultBeforeUse indicates a tool use arrived after its result.
+	StreamIssueResultBeforeUse = "tool_result_before_use"
+	// StreamIssueUnknownParent indicates a message references a parent tool
+	// use that was never delivered.
+	StreamIssueUnknownParent = "unknown_parent_tool_use"
+	// StreamIssueAfterParentResult indicates a subagent message arrived after
+	// its parent tool use had already completed.
+	StreamIssueAfterParentResult = "message_after_parent_result"
+	// StreamIssueDuplicateMessage indicates a message UUID was delivered twice.
+	StreamIssueDuplicateMessage = "duplicate_message"
+)
+
 // StreamIssue represents a validation issue found in the stream.
 type StreamIssue struct {
 	Type        string `json:"type"`                  // "missing_tool_result", "extra_tool_result", etc.
@@ -29,6 +53,10 @@ type StreamStats struct {
 	PendingTools   []string `json:"pending_tools"`   // Tool IDs still awaiting results
 	HasResult      bool     `json:"has_result"`      // Whether result message was seen
 	StreamEnded    bool     `json:"stream_ended"`    // Whether stream has ended
+
+	// Populated only when sequence tracking is enabled
+	MessagesTracked int `json:"messages_tracked"` // Messages checked for ordering
+	IntegrityIssues int `json:"integrity_issues"` // Lost, duplicated or reordered messages
 }
 
 // NewStreamValidator creates a new stream validator.
@@ -41,26 +69,62 @@ func NewStreamValidator() *StreamValidator {
 	}
 }
 
+// EnableSequenceTracking turns on ordering checks that detect messages
+// lost, duplicated or delivered out of order: tool uses arriving after their
+// results, repeated tool use IDs or message UUIDs, and subagent messages
+// whose parent_tool_use_id is unknown or already completed.
+func (v *StreamValidator) EnableSequenceTracking() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.sequenceTracking = true
+	if v.seenMessageUUIDs == nil {
+		v.seenMessageUUIDs = make(map[string]bool)
+	}
+}
+
 // TrackMessage processes a message and updates validation state.
 func (v *StreamValidator) TrackMessage(msg Message) {
+	v.TrackAndCheck(msg)
+}
+
+// TrackAndCheck processes a message like TrackMessage and returns the
+// validation issues it revealed.
+func (v *StreamValidator) TrackAndCheck(msg Message) []StreamIssue {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
+	before := len(v.issues)
+	if v.sequenceTracking {
+		v.messagesTracked++
+	}
+
 	switch m := msg.(type) {
 	case *AssistantMessage:
+		v.checkParent(m.ParentToolUseID)
+
 		// Track tool use requests
 		for _, block := range m.Content {
 			if toolUse, ok := block.(*ToolUseBlock); ok {
+				v.checkToolUseOrder(toolUse.ToolUseID)
 				v.toolsRequested[toolUse.ToolUseID] = true
-				v.pendingToolsSet[toolUse.ToolUseID] = true
+				if !v.toolsReceived[toolUse.ToolUseID] {
+					v.pendingToolsSet[toolUse.ToolUseID] = true
+				}
 			}
 		}
 
 	case *UserMessage:
+		v.checkDuplicateMessage(m.UUID)
+		v.checkParent(m.ParentToolUseID)
+
 		// Track tool results
 		if blocks, ok := m.Content.([]ContentBlock); ok {
 			for _, block := range blocks {
 				if toolResult, ok := block.(*ToolResultBlock); ok {
+					if v.sequenceTracking && v.toolsReceived[toolResult.ToolUseID] {
+						v.addIntegrityIssue(StreamIssueDuplicateToolResult,
+							"Tool result was delivered more than once", toolResult.ToolUseID)
+					}
 					v.toolsReceived[toolResult.ToolUseID] = true
 					delete(v.pendingToolsSet, toolResult.ToolUseID)
 
@@ -79,6 +143,69 @@ func (v *StreamValidator) TrackMessage(msg Message) {
 	case *ResultMessage:
 		v.hasResultMessage = true
 	}
+
+	if len(v.issues) == before {
+		return nil
+	}
+	found := make([]StreamIssue, len(v.issues)-before)
+	copy(found, v.issues[before:])
+	return found
+}
+
+// checkToolUseOrder reports tool uses that repeat or follow their result.
+// Must be called with v.mu held.
+func (v *StreamValidator) checkToolUseOrder(toolUseID string) {
+	switch {
+	case !v.sequenceTracking:
+	case v.toolsReceived[toolUseID]:
+		v.addIntegrityIssue(StreamIssueResultBeforeUse,
+			"Tool use arrived after its result", toolUseID)
+	case v.toolsRequested[toolUseID]:
+		v.addIntegrityIssue(StreamIssueDuplicateToolUse,
+			"Tool use was delivered more than once", toolUseID)
+	}
+}
+
+// checkParent reports subagent messages whose parent tool use is unknown or
+// already completed. Must be called with v.mu held.
+func (v *StreamValidator) checkParent(parentToolUseID *string) {
+	if !v.sequenceTracking || parentToolUseID == nil || *parentToolUseID == "" {
+		return
+	}
+	parent := *parentToolUseID
+	switch {
+	case !v.toolsRequested[parent]:
+		v.addIntegrityIssue(StreamIssueUnknownParent,
+			"Message references a parent tool use that was never received", parent)
+	case v.toolsReceived[parent]:
+		v.addIntegrityIssue(StreamIssueAfterParentResult,
+			"Message arrived after its parent tool use completed", parent)
+	}
+}
+
+// checkDuplicateMessage reports user messages delivered more than once.
+// Must be called with v.mu held.
+func (v *StreamValidator) checkDuplicateMessage(uuid *string) {
+	if !v.sequenceTracking || uuid == nil || *uuid == "" {
+		return
+	}
+	if v.seenMessageUUIDs[*uuid] {
+		v.addIntegrityIssue(StreamIssueDuplicateMessage,
+			"Message "+*uuid+" was delivered more than once", "")
+		return
+	}
+	v.seenMessageUUIDs[*uuid] = true
+}
+
+// addIntegrityIssue records an issue found by sequence tracking.
+// Must be called with v.mu held.
+func (v *StreamValidator) addIntegrityIssue(issueType, description, toolUseID string) {
+	v.integrityIssues++
+	v.issues = append(v.issues, StreamIssue{
+		Type:        issueType,
+		Description: description,
+		ToolUseID:   toolUseID,
+	})
 }
 
 // MarkStreamEnd marks the stream as ended and performs final validation.
@@ -128,11 +255,13 @@ func (v *StreamValidator) GetStats() StreamStats {
 	}
 
 	return StreamStats{
-		ToolsRequested: len(v.toolsRequested),
-		ToolsReceived:  len(v.toolsReceived),
-		PendingTools:   pendingTools,
-		HasResult:      v.hasResultMessage,
-		StreamEnded:    v.streamEnded,
+		ToolsRequested:  len(v.toolsRequested),
+		ToolsReceived:   len(v.toolsReceived),
+		PendingTools:    pendingTools,
+		HasResult:       v.hasResultMessage,
+		StreamEnded:     v.streamEnded,
+		MessagesTracked: v.messagesTracked,
+		IntegrityIssues: v.integrityIssues,
 	}
 }
 
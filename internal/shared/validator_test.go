@@ -316,3 +316,131 @@ func TestStreamValidator_PartialCompletion(t *testing.T) {
 		t.Errorf("Expected 1 pending tool, got %d", len(stats.PendingTools))
 	}
 }
+
+func TestStreamValidator_SequenceTracking(t *testing.T) {
+	parent := "task_1"
+	unknownParent := "task_lost"
+	uuid := "msg-1"
+
+	toolUse := func(id string, parentID *string) *AssistantMessage {
+		return &AssistantMessage{
+			Content:         []ContentBlock{&ToolUseBlock{ToolUseID: id, Name: "Task"}},
+			ParentToolUseID: parentID,
+		}
+	}
+	toolResult := func(id string, parentID *string) *UserMessage {
+		return &UserMessage{
+			Content:         []ContentBlock{&ToolResultBlock{ToolUseID: id, Content: "ok"}},
+			ParentToolUseID: parentID,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		messages   []Message
+		wantIssues []string
+	}{
+		{
+			name: "ordered subagent chain",
+			messages: []Message{
+				toolUse(parent, nil),
+				toolUse("sub_1", &parent),
+				toolResult("sub_1", &parent),
+				toolResult(parent, nil),
+				&ResultMessage{},
+			},
+		},
+		{
+			name:       "duplicate tool use",
+			messages:   []Message{toolUse("tool_1", nil), toolUse("tool_1", nil)},
+			wantIssues: []string{StreamIssueDuplicateToolUse},
+		},
+		{
+			name:       "duplicate tool result",
+			messages:   []Message{toolUse("tool_1", nil), toolResult("tool_1", nil), toolResult("tool_1", nil)},
+			wantIssues: []string{StreamIssueDuplicateToolResult},
+		},
+		{
+			name:       "result before use",
+			messages:   []Message{toolResult("tool_1", nil), toolUse("tool_1", nil)},
+			wantIssues: []string{"extra_tool_result", StreamIssueResultBeforeUse},
+		},
+		{
+			name:       "unknown parent",
+			messages:   []Message{toolUse("sub_1", &unknownParent)},
+			wantIssues: []string{StreamIssueUnknownParent},
+		},
+		{
+			name: "message after parent result",
+			messages: []Message{
+				toolUse(parent, nil),
+				toolResult(parent, nil),
+				toolUse("sub_1", &parent),
+			},
+			wantIssues: []string{StreamIssueAfterParentResult},
+		},
+		{
+			name: "duplicate message",
+			messages: []Message{
+				&UserMessage{Content: "hi", UUID: &uuid},
+				&UserMessage{Content: "hi", UUID: &uuid},
+			},
+			wantIssues: []string{StreamIssueDuplicateMessage},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			validator := NewStreamValidator()
+			validator.EnableSequenceTracking()
+
+			var found []string
+			for _, msg := range test.messages {
+				for _, issue := range validator.TrackAndCheck(msg) {
+					found = append(found, issue.Type)
+				}
+			}
+
+			if len(found) != len(test.wantIssues) {
+				t.Fatalf("Expected issues %v, got %v", test.wantIssues, found)
+			}
+			for i := range found {
+				if found[i] != test.wantIssues[i] {
+					t.Errorf("Issue %d: expected %q, got %q", i, test.wantIssues[i], found[i])
+				}
+			}
+
+			stats := validator.GetStats()
+			if stats.MessagesTracked != len(test.messages) {
+				t.Errorf("Expected %d messages tracked, got %d", len(test.messages), stats.MessagesTracked)
+			}
+			wantIntegrity := 0
+			for _, issue := range test.wantIssues {
+				if issue != "extra_tool_result" {
+					wantIntegrity++
+				}
+			}
+			if stats.IntegrityIssues != wantIntegrity {
+				t.Errorf("Expected %d integrity issues, got %d", wantIntegrity, stats.IntegrityIssues)
+			}
+		})
+	}
+}
+
+func TestStreamValidator_SequenceTrackingDisabled(t *testing.T) {
+	validator := NewStreamValidator()
+	parent := "task_lost"
+
+	validator.TrackMessage(&AssistantMessage{
+		Content:         []ContentBlock{&ToolUseBlock{ToolUseID: "tool_1"}},
+		ParentToolUseID: &parent,
+	})
+	validator.TrackMessage(&AssistantMessage{Content: []ContentBlock{&ToolUseBlock{ToolUseID: "tool_1"}}})
+
+	if issues := validator.GetIssues(); len(issues) != 0 {
+		t.Errorf("Expected no issues without sequence tracking, got %+v", issues)
+	}
+	if stats := validator.GetStats(); stats.MessagesTracked != 0 || stats.IntegrityIssues != 0 {
+		t.Errorf("Expected no sequence stats, got %+v", stats)
+	}
+}
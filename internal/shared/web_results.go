@@ -0,0 +1,226 @@
+package shared
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// WebSearchResult is the outcome of a WebSearch tool call.
+type WebSearchResult struct {
+	Query   string
+	Results []WebSearchHit
+	// Summary is the text the search returned alongside the links.
+	Summary string
+}
+
+// WebSearchHit is one page a web search found. Snippet is only set when
+// the search provided one.
+type WebSearchHit struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// WebFetchResult is the outcome of a WebFetch tool call. Content is the
+// page as the tool processed it for the agent, not the raw page. Fields the
+// result did not carry are zero; results read from a ToolResultBlock only
+// carry Content, or the redirect for a redirected fetch.
+type WebFetchResult struct {
+	URL        string
+	StatusCode int
+	StatusText string
+	Bytes      int
+	Content    string
+	// RedirectURL is set when the fetch stopped at a redirect to another
+	// host, which the agent has to fetch itself.
+	RedirectURL string
+}
+
+const (
+	webSearchHeader    = "Web search results for query: "
+	webSearchLinks     = "Links: "
+	webFetchRedirect   = "REDIRECT DETECTED"
+	webFetchOriginal   = "Original URL: "
+	webFetchRedirectTo = "Redirect URL: "
+	webFetchStatus     = "Status: "
+)
+
+// ParseWebSearchResult reads a WebSearch result from the tool's structured
+// response, as PostToolUse hooks receive it, or from the text of its tool
+// result. It reports false when v has neither shape.
+func ParseWebSearchResult(v any) (WebSearchResult, bool) {
+	if response, ok := v.(map[string]any); ok {
+		return parseWebSearchResponse(response)
+	}
+	text, ok := toolResultText(v)
+	if !ok || !strings.HasPrefix(text, webSearchHeader) {
+		return WebSearchResult{}, false
+	}
+
+	var result WebSearchResult
+	var summary []string
+	for i, line := range strings.Split(text, "\n") {
+		switch {
+		case i == 0:
+			result.Query = unquote(strings.TrimPrefix(line, webSearchHeader))
+		case strings.HasPrefix(line, webSearchLinks):
+			var hits []WebSearchHit
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, webSearchLinks)), &hits); err == nil {
+				result.Results = append(result.Results, hits...)
+				continue
+			}
+			summary = append(summary, line)
+		default:
+			summary = append(summary, line)
+		}
+	}
+	result.Summary = strings.TrimSpace(strings.Join(summary, "\n"))
+	return result, true
+}
+
+// parseWebSearchResponse reads the structured response, whose results mix
+// link lists and text.
+func parseWebSearchResponse(response map[string]any) (WebSearchResult, bool) {
+	items, ok := response["results"].([]any)
+	if !ok {
+		return WebSearchResult{}, false
+	}
+	var result WebSearchResult
+	result.Query, _ = response["query"].(string)
+	var summary []string
+	for _, item := range items {
+		switch item := item.(type) {
+		case string:
+			summary = append(summary, strings.TrimSpace(item))
+		case map[string]any:
+			links, _ := item["content"].([]any)
+			for _, link := range links {
+				if hit, ok := webSearchHit(link); ok {
+					result.Results = append(result.Results, hit)
+				}
+			}
+		}
+	}
+	result.Summary = strings.TrimSpace(strings.Join(summary, "\n\n"))
+	return result, true
+}
+
+func webSearchHit(v any) (WebSearchHit, bool) {
+	link, ok := v.(map[string]any)
+	if !ok {
+		return WebSearchHit{}, false
+	}
+	var hit WebSearchHit
+	hit.Title, _ = link["title"].(string)
+	hit.URL, _ = link["url"].(string)
+	hit.Snippet, _ = link["snippet"].(string)
+	if hit.Snippet == "" {
+		hit.Snippet, _ = link["description"].(string)
+	}
+	return hit, hit.URL != ""
+}
+
+// ParseWebFetchResult reads a WebFetch result from the tool's structured
+// response, as PostToolUse hooks receive it, or from the text of its tool
+// result. It reports false when v has neither shape.
+func ParseWebFetchResult(v any) (WebFetchResult, bool) {
+	if response, ok := v.(map[string]any); ok {
+		content, ok := response["result"].(string)
+		if !ok {
+			return WebFetchResult{}, false
+		}
+		result := WebFetchResult{Content: content}
+		result.URL, _ = response["url"].(string)
+		result.StatusText, _ = response["codeText"].(string)
+		if code, ok := response["code"].(float64); ok {
+			result.StatusCode = int(code)
+		}
+		if size, ok := response["bytes"].(float64); ok {
+			result.Bytes = int(size)
+		}
+		if strings.HasPrefix(content, webFetchRedirect) {
+			parseWebFetchRedirect(content, &result)
+		}
+		return result, true
+	}
+
+	text, ok := toolResultText(v)
+	if !ok {
+		return WebFetchResult{}, false
+	}
+	result := WebFetchResult{Content: text}
+	if strings.HasPrefix(text, webFetchRedirect) {
+		parseWebFetchRedirect(text, &result)
+	}
+	return result, true
+}
+
+// parseWebFetchRedirect reads the URLs and status of the notice the tool
+// returns instead of following a redirect to another host.
+func parseWebFetchRedirect(text string, result *WebFetchResult) {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, webFetchOriginal):
+			result.URL = strings.TrimPrefix(line, webFetchOriginal)
+		case strings.HasPrefix(line, webFetchRedirectTo):
+			result.RedirectURL = strings.TrimPrefix(line, webFetchRedirectTo)
+		case strings.HasPrefix(line, webFetchStatus):
+			code, text, _ := strings.Cut(strings.TrimPrefix(line, webFetchStatus), " ")
+			if n, err := strconv.Atoi(code); err == nil {
+				result.StatusCode = n
+				result.StatusText = text
+			}
+		}
+	}
+}
+
+// WebSearchResult reads the block as the result of a WebSearch call. It
+// reports false for failed calls and other tools' results.
+func (b *ToolResultBlock) WebSearchResult() (WebSearchResult, bool) {
+	if b.Failed() {
+		return WebSearchResult{}, false
+	}
+	return ParseWebSearchResult(b.Content)
+}
+
+// WebFetchResult reads the block as the result of a WebFetch call. The
+// block does not name its tool, so any successful text result reads as a
+// fetched page; check the matching ToolUseBlock first.
+func (b *ToolResultBlock) WebFetchResult() (WebFetchResult, bool) {
+	if b.Failed() {
+		return WebFetchResult{}, false
+	}
+	return ParseWebFetchResult(b.Content)
+}
+
+// toolResultText returns the text of tool result content: a string, or the
+// joined text blocks of structured content.
+func toolResultText(content any) (string, bool) {
+	switch content := content.(type) {
+	case string:
+		return content, true
+	case []any:
+		var parts []string
+		for _, item := range content {
+			block, ok := item.(map[string]any)
+			if !ok || block["type"] != ContentBlockTypeText {
+				continue
+			}
+			if text, ok := block["text"].(string); ok {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n"), len(parts) > 0
+	}
+	return "", false
+}
+
+// unquote strips the quotes around a query, leaving unquoted text as is.
+func unquote(s string) string {
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
@@ -0,0 +1,134 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+)
+
+const webSearchText = `Web search results for query: "go generics"
+
+Links: [{"title":"Tutorial: Getting started with generics","url":"https://go.dev/doc/tutorial/generics"},{"title":"An Introduction To Generics","url":"https://go.dev/blog/intro-generics"}]
+
+Go added generics in 1.18.`
+
+func TestParseWebSearchResultText(t *testing.T) {
+	want := WebSearchResult{
+		Query: "go generics",
+		Results: []WebSearchHit{
+			{Title: "Tutorial: Getting started with generics", URL: "https://go.dev/doc/tutorial/generics"},
+			{Title: "An Introduction To Generics", URL: "https://go.dev/blog/intro-generics"},
+		},
+		Summary: "Go added generics in 1.18.",
+	}
+
+	result, ok := ParseWebSearchResult(webSearchText)
+	if !ok || !reflect.DeepEqual(result, want) {
+		t.Errorf("Expected %+v, got %+v (ok=%v)", want, result, ok)
+	}
+
+	blocks := []any{map[string]any{"type": ContentBlockTypeText, "text": webSearchText}}
+	result, ok = ParseWebSearchResult(blocks)
+	if !ok || !reflect.DeepEqual(result, want) {
+		t.Errorf("Expected text blocks to parse, got %+v (ok=%v)", result, ok)
+	}
+
+	if _, ok := ParseWebSearchResult("file contents"); ok {
+		t.Error("Expected other text to be rejected")
+	}
+}
+
+func TestParseWebSearchResultResponse(t *testing.T) {
+	response := map[string]any{
+		"query": "go generics",
+		"results": []any{
+			map[string]any{
+				"tool_use_id": "srvtoolu_1",
+				"content": []any{
+					map[string]any{"title": "Generics", "url": "https://go.dev/blog/intro-generics", "snippet": "An introduction"},
+					map[string]any{"title": "No URL"},
+				},
+			},
+			"Go added generics in 1.18.\n",
+		},
+		"durationSeconds": 1.5,
+	}
+	result, ok := ParseWebSearchResult(response)
+	if !ok {
+		t.Fatal("Expected the response to parse")
+	}
+	want := []WebSearchHit{{Title: "Generics", URL: "https://go.dev/blog/intro-generics", Snippet: "An introduction"}}
+	if result.Query != "go generics" || !reflect.DeepEqual(result.Results, want) || result.Summary != "Go added generics in 1.18." {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	if _, ok := ParseWebSearchResult(map[string]any{"result": "page"}); ok {
+		t.Error("Expected a response without results to be rejected")
+	}
+}
+
+func TestParseWebFetchResult(t *testing.T) {
+	response := map[string]any{
+		"bytes":      float64(2048),
+		"code":       float64(200),
+		"codeText":   "OK",
+		"result":     "The page describes generics.",
+		"durationMs": float64(812),
+		"url":        "https://go.dev/blog/intro-generics",
+	}
+	want := WebFetchResult{
+		URL:        "https://go.dev/blog/intro-generics",
+		StatusCode: 200,
+		StatusText: "OK",
+		Bytes:      2048,
+		Content:    "The page describes generics.",
+	}
+	if result, ok := ParseWebFetchResult(response); !ok || result != want {
+		t.Errorf("Expected %+v, got %+v (ok=%v)", want, result, ok)
+	}
+
+	if result, ok := ParseWebFetchResult("The page describes generics."); !ok || result.Content != "The page describes generics." {
+		t.Errorf("Expected text content, got %+v (ok=%v)", result, ok)
+	}
+
+	if _, ok := ParseWebFetchResult(map[string]any{"query": "q"}); ok {
+		t.Error("Expected a response without a result to be rejected")
+	}
+	if _, ok := ParseWebFetchResult(42); ok {
+		t.Error("Expected non-text content to be rejected")
+	}
+}
+
+func TestParseWebFetchResultRedirect(t *testing.T) {
+	text := "REDIRECT DETECTED: The URL redirects to a different host.\n\n" +
+		"Original URL: http://golang.org/doc\n" +
+		"Redirect URL: https://go.dev/doc\n" +
+		"Status: 301 Moved Permanently\n\n" +
+		"To complete your request, fetch the redirect URL."
+	result, ok := ParseWebFetchResult(text)
+	if !ok {
+		t.Fatal("Expected the redirect to parse")
+	}
+	if result.URL != "http://golang.org/doc" || result.RedirectURL != "https://go.dev/doc" ||
+		result.StatusCode != 301 || result.StatusText != "Moved Permanently" || result.Content != text {
+		t.Errorf("Unexpected redirect result %+v", result)
+	}
+}
+
+func TestToolResultBlockWebResults(t *testing.T) {
+	failed := true
+	block := &ToolResultBlock{ToolUseID: "toolu_1", Content: webSearchText}
+	if result, ok := block.WebSearchResult(); !ok || len(result.Results) != 2 {
+		t.Errorf("Expected two search results, got %+v (ok=%v)", result, ok)
+	}
+	if result, ok := block.WebFetchResult(); !ok || result.Content != webSearchText {
+		t.Errorf("Expected the text as fetched content, got %+v (ok=%v)", result, ok)
+	}
+
+	block.IsError = &failed
+	if _, ok := block.WebSearchResult(); ok {
+		t.Error("Expected a failed result to be rejected")
+	}
+	if _, ok := block.WebFetchResult(); ok {
+		t.Error("Expected a failed result to be rejected")
+	}
+}
@@ -0,0 +1,244 @@
+package subprocess
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+// cassetteVersion is the format version written to new cassettes.
+const cassetteVersion = 1
+
+// cassette is the file a session is recorded to. The first interaction
+// holds what the CLI wrote before the first prompt, and each one after it
+// what the CLI wrote in answer to one prompt, as the raw stdout lines.
+type cassette struct {
+	Version      int           `json:"version"`
+	Interactions []interaction `json:"interactions"`
+}
+
+type interaction struct {
+	// Prompt is the hash of the user message, or empty before the first.
+	Prompt string   `json:"prompt,omitempty"`
+	Lines  []string `json:"lines"`
+}
+
+// Replaying reports whether options replay a cassette, in which case the
+// transport needs no CLI.
+func Replaying(options *shared.Options) bool {
+	if options == nil || options.CassettePath == "" {
+		return false
+	}
+	switch options.CassetteMode {
+	case shared.CassetteReplay:
+		return true
+	case shared.CassetteRecordOrReplay:
+		_, err := os.Stat(options.CassettePath)
+		return err == nil
+	}
+	return false
+}
+
+// recording reports whether options record a cassette. It is only asked
+// once replaying has been ruled out.
+func recording(options *shared.Options) bool {
+	return options != nil && options.CassettePath != "" &&
+		(options.CassetteMode == shared.CassetteRecord || options.CassetteMode == shared.CassetteRecordOrReplay)
+}
+
+// hashPrompt identifies a user message by the SHA-256 of its JSON
+// encoding. encoding/json sorts map keys, so equal messages hash equally.
+func hashPrompt(message any) (string, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash prompt: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// promptMessage is the user message a one-shot query's prompt stands for,
+// in the form the client sends, so both hash the same.
+func promptMessage(prompt string) map[string]any {
+	return map[string]any{"role": "user", "content": prompt}
+}
+
+// cassetteRecorder collects a session's stdout lines by prompt.
+type cassetteRecorder struct {
+	mu       sync.Mutex
+	path     string
+	cassette cassette
+}
+
+func newCassetteRecorder(path string) *cassetteRecorder {
+	return &cassetteRecorder{
+		path:     path,
+		cassette: cassette{Version: cassetteVersion, Interactions: []interaction{{Lines: []string{}}}},
+	}
+}
+
+// prompt starts the interaction answering the prompt with hash.
+func (r *cassetteRecorder) prompt(hash string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction{Prompt: hash, Lines: []string{}})
+}
+
+// line adds a stdout line to the current interaction.
+func (r *cassetteRecorder) line(line []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current := &r.cassette.Interactions[len(r.cassette.Interactions)-1]
+	current.Lines = append(current.Lines, string(line))
+}
+
+// save writes the cassette, replacing any earlier recording.
+func (r *cassetteRecorder) save() error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.WriteFile(r.path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// cassettePlayer hands out a recorded session's lines by prompt.
+type cassettePlayer struct {
+	mu       sync.Mutex
+	path     string
+	cassette cassette
+	played   []bool
+}
+
+func loadCassette(path string) (*cassettePlayer, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - Reading the caller's cassette is the point
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to decode cassette %s: %w", path, err)
+	}
+	if c.Version != cassetteVersion {
+		return nil, fmt.Errorf("cassette %s has unsupported version %d", path, c.Version)
+	}
+	return &cassettePlayer{path: path, cassette: c, played: make([]bool, len(c.Interactions))}, nil
+}
+
+// preamble returns the lines recorded before the first prompt.
+func (p *cassettePlayer) preamble() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.cassette.Interactions) == 0 || p.cassette.Interactions[0].Prompt != "" {
+		return nil
+	}
+	p.played[0] = true
+	return p.cassette.Interactions[0].Lines
+}
+
+// next returns the lines answering the prompt with hash. Recordings are
+// played in order, each once, so a repeated prompt gets each of its
+// answers in turn.
+func (p *cassettePlayer) next(hash string) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, recorded := range p.cassette.Interactions {
+		if !p.played[i] && recorded.Prompt == hash {
+			p.played[i] = true
+			return recorded.Lines, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %s: %s", shared.ErrCassetteMiss, p.path, hash)
+}
+
+// replayQueueSize is how many answers can wait to be written to the
+// replayed stdout.
+const replayQueueSize = 16
+
+// connectReplay connects to a recorded session instead of the CLI. The
+// recorded lines are written to a pipe that handleStdout reads as it would
+// the CLI's stdout, so they are parsed and validated the same way.
+func (t *Transport) connectReplay(ctx context.Context) error {
+	player, err := loadCassette(t.options.CassettePath)
+	if err != nil {
+		return err
+	}
+
+	queue := make(chan []string, replayQueueSize)
+	queue <- player.preamble()
+	if t.promptArg != nil {
+		hash, err := hashPrompt(promptMessage(*t.promptArg))
+		if err != nil {
+			return err
+		}
+		lines, err := player.next(hash)
+		if err != nil {
+			return err
+		}
+		// A one-shot CLI exits after its answer
+		queue <- lines
+		close(queue)
+	} else {
+		t.replayQueue = queue
+	}
+
+	reader, writer := io.Pipe()
+	go writeReplay(writer, queue)
+	t.player = player
+	t.stdout = reader
+	t.exit = nil
+
+	t.ctx, t.cancel = context.WithCancel(ctx)
+	t.msgChan = make(chan shared.Message, channelBufferSize)
+	t.errChan = make(chan error, channelBufferSize)
+	t.wg.Add(1)
+	go t.handleStdout()
+
+	t.connected = true
+	return nil
+}
+
+// sendReplay queues the recorded answer to message.
+func (t *Transport) sendReplay(ctx context.Context, message shared.StreamMessage) error {
+	if !t.connected || t.replayQueue == nil {
+		return fmt.Errorf("transport not connected")
+	}
+	hash, err := hashPrompt(message.Message)
+	if err != nil {
+		return err
+	}
+	lines, err := t.player.next(hash)
+	if err != nil {
+		return err
+	}
+	select {
+	case t.replayQueue <- lines:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// writeReplay writes queued lines to w until the queue closes, then
+// closes w. It stops early when the reading end is closed.
+func writeReplay(w *io.PipeWriter, queue <-chan []string) {
+	for lines := range queue {
+		for _, line := range lines {
+			if _, err := io.WriteString(w, line+"\n"); err != nil {
+				return
+			}
+		}
+	}
+	_ = w.Close()
+}
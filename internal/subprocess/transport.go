@@ -3,8 +3,10 @@ package subprocess
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -27,6 +29,11 @@ const (
 	terminationTimeoutSeconds = 5
 	// windowsOS is the GOOS value for Windows platform.
 	windowsOS = "windows"
+	// defaultMaxMessageSize is the default limit for a single stdout line (1MB).
+	defaultMaxMessageSize = 1024 * 1024
+	// stdoutReadBufferSize is the read buffer size for stdout; longer lines
+	// are accumulated across reads.
+	stdoutReadBufferSize = 64 * 1024
 )
 
 // Transport implements the Transport interface using subprocess communication.
@@ -44,18 +51,36 @@ type Transport struct {
 	mu        sync.RWMutex
 
 	// I/O streams
-	stdin  io.WriteCloser
-	stdout io.ReadCloser
-	stderr *os.File
+	stdin   io.WriteCloser
+	writeMu sync.Mutex // Keeps lines written to stdin whole
+	stdout  io.ReadCloser
+	stderr  *os.File
+
+	// Delivers stderr lines to options.StderrCallback, if set
+	stderrLines *stderrLineWriter
 
 	// Temporary files (cleaned up on Close)
 	mcpConfigFile *os.File // Temporary MCP config file
+	settingsFile  *os.File // Temporary settings file from SettingsJSON
 
 	// Message parsing
-	parser *parser.Parser
+	parser         *parser.Parser
+	maxMessageSize int
+	// spillLimit is the length above which strings read from stdout go to
+	// files instead of memory; 0 keeps them all in memory
+	spillLimit int
 
 	// Stream validation
 	validator *shared.StreamValidator
+	sequencer *shared.Sequencer // With StrictOrdering only
+
+	// Cassette recording, or replay in place of the CLI
+	recorder    *cassetteRecorder
+	player      *cassettePlayer
+	replayQueue chan []string // Batches of lines for the replayed stdout
+
+	// Reaps the CLI process, recording how it exited
+	exit *processExit
 
 	// Channels for communication
 	msgChan chan shared.Message
@@ -69,34 +94,85 @@ type Transport struct {
 
 // New creates a new subprocess transport.
 func New(cliPath string, options *shared.Options, closeStdin bool, entrypoint string) *Transport {
-	return &Transport{
+	t := &Transport{
 		cliPath:    cliPath,
 		options:    options,
 		closeStdin: closeStdin,
 		entrypoint: entrypoint,
-		parser:     parser.New(),
 		validator:  shared.NewStreamValidator(),
 	}
+	t.configureParser(options)
+	t.configureValidator(options)
+	return t
 }
 
 // NewWithPrompt creates a new subprocess transport for one-shot queries with prompt as CLI argument.
 func NewWithPrompt(cliPath string, options *shared.Options, prompt string) *Transport {
-	return &Transport{
+	t := &Transport{
 		cliPath:    cliPath,
 		options:    options,
 		closeStdin: true,
 		entrypoint: "sdk-go", // Query mode uses sdk-go
-		parser:     parser.New(),
 		validator:  shared.NewStreamValidator(),
 		promptArg:  &prompt,
 	}
+	t.configureParser(options)
+	t.configureValidator(options)
+	return t
+}
+
+// configureValidator enables sequence tracking when integrity checks are
+// requested, and ordering enforcement in strict mode.
+func (t *Transport) configureValidator(options *shared.Options) {
+	if options != nil && options.StreamIntegrityChecks {
+		t.validator.EnableSequenceTracking()
+	}
+	if options != nil && options.StrictOrdering {
+		t.sequencer = shared.NewSequencer()
+	}
+}
+
+// configureParser sets the stdout line limit and the parser from options.
+// MaxMessageSize takes precedence over MaxBufferSize.
+func (t *Transport) configureParser(options *shared.Options) {
+	t.maxMessageSize = defaultMaxMessageSize
+	if options != nil {
+		if options.MaxBufferSize != nil && *options.MaxBufferSize > 0 {
+			t.maxMessageSize = *options.MaxBufferSize
+		}
+		if options.MaxMessageSize != nil && *options.MaxMessageSize > 0 {
+			t.maxMessageSize = *options.MaxMessageSize
+		}
+	}
+	t.parser = parser.NewWithMaxBufferSize(t.maxMessageSize)
+	if options != nil && options.MaxInlineResultBytes != nil {
+		t.parser.SetMaxInlineResultBytes(*options.MaxInlineResultBytes)
+		t.spillLimit = *options.MaxInlineResultBytes
+	}
+	if options != nil && options.MessageRecycling {
+		t.parser.SetMessageRecycling(true)
+	}
+	if options != nil && options.JSONCodec != nil {
+		t.parser.SetJSONCodec(options.JSONCodec)
+	}
+	if options != nil && options.StrictProtocol {
+		t.parser.SetStrictProtocol(true)
+	}
+}
+
+// marshal encodes a message for stdin with the configured JSON codec.
+func (t *Transport) marshal(message any) ([]byte, error) {
+	if t.options != nil && t.options.JSONCodec != nil {
+		return t.options.JSONCodec.Marshal(message)
+	}
+	return json.Marshal(message)
 }
 
 // IsConnected returns whether the transport is currently connected.
 func (t *Transport) IsConnected() bool {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return t.connected && t.cmd != nil && t.cmd.Process != nil
+	return t.connected && (t.player != nil || t.cmd != nil && t.cmd.Process != nil)
 }
 
 // Connect starts the Claude CLI subprocess.
@@ -108,29 +184,15 @@ func (t *Transport) Connect(ctx context.Context) error {
 		return fmt.Errorf("transport already connected")
 	}
 
-	// Generate MCP config file if McpServers are specified
-	opts := t.options
-	if t.options != nil && len(t.options.McpServers) > 0 {
-		mcpConfigPath, err := t.generateMcpConfigFile()
-		if err != nil {
-			return fmt.Errorf("failed to generate MCP config file: %w", err)
-		}
+	if Replaying(t.options) {
+		return t.connectReplay(ctx)
+	}
 
-		// Create modified options with mcp-config in ExtraArgs
-		// We don't want to mutate the user's options, so create a shallow copy
-		optsCopy := *t.options
-		if optsCopy.ExtraArgs == nil {
-			optsCopy.ExtraArgs = make(map[string]*string)
-		} else {
-			// Deep copy the ExtraArgs map
-			extraArgsCopy := make(map[string]*string, len(optsCopy.ExtraArgs)+1)
-			for k, v := range optsCopy.ExtraArgs {
-				extraArgsCopy[k] = v
-			}
-			optsCopy.ExtraArgs = extraArgsCopy
-		}
-		optsCopy.ExtraArgs["mcp-config"] = &mcpConfigPath
-		opts = &optsCopy
+	// Write MCP config and settings to temporary files as needed
+	opts, err := t.commandOptions()
+	if err != nil {
+		t.cleanup()
+		return err
 	}
 
 	// Build command with all options
@@ -142,63 +204,98 @@ func (t *Transport) Connect(ctx context.Context) error {
 		// Streaming mode or regular one-shot
 		args = cli.BuildCommand(t.cliPath, opts, t.closeStdin)
 	}
-	//nolint:gosec // G204: This is the core CLI SDK functionality - subprocess execution is required
-	t.cmd = exec.CommandContext(ctx, args[0], args[1:]...)
 
-	// Set up environment - idiomatic Go: start with system env
-	env := os.Environ()
-
-	// Add SDK identifier (required)
-	env = append(env, "CLAUDE_CODE_ENTRYPOINT="+t.entrypoint)
-
-	// Merge custom environment variables
-	if t.options != nil && t.options.ExtraEnv != nil {
-		for key, value := range t.options.ExtraEnv {
-			// Use fmt.Sprintf for clarity and consistency
-			env = append(env, fmt.Sprintf("%s=%s", key, value))
+	// Variables set for the CLI on top of the system environment
+	cliEnv := []string{"CLAUDE_CODE_ENTRYPOINT=" + t.entrypoint}
+	if t.options != nil {
+		for key, value := range t.options.CLIEnv() {
+			cliEnv = append(cliEnv, fmt.Sprintf("%s=%s", key, value))
 		}
 	}
 
-	// Apply environment to command
-	t.cmd.Env = env
-
 	// Set working directory if specified
+	var dir string
 	if t.options != nil && t.options.Cwd != nil {
 		if err := cli.ValidateWorkingDirectory(*t.options.Cwd); err != nil {
+			t.cleanup()
 			return err
 		}
-		t.cmd.Dir = *t.options.Cwd
+		dir = *t.options.Cwd
+	}
+
+	command := shared.CLICommand{Args: args, Dir: dir, Env: cliEnv, Files: t.tempFiles()}
+	if t.options != nil && t.options.CommandWrapper != nil {
+		command, err = t.options.CommandWrapper(command)
+		if err != nil {
+			t.cleanup()
+			return fmt.Errorf("command wrapper: %w", err)
+		}
+		if len(command.Args) == 0 {
+			t.cleanup()
+			return fmt.Errorf("command wrapper returned no command")
+		}
 	}
 
+	//nolint:gosec // G204: This is the core CLI SDK functionality - subprocess execution is required
+	t.cmd = exec.CommandContext(ctx, command.Args[0], command.Args[1:]...)
+
+	// Start with the system environment and add the SDK identifier and
+	// custom variables
+	t.cmd.Env = append(os.Environ(), command.Env...)
+	t.cmd.Dir = command.Dir
+
 	// Set up I/O pipes
-	var err error
 	if t.promptArg == nil {
 		// Only create stdin pipe if we need to send messages via stdin
 		t.stdin, err = t.cmd.StdinPipe()
 		if err != nil {
+			t.cleanup()
 			return fmt.Errorf("failed to create stdin pipe: %w", err)
 		}
 	}
 
 	t.stdout, err = t.cmd.StdoutPipe()
 	if err != nil {
+		t.cleanup()
 		return fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 
-	// Handle stderr based on DebugWriter configuration
-	if t.options != nil && t.options.DebugWriter != nil {
+	// Handle stderr based on StderrCallback and DebugWriter configuration
+	switch {
+	case t.options != nil && t.options.StderrCallback != nil:
+		// Stream lines to the callback, copying to the debug writer if set
+		t.stderrLines = newStderrLineWriter(t.options.StderrCallback, t.maxMessageSize)
+		t.cmd.Stderr = t.stderrLines
+		if t.options.DebugWriter != nil {
+			t.cmd.Stderr = io.MultiWriter(t.options.DebugWriter, t.stderrLines)
+		}
+	case t.options != nil && t.options.DebugWriter != nil:
 		// Use custom debug writer provided by user
 		t.cmd.Stderr = t.options.DebugWriter
-	} else {
+	default:
 		// Isolate stderr using temporary file to prevent deadlocks
 		// This matches Python SDK pattern to avoid subprocess pipe deadlocks
 		t.stderr, err = os.CreateTemp("", "claude_stderr_*.log")
 		if err != nil {
+			t.cleanup()
 			return fmt.Errorf("failed to create stderr file: %w", err)
 		}
 		t.cmd.Stderr = t.stderr
 	}
 
+	t.recorder = nil
+	if recording(t.options) {
+		t.recorder = newCassetteRecorder(t.options.CassettePath)
+		if t.promptArg != nil {
+			hash, err := hashPrompt(promptMessage(*t.promptArg))
+			if err != nil {
+				t.cleanup()
+				return err
+			}
+			t.recorder.prompt(hash)
+		}
+	}
+
 	// Start the process
 	if err := t.cmd.Start(); err != nil {
 		t.cleanup()
@@ -208,6 +305,8 @@ func (t *Transport) Connect(ctx context.Context) error {
 		)
 	}
 
+	t.exit = &processExit{cmd: t.cmd}
+
 	// Set up context for goroutine management
 	t.ctx, t.cancel = context.WithCancel(ctx)
 
@@ -238,6 +337,10 @@ func (t *Transport) SendMessage(ctx context.Context, message shared.StreamMessag
 		return nil // No-op for one-shot queries
 	}
 
+	if t.player != nil {
+		return t.sendReplay(ctx, message)
+	}
+
 	if !t.connected || t.stdin == nil {
 		return fmt.Errorf("transport not connected or stdin closed")
 	}
@@ -250,14 +353,22 @@ func (t *Transport) SendMessage(ctx context.Context, message shared.StreamMessag
 	}
 
 	// Serialize message to JSON
-	data, err := json.Marshal(message)
+	data, err := t.marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
 
+	// Start the prompt's recording before the CLI can answer it
+	if t.recorder != nil {
+		hash, err := hashPrompt(message.Message)
+		if err != nil {
+			return err
+		}
+		t.recorder.prompt(hash)
+	}
+
 	// Send with newline
-	_, err = t.stdin.Write(append(data, '\n'))
-	if err != nil {
+	if err := t.writeLine(data); err != nil {
 		return fmt.Errorf("failed to write message: %w", err)
 	}
 
@@ -270,6 +381,63 @@ func (t *Transport) SendMessage(ctx context.Context, message shared.StreamMessag
 	return nil
 }
 
+// writeLine writes data to stdin as one line. Callers hold t.mu.
+func (t *Transport) writeLine(data []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err := t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// SupportsControlRequests reports whether control messages can be exchanged
+// with the CLI. Only a streaming CLI reads stdin for the whole session.
+func (t *Transport) SupportsControlRequests() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.streaming()
+}
+
+// streaming reports whether the CLI reads stdin until Close. Callers hold t.mu.
+func (t *Transport) streaming() bool {
+	return t.promptArg == nil && !t.closeStdin && t.player == nil
+}
+
+// SendControlRequest writes a control request to the CLI. Its response
+// arrives among the received messages as a *shared.ControlResponseMessage.
+func (t *Transport) SendControlRequest(ctx context.Context, req *shared.ControlRequest) error {
+	return t.sendControl(ctx, shared.ControlRequestFrame(req))
+}
+
+// SendControlResponse answers a control request the CLI sent, received as a
+// *shared.ControlRequestMessage.
+func (t *Transport) SendControlResponse(ctx context.Context, resp *shared.ControlResponse) error {
+	return t.sendControl(ctx, shared.ControlResponseFrame(resp))
+}
+
+func (t *Transport) sendControl(ctx context.Context, frame map[string]any) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if !t.streaming() {
+		return fmt.Errorf("control messages require a streaming CLI")
+	}
+	if !t.connected || t.stdin == nil {
+		return fmt.Errorf("transport not connected or stdin closed")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := t.marshal(frame)
+	if err != nil {
+		return fmt.Errorf("failed to marshal control message: %w", err)
+	}
+	if err := t.writeLine(data); err != nil {
+		return fmt.Errorf("failed to write control message: %w", err)
+	}
+	return nil
+}
+
 // ReceiveMessages returns channels for receiving messages and errors.
 func (t *Transport) ReceiveMessages(_ context.Context) (<-chan shared.Message, <-chan error) {
 	t.mu.RLock()
@@ -292,6 +460,11 @@ func (t *Transport) Interrupt(_ context.Context) error {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
+	// A replayed answer plays out in full, as recorded
+	if t.connected && t.player != nil {
+		return nil
+	}
+
 	if !t.connected || t.cmd == nil || t.cmd.Process == nil {
 		return fmt.Errorf("process not running")
 	}
@@ -327,6 +500,12 @@ func (t *Transport) Close() error {
 		t.stdin = nil
 	}
 
+	// End the replayed stdout once the queued lines are written
+	if t.replayQueue != nil {
+		close(t.replayQueue)
+		t.replayQueue = nil
+	}
+
 	// Wait for goroutines to finish with timeout
 	done := make(chan struct{})
 	go func() {
@@ -334,9 +513,11 @@ func (t *Transport) Close() error {
 		close(done)
 	}()
 
+	finished := false
 	select {
 	case <-done:
 		// Goroutines finished gracefully
+		finished = true
 	case <-time.After(terminationTimeoutSeconds * time.Second):
 		// Timeout: proceed with cleanup anyway
 		// Goroutines should terminate when process is killed
@@ -348,8 +529,22 @@ func (t *Transport) Close() error {
 		err = t.terminateProcess()
 	}
 
+	// Once the process is reaped its stdout is closed, so the reader exits
+	// and no goroutine outlives Close.
+	if !finished && err == nil && t.cmd != nil && t.cmd.Process != nil {
+		<-done
+	}
+
 	// Cleanup resources
 	t.cleanup()
+	t.parser.RemoveSpilledFiles()
+
+	if t.recorder != nil {
+		if saveErr := t.recorder.save(); saveErr != nil && err == nil {
+			err = saveErr
+		}
+		t.recorder = nil
+	}
 
 	return err
 }
@@ -361,44 +556,118 @@ func (t *Transport) handleStdout() {
 	defer close(t.errChan)
 	defer t.validator.MarkStreamEnd() // Mark stream end for validation
 
-	scanner := bufio.NewScanner(t.stdout)
+	// Lines are read with a bounded buffer and accumulated across reads, so
+	// large tool results are limited only by maxMessageSize. Oversized lines
+	// are skipped and reported without ending the stream.
+	reader := bufio.NewReaderSize(t.stdout, stdoutReadBufferSize)
+	recorder := t.recorder
+	exit := t.exit
+
+	// Strings that would spill to image files skip memory as they are read;
+	// recorded lines must keep them
+	var spill *parser.StringSpiller
+	if t.spillLimit > 0 && recorder == nil {
+		spill = parser.NewStringSpiller(t.spillLimit)
+		defer func() {
+			spill.Discard()
+			spill.Cleanup()
+		}()
+	}
 
-	// Increase scanner buffer to handle large tool results (files, etc.)
-	// Default bufio.Scanner has MaxScanTokenSize of 64KB which is insufficient
-	// for tool results containing large files. We use 1MB to match parser's
-	// MaxBufferSize and handle files up to ~900KB after JSON encoding overhead.
-	const maxScanTokenSize = 1024 * 1024 // 1MB
-	buf := make([]byte, maxScanTokenSize)
-	scanner.Buffer(buf, maxScanTokenSize)
+	for {
+		line, spilled, err := readLine(reader, t.maxMessageSize, spill)
 
-	for scanner.Scan() {
 		select {
 		case <-t.ctx.Done():
 			return
 		default:
 		}
 
-		line := scanner.Text()
-		if line == "" {
+		if err != nil {
+			var tooLarge *shared.MessageTooLargeError
+			if errors.As(err, &tooLarge) || errors.Is(err, errSpillFailed) {
+				select {
+				case t.errChan <- err:
+				case <-t.ctx.Done():
+					return
+				}
+				continue
+			}
+			if !errors.Is(err, io.EOF) {
+				select {
+				case t.errChan <- fmt.Errorf("stdout read error: %w", err):
+				case <-t.ctx.Done():
+				}
+			} else if exit != nil {
+				// The CLI closed its output, so it exited on its own; reap
+				// it before the channels close so ExitCode is known
+				exit.reap()
+			}
+			return
+		}
+
+		if len(line) == 0 {
 			continue
 		}
+		if recorder != nil {
+			recorder.line(line)
+		}
 
-		// Parse line with the parser
-		messages, err := t.parser.ProcessLine(line)
+		// Parse line with the parser. Malformed input is reported, and any
+		// message parsed along with the error is still delivered.
+		var messages []shared.Message
+		if spill != nil {
+			messages, err = t.parser.ProcessSpilledLine(string(line), spilled)
+			if t.parser.BufferSize() == 0 {
+				spill.Cleanup()
+			}
+		} else {
+			messages, err = t.parser.ProcessLine(string(line))
+		}
 		if err != nil {
 			select {
 			case t.errChan <- err:
 			case <-t.ctx.Done():
 				return
 			}
-			continue
 		}
 
 		// Send parsed messages and track for validation
 		for _, msg := range messages {
+			if msg != nil && shared.IsControlMessage(msg) {
+				// Control messages are answered by the client and take no
+				// part in the conversation's ordering or integrity
+				select {
+				case t.msgChan <- msg:
+				case <-t.ctx.Done():
+					return
+				}
+				continue
+			}
 			if msg != nil {
-				// Track message for stream validation
-				t.validator.TrackMessage(msg)
+				// In strict mode an ordering violation ends the stream
+				if t.sequencer != nil {
+					if violation := t.sequencer.Check(msg); violation != nil {
+						select {
+						case t.errChan <- violation:
+						case <-t.ctx.Done():
+						}
+						return
+					}
+				}
+
+				// Track message for stream validation; with integrity
+				// checks enabled, violations are reported before the message
+				issues := t.validator.TrackAndCheck(msg)
+				if t.options != nil && t.options.StreamIntegrityChecks {
+					for _, issue := range issues {
+						select {
+						case t.errChan <- shared.NewStreamIntegrityError(issue):
+						case <-t.ctx.Done():
+							return
+						}
+					}
+				}
 
 				select {
 				case t.msgChan <- msg:
@@ -408,13 +677,151 @@ func (t *Transport) handleStdout() {
 			}
 		}
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		select {
-		case t.errChan <- fmt.Errorf("stdout scanner error: %w", err):
-		case <-t.ctx.Done():
+// errSpillFailed reports a line whose long strings could not be written to
+// files; the line is skipped.
+var errSpillFailed = errors.New("failed to spill message string")
+
+// readLine reads one newline-terminated line without its line ending.
+// Lines longer than maxSize are consumed in full and reported as a
+// MessageTooLargeError carrying their size. A final line without a
+// newline is returned before io.EOF.
+//
+// With a spiller, long strings are moved to files as they are read and do
+// not count towards maxSize; the files are returned with the line.
+func readLine(r *bufio.Reader, maxSize int, spill *parser.StringSpiller) ([]byte, []string, error) {
+	var line []byte
+	size := 0
+	var spillErr error
+	for {
+		chunk, err := r.ReadSlice('\n')
+		switch {
+		case err == nil:
+		case errors.Is(err, bufio.ErrBufferFull):
+		case errors.Is(err, io.EOF) && size+len(chunk) > 0:
+		default:
+			return nil, nil, err
+		}
+
+		complete := !errors.Is(err, bufio.ErrBufferFull)
+		if complete {
+			chunk = bytes.TrimRight(chunk, "\r\n")
+		}
+
+		switch {
+		case spill != nil && spillErr == nil && size <= maxSize:
+			spillErr = spill.Write(chunk)
+			size = spill.Len()
+			if spillErr != nil || size > maxSize {
+				spill.Discard()
+			}
+		case spill != nil:
+			size += len(chunk)
+		default:
+			size += len(chunk)
+			if size <= maxSize {
+				line = append(line, chunk...)
+			} else {
+				line = nil // Drop the partial line; keep counting its size
+			}
+		}
+
+		if complete {
+			if spillErr != nil {
+				return nil, nil, fmt.Errorf("%w: %v", errSpillFailed, spillErr)
+			}
+			if size > maxSize {
+				return nil, nil, shared.NewMessageTooLargeError(size, maxSize)
+			}
+			if spill != nil {
+				line, files, err := spill.Finish()
+				if err != nil {
+					return nil, nil, fmt.Errorf("%w: %v", errSpillFailed, err)
+				}
+				return line, files, nil
+			}
+			return line, nil, nil
+		}
+	}
+}
+
+// stderrLineWriter splits CLI stderr output into lines and passes each to a
+// callback as it is written. Lines longer than maxSize are cut to their
+// first maxSize bytes.
+type stderrLineWriter struct {
+	mu       sync.Mutex
+	callback shared.StderrCallback
+	maxSize  int
+	partial  []byte
+	// dropping is set while the rest of a cut line is skipped
+	dropping bool
+}
+
+func newStderrLineWriter(callback shared.StderrCallback, maxSize int) *stderrLineWriter {
+	return &stderrLineWriter{callback: callback, maxSize: maxSize}
+}
+
+// Write delivers every complete line in p, holding back a trailing partial
+// line until the rest of it arrives. A partial line reaching maxSize is
+// delivered cut, and the rest of it dropped.
+func (w *stderrLineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data := append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
 		}
+		if !w.dropping {
+			w.deliver(w.cut(data[:i]))
+		}
+		w.dropping = false
+		data = data[i+1:]
+	}
+	switch {
+	case w.dropping:
+		data = nil
+	case len(data) >= w.maxSize:
+		w.deliver(w.cut(data))
+		w.dropping = true
+		data = nil
 	}
+	w.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// cut shortens line to maxSize bytes.
+func (w *stderrLineWriter) cut(line []byte) []byte {
+	if len(line) > w.maxSize {
+		return line[:w.maxSize]
+	}
+	return line
+}
+
+// flush delivers a final line that was not terminated by a newline.
+func (w *stderrLineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) > 0 {
+		w.deliver(w.partial)
+		w.partial = nil
+	}
+}
+
+// deliver passes one line to the callback. Blank lines are skipped, and a
+// panicking callback does not take down the stderr copy.
+func (w *stderrLineWriter) deliver(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(bytes.TrimSpace(line)) == 0 {
+		return
+	}
+	defer func() {
+		_ = recover()
+	}()
+	w.callback(shared.ParseDebugRecord(string(line), time.Now()))
 }
 
 // isProcessAlreadyFinishedError checks if an error indicates the process has already terminated.
@@ -452,10 +859,10 @@ func (t *Transport) terminateProcess() error {
 
 	// Wait exactly 5 seconds
 	done := make(chan error, 1)
-	// Capture cmd while we know it's valid to avoid data race
-	cmd := t.cmd
+	// Capture the reaper while we know it's valid to avoid data race
+	exit := t.exit
 	go func() {
-		done <- cmd.Wait()
+		done <- exit.wait()
 	}()
 
 	select {
@@ -488,13 +895,73 @@ func (t *Transport) terminateProcess() error {
 	}
 }
 
+// processExit reaps the CLI process once, for whichever of the stdout
+// reader and terminateProcess gets there first.
+type processExit struct {
+	cmd  *exec.Cmd
+	once sync.Once
+	err  error
+
+	mu     sync.Mutex
+	code   int
+	exited bool // Reaped by the stdout reader, before Close
+}
+
+// wait waits for the process to exit and returns the error of cmd.Wait.
+func (e *processExit) wait() error {
+	e.once.Do(func() { e.err = e.cmd.Wait() })
+	return e.err
+}
+
+// reap waits for a process that exited on its own and records its exit
+// code.
+func (e *processExit) reap() {
+	err := e.wait()
+	code := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.code, e.exited = code, true
+}
+
+// ExitCode returns the exit code of a CLI process that exited on its own,
+// once its output ended; a process killed by a signal reports -1. It
+// reports false while the process runs, when Close terminated it, and when
+// replaying a cassette.
+func (t *Transport) ExitCode() (int, bool) {
+	t.mu.Lock()
+	exit := t.exit
+	t.mu.Unlock()
+	if exit == nil {
+		return 0, false
+	}
+	exit.mu.Lock()
+	defer exit.mu.Unlock()
+	return exit.code, exit.exited
+}
+
 // cleanup cleans up all resources
 func (t *Transport) cleanup() {
+	if t.stdin != nil {
+		// Only still set when Connect failed before the process started
+		_ = t.stdin.Close()
+		t.stdin = nil
+	}
+
 	if t.stdout != nil {
 		_ = t.stdout.Close()
 		t.stdout = nil
 	}
 
+	if t.stderrLines != nil {
+		// The process has exited, so a final line without a newline is complete
+		t.stderrLines.flush()
+		t.stderrLines = nil
+	}
+
 	if t.stderr != nil {
 		// Graceful cleanup matching Python SDK pattern
 		// Python: except Exception: pass
@@ -510,8 +977,140 @@ func (t *Transport) cleanup() {
 		t.mcpConfigFile = nil
 	}
 
+	if t.settingsFile != nil {
+		// Clean up temporary settings file
+		_ = t.settingsFile.Close()
+		_ = os.Remove(t.settingsFile.Name()) // Ignore cleanup errors
+		t.settingsFile = nil
+	}
+
 	// Reset state
 	t.cmd = nil
+	t.player = nil
+}
+
+// commandOptions returns the options used to build the CLI command. MCP
+// servers and inline settings are written to temporary files, which are
+// passed to the CLI by path on a copy, so the caller's options are not mutated.
+func (t *Transport) commandOptions() (*shared.Options, error) {
+	if t.options == nil || (len(t.options.McpServers) == 0 && t.options.SettingsJSON == nil && len(t.options.MemoryFiles) == 0) {
+		return t.options, nil
+	}
+	optsCopy := *t.options
+
+	// Append memory files to the system prompt, as the CLI has no flag for them
+	if len(t.options.MemoryFiles) > 0 {
+		prompt, err := appendMemoryFiles(t.options.AppendSystemPrompt, t.options.MemoryFiles)
+		if err != nil {
+			return nil, err
+		}
+		optsCopy.AppendSystemPrompt = &prompt
+	}
+
+	// Generate MCP config file if McpServers are specified
+	if len(t.options.McpServers) > 0 {
+		mcpConfigPath, err := t.generateMcpConfigFile()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate MCP config file: %w", err)
+		}
+
+		// Deep copy the ExtraArgs map before adding mcp-config
+		extraArgsCopy := make(map[string]*string, len(optsCopy.ExtraArgs)+1)
+		for k, v := range optsCopy.ExtraArgs {
+			extraArgsCopy[k] = v
+		}
+		extraArgsCopy["mcp-config"] = &mcpConfigPath
+		optsCopy.ExtraArgs = extraArgsCopy
+	}
+
+	// Generate settings file if an inline settings document is specified.
+	// Sandbox settings are merged into the file, so the CLI gets one --settings.
+	if t.options.SettingsJSON != nil {
+		settingsPath, err := t.generateSettingsFile()
+		if err != nil {
+			if t.mcpConfigFile != nil {
+				_ = t.mcpConfigFile.Close()
+				_ = os.Remove(t.mcpConfigFile.Name())
+				t.mcpConfigFile = nil
+			}
+			return nil, fmt.Errorf("failed to generate settings file: %w", err)
+		}
+		optsCopy.Settings = &settingsPath
+		optsCopy.Sandbox = nil
+	}
+
+	return &optsCopy, nil
+}
+
+// tempFiles returns the paths of the temporary files written for the CLI.
+func (t *Transport) tempFiles() []string {
+	var files []string
+	if t.mcpConfigFile != nil {
+		files = append(files, t.mcpConfigFile.Name())
+	}
+	if t.settingsFile != nil {
+		files = append(files, t.settingsFile.Name())
+	}
+	return files
+}
+
+// appendMemoryFiles returns the appended system prompt extended with the
+// contents of each memory file, in order.
+func appendMemoryFiles(appendPrompt *string, paths []string) (string, error) {
+	var sections []string
+	if appendPrompt != nil && *appendPrompt != "" {
+		sections = append(sections, *appendPrompt)
+	}
+	for _, path := range paths {
+		//nolint:gosec // G304: Memory files are chosen by the SDK user
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read memory file: %w", err)
+		}
+		sections = append(sections, fmt.Sprintf("Contents of %s:\n\n%s", path, strings.TrimRight(string(data), "\n")))
+	}
+	return strings.Join(sections, "\n\n"), nil
+}
+
+// generateSettingsFile writes options.SettingsJSON, merged with any sandbox
+// settings, to a temporary file and returns its path. The file is stored in
+// t.settingsFile for cleanup.
+func (t *Transport) generateSettingsFile() (string, error) {
+	var settings map[string]any
+	if err := json.Unmarshal(t.options.SettingsJSON, &settings); err != nil {
+		return "", fmt.Errorf("settings must be a JSON object: %w", err)
+	}
+	if settings == nil {
+		return "", fmt.Errorf("settings must be a JSON object")
+	}
+	if t.options.Sandbox != nil {
+		settings["sandbox"] = t.options.Sandbox
+	}
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal settings: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp("", "claude_settings_*.json")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to write settings: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		_ = os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to sync settings file: %w", err)
+	}
+
+	// Store for cleanup later
+	t.settingsFile = tmpFile
+
+	return tmpFile.Name(), nil
 }
 
 // generateMcpConfigFile creates a temporary MCP config file from options.McpServers.
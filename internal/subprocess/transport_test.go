@@ -1,9 +1,14 @@
 package subprocess
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -380,6 +385,46 @@ func WithInvalidOutput() TransportMockOption {
 	}
 }
 
+// runStdoutForTest feeds output to the transport's stdout reader and
+// collects everything it delivers.
+func runStdoutForTest(ctx context.Context, t *testing.T, transport *Transport, output string) ([]shared.Message, []error) {
+	t.Helper()
+	transport.stdout = io.NopCloser(strings.NewReader(output))
+	transport.ctx, transport.cancel = context.WithCancel(ctx)
+	defer transport.cancel()
+	transport.msgChan = make(chan shared.Message, 10)
+	transport.errChan = make(chan error, 10)
+
+	transport.wg.Add(1)
+	go transport.handleStdout()
+	transport.wg.Wait()
+
+	var messages []shared.Message
+	for msg := range transport.msgChan {
+		messages = append(messages, msg)
+	}
+	var errs []error
+	for err := range transport.errChan {
+		errs = append(errs, err)
+	}
+	return messages, errs
+}
+
+// countingCodec is encoding/json counting its calls.
+type countingCodec struct {
+	marshals, unmarshals int
+}
+
+func (c *countingCodec) Marshal(v any) ([]byte, error) {
+	c.marshals++
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v any) error {
+	c.unmarshals++
+	return json.Unmarshal(data, v)
+}
+
 func newTransportMockCLI() string {
 	return newTransportMockCLIWithOptions()
 }
@@ -787,6 +832,292 @@ func TestTransportHandleStdoutErrorPaths(t *testing.T) {
 	})
 }
 
+// TestReadLine tests stdout line framing, including lines longer than the read buffer
+func TestReadLine(t *testing.T) {
+	long := strings.Repeat("x", 100)
+
+	tests := []struct {
+		name      string
+		input     string
+		maxSize   int
+		wantLines []string
+		wantSizes []int // size of MessageTooLargeError per line, 0 if none
+	}{
+		{
+			name:      "simple_lines",
+			input:     "a\nbb\n",
+			maxSize:   10,
+			wantLines: []string{"a", "bb"},
+			wantSizes: []int{0, 0},
+		},
+		{
+			name:      "crlf_and_final_line_without_newline",
+			input:     "a\r\nlast",
+			maxSize:   10,
+			wantLines: []string{"a", "last"},
+			wantSizes: []int{0, 0},
+		},
+		{
+			name:      "line_longer_than_read_buffer",
+			input:     long + "\n",
+			maxSize:   200,
+			wantLines: []string{long},
+			wantSizes: []int{0},
+		},
+		{
+			name:      "too_large_line_is_skipped",
+			input:     long + "\nok\n",
+			maxSize:   50,
+			wantLines: []string{"", "ok"},
+			wantSizes: []int{100, 0},
+		},
+		{
+			name:      "line_at_limit",
+			input:     long + "\n",
+			maxSize:   100,
+			wantLines: []string{long},
+			wantSizes: []int{0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// A 16 byte buffer forces partial-line accumulation
+			reader := bufio.NewReaderSize(strings.NewReader(test.input), 16)
+
+			for i, want := range test.wantLines {
+				line, _, err := readLine(reader, test.maxSize, nil)
+				if test.wantSizes[i] > 0 {
+					var tooLarge *shared.MessageTooLargeError
+					if !errors.As(err, &tooLarge) {
+						t.Fatalf("line %d: expected MessageTooLargeError, got %v", i, err)
+					}
+					if tooLarge.Size != test.wantSizes[i] || tooLarge.Limit != test.maxSize {
+						t.Errorf("line %d: expected size %d limit %d, got %d and %d",
+							i, test.wantSizes[i], test.maxSize, tooLarge.Size, tooLarge.Limit)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("line %d: unexpected error: %v", i, err)
+				}
+				if string(line) != want {
+					t.Errorf("line %d: expected %q, got %q", i, want, line)
+				}
+			}
+
+			if _, _, err := readLine(reader, test.maxSize, nil); !errors.Is(err, io.EOF) {
+				t.Errorf("Expected io.EOF after last line, got %v", err)
+			}
+		})
+	}
+}
+
+// TestTransportSpillsLargeImages tests that tool result images larger than
+// the message size limit are read to files, which Close removes
+func TestTransportSpillsLargeImages(t *testing.T) {
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	image := strings.Repeat("PNG", 4096)
+	output := `{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":[` +
+		`{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` +
+		base64.StdEncoding.EncodeToString([]byte(image)) + `"}}]}]}}` + "\n"
+
+	maxMessageSize, inline := 1024, 100
+	transport := New("claude", &shared.Options{MaxMessageSize: &maxMessageSize, MaxInlineResultBytes: &inline}, false, "sdk-go")
+	messages, errs := runStdoutForTest(ctx, t, transport, output)
+	if len(errs) != 0 || len(messages) != 1 {
+		t.Fatalf("Expected the message delivered, got %d messages and errors %v", len(messages), errs)
+	}
+	block := messages[0].(*shared.UserMessage).Content.([]shared.ContentBlock)[0].(*shared.ToolResultBlock)
+	images := block.Images()
+	if len(images) != 1 || !images[0].IsFile() {
+		t.Fatalf("Expected the image in a file, got %+v", images)
+	}
+	if data, err := images[0].Data(); err != nil || string(data) != image {
+		t.Errorf("Expected the decoded image, got %d bytes, %v", len(data), err)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 1 {
+		t.Errorf("Expected only the image file left after parsing, got %d files", len(entries))
+	}
+
+	transport.connected = true
+	if err := transport.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(images[0].File); !os.IsNotExist(err) {
+		t.Errorf("Expected Close to remove the image file, got %v", err)
+	}
+}
+
+// TestTransportMaxMessageSize tests how the message size limit is configured
+func TestTransportMaxMessageSize(t *testing.T) {
+	bufferSize := 2048
+	messageSize := 4096
+
+	tests := []struct {
+		name    string
+		options *shared.Options
+		want    int
+	}{
+		{"default", nil, defaultMaxMessageSize},
+		{"max_buffer_size", &shared.Options{MaxBufferSize: &bufferSize}, bufferSize},
+		{"max_message_size_wins", &shared.Options{MaxBufferSize: &bufferSize, MaxMessageSize: &messageSize}, messageSize},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := New("claude", test.options, false, "sdk-go")
+			if transport.maxMessageSize != test.want {
+				t.Errorf("Expected max message size %d, got %d", test.want, transport.maxMessageSize)
+			}
+			if prompt := NewWithPrompt("claude", test.options, "hi"); prompt.maxMessageSize != test.want {
+				t.Errorf("Expected prompt transport max message size %d, got %d", test.want, prompt.maxMessageSize)
+			}
+		})
+	}
+}
+
+// TestTransportStreamIntegrityErrors tests that sequence violations are reported on the error channel
+func TestTransportStreamIntegrityErrors(t *testing.T) {
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	// The tool use arrives after its result, then a subagent message
+	// references a tool use that never arrived.
+	output := strings.Join([]string{
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"ok"}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{}}],"model":"claude-3"}}`,
+		`{"type":"assistant","parent_tool_use_id":"toolu_lost","message":{"content":[{"type":"text","text":"sub"}],"model":"claude-3"}}`,
+	}, "\n") + "\n"
+
+	tests := []struct {
+		name       string
+		options    *shared.Options
+		wantIssues []string
+	}{
+		{"disabled", &shared.Options{}, nil},
+		{
+			"enabled",
+			&shared.Options{StreamIntegrityChecks: true},
+			[]string{"extra_tool_result", shared.StreamIssueResultBeforeUse, shared.StreamIssueUnknownParent},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := New("claude", test.options, false, "sdk-go")
+			messages, errs := runStdoutForTest(ctx, t, transport, output)
+
+			if len(messages) != 3 {
+				t.Errorf("Expected all 3 messages delivered, got %d", len(messages))
+			}
+			if len(errs) != len(test.wantIssues) {
+				t.Fatalf("Expected %d errors, got %d: %v", len(test.wantIssues), len(errs), errs)
+			}
+			for i, err := range errs {
+				var integrityErr *shared.StreamIntegrityError
+				if !errors.As(err, &integrityErr) {
+					t.Fatalf("Expected StreamIntegrityError, got %T: %v", err, err)
+				}
+				if integrityErr.Issue.Type != test.wantIssues[i] {
+					t.Errorf("Error %d: expected issue %q, got %q", i, test.wantIssues[i], integrityErr.Issue.Type)
+				}
+			}
+		})
+	}
+}
+
+// TestTransportStrictOrdering tests that an ordering violation ends the stream in strict mode
+func TestTransportStrictOrdering(t *testing.T) {
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	// The second result ends a turn that already ended
+	output := strings.Join([]string{
+		`{"type":"system","subtype":"init","session_id":"s1"}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"hi"}],"model":"claude-3"}}`,
+		`{"type":"result","subtype":"success","duration_ms":1,"duration_api_ms":1,"is_error":false,"num_turns":1,"session_id":"s1"}`,
+		`{"type":"result","subtype":"success","duration_ms":1,"duration_api_ms":1,"is_error":false,"num_turns":1,"session_id":"s1"}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"late"}],"model":"claude-3"}}`,
+	}, "\n") + "\n"
+
+	t.Run("lenient", func(t *testing.T) {
+		messages, errs := runStdoutForTest(ctx, t, New("claude", &shared.Options{}, false, "sdk-go"), output)
+		if len(messages) != 5 || len(errs) != 0 {
+			t.Errorf("Expected all messages and no errors, got %d messages and %v", len(messages), errs)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		transport := New("claude", &shared.Options{StrictOrdering: true}, false, "sdk-go")
+		messages, errs := runStdoutForTest(ctx, t, transport, output)
+		if len(messages) != 3 {
+			t.Errorf("Expected the stream to end before the duplicate result, got %d messages", len(messages))
+		}
+		if len(errs) != 1 {
+			t.Fatalf("Expected one error, got %v", errs)
+		}
+		var integrityErr *shared.StreamIntegrityError
+		if !errors.As(errs[0], &integrityErr) || integrityErr.Issue.Type != shared.StreamIssueDuplicateResult {
+			t.Errorf("Expected a duplicate result violation, got %v", errs[0])
+		}
+	})
+}
+
+func TestTransportStrictProtocol(t *testing.T) {
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	// The tool use block's input is a string, not an object
+	output := strings.Join([]string{
+		`{"type":"system","subtype":"init","session_id":"s1","claude_code_version":"2.0.14"}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":"ls"}],"model":"claude-3"}}`,
+		`{"type":"result","subtype":"success","duration_ms":1,"duration_api_ms":1,"is_error":false,"num_turns":1,"session_id":"s1"}`,
+	}, "\n") + "\n"
+
+	transport := New("claude", &shared.Options{StrictProtocol: true}, false, "sdk-go")
+	messages, errs := runStdoutForTest(ctx, t, transport, output)
+	if len(messages) != 2 {
+		t.Errorf("Expected the mismatched message to be dropped, got %d messages", len(messages))
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Expected one error, got %v", errs)
+	}
+	var protocolErr *shared.ProtocolError
+	if !errors.As(errs[0], &protocolErr) || protocolErr.Field != "message.content[0].input" || protocolErr.Got != "string" {
+		t.Errorf("Expected a mismatch at message.content[0].input, got %v", errs[0])
+	}
+}
+
+func TestTransportJSONCodec(t *testing.T) {
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	codec := &countingCodec{}
+	transport := New("claude", &shared.Options{JSONCodec: codec}, false, "sdk-go")
+
+	output := `{"type":"assistant","message":{"content":[{"type":"text","text":"hi"}],"model":"claude-3"}}` + "\n"
+	messages, errs := runStdoutForTest(ctx, t, transport, output)
+	if len(messages) != 1 || len(errs) != 0 {
+		t.Fatalf("Expected one message and no errors, got %v %v", messages, errs)
+	}
+	if codec.unmarshals != 1 {
+		t.Errorf("Expected the codec to decode the line, got %d calls", codec.unmarshals)
+	}
+
+	data, err := transport.marshal(shared.StreamMessage{Type: "user"})
+	if err != nil || !strings.Contains(string(data), `"type":"user"`) {
+		t.Fatalf("Unexpected marshal result %q: %v", data, err)
+	}
+	if codec.marshals != 1 {
+		t.Errorf("Expected the codec to encode the message, got %d calls", codec.marshals)
+	}
+}
+
 // TestTransportInterruptErrorPaths tests uncovered Interrupt scenarios
 func TestTransportInterruptErrorPaths(t *testing.T) {
 	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
@@ -933,6 +1264,19 @@ func TestSubprocessEnvironmentVariables(t *testing.T) {
 				assertEnvContains(t, env, "CLAUDE_CODE_ENTRYPOINT=sdk-go")
 			},
 		},
+		{
+			name: "credentials_and_config_dir",
+			options: &shared.Options{
+				ExtraEnv:  map[string]string{"ANTHROPIC_AUTH_TOKEN": "inherited"},
+				APIKey:    "sk-ant-test",
+				ConfigDir: "/srv/bot/claude",
+			},
+			validate: func(t *testing.T, env []string) {
+				assertEnvContains(t, env, "ANTHROPIC_API_KEY=sk-ant-test")
+				assertEnvContains(t, env, "ANTHROPIC_AUTH_TOKEN=")
+				assertEnvContains(t, env, "CLAUDE_CONFIG_DIR=/srv/bot/claude")
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1027,6 +1371,335 @@ func TestTransportWorkingDirectory(t *testing.T) {
 	}
 }
 
+// TestTransportCommandWrapper tests that a command wrapper sees the CLI
+// command and replaces it
+func TestTransportCommandWrapper(t *testing.T) {
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	cwd := t.TempDir()
+	wrapperDir := t.TempDir()
+	cliPath := newTransportMockCLI()
+	var seen shared.CLICommand
+	options := &shared.Options{
+		Cwd:        &cwd,
+		ExtraEnv:   map[string]string{"CUSTOM": "1"},
+		McpServers: map[string]shared.McpServerConfig{"fs": &shared.McpStdioServerConfig{Type: shared.McpServerTypeStdio, Command: "fs"}},
+		CommandWrapper: func(cmd shared.CLICommand) (shared.CLICommand, error) {
+			seen = cmd
+			return shared.CLICommand{Args: cmd.Args, Dir: wrapperDir, Env: []string{"WRAPPED=1"}}, nil
+		},
+	}
+	transport := New(cliPath, options, false, "sdk-go-client")
+	defer disconnectTransportSafely(t, transport)
+	assertNoTransportError(t, transport.Connect(ctx))
+
+	if len(seen.Args) == 0 || seen.Args[0] != cliPath || seen.Dir != cwd {
+		t.Errorf("Expected the wrapper to see the CLI command, got %+v", seen)
+	}
+	if strings.Join(seen.Env, " ") != "CLAUDE_CODE_ENTRYPOINT=sdk-go-client CUSTOM=1" {
+		t.Errorf("Expected the CLI's variables, got %v", seen.Env)
+	}
+	if len(seen.Files) != 1 || seen.Files[0] != transport.mcpConfigFile.Name() {
+		t.Errorf("Expected the MCP config file, got %v", seen.Files)
+	}
+	if transport.cmd.Dir != wrapperDir {
+		t.Errorf("Expected the wrapped directory, got %s", transport.cmd.Dir)
+	}
+	env := strings.Join(transport.cmd.Env, "\n")
+	if !strings.Contains(env, "WRAPPED=1") || strings.Contains(env, "CUSTOM=1") {
+		t.Error("Expected the wrapped environment to replace the CLI's variables")
+	}
+}
+
+func TestTransportCommandWrapperError(t *testing.T) {
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	var files []string
+	options := &shared.Options{
+		McpServers: map[string]shared.McpServerConfig{"fs": &shared.McpStdioServerConfig{Type: shared.McpServerTypeStdio, Command: "fs"}},
+		CommandWrapper: func(cmd shared.CLICommand) (shared.CLICommand, error) {
+			files = cmd.Files
+			return cmd, errors.New("no container runtime")
+		},
+	}
+	transport := New(newTransportMockCLI(), options, false, "sdk-go")
+	err := transport.Connect(ctx)
+	if err == nil || !strings.Contains(err.Error(), "no container runtime") {
+		t.Fatalf("Expected the wrapper error, got %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("Expected the MCP config file, got %v", files)
+	}
+	if _, err := os.Stat(files[0]); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected temporary files to be removed, got %v", err)
+	}
+}
+
+// TestTransportStderrCallback tests that stderr lines stream to the callback
+func TestTransportStderrCallback(t *testing.T) {
+	if runtime.GOOS == windowsOS {
+		t.Skip("Shell script mock CLI is not supported on Windows")
+	}
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	script := "#!/bin/sh\n" +
+		"printf '2025-01-02T03:04:05.000Z [DEBUG] starting\\n\\n[ERROR] boom\\r\\nunterminated' >&2\n"
+	cliPath := createTransportTempScript(script, "")
+	defer func() { _ = os.Remove(cliPath) }()
+
+	var mu sync.Mutex
+	var records []shared.DebugRecord
+	var raw bytes.Buffer
+	transport := New(cliPath, &shared.Options{
+		DebugWriter: &raw,
+		StderrCallback: func(record shared.DebugRecord) {
+			mu.Lock()
+			records = append(records, record)
+			mu.Unlock()
+		},
+	}, false, "sdk-go")
+	connectTransportSafely(ctx, t, transport)
+
+	msgChan, _ := transport.ReceiveMessages(ctx)
+	for range msgChan {
+	}
+	disconnectTransportSafely(t, transport)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d: %+v", len(records), records)
+	}
+	want := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	if !records[0].Time.Equal(want) || records[0].Level != "DEBUG" || records[0].Message != "starting" {
+		t.Errorf("Unexpected first record: %+v", records[0])
+	}
+	if records[1].Level != "ERROR" || records[1].Message != "boom" {
+		t.Errorf("Unexpected second record: %+v", records[1])
+	}
+	if records[2].Message != "unterminated" {
+		t.Errorf("Expected unterminated final line to be flushed, got %+v", records[2])
+	}
+	if !strings.Contains(raw.String(), "[ERROR] boom") {
+		t.Errorf("Expected debug writer to receive raw output, got %q", raw.String())
+	}
+}
+
+func TestTransportExitCode(t *testing.T) {
+	if runtime.GOOS == windowsOS {
+		t.Skip("Shell script mock CLI is not supported on Windows")
+	}
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	cliPath := createTransportTempScript("#!/bin/sh\nexit 75\n", "")
+	defer func() { _ = os.Remove(cliPath) }()
+
+	transport := New(cliPath, &shared.Options{}, false, "sdk-go")
+	connectTransportSafely(ctx, t, transport)
+	msgChan, _ := transport.ReceiveMessages(ctx)
+	for range msgChan {
+	}
+	if code, exited := transport.ExitCode(); !exited || code != 75 {
+		t.Errorf("Expected exit code 75, got %d (%v)", code, exited)
+	}
+	disconnectTransportSafely(t, transport)
+
+	// A process terminated by Close did not exit on its own
+	cliPath = createTransportTempScript("#!/bin/sh\nexec sleep 30\n", "")
+	defer func() { _ = os.Remove(cliPath) }()
+	transport = New(cliPath, &shared.Options{}, false, "sdk-go")
+	connectTransportSafely(ctx, t, transport)
+	if _, exited := transport.ExitCode(); exited {
+		t.Error("Expected no exit code while the CLI runs")
+	}
+	disconnectTransportSafely(t, transport)
+	if _, exited := transport.ExitCode(); exited {
+		t.Error("Expected no exit code after Close")
+	}
+}
+
+func TestStderrLineWriterSplitsWrites(t *testing.T) {
+	var messages []string
+	writer := newStderrLineWriter(func(record shared.DebugRecord) {
+		messages = append(messages, record.Message)
+		if record.Message == "panics" {
+			panic("callback bug")
+		}
+	}, defaultMaxMessageSize)
+
+	for _, chunk := range []string{"fir", "st\npan", "ics\nsec", "ond\n"} {
+		if n, err := writer.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write(%q) = %d, %v", chunk, n, err)
+		}
+	}
+	writer.flush()
+
+	want := []string{"first", "panics", "second"}
+	if strings.Join(messages, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, messages)
+	}
+}
+
+// TestStderrLineWriterCapsLines tests that a line outgrowing the limit is
+// delivered cut instead of buffered whole
+func TestStderrLineWriterCapsLines(t *testing.T) {
+	var messages []string
+	writer := newStderrLineWriter(func(record shared.DebugRecord) {
+		messages = append(messages, record.Message)
+	}, 8)
+
+	for _, chunk := range []string{"abcde", "fghij", "klm\nshort\n", "0123456789\nend"} {
+		if _, err := writer.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write(%q) failed: %v", chunk, err)
+		}
+		if len(writer.partial) > 8 {
+			t.Fatalf("Expected at most 8 bytes held back, got %d", len(writer.partial))
+		}
+	}
+	writer.flush()
+
+	want := []string{"abcdefgh", "short", "01234567", "end"}
+	if strings.Join(messages, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, messages)
+	}
+}
+
+// TestTransportMemoryFiles tests that memory files are appended to the system prompt
+func TestTransportMemoryFiles(t *testing.T) {
+	dir := t.TempDir()
+	memoryPath := filepath.Join(dir, "team.md")
+	if err := os.WriteFile(memoryPath, []byte("Always run the linter.\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write memory file: %v", err)
+	}
+
+	appendPrompt := "Be brief."
+	options := &shared.Options{AppendSystemPrompt: &appendPrompt, MemoryFiles: []string{memoryPath}}
+	transport := New(newTransportMockCLI(), options, false, "sdk-go")
+	opts, err := transport.commandOptions()
+	assertNoTransportError(t, err)
+
+	want := "Be brief.\n\nContents of " + memoryPath + ":\n\nAlways run the linter."
+	if opts.AppendSystemPrompt == nil || *opts.AppendSystemPrompt != want {
+		t.Errorf("Expected appended system prompt %q, got %v", want, opts.AppendSystemPrompt)
+	}
+	if *options.AppendSystemPrompt != appendPrompt {
+		t.Error("Caller options must not be mutated")
+	}
+
+	options.MemoryFiles = []string{filepath.Join(dir, "missing.md")}
+	if _, err := transport.commandOptions(); err == nil || !strings.Contains(err.Error(), "memory file") {
+		t.Errorf("Expected missing memory file error, got %v", err)
+	}
+}
+
+// TestTransportSettingsFile tests that inline settings are passed to the CLI as a temporary file
+func TestTransportSettingsFile(t *testing.T) {
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	t.Run("settings_file_generated_and_cleaned_up", func(t *testing.T) {
+		options := &shared.Options{
+			SettingsJSON: []byte(`{"permissions":{"deny":["Bash(rm:*)"]},"env":{"FOO":"bar"}}`),
+			Sandbox:      &shared.SandboxSettings{Enabled: true},
+		}
+		transport := New(newTransportMockCLI(), options, false, "sdk-go")
+		defer disconnectTransportSafely(t, transport)
+		connectTransportSafely(ctx, t, transport)
+
+		if transport.settingsFile == nil {
+			t.Fatal("Expected settings file to be generated")
+		}
+		settingsPath := transport.settingsFile.Name()
+		assertSettingsArg(t, transport.cmd.Args, settingsPath)
+
+		data, err := os.ReadFile(settingsPath)
+		if err != nil {
+			t.Fatalf("Failed to read settings file: %v", err)
+		}
+		var settings map[string]any
+		if err := json.Unmarshal(data, &settings); err != nil {
+			t.Fatalf("Settings file is not valid JSON: %v", err)
+		}
+		if _, ok := settings["permissions"]; !ok {
+			t.Error("Expected permissions to be preserved")
+		}
+		if _, ok := settings["sandbox"]; !ok {
+			t.Error("Expected sandbox settings to be merged into the settings file")
+		}
+		if options.Settings != nil || options.Sandbox == nil {
+			t.Error("Caller options must not be mutated")
+		}
+
+		if err := transport.Close(); err != nil {
+			t.Errorf("Failed to close transport: %v", err)
+		}
+		if _, err := os.Stat(settingsPath); !os.IsNotExist(err) {
+			t.Error("Settings file should be deleted after transport Close()")
+		}
+	})
+
+	t.Run("invalid_settings_json", func(t *testing.T) {
+		options := &shared.Options{SettingsJSON: []byte(`["not","an","object"]`)}
+		transport := New(newTransportMockCLI(), options, false, "sdk-go")
+		defer disconnectTransportSafely(t, transport)
+
+		err := transport.Connect(ctx)
+		if err == nil || !strings.Contains(err.Error(), "settings must be a JSON object") {
+			t.Fatalf("Expected settings JSON error, got %v", err)
+		}
+		if transport.settingsFile != nil {
+			t.Error("Expected no settings file for invalid JSON")
+		}
+	})
+
+	t.Run("invalid_settings_json_removes_mcp_config", func(t *testing.T) {
+		tmp := t.TempDir()
+		t.Setenv("TMPDIR", tmp)
+		options := &shared.Options{
+			SettingsJSON: []byte(`["not","an","object"]`),
+			McpServers:   map[string]shared.McpServerConfig{"fs": &shared.McpStdioServerConfig{Type: shared.McpServerTypeStdio, Command: "fs"}},
+		}
+		transport := New(newTransportMockCLI(), options, false, "sdk-go")
+		defer disconnectTransportSafely(t, transport)
+
+		if err := transport.Connect(ctx); err == nil {
+			t.Fatal("Expected settings JSON error")
+		}
+		if configs, _ := filepath.Glob(filepath.Join(tmp, "claude_mcp_config_*")); len(configs) != 0 {
+			t.Errorf("Expected the MCP config removed, got %v", configs)
+		}
+	})
+
+	t.Run("settings_path_passed_through", func(t *testing.T) {
+		path := "/etc/claude/settings.json"
+		transport := New(newTransportMockCLI(), &shared.Options{Settings: &path}, false, "sdk-go")
+		defer disconnectTransportSafely(t, transport)
+		connectTransportSafely(ctx, t, transport)
+
+		if transport.settingsFile != nil {
+			t.Error("Expected no temporary settings file for a settings path")
+		}
+		assertSettingsArg(t, transport.cmd.Args, path)
+	})
+}
+
+func assertSettingsArg(t *testing.T, args []string, want string) {
+	t.Helper()
+	for i, arg := range args {
+		if arg == "--settings" && i+1 < len(args) {
+			if args[i+1] != want {
+				t.Errorf("Expected --settings %s, got %s", want, args[i+1])
+			}
+			return
+		}
+	}
+	t.Errorf("Expected --settings flag in %v", args)
+}
+
 // TestTransportMcpServerConfiguration tests MCP server config file generation
 func TestTransportMcpServerConfiguration(t *testing.T) {
 	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
@@ -1194,6 +1867,157 @@ func TestTransportMcpServerConfiguration(t *testing.T) {
 	}
 }
 
+const (
+	cassetteAssistantLine = `{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"Hello"}],"model":"claude-3"}}`
+	cassetteResultLine    = `{"type":"result","subtype":"success","duration_ms":1,"duration_api_ms":1,"is_error":false,"num_turns":1,"session_id":"s1"}`
+)
+
+func TestTransportCassetteRecord(t *testing.T) {
+	if runtime.GOOS == windowsOS {
+		t.Skip("Mock CLI script requires bash")
+	}
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	cliPath := createTransportTempScript(`#!/bin/bash
+while read -r line; do
+echo '`+cassetteAssistantLine+`'
+echo '`+cassetteResultLine+`'
+done
+`, "")
+	defer func() { _ = os.Remove(cliPath) }()
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	options := &shared.Options{CassettePath: path, CassetteMode: shared.CassetteRecordOrReplay}
+	if Replaying(options) {
+		t.Fatal("Expected a missing cassette to be recorded")
+	}
+	transport := New(cliPath, options, false, "sdk-go-client")
+	connectTransportSafely(ctx, t, transport)
+	prompt := promptMessage("hi")
+	assertNoTransportError(t, transport.SendMessage(ctx, shared.StreamMessage{Type: "user", Message: prompt}))
+	receiveUntilResult(ctx, t, transport)
+	assertNoTransportError(t, transport.Close())
+
+	player, err := loadCassette(path)
+	if err != nil {
+		t.Fatalf("Failed to load recorded cassette: %v", err)
+	}
+	hash, _ := hashPrompt(prompt)
+	lines, err := player.next(hash)
+	if err != nil {
+		t.Fatalf("Expected a recording for the prompt: %v", err)
+	}
+	if strings.Join(lines, "\n") != cassetteAssistantLine+"\n"+cassetteResultLine {
+		t.Errorf("Expected the CLI's lines verbatim, got %q", lines)
+	}
+	if !Replaying(options) {
+		t.Error("Expected the recorded cassette to be replayed")
+	}
+}
+
+func TestTransportCassetteReplay(t *testing.T) {
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	path := writeTestCassette(t, "hi")
+	options := &shared.Options{CassettePath: path, CassetteMode: shared.CassetteReplay}
+	transport := New("", options, false, "sdk-go-client")
+	connectTransportSafely(ctx, t, transport)
+	defer disconnectTransportSafely(t, transport)
+	assertTransportConnected(t, transport, true)
+
+	assertNoTransportError(t, transport.SendMessage(ctx, shared.StreamMessage{Type: "user", Message: promptMessage("hi")}))
+	messages := receiveUntilResult(ctx, t, transport)
+	if len(messages) != 2 || messages[0].Type() != shared.MessageTypeAssistant {
+		t.Errorf("Expected the recorded assistant and result messages, got %v", messages)
+	}
+	assertNoTransportError(t, transport.Interrupt(ctx))
+
+	// Each recording plays once
+	err := transport.SendMessage(ctx, shared.StreamMessage{Type: "user", Message: promptMessage("hi")})
+	if !errors.Is(err, shared.ErrCassetteMiss) {
+		t.Errorf("Expected ErrCassetteMiss, got %v", err)
+	}
+
+	assertNoTransportError(t, transport.Close())
+	msgChan, _ := transport.ReceiveMessages(ctx)
+	if _, ok := <-msgChan; ok {
+		t.Error("Expected no messages after Close")
+	}
+}
+
+func TestTransportCassetteReplayQuery(t *testing.T) {
+	ctx, cancel := setupTransportTestContext(t, 5*time.Second)
+	defer cancel()
+
+	path := writeTestCassette(t, "hi")
+	options := &shared.Options{CassettePath: path, CassetteMode: shared.CassetteReplay}
+	transport := NewWithPrompt("", options, "hi")
+	connectTransportSafely(ctx, t, transport)
+	defer disconnectTransportSafely(t, transport)
+
+	msgChan, _ := transport.ReceiveMessages(ctx)
+	count := 0
+	for range msgChan {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Expected the answer then the end of output, got %d messages", count)
+	}
+
+	other := NewWithPrompt("", options, "bye")
+	if err := other.Connect(ctx); !errors.Is(err, shared.ErrCassetteMiss) {
+		t.Errorf("Expected ErrCassetteMiss for an unrecorded prompt, got %v", err)
+	}
+
+	missing := &shared.Options{CassettePath: filepath.Join(t.TempDir(), "missing.json"), CassetteMode: shared.CassetteReplay}
+	if err := New("", missing, false, "sdk-go-client").Connect(ctx); err == nil {
+		t.Error("Expected replaying a missing cassette to fail")
+	}
+}
+
+// writeTestCassette records an answer to prompt without a CLI.
+func writeTestCassette(t *testing.T, prompt string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session.json")
+	recorder := newCassetteRecorder(path)
+	hash, err := hashPrompt(promptMessage(prompt))
+	if err != nil {
+		t.Fatalf("hashPrompt failed: %v", err)
+	}
+	recorder.prompt(hash)
+	recorder.line([]byte(cassetteAssistantLine))
+	recorder.line([]byte(cassetteResultLine))
+	if err := recorder.save(); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	return path
+}
+
+// receiveUntilResult reads messages up to and including a result.
+func receiveUntilResult(ctx context.Context, t *testing.T, transport *Transport) []shared.Message {
+	t.Helper()
+	msgChan, errChan := transport.ReceiveMessages(ctx)
+	var messages []shared.Message
+	for {
+		select {
+		case msg, ok := <-msgChan:
+			if !ok {
+				t.Fatal("Expected a result before the stream ended")
+			}
+			messages = append(messages, msg)
+			if msg.Type() == shared.MessageTypeResult {
+				return messages
+			}
+		case err := <-errChan:
+			t.Fatalf("Unexpected stream error: %v", err)
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for a result")
+		}
+	}
+}
+
 // assertEnvContains checks if environment slice contains a key=value pair
 func assertEnvContains(t *testing.T, env []string, expected string) {
 	t.Helper()
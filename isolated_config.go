@@ -0,0 +1,134 @@
+package claudecode
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// configDirPattern names the directories of WithIsolatedConfig.
+const configDirPattern = "claude-config-*"
+
+// loginConfigKeys are the fields of .claude.json describing the login,
+// copied into an isolated config directory.
+var loginConfigKeys = []string{"oauthAccount", "primaryApiKey"}
+
+// isolatedConfig is the CLI config directory of a client, created with
+// WithIsolatedConfig.
+type isolatedConfig struct {
+	dir string
+
+	// The client's config directory, restored when the connection ends
+	prevConfigDir string
+
+	mu      sync.Mutex
+	failed  bool
+	removed bool
+}
+
+// newIsolatedConfig creates a config directory in baseDir, holding the
+// login of the config directory options point at unless the options carry
+// credentials of their own.
+func newIsolatedConfig(baseDir string, options *Options) (*isolatedConfig, error) {
+	if baseDir != "" {
+		if err := os.MkdirAll(baseDir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating config base directory: %w", err)
+		}
+	}
+	dir, err := os.MkdirTemp(baseDir, configDirPattern)
+	if err != nil {
+		return nil, fmt.Errorf("creating config directory: %w", err)
+	}
+	if options.APIKey == "" && options.OAuthToken == "" {
+		if err := copyLogin(cliConfigDir(options), dir); err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, fmt.Errorf("copying CLI login: %w", err)
+		}
+	}
+	return &isolatedConfig{dir: dir}, nil
+}
+
+// copyLogin copies the stored login of the config directory src into dst.
+func copyLogin(src, dst string) error {
+	if src == "" {
+		return nil
+	}
+	err := copyFile(filepath.Join(src, credentialsFile), filepath.Join(dst, credentialsFile), 0o600)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var config map[string]any
+	if err := readJSONFile(globalConfigPath(src), &config); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	login := map[string]any{}
+	for _, key := range loginConfigKeys {
+		if value, ok := config[key]; ok {
+			login[key] = value
+		}
+	}
+	if len(login) == 0 {
+		return nil
+	}
+	return updateJSONFile(globalConfigPath(dst), func(config map[string]any) {
+		for key, value := range login {
+			config[key] = value
+		}
+	})
+}
+
+// usable reports whether the directory can serve another connection.
+func (ic *isolatedConfig) usable() bool {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	return !ic.removed
+}
+
+// attach points options at the directory for a connection.
+func (ic *isolatedConfig) attach(options *Options) {
+	ic.mu.Lock()
+	ic.failed = false
+	ic.mu.Unlock()
+	ic.prevConfigDir = options.ConfigDir
+	options.ConfigDir = ic.dir
+}
+
+// restore points options back at the client's own config directory.
+func (ic *isolatedConfig) restore(options *Options) {
+	options.ConfigDir = ic.prevConfigDir
+}
+
+// track marks the connection failed when a turn ends in an error.
+func (ic *isolatedConfig) track(msg Message) {
+	if result, ok := msg.(*ResultMessage); ok && result.IsError {
+		ic.mu.Lock()
+		ic.failed = true
+		ic.mu.Unlock()
+	}
+}
+
+// remove deletes the directory as cleanup says, once the connection ended
+// for reason.
+func (ic *isolatedConfig) remove(reason SessionEndReason, cleanup ConfigCleanup) error {
+	ic.mu.Lock()
+	failed := ic.failed || reason != SessionEndDisconnect
+	switch cleanup {
+	case ConfigCleanupNever:
+		ic.mu.Unlock()
+		return nil
+	case ConfigCleanupOnSuccess:
+		if failed {
+			ic.mu.Unlock()
+			return nil
+		}
+	}
+	ic.removed = true
+	ic.mu.Unlock()
+	return os.RemoveAll(ic.dir)
+}
@@ -0,0 +1,131 @@
+package claudecode
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientIsolatedConfig(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	login := t.TempDir()
+	writeTestFile(t, filepath.Join(login, credentialsFile), `{"claudeAiOauth": {"accessToken": "a", "refreshToken": "r"}}`)
+	writeTestFile(t, filepath.Join(login, ".claude.json"), `{"oauthAccount": {"emailAddress": "dev@example.com"}, "numStartups": 3}`)
+	base := filepath.Join(t.TempDir(), "clients")
+
+	client := NewClientWithTransport(newClientMockTransport(), WithConfigDir(login), WithIsolatedConfig(base)).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	dir := client.ConfigDir()
+
+	if filepath.Dir(dir) != base {
+		t.Fatalf("Expected a config directory in %s, got %q", base, dir)
+	}
+	options := client.options
+	if options.ConfigDir != dir || options.CLIEnv()["CLAUDE_CONFIG_DIR"] != dir {
+		t.Errorf("Expected the CLI pointed at %s, got %q", dir, options.ConfigDir)
+	}
+	if got := readWorkspaceFile(t, filepath.Join(dir, credentialsFile)); !strings.Contains(got, `"refreshToken": "r"`) {
+		t.Errorf("Expected the credentials copied, got %q", got)
+	}
+	var config map[string]any
+	if err := json.Unmarshal([]byte(readWorkspaceFile(t, filepath.Join(dir, ".claude.json"))), &config); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := config["oauthAccount"]; !ok || len(config) != 1 {
+		t.Errorf("Expected only the login copied, got %v", config)
+	}
+
+	disconnectClientSafely(t, client)
+	if _, err := os.Stat(dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the config directory to be removed, got %v", err)
+	}
+	if options.ConfigDir != login {
+		t.Errorf("Expected the client's config directory restored, got %q", options.ConfigDir)
+	}
+
+	// The next connection gets a new directory
+	connectClientSafely(ctx, t, client)
+	if next := client.ConfigDir(); next == dir || filepath.Dir(next) != base {
+		t.Errorf("Expected a new config directory, got %q", next)
+	}
+	disconnectClientSafely(t, client)
+}
+
+func TestClientIsolatedConfigCleanup(t *testing.T) {
+	tests := []struct {
+		name     string
+		cleanup  ConfigCleanup
+		isError  bool
+		wantKept bool
+	}{
+		{"removed on disconnect", ConfigCleanupOnDisconnect, true, false},
+		{"failed session kept", ConfigCleanupOnSuccess, true, true},
+		{"successful session removed", ConfigCleanupOnSuccess, false, false},
+		{"never removed", ConfigCleanupNever, false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := setupClientTestContext(t, 5*time.Second)
+			defer cancel()
+
+			transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+				&ResultMessage{Subtype: "error_during_execution", IsError: test.isError},
+			}))
+			client := NewClientWithTransport(transport, WithAPIKey("sk-ant-test"),
+				WithIsolatedConfig(t.TempDir()), WithConfigCleanup(test.cleanup)).(*ClientImpl)
+			connectClientSafely(ctx, t, client)
+			dir := client.ConfigDir()
+			awaitClientResult(ctx, t, client)
+			disconnectClientSafely(t, client)
+
+			_, err := os.Stat(dir)
+			if kept := err == nil; kept != test.wantKept {
+				t.Fatalf("Expected kept %v, got stat error %v", test.wantKept, err)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("Expected no login copied with an API key, got %d files", len(entries))
+			}
+			if test.wantKept {
+				// A kept directory serves the next connection
+				connectClientSafely(ctx, t, client)
+				if client.ConfigDir() != dir {
+					t.Errorf("Expected the kept directory reused, got %q", client.ConfigDir())
+				}
+				disconnectClientSafely(t, client)
+			}
+		})
+	}
+}
+
+func TestClientIsolatedConfigFailedConnect(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	base := t.TempDir()
+	client := NewClientWithTransport(newMockTransportWithError("connect", errors.New("boom")), WithIsolatedConfig(base))
+	if err := client.Connect(ctx); err == nil {
+		t.Fatal("Expected the connect error")
+	}
+	if entries, _ := os.ReadDir(base); len(entries) != 0 {
+		t.Errorf("Expected the directory of a failed connect removed, got %d entries", len(entries))
+	}
+	if client.(*ClientImpl).options.ConfigDir != "" {
+		t.Errorf("Expected the config directory restored, got %q", client.(*ClientImpl).options.ConfigDir)
+	}
+}
+
+func TestIsolatedConfigValidation(t *testing.T) {
+	err := validateQueryOptions(NewOptions(WithIsolatedConfig("")))
+	if err == nil || !strings.Contains(err.Error(), "requires a Client") {
+		t.Errorf("Expected isolated config to be rejected, got %v", err)
+	}
+	assertOptionsValidationError(t, NewOptions(WithConfigCleanup("sometimes")), true,
+		"unknown cleanup policy should fail validation")
+}
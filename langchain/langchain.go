@@ -0,0 +1,232 @@
+// Package langchain plugs Claude Code into agent frameworks such as
+// langchaingo, as a model for chains and as a tool for other agents.
+//
+// Tool implements langchaingo's tools.Tool interface as is, so an agent
+// can delegate coding tasks to Claude Code:
+//
+//	coder := langchain.NewTool("coder", "Makes changes to the repository. Input: the task.",
+//		langchain.New(claudecode.WithCwd(repo), claudecode.WithPermissionMode(claudecode.PermissionModeAcceptEdits)))
+//	executor := agents.NewExecutor(agents.NewOneShotAgent(llm, []tools.Tool{coder}))
+//
+// Model takes plain Go types that mirror langchaingo's llms package, so the
+// package only depends on the SDK. Implementing llms.Model takes a thin
+// adapter in the application:
+//
+//	type claudeModel struct{ m *langchain.Model }
+//
+//	func (a claudeModel) GenerateContent(ctx context.Context, msgs []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+//		var opts llms.CallOptions
+//		for _, o := range options {
+//			o(&opts)
+//		}
+//		resp, err := a.m.GenerateContent(ctx, toMessages(msgs), langchain.CallOptions{
+//			Model: opts.Model, StreamingFunc: opts.StreamingFunc,
+//		})
+//		if err != nil {
+//			return nil, err
+//		}
+//		return &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+//			Content: resp.Content, StopReason: resp.StopReason, GenerationInfo: resp.GenerationInfo,
+//		}}}, nil
+//	}
+//
+//	func (a claudeModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+//		return llms.GenerateFromSinglePrompt(ctx, a, prompt, options...)
+//	}
+//
+// Each call is one Claude Code query: the agent may use its tools over
+// several internal turns before it answers.
+package langchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// ErrNoPrompt is returned for messages without human text to answer.
+var ErrNoPrompt = errors.New("no human message to answer")
+
+// Role mirrors llms.ChatMessageType.
+type Role string
+
+// Roles of messages.
+const (
+	RoleSystem  Role = "system"
+	RoleHuman   Role = "human"
+	RoleAI      Role = "ai"
+	RoleTool    Role = "tool"
+	RoleGeneric Role = "generic"
+)
+
+// Message mirrors llms.MessageContent with its text parts joined.
+type Message struct {
+	Role Role
+	Text string
+}
+
+// CallOptions mirrors the fields of llms.CallOptions that apply to Claude
+// Code.
+type CallOptions struct {
+	// Model overrides the model of the Model's options.
+	Model string
+	// StreamingFunc, if set, receives the agent's text as it arrives.
+	// Returning an error cancels the query.
+	StreamingFunc func(ctx context.Context, chunk []byte) error
+}
+
+// Response mirrors the single llms.ContentChoice of a response.
+type Response struct {
+	Content string
+	// StopReason is the subtype of the query's result, such as "success"
+	// or "error_max_turns".
+	StopReason string
+	// GenerationInfo holds the SessionID, TotalCostUSD, InputTokens and
+	// OutputTokens of the query, when reported.
+	GenerationInfo map[string]any
+}
+
+// Model answers prompts with Claude Code queries.
+type Model struct {
+	// Options apply to every query.
+	Options []claudecode.Option
+	// Query runs the queries; nil means claudecode.Query.
+	Query claudecode.QueryFunc
+}
+
+// New returns a Model whose queries use opts.
+func New(opts ...claudecode.Option) *Model {
+	return &Model{Options: opts}
+}
+
+// Call answers a single prompt.
+func (m *Model) Call(ctx context.Context, prompt string, opts CallOptions) (string, error) {
+	resp, err := m.GenerateContent(ctx, []Message{{Role: RoleHuman, Text: prompt}}, opts)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// GenerateContent answers the last human message. System messages become
+// the appended system prompt, and earlier messages are included in the
+// prompt as the conversation so far.
+func (m *Model) GenerateContent(ctx context.Context, messages []Message, opts CallOptions) (*Response, error) {
+	prompt, system, err := buildPrompt(messages)
+	if err != nil {
+		return nil, err
+	}
+	extra := []claudecode.Option{}
+	if system != "" {
+		extra = append(extra, claudecode.WithAppendSystemPrompt(system))
+	}
+	if opts.Model != "" {
+		extra = append(extra, claudecode.WithModel(opts.Model))
+	}
+	return m.run(ctx, prompt, extra, opts.StreamingFunc)
+}
+
+// run runs one query, returning its result text.
+func (m *Model) run(ctx context.Context, prompt string, extra []claudecode.Option, stream func(context.Context, []byte) error) (*Response, error) {
+	var text strings.Builder
+	spec := claudecode.RunSpec{
+		Prompt:  prompt,
+		Options: append(append([]claudecode.Option(nil), m.Options...), extra...),
+		OnMessage: func(msg claudecode.Message) error {
+			assistant, ok := msg.(*claudecode.AssistantMessage)
+			if !ok || assistant.ParentToolUseID != nil {
+				return nil
+			}
+			for _, block := range assistant.Content {
+				tb, ok := block.(*claudecode.TextBlock)
+				if !ok {
+					continue
+				}
+				text.WriteString(tb.Text)
+				if stream != nil {
+					if err := stream(ctx, []byte(tb.Text)); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+	result, err := claudecode.RunWithQuery(ctx, m.Query, spec)
+	if err != nil {
+		return nil, err
+	}
+	return resultResponse(result.Result, text.String())
+}
+
+// resultResponse builds the response for a query's result. The result text
+// is the agent's final answer; the streamed text is used when it is absent.
+func resultResponse(result *claudecode.ResultMessage, streamed string) (*Response, error) {
+	if result.IsError {
+		return nil, fmt.Errorf("%w: %s", claudecode.ErrFailedResult, result.Subtype)
+	}
+	resp := &Response{
+		Content:        streamed,
+		StopReason:     result.Subtype,
+		GenerationInfo: map[string]any{"SessionID": result.SessionID},
+	}
+	if result.Result != nil {
+		resp.Content = *result.Result
+	}
+	if result.TotalCostUSD != nil {
+		resp.GenerationInfo["TotalCostUSD"] = *result.TotalCostUSD
+	}
+	if u := result.Usage; u != nil {
+		resp.GenerationInfo["InputTokens"] = u.TotalInputTokens()
+		resp.GenerationInfo["OutputTokens"] = u.OutputTokens
+	}
+	return resp, nil
+}
+
+// buildPrompt renders messages as a prompt and system prompt. The last
+// human message is the prompt; anything before it is quoted as the
+// conversation so far.
+func buildPrompt(messages []Message) (prompt, system string, err error) {
+	last := -1
+	var systems []string
+	for i, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			systems = append(systems, msg.Text)
+		case RoleHuman, RoleGeneric:
+			if strings.TrimSpace(msg.Text) != "" {
+				last = i
+			}
+		}
+	}
+	if last < 0 {
+		return "", "", ErrNoPrompt
+	}
+
+	var history strings.Builder
+	for _, msg := range messages[:last] {
+		if msg.Role == RoleSystem || msg.Text == "" {
+			continue
+		}
+		fmt.Fprintf(&history, "%s: %s\n\n", speaker(msg.Role), msg.Text)
+	}
+	prompt = messages[last].Text
+	if history.Len() > 0 {
+		prompt = "<conversation>\n" + strings.TrimSuffix(history.String(), "\n") + "</conversation>\n\n" + prompt
+	}
+	return prompt, strings.Join(systems, "\n\n"), nil
+}
+
+func speaker(role Role) string {
+	switch role {
+	case RoleAI:
+		return "Assistant"
+	case RoleTool:
+		return "Tool result"
+	default:
+		return "Human"
+	}
+}
@@ -0,0 +1,180 @@
+package langchain
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestModelCall(t *testing.T) {
+	fake := &fakeQuery{messages: []claudecode.Message{
+		textMessage("Thinking about it. "),
+		textMessage("The answer is 4."),
+		successResult("The answer is 4."),
+	}}
+	m := &Model{Options: []claudecode.Option{claudecode.WithMaxTurns(3)}, Query: fake.query}
+
+	var streamed []string
+	resp, err := m.GenerateContent(context.Background(), []Message{{Role: RoleHuman, Text: "2+2?"}}, CallOptions{
+		Model: "claude-haiku-4-5",
+		StreamingFunc: func(_ context.Context, chunk []byte) error {
+			streamed = append(streamed, string(chunk))
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "The answer is 4." || resp.StopReason != "success" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if resp.GenerationInfo["SessionID"] != "session-1" || resp.GenerationInfo["InputTokens"] != 17 ||
+		resp.GenerationInfo["OutputTokens"] != 5 || resp.GenerationInfo["TotalCostUSD"] != 0.25 {
+		t.Errorf("Unexpected generation info %v", resp.GenerationInfo)
+	}
+	if strings.Join(streamed, "") != "Thinking about it. The answer is 4." {
+		t.Errorf("Expected the text to be streamed, got %q", streamed)
+	}
+
+	if fake.prompt != "2+2?" {
+		t.Errorf("Expected the prompt as is, got %q", fake.prompt)
+	}
+	opts := claudecode.NewOptions(fake.opts...)
+	if opts.MaxTurns != 3 || opts.Model == nil || *opts.Model != "claude-haiku-4-5" {
+		t.Errorf("Expected the model's options and the call's model, got %+v", opts)
+	}
+}
+
+func TestModelGenerateContentConversation(t *testing.T) {
+	fake := &fakeQuery{messages: []claudecode.Message{successResult("Done")}}
+	m := &Model{Query: fake.query}
+
+	_, err := m.GenerateContent(context.Background(), []Message{
+		{Role: RoleSystem, Text: "Be brief."},
+		{Role: RoleHuman, Text: "Rename foo to bar."},
+		{Role: RoleAI, Text: "Which package?"},
+		{Role: RoleHuman, Text: "internal/util"},
+	}, CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "<conversation>\nHuman: Rename foo to bar.\n\nAssistant: Which package?\n</conversation>\n\ninternal/util"
+	if fake.prompt != want {
+		t.Errorf("Expected prompt:\n%s\ngot:\n%s", want, fake.prompt)
+	}
+	opts := claudecode.NewOptions(fake.opts...)
+	if opts.AppendSystemPrompt == nil || *opts.AppendSystemPrompt != "Be brief." {
+		t.Errorf("Expected the system message as appended system prompt, got %v", opts.AppendSystemPrompt)
+	}
+}
+
+func TestModelErrors(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		messages []claudecode.Message
+		input    []Message
+		stream   func(context.Context, []byte) error
+		wantErr  error
+	}{
+		{
+			name:    "no human message",
+			input:   []Message{{Role: RoleSystem, Text: "Be brief."}},
+			wantErr: ErrNoPrompt,
+		},
+		{
+			name:     "failed result",
+			messages: []claudecode.Message{&claudecode.ResultMessage{Subtype: "error_max_turns", IsError: true}},
+			wantErr:  claudecode.ErrFailedResult,
+		},
+		{
+			name:     "no result",
+			messages: []claudecode.Message{textMessage("partial")},
+			wantErr:  claudecode.ErrNoResult,
+		},
+		{
+			name:     "streaming func fails",
+			messages: []claudecode.Message{textMessage("partial"), successResult("partial")},
+			stream:   func(context.Context, []byte) error { return errStop },
+			wantErr:  errStop,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeQuery{messages: test.messages}
+			input := test.input
+			if input == nil {
+				input = []Message{{Role: RoleHuman, Text: "hi"}}
+			}
+			_, err := (&Model{Query: fake.query}).GenerateContent(ctx, input, CallOptions{StreamingFunc: test.stream})
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Expected %v, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestModelUsesStreamedTextWithoutResultText(t *testing.T) {
+	parent := "tool-1"
+	subagent := textMessage("subagent chatter")
+	subagent.ParentToolUseID = &parent
+	fake := &fakeQuery{messages: []claudecode.Message{
+		subagent,
+		textMessage("Final answer"),
+		&claudecode.ResultMessage{Subtype: "success"},
+	}}
+	got, err := (&Model{Query: fake.query}).Call(context.Background(), "hi", CallOptions{})
+	if err != nil || got != "Final answer" {
+		t.Errorf("Expected the top-level text, got %q, %v", got, err)
+	}
+}
+
+var errStop = errors.New("client went away")
+
+// fakeQuery records a query and replies with messages.
+type fakeQuery struct {
+	messages []claudecode.Message
+	prompt   string
+	opts     []claudecode.Option
+}
+
+func (f *fakeQuery) query(_ context.Context, prompt string, opts ...claudecode.Option) (claudecode.MessageIterator, error) {
+	f.prompt, f.opts = prompt, opts
+	return &sliceIterator{messages: f.messages}, nil
+}
+
+type sliceIterator struct {
+	messages []claudecode.Message
+}
+
+func (it *sliceIterator) Next(context.Context) (claudecode.Message, error) {
+	if len(it.messages) == 0 {
+		return nil, claudecode.ErrNoMoreMessages
+	}
+	msg := it.messages[0]
+	it.messages = it.messages[1:]
+	return msg, nil
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
+
+func textMessage(text string) *claudecode.AssistantMessage {
+	return &claudecode.AssistantMessage{Content: []claudecode.ContentBlock{&claudecode.TextBlock{Text: text}}}
+}
+
+func successResult(text string) *claudecode.ResultMessage {
+	cost := 0.25
+	return &claudecode.ResultMessage{
+		Subtype:      "success",
+		SessionID:    "session-1",
+		Result:       &text,
+		TotalCostUSD: &cost,
+		Usage:        &claudecode.Usage{InputTokens: 10, OutputTokens: 5, CacheCreation: 5, CacheRead: 2},
+	}
+}
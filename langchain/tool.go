@@ -0,0 +1,32 @@
+package langchain
+
+import "context"
+
+// Tool hands tasks to a Model. It implements langchaingo's tools.Tool.
+type Tool struct {
+	name        string
+	description string
+	model       *Model
+}
+
+// NewTool returns a Tool named name that runs its input as a prompt on
+// model. The description tells the calling agent when to use it and what
+// input to give.
+func NewTool(name, description string, model *Model) *Tool {
+	return &Tool{name: name, description: description, model: model}
+}
+
+// Name returns the name of the tool.
+func (t *Tool) Name() string {
+	return t.name
+}
+
+// Description returns the description of the tool.
+func (t *Tool) Description() string {
+	return t.description
+}
+
+// Call runs input as a prompt and returns the agent's answer.
+func (t *Tool) Call(ctx context.Context, input string) (string, error) {
+	return t.model.Call(ctx, input, CallOptions{})
+}
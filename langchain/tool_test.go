@@ -0,0 +1,33 @@
+package langchain
+
+import (
+	"context"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// langchainTool is langchaingo's tools.Tool interface.
+type langchainTool interface {
+	Name() string
+	Description() string
+	Call(ctx context.Context, input string) (string, error)
+}
+
+var _ langchainTool = (*Tool)(nil)
+
+func TestTool(t *testing.T) {
+	fake := &fakeQuery{messages: []claudecode.Message{successResult("Renamed 3 files")}}
+	tool := NewTool("coder", "Changes code", &Model{Query: fake.query})
+
+	if tool.Name() != "coder" || tool.Description() != "Changes code" {
+		t.Errorf("Unexpected tool %q: %q", tool.Name(), tool.Description())
+	}
+	got, err := tool.Call(context.Background(), "Rename foo to bar")
+	if err != nil || got != "Renamed 3 files" {
+		t.Errorf("Expected the agent's answer, got %q, %v", got, err)
+	}
+	if fake.prompt != "Rename foo to bar" {
+		t.Errorf("Expected the input as prompt, got %q", fake.prompt)
+	}
+}
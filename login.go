@@ -0,0 +1,119 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Login stores the credentials of WithAPIKey or WithOAuthToken in the CLI
+// config directory of WithConfigDir, the way the CLI's own login does, and
+// returns the AuthStatus of the directory. Clients using the directory are
+// then authenticated without the credentials in their options or
+// environment, so each identity on a host can keep its own directory:
+//
+//	_, err := claudecode.Login(ctx, claudecode.WithConfigDir("/var/lib/bot/claude"),
+//	    claudecode.WithOAuthToken(os.Getenv("BOT_OAUTH_TOKEN")))
+//	...
+//	client := claudecode.NewClient(claudecode.WithConfigDir("/var/lib/bot/claude"))
+//
+// The credentials replace the directory's previous login. Login starts no
+// CLI and makes no API call; the credentials are checked on first use.
+func Login(ctx context.Context, opts ...Option) (AuthInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return AuthInfo{}, err
+	}
+	options := NewOptions(opts...)
+	if err := options.Validate(); err != nil {
+		return AuthInfo{}, err
+	}
+	configDir := options.CLIEnv()["CLAUDE_CONFIG_DIR"]
+	if configDir == "" {
+		return AuthInfo{}, fmt.Errorf("login requires a config directory set with WithConfigDir")
+	}
+	if options.APIKey == "" && options.OAuthToken == "" {
+		return AuthInfo{}, fmt.Errorf("login requires credentials set with WithAPIKey or WithOAuthToken")
+	}
+	if err := os.MkdirAll(configDir, 0o700); err != nil {
+		return AuthInfo{}, fmt.Errorf("create config directory: %w", err)
+	}
+
+	credentialsPath := filepath.Join(configDir, credentialsFile)
+	configPath := globalConfigPath(configDir)
+	var err error
+	if options.APIKey != "" {
+		err = updateJSONFile(credentialsPath, func(credentials map[string]any) {
+			delete(credentials, "claudeAiOauth")
+		})
+		if err == nil {
+			err = updateJSONFile(configPath, func(config map[string]any) {
+				delete(config, "oauthAccount")
+				config["primaryApiKey"] = options.APIKey
+			})
+		}
+	} else {
+		err = updateJSONFile(configPath, func(config map[string]any) {
+			delete(config, "oauthAccount")
+			delete(config, "primaryApiKey")
+		})
+		if err == nil {
+			// The shape the CLI gives a CLAUDE_CODE_OAUTH_TOKEN: long-lived,
+			// without a refresh token
+			err = updateJSONFile(credentialsPath, func(credentials map[string]any) {
+				credentials["claudeAiOauth"] = map[string]any{
+					"accessToken":      options.OAuthToken,
+					"refreshToken":     nil,
+					"expiresAt":        nil,
+					"scopes":           []string{"user:inference"},
+					"subscriptionType": nil,
+				}
+			})
+		}
+	}
+	if err != nil {
+		return AuthInfo{}, fmt.Errorf("store CLI credentials: %w", err)
+	}
+
+	stored := *options
+	stored.APIKey, stored.OAuthToken = "", ""
+	return authStatus(ctx, &stored)
+}
+
+// updateJSONFile applies update to the JSON object in path, creating the
+// file if it is missing. The file is replaced atomically and readable only
+// by its owner, as it holds credentials.
+func updateJSONFile(path string, update func(map[string]any)) error {
+	data := map[string]any{}
+	if err := readJSONFile(path, &data); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if data == nil {
+		data = map[string]any{}
+	}
+	update(data)
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
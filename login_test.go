@@ -0,0 +1,64 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogin(t *testing.T) {
+	clearAuthEnv(t)
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "claude")
+
+	info, err := Login(ctx, WithConfigDir(dir), WithAPIKey("sk-ant-test"))
+	assertNoError(t, err)
+	if info.Method != AuthMethodAPIKey || info.Source != filepath.Join(dir, ".claude.json") || info.ConfigDir != dir {
+		t.Errorf("Expected the stored API key, got %+v", info)
+	}
+
+	// A token replaces the key, keeping the rest of the config
+	writeTestFile(t, filepath.Join(dir, ".claude.json"), `{"primaryApiKey": "sk-ant-test", "numStartups": 3}`)
+	info, err = Login(ctx, WithConfigDir(dir), WithOAuthToken("sk-ant-oat-test"))
+	assertNoError(t, err)
+	if info.Method != AuthMethodOAuth || info.Source != filepath.Join(dir, credentialsFile) || !info.ExpiresAt.IsZero() {
+		t.Errorf("Expected the stored OAuth token, got %+v", info)
+	}
+	var config map[string]any
+	data, err := os.ReadFile(filepath.Join(dir, ".claude.json"))
+	assertNoError(t, err)
+	assertNoError(t, json.Unmarshal(data, &config))
+	if _, ok := config["primaryApiKey"]; ok || config["numStartups"] != float64(3) {
+		t.Errorf("Expected only the API key removed, got %v", config)
+	}
+	stat, err := os.Stat(filepath.Join(dir, credentialsFile))
+	assertNoError(t, err)
+	if stat.Mode().Perm() != 0o600 {
+		t.Errorf("Expected credentials readable only by their owner, got %v", stat.Mode())
+	}
+
+	// Clients of the directory use the stored login
+	info, err = AuthStatus(ctx, WithConfigDir(dir))
+	assertNoError(t, err)
+	if info.Method != AuthMethodOAuth {
+		t.Errorf("Expected the stored OAuth token, got %+v", info)
+	}
+}
+
+func TestLoginRequirements(t *testing.T) {
+	clearAuthEnv(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	if _, err := Login(ctx, WithAPIKey("sk-ant-test")); err == nil {
+		t.Error("Expected an error without a config directory")
+	}
+	if _, err := Login(ctx, WithConfigDir(dir)); err == nil {
+		t.Error("Expected an error without credentials")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected nothing written, got %d files", len(entries))
+	}
+}
@@ -0,0 +1,115 @@
+package claudecode
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrMcpUnavailable is returned by Connect with WithMcpStrict when a
+// configured MCP server is not connected.
+var ErrMcpUnavailable = errors.New("MCP server unavailable")
+
+// defaultMcpStrictTimeout is how long Connect waits for the init message
+// with WithMcpStrict when WithInitTimeout is not set.
+const defaultMcpStrictTimeout = 30 * time.Second
+
+// mcpHealth returns the state of the MCP servers reported in info and of
+// those configured, sorted by name. Before the init message, info is nil
+// and only configured servers are listed, without a status.
+func mcpHealth(info *InitInfo, configured map[string]McpServerConfig) []McpServerHealth {
+	byName := make(map[string]*McpServerHealth)
+	for name := range configured {
+		byName[name] = &McpServerHealth{Name: name, Configured: true}
+	}
+	if info != nil {
+		for _, server := range info.McpServers {
+			health, ok := byName[server.Name]
+			if !ok {
+				health = &McpServerHealth{Name: server.Name}
+				byName[server.Name] = health
+			}
+			health.Status = server.Status
+		}
+		for _, health := range byName {
+			if health.Status == "" {
+				health.Status = McpStatusMissing
+			}
+		}
+		for _, tool := range info.Tools {
+			if !strings.HasPrefix(tool, mcpToolPrefix) {
+				continue
+			}
+			if health, ok := byName[mcpServerOf(tool, info.McpServers)]; ok {
+				health.Tools++
+			}
+		}
+	}
+
+	servers := make([]McpServerHealth, 0, len(byName))
+	for _, name := range sortedKeys(byName) {
+		servers = append(servers, *byName[name])
+	}
+	return servers
+}
+
+// checkMcpServers fails with ErrMcpUnavailable, naming each configured
+// server that is not connected.
+func checkMcpServers(info *InitInfo, configured map[string]McpServerConfig) error {
+	var unavailable []string
+	for _, health := range mcpHealth(info, configured) {
+		if health.Configured && !health.Available() {
+			unavailable = append(unavailable, fmt.Sprintf("%s (%s)", health.Name, health.Status))
+		}
+	}
+	if len(unavailable) > 0 {
+		return fmt.Errorf("%w: %s", ErrMcpUnavailable, strings.Join(unavailable, ", "))
+	}
+	return nil
+}
+
+// observeMcpServers passes the unavailable servers of an init message to
+// observer.
+func observeMcpServers(msg Message, configured map[string]McpServerConfig, observer McpObserver) {
+	system, ok := msg.(*SystemMessage)
+	if !ok {
+		return
+	}
+	info, ok := system.Init()
+	if !ok {
+		return
+	}
+	for _, health := range mcpHealth(&info, configured) {
+		if !health.Available() {
+			observer(health)
+		}
+	}
+}
+
+// McpStatus returns the state of each MCP server in the current session,
+// sorted by name: those the CLI reported in its init message and those set
+// with WithMcpServers. Until the init message arrives, only the configured
+// servers are listed, with an empty status.
+func (c *ClientImpl) McpStatus() []McpServerHealth {
+	c.mu.RLock()
+	initInfo, options := c.initInfo, c.options
+	c.mu.RUnlock()
+
+	var configured map[string]McpServerConfig
+	if options != nil {
+		configured = options.McpServers
+	}
+	return mcpHealth(initInfo.info(), configured)
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,113 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientMcpStatus(t *testing.T) {
+	ctx, cancel := setupMcpHealthTestContext(t)
+	defer cancel()
+
+	var mu sync.Mutex
+	var failures []McpServerHealth
+	observer := func(health McpServerHealth) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, health)
+	}
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		mcpInitMessage(),
+		&ResultMessage{Subtype: "success"},
+	}))
+	client := NewClientWithTransport(transport,
+		WithMcpServers(map[string]McpServerConfig{
+			"github": &McpStdioServerConfig{Type: McpServerTypeStdio, Command: "github-mcp"},
+			"jira":   &McpStdioServerConfig{Type: McpServerTypeStdio, Command: "jira-mcp"},
+		}),
+		WithMcpObserver(observer)).(*ClientImpl)
+
+	before := client.McpStatus()
+	if len(before) != 2 || before[0].Name != "github" || before[0].Status != "" || !before[0].Configured {
+		t.Errorf("Expected configured servers without a status before connecting, got %+v", before)
+	}
+
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+	awaitClientResult(ctx, t, client)
+
+	status := client.McpStatus()
+	want := []McpServerHealth{
+		{Name: "github", Status: McpStatusConnected, Tools: 2, Configured: true},
+		{Name: "jira", Status: McpStatusMissing, Configured: true},
+		{Name: "slack", Status: McpStatusFailed},
+	}
+	if len(status) != len(want) {
+		t.Fatalf("Expected %d servers, got %+v", len(want), status)
+	}
+	for i := range want {
+		if status[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], status[i])
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failures) != 2 || failures[0].Name != "jira" || failures[1].Name != "slack" {
+		t.Errorf("Expected the unavailable servers observed, got %+v", failures)
+	}
+}
+
+func TestClientMcpStrict(t *testing.T) {
+	ctx, cancel := setupMcpHealthTestContext(t)
+	defer cancel()
+
+	servers := map[string]McpServerConfig{
+		"github": &McpStdioServerConfig{Type: McpServerTypeStdio, Command: "github-mcp"},
+	}
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{mcpInitMessage()}))
+	client := NewClientWithTransport(transport, WithMcpServers(servers), WithMcpStrict(true))
+	connectClientSafely(ctx, t, client)
+	disconnectClientSafely(t, client)
+
+	servers["jira"] = &McpStdioServerConfig{Type: McpServerTypeStdio, Command: "jira-mcp"}
+	transport = newClientMockTransportWithOptions(WithClientResponseMessages([]Message{mcpInitMessage()}))
+	client = NewClientWithTransport(transport, WithMcpServers(servers), WithMcpStrict(true))
+	err := client.Connect(ctx)
+	if !errors.Is(err, ErrMcpUnavailable) || !strings.Contains(err.Error(), "jira (missing)") {
+		t.Fatalf("Expected ErrMcpUnavailable naming jira, got %v", err)
+	}
+	if !transport.closed {
+		t.Error("Expected the transport to be closed after a failed start")
+	}
+
+	// Servers from the CLI's settings are not required
+	transport = newClientMockTransportWithOptions(WithClientResponseMessages([]Message{mcpInitMessage()}))
+	client = NewClientWithTransport(transport, WithMcpStrict(true))
+	connectClientSafely(ctx, t, client)
+	disconnectClientSafely(t, client)
+
+	client = NewClientWithTransport(newClientMockTransport(), WithMcpStrict(true), WithInitTimeout(20*time.Millisecond))
+	if err := client.Connect(ctx); !errors.Is(err, ErrInitTimeout) {
+		t.Errorf("Expected ErrInitTimeout without an init message, got %v", err)
+	}
+}
+
+// mcpInitMessage reports github connected with two tools and slack failed.
+func mcpInitMessage() *SystemMessage {
+	init := initMessage("Read", "mcp__github__create_issue", "mcp__github__list_issues")
+	init.Data["mcp_servers"] = []any{
+		map[string]any{"name": "github", "status": "connected"},
+		map[string]any{"name": "slack", "status": "failed"},
+	}
+	return init
+}
+
+func setupMcpHealthTestContext(t *testing.T) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
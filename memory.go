@@ -0,0 +1,37 @@
+package claudecode
+
+import "github.com/severity1/claude-code-sdk-go/internal/shared"
+
+// MemorySource identifies where a memory file comes from.
+type MemorySource = shared.MemorySource
+
+// Re-export memory file sources
+const (
+	// MemorySourceUser is the user's ~/.claude/CLAUDE.md.
+	MemorySourceUser = shared.MemorySourceUser
+	// MemorySourceProject is a CLAUDE.md of the project.
+	MemorySourceProject = shared.MemorySourceProject
+	// MemorySourceLocal is a CLAUDE.local.md of the project.
+	MemorySourceLocal = shared.MemorySourceLocal
+	// MemorySourceInjected is a file added with WithMemoryFiles.
+	MemorySourceInjected = shared.MemorySourceInjected
+)
+
+// MemoryFile is a CLAUDE.md file whose instructions the agent sees.
+type MemoryFile = shared.MemoryFile
+
+// memoryFiles lists the memory files a session loads: those the CLI
+// reported in its init message, if it has arrived, followed by the injected
+// files.
+func memoryFiles(options *Options, init *InitInfo) []MemoryFile {
+	var files []MemoryFile
+	if init != nil {
+		files = append(files, init.MemoryFiles...)
+	}
+	if options != nil {
+		for _, path := range options.MemoryFiles {
+			files = append(files, MemoryFile{Path: path, Source: MemorySourceInjected})
+		}
+	}
+	return files
+}
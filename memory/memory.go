@@ -0,0 +1,256 @@
+// Package memory carries a conversation over many sessions, for long-lived
+// assistants whose users expect them to remember earlier conversations.
+//
+// A Memory records the text turns of each session: the user's prompts and
+// the agent's replies, without tool traffic. When a new session starts, a
+// Strategy condenses the recorded turns and the result is appended to the
+// session's system prompt. Three strategies are provided: SlidingWindow
+// keeps the latest turns verbatim, Summary summarizes older turns and keeps
+// recent ones, and Entities keeps facts about the people, projects and
+// other things mentioned:
+//
+//	mem, err := memory.Load("assistant-memory.json", &memory.Summary{Keep: 6})
+//	if err != nil {
+//		return err
+//	}
+//	recall, err := mem.Option(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	client := claudecode.NewClient(recall, mem.Recorder())
+//	// ... run the session ...
+//	err = mem.Save("assistant-memory.json")
+//
+// Summary and Entities condense turns with an agent by default, so
+// rendering memory may run a query; both reuse earlier work and only
+// process turns added since.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Roles of a Turn.
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// promptHeader introduces the memory in the system prompt.
+const promptHeader = "You are continuing an ongoing relationship with this user. " +
+	"What you remember from earlier conversations:\n\n"
+
+// Turn is what one side said in a conversation.
+type Turn struct {
+	Role string `json:"role"`
+	Text string `json:"text"`
+}
+
+// Strategy condenses the turns of earlier sessions into text for a new
+// session's system prompt.
+type Strategy interface {
+	// Render returns the memory of turns, or "" to inject nothing. turns
+	// only grows between calls.
+	Render(ctx context.Context, turns []Turn) (string, error)
+}
+
+// Memory records conversation turns across sessions. It is safe for
+// concurrent use.
+type Memory struct {
+	strategy Strategy
+
+	mu    sync.Mutex
+	turns []Turn
+}
+
+// New creates an empty Memory condensed by strategy.
+func New(strategy Strategy) *Memory {
+	return &Memory{strategy: strategy}
+}
+
+// memoryFile is the format of a saved Memory.
+type memoryFile struct {
+	Turns []Turn `json:"turns"`
+}
+
+// Load reads the turns saved at path into a Memory condensed by strategy.
+// A missing file gives an empty Memory. Strategy state is not saved, so a
+// Summary or Entities strategy processes the loaded turns again when first
+// rendered.
+func Load(path string, strategy Strategy) (*Memory, error) {
+	m := New(strategy)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading memory: %w", err)
+	}
+	var file memoryFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decoding memory %s: %w", path, err)
+	}
+	m.turns = file.Turns
+	return m, nil
+}
+
+// Save writes the recorded turns to path.
+func (m *Memory) Save(path string) error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(memoryFile{Turns: m.turns}, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding memory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("writing memory: %w", err)
+	}
+	return nil
+}
+
+// Add records turns. Consecutive turns of the same role are merged.
+func (m *Memory) Add(turns ...Turn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, turn := range turns {
+		m.add(turn)
+	}
+}
+
+func (m *Memory) add(turn Turn) {
+	turn.Text = strings.TrimSpace(turn.Text)
+	if turn.Text == "" {
+		return
+	}
+	if n := len(m.turns); n > 0 && m.turns[n-1].Role == turn.Role {
+		m.turns[n-1].Text += "\n\n" + turn.Text
+		return
+	}
+	m.turns = append(m.turns, turn)
+}
+
+// AddMessages records the assistant's replies in messages, as received
+// from Query or a Client. The CLI does not echo prompts, so record them
+// with Add or use Recorder.
+func (m *Memory) AddMessages(messages []claudecode.Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range messages {
+		if text, ok := replyText(msg); ok {
+			m.add(Turn{Role: RoleAssistant, Text: text})
+		}
+	}
+}
+
+// Turns returns the recorded turns.
+func (m *Memory) Turns() []Turn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Turn(nil), m.turns...)
+}
+
+// Recorder returns a client option recording the session into the memory:
+// each prompt sent and each reply received. Prompts sent with context
+// blocks are recorded without the context.
+func (m *Memory) Recorder() claudecode.Option {
+	return func(o *claudecode.Options) {
+		o.PromptInterceptors = append(o.PromptInterceptors, func(_ context.Context, msg *claudecode.UserMessage) error {
+			if text := promptText(msg.Content); text != "" {
+				m.Add(Turn{Role: RoleUser, Text: text})
+			}
+			return nil
+		})
+		o.MessageObservers = append(o.MessageObservers, func(msg claudecode.Message) {
+			if text, ok := replyText(msg); ok {
+				m.Add(Turn{Role: RoleAssistant, Text: text})
+			}
+		})
+	}
+}
+
+// SystemPrompt renders the memory for a new session's system prompt. It
+// returns "" when there is nothing to remember.
+func (m *Memory) SystemPrompt(ctx context.Context) (string, error) {
+	turns := m.Turns()
+	if len(turns) == 0 {
+		return "", nil
+	}
+	rendered, err := m.strategy.Render(ctx, turns)
+	if err != nil {
+		return "", fmt.Errorf("rendering memory: %w", err)
+	}
+	if rendered == "" {
+		return "", nil
+	}
+	return promptHeader + rendered, nil
+}
+
+// Option renders the memory and returns an option appending it to the
+// system prompt. It replaces any appended system prompt set before it.
+func (m *Memory) Option(ctx context.Context) (claudecode.Option, error) {
+	prompt, err := m.SystemPrompt(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func(o *claudecode.Options) {
+		if prompt != "" {
+			o.AppendSystemPrompt = &prompt
+		}
+	}, nil
+}
+
+// replyText returns the text of a top-level assistant message. Subagent
+// messages are left out, as the user never saw them.
+func replyText(msg claudecode.Message) (string, bool) {
+	assistant, ok := msg.(*claudecode.AssistantMessage)
+	if !ok || assistant.ParentToolUseID != nil {
+		return "", false
+	}
+	var parts []string
+	for _, block := range assistant.Content {
+		if text, ok := block.(*claudecode.TextBlock); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n"), len(parts) > 0
+}
+
+// promptText returns the text of a prompt: the string, or the last text
+// block, which follows any context blocks.
+func promptText(content any) string {
+	switch content := content.(type) {
+	case string:
+		return content
+	case []claudecode.ContentBlock:
+		for i := len(content) - 1; i >= 0; i-- {
+			if text, ok := content[i].(*claudecode.TextBlock); ok {
+				return text.Text
+			}
+		}
+	}
+	return ""
+}
+
+// formatTurns renders turns as a labeled dialogue.
+func formatTurns(turns []Turn) string {
+	var sb strings.Builder
+	for i, turn := range turns {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		label := "User"
+		if turn.Role == RoleAssistant {
+			label = "Assistant"
+		}
+		fmt.Fprintf(&sb, "%s: %s", label, turn.Text)
+	}
+	return sb.String()
+}
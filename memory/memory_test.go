@@ -0,0 +1,145 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestMemoryRecorder(t *testing.T) {
+	mem := New(SlidingWindow{})
+	options := claudecode.NewOptions(mem.Recorder())
+	if len(options.PromptInterceptors) != 1 || len(options.MessageObservers) != 1 {
+		t.Fatalf("Expected an interceptor and an observer, got %d and %d",
+			len(options.PromptInterceptors), len(options.MessageObservers))
+	}
+	intercept, observe := options.PromptInterceptors[0], options.MessageObservers[0]
+	ctx := context.Background()
+
+	if err := intercept(ctx, &claudecode.UserMessage{Content: "My name is Ada."}); err != nil {
+		t.Fatalf("Interceptor failed: %v", err)
+	}
+	observe(&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+		&claudecode.TextBlock{Text: "Nice to meet you, Ada."},
+		&claudecode.ToolUseBlock{Name: "Read"},
+	}})
+	parent := "toolu_1"
+	observe(&claudecode.AssistantMessage{ParentToolUseID: &parent, Content: []claudecode.ContentBlock{
+		&claudecode.TextBlock{Text: "subagent chatter"},
+	}})
+	observe(&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{&claudecode.TextBlock{Text: "How can I help?"}}})
+	observe(&claudecode.ResultMessage{})
+	if err := intercept(ctx, &claudecode.UserMessage{Content: []claudecode.ContentBlock{
+		&claudecode.TextBlock{Text: "<context>notes.md</context>"},
+		&claudecode.TextBlock{Text: "Plan my week."},
+	}}); err != nil {
+		t.Fatalf("Interceptor failed: %v", err)
+	}
+
+	want := []Turn{
+		{Role: RoleUser, Text: "My name is Ada."},
+		{Role: RoleAssistant, Text: "Nice to meet you, Ada.\n\nHow can I help?"},
+		{Role: RoleUser, Text: "Plan my week."},
+	}
+	if got := mem.Turns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected turns %+v, got %+v", want, got)
+	}
+}
+
+func TestMemoryAddMessages(t *testing.T) {
+	mem := New(SlidingWindow{})
+	mem.Add(Turn{Role: RoleUser, Text: "  Hi  "}, Turn{Role: RoleUser, Text: ""})
+	mem.AddMessages([]claudecode.Message{
+		&claudecode.SystemMessage{Subtype: "init"},
+		&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{&claudecode.TextBlock{Text: "Hello"}}},
+	})
+
+	want := []Turn{{Role: RoleUser, Text: "Hi"}, {Role: RoleAssistant, Text: "Hello"}}
+	if got := mem.Turns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected turns %+v, got %+v", want, got)
+	}
+}
+
+func TestMemorySystemPrompt(t *testing.T) {
+	ctx := context.Background()
+	mem := New(SlidingWindow{})
+
+	prompt, err := mem.SystemPrompt(ctx)
+	if err != nil || prompt != "" {
+		t.Errorf("Expected no prompt for an empty memory, got %q, %v", prompt, err)
+	}
+	option, err := mem.Option(ctx)
+	if err != nil {
+		t.Fatalf("Option failed: %v", err)
+	}
+	if options := claudecode.NewOptions(option); options.AppendSystemPrompt != nil {
+		t.Errorf("Expected no appended system prompt, got %q", *options.AppendSystemPrompt)
+	}
+
+	mem.Add(Turn{Role: RoleUser, Text: "I prefer tabs."}, Turn{Role: RoleAssistant, Text: "Noted."})
+	option, err = mem.Option(ctx)
+	if err != nil {
+		t.Fatalf("Option failed: %v", err)
+	}
+	options := claudecode.NewOptions(option)
+	if options.AppendSystemPrompt == nil {
+		t.Fatal("Expected an appended system prompt")
+	}
+	for _, fragment := range []string{promptHeader, "User: I prefer tabs.", "Assistant: Noted."} {
+		if !strings.Contains(*options.AppendSystemPrompt, fragment) {
+			t.Errorf("Expected the system prompt to contain %q, got %q", fragment, *options.AppendSystemPrompt)
+		}
+	}
+}
+
+func TestMemorySystemPromptError(t *testing.T) {
+	failure := errors.New("summarizer down")
+	mem := New(&Summary{Keep: 1, Summarize: func(context.Context, string, []Turn) (string, error) {
+		return "", failure
+	}})
+	mem.Add(Turn{Role: RoleUser, Text: "one"}, Turn{Role: RoleAssistant, Text: "two"})
+
+	if _, err := mem.Option(context.Background()); !errors.Is(err, failure) {
+		t.Errorf("Expected the summarizer error, got %v", err)
+	}
+}
+
+func TestMemorySaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.json")
+
+	mem, err := Load(path, SlidingWindow{})
+	if err != nil {
+		t.Fatalf("Load of a missing file failed: %v", err)
+	}
+	if len(mem.Turns()) != 0 {
+		t.Errorf("Expected an empty memory, got %+v", mem.Turns())
+	}
+
+	mem.Add(Turn{Role: RoleUser, Text: "Remember the milk."}, Turn{Role: RoleAssistant, Text: "Will do."})
+	if err := mem.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(path, SlidingWindow{})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Turns(), mem.Turns()) {
+		t.Errorf("Expected loaded turns %+v, got %+v", mem.Turns(), loaded.Turns())
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.json")
+	if err := os.WriteFile(path, []byte("{turns"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := Load(path, SlidingWindow{}); err == nil {
+		t.Error("Expected an error loading invalid JSON")
+	}
+}
@@ -0,0 +1,371 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Defaults for strategies whose limits are not set.
+const (
+	// DefaultWindowTurns is how many turns a SlidingWindow keeps.
+	DefaultWindowTurns = 10
+	// DefaultKeepTurns is how many recent turns a Summary keeps verbatim.
+	DefaultKeepTurns = 6
+)
+
+// ErrEmptySummary is returned when a summarizer agent replies with no text.
+var ErrEmptySummary = errors.New("summarizer returned no summary")
+
+// RunFunc runs a condensing query. claudecode.Run is the default.
+type RunFunc func(ctx context.Context, spec claudecode.RunSpec) (*claudecode.RunResult, error)
+
+// SlidingWindow remembers the latest turns verbatim and forgets the rest.
+type SlidingWindow struct {
+	// MaxTurns is how many turns to keep; the default is
+	// DefaultWindowTurns.
+	MaxTurns int
+	// MaxBytes, if set, drops the oldest kept turns until the rest fit.
+	// The latest turn is always kept.
+	MaxBytes int
+}
+
+// Render returns the latest turns.
+func (w SlidingWindow) Render(_ context.Context, turns []Turn) (string, error) {
+	maxTurns := w.MaxTurns
+	if maxTurns <= 0 {
+		maxTurns = DefaultWindowTurns
+	}
+	if len(turns) > maxTurns {
+		turns = turns[len(turns)-maxTurns:]
+	}
+	if w.MaxBytes > 0 {
+		size := 0
+		start := len(turns)
+		for start > 0 {
+			size += len(turns[start-1].Text)
+			if size > w.MaxBytes && start < len(turns) {
+				break
+			}
+			start--
+		}
+		turns = turns[start:]
+	}
+	if len(turns) == 0 {
+		return "", nil
+	}
+	return "Most recent conversation:\n\n" + formatTurns(turns), nil
+}
+
+// SummarizeFunc folds turns into the summary of the turns before them,
+// which is "" at first, and returns the new summary.
+type SummarizeFunc func(ctx context.Context, previous string, turns []Turn) (string, error)
+
+// Summary remembers older turns as a running summary and recent turns
+// verbatim. Turns are summarized once: each Render only summarizes the
+// turns that left the recent window since the last one. Use a Summary
+// with a single Memory.
+type Summary struct {
+	// Keep is how many recent turns to keep verbatim; the default is
+	// DefaultKeepTurns.
+	Keep int
+	// Summarize condenses older turns; the default is AgentSummarizer().
+	Summarize SummarizeFunc
+
+	mu         sync.Mutex
+	summary    string
+	summarized int
+}
+
+// Render returns the summary of older turns followed by the recent turns.
+func (s *Summary) Render(ctx context.Context, turns []Turn) (string, error) {
+	keep := s.Keep
+	if keep <= 0 {
+		keep = DefaultKeepTurns
+	}
+	split := len(turns) - keep
+	if split < 0 {
+		split = 0
+	}
+	older, recent := turns[:split], turns[split:]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.summarized > len(older) {
+		// Not the turns summarized before; start over
+		s.summary, s.summarized = "", 0
+	}
+	if len(older) > s.summarized {
+		summarize := s.Summarize
+		if summarize == nil {
+			summarize = AgentSummarizer()
+		}
+		summary, err := summarize(ctx, s.summary, older[s.summarized:])
+		if err != nil {
+			return "", fmt.Errorf("summarizing turns: %w", err)
+		}
+		s.summary, s.summarized = strings.TrimSpace(summary), len(older)
+	}
+
+	var parts []string
+	if s.summary != "" {
+		parts = append(parts, "Summary of earlier conversations:\n"+s.summary)
+	}
+	if len(recent) > 0 {
+		parts = append(parts, "Most recent conversation:\n\n"+formatTurns(recent))
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// ExtractFunc returns facts stated in turns, keyed by the entity they are
+// about: a person, project, preference or anything else worth recalling.
+// known holds the facts extracted so far.
+type ExtractFunc func(ctx context.Context, known map[string][]string, turns []Turn) (map[string][]string, error)
+
+// Entities remembers facts about the entities mentioned in the
+// conversation rather than the conversation itself. Turns are processed
+// once: each Render only extracts facts from turns added since the last.
+// Use an Entities with a single Memory.
+type Entities struct {
+	// Extract finds facts in turns; the default is AgentExtractor().
+	Extract ExtractFunc
+
+	mu        sync.Mutex
+	facts     map[string][]string
+	processed int
+}
+
+// Render returns the known facts, grouped by entity.
+func (e *Entities) Render(ctx context.Context, turns []Turn) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.processed > len(turns) {
+		// Not the turns processed before; start over
+		e.facts, e.processed = nil, 0
+	}
+	if len(turns) > e.processed {
+		extract := e.Extract
+		if extract == nil {
+			extract = AgentExtractor()
+		}
+		found, err := extract(ctx, e.Facts(), turns[e.processed:])
+		if err != nil {
+			return "", fmt.Errorf("extracting entities: %w", err)
+		}
+		e.merge(found)
+		e.processed = len(turns)
+	}
+
+	if len(e.facts) == 0 {
+		return "", nil
+	}
+	names := make([]string, 0, len(e.facts))
+	for name := range e.facts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	sb.WriteString("Known entities:")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "\n- %s: %s", name, strings.Join(e.facts[name], "; "))
+	}
+	return sb.String(), nil
+}
+
+// Facts returns a copy of the facts extracted so far.
+func (e *Entities) Facts() map[string][]string {
+	facts := make(map[string][]string, len(e.facts))
+	for name, list := range e.facts {
+		facts[name] = append([]string(nil), list...)
+	}
+	return facts
+}
+
+// merge adds new facts, skipping ones already known.
+func (e *Entities) merge(found map[string][]string) {
+	if e.facts == nil {
+		e.facts = make(map[string][]string)
+	}
+	for name, list := range found {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		for _, fact := range list {
+			fact = strings.TrimSpace(fact)
+			if fact != "" && !containsString(e.facts[name], fact) {
+				e.facts[name] = append(e.facts[name], fact)
+			}
+		}
+	}
+}
+
+// Combine renders several strategies in order, for example Entities for
+// lasting facts with a SlidingWindow for the latest exchange.
+func Combine(strategies ...Strategy) Strategy {
+	return combined(strategies)
+}
+
+type combined []Strategy
+
+func (c combined) Render(ctx context.Context, turns []Turn) (string, error) {
+	var parts []string
+	for _, strategy := range c {
+		rendered, err := strategy.Render(ctx, turns)
+		if err != nil {
+			return "", err
+		}
+		if rendered != "" {
+			parts = append(parts, rendered)
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// AgentSummarizer returns a SummarizeFunc that asks an agent to update the
+// summary. opts configure its queries, typically a small model; the agent
+// is restricted to read-only tools after them.
+func AgentSummarizer(opts ...claudecode.Option) SummarizeFunc {
+	return agentSummarizer(claudecode.Run, opts)
+}
+
+func agentSummarizer(run RunFunc, opts []claudecode.Option) SummarizeFunc {
+	return func(ctx context.Context, previous string, turns []Turn) (string, error) {
+		var sb strings.Builder
+		sb.WriteString("You maintain the memory of an assistant's conversations with a user. " +
+			"Update the summary with the new turns. Keep facts, decisions, preferences and open tasks; " +
+			"drop small talk. Reply with only the updated summary.\n\n")
+		if previous != "" {
+			fmt.Fprintf(&sb, "Current summary:\n%s\n\n", previous)
+		}
+		fmt.Fprintf(&sb, "New turns:\n%s", formatTurns(turns))
+
+		result, err := run(ctx, claudecode.RunSpec{Prompt: sb.String(), Options: agentOptions(opts)})
+		if err != nil {
+			return "", err
+		}
+		if !result.Success {
+			return "", fmt.Errorf("summarizer run failed")
+		}
+		summary := strings.TrimSpace(result.Text)
+		if summary == "" {
+			return "", ErrEmptySummary
+		}
+		return summary, nil
+	}
+}
+
+// extraction is the extractor agent's answer.
+type extraction struct {
+	Entities []struct {
+		Name  string   `json:"name"`
+		Facts []string `json:"facts"`
+	} `json:"entities"`
+}
+
+// extractionSchema is the JSON Schema of the extractor agent's answer.
+func extractionSchema() map[string]any {
+	return map[string]any{
+		"type":     "object",
+		"required": []any{"entities"},
+		"properties": map[string]any{
+			"entities": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type":     "object",
+					"required": []any{"name", "facts"},
+					"properties": map[string]any{
+						"name":  map[string]any{"type": "string"},
+						"facts": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// AgentExtractor returns an ExtractFunc that asks an agent for the facts in
+// the turns. opts configure its queries, typically a small model; the agent
+// is restricted to read-only tools after them.
+func AgentExtractor(opts ...claudecode.Option) ExtractFunc {
+	return agentExtractor(claudecode.Run, opts)
+}
+
+func agentExtractor(run RunFunc, opts []claudecode.Option) ExtractFunc {
+	return func(ctx context.Context, known map[string][]string, turns []Turn) (map[string][]string, error) {
+		var sb strings.Builder
+		sb.WriteString("You maintain the memory of an assistant's conversations with a user. " +
+			"List the lasting facts the new turns state about people, projects, preferences and other entities. " +
+			"Use the names of known entities where they apply and leave out facts already known.\n\n")
+		if len(known) > 0 {
+			encoded, err := json.Marshal(known)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&sb, "Known entities:\n%s\n\n", encoded)
+		}
+		fmt.Fprintf(&sb, "New turns:\n%s\n\n", formatTurns(turns))
+		sb.WriteString(`Reply with only a JSON object {"entities": [{"name": "<entity>", "facts": ["<fact>"]}]}.`)
+
+		options := append(agentOptions(opts), claudecode.WithJSONSchema(extractionSchema()))
+		result, err := run(ctx, claudecode.RunSpec{Prompt: sb.String(), Options: options})
+		if err != nil {
+			return nil, err
+		}
+		if !result.Success {
+			return nil, fmt.Errorf("extractor run failed")
+		}
+		var answer extraction
+		if err := decodeExtraction(result, &answer); err != nil {
+			return nil, err
+		}
+		found := make(map[string][]string, len(answer.Entities))
+		for _, entity := range answer.Entities {
+			found[entity.Name] = append(found[entity.Name], entity.Facts...)
+		}
+		return found, nil
+	}
+}
+
+// agentOptions restricts condensing agents to read-only tools.
+func agentOptions(opts []claudecode.Option) []claudecode.Option {
+	return append(append([]claudecode.Option(nil), opts...), claudecode.WithReadOnly())
+}
+
+// decodeExtraction reads the answer from the structured output, or from
+// the result text, which may be wrapped in a Markdown code fence.
+func decodeExtraction(result *claudecode.RunResult, v *extraction) error {
+	var data []byte
+	if result.Result != nil && result.Result.StructuredOutput != nil {
+		encoded, err := json.Marshal(result.Result.StructuredOutput)
+		if err != nil {
+			return err
+		}
+		data = encoded
+	} else {
+		text := strings.TrimSpace(result.Text)
+		if start, end := strings.Index(text, "{"), strings.LastIndex(text, "}"); start >= 0 && end > start {
+			text = text[start : end+1]
+		}
+		data = []byte(text)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decoding entities: %w", err)
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
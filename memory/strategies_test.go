@@ -0,0 +1,242 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	ctx := context.Background()
+	turns := numberedTurns(5)
+
+	got, err := SlidingWindow{MaxTurns: 2}.Render(ctx, turns)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if want := "Most recent conversation:\n\nAssistant: turn 4\n\nUser: turn 5"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	got, _ = SlidingWindow{MaxBytes: len("turn 5") + 1}.Render(ctx, turns)
+	if strings.Contains(got, "turn 4") || !strings.Contains(got, "turn 5") {
+		t.Errorf("Expected only the latest turn within the byte limit, got %q", got)
+	}
+	got, _ = SlidingWindow{MaxBytes: 1}.Render(ctx, turns)
+	if !strings.Contains(got, "turn 5") {
+		t.Errorf("Expected the latest turn kept over the byte limit, got %q", got)
+	}
+
+	if got, _ := (SlidingWindow{}).Render(ctx, nil); got != "" {
+		t.Errorf("Expected nothing for no turns, got %q", got)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	ctx := context.Background()
+	var calls [][]Turn
+	summary := &Summary{Keep: 2, Summarize: func(_ context.Context, previous string, turns []Turn) (string, error) {
+		calls = append(calls, turns)
+		texts := []string{}
+		if previous != "" {
+			texts = append(texts, previous)
+		}
+		for _, turn := range turns {
+			texts = append(texts, turn.Text)
+		}
+		return strings.Join(texts, ", "), nil
+	}}
+
+	got, err := summary.Render(ctx, numberedTurns(2))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if len(calls) != 0 || strings.Contains(got, "Summary") {
+		t.Errorf("Expected no summary while all turns are recent, got %q", got)
+	}
+
+	got, err = summary.Render(ctx, numberedTurns(5))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	want := "Summary of earlier conversations:\nturn 1, turn 2, turn 3\n\n" +
+		"Most recent conversation:\n\nAssistant: turn 4\n\nUser: turn 5"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	got, _ = summary.Render(ctx, numberedTurns(6))
+	if len(calls) != 2 || len(calls[1]) != 1 || calls[1][0].Text != "turn 4" {
+		t.Errorf("Expected only the new older turn summarized, got calls %+v", calls)
+	}
+	if !strings.Contains(got, "turn 1, turn 2, turn 3, turn 4\n") {
+		t.Errorf("Expected the summary extended, got %q", got)
+	}
+
+	summary.Render(ctx, numberedTurns(6))
+	if len(calls) != 2 {
+		t.Errorf("Expected no summarizing without new turns, got %d calls", len(calls))
+	}
+
+	summary.Render(ctx, numberedTurns(3))
+	if len(calls) != 3 || len(calls[2]) != 1 {
+		t.Errorf("Expected the summary restarted for fewer turns, got calls %+v", calls)
+	}
+}
+
+func TestEntities(t *testing.T) {
+	ctx := context.Background()
+	var known []map[string][]string
+	entities := &Entities{Extract: func(_ context.Context, facts map[string][]string, turns []Turn) (map[string][]string, error) {
+		known = append(known, facts)
+		found := map[string][]string{}
+		for _, turn := range turns {
+			switch turn.Text {
+			case "turn 1":
+				found["Ada"] = []string{"prefers tabs"}
+			case "turn 3":
+				found["Ada"] = []string{"prefers tabs", "works on Atlas"}
+				found["Atlas"] = []string{" written in Go "}
+				found[" "] = []string{"ignored"}
+			}
+		}
+		return found, nil
+	}}
+
+	got, err := entities.Render(ctx, numberedTurns(2))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if want := "Known entities:\n- Ada: prefers tabs"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	got, err = entities.Render(ctx, numberedTurns(3))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if want := "Known entities:\n- Ada: prefers tabs; works on Atlas\n- Atlas: written in Go"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if len(known) != 2 || !reflect.DeepEqual(known[1], map[string][]string{"Ada": {"prefers tabs"}}) {
+		t.Errorf("Expected the known facts passed to the extractor, got %+v", known)
+	}
+}
+
+func TestEntitiesError(t *testing.T) {
+	failure := errors.New("extractor down")
+	entities := &Entities{Extract: func(context.Context, map[string][]string, []Turn) (map[string][]string, error) {
+		return nil, failure
+	}}
+	if _, err := entities.Render(context.Background(), numberedTurns(1)); !errors.Is(err, failure) {
+		t.Errorf("Expected the extractor error, got %v", err)
+	}
+}
+
+func TestCombine(t *testing.T) {
+	entities := &Entities{Extract: func(context.Context, map[string][]string, []Turn) (map[string][]string, error) {
+		return map[string][]string{"Ada": {"likes Go"}}, nil
+	}}
+	got, err := Combine(entities, SlidingWindow{MaxTurns: 1}).Render(context.Background(), numberedTurns(2))
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if want := "Known entities:\n- Ada: likes Go\n\nMost recent conversation:\n\nAssistant: turn 2"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestAgentSummarizer(t *testing.T) {
+	var spec claudecode.RunSpec
+	summarize := agentSummarizer(func(_ context.Context, s claudecode.RunSpec) (*claudecode.RunResult, error) {
+		spec = s
+		return &claudecode.RunResult{Success: true, Text: "  Ada prefers tabs.  "}, nil
+	}, []claudecode.Option{claudecode.WithModel("haiku")})
+
+	summary, err := summarize(context.Background(), "Ada is a user.", numberedTurns(1))
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if summary != "Ada prefers tabs." {
+		t.Errorf("Expected the trimmed summary, got %q", summary)
+	}
+	for _, fragment := range []string{"Current summary:\nAda is a user.", "User: turn 1"} {
+		if !strings.Contains(spec.Prompt, fragment) {
+			t.Errorf("Expected the prompt to contain %q, got %q", fragment, spec.Prompt)
+		}
+	}
+	options := claudecode.NewOptions(spec.Options...)
+	if options.Model == nil || *options.Model != "haiku" || len(options.AllowedTools) == 0 {
+		t.Errorf("Expected the model and read-only tools, got %+v", options)
+	}
+
+	empty := agentSummarizer(func(context.Context, claudecode.RunSpec) (*claudecode.RunResult, error) {
+		return &claudecode.RunResult{Success: true}, nil
+	}, nil)
+	if _, err := empty(context.Background(), "", numberedTurns(1)); !errors.Is(err, ErrEmptySummary) {
+		t.Errorf("Expected ErrEmptySummary, got %v", err)
+	}
+	failed := agentSummarizer(func(context.Context, claudecode.RunSpec) (*claudecode.RunResult, error) {
+		return &claudecode.RunResult{Text: "error"}, nil
+	}, nil)
+	if _, err := failed(context.Background(), "", numberedTurns(1)); err == nil {
+		t.Error("Expected an error for a failed run")
+	}
+}
+
+func TestAgentExtractor(t *testing.T) {
+	var spec claudecode.RunSpec
+	extract := agentExtractor(func(_ context.Context, s claudecode.RunSpec) (*claudecode.RunResult, error) {
+		spec = s
+		return &claudecode.RunResult{Success: true, Result: &claudecode.ResultMessage{StructuredOutput: map[string]any{
+			"entities": []any{map[string]any{"name": "Ada", "facts": []any{"works on Atlas"}}},
+		}}}, nil
+	}, nil)
+
+	found, err := extract(context.Background(), map[string][]string{"Ada": {"prefers tabs"}}, numberedTurns(1))
+	if err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if want := map[string][]string{"Ada": {"works on Atlas"}}; !reflect.DeepEqual(found, want) {
+		t.Errorf("Expected %+v, got %+v", want, found)
+	}
+	if !strings.Contains(spec.Prompt, `{"Ada":["prefers tabs"]}`) {
+		t.Errorf("Expected the known entities in the prompt, got %q", spec.Prompt)
+	}
+	if options := claudecode.NewOptions(spec.Options...); options.OutputFormat == nil {
+		t.Error("Expected a JSON schema")
+	}
+
+	fenced := agentExtractor(func(context.Context, claudecode.RunSpec) (*claudecode.RunResult, error) {
+		return &claudecode.RunResult{Success: true, Text: "```json\n{\"entities\": [{\"name\": \"Go\", \"facts\": [\"is a language\"]}]}\n```"}, nil
+	}, nil)
+	found, err = fenced(context.Background(), nil, numberedTurns(1))
+	if err != nil || len(found["Go"]) != 1 {
+		t.Errorf("Expected entities from fenced text, got %+v, %v", found, err)
+	}
+
+	invalid := agentExtractor(func(context.Context, claudecode.RunSpec) (*claudecode.RunResult, error) {
+		return &claudecode.RunResult{Success: true, Text: "no entities"}, nil
+	}, nil)
+	if _, err := invalid(context.Background(), nil, numberedTurns(1)); err == nil {
+		t.Error("Expected an error for an undecodable answer")
+	}
+}
+
+// numberedTurns returns n alternating turns, starting with the user.
+func numberedTurns(n int) []Turn {
+	turns := make([]Turn, n)
+	for i := range turns {
+		role := RoleUser
+		if i%2 == 1 {
+			role = RoleAssistant
+		}
+		turns[i] = Turn{Role: role, Text: "turn " + strconv.Itoa(i+1)}
+	}
+	return turns
+}
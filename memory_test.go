@@ -0,0 +1,43 @@
+package claudecode
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClientMemoriesFromInit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	init := initMessage("Read")
+	init.Data["memory_files"] = []any{
+		map[string]any{"path": "/home/me/.claude/CLAUDE.md", "source": "user"},
+		map[string]any{"path": "/work/CLAUDE.md", "source": "project"},
+	}
+
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		init,
+		&ResultMessage{Subtype: "success"},
+	}))
+	client := NewClientWithTransport(transport, WithMemoryFiles("/etc/team.md")).(*ClientImpl)
+
+	injected := []MemoryFile{{Path: "/etc/team.md", Source: MemorySourceInjected}}
+	if got := client.Memories(); !reflect.DeepEqual(got, injected) {
+		t.Errorf("Expected only injected files before init, got %+v", got)
+	}
+
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+	awaitClientResult(ctx, t, client)
+
+	want := []MemoryFile{
+		{Path: "/home/me/.claude/CLAUDE.md", Source: MemorySourceUser},
+		{Path: "/work/CLAUDE.md", Source: MemorySourceProject},
+		{Path: "/etc/team.md", Source: MemorySourceInjected},
+	}
+	if got := client.Memories(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,163 @@
+package claudecode
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MessageKey names a message the SDK writes for people or for the agent,
+// such as the explanation sent when it denies a tool call.
+type MessageKey string
+
+// Messages of the catalog. Each documents the arguments of its format.
+const (
+	// MessageDryRun replaces each tool result with WithDryRun: the tool name.
+	MessageDryRun MessageKey = "dry_run"
+	// MessageToolNotGranted denies a tool no callback allowed: the tool name.
+	MessageToolNotGranted MessageKey = "tool_not_granted"
+	// MessagePermissionCallbackFailed denies a tool call whose permission
+	// callback returned an error or panicked.
+	MessagePermissionCallbackFailed MessageKey = "permission_callback_failed"
+	// MessagePermissionCallbackTimeout denies a tool call whose permission
+	// callback did not answer in time.
+	MessagePermissionCallbackTimeout MessageKey = "permission_callback_timeout"
+	// MessagePlanReviewFailed denies a plan whose reviewer failed: the error.
+	MessagePlanReviewFailed MessageKey = "plan_review_failed"
+	// MessagePlanRejected denies a plan rejected without feedback.
+	MessagePlanRejected MessageKey = "plan_rejected"
+	// MessageDeadlineNotice tells the agent how long it has to answer,
+	// with WithQueryDeadlinePolicy: the time left.
+	MessageDeadlineNotice MessageKey = "deadline_notice"
+	// MessageTokenLimitReached tells a tenant its token quota is used up:
+	// the tokens used, the limit and when it resets.
+	MessageTokenLimitReached MessageKey = "token_limit_reached"
+	// MessageBudgetExceeded tells a tenant its spending quota is used up:
+	// the USD spent, the limit and when it resets.
+	MessageBudgetExceeded MessageKey = "budget_exceeded"
+)
+
+// DefaultLocale is the locale of the SDK's messages, used for locales and
+// messages the catalog has no translation for.
+const DefaultLocale = "en"
+
+var (
+	catalogMu sync.RWMutex
+	// catalog holds message formats by locale and key.
+	catalog = map[string]map[MessageKey]string{
+		"en": {
+			MessageDryRun: "Dry run: the %s call was recorded but not executed. " +
+				"Assume it succeeded and continue planning the remaining steps.",
+			MessageToolNotGranted:            "permission to use %s has not been granted",
+			MessagePermissionCallbackFailed:  "Callback failed",
+			MessagePermissionCallbackTimeout: "Callback timeout",
+			MessagePlanReviewFailed:          "plan review failed: %v",
+			MessagePlanRejected:              "Plan rejected by reviewer",
+			MessageDeadlineNotice:            "Time limit: you have %s to complete this request. Prioritize the most important work and wrap up before the time runs out.",
+			MessageTokenLimitReached:         "Token limit reached: %d of %d tokens used. It resets at %s.",
+			MessageBudgetExceeded:            "Budget exceeded: $%.2f of $%.2f spent. It resets at %s.",
+		},
+		"de": {
+			MessageDryRun: "Probelauf: Der Aufruf von %s wurde aufgezeichnet, aber nicht ausgeführt. " +
+				"Nimm an, dass er erfolgreich war, und plane die restlichen Schritte weiter.",
+			MessageToolNotGranted:            "die Berechtigung zur Verwendung von %s wurde nicht erteilt",
+			MessagePermissionCallbackFailed:  "Berechtigungsprüfung fehlgeschlagen",
+			MessagePermissionCallbackTimeout: "Zeitüberschreitung bei der Berechtigungsprüfung",
+			MessagePlanReviewFailed:          "Planprüfung fehlgeschlagen: %v",
+			MessagePlanRejected:              "Plan vom Prüfer abgelehnt",
+			MessageDeadlineNotice:            "Zeitlimit: Du hast %s für diese Anfrage. Erledige zuerst das Wichtigste und schließe ab, bevor die Zeit abläuft.",
+			MessageTokenLimitReached:         "Token-Limit erreicht: %d von %d Tokens verbraucht. Es wird am %s zurückgesetzt.",
+			MessageBudgetExceeded:            "Budget überschritten: $%.2f von $%.2f ausgegeben. Es wird am %s zurückgesetzt.",
+		},
+		"es": {
+			MessageDryRun: "Simulación: la llamada a %s se registró pero no se ejecutó. " +
+				"Supón que tuvo éxito y continúa planificando los pasos restantes.",
+			MessageToolNotGranted:            "no se ha concedido permiso para usar %s",
+			MessagePermissionCallbackFailed:  "La comprobación de permisos falló",
+			MessagePermissionCallbackTimeout: "Se agotó el tiempo de la comprobación de permisos",
+			MessagePlanReviewFailed:          "la revisión del plan falló: %v",
+			MessagePlanRejected:              "El revisor rechazó el plan",
+			MessageDeadlineNotice:            "Límite de tiempo: tienes %s para completar esta solicitud. Prioriza lo más importante y termina antes de que se acabe el tiempo.",
+			MessageTokenLimitReached:         "Límite de tokens alcanzado: se usaron %d de %d tokens. Se restablece el %s.",
+			MessageBudgetExceeded:            "Presupuesto superado: se gastaron $%.2f de $%.2f. Se restablece el %s.",
+		},
+		"fr": {
+			MessageDryRun: "Simulation : l'appel à %s a été enregistré mais pas exécuté. " +
+				"Considère qu'il a réussi et continue à planifier les étapes restantes.",
+			MessageToolNotGranted:            "l'autorisation d'utiliser %s n'a pas été accordée",
+			MessagePermissionCallbackFailed:  "La vérification des autorisations a échoué",
+			MessagePermissionCallbackTimeout: "Délai dépassé pour la vérification des autorisations",
+			MessagePlanReviewFailed:          "la relecture du plan a échoué : %v",
+			MessagePlanRejected:              "Plan refusé par le relecteur",
+			MessageDeadlineNotice:            "Limite de temps : tu as %s pour traiter cette demande. Priorise l'essentiel et termine avant la fin du temps imparti.",
+			MessageTokenLimitReached:         "Limite de jetons atteinte : %d jetons utilisés sur %d. Réinitialisation le %s.",
+			MessageBudgetExceeded:            "Budget dépassé : $%.2f dépensés sur $%.2f. Réinitialisation le %s.",
+		},
+		"ja": {
+			MessageDryRun: "ドライラン: %s の呼び出しは記録されましたが、実行されていません。" +
+				"成功したものとして、残りの手順の計画を続けてください。",
+			MessageToolNotGranted:            "%s の使用は許可されていません",
+			MessagePermissionCallbackFailed:  "権限の確認に失敗しました",
+			MessagePermissionCallbackTimeout: "権限の確認がタイムアウトしました",
+			MessagePlanReviewFailed:          "計画のレビューに失敗しました: %v",
+			MessagePlanRejected:              "レビュー担当者が計画を却下しました",
+			MessageDeadlineNotice:            "制限時間: このリクエストを完了するまでの時間は %s です。最も重要な作業を優先し、時間切れになる前にまとめてください。",
+			MessageTokenLimitReached:         "トークンの上限に達しました: %d / %d トークンを使用済みです。リセット日時: %s",
+			MessageBudgetExceeded:            "予算を超過しました: $%.2f / $%.2f を使用済みです。リセット日時: %s",
+		},
+	}
+)
+
+// RegisterMessages adds or replaces the formats of a locale's messages,
+// for languages the SDK does not ship or to reword its translations.
+// Formats take the arguments documented on their key, in that order;
+// keys left out keep their current format, or fall back to English.
+//
+//	claudecode.RegisterMessages("pt-BR", map[claudecode.MessageKey]string{
+//	    claudecode.MessageToolNotGranted: "a permissão para usar %s não foi concedida",
+//	})
+func RegisterMessages(locale string, messages map[MessageKey]string) {
+	locale = normalizeLocale(locale)
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	formats := catalog[locale]
+	if formats == nil {
+		formats = make(map[MessageKey]string, len(messages))
+		catalog[locale] = formats
+	}
+	for key, format := range messages {
+		formats[key] = format
+	}
+}
+
+// LocalizedMessage formats the message key in locale, a BCP 47 tag such as
+// "de" or "pt-BR". A region without its own translation falls back to its
+// language, and a language without one to English.
+func LocalizedMessage(locale string, key MessageKey, args ...any) string {
+	return fmt.Sprintf(messageFormat(locale, key), args...)
+}
+
+func messageFormat(locale string, key MessageKey) string {
+	locale = normalizeLocale(locale)
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	candidates := []string{locale}
+	if i := strings.IndexByte(locale, '-'); i > 0 {
+		candidates = append(candidates, locale[:i])
+	}
+	for _, candidate := range append(candidates, DefaultLocale) {
+		if format, ok := catalog[candidate][key]; ok {
+			return format
+		}
+	}
+	return string(key)
+}
+
+// normalizeLocale lowercases a locale tag and accepts POSIX forms such as
+// "pt_BR.UTF-8".
+func normalizeLocale(locale string) string {
+	if i := strings.IndexByte(locale, '.'); i >= 0 {
+		locale = locale[:i]
+	}
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
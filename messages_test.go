@@ -0,0 +1,84 @@
+package claudecode
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalizedMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale string
+		key    MessageKey
+		args   []any
+		expect string
+	}{
+		{"default locale", "", MessageToolNotGranted, []any{"Bash"}, "permission to use Bash has not been granted"},
+		{"language", "de", MessagePlanRejected, nil, "Plan vom Prüfer abgelehnt"},
+		{"region falls back to language", "fr-CA", MessageToolNotGranted, []any{"Bash"}, "l'autorisation d'utiliser Bash n'a pas été accordée"},
+		{"POSIX locale", "es_MX.UTF-8", MessagePermissionCallbackTimeout, nil, "Se agotó el tiempo de la comprobación de permisos"},
+		{"unknown language falls back to English", "sv", MessageToolNotGranted, []any{"Bash"}, "permission to use Bash has not been granted"},
+		{"unknown key", "de", MessageKey("missing"), nil, "missing"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := LocalizedMessage(test.locale, test.key, test.args...); got != test.expect {
+				t.Errorf("Expected %q, got %q", test.expect, got)
+			}
+		})
+	}
+}
+
+func TestRegisterMessages(t *testing.T) {
+	RegisterMessages("pt-BR", map[MessageKey]string{
+		MessageToolNotGranted: "a permissão para usar %s não foi concedida",
+	})
+	if got := LocalizedMessage("pt_BR", MessageToolNotGranted, "Bash"); got != "a permissão para usar Bash não foi concedida" {
+		t.Errorf("Expected the registered translation, got %q", got)
+	}
+	// Keys the locale lacks are in English
+	if got := LocalizedMessage("pt-BR", MessagePlanRejected); got != "Plan rejected by reviewer" {
+		t.Errorf("Expected the English message, got %q", got)
+	}
+}
+
+func TestClientLocaleTranslatesDenials(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := NewClientWithTransport(newClientControlMockTransport(), WithDryRun(true), WithLocale("ja"))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+	protocol := client.(*ClientImpl).GetControlProtocol().(*controlProtocol)
+
+	response, err := protocol.HandleControlRequest(ctx, &ControlRequest{
+		Subtype: ControlRequestTypeCanUseTool,
+		Data:    map[string]any{"tool_name": "Write", "input": map[string]any{"file_path": "main.go"}},
+	})
+	if err != nil {
+		t.Fatalf("HandleControlRequest failed: %v", err)
+	}
+	message, _ := response.Data["message"].(string)
+	if !strings.HasPrefix(message, "ドライラン: Write の呼び出し") {
+		t.Errorf("Expected a Japanese dry run message, got %q", message)
+	}
+}
+
+func TestPermissionCallbackFailureLocalized(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pm := &permissionManager{locale: "de"}
+	pm.SetPermissionCallback(func(context.Context, string, map[string]any, ToolPermissionContext) (PermissionResult, error) {
+		return nil, context.DeadlineExceeded
+	})
+	result, err := pm.CheckPermission(ctx, "Bash", nil, ToolPermissionContext{})
+	if err == nil {
+		t.Fatal("Expected the callback error")
+	}
+	if result.Message() != "Berechtigungsprüfung fehlgeschlagen" {
+		t.Errorf("Expected a German denial, got %q", result.Message())
+	}
+}
@@ -0,0 +1,101 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ModelInfo describes a model the CLI can run, for model pickers.
+type ModelInfo struct {
+	// ID is the value to pass to WithModel or SetModel: an alias such as
+	// "sonnet", or a full model name.
+	ID          string
+	DisplayName string
+	Description string
+	// ContextWindow is the model's context window in tokens, or 0 if the
+	// SDK does not know it.
+	ContextWindow int
+}
+
+// builtinModels are the model aliases the CLI resolves to its current
+// models, listed when the CLI cannot be asked.
+var builtinModels = []ModelInfo{
+	{ID: "sonnet", DisplayName: "Sonnet", Description: "Best for everyday tasks", ContextWindow: 200_000},
+	{ID: "sonnet[1m]", DisplayName: "Sonnet (1M context)", Description: "Sonnet for long sessions", ContextWindow: 1_000_000},
+	{ID: "opus", DisplayName: "Opus", Description: "Most capable, for complex work", ContextWindow: 200_000},
+	{ID: "haiku", DisplayName: "Haiku", Description: "Fastest, for simple tasks", ContextWindow: 200_000},
+}
+
+// SupportedModels returns the models the CLI can run, in the order it
+// lists them. It asks the CLI with a control request; when the transport
+// does not support control requests or the CLI does not answer, it returns
+// the CLI's model aliases as the SDK knows them. Context windows the CLI
+// does not report are filled in for the aliases.
+func (c *ClientImpl) SupportedModels(ctx context.Context) ([]ModelInfo, error) {
+	c.mu.RLock()
+	connected, controlProtocol := c.connected, c.controlProtocol
+	c.mu.RUnlock()
+
+	if !connected {
+		return nil, fmt.Errorf("client not connected")
+	}
+	if controlProtocol == nil || !controlProtocol.HasControlSupport() {
+		return append([]ModelInfo(nil), builtinModels...), nil
+	}
+
+	resp, err := controlProtocol.SendRequest(ctx, &ControlRequest{Subtype: ControlRequestTypeSupportedModels})
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, ErrClientClosed) {
+			return nil, err
+		}
+		return append([]ModelInfo(nil), builtinModels...), nil
+	}
+	models := parseModelInfos(resp.Data)
+	if len(models) == 0 {
+		return append([]ModelInfo(nil), builtinModels...), nil
+	}
+	return models, nil
+}
+
+// parseModelInfos reads the models of a supported_models response, in the
+// CLI's naming: value, displayName, description and contextWindow. Entries
+// without a value are skipped.
+func parseModelInfos(data map[string]any) []ModelInfo {
+	items, _ := data["models"].([]any)
+	models := make([]ModelInfo, 0, len(items))
+	for _, item := range items {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		model := ModelInfo{}
+		model.ID, _ = entry["value"].(string)
+		if model.ID == "" {
+			continue
+		}
+		model.DisplayName, _ = entry["displayName"].(string)
+		model.Description, _ = entry["description"].(string)
+		if window, ok := entry["contextWindow"].(float64); ok {
+			model.ContextWindow = int(window)
+		}
+		if model.ContextWindow == 0 {
+			model.ContextWindow = builtinContextWindow(model.ID)
+		}
+		if model.DisplayName == "" {
+			model.DisplayName = model.ID
+		}
+		models = append(models, model)
+	}
+	return models
+}
+
+// builtinContextWindow returns the context window of a model alias, or 0.
+func builtinContextWindow(id string) int {
+	for _, model := range builtinModels {
+		if model.ID == id {
+			return model.ContextWindow
+		}
+	}
+	return 0
+}
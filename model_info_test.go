@@ -0,0 +1,96 @@
+package claudecode
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// modelsControlTransport answers supported_models requests with data, or
+// with an error response when data is nil.
+type modelsControlTransport struct {
+	*clientControlMockTransport
+	data map[string]any
+}
+
+func (m *modelsControlTransport) SendControlRequest(_ context.Context, req *ControlRequest) error {
+	m.controlMu.Lock()
+	m.controlRequests = append(m.controlRequests, req)
+	m.controlMu.Unlock()
+
+	resp := &ControlResponse{ID: req.ID, Subtype: ControlResponseTypeSuccess, Data: m.data}
+	if m.data == nil {
+		resp = &ControlResponse{ID: req.ID, Subtype: ControlResponseTypeError, Error: &ControlResponseError{Message: "unknown request"}}
+	}
+	return m.respond(resp)
+}
+
+func TestSupportedModels(t *testing.T) {
+	reported := map[string]any{"models": []any{
+		map[string]any{"value": "default", "displayName": "Default (recommended)", "description": "Sonnet"},
+		map[string]any{"value": "opus", "displayName": "Opus"},
+		map[string]any{"value": "claude-test", "contextWindow": float64(64_000)},
+		map[string]any{"displayName": "No value"},
+	}}
+	tests := []struct {
+		name   string
+		data   map[string]any
+		expect []ModelInfo
+	}{
+		{
+			name: "reported by the CLI",
+			data: reported,
+			expect: []ModelInfo{
+				{ID: "default", DisplayName: "Default (recommended)", Description: "Sonnet"},
+				{ID: "opus", DisplayName: "Opus", ContextWindow: 200_000},
+				{ID: "claude-test", DisplayName: "claude-test", ContextWindow: 64_000},
+			},
+		},
+		{name: "request failed", expect: builtinModels},
+		{name: "no models reported", data: map[string]any{}, expect: builtinModels},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			transport := &modelsControlTransport{clientControlMockTransport: newClientControlMockTransport(), data: test.data}
+			client := NewClientWithTransport(transport).(*ClientImpl)
+			connectClientSafely(ctx, t, client)
+			defer disconnectClientSafely(t, client)
+
+			models, err := client.SupportedModels(ctx)
+			assertNoError(t, err)
+			if !reflect.DeepEqual(models, test.expect) {
+				t.Errorf("Expected %+v, got %+v", test.expect, models)
+			}
+			if requests := transport.getControlRequests(); len(requests) != 1 || requests[0].Subtype != ControlRequestTypeSupportedModels {
+				t.Errorf("Expected one supported_models request, got %+v", requests)
+			}
+		})
+	}
+}
+
+func TestSupportedModelsWithoutControl(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := NewClientWithTransport(newClientMockTransport()).(*ClientImpl)
+	if _, err := client.SupportedModels(ctx); err == nil {
+		t.Error("Expected an error before connecting")
+	}
+
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+	models, err := client.SupportedModels(ctx)
+	assertNoError(t, err)
+	if !reflect.DeepEqual(models, builtinModels) {
+		t.Errorf("Expected the built-in aliases, got %+v", models)
+	}
+	// Callers own the returned slice
+	models[0].ID = "changed"
+	if builtinModels[0].ID == "changed" {
+		t.Error("Expected a copy of the built-in aliases")
+	}
+}
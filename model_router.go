@@ -0,0 +1,31 @@
+package claudecode
+
+import "context"
+
+// routeModel asks the ModelRouter of options for the model of a request
+// that would otherwise use model. spentUSD is what the session has cost so
+// far, for the remaining budget. Without a router, or when the router
+// keeps the model, model is returned.
+func routeModel(ctx context.Context, options *Options, prompt string, model *string, flags []string, spentUSD float64) *string {
+	if options.ModelRouter == nil {
+		return model
+	}
+
+	req := RouteRequest{
+		Prompt: prompt,
+		Tools:  append([]string(nil), options.AllowedTools...),
+		Flags:  flags,
+	}
+	if model != nil {
+		req.Model = *model
+	}
+	if options.MaxBudgetUSD != nil {
+		remaining := *options.MaxBudgetUSD - spentUSD
+		req.RemainingBudgetUSD = &remaining
+	}
+
+	if routed := options.ModelRouter.Route(ctx, req); routed != "" {
+		return &routed
+	}
+	return model
+}
@@ -0,0 +1,119 @@
+package claudecode
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRouteModel(t *testing.T) {
+	ctx := context.Background()
+	current := "sonnet"
+
+	// Without a router the model is kept
+	options := NewOptions(WithModel(current))
+	if got := routeModel(ctx, options, "hi", options.Model, nil, 0); got != options.Model {
+		t.Errorf("Expected the model to be kept without a router, got %v", got)
+	}
+
+	var seen RouteRequest
+	router := ModelRouterFunc(func(_ context.Context, req RouteRequest) string {
+		seen = req
+		if req.HasFlag(RouteFlagDeep) {
+			return "opus"
+		}
+		return ""
+	})
+	options = NewOptions(WithModelRouter(router), WithAllowedTools("Read", "Bash"), WithMaxBudgetUSD(2))
+
+	if got := routeModel(ctx, options, "hi", &current, nil, 0.5); got == nil || *got != "sonnet" {
+		t.Errorf("Expected an empty route to keep the model, got %v", got)
+	}
+	if seen.Prompt != "hi" || seen.Model != "sonnet" || len(seen.Tools) != 2 {
+		t.Errorf("Unexpected route request: %+v", seen)
+	}
+	if seen.RemainingBudgetUSD == nil || *seen.RemainingBudgetUSD != 1.5 {
+		t.Errorf("Expected a remaining budget of 1.5, got %v", seen.RemainingBudgetUSD)
+	}
+
+	if got := routeModel(ctx, options, "hi", nil, []string{RouteFlagDeep}, 0); got == nil || *got != "opus" {
+		t.Errorf("Expected the routed model, got %v", got)
+	}
+
+	options = NewOptions(WithModelRouter(router))
+	routeModel(ctx, options, "hi", nil, nil, 0)
+	if seen.RemainingBudgetUSD != nil {
+		t.Errorf("Expected no remaining budget without a limit, got %v", *seen.RemainingBudgetUSD)
+	}
+}
+
+func TestQueryWithTransportRoutesModel(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	var seen RouteRequest
+	router := ModelRouterFunc(func(_ context.Context, req RouteRequest) string {
+		seen = req
+		return "haiku"
+	})
+
+	transport := newQueryMockTransport(WithQueryAssistantResponse("4"))
+	iter, err := QueryWithTransport(ctx, "What is 2+2?", transport,
+		WithModelRouter(router), WithRouteFlags("quick"))
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	defer iter.Close()
+
+	if seen.Prompt != "What is 2+2?" || !seen.HasFlag("quick") {
+		t.Errorf("Expected the router to see the prompt and flags, got %+v", seen)
+	}
+}
+
+func TestClientModelRouter(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	router := ModelRouterFunc(func(_ context.Context, req RouteRequest) string {
+		switch {
+		case req.HasFlag(RouteFlagDeep):
+			return "opus"
+		case len(req.Prompt) < 10:
+			return "haiku"
+		}
+		return ""
+	})
+
+	transport := newClientControlMockTransport()
+	transport.autoResult = true
+	client := NewClientWithTransport(transport, WithModel("sonnet"), WithModelRouter(router))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	queries := []struct {
+		prompt string
+		opts   []Option
+	}{
+		{"hi", nil},
+		{"hello", nil},
+		{"a longer question", nil},
+		{"a longer question", []Option{WithRouteFlags(RouteFlagDeep)}},
+		// An explicit model bypasses the router
+		{"hi", []Option{WithModel("sonnet")}},
+	}
+	for _, q := range queries {
+		assertNoError(t, client.Query(ctx, q.prompt, q.opts...))
+		awaitClientResult(ctx, t, client)
+	}
+
+	want := []string{"haiku", "sonnet", "opus", "sonnet"}
+	requests := transport.getControlRequests()
+	if len(requests) != len(want) {
+		t.Fatalf("Expected %d control requests, got %d", len(want), len(requests))
+	}
+	for i, model := range want {
+		if requests[i].Data["model"] != model {
+			t.Errorf("Request %d: expected model %s, got %v", i, model, requests[i].Data["model"])
+		}
+	}
+}
@@ -0,0 +1,326 @@
+// Package openaicompat converts the SDK's message stream into the OpenAI
+// chat completions format, so frontends and gateways built against the
+// OpenAI streaming API can consume Claude Code sessions unchanged.
+//
+// A Transformer turns each message into "chat.completion.chunk" objects and
+// accumulates them into a final "chat.completion". Stream does both for an
+// HTTP response, writing chunks as Server-Sent Events followed by the
+// "[DONE]" marker:
+//
+//	http.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+//		iter, err := claudecode.Query(r.Context(), promptFrom(r))
+//		if err != nil {
+//			http.Error(w, err.Error(), http.StatusBadGateway)
+//			return
+//		}
+//		defer iter.Close()
+//		w.Header().Set("Content-Type", "text/event-stream")
+//		_, _ = openaicompat.Stream(r.Context(), w, iter)
+//	})
+//
+// Only the agent's top-level text reaches the output by default: tools are
+// run by the agent itself, and subagent messages are skipped. WithToolCalls
+// and WithReasoning add tool calls and thinking for frontends that display
+// them.
+package openaicompat
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Object types of the OpenAI format.
+const (
+	ObjectChunk      = "chat.completion.chunk"
+	ObjectCompletion = "chat.completion"
+)
+
+// Finish reasons of the OpenAI format.
+const (
+	FinishStop   = "stop"
+	FinishLength = "length"
+)
+
+// Chunk is a "chat.completion.chunk" object.
+type Chunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+	// Usage is set on the last chunk of a completion.
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// ChunkChoice is the single choice of a Chunk.
+type ChunkChoice struct {
+	Index int   `json:"index"`
+	Delta Delta `json:"delta"`
+	// FinishReason is set on the last chunk of a completion.
+	FinishReason *string `json:"finish_reason"`
+}
+
+// Delta is what a Chunk adds to the message.
+type Delta struct {
+	Role             string          `json:"role,omitempty"`
+	Content          string          `json:"content,omitempty"`
+	ReasoningContent string          `json:"reasoning_content,omitempty"`
+	ToolCalls        []ToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// ToolCallDelta is a tool call in a Delta. Claude Code reports whole tool
+// calls, so each is sent complete in one delta.
+type ToolCallDelta struct {
+	Index    int          `json:"index"`
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall is the function a tool call invokes, with its arguments as
+// a JSON string.
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolCall is a tool call in a completed Message.
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// Completion is a "chat.completion" object.
+type Completion struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []Choice `json:"choices"`
+	Usage   *Usage   `json:"usage,omitempty"`
+}
+
+// Choice is the single choice of a Completion.
+type Choice struct {
+	Index        int     `json:"index"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// Message is the assistant message of a Completion.
+type Message struct {
+	Role             string     `json:"role"`
+	Content          string     `json:"content"`
+	ReasoningContent string     `json:"reasoning_content,omitempty"`
+	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// Usage counts the tokens of a completion. Prompt tokens include those
+// written to and read from the prompt cache.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Option configures a Transformer.
+type Option func(*Transformer)
+
+// WithID sets the completion ID instead of a random "chatcmpl-" one.
+func WithID(id string) Option {
+	return func(t *Transformer) {
+		t.id = id
+	}
+}
+
+// WithModel sets the model reported in every chunk, instead of the model
+// of the agent's messages.
+func WithModel(model string) Option {
+	return func(t *Transformer) {
+		t.model = model
+	}
+}
+
+// WithCreated sets the creation time reported in every chunk instead of
+// the time the Transformer was made.
+func WithCreated(created time.Time) Option {
+	return func(t *Transformer) {
+		t.created = created.Unix()
+	}
+}
+
+// WithToolCalls includes the agent's tool calls as tool_calls deltas. The
+// agent runs its tools itself, so the finish reason stays "stop".
+func WithToolCalls() Option {
+	return func(t *Transformer) {
+		t.toolCalls = true
+	}
+}
+
+// WithReasoning includes the agent's thinking as reasoning_content deltas.
+func WithReasoning() Option {
+	return func(t *Transformer) {
+		t.reasoning = true
+	}
+}
+
+// Transformer converts the messages of one turn into chunks. It is not safe
+// for concurrent use.
+type Transformer struct {
+	id        string
+	model     string
+	created   int64
+	toolCalls bool
+	reasoning bool
+
+	started bool
+	done    bool
+	message Message
+	content strings.Builder
+	thought strings.Builder
+	finish  string
+	usage   *Usage
+}
+
+// NewTransformer returns a Transformer for one completion.
+func NewTransformer(opts ...Option) *Transformer {
+	t := &Transformer{created: time.Now().Unix()}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.id == "" {
+		t.id = newID()
+	}
+	return t
+}
+
+// Transform returns the chunks for msg, if any. The first chunk carries
+// the assistant role, and the ResultMessage ending the turn becomes the
+// last chunk with the finish reason and usage. Messages after it are
+// ignored.
+func (t *Transformer) Transform(msg claudecode.Message) []Chunk {
+	if t.done {
+		return nil
+	}
+	switch m := msg.(type) {
+	case *claudecode.AssistantMessage:
+		// Subagents report to the agent, not to the user
+		if m.ParentToolUseID != nil {
+			return nil
+		}
+		if t.model == "" {
+			t.model = m.Model
+		}
+		var chunks []Chunk
+		for _, block := range m.Content {
+			if delta, ok := t.delta(block); ok {
+				chunks = append(chunks, t.chunk(delta, nil))
+			}
+		}
+		return chunks
+	case *claudecode.ResultMessage:
+		t.done = true
+		t.finish = FinishStop
+		if m.Subtype == "error_max_turns" {
+			t.finish = FinishLength
+		}
+		if m.Usage != nil {
+			prompt := m.Usage.TotalInputTokens()
+			t.usage = &Usage{
+				PromptTokens:     prompt,
+				CompletionTokens: m.Usage.OutputTokens,
+				TotalTokens:      prompt + m.Usage.OutputTokens,
+			}
+		}
+		finish := t.finish
+		chunk := t.chunk(Delta{}, &finish)
+		chunk.Usage = t.usage
+		return []Chunk{chunk}
+	default:
+		return nil
+	}
+}
+
+// Done reports whether the turn's ResultMessage was transformed.
+func (t *Transformer) Done() bool {
+	return t.done
+}
+
+// Completion returns the chunks so far as a completion. Before the turn
+// ends, its finish reason is empty.
+func (t *Transformer) Completion() Completion {
+	message := t.message
+	message.Role = "assistant"
+	message.Content = t.content.String()
+	message.ReasoningContent = t.thought.String()
+	return Completion{
+		ID:      t.id,
+		Object:  ObjectCompletion,
+		Created: t.created,
+		Model:   t.model,
+		Choices: []Choice{{Message: message, FinishReason: t.finish}},
+		Usage:   t.usage,
+	}
+}
+
+// delta converts a content block, reporting false for blocks left out.
+func (t *Transformer) delta(block claudecode.ContentBlock) (Delta, bool) {
+	switch b := block.(type) {
+	case *claudecode.TextBlock:
+		if b.Text == "" {
+			return Delta{}, false
+		}
+		t.content.WriteString(b.Text)
+		return Delta{Content: b.Text}, true
+	case *claudecode.ThinkingBlock:
+		if !t.reasoning || b.Thinking == "" {
+			return Delta{}, false
+		}
+		t.thought.WriteString(b.Thinking)
+		return Delta{ReasoningContent: b.Thinking}, true
+	case *claudecode.ToolUseBlock:
+		if !t.toolCalls {
+			return Delta{}, false
+		}
+		arguments, err := json.Marshal(b.Input)
+		if err != nil || b.Input == nil {
+			arguments = []byte("{}")
+		}
+		call := ToolCall{ID: b.ToolUseID, Type: "function", Function: FunctionCall{Name: b.Name, Arguments: string(arguments)}}
+		index := len(t.message.ToolCalls)
+		t.message.ToolCalls = append(t.message.ToolCalls, call)
+		return Delta{ToolCalls: []ToolCallDelta{{Index: index, ID: call.ID, Type: call.Type, Function: call.Function}}}, true
+	default:
+		return Delta{}, false
+	}
+}
+
+// chunk wraps delta, adding the role to the first chunk.
+func (t *Transformer) chunk(delta Delta, finish *string) Chunk {
+	if !t.started {
+		t.started = true
+		delta.Role = "assistant"
+	}
+	return Chunk{
+		ID:      t.id,
+		Object:  ObjectChunk,
+		Created: t.created,
+		Model:   t.model,
+		Choices: []ChunkChoice{{Delta: delta, FinishReason: finish}},
+	}
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "chatcmpl-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,128 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestTransformerText(t *testing.T) {
+	created := time.Unix(1700000000, 0)
+	tr := NewTransformer(WithID("chatcmpl-1"), WithCreated(created))
+
+	chunks := tr.Transform(assistant("claude-sonnet-4-5",
+		&claudecode.TextBlock{Text: "Hello"},
+		&claudecode.ThinkingBlock{Thinking: "hmm"},
+		&claudecode.ToolUseBlock{ToolUseID: "tool-1", Name: "Read", Input: map[string]any{"file_path": "a.go"}},
+	))
+	chunks = append(chunks, tr.Transform(assistant("claude-sonnet-4-5", &claudecode.TextBlock{Text: ", world"}))...)
+	chunks = append(chunks, tr.Transform(result("success", 100, 20))...)
+
+	want := []string{
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"claude-sonnet-4-5","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"claude-sonnet-4-5","choices":[{"index":0,"delta":{"content":", world"},"finish_reason":null}]}`,
+		`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"claude-sonnet-4-5","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":107,"completion_tokens":20,"total_tokens":127}}`,
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("Expected %d chunks, got %d", len(want), len(chunks))
+	}
+	for i, chunk := range chunks {
+		if got := marshal(t, chunk); got != want[i] {
+			t.Errorf("Chunk %d:\nwant %s\ngot  %s", i, want[i], got)
+		}
+	}
+
+	if !tr.Done() {
+		t.Error("Expected the transformer to be done after the result")
+	}
+	if more := tr.Transform(assistant("", &claudecode.TextBlock{Text: "late"})); more != nil {
+		t.Errorf("Expected messages after the result to be ignored, got %v", more)
+	}
+
+	wantCompletion := `{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"claude-sonnet-4-5","choices":[{"index":0,"message":{"role":"assistant","content":"Hello, world"},"finish_reason":"stop"}],"usage":{"prompt_tokens":107,"completion_tokens":20,"total_tokens":127}}`
+	if got := marshal(t, tr.Completion()); got != wantCompletion {
+		t.Errorf("Completion:\nwant %s\ngot  %s", wantCompletion, got)
+	}
+}
+
+func TestTransformerToolCallsAndReasoning(t *testing.T) {
+	tr := NewTransformer(WithToolCalls(), WithReasoning(), WithModel("gpt-4o"))
+
+	chunks := tr.Transform(assistant("claude-sonnet-4-5",
+		&claudecode.ThinkingBlock{Thinking: "Look at the file"},
+		&claudecode.ToolUseBlock{ToolUseID: "tool-1", Name: "Read", Input: map[string]any{"file_path": "a.go"}},
+		&claudecode.ToolUseBlock{ToolUseID: "tool-2", Name: "Bash"},
+	))
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(chunks))
+	}
+	if chunks[0].Model != "gpt-4o" || chunks[0].Choices[0].Delta.Role != "assistant" {
+		t.Errorf("Expected the model override and role on the first chunk, got %+v", chunks[0])
+	}
+	if chunks[0].Choices[0].Delta.ReasoningContent != "Look at the file" {
+		t.Errorf("Expected reasoning content, got %+v", chunks[0].Choices[0].Delta)
+	}
+	calls := chunks[2].Choices[0].Delta.ToolCalls
+	if len(calls) != 1 || calls[0].Index != 1 || calls[0].Function.Name != "Bash" || calls[0].Function.Arguments != "{}" {
+		t.Errorf("Expected the second tool call at index 1, got %+v", calls)
+	}
+
+	tr.Transform(result("error_max_turns", 0, 0))
+	completion := tr.Completion()
+	choice := completion.Choices[0]
+	if choice.FinishReason != FinishLength {
+		t.Errorf("Expected finish reason length for max turns, got %q", choice.FinishReason)
+	}
+	if len(choice.Message.ToolCalls) != 2 || choice.Message.ToolCalls[0].Function.Arguments != `{"file_path":"a.go"}` {
+		t.Errorf("Expected both tool calls in the completion, got %+v", choice.Message.ToolCalls)
+	}
+	if choice.Message.ReasoningContent != "Look at the file" {
+		t.Errorf("Expected reasoning in the completion, got %q", choice.Message.ReasoningContent)
+	}
+}
+
+func TestTransformerSkipsSubagents(t *testing.T) {
+	tr := NewTransformer()
+	parent := "tool-1"
+	msg := assistant("claude-haiku-4-5", &claudecode.TextBlock{Text: "subagent output"})
+	msg.ParentToolUseID = &parent
+	if chunks := tr.Transform(msg); chunks != nil {
+		t.Errorf("Expected subagent messages to be skipped, got %v", chunks)
+	}
+	if chunks := tr.Transform(&claudecode.UserMessage{Content: "tool result"}); chunks != nil {
+		t.Errorf("Expected user messages to be skipped, got %v", chunks)
+	}
+
+	// The role goes on the first chunk sent, even the final one
+	chunks := tr.Transform(result("success", 0, 0))
+	if len(chunks) != 1 || chunks[0].Choices[0].Delta.Role != "assistant" || chunks[0].Usage != nil {
+		t.Errorf("Expected a final chunk with the role and no usage, got %+v", chunks)
+	}
+	if !strings.HasPrefix(tr.Completion().ID, "chatcmpl-") {
+		t.Errorf("Expected a generated chatcmpl ID, got %q", tr.Completion().ID)
+	}
+}
+
+func assistant(model string, blocks ...claudecode.ContentBlock) *claudecode.AssistantMessage {
+	return &claudecode.AssistantMessage{Model: model, Content: blocks}
+}
+
+func result(subtype string, input, output int) *claudecode.ResultMessage {
+	msg := &claudecode.ResultMessage{Subtype: subtype, IsError: subtype != "success"}
+	if input > 0 || output > 0 {
+		msg.Usage = &claudecode.Usage{InputTokens: input, OutputTokens: output, CacheCreation: 5, CacheRead: 2}
+	}
+	return msg
+}
+
+func marshal(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,62 @@
+package openaicompat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// WriteChunk writes chunk as a Server-Sent Event.
+func WriteChunk(w io.Writer, chunk Chunk) error {
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return fmt.Errorf("failed to encode chunk: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
+
+// WriteDone writes the "[DONE]" event ending an OpenAI stream.
+func WriteDone(w io.Writer) error {
+	_, err := io.WriteString(w, "data: [DONE]\n\n")
+	return err
+}
+
+// Stream writes the turn read from iter to w as chunk events, flushing
+// after each when w is an http.Flusher, and ends it with "[DONE]" once the
+// turn's ResultMessage arrives or iter runs out. It returns the completion
+// accumulated from the chunks, which is partial if reading or writing
+// failed.
+func Stream(ctx context.Context, w io.Writer, iter claudecode.MessageIterator, opts ...Option) (Completion, error) {
+	t := NewTransformer(opts...)
+	flusher, _ := w.(http.Flusher)
+	for !t.Done() {
+		msg, err := iter.Next(ctx)
+		if errors.Is(err, claudecode.ErrNoMoreMessages) {
+			break
+		}
+		if err != nil {
+			return t.Completion(), err
+		}
+		for _, chunk := range t.Transform(msg) {
+			if err := WriteChunk(w, chunk); err != nil {
+				return t.Completion(), err
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if err := WriteDone(w); err != nil {
+		return t.Completion(), err
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return t.Completion(), nil
+}
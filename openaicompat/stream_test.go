@@ -0,0 +1,105 @@
+package openaicompat
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestStream(t *testing.T) {
+	w := httptest.NewRecorder()
+	iter := &sliceIterator{messages: []claudecode.Message{
+		&claudecode.SystemMessage{Subtype: claudecode.SystemSubtypeInit},
+		assistant("claude-sonnet-4-5", &claudecode.TextBlock{Text: "Hi"}),
+		result("success", 10, 2),
+		assistant("claude-sonnet-4-5", &claudecode.TextBlock{Text: "next turn"}),
+	}}
+
+	completion, err := Stream(context.Background(), w, iter, WithID("chatcmpl-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completion.Choices[0].Message.Content != "Hi" || completion.Choices[0].FinishReason != FinishStop {
+		t.Errorf("Unexpected completion %+v", completion)
+	}
+	if len(iter.messages) != 1 {
+		t.Errorf("Expected Stream to stop reading at the result, %d messages left", len(iter.messages))
+	}
+	if !w.Flushed {
+		t.Error("Expected the response to be flushed")
+	}
+
+	events := strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %q", w.Body.String())
+	}
+	if !strings.HasPrefix(events[0], `data: {"id":"chatcmpl-1","object":"chat.completion.chunk"`) {
+		t.Errorf("Expected a chunk event, got %q", events[0])
+	}
+	if !strings.Contains(events[1], `"finish_reason":"stop"`) {
+		t.Errorf("Expected the final chunk, got %q", events[1])
+	}
+	if events[2] != "data: [DONE]" {
+		t.Errorf("Expected the done marker, got %q", events[2])
+	}
+}
+
+func TestStreamEndsWithoutResult(t *testing.T) {
+	var b strings.Builder
+	iter := &sliceIterator{messages: []claudecode.Message{assistant("", &claudecode.TextBlock{Text: "Hi"})}}
+	completion, err := Stream(context.Background(), &b, iter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(b.String(), "data: [DONE]\n\n") {
+		t.Errorf("Expected the stream to end with the done marker, got %q", b.String())
+	}
+	if completion.Choices[0].FinishReason != "" {
+		t.Errorf("Expected no finish reason without a result, got %q", completion.Choices[0].FinishReason)
+	}
+}
+
+func TestStreamReadError(t *testing.T) {
+	var b strings.Builder
+	failure := errors.New("transport closed")
+	iter := &sliceIterator{
+		messages: []claudecode.Message{assistant("", &claudecode.TextBlock{Text: "partial"})},
+		err:      failure,
+	}
+	completion, err := Stream(context.Background(), &b, iter)
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected the iterator's error, got %v", err)
+	}
+	if completion.Choices[0].Message.Content != "partial" {
+		t.Errorf("Expected the partial completion, got %+v", completion)
+	}
+	if strings.Contains(b.String(), "[DONE]") {
+		t.Error("Expected no done marker after a failure")
+	}
+}
+
+// sliceIterator yields messages, then err or ErrNoMoreMessages.
+type sliceIterator struct {
+	messages []claudecode.Message
+	err      error
+}
+
+func (it *sliceIterator) Next(context.Context) (claudecode.Message, error) {
+	if len(it.messages) == 0 {
+		if it.err != nil {
+			return nil, it.err
+		}
+		return nil, claudecode.ErrNoMoreMessages
+	}
+	msg := it.messages[0]
+	it.messages = it.messages[1:]
+	return msg, nil
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
@@ -3,6 +3,8 @@ package claudecode
 import (
 	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/severity1/claude-code-sdk-go/internal/shared"
 )
@@ -71,23 +73,101 @@ const (
 	SdkPluginTypeLocal              = shared.SdkPluginTypeLocal
 )
 
+// PermissionModes returns every permission mode the CLI accepts.
+var PermissionModes = shared.PermissionModes
+
+// ParsePermissionMode converts a string such as a flag or config value to a
+// PermissionMode, rejecting unknown modes.
+var ParsePermissionMode = shared.ParsePermissionMode
+
 // Option configures Options using the functional options pattern.
 type Option func(*Options)
 
-// WithAllowedTools sets the allowed tools list.
+// WithAllowedTools sets the allowed tools list. Entries may include a rule,
+// such as "Bash(git:*)".
 func WithAllowedTools(tools ...string) Option {
 	return func(o *Options) {
 		o.AllowedTools = tools
 	}
 }
 
-// WithDisallowedTools sets the disallowed tools list.
+// WithDisallowedTools sets the disallowed tools list. A tool listed in both
+// WithAllowedTools and WithDisallowedTools is rejected when the client
+// connects or the query starts.
 func WithDisallowedTools(tools ...string) Option {
 	return func(o *Options) {
 		o.DisallowedTools = tools
 	}
 }
 
+// readOnlyTools are the built-in tools that only read files or fetch
+// from the web.
+var readOnlyTools = []string{"Read", "Grep", "Glob", "LS", "NotebookRead", "WebFetch", "WebSearch"}
+
+// mutatingTools are the built-in tools that change files or run commands.
+var mutatingTools = []string{"Bash", "Edit", "MultiEdit", "NotebookEdit", "Write"}
+
+// WithReadOnly restricts the agent to analysis: the read-only tools (Read,
+// Grep, Glob, LS, NotebookRead, WebFetch and WebSearch) are allowed and the
+// tools that change files or run commands (Bash, Edit, MultiEdit,
+// NotebookEdit and Write) are disallowed. Tools already disallowed stay
+// disallowed. The CLI enforces disallowed tools in every permission mode.
+//
+// Each exception names a tool or a rule to allow anyway, such as "Write" or
+// "Bash(git log:*)". An exception for a disallowed tool lifts the ban on
+// that tool, so only the named rule is approved: other uses of the tool
+// need permission.
+//
+// The tools are added to the allowed and disallowed lists, so a later
+// WithAllowedTools or WithDisallowedTools replaces them. The read-only
+// tools may still read outside the working directory; add WithPathPolicy
+// to confine them.
+func WithReadOnly(exceptions ...string) Option {
+	return func(o *Options) {
+		excepted := make(map[string]bool, len(exceptions))
+		for _, exception := range exceptions {
+			name, _, _ := strings.Cut(exception, "(")
+			excepted[name] = true
+		}
+
+		// Read-only tools the caller disallowed stay disallowed
+		var allowed []string
+		for _, tool := range readOnlyTools {
+			if !containsString(o.DisallowedTools, tool) {
+				allowed = append(allowed, tool)
+			}
+		}
+		o.AllowedTools = appendMissing(o.AllowedTools, allowed...)
+		o.AllowedTools = appendMissing(o.AllowedTools, exceptions...)
+		for _, tool := range mutatingTools {
+			if !excepted[tool] {
+				o.DisallowedTools = appendMissing(o.DisallowedTools, tool)
+			}
+		}
+	}
+}
+
+// appendMissing returns a copy of list with the items not already in it
+// appended.
+func appendMissing(list []string, items ...string) []string {
+	list = append([]string(nil), list...)
+	for _, item := range items {
+		if !containsString(list, item) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 // WithTools sets available tools as a list of tool names.
 func WithTools(tools ...string) Option {
 	return func(o *Options) {
@@ -145,6 +225,25 @@ func WithMaxBudgetUSD(budget float64) Option {
 	}
 }
 
+// WithModelRouter sets a router that picks the model for each request
+// from its prompt, tools, flags and remaining budget, for example to send
+// short questions to a cheaper model. The router's choice replaces the
+// model set with WithModel; a Client query that sets WithModel itself is
+// not routed. See the router package for a configurable default.
+func WithModelRouter(router ModelRouter) Option {
+	return func(o *Options) {
+		o.ModelRouter = router
+	}
+}
+
+// WithRouteFlags passes hints such as RouteFlagDeep to the ModelRouter. On
+// a Client they can also be set per query.
+func WithRouteFlags(flags ...string) Option {
+	return func(o *Options) {
+		o.RouteFlags = append(o.RouteFlags, flags...)
+	}
+}
+
 // WithUser sets the user identifier for tracking and billing.
 func WithUser(user string) Option {
 	return func(o *Options) {
@@ -152,6 +251,22 @@ func WithUser(user string) Option {
 	}
 }
 
+// WithSessionTags labels the client's sessions, for example with a team,
+// ticket ID or environment, for cost attribution in services shared by
+// many tenants. Tags are merged with those set before. They are not sent
+// to the CLI; they appear in session summaries, webhook events, metrics
+// exported with promexporter and transcripts.
+func WithSessionTags(tags map[string]string) Option {
+	return func(o *Options) {
+		if o.SessionTags == nil {
+			o.SessionTags = make(map[string]string, len(tags))
+		}
+		for k, v := range tags {
+			o.SessionTags[k] = v
+		}
+	}
+}
+
 // WithMaxBufferSize sets the maximum buffer size for CLI output.
 func WithMaxBufferSize(size int) Option {
 	return func(o *Options) {
@@ -159,6 +274,102 @@ func WithMaxBufferSize(size int) Option {
 	}
 }
 
+// WithMaxMessageSize sets the maximum size in bytes of a single message read
+// from the CLI. Longer lines are skipped and reported as MessageTooLargeError.
+// Defaults to 1MB, or the value of WithMaxBufferSize if set.
+func WithMaxMessageSize(size int) Option {
+	return func(o *Options) {
+		o.MaxMessageSize = &size
+	}
+}
+
+// WithMaxInlineResultBytes sets the size above which base64 images in tool
+// results are decoded to temporary files instead of held in memory. Long
+// strings are moved to disk while the CLI's output is read, so such images
+// do not count towards WithMaxMessageSize. ToolResultBlock.Images reports
+// them with their File path. The files are removed when the transport
+// closes, on Disconnect or when a query's iterator is closed; copy a file
+// to keep it, or delete it earlier with ImageContent.Remove.
+func WithMaxInlineResultBytes(limit int) Option {
+	return func(o *Options) {
+		o.MaxInlineResultBytes = &limit
+	}
+}
+
+// WithMessageRecycling makes the SDK take user and assistant messages, and
+// their content blocks, from pools instead of allocating them, which cuts
+// garbage collection work for high-throughput consumers. It is off by
+// default.
+//
+// With recycling on, the caller owns each message it receives and must call
+// its Release method once, after the last use of the message. After Release
+// the message and its blocks may be reused for later messages, so neither
+// may be kept or read again; copy out what must outlive it. Strings, maps
+// and other values read from a message stay valid. Messages that are never
+// released are collected as usual.
+//
+// The SDK's own observers, such as the ToolObserver, see a message before it
+// is delivered and do not keep it. Callbacks must not keep blocks either.
+func WithMessageRecycling(enabled bool) Option {
+	return func(o *Options) {
+		o.MessageRecycling = enabled
+	}
+}
+
+// WithJSONCodec replaces encoding/json for the messages exchanged with the
+// CLI: decoding every line it writes and encoding the messages sent to it.
+// Consumers on hot paths can plug in a faster codec:
+//
+//	claudecode.WithJSONCodec(sonic.ConfigStd)
+//
+// The codec must behave like encoding/json; see JSONCodec. Incomplete and
+// invalid lines are still told apart with encoding/json, and configuration
+// passed to the CLI as flags is always encoded with it.
+func WithJSONCodec(codec JSONCodec) Option {
+	return func(o *Options) {
+		o.JSONCodec = codec
+	}
+}
+
+// WithContextLimit sets the size limit, in bytes, for context added with
+// AddContextFile and AddContextText, and the policy applied when it is
+// exceeded. Without it the limit is 256 KiB and oversized context is
+// truncated.
+func WithContextLimit(maxBytes int, policy ContextTruncationPolicy) Option {
+	return func(o *Options) {
+		o.ContextMaxBytes = &maxBytes
+		o.ContextTruncation = policy
+	}
+}
+
+// WithContextCaching marks context added with AddContextFile and
+// AddContextText as cacheable, so a long context sent again in later
+// sessions, or resent after the conversation was cleared, is read from the
+// prompt cache. The breakpoint is set on the last context block, caching
+// the system prompt and all context before the prompt. ttl is "5m", "1h",
+// or empty for the API's default.
+func WithContextCaching(ttl string) Option {
+	return func(o *Options) {
+		o.ContextCache = &CacheControl{Type: CacheControlEphemeral, TTL: ttl}
+	}
+}
+
+// WithPromptCaching turns the CLI's own prompt caching of the system
+// prompt, tools and conversation on or off. It is on by default; turning
+// it off avoids cache write costs for one-off prompts.
+func WithPromptCaching(enabled bool) Option {
+	return func(o *Options) {
+		if o.ExtraEnv == nil {
+			o.ExtraEnv = make(map[string]string)
+		}
+		if enabled {
+			delete(o.ExtraEnv, "DISABLE_PROMPT_CACHING")
+		} else {
+			o.ExtraEnv["DISABLE_PROMPT_CACHING"] = "1"
+		}
+	}
+}
+
 // WithMaxThinkingTokens sets the maximum thinking tokens.
 func WithMaxThinkingTokens(tokens int) Option {
 	return func(o *Options) {
@@ -167,12 +378,196 @@ func WithMaxThinkingTokens(tokens int) Option {
 }
 
 // WithPermissionMode sets the permission mode.
+// Modes other than the PermissionMode constants are rejected when the client
+// connects or the query starts; use ParsePermissionMode to validate strings early.
 func WithPermissionMode(mode PermissionMode) Option {
 	return func(o *Options) {
 		o.PermissionMode = &mode
 	}
 }
 
+// WithPlanReviewer sets a callback that approves or rejects the agent's plan
+// before it leaves plan mode, typically combined with
+// WithPermissionMode(PermissionModePlan). The reviewer is consulted when the
+// CLI asks permission to use the ExitPlanMode tool over the control protocol;
+// a rejection is returned to the agent with the reviewer's feedback.
+func WithPlanReviewer(reviewer PlanReviewer) Option {
+	return func(o *Options) {
+		o.PlanReviewer = reviewer
+	}
+}
+
+// WithMaxConcurrentTools limits how many tool calls run at once, for
+// example to keep an agent from starting dozens of parallel Bash processes
+// on a small machine. The client registers PreToolUse and PostToolUse hooks
+// with the CLI when it connects; once the limit is reached, it holds back the
+// answer to the next tool's PreToolUse hook until a running tool finishes.
+// Zero, the default, means no limit.
+func WithMaxConcurrentTools(n int) Option {
+	return func(o *Options) {
+		o.MaxConcurrentTools = n
+	}
+}
+
+// WithDryRun previews an agent's plan of action without letting it change
+// anything. Every tool the CLI asks permission for is denied, and the agent
+// is told the call was recorded rather than run so it keeps planning; the
+// calls are collected in the client's DryRunReport.
+//
+// Dry runs need the control protocol, so they apply to the Client only.
+// The CLI is asked to route every permission check to the client, and
+// WithAllowedTools is not passed on, but tools allowed by settings files
+// are still run without asking. Dry runs cannot be combined with the
+// acceptEdits or bypassPermissions permission modes.
+func WithDryRun(enabled bool) Option {
+	return func(o *Options) {
+		o.DryRun = enabled
+	}
+}
+
+// WithPathPolicy checks the paths of the file tools (Read, Write, Edit,
+// MultiEdit, NotebookEdit, NotebookRead, Glob, Grep and LS) with policy,
+// usually a *pathguard.Policy, before they run. Calls it rejects are
+// denied with its error and collected in the client's PathViolations.
+//
+// Path policies need the control protocol, so they apply to the Client
+// only. The CLI is asked to route permission checks to the client, and
+// file tools given to WithAllowedTools are approved by the client once
+// their paths pass, while other tools still need a permission callback to
+// be approved. The CLI runs tools without asking in the bypassPermissions
+// permission mode, which a path policy cannot be combined with.
+func WithPathPolicy(policy PathPolicy) Option {
+	return func(o *Options) {
+		o.PathPolicy = policy
+	}
+}
+
+// WithToolObserver sets a callback that receives an event when each tool
+// call starts and completes, for exporting to metrics systems. The observer
+// runs on the goroutine delivering messages, so it must not block.
+func WithToolObserver(observer ToolObserver) Option {
+	return func(o *Options) {
+		o.ToolObserver = observer
+	}
+}
+
+// WithSlowHookThreshold reports every hook run that takes longer than
+// threshold or times out to reporter, naming the event and pattern it ran
+// for, so hooks that add latency to each tool call can be found. Timings of
+// all hook runs are available from ClientImpl.HookStats. The reporter runs on the
+// goroutine that ran the hook, so it must not block.
+func WithSlowHookThreshold(threshold time.Duration, reporter SlowHookReporter) Option {
+	return func(o *Options) {
+		o.SlowHookThreshold = threshold
+		o.SlowHookReporter = reporter
+	}
+}
+
+// WithToolTimeout limits how long each tool call may run, by tool name;
+// the name "*" sets the limit of tools not listed. When a call's result does
+// not arrive in time, the client interrupts the turn, so a hung Bash
+// command does not stall the conversation. The timeout only interrupts: no
+// result is made up for the call, and the CLI reports how it ended.
+// Timeouts accumulate across calls.
+func WithToolTimeout(timeouts map[string]time.Duration) Option {
+	return func(o *Options) {
+		if o.ToolTimeouts == nil {
+			o.ToolTimeouts = make(map[string]time.Duration, len(timeouts))
+		}
+		for name, timeout := range timeouts {
+			o.ToolTimeouts[name] = timeout
+		}
+	}
+}
+
+// WithToolProgress calls fn every interval, or every
+// DefaultToolProgressInterval when interval is zero, with the tool calls in
+// flight, so UIs can show what a long build or test run is doing instead of
+// appearing frozen. fn is called while calls are in flight and once more
+// with none after the last one finishes. It runs on a goroutine of the
+// client's connection, so it must not block.
+func WithToolProgress(interval time.Duration, fn ToolProgressFunc) Option {
+	return func(o *Options) {
+		o.ToolProgressInterval = interval
+		o.ToolProgress = fn
+	}
+}
+
+// WithTurnObserver sets a callback that receives each turn when its
+// ResultMessage arrives: the prompt, the agent's messages, its tool calls
+// and the result, for analytics that would otherwise reassemble them from
+// the message stream. The observer runs on the goroutine delivering
+// messages, so it must not block.
+func WithTurnObserver(observer TurnObserver) Option {
+	return func(o *Options) {
+		o.TurnObserver = observer
+	}
+}
+
+// WithMessageObserver adds a callback that sees each message the client
+// receives from the CLI before it is delivered, for example to checkpoint
+// work when a turn's ResultMessage arrives. Observers run in the order they
+// were added, on the goroutine delivering messages: delivery waits for
+// them, which also means an observer has finished with a turn before the
+// caller sees its result. Observers must not keep messages when
+// WithMessageRecycling is enabled.
+//
+// Observers apply to the Client; the Query function does not call them.
+func WithMessageObserver(observer MessageObserver) Option {
+	return func(o *Options) {
+		o.MessageObservers = append(o.MessageObservers, observer)
+	}
+}
+
+// WithFinalizer sets a callback that receives a SessionSummary, with token
+// totals and duration, each time the client finishes tearing down a
+// session. It runs after the Stop and SessionEnd hooks, including when the
+// function passed to WithClient panics or its context is canceled.
+func WithFinalizer(fn func(summary SessionSummary)) Option {
+	return func(o *Options) {
+		o.Finalizer = fn
+	}
+}
+
+// WithStreamIntegrityChecks enables sequence tracking of the CLI's output.
+// Messages that were lost, duplicated or delivered out of order are reported
+// as StreamIntegrityError values on the error channel and recorded in the
+// validator's issues and statistics; the stream itself continues.
+func WithStreamIntegrityChecks() Option {
+	return func(o *Options) {
+		o.StreamIntegrityChecks = true
+	}
+}
+
+// WithStrictOrdering makes ordering violations in the CLI's output fatal.
+// The stream guarantees that the init SystemMessage precedes every
+// assistant, user and result message, that each ToolResultBlock follows its
+// ToolUseBlock, and that each turn ends with exactly one ResultMessage. In
+// strict mode, the first message breaking a guarantee is not delivered:
+// a StreamIntegrityError describing it is reported on the error channel
+// and the message stream ends.
+func WithStrictOrdering() Option {
+	return func(o *Options) {
+		o.StrictOrdering = true
+	}
+}
+
+// WithStrictProtocol checks every message from the CLI against an embedded
+// JSON Schema of the CLI's protocol, picked by the version in its init
+// message. A message that does not match is not delivered: a
+// *ProtocolError naming the field, the type expected and the type received
+// is reported on the error channel, and the stream continues. This turns a
+// CLI upgrade that changes a message's shape into an error at the message,
+// rather than zero values or nil fields deep in application code.
+//
+// Fields the schema does not know are allowed, so a newer CLI adding
+// fields still passes. It applies to the CLI subprocess transport.
+func WithStrictProtocol(enabled bool) Option {
+	return func(o *Options) {
+		o.StrictProtocol = enabled
+	}
+}
+
 // WithPermissionPromptToolName sets the permission prompt tool name.
 func WithPermissionPromptToolName(toolName string) Option {
 	return func(o *Options) {
@@ -180,7 +575,147 @@ func WithPermissionPromptToolName(toolName string) Option {
 	}
 }
 
-// WithContinueConversation enables conversation continuation.
+// WithPartialText makes QueryText return the text produced before a
+// failure along with the error, instead of an empty string.
+func WithPartialText() Option {
+	return func(o *Options) {
+		o.PartialText = true
+	}
+}
+
+// WithLocale sets the locale of the messages the SDK writes, a BCP 47
+// tag such as "de" or "pt-BR": the explanations of the tool calls it
+// denies and the results of tool calls it times out. Messages without a
+// translation in the catalog are in English; add translations with
+// RegisterMessages.
+func WithLocale(tag string) Option {
+	return func(o *Options) {
+		o.Locale = tag
+	}
+}
+
+// WithQueryDeadlinePolicy makes queries whose context has a deadline end
+// their turn gracefully before it: the SDK interrupts the turn policy.Margin
+// (DefaultDeadlineMargin when zero) before the deadline, so the CLI reports
+// a result with the work done so far instead of being killed mid-tool when
+// the context expires. When less than twice the margin remains, the turn
+// is interrupted halfway to the deadline instead. With AnnounceDeadline,
+// the prompt also tells the agent how long it has.
+//
+// The policy applies to the Query function and to Client.Query and
+// Client.QueryWithSession; queries queued with WithQueryQueueing are sent
+// after their caller returned and have no deadline.
+//
+//	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+//	defer cancel()
+//	text, err := claudecode.QueryText(ctx, "Triage the failing tests",
+//	    claudecode.WithQueryDeadlinePolicy(claudecode.QueryDeadlinePolicy{AnnounceDeadline: true}))
+func WithQueryDeadlinePolicy(policy QueryDeadlinePolicy) Option {
+	return func(o *Options) {
+		o.QueryDeadline = &policy
+	}
+}
+
+// WithQueryQueueing controls what Client.Query does while the previous
+// query's turn is still streaming, that is before its ResultMessage arrives.
+// By default Query returns ErrTurnInProgress. With queueing enabled, Query
+// returns immediately and the prompt is sent once the current turn ends;
+// queued prompts are sent in order, and a prompt that cannot be sent is
+// reported on the client's error channel.
+func WithQueryQueueing(enabled bool) Option {
+	return func(o *Options) {
+		o.QueryQueueing = enabled
+	}
+}
+
+// WithIdempotencyKey identifies a query, so a retried submission, for
+// example after a network error between a frontend and the service, does
+// not run an expensive turn twice. It needs WithIdempotencyStore, which
+// keeps the result of the first query with the key: a retry of a completed
+// query gets that result instead of running, as the only message of a
+// one-shot Query or as the turn of a Client query. A retry while the first
+// query runs returns ErrQueryInProgress. A query that ends in an error, or
+// cannot be sent, releases its key, so its retry runs. On a Client, set it
+// per query.
+//
+// Example:
+//
+//	store := sessionstore.NewRedisStore(redisAdapter{rdb}, "")
+//	client := claudecode.NewClient(claudecode.WithIdempotencyStore(store))
+//	// ...
+//	err := client.Query(ctx, prompt, claudecode.WithIdempotencyKey(requestID))
+func WithIdempotencyKey(key string) Option {
+	return func(o *Options) {
+		o.IdempotencyKey = key
+	}
+}
+
+// WithIdempotencyStore sets the store keeping the results of queries sent
+// with WithIdempotencyKey. The sessionstore package provides stores in
+// memory and in Redis; a store shared by the instances of a service
+// deduplicates retries across them.
+func WithIdempotencyStore(store IdempotencyStore) Option {
+	return func(o *Options) {
+		o.IdempotencyStore = store
+	}
+}
+
+// WithPromptInterceptor adds an interceptor that sees every prompt before
+// it is written to the CLI, for example to scrub secrets, enforce length
+// limits or inject policy text. Interceptors run in the order they were
+// added; each sees the changes made by the ones before it, and the first
+// error rejects the prompt.
+//
+// Interceptors apply to Client.Query, Client.QueryWithSession,
+// Client.SendUserMessage and the Query function. Messages passed to
+// QueryStream or Connect are already in wire form and are sent unchanged.
+func WithPromptInterceptor(interceptor PromptInterceptor) Option {
+	return func(o *Options) {
+		o.PromptInterceptors = append(o.PromptInterceptors, interceptor)
+	}
+}
+
+// WithInitTimeout makes Connect wait up to timeout for the CLI's init
+// message and fail with ErrInitTimeout if it does not arrive. The CLI sends
+// the message after reading its first input, so pass the opening prompt to
+// Connect when using this option.
+func WithInitTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.InitTimeout = timeout
+	}
+}
+
+// WithAutoReconnect makes a client survive the CLI exiting to restart
+// itself, as after an auto-update. When the CLI exits with one of
+// policy.ExitCodes, which are required, the client starts a new CLI
+// process resuming the session, up to policy.MaxAttempts
+// (DefaultMaxReconnects when zero) times per connection. The message and
+// error channels stay open, and messages sent during the pause wait for
+// the new process.
+//
+// A turn in progress when the CLI exits ends without a ResultMessage: its
+// response iterator returns ErrCLIRestarted, and the prompt is not resent.
+// policy.OnEvent is told when each reconnect starts and ends, so the
+// application can tell users about the pause. Other exits end the session
+// as without the option. With a custom transport, the transport must
+// report exit codes with an ExitCode() (int, bool) method, and it is
+// reconnected by calling Close and then Connect again.
+func WithAutoReconnect(policy AutoReconnectPolicy) Option {
+	return func(o *Options) {
+		o.AutoReconnect = &policy
+	}
+}
+
+// WithContinueConversation continues the most recent conversation in the
+// working directory, like the CLI's --continue flag. With Query, the
+// returned ResultMessage reports the resumed session and the turns it had
+// before the query in its Resumed field.
+//
+// Example:
+//
+//	iter, err := claudecode.Query(ctx, "And now add tests",
+//	    claudecode.WithCwd(projectDir),
+//	    claudecode.WithContinueConversation(true))
 func WithContinueConversation(continueConversation bool) Option {
 	return func(o *Options) {
 		o.ContinueConversation = continueConversation
@@ -201,6 +736,40 @@ func WithCwd(cwd string) Option {
 	}
 }
 
+// WithEphemeralWorkspace runs each connection of a client in its own new
+// temporary directory, isolated from the caller's files. The directory
+// starts as a copy of template, such as a checkout of a repository, or
+// empty when template is "". It replaces the working directory set with
+// WithCwd and is added to the allowed directories. The client's Workspace
+// method returns its path. The file tools are confined to the workspace
+// and the added directories, as with WithPathPolicy.
+//
+// The directory is removed when the client disconnects, after the
+// finalizer has run, unless WithKeepWorkspaceOnFailure is set and the
+// session failed. Workspaces apply to the Client only.
+//
+// Example:
+//
+//	client := claudecode.NewClient(
+//		claudecode.WithEphemeralWorkspace("testdata/fixture-repo"),
+//		claudecode.WithKeepWorkspaceOnFailure(true),
+//	)
+func WithEphemeralWorkspace(template string) Option {
+	return func(o *Options) {
+		o.EphemeralWorkspace = &template
+		o.ConfinePaths = true
+	}
+}
+
+// WithKeepWorkspaceOnFailure keeps an ephemeral workspace for inspection
+// when its session failed: a turn ended in an error, or the session ended
+// without Disconnect, for example because the CLI exited.
+func WithKeepWorkspaceOnFailure(keep bool) Option {
+	return func(o *Options) {
+		o.KeepWorkspaceOnFailure = keep
+	}
+}
+
 // WithAddDirs adds directories to the context.
 func WithAddDirs(dirs ...string) Option {
 	return func(o *Options) {
@@ -215,20 +784,55 @@ func WithMcpServers(servers map[string]McpServerConfig) Option {
 	}
 }
 
-// WithMaxTurns sets the maximum number of conversation turns.
+// WithMcpStrict makes Connect fail with ErrMcpUnavailable when an MCP
+// server set with WithMcpServers is not connected once the session starts.
+// Connect waits for the CLI's init message to find out, for the
+// WithInitTimeout duration or 30 seconds; the CLI sends the message after
+// reading its first input, so pass the opening prompt to Connect.
+func WithMcpStrict(strict bool) Option {
+	return func(o *Options) {
+		o.McpStrict = strict
+	}
+}
+
+// WithMcpObserver sets a callback that receives each MCP server that is
+// not connected when a session starts, including configured servers the
+// CLI did not report. It runs on the goroutine delivering messages, so it
+// must not block.
+func WithMcpObserver(observer McpObserver) Option {
+	return func(o *Options) {
+		o.McpObserver = observer
+	}
+}
+
+// WithMaxTurns sets the maximum number of conversation turns. On a Client
+// it can also be set per query, limiting the model's responses in that
+// query's turn.
 func WithMaxTurns(turns int) Option {
 	return func(o *Options) {
 		o.MaxTurns = turns
 	}
 }
 
-// WithSettings sets the settings file path or JSON string.
+// WithSettings sets the path of a settings file passed to the CLI via --settings.
+// An inline JSON string is also accepted; prefer WithSettingsJSON for that.
 func WithSettings(settings string) Option {
 	return func(o *Options) {
 		o.Settings = &settings
 	}
 }
 
+// WithSettingsJSON supplies a complete settings document (hooks, permissions,
+// env and so on) as raw JSON. The document is written to a temporary file
+// that is passed to the CLI via --settings and removed when the transport
+// closes. Sandbox settings from WithSandbox are merged into the document.
+// It cannot be combined with WithSettings.
+func WithSettingsJSON(raw []byte) Option {
+	return func(o *Options) {
+		o.SettingsJSON = append([]byte(nil), raw...)
+	}
+}
+
 // WithForkSession enables forking to a new session ID when resuming.
 // When true, resumed sessions fork to a new session ID rather than
 // continuing the previous session.
@@ -246,6 +850,25 @@ func WithSettingSources(sources ...SettingSource) Option {
 	}
 }
 
+// WithMemoryFiles adds memory files whose instructions the agent follows
+// alongside any CLAUDE.md files. The files are read when the CLI starts and
+// appended to the system prompt, so a missing file fails Connect.
+func WithMemoryFiles(paths ...string) Option {
+	return func(o *Options) {
+		o.MemoryFiles = append(o.MemoryFiles, paths...)
+	}
+}
+
+// WithNoProjectMemory keeps the project's CLAUDE.md and CLAUDE.local.md
+// files from being loaded. The CLI only loads them with the project and
+// local setting sources, so options enabling either of those sources fail
+// validation rather than dropping the project settings they carry.
+func WithNoProjectMemory(disabled bool) Option {
+	return func(o *Options) {
+		o.NoProjectMemory = disabled
+	}
+}
+
 // WithExtraArgs sets arbitrary CLI flags via ExtraArgs.
 func WithExtraArgs(args map[string]*string) Option {
 	return func(o *Options) {
@@ -260,6 +883,53 @@ func WithCLIPath(path string) Option {
 	}
 }
 
+// WithCommandWrapper rewrites the CLI command before the subprocess
+// transport starts it, for example to run the CLI inside a container or
+// under a sandboxing tool. The wrapper sees the command the SDK built and
+// returns the one to run; its Env is added to the inherited environment.
+func WithCommandWrapper(wrapper CommandWrapper) Option {
+	return func(o *Options) {
+		o.CommandWrapper = wrapper
+	}
+}
+
+// WithCassette records the CLI's output to a cassette file, or replays a
+// recorded one without starting the CLI, so applications get deterministic
+// CI runs. Recorded output is matched to prompts by a hash of each user
+// message, and replayed line for line through the normal parser:
+//
+//	client := claudecode.NewClient(
+//		claudecode.WithCassette("testdata/greeting.cassette.json", claudecode.CassetteRecordOrReplay),
+//	)
+//
+// Sending a prompt the cassette has no recording for fails with
+// ErrCassetteMiss. A recording holds one connection and is written when
+// the transport closes.
+func WithCassette(path string, mode CassetteMode) Option {
+	return func(o *Options) {
+		o.CassettePath = path
+		o.CassetteMode = mode
+	}
+}
+
+// WithClock replaces the system clock used for the client's timestamps and
+// timeouts: control request and hook timeouts, the init timeout and the
+// session times in SessionSummary. Tests can pass a fake clock, such as
+// fakeclock.Clock, to fire timeouts without sleeping.
+func WithClock(clock Clock) Option {
+	return func(o *Options) {
+		o.Clock = clock
+	}
+}
+
+// WithIDGenerator replaces the generator of the IDs the client gives its
+// control requests, which are otherwise numbered from 1 for each client.
+func WithIDGenerator(ids IDGenerator) Option {
+	return func(o *Options) {
+		o.IDGenerator = ids
+	}
+}
+
 // WithEnv sets environment variables for the subprocess.
 // Multiple calls to WithEnv or WithEnvVar merge the values.
 // Later calls override earlier ones for the same key.
@@ -286,6 +956,62 @@ func WithEnvVar(key, value string) Option {
 	}
 }
 
+// WithAPIKey authenticates the CLI with an API key, replacing any API key
+// or token it would inherit from the environment, and a token set with
+// WithOAuthToken.
+func WithAPIKey(key string) Option {
+	return func(o *Options) {
+		o.APIKey = key
+		o.OAuthToken = ""
+	}
+}
+
+// WithOAuthToken authenticates the CLI with an OAuth token created with
+// `claude setup-token`, replacing any API key or token it would inherit from
+// the environment, and a key set with WithAPIKey.
+func WithOAuthToken(token string) Option {
+	return func(o *Options) {
+		o.OAuthToken = token
+		o.APIKey = ""
+	}
+}
+
+// WithConfigDir sets the CLI's config directory, which holds its login,
+// settings and session transcripts. Clients with different directories can
+// run as different identities on one host; see Login.
+func WithConfigDir(dir string) Option {
+	return func(o *Options) {
+		o.ConfigDir = dir
+	}
+}
+
+// WithIsolatedConfig gives the client its own CLI config directory, a new
+// directory in baseDir, or in the system temporary directory when baseDir
+// is "". Settings, session transcripts and history written by the CLI stay
+// in it, so concurrent clients do not overwrite each other's. The
+// directory starts with the login of the config directory the client would
+// otherwise use, set with WithConfigDir or CLAUDE_CONFIG_DIR, or ~/.claude;
+// WithAPIKey and WithOAuthToken keep the login out of it. The client's
+// ConfigDir method returns its path.
+//
+// The directory is removed when the client disconnects, after the
+// finalizer has run; WithConfigCleanup changes when. A kept directory is
+// reused by the client's next connection. Isolated config applies to the
+// Client only.
+func WithIsolatedConfig(baseDir string) Option {
+	return func(o *Options) {
+		o.IsolatedConfig = &baseDir
+	}
+}
+
+// WithConfigCleanup sets when the directory of WithIsolatedConfig is
+// removed. The default is ConfigCleanupOnDisconnect.
+func WithConfigCleanup(cleanup ConfigCleanup) Option {
+	return func(o *Options) {
+		o.ConfigCleanup = cleanup
+	}
+}
+
 // WithBetas sets the SDK beta features to enable.
 // See https://docs.anthropic.com/en/api/beta-headers
 func WithBetas(betas ...SdkBeta) Option {
@@ -441,6 +1167,34 @@ func WithDebugDisabled() Option {
 	return WithDebugWriter(io.Discard)
 }
 
+// WithDebug enables the CLI's debug logging on stderr. Combine it with
+// WithStderrCallback or WithDebugWriter to see the output.
+func WithDebug(enabled bool) Option {
+	return func(o *Options) {
+		o.Debug = enabled
+	}
+}
+
+// WithStderrCallback streams CLI stderr output to fn, one parsed line at a
+// time, as the CLI writes it. A DebugWriter set alongside still receives the
+// raw output. Lines longer than the message size limit are cut to it. fn
+// runs on the goroutine copying stderr, so a slow callback eventually blocks
+// the CLI; panics in fn are recovered.
+//
+// Example:
+//
+//	client := claudecode.NewClient(
+//		claudecode.WithDebug(true),
+//		claudecode.WithStderrCallback(func(r claudecode.DebugRecord) {
+//			log.Printf("cli %s: %s", r.Level, r.Message)
+//		}),
+//	)
+func WithStderrCallback(fn StderrCallback) Option {
+	return func(o *Options) {
+		o.StderrCallback = fn
+	}
+}
+
 // OutputFormatJSONSchema creates an OutputFormat for JSON schema constraints.
 func OutputFormatJSONSchema(schema map[string]any) *OutputFormat {
 	return &OutputFormat{
@@ -5,7 +5,11 @@ import (
 	"context"
 	"io"
 	"os"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/severity1/claude-code-sdk-go/internal/cli"
 )
 
 // Ensure context is used (for mock transport)
@@ -584,6 +588,38 @@ func TestWithCLIPath(t *testing.T) {
 	})
 }
 
+// TestWithCommandWrapper tests the WithCommandWrapper option function
+func TestWithCommandWrapper(t *testing.T) {
+	if options := NewOptions(); options.CommandWrapper != nil {
+		t.Error("Expected no command wrapper by default")
+	}
+
+	options := NewOptions(WithCommandWrapper(func(cmd CLICommand) (CLICommand, error) {
+		cmd.Args = append([]string{"nice"}, cmd.Args...)
+		return cmd, nil
+	}))
+	if options.CommandWrapper == nil {
+		t.Fatal("Expected the command wrapper to be set")
+	}
+	cmd, err := options.CommandWrapper(CLICommand{Args: []string{"claude"}})
+	if err != nil || len(cmd.Args) != 2 || cmd.Args[0] != "nice" {
+		t.Errorf("Expected the wrapper to be called, got %v, %v", cmd.Args, err)
+	}
+}
+
+// TestWithCassette tests the WithCassette option function
+func TestWithCassette(t *testing.T) {
+	options := NewOptions()
+	if options.CassettePath != "" || options.CassetteMode != "" {
+		t.Error("Expected no cassette by default")
+	}
+
+	options = NewOptions(WithCassette("testdata/session.json", CassetteRecordOrReplay))
+	if options.CassettePath != "testdata/session.json" || options.CassetteMode != CassetteRecordOrReplay {
+		t.Errorf("Expected the cassette path and mode, got %q %q", options.CassettePath, options.CassetteMode)
+	}
+}
+
 // TestWithTransport tests the WithTransport option function
 func TestWithTransport(t *testing.T) {
 	// Create a mock transport for testing
@@ -1157,6 +1193,431 @@ func TestMaxBufferSizeOption(t *testing.T) {
 	})
 }
 
+// MaxMessageSize Option
+func TestMaxMessageSizeOption(t *testing.T) {
+	options := NewOptions(WithMaxMessageSize(8 * 1024 * 1024))
+	if options.MaxMessageSize == nil || *options.MaxMessageSize != 8*1024*1024 {
+		t.Errorf("Expected MaxMessageSize = %d, got %v", 8*1024*1024, options.MaxMessageSize)
+	}
+
+	if options := NewOptions(); options.MaxMessageSize != nil {
+		t.Errorf("Expected MaxMessageSize = nil, got %d", *options.MaxMessageSize)
+	}
+
+	invalid := NewOptions(WithMaxMessageSize(0))
+	assertOptionsValidationError(t, invalid, true, "zero max message size should fail validation")
+}
+
+// MaxInlineResultBytes Option
+func TestMaxInlineResultBytesOption(t *testing.T) {
+	options := NewOptions(WithMaxInlineResultBytes(512 * 1024))
+	if options.MaxInlineResultBytes == nil || *options.MaxInlineResultBytes != 512*1024 {
+		t.Errorf("Expected MaxInlineResultBytes = %d, got %v", 512*1024, options.MaxInlineResultBytes)
+	}
+
+	invalid := NewOptions(WithMaxInlineResultBytes(-1))
+	assertOptionsValidationError(t, invalid, true, "negative max inline result bytes should fail validation")
+}
+
+func TestPlanReviewerOption(t *testing.T) {
+	if NewOptions().PlanReviewer != nil {
+		t.Error("Expected no plan reviewer by default")
+	}
+
+	reviewer := func(_ context.Context, plan Plan) (PlanDecision, error) {
+		return PlanDecision{Approved: len(plan.Steps) > 0}, nil
+	}
+	options := NewOptions(WithPermissionMode(PermissionModePlan), WithPlanReviewer(reviewer))
+	if options.PlanReviewer == nil {
+		t.Fatal("Expected plan reviewer to be set")
+	}
+	decision, err := options.PlanReviewer(context.Background(), Plan{Steps: []string{"step"}})
+	if err != nil || !decision.Approved {
+		t.Errorf("Expected configured reviewer to be called, got %+v, %v", decision, err)
+	}
+}
+
+func TestSettingsJSONOption(t *testing.T) {
+	raw := []byte(`{"env":{"FOO":"bar"}}`)
+	options := NewOptions(WithSettingsJSON(raw))
+	if string(options.SettingsJSON) != string(raw) {
+		t.Errorf("Expected SettingsJSON %s, got %s", raw, options.SettingsJSON)
+	}
+
+	// The option keeps its own copy of the document
+	raw[0] = '['
+	if options.SettingsJSON[0] != '{' {
+		t.Error("Expected SettingsJSON to be copied")
+	}
+
+	conflicting := NewOptions(WithSettings("settings.json"), WithSettingsJSON([]byte(`{}`)))
+	assertOptionsValidationError(t, conflicting, true, "settings path and JSON together should fail validation")
+}
+
+func TestContextLimitOption(t *testing.T) {
+	options := NewOptions(WithContextLimit(4096, ContextDrop))
+	if options.ContextMaxBytes == nil || *options.ContextMaxBytes != 4096 {
+		t.Errorf("Expected ContextMaxBytes 4096, got %v", options.ContextMaxBytes)
+	}
+	if options.ContextTruncation != ContextDrop {
+		t.Errorf("Expected ContextDrop policy, got %q", options.ContextTruncation)
+	}
+	assertOptionsValidationError(t, options, false, "valid context limit")
+
+	assertOptionsValidationError(t, NewOptions(WithContextLimit(0, ContextTruncate)), true,
+		"zero context limit should fail validation")
+	assertOptionsValidationError(t, NewOptions(WithContextLimit(10, "summarize")), true,
+		"unknown truncation policy should fail validation")
+}
+
+func TestContextCachingOption(t *testing.T) {
+	options := NewOptions(WithContextCaching("1h"))
+	if options.ContextCache == nil || options.ContextCache.Type != CacheControlEphemeral || options.ContextCache.TTL != "1h" {
+		t.Errorf("Expected an ephemeral 1h cache control, got %+v", options.ContextCache)
+	}
+	assertOptionsValidationError(t, options, false, "valid cache TTL")
+	assertOptionsValidationError(t, NewOptions(WithContextCaching("")), false, "default cache TTL")
+	assertOptionsValidationError(t, NewOptions(WithContextCaching("10m")), true,
+		"unsupported cache TTL should fail validation")
+}
+
+func TestPromptCachingOption(t *testing.T) {
+	options := NewOptions(WithPromptCaching(false))
+	if options.ExtraEnv["DISABLE_PROMPT_CACHING"] != "1" {
+		t.Errorf("Expected prompt caching disabled, got %v", options.ExtraEnv)
+	}
+	options = NewOptions(WithPromptCaching(false), WithPromptCaching(true))
+	if _, ok := options.ExtraEnv["DISABLE_PROMPT_CACHING"]; ok {
+		t.Errorf("Expected prompt caching re-enabled, got %v", options.ExtraEnv)
+	}
+}
+
+func TestSessionTagsOption(t *testing.T) {
+	options := NewOptions(
+		WithSessionTags(map[string]string{"team": "payments", "env": "staging"}),
+		WithSessionTags(map[string]string{"env": "prod", "ticket": "OPS-1"}),
+	)
+	want := map[string]string{"team": "payments", "env": "prod", "ticket": "OPS-1"}
+	if !reflect.DeepEqual(options.SessionTags, want) {
+		t.Errorf("Expected merged tags %v, got %v", want, options.SessionTags)
+	}
+	assertOptionsValidationError(t, options, false, "valid session tags")
+	assertOptionsValidationError(t, NewOptions(WithSessionTags(map[string]string{"": "x"})), true,
+		"empty tag key should fail validation")
+}
+
+func TestInitTimeoutOption(t *testing.T) {
+	options := NewOptions(WithInitTimeout(3 * time.Second))
+	if options.InitTimeout != 3*time.Second {
+		t.Errorf("Expected InitTimeout 3s, got %s", options.InitTimeout)
+	}
+	assertOptionsValidationError(t, options, false, "valid init timeout")
+	assertOptionsValidationError(t, NewOptions(WithInitTimeout(-time.Second)), true,
+		"negative init timeout should fail validation")
+}
+
+func TestToolTimeoutOption(t *testing.T) {
+	options := NewOptions(
+		WithToolTimeout(map[string]time.Duration{"Bash": time.Minute}),
+		WithToolTimeout(map[string]time.Duration{"*": time.Hour}),
+	)
+	want := map[string]time.Duration{"Bash": time.Minute, "*": time.Hour}
+	if !reflect.DeepEqual(options.ToolTimeouts, want) {
+		t.Errorf("Expected merged tool timeouts %v, got %v", want, options.ToolTimeouts)
+	}
+	assertOptionsValidationError(t, options, false, "valid tool timeouts")
+	assertOptionsValidationError(t, NewOptions(WithToolTimeout(map[string]time.Duration{"Bash": 0})), true,
+		"zero tool timeout should fail validation")
+	assertOptionsValidationError(t, NewOptions(WithToolTimeout(map[string]time.Duration{"": time.Second})), true,
+		"empty tool name should fail validation")
+}
+
+func TestToolProgressOption(t *testing.T) {
+	options := NewOptions(WithToolProgress(250*time.Millisecond, func([]InFlightTool) {}))
+	if options.ToolProgressInterval != 250*time.Millisecond || options.ToolProgress == nil {
+		t.Errorf("Expected the tool progress callback every 250ms, got %s", options.ToolProgressInterval)
+	}
+	assertOptionsValidationError(t, options, false, "valid tool progress interval")
+	assertOptionsValidationError(t, NewOptions(WithToolProgress(-time.Second, func([]InFlightTool) {})), true,
+		"negative tool progress interval should fail validation")
+}
+
+func TestPathPolicyOptions(t *testing.T) {
+	if NewOptions().ConfinePaths {
+		t.Error("Expected paths not to be confined by default")
+	}
+	if NewOptions(WithReadOnly()).ConfinePaths {
+		t.Error("Expected WithReadOnly to rely on the tool lists alone")
+	}
+	if !NewOptions(WithEphemeralWorkspace("")).ConfinePaths {
+		t.Error("Expected an ephemeral workspace to confine paths")
+	}
+	policy := pathPolicyFunc(func(string, map[string]any) error { return nil })
+	if NewOptions(WithPathPolicy(policy)).PathPolicy == nil {
+		t.Error("Expected the path policy to be set")
+	}
+}
+
+func TestSlowHookThresholdOption(t *testing.T) {
+	reporter := func(SlowHookReport) {}
+	options := NewOptions(WithSlowHookThreshold(250*time.Millisecond, reporter))
+	if options.SlowHookThreshold != 250*time.Millisecond || options.SlowHookReporter == nil {
+		t.Errorf("Expected the threshold and reporter to be set, got %v", options.SlowHookThreshold)
+	}
+	assertOptionsValidationError(t, options, false, "valid slow hook threshold")
+	assertOptionsValidationError(t, NewOptions(WithSlowHookThreshold(-time.Second, reporter)), true,
+		"negative slow hook threshold should fail validation")
+}
+
+func TestMemoryOptions(t *testing.T) {
+	options := NewOptions(WithMemoryFiles("a.md"), WithMemoryFiles("b.md", "c.md"), WithNoProjectMemory(true))
+	if !reflect.DeepEqual(options.MemoryFiles, []string{"a.md", "b.md", "c.md"}) {
+		t.Errorf("Expected memory files to accumulate, got %v", options.MemoryFiles)
+	}
+	if !options.NoProjectMemory {
+		t.Error("Expected NoProjectMemory to be set")
+	}
+	assertOptionsValidationError(t, options, false, "valid memory files")
+	assertOptionsValidationError(t, NewOptions(WithMemoryFiles("")), true,
+		"empty memory file path should fail validation")
+	assertOptionsValidationError(t, NewOptions(WithNoProjectMemory(true), WithSettingSources(SettingSourceUser)), false,
+		"no project memory with the user setting source")
+	assertOptionsValidationError(t, NewOptions(WithNoProjectMemory(true), WithSettingSources(SettingSourceLocal)), true,
+		"no project memory with the local setting source should fail validation")
+}
+
+func TestFinalizerOption(t *testing.T) {
+	if NewOptions().Finalizer != nil {
+		t.Error("Expected no finalizer by default")
+	}
+
+	var got SessionSummary
+	options := NewOptions(WithFinalizer(func(summary SessionSummary) { got = summary }))
+	if options.Finalizer == nil {
+		t.Fatal("Expected finalizer to be set")
+	}
+	options.Finalizer(SessionSummary{SessionID: "s1", Reason: SessionEndPanic})
+	if got.SessionID != "s1" || got.Reason != SessionEndPanic {
+		t.Errorf("Expected finalizer to receive the summary, got %+v", got)
+	}
+}
+
+func TestStreamIntegrityChecksOption(t *testing.T) {
+	if NewOptions().StreamIntegrityChecks {
+		t.Error("Expected stream integrity checks to be disabled by default")
+	}
+	if !NewOptions(WithStreamIntegrityChecks()).StreamIntegrityChecks {
+		t.Error("Expected WithStreamIntegrityChecks to enable checks")
+	}
+}
+
+func TestPartialTextOption(t *testing.T) {
+	if NewOptions().PartialText {
+		t.Error("Expected partial text to be disabled by default")
+	}
+	if !NewOptions(WithPartialText()).PartialText {
+		t.Error("Expected WithPartialText to enable partial text")
+	}
+}
+
+func TestLocaleOption(t *testing.T) {
+	if NewOptions().Locale != "" {
+		t.Error("Expected no locale by default")
+	}
+	if got := NewOptions(WithLocale("de-DE")).Locale; got != "de-DE" {
+		t.Errorf("Expected locale de-DE, got %q", got)
+	}
+}
+
+func TestQueryDeadlinePolicyOption(t *testing.T) {
+	if NewOptions().QueryDeadline != nil {
+		t.Error("Expected no deadline policy by default")
+	}
+	policy := NewOptions(WithQueryDeadlinePolicy(QueryDeadlinePolicy{Margin: 5 * time.Second, AnnounceDeadline: true})).QueryDeadline
+	if policy == nil || policy.Margin != 5*time.Second || !policy.AnnounceDeadline {
+		t.Errorf("Expected the policy to be set, got %+v", policy)
+	}
+}
+
+func TestAutoReconnectOption(t *testing.T) {
+	if NewOptions().AutoReconnect != nil {
+		t.Error("Expected no auto reconnect by default")
+	}
+	policy := NewOptions(WithAutoReconnect(AutoReconnectPolicy{ExitCodes: []int{75, 76}, MaxAttempts: 5})).AutoReconnect
+	if policy == nil || len(policy.ExitCodes) != 2 || policy.MaxAttempts != 5 {
+		t.Errorf("Expected the policy to be set, got %+v", policy)
+	}
+}
+
+func TestCredentialOptions(t *testing.T) {
+	options := NewOptions()
+	if options.APIKey != "" || options.OAuthToken != "" || options.ConfigDir != "" {
+		t.Errorf("Expected no credentials or config dir by default, got %+v", options)
+	}
+	options = NewOptions(WithOAuthToken("sk-ant-oat-test"), WithAPIKey("sk-ant-test"), WithConfigDir("/srv/bot/claude"))
+	if options.APIKey != "sk-ant-test" || options.OAuthToken != "" {
+		t.Errorf("Expected the API key to replace the OAuth token, got %q and %q", options.APIKey, options.OAuthToken)
+	}
+	if options.ConfigDir != "/srv/bot/claude" {
+		t.Errorf("Expected config dir /srv/bot/claude, got %q", options.ConfigDir)
+	}
+	options = NewOptions(WithAPIKey("sk-ant-test"), WithOAuthToken("sk-ant-oat-test"))
+	if options.APIKey != "" || options.OAuthToken != "sk-ant-oat-test" {
+		t.Errorf("Expected the OAuth token to replace the API key, got %q and %q", options.APIKey, options.OAuthToken)
+	}
+	assertOptionsValidationError(t, options, false, "valid credentials")
+}
+
+func TestIsolatedConfigOption(t *testing.T) {
+	options := NewOptions()
+	if options.IsolatedConfig != nil || options.ConfigCleanup != "" {
+		t.Errorf("Expected no isolated config by default, got %v %q", options.IsolatedConfig, options.ConfigCleanup)
+	}
+	options = NewOptions(WithIsolatedConfig("/var/lib/bots"), WithConfigCleanup(ConfigCleanupNever))
+	if options.IsolatedConfig == nil || *options.IsolatedConfig != "/var/lib/bots" {
+		t.Errorf("Expected base dir /var/lib/bots, got %v", options.IsolatedConfig)
+	}
+	if options.ConfigCleanup != ConfigCleanupNever {
+		t.Errorf("Expected cleanup %q, got %q", ConfigCleanupNever, options.ConfigCleanup)
+	}
+	assertOptionsValidationError(t, options, false, "valid isolated config")
+}
+
+func TestIdempotencyKeyOption(t *testing.T) {
+	if NewOptions().IdempotencyKey != "" {
+		t.Error("Expected no idempotency key by default")
+	}
+	if key := NewOptions(WithIdempotencyKey("req-1")).IdempotencyKey; key != "req-1" {
+		t.Errorf("Expected idempotency key req-1, got %q", key)
+	}
+	if _, err := parseQueryOptions([]Option{WithIdempotencyKey("req-1")}); err != nil {
+		t.Errorf("Expected the key to be accepted per query, got %v", err)
+	}
+}
+
+func TestStrictProtocolOption(t *testing.T) {
+	if NewOptions().StrictProtocol {
+		t.Error("Expected strict protocol checks to be disabled by default")
+	}
+	if !NewOptions(WithStrictProtocol(true)).StrictProtocol {
+		t.Error("Expected WithStrictProtocol to enable strict protocol checks")
+	}
+}
+
+func TestStrictOrderingOption(t *testing.T) {
+	if NewOptions().StrictOrdering {
+		t.Error("Expected strict ordering to be disabled by default")
+	}
+	if !NewOptions(WithStrictOrdering()).StrictOrdering {
+		t.Error("Expected WithStrictOrdering to enable strict ordering")
+	}
+}
+
+func TestMessageRecyclingOption(t *testing.T) {
+	if NewOptions().MessageRecycling {
+		t.Error("Expected message recycling to be disabled by default")
+	}
+	if !NewOptions(WithMessageRecycling(true)).MessageRecycling {
+		t.Error("Expected WithMessageRecycling to enable recycling")
+	}
+}
+
+func TestJSONCodecOption(t *testing.T) {
+	if NewOptions().JSONCodec != nil {
+		t.Error("Expected no JSON codec by default")
+	}
+	var codec JSONCodec = StdJSONCodec{}
+	if NewOptions(WithJSONCodec(codec)).JSONCodec != codec {
+		t.Error("Expected WithJSONCodec to set the codec")
+	}
+}
+
+func TestWithReadOnly(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           []Option
+		wantAllowed    []string
+		wantDisallowed []string
+	}{
+		{
+			name:           "defaults",
+			opts:           []Option{WithReadOnly()},
+			wantAllowed:    []string{"Read", "Grep", "Glob", "LS", "NotebookRead", "WebFetch", "WebSearch"},
+			wantDisallowed: []string{"Bash", "Edit", "MultiEdit", "NotebookEdit", "Write"},
+		},
+		{
+			name:           "exceptions",
+			opts:           []Option{WithReadOnly("Bash(git log:*)", "TodoWrite")},
+			wantAllowed:    []string{"Read", "Grep", "Glob", "LS", "NotebookRead", "WebFetch", "WebSearch", "Bash(git log:*)", "TodoWrite"},
+			wantDisallowed: []string{"Edit", "MultiEdit", "NotebookEdit", "Write"},
+		},
+		{
+			name:           "merges_with_tool_lists",
+			opts:           []Option{WithAllowedTools("Read", "mcp__docs"), WithDisallowedTools("WebSearch", "Bash"), WithReadOnly()},
+			wantAllowed:    []string{"Read", "mcp__docs", "Grep", "Glob", "LS", "NotebookRead", "WebFetch"},
+			wantDisallowed: []string{"WebSearch", "Bash", "Edit", "MultiEdit", "NotebookEdit", "Write"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := NewOptions(test.opts...)
+			assertOptionsStringSlice(t, options.AllowedTools, test.wantAllowed, "AllowedTools")
+			assertOptionsStringSlice(t, options.DisallowedTools, test.wantDisallowed, "DisallowedTools")
+		})
+	}
+}
+
+func TestWithReadOnlyCommand(t *testing.T) {
+	// The CLI enforces the tool lists itself; nothing routes permission
+	// checks to the SDK
+	got := cli.BuildCommand("claude", NewOptions(WithReadOnly()), false)
+	want := []string{
+		"claude", "--output-format", "stream-json", "--verbose", "--input-format", "stream-json",
+		"--allowed-tools", "Read,Grep,Glob,LS,NotebookRead,WebFetch,WebSearch",
+		"--disallowed-tools", "Bash,Edit,MultiEdit,NotebookEdit,Write",
+		"--setting-sources", "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestWithReadOnlyDoesNotModifyCallerSlice(t *testing.T) {
+	allowed := make([]string, 1, 10)
+	allowed[0] = "Read"
+	NewOptions(WithAllowedTools(allowed...), WithReadOnly())
+	if extended := allowed[:2]; extended[1] != "" {
+		t.Errorf("Expected the caller's backing array untouched, got %q", extended[1])
+	}
+}
+
+func TestDryRunOption(t *testing.T) {
+	if NewOptions().DryRun {
+		t.Error("Expected dry run off by default")
+	}
+	if !NewOptions(WithDryRun(true)).DryRun {
+		t.Error("Expected WithDryRun(true) to enable dry run")
+	}
+}
+
+func TestMessageObserverOption(t *testing.T) {
+	var seen []string
+	opts := NewOptions(
+		WithMessageObserver(func(Message) { seen = append(seen, "first") }),
+		WithMessageObserver(func(Message) { seen = append(seen, "second") }),
+	)
+	if len(opts.MessageObservers) != 2 {
+		t.Fatalf("Expected 2 observers, got %d", len(opts.MessageObservers))
+	}
+	for _, observe := range opts.MessageObservers {
+		observe(&ResultMessage{})
+	}
+	if len(seen) != 2 || seen[0] != "first" || seen[1] != "second" {
+		t.Errorf("Expected observers in the order added, got %v", seen)
+	}
+}
+
 // T030: New Options Integration Test
 func TestNewConfigOptionsIntegration(t *testing.T) {
 	// Test all new options together with existing options
@@ -1826,6 +2287,31 @@ func TestWithDebugWriter(t *testing.T) {
 	}
 }
 
+func TestWithStderrCallback(t *testing.T) {
+	var received []DebugRecord
+	options := NewOptions(
+		WithDebug(true),
+		WithStderrCallback(func(record DebugRecord) {
+			received = append(received, record)
+		}),
+	)
+
+	if !options.Debug {
+		t.Error("Expected Debug to be enabled")
+	}
+	if options.StderrCallback == nil {
+		t.Fatal("Expected StderrCallback to be set")
+	}
+	options.StderrCallback(DebugRecord{Message: "hello"})
+	if len(received) != 1 || received[0].Message != "hello" {
+		t.Errorf("Expected callback to receive the record, got %v", received)
+	}
+
+	if defaults := NewOptions(); defaults.Debug || defaults.StderrCallback != nil {
+		t.Error("Expected debug streaming to be off by default")
+	}
+}
+
 // TestWithDebugWriterIntegration tests debug writer with other options
 func TestWithDebugWriterIntegration(t *testing.T) {
 	var debugBuf bytes.Buffer
@@ -2625,3 +3111,29 @@ func assertAgentDefinition(t *testing.T, agent AgentDefinition, description, pro
 		t.Errorf("Expected Model = %q, got %q", model, agent.Model)
 	}
 }
+
+func TestEphemeralWorkspaceOptions(t *testing.T) {
+	opts := NewOptions()
+	if opts.EphemeralWorkspace != nil || opts.KeepWorkspaceOnFailure {
+		t.Error("Expected no ephemeral workspace by default")
+	}
+	opts = NewOptions(WithEphemeralWorkspace(""), WithKeepWorkspaceOnFailure(true))
+	if opts.EphemeralWorkspace == nil || *opts.EphemeralWorkspace != "" {
+		t.Errorf("Expected an empty workspace template, got %v", opts.EphemeralWorkspace)
+	}
+	if !opts.KeepWorkspaceOnFailure {
+		t.Error("Expected WithKeepWorkspaceOnFailure(true) to keep failed workspaces")
+	}
+}
+
+func TestModelRouterOption(t *testing.T) {
+	router := ModelRouterFunc(func(context.Context, RouteRequest) string { return "haiku" })
+	options := NewOptions(WithModelRouter(router), WithRouteFlags(RouteFlagDeep), WithRouteFlags("batch"))
+
+	if options.ModelRouter == nil || options.ModelRouter.Route(context.Background(), RouteRequest{}) != "haiku" {
+		t.Error("Expected the model router to be set")
+	}
+	if len(options.RouteFlags) != 2 || options.RouteFlags[0] != RouteFlagDeep || options.RouteFlags[1] != "batch" {
+		t.Errorf("Expected the route flags to accumulate, got %v", options.RouteFlags)
+	}
+}
@@ -0,0 +1,238 @@
+// Package orchestrate composes agent steps into pipelines.
+//
+// Each Step runs one query with its own options. Its prompt is built from
+// the pipeline input and the outputs of earlier steps, and its output,
+// parsed from the query's result, is kept under the step's name for the
+// steps after it. Steps can be retried, skipped, or branch to another step,
+// and the pipeline reports each step's cost and latency:
+//
+//	pipeline := &orchestrate.Pipeline{Steps: []orchestrate.Step{
+//		{
+//			Name:   "generate",
+//			Prompt: func(s *orchestrate.State) (string, error) { return "Write " + s.Input.(string), nil },
+//		},
+//		{
+//			Name:    "review",
+//			Prompt:  reviewPrompt,
+//			Parse:   orchestrate.JSON[Review](),
+//			Options: []claudecode.Option{claudecode.WithModel("opus")},
+//			Next: func(s *orchestrate.State) string {
+//				if review, _ := orchestrate.Get[Review](s, "review"); review.Approved {
+//					return orchestrate.End
+//				}
+//				return ""
+//			},
+//		},
+//		{Name: "fix", Prompt: fixPrompt, Retries: 2, Next: func(*orchestrate.State) string { return "review" }},
+//	}}
+//	state, err := pipeline.Run(ctx, "a CSV parser")
+//	fmt.Printf("$%.4f\n", state.CostUSD())
+package orchestrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// End is returned by Step.Next to finish the pipeline. It cannot be used as
+// a step name.
+const End = "$end"
+
+// DefaultMaxSteps bounds how many steps a pipeline runs, so branches that
+// loop end.
+const DefaultMaxSteps = 100
+
+var (
+	// ErrUnknownStep is returned when Next names a step the pipeline does
+	// not have.
+	ErrUnknownStep = errors.New("unknown step")
+	// ErrTooManySteps is returned when a pipeline runs more than MaxSteps
+	// steps.
+	ErrTooManySteps = errors.New("too many steps")
+)
+
+// ParseFunc turns a query's result into a step's output.
+type ParseFunc func(result *claudecode.RunResult) (any, error)
+
+// Step is one query of a pipeline.
+type Step struct {
+	// Name identifies the step's output and is the target of branches.
+	Name string
+	// Prompt builds the query's prompt.
+	Prompt func(s *State) (string, error)
+	// Options are added after the pipeline's options.
+	Options []claudecode.Option
+	// Parse turns the result into the step's output; by default the output
+	// is the result text. A parse error fails the attempt.
+	Parse ParseFunc
+	// Retries is how many times a failed attempt is retried.
+	Retries int
+	// RetryDelay is the wait before each retry.
+	RetryDelay time.Duration
+	// When skips the step if it returns false.
+	When func(s *State) bool
+	// Next names the step to run after this one, or End. An empty name
+	// goes on to the following step.
+	Next func(s *State) string
+}
+
+// Pipeline runs steps in order, following their branches.
+type Pipeline struct {
+	Steps []Step
+	// Options are passed to every step's query.
+	Options []claudecode.Option
+	// MaxSteps bounds the steps run, counting repeats; the default is
+	// DefaultMaxSteps.
+	MaxSteps int
+	// Query runs the queries; the default is claudecode.Query.
+	Query claudecode.QueryFunc
+}
+
+// StepError reports the step that stopped a pipeline.
+type StepError struct {
+	Step     string
+	Attempts int
+	Err      error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step %s failed after %d attempt(s): %v", e.Step, e.Attempts, e.Err)
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Run runs the pipeline from its first step. The returned State holds the
+// outputs and reports of the steps that ran, even when Run fails.
+func (p *Pipeline) Run(ctx context.Context, input any) (*State, error) {
+	state := newState(input)
+	index := make(map[string]int, len(p.Steps))
+	for i, step := range p.Steps {
+		if step.Name == "" || step.Name == End {
+			return state, fmt.Errorf("invalid step name %q", step.Name)
+		}
+		if _, ok := index[step.Name]; ok {
+			return state, fmt.Errorf("duplicate step name %q", step.Name)
+		}
+		if step.Prompt == nil {
+			return state, fmt.Errorf("step %s has no prompt", step.Name)
+		}
+		index[step.Name] = i
+	}
+	maxSteps := p.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+
+	for i, runs := 0, 0; i < len(p.Steps); runs++ {
+		if runs == maxSteps {
+			return state, fmt.Errorf("%w: ran %d", ErrTooManySteps, runs)
+		}
+		step := &p.Steps[i]
+
+		if step.When != nil && !step.When(state) {
+			state.report(StepReport{Name: step.Name, Skipped: true})
+			i++
+			continue
+		}
+		if err := p.runStep(ctx, step, state); err != nil {
+			return state, err
+		}
+
+		i++
+		if step.Next == nil {
+			continue
+		}
+		switch next := step.Next(state); next {
+		case "":
+		case End:
+			return state, nil
+		default:
+			target, ok := index[next]
+			if !ok {
+				return state, fmt.Errorf("%w %q after step %s", ErrUnknownStep, next, step.Name)
+			}
+			i = target
+		}
+	}
+	return state, nil
+}
+
+// runStep runs a step's attempts, recording its output and report.
+func (p *Pipeline) runStep(ctx context.Context, step *Step, state *State) error {
+	report := StepReport{Name: step.Name}
+	start := time.Now()
+	defer func() {
+		report.Duration = time.Since(start)
+		state.report(report)
+	}()
+
+	prompt, err := step.Prompt(state)
+	if err != nil {
+		report.Err = &StepError{Step: step.Name, Err: fmt.Errorf("building prompt: %w", err)}
+		return report.Err
+	}
+	opts := append(append([]claudecode.Option(nil), p.Options...), step.Options...)
+
+	for {
+		report.Attempts++
+		result, err := p.query(ctx, prompt, opts)
+		if result != nil && result.Result != nil {
+			report.Turns += result.Result.NumTurns
+			report.CostUSD += result.CostUSD
+		}
+		var output any
+		if err == nil {
+			output, err = parse(step, result)
+		}
+		if err == nil {
+			state.outputs[step.Name] = output
+			return nil
+		}
+
+		if report.Attempts > step.Retries || ctx.Err() != nil {
+			report.Err = &StepError{Step: step.Name, Attempts: report.Attempts, Err: err}
+			return report.Err
+		}
+		if step.RetryDelay > 0 {
+			timer := time.NewTimer(step.RetryDelay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				report.Err = &StepError{Step: step.Name, Attempts: report.Attempts, Err: ctx.Err()}
+				return report.Err
+			}
+		}
+	}
+}
+
+// query runs one query to its end. The result is returned whenever the
+// query produced one, even if it is an error, so its cost is counted.
+func (p *Pipeline) query(ctx context.Context, prompt string, opts []claudecode.Option) (*claudecode.RunResult, error) {
+	result, err := claudecode.RunWithQuery(ctx, p.Query, claudecode.RunSpec{Prompt: prompt, Options: opts})
+	if err != nil {
+		return result, err
+	}
+	if result.Result.IsError {
+		return result, fmt.Errorf("%w: %s", claudecode.ErrFailedResult, result.Result.Subtype)
+	}
+	return result, nil
+}
+
+func parse(step *Step, result *claudecode.RunResult) (any, error) {
+	if step.Parse == nil {
+		return result.Text, nil
+	}
+	output, err := step.Parse(result)
+	if err != nil {
+		return nil, fmt.Errorf("parsing result: %w", err)
+	}
+	return output, nil
+}
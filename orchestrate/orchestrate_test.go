@@ -0,0 +1,298 @@
+package orchestrate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestPipelinePassesOutputsForward(t *testing.T) {
+	queries := &fakeQueries{replies: map[string][]reply{
+		"Write a parser":          {{text: "func Parse() {}", cost: 0.01}},
+		"Review: func Parse() {}": {{text: `{"approved": true, "notes": "fine"}`, cost: 0.02}},
+	}}
+	pipeline := &Pipeline{
+		Query: queries.query,
+		Steps: []Step{
+			{Name: "generate", Prompt: func(s *State) (string, error) { return "Write " + s.Input.(string), nil }},
+			{Name: "review", Prompt: reviewPrompt, Parse: JSON[review]()},
+		},
+	}
+
+	state, err := pipeline.Run(context.Background(), "a parser")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if code, _ := Get[string](state, "generate"); code != "func Parse() {}" {
+		t.Errorf("Expected the generated code, got %q", code)
+	}
+	if got, ok := Get[review](state, "review"); !ok || !got.Approved || got.Notes != "fine" {
+		t.Errorf("Expected a typed review, got %+v", got)
+	}
+	if cost := state.CostUSD(); cost < 0.0299 || cost > 0.0301 {
+		t.Errorf("Expected total cost 0.03, got %v", cost)
+	}
+	reports := state.Reports()
+	if len(reports) != 2 || reports[0].Name != "generate" || reports[1].CostUSD != 0.02 || reports[1].Turns != 1 {
+		t.Errorf("Expected a report per step, got %+v", reports)
+	}
+}
+
+func TestPipelineStepOptions(t *testing.T) {
+	queries := &fakeQueries{replies: map[string][]reply{"go": {{text: "ok"}}}}
+	pipeline := &Pipeline{
+		Query:   queries.query,
+		Options: []claudecode.Option{claudecode.WithModel("sonnet"), claudecode.WithMaxTurns(3)},
+		Steps: []Step{
+			{Name: "only", Prompt: constant("go"), Options: []claudecode.Option{claudecode.WithModel("opus")}},
+		},
+	}
+	if _, err := pipeline.Run(context.Background(), nil); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	options := claudecode.NewOptions(queries.options[0]...)
+	if options.Model == nil || *options.Model != "opus" || options.MaxTurns != 3 {
+		t.Errorf("Expected step options after pipeline options, got model %v and %d turns", options.Model, options.MaxTurns)
+	}
+}
+
+func TestPipelineRetries(t *testing.T) {
+	queries := &fakeQueries{replies: map[string][]reply{
+		"flaky": {
+			{err: errors.New("connection reset")},
+			{text: "partial", isError: true, cost: 0.5},
+			{text: "not json", cost: 0.25},
+			{text: `{"approved": false}`, cost: 0.25},
+		},
+	}}
+	pipeline := &Pipeline{
+		Query: queries.query,
+		Steps: []Step{{Name: "flaky", Prompt: constant("flaky"), Parse: JSON[review](), Retries: 3}},
+	}
+
+	state, err := pipeline.Run(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	report := state.Reports()[0]
+	if report.Attempts != 4 || report.CostUSD != 1 || report.Err != nil {
+		t.Errorf("Expected 4 attempts costing $1, got %+v", report)
+	}
+
+	// Without retries left, the last error is reported
+	queries.replies["flaky"] = []reply{{text: "partial", isError: true}}
+	pipeline.Steps[0].Retries = 0
+	state, err = pipeline.Run(context.Background(), nil)
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "flaky" || !errors.Is(err, claudecode.ErrFailedResult) {
+		t.Fatalf("Expected a StepError wrapping ErrFailedResult, got %v", err)
+	}
+	if _, ok := state.Output("flaky"); ok || state.Reports()[0].Err == nil {
+		t.Error("Expected no output and the error in the report")
+	}
+}
+
+func TestPipelineBranches(t *testing.T) {
+	queries := &fakeQueries{replies: map[string][]reply{
+		"generate": {{text: "v1"}},
+		"review":   {{text: `{"approved": false}`}, {text: `{"approved": true}`}},
+		"fix":      {{text: "v2"}},
+		"publish":  {{text: "never"}},
+	}}
+	pipeline := &Pipeline{
+		Query: queries.query,
+		Steps: []Step{
+			{Name: "generate", Prompt: constant("generate")},
+			{
+				Name: "review", Prompt: constant("review"), Parse: JSON[review](),
+				Next: func(s *State) string {
+					if r, _ := Get[review](s, "review"); r.Approved {
+						return End
+					}
+					return ""
+				},
+			},
+			{Name: "fix", Prompt: constant("fix"), Next: func(*State) string { return "review" }},
+			{Name: "publish", Prompt: constant("publish")},
+		},
+	}
+
+	state, err := pipeline.Run(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	var names []string
+	for _, report := range state.Reports() {
+		names = append(names, report.Name)
+	}
+	if got := strings.Join(names, ","); got != "generate,review,fix,review" {
+		t.Errorf("Expected the review loop, got %s", got)
+	}
+	if r, _ := Get[review](state, "review"); !r.Approved {
+		t.Error("Expected the latest review output")
+	}
+}
+
+func TestPipelineWhen(t *testing.T) {
+	queries := &fakeQueries{replies: map[string][]reply{"b": {{text: "b"}}}}
+	pipeline := &Pipeline{
+		Query: queries.query,
+		Steps: []Step{
+			{Name: "a", Prompt: constant("a"), When: func(s *State) bool { return s.Input == "run a" }},
+			{Name: "b", Prompt: constant("b")},
+		},
+	}
+
+	state, err := pipeline.Run(context.Background(), "skip a")
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	reports := state.Reports()
+	if len(reports) != 2 || !reports[0].Skipped || reports[0].Attempts != 0 || reports[1].Skipped {
+		t.Errorf("Expected a skipped then run, got %+v", reports)
+	}
+}
+
+func TestPipelineErrors(t *testing.T) {
+	endless := func(*State) string { return "loop" }
+	queries := &fakeQueries{replies: map[string][]reply{"loop": {{text: "again"}}, "empty": {{noResult: true}}}}
+	tests := []struct {
+		name  string
+		steps []Step
+		want  error
+		text  string
+	}{
+		{"unknown_step", []Step{{Name: "loop", Prompt: constant("loop"), Next: func(*State) string { return "missing" }}}, ErrUnknownStep, ""},
+		{"loop", []Step{{Name: "loop", Prompt: constant("loop"), Next: endless}}, ErrTooManySteps, ""},
+		{"no_result", []Step{{Name: "empty", Prompt: constant("empty")}}, claudecode.ErrNoResult, ""},
+		{"prompt_error", []Step{{Name: "p", Prompt: func(*State) (string, error) { return "", errors.New("no input") }}}, nil, "building prompt: no input"},
+		{"duplicate", []Step{{Name: "a", Prompt: constant("a")}, {Name: "a", Prompt: constant("a")}}, nil, "duplicate step name"},
+		{"reserved", []Step{{Name: End, Prompt: constant("a")}}, nil, "invalid step name"},
+		{"no_prompt", []Step{{Name: "a"}}, nil, "has no prompt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pipeline := &Pipeline{Query: queries.query, Steps: test.steps, MaxSteps: 5}
+			_, err := pipeline.Run(context.Background(), nil)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Errorf("Expected %v, got %v", test.want, err)
+			}
+			if !strings.Contains(err.Error(), test.text) {
+				t.Errorf("Expected error containing %q, got %v", test.text, err)
+			}
+		})
+	}
+}
+
+func TestPipelineRetryDelayCanceled(t *testing.T) {
+	queries := &fakeQueries{replies: map[string][]reply{"x": {{err: errors.New("failed")}}}}
+	pipeline := &Pipeline{
+		Query: queries.query,
+		Steps: []Step{{Name: "x", Prompt: constant("x"), Retries: 5, RetryDelay: time.Hour}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := pipeline.Run(ctx, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the retry wait to end with the context, got %v", err)
+	}
+}
+
+type review struct {
+	Approved bool   `json:"approved"`
+	Notes    string `json:"notes"`
+}
+
+// reply scripts one query: an error from Query, or a result.
+type reply struct {
+	err      error
+	text     string
+	isError  bool
+	cost     float64
+	noResult bool
+}
+
+// fakeQueries answers each prompt with its scripted replies in turn,
+// repeating the last one.
+type fakeQueries struct {
+	mu      sync.Mutex
+	replies map[string][]reply
+	calls   map[string]int
+	options [][]claudecode.Option
+}
+
+func (f *fakeQueries) query(_ context.Context, prompt string, opts ...claudecode.Option) (claudecode.MessageIterator, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.calls == nil {
+		f.calls = make(map[string]int)
+	}
+	f.options = append(f.options, opts)
+
+	replies := f.replies[prompt]
+	if len(replies) == 0 {
+		return nil, errors.New("unexpected prompt " + prompt)
+	}
+	n := f.calls[prompt]
+	f.calls[prompt]++
+	if n >= len(replies) {
+		n = len(replies) - 1
+	}
+	r := replies[n]
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	messages := []claudecode.Message{
+		&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{&claudecode.TextBlock{Text: r.text}}},
+	}
+	if !r.noResult {
+		text, cost := r.text, r.cost
+		messages = append(messages, &claudecode.ResultMessage{
+			Subtype: "success", IsError: r.isError, NumTurns: 1, Result: &text, TotalCostUSD: &cost,
+		})
+	}
+	return &sliceIterator{messages: messages}, nil
+}
+
+type sliceIterator struct {
+	messages []claudecode.Message
+}
+
+func (it *sliceIterator) Next(context.Context) (claudecode.Message, error) {
+	if len(it.messages) == 0 {
+		return nil, claudecode.ErrNoMoreMessages
+	}
+	msg := it.messages[0]
+	it.messages = it.messages[1:]
+	return msg, nil
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
+
+func constant(prompt string) func(*State) (string, error) {
+	return func(*State) (string, error) {
+		return prompt, nil
+	}
+}
+
+func reviewPrompt(s *State) (string, error) {
+	code, ok := Get[string](s, "generate")
+	if !ok {
+		return "", errors.New("nothing to review")
+	}
+	return "Review: " + code, nil
+}
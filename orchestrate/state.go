@@ -0,0 +1,115 @@
+package orchestrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// StepReport describes one run of a step. A step that runs again after a
+// branch gets a report per run.
+type StepReport struct {
+	Name     string
+	Skipped  bool
+	Attempts int
+	Turns    int
+	// CostUSD adds up every attempt.
+	CostUSD float64
+	// Duration is the wall-clock time of the run, including retries.
+	Duration time.Duration
+	Err      error
+}
+
+// State is the progress of a pipeline run: its input, the outputs of the
+// steps so far, and their reports. It is passed to the step callbacks and
+// is not safe for concurrent use.
+type State struct {
+	Input any
+
+	outputs map[string]any
+	reports []StepReport
+}
+
+func newState(input any) *State {
+	return &State{Input: input, outputs: make(map[string]any)}
+}
+
+// Output returns the latest output of the named step.
+func (s *State) Output(name string) (any, bool) {
+	output, ok := s.outputs[name]
+	return output, ok
+}
+
+// Reports returns the report of each step run, in order.
+func (s *State) Reports() []StepReport {
+	return append([]StepReport(nil), s.reports...)
+}
+
+// CostUSD returns the cost of every step run.
+func (s *State) CostUSD() float64 {
+	var total float64
+	for _, report := range s.reports {
+		total += report.CostUSD
+	}
+	return total
+}
+
+// Duration returns the time spent running steps.
+func (s *State) Duration() time.Duration {
+	var total time.Duration
+	for _, report := range s.reports {
+		total += report.Duration
+	}
+	return total
+}
+
+func (s *State) report(report StepReport) {
+	s.reports = append(s.reports, report)
+}
+
+// Get returns the latest output of the named step as a T. It returns false
+// if the step has no output or the output is not a T.
+func Get[T any](s *State, name string) (T, bool) {
+	output, ok := s.outputs[name].(T)
+	return output, ok
+}
+
+// JSON returns a ParseFunc decoding a step's result into a T: the
+// structured output when the query used a JSON schema, otherwise the result
+// text, which may be wrapped in a Markdown code fence.
+func JSON[T any]() ParseFunc {
+	return func(result *claudecode.RunResult) (any, error) {
+		var data []byte
+		if structured := result.Result.StructuredOutput; structured != nil {
+			encoded, err := json.Marshal(structured)
+			if err != nil {
+				return nil, err
+			}
+			data = encoded
+		} else {
+			data = []byte(unfence(result.Text))
+		}
+
+		var output T
+		if err := json.Unmarshal(data, &output); err != nil {
+			return nil, fmt.Errorf("decoding %T: %w", output, err)
+		}
+		return output, nil
+	}
+}
+
+// unfence returns the body of text when it is a single fenced code block.
+func unfence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") || !strings.HasSuffix(text, "```") {
+		return text
+	}
+	body := strings.TrimSuffix(text, "```")
+	if _, rest, ok := strings.Cut(body, "\n"); ok {
+		return rest
+	}
+	return text
+}
@@ -0,0 +1,74 @@
+package orchestrate
+
+import (
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestJSON(t *testing.T) {
+	type answer struct {
+		Value int `json:"value"`
+	}
+	tests := []struct {
+		name   string
+		result *claudecode.RunResult
+		want   int
+		fails  bool
+	}{
+		{"text", textResult(`{"value": 1}`), 1, false},
+		{"fenced", textResult("```json\n{\"value\": 2}\n```"), 2, false},
+		{"structured", &claudecode.RunResult{Text: "ignored", Result: &claudecode.ResultMessage{StructuredOutput: map[string]any{"value": 3}}}, 3, false},
+		{"invalid", textResult("the value is 4"), 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := JSON[answer]()(test.result)
+			if test.fails {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", output)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := output.(answer); got.Value != test.want {
+				t.Errorf("Expected value %d, got %d", test.want, got.Value)
+			}
+		})
+	}
+}
+
+func TestStateAccessors(t *testing.T) {
+	state := newState("input")
+	state.outputs["count"] = 3
+	state.report(StepReport{Name: "a", CostUSD: 0.25, Duration: time.Second})
+	state.report(StepReport{Name: "b", Skipped: true})
+	state.report(StepReport{Name: "c", CostUSD: 0.5, Duration: 2 * time.Second})
+
+	if n, ok := Get[int](state, "count"); !ok || n != 3 {
+		t.Errorf("Expected count 3, got %d, %v", n, ok)
+	}
+	if _, ok := Get[string](state, "count"); ok {
+		t.Error("Expected Get to fail for the wrong type")
+	}
+	if _, ok := state.Output("missing"); ok {
+		t.Error("Expected no output for a step that did not run")
+	}
+	if state.CostUSD() != 0.75 || state.Duration() != 3*time.Second {
+		t.Errorf("Expected totals of $0.75 and 3s, got %v and %v", state.CostUSD(), state.Duration())
+	}
+
+	reports := state.Reports()
+	reports[0].Name = "changed"
+	if state.Reports()[0].Name != "a" {
+		t.Error("Expected Reports to return a copy")
+	}
+}
+
+func textResult(text string) *claudecode.RunResult {
+	return &claudecode.RunResult{Text: text, Result: &claudecode.ResultMessage{Result: &text}}
+}
@@ -0,0 +1,119 @@
+package claudecode
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+	"github.com/severity1/claude-code-sdk-go/pathguard"
+)
+
+// PathViolation is a file tool call denied by the client's path policy.
+type PathViolation struct {
+	ToolUseID string
+	ToolName  string
+	Input     map[string]any
+	// Reason is the policy's error, as shown to the agent.
+	Reason string
+	Time   time.Time
+}
+
+// pathViolationRecorder collects the tool calls denied by a path policy.
+type pathViolationRecorder struct {
+	mu         sync.Mutex
+	violations []PathViolation
+}
+
+func (pr *pathViolationRecorder) record(violation PathViolation) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.violations = append(pr.violations, violation)
+}
+
+func (pr *pathViolationRecorder) list() []PathViolation {
+	if pr == nil {
+		return nil
+	}
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	return append([]PathViolation(nil), pr.violations...)
+}
+
+// connectionPathPolicy returns the policy checking the file tools of a
+// connection: the one set with WithPathPolicy, or, for ephemeral
+// workspaces, one confining them to the working directory and the added
+// directories. Call it once the workspace, if any, is set up.
+func connectionPathPolicy(options *Options) (PathPolicy, error) {
+	if options.PathPolicy != nil {
+		return options.PathPolicy, nil
+	}
+	if !options.ConfinePaths {
+		return nil, nil
+	}
+	var cwd string
+	if options.Cwd != nil {
+		cwd = *options.Cwd
+	}
+	policy, err := pathguard.New(cwd, pathguard.Rules{Allow: append([]string{"."}, options.AddDirs...)})
+	if err != nil {
+		return nil, fmt.Errorf("confining tool paths: %w", err)
+	}
+	return policy, nil
+}
+
+// guardToolPaths denies the file tool calls policy rejects, recording them.
+// A path policy makes the CLI ask before running file tools, so calls to
+// tools the client allowed are approved here once their paths pass. Other
+// calls go to next, unless the CLI would have denied them itself: nothing
+// but the policy asked it to route permission checks to the client, and
+// no permission callback is set.
+func guardToolPaths(policy PathPolicy, recorder *pathViolationRecorder, options *Options, permissions PermissionManager, next ControlRequestHandler) ControlRequestHandler {
+	allowed := options.AllowedTools
+	routed := options.PermissionPromptToolName != nil
+	reviewer := options.PlanReviewer
+	locale := options.Locale
+	return func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		toolName, _ := data["tool_name"].(string)
+		input, _ := data["input"].(map[string]any)
+		toolUseID, _ := data["tool_use_id"].(string)
+
+		if shared.IsPathTool(toolName) {
+			if err := policy.CheckTool(toolName, input); err != nil {
+				recorder.record(PathViolation{
+					ToolUseID: toolUseID,
+					ToolName:  toolName,
+					Input:     input,
+					Reason:    err.Error(),
+					Time:      time.Now(),
+				})
+				return permissionResponse(NewPermissionResultDeny(err.Error()), input), nil
+			}
+			if containsString(allowed, toolName) {
+				return permissionResponse(NewPermissionResultAllow(), input), nil
+			}
+		}
+		decided := routed || (permissions != nil && permissions.HasCallback()) ||
+			(toolName == ToolNameExitPlanMode && reviewer != nil)
+		if !decided {
+			return permissionResponse(NewPermissionResultDeny(LocalizedMessage(locale, MessageToolNotGranted, toolName)), input), nil
+		}
+		return next(ctx, data)
+	}
+}
+
+// validatePathPolicy rejects settings under which the CLI runs file tools
+// without asking the client.
+func validatePathPolicy(options *Options) error {
+	if options.PathPolicy == nil {
+		return nil
+	}
+	if options.PermissionMode != nil && *options.PermissionMode == PermissionModeBypassPermissions {
+		return fmt.Errorf("path policy cannot be combined with permission mode %s", PermissionModeBypassPermissions)
+	}
+	if tool := options.PermissionPromptToolName; tool != nil && *tool != "stdio" {
+		return fmt.Errorf("path policy cannot be combined with permission prompt tool %s", *tool)
+	}
+	return nil
+}
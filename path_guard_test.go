@@ -0,0 +1,176 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/severity1/claude-code-sdk-go/claudetest/fakecli"
+	"github.com/severity1/claude-code-sdk-go/pathguard"
+)
+
+func TestClientConfinePaths(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	confine := func(o *Options) { o.ConfinePaths = true }
+	client := NewClientWithTransport(newClientControlMockTransport(), WithCwd(dir), WithAllowedTools("Read", "Grep"), confine).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	outside := filepath.Join(filepath.Dir(dir), "elsewhere", "secret")
+	requestPathPermission(ctx, t, client, "Read", map[string]any{"file_path": filepath.Join(dir, "main.go")}, "allow")
+	requestPathPermission(ctx, t, client, "Grep", map[string]any{"pattern": "TODO"}, "allow")
+	requestPathPermission(ctx, t, client, "Read", map[string]any{"file_path": outside}, "deny")
+	// Tools the CLI asks about that nothing approves stay denied
+	requestPathPermission(ctx, t, client, "WebFetch", map[string]any{"url": "https://example.com"}, "deny")
+
+	violations := client.PathViolations()
+	if len(violations) != 1 {
+		t.Fatalf("Expected 1 violation, got %+v", violations)
+	}
+	if v := violations[0]; v.ToolName != "Read" || v.ToolUseID != "toolu_Read" || !strings.Contains(v.Reason, "outside the allowed directories") {
+		t.Errorf("Unexpected violation: %+v", v)
+	}
+}
+
+func TestClientPathPolicyWithCLI(t *testing.T) {
+	dir := t.TempDir()
+	inside := map[string]any{"file_path": filepath.Join(dir, "main.go")}
+	outside := map[string]any{"file_path": "/etc/passwd"}
+	cli := newFakeCLI(t, fakecli.Steps(
+		fakecli.CanUseTool("Read", "toolu_1", outside),
+		fakecli.CanUseTool("Read", "toolu_2", inside),
+		fakecli.Result("done"),
+	))
+	ctx, cancel := setupClientTestContext(t, time.Minute)
+	defer cancel()
+
+	policy, err := pathguard.New(dir, pathguard.Rules{Allow: []string{"."}})
+	assertNoError(t, err)
+	client := NewClient(WithCLIPath(cli.Path), WithCwd(dir), WithPathPolicy(policy), WithAllowedTools("Read", "Bash")).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	runFakeCLITurn(ctx, t, client, "read the files")
+	violations := client.PathViolations()
+	disconnectClientSafely(t, client)
+
+	// The CLI asks before every file tool, even allowed ones
+	args := strings.Join(cli.Args(t), " ")
+	if !strings.Contains(args, "--permission-prompt-tool stdio") || !strings.Contains(args, "--allowed-tools Bash ") {
+		t.Errorf("Expected file tools checked over stdio, got %q", args)
+	}
+	responses := controlResponses(t, cli)
+	assertPermissionResponse(t, responses["fakecli_req_1"]["response"].(map[string]any), "deny", "")
+	assertPermissionResponse(t, responses["fakecli_req_2"]["response"].(map[string]any), "allow", "")
+	if len(violations) != 1 || violations[0].ToolUseID != "toolu_1" {
+		t.Errorf("Expected the read outside the directory recorded, got %+v", violations)
+	}
+}
+
+func TestClientEphemeralWorkspaceConfinesPaths(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	shared := t.TempDir()
+	client := NewClientWithTransport(newClientControlMockTransport(), WithEphemeralWorkspace(""), WithAddDirs(shared)).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+	var asked []string
+	client.GetPermissionManager().SetPermissionCallback(func(_ context.Context, toolName string, _ map[string]any, _ ToolPermissionContext) (PermissionResult, error) {
+		asked = append(asked, toolName)
+		return NewPermissionResultAllow(), nil
+	})
+
+	workspace := client.Workspace()
+	requestPathPermission(ctx, t, client, "Write", map[string]any{"file_path": filepath.Join(workspace, "out.txt")}, "allow")
+	requestPathPermission(ctx, t, client, "Edit", map[string]any{"file_path": filepath.Join(shared, "lib.go")}, "allow")
+	requestPathPermission(ctx, t, client, "Write", map[string]any{"file_path": filepath.Join(workspace, "..", "escaped.txt")}, "deny")
+	requestPathPermission(ctx, t, client, "Bash", map[string]any{"command": "ls"}, "allow")
+
+	// Denied calls never reach the permission callback
+	if strings.Join(asked, ",") != "Write,Edit,Bash" {
+		t.Errorf("Expected the callback to decide allowed paths, got %v", asked)
+	}
+	if len(client.PathViolations()) != 1 {
+		t.Errorf("Expected 1 violation, got %+v", client.PathViolations())
+	}
+}
+
+func TestClientPathPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	policy := pathPolicyFunc(func(toolName string, input map[string]any) error {
+		if path, _ := input["file_path"].(string); strings.HasSuffix(path, ".env") {
+			return errors.New("no env files")
+		}
+		return nil
+	})
+	client := NewClientWithTransport(newClientControlMockTransport(), WithPathPolicy(policy), WithAllowedTools("Read"))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	requestPathPermission(ctx, t, client, "Read", map[string]any{"file_path": "/anywhere/main.go"}, "allow")
+	response := requestPathPermission(ctx, t, client, "Read", map[string]any{"file_path": ".env"}, "deny")
+	if response["message"] != "no env files" {
+		t.Errorf("Expected the policy's error as the message, got %v", response["message"])
+	}
+}
+
+func TestPathPolicyValidation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	policy := pathPolicyFunc(func(string, map[string]any) error { return nil })
+
+	for _, opt := range []Option{WithPermissionMode(PermissionModeBypassPermissions), WithPermissionPromptToolName("mcp__auth__prompt")} {
+		client := NewClientWithTransport(newClientControlMockTransport(), WithPathPolicy(policy), opt)
+		if err := client.Connect(ctx); err == nil || !strings.Contains(err.Error(), "path policy") {
+			t.Errorf("Expected the path policy to be rejected, got %v", err)
+		}
+	}
+	if _, err := QueryWithTransport(ctx, "read it", newClientControlMockTransport(), WithPathPolicy(policy)); err == nil {
+		t.Error("Expected one-shot queries to reject path policies")
+	}
+
+	// Implied confinement is not rejected
+	client := NewClientWithTransport(newClientControlMockTransport(), WithEphemeralWorkspace(""), WithPermissionMode(PermissionModeBypassPermissions))
+	connectClientSafely(ctx, t, client)
+	disconnectClientSafely(t, client)
+}
+
+func TestClientWithoutPathPolicyHasNoViolations(t *testing.T) {
+	client := NewClientWithTransport(newClientControlMockTransport()).(*ClientImpl)
+	if violations := client.PathViolations(); len(violations) != 0 {
+		t.Errorf("Expected no violations, got %+v", violations)
+	}
+	if policy, err := connectionPathPolicy(NewOptions(WithCwd(os.TempDir()))); policy != nil || err != nil {
+		t.Errorf("Expected no policy without confinement, got %v, %v", policy, err)
+	}
+}
+
+type pathPolicyFunc func(toolName string, input map[string]any) error
+
+func (f pathPolicyFunc) CheckTool(toolName string, input map[string]any) error {
+	return f(toolName, input)
+}
+
+func requestPathPermission(ctx context.Context, t *testing.T, client Client, toolName string, input map[string]any, behavior string) map[string]any {
+	t.Helper()
+	protocol := client.(*ClientImpl).GetControlProtocol().(*controlProtocol)
+	response, err := protocol.HandleControlRequest(ctx, &ControlRequest{
+		Subtype: ControlRequestTypeCanUseTool,
+		Data:    map[string]any{"tool_name": toolName, "tool_use_id": "toolu_" + toolName, "input": input},
+	})
+	if err != nil {
+		t.Fatalf("HandleControlRequest failed: %v", err)
+	}
+	if response.Data["behavior"] != behavior {
+		t.Errorf("Expected %s %v to get %q, got %v", toolName, input, behavior, response.Data)
+	}
+	return response.Data
+}
@@ -0,0 +1,408 @@
+// Package pathguard confines the file tools of an agent (Read, Write, Edit,
+// MultiEdit, NotebookEdit, NotebookRead, Glob, Grep and LS) to chosen
+// directories.
+//
+// A Policy canonicalizes each path a tool call names, resolving ".." and
+// symbolic links the way the operating system would, so a link inside an
+// allowed directory cannot lead the agent outside it. The resolved path is
+// then checked against deny rules and allow rules, and calls that break
+// them are recorded:
+//
+//	policy, err := pathguard.New(repoDir, pathguard.Rules{
+//		Allow: []string{".", "/tmp/build"},
+//		Deny:  []string{".git", "**/.env", "~/.ssh"},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	client := claudecode.NewClient(claudecode.WithCwd(repoDir), claudecode.WithPathPolicy(policy))
+//	// ... run turns ...
+//	for _, v := range policy.Violations() {
+//		log.Printf("%s tried %s", v.ToolName, v.Resolved)
+//	}
+//
+// A rule is a directory, covering everything under it, or a glob in which
+// * and ? match within a path element and ** matches any number of
+// elements. Relative rules and paths are relative to the policy's base
+// directory, and ~ is the user's home directory. The package has no
+// dependencies outside the standard library, so the SDK relies on it for
+// ephemeral workspaces.
+package pathguard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+// maxLinks bounds the symbolic links followed resolving one path, as the
+// operating system does.
+const maxLinks = 40
+
+// ErrViolation matches every *Violation with errors.Is.
+var ErrViolation = errors.New("path not allowed")
+
+// Tools lists the tools whose paths a Policy checks.
+var Tools = shared.PathTools
+
+// IsTool reports whether name is one of Tools.
+func IsTool(name string) bool {
+	return shared.IsPathTool(name)
+}
+
+// Rules configure a Policy.
+type Rules struct {
+	// Allow lists the directories and globs tools may access. Without any,
+	// every path not denied is allowed.
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists the directories and globs tools may not access, even
+	// within an allowed directory.
+	Deny []string `json:"deny,omitempty"`
+}
+
+// Violation is a tool call naming a path the policy does not allow.
+type Violation struct {
+	ToolName string
+	// Path is the path as the tool call named it.
+	Path string
+	// Resolved is the canonical path that was checked.
+	Resolved string
+	// Rule is the deny rule the path matched, or "" for a path outside
+	// every allowed directory.
+	Rule string
+	Time time.Time
+}
+
+func (v *Violation) Error() string {
+	if v.Rule != "" {
+		return fmt.Sprintf("%s access to %s is denied by rule %q", v.ToolName, v.Resolved, v.Rule)
+	}
+	return fmt.Sprintf("%s access to %s is outside the allowed directories", v.ToolName, v.Resolved)
+}
+
+// Is reports whether target is ErrViolation.
+func (v *Violation) Is(target error) bool {
+	return target == ErrViolation
+}
+
+// Policy checks tool paths against rules and records violations. It is
+// safe for concurrent use.
+type Policy struct {
+	base  string
+	allow []matcher
+	deny  []matcher
+	now   func() time.Time
+
+	mu         sync.Mutex
+	violations []Violation
+}
+
+// New returns a Policy applying rules, resolving relative rules and paths
+// against base, or the working directory when base is "".
+func New(base string, rules Rules) (*Policy, error) {
+	base, err := Canonicalize("", base)
+	if err != nil {
+		return nil, fmt.Errorf("pathguard: base directory: %w", err)
+	}
+	p := &Policy{base: base, now: time.Now}
+	for _, list := range []struct {
+		rules []string
+		into  *[]matcher
+	}{{rules.Allow, &p.allow}, {rules.Deny, &p.deny}} {
+		for _, rule := range list.rules {
+			m, err := newMatcher(base, rule)
+			if err != nil {
+				return nil, err
+			}
+			*list.into = append(*list.into, m)
+		}
+	}
+	return p, nil
+}
+
+// Base returns the directory relative paths are resolved against.
+func (p *Policy) Base() string {
+	return p.base
+}
+
+// CheckTool checks every path of a tool call, returning the first
+// *Violation. Tools other than Tools have no paths and pass.
+func (p *Policy) CheckTool(toolName string, input map[string]any) error {
+	for _, path := range ToolPaths(toolName, input) {
+		if err := p.CheckPath(toolName, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckPath checks a path a tool names, returning a *Violation if it is not
+// allowed, or an error if it cannot be resolved.
+func (p *Policy) CheckPath(toolName, path string) error {
+	resolved, err := Canonicalize(p.base, path)
+	if err != nil {
+		return fmt.Errorf("pathguard: %w", err)
+	}
+	rule, ok := p.decide(resolved)
+	if ok {
+		return nil
+	}
+	v := Violation{ToolName: toolName, Path: path, Resolved: resolved, Rule: rule, Time: p.now()}
+	p.mu.Lock()
+	p.violations = append(p.violations, v)
+	p.mu.Unlock()
+	return &v
+}
+
+// decide returns the deny rule resolved matches, and whether it is
+// allowed.
+func (p *Policy) decide(resolved string) (string, bool) {
+	for _, m := range p.deny {
+		if m.match(resolved) {
+			return m.rule, false
+		}
+	}
+	if len(p.allow) == 0 {
+		return "", true
+	}
+	for _, m := range p.allow {
+		if m.match(resolved) {
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// Violations returns the violations recorded so far, oldest first.
+func (p *Policy) Violations() []Violation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Violation(nil), p.violations...)
+}
+
+// ToolPaths returns the paths a call of a file tool names. Glob and Grep
+// search the base directory when given no path, which is returned as ".",
+// and a Glob pattern also names the directory it starts in, which may be
+// elsewhere, as in "../../etc/*".
+func ToolPaths(toolName string, input map[string]any) []string {
+	str := func(key string) string {
+		s, _ := input[key].(string)
+		return s
+	}
+	switch toolName {
+	case "Read", "Write", "Edit", "MultiEdit":
+		return nonEmpty(str("file_path"))
+	case "NotebookEdit", "NotebookRead":
+		return nonEmpty(str("notebook_path"))
+	case "LS":
+		return nonEmpty(str("path"))
+	case "Grep":
+		if path := str("path"); path != "" {
+			return []string{path}
+		}
+		return []string{"."}
+	case "Glob":
+		dir := str("path")
+		if dir == "" {
+			dir = "."
+		}
+		paths := []string{dir}
+		pattern := str("pattern")
+		switch static := staticPrefix(pattern); {
+		case pattern == "" || static == ".":
+		case filepath.IsAbs(pattern):
+			paths = append(paths, static)
+		default:
+			paths = append(paths, dir+string(filepath.Separator)+static)
+		}
+		return paths
+	}
+	return nil
+}
+
+func nonEmpty(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return []string{path}
+}
+
+// Canonicalize returns the absolute form of path, relative to base when not
+// absolute, with "~" expanded and every symbolic link resolved. Elements
+// that do not exist yet, such as a file about to be written, are kept as
+// given.
+func Canonicalize(base, path string) (string, error) {
+	return canonicalize(base, path, 0)
+}
+
+func canonicalize(base, path string, links int) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = home + path[1:]
+	}
+	if !filepath.IsAbs(path) {
+		if base == "" {
+			wd, err := os.Getwd()
+			if err != nil {
+				return "", err
+			}
+			base = wd
+		}
+		path = base + string(filepath.Separator) + path
+	}
+
+	volume := filepath.VolumeName(path)
+	resolved := volume + string(filepath.Separator)
+	missing := false
+	elements := strings.FieldsFunc(path[len(volume):], func(r rune) bool {
+		return r < 0x80 && os.IsPathSeparator(uint8(r))
+	})
+	for _, element := range elements {
+		switch element {
+		case ".":
+			continue
+		case "..":
+			// After links are resolved, ".." is the parent of the target
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, element)
+		if !missing {
+			info, err := os.Lstat(next)
+			switch {
+			case err != nil:
+				missing = true
+			case info.Mode()&os.ModeSymlink != 0:
+				if links >= maxLinks {
+					return "", fmt.Errorf("too many links resolving %s", path)
+				}
+				target, err := os.Readlink(next)
+				if err != nil {
+					return "", err
+				}
+				if next, err = canonicalize(resolved, target, links+1); err != nil {
+					return "", err
+				}
+			}
+		}
+		resolved = next
+	}
+	return resolved, nil
+}
+
+// matcher matches canonical paths against a rule.
+type matcher struct {
+	rule string
+	// prefix is the canonical directory the rule covers, or the directory a
+	// glob starts in
+	prefix string
+	// glob holds the elements of a glob after prefix, if the rule is one
+	glob []string
+}
+
+func newMatcher(base, rule string) (matcher, error) {
+	if strings.TrimSpace(rule) == "" {
+		return matcher{}, errors.New("pathguard: empty rule")
+	}
+	m := matcher{rule: rule}
+	static, rest := splitGlob(rule)
+	prefix, err := Canonicalize(base, static)
+	if err != nil {
+		return matcher{}, fmt.Errorf("pathguard: rule %q: %w", rule, err)
+	}
+	m.prefix = prefix
+	if rest != "" {
+		m.glob = strings.FieldsFunc(filepath.ToSlash(rest), func(r rune) bool { return r == '/' })
+		for _, element := range m.glob {
+			if _, err := filepath.Match(element, ""); err != nil {
+				return matcher{}, fmt.Errorf("pathguard: rule %q: %w", rule, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m matcher) match(path string) bool {
+	if m.glob == nil {
+		return within(m.prefix, path)
+	}
+	if !within(m.prefix, path) {
+		return false
+	}
+	rel, err := filepath.Rel(m.prefix, path)
+	if err != nil {
+		return false
+	}
+	var elements []string
+	if rel != "." {
+		elements = strings.Split(filepath.ToSlash(rel), "/")
+	}
+	return matchElements(m.glob, elements)
+}
+
+// matchElements matches path elements against glob elements, in which **
+// matches any number of elements. A glob matching a directory also covers
+// everything under it.
+func matchElements(glob, elements []string) bool {
+	if len(glob) == 0 {
+		return true
+	}
+	if glob[0] == "**" {
+		for i := 0; i <= len(elements); i++ {
+			if matchElements(glob[1:], elements[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elements) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(glob[0], elements[0])
+	return ok && matchElements(glob[1:], elements[1:])
+}
+
+// within reports whether path is dir or under it.
+func within(dir, path string) bool {
+	if path == dir {
+		return true
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return strings.HasPrefix(path, dir)
+}
+
+// staticPrefix returns the elements of a glob before the first containing
+// a wildcard, or "." when there are none.
+func staticPrefix(pattern string) string {
+	static, _ := splitGlob(pattern)
+	return static
+}
+
+// splitGlob splits a glob into the elements before the first containing a
+// wildcard and the rest.
+func splitGlob(pattern string) (static, rest string) {
+	i := strings.IndexAny(pattern, "*?[")
+	if i < 0 {
+		return pattern, ""
+	}
+	// Cut back to the separator before the wildcard's element
+	j := strings.LastIndexAny(pattern[:i], `/\`)
+	switch {
+	case j < 0:
+		return ".", pattern
+	case j == 0:
+		return pattern[:1], pattern[1:]
+	}
+	return pattern[:j], pattern[j+1:]
+}
@@ -0,0 +1,239 @@
+package pathguard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	root := testTree(t)
+	outside := filepath.Join(root, "outside")
+	repo := filepath.Join(root, "repo")
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"relative", "src/main.go", filepath.Join(repo, "src", "main.go")},
+		{"dot dot", "src/../../outside/secret", filepath.Join(outside, "secret")},
+		{"absolute", filepath.Join(outside, "secret"), filepath.Join(outside, "secret")},
+		{"new file", "src/new/file.go", filepath.Join(repo, "src", "new", "file.go")},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests, []struct {
+			name string
+			path string
+			want string
+		}{
+			{"link", "escape/secret", filepath.Join(outside, "secret")},
+			// ".." after a link is the parent of its target, as for the kernel
+			{"dot dot after link", "escape/../repo-sibling", filepath.Join(root, "repo-sibling")},
+			{"dangling link", "dangling", filepath.Join(outside, "created-later")},
+		}...)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Canonicalize(repo, test.path)
+			if err != nil {
+				t.Fatalf("Canonicalize(%q) failed: %v", test.path, err)
+			}
+			if got != test.want {
+				t.Errorf("Canonicalize(%q) = %q, want %q", test.path, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeLinkLoop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privileges on Windows")
+	}
+	dir := t.TempDir()
+	if err := os.Symlink("b", filepath.Join(dir, "a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a", filepath.Join(dir, "b")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Canonicalize(dir, "a/file"); err == nil {
+		t.Error("Expected a link loop to fail")
+	}
+}
+
+func TestPolicyCheckPath(t *testing.T) {
+	root := testTree(t)
+	repo := filepath.Join(root, "repo")
+	policy, err := New(repo, Rules{
+		Allow: []string{".", "../shared"},
+		Deny:  []string{".git", "**/.env", "secrets/*.key"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		allowed  bool
+		wantRule string
+	}{
+		{"src/main.go", true, ""},
+		{filepath.Join(repo, "README.md"), true, ""},
+		{"../shared/lib.go", true, ""},
+		{"secrets/notes.txt", true, ""},
+		{".git/config", false, ".git"},
+		{"src/.env", false, "**/.env"},
+		{".env", false, "**/.env"},
+		{"secrets/prod.key", false, "secrets/*.key"},
+		{"../outside/secret", false, ""},
+		{"/", false, ""},
+	}
+	if runtime.GOOS != "windows" {
+		tests = append(tests, []struct {
+			path     string
+			allowed  bool
+			wantRule string
+		}{
+			{"escape/secret", false, ""},
+			{"dangling", false, ""},
+		}...)
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			err := policy.CheckPath("Read", test.path)
+			if test.allowed {
+				if err != nil {
+					t.Errorf("Expected %q to be allowed, got %v", test.path, err)
+				}
+				return
+			}
+			var v *Violation
+			if !errors.As(err, &v) || !errors.Is(err, ErrViolation) {
+				t.Fatalf("Expected a violation for %q, got %v", test.path, err)
+			}
+			if v.Rule != test.wantRule || v.Path != test.path || v.ToolName != "Read" {
+				t.Errorf("Unexpected violation: %+v", v)
+			}
+		})
+	}
+
+	violations := policy.Violations()
+	if len(violations) == 0 || violations[0].Path != ".git/config" || violations[0].Time.IsZero() {
+		t.Errorf("Expected violations to be recorded in order, got %+v", violations)
+	}
+}
+
+func TestPolicyWithoutAllowRules(t *testing.T) {
+	policy, err := New(t.TempDir(), Rules{Deny: []string{"/etc"}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := policy.CheckPath("Read", "anything/at/all"); err != nil {
+		t.Errorf("Expected paths not denied to be allowed, got %v", err)
+	}
+	if err := policy.CheckPath("Write", "/etc/passwd"); !errors.Is(err, ErrViolation) {
+		t.Errorf("Expected /etc to be denied, got %v", err)
+	}
+}
+
+func TestPolicyCheckTool(t *testing.T) {
+	repo := filepath.Join(testTree(t), "repo")
+	policy, err := New(repo, Rules{Allow: []string{"."}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	tests := []struct {
+		tool    string
+		input   map[string]any
+		allowed bool
+	}{
+		{"Edit", map[string]any{"file_path": "src/main.go"}, true},
+		{"Write", map[string]any{"file_path": "../outside/x"}, false},
+		{"NotebookEdit", map[string]any{"notebook_path": "../outside/x.ipynb"}, false},
+		{"Grep", map[string]any{"pattern": "TODO"}, true},
+		{"Grep", map[string]any{"pattern": "TODO", "path": "/"}, false},
+		{"Glob", map[string]any{"pattern": "**/*.go"}, true},
+		{"Glob", map[string]any{"pattern": "../../*"}, false},
+		{"Glob", map[string]any{"pattern": filepath.Join(repo, "..", "outside", "*")}, false},
+		{"Bash", map[string]any{"command": "cat /etc/passwd"}, true},
+	}
+	for _, test := range tests {
+		err := policy.CheckTool(test.tool, test.input)
+		if (err == nil) != test.allowed {
+			t.Errorf("CheckTool(%s, %v) = %v, want allowed=%v", test.tool, test.input, err, test.allowed)
+		}
+	}
+}
+
+func TestToolPaths(t *testing.T) {
+	tests := []struct {
+		tool  string
+		input map[string]any
+		want  []string
+	}{
+		{"Read", map[string]any{"file_path": "/a/b"}, []string{"/a/b"}},
+		{"Read", map[string]any{}, nil},
+		{"LS", map[string]any{"path": "/a"}, []string{"/a"}},
+		{"Glob", map[string]any{"pattern": "src/**/*.go", "path": "/a"}, []string{"/a", "/a" + string(filepath.Separator) + "src"}},
+		{"Glob", map[string]any{"pattern": "*.go"}, []string{"."}},
+		{"WebFetch", map[string]any{"url": "https://example.com"}, nil},
+	}
+	for _, test := range tests {
+		if got := ToolPaths(test.tool, test.input); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ToolPaths(%s, %v) = %q, want %q", test.tool, test.input, got, test.want)
+		}
+	}
+}
+
+func TestNewInvalidRules(t *testing.T) {
+	for _, rules := range []Rules{
+		{Allow: []string{""}},
+		{Deny: []string{"src/[.go"}},
+	} {
+		if _, err := New(t.TempDir(), rules); err == nil {
+			t.Errorf("Expected %+v to be rejected", rules)
+		}
+	}
+}
+
+// testTree creates:
+//
+//	root/repo/src/main.go
+//	root/repo/escape -> root/outside
+//	root/repo/dangling -> root/outside/created-later
+//	root/outside/secret
+//	root/shared/lib.go
+func testTree(t *testing.T) string {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"repo/src/main.go", "outside/secret", "shared/lib.go"} {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		for link, target := range map[string]string{
+			"escape":   filepath.Join(root, "outside"),
+			"dangling": filepath.Join(root, "outside", "created-later"),
+		} {
+			if err := os.Symlink(target, filepath.Join(root, "repo", link)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root
+}
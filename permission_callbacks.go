@@ -3,6 +3,7 @@ package claudecode
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -33,17 +34,17 @@ type PermissionRuleValue struct {
 
 // PermissionUpdate represents a permission update request
 type PermissionUpdate struct {
-	Type        PermissionUpdateDestination `json:"type"`
-	Rules       []PermissionRuleValue     `json:"rules,omitempty"`
-	Behavior    *PermissionBehavior        `json:"behavior,omitempty"`
-	Mode        *PermissionMode           `json:"mode,omitempty"`
-	Directories []string                   `json:"directories,omitempty"`
+	Type        PermissionUpdateDestination  `json:"type"`
+	Rules       []PermissionRuleValue        `json:"rules,omitempty"`
+	Behavior    *PermissionBehavior          `json:"behavior,omitempty"`
+	Mode        *PermissionMode              `json:"mode,omitempty"`
+	Directories []string                     `json:"directories,omitempty"`
 	Destination *PermissionUpdateDestination `json:"destination,omitempty"`
 }
 
 // ToolPermissionContext provides context information for tool permission callbacks
 type ToolPermissionContext struct {
-	Signal      any              `json:"signal,omitempty"`
+	Signal      any                `json:"signal,omitempty"`
 	Suggestions []PermissionUpdate `json:"suggestions,omitempty"`
 }
 
@@ -58,7 +59,7 @@ type PermissionResult interface {
 
 // PermissionResultAllow implements PermissionResult for allowed operations
 type PermissionResultAllow struct {
-	updatedInput      map[string]any    `json:"-"`
+	updatedInput       map[string]any     `json:"-"`
 	updatedPermissions []PermissionUpdate `json:"-"`
 }
 
@@ -106,8 +107,8 @@ func NewPermissionResultAllow() *PermissionResultAllow {
 
 // PermissionResultDeny implements PermissionResult for denied operations
 type PermissionResultDeny struct {
-	message   string  `json:"-"`
-	interrupt bool    `json:"-"`
+	message   string `json:"-"`
+	interrupt bool   `json:"-"`
 }
 
 // Behavior returns "deny"
@@ -168,8 +169,21 @@ type PermissionManager interface {
 	HasCallback() bool
 }
 
-// permissionManager implements PermissionManager
+// permissionManager implements PermissionManager. Callbacks pushed with a
+// PermissionStack override the one set with SetPermissionCallback until
+// they are popped.
 type permissionManager struct {
+	mu        sync.RWMutex
+	callback  CanUseToolFunc
+	overrides []permissionOverride
+	auditor   func(PermissionDecision)
+	// locale of the denials written for failed callbacks
+	locale string
+}
+
+// permissionOverride is a callback pushed with a PermissionStack.
+type permissionOverride struct {
+	name     string
 	callback CanUseToolFunc
 }
 
@@ -180,12 +194,44 @@ func NewPermissionManager() PermissionManager {
 
 // SetPermissionCallback sets the permission callback
 func (pm *permissionManager) SetPermissionCallback(callback CanUseToolFunc) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
 	pm.callback = callback
 }
 
-// CheckPermission executes the permission callback if set
+// active returns the callback deciding permissions and its policy name.
+func (pm *permissionManager) active() (string, CanUseToolFunc, func(PermissionDecision)) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	if n := len(pm.overrides); n > 0 {
+		top := pm.overrides[n-1]
+		return top.name, top.callback, pm.auditor
+	}
+	return DefaultPermissionPolicy, pm.callback, pm.auditor
+}
+
+// CheckPermission executes the active permission callback, reporting the
+// decision to the auditor set with PermissionStack.SetAuditor.
 func (pm *permissionManager) CheckPermission(ctx context.Context, toolName string, input map[string]any, permContext ToolPermissionContext) (PermissionResult, error) {
-	if pm.callback == nil {
+	policy, callback, auditor := pm.active()
+	result, err := runPermissionCallback(ctx, callback, toolName, input, permContext, pm.locale)
+	if auditor != nil && result != nil {
+		auditor(PermissionDecision{
+			Time:     time.Now(),
+			ToolName: toolName,
+			Policy:   policy,
+			Behavior: result.Behavior(),
+			Message:  result.Message(),
+			Err:      err,
+		})
+	}
+	return result, err
+}
+
+// runPermissionCallback runs callback, allowing every tool when it is nil.
+// A callback that fails or times out denies the tool in locale.
+func runPermissionCallback(ctx context.Context, callback CanUseToolFunc, toolName string, input map[string]any, permContext ToolPermissionContext, locale string) (PermissionResult, error) {
+	if callback == nil {
 		// Default: allow all operations when no callback is set
 		return NewPermissionResultAllow(), nil
 	}
@@ -208,7 +254,7 @@ func (pm *permissionManager) CheckPermission(ctx context.Context, toolName strin
 			}
 		}()
 
-		result, err := pm.callback(timeoutCtx, toolName, input, permContext)
+		result, err := callback(timeoutCtx, toolName, input, permContext)
 		if err != nil {
 			select {
 			case errChan <- err:
@@ -226,13 +272,15 @@ func (pm *permissionManager) CheckPermission(ctx context.Context, toolName strin
 	case result := <-resultChan:
 		return result, nil
 	case err := <-errChan:
-		return NewPermissionResultDeny("Callback failed"), fmt.Errorf("permission callback failed: %w", err)
+		return NewPermissionResultDeny(LocalizedMessage(locale, MessagePermissionCallbackFailed)), fmt.Errorf("permission callback failed: %w", err)
 	case <-timeoutCtx.Done():
-		return NewPermissionResultDeny("Callback timeout"), nil
+		return NewPermissionResultDeny(LocalizedMessage(locale, MessagePermissionCallbackTimeout)), nil
 	}
 }
 
-// HasCallback returns true if a callback is set
+// HasCallback returns true if a callback is set or pushed
 func (pm *permissionManager) HasCallback() bool {
-	return pm.callback != nil
-}
\ No newline at end of file
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return pm.callback != nil || len(pm.overrides) > 0
+}
@@ -281,4 +281,4 @@ func TestPermissionUpdate(t *testing.T) {
 			t.Errorf("Expected rule_content 'allow', got: %s", update.Rules[0].RuleContent)
 		}
 	})
-}
\ No newline at end of file
+}
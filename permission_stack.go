@@ -0,0 +1,76 @@
+package claudecode
+
+import "time"
+
+// DefaultPermissionPolicy is the policy name of decisions made by the
+// callback set with PermissionManager.SetPermissionCallback, or by the
+// default of allowing every tool when none is set.
+const DefaultPermissionPolicy = "default"
+
+// PermissionDecision records the answer of a client's permission callback
+// to a tool call.
+type PermissionDecision struct {
+	Time     time.Time
+	ToolName string
+	// Policy names the callback that decided: the name it was pushed
+	// with, or DefaultPermissionPolicy.
+	Policy   string
+	Behavior PermissionBehavior
+	// Message is the reason given for a denial.
+	Message string
+	// Err is the callback's error, if it failed; the tool was denied.
+	Err error
+}
+
+// PermissionStack overrides a client's permission callback for a while,
+// such as a stricter policy during one step of a pipeline. The callback
+// pushed last decides every tool call until it is popped, replacing the
+// callback set with SetPermissionCallback rather than running after it;
+// wrap that callback to tighten it instead. Changes apply from the next
+// permission check, including while connected.
+//
+//	perms := client.(*claudecode.ClientImpl).Permissions()
+//	perms.Push("deploy-step", readOnly)
+//	defer perms.Pop()
+//
+// The CLI asks the SDK for permission when the client sets
+// WithPermissionPromptToolName("stdio").
+type PermissionStack struct {
+	pm *permissionManager
+}
+
+// Push makes callback decide permissions, reported as policy name in
+// PermissionDecisions, until it is popped.
+func (s *PermissionStack) Push(name string, callback CanUseToolFunc) {
+	s.pm.mu.Lock()
+	defer s.pm.mu.Unlock()
+	s.pm.overrides = append(s.pm.overrides, permissionOverride{name: name, callback: callback})
+}
+
+// Pop removes the callback pushed last, reporting whether there was one.
+func (s *PermissionStack) Pop() bool {
+	s.pm.mu.Lock()
+	defer s.pm.mu.Unlock()
+	n := len(s.pm.overrides)
+	if n == 0 {
+		return false
+	}
+	s.pm.overrides[n-1] = permissionOverride{}
+	s.pm.overrides = s.pm.overrides[:n-1]
+	return true
+}
+
+// Active returns the policy name of the callback deciding permissions.
+func (s *PermissionStack) Active() string {
+	name, _, _ := s.pm.active()
+	return name
+}
+
+// SetAuditor sets a function receiving every decision, with the policy that
+// made it, or removes it when nil. It is called from the goroutine handling
+// the permission check, so it should not block.
+func (s *PermissionStack) SetAuditor(auditor func(PermissionDecision)) {
+	s.pm.mu.Lock()
+	defer s.pm.mu.Unlock()
+	s.pm.auditor = auditor
+}
@@ -0,0 +1,141 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// denyTools returns a permission callback denying every tool with message.
+func denyTools(message string) CanUseToolFunc {
+	return func(context.Context, string, map[string]any, ToolPermissionContext) (PermissionResult, error) {
+		return NewPermissionResultDeny(message), nil
+	}
+}
+
+func TestPermissionStackOverridesOnLiveClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := NewClientWithTransport(newClientControlMockTransport(), WithPermissionPromptToolName("stdio")).(*ClientImpl)
+	perms := client.Permissions()
+	var mu sync.Mutex
+	var decisions []PermissionDecision
+	perms.SetAuditor(func(d PermissionDecision) {
+		mu.Lock()
+		defer mu.Unlock()
+		decisions = append(decisions, d)
+	})
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	requestPathPermission(ctx, t, client, "Bash", map[string]any{"command": "ls"}, "allow")
+
+	perms.Push("pipeline-step", denyTools("read-only step"))
+	if perms.Active() != "pipeline-step" {
+		t.Errorf("Expected the pushed policy to be active, got %q", perms.Active())
+	}
+	response := requestPathPermission(ctx, t, client, "Bash", map[string]any{"command": "rm -rf build"}, "deny")
+	if response["message"] != "read-only step" {
+		t.Errorf("Expected the override's message, got %v", response["message"])
+	}
+
+	if !perms.Pop() {
+		t.Fatal("Expected Pop to remove the override")
+	}
+	if perms.Pop() {
+		t.Error("Expected Pop on an empty stack to report false")
+	}
+	requestPathPermission(ctx, t, client, "Bash", map[string]any{"command": "ls"}, "allow")
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []struct {
+		policy   string
+		behavior PermissionBehavior
+	}{
+		{DefaultPermissionPolicy, PermissionBehaviorAllow},
+		{"pipeline-step", PermissionBehaviorDeny},
+		{DefaultPermissionPolicy, PermissionBehaviorAllow},
+	}
+	if len(decisions) != len(want) {
+		t.Fatalf("Expected %d decisions, got %+v", len(want), decisions)
+	}
+	for i, w := range want {
+		d := decisions[i]
+		if d.Policy != w.policy || d.Behavior != w.behavior || d.ToolName != "Bash" || d.Time.IsZero() {
+			t.Errorf("Decision %d: expected %s by %q, got %+v", i, w.behavior, w.policy, d)
+		}
+	}
+}
+
+func TestPermissionStackNesting(t *testing.T) {
+	pm := NewPermissionManager().(*permissionManager)
+	pm.SetPermissionCallback(denyTools("base"))
+	stack := &PermissionStack{pm: pm}
+	stack.Push("outer", denyTools("outer"))
+	stack.Push("inner", denyTools("inner"))
+
+	for _, want := range []string{"inner", "outer", "base"} {
+		result, err := pm.CheckPermission(context.Background(), "Write", nil, ToolPermissionContext{})
+		if err != nil {
+			t.Fatalf("CheckPermission failed: %v", err)
+		}
+		if result.Message() != want {
+			t.Errorf("Expected %q to decide, got %q", want, result.Message())
+		}
+		stack.Pop()
+	}
+	if stack.Active() != DefaultPermissionPolicy {
+		t.Errorf("Expected the default policy once popped, got %q", stack.Active())
+	}
+}
+
+func TestPermissionStackAuditsErrors(t *testing.T) {
+	pm := NewPermissionManager().(*permissionManager)
+	stack := &PermissionStack{pm: pm}
+	var got PermissionDecision
+	stack.SetAuditor(func(d PermissionDecision) { got = d })
+	stack.Push("broken", func(context.Context, string, map[string]any, ToolPermissionContext) (PermissionResult, error) {
+		return nil, errors.New("policy store unavailable")
+	})
+
+	if _, err := pm.CheckPermission(context.Background(), "Edit", nil, ToolPermissionContext{}); err == nil {
+		t.Fatal("Expected the callback's error")
+	}
+	if got.Policy != "broken" || got.Behavior != PermissionBehaviorDeny || got.Err == nil {
+		t.Errorf("Expected the failure to be audited as a denial by broken, got %+v", got)
+	}
+	if !pm.HasCallback() {
+		t.Error("Expected a pushed callback to count as a callback")
+	}
+}
+
+func TestPermissionStackConcurrentSwaps(t *testing.T) {
+	pm := NewPermissionManager().(*permissionManager)
+	stack := &PermissionStack{pm: pm}
+	stack.SetAuditor(func(PermissionDecision) {})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				stack.Push("strict", denyTools("strict"))
+				stack.Pop()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := pm.CheckPermission(context.Background(), "Read", nil, ToolPermissionContext{}); err != nil {
+					t.Errorf("CheckPermission failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
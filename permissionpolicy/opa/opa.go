@@ -0,0 +1,262 @@
+// Package opa decides tool permissions with Open Policy Agent policies, for
+// organizations that keep their rules in Rego.
+//
+// A Policy builds an input document for each permission request, asks an
+// Evaluator for a decision, and turns it into a permission result. The SDK
+// has no dependencies, so it does not embed a Rego engine: Server queries
+// an OPA server, and an EvaluatorFunc can wrap a query prepared with the
+// OPA Go library.
+//
+//	policy := opa.New(&opa.Server{URL: "http://localhost:8181", Path: "claude/tools/decision"},
+//		opa.WithCwd(repoDir),
+//		opa.WithSessionMetadata(map[string]any{"team": "payments"}),
+//		opa.WithCache(time.Minute, 1000),
+//	)
+//	client.(*claudecode.ClientImpl).GetPermissionManager().SetPermissionCallback(policy.CanUseTool)
+//
+// The policy sees input.tool, input.args, input.cwd and input.session, and
+// returns either a boolean or an object such as:
+//
+//	package claude.tools
+//
+//	default decision := {"allow": false, "reason": "not allowed by policy"}
+//
+//	decision := {"allow": true} if input.tool in {"Read", "Grep", "Glob"}
+//
+//	decision := {"allow": true} if {
+//		input.tool == "Bash"
+//		startswith(input.args.command, "go test")
+//	}
+//
+// Requests fail closed: a policy that errors or is undefined denies the
+// tool.
+package opa
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// DefaultDenyMessage is the reason given to the agent when a policy denies
+// a tool without one.
+const DefaultDenyMessage = "denied by policy"
+
+// ErrUndefined is returned by an Evaluator when the policy produced no
+// decision for the input.
+var ErrUndefined = errors.New("policy decision is undefined")
+
+// Input is the document a policy is evaluated against.
+type Input struct {
+	Tool    string         `json:"tool"`
+	Args    map[string]any `json:"args"`
+	Cwd     string         `json:"cwd,omitempty"`
+	Session map[string]any `json:"session,omitempty"`
+}
+
+// Decision is a policy's answer to a permission request.
+type Decision struct {
+	Allow bool `json:"allow"`
+	// Reason is shown to the agent when the tool is denied.
+	Reason string `json:"reason,omitempty"`
+	// Interrupt stops the turn when the tool is denied.
+	Interrupt bool `json:"interrupt,omitempty"`
+	// Args, when set on an allowed tool, replace its input.
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// UnmarshalJSON accepts a bare boolean, as returned by rules such as
+// data.claude.tools.allow, as well as a decision object.
+func (d *Decision) UnmarshalJSON(data []byte) error {
+	var allow bool
+	if err := json.Unmarshal(data, &allow); err == nil {
+		*d = Decision{Allow: allow}
+		return nil
+	}
+	type decision Decision
+	var out decision
+	if err := json.Unmarshal(data, &out); err != nil {
+		return fmt.Errorf("decision must be a boolean or an object: %w", err)
+	}
+	*d = Decision(out)
+	return nil
+}
+
+// Evaluator evaluates a policy against an input document.
+type Evaluator interface {
+	Evaluate(ctx context.Context, input Input) (Decision, error)
+}
+
+// EvaluatorFunc adapts a function to an Evaluator.
+type EvaluatorFunc func(ctx context.Context, input Input) (Decision, error)
+
+// Evaluate calls f.
+func (f EvaluatorFunc) Evaluate(ctx context.Context, input Input) (Decision, error) {
+	return f(ctx, input)
+}
+
+// Option configures a Policy.
+type Option func(*Policy)
+
+// WithCwd sets input.cwd, normally the client's working directory.
+func WithCwd(cwd string) Option {
+	return func(p *Policy) {
+		p.cwd = cwd
+	}
+}
+
+// WithSessionMetadata sets input.session, such as the user, team or
+// environment the session runs for.
+func WithSessionMetadata(metadata map[string]any) Option {
+	return func(p *Policy) {
+		p.session = metadata
+	}
+}
+
+// WithCache remembers decisions for ttl, keyed by tool and arguments, so
+// repeated calls are not evaluated again. At most size decisions are kept,
+// dropping the least recently used. Errors are not cached.
+func WithCache(ttl time.Duration, size int) Option {
+	return func(p *Policy) {
+		p.cacheTTL = ttl
+		p.cacheSize = size
+	}
+}
+
+// Policy decides tool permissions with an Evaluator. It is safe for
+// concurrent use.
+type Policy struct {
+	evaluator Evaluator
+	cwd       string
+	session   map[string]any
+	cacheTTL  time.Duration
+	cacheSize int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	key      string
+	decision Decision
+	expires  time.Time
+}
+
+// New returns a Policy that asks evaluator for decisions.
+func New(evaluator Evaluator, opts ...Option) *Policy {
+	p := &Policy{
+		evaluator: evaluator,
+		entries:   make(map[string]*list.Element),
+		order:     list.New(),
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// CanUseTool is a claudecode.CanUseToolFunc. It denies the tool, with the
+// error as the reason, when the policy cannot be evaluated.
+func (p *Policy) CanUseTool(ctx context.Context, toolName string, args map[string]any, _ claudecode.ToolPermissionContext) (claudecode.PermissionResult, error) {
+	decision, err := p.Decide(ctx, toolName, args)
+	if err != nil {
+		return claudecode.NewPermissionResultDeny(fmt.Sprintf("%s: %v", DefaultDenyMessage, err)), nil
+	}
+	if decision.Allow {
+		allow := claudecode.NewPermissionResultAllow()
+		if decision.Args != nil {
+			allow.WithInput(decision.Args)
+		}
+		return allow, nil
+	}
+	reason := decision.Reason
+	if reason == "" {
+		reason = DefaultDenyMessage
+	}
+	deny := claudecode.NewPermissionResultDeny(reason)
+	if decision.Interrupt {
+		deny.WithInterrupt()
+	}
+	return deny, nil
+}
+
+// Decide evaluates the policy for a tool call, using a cached decision
+// when there is one.
+func (p *Policy) Decide(ctx context.Context, toolName string, args map[string]any) (Decision, error) {
+	key, cacheable := p.cacheKey(toolName, args)
+	if cacheable {
+		if decision, ok := p.cached(key); ok {
+			return decision, nil
+		}
+	}
+
+	decision, err := p.evaluator.Evaluate(ctx, Input{
+		Tool:    toolName,
+		Args:    args,
+		Cwd:     p.cwd,
+		Session: p.session,
+	})
+	if err != nil {
+		return Decision{}, err
+	}
+	if cacheable {
+		p.store(key, decision)
+	}
+	return decision, nil
+}
+
+// cacheKey identifies a tool call. Map keys are marshaled in sorted order,
+// so equal arguments give equal keys.
+func (p *Policy) cacheKey(toolName string, args map[string]any) (string, bool) {
+	if p.cacheTTL <= 0 || p.cacheSize <= 0 {
+		return "", false
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", false
+	}
+	return toolName + "\x00" + string(data), true
+}
+
+func (p *Policy) cached(key string) (Decision, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	element, ok := p.entries[key]
+	if !ok {
+		return Decision{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !p.now().Before(entry.expires) {
+		p.order.Remove(element)
+		delete(p.entries, key)
+		return Decision{}, false
+	}
+	p.order.MoveToFront(element)
+	return entry.decision, true
+}
+
+func (p *Policy) store(key string, decision Decision) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry := &cacheEntry{key: key, decision: decision, expires: p.now().Add(p.cacheTTL)}
+	if element, ok := p.entries[key]; ok {
+		element.Value = entry
+		p.order.MoveToFront(element)
+		return
+	}
+	p.entries[key] = p.order.PushFront(entry)
+	for p.order.Len() > p.cacheSize {
+		oldest := p.order.Back()
+		p.order.Remove(oldest)
+		delete(p.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
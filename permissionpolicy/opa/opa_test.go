@@ -0,0 +1,164 @@
+package opa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestCanUseTool(t *testing.T) {
+	tests := []struct {
+		name        string
+		decision    Decision
+		err         error
+		wantAllow   bool
+		wantMessage string
+		wantArgs    map[string]any
+	}{
+		{name: "allow", decision: Decision{Allow: true}, wantAllow: true},
+		{name: "allow with rewritten args", decision: Decision{Allow: true, Args: map[string]any{"command": "go test ./..."}},
+			wantAllow: true, wantArgs: map[string]any{"command": "go test ./..."}},
+		{name: "deny with reason", decision: Decision{Reason: "no network access"}, wantMessage: "no network access"},
+		{name: "deny without reason", decision: Decision{}, wantMessage: DefaultDenyMessage},
+		{name: "evaluation error fails closed", err: ErrUndefined, wantMessage: DefaultDenyMessage + ": " + ErrUndefined.Error()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := New(EvaluatorFunc(func(context.Context, Input) (Decision, error) {
+				return test.decision, test.err
+			}))
+			result, err := policy.CanUseTool(context.Background(), "Bash", map[string]any{"command": "make"}, claudecode.ToolPermissionContext{})
+			if err != nil {
+				t.Fatalf("CanUseTool failed: %v", err)
+			}
+			if allowed := result.Behavior() == claudecode.PermissionBehaviorAllow; allowed != test.wantAllow {
+				t.Fatalf("Expected allow %v, got %v", test.wantAllow, result.Behavior())
+			}
+			if result.Message() != test.wantMessage {
+				t.Errorf("Expected message %q, got %q", test.wantMessage, result.Message())
+			}
+			if test.wantArgs != nil && result.UpdatedInput()["command"] != test.wantArgs["command"] {
+				t.Errorf("Expected args %v, got %v", test.wantArgs, result.UpdatedInput())
+			}
+		})
+	}
+}
+
+func TestCanUseToolInterrupt(t *testing.T) {
+	policy := New(EvaluatorFunc(func(context.Context, Input) (Decision, error) {
+		return Decision{Reason: "production access", Interrupt: true}, nil
+	}))
+	result, _ := policy.CanUseTool(context.Background(), "Bash", nil, claudecode.ToolPermissionContext{})
+	if !result.ShouldInterrupt() {
+		t.Error("Expected the denial to interrupt")
+	}
+}
+
+func TestInputDocument(t *testing.T) {
+	var got Input
+	policy := New(EvaluatorFunc(func(_ context.Context, input Input) (Decision, error) {
+		got = input
+		return Decision{Allow: true}, nil
+	}), WithCwd("/repo"), WithSessionMetadata(map[string]any{"team": "payments"}))
+
+	if _, err := policy.Decide(context.Background(), "Read", map[string]any{"file_path": "main.go"}); err != nil {
+		t.Fatalf("Decide failed: %v", err)
+	}
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `{"tool":"Read","args":{"file_path":"main.go"},"cwd":"/repo","session":{"team":"payments"}}`
+	if string(data) != want {
+		t.Errorf("Expected input %s, got %s", want, data)
+	}
+}
+
+func TestDecisionCache(t *testing.T) {
+	evaluations := 0
+	fail := false
+	policy := New(EvaluatorFunc(func(_ context.Context, input Input) (Decision, error) {
+		evaluations++
+		if fail {
+			return Decision{}, errors.New("unreachable")
+		}
+		return Decision{Allow: input.Tool == "Read"}, nil
+	}), WithCache(time.Minute, 2))
+	now := time.Now()
+	policy.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	decide := func(tool, path string) Decision {
+		t.Helper()
+		decision, err := policy.Decide(ctx, tool, map[string]any{"file_path": path})
+		if err != nil {
+			t.Fatalf("Decide failed: %v", err)
+		}
+		return decision
+	}
+
+	decide("Read", "a.go")
+	if !decide("Read", "a.go").Allow || evaluations != 1 {
+		t.Fatalf("Expected the repeated call to be cached, got %d evaluations", evaluations)
+	}
+	decide("Read", "b.go")
+	if evaluations != 2 {
+		t.Fatalf("Expected different args to be evaluated, got %d evaluations", evaluations)
+	}
+
+	// a.go was used more recently than b.go, so b.go is dropped
+	decide("Read", "a.go")
+	decide("Write", "a.go")
+	decide("Read", "a.go")
+	if evaluations != 3 {
+		t.Errorf("Expected a.go to stay cached, got %d evaluations", evaluations)
+	}
+	decide("Read", "b.go")
+	if evaluations != 4 {
+		t.Errorf("Expected b.go to be evicted, got %d evaluations", evaluations)
+	}
+
+	now = now.Add(2 * time.Minute)
+	fail = true
+	if _, err := policy.Decide(ctx, "Read", map[string]any{"file_path": "b.go"}); err == nil {
+		t.Error("Expected an expired decision to be evaluated again")
+	}
+	if _, err := policy.Decide(ctx, "Read", map[string]any{"file_path": "b.go"}); err == nil {
+		t.Error("Expected errors not to be cached")
+	}
+}
+
+func TestDecisionUnmarshal(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    Decision
+		wantErr bool
+	}{
+		{data: `true`, want: Decision{Allow: true}},
+		{data: `false`, want: Decision{}},
+		{data: `{"allow":false,"reason":"no","interrupt":true}`, want: Decision{Reason: "no", Interrupt: true}},
+		{data: `"yes"`, wantErr: true},
+	}
+	for _, test := range tests {
+		var got Decision
+		err := json.Unmarshal([]byte(test.data), &got)
+		if test.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "boolean or an object") {
+				t.Errorf("%s: expected a decision error, got %v", test.data, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.data, err)
+			continue
+		}
+		if got.Allow != test.want.Allow || got.Reason != test.want.Reason || got.Interrupt != test.want.Interrupt {
+			t.Errorf("%s: expected %+v, got %+v", test.data, test.want, got)
+		}
+	}
+}
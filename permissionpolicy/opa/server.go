@@ -0,0 +1,70 @@
+package opa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody bounds how much of an error response is quoted.
+const maxErrorBody = 512
+
+// Server evaluates policies with an OPA server's Data API, posting the
+// input to /v1/data/<Path> and reading the decision from the result.
+type Server struct {
+	// URL is the server's base URL, such as "http://localhost:8181".
+	URL string
+	// Path is the rule to query, such as "claude/tools/decision".
+	Path string
+	// Client sends the requests; the default is http.DefaultClient.
+	Client *http.Client
+	// Header is added to each request, for example to authenticate.
+	Header http.Header
+}
+
+// Evaluate queries the server. It returns ErrUndefined when the rule has
+// no value for the input.
+func (s *Server) Evaluate(ctx context.Context, input Input) (Decision, error) {
+	body, err := json.Marshal(map[string]any{"input": input})
+	if err != nil {
+		return Decision{}, fmt.Errorf("encoding input: %w", err)
+	}
+	url := strings.TrimSuffix(s.URL, "/") + "/v1/data/" + strings.Trim(s.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, err
+	}
+	for name, values := range s.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Decision{}, fmt.Errorf("querying OPA: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return Decision{}, fmt.Errorf("OPA returned %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	var out struct {
+		Result *Decision `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return Decision{}, fmt.Errorf("decoding OPA response: %w", err)
+	}
+	if out.Result == nil {
+		return Decision{}, ErrUndefined
+	}
+	return *out.Result, nil
+}
@@ -0,0 +1,80 @@
+package opa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerEvaluate(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotInput Input
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		var body struct {
+			Input Input `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Decoding request failed: %v", err)
+		}
+		gotInput = body.Input
+		w.Write([]byte(`{"result":{"allow":false,"reason":"writes are reviewed"}}`))
+	}))
+	defer server.Close()
+
+	evaluator := &Server{
+		URL:    server.URL + "/",
+		Path:   "/claude/tools/decision",
+		Header: http.Header{"Authorization": []string{"Bearer token"}},
+	}
+	decision, err := evaluator.Evaluate(context.Background(), Input{Tool: "Write", Args: map[string]any{"file_path": "x"}})
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if decision.Allow || decision.Reason != "writes are reviewed" {
+		t.Errorf("Expected the server's decision, got %+v", decision)
+	}
+	if gotPath != "/v1/data/claude/tools/decision" {
+		t.Errorf("Expected the data API path, got %q", gotPath)
+	}
+	if gotAuth != "Bearer token" {
+		t.Errorf("Expected the configured header, got %q", gotAuth)
+	}
+	if gotInput.Tool != "Write" || gotInput.Args["file_path"] != "x" {
+		t.Errorf("Expected the input document, got %+v", gotInput)
+	}
+}
+
+func TestServerEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "undefined", status: http.StatusOK, body: `{}`, wantErr: ErrUndefined.Error()},
+		{name: "server error", status: http.StatusInternalServerError, body: `{"code":"internal_error"}`, wantErr: "internal_error"},
+		{name: "bad response", status: http.StatusOK, body: `not json`, wantErr: "decoding OPA response"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			_, err := (&Server{URL: server.URL, Path: "claude/allow"}).Evaluate(context.Background(), Input{Tool: "Read"})
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", test.wantErr, err)
+			}
+			if test.name == "undefined" && !errors.Is(err, ErrUndefined) {
+				t.Errorf("Expected ErrUndefined, got %v", err)
+			}
+		})
+	}
+}
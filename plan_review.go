@@ -0,0 +1,132 @@
+package claudecode
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ToolNameExitPlanMode is the tool the agent uses to present its plan and
+// leave plan mode.
+const ToolNameExitPlanMode = "ExitPlanMode"
+
+// planStepPattern matches numbered ("1." or "1)") and bulleted list items,
+// with an optional task checkbox.
+var planStepPattern = regexp.MustCompile(`^\s*(?:\d+[.)]|[-*+])\s+(?:\[[ xX]\]\s+)?(.+)$`)
+
+// ParsePlan splits a plan into steps. List items become steps; a plan
+// without any list uses its non-empty, non-heading lines instead.
+func ParsePlan(raw string) Plan {
+	plan := Plan{Raw: raw}
+
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		if match := planStepPattern.FindStringSubmatch(line); match != nil {
+			plan.Steps = append(plan.Steps, strings.TrimSpace(match[1]))
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			lines = append(lines, trimmed)
+		}
+	}
+
+	if len(plan.Steps) == 0 {
+		plan.Steps = lines
+	}
+	return plan
+}
+
+// PlanFromToolUse extracts the plan from an ExitPlanMode tool use.
+// It returns false for any other tool use.
+//
+// Example:
+//
+//	for _, block := range assistantMsg.Content {
+//	    if toolUse, ok := block.(*claudecode.ToolUseBlock); ok {
+//	        if plan, ok := claudecode.PlanFromToolUse(toolUse); ok {
+//	            fmt.Println(len(plan.Steps), "steps planned")
+//	        }
+//	    }
+//	}
+func PlanFromToolUse(block *ToolUseBlock) (Plan, bool) {
+	if block == nil || block.Name != ToolNameExitPlanMode {
+		return Plan{}, false
+	}
+	raw, _ := block.Input["plan"].(string)
+	return ParsePlan(raw), true
+}
+
+// newCanUseToolHandler answers can_use_tool control requests. Requests to use
+// ExitPlanMode go to the plan reviewer; all other tools go through the
+// permission manager. Denials the handler writes itself are in locale.
+func newCanUseToolHandler(reviewer PlanReviewer, permissions PermissionManager, locale string) ControlRequestHandler {
+	return func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		toolName, _ := data["tool_name"].(string)
+		input, _ := data["input"].(map[string]any)
+
+		if toolName == ToolNameExitPlanMode && reviewer != nil {
+			raw, _ := input["plan"].(string)
+			decision, err := reviewPlan(ctx, reviewer, ParsePlan(raw))
+			if err != nil {
+				return permissionResponse(NewPermissionResultDeny(LocalizedMessage(locale, MessagePlanReviewFailed, err)), input), nil
+			}
+			if !decision.Approved {
+				feedback := decision.Feedback
+				if feedback == "" {
+					feedback = LocalizedMessage(locale, MessagePlanRejected)
+				}
+				return permissionResponse(NewPermissionResultDeny(feedback), input), nil
+			}
+			return permissionResponse(NewPermissionResultAllow(), input), nil
+		}
+
+		if permissions == nil {
+			return permissionResponse(NewPermissionResultAllow(), input), nil
+		}
+		result, err := permissions.CheckPermission(ctx, toolName, input, ToolPermissionContext{})
+		if err != nil {
+			return nil, err
+		}
+		return permissionResponse(result, input), nil
+	}
+}
+
+// reviewPlan runs the reviewer, treating a panic as a review failure.
+func reviewPlan(ctx context.Context, reviewer PlanReviewer, plan Plan) (decision PlanDecision, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("reviewer panic: %v", r)
+		}
+	}()
+	return reviewer(ctx, plan)
+}
+
+// permissionResponse converts a permission result to the can_use_tool
+// response payload the CLI expects.
+func permissionResponse(result PermissionResult, input map[string]any) map[string]any {
+	if result.Behavior() != PermissionBehaviorAllow {
+		response := map[string]any{
+			"behavior": string(PermissionBehaviorDeny),
+			"message":  result.Message(),
+		}
+		if result.ShouldInterrupt() {
+			response["interrupt"] = true
+		}
+		return response
+	}
+
+	updatedInput := result.UpdatedInput()
+	if updatedInput == nil {
+		updatedInput = input
+	}
+	response := map[string]any{
+		"behavior":     string(PermissionBehaviorAllow),
+		"updatedInput": updatedInput,
+	}
+	if updates := result.UpdatedPermissions(); len(updates) > 0 {
+		response["updatedPermissions"] = updates
+	}
+	return response
+}
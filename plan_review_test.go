@@ -0,0 +1,261 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePlan(t *testing.T) {
+	tests := []struct {
+		name  string
+		raw   string
+		steps []string
+	}{
+		{
+			name:  "numbered list",
+			raw:   "# Plan\n\n1. Add the parser\n2) Wire it into the client\n3. Write tests",
+			steps: []string{"Add the parser", "Wire it into the client", "Write tests"},
+		},
+		{
+			name:  "bullets with checkboxes",
+			raw:   "## Steps\n- [ ] Read config.go\n* [x] Update defaults\n+ Run the linter",
+			steps: []string{"Read config.go", "Update defaults", "Run the linter"},
+		},
+		{
+			name:  "prose ignored when list present",
+			raw:   "I will refactor the module.\n\n1. Extract interface\n2. Move helpers",
+			steps: []string{"Extract interface", "Move helpers"},
+		},
+		{
+			name:  "no list falls back to lines",
+			raw:   "# Plan\nRename the package.\n\n  Update imports.  ",
+			steps: []string{"Rename the package.", "Update imports."},
+		},
+		{
+			name:  "empty plan",
+			raw:   "",
+			steps: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan := ParsePlan(test.raw)
+			if plan.Raw != test.raw {
+				t.Errorf("Expected raw plan to be preserved, got %q", plan.Raw)
+			}
+			if !reflect.DeepEqual(plan.Steps, test.steps) {
+				t.Errorf("Expected steps %q, got %q", test.steps, plan.Steps)
+			}
+		})
+	}
+}
+
+func TestPlanFromToolUse(t *testing.T) {
+	plan, ok := PlanFromToolUse(&ToolUseBlock{
+		Name:  ToolNameExitPlanMode,
+		Input: map[string]any{"plan": "1. First\n2. Second"},
+	})
+	if !ok {
+		t.Fatal("Expected ExitPlanMode tool use to yield a plan")
+	}
+	if !reflect.DeepEqual(plan.Steps, []string{"First", "Second"}) {
+		t.Errorf("Unexpected steps: %q", plan.Steps)
+	}
+
+	if _, ok := PlanFromToolUse(&ToolUseBlock{Name: "Bash", Input: map[string]any{"plan": "1. x"}}); ok {
+		t.Error("Expected other tools to be ignored")
+	}
+	if _, ok := PlanFromToolUse(nil); ok {
+		t.Error("Expected nil block to be ignored")
+	}
+}
+
+func TestCanUseToolHandlerPlanReview(t *testing.T) {
+	tests := []struct {
+		name         string
+		reviewer     PlanReviewer
+		wantBehavior string
+		wantMessage  string
+	}{
+		{
+			name: "approved",
+			reviewer: func(_ context.Context, _ Plan) (PlanDecision, error) {
+				return PlanDecision{Approved: true}, nil
+			},
+			wantBehavior: "allow",
+		},
+		{
+			name: "rejected with feedback",
+			reviewer: func(_ context.Context, _ Plan) (PlanDecision, error) {
+				return PlanDecision{Feedback: "Skip step 2"}, nil
+			},
+			wantBehavior: "deny",
+			wantMessage:  "Skip step 2",
+		},
+		{
+			name: "rejected without feedback",
+			reviewer: func(_ context.Context, _ Plan) (PlanDecision, error) {
+				return PlanDecision{}, nil
+			},
+			wantBehavior: "deny",
+			wantMessage:  "Plan rejected by reviewer",
+		},
+		{
+			name: "reviewer error denies",
+			reviewer: func(_ context.Context, _ Plan) (PlanDecision, error) {
+				return PlanDecision{Approved: true}, errors.New("reviewer offline")
+			},
+			wantBehavior: "deny",
+			wantMessage:  "plan review failed: reviewer offline",
+		},
+		{
+			name: "reviewer panic denies",
+			reviewer: func(_ context.Context, _ Plan) (PlanDecision, error) {
+				panic("boom")
+			},
+			wantBehavior: "deny",
+			wantMessage:  "plan review failed: reviewer panic: boom",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := setupPlanReviewTestContext(t)
+			defer cancel()
+
+			input := map[string]any{"plan": "1. Edit main.go\n2. Run tests"}
+			handler := newCanUseToolHandler(test.reviewer, NewPermissionManager(), "")
+			response, err := handler(ctx, map[string]any{"tool_name": ToolNameExitPlanMode, "input": input})
+			if err != nil {
+				t.Fatalf("Unexpected handler error: %v", err)
+			}
+			assertPermissionResponse(t, response, test.wantBehavior, test.wantMessage)
+			if test.wantBehavior == "allow" && !reflect.DeepEqual(response["updatedInput"], input) {
+				t.Errorf("Expected original input to be passed through, got %v", response["updatedInput"])
+			}
+		})
+	}
+}
+
+func TestCanUseToolHandlerReceivesParsedPlan(t *testing.T) {
+	ctx, cancel := setupPlanReviewTestContext(t)
+	defer cancel()
+
+	var reviewed Plan
+	handler := newCanUseToolHandler(func(_ context.Context, plan Plan) (PlanDecision, error) {
+		reviewed = plan
+		return PlanDecision{Approved: true}, nil
+	}, nil, "")
+
+	raw := "# Plan\n1. Edit main.go\n2. Run tests"
+	_, err := handler(ctx, map[string]any{
+		"tool_name": ToolNameExitPlanMode,
+		"input":     map[string]any{"plan": raw},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected handler error: %v", err)
+	}
+	if reviewed.Raw != raw || !reflect.DeepEqual(reviewed.Steps, []string{"Edit main.go", "Run tests"}) {
+		t.Errorf("Reviewer got unexpected plan: %+v", reviewed)
+	}
+}
+
+func TestCanUseToolHandlerOtherTools(t *testing.T) {
+	ctx, cancel := setupPlanReviewTestContext(t)
+	defer cancel()
+
+	reviewerCalled := false
+	reviewer := func(_ context.Context, _ Plan) (PlanDecision, error) {
+		reviewerCalled = true
+		return PlanDecision{Approved: true}, nil
+	}
+
+	permissions := NewPermissionManager()
+	permissions.SetPermissionCallback(func(_ context.Context, toolName string, _ map[string]any, _ ToolPermissionContext) (PermissionResult, error) {
+		if toolName == "Bash" {
+			return NewPermissionResultDeny("no shell").WithInterrupt(), nil
+		}
+		return NewPermissionResultAllow(), nil
+	})
+
+	handler := newCanUseToolHandler(reviewer, permissions, "")
+	response, err := handler(ctx, map[string]any{"tool_name": "Bash", "input": map[string]any{"command": "ls"}})
+	if err != nil {
+		t.Fatalf("Unexpected handler error: %v", err)
+	}
+	assertPermissionResponse(t, response, "deny", "no shell")
+	if response["interrupt"] != true {
+		t.Errorf("Expected interrupt flag, got %v", response["interrupt"])
+	}
+	if reviewerCalled {
+		t.Error("Plan reviewer must only see ExitPlanMode requests")
+	}
+}
+
+func TestClientPlanReviewerHandlesControlRequests(t *testing.T) {
+	ctx, cancel := setupPlanReviewTestContext(t)
+	defer cancel()
+
+	reviewer := func(_ context.Context, plan Plan) (PlanDecision, error) {
+		return PlanDecision{Approved: len(plan.Steps) <= 2, Feedback: "Too many steps"}, nil
+	}
+	client := NewClientWithTransport(newClientControlMockTransport(),
+		WithPermissionMode(PermissionModePlan), WithPlanReviewer(reviewer))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	protocol, ok := client.(*ClientImpl).GetControlProtocol().(*controlProtocol)
+	if !ok {
+		t.Fatal("Expected built-in control protocol")
+	}
+
+	response, err := protocol.HandleControlRequest(ctx, &ControlRequest{
+		ID:      "cli-1",
+		Subtype: ControlRequestTypeCanUseTool,
+		Data: map[string]any{
+			"tool_name": ToolNameExitPlanMode,
+			"input":     map[string]any{"plan": "1. a\n2. b\n3. c"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("HandleControlRequest failed: %v", err)
+	}
+	if response.ID != "cli-1" || response.Subtype != ControlResponseTypeSuccess {
+		t.Errorf("Unexpected response envelope: %+v", response)
+	}
+	assertPermissionResponse(t, response.Data, "deny", "Too many steps")
+}
+
+func TestClientWithoutPlanReviewerHasNoCanUseToolHandler(t *testing.T) {
+	ctx, cancel := setupPlanReviewTestContext(t)
+	defer cancel()
+
+	client := NewClientWithTransport(newClientControlMockTransport(), WithPermissionMode(PermissionModePlan))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	protocol := client.(*ClientImpl).GetControlProtocol().(*controlProtocol)
+	_, err := protocol.HandleControlRequest(ctx, &ControlRequest{Subtype: ControlRequestTypeCanUseTool})
+	if err == nil {
+		t.Error("Expected no can_use_tool handler without a plan reviewer")
+	}
+}
+
+func setupPlanReviewTestContext(t *testing.T) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
+
+func assertPermissionResponse(t *testing.T, response map[string]any, behavior, message string) {
+	t.Helper()
+	if response["behavior"] != behavior {
+		t.Errorf("Expected behavior %q, got %v", behavior, response["behavior"])
+	}
+	if message != "" && response["message"] != message {
+		t.Errorf("Expected message %q, got %v", message, response["message"])
+	}
+}
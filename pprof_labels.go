@@ -0,0 +1,70 @@
+package claudecode
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// pprof labels on the SDK's goroutines, so goroutine profiles attribute
+// work to sessions. For example, to see the hooks of one session:
+//
+//	go tool pprof -tagfocus session_id=4f1c... -tagfocus role=hook http://localhost:6060/debug/pprof/goroutine
+const (
+	// LabelSessionID holds the CLI's session ID, from its init message or
+	// the session resumed with WithResume. It is missing until known.
+	LabelSessionID = "session_id"
+	// LabelRole holds what the goroutine does: one of the GoroutineRole
+	// values.
+	LabelRole = "role"
+)
+
+// Roles of the SDK's goroutines, in their LabelRole label.
+const (
+	// GoroutineRoleReader reads the CLI's messages, in the transport and
+	// in the client.
+	GoroutineRoleReader = "reader"
+	// GoroutineRoleWriter sends queued queries and QueryStream messages.
+	GoroutineRoleWriter = "writer"
+	// GoroutineRoleHook runs a hook callback or answers a control request
+	// from the CLI, such as a permission check. Goroutines the callback
+	// starts inherit the label.
+	GoroutineRoleHook = "hook"
+	// GoroutineRoleMonitor enforces tool timeouts and query deadlines and
+	// reports tool progress.
+	GoroutineRoleMonitor = "monitor"
+)
+
+// goroutineLabels returns the labels of a goroutine with role in the
+// session, if known.
+func goroutineLabels(sessionID, role string) pprof.LabelSet {
+	if sessionID == "" {
+		return pprof.Labels(LabelRole, role)
+	}
+	return pprof.Labels(LabelSessionID, sessionID, LabelRole, role)
+}
+
+// labelGoroutine replaces the labels of the calling goroutine.
+func labelGoroutine(sessionID, role string) {
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), goroutineLabels(sessionID, role)))
+}
+
+// resumedSessionID returns the session resumed with WithResume, the only
+// session ID known before the CLI's init message.
+func resumedSessionID(options *Options) string {
+	if options == nil || options.Resume == nil {
+		return ""
+	}
+	return *options.Resume
+}
+
+// connectionSessionID returns the session ID of a connection for its
+// goroutine labels: the init message's, or the resumed session's until the
+// init message arrives.
+func connectionSessionID(initInfo *initTracker, resumed string) func() string {
+	return func() string {
+		if info := initInfo.info(); info != nil && info.SessionID != "" {
+			return info.SessionID
+		}
+		return resumed
+	}
+}
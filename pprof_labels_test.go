@@ -0,0 +1,81 @@
+package claudecode
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHookGoroutineLabels(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hs := newHookSystem(SystemClock{})
+	hs.session = func() HookContext { return HookContext{SessionID: "session-1"} }
+	var role, session string
+	if err := hs.AddHook(string(HookEventTypeStop), func(ctx context.Context, _ interface{}, _ HookContext) (HookOutput, error) {
+		role, _ = pprof.Label(ctx, LabelRole)
+		session, _ = pprof.Label(ctx, LabelSessionID)
+		return HookOutput{Behavior: HookBehaviorContinue}, nil
+	}); err != nil {
+		t.Fatalf("AddHook failed: %v", err)
+	}
+
+	if _, err := hs.ExecuteHooks(ctx, HookEventTypeStop, StopHookInput{}); err != nil {
+		t.Fatalf("ExecuteHooks failed: %v", err)
+	}
+	if role != GoroutineRoleHook || session != "session-1" {
+		t.Errorf("Expected the hook labeled with its role and session, got %q and %q", role, session)
+	}
+}
+
+func TestClientGoroutineLabels(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	init := initMessage("Bash")
+	init.Data["session_id"] = "session-labels"
+	client := NewClientWithTransport(newClientMockTransportWithOptions(WithClientResponseMessages([]Message{init})))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	// The reader picks up the session ID from the init message
+	waitForGoroutineLabels(ctx, t, `"role":"reader", "session_id":"session-labels"`)
+	waitForGoroutineLabels(ctx, t, `"role":"writer"`)
+}
+
+func TestGoroutineLabels(t *testing.T) {
+	labels := pprof.WithLabels(context.Background(), goroutineLabels("", GoroutineRoleMonitor))
+	if _, ok := pprof.Label(labels, LabelSessionID); ok {
+		t.Error("Expected no session label before the session is known")
+	}
+
+	resumed := "session-resumed"
+	sessionID := connectionSessionID(newInitTracker(), resumedSessionID(NewOptions(WithResume(resumed))))
+	if got := sessionID(); got != resumed {
+		t.Errorf("Expected the resumed session before the init message, got %q", got)
+	}
+}
+
+// waitForGoroutineLabels waits for a goroutine labeled with labels, as
+// formatted in goroutine profiles.
+func waitForGoroutineLabels(ctx context.Context, t *testing.T, labels string) {
+	t.Helper()
+	for {
+		var profile bytes.Buffer
+		if err := pprof.Lookup("goroutine").WriteTo(&profile, 1); err != nil {
+			t.Fatalf("Failed to write the goroutine profile: %v", err)
+		}
+		if strings.Contains(profile.String(), "labels: {"+labels+"}") {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("No goroutine labeled %s in:\n%s", labels, profile.String())
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Package promexporter exports Prometheus metrics for agent clients, so
+// services running agents get dashboards without instrumenting them.
+//
+// An Exporter counts queries, tokens, cost, tool calls and permission
+// denials from the messages of every client it is attached to, and the
+// sessions they end, by reason. It serves the metrics in the Prometheus
+// text format, so it needs no Prometheus client library:
+//
+//	metrics := promexporter.New(promexporter.WithConstLabels(map[string]string{"service": "reviewer"}))
+//	http.Handle("/metrics", metrics)
+//
+//	client := claudecode.NewClient(promexporter.WithMetrics(metrics))
+//	hooks := client.(*claudecode.ClientImpl).GetHookSystem()
+//	hooks.AddHook("Stop", metrics.InstrumentHook(claudecode.HookEventTypeStop, onStop))
+//
+// Sessions ending with reason "process_exit" count CLI processes that died
+// before the client was disconnected and had to be restarted.
+//
+// WithTagLabels turns session tags into labels, to attribute cost and
+// usage to tenants sharing an exporter:
+//
+//	metrics := promexporter.New(promexporter.WithTagLabels("team"))
+//	client := claudecode.NewClient(
+//		claudecode.WithSessionTags(map[string]string{"team": "payments"}),
+//		promexporter.WithMetrics(metrics),
+//	)
+package promexporter
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// DefaultNamespace prefixes metric names.
+const DefaultNamespace = "claude"
+
+// contentType is the Prometheus text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	// DefaultQueryBuckets are the query duration buckets, in seconds.
+	DefaultQueryBuckets = []float64{1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+	// DefaultHookBuckets are the hook latency buckets, in seconds.
+	DefaultHookBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+)
+
+// Option configures an Exporter.
+type Option func(*config)
+
+type config struct {
+	namespace    string
+	constLabels  map[string]string
+	tagLabels    []string
+	queryBuckets []float64
+	hookBuckets  []float64
+}
+
+// WithNamespace replaces DefaultNamespace as the prefix of metric names.
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithConstLabels adds labels to every sample, such as the service name.
+func WithConstLabels(labels map[string]string) Option {
+	return func(c *config) {
+		c.constLabels = labels
+	}
+}
+
+// WithTagLabels adds the session tags with the given keys as labels to the
+// query, token, cost, tool call, permission denial and session metrics of
+// clients attached with WithMetrics. Clients without a tag get an empty
+// label value. Keys must be valid Prometheus label names. Other tags are
+// ignored, which keeps the number of series bounded.
+func WithTagLabels(keys ...string) Option {
+	return func(c *config) {
+		c.tagLabels = keys
+	}
+}
+
+// WithQueryBuckets sets the query duration buckets, in seconds.
+func WithQueryBuckets(buckets ...float64) Option {
+	return func(c *config) {
+		c.queryBuckets = buckets
+	}
+}
+
+// WithHookBuckets sets the hook latency buckets, in seconds.
+func WithHookBuckets(buckets ...float64) Option {
+	return func(c *config) {
+		c.hookBuckets = buckets
+	}
+}
+
+// Exporter collects agent metrics and serves them over HTTP. It is safe
+// for concurrent use and can be shared by many clients.
+type Exporter struct {
+	constLabels string
+	tagLabels   []string
+
+	queries           *counterVec
+	queryDuration     *histogramVec
+	tokens            *counterVec
+	cost              *counterVec
+	toolCalls         *counterVec
+	permissionDenials *counterVec
+	hookDuration      *histogramVec
+	sessions          *counterVec
+
+	metrics []metric
+}
+
+// New returns an Exporter with no samples.
+func New(opts ...Option) *Exporter {
+	c := &config{
+		namespace:    DefaultNamespace,
+		queryBuckets: DefaultQueryBuckets,
+		hookBuckets:  DefaultHookBuckets,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	name := func(s string) string {
+		if c.namespace == "" {
+			return s
+		}
+		return c.namespace + "_" + s
+	}
+
+	e := &Exporter{
+		constLabels:       formatConstLabels(c.constLabels),
+		tagLabels:         append([]string(nil), c.tagLabels...),
+		queries:           newCounterVec(name("queries_total"), "Queries completed, by status.", "status"),
+		queryDuration:     newHistogramVec(name("query_duration_seconds"), "Query duration reported by the CLI.", "", c.queryBuckets),
+		tokens:            newCounterVec(name("tokens_total"), "Tokens used, by type.", "type"),
+		cost:              newCounterVec(name("cost_usd_total"), "Cost of queries in US dollars.", ""),
+		toolCalls:         newCounterVec(name("tool_calls_total"), "Tool calls made by the agent, by tool.", "tool"),
+		permissionDenials: newCounterVec(name("permission_denials_total"), "Tool calls denied permission, by tool.", "tool"),
+		hookDuration:      newHistogramVec(name("hook_duration_seconds"), "Latency of instrumented hooks, by event.", "event", c.hookBuckets),
+		sessions:          newCounterVec(name("sessions_total"), "Client sessions ended, by reason.", "reason"),
+	}
+	e.metrics = []metric{e.queries, e.queryDuration, e.tokens, e.cost, e.toolCalls, e.permissionDenials, e.hookDuration, e.sessions}
+	return e
+}
+
+// WithMetrics attaches e to a client. It chains the finalizer set so far,
+// so it must come after WithFinalizer.
+func WithMetrics(e *Exporter) claudecode.Option {
+	return func(o *claudecode.Options) {
+		o.MessageObservers = append(o.MessageObservers, func(msg claudecode.Message) {
+			e.ObserveTagged(msg, o.SessionTags)
+		})
+		next := o.Finalizer
+		o.Finalizer = func(summary claudecode.SessionSummary) {
+			e.sessions.add(e.formatTags(summary.Tags), string(summary.Reason), 1)
+			if next != nil {
+				next(summary)
+			}
+		}
+	}
+}
+
+// Observe records the metrics carried by a message. WithMetrics calls it
+// for each message a client receives; call it directly for messages read
+// from one-shot queries.
+func (e *Exporter) Observe(msg claudecode.Message) {
+	e.ObserveTagged(msg, nil)
+}
+
+// ObserveTagged is Observe for a message from a session with tags.
+func (e *Exporter) ObserveTagged(msg claudecode.Message, tags map[string]string) {
+	labels := e.formatTags(tags)
+	switch m := msg.(type) {
+	case *claudecode.AssistantMessage:
+		for _, block := range m.Content {
+			if use, ok := block.(*claudecode.ToolUseBlock); ok {
+				e.toolCalls.add(labels, use.Name, 1)
+			}
+		}
+	case *claudecode.ResultMessage:
+		status := "success"
+		if m.IsError {
+			status = "error"
+		}
+		e.queries.add(labels, status, 1)
+		e.queryDuration.observe(labels, "", float64(m.DurationMs)/1000)
+		if m.TotalCostUSD != nil {
+			e.cost.add(labels, "", *m.TotalCostUSD)
+		}
+		if u := m.Usage; u != nil {
+			e.tokens.add(labels, "input", float64(u.InputTokens))
+			e.tokens.add(labels, "output", float64(u.OutputTokens))
+			e.tokens.add(labels, "cache_creation", float64(u.CacheCreation))
+			e.tokens.add(labels, "cache_read", float64(u.CacheRead))
+		}
+		for _, denial := range m.PermissionDenials {
+			e.permissionDenials.add(labels, denial.ToolName, 1)
+		}
+	}
+}
+
+// formatTags renders the tag labels for a session with tags.
+func (e *Exporter) formatTags(tags map[string]string) string {
+	labels := ""
+	for _, key := range e.tagLabels {
+		labels = joinLabels(labels, key, tags[key])
+	}
+	return labels
+}
+
+// InstrumentHook wraps a hook callback to record its latency under event.
+func (e *Exporter) InstrumentHook(event claudecode.HookEventType, hook claudecode.HookCallback) claudecode.HookCallback {
+	return func(ctx context.Context, input interface{}, hookCtx claudecode.HookContext) (claudecode.HookOutput, error) {
+		start := time.Now()
+		defer func() {
+			e.hookDuration.observe("", string(event), time.Since(start).Seconds())
+		}()
+		return hook(ctx, input, hookCtx)
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	for _, m := range e.metrics {
+		if err := m.write(cw, e.constLabels); err != nil {
+			return cw.n, err
+		}
+	}
+	return cw.n, nil
+}
+
+// ServeHTTP serves the metrics for scraping.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	bw := bufio.NewWriter(w)
+	if _, err := e.WriteTo(bw); err != nil {
+		return
+	}
+	_ = bw.Flush()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
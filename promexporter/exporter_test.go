@@ -0,0 +1,148 @@
+package promexporter
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestObserve(t *testing.T) {
+	e := New()
+	cost := 0.25
+	e.Observe(&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+		&claudecode.TextBlock{Text: "Reading"},
+		&claudecode.ToolUseBlock{Name: "Read"},
+		&claudecode.ToolUseBlock{Name: "Read"},
+		&claudecode.ToolUseBlock{Name: "Bash"},
+	}})
+	e.Observe(&claudecode.ResultMessage{
+		Subtype:      "success",
+		DurationMs:   4000,
+		TotalCostUSD: &cost,
+		Usage:        &claudecode.Usage{InputTokens: 100, OutputTokens: 20, CacheRead: 50},
+		PermissionDenials: []claudecode.PermissionDenial{
+			{ToolName: "Write"},
+		},
+	})
+	e.Observe(&claudecode.ResultMessage{Subtype: "error_max_turns", IsError: true, DurationMs: 45000})
+
+	got := scrape(t, e)
+	for _, want := range []string{
+		`claude_queries_total{status="error"} 1`,
+		`claude_queries_total{status="success"} 1`,
+		`claude_query_duration_seconds_bucket{le="5"} 1`,
+		`claude_query_duration_seconds_bucket{le="60"} 2`,
+		`claude_query_duration_seconds_bucket{le="+Inf"} 2`,
+		"claude_query_duration_seconds_sum 49\n",
+		"claude_query_duration_seconds_count 2\n",
+		`claude_tokens_total{type="input"} 100`,
+		`claude_tokens_total{type="output"} 20`,
+		`claude_tokens_total{type="cache_read"} 50`,
+		"claude_cost_usd_total 0.25\n",
+		`claude_tool_calls_total{tool="Bash"} 1`,
+		`claude_tool_calls_total{tool="Read"} 2`,
+		`claude_permission_denials_total{tool="Write"} 1`,
+		"# TYPE claude_hook_duration_seconds histogram\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	e := New(WithNamespace("agent"), WithConstLabels(map[string]string{"service": "review"}))
+	var finalized []claudecode.SessionSummary
+	options := claudecode.NewOptions(
+		claudecode.WithFinalizer(func(s claudecode.SessionSummary) { finalized = append(finalized, s) }),
+		WithMetrics(e),
+	)
+
+	if len(options.MessageObservers) != 1 {
+		t.Fatalf("Expected a message observer, got %d", len(options.MessageObservers))
+	}
+	options.MessageObservers[0](&claudecode.ResultMessage{Subtype: "success"})
+	options.Finalizer(claudecode.SessionSummary{Reason: claudecode.SessionEndProcessExit})
+	options.Finalizer(claudecode.SessionSummary{Reason: claudecode.SessionEndDisconnect})
+
+	if len(finalized) != 2 {
+		t.Errorf("Expected the earlier finalizer to still run, got %d calls", len(finalized))
+	}
+	got := scrape(t, e)
+	for _, want := range []string{
+		`agent_queries_total{service="review",status="success"} 1`,
+		`agent_sessions_total{service="review",reason="disconnect"} 1`,
+		`agent_sessions_total{service="review",reason="process_exit"} 1`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestWithTagLabels(t *testing.T) {
+	e := New(WithTagLabels("team", "env"))
+	cost := 0.5
+	tenant := claudecode.NewOptions(
+		WithMetrics(e),
+		claudecode.WithSessionTags(map[string]string{"team": "payments", "ticket": "OPS-1"}),
+	)
+	untagged := claudecode.NewOptions(WithMetrics(e))
+
+	tenant.MessageObservers[0](&claudecode.ResultMessage{Subtype: "success", TotalCostUSD: &cost})
+	tenant.MessageObservers[0](&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{&claudecode.ToolUseBlock{Name: "Read"}}})
+	untagged.MessageObservers[0](&claudecode.ResultMessage{Subtype: "success"})
+	tenant.Finalizer(claudecode.SessionSummary{Reason: claudecode.SessionEndDisconnect, Tags: tenant.SessionTags})
+
+	got := scrape(t, e)
+	for _, want := range []string{
+		`claude_queries_total{team="",env="",status="success"} 1`,
+		`claude_queries_total{team="payments",env="",status="success"} 1`,
+		`claude_cost_usd_total{team="payments",env=""} 0.5`,
+		`claude_query_duration_seconds_count{team="payments",env=""} 1`,
+		`claude_tool_calls_total{team="payments",env="",tool="Read"} 1`,
+		`claude_sessions_total{team="payments",env="",reason="disconnect"} 1`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "OPS-1") {
+		t.Errorf("Expected tags without a label to be left out, got:\n%s", got)
+	}
+}
+
+func TestInstrumentHook(t *testing.T) {
+	e := New(WithHookBuckets(1))
+	hook := e.InstrumentHook(claudecode.HookEventTypeStop, func(context.Context, interface{}, claudecode.HookContext) (claudecode.HookOutput, error) {
+		return claudecode.HookOutput{Behavior: claudecode.HookBehaviorStop}, nil
+	})
+
+	out, err := hook(context.Background(), nil, claudecode.HookContext{})
+	if err != nil || out.Behavior != claudecode.HookBehaviorStop {
+		t.Fatalf("Expected the hook's output, got %+v, %v", out, err)
+	}
+	got := scrape(t, e)
+	for _, want := range []string{
+		`claude_hook_duration_seconds_bucket{event="Stop",le="1"} 1`,
+		`claude_hook_duration_seconds_count{event="Stop"} 1`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+// scrape fetches the metrics through the exporter's handler.
+func scrape(t *testing.T, e *Exporter) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != contentType {
+		t.Errorf("Expected content type %q, got %q", contentType, ct)
+	}
+	return rec.Body.String()
+}
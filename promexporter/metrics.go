@@ -0,0 +1,199 @@
+package promexporter
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// metric is a family of samples written in the text exposition format.
+type metric interface {
+	write(w io.Writer, constLabels string) error
+}
+
+// counterVec is a counter partitioned by the values of one label, or a
+// plain counter when label is empty, and by tag labels. Series are keyed by
+// their rendered labels.
+type counterVec struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name, help, label string) *counterVec {
+	return &counterVec{name: name, help: help, label: label, values: make(map[string]float64)}
+}
+
+// add adds v to the series for labelValue under tags, the rendered tag
+// labels.
+func (c *counterVec) add(tags, labelValue string, v float64) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[joinLabels(tags, c.label, labelValue)] += v
+}
+
+func (c *counterVec) write(w io.Writer, constLabels string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, escapeHelp(c.help), c.name); err != nil {
+		return err
+	}
+	for _, series := range sortedKeys(c.values) {
+		labels := braces(concatLabels(constLabels, series))
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", c.name, labels, formatFloat(c.values[series])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// histogramVec is a histogram partitioned by the values of one label and
+// by tag labels. Series are keyed by their rendered labels.
+type histogramVec struct {
+	name, help, label string
+	buckets           []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newHistogramVec(name, help, label string, buckets []float64) *histogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &histogramVec{name: name, help: help, label: label, buckets: buckets, series: make(map[string]*histogram)}
+}
+
+// observe records v in the series for labelValue under tags, the rendered
+// tag labels.
+func (h *histogramVec) observe(tags, labelValue string, v float64) {
+	key := joinLabels(tags, h.label, labelValue)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *histogramVec) write(w io.Writer, constLabels string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, escapeHelp(h.help), h.name); err != nil {
+		return err
+	}
+	for _, series := range sortedKeys(h.series) {
+		s := h.series[series]
+		inner := concatLabels(constLabels, series)
+		labels := braces(inner)
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			le := braces(joinLabels(inner, "le", formatFloat(bound)))
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, le, cumulative); err != nil {
+				return err
+			}
+		}
+		le := braces(joinLabels(inner, "le", "+Inf"))
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			h.name, le, s.count, h.name, labels, formatFloat(s.sum), h.name, labels, s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinLabels adds name="value" to a comma-separated list of labels. An
+// empty name adds nothing.
+func joinLabels(labels, name, value string) string {
+	if name == "" {
+		return labels
+	}
+	pair := name + `="` + escapeLabelValue(value) + `"`
+	if labels == "" {
+		return pair
+	}
+	return labels + "," + pair
+}
+
+// concatLabels joins two comma-separated lists of labels.
+func concatLabels(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return a + "," + b
+}
+
+// braces wraps a list of labels for a sample line.
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// formatConstLabels renders constant labels, sorted by name, without
+// braces.
+func formatConstLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, name := range sortedKeys(labels) {
+		pairs = append(pairs, name+`="`+escapeLabelValue(labels[name])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
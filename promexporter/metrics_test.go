@@ -0,0 +1,47 @@
+package promexporter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCounterVecWrite(t *testing.T) {
+	c := newCounterVec("tool_calls_total", "Tool calls.\nBy tool.", "tool")
+	c.add("", `Bash "quoted"`, 1)
+	c.add("", "Read", 2)
+	c.add("", "Read", -1) // counters only go up
+
+	var b strings.Builder
+	if err := c.write(&b, `service="a\\b"`); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	want := "# HELP tool_calls_total Tool calls.\\nBy tool.\n" +
+		"# TYPE tool_calls_total counter\n" +
+		`tool_calls_total{service="a\\b",tool="Bash \"quoted\""} 1` + "\n" +
+		`tool_calls_total{service="a\\b",tool="Read"} 2` + "\n"
+	if b.String() != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, b.String())
+	}
+}
+
+func TestHistogramVecWrite(t *testing.T) {
+	h := newHistogramVec("latency_seconds", "Latency.", "", []float64{1, 0.5})
+	h.observe("", "", 0.5)
+	h.observe("", "", 0.75)
+	h.observe("", "", 3)
+
+	var b strings.Builder
+	if err := h.write(&b, ""); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	want := "# HELP latency_seconds Latency.\n" +
+		"# TYPE latency_seconds histogram\n" +
+		`latency_seconds_bucket{le="0.5"} 1` + "\n" +
+		`latency_seconds_bucket{le="1"} 2` + "\n" +
+		`latency_seconds_bucket{le="+Inf"} 3` + "\n" +
+		"latency_seconds_sum 4.25\n" +
+		"latency_seconds_count 3\n"
+	if b.String() != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, b.String())
+	}
+}
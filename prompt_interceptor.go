@@ -0,0 +1,38 @@
+package claudecode
+
+import (
+	"context"
+	"fmt"
+)
+
+// interceptPrompt runs the prompt interceptors configured in options over
+// msg, stopping at the first rejection.
+func interceptPrompt(ctx context.Context, options *Options, msg *UserMessage) error {
+	if options == nil {
+		return nil
+	}
+	for _, interceptor := range options.PromptInterceptors {
+		if err := interceptor(ctx, msg); err != nil {
+			return fmt.Errorf("prompt rejected: %w", err)
+		}
+	}
+	return nil
+}
+
+// interceptQueryPrompt runs the prompt interceptors over a one-shot query
+// prompt. The prompt is passed to the CLI as an argument, so it must remain
+// text.
+func interceptQueryPrompt(ctx context.Context, options *Options, prompt string) (string, error) {
+	if len(options.PromptInterceptors) == 0 {
+		return prompt, nil
+	}
+	msg := &UserMessage{Content: prompt}
+	if err := interceptPrompt(ctx, options, msg); err != nil {
+		return "", err
+	}
+	text, ok := msg.Content.(string)
+	if !ok {
+		return "", fmt.Errorf("prompt interceptor changed a one-shot query prompt to %T; it must remain a string", msg.Content)
+	}
+	return text, nil
+}
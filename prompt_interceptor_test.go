@@ -0,0 +1,135 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientPromptInterceptorChain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var seen []string
+	scrub := func(_ context.Context, msg *UserMessage) error {
+		text, _ := msg.Content.(string)
+		seen = append(seen, "scrub:"+text)
+		msg.Content = strings.ReplaceAll(text, "hunter2", "[REDACTED]")
+		return nil
+	}
+	policy := func(_ context.Context, msg *UserMessage) error {
+		text, _ := msg.Content.(string)
+		seen = append(seen, "policy:"+text)
+		msg.Content = text + "\nFollow the company style guide."
+		return nil
+	}
+
+	transport := newClientMockTransportWithOptions(WithClientAutoResult())
+	client := NewClientWithTransport(transport, WithPromptInterceptor(scrub), WithPromptInterceptor(policy))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	assertNoError(t, client.Query(ctx, "my password is hunter2"))
+	assertSentPrompt(t, transport, 0, "my password is [REDACTED]\nFollow the company style guide.")
+	if len(seen) != 2 || seen[1] != "policy:my password is [REDACTED]" {
+		t.Errorf("Expected interceptors to run in order on rewritten prompts, got %q", seen)
+	}
+}
+
+func TestClientPromptInterceptorRejects(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errTooLong := errors.New("prompt too long")
+	limit := func(_ context.Context, msg *UserMessage) error {
+		if text, ok := msg.Content.(string); ok && len(text) > 10 {
+			return errTooLong
+		}
+		return nil
+	}
+
+	transport := newClientMockTransportWithOptions(WithClientAutoResult())
+	client := NewClientWithTransport(transport, WithPromptInterceptor(limit))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	if err := client.Query(ctx, "a prompt that is far too long"); !errors.Is(err, errTooLong) {
+		t.Fatalf("Expected the interceptor's error, got %v", err)
+	}
+	err := client.SendUserMessage(ctx, UserMessage{Content: "also far too long"})
+	if !errors.Is(err, errTooLong) {
+		t.Errorf("Expected SendUserMessage to be intercepted, got %v", err)
+	}
+	assertClientMessageCount(t, transport, 0)
+
+	// The rejected query did not hold the turn
+	assertNoError(t, client.Query(ctx, "short"))
+	assertClientMessageCount(t, transport, 1)
+}
+
+func TestClientPromptInterceptorSeesContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var blocks int
+	count := func(_ context.Context, msg *UserMessage) error {
+		content, _ := msg.Content.([]ContentBlock)
+		blocks = len(content)
+		return nil
+	}
+
+	transport := newClientMockTransportWithOptions(WithClientAutoResult())
+	client := NewClientWithTransport(transport, WithPromptInterceptor(count))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	if err := client.AddContextText("log", "line 1"); err != nil {
+		t.Fatalf("AddContextText failed: %v", err)
+	}
+	assertNoError(t, client.Query(ctx, "Explain the log"))
+	if blocks != 2 {
+		t.Errorf("Expected interceptor to see context and prompt blocks, got %d", blocks)
+	}
+}
+
+func TestQueryPromptInterceptor(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	upper := func(_ context.Context, msg *UserMessage) error {
+		msg.Content = strings.ToUpper(msg.Content.(string))
+		return nil
+	}
+	transport := newQueryMockTransport(WithQueryResultMessage(false, 1000, 1))
+	iter, err := QueryWithTransport(ctx, "hello", transport, WithPromptInterceptor(upper))
+	if err != nil {
+		t.Fatalf("QueryWithTransport failed: %v", err)
+	}
+	defer func() { _ = iter.Close() }()
+	collectQueryMessages(ctx, t, iter)
+
+	transport.mu.RLock()
+	defer transport.mu.RUnlock()
+	if len(transport.receivedMessages) != 1 {
+		t.Fatalf("Expected 1 sent message, got %d", len(transport.receivedMessages))
+	}
+	if sent := transport.receivedMessages[0].Message.(*UserMessage); sent.Content != "HELLO" {
+		t.Errorf("Expected rewritten prompt, got %v", sent.Content)
+	}
+}
+
+func TestQueryPromptInterceptorMustKeepText(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	toBlocks := func(_ context.Context, msg *UserMessage) error {
+		msg.Content = []ContentBlock{&TextBlock{Text: "hi"}}
+		return nil
+	}
+	_, err := QueryWithTransport(ctx, "hello", newQueryMockTransport(), WithPromptInterceptor(toBlocks))
+	if err == nil || !strings.Contains(err.Error(), "must remain a string") {
+		t.Errorf("Expected one-shot prompts to stay text, got %v", err)
+	}
+}
@@ -1,9 +1,14 @@
 package claudecode
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"runtime/pprof"
 	"sync"
 
 	"github.com/severity1/claude-code-sdk-go/internal/cli"
@@ -17,6 +22,18 @@ var ErrNoMoreMessages = errors.New("no more messages")
 // This follows the Python SDK pattern but uses dependency injection for transport.
 func Query(ctx context.Context, prompt string, opts ...Option) (MessageIterator, error) {
 	options := NewOptions(opts...)
+	if err := validateQueryOptions(options); err != nil {
+		return nil, err
+	}
+	plan, hasDeadline := planDeadline(ctx, options.QueryDeadline, queryClock(options).Now())
+	if hasDeadline {
+		prompt = announceDeadline(prompt, options.QueryDeadline, plan, options.Locale)
+	}
+	prompt, err := interceptQueryPrompt(ctx, options, prompt)
+	if err != nil {
+		return nil, err
+	}
+	options.Model = routeModel(ctx, options, prompt, options.Model, options.RouteFlags, 0)
 
 	// For one-shot queries, create a transport that passes prompt as CLI argument
 	// This matches the Python SDK behavior where prompt is passed via --print flag
@@ -24,8 +41,15 @@ func Query(ctx context.Context, prompt string, opts ...Option) (MessageIterator,
 	if err != nil {
 		return nil, fmt.Errorf("failed to create query transport: %w", err)
 	}
+	if stored, err := claimQuery(ctx, options); stored != nil || err != nil {
+		return stored, err
+	}
 
-	return queryWithTransportAndOptions(ctx, prompt, transport, options)
+	iter := newQueryIterator(ctx, prompt, transport, options)
+	if hasDeadline {
+		iter.deadline = &plan
+	}
+	return iter, nil
 }
 
 // QueryWithTransport executes a query with a custom transport.
@@ -41,27 +65,111 @@ func QueryWithTransport(
 	}
 
 	options := NewOptions(opts...)
-	return queryWithTransportAndOptions(ctx, prompt, transport, options)
+	if err := validateQueryOptions(options); err != nil {
+		return nil, err
+	}
+	plan, hasDeadline := planDeadline(ctx, options.QueryDeadline, queryClock(options).Now())
+	if hasDeadline {
+		prompt = announceDeadline(prompt, options.QueryDeadline, plan, options.Locale)
+	}
+	prompt, err := interceptQueryPrompt(ctx, options, prompt)
+	if err != nil {
+		return nil, err
+	}
+	options.Model = routeModel(ctx, options, prompt, options.Model, options.RouteFlags, 0)
+	if stored, err := claimQuery(ctx, options); stored != nil || err != nil {
+		return stored, err
+	}
+	iter := newQueryIterator(ctx, prompt, transport, options)
+	if hasDeadline {
+		iter.deadline = &plan
+	}
+	return iter, nil
 }
 
-// Internal helper functions
-func queryWithTransportAndOptions(
-	ctx context.Context,
-	prompt string,
-	transport Transport,
-	options *Options,
-) (MessageIterator, error) {
-	if transport == nil {
-		return nil, fmt.Errorf("transport is required")
+// validateQueryOptions rejects option values the CLI would not accept.
+func validateQueryOptions(options *Options) error {
+	if options.PermissionMode != nil && !options.PermissionMode.IsValid() {
+		return fmt.Errorf("invalid permission mode: %s", string(*options.PermissionMode))
+	}
+	if options.DryRun {
+		// Denying tools needs the control protocol, which one-shot queries lack
+		return fmt.Errorf("dry run requires a Client")
+	}
+	if options.EphemeralWorkspace != nil {
+		// The workspace is removed on Disconnect, which one-shot queries lack
+		return fmt.Errorf("ephemeral workspace requires a Client")
+	}
+	if options.IdempotencyKey != "" && options.IdempotencyStore == nil {
+		return fmt.Errorf("WithIdempotencyKey requires WithIdempotencyStore")
+	}
+	if options.IsolatedConfig != nil {
+		// The directory is removed on Disconnect, which one-shot queries lack
+		return fmt.Errorf("isolated config requires a Client")
+	}
+	if options.PathPolicy != nil {
+		return fmt.Errorf("path policy requires a Client")
 	}
+	return options.Validate()
+}
+
+// claimQuery claims the idempotency key of a one-shot query, if any. For
+// a key whose query completed, it returns an iterator over the stored
+// result instead.
+func claimQuery(ctx context.Context, options *Options) (MessageIterator, error) {
+	if options.IdempotencyKey == "" {
+		return nil, nil
+	}
+	stored, err := claimIdempotencyKey(ctx, options.IdempotencyStore, options.IdempotencyKey)
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	return &storedResultIterator{result: stored}, nil
+}
+
+// storedResultIterator yields the stored result of a query retried with
+// its idempotency key.
+type storedResultIterator struct {
+	mu     sync.Mutex
+	result *ResultMessage
+}
+
+func (si *storedResultIterator) Next(_ context.Context) (Message, error) {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	if si.result == nil {
+		return nil, ErrNoMoreMessages
+	}
+	result := si.result
+	si.result = nil
+	return result, nil
+}
+
+func (si *storedResultIterator) Close() error {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	si.result = nil
+	return nil
+}
 
-	// Create iterator that manages the transport lifecycle
+// newQueryIterator returns an iterator that manages the transport
+// lifecycle of a one-shot query.
+func newQueryIterator(ctx context.Context, prompt string, transport Transport, options *Options) *queryIterator {
 	return &queryIterator{
 		transport: transport,
 		prompt:    prompt,
 		ctx:       ctx,
 		options:   options,
-	}, nil
+		turn:      &turnDeadline{},
+	}
+}
+
+// queryClock returns the clock set with WithClock, or the system clock.
+func queryClock(options *Options) Clock {
+	if options.Clock != nil {
+		return options.Clock
+	}
+	return SystemClock{}
 }
 
 // queryIterator implements MessageIterator for simple queries
@@ -76,6 +184,19 @@ type queryIterator struct {
 	mu        sync.Mutex
 	closed    bool
 	closeOnce sync.Once
+
+	// The query's result is stored under its idempotency key, or the key
+	// released, once
+	settleOnce sync.Once
+
+	// Session and working directory reported by the CLI's init message,
+	// for continued queries
+	resumedSessionID string
+	resumedCwd       string
+
+	// When to interrupt the query, with WithQueryDeadlinePolicy
+	deadline *deadlinePlan
+	turn     *turnDeadline
 }
 
 func (qi *queryIterator) Next(_ context.Context) (Message, error) {
@@ -89,6 +210,7 @@ func (qi *queryIterator) Next(_ context.Context) (Message, error) {
 	if !qi.started {
 		if err := qi.start(); err != nil {
 			qi.mu.Unlock()
+			qi.settleKey(nil)
 			return nil, err
 		}
 		qi.started = true
@@ -96,34 +218,161 @@ func (qi *queryIterator) Next(_ context.Context) (Message, error) {
 	qi.mu.Unlock()
 
 	// Read from message channels
-	select {
-	case msg, ok := <-qi.msgChan:
-		if !ok {
+	for {
+		select {
+		case msg, ok := <-qi.msgChan:
+			if !ok {
+				qi.mu.Lock()
+				qi.closed = true
+				qi.mu.Unlock()
+				qi.settleKey(nil)
+				return nil, ErrNoMoreMessages
+			}
+			qi.annotateResumed(msg)
+			qi.turn.track(msg)
+			if result, ok := msg.(*ResultMessage); ok {
+				qi.settleKey(result)
+			}
+			return msg, nil
+		case err, ok := <-qi.errChan:
+			if !ok {
+				// The transport closes both channels when the stream ends;
+				// keep reading the messages still buffered
+				qi.errChan = nil
+				continue
+			}
+			qi.mu.Lock()
+			qi.closed = true
+			qi.mu.Unlock()
+			qi.settleKey(nil)
+			return nil, err
+		case <-qi.ctx.Done():
 			qi.mu.Lock()
 			qi.closed = true
 			qi.mu.Unlock()
-			return nil, ErrNoMoreMessages
+			qi.settleKey(nil)
+			return nil, qi.ctx.Err()
 		}
-		return msg, nil
-	case err := <-qi.errChan:
-		qi.mu.Lock()
-		qi.closed = true
-		qi.mu.Unlock()
-		return nil, err
-	case <-qi.ctx.Done():
-		qi.mu.Lock()
-		qi.closed = true
-		qi.mu.Unlock()
-		return nil, qi.ctx.Err()
 	}
 }
 
+// settleKey stores result under the query's idempotency key, or releases
+// the key when the query ended without a successful result. Only the first
+// call counts.
+func (qi *queryIterator) settleKey(result *ResultMessage) {
+	if qi.options == nil || qi.options.IdempotencyKey == "" {
+		return
+	}
+	qi.settleOnce.Do(func() {
+		settleIdempotencyKey(qi.ctx, qi.options.IdempotencyStore, qi.options.IdempotencyKey, result)
+	})
+}
+
+// annotateResumed records the session a continued or resumed query picked
+// up and reports it on the ResultMessage, with the turns it had before the
+// query counted from its transcript.
+func (qi *queryIterator) annotateResumed(msg Message) {
+	if qi.options == nil || (!qi.options.ContinueConversation && qi.options.Resume == nil) {
+		return
+	}
+
+	qi.mu.Lock()
+	defer qi.mu.Unlock()
+	switch m := msg.(type) {
+	case *SystemMessage:
+		if info, ok := m.Init(); ok {
+			qi.resumedSessionID = info.SessionID
+			qi.resumedCwd = info.Cwd
+		}
+	case *ResultMessage:
+		sessionID := qi.resumedSessionID
+		if sessionID == "" {
+			sessionID = m.SessionID
+		}
+		cwd := qi.resumedCwd
+		if cwd == "" && qi.options.Cwd != nil {
+			cwd = *qi.options.Cwd
+		}
+		if cwd == "" {
+			cwd, _ = os.Getwd()
+		}
+		m.Resumed = &ResumedSession{
+			SessionID: sessionID,
+			NumTurns:  priorTurns(transcriptPath(qi.options, cwd, sessionID)),
+		}
+	}
+}
+
+// priorTurns counts the turns in the transcript at path before the current
+// one: the prompts it holds, less the query's own, which the CLI has
+// written by the time the query's result arrives. Tool results, which the
+// transcript also records as user entries, and meta entries are not
+// prompts. It returns 0 if the transcript cannot be read.
+func priorTurns(path string) int {
+	if path == "" {
+		return 0
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	prompts := 0
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 && transcriptPrompt(line) {
+			prompts++
+		}
+		if err != nil {
+			break
+		}
+	}
+	if prompts == 0 {
+		return 0
+	}
+	return prompts - 1
+}
+
+// transcriptPrompt reports whether a transcript line holds a prompt.
+func transcriptPrompt(line []byte) bool {
+	var entry struct {
+		Type    string `json:"type"`
+		IsMeta  bool   `json:"isMeta"`
+		Message struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"message"`
+	}
+	if json.Unmarshal(line, &entry) != nil || entry.Type != MessageTypeUser || entry.IsMeta {
+		return false
+	}
+	var text string
+	if json.Unmarshal(entry.Message.Content, &text) == nil {
+		return true
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(entry.Message.Content, &blocks) != nil {
+		return false
+	}
+	for _, block := range blocks {
+		if block.Type == ContentBlockTypeToolResult {
+			return false
+		}
+	}
+	return len(blocks) > 0
+}
+
 func (qi *queryIterator) Close() error {
 	var err error
 	qi.closeOnce.Do(func() {
 		qi.mu.Lock()
 		qi.closed = true
 		qi.mu.Unlock()
+		qi.turn.disarm()
+		qi.settleKey(nil)
 		if qi.transport != nil {
 			err = qi.transport.Close()
 		}
@@ -132,16 +381,20 @@ func (qi *queryIterator) Close() error {
 }
 
 func (qi *queryIterator) start() error {
-	// Connect to transport
-	if err := qi.transport.Connect(qi.ctx); err != nil {
+	// Connect to transport and get message channels. Goroutines the
+	// transport starts inherit the reader labels.
+	sessionID := resumedSessionID(qi.options)
+	var err error
+	pprof.Do(qi.ctx, goroutineLabels(sessionID, GoroutineRoleReader), func(ctx context.Context) {
+		if err = qi.transport.Connect(ctx); err != nil {
+			return
+		}
+		qi.msgChan, qi.errChan = qi.transport.ReceiveMessages(ctx)
+	})
+	if err != nil {
 		return fmt.Errorf("failed to connect transport: %w", err)
 	}
 
-	// Get message channels
-	msgChan, errChan := qi.transport.ReceiveMessages(qi.ctx)
-	qi.msgChan = msgChan
-	qi.errChan = errChan
-
 	// Send the prompt
 	userMsg := &UserMessage{Content: qi.prompt}
 	streamMsg := StreamMessage{
@@ -149,7 +402,18 @@ func (qi *queryIterator) start() error {
 		Message: userMsg,
 	}
 
+	// Arm the deadline first so the query cannot end before it is armed
+	if qi.deadline != nil {
+		spawn := func(wait func(done <-chan struct{})) {
+			go func() {
+				labelGoroutine(sessionID, GoroutineRoleMonitor)
+				wait(nil)
+			}()
+		}
+		qi.turn.arm(spawn, queryClock(qi.options), qi.deadline.budget, deadlineInterrupt(qi.ctx, qi.transport))
+	}
 	if err := qi.transport.SendMessage(qi.ctx, streamMsg); err != nil {
+		qi.turn.disarm()
 		return fmt.Errorf("failed to send message: %w", err)
 	}
 
@@ -158,9 +422,7 @@ func (qi *queryIterator) start() error {
 
 // createQueryTransport creates a transport for one-shot queries with prompt as CLI argument.
 func createQueryTransport(prompt string, options *Options) (Transport, error) {
-	// Import here to avoid issues - actual imports are at the top of the file
-	// Find Claude CLI binary
-	cliPath, err := cli.FindCLI()
+	cliPath, err := findCLI(options)
 	if err != nil {
 		return nil, err
 	}
@@ -168,3 +430,15 @@ func createQueryTransport(prompt string, options *Options) (Transport, error) {
 	// Create subprocess transport with prompt as CLI argument
 	return subprocess.NewWithPrompt(cliPath, options, prompt), nil
 }
+
+// findCLI returns the CLI set with WithCLIPath, or discovers one.
+// Replaying a cassette needs no CLI.
+func findCLI(options *Options) (string, error) {
+	if subprocess.Replaying(options) {
+		return "", nil
+	}
+	if options != nil && options.CLIPath != nil && *options.CLIPath != "" {
+		return *options.CLIPath, nil
+	}
+	return cli.FindCLI()
+}
@@ -0,0 +1,114 @@
+package claudecode
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultDeadlineMargin is how long before a query's deadline
+// WithQueryDeadlinePolicy interrupts the turn when the policy sets no
+// margin.
+const DefaultDeadlineMargin = 10 * time.Second
+
+// deadlinePlan is when a query with a deadline is interrupted.
+type deadlinePlan struct {
+	// budget is the time from sending the prompt to the interrupt
+	budget time.Duration
+}
+
+// planDeadline returns when to interrupt a query sent at now under ctx,
+// or false without a policy or a deadline.
+func planDeadline(ctx context.Context, policy *QueryDeadlinePolicy, now time.Time) (deadlinePlan, bool) {
+	if policy == nil {
+		return deadlinePlan{}, false
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return deadlinePlan{}, false
+	}
+	margin := policy.Margin
+	if margin <= 0 {
+		margin = DefaultDeadlineMargin
+	}
+	remaining := deadline.Sub(now)
+	if remaining <= 0 {
+		return deadlinePlan{}, false
+	}
+	if margin > remaining/2 {
+		margin = remaining / 2
+	}
+	return deadlinePlan{budget: remaining - margin}, true
+}
+
+// announceDeadline adds the time the agent has to prompt, when the policy
+// asks for it.
+func announceDeadline(prompt string, policy *QueryDeadlinePolicy, plan deadlinePlan, locale string) string {
+	if !policy.AnnounceDeadline {
+		return prompt
+	}
+	budget := plan.budget.Round(time.Second)
+	if budget < time.Second {
+		budget = time.Second
+	}
+	return prompt + "\n\n" + LocalizedMessage(locale, MessageDeadlineNotice, budget)
+}
+
+// turnDeadline interrupts the current turn when its deadline plan says
+// so, unless the turn ends first.
+type turnDeadline struct {
+	mu sync.Mutex
+	// cancel is closed to disarm the armed deadline
+	cancel chan struct{}
+}
+
+// arm interrupts the turn after d, replacing any armed deadline. spawn
+// runs the waiting goroutine, which must return once its done channel is
+// closed.
+func (td *turnDeadline) arm(spawn func(func(done <-chan struct{})), clock Clock, d time.Duration, interrupt func()) {
+	td.mu.Lock()
+	if td.cancel != nil {
+		close(td.cancel)
+	}
+	cancel := make(chan struct{})
+	td.cancel = cancel
+	td.mu.Unlock()
+
+	spawn(func(done <-chan struct{}) {
+		timer := clock.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+			interrupt()
+		case <-cancel:
+		case <-done:
+		}
+	})
+}
+
+// disarm cancels the armed deadline, if any.
+func (td *turnDeadline) disarm() {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	if td.cancel != nil {
+		close(td.cancel)
+		td.cancel = nil
+	}
+}
+
+// track disarms the deadline when the turn's ResultMessage arrives.
+func (td *turnDeadline) track(msg Message) {
+	if _, ok := msg.(*ResultMessage); ok {
+		td.disarm()
+	}
+}
+
+// deadlineInterrupt returns a function interrupting transport, bounded
+// like the interrupts of timed out tools.
+func deadlineInterrupt(ctx context.Context, transport Transport) func() {
+	return func() {
+		ctx, cancel := context.WithTimeout(ctx, toolTimeoutInterruptTimeout)
+		defer cancel()
+		_ = transport.Interrupt(ctx)
+	}
+}
@@ -0,0 +1,144 @@
+package claudecode
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPlanDeadline(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		policy   *QueryDeadlinePolicy
+		deadline time.Duration
+		expect   time.Duration
+		ok       bool
+	}{
+		{"no policy", nil, time.Minute, 0, false},
+		{"no deadline", &QueryDeadlinePolicy{}, 0, 0, false},
+		{"past deadline", &QueryDeadlinePolicy{}, -time.Second, 0, false},
+		{"default margin", &QueryDeadlinePolicy{}, time.Minute, 50 * time.Second, true},
+		{"custom margin", &QueryDeadlinePolicy{Margin: 5 * time.Second}, time.Minute, 55 * time.Second, true},
+		{"margin clamped to half", &QueryDeadlinePolicy{Margin: time.Minute}, 30 * time.Second, 15 * time.Second, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.deadline != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, now.Add(test.deadline))
+				defer cancel()
+			}
+			plan, ok := planDeadline(ctx, test.policy, now)
+			if ok != test.ok || plan.budget != test.expect {
+				t.Errorf("Expected budget %v (%v), got %v (%v)", test.expect, test.ok, plan.budget, ok)
+			}
+		})
+	}
+}
+
+func TestAnnounceDeadline(t *testing.T) {
+	policy := &QueryDeadlinePolicy{AnnounceDeadline: true}
+	got := announceDeadline("Fix the build", policy, deadlinePlan{budget: 89600 * time.Millisecond}, "")
+	expect := "Fix the build\n\nTime limit: you have 1m30s to complete this request."
+	if !strings.HasPrefix(got, expect) {
+		t.Errorf("Expected %q to start with %q", got, expect)
+	}
+
+	got = announceDeadline("Fix the build", policy, deadlinePlan{budget: 100 * time.Millisecond}, "de")
+	if !strings.Contains(got, "Du hast 1s") {
+		t.Errorf("Expected at least a second in German, got %q", got)
+	}
+
+	if got := announceDeadline("Fix the build", &QueryDeadlinePolicy{}, deadlinePlan{budget: time.Minute}, ""); got != "Fix the build" {
+		t.Errorf("Expected the prompt unchanged, got %q", got)
+	}
+}
+
+func TestClientQueryDeadlineInterruptsTurn(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clock := newManualClock()
+	clock.now = time.Now()
+	transport := &interruptCountingTransport{clientMockTransport: newClientMockTransport()}
+	client := NewClientWithTransport(transport, WithClock(clock),
+		WithQueryDeadlinePolicy(QueryDeadlinePolicy{Margin: time.Second, AnnounceDeadline: true}))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	assertNoError(t, client.Query(ctx, "Fix the build"))
+	timer := clock.waitForTimer(t)
+	if timer.d <= 0 || timer.d > 4*time.Second {
+		t.Errorf("Expected the deadline a second before the context's, got %v", timer.d)
+	}
+	sent, ok := transport.getSentMessage(0)
+	if !ok {
+		t.Fatal("Expected the prompt to be sent")
+	}
+	content, _ := sent.Message.(map[string]interface{})["content"].(string)
+	if !strings.Contains(content, "Time limit: you have") {
+		t.Errorf("Expected the deadline in the prompt, got %q", content)
+	}
+
+	timer.fire()
+	waitForInterrupts(ctx, t, transport, 1)
+}
+
+func TestClientQueryDeadlineDisarmedByResult(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clock := newManualClock()
+	clock.now = time.Now()
+	transport := &interruptCountingTransport{clientMockTransport: newClientMockTransport()}
+	client := NewClientWithTransport(transport, WithClock(clock), WithQueryDeadlinePolicy(QueryDeadlinePolicy{}))
+	connectClientSafely(ctx, t, client)
+
+	assertNoError(t, client.Query(ctx, "Fix the build"))
+	timer := clock.waitForTimer(t)
+	deliverTurnResult(t, transport.clientMockTransport)
+	awaitClientResult(ctx, t, client)
+	timer.fire()
+
+	disconnectClientSafely(t, client)
+	client.Wait()
+	if n := atomic.LoadInt32(&transport.interrupts); n != 0 {
+		t.Errorf("Expected no interrupt after the result, got %d", n)
+	}
+	sent, _ := transport.getSentMessage(0)
+	if content, _ := sent.Message.(map[string]interface{})["content"].(string); content != "Fix the build" {
+		t.Errorf("Expected the prompt unannounced, got %q", content)
+	}
+}
+
+func TestQueryWithTransportDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clock := newManualClock()
+	clock.now = time.Now()
+	transport := &interruptCountingTransport{clientMockTransport: newClientMockTransport()}
+	iter, err := QueryWithTransport(ctx, "Fix the build", transport, WithClock(clock),
+		WithQueryDeadlinePolicy(QueryDeadlinePolicy{Margin: time.Second}))
+	assertNoError(t, err)
+	defer iter.Close()
+
+	go func() { _, _ = iter.Next(ctx) }()
+	clock.waitForTimer(t).fire()
+	waitForInterrupts(ctx, t, transport, 1)
+}
+
+func waitForInterrupts(ctx context.Context, t *testing.T, transport *interruptCountingTransport, n int32) {
+	t.Helper()
+	for atomic.LoadInt32(&transport.interrupts) != n {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Expected %d interrupts, got %d", n, atomic.LoadInt32(&transport.interrupts))
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package claudecode
+
+import (
+	"context"
+	"sync"
+)
+
+// overrideState tracks the model and permission mode overrides of the
+// running query, so they are reverted when its turn ends.
+type overrideState struct {
+	// mu serializes changes to the CLI's model and permission mode
+	mu sync.Mutex
+
+	// stateMu guards the fields below and is never held while waiting for
+	// the CLI
+	stateMu    sync.Mutex
+	overridden bool
+	// reverting is closed once the overrides of the last turn are reverted
+	reverting chan struct{}
+}
+
+// endTurn is called when a turn ends. If its query overrode settings, it
+// returns the channel to close once they are reverted; the next query
+// waits for that.
+func (st *overrideState) endTurn() chan struct{} {
+	st.stateMu.Lock()
+	defer st.stateMu.Unlock()
+	if !st.overridden {
+		return nil
+	}
+	st.overridden = false
+	st.reverting = make(chan struct{})
+	return st.reverting
+}
+
+// setOverridden records whether the query being sent overrides settings.
+func (st *overrideState) setOverridden(overridden bool) {
+	st.stateMu.Lock()
+	defer st.stateMu.Unlock()
+	st.overridden = overridden
+}
+
+// waitRevert waits until the overrides of the previous turn are reverted.
+func (st *overrideState) waitRevert(ctx context.Context) error {
+	st.stateMu.Lock()
+	reverting := st.reverting
+	st.stateMu.Unlock()
+	if reverting == nil {
+		return nil
+	}
+	select {
+	case <-reverting:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// revertQueryOverrides brings the CLI back to the client's model and
+// permission mode after a turn that overrode them, closing done when
+// finished. A failed revert is retried by the next query, which aligns the
+// CLI with its own settings before sending.
+func (c *ClientImpl) revertQueryOverrides(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	c.overrides.mu.Lock()
+	defer c.overrides.mu.Unlock()
+
+	c.mu.RLock()
+	model, mode := c.defaultModel, c.defaultPermissionMode
+	c.mu.RUnlock()
+	_ = c.alignSettings(ctx, model, mode)
+}
+
+// turnLimit enforces the WithMaxTurns of a query. The CLI's own limit is
+// fixed when it starts, so the client counts the model's responses in the
+// turn and interrupts it when tool results arrive after the last allowed
+// response, before the model is asked to continue.
+type turnLimit struct {
+	mu        sync.Mutex
+	max       int
+	responses int
+	// awaiting is set until the response after the latest tool results
+	// begins
+	awaiting  bool
+	interrupt func()
+}
+
+// arm limits the turn about to start to max responses. spawn runs
+// interrupt, which must not hold up the message stream.
+func (tl *turnLimit) arm(max int, spawn func(func(done <-chan struct{})), interrupt func()) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.max, tl.responses, tl.awaiting = max, 0, true
+	tl.interrupt = func() {
+		spawn(func(<-chan struct{}) { interrupt() })
+	}
+}
+
+// disarm removes the limit.
+func (tl *turnLimit) disarm() {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.max, tl.interrupt = 0, nil
+}
+
+// track counts the turn's responses and interrupts it once it would go
+// past the limit.
+func (tl *turnLimit) track(msg Message) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	if tl.interrupt == nil {
+		return
+	}
+
+	switch m := msg.(type) {
+	case *AssistantMessage:
+		// A response arrives as one message per content block
+		if tl.awaiting {
+			tl.responses++
+			tl.awaiting = false
+		}
+	case *UserMessage:
+		if !hasToolResults(m) {
+			return
+		}
+		tl.awaiting = true
+		if tl.responses >= tl.max {
+			interrupt := tl.interrupt
+			tl.max, tl.interrupt = 0, nil
+			interrupt()
+		}
+	case *ResultMessage:
+		tl.max, tl.interrupt = 0, nil
+	}
+}
+
+// hasToolResults reports whether msg carries tool results.
+func hasToolResults(msg *UserMessage) bool {
+	blocks, ok := msg.Content.([]ContentBlock)
+	if !ok {
+		return false
+	}
+	for _, block := range blocks {
+		if _, ok := block.(*ToolResultBlock); ok {
+			return true
+		}
+	}
+	return false
+}
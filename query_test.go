@@ -2,8 +2,11 @@ package claudecode
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"sync"
@@ -540,6 +543,12 @@ func TestCreateQueryTransport(t *testing.T) {
 			expectError: true,
 			errorMsg:    "Claude Code requires Node.js",
 		},
+		{
+			name:      "cli_path_skips_discovery",
+			prompt:    "test prompt",
+			options:   NewOptions(WithCLIPath("/opt/claude/bin/claude")),
+			setupMock: setupIsolatedEnvironment,
+		},
 	}
 
 	for _, test := range tests {
@@ -785,6 +794,121 @@ func TestQueryWithNilOptions(t *testing.T) {
 	assertQueryTextContent(t, assistantMsg, "response")
 }
 
+// TestQueryRejectsInvalidPermissionMode tests that unknown modes fail before the CLI starts
+func TestQueryRejectsInvalidPermissionMode(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	transport := newQueryMockTransport(WithQueryAssistantResponse("response"))
+	iter, err := QueryWithTransport(ctx, "test prompt", transport, WithPermissionMode("auto"))
+	if err == nil || !strings.Contains(err.Error(), "invalid permission mode") {
+		t.Fatalf("Expected invalid permission mode error, got %v", err)
+	}
+	if iter != nil {
+		t.Error("Expected nil iterator for invalid options")
+	}
+
+	// Rejected before the CLI is looked up
+	_, err = Query(ctx, "test prompt", WithPermissionMode("auto"))
+	if err == nil || !strings.Contains(err.Error(), "invalid permission mode") {
+		t.Fatalf("Expected invalid permission mode error from Query, got %v", err)
+	}
+}
+
+func TestQueryRejectsConflictingToolLists(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	transport := newQueryMockTransport(WithQueryAssistantResponse("response"))
+	_, err := QueryWithTransport(ctx, "test prompt", transport,
+		WithAllowedTools("Read", "Write"), WithDisallowedTools("Write"))
+	if err == nil || !strings.Contains(err.Error(), `tool "Write" cannot be both allowed and disallowed`) {
+		t.Fatalf("Expected tool conflict error, got %v", err)
+	}
+}
+
+func TestQueryContinueConversationReportsResumedSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		init    bool
+		want    *ResumedSession
+	}{
+		{"continue with init", []Option{WithContinueConversation(true)}, true,
+			&ResumedSession{SessionID: "prior-session"}},
+		{"resume without init", []Option{WithResume("test-session")}, false,
+			&ResumedSession{SessionID: "test-session"}},
+		{"fresh session", nil, true, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+			defer cancel()
+
+			var mockOptions []QueryMockOption
+			if test.init {
+				mockOptions = append(mockOptions,
+					WithQuerySystemMessage("init", map[string]any{"session_id": "prior-session"}))
+			}
+			mockOptions = append(mockOptions, WithQueryResultMessage(false, 1000, 4))
+			transport := newQueryMockTransport(mockOptions...)
+
+			options := append([]Option{WithConfigDir(t.TempDir())}, test.options...)
+			iter, err := QueryWithTransport(ctx, "keep going", transport, options...)
+			if err != nil {
+				t.Fatalf("QueryWithTransport failed: %v", err)
+			}
+			defer func() { _ = iter.Close() }()
+
+			result := nextQueryResult(ctx, t, iter)
+			if !reflect.DeepEqual(result.Resumed, test.want) {
+				t.Errorf("Expected resumed session %+v, got %+v", test.want, result.Resumed)
+			}
+		})
+	}
+}
+
+func TestQueryResumedSessionCountsPriorTurns(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	configDir := t.TempDir()
+	options := NewOptions(WithConfigDir(configDir))
+	path := transcriptPath(options, "/work/repo", "prior-session")
+	transcript := strings.Join([]string{
+		`{"type":"user","message":{"role":"user","content":"Write a parser"}}`,
+		`{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"Write","input":{}}]}}`,
+		`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}}`,
+		`{"type":"user","isMeta":true,"message":{"role":"user","content":"<command-name>/clear</command-name>"}}`,
+		`{"type":"user","message":{"role":"user","content":[{"type":"text","text":"Now add tests"}]}}`,
+		`{"type":"user","message":{"role":"user","content":"keep going"}}`,
+	}, "\n") + "\n"
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(transcript), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := newQueryMockTransport(
+		WithQuerySystemMessage("init", map[string]any{"session_id": "prior-session", "cwd": "/work/repo"}),
+		WithQueryResultMessage(false, 1000, 4),
+	)
+	iter, err := QueryWithTransport(ctx, "keep going", transport,
+		WithContinueConversation(true), WithConfigDir(configDir))
+	if err != nil {
+		t.Fatalf("QueryWithTransport failed: %v", err)
+	}
+	defer func() { _ = iter.Close() }()
+
+	result := nextQueryResult(ctx, t, iter)
+	want := &ResumedSession{SessionID: "prior-session", NumTurns: 2}
+	if !reflect.DeepEqual(result.Resumed, want) {
+		t.Errorf("Expected resumed session %+v, got %+v", want, result.Resumed)
+	}
+}
+
 // TestQueryIteratorContextCancellation tests context cancellation during iteration
 func TestQueryIteratorContextCancellation(t *testing.T) {
 	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
@@ -812,6 +936,46 @@ func TestQueryIteratorContextCancellation(t *testing.T) {
 	}
 }
 
+func TestQueryIteratorDrainsMessagesAfterStreamEnd(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	// Both channels are already closed with messages still buffered, as when
+	// the CLI exits right after writing its result
+	transport := &closedStreamTransport{queryMockTransport: newQueryMockTransport()}
+	iter, err := QueryWithTransport(ctx, "test", transport)
+	if err != nil {
+		t.Fatalf("QueryWithTransport failed: %v", err)
+	}
+	defer iter.Close()
+
+	for i := 0; i < 2; i++ {
+		msg, err := iter.Next(ctx)
+		if err != nil || msg == nil {
+			t.Fatalf("Message %d: expected buffered message, got %v, %v", i, msg, err)
+		}
+	}
+	if _, err := iter.Next(ctx); !errors.Is(err, ErrNoMoreMessages) {
+		t.Errorf("Expected ErrNoMoreMessages, got %v", err)
+	}
+}
+
+// closedStreamTransport delivers a finished stream: buffered messages on
+// closed channels.
+type closedStreamTransport struct {
+	*queryMockTransport
+}
+
+func (c *closedStreamTransport) ReceiveMessages(_ context.Context) (<-chan Message, <-chan error) {
+	msgChan := make(chan Message, 2)
+	msgChan <- &AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "done"}}, Model: "claude-sonnet-4-5"}
+	msgChan <- &ResultMessage{Subtype: "success", SessionID: "s1"}
+	close(msgChan)
+	errChan := make(chan error)
+	close(errChan)
+	return msgChan, errChan
+}
+
 // Mock Transport Implementation
 type queryMockTransport struct {
 	mu               sync.RWMutex
@@ -1225,3 +1389,16 @@ func TestQueryFunction(t *testing.T) {
 		})
 	}
 }
+
+func nextQueryResult(ctx context.Context, t *testing.T, iter MessageIterator) *ResultMessage {
+	t.Helper()
+	for {
+		msg, err := iter.Next(ctx)
+		if err != nil {
+			t.Fatalf("Expected a result message, got error: %v", err)
+		}
+		if result, ok := msg.(*ResultMessage); ok {
+			return result
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// QueryText runs a one-shot query and returns its result text, for callers
+// that only want the answer.
+//
+// A query that fails, or ends with an error result, returns an error. When
+// messages arrived before the failure, the error is a *PartialResult
+// holding them. With WithPartialText, the text produced before the failure
+// is also returned, so a long generation cut short by a crash or rate
+// limit is not lost:
+//
+//	text, err := claudecode.QueryText(ctx, "Draft the release notes", claudecode.WithPartialText())
+//	var partial *claudecode.PartialResult
+//	if errors.As(err, &partial) {
+//	    log.Printf("kept %d bytes of a failed generation: %v", len(text), err)
+//	} else if err != nil {
+//	    return err
+//	}
+func QueryText(ctx context.Context, prompt string, opts ...Option) (string, error) {
+	return queryText(ctx, prompt, opts, Query)
+}
+
+// QueryTextWithTransport runs QueryText over a custom transport, like
+// QueryWithTransport.
+func QueryTextWithTransport(ctx context.Context, prompt string, transport Transport, opts ...Option) (string, error) {
+	if transport == nil {
+		return "", fmt.Errorf("transport is required")
+	}
+	return queryText(ctx, prompt, opts, func(ctx context.Context, prompt string, opts ...Option) (MessageIterator, error) {
+		return QueryWithTransport(ctx, prompt, transport, opts...)
+	})
+}
+
+func queryText(
+	ctx context.Context,
+	prompt string,
+	opts []Option,
+	query func(ctx context.Context, prompt string, opts ...Option) (MessageIterator, error),
+) (string, error) {
+	result, err := runSpec(ctx, RunSpec{Prompt: prompt, Options: opts}, query)
+	if err == nil && result.Result.IsError {
+		err = NewPartialResult(result.Messages,
+			fmt.Errorf("query ended with an error result (%s)", result.Result.Subtype))
+	}
+	if err == nil {
+		return result.Text, nil
+	}
+
+	var partial *PartialResult
+	if NewOptions(opts...).PartialText && errors.As(err, &partial) {
+		return partial.Text(), err
+	}
+	return "", err
+}
@@ -0,0 +1,73 @@
+package claudecode
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueryText(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	answer := "4"
+	transport := newQueryMockTransport(WithQueryAssistantResponse("4"))
+	transport.responseMessages = append(transport.responseMessages, &ResultMessage{Subtype: "success", Result: &answer})
+
+	text, err := QueryTextWithTransport(ctx, "What is 2+2?", transport)
+	if err != nil {
+		t.Fatalf("QueryText failed: %v", err)
+	}
+	if text != "4" {
+		t.Errorf("Expected the result text, got %q", text)
+	}
+}
+
+func TestQueryTextPartialResult(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	// The stream ends without a result, as when the CLI crashes
+	crashing := func() Transport {
+		return newQueryMockTransport(WithQueryAssistantResponse("Chapter one"), WithQueryAssistantResponse("Chapter two"))
+	}
+
+	text, err := QueryTextWithTransport(ctx, "Write a book", crashing())
+	var partial *PartialResult
+	if !errors.As(err, &partial) || !errors.Is(err, ErrNoResult) {
+		t.Fatalf("Expected a PartialResult wrapping ErrNoResult, got %v", err)
+	}
+	if text != "" {
+		t.Errorf("Expected no text without WithPartialText, got %q", text)
+	}
+	if len(partial.Messages) != 2 || partial.Text() != "Chapter one\nChapter two" {
+		t.Errorf("Expected the messages received so far, got %+v", partial.Messages)
+	}
+
+	text, err = QueryTextWithTransport(ctx, "Write a book", crashing(), WithPartialText())
+	if !errors.As(err, &partial) || text != "Chapter one\nChapter two" {
+		t.Errorf("Expected the partial text with the error, got %q, %v", text, err)
+	}
+}
+
+func TestQueryTextErrors(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	transport := newQueryMockTransport(WithQueryAssistantResponse("Rate limited"), WithQueryResultMessage(true, 1000, 1))
+	text, err := QueryTextWithTransport(ctx, "go", transport, WithPartialText())
+	var partial *PartialResult
+	if !errors.As(err, &partial) || text != "Rate limited" {
+		t.Errorf("Expected an error result to fail with its partial text, got %q, %v", text, err)
+	}
+
+	connectErr := errors.New("connect failed")
+	_, err = QueryTextWithTransport(ctx, "go", newQueryMockTransport(WithQueryConnectError(connectErr)), WithPartialText())
+	if !errors.Is(err, connectErr) || errors.As(err, &partial) {
+		t.Errorf("Expected the connect error without a partial result, got %v", err)
+	}
+
+	if _, err := QueryTextWithTransport(ctx, "go", nil); err == nil {
+		t.Error("Expected an error without a transport")
+	}
+}
@@ -0,0 +1,267 @@
+// Package quota enforces per-tenant token and spending limits across all
+// the clients of a service.
+//
+// A Manager keeps daily and monthly usage per tenant in a Store shared by
+// every client, so limits hold across clients, processes and hosts when
+// the store is shared too. Before each query, the tenant's usage is checked
+// against its limits, and a query over a limit fails with a
+// *QuotaExceededError before anything is sent. Each ResultMessage adds the
+// tokens and cost of its turn:
+//
+//	quotas := quota.New(quota.NewMemoryStore(), quota.Limits{DailyUSD: 5, MonthlyTokens: 10_000_000})
+//	quotas.SetLimits("enterprise-co", quota.Limits{DailyUSD: 100})
+//
+//	client := claudecode.NewClient(quota.WithQuota(quotas, tenantID))
+//	if err := client.Query(ctx, prompt); err != nil {
+//		var exceeded *quota.QuotaExceededError
+//		if errors.As(err, &exceeded) {
+//			// tell the tenant when the quota resets, in their language
+//			return errors.New(exceeded.Localize(tenantLocale))
+//		}
+//	}
+//
+// Usage is recorded when a turn ends, so queries already running when a
+// limit is reached complete and may overshoot it. Periods follow UTC
+// calendar days and months.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// ErrQuotaExceeded matches every *QuotaExceededError with errors.Is.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Period is the window a limit applies to.
+type Period string
+
+// Periods limits apply to.
+const (
+	Daily   Period = "daily"
+	Monthly Period = "monthly"
+)
+
+// Resource is what a limit counts.
+type Resource string
+
+// Resources limits apply to.
+const (
+	Tokens Resource = "tokens"
+	USD    Resource = "usd"
+)
+
+// Limits are a tenant's quotas. Zero fields are unlimited.
+type Limits struct {
+	DailyTokens   int64
+	MonthlyTokens int64
+	DailyUSD      float64
+	MonthlyUSD    float64
+}
+
+// Usage is what a tenant consumed in a period.
+type Usage struct {
+	// Tokens counts input, output and cache tokens.
+	Tokens int64
+	USD    float64
+}
+
+// QuotaExceededError is returned for a query by a tenant that has used up
+// one of its limits.
+type QuotaExceededError struct {
+	Tenant   string
+	Period   Period
+	Resource Resource
+	Limit    float64
+	Used     float64
+	// ResetAt is when the period ends and the quota is available again.
+	ResetAt time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: tenant %s used %g of its %s %s limit of %g, resets at %s",
+		e.Tenant, e.Used, e.Period, e.Resource, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// Localize returns a message for the tenant in locale, for example "de",
+// saying which limit was reached and when it resets. The message comes
+// from the SDK's catalog; see claudecode.RegisterMessages.
+func (e *QuotaExceededError) Localize(locale string) string {
+	reset := e.ResetAt.Format(time.RFC3339)
+	if e.Resource == USD {
+		return claudecode.LocalizedMessage(locale, claudecode.MessageBudgetExceeded, e.Used, e.Limit, reset)
+	}
+	return claudecode.LocalizedMessage(locale, claudecode.MessageTokenLimitReached, int64(e.Used), int64(e.Limit), reset)
+}
+
+// Is reports whether target is ErrQuotaExceeded.
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Manager checks and records tenant usage. It is safe for concurrent use.
+type Manager struct {
+	// OnError, if set, receives the errors of recording results for
+	// clients configured with WithQuota. Set it before use.
+	OnError func(tenant string, err error)
+
+	store    Store
+	defaults Limits
+	now      func() time.Time
+
+	mu      sync.RWMutex
+	tenants map[string]Limits
+}
+
+// New returns a Manager keeping usage in store and applying defaults to
+// tenants without limits of their own.
+func New(store Store, defaults Limits) *Manager {
+	return &Manager{store: store, defaults: defaults, now: time.Now, tenants: make(map[string]Limits)}
+}
+
+// SetLimits sets the limits of tenant, replacing the defaults.
+func (m *Manager) SetLimits(tenant string, limits Limits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tenants[tenant] = limits
+}
+
+// Limits returns the limits that apply to tenant.
+func (m *Manager) Limits(tenant string) Limits {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if limits, ok := m.tenants[tenant]; ok {
+		return limits
+	}
+	return m.defaults
+}
+
+// Usage returns what tenant consumed in the current period.
+func (m *Manager) Usage(ctx context.Context, tenant string, period Period) (Usage, error) {
+	w := window(period, m.now())
+	return m.store.Get(ctx, w.key(tenant))
+}
+
+// Check returns a *QuotaExceededError if tenant has reached one of its
+// limits in the current day or month.
+func (m *Manager) Check(ctx context.Context, tenant string) error {
+	limits := m.Limits(tenant)
+	now := m.now()
+	for _, c := range []struct {
+		period    Period
+		tokens    int64
+		usd       float64
+		unlimited bool
+	}{
+		{Daily, limits.DailyTokens, limits.DailyUSD, limits.DailyTokens <= 0 && limits.DailyUSD <= 0},
+		{Monthly, limits.MonthlyTokens, limits.MonthlyUSD, limits.MonthlyTokens <= 0 && limits.MonthlyUSD <= 0},
+	} {
+		if c.unlimited {
+			continue
+		}
+		w := window(c.period, now)
+		usage, err := m.store.Get(ctx, w.key(tenant))
+		if err != nil {
+			return fmt.Errorf("reading quota usage: %w", err)
+		}
+		exceeded := &QuotaExceededError{Tenant: tenant, Period: c.period, ResetAt: w.end}
+		switch {
+		case c.tokens > 0 && usage.Tokens >= c.tokens:
+			exceeded.Resource, exceeded.Limit, exceeded.Used = Tokens, float64(c.tokens), float64(usage.Tokens)
+		case c.usd > 0 && usage.USD >= c.usd:
+			exceeded.Resource, exceeded.Limit, exceeded.Used = USD, c.usd, usage.USD
+		default:
+			continue
+		}
+		return exceeded
+	}
+	return nil
+}
+
+// Record adds usage to tenant's current day and month.
+func (m *Manager) Record(ctx context.Context, tenant string, usage Usage) error {
+	now := m.now()
+	for _, period := range []Period{Daily, Monthly} {
+		w := window(period, now)
+		if err := m.store.Add(ctx, w.key(tenant), usage, w.end.Add(retention).Sub(now)); err != nil {
+			return fmt.Errorf("recording quota usage: %w", err)
+		}
+	}
+	return nil
+}
+
+// RecordResult adds the tokens and cost a ResultMessage reports to
+// tenant's usage. Results without usage or cost add nothing.
+func (m *Manager) RecordResult(ctx context.Context, tenant string, result *claudecode.ResultMessage) error {
+	usage := UsageOf(result)
+	if usage == (Usage{}) {
+		return nil
+	}
+	return m.Record(ctx, tenant, usage)
+}
+
+// UsageOf returns the tokens and cost a ResultMessage reports.
+func UsageOf(result *claudecode.ResultMessage) Usage {
+	var usage Usage
+	if u := result.Usage; u != nil {
+		usage.Tokens = int64(u.TotalInputTokens() + u.OutputTokens)
+	}
+	if result.TotalCostUSD != nil {
+		usage.USD = *result.TotalCostUSD
+	}
+	return usage
+}
+
+// WithQuota enforces m's limits for tenant on a client or query: prompts
+// are rejected with a *QuotaExceededError while a limit is reached, and
+// the client's results are recorded. Errors reading the store also reject
+// the prompt; errors recording a result go to m.OnError.
+//
+// Like other message observers, recording applies to the Client; record
+// the results of the Query function with RecordResult.
+func WithQuota(m *Manager, tenant string) claudecode.Option {
+	return func(o *claudecode.Options) {
+		o.PromptInterceptors = append(o.PromptInterceptors, func(ctx context.Context, _ *claudecode.UserMessage) error {
+			return m.Check(ctx, tenant)
+		})
+		o.MessageObservers = append(o.MessageObservers, func(msg claudecode.Message) {
+			result, ok := msg.(*claudecode.ResultMessage)
+			if !ok {
+				return
+			}
+			if err := m.RecordResult(context.Background(), tenant, result); err != nil && m.OnError != nil {
+				m.OnError(tenant, err)
+			}
+		})
+	}
+}
+
+// retention is how long usage is kept after its period ends.
+const retention = 24 * time.Hour
+
+// periodWindow is one day or month, in UTC.
+type periodWindow struct {
+	period Period
+	id     string
+	end    time.Time
+}
+
+func window(period Period, now time.Time) periodWindow {
+	now = now.UTC()
+	if period == Monthly {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return periodWindow{period: period, id: start.Format("2006-01"), end: start.AddDate(0, 1, 0)}
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return periodWindow{period: period, id: start.Format("2006-01-02"), end: start.AddDate(0, 0, 1)}
+}
+
+// key names the usage of tenant in the window in a Store.
+func (w periodWindow) key(tenant string) string {
+	return tenant + ":" + string(w.period) + ":" + w.id
+}
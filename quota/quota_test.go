@@ -0,0 +1,192 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func newTestManager(t *testing.T, defaults Limits, now time.Time) *Manager {
+	t.Helper()
+	m := New(NewMemoryStore(), defaults)
+	m.now = func() time.Time { return now }
+	return m
+}
+
+func TestManagerCheck(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 30, 22, 0, 0, 0, time.UTC)
+	m := newTestManager(t, Limits{DailyTokens: 1000, MonthlyUSD: 10}, now)
+
+	if err := m.Check(ctx, "acme"); err != nil {
+		t.Fatalf("Expected a fresh tenant within quota, got %v", err)
+	}
+	if err := m.Record(ctx, "acme", Usage{Tokens: 999, USD: 1}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if err := m.Check(ctx, "acme"); err != nil {
+		t.Errorf("Expected usage below the limit to pass, got %v", err)
+	}
+
+	if err := m.Record(ctx, "acme", Usage{Tokens: 1}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	err := m.Check(ctx, "acme")
+	var exceeded *QuotaExceededError
+	if !errors.As(err, &exceeded) || !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected a QuotaExceededError, got %v", err)
+	}
+	want := QuotaExceededError{Tenant: "acme", Period: Daily, Resource: Tokens, Limit: 1000, Used: 1000,
+		ResetAt: time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)}
+	if *exceeded != want {
+		t.Errorf("Expected %+v, got %+v", want, *exceeded)
+	}
+	if !strings.Contains(err.Error(), "tenant acme used 1000 of its daily tokens limit of 1000") {
+		t.Errorf("Unexpected message: %v", err)
+	}
+
+	// Other tenants have quotas of their own
+	if err := m.Check(ctx, "globex"); err != nil {
+		t.Errorf("Expected another tenant within quota, got %v", err)
+	}
+
+	// The next day the daily quota is available again, but the month's
+	// spending carries over
+	m.now = func() time.Time { return now.Add(3 * time.Hour) }
+	if err := m.Check(ctx, "acme"); err != nil {
+		t.Errorf("Expected the daily quota to reset, got %v", err)
+	}
+	if err := m.Record(ctx, "acme", Usage{USD: 9}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	err = m.Check(ctx, "acme")
+	if !errors.As(err, &exceeded) || exceeded.Period != Monthly || exceeded.Resource != USD || exceeded.Used != 10 {
+		t.Errorf("Expected the monthly spending limit, got %v", err)
+	}
+	if !exceeded.ResetAt.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the quota to reset with the month, got %v", exceeded.ResetAt)
+	}
+}
+
+func TestManagerTenantLimits(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, Limits{DailyUSD: 1}, time.Now())
+	m.SetLimits("enterprise", Limits{})
+
+	for _, tenant := range []string{"small", "enterprise"} {
+		if err := m.Record(ctx, tenant, Usage{USD: 5}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	if err := m.Check(ctx, "small"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected the default limit to apply, got %v", err)
+	}
+	if err := m.Check(ctx, "enterprise"); err != nil {
+		t.Errorf("Expected an unlimited tenant, got %v", err)
+	}
+	if usage, err := m.Usage(ctx, "enterprise", Monthly); err != nil || usage.USD != 5 {
+		t.Errorf("Expected the month's usage, got %+v, %v", usage, err)
+	}
+}
+
+func TestManagerStoreErrors(t *testing.T) {
+	storeErr := errors.New("connection refused")
+	m := New(failingStore{err: storeErr}, Limits{DailyTokens: 1})
+
+	if err := m.Check(context.Background(), "acme"); !errors.Is(err, storeErr) || errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected the store error, got %v", err)
+	}
+	if err := m.Record(context.Background(), "acme", Usage{Tokens: 1}); !errors.Is(err, storeErr) {
+		t.Errorf("Expected the store error, got %v", err)
+	}
+	// Unlimited tenants do not touch the store
+	m.SetLimits("free", Limits{})
+	if err := m.Check(context.Background(), "free"); err != nil {
+		t.Errorf("Expected no store access without limits, got %v", err)
+	}
+}
+
+func TestUsageOf(t *testing.T) {
+	cost := 0.02
+	result := &claudecode.ResultMessage{
+		TotalCostUSD: &cost,
+		Usage:        &claudecode.Usage{InputTokens: 10, OutputTokens: 5, CacheCreation: 20, CacheRead: 100},
+	}
+	if usage := UsageOf(result); usage != (Usage{Tokens: 135, USD: 0.02}) {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+	if usage := UsageOf(&claudecode.ResultMessage{}); usage != (Usage{}) {
+		t.Errorf("Expected no usage, got %+v", usage)
+	}
+}
+
+func TestQuotaExceededErrorLocalize(t *testing.T) {
+	reset := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	tokens := &QuotaExceededError{Tenant: "acme", Period: Daily, Resource: Tokens, Limit: 1000, Used: 1200, ResetAt: reset}
+	if got, want := tokens.Localize("en"), "Token limit reached: 1200 of 1000 tokens used. It resets at 2026-03-31T00:00:00Z."; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	spend := &QuotaExceededError{Tenant: "acme", Period: Monthly, Resource: USD, Limit: 50, Used: 50.25, ResetAt: reset}
+	if got, want := spend.Localize("es"), "Presupuesto superado: se gastaron $50.25 de $50.00. Se restablece el 2026-03-31T00:00:00Z."; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestWithQuota(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t, Limits{DailyUSD: 1}, time.Now())
+	options := claudecode.NewOptions(WithQuota(m, "acme"))
+	if len(options.PromptInterceptors) != 1 || len(options.MessageObservers) != 1 {
+		t.Fatalf("Expected an interceptor and an observer, got %d and %d",
+			len(options.PromptInterceptors), len(options.MessageObservers))
+	}
+
+	check := func() error {
+		return options.PromptInterceptors[0](ctx, &claudecode.UserMessage{Content: "hi"})
+	}
+	if err := check(); err != nil {
+		t.Fatalf("Expected the first prompt allowed, got %v", err)
+	}
+
+	cost := 1.5
+	options.MessageObservers[0](&claudecode.AssistantMessage{})
+	options.MessageObservers[0](&claudecode.ResultMessage{Subtype: "success", TotalCostUSD: &cost})
+	if err := check(); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected the prompt rejected once the quota is used, got %v", err)
+	}
+}
+
+func TestWithQuotaRecordError(t *testing.T) {
+	storeErr := errors.New("store down")
+	m := New(failingStore{err: storeErr}, Limits{})
+	var reported []string
+	m.OnError = func(tenant string, err error) {
+		if errors.Is(err, storeErr) {
+			reported = append(reported, tenant)
+		}
+	}
+	options := claudecode.NewOptions(WithQuota(m, "acme"))
+
+	cost := 0.1
+	options.MessageObservers[0](&claudecode.ResultMessage{TotalCostUSD: &cost})
+	if len(reported) != 1 || reported[0] != "acme" {
+		t.Errorf("Expected the record error reported, got %v", reported)
+	}
+}
+
+// failingStore is a Store whose operations fail.
+type failingStore struct {
+	err error
+}
+
+func (s failingStore) Get(context.Context, string) (Usage, error) {
+	return Usage{}, s.err
+}
+
+func (s failingStore) Add(context.Context, string, Usage, time.Duration) error {
+	return s.err
+}
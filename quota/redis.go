@@ -0,0 +1,96 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RedisClient runs Lua scripts on a Redis server. It keeps a Redis client
+// library out of the SDK's dependencies; go-redis needs a small adapter:
+//
+//	type redisAdapter struct{ *redis.Client }
+//
+//	func (a redisAdapter) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+//		return a.Client.Eval(ctx, script, keys, args...).Result()
+//	}
+type RedisClient interface {
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+// RedisStore is a Store in Redis, for clients in many processes. Each key
+// is a hash with "tokens" and "usd" fields.
+type RedisStore struct {
+	client RedisClient
+	prefix string
+}
+
+// DefaultRedisPrefix prefixes the keys of a RedisStore.
+const DefaultRedisPrefix = "claude:quota:"
+
+// NewRedisStore returns a RedisStore using client. Keys are prefixed with
+// prefix, or DefaultRedisPrefix when it is empty.
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+const redisGetScript = `return redis.call('HMGET', KEYS[1], 'tokens', 'usd')`
+
+// redisAddScript adds to both counters and extends the key's expiry, never
+// shortening it.
+const redisAddScript = `
+redis.call('HINCRBY', KEYS[1], 'tokens', ARGV[1])
+redis.call('HINCRBYFLOAT', KEYS[1], 'usd', ARGV[2])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[3]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return 1`
+
+// Get returns the usage under key.
+func (s *RedisStore) Get(ctx context.Context, key string) (Usage, error) {
+	reply, err := s.client.Eval(ctx, redisGetScript, []string{s.prefix + key})
+	if err != nil {
+		return Usage{}, err
+	}
+	fields, ok := reply.([]any)
+	if !ok || len(fields) != 2 {
+		return Usage{}, fmt.Errorf("unexpected redis reply %v", reply)
+	}
+	var usage Usage
+	tokens, err := redisNumber(fields[0])
+	if err != nil {
+		return Usage{}, err
+	}
+	usage.Tokens = int64(tokens)
+	if usage.USD, err = redisNumber(fields[1]); err != nil {
+		return Usage{}, err
+	}
+	return usage, nil
+}
+
+// Add adds usage under key.
+func (s *RedisStore) Add(ctx context.Context, key string, usage Usage, ttl time.Duration) error {
+	_, err := s.client.Eval(ctx, redisAddScript, []string{s.prefix + key},
+		usage.Tokens, strconv.FormatFloat(usage.USD, 'f', -1, 64), ttl.Milliseconds())
+	return err
+}
+
+// redisNumber converts a hash field from a script reply; missing fields
+// are nil.
+func redisNumber(v any) (float64, error) {
+	switch n := v.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return float64(n), nil
+	case string:
+		return strconv.ParseFloat(n, 64)
+	case []byte:
+		return strconv.ParseFloat(string(n), 64)
+	}
+	return 0, fmt.Errorf("unexpected redis value %v", v)
+}
@@ -0,0 +1,106 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeRedis interprets the RedisStore scripts against maps, replying like
+// go-redis: strings for hash fields and nil for missing ones.
+type fakeRedis struct {
+	hashes  map[string]map[string]string
+	expires map[string]int64
+	err     error
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{hashes: make(map[string]map[string]string), expires: make(map[string]int64)}
+}
+
+func (r *fakeRedis) Eval(_ context.Context, script string, keys []string, args ...any) (any, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	hash := r.hashes[keys[0]]
+	switch script {
+	case redisGetScript:
+		reply := []any{nil, nil}
+		for i, field := range []string{"tokens", "usd"} {
+			if v, ok := hash[field]; ok {
+				reply[i] = v
+			}
+		}
+		return reply, nil
+	case redisAddScript:
+		if hash == nil {
+			hash = make(map[string]string)
+			r.hashes[keys[0]] = hash
+		}
+		tokens, _ := strconv.ParseInt(hash["tokens"], 10, 64)
+		usd, _ := strconv.ParseFloat(hash["usd"], 64)
+		added, _ := strconv.ParseFloat(args[1].(string), 64)
+		hash["tokens"] = strconv.FormatInt(tokens+args[0].(int64), 10)
+		hash["usd"] = strconv.FormatFloat(usd+added, 'f', -1, 64)
+		if ttl := args[2].(int64); ttl > r.expires[keys[0]] {
+			r.expires[keys[0]] = ttl
+		}
+		return int64(1), nil
+	}
+	return nil, errors.New("unknown script")
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	s := NewRedisStore(redis, "")
+
+	if usage, err := s.Get(ctx, "acme:daily:2026-01-01"); err != nil || usage != (Usage{}) {
+		t.Errorf("Expected zero usage, got %+v, %v", usage, err)
+	}
+	if err := s.Add(ctx, "acme:daily:2026-01-01", Usage{Tokens: 100, USD: 0.25}, time.Hour); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := s.Add(ctx, "acme:daily:2026-01-01", Usage{Tokens: 50, USD: 0.5}, time.Minute); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	usage, err := s.Get(ctx, "acme:daily:2026-01-01")
+	if err != nil || usage != (Usage{Tokens: 150, USD: 0.75}) {
+		t.Errorf("Expected the sums, got %+v, %v", usage, err)
+	}
+	if ttl := redis.expires[DefaultRedisPrefix+"acme:daily:2026-01-01"]; ttl != time.Hour.Milliseconds() {
+		t.Errorf("Expected the prefixed key to expire after an hour, got %d", ttl)
+	}
+
+	redis.err = errors.New("connection reset")
+	if _, err := s.Get(ctx, "acme:daily:2026-01-01"); !errors.Is(err, redis.err) {
+		t.Errorf("Expected the client error, got %v", err)
+	}
+}
+
+func TestRedisNumber(t *testing.T) {
+	for _, test := range []struct {
+		in   any
+		want float64
+	}{
+		{nil, 0}, {int64(3), 3}, {"1.5", 1.5}, {[]byte("2"), 2},
+	} {
+		if got, err := redisNumber(test.in); err != nil || got != test.want {
+			t.Errorf("redisNumber(%v) = %v, %v; want %v", test.in, got, err, test.want)
+		}
+	}
+	if _, err := redisNumber(true); err == nil {
+		t.Error("Expected an error for an unexpected value")
+	}
+	if _, err := NewRedisStore(&badReply{}, "x:").Get(context.Background(), "k"); err == nil {
+		t.Error("Expected an error for an unexpected reply")
+	}
+}
+
+type badReply struct{}
+
+func (badReply) Eval(context.Context, string, []string, ...any) (any, error) {
+	return "OK", nil
+}
@@ -0,0 +1,74 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps usage counters by key. Clients share limits by sharing a
+// Store; implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the usage under key, or zero usage if there is none.
+	Get(ctx context.Context, key string) (Usage, error)
+	// Add adds usage to the counters under key atomically and keeps them
+	// for at least ttl.
+	Add(ctx context.Context, key string, usage Usage, ttl time.Duration) error
+}
+
+// MemoryStore is a Store for the clients of one process.
+type MemoryStore struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	usage   Usage
+	expires time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{now: time.Now, entries: make(map[string]memoryEntry)}
+}
+
+// Get returns the usage under key.
+func (s *MemoryStore) Get(_ context.Context, key string) (Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || !s.now().Before(entry.expires) {
+		return Usage{}, nil
+	}
+	return entry.usage, nil
+}
+
+// Add adds usage under key. Expired counters are dropped as new ones are
+// added.
+func (s *MemoryStore) Add(_ context.Context, key string, usage Usage, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		s.purge(now)
+		entry = memoryEntry{}
+	}
+	entry.usage.Tokens += usage.Tokens
+	entry.usage.USD += usage.USD
+	if expires := now.Add(ttl); expires.After(entry.expires) {
+		entry.expires = expires
+	}
+	s.entries[key] = entry
+	return nil
+}
+
+// purge drops expired counters. Must be called with s.mu held.
+func (s *MemoryStore) purge(now time.Time) {
+	for key, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
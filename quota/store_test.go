@@ -0,0 +1,44 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+
+	if usage, err := s.Get(ctx, "missing"); err != nil || usage != (Usage{}) {
+		t.Errorf("Expected zero usage, got %+v, %v", usage, err)
+	}
+
+	_ = s.Add(ctx, "a", Usage{Tokens: 10, USD: 0.5}, time.Hour)
+	_ = s.Add(ctx, "a", Usage{Tokens: 5, USD: 0.25}, time.Minute)
+	_ = s.Add(ctx, "b", Usage{Tokens: 1}, time.Minute)
+	if usage, _ := s.Get(ctx, "a"); usage != (Usage{Tokens: 15, USD: 0.75}) {
+		t.Errorf("Expected the sums, got %+v", usage)
+	}
+
+	// A shorter TTL does not shorten the expiry
+	now = now.Add(30 * time.Minute)
+	if usage, _ := s.Get(ctx, "a"); usage.Tokens != 15 {
+		t.Errorf("Expected the counter to be kept, got %+v", usage)
+	}
+	if usage, _ := s.Get(ctx, "b"); usage != (Usage{}) {
+		t.Errorf("Expected the counter to expire, got %+v", usage)
+	}
+
+	// Expired counters start over and are purged
+	now = now.Add(time.Hour)
+	_ = s.Add(ctx, "a", Usage{Tokens: 1}, time.Hour)
+	if usage, _ := s.Get(ctx, "a"); usage.Tokens != 1 {
+		t.Errorf("Expected an expired counter to start over, got %+v", usage)
+	}
+	if len(s.entries) != 1 {
+		t.Errorf("Expected expired counters purged, got %d", len(s.entries))
+	}
+}
@@ -0,0 +1,196 @@
+// Package redact keeps secrets out of what an agent sees and out of logs
+// and stored transcripts.
+//
+// A Redactor finds secrets with detectors: AWS keys, JWTs, private keys,
+// GitHub and Anthropic tokens, and custom patterns. It replaces them in two
+// ways. Redact swaps each secret for a numbered placeholder and remembers
+// it, so Restore can put it back, for example in a tool input before the
+// tool runs. Mask replaces secrets for good, for anything written to logs
+// or disk:
+//
+//	redactor := redact.New()
+//	client := claudecode.NewClient(
+//		claudecode.WithDebugWriter(os.Stderr),
+//		redact.WithRedaction(redactor), // after the debug options it wraps
+//	)
+//	// ...
+//	md := transcript.ExportMarkdown(redactor.MaskMessages(messages))
+//
+// Placeholders look like [REDACTED:aws_access_key:1]; masks like
+// [REDACTED:aws_access_key]. The same secret always gets the same
+// placeholder from a Redactor.
+//
+// A ResultFilter applies a Redactor to what tools return, masking or
+// withholding file contents and command output that hold secrets:
+//
+//	filter := redactor.ResultFilter(redact.ResultMask)
+//	client := claudecode.NewClient(redact.WithResultFilter(filter))
+//	client.(*claudecode.ClientImpl).GetPermissionManager().SetPermissionCallback(filter.PermissionCallback(nil))
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Detector finds one kind of secret. When Pattern has a capture group,
+// only the first group is replaced, so context such as a variable name can
+// be matched without being redacted.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Built-in detectors.
+var (
+	AWSAccessKey = Detector{
+		Name:    "aws_access_key",
+		Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	}
+	AWSSecretKey = Detector{
+		Name:    "aws_secret_key",
+		Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b`),
+	}
+	JWT = Detector{
+		Name:    "jwt",
+		Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	}
+	PrivateKey = Detector{
+		Name:    "private_key",
+		Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	}
+	GitHubToken = Detector{
+		Name:    "github_token",
+		Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`),
+	}
+	AnthropicAPIKey = Detector{
+		Name:    "anthropic_api_key",
+		Pattern: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`),
+	}
+)
+
+// DefaultDetectors returns the built-in detectors.
+func DefaultDetectors() []Detector {
+	return []Detector{PrivateKey, AWSAccessKey, AWSSecretKey, JWT, GitHubToken, AnthropicAPIKey}
+}
+
+// NewDetector returns a detector for a custom pattern. The name appears in
+// placeholders, so it should be short and contain no brackets or colons.
+func NewDetector(name, pattern string) (Detector, error) {
+	if name == "" || strings.ContainsAny(name, "[]:") {
+		return Detector{}, fmt.Errorf("invalid detector name %q", name)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Detector{}, fmt.Errorf("detector %s: %w", name, err)
+	}
+	return Detector{Name: name, Pattern: re}, nil
+}
+
+// placeholderPattern matches the placeholders Redact produces.
+var placeholderPattern = regexp.MustCompile(`\[REDACTED:[^\[\]:]+:\d+\]`)
+
+// Redactor replaces secrets found by its detectors. It is safe for
+// concurrent use.
+type Redactor struct {
+	detectors []Detector
+
+	mu       sync.Mutex
+	secrets  map[string]string // placeholder to secret
+	replaced map[string]string // secret to placeholder
+	counts   map[string]int    // placeholders per detector
+}
+
+// New returns a Redactor using detectors, or DefaultDetectors when none are
+// given. Combine them to add custom detectors to the built-in ones:
+//
+//	redact.New(append(redact.DefaultDetectors(), internalToken)...)
+func New(detectors ...Detector) *Redactor {
+	if len(detectors) == 0 {
+		detectors = DefaultDetectors()
+	}
+	return &Redactor{
+		detectors: detectors,
+		secrets:   make(map[string]string),
+		replaced:  make(map[string]string),
+		counts:    make(map[string]int),
+	}
+}
+
+// Redact replaces each secret in s with a placeholder that Restore turns
+// back into the secret.
+func (r *Redactor) Redact(s string) string {
+	return r.replace(s, func(detector, secret string) string {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if placeholder, ok := r.replaced[secret]; ok {
+			return placeholder
+		}
+		r.counts[detector]++
+		placeholder := "[REDACTED:" + detector + ":" + strconv.Itoa(r.counts[detector]) + "]"
+		r.secrets[placeholder] = secret
+		r.replaced[secret] = placeholder
+		return placeholder
+	})
+}
+
+// Restore replaces the placeholders Redact produced with their secrets.
+// Placeholders it did not produce are left alone.
+func (r *Redactor) Restore(s string) string {
+	if !strings.Contains(s, "[REDACTED:") {
+		return s
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return placeholderPattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+		if secret, ok := r.secrets[placeholder]; ok {
+			return secret
+		}
+		return placeholder
+	})
+}
+
+// Mask replaces each secret in s, and each placeholder from Redact, with a
+// mask that cannot be restored.
+func (r *Redactor) Mask(s string) string {
+	s = r.replace(s, func(detector, _ string) string {
+		return "[REDACTED:" + detector + "]"
+	})
+	if !strings.Contains(s, "[REDACTED:") {
+		return s
+	}
+	return placeholderPattern.ReplaceAllStringFunc(s, func(placeholder string) string {
+		name := strings.TrimPrefix(placeholder, "[REDACTED:")
+		name = name[:strings.LastIndex(name, ":")]
+		return "[REDACTED:" + name + "]"
+	})
+}
+
+// replace runs each detector over s, replacing the secrets it finds with
+// the result of with.
+func (r *Redactor) replace(s string, with func(detector, secret string) string) string {
+	for _, d := range r.detectors {
+		matches := d.Pattern.FindAllStringSubmatchIndex(s, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			b.WriteString(s[last:start])
+			b.WriteString(with(d.Name, s[start:end]))
+			last = end
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	return s
+}
@@ -0,0 +1,304 @@
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// ResultAction is what a ResultFilter does with a tool result in which it
+// finds secrets.
+type ResultAction string
+
+const (
+	// ResultMask masks the secrets and keeps the rest of the result.
+	ResultMask ResultAction = "mask"
+	// ResultBlock replaces the whole result with a note saying why it was
+	// withheld.
+	ResultBlock ResultAction = "block"
+)
+
+// defaultReadLimit is the number of lines the Read tool returns when its
+// call sets no limit.
+const defaultReadLimit = 2000
+
+// Finding is a tool result in which a ResultFilter found secrets.
+type Finding struct {
+	// ToolUseID is empty for results seen by the hook, whose input does
+	// not carry it.
+	ToolUseID string
+	ToolName  string
+	// Detectors names the detectors that matched.
+	Detectors []string
+	Action    ResultAction
+	Time      time.Time
+}
+
+// ResultFilter scans tool results, such as file contents and command
+// output, for the secrets its Redactor detects, and masks or blocks them.
+//
+// The CLI runs tools itself, so results reach the model before the client
+// sees them. The filter stops secrets at three points:
+//
+//   - PermissionCallback reads the files the Read and NotebookRead tools
+//     are about to read. When they hold secrets, it denies the call and
+//     gives the model the masked content, or the note for a blocked
+//     result, as the denial message. This is the only point that keeps
+//     secrets from the model.
+//   - Hook filters PostToolUse results, for sessions whose hooks run.
+//   - WithResultFilter filters the tool results the client delivers, so
+//     secrets in command output or search matches stay out of the
+//     application and its stored transcripts.
+//
+// It is safe for concurrent use.
+type ResultFilter struct {
+	redactor *Redactor
+	action   ResultAction
+	now      func() time.Time
+
+	mu       sync.Mutex
+	findings []Finding
+	tools    map[string]string // tool use ID to tool name, for results
+}
+
+// ResultFilter returns a filter applying action to tool results in which r
+// finds secrets.
+func (r *Redactor) ResultFilter(action ResultAction) *ResultFilter {
+	return &ResultFilter{
+		redactor: r,
+		action:   action,
+		now:      time.Now,
+		tools:    make(map[string]string),
+	}
+}
+
+// Findings returns the results in which secrets were found, oldest first.
+func (f *ResultFilter) Findings() []Finding {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Finding(nil), f.findings...)
+}
+
+// Filter returns output with its secrets masked, or the note replacing a
+// blocked result, and the names of the detectors that matched. Output
+// without secrets is returned unchanged.
+func (f *ResultFilter) Filter(toolName, output string) (string, []string) {
+	detectors := f.redactor.detect(output)
+	if len(detectors) == 0 {
+		return output, nil
+	}
+	if f.action == ResultBlock {
+		return withheld(toolName, detectors), detectors
+	}
+	return f.redactor.Mask(output), detectors
+}
+
+// FilterValue is Filter for structured results, such as decoded JSON. A
+// blocked value is replaced by the note, a string.
+func (f *ResultFilter) FilterValue(toolName string, v any) (any, []string) {
+	var detectors []string
+	mapStrings(v, func(s string) string {
+		detectors = appendNew(detectors, f.redactor.detect(s)...)
+		return s
+	})
+	if len(detectors) == 0 {
+		return v, nil
+	}
+	if f.action == ResultBlock {
+		return withheld(toolName, detectors), detectors
+	}
+	return f.redactor.MaskValue(v), detectors
+}
+
+// PermissionCallback returns a permission callback that screens the files
+// the Read and NotebookRead tools are about to read. A file holding secrets
+// is not read: the call is denied with the masked lines the tool would have
+// returned, or with a note that the file was withheld. next, if not nil,
+// decides everything else; otherwise it is allowed.
+//
+// Files the callback cannot read itself are left to the tool.
+func (f *ResultFilter) PermissionCallback(next claudecode.CanUseToolFunc) claudecode.CanUseToolFunc {
+	return func(ctx context.Context, toolName string, input map[string]any, permContext claudecode.ToolPermissionContext) (claudecode.PermissionResult, error) {
+		if message, ok := f.screenFile(toolName, input); ok {
+			return claudecode.NewPermissionResultDeny(message), nil
+		}
+		if next == nil {
+			return claudecode.NewPermissionResultAllow(), nil
+		}
+		return next(ctx, toolName, input, permContext)
+	}
+}
+
+// screenFile reads the file a Read or NotebookRead call names and, if it
+// holds secrets, returns the message to give the model instead.
+func (f *ResultFilter) screenFile(toolName string, input map[string]any) (string, bool) {
+	var path string
+	switch toolName {
+	case "Read":
+		path, _ = input["file_path"].(string)
+	case "NotebookRead":
+		path, _ = input["notebook_path"].(string)
+	default:
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	content := string(data)
+	detectors := f.redactor.detect(content)
+	if len(detectors) == 0 {
+		return "", false
+	}
+	f.record("", toolName, detectors)
+	if f.action == ResultBlock || toolName != "Read" {
+		return withheld(toolName, detectors), true
+	}
+	offset, _ := input["offset"].(float64)
+	limit, _ := input["limit"].(float64)
+	return fmt.Sprintf("%s contains secrets (%s), which were masked. Its content:\n%s",
+		path, strings.Join(detectors, ", "), numberLines(f.redactor.Mask(content), int(offset), int(limit))), true
+}
+
+// Hook returns a PostToolUse hook that stops tool results holding secrets,
+// with the masked result, or the note for a blocked one, as its message.
+// Other events are ignored.
+func (f *ResultFilter) Hook() claudecode.HookCallback {
+	return func(_ context.Context, input interface{}, _ claudecode.HookContext) (claudecode.HookOutput, error) {
+		var post claudecode.PostToolUseHookInput
+		switch in := input.(type) {
+		case claudecode.PostToolUseHookInput:
+			post = in
+		case *claudecode.PostToolUseHookInput:
+			post = *in
+		default:
+			return claudecode.HookOutput{Behavior: claudecode.HookBehaviorContinue}, nil
+		}
+		filtered, detectors := f.FilterValue(post.ToolName, post.ToolResponse)
+		if len(detectors) == 0 {
+			return claudecode.HookOutput{Behavior: claudecode.HookBehaviorContinue}, nil
+		}
+		f.record("", post.ToolName, detectors)
+		message, ok := filtered.(string)
+		if !ok {
+			encoded, err := json.Marshal(filtered)
+			if err != nil {
+				return claudecode.HookOutput{}, fmt.Errorf("encoding filtered %s result: %w", post.ToolName, err)
+			}
+			message = string(encoded)
+		}
+		return claudecode.HookOutput{Behavior: claudecode.HookBehaviorStop, Message: message}, nil
+	}
+}
+
+// WithResultFilter filters the tool results in the messages the client
+// delivers, changing them in place. Like other message observers it
+// applies to the Client and not to the Query function, and it must come
+// before options that keep messages, such as stored sessions, for them to
+// keep the filtered results.
+func WithResultFilter(f *ResultFilter) claudecode.Option {
+	return claudecode.WithMessageObserver(f.observe)
+}
+
+// observe remembers which tool each tool use calls and filters the results
+// of those calls.
+func (f *ResultFilter) observe(msg claudecode.Message) {
+	switch m := msg.(type) {
+	case *claudecode.AssistantMessage:
+		for _, block := range m.Content {
+			if use, ok := block.(*claudecode.ToolUseBlock); ok {
+				f.mu.Lock()
+				f.tools[use.ToolUseID] = use.Name
+				f.mu.Unlock()
+			}
+		}
+	case *claudecode.UserMessage:
+		blocks, _ := m.Content.([]claudecode.ContentBlock)
+		for _, block := range blocks {
+			result, ok := block.(*claudecode.ToolResultBlock)
+			if !ok {
+				continue
+			}
+			f.mu.Lock()
+			toolName := f.tools[result.ToolUseID]
+			delete(f.tools, result.ToolUseID)
+			f.mu.Unlock()
+			filtered, detectors := f.FilterValue(toolName, result.Content)
+			if len(detectors) > 0 {
+				result.Content = filtered
+				f.record(result.ToolUseID, toolName, detectors)
+			}
+		}
+	}
+}
+
+func (f *ResultFilter) record(toolUseID, toolName string, detectors []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.findings = append(f.findings, Finding{
+		ToolUseID: toolUseID,
+		ToolName:  toolName,
+		Detectors: detectors,
+		Action:    f.action,
+		Time:      f.now(),
+	})
+}
+
+// detect returns the names of the detectors that match s.
+func (r *Redactor) detect(s string) []string {
+	var names []string
+	for _, d := range r.detectors {
+		if d.Pattern.MatchString(s) {
+			names = appendNew(names, d.Name)
+		}
+	}
+	return names
+}
+
+// withheld is the note replacing a blocked result.
+func withheld(toolName string, detectors []string) string {
+	if toolName == "" {
+		toolName = "tool"
+	}
+	return fmt.Sprintf("[%s result withheld: it contains secrets (%s)]", toolName, strings.Join(detectors, ", "))
+}
+
+// numberLines formats the lines of content the Read tool would return,
+// from the 1-based line offset and at most limit lines, numbered as it
+// numbers them.
+func numberLines(content string, offset, limit int) string {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if offset < 1 {
+		offset = 1
+	}
+	if limit <= 0 {
+		limit = defaultReadLimit
+	}
+	var b strings.Builder
+	for i := offset - 1; i < len(lines) && i < offset-1+limit; i++ {
+		fmt.Fprintf(&b, "%6d\t%s\n", i+1, lines[i])
+	}
+	return b.String()
+}
+
+func appendNew(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, existing := range list {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
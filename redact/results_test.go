@@ -0,0 +1,157 @@
+package redact
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestResultFilterFilter(t *testing.T) {
+	output := "AWS_ACCESS_KEY_ID=" + testAccessKey + "\nGITHUB_TOKEN=" + testGitHub + "\n"
+
+	masked, detectors := New().ResultFilter(ResultMask).Filter("Bash", output)
+	if masked != "AWS_ACCESS_KEY_ID=[REDACTED:aws_access_key]\nGITHUB_TOKEN=[REDACTED:github_token]\n" {
+		t.Errorf("Expected the secrets masked, got %q", masked)
+	}
+	if strings.Join(detectors, ",") != "aws_access_key,github_token" {
+		t.Errorf("Unexpected detectors: %v", detectors)
+	}
+
+	blocked, _ := New().ResultFilter(ResultBlock).Filter("Bash", output)
+	if strings.Contains(blocked, "AWS_ACCESS_KEY_ID") || !strings.Contains(blocked, "Bash result withheld") {
+		t.Errorf("Expected the result withheld, got %q", blocked)
+	}
+
+	clean, detectors := New().ResultFilter(ResultBlock).Filter("Bash", "PASS\nok")
+	if clean != "PASS\nok" || detectors != nil {
+		t.Errorf("Expected a clean result unchanged, got %q, %v", clean, detectors)
+	}
+}
+
+func TestResultFilterFilterValue(t *testing.T) {
+	filter := New().ResultFilter(ResultMask)
+	value := map[string]any{"stdout": "token " + testJWT, "exit_code": float64(0)}
+
+	filtered, detectors := filter.FilterValue("Bash", value)
+	out, ok := filtered.(map[string]any)
+	if !ok || out["stdout"] != "token [REDACTED:jwt]" || out["exit_code"] != float64(0) {
+		t.Errorf("Expected the structured result masked, got %#v", filtered)
+	}
+	if len(detectors) != 1 || value["stdout"] != "token "+testJWT {
+		t.Errorf("Expected one detector and the original untouched, got %v, %v", detectors, value)
+	}
+}
+
+func TestResultFilterPermissionCallback(t *testing.T) {
+	dir := t.TempDir()
+	secrets := filepath.Join(dir, ".env")
+	if err := os.WriteFile(secrets, []byte("DEBUG=1\nAWS_KEY="+testAccessKey+"\nPORT=80\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	clean := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(clean, []byte("package main\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	filter := New().ResultFilter(ResultMask)
+	callback := filter.PermissionCallback(nil)
+	result, err := callback(ctx, "Read", map[string]any{"file_path": secrets, "offset": float64(2), "limit": float64(1)}, claudecode.ToolPermissionContext{})
+	if err != nil {
+		t.Fatalf("Callback failed: %v", err)
+	}
+	deny, ok := result.(*claudecode.PermissionResultDeny)
+	if !ok {
+		t.Fatalf("Expected the read to be denied, got %#v", result)
+	}
+	if message := deny.Message(); strings.Contains(message, testAccessKey) ||
+		!strings.HasSuffix(message, "     2\tAWS_KEY=[REDACTED:aws_access_key]\n") || strings.Contains(message, "PORT") {
+		t.Errorf("Expected the requested lines masked in the message, got %q", message)
+	}
+	if findings := filter.Findings(); len(findings) != 1 || findings[0].ToolName != "Read" || findings[0].Action != ResultMask {
+		t.Errorf("Expected the finding recorded, got %+v", findings)
+	}
+
+	for _, call := range []struct {
+		tool  string
+		input map[string]any
+	}{
+		{"Read", map[string]any{"file_path": clean}},
+		{"Read", map[string]any{"file_path": filepath.Join(dir, "missing")}},
+		{"Bash", map[string]any{"command": "cat " + secrets}},
+	} {
+		result, _ := callback(ctx, call.tool, call.input, claudecode.ToolPermissionContext{})
+		if _, ok := result.(*claudecode.PermissionResultAllow); !ok {
+			t.Errorf("Expected %s %v to be allowed, got %#v", call.tool, call.input, result)
+		}
+	}
+
+	blocking := New().ResultFilter(ResultBlock).PermissionCallback(nil)
+	result, _ = blocking(ctx, "Read", map[string]any{"file_path": secrets}, claudecode.ToolPermissionContext{})
+	if deny, ok := result.(*claudecode.PermissionResultDeny); !ok || strings.Contains(deny.Message(), "DEBUG") {
+		t.Errorf("Expected the file withheld, got %#v", result)
+	}
+}
+
+func TestResultFilterHook(t *testing.T) {
+	filter := New().ResultFilter(ResultBlock)
+	hook := filter.Hook()
+	ctx := context.Background()
+
+	output, err := hook(ctx, &claudecode.PostToolUseHookInput{
+		ToolName:     "Bash",
+		ToolResponse: map[string]any{"stdout": testPEM},
+	}, claudecode.HookContext{})
+	if err != nil {
+		t.Fatalf("Hook failed: %v", err)
+	}
+	if output.Behavior != claudecode.HookBehaviorStop || !strings.Contains(output.Message, "private_key") || strings.Contains(output.Message, "MIIE") {
+		t.Errorf("Expected the hook to withhold the result, got %+v", output)
+	}
+
+	for _, input := range []interface{}{
+		claudecode.PostToolUseHookInput{ToolName: "Bash", ToolResponse: "ok"},
+		claudecode.PreToolUseHookInput{ToolName: "Bash", ToolInput: map[string]any{"command": testAccessKey}},
+	} {
+		output, err := hook(ctx, input, claudecode.HookContext{})
+		if err != nil || output.Behavior != claudecode.HookBehaviorContinue {
+			t.Errorf("Expected %T to continue, got %+v, %v", input, output, err)
+		}
+	}
+
+	output, _ = New().ResultFilter(ResultMask).Hook()(ctx, claudecode.PostToolUseHookInput{
+		ToolName:     "Grep",
+		ToolResponse: map[string]any{"content": "config.go:3: key = " + testAnthropic},
+	}, claudecode.HookContext{})
+	if output.Message != `{"content":"config.go:3: key = [REDACTED:anthropic_api_key]"}` {
+		t.Errorf("Expected the masked result as the message, got %q", output.Message)
+	}
+}
+
+func TestWithResultFilter(t *testing.T) {
+	filter := New().ResultFilter(ResultMask)
+	options := claudecode.NewOptions(WithResultFilter(filter))
+	if len(options.MessageObservers) != 1 {
+		t.Fatalf("Expected a message observer, got %d", len(options.MessageObservers))
+	}
+	observe := options.MessageObservers[0]
+
+	observe(&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+		&claudecode.ToolUseBlock{ToolUseID: "toolu_1", Name: "Bash", Input: map[string]any{"command": "env"}},
+	}})
+	result := &claudecode.ToolResultBlock{ToolUseID: "toolu_1", Content: "HOME=/root\nKEY=" + testAccessKey}
+	clean := &claudecode.ToolResultBlock{ToolUseID: "toolu_2", Content: "ok"}
+	observe(&claudecode.UserMessage{Content: []claudecode.ContentBlock{result, clean}})
+
+	if result.Content != "HOME=/root\nKEY=[REDACTED:aws_access_key]" || clean.Content != "ok" {
+		t.Errorf("Expected the delivered result masked, got %v, %v", result.Content, clean.Content)
+	}
+	findings := filter.Findings()
+	if len(findings) != 1 || findings[0].ToolUseID != "toolu_1" || findings[0].ToolName != "Bash" {
+		t.Errorf("Expected the finding recorded with its tool, got %+v", findings)
+	}
+}
@@ -0,0 +1,90 @@
+package redact
+
+import (
+	"context"
+	"io"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// WithRedaction redacts secrets from outgoing prompts and masks them in the
+// client's debug output. It wraps the DebugWriter and StderrCallback set so
+// far, so it must come after WithDebugWriter and WithStderrCallback.
+//
+// Tools given a redacted prompt see placeholders; install PermissionCallback
+// on the client's permission manager to restore them in tool inputs.
+func WithRedaction(r *Redactor) claudecode.Option {
+	return func(o *claudecode.Options) {
+		o.PromptInterceptors = append(o.PromptInterceptors, r.PromptInterceptor())
+		if o.DebugWriter != nil {
+			o.DebugWriter = r.Writer(o.DebugWriter)
+		}
+		if callback := o.StderrCallback; callback != nil {
+			o.StderrCallback = func(record claudecode.DebugRecord) {
+				record.Message = r.Mask(record.Message)
+				record.Raw = r.Mask(record.Raw)
+				callback(record)
+			}
+		}
+	}
+}
+
+// PromptInterceptor returns an interceptor that replaces secrets in
+// prompts with placeholders, in text and in text blocks.
+func (r *Redactor) PromptInterceptor() claudecode.PromptInterceptor {
+	return func(_ context.Context, msg *claudecode.UserMessage) error {
+		switch content := msg.Content.(type) {
+		case string:
+			msg.Content = r.Redact(content)
+		case []claudecode.ContentBlock:
+			for _, block := range content {
+				if text, ok := block.(*claudecode.TextBlock); ok {
+					text.Text = r.Redact(text.Text)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// PermissionCallback returns a permission callback that restores secrets in
+// tool inputs before the tool runs. next, if not nil, decides with the
+// restored input; otherwise the tool is allowed.
+//
+//	client.(*claudecode.ClientImpl).GetPermissionManager().SetPermissionCallback(redactor.PermissionCallback(nil))
+func (r *Redactor) PermissionCallback(next claudecode.CanUseToolFunc) claudecode.CanUseToolFunc {
+	return func(ctx context.Context, toolName string, input map[string]any, permContext claudecode.ToolPermissionContext) (claudecode.PermissionResult, error) {
+		restored, _ := r.RestoreValue(input).(map[string]any)
+		if next == nil {
+			return claudecode.NewPermissionResultAllow().WithInput(restored), nil
+		}
+		result, err := next(ctx, toolName, restored, permContext)
+		if err != nil {
+			return result, err
+		}
+		if allow, ok := result.(*claudecode.PermissionResultAllow); ok && allow.UpdatedInput() == nil {
+			return allow.WithInput(restored), nil
+		}
+		return result, nil
+	}
+}
+
+// Writer returns a writer that masks secrets in each write before passing
+// it to w. A secret split across two writes is not detected, so w should
+// be written whole lines, as the CLI's stderr is.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &maskingWriter{r: r, w: w}
+}
+
+type maskingWriter struct {
+	r *Redactor
+	w io.Writer
+}
+
+func (mw *maskingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(mw.w, mw.r.Mask(string(p))); err != nil {
+		return 0, err
+	}
+	// Report the caller's bytes as written, since masking changes the length
+	return len(p), nil
+}
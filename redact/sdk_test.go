@@ -0,0 +1,93 @@
+package redact
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestWithRedaction(t *testing.T) {
+	r := New()
+	var buf bytes.Buffer
+	var records []claudecode.DebugRecord
+	options := claudecode.NewOptions(
+		claudecode.WithDebugWriter(&buf),
+		claudecode.WithStderrCallback(func(record claudecode.DebugRecord) { records = append(records, record) }),
+		WithRedaction(r),
+	)
+
+	n, err := options.DebugWriter.Write([]byte("key " + testAccessKey + "\n"))
+	if err != nil || n != len("key "+testAccessKey+"\n") {
+		t.Fatalf("Expected the full write reported, got %d, %v", n, err)
+	}
+	if got := buf.String(); got != "key [REDACTED:aws_access_key]\n" {
+		t.Errorf("Expected the debug output masked, got %q", got)
+	}
+
+	options.StderrCallback(claudecode.DebugRecord{Message: testJWT, Raw: "[DEBUG] " + testJWT})
+	if len(records) != 1 || records[0].Message != "[REDACTED:jwt]" || records[0].Raw != "[DEBUG] [REDACTED:jwt]" {
+		t.Errorf("Expected the stderr record masked, got %+v", records)
+	}
+
+	if len(options.PromptInterceptors) != 1 {
+		t.Fatalf("Expected a prompt interceptor, got %d", len(options.PromptInterceptors))
+	}
+	msg := &claudecode.UserMessage{Content: "use " + testAccessKey}
+	if err := options.PromptInterceptors[0](context.Background(), msg); err != nil {
+		t.Fatalf("Interceptor failed: %v", err)
+	}
+	if msg.Content != "use [REDACTED:aws_access_key:1]" {
+		t.Errorf("Expected the prompt redacted, got %v", msg.Content)
+	}
+}
+
+func TestWithRedactionWithoutSinks(t *testing.T) {
+	options := claudecode.NewOptions(WithRedaction(New()))
+	if options.DebugWriter != nil || options.StderrCallback != nil {
+		t.Error("Expected no sinks to be created")
+	}
+}
+
+func TestPromptInterceptorBlocks(t *testing.T) {
+	r := New()
+	text := &claudecode.TextBlock{Text: "token " + testGitHub}
+	msg := &claudecode.UserMessage{Content: []claudecode.ContentBlock{text}}
+	if err := r.PromptInterceptor()(context.Background(), msg); err != nil {
+		t.Fatalf("Interceptor failed: %v", err)
+	}
+	if text.Text != "token [REDACTED:github_token:1]" {
+		t.Errorf("Expected the text block redacted, got %q", text.Text)
+	}
+}
+
+func TestPermissionCallback(t *testing.T) {
+	r := New()
+	input := map[string]any{"command": r.Redact("aws configure set key " + testAccessKey)}
+	want := "aws configure set key " + testAccessKey
+
+	result, err := r.PermissionCallback(nil)(context.Background(), "Bash", input, claudecode.ToolPermissionContext{})
+	if err != nil {
+		t.Fatalf("Callback failed: %v", err)
+	}
+	if result.Behavior() != claudecode.PermissionBehaviorAllow || result.UpdatedInput()["command"] != want {
+		t.Errorf("Expected an allow with the secret restored, got %v %v", result.Behavior(), result.UpdatedInput())
+	}
+
+	var seen map[string]any
+	next := func(_ context.Context, _ string, input map[string]any, _ claudecode.ToolPermissionContext) (claudecode.PermissionResult, error) {
+		seen = input
+		return claudecode.NewPermissionResultDeny("no"), nil
+	}
+	result, err = r.PermissionCallback(next)(context.Background(), "Bash", input, claudecode.ToolPermissionContext{})
+	if err != nil {
+		t.Fatalf("Callback failed: %v", err)
+	}
+	if seen["command"] != want {
+		t.Errorf("Expected next to see the restored input, got %v", seen)
+	}
+	if result.Behavior() != claudecode.PermissionBehaviorDeny {
+		t.Errorf("Expected next's decision, got %v", result.Behavior())
+	}
+}
@@ -0,0 +1,157 @@
+package redact
+
+import (
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// RedactValue returns a copy of v with secrets in its strings replaced by
+// placeholders. Maps and slices are copied; other values are returned as
+// they are.
+func (r *Redactor) RedactValue(v any) any {
+	return mapStrings(v, r.Redact)
+}
+
+// RestoreValue returns a copy of v with placeholders in its strings
+// replaced by their secrets.
+func (r *Redactor) RestoreValue(v any) any {
+	return mapStrings(v, r.Restore)
+}
+
+// MaskValue returns a copy of v with secrets and placeholders in its
+// strings masked.
+func (r *Redactor) MaskValue(v any) any {
+	return mapStrings(v, r.Mask)
+}
+
+// mapStrings applies fn to each string in v, which is typically decoded
+// JSON. Image blocks are left alone: their base64 data can match detectors
+// by chance and is too large to scan.
+func mapStrings(v any, fn func(string) string) any {
+	switch v := v.(type) {
+	case string:
+		return fn(v)
+	case map[string]any:
+		if v == nil {
+			return v
+		}
+		if v["type"] == "image" {
+			return v
+		}
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			out[key] = mapStrings(value, fn)
+		}
+		return out
+	case []any:
+		if v == nil {
+			return v
+		}
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = mapStrings(value, fn)
+		}
+		return out
+	case []string:
+		if v == nil {
+			return v
+		}
+		out := make([]string, len(v))
+		for i, value := range v {
+			out[i] = fn(value)
+		}
+		return out
+	}
+	return v
+}
+
+// MaskMessages returns copies of messages with secrets masked, for writing
+// transcripts or logs. The messages themselves are not changed.
+func (r *Redactor) MaskMessages(messages []claudecode.Message) []claudecode.Message {
+	masked := make([]claudecode.Message, len(messages))
+	for i, msg := range messages {
+		masked[i] = r.MaskMessage(msg)
+	}
+	return masked
+}
+
+// MaskMessage returns a copy of msg with secrets masked. Message types it
+// does not know are returned unchanged.
+func (r *Redactor) MaskMessage(msg claudecode.Message) claudecode.Message {
+	switch m := msg.(type) {
+	case *claudecode.UserMessage:
+		out := &claudecode.UserMessage{
+			MessageType:     m.MessageType,
+			UUID:            m.UUID,
+			ParentToolUseID: m.ParentToolUseID,
+		}
+		switch content := m.Content.(type) {
+		case string:
+			out.Content = r.Mask(content)
+		case []claudecode.ContentBlock:
+			out.Content = r.maskBlocks(content)
+		default:
+			out.Content = content
+		}
+		return out
+	case *claudecode.AssistantMessage:
+		return &claudecode.AssistantMessage{
+			MessageType:     m.MessageType,
+			Content:         r.maskBlocks(m.Content),
+			Model:           m.Model,
+			Error:           m.Error,
+			ParentToolUseID: m.ParentToolUseID,
+		}
+	case *claudecode.SystemMessage:
+		out := *m
+		out.Data, _ = r.MaskValue(m.Data).(map[string]any)
+		return &out
+	case *claudecode.ResultMessage:
+		out := *m
+		if m.Result != nil {
+			result := r.Mask(*m.Result)
+			out.Result = &result
+		}
+		out.StructuredOutput = r.MaskValue(m.StructuredOutput)
+		if m.PermissionDenials != nil {
+			out.PermissionDenials = make([]claudecode.PermissionDenial, len(m.PermissionDenials))
+			for i, denial := range m.PermissionDenials {
+				denial.ToolInput, _ = r.MaskValue(denial.ToolInput).(map[string]any)
+				out.PermissionDenials[i] = denial
+			}
+		}
+		return &out
+	}
+	return msg
+}
+
+func (r *Redactor) maskBlocks(blocks []claudecode.ContentBlock) []claudecode.ContentBlock {
+	if blocks == nil {
+		return nil
+	}
+	out := make([]claudecode.ContentBlock, len(blocks))
+	for i, block := range blocks {
+		out[i] = r.maskBlock(block)
+	}
+	return out
+}
+
+func (r *Redactor) maskBlock(block claudecode.ContentBlock) claudecode.ContentBlock {
+	switch b := block.(type) {
+	case *claudecode.TextBlock:
+		return &claudecode.TextBlock{MessageType: b.MessageType, Text: r.Mask(b.Text)}
+	case *claudecode.ThinkingBlock:
+		// The signature covers the original thinking, so it is kept as is
+		return &claudecode.ThinkingBlock{MessageType: b.MessageType, Thinking: r.Mask(b.Thinking), Signature: b.Signature}
+	case *claudecode.ToolUseBlock:
+		input, _ := r.MaskValue(b.Input).(map[string]any)
+		return &claudecode.ToolUseBlock{MessageType: b.MessageType, ToolUseID: b.ToolUseID, Name: b.Name, Input: input}
+	case *claudecode.ToolResultBlock:
+		return &claudecode.ToolResultBlock{
+			MessageType: b.MessageType,
+			ToolUseID:   b.ToolUseID,
+			Content:     r.MaskValue(b.Content),
+			IsError:     b.IsError,
+		}
+	}
+	return block
+}
@@ -0,0 +1,84 @@
+package redact
+
+import (
+	"reflect"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestRedactValue(t *testing.T) {
+	r := New()
+	input := map[string]any{
+		"command": "export KEY=" + testAccessKey,
+		"args":    []any{testGitHub, 3},
+		"image":   map[string]any{"type": "image", "data": testAccessKey},
+	}
+
+	redacted := r.RedactValue(input).(map[string]any)
+	if redacted["command"] != "export KEY=[REDACTED:aws_access_key:1]" {
+		t.Errorf("Expected the command redacted, got %v", redacted["command"])
+	}
+	if args := redacted["args"].([]any); args[0] != "[REDACTED:github_token:1]" || args[1] != 3 {
+		t.Errorf("Expected the args redacted, got %v", args)
+	}
+	if image := redacted["image"].(map[string]any); image["data"] != testAccessKey {
+		t.Errorf("Expected image blocks left alone, got %v", image)
+	}
+	if input["command"] != "export KEY="+testAccessKey {
+		t.Error("Expected the input not to be modified")
+	}
+	if restored := r.RestoreValue(redacted); !reflect.DeepEqual(restored, input) {
+		t.Errorf("Expected %v restored, got %v", input, restored)
+	}
+}
+
+func TestMaskMessages(t *testing.T) {
+	r := New()
+	result := "done with " + testAccessKey
+	messages := []claudecode.Message{
+		&claudecode.UserMessage{Content: "deploy with " + r.Redact(testAccessKey)},
+		&claudecode.AssistantMessage{Model: "claude-test", Content: []claudecode.ContentBlock{
+			&claudecode.TextBlock{Text: "Using " + testAccessKey},
+			&claudecode.ThinkingBlock{Thinking: testJWT, Signature: "sig"},
+			&claudecode.ToolUseBlock{ToolUseID: "t1", Name: "Bash", Input: map[string]any{"command": "echo " + testGitHub}},
+		}},
+		&claudecode.UserMessage{Content: []claudecode.ContentBlock{
+			&claudecode.ToolResultBlock{ToolUseID: "t1", Content: testGitHub},
+		}},
+		&claudecode.ResultMessage{Subtype: "success", Result: &result},
+	}
+
+	masked := r.MaskMessages(messages)
+
+	if got := masked[0].(*claudecode.UserMessage).Content; got != "deploy with [REDACTED:aws_access_key]" {
+		t.Errorf("Expected the prompt placeholder masked, got %v", got)
+	}
+	assistant := masked[1].(*claudecode.AssistantMessage)
+	if assistant.Model != "claude-test" {
+		t.Errorf("Expected the model kept, got %q", assistant.Model)
+	}
+	if got := assistant.Content[0].(*claudecode.TextBlock).Text; got != "Using [REDACTED:aws_access_key]" {
+		t.Errorf("Expected the text masked, got %q", got)
+	}
+	if thinking := assistant.Content[1].(*claudecode.ThinkingBlock); thinking.Thinking != "[REDACTED:jwt]" || thinking.Signature != "sig" {
+		t.Errorf("Expected the thinking masked and signature kept, got %+v", thinking)
+	}
+	if got := assistant.Content[2].(*claudecode.ToolUseBlock).Input["command"]; got != "echo [REDACTED:github_token]" {
+		t.Errorf("Expected the tool input masked, got %v", got)
+	}
+	toolResult := masked[2].(*claudecode.UserMessage).Content.([]claudecode.ContentBlock)[0].(*claudecode.ToolResultBlock)
+	if toolResult.Content != "[REDACTED:github_token]" || toolResult.ToolUseID != "t1" {
+		t.Errorf("Expected the tool result masked, got %+v", toolResult)
+	}
+	if got := *masked[3].(*claudecode.ResultMessage).Result; got != "done with [REDACTED:aws_access_key]" {
+		t.Errorf("Expected the result masked, got %q", got)
+	}
+
+	if got := messages[1].(*claudecode.AssistantMessage).Content[0].(*claudecode.TextBlock).Text; got != "Using "+testAccessKey {
+		t.Errorf("Expected the original messages unchanged, got %q", got)
+	}
+	if result != "done with "+testAccessKey {
+		t.Error("Expected the original result unchanged")
+	}
+}
@@ -0,0 +1,434 @@
+// Package term renders the SDK's message stream for interactive command
+// line apps: the agent's text, a spinner while tools run, a line per
+// finished tool call, collapsed thinking and a closing cost summary.
+//
+//	if err := client.Query(ctx, prompt); err != nil {
+//		return err
+//	}
+//	r := term.New(os.Stdout)
+//	result, err := r.Run(ctx, client.ReceiveMessages(ctx))
+//
+// Colors and the spinner are enabled when the writer is a terminal, and
+// colors are left out when the NO_COLOR environment variable is set. A
+// Renderer draws one turn after another and is safe for concurrent use.
+package term
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+	"github.com/severity1/claude-code-sdk-go/uistream"
+)
+
+// DefaultSpinnerInterval is how often the spinner advances.
+const DefaultSpinnerInterval = 100 * time.Millisecond
+
+// ErrNoResult is returned by Run when the message stream ends before the
+// turn's result.
+var ErrNoResult = errors.New("message stream ended without a result")
+
+// spinnerFrames are drawn in turn while tools run.
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// ANSI escape sequences.
+const (
+	ansiReset     = "\033[0m"
+	ansiBold      = "\033[1m"
+	ansiDim       = "\033[2m"
+	ansiRed       = "\033[31m"
+	ansiGreen     = "\033[32m"
+	ansiCyan      = "\033[36m"
+	ansiClearLine = "\r\033[K"
+)
+
+// Option configures a Renderer.
+type Option func(*config)
+
+type config struct {
+	color           bool
+	spinner         bool
+	spinnerInterval time.Duration
+	thinking        bool
+	summaryLength   int
+	clock           claudecode.Clock
+}
+
+// WithColor turns colors on or off, overriding the terminal detection.
+func WithColor(enabled bool) Option {
+	return func(c *config) {
+		c.color = enabled
+	}
+}
+
+// WithSpinner turns the tool spinner on or off, overriding the terminal
+// detection. Without it, tools are only shown once they finish.
+func WithSpinner(enabled bool) Option {
+	return func(c *config) {
+		c.spinner = enabled
+	}
+}
+
+// WithSpinnerInterval sets how often the spinner advances.
+func WithSpinnerInterval(d time.Duration) Option {
+	return func(c *config) {
+		if d > 0 {
+			c.spinnerInterval = d
+		}
+	}
+}
+
+// WithThinking expands thinking blocks. By default each is collapsed to a
+// single line giving its length.
+func WithThinking(expanded bool) Option {
+	return func(c *config) {
+		c.thinking = expanded
+	}
+}
+
+// WithSummaryLength sets the rune limit of the output summary shown for
+// each finished tool call.
+func WithSummaryLength(n int) Option {
+	return func(c *config) {
+		c.summaryLength = n
+	}
+}
+
+// WithClock sets the clock timing tools and driving the spinner, for
+// tests.
+func WithClock(clock claudecode.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// Renderer draws messages to a terminal.
+type Renderer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	config config
+	mapper *uistream.Mapper
+	// Tools awaiting their results, in the order they started
+	running []runningTool
+	frame   int
+	// Whether the spinner line is on screen and must be cleared first
+	spinning bool
+	last     outputKind
+}
+
+type runningTool struct {
+	id      string
+	label   string
+	started time.Time
+}
+
+// outputKind tells what was written last, to separate paragraphs.
+type outputKind int
+
+const (
+	outputNone outputKind = iota
+	outputText
+	outputStatus
+)
+
+// New returns a Renderer writing to w.
+func New(w io.Writer, opts ...Option) *Renderer {
+	tty := isTerminal(w)
+	c := config{
+		color:           tty && os.Getenv("NO_COLOR") == "",
+		spinner:         tty,
+		spinnerInterval: DefaultSpinnerInterval,
+		summaryLength:   uistream.DefaultSummaryLength,
+		clock:           claudecode.SystemClock{},
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return &Renderer{
+		w:      w,
+		config: c,
+		mapper: uistream.NewMapper(uistream.WithSummaryLength(c.summaryLength)),
+	}
+}
+
+// Run renders msgs until the turn's result, which it returns. It returns
+// ErrNoResult when msgs is closed first, and ctx.Err() when ctx is done.
+// The spinner only moves while Run is running.
+func (r *Renderer) Run(ctx context.Context, msgs <-chan claudecode.Message) (*claudecode.ResultMessage, error) {
+	defer r.clearSpinner()
+
+	var timer claudecode.Timer
+	var tick <-chan time.Time
+	if r.config.spinner {
+		timer = r.config.clock.NewTimer(r.config.spinnerInterval)
+		tick = timer.C()
+		defer func() { timer.Stop() }()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-tick:
+			if err := r.tick(); err != nil {
+				return nil, err
+			}
+			timer = r.config.clock.NewTimer(r.config.spinnerInterval)
+			tick = timer.C()
+		case msg, ok := <-msgs:
+			if !ok {
+				return nil, ErrNoResult
+			}
+			if err := r.Render(msg); err != nil {
+				return nil, err
+			}
+			if result, ok := msg.(*claudecode.ResultMessage); ok {
+				return result, nil
+			}
+		}
+	}
+}
+
+// Render draws one message.
+func (r *Renderer) Render(msg claudecode.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var b strings.Builder
+	r.erase(&b)
+	if assistant, ok := msg.(*claudecode.AssistantMessage); ok && assistant.ParentToolUseID == nil {
+		for _, block := range assistant.Content {
+			if thinking, ok := block.(*claudecode.ThinkingBlock); ok && strings.TrimSpace(thinking.Thinking) != "" {
+				r.thinking(&b, thinking.Thinking)
+			}
+		}
+	}
+	for _, ev := range r.mapper.Map(msg) {
+		switch ev := ev.(type) {
+		case uistream.AssistantTextAppended:
+			r.text(&b, ev.Text)
+		case uistream.ToolStarted:
+			r.running = append(r.running, runningTool{
+				id:      ev.ID,
+				label:   toolLabel(ev.Name, ev.Args),
+				started: r.config.clock.Now(),
+			})
+		case uistream.ToolFinished:
+			r.toolFinished(&b, ev)
+		}
+	}
+	if result, ok := msg.(*claudecode.ResultMessage); ok {
+		r.running = nil
+		r.summary(&b, result)
+	}
+	r.drawSpinner(&b)
+	_, err := io.WriteString(r.w, b.String())
+	return err
+}
+
+// tick advances the spinner.
+func (r *Renderer) tick() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.running) == 0 {
+		return nil
+	}
+	r.frame = (r.frame + 1) % len(spinnerFrames)
+	var b strings.Builder
+	r.erase(&b)
+	r.drawSpinner(&b)
+	_, err := io.WriteString(r.w, b.String())
+	return err
+}
+
+// clearSpinner removes the spinner line when Run returns.
+func (r *Renderer) clearSpinner() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var b strings.Builder
+	r.erase(&b)
+	if b.Len() > 0 {
+		_, _ = io.WriteString(r.w, b.String())
+	}
+}
+
+// erase removes the spinner line so that other output can take its place.
+func (r *Renderer) erase(b *strings.Builder) {
+	if r.spinning {
+		b.WriteString(ansiClearLine)
+		r.spinning = false
+	}
+}
+
+// drawSpinner draws the spinner line for the running tools, leaving the
+// cursor on it.
+func (r *Renderer) drawSpinner(b *strings.Builder) {
+	if !r.config.spinner || len(r.running) == 0 {
+		return
+	}
+	oldest := r.running[0]
+	label := oldest.label
+	if n := len(r.running); n > 1 {
+		label = fmt.Sprintf("%s (+%d more)", label, n-1)
+	}
+	elapsed := r.config.clock.Now().Sub(oldest.started)
+	b.WriteString(r.style(ansiCyan, spinnerFrames[r.frame]))
+	b.WriteString(" " + label + " ")
+	b.WriteString(r.style(ansiDim, formatDuration(elapsed)))
+	r.spinning = true
+}
+
+func (r *Renderer) text(b *strings.Builder, text string) {
+	r.separate(b, outputText)
+	b.WriteString(strings.TrimRight(text, "\n"))
+	b.WriteString("\n")
+}
+
+func (r *Renderer) thinking(b *strings.Builder, text string) {
+	r.separate(b, outputStatus)
+	if !r.config.thinking {
+		words := len(strings.Fields(text))
+		b.WriteString(r.style(ansiDim, fmt.Sprintf("▸ Thinking (%d words)", words)))
+		b.WriteString("\n")
+		return
+	}
+	b.WriteString(r.style(ansiDim, "▾ Thinking"))
+	b.WriteString("\n")
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		b.WriteString(r.style(ansiDim, "  │ "+line))
+		b.WriteString("\n")
+	}
+}
+
+func (r *Renderer) toolFinished(b *strings.Builder, ev uistream.ToolFinished) {
+	label := ev.Name
+	var elapsed time.Duration
+	for i, tool := range r.running {
+		if tool.id == ev.ID {
+			label = tool.label
+			elapsed = r.config.clock.Now().Sub(tool.started)
+			r.running = append(r.running[:i], r.running[i+1:]...)
+			break
+		}
+	}
+	if label == "" {
+		label = "tool"
+	}
+
+	r.separate(b, outputStatus)
+	if ev.OK {
+		b.WriteString(r.style(ansiGreen, "✓"))
+	} else {
+		b.WriteString(r.style(ansiRed, "✗"))
+	}
+	b.WriteString(" " + label)
+	if elapsed > 0 {
+		b.WriteString(" " + r.style(ansiDim, formatDuration(elapsed)))
+	}
+	if ev.Summary != "" {
+		b.WriteString(r.style(ansiDim, " · "+ev.Summary))
+	}
+	b.WriteString("\n")
+}
+
+// summary writes the line closing a turn: its outcome, length and cost.
+func (r *Renderer) summary(b *strings.Builder, result *claudecode.ResultMessage) {
+	parts := []string{fmt.Sprintf("%d turns", result.NumTurns)}
+	if result.NumTurns == 1 {
+		parts[0] = "1 turn"
+	}
+	parts = append(parts, formatDuration(time.Duration(result.DurationMs)*time.Millisecond))
+	if result.TotalCostUSD != nil {
+		parts = append(parts, fmt.Sprintf("$%.4f", *result.TotalCostUSD))
+	}
+	if usage := result.Usage; usage != nil {
+		parts = append(parts, fmt.Sprintf("%s in / %s out tokens",
+			formatCount(usage.TotalInputTokens()), formatCount(usage.OutputTokens)))
+	}
+
+	r.separate(b, outputNone)
+	if result.IsError {
+		b.WriteString(r.style(ansiBold+ansiRed, "✗ Failed ("+result.Subtype+")"))
+	} else {
+		b.WriteString(r.style(ansiBold+ansiGreen, "✓ Done"))
+	}
+	b.WriteString(r.style(ansiDim, " · "+strings.Join(parts, " · ")))
+	b.WriteString("\n")
+	// Whatever follows starts a new paragraph
+	r.last = outputText
+}
+
+// separate writes a blank line between paragraphs: before and after text,
+// and between turns. Consecutive status lines are kept together.
+func (r *Renderer) separate(b *strings.Builder, kind outputKind) {
+	if r.last != outputNone && (kind != outputStatus || r.last != outputStatus) {
+		b.WriteString("\n")
+	}
+	r.last = kind
+}
+
+// style wraps s in an ANSI style when colors are enabled.
+func (r *Renderer) style(code, s string) string {
+	if !r.config.color {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// argumentKeys are the input fields shown next to a tool's name, by
+// preference.
+var argumentKeys = []string{"command", "file_path", "notebook_path", "path", "pattern", "url", "query", "description"}
+
+// toolLabel names a tool call by its tool and main argument, such as
+// "Bash go test ./...".
+func toolLabel(name string, args map[string]any) string {
+	for _, key := range argumentKeys {
+		if arg, ok := args[key].(string); ok && arg != "" {
+			if i := strings.IndexByte(arg, '\n'); i >= 0 {
+				arg = arg[:i] + "…"
+			}
+			return name + " " + arg
+		}
+	}
+	return name
+}
+
+// formatDuration rounds d for display: 350ms, 2.4s, 1m5s.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+// formatCount shortens token counts: 950, 12.3k, 1.2M.
+func formatCount(n int) string {
+	switch {
+	case n < 1000:
+		return fmt.Sprintf("%d", n)
+	case n < 1000000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%.1fM", float64(n)/1000000)
+	}
+}
+
+// isTerminal reports whether w is a character device, such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,202 @@
+package term
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+	"github.com/severity1/claude-code-sdk-go/claudetest/fakeclock"
+)
+
+func TestRenderTurn(t *testing.T) {
+	clock := fakeclock.New(time.Unix(1700000000, 0))
+	var out bytes.Buffer
+	r := New(&out, WithClock(clock))
+
+	failed := true
+	cost := 0.0123
+	render := func(msg claudecode.Message) {
+		t.Helper()
+		if err := r.Render(msg); err != nil {
+			t.Fatalf("Render failed: %v", err)
+		}
+	}
+	render(&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+		&claudecode.ThinkingBlock{Thinking: "The tests live in the root package."},
+		&claudecode.TextBlock{Text: "Running the tests."},
+		&claudecode.ToolUseBlock{ToolUseID: "tool-1", Name: "Bash", Input: map[string]any{"command": "go test ./..."}},
+		&claudecode.ToolUseBlock{ToolUseID: "tool-2", Name: "Read", Input: map[string]any{"file_path": "missing.go"}},
+	}})
+	clock.Advance(2400 * time.Millisecond)
+	render(&claudecode.UserMessage{Content: []claudecode.ContentBlock{
+		&claudecode.ToolResultBlock{ToolUseID: "tool-1", Content: "ok  \tgithub.com/acme/app\t0.2s"},
+		&claudecode.ToolResultBlock{ToolUseID: "tool-2", IsError: &failed, Content: "File does not exist."},
+	}})
+	render(&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+		&claudecode.TextBlock{Text: "All tests pass."},
+	}})
+	render(&claudecode.ResultMessage{
+		Subtype:      "success",
+		NumTurns:     2,
+		DurationMs:   3500,
+		TotalCostUSD: &cost,
+		Usage:        &claudecode.Usage{InputTokens: 1200, CacheRead: 300, OutputTokens: 85},
+	})
+
+	expect := strings.Join([]string{
+		"▸ Thinking (7 words)",
+		"",
+		"Running the tests.",
+		"",
+		"✓ Bash go test ./... 2.4s · ok  \tgithub.com/acme/app\t0.2s",
+		"✗ Read missing.go 2.4s · File does not exist.",
+		"",
+		"All tests pass.",
+		"",
+		"✓ Done · 2 turns · 3.5s · $0.0123 · 1.5k in / 85 out tokens",
+		"",
+	}, "\n")
+	if out.String() != expect {
+		t.Errorf("Expected output:\n%s\ngot:\n%s", expect, out.String())
+	}
+}
+
+func TestRenderExpandedThinking(t *testing.T) {
+	var out bytes.Buffer
+	r := New(&out, WithThinking(true))
+	err := r.Render(&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+		&claudecode.ThinkingBlock{Thinking: "First idea.\nSecond idea."},
+	}})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	expect := "▾ Thinking\n  │ First idea.\n  │ Second idea.\n"
+	if out.String() != expect {
+		t.Errorf("Expected %q, got %q", expect, out.String())
+	}
+}
+
+func TestRenderFailedResultInColor(t *testing.T) {
+	var out bytes.Buffer
+	r := New(&out, WithColor(true))
+	err := r.Render(&claudecode.ResultMessage{Subtype: "error_max_turns", IsError: true, NumTurns: 1, DurationMs: 250})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	expect := ansiBold + ansiRed + "✗ Failed (error_max_turns)" + ansiReset +
+		ansiDim + " · 1 turn · 250ms" + ansiReset + "\n"
+	if out.String() != expect {
+		t.Errorf("Expected %q, got %q", expect, out.String())
+	}
+}
+
+func TestRunSpinner(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clock := fakeclock.New(time.Unix(1700000000, 0))
+	out := &syncBuffer{}
+	r := New(out, WithSpinner(true), WithClock(clock), WithSpinnerInterval(time.Second))
+
+	msgs := make(chan claudecode.Message)
+	type outcome struct {
+		result *claudecode.ResultMessage
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := r.Run(ctx, msgs)
+		done <- outcome{result, err}
+	}()
+
+	msgs <- &claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+		&claudecode.ToolUseBlock{ToolUseID: "tool-1", Name: "Bash", Input: map[string]any{"command": "make"}},
+	}}
+	clock.BlockUntilTimers(1)
+	clock.Advance(time.Second)
+	waitForOutput(ctx, t, out, "⠙ Bash make 1s")
+
+	msgs <- &claudecode.UserMessage{Content: []claudecode.ContentBlock{
+		&claudecode.ToolResultBlock{ToolUseID: "tool-1", Content: "built"},
+	}}
+	msgs <- &claudecode.ResultMessage{Subtype: "success", NumTurns: 1, DurationMs: 1000}
+
+	got := <-done
+	if got.err != nil || got.result == nil {
+		t.Fatalf("Expected the result, got %v, %v", got.result, got.err)
+	}
+	expect := "⠋ Bash make 0s" + ansiClearLine + "⠙ Bash make 1s" + ansiClearLine +
+		"✓ Bash make 1s · built\n\n✓ Done · 1 turn · 1s\n"
+	if out.String() != expect {
+		t.Errorf("Expected %q, got %q", expect, out.String())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msgs := make(chan claudecode.Message)
+	close(msgs)
+	if _, err := New(&bytes.Buffer{}).Run(ctx, msgs); !errors.Is(err, ErrNoResult) {
+		t.Errorf("Expected ErrNoResult, got %v", err)
+	}
+
+	canceled, cancelRun := context.WithCancel(ctx)
+	cancelRun()
+	if _, err := New(&bytes.Buffer{}).Run(canceled, make(chan claudecode.Message)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestToolLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   map[string]any
+		expect string
+	}{
+		{"Bash", map[string]any{"command": "go build\ngo test", "description": "Build"}, "Bash go build…"},
+		{"Grep", map[string]any{"pattern": "TODO"}, "Grep TODO"},
+		{"mcp__db__query", map[string]any{"sql": "select 1"}, "mcp__db__query"},
+	}
+	for _, test := range tests {
+		if got := toolLabel(test.name, test.args); got != test.expect {
+			t.Errorf("toolLabel(%q) = %q, expected %q", test.name, got, test.expect)
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the renderer and the test to use
+// at once.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func waitForOutput(ctx context.Context, t *testing.T, out *syncBuffer, want string) {
+	t.Helper()
+	for !strings.Contains(out.String(), want) {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("Timed out waiting for %q, got %q", want, out.String())
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,380 @@
+// Package review asks the agent to review a diff and returns its findings
+// as data, for CI bots that comment on pull requests or fail builds.
+//
+// The agent is prompted with the diff and the rules to check, and must
+// answer with JSON matching a strict schema. The answer is validated: JSON
+// wrapped in prose or a code fence, trailing commas and paths with git's
+// a/ and b/ prefixes are repaired locally, and findings that still do not
+// validate are sent back to the agent to correct:
+//
+//	findings, err := review.Diff(ctx, diff, review.Rules{
+//		{ID: "no-panic", Description: "Library code must return errors instead of panicking", Severity: review.SeverityError},
+//		{ID: "doc", Description: "Exported identifiers need doc comments", Severity: review.SeverityWarning},
+//	})
+//	for _, f := range findings {
+//		fmt.Printf("%s:%d: %s: %s\n", f.File, f.Line, f.Severity, f.Message)
+//	}
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// DefaultRepairAttempts is how many times invalid findings are sent back to
+// the agent to correct when Reviewer.RepairAttempts is zero.
+const DefaultRepairAttempts = 1
+
+// ErrInvalidFindings is returned when the agent's findings do not validate
+// after all repair attempts.
+var ErrInvalidFindings = errors.New("invalid review findings")
+
+// Severity ranks a finding.
+type Severity string
+
+// Severities, from most to least severe.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// rank orders severities, most severe first; unknown severities rank last.
+func (s Severity) rank() int {
+	switch s {
+	case SeverityError:
+		return 0
+	case SeverityWarning:
+		return 1
+	case SeverityInfo:
+		return 2
+	}
+	return 3
+}
+
+// Rule is a check the agent applies to the diff.
+type Rule struct {
+	// ID names the rule in findings; it may be empty for free-form
+	// guidance.
+	ID          string
+	Description string
+	// Severity is the severity suggested for violations. The agent may
+	// choose another.
+	Severity Severity
+}
+
+// Rules are the checks of a review. Without rules, the agent looks for
+// bugs, security problems and unclear code.
+type Rules []Rule
+
+// Finding is a problem the agent found in the diff.
+type Finding struct {
+	// File is the path of the file as named in the diff, without git's
+	// a/ or b/ prefix.
+	File string `json:"file"`
+	// Line is the line in the new version of the file, or 0 for a finding
+	// about the whole file.
+	Line     int      `json:"line"`
+	Severity Severity `json:"severity"`
+	// Rule is the ID of the violated rule, if any.
+	Rule    string `json:"rule,omitempty"`
+	Message string `json:"message"`
+	// Patch is a suggested fix as a unified diff, if the agent has one.
+	Patch string `json:"suggested_patch,omitempty"`
+}
+
+// Reviewer reviews diffs. The zero value is ready to use.
+type Reviewer struct {
+	// Options are passed to every query. The output schema and read-only
+	// tools are added after them.
+	Options []claudecode.Option
+	// RepairAttempts is how many times invalid findings are sent back to
+	// the agent; the default is DefaultRepairAttempts and a negative value
+	// disables repair.
+	RepairAttempts int
+	// Query runs the queries; the default is claudecode.Query.
+	Query claudecode.QueryFunc
+}
+
+// Diff reviews a unified diff against rules with a default Reviewer using
+// opts.
+func Diff(ctx context.Context, diff string, rules Rules, opts ...claudecode.Option) ([]Finding, error) {
+	reviewer := &Reviewer{Options: opts}
+	return reviewer.Diff(ctx, diff, rules)
+}
+
+// Diff reviews a unified diff against rules. Findings are sorted by file,
+// line and severity.
+func (r *Reviewer) Diff(ctx context.Context, diff string, rules Rules) ([]Finding, error) {
+	if strings.TrimSpace(diff) == "" {
+		return nil, fmt.Errorf("diff is empty")
+	}
+	files := diffFiles(diff)
+
+	attempts := r.RepairAttempts
+	switch {
+	case attempts == 0:
+		attempts = DefaultRepairAttempts
+	case attempts < 0:
+		attempts = 0
+	}
+
+	prompt := reviewPrompt(diff, rules)
+	var resume []claudecode.Option
+	for attempt := 0; ; attempt++ {
+		result, text, err := r.query(ctx, prompt, resume)
+		if err != nil {
+			return nil, err
+		}
+		findings, problems := parseFindings(result.StructuredOutput, text, files)
+		if len(problems) == 0 {
+			sortFindings(findings)
+			return findings, nil
+		}
+		if attempt >= attempts {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFindings, strings.Join(problems, "; "))
+		}
+
+		// Continue the session, so the agent keeps the diff in mind
+		prompt = repairPrompt(text, problems)
+		resume = nil
+		if result.SessionID != "" {
+			resume = []claudecode.Option{claudecode.WithResume(result.SessionID)}
+		}
+	}
+}
+
+// query runs one query to its end, returning its result and text.
+func (r *Reviewer) query(ctx context.Context, prompt string, extra []claudecode.Option) (*claudecode.ResultMessage, string, error) {
+	opts := append(append([]claudecode.Option(nil), r.Options...), extra...)
+	opts = append(opts, claudecode.WithJSONSchema(findingsSchema()), claudecode.WithReadOnly())
+	result, err := claudecode.RunWithQuery(ctx, r.Query, claudecode.RunSpec{Prompt: prompt, Options: opts})
+	if err != nil {
+		return nil, "", err
+	}
+	if result.Result.IsError {
+		return nil, "", fmt.Errorf("%w: %s", claudecode.ErrFailedResult, result.Result.Subtype)
+	}
+	return result.Result, result.Text, nil
+}
+
+// findingsSchema is the JSON Schema of the agent's answer.
+func findingsSchema() map[string]any {
+	return map[string]any{
+		"type":     "object",
+		"required": []any{"findings"},
+		"properties": map[string]any{
+			"findings": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type":                 "object",
+					"required":             []any{"file", "line", "severity", "message"},
+					"additionalProperties": false,
+					"properties": map[string]any{
+						"file":            map[string]any{"type": "string"},
+						"line":            map[string]any{"type": "integer", "minimum": 0},
+						"severity":        map[string]any{"type": "string", "enum": []any{"error", "warning", "info"}},
+						"rule":            map[string]any{"type": "string"},
+						"message":         map[string]any{"type": "string"},
+						"suggested_patch": map[string]any{"type": "string"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// reviewPrompt asks for a review of diff against rules.
+func reviewPrompt(diff string, rules Rules) string {
+	var sb strings.Builder
+	sb.WriteString("Review the following diff and report problems in the lines it changes.\n")
+	if len(rules) == 0 {
+		sb.WriteString("Look for bugs, security problems and unclear code.\n")
+	} else {
+		sb.WriteString("Check these rules:\n")
+		for _, rule := range rules {
+			sb.WriteString("- ")
+			if rule.ID != "" {
+				fmt.Fprintf(&sb, "[%s] ", rule.ID)
+			}
+			sb.WriteString(rule.Description)
+			if rule.Severity != "" {
+				fmt.Fprintf(&sb, " (severity: %s)", rule.Severity)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	sb.WriteString("\nReply with only a JSON object of the form " +
+		`{"findings": [{"file": "...", "line": 1, "severity": "error", "rule": "...", "message": "...", "suggested_patch": "..."}]}` + ".\n" +
+		"file is the path as named in the diff, line is the line in the new version of the file " +
+		"(0 for the whole file), severity is error, warning or info, rule is the ID of the violated rule " +
+		"if any, and suggested_patch is an optional fix as a unified diff. " +
+		"Reply with an empty findings list if there are no problems.\n\n```diff\n")
+	sb.WriteString(diff)
+	if !strings.HasSuffix(diff, "\n") {
+		sb.WriteString("\n")
+	}
+	sb.WriteString("```")
+	return sb.String()
+}
+
+// repairPrompt sends an invalid answer back to the agent.
+func repairPrompt(text string, problems []string) string {
+	var sb strings.Builder
+	sb.WriteString("Your review could not be used:\n")
+	for _, problem := range problems {
+		fmt.Fprintf(&sb, "- %s\n", problem)
+	}
+	sb.WriteString("\nReply again with only the corrected JSON object.")
+	if text != "" {
+		sb.WriteString("\n\nYour reply was:\n")
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+// reviewAnswer is the shape of the agent's answer.
+type reviewAnswer struct {
+	Findings []Finding `json:"findings"`
+}
+
+// parseFindings decodes and validates the agent's answer: the structured
+// output when the CLI returned one, otherwise the result text. It returns
+// the problems that need the agent to repair the answer.
+func parseFindings(structured any, text string, files map[string]bool) ([]Finding, []string) {
+	var answer reviewAnswer
+	if structured != nil {
+		data, err := json.Marshal(structured)
+		if err != nil {
+			return nil, []string{err.Error()}
+		}
+		if err := json.Unmarshal(data, &answer); err != nil {
+			return nil, []string{fmt.Sprintf("invalid JSON: %v", err)}
+		}
+	} else if err := decodeAnswer(text, &answer); err != nil {
+		return nil, []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+
+	var problems []string
+	for i := range answer.Findings {
+		for _, problem := range repairFinding(&answer.Findings[i], files) {
+			problems = append(problems, fmt.Sprintf("finding %d: %s", i+1, problem))
+		}
+	}
+	return answer.Findings, problems
+}
+
+// trailingComma matches a comma before a closing bracket.
+var trailingComma = regexp.MustCompile(`,\s*([}\]])`)
+
+// decodeAnswer decodes text as the answer, repairing common defects: prose
+// or a code fence around the JSON, trailing commas, and a bare array of
+// findings.
+func decodeAnswer(text string, answer *reviewAnswer) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return errors.New("empty reply")
+	}
+	candidates := []string{text}
+	if start := strings.IndexAny(text, "{["); start >= 0 {
+		end := strings.LastIndexAny(text, "}]")
+		if end > start {
+			extracted := text[start : end+1]
+			candidates = append(candidates, extracted, trailingComma.ReplaceAllString(extracted, "$1"))
+		}
+	}
+
+	var firstErr error
+	for _, candidate := range candidates {
+		var err error
+		if strings.HasPrefix(candidate, "[") {
+			err = json.Unmarshal([]byte(candidate), &answer.Findings)
+		} else {
+			err = json.Unmarshal([]byte(candidate), answer)
+		}
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// repairFinding normalizes f and returns what cannot be repaired. files
+// holds the paths in the diff; when it is empty any file is accepted.
+func repairFinding(f *Finding, files map[string]bool) []string {
+	var problems []string
+	f.File = trimGitPrefix(strings.TrimSpace(f.File))
+	switch {
+	case f.File == "":
+		problems = append(problems, "file is missing")
+	case len(files) > 0 && !files[f.File]:
+		problems = append(problems, fmt.Sprintf("file %s is not in the diff", f.File))
+	}
+	if f.Line < 0 {
+		problems = append(problems, fmt.Sprintf("line %d is negative", f.Line))
+	}
+	f.Severity = Severity(strings.ToLower(strings.TrimSpace(string(f.Severity))))
+	if f.Severity.rank() > SeverityInfo.rank() {
+		problems = append(problems, fmt.Sprintf("severity %q is not error, warning or info", f.Severity))
+	}
+	f.Message = strings.TrimSpace(f.Message)
+	if f.Message == "" {
+		problems = append(problems, "message is missing")
+	}
+	return problems
+}
+
+// diffFiles returns the paths of the files a unified diff changes, read
+// from its ---/+++ header pairs. A removed line starting with "--" is not
+// a header, as no +++ line follows it.
+func diffFiles(diff string) map[string]bool {
+	files := make(map[string]bool)
+	lines := strings.Split(diff, "\n")
+	for i := 0; i+1 < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "--- ") || !strings.HasPrefix(lines[i+1], "+++ ") {
+			continue
+		}
+		for _, header := range lines[i : i+2] {
+			// Some tools append a timestamp after a tab
+			path, _, _ := strings.Cut(header[4:], "\t")
+			path = strings.TrimSpace(path)
+			if path != "" && path != "/dev/null" {
+				files[trimGitPrefix(path)] = true
+			}
+		}
+		i++
+	}
+	return files
+}
+
+// trimGitPrefix removes the a/ or b/ prefix git gives paths in diffs.
+func trimGitPrefix(path string) string {
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		return path[2:]
+	}
+	return path
+}
+
+// sortFindings orders findings by file, line and severity.
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Severity.rank() < b.Severity.rank()
+	})
+}
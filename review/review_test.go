@@ -0,0 +1,222 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+const testDiff = `diff --git a/parse.go b/parse.go
+--- a/parse.go
++++ b/parse.go
+@@ -1,3 +1,4 @@
+ package parse
+-func Parse() {}
++func Parse(s string) int {
++	panic("todo")
++}
+`
+
+func TestReviewerDiff(t *testing.T) {
+	queries := &fakeQueries{replies: []reply{{
+		text: "Here is my review:\n```json\n" +
+			`{"findings": [` +
+			`{"file": "b/parse.go", "line": 0, "severity": "Info", "message": "Consider a doc comment"},` +
+			`{"file": "parse.go", "line": 3, "severity": "error", "rule": "no-panic", "message": "Parse panics", "suggested_patch": "-panic\n+return 0"},` +
+			"]}\n```",
+	}}}
+	reviewer := &Reviewer{Query: queries.query, Options: []claudecode.Option{claudecode.WithModel("opus")}}
+	rules := Rules{{ID: "no-panic", Description: "Library code must not panic", Severity: SeverityError}}
+
+	findings, err := reviewer.Diff(context.Background(), testDiff, rules)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	want := []Finding{
+		{File: "parse.go", Line: 0, Severity: SeverityInfo, Message: "Consider a doc comment"},
+		{File: "parse.go", Line: 3, Severity: SeverityError, Rule: "no-panic", Message: "Parse panics", Patch: "-panic\n+return 0"},
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Errorf("Expected %+v, got %+v", want, findings)
+	}
+
+	prompt := queries.prompts[0]
+	for _, fragment := range []string{"[no-panic] Library code must not panic (severity: error)", "+func Parse(s string) int {"} {
+		if !strings.Contains(prompt, fragment) {
+			t.Errorf("Expected the prompt to contain %q", fragment)
+		}
+	}
+	options := claudecode.NewOptions(queries.options[0]...)
+	if options.Model == nil || *options.Model != "opus" || options.OutputFormat == nil {
+		t.Errorf("Expected the reviewer's options and an output schema, got %+v", options)
+	}
+	if len(options.DisallowedTools) == 0 {
+		t.Error("Expected the review to be read-only")
+	}
+}
+
+func TestReviewerDiffStructuredOutput(t *testing.T) {
+	queries := &fakeQueries{replies: []reply{{structured: map[string]any{
+		"findings": []any{map[string]any{"file": "parse.go", "line": 3, "severity": "warning", "message": "Unhandled case"}},
+	}}}}
+	findings, err := (&Reviewer{Query: queries.query}).Diff(context.Background(), testDiff, nil)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Severity != SeverityWarning || findings[0].Line != 3 {
+		t.Errorf("Expected the structured finding, got %+v", findings)
+	}
+	if !strings.Contains(queries.prompts[0], "Look for bugs") {
+		t.Error("Expected a general review without rules")
+	}
+}
+
+func TestReviewerDiffRepair(t *testing.T) {
+	queries := &fakeQueries{replies: []reply{
+		{text: `{"findings": [{"file": "other.go", "line": 1, "severity": "critical", "message": "Bad"}]}`, sessionID: "session-1"},
+		{text: `{"findings": [{"file": "parse.go", "line": 1, "severity": "error", "message": "Bad"}]}`},
+	}}
+	findings, err := (&Reviewer{Query: queries.query}).Diff(context.Background(), testDiff, nil)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(findings) != 1 || findings[0].File != "parse.go" {
+		t.Errorf("Expected the repaired finding, got %+v", findings)
+	}
+
+	repair := queries.prompts[1]
+	for _, fragment := range []string{"file other.go is not in the diff", `severity "critical"`} {
+		if !strings.Contains(repair, fragment) {
+			t.Errorf("Expected the repair prompt to contain %q, got %q", fragment, repair)
+		}
+	}
+	if options := claudecode.NewOptions(queries.options[1]...); options.Resume == nil || *options.Resume != "session-1" {
+		t.Error("Expected the repair to resume the review session")
+	}
+}
+
+func TestReviewerDiffErrors(t *testing.T) {
+	ctx := context.Background()
+	invalid := reply{text: "I found no problems."}
+
+	tests := []struct {
+		name     string
+		reviewer *Reviewer
+		diff     string
+		want     error
+		queries  int
+	}{
+		{"empty diff", &Reviewer{}, " ", nil, 0},
+		{"invalid after repair", &Reviewer{}, testDiff, ErrInvalidFindings, 2},
+		{"repair disabled", &Reviewer{RepairAttempts: -1}, testDiff, ErrInvalidFindings, 1},
+		{"failed result", &Reviewer{}, testDiff, claudecode.ErrFailedResult, 1},
+		{"no result", &Reviewer{}, testDiff, claudecode.ErrNoResult, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := invalid
+			switch test.want {
+			case claudecode.ErrFailedResult:
+				r.isError = true
+			case claudecode.ErrNoResult:
+				r.noResult = true
+			}
+			queries := &fakeQueries{replies: []reply{r}}
+			test.reviewer.Query = queries.query
+			_, err := test.reviewer.Diff(ctx, test.diff, nil)
+			if err == nil || (test.want != nil && !errors.Is(err, test.want)) {
+				t.Errorf("Expected %v, got %v", test.want, err)
+			}
+			if len(queries.prompts) != test.queries {
+				t.Errorf("Expected %d queries, got %d", test.queries, len(queries.prompts))
+			}
+		})
+	}
+}
+
+func TestDecodeAnswer(t *testing.T) {
+	tests := []struct {
+		text  string
+		count int
+	}{
+		{`{"findings": []}`, 0},
+		{`[{"file": "a.go", "line": 1, "severity": "info", "message": "m"}]`, 1},
+		{"```\n{\"findings\": [{\"file\": \"a.go\", \"message\": \"m\"},]}\n```", 1},
+	}
+	for _, test := range tests {
+		var answer reviewAnswer
+		if err := decodeAnswer(test.text, &answer); err != nil || len(answer.Findings) != test.count {
+			t.Errorf("decodeAnswer(%q) = %+v, %v; want %d findings", test.text, answer, err, test.count)
+		}
+	}
+	if err := decodeAnswer("no JSON here", &reviewAnswer{}); err == nil {
+		t.Error("Expected an error for text without JSON")
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	diff := "--- a/old.go\n+++ /dev/null\n@@ -1 +0,0 @@\n--- removed comment\n--- /dev/null\n+++ b/new.go\t2024-01-01\n"
+	want := map[string]bool{"old.go": true, "new.go": true}
+	if got := diffFiles(diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+type reply struct {
+	text       string
+	structured any
+	sessionID  string
+	isError    bool
+	noResult   bool
+}
+
+// fakeQueries answers queries with replies in order, repeating the last.
+type fakeQueries struct {
+	mu      sync.Mutex
+	replies []reply
+	prompts []string
+	options [][]claudecode.Option
+}
+
+func (f *fakeQueries) query(_ context.Context, prompt string, opts ...claudecode.Option) (claudecode.MessageIterator, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := len(f.prompts)
+	f.prompts = append(f.prompts, prompt)
+	f.options = append(f.options, opts)
+	if n >= len(f.replies) {
+		n = len(f.replies) - 1
+	}
+	r := f.replies[n]
+
+	var messages []claudecode.Message
+	if !r.noResult {
+		text := r.text
+		messages = append(messages, &claudecode.ResultMessage{
+			Subtype: "success", IsError: r.isError, Result: &text, StructuredOutput: r.structured, SessionID: r.sessionID,
+		})
+	}
+	return &sliceIterator{messages: messages}, nil
+}
+
+type sliceIterator struct {
+	messages []claudecode.Message
+}
+
+func (it *sliceIterator) Next(context.Context) (claudecode.Message, error) {
+	if len(it.messages) == 0 {
+		return nil, claudecode.ErrNoMoreMessages
+	}
+	msg := it.messages[0]
+	it.messages = it.messages[1:]
+	return msg, nil
+}
+
+func (it *sliceIterator) Close() error {
+	return nil
+}
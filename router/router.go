@@ -0,0 +1,175 @@
+// Package router picks a model for each request from simple heuristics,
+// so cheap questions go to a small model and hard ones to a capable one.
+//
+// A Router is a claudecode.ModelRouter. Its Rules are checked first, in
+// order; without a matching rule, requests flagged with
+// claudecode.RouteFlagDeep get the Deep model, short prompts without code
+// or tools get the Fast model, and everything else gets the Default model.
+// When little budget remains, flagged requests are treated as ordinary and
+// ordinary ones get the Fast model:
+//
+//	r := &router.Router{
+//		Rules: []router.Rule{
+//			{Name: "review", Model: router.ModelOpus, Match: router.PromptContains("security review")},
+//		},
+//	}
+//	client := claudecode.NewClient(claudecode.WithModelRouter(r), claudecode.WithMaxBudgetUSD(5))
+//
+//	// Routed to haiku
+//	client.Query(ctx, "What does this error mean?")
+//	// Routed to opus
+//	client.Query(ctx, "Redesign the cache layer", claudecode.WithRouteFlags(claudecode.RouteFlagDeep))
+package router
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Model aliases understood by the CLI.
+const (
+	ModelHaiku  = "haiku"
+	ModelSonnet = "sonnet"
+	ModelOpus   = "opus"
+)
+
+// Defaults for Router fields that are not set.
+const (
+	// DefaultShortPromptChars is the longest prompt, in bytes, that counts
+	// as short.
+	DefaultShortPromptChars = 280
+	// DefaultLowBudgetUSD is the remaining budget below which the router
+	// economizes.
+	DefaultLowBudgetUSD = 0.5
+)
+
+// Rule routes the requests it matches to a model.
+type Rule struct {
+	// Name describes the rule, for logs and debugging.
+	Name  string
+	Match func(req claudecode.RouteRequest) bool
+	Model string
+}
+
+// Router is a claudecode.ModelRouter with configurable heuristics. The zero
+// value routes between haiku, sonnet and opus.
+type Router struct {
+	// Rules are checked before the heuristics; the first match wins.
+	Rules []Rule
+
+	// Fast, Default and Deep are the models for short, ordinary and
+	// flagged requests; the defaults are haiku, sonnet and opus.
+	Fast    string
+	Default string
+	Deep    string
+
+	// ShortPromptChars is the longest prompt that counts as short; the
+	// default is DefaultShortPromptChars.
+	ShortPromptChars int
+	// LowBudgetUSD is the remaining budget below which the router avoids
+	// the Deep model and uses the Fast one in place of the Default one;
+	// the default is DefaultLowBudgetUSD.
+	LowBudgetUSD float64
+	// HeavyTools are tools whose presence in the request's allowed tools
+	// keeps it off the Fast model; the default is Bash, Edit, MultiEdit,
+	// Write and NotebookEdit.
+	HeavyTools []string
+}
+
+// defaultHeavyTools are the tools that make a request more than a quick
+// question.
+var defaultHeavyTools = []string{"Bash", "Edit", "MultiEdit", "Write", "NotebookEdit"}
+
+// Route returns the model for req.
+func (r *Router) Route(_ context.Context, req claudecode.RouteRequest) string {
+	for _, rule := range r.Rules {
+		if rule.Match != nil && rule.Match(req) {
+			return rule.Model
+		}
+	}
+
+	lowBudget := req.RemainingBudgetUSD != nil && *req.RemainingBudgetUSD < r.lowBudget()
+	switch {
+	case req.HasFlag(claudecode.RouteFlagDeep) && !lowBudget:
+		return orDefault(r.Deep, ModelOpus)
+	case lowBudget && !req.HasFlag(claudecode.RouteFlagDeep):
+		return orDefault(r.Fast, ModelHaiku)
+	case r.isShort(req):
+		return orDefault(r.Fast, ModelHaiku)
+	default:
+		return orDefault(r.Default, ModelSonnet)
+	}
+}
+
+// isShort reports whether req is a quick question: a short prompt without
+// code and without heavy tools.
+func (r *Router) isShort(req claudecode.RouteRequest) bool {
+	limit := r.ShortPromptChars
+	if limit <= 0 {
+		limit = DefaultShortPromptChars
+	}
+	if len(req.Prompt) > limit || ContainsCode(req.Prompt) {
+		return false
+	}
+	heavy := r.HeavyTools
+	if heavy == nil {
+		heavy = defaultHeavyTools
+	}
+	for _, tool := range req.Tools {
+		name, _, _ := strings.Cut(tool, "(")
+		for _, h := range heavy {
+			if name == h {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (r *Router) lowBudget() float64 {
+	if r.LowBudgetUSD > 0 {
+		return r.LowBudgetUSD
+	}
+	return DefaultLowBudgetUSD
+}
+
+func orDefault(model, fallback string) string {
+	if model != "" {
+		return model
+	}
+	return fallback
+}
+
+// codePattern matches code fences and lines that look like source code.
+var codePattern = regexp.MustCompile("```|(?m)^\\s*(func|def|class|import|package|return|#include|public|private)\\b|[;{}]\\s*$")
+
+// ContainsCode reports whether text looks like it contains source code: a
+// Markdown code fence, a line starting with a common keyword, or a line
+// ending in a brace or semicolon.
+func ContainsCode(text string) bool {
+	return codePattern.MatchString(text)
+}
+
+// PromptContains returns a Rule matcher for prompts containing any of the
+// substrings, ignoring case.
+func PromptContains(substrings ...string) func(claudecode.RouteRequest) bool {
+	return func(req claudecode.RouteRequest) bool {
+		prompt := strings.ToLower(req.Prompt)
+		for _, s := range substrings {
+			if strings.Contains(prompt, strings.ToLower(s)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasFlag returns a Rule matcher for requests with flag set.
+func HasFlag(flag string) func(claudecode.RouteRequest) bool {
+	return func(req claudecode.RouteRequest) bool {
+		return req.HasFlag(flag)
+	}
+}
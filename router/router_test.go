@@ -0,0 +1,90 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestRouterDefaults(t *testing.T) {
+	low, plenty := 0.1, 10.0
+	tests := []struct {
+		name string
+		req  claudecode.RouteRequest
+		want string
+	}{
+		{"short question", claudecode.RouteRequest{Prompt: "What is a goroutine?"}, ModelHaiku},
+		{"long prompt", claudecode.RouteRequest{Prompt: string(make([]byte, DefaultShortPromptChars+1))}, ModelSonnet},
+		{"code", claudecode.RouteRequest{Prompt: "Why does this fail?\n```go\nx := 1\n```"}, ModelSonnet},
+		{"heavy tools", claudecode.RouteRequest{Prompt: "Fix it", Tools: []string{"Read", "Bash(go test:*)"}}, ModelSonnet},
+		{"light tools", claudecode.RouteRequest{Prompt: "Find it", Tools: []string{"Read", "Grep"}}, ModelHaiku},
+		{"deep", claudecode.RouteRequest{Prompt: "Hi", Flags: []string{claudecode.RouteFlagDeep}}, ModelOpus},
+		{"deep with budget", claudecode.RouteRequest{Prompt: "Hi", Flags: []string{claudecode.RouteFlagDeep}, RemainingBudgetUSD: &plenty}, ModelOpus},
+		{"deep on low budget", claudecode.RouteRequest{Prompt: "Fix it", Tools: []string{"Edit"}, Flags: []string{claudecode.RouteFlagDeep}, RemainingBudgetUSD: &low}, ModelSonnet},
+		{"ordinary on low budget", claudecode.RouteRequest{Prompt: "Fix it", Tools: []string{"Edit"}, RemainingBudgetUSD: &low}, ModelHaiku},
+	}
+
+	r := &Router{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := r.Route(context.Background(), test.req); got != test.want {
+				t.Errorf("Expected %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestRouterConfiguration(t *testing.T) {
+	r := &Router{
+		Rules: []Rule{
+			{Name: "security", Model: "custom-security", Match: PromptContains("Security Review")},
+			{Name: "batch", Model: "custom-batch", Match: HasFlag("batch")},
+			{Name: "incomplete"},
+		},
+		Fast:             "small",
+		Default:          "medium",
+		Deep:             "large",
+		ShortPromptChars: 5,
+		LowBudgetUSD:     2,
+		HeavyTools:       []string{"Read"},
+	}
+	ctx := context.Background()
+	budget := 1.0
+
+	tests := []struct {
+		req  claudecode.RouteRequest
+		want string
+	}{
+		{claudecode.RouteRequest{Prompt: "Please do a security review"}, "custom-security"},
+		{claudecode.RouteRequest{Prompt: "x", Flags: []string{"batch", claudecode.RouteFlagDeep}}, "custom-batch"},
+		{claudecode.RouteRequest{Prompt: "hi"}, "small"},
+		{claudecode.RouteRequest{Prompt: "hi", Tools: []string{"Read"}}, "medium"},
+		{claudecode.RouteRequest{Prompt: "a longer prompt"}, "medium"},
+		{claudecode.RouteRequest{Prompt: "hi", Flags: []string{claudecode.RouteFlagDeep}}, "large"},
+		{claudecode.RouteRequest{Prompt: "a longer prompt", RemainingBudgetUSD: &budget}, "small"},
+	}
+	for _, test := range tests {
+		if got := r.Route(ctx, test.req); got != test.want {
+			t.Errorf("Route(%+v): expected %s, got %s", test.req, test.want, got)
+		}
+	}
+}
+
+func TestContainsCode(t *testing.T) {
+	for _, text := range []string{
+		"```\nls\n```",
+		"func main() {",
+		"  return x;",
+		"def handler(event):\n    pass",
+	} {
+		if !ContainsCode(text) {
+			t.Errorf("Expected %q to contain code", text)
+		}
+	}
+	for _, text := range []string{"What is the capital of France?", "Summarize the meeting; keep it short."} {
+		if ContainsCode(text) {
+			t.Errorf("Expected %q not to contain code", text)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrNoResult is returned by Run, and the helpers built on it, when the
+	// query ended without a result message.
+	ErrNoResult = errors.New("query ended without a result")
+	// ErrFailedResult is returned by helpers built on Run for a query whose
+	// result is an error. Run itself reports such results with Success
+	// false.
+	ErrFailedResult = errors.New("query result is an error")
+)
+
+// QueryFunc runs a one-shot query. Query is the default wherever one is
+// taken; tests and callers with their own transport substitute another.
+type QueryFunc func(ctx context.Context, prompt string, opts ...Option) (MessageIterator, error)
+
+// RunSpec describes a run for Run.
+type RunSpec struct {
+	Prompt  string
+	Options []Option
+	// OnMessage, if set, is called with each message as it arrives, for
+	// callers streaming the run. An error from it ends the run.
+	OnMessage func(msg Message) error
+	// SuccessCheck decides whether a completed run succeeded, for example
+	// by checking its text or the files it changed. Without it, a run
+	// succeeds when its result is not an error. It is only called for
+	// results that are not errors.
+	SuccessCheck func(result *RunResult) bool
+}
+
+// RunResult is everything a batch job needs to know about one run.
+type RunResult struct {
+	// Text is the final result text.
+	Text     string
+	Messages []Message
+	Result   *ResultMessage
+	// SessionID is the session the run used, for resuming it later.
+	SessionID string
+	// FilesChanged lists the files the agent wrote or edited successfully,
+	// in the order they were first changed.
+	FilesChanged []string
+	CostUSD      float64
+	Duration     time.Duration
+	// Success is false for error results and runs rejected by the
+	// SuccessCheck.
+	Success bool
+}
+
+// fileTools maps the built-in tools that change files to the input field
+// naming the file.
+var fileTools = map[string]string{
+	"Write":        "file_path",
+	"Edit":         "file_path",
+	"MultiEdit":    "file_path",
+	"NotebookEdit": "notebook_path",
+}
+
+// Run runs a one-shot query to completion and collects its outcome, for
+// schedulers and CI jobs that want one value per run instead of a message
+// stream.
+//
+// A run whose result is an error is not a Go error: it is reported with
+// Success false. Errors are returned when the run could not complete, such
+// as when the CLI is missing or ctx ends, together with what was collected
+// so far. When messages arrived before the failure, the error is a
+// *PartialResult holding them.
+//
+// Example:
+//
+//	result, err := claudecode.Run(ctx, claudecode.RunSpec{
+//	    Prompt:  "Update the changelog for v1.4.0",
+//	    Options: []claudecode.Option{claudecode.WithCwd(repo), claudecode.WithMaxTurns(10)},
+//	    SuccessCheck: func(r *claudecode.RunResult) bool {
+//	        return len(r.FilesChanged) > 0
+//	    },
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	log.Printf("success=%v cost=$%.4f files=%v", result.Success, result.CostUSD, result.FilesChanged)
+func Run(ctx context.Context, spec RunSpec) (*RunResult, error) {
+	return runSpec(ctx, spec, Query)
+}
+
+// RunWithTransport runs spec over a custom transport, like
+// QueryWithTransport.
+func RunWithTransport(ctx context.Context, transport Transport, spec RunSpec) (*RunResult, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("transport is required")
+	}
+	return runSpec(ctx, spec, func(ctx context.Context, prompt string, opts ...Option) (MessageIterator, error) {
+		return QueryWithTransport(ctx, prompt, transport, opts...)
+	})
+}
+
+// RunWithQuery runs spec with query in place of Query. A nil query is
+// Query.
+func RunWithQuery(ctx context.Context, query QueryFunc, spec RunSpec) (*RunResult, error) {
+	if query == nil {
+		query = Query
+	}
+	return runSpec(ctx, spec, query)
+}
+
+func runSpec(ctx context.Context, spec RunSpec, query QueryFunc) (*RunResult, error) {
+	started := time.Now()
+	result := &RunResult{}
+	err := collectRun(ctx, spec, query, result)
+	result.Duration = time.Since(started)
+	if err != nil {
+		if len(result.Messages) > 0 {
+			err = NewPartialResult(result.Messages, err)
+		}
+		return result, err
+	}
+
+	result.Success = !result.Result.IsError
+	if result.Success && spec.SuccessCheck != nil {
+		result.Success = spec.SuccessCheck(result)
+	}
+	return result, nil
+}
+
+// collectRun runs the query, recording its messages and changed files in
+// result.
+func collectRun(ctx context.Context, spec RunSpec, query QueryFunc, result *RunResult) error {
+	iter, err := query(ctx, spec.Prompt, spec.Options...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	// Paths of file changes waiting for their tool result
+	pending := make(map[string]string)
+	changed := make(map[string]bool)
+	for {
+		msg, err := iter.Next(ctx)
+		if errors.Is(err, ErrNoMoreMessages) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		result.Messages = append(result.Messages, msg)
+		if spec.OnMessage != nil {
+			if err := spec.OnMessage(msg); err != nil {
+				return err
+			}
+		}
+
+		switch m := msg.(type) {
+		case *AssistantMessage:
+			for _, block := range m.Content {
+				toolUse, ok := block.(*ToolUseBlock)
+				if !ok {
+					continue
+				}
+				if field, ok := fileTools[toolUse.Name]; ok {
+					if path, _ := toolUse.Input[field].(string); path != "" {
+						pending[toolUse.ToolUseID] = path
+					}
+				}
+			}
+		case *UserMessage:
+			blocks, _ := m.Content.([]ContentBlock)
+			for _, block := range blocks {
+				toolResult, ok := block.(*ToolResultBlock)
+				if !ok {
+					continue
+				}
+				path, ok := pending[toolResult.ToolUseID]
+				if !ok {
+					continue
+				}
+				delete(pending, toolResult.ToolUseID)
+				if !toolResult.Failed() && !changed[path] {
+					changed[path] = true
+					result.FilesChanged = append(result.FilesChanged, path)
+				}
+			}
+		case *ResultMessage:
+			result.Result = m
+			result.SessionID = m.SessionID
+			if m.Result != nil {
+				result.Text = *m.Result
+			}
+			if m.TotalCostUSD != nil {
+				result.CostUSD = *m.TotalCostUSD
+			}
+		}
+	}
+
+	if result.Result == nil {
+		return ErrNoResult
+	}
+	return nil
+}
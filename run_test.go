@@ -0,0 +1,147 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	failed := true
+	text, cost := "Changelog updated", 0.05
+	transport := newQueryMockTransport()
+	transport.responseMessages = []Message{
+		&AssistantMessage{Content: []ContentBlock{
+			&ToolUseBlock{ToolUseID: "t1", Name: "Edit", Input: map[string]any{"file_path": "CHANGELOG.md"}},
+			&ToolUseBlock{ToolUseID: "t2", Name: "Write", Input: map[string]any{"file_path": "locked.txt"}},
+			&ToolUseBlock{ToolUseID: "t3", Name: "Read", Input: map[string]any{"file_path": "README.md"}},
+		}},
+		&UserMessage{Content: []ContentBlock{
+			&ToolResultBlock{ToolUseID: "t1", Content: "ok"},
+			&ToolResultBlock{ToolUseID: "t2", Content: "permission denied", IsError: &failed},
+			&ToolResultBlock{ToolUseID: "t3", Content: "# Project"},
+		}},
+		&AssistantMessage{Content: []ContentBlock{
+			&ToolUseBlock{ToolUseID: "t4", Name: "NotebookEdit", Input: map[string]any{"notebook_path": "notes.ipynb"}},
+			&ToolUseBlock{ToolUseID: "t5", Name: "Edit", Input: map[string]any{"file_path": "CHANGELOG.md"}},
+		}},
+		&UserMessage{Content: []ContentBlock{
+			&ToolResultBlock{ToolUseID: "t4", Content: "ok"},
+			&ToolResultBlock{ToolUseID: "t5", Content: "ok"},
+		}},
+		&ResultMessage{Subtype: "success", SessionID: "session-1", Result: &text, TotalCostUSD: &cost},
+	}
+
+	var checked *RunResult
+	result, err := RunWithTransport(ctx, transport, RunSpec{
+		Prompt: "Update the changelog",
+		SuccessCheck: func(r *RunResult) bool {
+			checked = r
+			return true
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !result.Success || result.Text != text || result.CostUSD != cost || result.SessionID != "session-1" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if want := []string{"CHANGELOG.md", "notes.ipynb"}; !reflect.DeepEqual(result.FilesChanged, want) {
+		t.Errorf("Expected files changed %v, got %v", want, result.FilesChanged)
+	}
+	if len(result.Messages) != 5 || result.Duration <= 0 {
+		t.Errorf("Expected all messages and a duration, got %d messages in %v", len(result.Messages), result.Duration)
+	}
+	if checked != result {
+		t.Error("Expected the success check to see the result")
+	}
+}
+
+func TestRunSuccess(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name    string
+		isError bool
+		check   func(*RunResult) bool
+		want    bool
+	}{
+		{"success", false, nil, true},
+		{"error result", true, nil, false},
+		{"rejected by check", false, func(*RunResult) bool { return false }, false},
+		{"check skipped for errors", true, func(*RunResult) bool { return true }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			transport := newQueryMockTransport(WithQueryResultMessage(test.isError, 1000, 1))
+			result, err := RunWithTransport(ctx, transport, RunSpec{Prompt: "go", SuccessCheck: test.check})
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if result.Success != test.want {
+				t.Errorf("Expected success %v, got %v", test.want, result.Success)
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	result, err := RunWithTransport(ctx, newQueryMockTransport(WithQueryAssistantResponse("partial")), RunSpec{Prompt: "go"})
+	if !errors.Is(err, ErrNoResult) || result == nil || len(result.Messages) != 1 || result.Success {
+		t.Errorf("Expected ErrNoResult with the partial run, got %+v, %v", result, err)
+	}
+	var partial *PartialResult
+	if !errors.As(err, &partial) || len(partial.Messages) != 1 || partial.Text() != "partial" {
+		t.Errorf("Expected a PartialResult with the messages received, got %v", err)
+	}
+
+	connectErr := errors.New("connect failed")
+	if _, err := RunWithTransport(ctx, newQueryMockTransport(WithQueryConnectError(connectErr)), RunSpec{Prompt: "go"}); !errors.Is(err, connectErr) {
+		t.Errorf("Expected the connect error, got %v", err)
+	}
+
+	if _, err := RunWithTransport(ctx, nil, RunSpec{Prompt: "go"}); err == nil {
+		t.Error("Expected an error without a transport")
+	}
+}
+
+func TestRunWithQuery(t *testing.T) {
+	ctx, cancel := setupQueryTestContext(t, 5*time.Second)
+	defer cancel()
+
+	transport := newQueryMockTransport(WithQueryAssistantResponse("4"), WithQueryResultMessage(false, 10, 1))
+	query := func(ctx context.Context, prompt string, opts ...Option) (MessageIterator, error) {
+		return QueryWithTransport(ctx, prompt, transport, opts...)
+	}
+
+	var seen []Message
+	result, err := RunWithQuery(ctx, query, RunSpec{
+		Prompt: "What is 2+2?",
+		OnMessage: func(msg Message) error {
+			seen = append(seen, msg)
+			return nil
+		},
+	})
+	if err != nil || !result.Success {
+		t.Fatalf("Expected a successful run, got %+v, %v", result, err)
+	}
+	if !reflect.DeepEqual(seen, result.Messages) {
+		t.Errorf("Expected OnMessage to see every message, got %d of %d", len(seen), len(result.Messages))
+	}
+
+	stop := errors.New("stop")
+	transport = newQueryMockTransport(WithQueryAssistantResponse("4"), WithQueryResultMessage(false, 10, 1))
+	_, err = RunWithQuery(ctx, query, RunSpec{Prompt: "What is 2+2?", OnMessage: func(Message) error { return stop }})
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected the OnMessage error to end the run, got %v", err)
+	}
+}
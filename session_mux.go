@@ -0,0 +1,383 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSessionClosed indicates an operation on a closed MuxSession or SessionMux.
+var ErrSessionClosed = errors.New("session closed")
+
+// sessionChannelBufferSize is the buffer size for per-session message channels.
+const sessionChannelBufferSize = 10
+
+// SessionMux hosts multiple independent logical sessions on one connected Client.
+//
+// A CLI process works on one turn at a time, so the mux serializes turns:
+// prompts from different sessions are queued and sent in FIFO order, and
+// every message the CLI emits is routed to the session that owns the current
+// turn until that turn's ResultMessage arrives. Each session keeps its own
+// conversation context through the session ID sent with its prompts.
+//
+// Example:
+//
+//	mux := claudecode.NewSessionMux(client)
+//	if err := mux.Start(ctx); err != nil {
+//		return err
+//	}
+//	defer mux.Close()
+//
+//	alice := mux.Session("alice")
+//	if err := alice.Query(ctx, "Hello"); err != nil {
+//		return err
+//	}
+//	for msg := range alice.ReceiveMessages() {
+//		if _, ok := msg.(*claudecode.ResultMessage); ok {
+//			break
+//		}
+//	}
+type SessionMux struct {
+	client Client
+
+	mu       sync.Mutex
+	sessions map[string]*MuxSession
+	queue    []*muxTurn
+	active   *muxTurn
+	closing  []*MuxSession // closed sessions whose channels the router must close
+	started  bool
+	closed   bool
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// MuxSession is a logical session hosted by a SessionMux.
+type MuxSession struct {
+	id     string
+	mux    *SessionMux
+	msgCh  chan Message
+	done   chan struct{}
+	closed bool // guarded by mux.mu
+}
+
+// muxTurn is a queued prompt waiting to be sent to the CLI.
+type muxTurn struct {
+	session *MuxSession
+	prompt  string
+	sent    chan error
+}
+
+// NewSessionMux creates a SessionMux on top of a client.
+// The client must be connected before Start is called, and must not be used
+// directly for queries while the mux is running.
+func NewSessionMux(client Client) *SessionMux {
+	return &SessionMux{
+		client:   client,
+		sessions: make(map[string]*MuxSession),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Start begins routing messages from the client to sessions.
+// The mux runs until ctx is canceled, Close is called, or the client's
+// message stream ends.
+func (m *SessionMux) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrSessionClosed
+	}
+	if m.started {
+		return fmt.Errorf("session mux already started")
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	m.started = true
+
+	go m.run(runCtx, m.client.ReceiveMessages(runCtx))
+	return nil
+}
+
+// Session returns the session with the given ID, creating it if needed.
+func (m *SessionMux) Session(id string) *MuxSession {
+	if id == "" {
+		id = defaultSessionID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.sessions[id]; ok {
+		return s
+	}
+
+	s := &MuxSession{
+		id:    id,
+		mux:   m,
+		msgCh: make(chan Message, sessionChannelBufferSize),
+		done:  make(chan struct{}),
+	}
+	if m.closed {
+		s.closed = true
+		close(s.done)
+		close(s.msgCh)
+	} else {
+		m.sessions[id] = s
+	}
+	return s
+}
+
+// Sessions returns the IDs of all open sessions.
+func (m *SessionMux) Sessions() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(m.sessions))
+	for id := range m.sessions {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Close stops routing and closes every session's message channel.
+// It does not disconnect the underlying client. Close is safe to call
+// multiple times.
+func (m *SessionMux) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	cancel := m.cancel
+	done := m.done
+	m.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+		return nil
+	}
+
+	// Never started: nothing is routing, so shut down directly
+	m.mu.Lock()
+	m.shutdownLocked()
+	m.mu.Unlock()
+	return nil
+}
+
+// ID returns the session ID.
+func (s *MuxSession) ID() string {
+	return s.id
+}
+
+// Query queues a prompt for this session.
+// It blocks until the prompt has been sent to the CLI, which happens once all
+// turns queued before it have produced their ResultMessage.
+func (s *MuxSession) Query(ctx context.Context, prompt string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	turn := &muxTurn{
+		session: s,
+		prompt:  prompt,
+		sent:    make(chan error, 1),
+	}
+
+	m := s.mux
+	m.mu.Lock()
+	if s.closed || m.closed {
+		m.mu.Unlock()
+		return ErrSessionClosed
+	}
+	if !m.started {
+		m.mu.Unlock()
+		return fmt.Errorf("session mux not started")
+	}
+	m.queue = append(m.queue, turn)
+	m.mu.Unlock()
+
+	m.signal()
+
+	select {
+	case err := <-turn.sent:
+		return err
+	case <-ctx.Done():
+		m.removeQueued(turn)
+		return ctx.Err()
+	}
+}
+
+// ReceiveMessages returns the channel of messages produced by this session's turns.
+// The channel is closed when the session or the mux is closed.
+func (s *MuxSession) ReceiveMessages() <-chan Message {
+	return s.msgCh
+}
+
+// Close removes the session from the mux and closes its message channel.
+// Queued prompts are dropped and output of an in-flight turn is discarded.
+func (s *MuxSession) Close() error {
+	m := s.mux
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	delete(m.sessions, s.id)
+
+	// Once routing has started only the router closes message channels,
+	// so a close never races with a delivery.
+	if m.started {
+		m.closing = append(m.closing, s)
+		m.signal()
+	} else {
+		close(s.msgCh)
+	}
+
+	remaining := m.queue[:0]
+	for _, turn := range m.queue {
+		if turn.session == s {
+			turn.sent <- ErrSessionClosed
+			continue
+		}
+		remaining = append(remaining, turn)
+	}
+	m.queue = remaining
+	return nil
+}
+
+// run is the routing loop. It is the only goroutine that sends prompts and
+// delivers messages, which keeps turn ownership unambiguous.
+func (m *SessionMux) run(ctx context.Context, messages <-chan Message) {
+	defer close(m.done)
+	defer func() {
+		m.mu.Lock()
+		m.shutdownLocked()
+		m.mu.Unlock()
+	}()
+
+	for {
+		m.reapClosed()
+		m.dispatchNext(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.wake:
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			m.route(ctx, msg)
+		}
+	}
+}
+
+// dispatchNext sends the next queued prompt if no turn is in flight.
+func (m *SessionMux) dispatchNext(ctx context.Context) {
+	m.mu.Lock()
+	if m.active != nil || len(m.queue) == 0 {
+		m.mu.Unlock()
+		return
+	}
+	turn := m.queue[0]
+	m.queue = m.queue[1:]
+	m.active = turn
+	m.mu.Unlock()
+
+	err := m.client.QueryWithSession(ctx, turn.prompt, turn.session.id)
+	if err != nil {
+		m.mu.Lock()
+		m.active = nil
+		m.mu.Unlock()
+		// Try the next turn on the following loop iteration
+		m.signal()
+	}
+	turn.sent <- err
+}
+
+// route delivers a message to the session owning the current turn.
+func (m *SessionMux) route(ctx context.Context, msg Message) {
+	m.mu.Lock()
+	turn := m.active
+	if _, isResult := msg.(*ResultMessage); isResult {
+		m.active = nil
+	}
+	if turn == nil || turn.session.closed {
+		m.mu.Unlock()
+		return // No owner (or owner closed): discard
+	}
+	session := turn.session
+	m.mu.Unlock()
+
+	select {
+	case session.msgCh <- msg:
+	case <-session.done:
+	case <-ctx.Done():
+	}
+}
+
+// reapClosed closes the message channels of sessions closed since the last pass.
+func (m *SessionMux) reapClosed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.closing {
+		close(s.msgCh)
+	}
+	m.closing = nil
+}
+
+// removeQueued drops a turn that has not been dispatched yet.
+func (m *SessionMux) removeQueued(turn *muxTurn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, queued := range m.queue {
+		if queued == turn {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// signal wakes the routing loop without blocking.
+func (m *SessionMux) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// shutdownLocked fails queued turns and closes all session channels.
+// Must be called with m.mu held.
+func (m *SessionMux) shutdownLocked() {
+	m.closed = true
+	for _, turn := range m.queue {
+		turn.sent <- ErrSessionClosed
+	}
+	m.queue = nil
+	m.active = nil
+
+	for _, s := range m.closing {
+		close(s.msgCh)
+	}
+	m.closing = nil
+
+	for id, s := range m.sessions {
+		s.closed = true
+		close(s.done)
+		close(s.msgCh)
+		delete(m.sessions, id)
+	}
+}
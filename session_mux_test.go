@@ -0,0 +1,210 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionMuxRoutesTurnsToOwningSession(t *testing.T) {
+	ctx, cancel := setupMuxTestContext(t, 5*time.Second)
+	defer cancel()
+
+	transport, mux := setupMuxForTest(ctx, t)
+	defer mux.Close()
+
+	alice := mux.Session("alice")
+	bob := mux.Session("bob")
+
+	if err := alice.Query(ctx, "hello from alice"); err != nil {
+		t.Fatalf("alice Query failed: %v", err)
+	}
+
+	// Bob's turn must wait for alice's result before it is sent
+	bobSent := make(chan error, 1)
+	go func() { bobSent <- bob.Query(ctx, "hello from bob") }()
+
+	select {
+	case err := <-bobSent:
+		t.Fatalf("bob's prompt was sent before alice's turn finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	transport.injectTestMessage(newMuxAssistantMessage("for alice"))
+	transport.injectTestMessage(&ResultMessage{Subtype: "success", SessionID: "cli-1"})
+
+	assertMuxTurn(ctx, t, alice, "for alice")
+
+	if err := <-bobSent; err != nil {
+		t.Fatalf("bob Query failed: %v", err)
+	}
+
+	transport.injectTestMessage(newMuxAssistantMessage("for bob"))
+	transport.injectTestMessage(&ResultMessage{Subtype: "success", SessionID: "cli-1"})
+
+	assertMuxTurn(ctx, t, bob, "for bob")
+
+	// Prompts carry their session IDs to the CLI
+	first, _ := transport.getSentMessage(0)
+	second, _ := transport.getSentMessage(1)
+	if first.SessionID != "alice" || second.SessionID != "bob" {
+		t.Errorf("Expected session IDs alice, bob; got %q, %q", first.SessionID, second.SessionID)
+	}
+}
+
+func TestSessionMuxSessionReuse(t *testing.T) {
+	mux := NewSessionMux(NewClientWithTransport(newClientMockTransport()))
+	defer mux.Close()
+
+	if mux.Session("a") != mux.Session("a") {
+		t.Error("Expected Session to return the same session for the same ID")
+	}
+	if mux.Session("").ID() != defaultSessionID {
+		t.Error("Expected empty session ID to map to the default session")
+	}
+	if got := len(mux.Sessions()); got != 2 {
+		t.Errorf("Expected 2 sessions, got %d", got)
+	}
+}
+
+func TestSessionMuxClose(t *testing.T) {
+	ctx, cancel := setupMuxTestContext(t, 5*time.Second)
+	defer cancel()
+
+	_, mux := setupMuxForTest(ctx, t)
+
+	alice := mux.Session("alice")
+	bob := mux.Session("bob")
+	if err := alice.Query(ctx, "busy"); err != nil {
+		t.Fatalf("alice Query failed: %v", err)
+	}
+
+	queued := make(chan error, 1)
+	go func() { queued <- bob.Query(ctx, "queued") }()
+	time.Sleep(20 * time.Millisecond)
+
+	if err := mux.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := mux.Close(); err != nil {
+		t.Fatalf("second Close failed: %v", err)
+	}
+
+	if err := <-queued; !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Expected queued query to fail with ErrSessionClosed, got %v", err)
+	}
+	assertMuxChannelClosed(ctx, t, alice)
+	assertMuxChannelClosed(ctx, t, bob)
+
+	if err := alice.Query(ctx, "after close"); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Expected ErrSessionClosed after close, got %v", err)
+	}
+}
+
+func TestMuxSessionCloseDiscardsOutput(t *testing.T) {
+	ctx, cancel := setupMuxTestContext(t, 5*time.Second)
+	defer cancel()
+
+	transport, mux := setupMuxForTest(ctx, t)
+	defer mux.Close()
+
+	alice := mux.Session("alice")
+	bob := mux.Session("bob")
+	if err := alice.Query(ctx, "will be abandoned"); err != nil {
+		t.Fatalf("alice Query failed: %v", err)
+	}
+	if err := alice.Close(); err != nil {
+		t.Fatalf("session Close failed: %v", err)
+	}
+	assertMuxChannelClosed(ctx, t, alice)
+
+	// Alice's output is discarded, and her result still frees the CLI for bob
+	transport.injectTestMessage(newMuxAssistantMessage("for alice"))
+	transport.injectTestMessage(&ResultMessage{Subtype: "success"})
+
+	if err := bob.Query(ctx, "next"); err != nil {
+		t.Fatalf("bob Query failed: %v", err)
+	}
+	transport.injectTestMessage(newMuxAssistantMessage("for bob"))
+	transport.injectTestMessage(&ResultMessage{Subtype: "success"})
+	assertMuxTurn(ctx, t, bob, "for bob")
+}
+
+func TestSessionMuxQueryBeforeStart(t *testing.T) {
+	ctx, cancel := setupMuxTestContext(t, 5*time.Second)
+	defer cancel()
+
+	mux := NewSessionMux(NewClientWithTransport(newClientMockTransport()))
+	defer mux.Close()
+
+	if err := mux.Session("a").Query(ctx, "hi"); err == nil {
+		t.Error("Expected error when querying before Start")
+	}
+}
+
+// Helper functions
+
+func setupMuxTestContext(t *testing.T, timeout time.Duration) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+func setupMuxForTest(ctx context.Context, t *testing.T) (*clientMockTransport, *SessionMux) {
+	t.Helper()
+	transport := newClientMockTransport()
+	client := NewClientWithTransport(transport)
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Disconnect() })
+
+	mux := NewSessionMux(client)
+	if err := mux.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	return transport, mux
+}
+
+func newMuxAssistantMessage(text string) *AssistantMessage {
+	return &AssistantMessage{
+		Content: []ContentBlock{&TextBlock{Text: text}},
+		Model:   "claude-sonnet-4-5",
+	}
+}
+
+func assertMuxTurn(ctx context.Context, t *testing.T, s *MuxSession, wantText string) {
+	t.Helper()
+	for {
+		select {
+		case msg, ok := <-s.ReceiveMessages():
+			if !ok {
+				t.Fatalf("session %s channel closed before result", s.ID())
+			}
+			switch m := msg.(type) {
+			case *AssistantMessage:
+				if got := m.Content[0].(*TextBlock).Text; got != wantText {
+					t.Errorf("session %s: expected %q, got %q", s.ID(), wantText, got)
+				}
+			case *ResultMessage:
+				return
+			}
+		case <-ctx.Done():
+			t.Fatalf("session %s: timed out waiting for turn", s.ID())
+		}
+	}
+}
+
+func assertMuxChannelClosed(ctx context.Context, t *testing.T, s *MuxSession) {
+	t.Helper()
+	for {
+		select {
+		case _, ok := <-s.ReceiveMessages():
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatalf("session %s channel was not closed", s.ID())
+		}
+	}
+}
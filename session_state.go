@@ -0,0 +1,113 @@
+package claudecode
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// SessionState is a key/value store scoped to one client session, shared
+// by the application, hooks and permission callbacks. Callbacks find it in
+// their context with SessionStateFromContext, so they need not close over
+// the application's variables:
+//
+//	client.GetPermissionManager().SetPermissionCallback(func(ctx context.Context, toolName string, ...) (claudecode.PermissionResult, error) {
+//		claudecode.SessionStateFromContext(ctx).Add("calls:"+toolName, 1)
+//		return claudecode.NewPermissionResultAllow(), nil
+//	})
+//
+// It is safe for concurrent use. Values should not be modified once set;
+// use Update to change a value based on the current one.
+type SessionState struct {
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+// NewSessionState returns an empty SessionState.
+func NewSessionState() *SessionState {
+	return &SessionState{values: make(map[string]any)}
+}
+
+// Get returns the value under key, reporting whether there is one.
+func (s *SessionState) Get(key string) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// Set stores value under key.
+func (s *SessionState) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+}
+
+// Delete removes the value under key.
+func (s *SessionState) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+}
+
+// Update replaces the value under key with what fn returns for the current
+// one, atomically, and returns it. fn must not use the SessionState.
+func (s *SessionState) Update(key string, fn func(current any, ok bool) any) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.values[key]
+	next := fn(current, ok)
+	s.values[key] = next
+	return next
+}
+
+// Add adds delta to the int64 counter under key, starting from zero, and
+// returns the new count. A value of another type is replaced.
+func (s *SessionState) Add(key string, delta int64) int64 {
+	return s.Update(key, func(current any, _ bool) any {
+		count, _ := current.(int64)
+		return count + delta
+	}).(int64)
+}
+
+// Keys returns the keys with values, sorted.
+func (s *SessionState) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// StateValue returns the value under key as a T, reporting whether there is
+// one of that type.
+func StateValue[T any](s *SessionState, key string) (T, bool) {
+	value, _ := s.Get(key)
+	typed, ok := value.(T)
+	return typed, ok
+}
+
+type sessionStateKey struct{}
+
+// WithSessionState returns a copy of ctx carrying state.
+func WithSessionState(ctx context.Context, state *SessionState) context.Context {
+	return context.WithValue(ctx, sessionStateKey{}, state)
+}
+
+// SessionStateFromContext returns the SessionState of the session a hook
+// or permission callback runs for, or nil outside one.
+func SessionStateFromContext(ctx context.Context) *SessionState {
+	state, _ := ctx.Value(sessionStateKey{}).(*SessionState)
+	return state
+}
+
+// withSessionStateHandler makes state available to the callbacks handler
+// runs.
+func withSessionStateHandler(state *SessionState, handler ControlRequestHandler) ControlRequestHandler {
+	return func(ctx context.Context, data map[string]any) (map[string]any, error) {
+		return handler(WithSessionState(ctx, state), data)
+	}
+}
@@ -0,0 +1,102 @@
+package claudecode
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionState(t *testing.T) {
+	state := NewSessionState()
+	if _, ok := state.Get("missing"); ok {
+		t.Error("Expected no value for a missing key")
+	}
+	state.Set("files", []string{"a.go"})
+	state.Update("files", func(current any, ok bool) any {
+		files, _ := current.([]string)
+		return append(files[:len(files):len(files)], "b.go")
+	})
+	if files, ok := StateValue[[]string](state, "files"); !ok || !reflect.DeepEqual(files, []string{"a.go", "b.go"}) {
+		t.Errorf("Expected the updated file list, got %v, %v", files, ok)
+	}
+	if _, ok := StateValue[int](state, "files"); ok {
+		t.Error("Expected StateValue to report a value of another type as missing")
+	}
+	if n := state.Add("calls", 2); n != 2 {
+		t.Errorf("Expected a new counter to start from zero, got %d", n)
+	}
+	state.Delete("files")
+	if keys := state.Keys(); !reflect.DeepEqual(keys, []string{"calls"}) {
+		t.Errorf("Expected only the counter to remain, got %v", keys)
+	}
+}
+
+func TestSessionStateConcurrentAdd(t *testing.T) {
+	state := NewSessionState()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				state.Add("calls", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _ := StateValue[int64](state, "calls"); n != 800 {
+		t.Errorf("Expected 800 calls, got %d", n)
+	}
+}
+
+func TestClientStateReachesCallbacks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := NewClientWithTransport(newClientControlMockTransport(), WithPermissionPromptToolName("stdio")).(*ClientImpl)
+	state := client.State()
+	state.Set("owner", "pipeline")
+
+	var seenAtEnd int64
+	if _, err := client.Hooks().Add(HookEventTypeSessionEnd, HookMatcher{Hooks: []HookCallback{
+		func(ctx context.Context, input interface{}, hookCtx HookContext) (HookOutput, error) {
+			seenAtEnd, _ = StateValue[int64](SessionStateFromContext(ctx), "calls:Bash")
+			return HookOutput{Behavior: HookBehaviorContinue}, nil
+		},
+	}}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	client.Permissions().Push("counting", func(ctx context.Context, toolName string, _ map[string]any, _ ToolPermissionContext) (PermissionResult, error) {
+		SessionStateFromContext(ctx).Add("calls:"+toolName, 1)
+		return NewPermissionResultAllow(), nil
+	})
+
+	connectClientSafely(ctx, t, client)
+	requestPathPermission(ctx, t, client, "Bash", map[string]any{"command": "ls"}, "allow")
+	requestPathPermission(ctx, t, client, "Bash", map[string]any{"command": "pwd"}, "allow")
+	if n, _ := StateValue[int64](client.State(), "calls:Bash"); n != 2 {
+		t.Errorf("Expected the application to see the callback's count, got %d", n)
+	}
+	if client.State() != state {
+		t.Error("Expected the state set before Connect to be the session's")
+	}
+	disconnectClientSafely(t, client)
+
+	if seenAtEnd != 2 {
+		t.Errorf("Expected the SessionEnd hook to see the count, got %d", seenAtEnd)
+	}
+	if next := client.State(); next == state || len(next.Keys()) != 0 {
+		t.Errorf("Expected the next session to start empty, got %v", next.Keys())
+	}
+	if owner, _ := StateValue[string](state, "owner"); owner != "pipeline" {
+		t.Errorf("Expected the ended session's store to keep its values, got %q", owner)
+	}
+}
+
+func TestSessionStateFromContextWithoutSession(t *testing.T) {
+	if SessionStateFromContext(context.Background()) != nil {
+		t.Error("Expected no state outside a session")
+	}
+}
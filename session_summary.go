@@ -0,0 +1,131 @@
+package claudecode
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sessionTracker accumulates the totals reported in a SessionSummary for
+// one connection.
+type sessionTracker struct {
+	mu            sync.Mutex
+	started       time.Time
+	summary       SessionSummary
+	processExited bool
+}
+
+func newSessionTracker(started time.Time, tags map[string]string) *sessionTracker {
+	st := &sessionTracker{started: started}
+	if len(tags) > 0 {
+		st.summary.Tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			st.summary.Tags[k] = v
+		}
+	}
+	return st
+}
+
+// track adds the usage and cost of result messages to the totals.
+func (st *sessionTracker) track(msg Message) {
+	result, ok := msg.(*ResultMessage)
+	if !ok {
+		return
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if result.SessionID != "" {
+		st.summary.SessionID = result.SessionID
+	}
+	st.summary.NumTurns += result.NumTurns
+	if result.TotalCostUSD != nil {
+		st.summary.TotalCostUSD += *result.TotalCostUSD
+	}
+	if result.Usage != nil {
+		st.summary.InputTokens += result.Usage.InputTokens
+		st.summary.OutputTokens += result.Usage.OutputTokens
+		st.summary.CacheCreationInputTokens += result.Usage.CacheCreation
+		st.summary.CacheReadInputTokens += result.Usage.CacheRead
+	}
+}
+
+// costUSD returns the cost of the session so far.
+func (st *sessionTracker) costUSD() float64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.summary.TotalCostUSD
+}
+
+// transportEnded records that the transport's message stream closed. If it
+// closed before shutdown started, the CLI process exited on its own and
+// transportEnded reports true.
+func (st *sessionTracker) transportEnded(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return false
+	default:
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.processExited = true
+	return true
+}
+
+// finish returns the summary of the session ending now for reason.
+func (st *sessionTracker) finish(reason SessionEndReason, now time.Time) SessionSummary {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	summary := st.summary
+	summary.Duration = now.Sub(st.started)
+	summary.Reason = reason
+	if reason == SessionEndDisconnect && st.processExited {
+		summary.Reason = SessionEndProcessExit
+	}
+	return summary
+}
+
+// withClientEndReason reports why a WithClient session is ending.
+func withClientEndReason(ctx context.Context, panicked bool) SessionEndReason {
+	switch {
+	case panicked:
+		return SessionEndPanic
+	case ctx.Err() != nil:
+		return SessionEndContextCanceled
+	default:
+		return SessionEndDisconnect
+	}
+}
+
+// runSessionEnd fires the Stop hooks, then the SessionEnd hooks, then the
+// finalizer. It runs once per connection, after teardown, so hooks may call
+// back into the client. Hook errors and finalizer panics are ignored: they
+// cannot stop a session that has already ended. Hooks find the session's
+// state in their context.
+func runSessionEnd(hooks HookSystem, state *SessionState, finalizer Finalizer, summary SessionSummary, cwd string) {
+	if hooks != nil && hooks.HasHooks() {
+		base := BaseHookInput{SessionID: summary.SessionID, Cwd: cwd}
+		ctx := context.Background()
+		if state != nil {
+			ctx = WithSessionState(ctx, state)
+		}
+		_, _ = hooks.ExecuteHooks(ctx, HookEventTypeStop, StopHookInput{
+			BaseHookInput: base,
+			HookEventName: HookEventTypeStop,
+		})
+		_, _ = hooks.ExecuteHooks(ctx, HookEventTypeSessionEnd, SessionEndHookInput{
+			BaseHookInput: base,
+			HookEventName: HookEventTypeSessionEnd,
+			Reason:        summary.Reason,
+		})
+	}
+
+	if finalizer == nil {
+		return
+	}
+	defer func() {
+		_ = recover()
+	}()
+	finalizer(summary)
+}
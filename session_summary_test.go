@@ -0,0 +1,296 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionTrackerTotals(t *testing.T) {
+	started := time.Unix(1700000000, 0)
+	tracker := newSessionTracker(started, nil)
+
+	tracker.track(&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "ignored"}}})
+	tracker.track(sessionResultMessage("session-1", 2, 0.01, 100, 20))
+	tracker.track(sessionResultMessage("session-1", 3, 0.02, 50, 30))
+
+	summary := tracker.finish(SessionEndDisconnect, started.Add(90*time.Second))
+	want := SessionSummary{
+		SessionID:                "session-1",
+		Reason:                   SessionEndDisconnect,
+		Duration:                 90 * time.Second,
+		NumTurns:                 5,
+		InputTokens:              150,
+		OutputTokens:             50,
+		CacheCreationInputTokens: 10,
+		CacheReadInputTokens:     4,
+		TotalCostUSD:             0.03,
+	}
+	if !reflect.DeepEqual(summary, want) {
+		t.Errorf("Expected summary %+v, got %+v", want, summary)
+	}
+	if ratio := summary.CacheHitRatio(); ratio != 4.0/164 {
+		t.Errorf("Expected a cache hit ratio of 4/164, got %v", ratio)
+	}
+}
+
+func TestSessionTrackerProcessExit(t *testing.T) {
+	tracker := newSessionTracker(time.Now(), nil)
+	done := make(chan struct{})
+	tracker.transportEnded(done)
+
+	if reason := tracker.finish(SessionEndDisconnect, time.Now()).Reason; reason != SessionEndProcessExit {
+		t.Errorf("Expected process exit reason, got %q", reason)
+	}
+	// An explicit reason takes precedence
+	if reason := tracker.finish(SessionEndPanic, time.Now()).Reason; reason != SessionEndPanic {
+		t.Errorf("Expected panic reason, got %q", reason)
+	}
+
+	// The stream closing during shutdown is not a process exit
+	shutdown := newSessionTracker(time.Now(), nil)
+	close(done)
+	shutdown.transportEnded(done)
+	if reason := shutdown.finish(SessionEndDisconnect, time.Now()).Reason; reason != SessionEndDisconnect {
+		t.Errorf("Expected disconnect reason, got %q", reason)
+	}
+}
+
+func TestWithClientSessionEnd(t *testing.T) {
+	tests := []struct {
+		name       string
+		fn         func(ctx context.Context, cancel context.CancelFunc, client Client) error
+		wantReason SessionEndReason
+		wantPanic  bool
+	}{
+		{
+			name:       "returns normally",
+			fn:         func(context.Context, context.CancelFunc, Client) error { return nil },
+			wantReason: SessionEndDisconnect,
+		},
+		{
+			name: "returns error",
+			fn: func(context.Context, context.CancelFunc, Client) error {
+				return errors.New("consumer failed")
+			},
+			wantReason: SessionEndDisconnect,
+		},
+		{
+			name: "context canceled",
+			fn: func(_ context.Context, cancel context.CancelFunc, _ Client) error {
+				cancel()
+				return nil
+			},
+			wantReason: SessionEndContextCanceled,
+		},
+		{
+			name: "consumer panics",
+			fn: func(context.Context, context.CancelFunc, Client) error {
+				panic("consumer bug")
+			},
+			wantReason: SessionEndPanic,
+			wantPanic:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			recorder := &sessionEndRecorder{}
+			panicked := runWithClientForSessionEnd(t, func() {
+				_ = WithClientTransport(ctx, newClientMockTransport(), func(client Client) error {
+					recorder.register(t, client)
+					return test.fn(ctx, cancel, client)
+				}, WithFinalizer(recorder.finalize))
+			})
+
+			if panicked != test.wantPanic {
+				t.Errorf("Expected panic=%v to propagate, got %v", test.wantPanic, panicked)
+			}
+			recorder.assertEvents(t, []string{"Stop", "SessionEnd:" + string(test.wantReason), "finalizer"})
+			if recorder.summary.Reason != test.wantReason {
+				t.Errorf("Expected summary reason %q, got %q", test.wantReason, recorder.summary.Reason)
+			}
+		})
+	}
+}
+
+func TestClientSessionEndRunsOnce(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	recorder := &sessionEndRecorder{}
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		sessionResultMessage("session-7", 1, 0.5, 10, 5),
+	}))
+	client := NewClientWithTransport(transport, WithFinalizer(recorder.finalize),
+		WithSessionTags(map[string]string{"team": "payments"}))
+	connectClientSafely(ctx, t, client)
+	recorder.register(t, client)
+
+	for msg := range client.ReceiveMessages(ctx) {
+		if _, ok := msg.(*ResultMessage); ok {
+			break
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = client.Disconnect()
+		}()
+	}
+	wg.Wait()
+	client.Wait()
+	_ = client.Disconnect()
+
+	recorder.assertEvents(t, []string{"Stop", "SessionEnd:disconnect", "finalizer"})
+	if recorder.summary.SessionID != "session-7" || recorder.summary.InputTokens != 10 || recorder.summary.TotalCostUSD != 0.5 {
+		t.Errorf("Unexpected summary: %+v", recorder.summary)
+	}
+	if recorder.summary.Tags["team"] != "payments" {
+		t.Errorf("Expected the session tags in the summary, got %v", recorder.summary.Tags)
+	}
+	if recorder.stopInput.SessionID != "session-7" {
+		t.Errorf("Expected Stop hook to receive the session ID, got %q", recorder.stopInput.SessionID)
+	}
+}
+
+func TestClientSessionEndAfterProcessExit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	recorder := &sessionEndRecorder{}
+	client := NewClientWithTransport(&sessionExitMockTransport{newClientMockTransport()}, WithFinalizer(recorder.finalize))
+	connectClientSafely(ctx, t, client)
+	recorder.register(t, client)
+
+	// The message channel closes once the CLI process is gone
+	for range client.ReceiveMessages(ctx) {
+	}
+	disconnectClientSafely(t, client)
+
+	recorder.assertEvents(t, []string{"Stop", "SessionEnd:process_exit", "finalizer"})
+}
+
+func TestClientFinalizerPanicIsContained(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := NewClientWithTransport(newClientMockTransport(), WithFinalizer(func(SessionSummary) {
+		panic("finalizer bug")
+	}))
+	connectClientSafely(ctx, t, client)
+	if err := client.Disconnect(); err != nil {
+		t.Errorf("Expected finalizer panic to be contained, got %v", err)
+	}
+}
+
+// sessionExitMockTransport simulates a CLI process that exits right away.
+type sessionExitMockTransport struct {
+	*clientMockTransport
+}
+
+func (s *sessionExitMockTransport) ReceiveMessages(_ context.Context) (<-chan Message, <-chan error) {
+	msgChan := make(chan Message)
+	close(msgChan)
+	return msgChan, make(chan error)
+}
+
+// sessionEndRecorder records the order of shutdown callbacks.
+type sessionEndRecorder struct {
+	mu        sync.Mutex
+	events    []string
+	summary   SessionSummary
+	stopInput BaseHookInput
+}
+
+func (r *sessionEndRecorder) register(t *testing.T, client Client) {
+	t.Helper()
+	hooks := client.(*ClientImpl).GetHookSystem()
+	if hooks == nil {
+		t.Fatal("Expected hook system after connecting")
+	}
+	_ = hooks.AddHook(string(HookEventTypeSessionEnd), func(_ context.Context, input interface{}, _ HookContext) (HookOutput, error) {
+		r.record("SessionEnd:" + string(input.(SessionEndHookInput).Reason))
+		return HookOutput{Behavior: HookBehaviorContinue}, nil
+	})
+	_ = hooks.AddHook(string(HookEventTypeStop), func(_ context.Context, input interface{}, _ HookContext) (HookOutput, error) {
+		r.mu.Lock()
+		r.stopInput = input.(StopHookInput).BaseHookInput
+		r.mu.Unlock()
+		r.record("Stop")
+		return HookOutput{Behavior: HookBehaviorContinue}, nil
+	})
+}
+
+func (r *sessionEndRecorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *sessionEndRecorder) finalize(summary SessionSummary) {
+	r.mu.Lock()
+	r.summary = summary
+	r.mu.Unlock()
+	r.record("finalizer")
+}
+
+func (r *sessionEndRecorder) assertEvents(t *testing.T, want []string) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !reflect.DeepEqual(r.events, want) {
+		t.Errorf("Expected shutdown events %q, got %q", want, r.events)
+	}
+}
+
+func runWithClientForSessionEnd(t *testing.T, fn func()) (panicked bool) {
+	t.Helper()
+	defer func() {
+		if recover() != nil {
+			panicked = true
+		}
+	}()
+	fn()
+	return false
+}
+
+func sessionResultMessage(sessionID string, turns int, cost float64, input, output int) *ResultMessage {
+	return &ResultMessage{
+		Subtype:      "success",
+		SessionID:    sessionID,
+		NumTurns:     turns,
+		TotalCostUSD: &cost,
+		Usage:        &Usage{InputTokens: input, OutputTokens: output, CacheCreation: 5, CacheRead: 2},
+	}
+}
+
+func TestClientSessionSummaryUsesClock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	clock := newManualClock()
+	recorder := &sessionEndRecorder{}
+	client := NewClientWithTransport(newClientMockTransport(), WithFinalizer(recorder.finalize), WithClock(clock))
+	connectClientSafely(ctx, t, client)
+	recorder.register(t, client)
+
+	clock.advance(90 * time.Second)
+	if err := client.Disconnect(); err != nil {
+		t.Fatalf("Disconnect failed: %v", err)
+	}
+	client.Wait()
+
+	if recorder.summary.Duration != 90*time.Second {
+		t.Errorf("Expected the duration measured by the clock, got %v", recorder.summary.Duration)
+	}
+}
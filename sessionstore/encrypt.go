@@ -0,0 +1,95 @@
+package sessionstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/severity1/claude-code-sdk-go/encrypt"
+)
+
+// sealedPrefix marks session IDs holding an encrypted session.
+const sealedPrefix = "sealed:"
+
+// sealedSession is what an encrypted Store seals.
+type sealedSession struct {
+	SessionID string            `json:"session_id"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+type encryptedStore struct {
+	inner Store
+	keys  encrypt.KeyProvider
+}
+
+// Encrypt returns a Store that encrypts the session ID and metadata of each
+// session with AES-GCM before saving it in inner. Keys stay readable, since
+// inner looks sessions up by them, as do update times.
+//
+// Sessions saved with an earlier key open while keys has it, and are
+// encrypted with the current key the next time they are saved. Sessions
+// inner held before encryption was enabled are returned as they are.
+//
+// Locks are not encrypted: pass inner, not the encrypted Store, to
+// NewLocker.
+func Encrypt(inner Store, keys encrypt.KeyProvider) Store {
+	return &encryptedStore{inner: inner, keys: keys}
+}
+
+// Get returns the session under key, decrypted.
+func (s *encryptedStore) Get(ctx context.Context, key string) (Session, error) {
+	session, err := s.inner.Get(ctx, key)
+	if err != nil {
+		return session, err
+	}
+	encoded, ok := cutPrefix(session.SessionID, sealedPrefix)
+	if !ok {
+		return session, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return Session{}, fmt.Errorf("session %s: %w: %v", key, encrypt.ErrDecrypt, err)
+	}
+	data, err := encrypt.Open(ctx, s.keys, sealed, []byte(key))
+	if err != nil {
+		return Session{}, fmt.Errorf("session %s: %w", key, err)
+	}
+	var plain sealedSession
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return Session{}, fmt.Errorf("session %s: %w", key, err)
+	}
+	session.SessionID, session.Metadata = plain.SessionID, plain.Metadata
+	return session, nil
+}
+
+// Put encrypts session and saves it. The session's Key is authenticated
+// with it, so a session copied to another key does not open.
+func (s *encryptedStore) Put(ctx context.Context, session Session) error {
+	data, err := json.Marshal(sealedSession{SessionID: session.SessionID, Metadata: session.Metadata})
+	if err != nil {
+		return err
+	}
+	sealed, err := encrypt.Seal(ctx, s.keys, data, []byte(session.Key))
+	if err != nil {
+		return fmt.Errorf("session %s: %w", session.Key, err)
+	}
+	return s.inner.Put(ctx, Session{
+		Key:       session.Key,
+		SessionID: sealedPrefix + base64.StdEncoding.EncodeToString(sealed),
+		UpdatedAt: session.UpdatedAt,
+	})
+}
+
+// Delete removes the session under key.
+func (s *encryptedStore) Delete(ctx context.Context, key string) error {
+	return s.inner.Delete(ctx, key)
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
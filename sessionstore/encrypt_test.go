@@ -0,0 +1,67 @@
+package sessionstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/severity1/claude-code-sdk-go/encrypt"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryStore()
+	keys, err := encrypt.NewKeyring("2025-01", bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := Encrypt(inner, keys)
+
+	updated := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := s.Put(ctx, Session{Key: "conv-1", SessionID: "session-1", Metadata: map[string]string{"repo": "secret-project"}, UpdatedAt: updated}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	stored, _ := inner.Get(ctx, "conv-1")
+	if !strings.HasPrefix(stored.SessionID, sealedPrefix) || stored.Metadata != nil || !stored.UpdatedAt.Equal(updated) {
+		t.Errorf("Expected the session sealed in the inner store, got %+v", stored)
+	}
+	session, err := s.Get(ctx, "conv-1")
+	if err != nil || session.SessionID != "session-1" || session.Metadata["repo"] != "secret-project" {
+		t.Errorf("Expected the session back, got %+v, %v", session, err)
+	}
+
+	// Sessions sealed with an earlier key open after rotation
+	if err := keys.Rotate("2025-02", bytes.Repeat([]byte{2}, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if session, err := s.Get(ctx, "conv-1"); err != nil || session.SessionID != "session-1" {
+		t.Errorf("Expected the session to open after rotation, got %+v, %v", session, err)
+	}
+
+	// A sealed session copied to another key does not open
+	stored.Key = "conv-2"
+	_ = inner.Put(ctx, stored)
+	if _, err := s.Get(ctx, "conv-2"); !errors.Is(err, encrypt.ErrDecrypt) {
+		t.Errorf("Expected a moved session not to open, got %v", err)
+	}
+
+	// Sessions saved before encryption are returned as they are
+	_ = inner.Put(ctx, Session{Key: "conv-3", SessionID: "session-3"})
+	if session, err := s.Get(ctx, "conv-3"); err != nil || session.SessionID != "session-3" {
+		t.Errorf("Expected the plain session, got %+v, %v", session, err)
+	}
+
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := s.Delete(ctx, "conv-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inner.Get(ctx, "conv-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the session deleted, got %v", err)
+	}
+}
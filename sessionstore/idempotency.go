@@ -0,0 +1,43 @@
+package sessionstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Defaults for the idempotency keys of a store.
+const (
+	// DefaultClaimTTL is how long an idempotency key stays claimed by a
+	// query that neither completed nor failed, for example because its
+	// instance crashed.
+	DefaultClaimTTL = 10 * time.Minute
+	// DefaultResultTTL is how long the result of a query stays stored
+	// under its idempotency key.
+	DefaultResultTTL = 24 * time.Hour
+)
+
+// Stores deduplicate retried queries for the SDK.
+var (
+	_ claudecode.IdempotencyStore = (*MemoryStore)(nil)
+	_ claudecode.IdempotencyStore = (*RedisStore)(nil)
+)
+
+// ttlOr returns ttl, or fallback when ttl is not positive.
+func ttlOr(ttl, fallback time.Duration) time.Duration {
+	if ttl <= 0 {
+		return fallback
+	}
+	return ttl
+}
+
+// decodeResult decodes the stored result of the query with key.
+func decodeResult(key string, data []byte) (*claudecode.ResultMessage, error) {
+	var result claudecode.ResultMessage
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("decoding result %s: %w", key, err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,227 @@
+package sessionstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Defaults for a Locker.
+const (
+	// DefaultLockTTL is how long a lease lasts without renewal, and so how
+	// long a session stays locked after its holder fails.
+	DefaultLockTTL = 30 * time.Second
+	// DefaultRetryInterval is how often Lock tries to take a held lock.
+	DefaultRetryInterval = time.Second
+)
+
+var (
+	// ErrLocked is returned by TryLock for a session another holder has
+	// locked.
+	ErrLocked = errors.New("session locked")
+	// ErrLeaseLost is the cause of a Lease's context when the lease could
+	// not be renewed.
+	ErrLeaseLost = errors.New("session lease lost")
+)
+
+// LockStore keeps expiring locks. A lock is held under a token, and only
+// that token renews or releases it. Implementations must be safe for
+// concurrent use.
+type LockStore interface {
+	// Acquire takes the lock on key for ttl if no one holds it, and
+	// reports whether it did.
+	Acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	// Renew extends the lock on key to ttl from now if token holds it, and
+	// reports whether it does.
+	Renew(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	// Release frees the lock on key if token holds it.
+	Release(ctx context.Context, key, token string) error
+}
+
+// Locker hands out leases on sessions. It is safe for concurrent use.
+type Locker struct {
+	store LockStore
+	ttl   time.Duration
+
+	// RetryInterval is how often Lock tries to take a held lock; the
+	// default is DefaultRetryInterval. Set it before use.
+	RetryInterval time.Duration
+}
+
+// NewLocker returns a Locker whose leases last ttl without renewal, or
+// DefaultLockTTL when ttl is not positive.
+func NewLocker(store LockStore, ttl time.Duration) *Locker {
+	if ttl <= 0 {
+		ttl = DefaultLockTTL
+	}
+	return &Locker{store: store, ttl: ttl}
+}
+
+// TryLock takes the lock on the session under key, or returns ErrLocked if
+// another holder has it.
+func (l *Locker) TryLock(ctx context.Context, key string) (*Lease, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := l.store.Acquire(ctx, key, token, l.ttl)
+	if err != nil {
+		return nil, fmt.Errorf("acquiring session lock: %w", err)
+	}
+	if !ok {
+		return nil, ErrLocked
+	}
+	return newLease(l, key, token), nil
+}
+
+// Lock takes the lock on the session under key, waiting for its holder to
+// release it or to fail and let its lease expire. It returns the context's
+// error if ctx is done first.
+func (l *Locker) Lock(ctx context.Context, key string) (*Lease, error) {
+	interval := l.RetryInterval
+	if interval <= 0 {
+		interval = DefaultRetryInterval
+	}
+	for {
+		lease, err := l.TryLock(ctx, key)
+		if !errors.Is(err, ErrLocked) {
+			return lease, err
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Lease is a held session lock. It renews itself in the background until
+// released; if renewal fails for a whole TTL, or another holder took the
+// lock over, the lease is lost.
+type Lease struct {
+	locker *Locker
+	key    string
+	token  string
+
+	lost     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newLease(l *Locker, key, token string) *Lease {
+	lease := &Lease{
+		locker: l,
+		key:    key,
+		token:  token,
+		lost:   make(chan struct{}),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go lease.renew()
+	return lease
+}
+
+// Key returns the key of the locked session.
+func (l *Lease) Key() string {
+	return l.key
+}
+
+// Lost returns a channel closed when the lease is lost.
+func (l *Lease) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Context returns a copy of parent canceled when the lease is lost, with
+// ErrLeaseLost reported by Err, so work on the session stops once another
+// holder may have taken it over.
+func (l *Lease) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	lc := &leaseContext{Context: ctx, lease: l}
+	go func() {
+		select {
+		case <-l.lost:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return lc, cancel
+}
+
+// leaseContext reports ErrLeaseLost as the error of a context canceled
+// because its lease was lost.
+type leaseContext struct {
+	context.Context
+	lease *Lease
+}
+
+func (c *leaseContext) Err() error {
+	err := c.Context.Err()
+	if err == nil {
+		return nil
+	}
+	select {
+	case <-c.lease.lost:
+		return ErrLeaseLost
+	default:
+		return err
+	}
+}
+
+// Release stops renewing the lease and frees the lock, unless it was lost.
+// It is safe to call more than once.
+func (l *Lease) Release(ctx context.Context) error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+	select {
+	case <-l.lost:
+		return nil
+	default:
+	}
+	if err := l.locker.store.Release(ctx, l.key, l.token); err != nil {
+		return fmt.Errorf("releasing session lock: %w", err)
+	}
+	return nil
+}
+
+// renew extends the lease a few times per TTL, so a renewal failing now
+// and then does not lose it.
+func (l *Lease) renew() {
+	defer close(l.done)
+	ttl := l.locker.ttl
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), ttl/3)
+		held, err := l.locker.store.Renew(ctx, l.key, l.token, ttl)
+		cancel()
+		switch {
+		case err == nil && held:
+			renewed = time.Now()
+		case err == nil || time.Since(renewed) >= ttl:
+			// Taken over, or expired while the store was unreachable
+			close(l.lost)
+			return
+		}
+	}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating lock token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,194 @@
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testLockTTL = 30 * time.Millisecond
+
+func TestLockerTryLock(t *testing.T) {
+	ctx := context.Background()
+	locker := NewLocker(NewMemoryStore(), testLockTTL)
+
+	lease, err := locker.TryLock(ctx, "conv-1")
+	if err != nil {
+		t.Fatalf("Expected to lock a free session, got %v", err)
+	}
+	if lease.Key() != "conv-1" {
+		t.Errorf("Expected key conv-1, got %q", lease.Key())
+	}
+	if _, err := locker.TryLock(ctx, "conv-1"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected ErrLocked, got %v", err)
+	}
+	if other, err := locker.TryLock(ctx, "conv-2"); err != nil {
+		t.Errorf("Expected other sessions to stay free, got %v", err)
+	} else {
+		_ = other.Release(ctx)
+	}
+
+	// Renewal keeps the lease for longer than its TTL
+	time.Sleep(3 * testLockTTL)
+	if _, err := locker.TryLock(ctx, "conv-1"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected the renewed lease to hold, got %v", err)
+	}
+
+	if err := lease.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := lease.Release(ctx); err != nil {
+		t.Errorf("Expected a second release to succeed, got %v", err)
+	}
+	if again, err := locker.TryLock(ctx, "conv-1"); err != nil {
+		t.Errorf("Expected a released session to be free, got %v", err)
+	} else {
+		_ = again.Release(ctx)
+	}
+}
+
+func TestLockerLockWaits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	locker := NewLocker(NewMemoryStore(), testLockTTL)
+	locker.RetryInterval = time.Millisecond
+
+	held, err := locker.TryLock(ctx, "conv-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = held.Release(ctx)
+	}()
+	lease, err := locker.Lock(ctx, "conv-1")
+	if err != nil {
+		t.Fatalf("Expected to lock after the holder released, got %v", err)
+	}
+	defer lease.Release(ctx)
+
+	short, cancelShort := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelShort()
+	if _, err := locker.Lock(short, "conv-1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context's error, got %v", err)
+	}
+}
+
+func TestLeaseTakeover(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	clock := &fakeClock{now: time.Now()}
+	store.now = clock.Now
+	locker := NewLocker(store, testLockTTL)
+
+	lease, err := locker.TryLock(ctx, "conv-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaseCtx, cancel := lease.Context(ctx)
+	defer cancel()
+
+	// The holder stalls past its TTL and another instance takes over
+	clock.Advance(time.Minute)
+	takeover, err := locker.TryLock(ctx, "conv-1")
+	if err != nil {
+		t.Fatalf("Expected to take over an expired lease, got %v", err)
+	}
+	defer takeover.Release(ctx)
+
+	select {
+	case <-lease.Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the lease to be lost")
+	}
+	<-leaseCtx.Done()
+	if !errors.Is(leaseCtx.Err(), ErrLeaseLost) {
+		t.Errorf("Expected ErrLeaseLost, got %v", leaseCtx.Err())
+	}
+
+	// Releasing a lost lease leaves the new holder's lock alone
+	if err := lease.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locker.TryLock(ctx, "conv-1"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected the new holder to keep the lock, got %v", err)
+	}
+}
+
+func TestLeaseLostWhenStoreUnreachable(t *testing.T) {
+	store := &flakyLockStore{MemoryStore: NewMemoryStore()}
+	locker := NewLocker(store, testLockTTL)
+	lease, err := locker.TryLock(context.Background(), "conv-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.Release(context.Background())
+
+	store.fail(errors.New("connection refused"))
+	select {
+	case <-lease.Lost():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the lease to be lost once renewals failed for a TTL")
+	}
+}
+
+func TestLeaseContextCanceledByParent(t *testing.T) {
+	locker := NewLocker(NewMemoryStore(), testLockTTL)
+	lease, err := locker.TryLock(context.Background(), "conv-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.Release(context.Background())
+
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := lease.Context(parent)
+	defer cancel()
+	cancelParent()
+	<-ctx.Done()
+	if !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", ctx.Err())
+	}
+}
+
+// fakeClock is a settable time source.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// flakyLockStore fails renewals once fail is called.
+type flakyLockStore struct {
+	*MemoryStore
+	mu  sync.Mutex
+	err error
+}
+
+func (s *flakyLockStore) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *flakyLockStore) Renew(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	err := s.err
+	s.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	return s.MemoryStore.Renew(ctx, key, token, ttl)
+}
@@ -0,0 +1,163 @@
+package sessionstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// MemoryStore is a Store, LockStore and claudecode.IdempotencyStore for
+// the instances of a service running in one process, and for tests.
+type MemoryStore struct {
+	// ClaimTTL and ResultTTL are how long idempotency keys stay claimed and
+	// results stay stored; the defaults are DefaultClaimTTL and
+	// DefaultResultTTL. Set them before use.
+	ClaimTTL  time.Duration
+	ResultTTL time.Duration
+
+	mu       sync.Mutex
+	now      func() time.Time
+	sessions map[string]Session
+	locks    map[string]memoryLock
+	results  map[string]memoryResult
+}
+
+type memoryLock struct {
+	token   string
+	expires time.Time
+}
+
+// memoryResult is a claimed idempotency key, with the JSON of its query's
+// result once stored.
+type memoryResult struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:      time.Now,
+		sessions: make(map[string]Session),
+		locks:    make(map[string]memoryLock),
+		results:  make(map[string]memoryResult),
+	}
+}
+
+// Get returns the session under key.
+func (s *MemoryStore) Get(_ context.Context, key string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[key]
+	if !ok {
+		return Session{}, ErrNotFound
+	}
+	return copySession(session), nil
+}
+
+// Put saves session.
+func (s *MemoryStore) Put(_ context.Context, session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.Key] = copySession(session)
+	return nil
+}
+
+// Delete removes the session under key.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+	return nil
+}
+
+// Acquire takes the lock on key if it is free or expired.
+func (s *MemoryStore) Acquire(_ context.Context, key, token string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if lock, ok := s.locks[key]; ok && now.Before(lock.expires) {
+		return false, nil
+	}
+	s.locks[key] = memoryLock{token: token, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Renew extends the lock on key if token holds it.
+func (s *MemoryStore) Renew(_ context.Context, key, token string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	lock, ok := s.locks[key]
+	if !ok || lock.token != token || !now.Before(lock.expires) {
+		return false, nil
+	}
+	s.locks[key] = memoryLock{token: token, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Release frees the lock on key if token holds it.
+func (s *MemoryStore) Release(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lock, ok := s.locks[key]; ok && lock.token == token {
+		delete(s.locks, key)
+	}
+	return nil
+}
+
+// copySession copies the metadata of session, so callers cannot change a
+// stored session.
+func copySession(session Session) Session {
+	if session.Metadata != nil {
+		metadata := make(map[string]string, len(session.Metadata))
+		for k, v := range session.Metadata {
+			metadata[k] = v
+		}
+		session.Metadata = metadata
+	}
+	return session
+}
+
+// Claim reserves key unless it is claimed, returning the stored result of
+// a completed query with the key.
+func (s *MemoryStore) Claim(_ context.Context, key string) (*claudecode.ResultMessage, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if record, ok := s.results[key]; ok && now.Before(record.expires) {
+		if record.data == nil {
+			return nil, false, nil
+		}
+		result, err := decodeResult(key, record.data)
+		return result, false, err
+	}
+	s.results[key] = memoryResult{expires: now.Add(ttlOr(s.ClaimTTL, DefaultClaimTTL))}
+	return nil, true, nil
+}
+
+// Complete stores result under key.
+func (s *MemoryStore) Complete(_ context.Context, key string, result *claudecode.ResultMessage) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("encoding result %s: %w", key, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[key] = memoryResult{data: data, expires: s.now().Add(ttlOr(s.ResultTTL, DefaultResultTTL))}
+	return nil
+}
+
+// Abandon frees key unless its result is stored.
+func (s *MemoryStore) Abandon(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.results[key]; ok && record.data == nil {
+		delete(s.results, key)
+	}
+	return nil
+}
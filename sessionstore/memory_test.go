@@ -0,0 +1,106 @@
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestMemoryStoreSessions(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	if _, err := s.Get(ctx, "conv-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	metadata := map[string]string{"tenant": "acme"}
+	if err := s.Put(ctx, Session{Key: "conv-1", SessionID: "session-1", Metadata: metadata}); err != nil {
+		t.Fatal(err)
+	}
+	metadata["tenant"] = "changed"
+
+	session, err := s.Get(ctx, "conv-1")
+	if err != nil || session.SessionID != "session-1" || session.Metadata["tenant"] != "acme" {
+		t.Errorf("Unexpected session %+v, err %v", session, err)
+	}
+
+	if err := s.Delete(ctx, "conv-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "conv-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestMemoryStoreLocks(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s.now = clock.Now
+
+	if ok, _ := s.Acquire(ctx, "conv-1", "a", time.Minute); !ok {
+		t.Fatal("Expected to acquire a free lock")
+	}
+	if ok, _ := s.Acquire(ctx, "conv-1", "b", time.Minute); ok {
+		t.Error("Expected a held lock not to be acquired")
+	}
+	if ok, _ := s.Renew(ctx, "conv-1", "b", time.Minute); ok {
+		t.Error("Expected another token not to renew the lock")
+	}
+	_ = s.Release(ctx, "conv-1", "b")
+	if ok, _ := s.Renew(ctx, "conv-1", "a", time.Minute); !ok {
+		t.Error("Expected another token's release to leave the lock held")
+	}
+
+	clock.Advance(time.Minute)
+	if ok, _ := s.Renew(ctx, "conv-1", "a", time.Minute); ok {
+		t.Error("Expected an expired lock not to be renewed")
+	}
+	if ok, _ := s.Acquire(ctx, "conv-1", "b", time.Minute); !ok {
+		t.Error("Expected an expired lock to be taken over")
+	}
+
+	_ = s.Release(ctx, "conv-1", "b")
+	if ok, _ := s.Acquire(ctx, "conv-1", "c", time.Minute); !ok {
+		t.Error("Expected a released lock to be free")
+	}
+}
+
+func TestMemoryStoreIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s.now = clock.Now
+
+	if _, claimed, _ := s.Claim(ctx, "req-1"); !claimed {
+		t.Fatal("Expected to claim a new key")
+	}
+	if result, claimed, _ := s.Claim(ctx, "req-1"); claimed || result != nil {
+		t.Errorf("Expected a claimed key to be in progress, got %v, %+v", claimed, result)
+	}
+	text := "done"
+	stored := &claudecode.ResultMessage{Subtype: "success", Result: &text}
+	if err := s.Complete(ctx, "req-1", stored); err != nil {
+		t.Fatal(err)
+	}
+	text = "changed"
+	_ = s.Abandon(ctx, "req-1")
+	result, claimed, err := s.Claim(ctx, "req-1")
+	if err != nil || claimed || result == nil || *result.Result != "done" {
+		t.Errorf("Expected the stored result, got %v, %+v, %v", claimed, result, err)
+	}
+
+	// Claims of queries that never finished expire
+	_, _, _ = s.Claim(ctx, "req-2")
+	clock.Advance(DefaultClaimTTL)
+	if _, claimed, _ := s.Claim(ctx, "req-2"); !claimed {
+		t.Error("Expected an expired claim to be taken over")
+	}
+	clock.Advance(DefaultResultTTL)
+	if _, claimed, _ := s.Claim(ctx, "req-1"); !claimed {
+		t.Error("Expected an expired result to be dropped")
+	}
+}
@@ -0,0 +1,178 @@
+package sessionstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// RedisClient runs Lua scripts on a Redis server. It keeps a Redis client
+// library out of the SDK's dependencies; go-redis needs a small adapter:
+//
+//	type redisAdapter struct{ *redis.Client }
+//
+//	func (a redisAdapter) Eval(ctx context.Context, script string, keys []string, args ...any) (any, error) {
+//		return a.Client.Eval(ctx, script, keys, args...).Result()
+//	}
+type RedisClient interface {
+	Eval(ctx context.Context, script string, keys []string, args ...any) (any, error)
+}
+
+// DefaultRedisPrefix prefixes the keys of a RedisStore.
+const DefaultRedisPrefix = "claude:session:"
+
+// RedisStore is a Store, LockStore and claudecode.IdempotencyStore in
+// Redis, for services running on many hosts. Sessions are JSON strings
+// under the prefix followed by "data:" and the key; locks are under the
+// prefix followed by "lock:", and query results under "result:", empty
+// while the key is claimed.
+type RedisStore struct {
+	// ClaimTTL and ResultTTL are how long idempotency keys stay claimed and
+	// results stay stored; the defaults are DefaultClaimTTL and
+	// DefaultResultTTL. Set them before use.
+	ClaimTTL  time.Duration
+	ResultTTL time.Duration
+
+	client RedisClient
+	prefix string
+}
+
+// NewRedisStore returns a RedisStore using client. Keys are prefixed with
+// prefix, or DefaultRedisPrefix when it is empty.
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Scripts return "" or 0 rather than nil, which some clients report as an
+// error.
+const (
+	redisGetScript      = `return redis.call('GET', KEYS[1]) or ''`
+	redisPutScript      = `redis.call('SET', KEYS[1], ARGV[1]) return 1`
+	redisDeleteScript   = `redis.call('DEL', KEYS[1]) return 1`
+	redisAcquireScript  = `if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then return 1 end return 0`
+	redisRenewScript    = `if redis.call('GET', KEYS[1]) == ARGV[1] then redis.call('PEXPIRE', KEYS[1], ARGV[2]) return 1 end return 0`
+	redisReleaseScript  = `if redis.call('GET', KEYS[1]) == ARGV[1] then redis.call('DEL', KEYS[1]) end return 1`
+	redisClaimScript    = `if redis.call('SET', KEYS[1], '', 'NX', 'PX', ARGV[1]) then return 1 end return redis.call('GET', KEYS[1]) or ''`
+	redisCompleteScript = `redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2]) return 1`
+	redisAbandonScript  = `if redis.call('GET', KEYS[1]) == '' then redis.call('DEL', KEYS[1]) end return 1`
+)
+
+// Get returns the session under key.
+func (s *RedisStore) Get(ctx context.Context, key string) (Session, error) {
+	reply, err := s.client.Eval(ctx, redisGetScript, []string{s.prefix + "data:" + key})
+	if err != nil {
+		return Session{}, err
+	}
+	var data []byte
+	switch v := reply.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return Session{}, fmt.Errorf("unexpected redis reply %v", reply)
+	}
+	if len(data) == 0 {
+		return Session{}, ErrNotFound
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return Session{}, fmt.Errorf("decoding session %s: %w", key, err)
+	}
+	return session, nil
+}
+
+// Put saves session.
+func (s *RedisStore) Put(ctx context.Context, session Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("encoding session %s: %w", session.Key, err)
+	}
+	_, err = s.client.Eval(ctx, redisPutScript, []string{s.prefix + "data:" + session.Key}, string(data))
+	return err
+}
+
+// Delete removes the session under key.
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.Eval(ctx, redisDeleteScript, []string{s.prefix + "data:" + key})
+	return err
+}
+
+// Acquire takes the lock on key if it is free or expired.
+func (s *RedisStore) Acquire(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return s.lockScript(ctx, redisAcquireScript, key, token, ttl.Milliseconds())
+}
+
+// Renew extends the lock on key if token holds it.
+func (s *RedisStore) Renew(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	return s.lockScript(ctx, redisRenewScript, key, token, ttl.Milliseconds())
+}
+
+// Release frees the lock on key if token holds it.
+func (s *RedisStore) Release(ctx context.Context, key, token string) error {
+	_, err := s.lockScript(ctx, redisReleaseScript, key, token)
+	return err
+}
+
+// lockScript runs a lock script and reports whether it returned 1.
+func (s *RedisStore) lockScript(ctx context.Context, script, key string, args ...any) (bool, error) {
+	reply, err := s.client.Eval(ctx, script, []string{s.prefix + "lock:" + key}, args...)
+	if err != nil {
+		return false, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return false, fmt.Errorf("unexpected redis reply %v", reply)
+	}
+	return n == 1, nil
+}
+
+// Claim reserves key unless it is claimed, returning the stored result of
+// a completed query with the key.
+func (s *RedisStore) Claim(ctx context.Context, key string) (*claudecode.ResultMessage, bool, error) {
+	ttl := ttlOr(s.ClaimTTL, DefaultClaimTTL)
+	reply, err := s.client.Eval(ctx, redisClaimScript, []string{s.prefix + "result:" + key}, ttl.Milliseconds())
+	if err != nil {
+		return nil, false, err
+	}
+	var data []byte
+	switch v := reply.(type) {
+	case int64:
+		return nil, v == 1, nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return nil, false, fmt.Errorf("unexpected redis reply %v", reply)
+	}
+	if len(data) == 0 {
+		// Claimed by a query still running
+		return nil, false, nil
+	}
+	result, err := decodeResult(key, data)
+	return result, false, err
+}
+
+// Complete stores result under key.
+func (s *RedisStore) Complete(ctx context.Context, key string, result *claudecode.ResultMessage) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("encoding result %s: %w", key, err)
+	}
+	ttl := ttlOr(s.ResultTTL, DefaultResultTTL)
+	_, err = s.client.Eval(ctx, redisCompleteScript, []string{s.prefix + "result:" + key}, string(data), ttl.Milliseconds())
+	return err
+}
+
+// Abandon frees key unless its result is stored.
+func (s *RedisStore) Abandon(ctx context.Context, key string) error {
+	_, err := s.client.Eval(ctx, redisAbandonScript, []string{s.prefix + "result:" + key})
+	return err
+}
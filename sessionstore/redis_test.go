@@ -0,0 +1,181 @@
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// fakeRedis interprets the RedisStore scripts against a map, replying like
+// go-redis does.
+type fakeRedis struct {
+	values  map[string]string
+	expires map[string]int64
+	err     error
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{values: make(map[string]string), expires: make(map[string]int64)}
+}
+
+func (r *fakeRedis) Eval(_ context.Context, script string, keys []string, args ...any) (any, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	key := keys[0]
+	value, ok := r.values[key]
+	switch script {
+	case redisGetScript:
+		return value, nil
+	case redisPutScript:
+		r.values[key] = args[0].(string)
+		return int64(1), nil
+	case redisDeleteScript:
+		delete(r.values, key)
+		return int64(1), nil
+	case redisAcquireScript:
+		if ok {
+			return int64(0), nil
+		}
+		r.values[key], r.expires[key] = args[0].(string), args[1].(int64)
+		return int64(1), nil
+	case redisRenewScript:
+		if !ok || value != args[0] {
+			return int64(0), nil
+		}
+		r.expires[key] = args[1].(int64)
+		return int64(1), nil
+	case redisReleaseScript:
+		if ok && value == args[0] {
+			delete(r.values, key)
+		}
+		return int64(1), nil
+	case redisClaimScript:
+		if ok {
+			return value, nil
+		}
+		r.values[key], r.expires[key] = "", args[0].(int64)
+		return int64(1), nil
+	case redisCompleteScript:
+		r.values[key], r.expires[key] = args[0].(string), args[1].(int64)
+		return int64(1), nil
+	case redisAbandonScript:
+		if ok && value == "" {
+			delete(r.values, key)
+		}
+		return int64(1), nil
+	}
+	return nil, errors.New("unknown script")
+}
+
+func TestRedisStoreSessions(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	s := NewRedisStore(redis, "")
+
+	if _, err := s.Get(ctx, "conv-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	updated := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	want := Session{Key: "conv-1", SessionID: "session-1", Metadata: map[string]string{"tenant": "acme"}, UpdatedAt: updated}
+	if err := s.Put(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := redis.values[DefaultRedisPrefix+"data:conv-1"]; !ok {
+		t.Errorf("Expected the session under the default prefix, got keys %v", redis.values)
+	}
+	got, err := s.Get(ctx, "conv-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SessionID != want.SessionID || got.Metadata["tenant"] != "acme" || !got.UpdatedAt.Equal(updated) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if err := s.Delete(ctx, "conv-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "conv-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+
+	redis.values[DefaultRedisPrefix+"data:bad"] = "{"
+	if _, err := s.Get(ctx, "bad"); err == nil {
+		t.Error("Expected an error decoding a corrupt session")
+	}
+}
+
+func TestRedisStoreLocks(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	s := NewRedisStore(redis, "app:")
+
+	if ok, err := s.Acquire(ctx, "conv-1", "a", 30*time.Second); err != nil || !ok {
+		t.Fatalf("Expected to acquire a free lock, got %v, %v", ok, err)
+	}
+	if redis.values["app:lock:conv-1"] != "a" || redis.expires["app:lock:conv-1"] != 30000 {
+		t.Errorf("Expected the lock under the prefix with a TTL in milliseconds, got %v %v", redis.values, redis.expires)
+	}
+	if ok, _ := s.Acquire(ctx, "conv-1", "b", time.Second); ok {
+		t.Error("Expected a held lock not to be acquired")
+	}
+	if ok, _ := s.Renew(ctx, "conv-1", "b", time.Second); ok {
+		t.Error("Expected another token not to renew the lock")
+	}
+	if ok, _ := s.Renew(ctx, "conv-1", "a", time.Minute); !ok || redis.expires["app:lock:conv-1"] != 60000 {
+		t.Error("Expected the holder to renew the lock")
+	}
+	_ = s.Release(ctx, "conv-1", "b")
+	if redis.values["app:lock:conv-1"] != "a" {
+		t.Error("Expected another token's release to leave the lock held")
+	}
+	if err := s.Release(ctx, "conv-1", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.Acquire(ctx, "conv-1", "b", time.Second); !ok {
+		t.Error("Expected a released lock to be free")
+	}
+
+	redis.err = errors.New("connection refused")
+	if _, err := s.Acquire(ctx, "conv-2", "a", time.Second); !errors.Is(err, redis.err) {
+		t.Errorf("Expected the client's error, got %v", err)
+	}
+}
+
+func TestRedisStoreIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	s := NewRedisStore(redis, "app:")
+
+	if _, claimed, err := s.Claim(ctx, "req-1"); err != nil || !claimed {
+		t.Fatalf("Expected to claim a new key, got %v, %v", claimed, err)
+	}
+	if redis.expires["app:result:req-1"] != DefaultClaimTTL.Milliseconds() {
+		t.Errorf("Expected the claim under the prefix with the default TTL, got %v", redis.expires)
+	}
+	if result, claimed, _ := s.Claim(ctx, "req-1"); claimed || result != nil {
+		t.Errorf("Expected a claimed key to be in progress, got %v, %+v", claimed, result)
+	}
+
+	text := "done"
+	if err := s.Complete(ctx, "req-1", &claudecode.ResultMessage{Subtype: "success", Result: &text}); err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Abandon(ctx, "req-1")
+	result, claimed, err := s.Claim(ctx, "req-1")
+	if err != nil || claimed || result == nil || *result.Result != "done" {
+		t.Errorf("Expected the stored result, got %v, %+v, %v", claimed, result, err)
+	}
+	if redis.expires["app:result:req-1"] != DefaultResultTTL.Milliseconds() {
+		t.Errorf("Expected the result stored with the default TTL, got %v", redis.expires)
+	}
+
+	_, _, _ = s.Claim(ctx, "req-2")
+	_ = s.Abandon(ctx, "req-2")
+	if _, claimed, _ := s.Claim(ctx, "req-2"); !claimed {
+		t.Error("Expected an abandoned key to be claimable")
+	}
+}
@@ -0,0 +1,115 @@
+// Package sessionstore shares conversations between the instances of a
+// horizontally scaled service.
+//
+// A Store maps the service's conversation keys to the CLI sessions that
+// hold them, so whichever instance handles the next request resumes the
+// right session. A Locker makes sure only one instance drives a session at
+// a time: the instance holding a session's Lease renews it in the
+// background, and when that instance fails, the lease expires and another
+// instance takes over.
+//
+//	store := sessionstore.NewRedisStore(redisAdapter{rdb}, "")
+//	locker := sessionstore.NewLocker(store, 0)
+//
+//	lease, err := locker.Lock(ctx, conversationID)
+//	if err != nil {
+//		return err
+//	}
+//	defer lease.Release(context.Background())
+//	ctx, cancel := lease.Context(ctx) // canceled if the lease is lost
+//	defer cancel()
+//
+//	session, err := store.Get(ctx, conversationID)
+//	if err != nil && !errors.Is(err, sessionstore.ErrNotFound) {
+//		return err
+//	}
+//	return claudecode.WithClient(ctx, func(client claudecode.Client) error {
+//		// ...
+//	}, session.ResumeOption(), sessionstore.Track(store, conversationID, nil))
+//
+// MemoryStore and RedisStore are also claudecode.IdempotencyStores, so
+// a query retried on another instance with claudecode.WithIdempotencyKey
+// gets the first one's result:
+//
+//	client := claudecode.NewClient(claudecode.WithIdempotencyStore(store))
+//
+// The CLI keeps session transcripts on local disk, so instances resuming
+// each other's sessions must share the CLI's configuration directory.
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// ErrNotFound is returned by Store.Get for keys without a session.
+var ErrNotFound = errors.New("session not found")
+
+// Session records the CLI session holding a conversation.
+type Session struct {
+	// Key is the service's identifier for the conversation.
+	Key string `json:"key"`
+	// SessionID is the CLI session to resume.
+	SessionID string `json:"session_id"`
+	// Metadata holds the session tags of the client that saved it.
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// ResumeOption returns an option resuming the session, or one that does
+// nothing for a session without an ID, such as the zero Session returned
+// with ErrNotFound.
+func (s Session) ResumeOption() claudecode.Option {
+	if s.SessionID == "" {
+		return func(*claudecode.Options) {}
+	}
+	return claudecode.WithResume(s.SessionID)
+}
+
+// Store keeps sessions by key. Implementations must be safe for concurrent
+// use.
+type Store interface {
+	// Get returns the session under key, or ErrNotFound.
+	Get(ctx context.Context, key string) (Session, error)
+	// Put saves session under its Key, replacing any session there.
+	Put(ctx context.Context, session Session) error
+	// Delete removes the session under key, if any.
+	Delete(ctx context.Context, key string) error
+}
+
+// Track saves the session of a client under key in store when the CLI
+// reports its session ID, along with the client's session tags. Errors
+// saving it go to onError, if not nil.
+func Track(store Store, key string, onError func(error)) claudecode.Option {
+	return func(o *claudecode.Options) {
+		var saved string
+		o.MessageObservers = append(o.MessageObservers, func(msg claudecode.Message) {
+			system, ok := msg.(*claudecode.SystemMessage)
+			if !ok {
+				return
+			}
+			info, ok := system.Init()
+			// The CLI may repeat its init message within a session
+			if !ok || info.SessionID == "" || info.SessionID == saved {
+				return
+			}
+			session := Session{Key: key, SessionID: info.SessionID, UpdatedAt: time.Now().UTC()}
+			if len(o.SessionTags) > 0 {
+				session.Metadata = make(map[string]string, len(o.SessionTags))
+				for k, v := range o.SessionTags {
+					session.Metadata[k] = v
+				}
+			}
+			if err := store.Put(context.Background(), session); err != nil {
+				if onError != nil {
+					onError(err)
+				}
+				return
+			}
+			saved = info.SessionID
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package sessionstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestSessionResumeOption(t *testing.T) {
+	var opts claudecode.Options
+	Session{}.ResumeOption()(&opts)
+	if opts.Resume != nil {
+		t.Errorf("Expected no resume for a session without an ID, got %q", *opts.Resume)
+	}
+
+	Session{Key: "conv-1", SessionID: "session-1"}.ResumeOption()(&opts)
+	if opts.Resume == nil || *opts.Resume != "session-1" {
+		t.Errorf("Expected to resume session-1, got %v", opts.Resume)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	opts := claudecode.NewOptions(
+		claudecode.WithSessionTags(map[string]string{"tenant": "acme"}),
+		Track(store, "conv-1", nil),
+	)
+
+	observe := func(msg claudecode.Message) {
+		t.Helper()
+		for _, observer := range opts.MessageObservers {
+			observer(msg)
+		}
+	}
+	observe(&claudecode.AssistantMessage{})
+	if _, err := store.Get(ctx, "conv-1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected nothing saved before the init message, got %v", err)
+	}
+
+	observe(initMessage("session-1"))
+	session, err := store.Get(ctx, "conv-1")
+	if err != nil {
+		t.Fatalf("Expected the session to be saved, got %v", err)
+	}
+	if session.Key != "conv-1" || session.SessionID != "session-1" || session.UpdatedAt.IsZero() {
+		t.Errorf("Unexpected session %+v", session)
+	}
+	if session.Metadata["tenant"] != "acme" {
+		t.Errorf("Expected the session tags in the metadata, got %v", session.Metadata)
+	}
+
+	// A new session ID, as when the CLI forks a resumed session, replaces it
+	observe(initMessage("session-2"))
+	if session, _ := store.Get(ctx, "conv-1"); session.SessionID != "session-2" {
+		t.Errorf("Expected session-2, got %q", session.SessionID)
+	}
+}
+
+func TestTrackReportsErrors(t *testing.T) {
+	failure := errors.New("store down")
+	var got []error
+	opts := claudecode.NewOptions(Track(failingStore{failure}, "conv-1", func(err error) {
+		got = append(got, err)
+	}))
+	for i := 0; i < 2; i++ {
+		for _, observer := range opts.MessageObservers {
+			observer(initMessage("session-1"))
+		}
+	}
+	// Saving is retried on the next init message after a failure
+	if len(got) != 2 || !errors.Is(got[0], failure) {
+		t.Errorf("Expected two store errors, got %v", got)
+	}
+}
+
+func initMessage(sessionID string) *claudecode.SystemMessage {
+	return &claudecode.SystemMessage{
+		Subtype: claudecode.SystemSubtypeInit,
+		Data:    map[string]any{"session_id": sessionID},
+	}
+}
+
+// failingStore is a Store that always fails.
+type failingStore struct{ err error }
+
+func (s failingStore) Get(context.Context, string) (Session, error) { return Session{}, s.err }
+func (s failingStore) Put(context.Context, Session) error           { return s.err }
+func (s failingStore) Delete(context.Context, string) error         { return s.err }
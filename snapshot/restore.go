@@ -0,0 +1,179 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ChangeKind is how a file differs from the snapshot.
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeModified ChangeKind = "modified"
+	ChangeDeleted  ChangeKind = "deleted"
+)
+
+// Change is a file that differs from the snapshot.
+type Change struct {
+	Path string
+	Kind ChangeKind
+	// Restorable is false for a modified or deleted file whose content was
+	// over the size limits.
+	Restorable bool
+}
+
+// Changes compares the allowed directories with the snapshot and returns
+// the files added, modified or deleted since, sorted by path.
+func (s *Snapshot) Changes() ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.captured {
+		return nil, ErrNotCaptured
+	}
+	return s.changes()
+}
+
+// Restore puts the file at path back as it was in the snapshot, removing it
+// if it did not exist then. Directories created for it are removed once
+// empty.
+func (s *Snapshot) Restore(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.captured {
+		return ErrNotCaptured
+	}
+	path = s.resolve(path)
+	if _, ok := s.covered(path); !ok {
+		return fmt.Errorf("%s: %w", path, ErrOutsideSnapshot)
+	}
+
+	state := s.files[path]
+	if state != nil && !state.Stored {
+		changed, err := s.changed(state)
+		if err != nil || !changed {
+			return err
+		}
+		return fmt.Errorf("%s: %w", path, ErrNotStored)
+	}
+	return s.restore(path, state)
+}
+
+// RestoreAll puts every changed file back as it was in the snapshot and
+// removes files added since. Nothing is changed if a file cannot be
+// restored because its content was not stored.
+func (s *Snapshot) RestoreAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.captured {
+		return ErrNotCaptured
+	}
+
+	changes, err := s.changes()
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if !change.Restorable {
+			return fmt.Errorf("%s: %w", change.Path, ErrNotStored)
+		}
+	}
+	for _, change := range changes {
+		if err := s.restore(change.Path, s.files[change.Path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Snapshot) changes() ([]Change, error) {
+	var changes []Change
+	for path, state := range s.files {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			changes = append(changes, Change{Path: path, Kind: ChangeDeleted, Restorable: state.Stored})
+			continue
+		}
+		changed, err := s.changed(state)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			changes = append(changes, Change{Path: path, Kind: ChangeModified, Restorable: state.Stored})
+		}
+	}
+
+	for _, dir := range s.dirs {
+		err := s.walk(dir, func(path string, entry fs.DirEntry) error {
+			if !entry.IsDir() && s.files[path] == nil {
+				changes = append(changes, Change{Path: path, Kind: ChangeAdded, Restorable: true})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// changed reports whether the file differs from its recorded state.
+func (s *Snapshot) changed(state *FileState) (bool, error) {
+	info, err := os.Stat(state.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != state.Size || info.Mode().Perm() != state.Mode {
+		return true, nil
+	}
+	sum, err := fileChecksum(state.Path)
+	if err != nil {
+		return false, err
+	}
+	return sum != state.SHA256, nil
+}
+
+// restore writes the recorded content of path, or removes it when state is
+// nil, then removes directories created since the snapshot that are empty.
+func (s *Snapshot) restore(path string, state *FileState) error {
+	if state == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		s.pruneDirs(filepath.Dir(path))
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, state.content, state.Mode); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(path, state.Mode)
+}
+
+// pruneDirs removes dir and its parents while they are empty and were not
+// in the snapshot.
+func (s *Snapshot) pruneDirs(dir string) {
+	for !s.dirsSeen[dir] {
+		if _, ok := s.covered(dir); !ok {
+			return
+		}
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,176 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/severity1/claude-code-sdk-go/internal/testfs"
+)
+
+func TestChanges(t *testing.T) {
+	root := t.TempDir()
+	testfs.Write(t, root, "same.txt", "same")
+	testfs.Write(t, root, "edited.txt", "original")
+	testfs.Write(t, root, "deleted.txt", "gone soon")
+	snap := capture(t, root)
+
+	testfs.Write(t, root, "edited.txt", "changed!")
+	testfs.Write(t, root, "new/created.txt", "new")
+	if err := os.Remove(filepath.Join(root, "deleted.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := snap.Changes()
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	var got []string
+	for _, change := range changes {
+		rel, _ := filepath.Rel(root, change.Path)
+		got = append(got, string(change.Kind)+" "+filepath.ToSlash(rel))
+	}
+	want := "deleted deleted.txt,modified edited.txt,added new/created.txt"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, ","))
+	}
+}
+
+func TestRestore(t *testing.T) {
+	root := t.TempDir()
+	testfs.Write(t, root, "edited.txt", "original")
+	testfs.Write(t, root, "deleted.txt", "deleted")
+	snap := capture(t, root)
+
+	testfs.Write(t, root, "edited.txt", "changed")
+	testfs.Write(t, root, "new/dir/created.txt", "new")
+	if err := os.Remove(filepath.Join(root, "deleted.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"edited.txt", "deleted.txt", filepath.Join(root, "new", "dir", "created.txt")} {
+		if err := snap.Restore(path); err != nil {
+			t.Fatalf("Restore(%s) failed: %v", path, err)
+		}
+	}
+	if got := testfs.Read(t, root, "edited.txt"); got != "original" {
+		t.Errorf("Expected edited.txt restored, got %q", got)
+	}
+	if got := testfs.Read(t, root, "deleted.txt"); got != "deleted" {
+		t.Errorf("Expected deleted.txt restored, got %q", got)
+	}
+	if testfs.Exists(root, "new") {
+		t.Error("Expected the created file and its directories removed")
+	}
+}
+
+func TestRestoreAll(t *testing.T) {
+	root := t.TempDir()
+	testfs.Write(t, root, "a.txt", "a")
+	testfs.Write(t, root, "src/b.txt", "b")
+	testfs.Write(t, root, "cache/skip.tmp", "cached")
+	snap := capture(t, root, WithExclude("*.tmp"))
+
+	testfs.Write(t, root, "a.txt", "changed")
+	testfs.Write(t, root, "src/c.txt", "new")
+	testfs.Write(t, root, "cache/other.tmp", "ignored")
+	if err := os.Remove(filepath.Join(root, "src", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := snap.RestoreAll(); err != nil {
+		t.Fatalf("RestoreAll failed: %v", err)
+	}
+	if got := testfs.Read(t, root, "a.txt"); got != "a" {
+		t.Errorf("Expected a.txt restored, got %q", got)
+	}
+	if got := testfs.Read(t, root, "src/b.txt"); got != "b" {
+		t.Errorf("Expected src/b.txt restored, got %q", got)
+	}
+	if testfs.Exists(root, "src/c.txt") {
+		t.Error("Expected the added file removed")
+	}
+	if !testfs.Exists(root, "cache/other.tmp") {
+		t.Error("Expected excluded files left alone")
+	}
+	if changes, err := snap.Changes(); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no changes after RestoreAll, got %v, %v", changes, err)
+	}
+}
+
+func TestRestoreMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not preserved on Windows")
+	}
+	root := t.TempDir()
+	testfs.Write(t, root, "run.sh", "#!/bin/sh\n")
+	if err := os.Chmod(filepath.Join(root, "run.sh"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	snap := capture(t, root)
+
+	if err := os.Chmod(filepath.Join(root, "run.sh"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := snap.RestoreAll(); err != nil {
+		t.Fatalf("RestoreAll failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(root, "run.sh"))
+	if err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("Expected mode 0755 restored, got %v, %v", info.Mode().Perm(), err)
+	}
+}
+
+func TestRestoreNotStored(t *testing.T) {
+	root := t.TempDir()
+	testfs.Write(t, root, "big.bin", strings.Repeat("x", 100))
+	testfs.Write(t, root, "small.txt", "small")
+	snap := capture(t, root, WithMaxFileSize(10))
+
+	// Unchanged files need no content
+	if err := snap.Restore("big.bin"); err != nil {
+		t.Errorf("Expected an unchanged file to restore, got %v", err)
+	}
+
+	testfs.Write(t, root, "big.bin", strings.Repeat("y", 100))
+	testfs.Write(t, root, "small.txt", "changed")
+	changes, err := snap.Changes()
+	if err != nil || len(changes) != 2 || changes[0].Restorable {
+		t.Fatalf("Expected big.bin reported as not restorable, got %+v, %v", changes, err)
+	}
+	if err := snap.Restore("big.bin"); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Expected ErrNotStored, got %v", err)
+	}
+	if err := snap.RestoreAll(); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Expected ErrNotStored, got %v", err)
+	}
+	if got := testfs.Read(t, root, "small.txt"); got != "changed" {
+		t.Errorf("Expected nothing restored when a file cannot be, got %q", got)
+	}
+}
+
+func TestRestoreNotCaptured(t *testing.T) {
+	snap := New(t.TempDir())
+	if err := snap.Restore("a.txt"); !errors.Is(err, ErrNotCaptured) {
+		t.Errorf("Expected ErrNotCaptured from Restore, got %v", err)
+	}
+	if err := snap.RestoreAll(); !errors.Is(err, ErrNotCaptured) {
+		t.Errorf("Expected ErrNotCaptured from RestoreAll, got %v", err)
+	}
+	if _, err := snap.Changes(); !errors.Is(err, ErrNotCaptured) {
+		t.Errorf("Expected ErrNotCaptured from Changes, got %v", err)
+	}
+}
+
+func capture(t *testing.T, root string, opts ...Option) *Snapshot {
+	t.Helper()
+	snap := New(root, opts...)
+	if err := snap.Capture(context.Background()); err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	return snap
+}
@@ -0,0 +1,337 @@
+// Package snapshot records the files an agent may touch before it touches
+// them, so they can be restored to their state before the session without
+// relying on git.
+//
+// A Snapshot walks its allowed directories once, before the first tool
+// runs, and keeps each file's checksum and content. Restore and RestoreAll
+// put files back, removing those created since:
+//
+//	snap := snapshot.New(workDir, snapshot.WithExclude("node_modules", "*.log"))
+//	client := claudecode.NewClient(claudecode.WithCwd(workDir))
+//	_ = client.GetHookSystem().AddHook(string(claudecode.HookEventTypePreToolUse), snap.Hook())
+//	// ... run turns ...
+//	changed, _ := snap.Changes()
+//	if err := snap.RestoreAll(); err != nil {
+//		return err
+//	}
+//
+// Content is only kept for files within the size limits; larger files are
+// recorded by checksum, so changes to them are detected but cannot be
+// undone. Only regular files are recorded: symbolic links are left alone.
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Default size limits.
+const (
+	DefaultMaxFileSize  int64 = 1 << 20  // 1MB
+	DefaultMaxTotalSize int64 = 64 << 20 // 64MB
+)
+
+var (
+	// ErrNotCaptured is returned when restoring before the snapshot was
+	// taken.
+	ErrNotCaptured = errors.New("snapshot not captured")
+	// ErrOutsideSnapshot is returned for a path outside the allowed
+	// directories or matched by an exclusion glob.
+	ErrOutsideSnapshot = errors.New("path not covered by the snapshot")
+	// ErrNotStored is returned when a changed file cannot be restored
+	// because its content was over the size limits.
+	ErrNotStored = errors.New("original content not stored")
+)
+
+// FileState is a file as recorded in the snapshot.
+type FileState struct {
+	Path   string
+	Size   int64
+	Mode   fs.FileMode
+	SHA256 string
+	// Stored is false when the content was over the size limits.
+	Stored bool
+
+	content []byte
+}
+
+// Option configures a Snapshot.
+type Option func(*Snapshot)
+
+// WithAllowedDirs adds directories to record besides the root, like
+// claudecode.WithAddDirs does for the CLI.
+func WithAllowedDirs(dirs ...string) Option {
+	return func(s *Snapshot) {
+		s.dirs = append(s.dirs, dirs...)
+	}
+}
+
+// WithExclude skips files and directories matching any of the globs. A glob
+// without a slash matches any file or directory name, such as "*.log" or
+// "node_modules"; one with a slash matches the path from the allowed
+// directory, such as "build/*.o".
+func WithExclude(globs ...string) Option {
+	return func(s *Snapshot) {
+		s.exclude = append(s.exclude, globs...)
+	}
+}
+
+// WithMaxFileSize sets the size above which a file is recorded by checksum
+// only. The default is DefaultMaxFileSize.
+func WithMaxFileSize(bytes int64) Option {
+	return func(s *Snapshot) {
+		s.maxFileSize = bytes
+	}
+}
+
+// WithMaxTotalSize bounds the content kept across all files; once reached,
+// further files are recorded by checksum only. The default is
+// DefaultMaxTotalSize.
+func WithMaxTotalSize(bytes int64) Option {
+	return func(s *Snapshot) {
+		s.maxTotalSize = bytes
+	}
+}
+
+// Snapshot holds the state of the allowed directories before the session.
+// It is safe for concurrent use.
+type Snapshot struct {
+	root         string
+	dirs         []string
+	exclude      []string
+	maxFileSize  int64
+	maxTotalSize int64
+
+	mu       sync.Mutex
+	captured bool
+	files    map[string]*FileState
+	dirsSeen map[string]bool
+	stored   int64
+}
+
+// New returns a Snapshot of root and any WithAllowedDirs directories.
+// Relative paths given to it resolve against root. Nothing is recorded
+// until the first PreToolUse hook or Capture.
+func New(root string, opts ...Option) *Snapshot {
+	s := &Snapshot{
+		root:         root,
+		maxFileSize:  DefaultMaxFileSize,
+		maxTotalSize: DefaultMaxTotalSize,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if abs, err := filepath.Abs(root); err == nil {
+		s.root = abs
+	}
+	dirs := []string{filepath.Clean(s.root)}
+	for _, dir := range s.dirs {
+		dirs = append(dirs, s.resolve(dir))
+	}
+	s.dirs = dirs
+	return s
+}
+
+// Hook returns a PreToolUse hook that takes the snapshot before the first
+// tool runs. A failed snapshot fails the hook, so the tool is not run
+// unprotected. Other events are ignored.
+func (s *Snapshot) Hook() claudecode.HookCallback {
+	return func(ctx context.Context, input interface{}, _ claudecode.HookContext) (claudecode.HookOutput, error) {
+		switch input.(type) {
+		case claudecode.PreToolUseHookInput, *claudecode.PreToolUseHookInput:
+			if err := s.Capture(ctx); err != nil {
+				return claudecode.HookOutput{}, err
+			}
+		}
+		return claudecode.HookOutput{Behavior: claudecode.HookBehaviorContinue}, nil
+	}
+}
+
+// Capture records the allowed directories. Only the first successful call
+// records anything.
+func (s *Snapshot) Capture(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.captured {
+		return nil
+	}
+
+	s.files = make(map[string]*FileState)
+	s.dirsSeen = make(map[string]bool)
+	s.stored = 0
+	for _, dir := range s.dirs {
+		err := s.walk(dir, func(path string, entry fs.DirEntry) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				s.dirsSeen[path] = true
+				return nil
+			}
+			return s.record(path)
+		})
+		if err != nil {
+			return fmt.Errorf("snapshot %s: %w", dir, err)
+		}
+	}
+	s.captured = true
+	return nil
+}
+
+// Captured reports whether the snapshot has been taken.
+func (s *Snapshot) Captured() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.captured
+}
+
+// File returns the recorded state of path, or false if the file did not
+// exist when the snapshot was taken or is not covered by it.
+func (s *Snapshot) File(path string) (FileState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.files[s.resolve(path)]
+	if !ok {
+		return FileState{}, false
+	}
+	return *state, true
+}
+
+func (s *Snapshot) record(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	state := &FileState{Path: path, Size: info.Size(), Mode: info.Mode().Perm()}
+	if state.Size <= s.maxFileSize && s.stored+state.Size <= s.maxTotalSize {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		state.Size = int64(len(data))
+		state.SHA256 = checksum(data)
+		state.Stored = true
+		state.content = data
+		s.stored += state.Size
+	} else if state.SHA256, err = fileChecksum(path); err != nil {
+		return err
+	}
+	s.files[path] = state
+	return nil
+}
+
+// walk calls fn for each directory and regular file under dir, skipping
+// excluded ones. A missing dir has nothing to walk.
+func (s *Snapshot) walk(dir string, fn func(path string, entry fs.DirEntry) error) error {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && s.excluded(dir, path) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() && !entry.Type().IsRegular() {
+			return nil
+		}
+		return fn(path, entry)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, statErr := os.Stat(dir); errors.Is(statErr, fs.ErrNotExist) {
+			return nil
+		}
+	}
+	return err
+}
+
+// covered returns the allowed directory holding path, or false if path is
+// outside them or excluded.
+func (s *Snapshot) covered(path string) (string, bool) {
+	for _, dir := range s.dirs {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if rel == "." || !s.excluded(dir, path) {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// excluded reports whether path, inside dir, matches an exclusion glob,
+// either itself or through one of its parent directories.
+func (s *Snapshot) excluded(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, glob := range s.exclude {
+		if strings.Contains(glob, "/") {
+			// Match the path and each of its parents
+			for p := rel; p != "."; p = pathDir(p) {
+				if ok, _ := filepath.Match(glob, p); ok {
+					return true
+				}
+			}
+			continue
+		}
+		for _, name := range strings.Split(rel, "/") {
+			if ok, _ := filepath.Match(glob, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func pathDir(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[:i]
+	}
+	return "."
+}
+
+func (s *Snapshot) resolve(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.root, path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return filepath.Clean(path)
+}
+
+// fileChecksum hashes a file without reading it into memory.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,160 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+	"github.com/severity1/claude-code-sdk-go/internal/testfs"
+)
+
+func TestCaptureRecordsFiles(t *testing.T) {
+	root := t.TempDir()
+	testfs.Write(t, root, "main.go", "package main\n")
+	testfs.Write(t, root, "big.bin", strings.Repeat("x", 100))
+	testfs.Write(t, root, "node_modules/dep/index.js", "module.exports = 1\n")
+	testfs.Write(t, root, "logs/app.log", "log line\n")
+	testfs.Write(t, root, "build/out.o", "object")
+	testfs.Write(t, root, "build/keep.txt", "keep")
+
+	snap := New(root, WithExclude("node_modules", "*.log", "build/*.o"), WithMaxFileSize(50))
+	if snap.Captured() {
+		t.Fatal("Expected nothing recorded before Capture")
+	}
+	if err := snap.Capture(context.Background()); err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	main, ok := snap.File("main.go")
+	if !ok || !main.Stored || main.Size != int64(len("package main\n")) || main.SHA256 == "" {
+		t.Errorf("Expected main.go stored, got %+v", main)
+	}
+	if big, ok := snap.File("big.bin"); !ok || big.Stored || big.SHA256 == "" {
+		t.Errorf("Expected big.bin recorded by checksum only, got %+v", big)
+	}
+	if _, ok := snap.File("build/keep.txt"); !ok {
+		t.Error("Expected build/keep.txt recorded")
+	}
+	for _, excluded := range []string{"node_modules/dep/index.js", "logs/app.log", "build/out.o"} {
+		if _, ok := snap.File(excluded); ok {
+			t.Errorf("Expected %s excluded", excluded)
+		}
+	}
+}
+
+func TestCaptureOnlyOnce(t *testing.T) {
+	root := t.TempDir()
+	testfs.Write(t, root, "a.txt", "before")
+	snap := New(root)
+
+	if err := snap.Capture(context.Background()); err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	testfs.Write(t, root, "a.txt", "after")
+	if err := snap.Capture(context.Background()); err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	if err := snap.Restore("a.txt"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got := testfs.Read(t, root, "a.txt"); got != "before" {
+		t.Errorf("Expected the first capture kept, got %q", got)
+	}
+}
+
+func TestMaxTotalSize(t *testing.T) {
+	root := t.TempDir()
+	testfs.Write(t, root, "a.txt", strings.Repeat("a", 30))
+	testfs.Write(t, root, "b.txt", strings.Repeat("b", 30))
+	snap := New(root, WithMaxTotalSize(40))
+	if err := snap.Capture(context.Background()); err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	// Files are walked in lexical order
+	a, _ := snap.File("a.txt")
+	b, _ := snap.File("b.txt")
+	if !a.Stored || b.Stored {
+		t.Errorf("Expected only a.txt within the total limit, got %v and %v", a.Stored, b.Stored)
+	}
+}
+
+func TestAllowedDirs(t *testing.T) {
+	root, extra, outside := t.TempDir(), t.TempDir(), t.TempDir()
+	testfs.Write(t, extra, "shared.txt", "shared")
+	testfs.Write(t, outside, "other.txt", "other")
+	snap := New(root, WithAllowedDirs(extra, filepath.Join(root, "missing")))
+	if err := snap.Capture(context.Background()); err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+
+	if _, ok := snap.File(filepath.Join(extra, "shared.txt")); !ok {
+		t.Error("Expected files in an allowed directory recorded")
+	}
+	if err := snap.Restore(filepath.Join(outside, "other.txt")); !errors.Is(err, ErrOutsideSnapshot) {
+		t.Errorf("Expected ErrOutsideSnapshot, got %v", err)
+	}
+}
+
+func TestHook(t *testing.T) {
+	root := t.TempDir()
+	testfs.Write(t, root, "a.txt", "a")
+	snap := New(root)
+	hook := snap.Hook()
+
+	output, err := hook(context.Background(), claudecode.PostToolUseHookInput{ToolName: "Edit"}, claudecode.HookContext{})
+	if err != nil || output.Behavior != claudecode.HookBehaviorContinue || snap.Captured() {
+		t.Fatalf("Expected other events ignored, got %+v, %v", output, err)
+	}
+
+	hooks := claudecode.NewHookSystem()
+	if err := hooks.AddHook(string(claudecode.HookEventTypePreToolUse), hook); err != nil {
+		t.Fatal(err)
+	}
+	input := &claudecode.PreToolUseHookInput{
+		HookEventName: claudecode.HookEventTypePreToolUse,
+		ToolName:      "Write",
+		ToolInput:     map[string]any{"file_path": filepath.Join(root, "a.txt")},
+	}
+	result, err := hooks.ExecuteHooks(context.Background(), claudecode.HookEventTypePreToolUse, input)
+	if err != nil || result.Behavior != claudecode.HookBehaviorContinue {
+		t.Fatalf("Expected the tool to continue, got %+v, %v", result, err)
+	}
+	if !snap.Captured() {
+		t.Error("Expected the snapshot taken before the first tool")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New(root).Hook()(ctx, input, claudecode.HookContext{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a failed snapshot to fail the hook, got %v", err)
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	dir := filepath.FromSlash("/work")
+	snap := &Snapshot{exclude: []string{".git", "*.tmp", "dist/*"}}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"main.go", false},
+		{".git", true},
+		{".git/HEAD", true},
+		{"src/cache.tmp", true},
+		{"dist/app.js", true},
+		{"dist/assets/logo.png", true},
+		{"src/dist/app.js", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if got := snap.excluded(dir, filepath.Join(dir, filepath.FromSlash(test.path))); got != test.want {
+				t.Errorf("Expected excluded(%q) = %v, got %v", test.path, test.want, got)
+			}
+		})
+	}
+}
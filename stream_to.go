@@ -0,0 +1,286 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// StreamFormat selects how StreamTo writes a response.
+type StreamFormat int
+
+const (
+	// StreamPlain writes the assistant text as is, one text block per
+	// paragraph.
+	StreamPlain StreamFormat = iota
+	// StreamMarkdown writes the assistant text as is and tool summaries as
+	// block quotes.
+	StreamMarkdown
+	// StreamJSONLines writes one JSON object per line: "text", "tool_use",
+	// "tool_result" and a final "result".
+	StreamJSONLines
+)
+
+// DefaultStreamSummaryLength is the rune limit of a tool result summary
+// written by StreamTo.
+const DefaultStreamSummaryLength = 80
+
+// StreamOptions configures StreamTo.
+type StreamOptions struct {
+	Format StreamFormat
+	// ToolSummaries also writes a line for each tool call and its result.
+	ToolSummaries bool
+	// SummaryLength limits tool result summaries, in runes. Zero means
+	// DefaultStreamSummaryLength.
+	SummaryLength int
+}
+
+// StreamTo writes the response to the current query to w as it arrives,
+// and returns its result once the turn ends:
+//
+//	if err := client.Query(ctx, "Explain this repository"); err != nil {
+//	    return err
+//	}
+//	result, err := client.(*claudecode.ClientImpl).StreamTo(ctx, os.Stdout, claudecode.StreamOptions{
+//	    Format:        claudecode.StreamMarkdown,
+//	    ToolSummaries: true,
+//	})
+//
+// Text of subagents, which report to the agent rather than to the user, is
+// left out. Writers with a Flush method, such as *bufio.Writer and
+// http.ResponseWriter, are flushed after each message.
+func (c *ClientImpl) StreamTo(ctx context.Context, w io.Writer, opts StreamOptions) (*ResultMessage, error) {
+	iter := c.ReceiveResponse(ctx)
+	if iter == nil {
+		return nil, fmt.Errorf("client not connected")
+	}
+	defer iter.Close()
+
+	sw := newStreamWriter(w, opts)
+	for {
+		msg, err := iter.Next(ctx)
+		if errors.Is(err, ErrNoMoreMessages) {
+			return nil, fmt.Errorf("stream ended without a result")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := sw.write(msg); err != nil {
+			return nil, fmt.Errorf("stream write: %w", err)
+		}
+		if result, ok := msg.(*ResultMessage); ok {
+			return result, nil
+		}
+	}
+}
+
+// streamWriter formats messages for StreamTo.
+type streamWriter struct {
+	w    io.Writer
+	opts StreamOptions
+	// Names of the tools awaiting results, by tool use ID
+	tools map[string]string
+	// Whether anything was written, to separate paragraphs
+	started bool
+}
+
+func newStreamWriter(w io.Writer, opts StreamOptions) *streamWriter {
+	if opts.SummaryLength <= 0 {
+		opts.SummaryLength = DefaultStreamSummaryLength
+	}
+	return &streamWriter{w: w, opts: opts, tools: make(map[string]string)}
+}
+
+// streamLine is a line written in StreamJSONLines format.
+type streamLine struct {
+	Type      string         `json:"type"`
+	Text      string         `json:"text,omitempty"`
+	ID        string         `json:"id,omitempty"`
+	Name      string         `json:"name,omitempty"`
+	Input     map[string]any `json:"input,omitempty"`
+	IsError   bool           `json:"is_error,omitempty"`
+	Summary   string         `json:"summary,omitempty"`
+	SessionID string         `json:"session_id,omitempty"`
+	CostUSD   *float64       `json:"cost_usd,omitempty"`
+	NumTurns  int            `json:"num_turns,omitempty"`
+}
+
+func (sw *streamWriter) write(msg Message) error {
+	var b strings.Builder
+	switch m := msg.(type) {
+	case *AssistantMessage:
+		for _, block := range m.Content {
+			switch block := block.(type) {
+			case *TextBlock:
+				if block.Text != "" && m.ParentToolUseID == nil {
+					sw.text(&b, block.Text)
+				}
+			case *ToolUseBlock:
+				sw.tools[block.ToolUseID] = block.Name
+				if sw.opts.ToolSummaries {
+					sw.toolUse(&b, block)
+				}
+			}
+		}
+	case *UserMessage:
+		blocks, _ := m.Content.([]ContentBlock)
+		for _, block := range blocks {
+			if result, ok := block.(*ToolResultBlock); ok {
+				name := sw.tools[result.ToolUseID]
+				delete(sw.tools, result.ToolUseID)
+				if sw.opts.ToolSummaries {
+					sw.toolResult(&b, name, result)
+				}
+			}
+		}
+	case *ResultMessage:
+		sw.result(&b, m)
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	if _, err := io.WriteString(sw.w, b.String()); err != nil {
+		return err
+	}
+	return flush(sw.w)
+}
+
+func (sw *streamWriter) text(b *strings.Builder, text string) {
+	if sw.opts.Format == StreamJSONLines {
+		writeStreamLine(b, streamLine{Type: "text", Text: text})
+		return
+	}
+	sw.paragraph(b)
+	b.WriteString(strings.TrimRight(text, "\n"))
+	b.WriteString("\n")
+}
+
+func (sw *streamWriter) toolUse(b *strings.Builder, block *ToolUseBlock) {
+	switch sw.opts.Format {
+	case StreamJSONLines:
+		writeStreamLine(b, streamLine{Type: "tool_use", ID: block.ToolUseID, Name: block.Name, Input: block.Input})
+	case StreamMarkdown:
+		sw.paragraph(b)
+		if arg := toolArgument(block); arg != "" {
+			fmt.Fprintf(b, "> **%s** `%s`\n", block.Name, arg)
+		} else {
+			fmt.Fprintf(b, "> **%s**\n", block.Name)
+		}
+	default:
+		sw.paragraph(b)
+		if arg := toolArgument(block); arg != "" {
+			fmt.Fprintf(b, "[%s: %s]\n", block.Name, arg)
+		} else {
+			fmt.Fprintf(b, "[%s]\n", block.Name)
+		}
+	}
+}
+
+func (sw *streamWriter) toolResult(b *strings.Builder, name string, result *ToolResultBlock) {
+	summary := summarizeLine(result.Text(), sw.opts.SummaryLength)
+	if sw.opts.Format == StreamJSONLines {
+		writeStreamLine(b, streamLine{
+			Type:    "tool_result",
+			ID:      result.ToolUseID,
+			Name:    name,
+			IsError: result.Failed(),
+			Summary: summary,
+		})
+		return
+	}
+
+	if name == "" {
+		name = "tool"
+	}
+	status := "done"
+	if result.Failed() {
+		status = "failed"
+	}
+	line := name + " " + status
+	if summary != "" {
+		line += ": " + summary
+	}
+	sw.paragraph(b)
+	if sw.opts.Format == StreamMarkdown {
+		fmt.Fprintf(b, "> %s\n", line)
+	} else {
+		fmt.Fprintf(b, "[%s]\n", line)
+	}
+}
+
+// result writes the end of the turn, which only JSON lines mark.
+func (sw *streamWriter) result(b *strings.Builder, result *ResultMessage) {
+	if sw.opts.Format != StreamJSONLines {
+		return
+	}
+	writeStreamLine(b, streamLine{
+		Type:      "result",
+		IsError:   result.IsError,
+		SessionID: result.SessionID,
+		CostUSD:   result.TotalCostUSD,
+		NumTurns:  result.NumTurns,
+	})
+}
+
+// paragraph separates what follows from what was already written with a
+// blank line.
+func (sw *streamWriter) paragraph(b *strings.Builder) {
+	if sw.started {
+		b.WriteString("\n")
+	}
+	sw.started = true
+}
+
+func writeStreamLine(b *strings.Builder, line streamLine) {
+	data, err := json.Marshal(line)
+	if err != nil {
+		// Tool input that cannot be encoded is left out
+		line.Input = nil
+		data, _ = json.Marshal(line)
+	}
+	b.Write(data)
+	b.WriteString("\n")
+}
+
+// toolArgument returns the main argument of a built-in tool call, such as
+// the command of Bash or the path of Read, or "" for other tools.
+func toolArgument(block *ToolUseBlock) string {
+	tool, ok := builtinTools[block.Name]
+	if !ok || len(tool.required) == 0 {
+		return ""
+	}
+	arg, _ := block.Input[tool.required[0]].(string)
+	return summarizeLine(arg, DefaultStreamSummaryLength)
+}
+
+// summarizeLine returns the first non-blank line of text, cut to limit
+// runes.
+func summarizeLine(text string, limit int) string {
+	var line string
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			line = l
+			break
+		}
+	}
+	if limit <= 0 || utf8.RuneCountInString(line) <= limit {
+		return line
+	}
+	runes := []rune(line)
+	return string(runes[:limit-1]) + "…"
+}
+
+// flush flushes writers that buffer, ignoring those that do not.
+func flush(w io.Writer) error {
+	switch f := w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Flush() }:
+		f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package claudecode
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientStreamToFormats(t *testing.T) {
+	cost := 0.01
+	tests := []struct {
+		name   string
+		opts   StreamOptions
+		expect string
+	}{
+		{
+			name:   "plain",
+			opts:   StreamOptions{},
+			expect: "Listing files.\n\nDone.\n",
+		},
+		{
+			name:   "plain with tool summaries",
+			opts:   StreamOptions{ToolSummaries: true},
+			expect: "Listing files.\n\n[Bash: ls -la]\n\n[Bash done: go.mod]\n\nDone.\n",
+		},
+		{
+			name:   "markdown with tool summaries",
+			opts:   StreamOptions{Format: StreamMarkdown, ToolSummaries: true},
+			expect: "Listing files.\n\n> **Bash** `ls -la`\n\n> Bash done: go.mod\n\nDone.\n",
+		},
+		{
+			name: "json lines",
+			opts: StreamOptions{Format: StreamJSONLines},
+			expect: `{"type":"text","text":"Listing files."}` + "\n" +
+				`{"type":"text","text":"Done."}` + "\n" +
+				`{"type":"result","session_id":"s1","cost_usd":0.01,"num_turns":2}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := setupStreamToTestContext(t)
+			defer cancel()
+
+			client := NewClientWithTransport(newClientMockTransportWithOptions(
+				WithClientResponseMessages(streamToTestMessages(&cost)),
+			)).(*ClientImpl)
+			connectClientSafely(ctx, t, client)
+			defer disconnectClientSafely(t, client)
+
+			var out bytes.Buffer
+			result, err := client.StreamTo(ctx, &out, test.opts)
+			assertNoError(t, err)
+			if result == nil || result.SessionID != "s1" {
+				t.Fatalf("Expected the result of session s1, got %+v", result)
+			}
+			if out.String() != test.expect {
+				t.Errorf("Expected output:\n%q\ngot:\n%q", test.expect, out.String())
+			}
+		})
+	}
+}
+
+func TestClientStreamToJSONLinesToolEvents(t *testing.T) {
+	ctx, cancel := setupStreamToTestContext(t)
+	defer cancel()
+
+	client := NewClientWithTransport(newClientMockTransportWithOptions(
+		WithClientResponseMessages(streamToTestMessages(nil)),
+	)).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	var out bytes.Buffer
+	_, err := client.StreamTo(ctx, &out, StreamOptions{Format: StreamJSONLines, ToolSummaries: true})
+	assertNoError(t, err)
+
+	var types []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var decoded streamLine
+		if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+			t.Fatalf("Line %q is not JSON: %v", line, err)
+		}
+		types = append(types, decoded.Type)
+		if decoded.Type == "tool_result" && (decoded.Name != "Bash" || decoded.Summary != "go.mod") {
+			t.Errorf("Expected Bash result summarized as go.mod, got %+v", decoded)
+		}
+	}
+	expect := "text tool_use tool_result text result"
+	if got := strings.Join(types, " "); got != expect {
+		t.Errorf("Expected lines %q, got %q", expect, got)
+	}
+}
+
+func TestClientStreamToSkipsSubagentText(t *testing.T) {
+	ctx, cancel := setupStreamToTestContext(t)
+	defer cancel()
+
+	parent := "toolu_task"
+	client := NewClientWithTransport(newClientMockTransportWithOptions(
+		WithClientResponseMessages([]Message{
+			&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "subagent notes"}}, ParentToolUseID: &parent},
+			&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "answer"}}},
+			&ResultMessage{Subtype: "success"},
+		}),
+	)).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	var out bytes.Buffer
+	_, err := client.StreamTo(ctx, &out, StreamOptions{})
+	assertNoError(t, err)
+	if out.String() != "answer\n" {
+		t.Errorf("Expected only the agent's text, got %q", out.String())
+	}
+}
+
+func TestClientStreamToFlushesBufferedWriters(t *testing.T) {
+	ctx, cancel := setupStreamToTestContext(t)
+	defer cancel()
+
+	client := NewClientWithTransport(newClientMockTransportWithOptions(
+		WithClientResponseMessages([]Message{
+			&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "hello"}}},
+			&ResultMessage{Subtype: "success"},
+		}),
+	)).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	var out bytes.Buffer
+	_, err := client.StreamTo(ctx, bufio.NewWriter(&out), StreamOptions{})
+	assertNoError(t, err)
+	if out.String() != "hello\n" {
+		t.Errorf("Expected the buffered writer to be flushed, got %q", out.String())
+	}
+}
+
+func TestClientStreamToErrors(t *testing.T) {
+	ctx, cancel := setupStreamToTestContext(t)
+	defer cancel()
+
+	t.Run("not connected", func(t *testing.T) {
+		client := NewClientWithTransport(newClientMockTransport()).(*ClientImpl)
+		_, err := client.StreamTo(ctx, &bytes.Buffer{}, StreamOptions{})
+		assertClientError(t, err, true, "not connected")
+	})
+
+	t.Run("write failure", func(t *testing.T) {
+		client := NewClientWithTransport(newClientMockTransportWithOptions(
+			WithClientResponseMessages([]Message{
+				&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "hello"}}},
+			}),
+		)).(*ClientImpl)
+		connectClientSafely(ctx, t, client)
+		defer disconnectClientSafely(t, client)
+
+		errClosed := errors.New("connection closed")
+		_, err := client.StreamTo(ctx, failingWriter{errClosed}, StreamOptions{})
+		if !errors.Is(err, errClosed) {
+			t.Errorf("Expected the write error, got %v", err)
+		}
+	})
+}
+
+func TestSummarizeLine(t *testing.T) {
+	tests := []struct {
+		text   string
+		limit  int
+		expect string
+	}{
+		{"\n\n  first line  \nsecond", 80, "first line"},
+		{"abcdefgh", 5, "abcd…"},
+		{"", 5, ""},
+	}
+	for _, test := range tests {
+		if got := summarizeLine(test.text, test.limit); got != test.expect {
+			t.Errorf("summarizeLine(%q, %d) = %q, expected %q", test.text, test.limit, got, test.expect)
+		}
+	}
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(_ []byte) (int, error) {
+	return 0, w.err
+}
+
+func setupStreamToTestContext(t *testing.T) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
+
+// streamToTestMessages is a turn with one text block before and after a
+// Bash call.
+func streamToTestMessages(cost *float64) []Message {
+	return []Message{
+		&AssistantMessage{Content: []ContentBlock{
+			&TextBlock{Text: "Listing files."},
+			&ToolUseBlock{ToolUseID: "toolu_1", Name: "Bash", Input: map[string]any{"command": "ls -la"}},
+		}},
+		&UserMessage{Content: []ContentBlock{
+			&ToolResultBlock{ToolUseID: "toolu_1", Content: "go.mod\nREADME.md"},
+		}},
+		&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "Done."}}},
+		&ResultMessage{Subtype: "success", SessionID: "s1", NumTurns: 2, TotalCostUSD: cost},
+	}
+}
@@ -0,0 +1,376 @@
+package claudecode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SupervisorTrigger is a condition a Supervisor watches for.
+type SupervisorTrigger string
+
+const (
+	// SupervisorTriggerInactivity fires when a turn produces no message for
+	// SupervisorConfig.InactivityTimeout.
+	SupervisorTriggerInactivity SupervisorTrigger = "inactivity"
+	// SupervisorTriggerRepeatedToolCall fires when the agent makes the same
+	// tool call, with the same input, SupervisorConfig.MaxRepeatedToolCalls
+	// times in a row.
+	SupervisorTriggerRepeatedToolCall SupervisorTrigger = "repeated_tool_call"
+	// SupervisorTriggerCostExceeded fires when a turn ends with the session's
+	// cost over SupervisorConfig.MaxCostUSD.
+	SupervisorTriggerCostExceeded SupervisorTrigger = "cost_exceeded"
+)
+
+// SupervisorAction is what a Supervisor does when a trigger fires.
+type SupervisorAction string
+
+const (
+	// SupervisorActionInterrupt interrupts the current turn.
+	SupervisorActionInterrupt SupervisorAction = "interrupt"
+	// SupervisorActionNudge sends SupervisorConfig.NudgePrompt.
+	SupervisorActionNudge SupervisorAction = "nudge"
+	// SupervisorActionSwitchModel switches to SupervisorConfig.FallbackModel.
+	SupervisorActionSwitchModel SupervisorAction = "switch_model"
+	// SupervisorActionAbort disconnects the client and stops the supervisor.
+	SupervisorActionAbort SupervisorAction = "abort"
+)
+
+// DefaultNudgePrompt is sent by SupervisorActionNudge when no NudgePrompt
+// is configured.
+const DefaultNudgePrompt = "You appear to be stuck. Step back, summarize what you have tried, and take a different approach."
+
+// SupervisorConfig selects what a Supervisor watches and how it responds.
+// A zero threshold disables its trigger.
+//
+// Each trigger has an escalation ladder of actions: the first time it fires
+// the first action is taken, the second time the second, and so on, with
+// the last action repeated once the ladder runs out. A trigger with an
+// empty ladder only reports events.
+type SupervisorConfig struct {
+	InactivityTimeout time.Duration
+	OnInactivity      []SupervisorAction
+
+	MaxRepeatedToolCalls int
+	OnRepeatedToolCall   []SupervisorAction
+
+	MaxCostUSD     float64
+	OnCostExceeded []SupervisorAction
+
+	// NudgePrompt is the prompt sent by SupervisorActionNudge.
+	NudgePrompt string
+	// FallbackModel is the model SupervisorActionSwitchModel switches to.
+	FallbackModel string
+
+	// OnEvent is called after each action, from the supervisor's goroutine.
+	OnEvent func(SupervisorEvent)
+}
+
+// SupervisorEvent describes a trigger firing and the action taken.
+type SupervisorEvent struct {
+	Trigger SupervisorTrigger
+	// Action is empty when the trigger has no action configured.
+	Action SupervisorAction
+	// Strike counts how many times the trigger has fired, from 1.
+	Strike int
+	Detail string
+	// Err is the error the action failed with, if any.
+	Err  error
+	Time time.Time
+}
+
+// Supervisor watches a connected client for stuck or runaway agents and
+// intervenes: it can interrupt the turn, nudge the agent with a prompt,
+// switch to a fallback model, or abort the session.
+//
+// The supervisor reads the client's messages and forwards them on its own
+// channel, so callers read from Messages instead of the client. Inactivity
+// is only measured while a turn is in progress: from Query, or the first
+// message of a turn, until its ResultMessage.
+//
+// Example:
+//
+//	supervisor := claudecode.NewSupervisor(client, claudecode.SupervisorConfig{
+//		InactivityTimeout:    2 * time.Minute,
+//		OnInactivity:         []claudecode.SupervisorAction{claudecode.SupervisorActionNudge, claudecode.SupervisorActionInterrupt},
+//		MaxRepeatedToolCalls: 3,
+//		OnRepeatedToolCall:   []claudecode.SupervisorAction{claudecode.SupervisorActionSwitchModel},
+//		FallbackModel:        "opus",
+//		MaxCostUSD:           5,
+//		OnCostExceeded:       []claudecode.SupervisorAction{claudecode.SupervisorActionAbort},
+//		OnEvent:              func(e claudecode.SupervisorEvent) { log.Printf("supervisor: %+v", e) },
+//	})
+//	if err := supervisor.Start(ctx); err != nil {
+//		return err
+//	}
+//	defer supervisor.Stop()
+//	if err := supervisor.Query(ctx, "Fix the failing tests"); err != nil {
+//		return err
+//	}
+//	for msg := range supervisor.Messages() {
+//		// ...
+//	}
+type Supervisor struct {
+	client Client
+	config SupervisorConfig
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	turn    bool // a turn is in progress
+	kick    chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+	msgCh   chan Message
+
+	// Watch state, owned by the run goroutine
+	lastCall string
+	repeats  int
+	costUSD  float64
+	strikes  map[SupervisorTrigger]int
+}
+
+// NewSupervisor creates a Supervisor for client. The client must be
+// connected before Start is called.
+func NewSupervisor(client Client, config SupervisorConfig) *Supervisor {
+	if config.NudgePrompt == "" {
+		config.NudgePrompt = DefaultNudgePrompt
+	}
+	return &Supervisor{
+		client:  client,
+		config:  config,
+		kick:    make(chan struct{}, 1),
+		msgCh:   make(chan Message, sessionChannelBufferSize),
+		strikes: make(map[SupervisorTrigger]int),
+	}
+}
+
+// Start begins watching the client. The supervisor runs until ctx is
+// canceled, Stop is called, it aborts the session, or the client's message
+// stream ends.
+func (s *Supervisor) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return ErrSessionClosed
+	}
+	if s.started {
+		return fmt.Errorf("supervisor already started")
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.started = true
+
+	go s.run(runCtx, s.client.ReceiveMessages(runCtx))
+	return nil
+}
+
+// Query sends a prompt through the client and starts the inactivity clock.
+func (s *Supervisor) Query(ctx context.Context, prompt string, opts ...Option) error {
+	s.startTurn()
+	return s.client.Query(ctx, prompt, opts...)
+}
+
+// Messages returns the client's messages, forwarded by the supervisor. The
+// channel is closed when the supervisor stops.
+func (s *Supervisor) Messages() <-chan Message {
+	return s.msgCh
+}
+
+// Stop stops watching and closes Messages. It does not disconnect the
+// client. Stop is safe to call multiple times.
+func (s *Supervisor) Stop() error {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopped = true
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+
+	if cancel == nil {
+		close(s.msgCh)
+		return nil
+	}
+	cancel()
+	<-done
+	return nil
+}
+
+// run forwards messages and fires triggers. It is the only goroutine that
+// takes actions, so they never overlap.
+func (s *Supervisor) run(ctx context.Context, messages <-chan Message) {
+	defer close(s.done)
+	defer close(s.msgCh)
+	defer func() {
+		s.mu.Lock()
+		s.stopped = true
+		s.mu.Unlock()
+	}()
+
+	var idle <-chan time.Time
+	var timer *time.Timer
+	resetIdle := func() {
+		if timer != nil {
+			timer.Stop()
+			idle = nil
+		}
+		if s.config.InactivityTimeout > 0 && s.inTurn() {
+			timer = time.NewTimer(s.config.InactivityTimeout)
+			idle = timer.C
+		}
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.kick:
+			resetIdle()
+		case <-idle:
+			idle = nil
+			detail := fmt.Sprintf("no messages for %s", s.config.InactivityTimeout)
+			if s.fire(ctx, SupervisorTriggerInactivity, s.config.OnInactivity, detail) {
+				return
+			}
+			resetIdle()
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			if s.watch(ctx, msg) {
+				return
+			}
+			select {
+			case s.msgCh <- msg:
+			case <-ctx.Done():
+				return
+			}
+			resetIdle()
+		}
+	}
+}
+
+// watch updates the watch state with msg and fires the triggers it trips.
+// It reports whether the supervisor aborted.
+func (s *Supervisor) watch(ctx context.Context, msg Message) bool {
+	switch m := msg.(type) {
+	case *ResultMessage:
+		s.endTurn()
+		if m.TotalCostUSD != nil {
+			s.costUSD += *m.TotalCostUSD
+		}
+		if s.config.MaxCostUSD > 0 && s.costUSD > s.config.MaxCostUSD {
+			detail := fmt.Sprintf("cost $%.4f exceeds $%.4f", s.costUSD, s.config.MaxCostUSD)
+			return s.fire(ctx, SupervisorTriggerCostExceeded, s.config.OnCostExceeded, detail)
+		}
+	case *AssistantMessage:
+		s.startTurn()
+		for _, block := range m.Content {
+			use, ok := block.(*ToolUseBlock)
+			if !ok || s.config.MaxRepeatedToolCalls <= 0 {
+				continue
+			}
+			call := toolCallSignature(use)
+			if call == s.lastCall {
+				s.repeats++
+			} else {
+				s.lastCall, s.repeats = call, 1
+			}
+			if s.repeats >= s.config.MaxRepeatedToolCalls {
+				detail := fmt.Sprintf("%s called %d times with the same input", use.Name, s.repeats)
+				s.lastCall, s.repeats = "", 0
+				if s.fire(ctx, SupervisorTriggerRepeatedToolCall, s.config.OnRepeatedToolCall, detail) {
+					return true
+				}
+			}
+		}
+	default:
+		s.startTurn()
+	}
+	return false
+}
+
+// fire takes the next action on trigger's escalation ladder and reports the
+// event. It reports whether the action aborted the session.
+func (s *Supervisor) fire(ctx context.Context, trigger SupervisorTrigger, ladder []SupervisorAction, detail string) bool {
+	s.strikes[trigger]++
+	event := SupervisorEvent{Trigger: trigger, Strike: s.strikes[trigger], Detail: detail}
+	if len(ladder) > 0 {
+		step := event.Strike - 1
+		if step >= len(ladder) {
+			step = len(ladder) - 1
+		}
+		event.Action = ladder[step]
+		event.Err = s.act(ctx, event.Action)
+	}
+	event.Time = time.Now()
+	if s.config.OnEvent != nil {
+		s.config.OnEvent(event)
+	}
+	return event.Action == SupervisorActionAbort
+}
+
+func (s *Supervisor) act(ctx context.Context, action SupervisorAction) error {
+	switch action {
+	case SupervisorActionInterrupt:
+		return s.client.Interrupt(ctx)
+	case SupervisorActionNudge:
+		s.startTurn()
+		return s.client.Query(ctx, s.config.NudgePrompt)
+	case SupervisorActionSwitchModel:
+		if s.config.FallbackModel == "" {
+			return fmt.Errorf("no fallback model configured")
+		}
+		return s.client.SetModel(ctx, s.config.FallbackModel)
+	case SupervisorActionAbort:
+		return s.client.Disconnect()
+	}
+	return fmt.Errorf("unknown supervisor action %q", action)
+}
+
+// startTurn marks a turn in progress, starting the inactivity clock if it
+// was not running.
+func (s *Supervisor) startTurn() {
+	s.mu.Lock()
+	started := !s.turn
+	s.turn = true
+	s.mu.Unlock()
+
+	if started {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Supervisor) endTurn() {
+	s.mu.Lock()
+	s.turn = false
+	s.mu.Unlock()
+}
+
+func (s *Supervisor) inTurn() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.turn
+}
+
+// toolCallSignature identifies a tool call by its name and input.
+func toolCallSignature(use *ToolUseBlock) string {
+	// Map keys are marshaled in sorted order, so equal inputs match
+	input, _ := json.Marshal(use.Input)
+	return use.Name + "\x00" + string(input)
+}
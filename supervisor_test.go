@@ -0,0 +1,292 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSupervisorForwardsMessages(t *testing.T) {
+	ctx, cancel := setupSupervisorTestContext(t)
+	defer cancel()
+
+	client := newSupervisorTestClient()
+	supervisor := startSupervisorForTest(ctx, t, client, SupervisorConfig{})
+
+	client.messages <- newMuxAssistantMessage("hello")
+	client.messages <- &ResultMessage{Subtype: "success"}
+	if msg := receiveSupervised(ctx, t, supervisor); msg.(*AssistantMessage).Content[0].(*TextBlock).Text != "hello" {
+		t.Errorf("Expected the assistant message, got %+v", msg)
+	}
+	if _, ok := receiveSupervised(ctx, t, supervisor).(*ResultMessage); !ok {
+		t.Error("Expected the result message")
+	}
+
+	if err := supervisor.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if _, ok := <-supervisor.Messages(); ok {
+		t.Error("Expected Messages closed after Stop")
+	}
+	if err := supervisor.Start(ctx); err == nil {
+		t.Error("Expected Start to fail after Stop")
+	}
+}
+
+func TestSupervisorInactivityEscalates(t *testing.T) {
+	ctx, cancel := setupSupervisorTestContext(t)
+	defer cancel()
+
+	client := newSupervisorTestClient()
+	events := make(chan SupervisorEvent, 10)
+	supervisor := startSupervisorForTest(ctx, t, client, SupervisorConfig{
+		InactivityTimeout: 20 * time.Millisecond,
+		OnInactivity:      []SupervisorAction{SupervisorActionNudge, SupervisorActionInterrupt},
+		NudgePrompt:       "keep going",
+		OnEvent:           func(e SupervisorEvent) { events <- e },
+	})
+
+	if err := supervisor.Query(ctx, "start"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	first := receiveEvent(ctx, t, events)
+	second := receiveEvent(ctx, t, events)
+	third := receiveEvent(ctx, t, events)
+	if first.Action != SupervisorActionNudge || second.Action != SupervisorActionInterrupt || third.Action != SupervisorActionInterrupt {
+		t.Errorf("Expected nudge then interrupts, got %s, %s, %s", first.Action, second.Action, third.Action)
+	}
+	if first.Trigger != SupervisorTriggerInactivity || first.Strike != 1 || third.Strike != 3 || first.Time.IsZero() {
+		t.Errorf("Expected numbered inactivity events, got %+v and %+v", first, third)
+	}
+
+	// Ending the turn stops the clock
+	client.messages <- &ResultMessage{Subtype: "error_during_execution"}
+	receiveSupervised(ctx, t, supervisor)
+	drainEvents(events)
+	select {
+	case e := <-events:
+		t.Errorf("Expected no events between turns, got %+v", e)
+	case <-time.After(60 * time.Millisecond):
+	}
+
+	if got := client.queries(); len(got) != 2 || got[0] != "start" || got[1] != "keep going" {
+		t.Errorf("Expected the prompt and a nudge, got %v", got)
+	}
+	if client.interrupts() < 2 {
+		t.Errorf("Expected at least 2 interrupts, got %d", client.interrupts())
+	}
+}
+
+func TestSupervisorRepeatedToolCalls(t *testing.T) {
+	ctx, cancel := setupSupervisorTestContext(t)
+	defer cancel()
+
+	client := newSupervisorTestClient()
+	events := make(chan SupervisorEvent, 10)
+	startSupervisorForTest(ctx, t, client, SupervisorConfig{
+		MaxRepeatedToolCalls: 3,
+		OnRepeatedToolCall:   []SupervisorAction{SupervisorActionSwitchModel},
+		FallbackModel:        "opus",
+		OnEvent:              func(e SupervisorEvent) { events <- e },
+	})
+
+	call := func(input map[string]any) Message {
+		return &AssistantMessage{Content: []ContentBlock{&ToolUseBlock{ToolUseID: "t", Name: "Bash", Input: input}}}
+	}
+	client.messages <- call(map[string]any{"command": "make", "timeout": 10})
+	client.messages <- call(map[string]any{"command": "make test"})
+	client.messages <- call(map[string]any{"timeout": 10, "command": "make"})
+	client.messages <- call(map[string]any{"command": "make test"})
+	client.messages <- call(map[string]any{"command": "make test"})
+	client.messages <- call(map[string]any{"command": "make test"})
+
+	event := receiveEvent(ctx, t, events)
+	if event.Trigger != SupervisorTriggerRepeatedToolCall || event.Action != SupervisorActionSwitchModel || event.Err != nil {
+		t.Errorf("Expected a model switch, got %+v", event)
+	}
+	if event.Detail != "Bash called 3 times with the same input" {
+		t.Errorf("Unexpected detail %q", event.Detail)
+	}
+	if got := client.model(); got != "opus" {
+		t.Errorf("Expected the fallback model set, got %q", got)
+	}
+}
+
+func TestSupervisorCostAbort(t *testing.T) {
+	ctx, cancel := setupSupervisorTestContext(t)
+	defer cancel()
+
+	client := newSupervisorTestClient()
+	events := make(chan SupervisorEvent, 10)
+	supervisor := startSupervisorForTest(ctx, t, client, SupervisorConfig{
+		MaxCostUSD:     1,
+		OnCostExceeded: []SupervisorAction{SupervisorActionAbort},
+		OnEvent:        func(e SupervisorEvent) { events <- e },
+	})
+
+	cost := 0.6
+	client.messages <- &ResultMessage{Subtype: "success", TotalCostUSD: &cost}
+	receiveSupervised(ctx, t, supervisor)
+	client.messages <- &ResultMessage{Subtype: "success", TotalCostUSD: &cost}
+
+	event := receiveEvent(ctx, t, events)
+	if event.Trigger != SupervisorTriggerCostExceeded || event.Action != SupervisorActionAbort {
+		t.Errorf("Expected an abort for cost, got %+v", event)
+	}
+	for range supervisor.Messages() {
+	}
+	if !client.disconnected() {
+		t.Error("Expected the client disconnected")
+	}
+}
+
+func TestSupervisorActionErrors(t *testing.T) {
+	ctx, cancel := setupSupervisorTestContext(t)
+	defer cancel()
+
+	client := newSupervisorTestClient()
+	client.interruptErr = errors.New("not connected")
+	events := make(chan SupervisorEvent, 10)
+	startSupervisorForTest(ctx, t, client, SupervisorConfig{
+		MaxRepeatedToolCalls: 1,
+		OnRepeatedToolCall:   []SupervisorAction{SupervisorActionInterrupt, SupervisorActionSwitchModel},
+		OnEvent:              func(e SupervisorEvent) { events <- e },
+	})
+
+	use := &AssistantMessage{Content: []ContentBlock{&ToolUseBlock{Name: "Read"}}}
+	client.messages <- use
+	client.messages <- use
+	if event := receiveEvent(ctx, t, events); event.Err == nil || event.Err.Error() != "not connected" {
+		t.Errorf("Expected the interrupt error, got %v", event.Err)
+	}
+	if event := receiveEvent(ctx, t, events); event.Err == nil {
+		t.Error("Expected an error switching without a fallback model")
+	}
+}
+
+// supervisorTestClient is a Client whose messages are fed by the test and
+// whose actions are recorded. Methods the supervisor does not use panic.
+type supervisorTestClient struct {
+	Client
+
+	messages     chan Message
+	interruptErr error
+
+	mu         sync.Mutex
+	sent       []string
+	interrupt  int
+	setModel   string
+	disconnect bool
+}
+
+func newSupervisorTestClient() *supervisorTestClient {
+	return &supervisorTestClient{messages: make(chan Message, 10)}
+}
+
+func (c *supervisorTestClient) ReceiveMessages(context.Context) <-chan Message {
+	return c.messages
+}
+
+func (c *supervisorTestClient) Query(_ context.Context, prompt string, _ ...Option) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, prompt)
+	return nil
+}
+
+func (c *supervisorTestClient) Interrupt(context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interrupt++
+	return c.interruptErr
+}
+
+func (c *supervisorTestClient) SetModel(_ context.Context, model string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setModel = model
+	return nil
+}
+
+func (c *supervisorTestClient) Disconnect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.disconnect = true
+	return nil
+}
+
+func (c *supervisorTestClient) queries() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.sent...)
+}
+
+func (c *supervisorTestClient) interrupts() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.interrupt
+}
+
+func (c *supervisorTestClient) model() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setModel
+}
+
+func (c *supervisorTestClient) disconnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.disconnect
+}
+
+func setupSupervisorTestContext(t *testing.T) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
+
+func startSupervisorForTest(ctx context.Context, t *testing.T, client Client, config SupervisorConfig) *Supervisor {
+	t.Helper()
+	supervisor := NewSupervisor(client, config)
+	if err := supervisor.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { _ = supervisor.Stop() })
+	return supervisor
+}
+
+func receiveSupervised(ctx context.Context, t *testing.T, supervisor *Supervisor) Message {
+	t.Helper()
+	select {
+	case msg, ok := <-supervisor.Messages():
+		if !ok {
+			t.Fatal("Messages closed unexpectedly")
+		}
+		return msg
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for a message")
+	}
+	return nil
+}
+
+func receiveEvent(ctx context.Context, t *testing.T, events <-chan SupervisorEvent) SupervisorEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for a supervisor event")
+	}
+	return SupervisorEvent{}
+}
+
+func drainEvents(events <-chan SupervisorEvent) {
+	for {
+		select {
+		case <-events:
+		default:
+			return
+		}
+	}
+}
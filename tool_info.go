@@ -0,0 +1,187 @@
+package claudecode
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ToolInfo describes a tool the CLI made available to the agent.
+type ToolInfo struct {
+	Name string
+	// Server is the MCP server providing the tool, or empty for a
+	// built-in tool.
+	Server string
+	// Description and InputSchema are known for built-in tools. The CLI
+	// does not report them for MCP tools, so they are empty there.
+	Description string
+	InputSchema map[string]any
+}
+
+// mcpToolPrefix starts the names of MCP tools: mcp__<server>__<tool>.
+const mcpToolPrefix = "mcp__"
+
+// builtinTool holds what the SDK knows about a built-in tool.
+type builtinTool struct {
+	description string
+	required    []string          // required parameters
+	params      map[string]string // parameter name to JSON type
+}
+
+// builtinTools describes the CLI's built-in tools and their main
+// parameters.
+var builtinTools = map[string]builtinTool{
+	"Bash": {
+		"Runs a shell command",
+		[]string{"command"},
+		map[string]string{"command": "string", "description": "string", "timeout": "number", "run_in_background": "boolean"},
+	},
+	"BashOutput": {
+		"Reads output from a background shell",
+		[]string{"bash_id"},
+		map[string]string{"bash_id": "string", "filter": "string"},
+	},
+	"KillShell": {
+		"Stops a background shell",
+		[]string{"shell_id"},
+		map[string]string{"shell_id": "string"},
+	},
+	"Read": {
+		"Reads a file",
+		[]string{"file_path"},
+		map[string]string{"file_path": "string", "offset": "number", "limit": "number"},
+	},
+	"Write": {
+		"Writes a file, replacing its contents",
+		[]string{"file_path", "content"},
+		map[string]string{"file_path": "string", "content": "string"},
+	},
+	"Edit": {
+		"Replaces text in a file",
+		[]string{"file_path", "old_string", "new_string"},
+		map[string]string{"file_path": "string", "old_string": "string", "new_string": "string", "replace_all": "boolean"},
+	},
+	"MultiEdit": {
+		"Makes several replacements in one file",
+		[]string{"file_path", "edits"},
+		map[string]string{"file_path": "string", "edits": "array"},
+	},
+	"NotebookEdit": {
+		"Edits a Jupyter notebook cell",
+		[]string{"notebook_path", "new_source"},
+		map[string]string{"notebook_path": "string", "new_source": "string", "cell_id": "string", "cell_type": "string", "edit_mode": "string"},
+	},
+	"Glob": {
+		"Finds files matching a glob pattern",
+		[]string{"pattern"},
+		map[string]string{"pattern": "string", "path": "string"},
+	},
+	"Grep": {
+		"Searches file contents with a regular expression",
+		[]string{"pattern"},
+		map[string]string{"pattern": "string", "path": "string", "glob": "string", "type": "string", "output_mode": "string"},
+	},
+	"WebFetch": {
+		"Fetches a URL and answers a prompt about its content",
+		[]string{"url", "prompt"},
+		map[string]string{"url": "string", "prompt": "string"},
+	},
+	"WebSearch": {
+		"Searches the web",
+		[]string{"query"},
+		map[string]string{"query": "string", "allowed_domains": "array", "blocked_domains": "array"},
+	},
+	"Task": {
+		"Runs a subagent on a task",
+		[]string{"description", "prompt", "subagent_type"},
+		map[string]string{"description": "string", "prompt": "string", "subagent_type": "string"},
+	},
+	"TodoWrite": {
+		"Updates the session's todo list",
+		[]string{"todos"},
+		map[string]string{"todos": "array"},
+	},
+	ToolNameExitPlanMode: {
+		"Presents a plan and asks to leave plan mode",
+		[]string{"plan"},
+		map[string]string{"plan": "string"},
+	},
+}
+
+// schema returns a JSON Schema for the tool's parameters.
+func (bt builtinTool) schema() map[string]any {
+	properties := make(map[string]any, len(bt.params))
+	for name, typ := range bt.params {
+		properties[name] = map[string]any{"type": typ}
+	}
+	required := make([]any, len(bt.required))
+	for i, name := range bt.required {
+		required[i] = name
+	}
+	return map[string]any{"type": "object", "properties": properties, "required": required}
+}
+
+// toolInfos describes the tools in an init message, in the order the CLI
+// reported them.
+func toolInfos(info *InitInfo) []ToolInfo {
+	if info == nil {
+		return nil
+	}
+	tools := make([]ToolInfo, 0, len(info.Tools))
+	for _, name := range info.Tools {
+		tool := ToolInfo{Name: name}
+		if strings.HasPrefix(name, mcpToolPrefix) {
+			tool.Server = mcpServerOf(name, info.McpServers)
+		} else if builtin, ok := builtinTools[name]; ok {
+			tool.Description = builtin.description
+			tool.InputSchema = builtin.schema()
+		}
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// mcpServerOf returns the server of an MCP tool. Server names can contain
+// the separator, so the longest reported server that prefixes the name
+// wins; without one, the name is split at the first separator.
+func mcpServerOf(name string, servers []McpServerStatus) string {
+	rest := strings.TrimPrefix(name, mcpToolPrefix)
+	best := ""
+	for _, server := range servers {
+		if strings.HasPrefix(rest, server.Name+"__") && len(server.Name) > len(best) {
+			best = server.Name
+		}
+	}
+	if best != "" {
+		return best
+	}
+	server, _, _ := strings.Cut(rest, "__")
+	return server
+}
+
+// AvailableTools returns the tools the CLI made available for the current
+// session, with descriptions and input schemas where the SDK knows them.
+// The CLI reports its tools in the init message, which it sends once the
+// first prompt arrives, so AvailableTools waits for it until ctx ends.
+func (c *ClientImpl) AvailableTools(ctx context.Context) ([]ToolInfo, error) {
+	c.mu.RLock()
+	initInfo, lc := c.initInfo, c.lifecycle
+	c.mu.RUnlock()
+
+	if initInfo == nil || lc == nil {
+		return nil, fmt.Errorf("client not connected")
+	}
+	select {
+	case <-initInfo.received:
+	case <-lc.done:
+		// The init message may have arrived before the disconnect
+		select {
+		case <-initInfo.received:
+		default:
+			return nil, ErrClientClosed
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return toolInfos(initInfo.info()), nil
+}
@@ -0,0 +1,88 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClientAvailableTools(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	init := initMessage("Bash", "mcp__github__create_issue", "mcp__aws__api__call", "Custom")
+	init.Data["mcp_servers"] = []any{
+		map[string]any{"name": "github", "status": "connected"},
+		map[string]any{"name": "aws__api", "status": "connected"},
+	}
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{init}))
+	client := NewClientWithTransport(transport).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	tools, err := client.AvailableTools(ctx)
+	if err != nil {
+		t.Fatalf("AvailableTools failed: %v", err)
+	}
+	if len(tools) != 4 {
+		t.Fatalf("Expected 4 tools, got %+v", tools)
+	}
+
+	bash := tools[0]
+	if bash.Name != "Bash" || bash.Server != "" || bash.Description == "" {
+		t.Errorf("Expected a described built-in tool, got %+v", bash)
+	}
+	required, _ := bash.InputSchema["required"].([]any)
+	if len(required) != 1 || required[0] != "command" {
+		t.Errorf("Expected Bash to require command, got %v", bash.InputSchema)
+	}
+	if tools[1].Server != "github" || tools[1].Description != "" || tools[1].InputSchema != nil {
+		t.Errorf("Expected an MCP tool of github, got %+v", tools[1])
+	}
+	if tools[2].Server != "aws__api" {
+		t.Errorf("Expected the longest matching server, got %q", tools[2].Server)
+	}
+	if tools[3].Server != "" || tools[3].InputSchema != nil {
+		t.Errorf("Expected an unknown tool without details, got %+v", tools[3])
+	}
+}
+
+func TestClientAvailableToolsWaits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client := NewClientWithTransport(newClientMockTransport()).(*ClientImpl)
+	if _, err := client.AvailableTools(ctx); err == nil {
+		t.Error("Expected an error before connecting")
+	}
+
+	connectClientSafely(ctx, t, client)
+	short, cancelShort := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelShort()
+	if _, err := client.AvailableTools(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected to wait for the init message, got %v", err)
+	}
+
+	disconnectClientSafely(t, client)
+	if _, err := client.AvailableTools(ctx); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed after disconnecting, got %v", err)
+	}
+}
+
+func TestMcpServerOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []McpServerStatus
+		want    string
+	}{
+		{"mcp__github__create_issue", nil, "github"},
+		{"mcp__a__b__tool", []McpServerStatus{{Name: "a"}, {Name: "a__b"}}, "a__b"},
+		{"mcp__a__tool", []McpServerStatus{{Name: "a__b"}}, "a"},
+	}
+	for _, test := range tests {
+		if got := mcpServerOf(test.name, test.servers); got != test.want {
+			t.Errorf("mcpServerOf(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package claudecode
+
+import (
+	"context"
+	"sync"
+)
+
+// toolLimiter caps how many tool calls run at once. A slot is taken when
+// the CLI's PreToolUse hook callback allows a tool and freed by its
+// PostToolUse callback, when the tool's result arrives, or when the turn
+// ends.
+type toolLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	// ids holds the approved calls that carried a tool_use_id; anonymous
+	// counts those that did not, which any result may release.
+	ids       map[string]bool
+	anonymous int
+	changed   chan struct{} // closed and replaced when a slot frees
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newToolLimiter(limit int) *toolLimiter {
+	return &toolLimiter{
+		limit:   limit,
+		ids:     make(map[string]bool),
+		changed: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+// acquire waits for a free slot and takes it for toolUseID, which may be
+// empty. It fails if ctx ends or the limiter is closed first.
+func (tl *toolLimiter) acquire(ctx context.Context, toolUseID string) error {
+	for {
+		tl.mu.Lock()
+		if tl.running < tl.limit {
+			tl.running++
+			if toolUseID != "" {
+				tl.ids[toolUseID] = true
+			} else {
+				tl.anonymous++
+			}
+			tl.mu.Unlock()
+			return nil
+		}
+		changed := tl.changed
+		tl.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tl.closed:
+			return ErrClientClosed
+		}
+	}
+}
+
+// release frees the slot held for toolUseID. A result for a tool the
+// limiter did not approve releases an anonymous slot, if any.
+func (tl *toolLimiter) release(toolUseID string) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	switch {
+	case toolUseID != "" && tl.ids[toolUseID]:
+		delete(tl.ids, toolUseID)
+	case tl.anonymous > 0:
+		tl.anonymous--
+	default:
+		return
+	}
+	tl.running--
+	tl.notify()
+}
+
+// releaseAll frees every slot, since no tool outlives its turn.
+func (tl *toolLimiter) releaseAll() {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	if tl.running == 0 {
+		return
+	}
+	tl.running = 0
+	tl.anonymous = 0
+	tl.ids = make(map[string]bool)
+	tl.notify()
+}
+
+// notify wakes waiting acquirers. Must be called with tl.mu held.
+func (tl *toolLimiter) notify() {
+	close(tl.changed)
+	tl.changed = make(chan struct{})
+}
+
+// track frees slots as tool results and turn results arrive.
+func (tl *toolLimiter) track(msg Message) {
+	switch m := msg.(type) {
+	case *UserMessage:
+		blocks, ok := m.Content.([]ContentBlock)
+		if !ok {
+			return
+		}
+		for _, block := range blocks {
+			if result, ok := block.(*ToolResultBlock); ok {
+				tl.release(result.ToolUseID)
+			}
+		}
+	case *ResultMessage:
+		tl.releaseAll()
+	}
+}
+
+// close fails current and future acquires with ErrClientClosed.
+func (tl *toolLimiter) close() {
+	tl.closeOnce.Do(func() {
+		close(tl.closed)
+	})
+}
@@ -0,0 +1,268 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/severity1/claude-code-sdk-go/claudetest/fakecli"
+)
+
+func TestToolLimiterReleasesByToolUseID(t *testing.T) {
+	ctx, cancel := setupToolLimiterTestContext(t)
+	defer cancel()
+
+	limiter := newToolLimiter(2)
+	assertNoError(t, limiter.acquire(ctx, "tool-1"))
+	assertNoError(t, limiter.acquire(ctx, "tool-2"))
+
+	acquired := acquireToolSlotAsync(ctx, limiter, "tool-3")
+	assertToolSlotPending(t, acquired)
+
+	// A result for an unknown tool frees nothing
+	limiter.track(toolResultMessage("other", false))
+	assertToolSlotPending(t, acquired)
+
+	limiter.track(toolResultMessage("tool-2", false))
+	assertToolSlotAcquired(t, acquired)
+}
+
+func TestToolLimiterAnonymousSlots(t *testing.T) {
+	ctx, cancel := setupToolLimiterTestContext(t)
+	defer cancel()
+
+	limiter := newToolLimiter(1)
+	assertNoError(t, limiter.acquire(ctx, ""))
+
+	acquired := acquireToolSlotAsync(ctx, limiter, "")
+	assertToolSlotPending(t, acquired)
+
+	// Without an ID to match, any tool result frees the slot
+	limiter.track(toolResultMessage("unmatched", false))
+	assertToolSlotAcquired(t, acquired)
+}
+
+func TestToolLimiterResultReleasesAll(t *testing.T) {
+	ctx, cancel := setupToolLimiterTestContext(t)
+	defer cancel()
+
+	limiter := newToolLimiter(1)
+	assertNoError(t, limiter.acquire(ctx, "tool-1"))
+
+	acquired := acquireToolSlotAsync(ctx, limiter, "tool-2")
+	assertToolSlotPending(t, acquired)
+
+	limiter.track(&ResultMessage{Subtype: "error_during_execution"})
+	assertToolSlotAcquired(t, acquired)
+}
+
+func TestToolLimiterCloseAndCancel(t *testing.T) {
+	ctx, cancel := setupToolLimiterTestContext(t)
+	defer cancel()
+
+	limiter := newToolLimiter(1)
+	assertNoError(t, limiter.acquire(ctx, "tool-1"))
+
+	waitCtx, waitCancel := context.WithCancel(ctx)
+	canceled := acquireToolSlotAsync(waitCtx, limiter, "tool-2")
+	waitCancel()
+	if err := <-canceled; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	closed := acquireToolSlotAsync(ctx, limiter, "tool-3")
+	limiter.close()
+	if err := <-closed; !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed, got %v", err)
+	}
+}
+
+func TestHookCallbackHandlerLimitsTools(t *testing.T) {
+	ctx, cancel := setupToolLimiterTestContext(t)
+	defer cancel()
+
+	hooks := newHookSystem(SystemClock{})
+	deny := func(_ context.Context, input interface{}, _ HookContext) (HookOutput, error) {
+		if input.(PreToolUseHookInput).ToolInput["command"] == "rm -rf /" {
+			return HookOutput{Behavior: HookBehaviorStop, Message: "not that"}, nil
+		}
+		return HookOutput{Behavior: HookBehaviorContinue}, nil
+	}
+	assertNoError(t, hooks.AddHook(string(HookEventTypePreToolUse), deny))
+	limiter := newToolLimiter(1)
+	handler := newHookCallbackHandler(hooks, limiter)
+
+	// A tool its hooks deny takes no slot
+	denied := toolHookRequest("cli-1", preToolUseCallbackID, "tool-1", "rm -rf /")
+	response, err := handler(ctx, denied.Data)
+	assertNoError(t, err)
+	decision, _ := response["hookSpecificOutput"].(map[string]any)
+	if decision["permissionDecision"] != "deny" || decision["permissionDecisionReason"] != "not that" {
+		t.Errorf("Expected the tool denied with the hook's reason, got %v", response)
+	}
+
+	response, err = handler(ctx, toolHookRequest("cli-2", preToolUseCallbackID, "tool-2", "make").Data)
+	assertNoError(t, err)
+	if len(response) != 0 {
+		t.Errorf("Expected an empty output allowing the tool, got %v", response)
+	}
+	assertToolSlotPending(t, acquireToolSlotAsync(ctx, limiter, "tool-3"))
+
+	if _, err := handler(ctx, map[string]any{"callback_id": "unknown"}); err == nil {
+		t.Error("Expected an unknown callback to fail")
+	}
+}
+
+func TestClientMaxConcurrentToolsDelaysApproval(t *testing.T) {
+	ctx, cancel := setupToolLimiterTestContext(t)
+	defer cancel()
+
+	transport := newClientControlMockTransport()
+	client := NewClientWithTransport(transport, WithMaxConcurrentTools(1))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	requests := transport.getControlRequests()
+	if len(requests) != 1 || requests[0].Subtype != ControlRequestTypeInitialize {
+		t.Fatalf("Expected tool hooks registered on connect, got %v", requests)
+	}
+	protocol, ok := client.(*ClientImpl).GetControlProtocol().(*controlProtocol)
+	if !ok {
+		t.Fatal("Expected built-in control protocol")
+	}
+
+	first, err := protocol.HandleControlRequest(ctx, toolHookRequest("cli-1", preToolUseCallbackID, "tool-1", "make"))
+	if err != nil || first.Subtype != ControlResponseTypeSuccess {
+		t.Fatalf("Expected the first tool allowed, got %+v, %v", first, err)
+	}
+
+	second := make(chan *ControlResponse, 1)
+	go func() {
+		response, _ := protocol.HandleControlRequest(ctx, toolHookRequest("cli-2", preToolUseCallbackID, "tool-2", "make"))
+		second <- response
+	}()
+	select {
+	case <-second:
+		t.Fatal("Expected the second tool to wait for the first to finish")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if _, err := protocol.HandleControlRequest(ctx, toolHookRequest("cli-3", postToolUseCallbackID, "tool-1", "make")); err != nil {
+		t.Fatalf("HandleControlRequest failed: %v", err)
+	}
+
+	select {
+	case response := <-second:
+		if response == nil || response.Subtype != ControlResponseTypeSuccess {
+			t.Fatalf("Expected the second tool allowed, got %+v", response)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for the second tool to be allowed")
+	}
+}
+
+func TestClientMaxConcurrentToolsWithCLI(t *testing.T) {
+	ctx, cancel := setupToolLimiterTestContext(t)
+	defer cancel()
+
+	hook := func(tool, id, callbackID string) fakecli.Step {
+		event := HookEventTypePreToolUse
+		if callbackID == postToolUseCallbackID {
+			event = HookEventTypePostToolUse
+		}
+		return fakecli.Request("hook_callback", map[string]any{
+			"callback_id": callbackID,
+			"tool_use_id": id,
+			"input":       map[string]any{"hook_event_name": event, "tool_name": tool, "tool_input": map[string]any{}},
+		})
+	}
+	cli := newFakeCLI(t, fakecli.Steps(
+		hook("Bash", "tool-1", preToolUseCallbackID),
+		fakecli.ToolUse("tool-1", "Bash", nil),
+		hook("Bash", "tool-1", postToolUseCallbackID),
+		// Only free once the first tool's PostToolUse arrived
+		hook("Bash", "tool-2", preToolUseCallbackID),
+		fakecli.Result("done"),
+	))
+	client := NewClient(WithCLIPath(cli.Path), WithMaxConcurrentTools(1))
+	connectClientSafely(ctx, t, client)
+	runFakeCLITurn(ctx, t, client, "build twice")
+	disconnectClientSafely(t, client)
+
+	inputs := cli.Inputs(t)
+	request, _ := inputs[0]["request"].(map[string]any)
+	if inputs[0]["type"] != "control_request" || request["subtype"] != "initialize" {
+		t.Fatalf("Expected tool hooks registered before the prompt, got %v", inputs[0])
+	}
+	hooks, _ := request["hooks"].(map[string]any)
+	if hooks["PreToolUse"] == nil || hooks["PostToolUse"] == nil {
+		t.Errorf("Expected PreToolUse and PostToolUse registered, got %v", hooks)
+	}
+	responses := controlResponses(t, cli)
+	for _, id := range []string{"fakecli_req_1", "fakecli_req_2", "fakecli_req_3"} {
+		if responses[id]["subtype"] != "success" {
+			t.Errorf("Expected hook callback %s answered, got %v", id, responses[id])
+		}
+	}
+}
+
+func TestMaxConcurrentToolsOption(t *testing.T) {
+	options := NewOptions(WithMaxConcurrentTools(3))
+	if options.MaxConcurrentTools != 3 {
+		t.Errorf("Expected MaxConcurrentTools 3, got %d", options.MaxConcurrentTools)
+	}
+	if err := NewOptions(WithMaxConcurrentTools(-1)).Validate(); err == nil {
+		t.Error("Expected negative MaxConcurrentTools to be rejected")
+	}
+}
+
+func setupToolLimiterTestContext(t *testing.T) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
+
+func toolHookRequest(id, callbackID, toolUseID, command string) *ControlRequest {
+	return &ControlRequest{
+		ID:      id,
+		Subtype: ControlRequestTypeHookCallback,
+		Data: map[string]any{
+			"callback_id": callbackID,
+			"tool_use_id": toolUseID,
+			"input": map[string]any{
+				"hook_event_name": "PreToolUse",
+				"tool_name":       "Bash",
+				"tool_input":      map[string]any{"command": command},
+			},
+		},
+	}
+}
+
+func acquireToolSlotAsync(ctx context.Context, limiter *toolLimiter, toolUseID string) <-chan error {
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- limiter.acquire(ctx, toolUseID)
+	}()
+	return acquired
+}
+
+func assertToolSlotPending(t *testing.T, acquired <-chan error) {
+	t.Helper()
+	select {
+	case err := <-acquired:
+		t.Fatalf("Expected acquire to block, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func assertToolSlotAcquired(t *testing.T, acquired <-chan error) {
+	t.Helper()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("Expected acquire to succeed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for a free slot")
+	}
+}
@@ -0,0 +1,60 @@
+package claudecode
+
+import "strings"
+
+// ToolPolicy describes the tools a client asked for and the tools the CLI
+// made available once the session started.
+type ToolPolicy struct {
+	// Allowed and Disallowed are the tool lists configured on the client.
+	Allowed    []string
+	Disallowed []string
+
+	// Negotiated reports whether the CLI's init message has been received.
+	// Available and Unavailable are only meaningful once it has.
+	Negotiated bool
+	// Available lists the tools the CLI reported in its init message.
+	Available []string
+	// Unavailable lists the allowed tools the CLI did not report, usually
+	// a misspelled name or an MCP server that failed to start.
+	Unavailable []string
+}
+
+// policy combines the configured tool lists with the negotiated tools.
+func (it *initTracker) policy(options *Options) ToolPolicy {
+	var policy ToolPolicy
+	if options != nil {
+		policy.Allowed = append([]string(nil), options.AllowedTools...)
+		policy.Disallowed = append([]string(nil), options.DisallowedTools...)
+	}
+	info := it.info()
+	if info == nil {
+		return policy
+	}
+	policy.Negotiated = true
+	policy.Available = info.Tools
+	for _, tool := range policy.Allowed {
+		if !toolAvailable(tool, info.Tools) {
+			policy.Unavailable = append(policy.Unavailable, tool)
+		}
+	}
+	return policy
+}
+
+// toolAvailable reports whether an allowed tool entry matches one of the
+// available tools. Entries may carry a rule such as "Bash(git:*)", and an
+// MCP server name such as "mcp__github" covers all of that server's tools.
+func toolAvailable(entry string, available []string) bool {
+	name := entry
+	if i := strings.Index(name, "("); i >= 0 {
+		name = name[:i]
+	}
+	for _, tool := range available {
+		if tool == name {
+			return true
+		}
+		if strings.HasPrefix(name, "mcp__") && strings.HasPrefix(tool, name+"__") {
+			return true
+		}
+	}
+	return false
+}
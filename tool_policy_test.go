@@ -0,0 +1,82 @@
+package claudecode
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInitTrackerPolicy(t *testing.T) {
+	options := NewOptions(
+		WithAllowedTools("Read", "Bash(git:*)", "Raed", "mcp__github", "mcp__jira"),
+		WithDisallowedTools("WebFetch"),
+	)
+	tracker := newInitTracker()
+
+	policy := tracker.policy(options)
+	if policy.Negotiated || policy.Available != nil || policy.Unavailable != nil {
+		t.Errorf("Expected no negotiated tools before init, got %+v", policy)
+	}
+
+	tracker.track(&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "hi"}}})
+	tracker.track(&SystemMessage{Subtype: "status", Data: map[string]any{"tools": []any{"Ignored"}}})
+	tracker.track(initMessage("Read", "Bash", "mcp__github__create_issue"))
+
+	policy = tracker.policy(options)
+	if !policy.Negotiated {
+		t.Fatal("Expected policy to be negotiated after init")
+	}
+	assertToolList(t, "Allowed", policy.Allowed, options.AllowedTools)
+	assertToolList(t, "Disallowed", policy.Disallowed, []string{"WebFetch"})
+	assertToolList(t, "Available", policy.Available, []string{"Read", "Bash", "mcp__github__create_issue"})
+	assertToolList(t, "Unavailable", policy.Unavailable, []string{"Raed", "mcp__jira"})
+}
+
+func TestClientEffectiveToolPolicy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		initMessage("Read", "Write"),
+		&ResultMessage{Subtype: "success"},
+	}))
+	client := NewClientWithTransport(transport, WithAllowedTools("Read", "Edit")).(*ClientImpl)
+
+	if policy := client.EffectiveToolPolicy(); policy.Negotiated || !reflect.DeepEqual(policy.Allowed, []string{"Read", "Edit"}) {
+		t.Errorf("Unexpected policy before connecting: %+v", policy)
+	}
+
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	for msg := range client.ReceiveMessages(ctx) {
+		if _, ok := msg.(*ResultMessage); ok {
+			break
+		}
+	}
+
+	policy := client.EffectiveToolPolicy()
+	if !policy.Negotiated {
+		t.Fatal("Expected init message to be observed")
+	}
+	assertToolList(t, "Unavailable", policy.Unavailable, []string{"Edit"})
+}
+
+func initMessage(tools ...string) *SystemMessage {
+	raw := make([]any, len(tools))
+	for i, tool := range tools {
+		raw[i] = tool
+	}
+	return &SystemMessage{
+		Subtype: SystemSubtypeInit,
+		Data:    map[string]any{"type": "system", "subtype": "init", "tools": raw},
+	}
+}
+
+func assertToolList(t *testing.T, label string, got, want []string) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %s %q, got %q", label, want, got)
+	}
+}
@@ -0,0 +1,104 @@
+package claudecode
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultToolProgressInterval is how often WithToolProgress reports tool
+// calls in flight when no interval is given.
+const DefaultToolProgressInterval = time.Second
+
+// defaultBashTimeout is how long the CLI lets a Bash command run when the
+// call does not set a timeout.
+const defaultBashTimeout = 2 * time.Minute
+
+// inFlight returns the tool uses still awaiting results, oldest first.
+func (tt *toolTracker) inFlight() []InFlightTool {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	now := tt.now()
+	tools := make([]InFlightTool, 0, len(tt.pending))
+	for id, use := range tt.pending {
+		tool := InFlightTool{
+			ToolUseID: id,
+			ToolName:  use.name,
+			Input:     use.input,
+			Started:   use.start,
+			Elapsed:   now.Sub(use.start),
+			Timeout:   toolTimeout(use.name, use.input),
+			Progress:  -1,
+		}
+		if tool.Timeout > 0 {
+			tool.Progress = float64(tool.Elapsed) / float64(tool.Timeout)
+			if tool.Progress > 1 {
+				tool.Progress = 1
+			}
+		}
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		if !tools[i].Started.Equal(tools[j].Started) {
+			return tools[i].Started.Before(tools[j].Started)
+		}
+		return tools[i].ToolUseID < tools[j].ToolUseID
+	})
+	return tools
+}
+
+// toolTimeout returns how long the CLI lets a tool call run, or 0 when it
+// is not known. Bash takes its timeout in milliseconds.
+func toolTimeout(name string, input map[string]any) time.Duration {
+	if name != "Bash" {
+		return 0
+	}
+	if ms, ok := input["timeout"].(float64); ok && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	return defaultBashTimeout
+}
+
+// reportToolProgress calls fn with the tool calls in flight every interval
+// until done is closed, and once with none after the last call in flight
+// finishes. A panicking fn does not stop the reports.
+func reportToolProgress(done <-chan struct{}, tools *toolTracker, interval time.Duration, fn ToolProgressFunc) {
+	if interval <= 0 {
+		interval = DefaultToolProgressInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reported := false
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		inFlight := tools.inFlight()
+		if len(inFlight) == 0 && !reported {
+			continue
+		}
+		reported = len(inFlight) > 0
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+			fn(inFlight)
+		}()
+	}
+}
+
+// InFlightTools returns the tool calls awaiting their results, oldest
+// first, with how long each has run and an estimate of its progress.
+func (c *ClientImpl) InFlightTools() []InFlightTool {
+	c.mu.RLock()
+	tools := c.tools
+	c.mu.RUnlock()
+
+	if tools == nil {
+		return []InFlightTool{}
+	}
+	return tools.inFlight()
+}
@@ -0,0 +1,125 @@
+package claudecode
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestToolTrackerInFlight(t *testing.T) {
+	tracker, clock := setupToolTrackerForTest(t, nil)
+
+	tracker.track(&AssistantMessage{Content: []ContentBlock{
+		&ToolUseBlock{ToolUseID: "bash-1", Name: "Bash", Input: map[string]any{"command": "make", "timeout": float64(600000)}},
+	}})
+	clock.advance(time.Minute)
+	tracker.track(toolUseMessage("bash-2", "Bash"))
+	tracker.track(toolUseMessage("read-1", "Read"))
+	clock.advance(30 * time.Second)
+	tracker.track(toolResultMessage("read-1", false))
+
+	got := tracker.inFlight()
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 tools in flight, got %+v", got)
+	}
+	first, second := got[0], got[1]
+	if first.ToolUseID != "bash-1" || first.Input["command"] != "make" || first.Elapsed != 90*time.Second {
+		t.Errorf("Expected the oldest call first, got %+v", first)
+	}
+	if first.Timeout != 10*time.Minute || first.Progress != 0.15 {
+		t.Errorf("Expected progress against the call's timeout, got %v of %v", first.Progress, first.Timeout)
+	}
+	if second.Timeout != defaultBashTimeout || second.Progress != 0.25 {
+		t.Errorf("Expected progress against the default timeout, got %v of %v", second.Progress, second.Timeout)
+	}
+
+	clock.advance(time.Hour)
+	if progress := tracker.inFlight()[1].Progress; progress != 1 {
+		t.Errorf("Expected progress capped at 1, got %v", progress)
+	}
+
+	tracker.track(toolUseMessage("grep-1", "Grep"))
+	if grep := tracker.inFlight()[2]; grep.Progress != -1 || grep.Timeout != 0 {
+		t.Errorf("Expected unknown progress for tools without a timeout, got %+v", grep)
+	}
+}
+
+func TestReportToolProgress(t *testing.T) {
+	tracker, _ := setupToolTrackerForTest(t, nil)
+	reports := make(chan []InFlightTool, 10)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		reportToolProgress(done, tracker, time.Millisecond, func(tools []InFlightTool) {
+			reports <- tools
+			if len(tools) > 0 && tools[0].ToolUseID == "panic" {
+				panic("UI bug")
+			}
+		})
+	}()
+	defer func() {
+		close(done)
+		<-exited
+	}()
+
+	tracker.track(toolUseMessage("panic", "Bash"))
+	if tools := receiveToolProgress(t, reports); len(tools) != 1 {
+		t.Fatalf("Expected the call in flight, got %+v", tools)
+	}
+	tracker.track(toolResultMessage("panic", false))
+	// A report follows the last call finishing, and none after it
+	for tools := receiveToolProgress(t, reports); len(tools) != 0; tools = receiveToolProgress(t, reports) {
+	}
+	select {
+	case tools := <-reports:
+		t.Errorf("Expected no reports while idle, got %+v", tools)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestClientInFlightTools(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reports := make(chan []InFlightTool, 100)
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		toolUseMessage("toolu_1", "Bash"),
+	}))
+	client := NewClientWithTransport(transport, WithToolProgress(time.Millisecond, func(tools []InFlightTool) {
+		select {
+		case reports <- tools:
+		default:
+		}
+	})).(*ClientImpl)
+	if tools := client.InFlightTools(); len(tools) != 0 {
+		t.Errorf("Expected no tools before connecting, got %+v", tools)
+	}
+
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+	for msg := range client.ReceiveMessages(ctx) {
+		if _, ok := msg.(*AssistantMessage); ok {
+			break
+		}
+	}
+
+	tools := client.InFlightTools()
+	if len(tools) != 1 || tools[0].ToolName != "Bash" || tools[0].Timeout != defaultBashTimeout {
+		t.Errorf("Expected the Bash call in flight, got %+v", tools)
+	}
+	if tools := receiveToolProgress(t, reports); len(tools) != 1 || tools[0].ToolUseID != "toolu_1" {
+		t.Errorf("Expected a progress report for the Bash call, got %+v", tools)
+	}
+}
+
+func receiveToolProgress(t *testing.T, reports <-chan []InFlightTool) []InFlightTool {
+	t.Helper()
+	select {
+	case tools := <-reports:
+		return tools
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a tool progress report")
+		return nil
+	}
+}
@@ -0,0 +1,205 @@
+package claudecode
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxToolLatencySamples bounds the latencies kept per tool for percentiles.
+// Once reached, the oldest samples are replaced.
+const maxToolLatencySamples = 1000
+
+// ToolStats summarizes the calls made to one tool.
+type ToolStats struct {
+	// Calls is the number of tool uses requested by the agent.
+	Calls int
+	// Completed is the number of tool results received.
+	Completed int
+	// Errors is the number of tool results flagged as errors.
+	Errors int
+	// ErrorRate is Errors divided by Completed, or 0 before any result.
+	ErrorRate float64
+
+	// Latency percentiles between a tool use and its result, computed over
+	// the most recent results.
+	LatencyP50 time.Duration
+	LatencyP90 time.Duration
+	LatencyP99 time.Duration
+	LatencyMax time.Duration
+}
+
+// toolTracker pairs ToolUseBlocks with their ToolResultBlocks to record
+// per-tool counts and latencies, and reports each call to an observer.
+type toolTracker struct {
+	mu       sync.Mutex
+	observer ToolObserver
+	now      func() time.Time
+	pending  map[string]pendingToolUse
+	tools    map[string]*toolRecord
+}
+
+type pendingToolUse struct {
+	name  string
+	input map[string]any
+	start time.Time
+}
+
+type toolRecord struct {
+	calls     int
+	completed int
+	errors    int
+	latencies []time.Duration
+	next      int // ring position once latencies is full
+}
+
+func newToolTracker(observer ToolObserver) *toolTracker {
+	return &toolTracker{
+		observer: observer,
+		now:      time.Now,
+		pending:  make(map[string]pendingToolUse),
+		tools:    make(map[string]*toolRecord),
+	}
+}
+
+// track records the tool uses and results in msg.
+func (tt *toolTracker) track(msg Message) {
+	var events []ToolEvent
+
+	tt.mu.Lock()
+	now := tt.now()
+	switch m := msg.(type) {
+	case *AssistantMessage:
+		for _, block := range m.Content {
+			if toolUse, ok := block.(*ToolUseBlock); ok {
+				tt.pending[toolUse.ToolUseID] = pendingToolUse{name: toolUse.Name, input: toolUse.Input, start: now}
+				tt.record(toolUse.Name).calls++
+				events = append(events, ToolEvent{
+					Type:      ToolEventStarted,
+					ToolUseID: toolUse.ToolUseID,
+					ToolName:  toolUse.Name,
+					Time:      now,
+				})
+			}
+		}
+	case *UserMessage:
+		blocks, _ := m.Content.([]ContentBlock)
+		for _, block := range blocks {
+			if result, ok := block.(*ToolResultBlock); ok {
+				if event, ok := tt.complete(result, now); ok {
+					events = append(events, event)
+				}
+			}
+		}
+	}
+	tt.mu.Unlock()
+
+	tt.notify(events)
+}
+
+// complete records a tool result. Results for unknown tool uses are ignored.
+// Must be called with tt.mu held.
+func (tt *toolTracker) complete(result *ToolResultBlock, now time.Time) (ToolEvent, bool) {
+	use, ok := tt.pending[result.ToolUseID]
+	if !ok {
+		return ToolEvent{}, false
+	}
+	delete(tt.pending, result.ToolUseID)
+
+	isError := result.Failed()
+	duration := now.Sub(use.start)
+
+	record := tt.record(use.name)
+	record.completed++
+	if isError {
+		record.errors++
+	}
+	if len(record.latencies) < maxToolLatencySamples {
+		record.latencies = append(record.latencies, duration)
+	} else {
+		record.latencies[record.next] = duration
+		record.next = (record.next + 1) % maxToolLatencySamples
+	}
+
+	return ToolEvent{
+		Type:      ToolEventCompleted,
+		ToolUseID: result.ToolUseID,
+		ToolName:  use.name,
+		Time:      now,
+		IsError:   isError,
+		Duration:  duration,
+	}, true
+}
+
+// record returns the record for name, creating it if needed.
+// Must be called with tt.mu held.
+func (tt *toolTracker) record(name string) *toolRecord {
+	record, ok := tt.tools[name]
+	if !ok {
+		record = &toolRecord{}
+		tt.tools[name] = record
+	}
+	return record
+}
+
+// notify delivers events to the observer. A panicking observer does not
+// interrupt message delivery.
+func (tt *toolTracker) notify(events []ToolEvent) {
+	if tt.observer == nil || len(events) == 0 {
+		return
+	}
+	defer func() {
+		_ = recover()
+	}()
+	for _, event := range events {
+		tt.observer(event)
+	}
+}
+
+// resetPending forgets tool uses still awaiting results, used when a new
+// connection starts.
+func (tt *toolTracker) resetPending() {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	tt.pending = make(map[string]pendingToolUse)
+}
+
+// stats returns a snapshot of the statistics for every tool seen.
+func (tt *toolTracker) stats() map[string]ToolStats {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	stats := make(map[string]ToolStats, len(tt.tools))
+	for name, record := range tt.tools {
+		s := ToolStats{
+			Calls:     record.calls,
+			Completed: record.completed,
+			Errors:    record.errors,
+		}
+		if record.completed > 0 {
+			s.ErrorRate = float64(record.errors) / float64(record.completed)
+		}
+		if len(record.latencies) > 0 {
+			sorted := make([]time.Duration, len(record.latencies))
+			copy(sorted, record.latencies)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+			s.LatencyP50 = percentile(sorted, 0.50)
+			s.LatencyP90 = percentile(sorted, 0.90)
+			s.LatencyP99 = percentile(sorted, 0.99)
+			s.LatencyMax = sorted[len(sorted)-1]
+		}
+		stats[name] = s
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank percentile p of sorted, which must
+// not be empty.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,194 @@
+package claudecode
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestToolTrackerStats(t *testing.T) {
+	tracker, clock := setupToolTrackerForTest(t, nil)
+
+	// Ten Bash calls taking 1..10 seconds, the last two failing
+	for i := 1; i <= 10; i++ {
+		id := fmt.Sprintf("bash-%d", i)
+		tracker.track(toolUseMessage(id, "Bash"))
+		clock.advance(time.Duration(i) * time.Second)
+		tracker.track(toolResultMessage(id, i > 8))
+	}
+	// One Read call still awaiting its result
+	tracker.track(toolUseMessage("read-1", "Read"))
+
+	stats := tracker.stats()
+	bash := stats["Bash"]
+	if bash.Calls != 10 || bash.Completed != 10 || bash.Errors != 2 {
+		t.Errorf("Unexpected Bash counts: %+v", bash)
+	}
+	if bash.ErrorRate != 0.2 {
+		t.Errorf("Expected error rate 0.2, got %v", bash.ErrorRate)
+	}
+	assertToolLatency(t, "p50", bash.LatencyP50, 5*time.Second)
+	assertToolLatency(t, "p90", bash.LatencyP90, 9*time.Second)
+	assertToolLatency(t, "p99", bash.LatencyP99, 10*time.Second)
+	assertToolLatency(t, "max", bash.LatencyMax, 10*time.Second)
+
+	read := stats["Read"]
+	if read.Calls != 1 || read.Completed != 0 || read.ErrorRate != 0 || read.LatencyMax != 0 {
+		t.Errorf("Unexpected stats for pending tool: %+v", read)
+	}
+}
+
+func TestToolTrackerIgnoresUnknownResults(t *testing.T) {
+	tracker, _ := setupToolTrackerForTest(t, nil)
+
+	tracker.track(toolResultMessage("never-requested", false))
+	tracker.track(&UserMessage{Content: "plain text"})
+
+	if stats := tracker.stats(); len(stats) != 0 {
+		t.Errorf("Expected no stats, got %+v", stats)
+	}
+}
+
+func TestToolTrackerBoundsLatencySamples(t *testing.T) {
+	tracker, clock := setupToolTrackerForTest(t, nil)
+
+	// Slow calls are pushed out of the window by later fast ones
+	for i := 0; i < maxToolLatencySamples+10; i++ {
+		id := fmt.Sprintf("grep-%d", i)
+		tracker.track(toolUseMessage(id, "Grep"))
+		if i < 10 {
+			clock.advance(time.Minute)
+		} else {
+			clock.advance(time.Millisecond)
+		}
+		tracker.track(toolResultMessage(id, false))
+	}
+
+	grep := tracker.stats()["Grep"]
+	if grep.Completed != maxToolLatencySamples+10 {
+		t.Errorf("Expected all results counted, got %d", grep.Completed)
+	}
+	assertToolLatency(t, "max", grep.LatencyMax, time.Millisecond)
+	if got := len(tracker.tools["Grep"].latencies); got != maxToolLatencySamples {
+		t.Errorf("Expected %d samples kept, got %d", maxToolLatencySamples, got)
+	}
+}
+
+func TestToolTrackerObserver(t *testing.T) {
+	var events []ToolEvent
+	tracker, clock := setupToolTrackerForTest(t, func(event ToolEvent) {
+		events = append(events, event)
+	})
+
+	tracker.track(toolUseMessage("toolu_1", "Edit"))
+	clock.advance(250 * time.Millisecond)
+	tracker.track(toolResultMessage("toolu_1", true))
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	started, completed := events[0], events[1]
+	if started.Type != ToolEventStarted || started.ToolName != "Edit" || started.ToolUseID != "toolu_1" {
+		t.Errorf("Unexpected start event: %+v", started)
+	}
+	if completed.Type != ToolEventCompleted || completed.ToolName != "Edit" || !completed.IsError {
+		t.Errorf("Unexpected completion event: %+v", completed)
+	}
+	if completed.Duration != 250*time.Millisecond {
+		t.Errorf("Expected duration 250ms, got %v", completed.Duration)
+	}
+}
+
+func TestToolTrackerObserverPanic(t *testing.T) {
+	tracker, _ := setupToolTrackerForTest(t, func(ToolEvent) {
+		panic("exporter failed")
+	})
+
+	tracker.track(toolUseMessage("toolu_1", "Bash"))
+	if tracker.stats()["Bash"].Calls != 1 {
+		t.Error("Expected tool use to be recorded despite observer panic")
+	}
+}
+
+func TestClientToolStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var events []ToolEvent
+	observer := func(event ToolEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+		toolUseMessage("toolu_1", "Bash"),
+		toolResultMessage("toolu_1", false),
+		&ResultMessage{Subtype: "success"},
+	}))
+	client := NewClientWithTransport(transport, WithToolObserver(observer)).(*ClientImpl)
+
+	if stats := client.ToolStats(); len(stats) != 0 {
+		t.Errorf("Expected no stats before connecting, got %+v", stats)
+	}
+
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	for msg := range client.ReceiveMessages(ctx) {
+		if _, ok := msg.(*ResultMessage); ok {
+			break
+		}
+	}
+
+	bash := client.ToolStats()["Bash"]
+	if bash.Calls != 1 || bash.Completed != 1 || bash.Errors != 0 {
+		t.Errorf("Unexpected Bash stats: %+v", bash)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 2 || events[0].Type != ToolEventStarted || events[1].Type != ToolEventCompleted {
+		t.Errorf("Expected start and completion events, got %+v", events)
+	}
+}
+
+// toolTrackerClock is a manually advanced clock for deterministic latencies.
+type toolTrackerClock struct {
+	now time.Time
+}
+
+func (c *toolTrackerClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func setupToolTrackerForTest(t *testing.T, observer ToolObserver) (*toolTracker, *toolTrackerClock) {
+	t.Helper()
+	clock := &toolTrackerClock{now: time.Unix(1700000000, 0)}
+	tracker := newToolTracker(observer)
+	tracker.now = func() time.Time { return clock.now }
+	return tracker, clock
+}
+
+func toolUseMessage(id, name string) *AssistantMessage {
+	return &AssistantMessage{
+		Content: []ContentBlock{&ToolUseBlock{ToolUseID: id, Name: name, Input: map[string]any{}}},
+		Model:   "claude-sonnet-4-5",
+	}
+}
+
+func toolResultMessage(id string, isError bool) *UserMessage {
+	return &UserMessage{
+		Content: []ContentBlock{&ToolResultBlock{ToolUseID: id, Content: "done", IsError: &isError}},
+	}
+}
+
+func assertToolLatency(t *testing.T, label string, got, want time.Duration) {
+	t.Helper()
+	if got != want {
+		t.Errorf("Expected %s latency %v, got %v", label, want, got)
+	}
+}
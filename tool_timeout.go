@@ -0,0 +1,116 @@
+package claudecode
+
+import "time"
+
+// toolTimeoutInterruptTimeout bounds the interrupt sent when a tool call
+// times out.
+const toolTimeoutInterruptTimeout = 5 * time.Second
+
+// toolTimeoutFor returns the limit for the tool name, falling back to the
+// "*" limit, or 0 for no limit.
+func toolTimeoutFor(timeouts map[string]time.Duration, name string) time.Duration {
+	if timeout, ok := timeouts[name]; ok {
+		return timeout
+	}
+	return timeouts["*"]
+}
+
+// enforceToolTimeouts copies messages from in to out until in is closed or
+// done is closed, then closes out. When a tool call's result does not
+// arrive within its limit, it calls interrupt. Messages pass through
+// unchanged: the CLI reports the interrupted call itself, so no result is
+// made up for it.
+func enforceToolTimeouts(done <-chan struct{}, in <-chan Message, out chan<- Message, timeouts map[string]time.Duration, interrupt func()) {
+	defer close(out)
+
+	// pending holds the deadlines of running calls, by tool use ID
+	pending := make(map[string]time.Time)
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	// schedule arms the timer for the earliest deadline
+	var expired <-chan time.Time
+	schedule := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, expired = nil, nil
+		}
+		var earliest time.Time
+		for _, deadline := range pending {
+			if earliest.IsZero() || deadline.Before(earliest) {
+				earliest = deadline
+			}
+		}
+		if !earliest.IsZero() {
+			timer = time.NewTimer(time.Until(earliest))
+			expired = timer.C
+		}
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+			trackToolDeadlines(msg, timeouts, pending)
+			schedule()
+			select {
+			case out <- msg:
+			case <-done:
+				return
+			}
+		case <-expired:
+			timer, expired = nil, nil
+			n := expireToolCalls(pending, time.Now())
+			schedule()
+			if n > 0 {
+				interrupt()
+			}
+		}
+	}
+}
+
+// trackToolDeadlines starts the deadlines of the tool uses in msg and ends
+// those of its tool results.
+func trackToolDeadlines(msg Message, timeouts map[string]time.Duration, pending map[string]time.Time) {
+	switch m := msg.(type) {
+	case *AssistantMessage:
+		now := time.Now()
+		for _, block := range m.Content {
+			if use, ok := block.(*ToolUseBlock); ok {
+				if limit := toolTimeoutFor(timeouts, use.Name); limit > 0 {
+					pending[use.ToolUseID] = now.Add(limit)
+				}
+			}
+		}
+	case *UserMessage:
+		blocks, ok := m.Content.([]ContentBlock)
+		if !ok {
+			return
+		}
+		for _, block := range blocks {
+			if result, ok := block.(*ToolResultBlock); ok {
+				delete(pending, result.ToolUseID)
+			}
+		}
+	}
+}
+
+// expireToolCalls ends the calls past their deadline at now, returning how
+// many there were.
+func expireToolCalls(pending map[string]time.Time, now time.Time) int {
+	n := 0
+	for id, deadline := range pending {
+		if !deadline.After(now) {
+			delete(pending, id)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,127 @@
+package claudecode
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestToolTimeoutFor(t *testing.T) {
+	timeouts := map[string]time.Duration{"Bash": time.Minute, "*": time.Hour}
+	if got := toolTimeoutFor(timeouts, "Bash"); got != time.Minute {
+		t.Errorf("Expected the tool's own limit, got %v", got)
+	}
+	if got := toolTimeoutFor(timeouts, "WebFetch"); got != time.Hour {
+		t.Errorf("Expected the wildcard limit, got %v", got)
+	}
+	if got := toolTimeoutFor(map[string]time.Duration{"Bash": time.Minute}, "Read"); got != 0 {
+		t.Errorf("Expected no limit, got %v", got)
+	}
+}
+
+func TestEnforceToolTimeouts(t *testing.T) {
+	in := make(chan Message)
+	out := make(chan Message)
+	done := make(chan struct{})
+	defer close(done)
+	interrupts := make(chan struct{}, 2)
+	go enforceToolTimeouts(done, in, out, map[string]time.Duration{"Bash": 10 * time.Millisecond}, func() {
+		interrupts <- struct{}{}
+	})
+
+	in <- &AssistantMessage{Content: []ContentBlock{
+		&ToolUseBlock{ToolUseID: "bash-1", Name: "Bash"},
+		&ToolUseBlock{ToolUseID: "read-1", Name: "Read"},
+	}}
+	receiveTimeoutMessage(t, out)
+
+	// Only the Bash call has a limit
+	select {
+	case <-interrupts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the hung call to interrupt the turn")
+	}
+
+	// Nothing is made up for the call; the CLI's results pass through
+	interrupted := toolResultMessage("bash-1", true)
+	in <- interrupted
+	if msg := receiveTimeoutMessage(t, out); msg != interrupted {
+		t.Errorf("Expected the CLI's result unchanged, got %+v", msg)
+	}
+	in <- &UserMessage{Content: []ContentBlock{
+		&ToolResultBlock{ToolUseID: "read-1", Content: "file"},
+	}}
+	receiveTimeoutMessage(t, out)
+
+	// A result in time stops the clock
+	in <- &AssistantMessage{Content: []ContentBlock{&ToolUseBlock{ToolUseID: "bash-2", Name: "Bash"}}}
+	receiveTimeoutMessage(t, out)
+	in <- toolResultMessage("bash-2", false)
+	receiveTimeoutMessage(t, out)
+	select {
+	case msg := <-out:
+		t.Errorf("Expected no message of the SDK's own, got %+v", msg)
+	case <-interrupts:
+		t.Error("Expected no interrupt for a completed call")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	close(in)
+	if _, ok := <-out; ok {
+		t.Error("Expected the output to close with the input")
+	}
+}
+
+func TestClientToolTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	transport := &interruptCountingTransport{clientMockTransport: newClientMockTransportWithOptions(
+		WithClientResponseMessages([]Message{toolUseMessage("toolu_1", "Bash")}),
+	)}
+	client := NewClientWithTransport(transport, WithToolTimeout(map[string]time.Duration{"Bash": 10 * time.Millisecond})).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	msgs := client.ReceiveMessages(ctx)
+	<-msgs
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&transport.interrupts) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&transport.interrupts); n != 1 {
+		t.Fatalf("Expected the turn to be interrupted once, got %d", n)
+	}
+	select {
+	case msg := <-msgs:
+		t.Errorf("Expected no result made up for the hung call, got %+v", msg)
+	case <-time.After(30 * time.Millisecond):
+	}
+	// The call is running until the CLI reports it
+	if tools := client.InFlightTools(); len(tools) != 1 {
+		t.Errorf("Expected the call still in flight, got %+v", tools)
+	}
+}
+
+// interruptCountingTransport counts interrupts.
+type interruptCountingTransport struct {
+	*clientMockTransport
+	interrupts int32
+}
+
+func (c *interruptCountingTransport) Interrupt(ctx context.Context) error {
+	atomic.AddInt32(&c.interrupts, 1)
+	return c.clientMockTransport.Interrupt(ctx)
+}
+
+func receiveTimeoutMessage(t *testing.T, out <-chan Message) Message {
+	t.Helper()
+	select {
+	case msg := <-out:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a message")
+		return nil
+	}
+}
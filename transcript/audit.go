@@ -0,0 +1,307 @@
+package transcript
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// ErrTampered is wrapped by the errors of Verify for audit trails that were
+// altered, reordered or cut short at the start.
+var ErrTampered = errors.New("audit trail tampered")
+
+// Directions of audited messages.
+const (
+	// DirectionSent marks prompts the client sent.
+	DirectionSent = "sent"
+	// DirectionReceived marks messages the client received from the CLI.
+	DirectionReceived = "received"
+)
+
+// Record is one line of an audit trail.
+type Record struct {
+	// Seq numbers the records of a trail from 1.
+	Seq       int             `json:"seq"`
+	Time      time.Time       `json:"time"`
+	Direction string          `json:"direction"`
+	Type      string          `json:"type"`
+	Message   json.RawMessage `json:"message"`
+	// PrevHash is the Hash of the previous record, empty for the first.
+	PrevHash string `json:"prev_hash"`
+	// Hash is the hex SHA-256 of the record's other fields, PrevHash
+	// included, so changing any record breaks the chain after it.
+	Hash string `json:"hash"`
+	// Signature signs the Hash's digest, when the recorder has a signer.
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// Recorder writes messages to an audit trail, one JSON record per line,
+// each chaining the hash of the one before and optionally signed. Verify
+// checks a trail. It is safe for concurrent use.
+//
+//	recorder, err := transcript.OpenRecorder("audit.jsonl", signer)
+//	if err != nil {
+//		return err
+//	}
+//	defer recorder.Close()
+//	client := claudecode.NewClient(transcript.WithAuditTrail(recorder))
+//
+// A hash chain shows that records were not changed, removed or reordered
+// after others were written; only signatures show who wrote them, since
+// anyone can rewrite a chain from the point they altered. Keep Head
+// somewhere the trail's writer cannot change to detect records removed
+// from the end.
+type Recorder struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	signer crypto.Signer
+	now    func() time.Time
+	seq    int
+	head   string
+	err    error
+}
+
+// NewRecorder returns a Recorder starting a new trail on w. signer, if not
+// nil, signs each record: Ed25519, ECDSA and RSA (PKCS #1 v1.5) keys are
+// supported.
+func NewRecorder(w io.Writer, signer crypto.Signer) *Recorder {
+	return &Recorder{w: w, signer: signer, now: time.Now}
+}
+
+// OpenRecorder returns a Recorder appending to the trail in the file at
+// path, creating it if needed. It checks the existing records, without
+// their signatures, and refuses to continue a trail whose chain is broken.
+func OpenRecorder(path string, signer crypto.Signer) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit trail: %w", err)
+	}
+	records, err := VerifyReader(f, nil)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("continuing audit trail %s: %w", path, err)
+	}
+	r := NewRecorder(f, signer)
+	r.closer = f
+	if len(records) > 0 {
+		last := records[len(records)-1]
+		r.seq, r.head = last.Seq, last.Hash
+	}
+	return r, nil
+}
+
+// Record appends msg to the trail. direction is DirectionSent or
+// DirectionReceived. After a write fails, the trail is broken and Record
+// returns that error.
+func (r *Recorder) Record(direction string, msg claudecode.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encoding %s message: %w", msg.Type(), err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	record := Record{
+		Seq:       r.seq + 1,
+		Time:      r.now().UTC(),
+		Direction: direction,
+		Type:      msg.Type(),
+		Message:   data,
+		PrevHash:  r.head,
+	}
+	digest, err := recordDigest(record)
+	if err != nil {
+		return err
+	}
+	record.Hash = hex.EncodeToString(digest)
+	if r.signer != nil {
+		record.Signature, err = sign(r.signer, digest)
+		if err != nil {
+			return fmt.Errorf("signing audit record %d: %w", record.Seq, err)
+		}
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encoding audit record %d: %w", record.Seq, err)
+	}
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		r.err = fmt.Errorf("writing audit record %d: %w", record.Seq, err)
+		return r.err
+	}
+	r.seq, r.head = record.Seq, record.Hash
+	return nil
+}
+
+// Head returns the hash of the last record written, or "" for an empty
+// trail.
+func (r *Recorder) Head() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.head
+}
+
+// Err returns the error that broke the trail, if any. Messages recorded by
+// WithAuditTrail's observer report errors only here.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close closes the file of a recorder from OpenRecorder. It does nothing
+// for one from NewRecorder.
+func (r *Recorder) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// WithAuditTrail records the client's prompts and the messages it receives
+// to r. A prompt that cannot be recorded is not sent. Like other message
+// observers it applies to the Client and not to the Query function.
+func WithAuditTrail(r *Recorder) claudecode.Option {
+	return func(o *claudecode.Options) {
+		o.PromptInterceptors = append(o.PromptInterceptors, func(_ context.Context, msg *claudecode.UserMessage) error {
+			return r.Record(DirectionSent, msg)
+		})
+		o.MessageObservers = append(o.MessageObservers, func(msg claudecode.Message) {
+			_ = r.Record(DirectionReceived, msg)
+		})
+	}
+}
+
+// Verify reads the audit trail in the file at path and checks its chain
+// and, when key is not nil, that every record is signed by key's private
+// key. It returns the records, or an error wrapping ErrTampered that names
+// the first bad record.
+func Verify(path string, key crypto.PublicKey) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit trail: %w", err)
+	}
+	defer f.Close()
+	return VerifyReader(f, key)
+}
+
+// VerifyReader is Verify for a trail read from r.
+func VerifyReader(r io.Reader, key crypto.PublicKey) ([]Record, error) {
+	switch key.(type) {
+	case nil, ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("verifying audit trail: unsupported key type %T", key)
+	}
+	var records []Record
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return records, fmt.Errorf("reading audit trail: %w", err)
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			var record Record
+			if jsonErr := json.Unmarshal(data, &record); jsonErr != nil {
+				return records, fmt.Errorf("%w: line %d is not a record: %v", ErrTampered, line, jsonErr)
+			}
+			prevHash := ""
+			if len(records) > 0 {
+				prevHash = records[len(records)-1].Hash
+			}
+			if verifyErr := verifyRecord(record, len(records)+1, prevHash, key); verifyErr != nil {
+				return records, verifyErr
+			}
+			records = append(records, record)
+		}
+		if err == io.EOF {
+			return records, nil
+		}
+	}
+}
+
+// verifyRecord checks that record is record seq of its trail and follows
+// the record hashed to prevHash.
+func verifyRecord(record Record, seq int, prevHash string, key crypto.PublicKey) error {
+	if record.Seq != seq {
+		return fmt.Errorf("%w: record %d found where record %d belongs", ErrTampered, record.Seq, seq)
+	}
+	if record.PrevHash != prevHash {
+		return fmt.Errorf("%w: record %d does not follow record %d", ErrTampered, record.Seq, seq-1)
+	}
+	digest, err := recordDigest(record)
+	if err != nil {
+		return err
+	}
+	if record.Hash != hex.EncodeToString(digest) {
+		return fmt.Errorf("%w: record %d does not match its hash", ErrTampered, record.Seq)
+	}
+	if key == nil {
+		return nil
+	}
+	if len(record.Signature) == 0 {
+		return fmt.Errorf("%w: record %d is not signed", ErrTampered, record.Seq)
+	}
+	if !verifySignature(key, digest, record.Signature) {
+		return fmt.Errorf("%w: record %d has a bad signature", ErrTampered, record.Seq)
+	}
+	return nil
+}
+
+// recordDigest hashes the fields of record that its Hash covers.
+func recordDigest(record Record) ([]byte, error) {
+	data, err := json.Marshal(struct {
+		Seq       int             `json:"seq"`
+		Time      string          `json:"time"`
+		Direction string          `json:"direction"`
+		Type      string          `json:"type"`
+		Message   json.RawMessage `json:"message"`
+		PrevHash  string          `json:"prev_hash"`
+	}{record.Seq, record.Time.UTC().Format(time.RFC3339Nano), record.Direction, record.Type, record.Message, record.PrevHash})
+	if err != nil {
+		return nil, fmt.Errorf("hashing audit record %d: %w", record.Seq, err)
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// sign signs digest. Ed25519 signs it as a message, since it hashes what
+// it signs itself.
+func sign(signer crypto.Signer, digest []byte) ([]byte, error) {
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, digest, crypto.Hash(0))
+	}
+	return signer.Sign(rand.Reader, digest, crypto.SHA256)
+}
+
+// verifySignature reports whether signature is key's signature of digest,
+// as made by sign.
+func verifySignature(key crypto.PublicKey, digest, signature []byte) bool {
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, digest, signature)
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(k, digest, signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, signature) == nil
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package transcript
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestRecorderChainsRecords(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf, nil)
+	for _, msg := range sampleRun() {
+		if err := recorder.Record(DirectionReceived, msg); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	records, err := VerifyReader(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(records) != len(sampleRun()) {
+		t.Fatalf("Expected %d records, got %d", len(sampleRun()), len(records))
+	}
+	if records[0].PrevHash != "" || records[1].PrevHash != records[0].Hash || records[len(records)-1].Hash != recorder.Head() {
+		t.Errorf("Expected the records to be chained, got %+v", records)
+	}
+	if records[0].Seq != 1 || records[0].Type != sampleRun()[0].Type() || records[0].Direction != DirectionReceived || len(records[0].Signature) != 0 {
+		t.Errorf("Unexpected first record: %+v", records[0])
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf, nil)
+	for _, text := range []string{"first", "second <b>&", "third"} {
+		if err := recorder.Record(DirectionSent, &claudecode.UserMessage{Content: text}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	tests := []struct {
+		name  string
+		trail string
+	}{
+		{"edited", strings.Replace(buf.String(), "second", "2nd", 1)},
+		{"removed", lines[0] + lines[2]},
+		{"reordered", lines[1] + lines[0] + lines[2]},
+		{"cut at the start", lines[1] + lines[2]},
+		{"garbled", buf.String() + "{not json\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := VerifyReader(strings.NewReader(test.trail), nil); !errors.Is(err, ErrTampered) {
+				t.Errorf("Expected ErrTampered, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRecorderSignatures(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+
+	for _, signer := range []crypto.Signer{edKey, ecKey, rsaKey} {
+		var buf bytes.Buffer
+		recorder := NewRecorder(&buf, signer)
+		if err := recorder.Record(DirectionSent, &claudecode.UserMessage{Content: "deploy"}); err != nil {
+			t.Fatalf("Record with %T failed: %v", signer, err)
+		}
+		if _, err := VerifyReader(bytes.NewReader(buf.Bytes()), signer.Public()); err != nil {
+			t.Errorf("Expected the %T signature to verify, got %v", signer, err)
+		}
+	}
+
+	// A chain rewritten without the key fails verification
+	var buf bytes.Buffer
+	_ = NewRecorder(&buf, nil).Record(DirectionSent, &claudecode.UserMessage{Content: "deploy"})
+	if _, err := VerifyReader(bytes.NewReader(buf.Bytes()), edKey.Public()); !errors.Is(err, ErrTampered) {
+		t.Errorf("Expected unsigned records to fail, got %v", err)
+	}
+	otherPublic, _, _ := ed25519.GenerateKey(rand.Reader)
+	buf.Reset()
+	_ = NewRecorder(&buf, edKey).Record(DirectionSent, &claudecode.UserMessage{Content: "deploy"})
+	if _, err := VerifyReader(bytes.NewReader(buf.Bytes()), otherPublic); !errors.Is(err, ErrTampered) {
+		t.Errorf("Expected another key's signature to fail, got %v", err)
+	}
+	if _, err := VerifyReader(bytes.NewReader(buf.Bytes()), "not a key"); err == nil || errors.Is(err, ErrTampered) {
+		t.Errorf("Expected an unsupported key to be rejected, got %v", err)
+	}
+}
+
+func TestOpenRecorderContinuesTrail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+
+	for _, text := range []string{"first", "second"} {
+		recorder, err := OpenRecorder(path, key)
+		if err != nil {
+			t.Fatalf("OpenRecorder failed: %v", err)
+		}
+		if err := recorder.Record(DirectionSent, &claudecode.UserMessage{Content: text}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+		if err := recorder.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+	}
+	records, err := Verify(path, key.Public())
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(records) != 2 || records[1].Seq != 2 {
+		t.Errorf("Expected the second recorder to continue the trail, got %+v", records)
+	}
+
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, bytes.Replace(data, []byte("first"), []byte("other"), 1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenRecorder(path, key); !errors.Is(err, ErrTampered) {
+		t.Errorf("Expected a tampered trail not to be continued, got %v", err)
+	}
+}
+
+func TestWithAuditTrail(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf, nil)
+	options := claudecode.NewOptions(WithAuditTrail(recorder))
+	if len(options.PromptInterceptors) != 1 || len(options.MessageObservers) != 1 {
+		t.Fatalf("Expected an interceptor and an observer, got %d and %d", len(options.PromptInterceptors), len(options.MessageObservers))
+	}
+	if err := options.PromptInterceptors[0](context.Background(), &claudecode.UserMessage{Content: "hi"}); err != nil {
+		t.Fatalf("Interceptor failed: %v", err)
+	}
+	options.MessageObservers[0](&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{&claudecode.TextBlock{Text: "hello"}}})
+
+	records, err := VerifyReader(bytes.NewReader(buf.Bytes()), nil)
+	if err != nil || len(records) != 2 || records[0].Direction != DirectionSent || records[1].Direction != DirectionReceived {
+		t.Errorf("Expected the prompt and reply recorded, got %+v, %v", records, err)
+	}
+
+	failing := NewRecorder(failingWriter{}, nil)
+	options = claudecode.NewOptions(WithAuditTrail(failing))
+	if err := options.PromptInterceptors[0](context.Background(), &claudecode.UserMessage{Content: "hi"}); err == nil {
+		t.Error("Expected a prompt that cannot be recorded to be stopped")
+	}
+	if failing.Err() == nil {
+		t.Error("Expected the broken trail to be reported")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
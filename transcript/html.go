@@ -0,0 +1,134 @@
+package transcript
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// stylesheet keeps exported pages readable without external resources.
+const stylesheet = `body{font-family:system-ui,sans-serif;max-width:56rem;margin:2rem auto;padding:0 1rem;line-height:1.5;color:#1f2328}
+.meta,.result{color:#59636e;font-size:.9rem}
+.turn{border-left:3px solid #d1d9e0;margin:1.5rem 0;padding:0 1rem}
+.turn.user{border-color:#0969da}.turn.assistant{border-color:#8250df}
+.role{font-weight:600;margin:0 0 .5rem}
+.text{white-space:pre-wrap}
+details{margin:.75rem 0}summary{cursor:pointer;color:#59636e}
+.tool{background:#f6f8fa;border-radius:6px;margin:.75rem 0;padding:.5rem .75rem}
+.tool.error{background:#ffebe9}
+.tool-name{font-family:ui-monospace,monospace;font-weight:600}
+pre{overflow-x:auto;margin:.5rem 0}code{font-family:ui-monospace,monospace;font-size:.85rem}
+.result{border-top:1px solid #d1d9e0;padding-top:.5rem}`
+
+// ExportHTML renders messages as a self-contained HTML page. Thinking
+// blocks and tool results are collapsed in <details> elements; code blocks
+// carry a language-* class for client-side highlighters unless
+// WithHighlighter renders them.
+func ExportHTML(messages []claudecode.Message, opts ...Option) string {
+	c := newConfig(opts)
+	h, turns := build(messages, c)
+
+	var b strings.Builder
+	title := html.EscapeString(c.title)
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s\n</style>\n</head>\n<body>\n<h1>%s</h1>\n", title, stylesheet, title)
+	if meta := htmlHeaderLine(h); meta != "" {
+		fmt.Fprintf(&b, "<p class=\"meta\">%s</p>\n", meta)
+	}
+
+	for _, t := range turns {
+		if t.role == "" {
+			for _, p := range t.parts {
+				writeHTMLPart(&b, p, c)
+			}
+			continue
+		}
+		label := "User"
+		if t.role == "assistant" {
+			label = "Assistant"
+		}
+		fmt.Fprintf(&b, "<section class=\"turn %s\">\n<p class=\"role\">%s</p>\n", t.role, label)
+		for _, p := range t.parts {
+			writeHTMLPart(&b, p, c)
+		}
+		b.WriteString("</section>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+func writeHTMLPart(b *strings.Builder, p part, c *config) {
+	switch p.kind {
+	case partText:
+		b.WriteString(htmlText(p.text, c))
+	case partThinking:
+		fmt.Fprintf(b, "<details class=\"thinking\">\n<summary>Thinking</summary>\n%s</details>\n", htmlText(p.text, c))
+	case partToolCall:
+		writeHTMLToolCall(b, p.call, c)
+	case partResult:
+		fmt.Fprintf(b, "<p class=\"result\">%s</p>\n", html.EscapeString(resultSummary(p.result)))
+	}
+}
+
+func writeHTMLToolCall(b *strings.Builder, call ToolCall, c *config) {
+	class := "tool"
+	if call.Result != nil && call.Result.Failed() {
+		class += " error"
+	}
+	fmt.Fprintf(b, "<div class=\"%s\">\n", class)
+	if use := call.Use; use != nil {
+		code, language := toolInput(use)
+		fmt.Fprintf(b, "<p class=\"tool-name\">%s</p>\n%s", html.EscapeString(use.Name), htmlCode(code, language, c))
+	} else {
+		fmt.Fprintf(b, "<p class=\"tool-name\">%s</p>\n", html.EscapeString(call.Result.ToolUseID))
+	}
+	if result := call.Result; result != nil {
+		summary := "Result"
+		if result.Failed() {
+			summary = "Error"
+		}
+		fmt.Fprintf(b, "<details>\n<summary>%s</summary>\n%s</details>\n", summary, htmlCode(toolOutput(result, c), c.language(call), c))
+	}
+	b.WriteString("</div>\n")
+}
+
+// htmlText renders user or assistant text with the text renderer, or
+// escaped with its line breaks kept.
+func htmlText(text string, c *config) string {
+	text = strings.TrimSpace(text)
+	if c.textRenderer != nil {
+		return fmt.Sprintf("<div class=\"text-rendered\">%s</div>\n", c.textRenderer(text))
+	}
+	return fmt.Sprintf("<div class=\"text\">%s</div>\n", html.EscapeString(text))
+}
+
+// htmlCode renders a code block with the highlighter, or escaped and
+// tagged with its language.
+func htmlCode(code, language string, c *config) string {
+	code = strings.TrimRight(code, "\n")
+	if c.highlighter != nil {
+		if highlighted, ok := c.highlighter(code, language); ok {
+			return highlighted + "\n"
+		}
+	}
+	return fmt.Sprintf("<pre><code class=\"language-%s\">%s</code></pre>\n", html.EscapeString(language), html.EscapeString(code))
+}
+
+func htmlHeaderLine(h header) string {
+	var parts []string
+	if h.sessionID != "" {
+		parts = append(parts, "Session <code>"+html.EscapeString(h.sessionID)+"</code>")
+	}
+	if h.model != "" {
+		parts = append(parts, "Model <code>"+html.EscapeString(h.model)+"</code>")
+	}
+	if len(h.tags) > 0 {
+		tags := make([]string, len(h.tags))
+		for i, tag := range h.tags {
+			tags[i] = "<code>" + html.EscapeString(tag) + "</code>"
+		}
+		parts = append(parts, "Tags "+strings.Join(tags, ", "))
+	}
+	return strings.Join(parts, " · ")
+}
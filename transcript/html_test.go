@@ -0,0 +1,53 @@
+package transcript
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExportHTML(t *testing.T) {
+	got := ExportHTML(sampleRun(), WithTitle("Build <failure>"), WithTags(map[string]string{"ticket": "<OPS-1>"}))
+
+	for _, want := range []string{
+		"<title>Build &lt;failure&gt;</title>",
+		"<p class=\"meta\">Session <code>session-1</code> · Model <code>claude-test</code> · Tags <code>ticket=&lt;OPS-1&gt;</code></p>",
+		"<section class=\"turn user\">\n<p class=\"role\">User</p>\n<div class=\"text\">Why does the build fail?</div>",
+		"<details class=\"thinking\">\n<summary>Thinking</summary>\n<div class=\"text\">Check main.go first.</div>\n</details>",
+		"<pre><code class=\"language-json\">{\n  &#34;file_path&#34;: &#34;main.go&#34;\n}</code></pre>",
+		"<div class=\"tool error\">\n<p class=\"tool-name\">Bash</p>",
+		"<p class=\"tool-name\">toolu_lost</p>",
+		"The variable `x` is &lt;unused&gt;.",
+		"<p class=\"result\">success · 3 turns · 12.4s · $0.0213</p>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected HTML to contain %q, got:\n%s", want, got)
+		}
+	}
+	if !strings.HasSuffix(got, "</body>\n</html>\n") {
+		t.Errorf("Expected a complete document, got:\n%s", got)
+	}
+}
+
+func TestExportHTMLHooks(t *testing.T) {
+	highlighter := func(code, language string) (string, bool) {
+		if language != "go" {
+			return "", false
+		}
+		return fmt.Sprintf("<pre class=\"hl\">%d bytes of go</pre>", len(code)), true
+	}
+	render := func(markdown string) string {
+		return "<p>" + strings.ToUpper(markdown) + "</p>"
+	}
+	got := ExportHTML(sampleRun(), WithHighlighter(highlighter), WithTextRenderer(render))
+
+	if !strings.Contains(got, "<pre class=\"hl\">") {
+		t.Errorf("Expected the highlighter to render the Go result, got:\n%s", got)
+	}
+	if !strings.Contains(got, "<code class=\"language-bash\">go build ./...</code>") {
+		t.Errorf("Expected a fallback for languages the highlighter declines, got:\n%s", got)
+	}
+	if !strings.Contains(got, "<div class=\"text-rendered\"><p>LET ME LOOK AT THE CODE.</p></div>") {
+		t.Errorf("Expected the text renderer output, got:\n%s", got)
+	}
+}
@@ -0,0 +1,97 @@
+package transcript
+
+import (
+	"fmt"
+	"strings"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// ExportMarkdown renders messages as a Markdown document. Thinking blocks
+// are collapsed in <details> elements, which GitHub and most viewers
+// support.
+func ExportMarkdown(messages []claudecode.Message, opts ...Option) string {
+	c := newConfig(opts)
+	h, turns := build(messages, c)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", c.title)
+	if meta := headerLine(h); meta != "" {
+		fmt.Fprintf(&b, "\n%s\n", meta)
+	}
+
+	for _, t := range turns {
+		switch t.role {
+		case "user":
+			b.WriteString("\n## User\n")
+		case "assistant":
+			b.WriteString("\n## Assistant\n")
+		}
+		for _, p := range t.parts {
+			writeMarkdownPart(&b, p, c)
+		}
+	}
+	return b.String()
+}
+
+func writeMarkdownPart(b *strings.Builder, p part, c *config) {
+	switch p.kind {
+	case partText:
+		fmt.Fprintf(b, "\n%s\n", strings.TrimSpace(p.text))
+	case partThinking:
+		fmt.Fprintf(b, "\n<details>\n<summary>Thinking</summary>\n\n%s\n\n</details>\n", strings.TrimSpace(p.text))
+	case partToolCall:
+		if use := p.call.Use; use != nil {
+			code, language := toolInput(use)
+			fmt.Fprintf(b, "\n**Tool call:** `%s`\n\n%s", use.Name, fence(code, language))
+		}
+		if result := p.call.Result; result != nil {
+			label := "Result"
+			if result.Failed() {
+				label = "Error"
+			}
+			if p.call.Use == nil {
+				label = fmt.Sprintf("%s of `%s`", label, result.ToolUseID)
+			}
+			fmt.Fprintf(b, "\n**%s:**\n\n%s", label, fence(toolOutput(result, c), c.language(p.call)))
+		}
+	case partResult:
+		fmt.Fprintf(b, "\n---\n\n*%s*\n", resultSummary(p.result))
+	}
+}
+
+// headerLine describes the session, for example "Session `abc` · Model `m`".
+func headerLine(h header) string {
+	var parts []string
+	if h.sessionID != "" {
+		parts = append(parts, fmt.Sprintf("Session `%s`", h.sessionID))
+	}
+	if h.model != "" {
+		parts = append(parts, fmt.Sprintf("Model `%s`", h.model))
+	}
+	if len(h.tags) > 0 {
+		parts = append(parts, fmt.Sprintf("Tags `%s`", strings.Join(h.tags, "`, `")))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// fence wraps code in a fenced code block, lengthening the fence past any
+// run of backticks in code.
+func fence(code, language string) string {
+	longest, run := 0, 0
+	for _, r := range code {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	marker := strings.Repeat("`", 3)
+	if longest >= 3 {
+		marker = strings.Repeat("`", longest+1)
+	}
+	return fmt.Sprintf("%s%s\n%s\n%s\n", marker, language, strings.TrimRight(code, "\n"), marker)
+}
@@ -0,0 +1,57 @@
+package transcript
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExportMarkdown(t *testing.T) {
+	got := ExportMarkdown(sampleRun(), WithTitle("Build failure"))
+
+	for _, want := range []string{
+		"# Build failure\n\nSession `session-1` · Model `claude-test`\n",
+		"\n## User\n\nWhy does the build fail?\n",
+		"\n## Assistant\n\n<details>\n<summary>Thinking</summary>\n\nCheck main.go first.\n\n</details>\n",
+		"**Tool call:** `Read`\n\n```json\n{\n  \"file_path\": \"main.go\"\n}\n```\n",
+		// The fence outgrows backticks in the output
+		"**Result:**\n\n````go\npackage main\n\nfunc main() { x := \"```\" }\n````\n",
+		"**Tool call:** `Bash`\n\n```bash\ngo build ./...\n```\n\n**Error:**\n\n```console\n",
+		"**Result of `toolu_lost`:**\n\n```text\nlate output\n```\n",
+		"The variable `x` is <unused>.\n",
+		"\n---\n\n*success · 3 turns · 12.4s · $0.0213*\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected Markdown to contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "## Assistant") != 1 {
+		t.Errorf("Expected consecutive assistant messages under one heading, got:\n%s", got)
+	}
+}
+
+func TestExportMarkdownOptions(t *testing.T) {
+	got := ExportMarkdown(sampleRun(), WithoutThinking(), WithLanguage(func(ToolCall) string { return "plain" }))
+
+	if !strings.HasPrefix(got, "# "+DefaultTitle+"\n") {
+		t.Errorf("Expected the default title, got:\n%s", got)
+	}
+	if strings.Contains(got, "Thinking") {
+		t.Errorf("Expected thinking to be left out, got:\n%s", got)
+	}
+	if !strings.Contains(got, "```plain\nlate output\n```") {
+		t.Errorf("Expected the language hook to tag results, got:\n%s", got)
+	}
+}
+
+func TestExportMarkdownEmpty(t *testing.T) {
+	if got := ExportMarkdown(nil); got != "# "+DefaultTitle+"\n" {
+		t.Errorf("Expected only the title, got %q", got)
+	}
+}
+
+func TestExportMarkdownTags(t *testing.T) {
+	got := ExportMarkdown(sampleRun(), WithTags(map[string]string{"team": "payments", "env": "prod"}))
+	if want := "Session `session-1` · Model `claude-test` · Tags `env=prod`, `team=payments`\n"; !strings.Contains(got, want) {
+		t.Errorf("Expected Markdown to contain %q, got:\n%s", want, got)
+	}
+}
@@ -0,0 +1,364 @@
+// Package transcript renders conversations as shareable Markdown or HTML
+// reports.
+//
+// ExportMarkdown and ExportHTML take the messages of an agent run, as
+// received from Query or Client.ReceiveMessages, and render user and
+// assistant turns, thinking blocks collapsed behind a summary, and each
+// tool call together with its result. Turn results are summarised after the
+// turn they end.
+//
+// Example:
+//
+//	var messages []claudecode.Message
+//	for msg := range client.ReceiveMessages(ctx) {
+//		messages = append(messages, msg)
+//		// ...
+//	}
+//	report := transcript.ExportHTML(messages, transcript.WithTitle("Nightly refactor"))
+//
+// Tool inputs and results are rendered as code blocks tagged with a
+// language, chosen by WithLanguage, so Markdown viewers and client-side
+// highlighters such as Prism or highlight.js can color them. WithHighlighter
+// highlights the HTML export on the server instead.
+//
+// For regulated environments, a Recorder keeps a tamper-evident audit
+// trail of a client's messages as they happen: hash-chained JSON records,
+// optionally signed, that Verify checks.
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// DefaultTitle is the heading of a transcript without WithTitle.
+const DefaultTitle = "Conversation"
+
+// ToolCall is a tool use paired with its result.
+type ToolCall struct {
+	// Use is nil for a result whose tool use is not in the transcript.
+	Use *claudecode.ToolUseBlock
+	// Result is nil when the transcript ends before the tool returned.
+	Result *claudecode.ToolResultBlock
+}
+
+// Name returns the tool name, or "" when the tool use is unknown.
+func (c ToolCall) Name() string {
+	if c.Use == nil {
+		return ""
+	}
+	return c.Use.Name
+}
+
+// Highlighter renders code in language as HTML for ExportHTML. Returning
+// false falls back to escaped code tagged with its language.
+type Highlighter func(code, language string) (html string, ok bool)
+
+// Option configures an export.
+type Option func(*config)
+
+type config struct {
+	title         string
+	tags          map[string]string
+	hideThinking  bool
+	maxToolOutput int
+	language      func(ToolCall) string
+	highlighter   Highlighter
+	textRenderer  func(markdown string) string
+}
+
+// WithTitle sets the heading of the transcript.
+func WithTitle(title string) Option {
+	return func(c *config) {
+		c.title = title
+	}
+}
+
+// WithTags shows session tags, such as those set with
+// claudecode.WithSessionTags, in the transcript's header.
+func WithTags(tags map[string]string) Option {
+	return func(c *config) {
+		c.tags = tags
+	}
+}
+
+// WithoutThinking leaves thinking blocks out of the transcript.
+func WithoutThinking() Option {
+	return func(c *config) {
+		c.hideThinking = true
+	}
+}
+
+// WithMaxToolOutput truncates tool results longer than n bytes, noting how
+// much was left out. Zero, the default, keeps results whole.
+func WithMaxToolOutput(n int) Option {
+	return func(c *config) {
+		c.maxToolOutput = n
+	}
+}
+
+// WithLanguage sets how the language of a tool result's code block is
+// chosen. The default is DefaultLanguage.
+func WithLanguage(language func(call ToolCall) string) Option {
+	return func(c *config) {
+		c.language = language
+	}
+}
+
+// WithHighlighter highlights the code blocks of ExportHTML.
+func WithHighlighter(highlighter Highlighter) Option {
+	return func(c *config) {
+		c.highlighter = highlighter
+	}
+}
+
+// WithTextRenderer converts the Markdown of user and assistant text to HTML
+// for ExportHTML. Without it, text is escaped and shown with its line
+// breaks. The renderer's output is inserted as is, so it must sanitize it.
+func WithTextRenderer(render func(markdown string) string) Option {
+	return func(c *config) {
+		c.textRenderer = render
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{title: DefaultTitle, language: DefaultLanguage}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.language == nil {
+		c.language = DefaultLanguage
+	}
+	return c
+}
+
+// extensionLanguages maps file extensions to code block languages.
+var extensionLanguages = map[string]string{
+	".go": "go", ".py": "python", ".js": "javascript", ".ts": "typescript",
+	".tsx": "tsx", ".jsx": "jsx", ".rs": "rust", ".java": "java", ".rb": "ruby",
+	".c": "c", ".h": "c", ".cpp": "cpp", ".cs": "csharp", ".sh": "bash",
+	".json": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml",
+	".md": "markdown", ".html": "html", ".css": "css", ".sql": "sql",
+	".proto": "protobuf", ".xml": "xml",
+}
+
+// DefaultLanguage picks a code block language for a tool result: the file
+// type for tools that read a file, "console" for shell commands and "text"
+// otherwise.
+func DefaultLanguage(call ToolCall) string {
+	switch call.Name() {
+	case "Bash":
+		return "console"
+	case "Read", "Write", "Edit":
+		path, _ := call.Use.Input["file_path"].(string)
+		if language, ok := extensionLanguages[strings.ToLower(filepath.Ext(path))]; ok {
+			return language
+		}
+	}
+	return "text"
+}
+
+// Part kinds of a turn.
+const (
+	partText = iota
+	partThinking
+	partToolCall
+	partResult
+)
+
+// part is one rendered element of a turn.
+type part struct {
+	kind   int
+	text   string
+	call   ToolCall
+	result *claudecode.ResultMessage
+}
+
+// turn is a run of consecutive parts from the same speaker.
+type turn struct {
+	role  string // "user", "assistant" or "" for turn results
+	parts []part
+}
+
+// header is what the transcript knows about its session.
+type header struct {
+	sessionID string
+	model     string
+	// tags are the session tags as key=value, sorted by key
+	tags []string
+}
+
+// build groups messages into turns, pairing each tool use with its result.
+func build(messages []claudecode.Message, c *config) (header, []turn) {
+	var h header
+	keys := make([]string, 0, len(c.tags))
+	for key := range c.tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		h.tags = append(h.tags, key+"="+c.tags[key])
+	}
+	results := make(map[string]*claudecode.ToolResultBlock)
+	uses := make(map[string]bool)
+	for _, msg := range messages {
+		switch m := msg.(type) {
+		case *claudecode.UserMessage:
+			blocks, _ := m.Content.([]claudecode.ContentBlock)
+			for _, block := range blocks {
+				if result, ok := block.(*claudecode.ToolResultBlock); ok {
+					results[result.ToolUseID] = result
+				}
+			}
+		case *claudecode.AssistantMessage:
+			for _, block := range m.Content {
+				if use, ok := block.(*claudecode.ToolUseBlock); ok {
+					uses[use.ToolUseID] = true
+				}
+			}
+		}
+	}
+
+	var turns []turn
+	add := func(role string, p part) {
+		if len(turns) == 0 || turns[len(turns)-1].role != role || role == "" {
+			turns = append(turns, turn{role: role})
+		}
+		last := &turns[len(turns)-1]
+		last.parts = append(last.parts, p)
+	}
+
+	for _, msg := range messages {
+		switch m := msg.(type) {
+		case *claudecode.SystemMessage:
+			if info, ok := m.Init(); ok {
+				h.sessionID, h.model = info.SessionID, info.Model
+			}
+		case *claudecode.UserMessage:
+			if text, ok := m.Content.(string); ok {
+				add("user", part{kind: partText, text: text})
+				continue
+			}
+			blocks, _ := m.Content.([]claudecode.ContentBlock)
+			for _, block := range blocks {
+				switch b := block.(type) {
+				case *claudecode.TextBlock:
+					add("user", part{kind: partText, text: b.Text})
+				case *claudecode.ToolResultBlock:
+					// Results are shown with their tool use
+					if !uses[b.ToolUseID] {
+						add("assistant", part{kind: partToolCall, call: ToolCall{Result: b}})
+					}
+				}
+			}
+		case *claudecode.AssistantMessage:
+			if h.model == "" {
+				h.model = m.Model
+			}
+			for _, block := range m.Content {
+				switch b := block.(type) {
+				case *claudecode.TextBlock:
+					add("assistant", part{kind: partText, text: b.Text})
+				case *claudecode.ThinkingBlock:
+					if !c.hideThinking {
+						add("assistant", part{kind: partThinking, text: b.Thinking})
+					}
+				case *claudecode.ToolUseBlock:
+					add("assistant", part{kind: partToolCall, call: ToolCall{Use: b, Result: results[b.ToolUseID]}})
+				}
+			}
+		case *claudecode.ResultMessage:
+			if h.sessionID == "" {
+				h.sessionID = m.SessionID
+			}
+			add("", part{kind: partResult, result: m})
+		}
+	}
+	return h, turns
+}
+
+// toolInput returns the input of a tool call as code and its language:
+// the command of shell calls, indented JSON otherwise.
+func toolInput(use *claudecode.ToolUseBlock) (code, language string) {
+	if command, ok := use.Input["command"].(string); ok && use.Name == "Bash" {
+		return command, "bash"
+	}
+	data, err := json.MarshalIndent(use.Input, "", "  ")
+	if err != nil {
+		return fmt.Sprint(use.Input), "text"
+	}
+	return string(data), "json"
+}
+
+// toolOutput returns the text of a tool result, truncated to c.maxToolOutput.
+// Images in structured results are noted by their media type.
+func toolOutput(result *claudecode.ToolResultBlock, c *config) string {
+	var text string
+	switch content := result.Content.(type) {
+	case string:
+		text = content
+	case []any:
+		var parts []string
+		for _, item := range content {
+			block, _ := item.(map[string]any)
+			switch block["type"] {
+			case "text":
+				s, _ := block["text"].(string)
+				parts = append(parts, s)
+			case "image":
+				source, _ := block["source"].(map[string]any)
+				mediaType, _ := source["media_type"].(string)
+				parts = append(parts, fmt.Sprintf("[image: %s]", mediaType))
+			}
+		}
+		text = strings.Join(parts, "\n")
+	case nil:
+	default:
+		data, err := json.MarshalIndent(content, "", "  ")
+		if err != nil {
+			text = fmt.Sprint(content)
+		} else {
+			text = string(data)
+		}
+	}
+
+	if c.maxToolOutput > 0 && len(text) > c.maxToolOutput {
+		cut := c.maxToolOutput
+		// Back up to a rune boundary
+		for cut > 0 && !isRuneStart(text[cut]) {
+			cut--
+		}
+		text = fmt.Sprintf("%s\n… (%d bytes omitted)", text[:cut], len(text)-cut)
+	}
+	return text
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// resultSummary describes how a turn ended, for example
+// "success · 3 turns · 12.4s · $0.0213".
+func resultSummary(r *claudecode.ResultMessage) string {
+	parts := []string{r.Subtype}
+	if r.NumTurns > 0 {
+		parts = append(parts, fmt.Sprintf("%d turns", r.NumTurns))
+	}
+	if r.DurationMs > 0 {
+		d := time.Duration(r.DurationMs) * time.Millisecond
+		parts = append(parts, d.Round(100*time.Millisecond).String())
+	}
+	if r.TotalCostUSD != nil {
+		parts = append(parts, fmt.Sprintf("$%.4f", *r.TotalCostUSD))
+	}
+	if len(r.PermissionDenials) > 0 {
+		parts = append(parts, fmt.Sprintf("%d denied tool calls", len(r.PermissionDenials)))
+	}
+	return strings.Join(parts, " · ")
+}
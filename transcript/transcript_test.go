@@ -0,0 +1,110 @@
+package transcript
+
+import (
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestBuildPairsToolCalls(t *testing.T) {
+	_, turns := build(sampleRun(), newConfig(nil))
+
+	roles := make([]string, len(turns))
+	for i, turn := range turns {
+		roles[i] = turn.role
+	}
+	if got := strings.Join(roles, ","); got != "user,assistant," {
+		t.Fatalf("Expected user, assistant and result turns, got %q", got)
+	}
+
+	var calls []ToolCall
+	for _, p := range turns[1].parts {
+		if p.kind == partToolCall {
+			calls = append(calls, p.call)
+		}
+	}
+	if len(calls) != 3 {
+		t.Fatalf("Expected 3 tool calls, got %d", len(calls))
+	}
+	if calls[0].Name() != "Read" || calls[0].Result == nil || calls[0].Result.ToolUseID != "toolu_1" {
+		t.Errorf("Expected the Read call paired with its result, got %+v", calls[0])
+	}
+	if calls[1].Name() != "Bash" || !calls[1].Result.Failed() {
+		t.Errorf("Expected the failed Bash call, got %+v", calls[1])
+	}
+	// A result without its tool use is still shown
+	if calls[2].Use != nil || calls[2].Result.ToolUseID != "toolu_lost" {
+		t.Errorf("Expected the orphaned result, got %+v", calls[2])
+	}
+}
+
+func TestDefaultLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		call ToolCall
+		want string
+	}{
+		{"go_file", ToolCall{Use: &claudecode.ToolUseBlock{Name: "Read", Input: map[string]any{"file_path": "/src/Main.GO"}}}, "go"},
+		{"unknown_extension", ToolCall{Use: &claudecode.ToolUseBlock{Name: "Read", Input: map[string]any{"file_path": "notes.xyz"}}}, "text"},
+		{"bash", ToolCall{Use: &claudecode.ToolUseBlock{Name: "Bash"}}, "console"},
+		{"other_tool", ToolCall{Use: &claudecode.ToolUseBlock{Name: "Grep"}}, "text"},
+		{"unknown_use", ToolCall{Result: &claudecode.ToolResultBlock{}}, "text"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := DefaultLanguage(test.call); got != test.want {
+				t.Errorf("Expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestToolOutput(t *testing.T) {
+	structured := &claudecode.ToolResultBlock{Content: []any{
+		map[string]any{"type": "text", "text": "Screenshot taken"},
+		map[string]any{"type": "image", "source": map[string]any{"type": "base64", "media_type": "image/png", "data": "AAAA"}},
+	}}
+	if got := toolOutput(structured, newConfig(nil)); got != "Screenshot taken\n[image: image/png]" {
+		t.Errorf("Unexpected structured output %q", got)
+	}
+
+	// Truncation keeps whole runes
+	long := &claudecode.ToolResultBlock{Content: "ab€cd"}
+	if got := toolOutput(long, newConfig([]Option{WithMaxToolOutput(3)})); got != "ab\n… (5 bytes omitted)" {
+		t.Errorf("Unexpected truncated output %q", got)
+	}
+}
+
+// sampleRun is a turn that reads a file, runs a failing command and gets a
+// result for a tool use it never saw.
+func sampleRun() []claudecode.Message {
+	isError := true
+	cost := 0.0213
+	return []claudecode.Message{
+		&claudecode.SystemMessage{Subtype: "init", Data: map[string]any{
+			"type": "system", "subtype": "init", "session_id": "session-1", "model": "claude-test",
+		}},
+		&claudecode.UserMessage{Content: "Why does the build fail?"},
+		&claudecode.AssistantMessage{Model: "claude-test", Content: []claudecode.ContentBlock{
+			&claudecode.ThinkingBlock{Thinking: "Check main.go first."},
+			&claudecode.TextBlock{Text: "Let me look at the code."},
+			&claudecode.ToolUseBlock{ToolUseID: "toolu_1", Name: "Read", Input: map[string]any{"file_path": "main.go"}},
+		}},
+		&claudecode.UserMessage{Content: []claudecode.ContentBlock{
+			&claudecode.ToolResultBlock{ToolUseID: "toolu_1", Content: "package main\n\nfunc main() { x := \"```\" }\n"},
+		}},
+		&claudecode.AssistantMessage{Model: "claude-test", Content: []claudecode.ContentBlock{
+			&claudecode.ToolUseBlock{ToolUseID: "toolu_2", Name: "Bash", Input: map[string]any{"command": "go build ./..."}},
+		}},
+		&claudecode.UserMessage{Content: []claudecode.ContentBlock{
+			&claudecode.ToolResultBlock{ToolUseID: "toolu_2", Content: "./main.go:3:15: x declared and not used", IsError: &isError},
+			&claudecode.ToolResultBlock{ToolUseID: "toolu_lost", Content: "late output"},
+		}},
+		&claudecode.AssistantMessage{Model: "claude-test", Content: []claudecode.ContentBlock{
+			&claudecode.TextBlock{Text: "The variable `x` is <unused>."},
+		}},
+		&claudecode.ResultMessage{Subtype: "success", NumTurns: 3, DurationMs: 12400, SessionID: "session-1", TotalCostUSD: &cost},
+	}
+}
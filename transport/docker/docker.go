@@ -0,0 +1,212 @@
+// Package docker runs the Claude CLI inside a container, for isolation that
+// running it as a local subprocess cannot give.
+//
+// WithContainer wraps the CLI command in "docker run": the container gets
+// the working directory mounted as its workspace, optional CPU, memory and
+// process limits, and a network policy. The SDK still speaks stream-json
+// over the CLI's stdio, which docker forwards, so clients and queries work
+// as usual:
+//
+//	client := claudecode.NewClient(
+//		claudecode.WithCwd(repoDir),
+//		docker.WithContainer(docker.Config{
+//			Image:  "registry.example.com/claude-cli:latest",
+//			CPUs:   2,
+//			Memory: "4g",
+//		}),
+//	)
+//
+// The image must have the CLI on its PATH, or at Config.CLIPath. Only the
+// workspace, the directories added with WithAddDirs and the SDK's own
+// temporary files are mounted, at the same paths as on the host except the
+// workspace. ANTHROPIC_API_KEY is passed through for authentication; other
+// host variables are not.
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Defaults for Config.
+const (
+	DefaultDockerPath    = "docker"
+	DefaultCLIPath       = "claude"
+	DefaultWorkspacePath = "/workspace"
+)
+
+// Network policies for Config.Network. Any other docker network name can
+// be used as well.
+const (
+	// NetworkBridge is docker's default network, with outbound access.
+	NetworkBridge = "bridge"
+	// NetworkNone cuts the container off from the network. The CLI then
+	// cannot reach the API unless a proxy is reachable another way, so it is
+	// mostly useful with a custom network instead.
+	NetworkNone = "none"
+	// NetworkHost shares the host's network, with no isolation.
+	NetworkHost = "host"
+)
+
+// DefaultForwardEnv lists the host variables passed to the container when
+// Config.ForwardEnv is nil.
+var DefaultForwardEnv = []string{"ANTHROPIC_API_KEY"}
+
+// ErrNoImage is returned when Config.Image is empty.
+var ErrNoImage = errors.New("docker: no image configured")
+
+// Config describes the container the CLI runs in.
+type Config struct {
+	// Image is the image to run. It is required.
+	Image string
+
+	// Workspace is the host directory mounted at WorkspacePath and used as
+	// the CLI's working directory. The default is the client's working
+	// directory, or the current directory.
+	Workspace string
+	// WorkspacePath is where the workspace is mounted in the container; the
+	// default is DefaultWorkspacePath.
+	WorkspacePath string
+	// ReadOnlyWorkspace mounts the workspace read-only.
+	ReadOnlyWorkspace bool
+
+	// CPUs limits the container's CPU use, such as 1.5; zero means no limit.
+	CPUs float64
+	// Memory limits the container's memory, such as "2g"; empty means no
+	// limit.
+	Memory string
+	// PidsLimit limits the processes in the container; zero means no limit.
+	PidsLimit int
+	// Network is the network the container joins, such as NetworkBridge,
+	// NetworkNone or a custom network; empty means docker's default.
+	Network string
+	// User runs the CLI as this user, such as "1000:1000".
+	User string
+
+	// ForwardEnv names host variables passed to the container; nil means
+	// DefaultForwardEnv.
+	ForwardEnv []string
+	// ExtraArgs are added to "docker run" before the image.
+	ExtraArgs []string
+
+	// DockerPath is the docker client; the default is DefaultDockerPath.
+	DockerPath string
+	// CLIPath is the CLI inside the container; the default is
+	// DefaultCLIPath.
+	CLIPath string
+}
+
+// WithContainer runs the CLI in a container described by config. It sets
+// the CLI path to the one inside the image, so the CLI need not be
+// installed on the host.
+func WithContainer(config Config) claudecode.Option {
+	return func(o *claudecode.Options) {
+		cliPath := config.CLIPath
+		if cliPath == "" {
+			cliPath = DefaultCLIPath
+		}
+		o.CLIPath = &cliPath
+		o.CommandWrapper = func(cmd claudecode.CLICommand) (claudecode.CLICommand, error) {
+			// Read when the CLI starts, to see directories added since
+			return config.wrap(cmd, o.AddDirs)
+		}
+	}
+}
+
+// wrap turns the CLI command into a "docker run" command.
+func (c Config) wrap(cmd claudecode.CLICommand, addDirs []string) (claudecode.CLICommand, error) {
+	if c.Image == "" {
+		return cmd, ErrNoImage
+	}
+	workspace := c.Workspace
+	if workspace == "" {
+		workspace = cmd.Dir
+	}
+	if workspace == "" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return cmd, fmt.Errorf("docker: workspace: %w", err)
+		}
+		workspace = dir
+	}
+	workspace, err := filepath.Abs(workspace)
+	if err != nil {
+		return cmd, fmt.Errorf("docker: workspace: %w", err)
+	}
+	workspacePath := c.WorkspacePath
+	if workspacePath == "" {
+		workspacePath = DefaultWorkspacePath
+	}
+
+	// -i keeps stdin open for stream-json; --init forwards signals to the
+	// CLI so interrupts and shutdown work
+	args := []string{c.dockerPath(), "run", "-i", "--rm", "--init"}
+	args = append(args, "--volume", mount(workspace, workspacePath, c.ReadOnlyWorkspace), "--workdir", workspacePath)
+	for _, dir := range addDirs {
+		args = append(args, "--volume", mount(dir, dir, false))
+	}
+	for _, file := range cmd.Files {
+		args = append(args, "--volume", mount(file, file, true))
+	}
+
+	if c.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(c.CPUs, 'f', -1, 64))
+	}
+	if c.Memory != "" {
+		args = append(args, "--memory", c.Memory)
+	}
+	if c.PidsLimit > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(c.PidsLimit))
+	}
+	if c.Network != "" {
+		args = append(args, "--network", c.Network)
+	}
+	if c.User != "" {
+		args = append(args, "--user", c.User)
+	}
+
+	// Variables are passed by name, so their values stay out of the docker
+	// command line; docker reads them from its own environment
+	forward := c.ForwardEnv
+	if forward == nil {
+		forward = DefaultForwardEnv
+	}
+	var env []string
+	for _, name := range forward {
+		if _, ok := os.LookupEnv(name); ok {
+			args = append(args, "--env", name)
+		}
+	}
+	for _, variable := range cmd.Env {
+		name, _, _ := strings.Cut(variable, "=")
+		args = append(args, "--env", name)
+		env = append(env, variable)
+	}
+
+	args = append(args, c.ExtraArgs...)
+	args = append(args, c.Image)
+	args = append(args, cmd.Args...)
+	return claudecode.CLICommand{Args: args, Env: env}, nil
+}
+
+func (c Config) dockerPath() string {
+	if c.DockerPath != "" {
+		return c.DockerPath
+	}
+	return DefaultDockerPath
+}
+
+// mount formats a bind mount for --volume.
+func mount(source, target string, readOnly bool) string {
+	spec := source + ":" + target
+	if readOnly {
+		spec += ":ro"
+	}
+	return spec
+}
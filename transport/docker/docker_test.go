@@ -0,0 +1,102 @@
+package docker
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestWrap(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "sk-test")
+	workspace := t.TempDir()
+	config := Config{
+		Image:     "claude-cli:test",
+		CPUs:      1.5,
+		Memory:    "2g",
+		PidsLimit: 256,
+		Network:   NetworkNone,
+		User:      "1000:1000",
+		ExtraArgs: []string{"--read-only"},
+	}
+	cmd := claudecode.CLICommand{
+		Args:  []string{"claude", "--output-format", "stream-json"},
+		Dir:   workspace,
+		Env:   []string{"CLAUDE_CODE_ENTRYPOINT=sdk-go-client", "TOKEN=secret"},
+		Files: []string{"/tmp/claude_mcp_config_1.json"},
+	}
+
+	got, err := config.wrap(cmd, []string{"/data"})
+	if err != nil {
+		t.Fatalf("wrap failed: %v", err)
+	}
+	want := "docker run -i --rm --init" +
+		" --volume " + workspace + ":/workspace --workdir /workspace" +
+		" --volume /data:/data" +
+		" --volume /tmp/claude_mcp_config_1.json:/tmp/claude_mcp_config_1.json:ro" +
+		" --cpus 1.5 --memory 2g --pids-limit 256 --network none --user 1000:1000" +
+		" --env ANTHROPIC_API_KEY --env CLAUDE_CODE_ENTRYPOINT --env TOKEN" +
+		" --read-only claude-cli:test claude --output-format stream-json"
+	if strings.Join(got.Args, " ") != want {
+		t.Errorf("Expected:\n%s\ngot:\n%s", want, strings.Join(got.Args, " "))
+	}
+	if strings.Join(got.Env, " ") != "CLAUDE_CODE_ENTRYPOINT=sdk-go-client TOKEN=secret" {
+		t.Errorf("Expected the CLI's variables set for docker, got %v", got.Env)
+	}
+	if got.Dir != "" {
+		t.Errorf("Expected docker to run in the current directory, got %q", got.Dir)
+	}
+	if strings.Contains(strings.Join(got.Args, " "), "secret") {
+		t.Error("Expected variable values to stay off the command line")
+	}
+}
+
+func TestWrapDefaults(t *testing.T) {
+	got, err := Config{Image: "img", ForwardEnv: []string{}, ReadOnlyWorkspace: true, WorkspacePath: "/src"}.
+		wrap(claudecode.CLICommand{Args: []string{"claude"}}, nil)
+	if err != nil {
+		t.Fatalf("wrap failed: %v", err)
+	}
+	args := strings.Join(got.Args, " ")
+	if !strings.HasPrefix(args, "docker run -i --rm --init --volume ") {
+		t.Errorf("Expected a docker run command, got %s", args)
+	}
+	if !strings.Contains(args, ":/src:ro --workdir /src img claude") {
+		t.Errorf("Expected the current directory mounted read-only at /src, got %s", args)
+	}
+
+	if _, err := (Config{}).wrap(claudecode.CLICommand{Args: []string{"claude"}}, nil); !errors.Is(err, ErrNoImage) {
+		t.Errorf("Expected ErrNoImage, got %v", err)
+	}
+}
+
+func TestWithContainer(t *testing.T) {
+	workspace := t.TempDir()
+	extra := filepath.Join(workspace, "extra")
+	options := claudecode.NewOptions(
+		WithContainer(Config{Image: "img", DockerPath: "podman", CLIPath: "/usr/local/bin/claude"}),
+		claudecode.WithAddDirs(extra),
+	)
+	if options.CLIPath == nil || *options.CLIPath != "/usr/local/bin/claude" {
+		t.Errorf("Expected the CLI path inside the image, got %v", options.CLIPath)
+	}
+	if options.CommandWrapper == nil {
+		t.Fatal("Expected a command wrapper")
+	}
+
+	got, err := options.CommandWrapper(claudecode.CLICommand{Args: []string{"/usr/local/bin/claude"}, Dir: workspace})
+	if err != nil {
+		t.Fatalf("wrapper failed: %v", err)
+	}
+	args := strings.Join(got.Args, " ")
+	if got.Args[0] != "podman" || !strings.Contains(args, "--volume "+extra+":"+extra) {
+		t.Errorf("Expected the docker path and directories added later, got %s", args)
+	}
+
+	defaults := claudecode.NewOptions(WithContainer(Config{Image: "img"}))
+	if *defaults.CLIPath != DefaultCLIPath {
+		t.Errorf("Expected the default CLI path, got %s", *defaults.CLIPath)
+	}
+}
@@ -0,0 +1,327 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// TransportMiddleware decorates a Transport, for example to log traffic,
+// record metrics or inject faults. Middleware wrapping a
+// ControlRequestTransport should implement it too; otherwise permission
+// callbacks and hooks stop working behind it.
+type TransportMiddleware func(Transport) Transport
+
+// WrapTransport returns t decorated with middleware. The first middleware
+// is the outermost: it sees calls first and received messages last.
+//
+//	transport = claudecode.WrapTransport(transport,
+//		claudecode.LogTransport(os.Stderr),
+//		claudecode.InjectFaults(claudecode.FaultConfig{DropRate: 0.1}),
+//	)
+//	client := claudecode.NewClientWithTransport(transport)
+func WrapTransport(t Transport, middleware ...TransportMiddleware) Transport {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		t = middleware[i](t)
+	}
+	return t
+}
+
+// transportDecorator forwards to the wrapped transport, including control
+// requests when it supports them. Built-in middleware embeds it and
+// overrides what it decorates.
+type transportDecorator struct {
+	Transport
+}
+
+// SendControlRequest forwards req, or fails if the wrapped transport has
+// no control protocol.
+func (d transportDecorator) SendControlRequest(ctx context.Context, req *ControlRequest) error {
+	ctrl, ok := d.Transport.(ControlRequestTransport)
+	if !ok {
+		return fmt.Errorf("transport does not support control requests")
+	}
+	return ctrl.SendControlRequest(ctx, req)
+}
+
+// SendControlResponse forwards resp, or fails if the wrapped transport
+// cannot answer control requests.
+func (d transportDecorator) SendControlResponse(ctx context.Context, resp *ControlResponse) error {
+	ctrl, ok := d.Transport.(ControlResponseTransport)
+	if !ok {
+		return fmt.Errorf("transport does not support control responses")
+	}
+	return ctrl.SendControlResponse(ctx, resp)
+}
+
+// SupportsControlRequests reports whether the wrapped transport does.
+func (d transportDecorator) SupportsControlRequests() bool {
+	ctrl, ok := d.Transport.(ControlRequestTransport)
+	return ok && ctrl.SupportsControlRequests()
+}
+
+// forwardReceived relays msgs and errs through new channels, passing each
+// message to onMessage, which returns false to drop it, and each error to
+// onError. Output channels close when their input does or ctx ends.
+func forwardReceived(
+	ctx context.Context,
+	msgs <-chan Message,
+	errs <-chan error,
+	onMessage func(Message) bool,
+	onError func(error),
+) (<-chan Message, <-chan error) {
+	var outMsgs chan Message
+	if msgs != nil {
+		outMsgs = make(chan Message, cap(msgs))
+		go func() {
+			defer close(outMsgs)
+			for msg := range msgs {
+				if !onMessage(msg) {
+					continue
+				}
+				select {
+				case outMsgs <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	var outErrs chan error
+	if errs != nil {
+		outErrs = make(chan error, cap(errs))
+		go func() {
+			defer close(outErrs)
+			for err := range errs {
+				onError(err)
+				select {
+				case outErrs <- err:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	return outMsgs, outErrs
+}
+
+// LogTransport returns middleware that writes a line to w for each call
+// and each received message or error. Message content is not logged.
+func LogTransport(w io.Writer) TransportMiddleware {
+	var mu sync.Mutex
+	logf := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "transport: "+format+"\n", args...)
+	}
+	return func(t Transport) Transport {
+		return &loggingTransport{transportDecorator{t}, logf}
+	}
+}
+
+type loggingTransport struct {
+	transportDecorator
+	logf func(format string, args ...any)
+}
+
+func (lt *loggingTransport) Connect(ctx context.Context) error {
+	start := time.Now()
+	err := lt.Transport.Connect(ctx)
+	lt.logResult("connect", err, time.Since(start))
+	return err
+}
+
+func (lt *loggingTransport) SendMessage(ctx context.Context, message StreamMessage) error {
+	err := lt.Transport.SendMessage(ctx, message)
+	lt.logResult("send "+message.Type, err, 0)
+	return err
+}
+
+func (lt *loggingTransport) SendControlRequest(ctx context.Context, req *ControlRequest) error {
+	err := lt.transportDecorator.SendControlRequest(ctx, req)
+	lt.logResult(fmt.Sprintf("send control_request %s %s", req.Subtype, req.ID), err, 0)
+	return err
+}
+
+func (lt *loggingTransport) ReceiveMessages(ctx context.Context) (<-chan Message, <-chan error) {
+	msgs, errs := lt.Transport.ReceiveMessages(ctx)
+	return forwardReceived(ctx, msgs, errs,
+		func(msg Message) bool {
+			lt.logf("receive %s", msg.Type())
+			return true
+		},
+		func(err error) {
+			lt.logf("receive error: %v", err)
+		})
+}
+
+func (lt *loggingTransport) Interrupt(ctx context.Context) error {
+	err := lt.Transport.Interrupt(ctx)
+	lt.logResult("interrupt", err, 0)
+	return err
+}
+
+func (lt *loggingTransport) Close() error {
+	err := lt.Transport.Close()
+	lt.logResult("close", err, 0)
+	return err
+}
+
+func (lt *loggingTransport) logResult(call string, err error, elapsed time.Duration) {
+	switch {
+	case err != nil:
+		lt.logf("%s failed: %v", call, err)
+	case elapsed > 0:
+		lt.logf("%s (%s)", call, elapsed.Round(time.Microsecond))
+	default:
+		lt.logf("%s", call)
+	}
+}
+
+// ErrInjectedFault is the error InjectFaults returns when FaultConfig does
+// not name one.
+var ErrInjectedFault = errors.New("injected transport fault")
+
+// FaultConfig configures InjectFaults. Rates are probabilities from 0 to 1.
+type FaultConfig struct {
+	// Latency delays Connect, each SendMessage and each received message.
+	Latency time.Duration
+	// ConnectError, if not nil, is returned by Connect.
+	ConnectError error
+	// SendErrorRate is the share of SendMessage calls that fail with
+	// SendError instead of reaching the transport. Control requests are
+	// not affected.
+	SendErrorRate float64
+	SendError     error
+	// DropRate is the share of received messages that are discarded. Any
+	// message may be dropped, including results, so a turn may never end.
+	DropRate float64
+	// ReceiveErrorAfter, if positive, sends ReceiveError on the error
+	// channel once that many messages have been received.
+	ReceiveErrorAfter int
+	ReceiveError      error
+	// Rand makes the faults reproducible. By default a source seeded with
+	// the current time is used.
+	Rand *rand.Rand
+}
+
+// InjectFaults returns middleware that adds latency and failures to a
+// transport, for testing how an application copes with them.
+func InjectFaults(config FaultConfig) TransportMiddleware {
+	if config.SendError == nil {
+		config.SendError = ErrInjectedFault
+	}
+	if config.ReceiveError == nil {
+		config.ReceiveError = ErrInjectedFault
+	}
+	if config.Rand == nil {
+		config.Rand = rand.New(rand.NewSource(time.Now().UnixNano())) // #nosec G404 - Faults need not be unpredictable
+	}
+	// The source is shared by every transport the middleware wraps
+	var mu sync.Mutex
+	chance := func(rate float64) bool {
+		if rate <= 0 {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return config.Rand.Float64() < rate
+	}
+	return func(t Transport) Transport {
+		return &faultyTransport{transportDecorator: transportDecorator{t}, config: config, chance: chance}
+	}
+}
+
+type faultyTransport struct {
+	transportDecorator
+	config FaultConfig
+	chance func(rate float64) bool
+}
+
+func (ft *faultyTransport) Connect(ctx context.Context) error {
+	if err := ft.delay(ctx); err != nil {
+		return err
+	}
+	if ft.config.ConnectError != nil {
+		return ft.config.ConnectError
+	}
+	return ft.Transport.Connect(ctx)
+}
+
+func (ft *faultyTransport) SendMessage(ctx context.Context, message StreamMessage) error {
+	if err := ft.delay(ctx); err != nil {
+		return err
+	}
+	if ft.chance(ft.config.SendErrorRate) {
+		return ft.config.SendError
+	}
+	return ft.Transport.SendMessage(ctx, message)
+}
+
+func (ft *faultyTransport) ReceiveMessages(ctx context.Context) (<-chan Message, <-chan error) {
+	msgs, errs := ft.Transport.ReceiveMessages(ctx)
+	if ft.config.ReceiveErrorAfter <= 0 {
+		return forwardReceived(ctx, msgs, errs, ft.receive(ctx, nil), func(error) {})
+	}
+
+	// Injected errors share the output channel with the transport's own
+	injected := make(chan error, 1)
+	merged := make(chan error, cap(errs))
+	go func() {
+		defer close(merged)
+		for {
+			var err error
+			select {
+			case e, ok := <-errs:
+				if !ok {
+					return
+				}
+				err = e
+			case err = <-injected:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case merged <- err:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return forwardReceived(ctx, msgs, merged, ft.receive(ctx, injected), func(error) {})
+}
+
+// receive returns the per-message callback for ReceiveMessages. It sends
+// ReceiveError on injected once ReceiveErrorAfter messages have arrived.
+func (ft *faultyTransport) receive(ctx context.Context, injected chan<- error) func(Message) bool {
+	received := 0
+	return func(Message) bool {
+		if ft.delay(ctx) != nil {
+			return false
+		}
+		received++
+		if injected != nil && received == ft.config.ReceiveErrorAfter {
+			injected <- ft.config.ReceiveError
+		}
+		return !ft.chance(ft.config.DropRate)
+	}
+}
+
+func (ft *faultyTransport) delay(ctx context.Context) error {
+	if ft.config.Latency <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(ft.config.Latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,174 @@
+package claudecode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrapTransportOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) TransportMiddleware {
+		return func(next Transport) Transport {
+			return &recordingTransport{transportDecorator{next}, name, &calls}
+		}
+	}
+
+	transport := WrapTransport(newClientMockTransport(), record("outer"), record("inner"))
+	if err := transport.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if got := strings.Join(calls, ","); got != "outer,inner" {
+		t.Errorf("Expected the first middleware outermost, got %s", got)
+	}
+
+	base := newClientMockTransport()
+	if WrapTransport(base) != Transport(base) {
+		t.Error("Expected no middleware to return the transport itself")
+	}
+}
+
+func TestTransportMiddlewareKeepsControlSupport(t *testing.T) {
+	wrapped := WrapTransport(newClientControlMockTransport(), LogTransport(&bytes.Buffer{}), InjectFaults(FaultConfig{}))
+	ctrl, ok := wrapped.(ControlRequestTransport)
+	if !ok || !ctrl.SupportsControlRequests() {
+		t.Fatal("Expected wrapped control transport to support control requests")
+	}
+
+	plain := WrapTransport(newClientMockTransport(), LogTransport(&bytes.Buffer{})).(ControlRequestTransport)
+	if plain.SupportsControlRequests() {
+		t.Error("Expected no control support without it underneath")
+	}
+	if err := plain.SendControlRequest(context.Background(), &ControlRequest{}); err == nil {
+		t.Error("Expected control requests to fail without support underneath")
+	}
+}
+
+func TestLogTransport(t *testing.T) {
+	ctx, cancel := setupTransportMiddlewareTestContext(t)
+	defer cancel()
+
+	var log bytes.Buffer
+	transport := newClientMockTransportWithOptions(WithClientAutoResult())
+	client := NewClientWithTransport(WrapTransport(transport, LogTransport(&log)))
+	connectClientSafely(ctx, t, client)
+	if err := client.Query(ctx, "hello"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if _, err := client.ReceiveResponse(ctx).Next(ctx); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	disconnectClientSafely(t, client)
+
+	output := log.String()
+	for _, line := range []string{"transport: connect (", "transport: send user\n", "transport: receive result\n", "transport: close\n"} {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected %q in log:\n%s", line, output)
+		}
+	}
+	if strings.Contains(output, "hello") {
+		t.Error("Expected message content to stay out of the log")
+	}
+
+	log.Reset()
+	failing := WrapTransport(newClientMockTransportWithOptions(WithClientSendError(errors.New("broken pipe"))), LogTransport(&log))
+	_ = failing.SendMessage(ctx, StreamMessage{Type: userMessageType})
+	if log.String() != "transport: send user failed: broken pipe\n" {
+		t.Errorf("Unexpected log for a failed send: %q", log.String())
+	}
+}
+
+func TestInjectFaults(t *testing.T) {
+	ctx, cancel := setupTransportMiddlewareTestContext(t)
+	defer cancel()
+
+	refused := errors.New("connection refused")
+	transport := WrapTransport(newClientMockTransport(), InjectFaults(FaultConfig{ConnectError: refused}))
+	if err := transport.Connect(ctx); !errors.Is(err, refused) {
+		t.Errorf("Expected the configured connect error, got %v", err)
+	}
+
+	base := newClientMockTransport()
+	transport = WrapTransport(base, InjectFaults(FaultConfig{SendErrorRate: 1}))
+	connectTransportSafely(ctx, t, transport)
+	if err := transport.SendMessage(ctx, StreamMessage{Type: userMessageType}); !errors.Is(err, ErrInjectedFault) {
+		t.Errorf("Expected ErrInjectedFault, got %v", err)
+	}
+	if base.getSentMessageCount() != 0 {
+		t.Error("Expected the failed send not to reach the transport")
+	}
+
+	transport = WrapTransport(newClientMockTransport(), InjectFaults(FaultConfig{Latency: time.Hour}))
+	short, cancelShort := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelShort()
+	if err := transport.Connect(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected latency to respect the context, got %v", err)
+	}
+}
+
+func TestInjectFaultsReceive(t *testing.T) {
+	ctx, cancel := setupTransportMiddlewareTestContext(t)
+	defer cancel()
+
+	messages := make([]Message, 6)
+	for i := range messages {
+		messages[i] = &AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "chunk"}}}
+	}
+	base := newClientMockTransportWithOptions(WithClientResponseMessages(messages))
+	transport := WrapTransport(base, InjectFaults(FaultConfig{
+		DropRate:          0.5,
+		ReceiveErrorAfter: 2,
+		Rand:              rand.New(rand.NewSource(1)), // #nosec G404 - Deterministic test faults
+	}))
+	connectTransportSafely(ctx, t, transport)
+	msgs, errs := transport.ReceiveMessages(ctx)
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrInjectedFault) {
+			t.Errorf("Expected ErrInjectedFault, got %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Expected an injected receive error")
+	}
+
+	_ = base.Close()
+	received := 0
+	for range msgs {
+		received++
+	}
+	if received == 0 || received == len(messages) {
+		t.Errorf("Expected some of %d messages dropped, received %d", len(messages), received)
+	}
+	if _, ok := <-errs; ok {
+		t.Error("Expected the error channel to close with the transport's")
+	}
+}
+
+// recordingTransport notes its name on Connect.
+type recordingTransport struct {
+	transportDecorator
+	name  string
+	calls *[]string
+}
+
+func (r *recordingTransport) Connect(ctx context.Context) error {
+	*r.calls = append(*r.calls, r.name)
+	return r.Transport.Connect(ctx)
+}
+
+func setupTransportMiddlewareTestContext(t *testing.T) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
+
+func connectTransportSafely(ctx context.Context, t *testing.T, transport Transport) {
+	t.Helper()
+	if err := transport.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+}
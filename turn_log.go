@@ -0,0 +1,140 @@
+package claudecode
+
+import (
+	"sync"
+	"time"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+// turnLog assembles the message stream into turns, keeps the completed
+// ones and reports each to an observer.
+type turnLog struct {
+	mu       sync.Mutex
+	observer TurnObserver
+	now      func() time.Time
+	turns    []Turn
+	current  *Turn
+	// Positions in current.ToolCalls of tool uses awaiting results
+	pending map[string]int
+}
+
+func newTurnLog(observer TurnObserver) *turnLog {
+	return &turnLog{observer: observer, now: time.Now}
+}
+
+// prompt records a prompt the client is sending, with the idempotency key
+// of its query, before it is sent so the turn cannot complete first. It
+// starts a turn unless one is in progress; a turn keeps the first prompt
+// sent.
+func (tl *turnLog) prompt(msg *UserMessage, key string) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	turn := tl.turn()
+	if turn.Prompt == nil {
+		turn.Prompt = shared.Detach(msg).(*UserMessage)
+		turn.IdempotencyKey = key
+	}
+}
+
+// discardPrompt forgets the turn started by a prompt that could not be
+// sent.
+func (tl *turnLog) discardPrompt() {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	if tl.current != nil && len(tl.current.Assistant) == 0 {
+		tl.current = nil
+		tl.pending = nil
+	}
+}
+
+// track adds msg to the current turn, completing it on a ResultMessage.
+func (tl *turnLog) track(msg Message) {
+	var completed Turn
+
+	tl.mu.Lock()
+	switch m := msg.(type) {
+	case *AssistantMessage:
+		turn := tl.turn()
+		m = shared.Detach(m).(*AssistantMessage)
+		turn.Assistant = append(turn.Assistant, m)
+		for _, block := range m.Content {
+			if toolUse, ok := block.(*ToolUseBlock); ok {
+				tl.pending[toolUse.ToolUseID] = len(turn.ToolCalls)
+				turn.ToolCalls = append(turn.ToolCalls, ToolCall{Use: toolUse})
+			}
+		}
+	case *UserMessage:
+		if tl.current == nil {
+			break
+		}
+		var detached []ContentBlock
+		blocks, _ := m.Content.([]ContentBlock)
+		for i, block := range blocks {
+			result, ok := block.(*ToolResultBlock)
+			if !ok {
+				continue
+			}
+			index, ok := tl.pending[result.ToolUseID]
+			if !ok {
+				continue
+			}
+			delete(tl.pending, result.ToolUseID)
+			if detached == nil {
+				detached, _ = shared.Detach(m).(*UserMessage).Content.([]ContentBlock)
+			}
+			tl.current.ToolCalls[index].Result, _ = detached[i].(*ToolResultBlock)
+		}
+	case *ResultMessage:
+		turn := tl.turn()
+		turn.Result = m
+		turn.Ended = tl.now()
+		tl.turns = append(tl.turns, *turn)
+		completed = *turn
+		tl.current = nil
+		tl.pending = nil
+	}
+	tl.mu.Unlock()
+
+	if completed.Result != nil {
+		tl.notify(completed)
+	}
+}
+
+// turn returns the current turn, starting one if needed. Must be called
+// with tl.mu held.
+func (tl *turnLog) turn() *Turn {
+	if tl.current == nil {
+		tl.current = &Turn{Number: len(tl.turns) + 1, Started: tl.now()}
+		tl.pending = make(map[string]int)
+	}
+	return tl.current
+}
+
+// notify delivers a completed turn to the observer. A panicking observer
+// does not interrupt message delivery.
+func (tl *turnLog) notify(turn Turn) {
+	if tl.observer == nil {
+		return
+	}
+	defer func() {
+		_ = recover()
+	}()
+	tl.observer(turn)
+}
+
+// resetCurrent discards the turn in progress, used when a new connection
+// starts.
+func (tl *turnLog) resetCurrent() {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.current = nil
+	tl.pending = nil
+}
+
+// completed returns the completed turns.
+func (tl *turnLog) completed() []Turn {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	return append([]Turn(nil), tl.turns...)
+}
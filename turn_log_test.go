@@ -0,0 +1,163 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/severity1/claude-code-sdk-go/internal/shared"
+)
+
+func TestTurnLogAssemblesTurns(t *testing.T) {
+	var observed []Turn
+	log := newTurnLog(func(turn Turn) { observed = append(observed, turn) })
+
+	log.prompt(&UserMessage{Content: "List the files"}, "")
+	log.track(&AssistantMessage{Content: []ContentBlock{
+		&TextBlock{Text: "Let me look."},
+		&ToolUseBlock{ToolUseID: "toolu_1", Name: "Bash", Input: map[string]any{"command": "ls"}},
+		&ToolUseBlock{ToolUseID: "toolu_2", Name: "Read"},
+	}})
+	log.track(toolResultMessage("toolu_1", false))
+	log.track(toolResultMessage("unknown", false))
+	log.track(&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "main.go"}}})
+	log.track(&ResultMessage{Subtype: "success", NumTurns: 2})
+
+	turns := log.completed()
+	if len(turns) != 1 || len(observed) != 1 {
+		t.Fatalf("Expected one completed and observed turn, got %d and %d", len(turns), len(observed))
+	}
+	turn := turns[0]
+	if turn.Number != 1 || turn.Prompt == nil || turn.Prompt.Content != "List the files" {
+		t.Errorf("Unexpected turn start: %+v", turn)
+	}
+	if len(turn.Assistant) != 2 || turn.Result == nil || turn.Result.NumTurns != 2 {
+		t.Errorf("Expected two assistant messages and the result, got %+v", turn)
+	}
+	if len(turn.ToolCalls) != 2 {
+		t.Fatalf("Expected two tool calls, got %+v", turn.ToolCalls)
+	}
+	if call := turn.ToolCalls[0]; call.Use.Name != "Bash" || call.Result == nil || call.Result.ToolUseID != "toolu_1" {
+		t.Errorf("Expected the Bash call paired with its result, got %+v", call)
+	}
+	if call := turn.ToolCalls[1]; call.Use.Name != "Read" || call.Result != nil {
+		t.Errorf("Expected the Read call without a result, got %+v", call)
+	}
+	if text := turn.Text(); text != "Let me look.\nmain.go" {
+		t.Errorf("Expected the turn's text, got %q", text)
+	}
+	if turn.Started.IsZero() || turn.Ended.Before(turn.Started) {
+		t.Errorf("Expected start and end times, got %v and %v", turn.Started, turn.Ended)
+	}
+
+	// Messages arriving before the prompt is recorded join the same turn
+	log.track(&AssistantMessage{Content: []ContentBlock{&TextBlock{Text: "again"}}})
+	log.prompt(&UserMessage{Content: "Again"}, "")
+	log.prompt(&UserMessage{Content: "Ignored"}, "")
+	log.track(&ResultMessage{Subtype: "success"})
+	if turns := log.completed(); len(turns) != 2 || turns[1].Number != 2 || turns[1].Prompt.Content != "Again" {
+		t.Errorf("Expected a second turn with its first prompt, got %+v", turns)
+	}
+}
+
+func TestTurnLogDiscardsUnsentPrompt(t *testing.T) {
+	log := newTurnLog(nil)
+
+	log.prompt(&UserMessage{Content: "lost"}, "")
+	log.discardPrompt()
+	log.track(&ResultMessage{Subtype: "success"})
+
+	turns := log.completed()
+	if len(turns) != 1 || turns[0].Prompt != nil {
+		t.Errorf("Expected a turn without the unsent prompt, got %+v", turns)
+	}
+}
+
+func TestTurnLogObserverPanic(t *testing.T) {
+	log := newTurnLog(func(Turn) { panic("analytics failed") })
+
+	log.track(&ResultMessage{Subtype: "success"})
+	if len(log.completed()) != 1 {
+		t.Error("Expected the turn to be recorded despite the observer panic")
+	}
+}
+
+func TestTurnLogDetachesRecycledMessages(t *testing.T) {
+	log := newTurnLog(nil)
+
+	msg := shared.AcquireAssistantMessage()
+	text := shared.AcquireTextBlock()
+	text.Text = "kept"
+	msg.Content = append(msg.Content, text)
+	log.track(msg)
+	msg.Release()
+	log.track(&ResultMessage{Subtype: "success"})
+
+	turns := log.completed()
+	if len(turns) != 1 || turns[0].Text() != "kept" {
+		t.Errorf("Expected the turn to outlive the released message, got %+v", turns)
+	}
+}
+
+func TestClientTurns(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var observed []Turn
+	observer := func(turn Turn) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed = append(observed, turn)
+	}
+
+	transport := newClientMockTransportWithOptions(WithClientAutoResult())
+	client := NewClientWithTransport(transport, WithTurnObserver(observer)).(*ClientImpl)
+	if turns := client.Turns(); len(turns) != 0 {
+		t.Errorf("Expected no turns before connecting, got %+v", turns)
+	}
+
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	if err := client.Query(ctx, "What is 2+2?"); err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for msg := range client.ReceiveMessages(ctx) {
+		if _, ok := msg.(*ResultMessage); ok {
+			break
+		}
+	}
+
+	turns := client.Turns()
+	if len(turns) != 1 || turns[0].Prompt == nil || turns[0].Prompt.Content != "What is 2+2?" || turns[0].Result == nil {
+		t.Errorf("Expected the query's turn, got %+v", turns)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(observed) != 1 || observed[0].Number != 1 {
+		t.Errorf("Expected the observer to see the turn, got %+v", observed)
+	}
+}
+
+func TestClientTurnsSendFailure(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sendErr := errors.New("pipe closed")
+	transport := newClientMockTransportWithOptions(WithClientSendError(sendErr))
+	client := NewClientWithTransport(transport)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	if err := client.Query(ctx, "unsent"); !errors.Is(err, sendErr) {
+		t.Fatalf("Expected the send error, got %v", err)
+	}
+	impl := client.(*ClientImpl)
+	impl.turnLog.track(&ResultMessage{Subtype: "success"})
+	if turns := impl.Turns(); len(turns) != 1 || turns[0].Prompt != nil {
+		t.Errorf("Expected the unsent prompt to be discarded, got %+v", turns)
+	}
+}
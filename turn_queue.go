@@ -0,0 +1,145 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTurnInProgress is returned by Query when the previous query has not
+// received its ResultMessage yet, and the transport has neither failed nor
+// closed. Use WithQueryQueueing to queue such queries instead.
+var ErrTurnInProgress = errors.New("turn in progress")
+
+// queuedQuery is a query waiting for the current turn to finish.
+type queuedQuery struct {
+	prompt    string
+	sessionID string
+	opts      []Option
+}
+
+// turnState tracks whether a query's turn is still streaming and holds the
+// queries queued behind it.
+type turnState struct {
+	mu     sync.Mutex
+	active bool
+	queue  []queuedQuery
+	ready  chan struct{} // signaled when a turn ends with queries queued
+}
+
+func newTurnState() *turnState {
+	return &turnState{ready: make(chan struct{}, 1)}
+}
+
+// begin starts a turn, reporting false if one is already in progress or
+// queries are waiting ahead of it.
+func (ts *turnState) begin() bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.active || len(ts.queue) > 0 {
+		return false
+	}
+	ts.active = true
+	return true
+}
+
+// enqueue adds a query to run after the current turn.
+func (ts *turnState) enqueue(query queuedQuery) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.queue = append(ts.queue, query)
+	if !ts.active {
+		ts.signal()
+	}
+}
+
+// end finishes the current turn: its ResultMessage arrived, its prompt
+// could not be sent, or the transport failed or closed.
+func (ts *turnState) end() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.active = false
+	if len(ts.queue) > 0 {
+		ts.signal()
+	}
+}
+
+// interrupt ends a turn that will get no ResultMessage, because the CLI
+// exited, reporting whether one was in progress.
+func (ts *turnState) interrupt() bool {
+	ts.mu.Lock()
+	active := ts.active
+	ts.mu.Unlock()
+	ts.end()
+	return active
+}
+
+// next starts a turn for the oldest queued query, if no turn is active.
+func (ts *turnState) next() (queuedQuery, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.active || len(ts.queue) == 0 {
+		return queuedQuery{}, false
+	}
+	query := ts.queue[0]
+	ts.queue = ts.queue[1:]
+	ts.active = true
+	return query, true
+}
+
+// signal wakes the dispatcher. Must be called with ts.mu held.
+func (ts *turnState) signal() {
+	select {
+	case ts.ready <- struct{}{}:
+	default:
+	}
+}
+
+// track ends the turn when its ResultMessage arrives.
+func (ts *turnState) track(msg Message) {
+	if _, ok := msg.(*ResultMessage); ok {
+		ts.end()
+	}
+}
+
+// dispatchQueries forwards transport errors to out and sends queued queries
+// as turns finish. A queued query that cannot be sent is reported on out.
+// out is closed when done is closed or the transport's errors end; ctx is
+// canceled along with done. sessionID labels the goroutine once known.
+func (c *ClientImpl) dispatchQueries(ctx context.Context, done <-chan struct{}, in <-chan error, out chan<- error, turns *turnState, sessionID func() string) {
+	defer close(out)
+
+	for {
+		select {
+		case <-done:
+			return
+		case err, ok := <-in:
+			if !ok {
+				return
+			}
+			// No result follows an error the stream does not go on after
+			if !recoverableStreamError(err) {
+				turns.end()
+			}
+			select {
+			case out <- err:
+			case <-done:
+				return
+			}
+		case <-turns.ready:
+			labelGoroutine(sessionID(), GoroutineRoleWriter)
+			for query, ok := turns.next(); ok; query, ok = turns.next() {
+				err := c.sendQuery(ctx, query.prompt, query.sessionID, query.opts)
+				if err == nil {
+					break
+				}
+				turns.end()
+				select {
+				case out <- err:
+				case <-done:
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package claudecode
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTurnStateQueueOrder(t *testing.T) {
+	turns := newTurnState()
+	if !turns.begin() {
+		t.Fatal("Expected first turn to begin")
+	}
+	if turns.begin() {
+		t.Fatal("Expected overlapping turn to be refused")
+	}
+
+	turns.enqueue(queuedQuery{prompt: "second"})
+	turns.enqueue(queuedQuery{prompt: "third"})
+	if _, ok := turns.next(); ok {
+		t.Fatal("Expected no dispatch while a turn is active")
+	}
+
+	for _, want := range []string{"second", "third"} {
+		turns.track(&ResultMessage{Subtype: "success"})
+		assertTurnReady(t, turns)
+		query, ok := turns.next()
+		if !ok || query.prompt != want {
+			t.Fatalf("Expected %q to be dispatched, got %q (ok=%v)", want, query.prompt, ok)
+		}
+		if turns.begin() {
+			t.Fatal("Expected dispatched query to hold the turn")
+		}
+	}
+
+	turns.track(&ResultMessage{Subtype: "success"})
+	if !turns.begin() {
+		t.Error("Expected a new turn once the queue is empty")
+	}
+}
+
+func TestClientQueryRejectsOverlappingTurn(t *testing.T) {
+	ctx, cancel := setupTurnTestContext(t)
+	defer cancel()
+
+	transport := newClientMockTransport()
+	client := NewClientWithTransport(transport)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	assertNoError(t, client.Query(ctx, "first"))
+	err := client.Query(ctx, "second")
+	if !errors.Is(err, ErrTurnInProgress) {
+		t.Fatalf("Expected ErrTurnInProgress, got %v", err)
+	}
+	if err := client.QueryWithSession(ctx, "other session", "s2"); !errors.Is(err, ErrTurnInProgress) {
+		t.Errorf("Expected ErrTurnInProgress for another session, got %v", err)
+	}
+	assertClientMessageCount(t, transport, 1)
+
+	deliverTurnResult(t, transport)
+	awaitClientResult(ctx, t, client)
+	assertNoError(t, client.Query(ctx, "second"))
+	assertClientMessageCount(t, transport, 2)
+}
+
+func TestClientQueryFailedSendEndsTurn(t *testing.T) {
+	ctx, cancel := setupTurnTestContext(t)
+	defer cancel()
+
+	transport := newClientMockTransportWithOptions(WithClientSendError(errors.New("broken pipe")))
+	client := NewClientWithTransport(transport)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	for i := 0; i < 2; i++ {
+		err := client.Query(ctx, "lost")
+		if err == nil || errors.Is(err, ErrTurnInProgress) {
+			t.Fatalf("Attempt %d: expected the send error, got %v", i, err)
+		}
+	}
+}
+
+func TestClientTransportFailureEndsTurn(t *testing.T) {
+	ctx, cancel := setupTurnTestContext(t)
+	defer cancel()
+
+	transport := newClientMockTransport()
+	client := NewClientWithTransport(transport)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	assertNoError(t, client.Query(ctx, "first"))
+	iter := client.ReceiveResponse(ctx)
+
+	// A parse error leaves the stream, and the turn, going
+	transport.mu.Lock()
+	transport.errChan <- NewJSONDecodeError("{", 0, errors.New("unexpected end"))
+	transport.mu.Unlock()
+	if _, err := iter.Next(ctx); err == nil {
+		t.Fatal("Expected the parse error")
+	}
+	if err := client.Query(ctx, "second"); !errors.Is(err, ErrTurnInProgress) {
+		t.Fatalf("Expected the turn to continue after a parse error, got %v", err)
+	}
+
+	transport.mu.Lock()
+	transport.errChan <- errors.New("stdout read error: broken pipe")
+	transport.mu.Unlock()
+	if _, err := iter.Next(ctx); err == nil {
+		t.Fatal("Expected the transport error")
+	}
+	assertNoError(t, client.Query(ctx, "third"))
+}
+
+func TestClientStreamEndEndsTurn(t *testing.T) {
+	ctx, cancel := setupTurnTestContext(t)
+	defer cancel()
+
+	transport := newClientMockTransport()
+	client := NewClientWithTransport(transport)
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	assertNoError(t, client.Query(ctx, "first"))
+	turns := client.(*ClientImpl).turns
+	assertNoError(t, transport.Close())
+	for !turns.begin() {
+		select {
+		case <-ctx.Done():
+			t.Fatal("Expected the turn to end with the stream")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestClientQueryQueueing(t *testing.T) {
+	ctx, cancel := setupTurnTestContext(t)
+	defer cancel()
+
+	transport := newClientMockTransport()
+	client := NewClientWithTransport(transport, WithQueryQueueing(true))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	for _, prompt := range []string{"first", "second", "third"} {
+		assertNoError(t, client.Query(ctx, prompt))
+	}
+	assertClientMessageCount(t, transport, 1)
+
+	// Each ResultMessage releases exactly one queued prompt, in order
+	for i, want := range []string{"second", "third"} {
+		deliverTurnResult(t, transport)
+		awaitClientResult(ctx, t, client)
+		waitForSentMessages(t, transport, i+2)
+		assertClientMessageCount(t, transport, i+2)
+		assertSentPrompt(t, transport, i+1, want)
+	}
+}
+
+func TestClientQueryQueueingRejectsInvalidOptions(t *testing.T) {
+	ctx, cancel := setupTurnTestContext(t)
+	defer cancel()
+
+	client := NewClientWithTransport(newClientMockTransport(), WithQueryQueueing(true))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	assertNoError(t, client.Query(ctx, "first"))
+	err := client.Query(ctx, "second", WithSystemPrompt("be brief"))
+	assertClientError(t, err, true, "unsupported query option")
+}
+
+func TestClientQueuedQueryFailureReported(t *testing.T) {
+	ctx, cancel := setupTurnTestContext(t)
+	defer cancel()
+
+	transport := newClientMockTransport()
+	client := NewClientWithTransport(transport, WithQueryQueueing(true))
+	connectClientSafely(ctx, t, client)
+	defer disconnectClientSafely(t, client)
+
+	assertNoError(t, client.Query(ctx, "first"))
+	assertNoError(t, client.Query(ctx, "queued"))
+
+	transport.mu.Lock()
+	transport.sendError = errors.New("broken pipe")
+	transport.mu.Unlock()
+	deliverTurnResult(t, transport)
+
+	impl := client.(*ClientImpl)
+	impl.mu.RLock()
+	errs := impl.errChan
+	impl.mu.RUnlock()
+	select {
+	case err := <-errs:
+		if err == nil || err.Error() != "broken pipe" {
+			t.Fatalf("Expected queued send failure, got %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Timed out waiting for queued send failure")
+	}
+
+	// The failed query released the turn
+	transport.mu.Lock()
+	transport.sendError = nil
+	transport.mu.Unlock()
+	assertNoError(t, client.Query(ctx, "retry"))
+}
+
+func setupTurnTestContext(t *testing.T) (context.Context, context.CancelFunc) {
+	t.Helper()
+	return context.WithTimeout(context.Background(), 5*time.Second)
+}
+
+// deliverTurnResult has the mock CLI finish the current turn.
+func deliverTurnResult(t *testing.T, transport *clientMockTransport) {
+	t.Helper()
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if transport.msgChan == nil {
+		t.Fatal("Transport is not receiving messages")
+	}
+	transport.msgChan <- &ResultMessage{Subtype: "success"}
+}
+
+func assertTurnReady(t *testing.T, turns *turnState) {
+	t.Helper()
+	select {
+	case <-turns.ready:
+	default:
+		t.Fatal("Expected the dispatcher to be signaled")
+	}
+}
+
+func assertSentPrompt(t *testing.T, transport *clientMockTransport, index int, prompt string) {
+	t.Helper()
+	sent, ok := transport.getSentMessage(index)
+	if !ok {
+		t.Fatalf("Expected sent message %d", index)
+	}
+	payload, _ := sent.Message.(map[string]interface{})
+	if payload["content"] != prompt {
+		t.Errorf("Expected message %d to be %q, got %v", index, prompt, payload["content"])
+	}
+}
@@ -24,9 +24,45 @@ type AssistantMessageError = shared.AssistantMessageError
 // SystemMessage represents a system prompt message.
 type SystemMessage = shared.SystemMessage
 
+// InitInfo describes the session the CLI started, from its init message.
+type InitInfo = shared.InitInfo
+
+// McpServerStatus is the connection state of an MCP server at startup.
+type McpServerStatus = shared.McpServerStatus
+
+// McpServerHealth is the state of an MCP server in the current session.
+type McpServerHealth = shared.McpServerHealth
+
+// McpObserver receives MCP servers that are unavailable when a session starts.
+type McpObserver = shared.McpObserver
+
+// Re-export MCP server state constants
+const (
+	McpStatusConnected = shared.McpStatusConnected
+	McpStatusFailed    = shared.McpStatusFailed
+	McpStatusNeedsAuth = shared.McpStatusNeedsAuth
+	McpStatusPending   = shared.McpStatusPending
+	McpStatusMissing   = shared.McpStatusMissing
+)
+
 // ResultMessage represents a result or status message.
 type ResultMessage = shared.ResultMessage
 
+// Usage reports the tokens a conversation turn consumed.
+type Usage = shared.Usage
+
+// CacheControl marks content as a prompt caching breakpoint.
+type CacheControl = shared.CacheControl
+
+// CacheControlEphemeral is the cache control type for prompt caching.
+const CacheControlEphemeral = shared.CacheControlEphemeral
+
+// PermissionDenial records a tool call the CLI refused during a turn.
+type PermissionDenial = shared.PermissionDenial
+
+// ResumedSession describes the session a continued or resumed query picked up.
+type ResumedSession = shared.ResumedSession
+
 // TextBlock represents a text content block.
 type TextBlock = shared.TextBlock
 
@@ -39,6 +75,201 @@ type ToolUseBlock = shared.ToolUseBlock
 // ToolResultBlock represents a tool result content block.
 type ToolResultBlock = shared.ToolResultBlock
 
+// ImageContent represents an image in a tool result.
+type ImageContent = shared.ImageContent
+
+// ToolErrorKind classifies why a tool call failed.
+type ToolErrorKind = shared.ToolErrorKind
+
+// WebSearchResult is the outcome of a WebSearch tool call.
+type WebSearchResult = shared.WebSearchResult
+
+// WebSearchHit is one page a web search found.
+type WebSearchHit = shared.WebSearchHit
+
+// WebFetchResult is the outcome of a WebFetch tool call.
+type WebFetchResult = shared.WebFetchResult
+
+// Plan is the plan the agent presents when it leaves plan mode.
+type Plan = shared.Plan
+
+// PlanDecision is a PlanReviewer's verdict on a plan.
+type PlanDecision = shared.PlanDecision
+
+// PlanReviewer approves or rejects a plan before the agent starts executing it.
+type PlanReviewer = shared.PlanReviewer
+
+// PathPolicy checks the paths of a file tool call before the tool runs. An
+// error denies the call and is shown to the agent.
+type PathPolicy = shared.PathPolicy
+
+// ToolEvent describes a tool call starting or completing.
+type ToolEvent = shared.ToolEvent
+
+// ToolEventType identifies the stage of a tool call reported to a ToolObserver.
+type ToolEventType = shared.ToolEventType
+
+// ToolObserver receives tool call events, for example to export metrics.
+type ToolObserver = shared.ToolObserver
+
+// SlowHookReport describes a hook that ran longer than the threshold set
+// with WithSlowHookThreshold.
+type SlowHookReport = shared.SlowHookReport
+
+// SlowHookReporter receives reports of slow hooks.
+type SlowHookReporter = shared.SlowHookReporter
+
+// QueryDeadlinePolicy adapts a query to the deadline of its context; see
+// WithQueryDeadlinePolicy.
+type QueryDeadlinePolicy = shared.QueryDeadlinePolicy
+
+// AutoReconnectPolicy controls how a client recovers when the CLI exits to
+// restart itself; see WithAutoReconnect.
+type AutoReconnectPolicy = shared.AutoReconnectPolicy
+
+// ReconnectEvent describes a reconnect after the CLI exited to restart.
+type ReconnectEvent = shared.ReconnectEvent
+
+// ReconnectStage identifies the stage of a reconnect reported to a
+// ReconnectObserver.
+type ReconnectStage = shared.ReconnectStage
+
+// ReconnectObserver receives reconnect events.
+type ReconnectObserver = shared.ReconnectObserver
+
+// InFlightTool is a tool call waiting for its result.
+type InFlightTool = shared.InFlightTool
+
+// ToolProgressFunc receives the tool calls in flight, oldest first.
+type ToolProgressFunc = shared.ToolProgressFunc
+
+// Turn is one exchange with the agent, from its prompt to its result.
+type Turn = shared.Turn
+
+// ToolCall is a tool use paired with its result.
+type ToolCall = shared.ToolCall
+
+// TurnObserver receives each turn once its ResultMessage arrives.
+type TurnObserver = shared.TurnObserver
+
+// MessageObserver sees each message the CLI sends before it is delivered.
+type MessageObserver = shared.MessageObserver
+
+// RouteRequest describes a request to a ModelRouter.
+type RouteRequest = shared.RouteRequest
+
+// ModelRouter picks the model for each request.
+type ModelRouter = shared.ModelRouter
+
+// ModelRouterFunc adapts a function to a ModelRouter.
+type ModelRouterFunc = shared.ModelRouterFunc
+
+// IdempotencyStore keeps the results of queries sent with an idempotency
+// key.
+type IdempotencyStore = shared.IdempotencyStore
+
+// RouteFlagDeep asks a ModelRouter for its most capable model.
+const RouteFlagDeep = shared.RouteFlagDeep
+
+// Re-export tool event type constants
+const (
+	ToolEventStarted   = shared.ToolEventStarted
+	ToolEventCompleted = shared.ToolEventCompleted
+)
+
+// Re-export reconnect stage constants
+const (
+	ReconnectStarted   = shared.ReconnectStarted
+	ReconnectCompleted = shared.ReconnectCompleted
+	ReconnectFailed    = shared.ReconnectFailed
+)
+
+// ConfigCleanup says when an isolated config directory is removed; see
+// WithIsolatedConfig.
+type ConfigCleanup = shared.ConfigCleanup
+
+// Re-export config cleanup constants
+const (
+	ConfigCleanupOnDisconnect = shared.ConfigCleanupOnDisconnect
+	ConfigCleanupOnSuccess    = shared.ConfigCleanupOnSuccess
+	ConfigCleanupNever        = shared.ConfigCleanupNever
+)
+
+// SessionEndReason describes why a client session ended.
+type SessionEndReason = shared.SessionEndReason
+
+// Re-export session end reason constants
+const (
+	SessionEndDisconnect      = shared.SessionEndDisconnect
+	SessionEndContextCanceled = shared.SessionEndContextCanceled
+	SessionEndPanic           = shared.SessionEndPanic
+	SessionEndProcessExit     = shared.SessionEndProcessExit
+)
+
+// SessionSummary describes a finished client session.
+type SessionSummary = shared.SessionSummary
+
+// Finalizer receives the summary of a client session after it was torn down.
+type Finalizer = shared.Finalizer
+
+// PromptInterceptor inspects, rewrites or rejects an outgoing prompt.
+type PromptInterceptor = shared.PromptInterceptor
+
+// JSONCodec encodes and decodes the JSON exchanged with the CLI.
+type JSONCodec = shared.JSONCodec
+
+// StdJSONCodec is the default JSONCodec, backed by encoding/json.
+type StdJSONCodec = shared.StdJSONCodec
+
+// Clock tells the client the time and times out its waits.
+type Clock = shared.Clock
+
+// Timer is a timer made by a Clock, like time.Timer.
+type Timer = shared.Timer
+
+// SystemClock is the default Clock, backed by the time package.
+type SystemClock = shared.SystemClock
+
+// IDGenerator makes the IDs of the requests the client sends.
+type IDGenerator = shared.IDGenerator
+
+// SequentialIDs is the default IDGenerator, numbering the IDs of each
+// prefix from 1.
+type SequentialIDs = shared.SequentialIDs
+
+// DebugRecord is one line of CLI stderr output.
+type DebugRecord = shared.DebugRecord
+
+// StderrCallback receives CLI stderr output line by line.
+type StderrCallback = shared.StderrCallback
+
+// CLICommand is the CLI process a transport is about to start.
+type CLICommand = shared.CLICommand
+
+// CommandWrapper rewrites the CLI command before it starts.
+type CommandWrapper = shared.CommandWrapper
+
+// CassetteMode selects whether a cassette records a session or replays it.
+type CassetteMode = shared.CassetteMode
+
+// Re-export cassette mode constants
+const (
+	CassetteRecord         = shared.CassetteRecord
+	CassetteReplay         = shared.CassetteReplay
+	CassetteRecordOrReplay = shared.CassetteRecordOrReplay
+)
+
+// ContextTruncationPolicy decides what happens when context added to the
+// next prompt exceeds its size limit.
+type ContextTruncationPolicy = shared.ContextTruncationPolicy
+
+// Re-export context truncation policy constants
+const (
+	ContextTruncate = shared.ContextTruncate
+	ContextDrop     = shared.ContextDrop
+	ContextReject   = shared.ContextReject
+)
+
 // StreamMessage represents a message in the streaming protocol.
 type StreamMessage = shared.StreamMessage
 
@@ -62,12 +293,44 @@ const (
 	MessageTypeResult    = shared.MessageTypeResult
 )
 
+// SystemSubtypeInit is the subtype of the CLI's init system message.
+const SystemSubtypeInit = shared.SystemSubtypeInit
+
 // Re-export content block type constants
 const (
 	ContentBlockTypeText       = shared.ContentBlockTypeText
 	ContentBlockTypeThinking   = shared.ContentBlockTypeThinking
 	ContentBlockTypeToolUse    = shared.ContentBlockTypeToolUse
 	ContentBlockTypeToolResult = shared.ContentBlockTypeToolResult
+	ContentBlockTypeImage      = shared.ContentBlockTypeImage
+)
+
+// Re-export image source type constants
+const (
+	ImageSourceTypeBase64 = shared.ImageSourceTypeBase64
+	ImageSourceTypeFile   = shared.ImageSourceTypeFile
+)
+
+// Re-export tool error kinds
+const (
+	ToolErrorNone             = shared.ToolErrorNone
+	ToolErrorPermissionDenied = shared.ToolErrorPermissionDenied
+	ToolErrorFileNotFound     = shared.ToolErrorFileNotFound
+	ToolErrorTimeout          = shared.ToolErrorTimeout
+	ToolErrorOther            = shared.ToolErrorOther
+)
+
+// Re-export stream issue types reported by sequence tracking and strict
+// ordering
+const (
+	StreamIssueDuplicateToolUse    = shared.StreamIssueDuplicateToolUse
+	StreamIssueDuplicateToolResult = shared.StreamIssueDuplicateToolResult
+	StreamIssueResultBeforeUse     = shared.StreamIssueResultBeforeUse
+	StreamIssueUnknownParent       = shared.StreamIssueUnknownParent
+	StreamIssueAfterParentResult   = shared.StreamIssueAfterParentResult
+	StreamIssueDuplicateMessage    = shared.StreamIssueDuplicateMessage
+	StreamIssueMessageBeforeInit   = shared.StreamIssueMessageBeforeInit
+	StreamIssueDuplicateResult     = shared.StreamIssueDuplicateResult
 )
 
 // Re-export AssistantMessageError constants
@@ -111,3 +374,19 @@ type ControlRequestTransport interface {
 	SendControlRequest(ctx context.Context, req *ControlRequest) error
 	SupportsControlRequests() bool
 }
+
+// ControlResponseTransport is implemented by control transports that can
+// answer the CLI's own control requests, such as can_use_tool. The requests
+// arrive among the received messages as *ControlRequestMessage values, and
+// the responses of the SDK's requests as *ControlResponseMessage values;
+// the client handles both and does not deliver them.
+type ControlResponseTransport interface {
+	ControlRequestTransport
+	SendControlResponse(ctx context.Context, resp *ControlResponse) error
+}
+
+// ControlRequestMessage carries a control request from the CLI.
+type ControlRequestMessage = shared.ControlRequestMessage
+
+// ControlResponseMessage carries the CLI's response to a control request.
+type ControlResponseMessage = shared.ControlResponseMessage
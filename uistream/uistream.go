@@ -0,0 +1,312 @@
+// Package uistream turns the SDK's message stream into coarse events for
+// terminal UIs: text appended to the answer, tools starting and finishing,
+// and the turn ending.
+//
+// Events reads a client's message channel and coalesces text arriving in
+// bursts, so a UI redraws a few times per second instead of on every
+// message. Each event is a plain value that can be delivered to a Bubble Tea
+// program as a tea.Msg:
+//
+//	events := uistream.Events(ctx, client.ReceiveMessages(ctx))
+//
+//	func waitForEvent(events <-chan uistream.Event) tea.Cmd {
+//		return func() tea.Msg { return <-events }
+//	}
+//
+//	func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+//		switch ev := msg.(type) {
+//		case uistream.AssistantTextAppended:
+//			m.answer += ev.Text
+//		case uistream.ToolStarted:
+//			m.status = "Running " + ev.Name
+//		case uistream.ToolFinished:
+//			m.status = ev.Summary
+//		case uistream.TurnDone:
+//			m.status = fmt.Sprintf("Done ($%.4f)", ev.CostUSD)
+//			return m, nil
+//		}
+//		return m, waitForEvent(m.events)
+//	}
+//
+// A Mapper does the same conversion one message at a time, without
+// coalescing, for UIs with their own event loop.
+package uistream
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+// Defaults for Events and Mapper.
+const (
+	// DefaultDebounce is how long Events collects text before emitting it.
+	DefaultDebounce = 50 * time.Millisecond
+	// DefaultSummaryLength is the rune limit of a ToolFinished summary.
+	DefaultSummaryLength = 80
+)
+
+// Event is one of AssistantTextAppended, ToolStarted, ToolFinished and
+// TurnDone.
+type Event interface {
+	uiEvent()
+}
+
+// AssistantTextAppended is text the agent added to its answer.
+type AssistantTextAppended struct {
+	Text string
+}
+
+// ToolStarted is a tool call the agent made.
+type ToolStarted struct {
+	ID   string
+	Name string
+	Args map[string]any
+}
+
+// ToolFinished is the result of a tool call.
+type ToolFinished struct {
+	ID   string
+	Name string
+	OK   bool
+	// Summary is the first line of the tool's output, or of its error when
+	// it failed, shortened for a status line.
+	Summary  string
+	Duration time.Duration
+}
+
+// TurnDone ends a turn.
+type TurnDone struct {
+	SessionID string
+	// CostUSD is the cost the CLI reported, or zero.
+	CostUSD  float64
+	IsError  bool
+	NumTurns int
+	Duration time.Duration
+}
+
+func (AssistantTextAppended) uiEvent() {}
+func (ToolStarted) uiEvent()           {}
+func (ToolFinished) uiEvent()          {}
+func (TurnDone) uiEvent()              {}
+
+// Option configures Events and Mapper.
+type Option func(*config)
+
+type config struct {
+	debounce      time.Duration
+	summaryLength int
+}
+
+// WithDebounce sets how long Events collects text before emitting it. Zero
+// or less emits text as it arrives.
+func WithDebounce(d time.Duration) Option {
+	return func(c *config) {
+		c.debounce = d
+	}
+}
+
+// WithSummaryLength sets the rune limit of ToolFinished summaries.
+func WithSummaryLength(n int) Option {
+	return func(c *config) {
+		c.summaryLength = n
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{debounce: DefaultDebounce, summaryLength: DefaultSummaryLength}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// Events converts msgs into events until msgs is closed or ctx is done,
+// then closes the returned channel. Text is collected for the debounce
+// interval and emitted as one AssistantTextAppended; any other event first
+// emits the text collected before it, so events stay in order.
+func Events(ctx context.Context, msgs <-chan claudecode.Message, opts ...Option) <-chan Event {
+	c := newConfig(opts)
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		mapper := &Mapper{config: c}
+		var pending strings.Builder
+		var timer *time.Timer
+		var tick <-chan time.Time
+
+		send := func(ev Event) bool {
+			select {
+			case out <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		flush := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer, tick = nil, nil
+			}
+			if pending.Len() == 0 {
+				return true
+			}
+			text := pending.String()
+			pending.Reset()
+			return send(AssistantTextAppended{Text: text})
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+				timer, tick = nil, nil
+				if !flush() {
+					return
+				}
+			case msg, ok := <-msgs:
+				if !ok {
+					flush()
+					return
+				}
+				for _, ev := range mapper.Map(msg) {
+					if text, ok := ev.(AssistantTextAppended); ok && c.debounce > 0 {
+						pending.WriteString(text.Text)
+						if timer == nil {
+							timer = time.NewTimer(c.debounce)
+							tick = timer.C
+						}
+						continue
+					}
+					if !flush() || !send(ev) {
+						return
+					}
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Mapper converts messages into events one at a time. It remembers the
+// tools that started, to name and time them when they finish. It is not
+// safe for concurrent use.
+type Mapper struct {
+	config config
+	tools  map[string]startedTool
+	now    func() time.Time
+}
+
+type startedTool struct {
+	name    string
+	started time.Time
+}
+
+// NewMapper returns a Mapper. WithDebounce does not apply to it.
+func NewMapper(opts ...Option) *Mapper {
+	return &Mapper{config: newConfig(opts)}
+}
+
+// Map returns the events of msg. Text of subagents, which report to the
+// agent rather than to the user, is left out; their tool calls are not.
+func (m *Mapper) Map(msg claudecode.Message) []Event {
+	now := time.Now
+	if m.now != nil {
+		now = m.now
+	}
+	switch msg := msg.(type) {
+	case *claudecode.AssistantMessage:
+		var events []Event
+		for _, block := range msg.Content {
+			switch b := block.(type) {
+			case *claudecode.TextBlock:
+				if b.Text != "" && msg.ParentToolUseID == nil {
+					events = append(events, AssistantTextAppended{Text: b.Text})
+				}
+			case *claudecode.ToolUseBlock:
+				if m.tools == nil {
+					m.tools = make(map[string]startedTool)
+				}
+				m.tools[b.ToolUseID] = startedTool{name: b.Name, started: now()}
+				events = append(events, ToolStarted{ID: b.ToolUseID, Name: b.Name, Args: b.Input})
+			}
+		}
+		return events
+	case *claudecode.UserMessage:
+		blocks, ok := msg.Content.([]claudecode.ContentBlock)
+		if !ok {
+			return nil
+		}
+		var events []Event
+		for _, block := range blocks {
+			result, ok := block.(*claudecode.ToolResultBlock)
+			if !ok {
+				continue
+			}
+			finished := ToolFinished{
+				ID:      result.ToolUseID,
+				OK:      !result.Failed(),
+				Summary: summarize(resultText(result.Content), m.config.summaryLength),
+			}
+			if tool, ok := m.tools[result.ToolUseID]; ok {
+				finished.Name = tool.name
+				finished.Duration = now().Sub(tool.started)
+				delete(m.tools, result.ToolUseID)
+			}
+			events = append(events, finished)
+		}
+		return events
+	case *claudecode.ResultMessage:
+		done := TurnDone{
+			SessionID: msg.SessionID,
+			IsError:   msg.IsError,
+			NumTurns:  msg.NumTurns,
+			Duration:  time.Duration(msg.DurationMs) * time.Millisecond,
+		}
+		if msg.TotalCostUSD != nil {
+			done.CostUSD = *msg.TotalCostUSD
+		}
+		return []Event{done}
+	default:
+		return nil
+	}
+}
+
+// resultText returns the text of tool result content: a string, or the
+// joined text blocks of structured content.
+func resultText(content any) string {
+	switch content := content.(type) {
+	case string:
+		return content
+	case []any:
+		var parts []string
+		for _, item := range content {
+			block, _ := item.(map[string]any)
+			if text, ok := block["text"].(string); ok && block["type"] == "text" {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// summarize returns the first non-blank line of text, cut to limit runes.
+func summarize(text string, limit int) string {
+	var line string
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			line = l
+			break
+		}
+	}
+	if limit <= 0 || utf8.RuneCountInString(line) <= limit {
+		return line
+	}
+	runes := []rune(line)
+	return string(runes[:limit-1]) + "…"
+}
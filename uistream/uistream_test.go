@@ -0,0 +1,144 @@
+package uistream
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	claudecode "github.com/severity1/claude-code-sdk-go"
+)
+
+func TestMapper(t *testing.T) {
+	clock := time.Unix(1700000000, 0)
+	m := NewMapper(WithSummaryLength(10))
+	m.now = func() time.Time { return clock }
+
+	failed := true
+	cost := 0.02
+	parent := "task-1"
+	var got []Event
+	for _, msg := range []claudecode.Message{
+		&claudecode.SystemMessage{Subtype: claudecode.SystemSubtypeInit},
+		&claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+			&claudecode.TextBlock{Text: "Checking."},
+			&claudecode.ToolUseBlock{ToolUseID: "tool-1", Name: "Bash", Input: map[string]any{"command": "go test"}},
+			&claudecode.ToolUseBlock{ToolUseID: "tool-2", Name: "Read", Input: map[string]any{"file_path": "a.go"}},
+		}},
+		&claudecode.AssistantMessage{ParentToolUseID: &parent, Content: []claudecode.ContentBlock{
+			&claudecode.TextBlock{Text: "subagent notes"},
+		}},
+		&claudecode.UserMessage{Content: []claudecode.ContentBlock{
+			&claudecode.ToolResultBlock{ToolUseID: "tool-1", Content: "\n  ok  \tgithub.com/acme/app\t0.2s\nmore"},
+			&claudecode.ToolResultBlock{ToolUseID: "tool-2", IsError: &failed, Content: []any{
+				map[string]any{"type": "text", "text": "File does not exist."},
+			}},
+			&claudecode.ToolResultBlock{ToolUseID: "unknown", Content: "x"},
+		}},
+		&claudecode.UserMessage{Content: "plain prompt"},
+		&claudecode.ResultMessage{SessionID: "session-1", NumTurns: 2, DurationMs: 1500, TotalCostUSD: &cost},
+	} {
+		if _, ok := msg.(*claudecode.UserMessage); ok {
+			clock = clock.Add(2 * time.Second)
+		}
+		got = append(got, m.Map(msg)...)
+	}
+
+	want := []Event{
+		AssistantTextAppended{Text: "Checking."},
+		ToolStarted{ID: "tool-1", Name: "Bash", Args: map[string]any{"command": "go test"}},
+		ToolStarted{ID: "tool-2", Name: "Read", Args: map[string]any{"file_path": "a.go"}},
+		ToolFinished{ID: "tool-1", Name: "Bash", OK: true, Summary: "ok  \tgith…", Duration: 2 * time.Second},
+		ToolFinished{ID: "tool-2", Name: "Read", OK: false, Summary: "File does…", Duration: 2 * time.Second},
+		ToolFinished{ID: "unknown", OK: true, Summary: "x"},
+		TurnDone{SessionID: "session-1", CostUSD: 0.02, NumTurns: 2, Duration: 1500 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected events:\n%#v\ngot:\n%#v", want, got)
+	}
+}
+
+func TestEventsCoalescesText(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msgs := make(chan claudecode.Message)
+	// A debounce longer than the test: only other events and the end of
+	// the stream emit text
+	events := Events(ctx, msgs, WithDebounce(time.Hour))
+
+	go func() {
+		msgs <- text("Hel")
+		msgs <- text("lo")
+		msgs <- &claudecode.AssistantMessage{Content: []claudecode.ContentBlock{
+			&claudecode.ToolUseBlock{ToolUseID: "tool-1", Name: "Bash"},
+		}}
+		msgs <- text("Done")
+		close(msgs)
+	}()
+
+	var got []Event
+	for ev := range events {
+		got = append(got, ev)
+	}
+	want := []Event{
+		AssistantTextAppended{Text: "Hello"},
+		ToolStarted{ID: "tool-1", Name: "Bash"},
+		AssistantTextAppended{Text: "Done"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected events %#v, got %#v", want, got)
+	}
+}
+
+func TestEventsDebounceTimer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msgs := make(chan claudecode.Message)
+	events := Events(ctx, msgs, WithDebounce(10*time.Millisecond))
+
+	msgs <- text("a")
+	msgs <- text("b")
+	if ev := <-events; ev != (AssistantTextAppended{Text: "ab"}) {
+		t.Errorf("Expected the collected text after the debounce, got %#v", ev)
+	}
+	close(msgs)
+	if _, ok := <-events; ok {
+		t.Error("Expected the events to close with the messages")
+	}
+}
+
+func TestEventsWithoutDebounce(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msgs := make(chan claudecode.Message, 2)
+	msgs <- text("a")
+	msgs <- text("b")
+	close(msgs)
+
+	var got []Event
+	for ev := range Events(ctx, msgs, WithDebounce(0)) {
+		got = append(got, ev)
+	}
+	if len(got) != 2 {
+		t.Errorf("Expected each text as it arrives, got %#v", got)
+	}
+}
+
+func TestEventsStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs := make(chan claudecode.Message)
+	events := Events(ctx, msgs)
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Error("Expected no events after cancellation")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the events to close when the context is done")
+	}
+}
+
+func text(s string) *claudecode.AssistantMessage {
+	return &claudecode.AssistantMessage{Content: []claudecode.ContentBlock{&claudecode.TextBlock{Text: s}}}
+}
@@ -0,0 +1,35 @@
+package claudecode
+
+import "github.com/severity1/claude-code-sdk-go/internal/shared"
+
+// Tool names of the CLI's web tools.
+const (
+	ToolNameWebSearch = "WebSearch"
+	ToolNameWebFetch  = "WebFetch"
+)
+
+// ParseWebSearchResult reads a WebSearch result from a PostToolUse tool
+// response or from the content of its ToolResultBlock.
+var ParseWebSearchResult = shared.ParseWebSearchResult
+
+// ParseWebFetchResult reads a WebFetch result from a PostToolUse tool
+// response or from the content of its ToolResultBlock.
+var ParseWebFetchResult = shared.ParseWebFetchResult
+
+// WebSearchResult reads the tool response of a WebSearch call. It reports
+// false for other tools.
+func (in PostToolUseHookInput) WebSearchResult() (WebSearchResult, bool) {
+	if in.ToolName != ToolNameWebSearch {
+		return WebSearchResult{}, false
+	}
+	return ParseWebSearchResult(in.ToolResponse)
+}
+
+// WebFetchResult reads the tool response of a WebFetch call. It reports
+// false for other tools.
+func (in PostToolUseHookInput) WebFetchResult() (WebFetchResult, bool) {
+	if in.ToolName != ToolNameWebFetch {
+		return WebFetchResult{}, false
+	}
+	return ParseWebFetchResult(in.ToolResponse)
+}
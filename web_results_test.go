@@ -0,0 +1,38 @@
+package claudecode
+
+import "testing"
+
+func TestPostToolUseHookInputWebResults(t *testing.T) {
+	search := PostToolUseHookInput{
+		ToolName:  ToolNameWebSearch,
+		ToolInput: map[string]any{"query": "go generics"},
+		ToolResponse: map[string]any{
+			"query": "go generics",
+			"results": []any{map[string]any{
+				"tool_use_id": "srvtoolu_1",
+				"content": []any{
+					map[string]any{"title": "Generics", "url": "https://go.dev/blog/intro-generics"},
+				},
+			}},
+		},
+	}
+	result, ok := search.WebSearchResult()
+	if !ok || len(result.Results) != 1 || result.Results[0].URL != "https://go.dev/blog/intro-generics" {
+		t.Errorf("Expected one search result, got %+v (ok=%v)", result, ok)
+	}
+	if _, ok := search.WebFetchResult(); ok {
+		t.Error("Expected WebFetchResult to reject a WebSearch call")
+	}
+
+	fetch := PostToolUseHookInput{
+		ToolName:     ToolNameWebFetch,
+		ToolResponse: map[string]any{"code": float64(404), "codeText": "Not Found", "result": "", "url": "https://go.dev/missing"},
+	}
+	page, ok := fetch.WebFetchResult()
+	if !ok || page.StatusCode != 404 || page.URL != "https://go.dev/missing" {
+		t.Errorf("Expected a 404 page, got %+v (ok=%v)", page, ok)
+	}
+	if _, ok := fetch.WebSearchResult(); ok {
+		t.Error("Expected WebSearchResult to reject a WebFetch call")
+	}
+}
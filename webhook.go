@@ -0,0 +1,328 @@
+package claudecode
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ClientEventType identifies a lifecycle event delivered to webhooks.
+type ClientEventType string
+
+const (
+	// ClientEventSessionStart is sent when the CLI reports a new session.
+	ClientEventSessionStart ClientEventType = "session.start"
+	// ClientEventSessionEnd is sent when the client finishes tearing down a
+	// session.
+	ClientEventSessionEnd ClientEventType = "session.end"
+	// ClientEventBudgetExceeded is sent when a turn stops because it reached
+	// the budget set with WithMaxBudgetUSD.
+	ClientEventBudgetExceeded ClientEventType = "budget.exceeded"
+	// ClientEventPermissionDenied is sent for each tool call denied during a
+	// turn.
+	ClientEventPermissionDenied ClientEventType = "permission.denied"
+	// ClientEventCompaction is sent when the CLI compacts the conversation.
+	ClientEventCompaction ClientEventType = "compaction"
+)
+
+// Webhook request headers.
+const (
+	WebhookEventHeader     = "X-Claude-Event"
+	WebhookDeliveryHeader  = "X-Claude-Delivery"
+	WebhookTimestampHeader = "X-Claude-Timestamp"
+	WebhookSignatureHeader = "X-Claude-Signature"
+)
+
+// WebhookSecretEnv names the environment variable WithWebhook reads its
+// signing secret from.
+const WebhookSecretEnv = "CLAUDE_WEBHOOK_SECRET"
+
+// Webhook delivery defaults.
+const (
+	DefaultWebhookMaxRetries = 3
+	DefaultWebhookRetryDelay = time.Second
+	DefaultWebhookTimeout    = 10 * time.Second
+)
+
+// Subtypes of the messages webhook events are derived from.
+const (
+	resultSubtypeMaxBudget  = "error_max_budget_usd"
+	systemSubtypeCompaction = "compact_boundary"
+)
+
+// ClientEvent is the JSON payload posted to a webhook.
+type ClientEvent struct {
+	// ID identifies the event; retries of a delivery share it.
+	ID        string          `json:"id"`
+	Type      ClientEventType `json:"type"`
+	Time      time.Time       `json:"time"`
+	SessionID string          `json:"session_id,omitempty"`
+	// Tags are the client's session tags.
+	Tags map[string]string `json:"tags,omitempty"`
+	Data map[string]any    `json:"data,omitempty"`
+}
+
+// WebhookConfig configures a webhook.
+type WebhookConfig struct {
+	URL string
+	// Events selects the events posted; all events when empty.
+	Events []ClientEventType
+	// Secret signs each payload with HMAC-SHA256; payloads are unsigned
+	// when it is empty.
+	Secret string
+	// MaxRetries is how many times a failed delivery is retried, with the
+	// delay doubling from RetryDelay. Deliveries rejected with a 4xx status
+	// other than 408 and 429 are not retried. A negative value disables
+	// retries.
+	MaxRetries int
+	RetryDelay time.Duration
+	// Client sends the requests; the default has a DefaultWebhookTimeout
+	// timeout.
+	Client *http.Client
+	// OnError is called with each event that could not be delivered.
+	OnError func(event ClientEvent, err error)
+}
+
+// WithWebhook posts the selected lifecycle events, or all of them, to url
+// as JSON. Payloads are signed with the secret in the WebhookSecretEnv
+// environment variable when it is set; use WithWebhookConfig to pass the
+// secret or tune retries directly.
+func WithWebhook(url string, events ...ClientEventType) Option {
+	return WithWebhookConfig(WebhookConfig{URL: url, Events: events, Secret: os.Getenv(WebhookSecretEnv)})
+}
+
+// WithWebhookConfig posts lifecycle events as configured.
+//
+// Events are delivered in the background, so they do not hold up the
+// session, and may arrive out of order. When Secret is set, each request
+// carries the Unix time in WebhookTimestampHeader and, in
+// WebhookSignatureHeader, "sha256=" followed by the hex HMAC-SHA256 of the
+// timestamp, a period and the body; receivers check it with
+// VerifyWebhookSignature. Permission denial events name the tool but leave
+// out its input, which may hold secrets.
+//
+// The webhook chains the finalizer set so far, so it must come after
+// WithFinalizer. Like other message observers, it applies to the Client
+// and not to the Query function.
+func WithWebhookConfig(config WebhookConfig) Option {
+	return func(o *Options) {
+		w := newWebhook(config, o)
+		o.MessageObservers = append(o.MessageObservers, w.observe)
+		next := o.Finalizer
+		o.Finalizer = func(summary SessionSummary) {
+			w.sessionEnded(summary)
+			if next != nil {
+				next(summary)
+			}
+		}
+	}
+}
+
+// VerifyWebhookSignature reports whether signature, the value of the
+// WebhookSignatureHeader, matches body and timestamp for secret.
+func VerifyWebhookSignature(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(signWebhook(secret, timestamp, body)))
+}
+
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhook turns client messages into events and delivers them.
+type webhook struct {
+	config  WebhookConfig
+	events  map[ClientEventType]bool
+	options *Options
+
+	mu      sync.Mutex
+	session string // the session a start event was sent for
+}
+
+func newWebhook(config WebhookConfig, options *Options) *webhook {
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultWebhookMaxRetries
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = DefaultWebhookRetryDelay
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: DefaultWebhookTimeout}
+	}
+	w := &webhook{config: config, options: options}
+	if len(config.Events) > 0 {
+		w.events = make(map[ClientEventType]bool, len(config.Events))
+		for _, event := range config.Events {
+			w.events[event] = true
+		}
+	}
+	return w
+}
+
+// observe sends the events msg signals. It only reads the message, so it
+// is safe with message recycling.
+func (w *webhook) observe(msg Message) {
+	switch m := msg.(type) {
+	case *SystemMessage:
+		if info, ok := m.Init(); ok {
+			w.sessionStarted(info)
+			return
+		}
+		if m.Subtype == systemSubtypeCompaction {
+			sessionID, _ := m.Data["session_id"].(string)
+			data := map[string]any{}
+			if metadata, ok := m.Data["compact_metadata"].(map[string]any); ok {
+				data["trigger"] = metadata["trigger"]
+				data["pre_tokens"] = metadata["pre_tokens"]
+			}
+			w.send(ClientEventCompaction, sessionID, data)
+		}
+	case *ResultMessage:
+		for _, denial := range m.PermissionDenials {
+			w.send(ClientEventPermissionDenied, m.SessionID, map[string]any{
+				"tool_name":   denial.ToolName,
+				"tool_use_id": denial.ToolUseID,
+			})
+		}
+		if m.Subtype == resultSubtypeMaxBudget {
+			data := map[string]any{"num_turns": m.NumTurns}
+			if m.TotalCostUSD != nil {
+				data["total_cost_usd"] = *m.TotalCostUSD
+			}
+			if w.options.MaxBudgetUSD != nil {
+				data["max_budget_usd"] = *w.options.MaxBudgetUSD
+			}
+			w.send(ClientEventBudgetExceeded, m.SessionID, data)
+		}
+	}
+}
+
+// sessionStarted sends a start event the first time a session reports
+// itself; the CLI may repeat its init message within a session.
+func (w *webhook) sessionStarted(info InitInfo) {
+	w.mu.Lock()
+	repeated := info.SessionID != "" && info.SessionID == w.session
+	w.session = info.SessionID
+	w.mu.Unlock()
+	if repeated {
+		return
+	}
+	w.send(ClientEventSessionStart, info.SessionID, map[string]any{
+		"model":               info.Model,
+		"cwd":                 info.Cwd,
+		"permission_mode":     string(info.PermissionMode),
+		"claude_code_version": info.ClaudeCodeVersion,
+	})
+}
+
+func (w *webhook) sessionEnded(summary SessionSummary) {
+	w.mu.Lock()
+	w.session = ""
+	w.mu.Unlock()
+	w.send(ClientEventSessionEnd, summary.SessionID, map[string]any{
+		"reason":         string(summary.Reason),
+		"duration_ms":    summary.Duration.Milliseconds(),
+		"num_turns":      summary.NumTurns,
+		"input_tokens":   summary.InputTokens,
+		"output_tokens":  summary.OutputTokens,
+		"total_cost_usd": summary.TotalCostUSD,
+	})
+}
+
+// send delivers an event in the background if it is selected.
+func (w *webhook) send(eventType ClientEventType, sessionID string, data map[string]any) {
+	if w.events != nil && !w.events[eventType] {
+		return
+	}
+	event := ClientEvent{
+		ID:        newWebhookEventID(),
+		Type:      eventType,
+		Time:      time.Now().UTC(),
+		SessionID: sessionID,
+		Tags:      w.options.SessionTags,
+		Data:      data,
+	}
+	go func() {
+		if err := w.deliver(event); err != nil && w.config.OnError != nil {
+			w.config.OnError(event, err)
+		}
+	}()
+}
+
+// deliver posts event, retrying failures that may be transient.
+func (w *webhook) deliver(event ClientEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encoding webhook event: %w", err)
+	}
+	delay := w.config.RetryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(event, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.config.MaxRetries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (w *webhook) post(event ClientEvent, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, string(event.Type))
+	req.Header.Set(WebhookDeliveryHeader, event.ID)
+	if w.config.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, signWebhook(w.config.Secret, timestamp, body))
+	}
+
+	resp, err := w.config.Client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("posting webhook event: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook returned %s", resp.Status)
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return true, err
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return false, err
+	}
+	return true, err
+}
+
+func newWebhookEventID() string {
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "evt_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return "evt_" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,215 @@
+package claudecode
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhookDeliversSignedEvents(t *testing.T) {
+	requests := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	options := NewOptions(
+		WithMaxBudgetUSD(1),
+		WithWebhookConfig(WebhookConfig{URL: server.URL, Secret: "s3cret"}),
+		WithSessionTags(map[string]string{"tenant": "acme"}),
+	)
+	cost := 1.25
+	options.MessageObservers[0](&ResultMessage{
+		Subtype:      "error_max_budget_usd",
+		SessionID:    "sess-1",
+		NumTurns:     4,
+		TotalCostUSD: &cost,
+	})
+
+	req, body := receiveWebhook(t, requests, bodies)
+	if req.Header.Get(WebhookEventHeader) != string(ClientEventBudgetExceeded) {
+		t.Errorf("Expected the event header, got %q", req.Header.Get(WebhookEventHeader))
+	}
+	timestamp := req.Header.Get(WebhookTimestampHeader)
+	if !VerifyWebhookSignature("s3cret", timestamp, body, req.Header.Get(WebhookSignatureHeader)) {
+		t.Error("Expected a valid signature")
+	}
+	if VerifyWebhookSignature("other", timestamp, body, req.Header.Get(WebhookSignatureHeader)) {
+		t.Error("Expected the signature to depend on the secret")
+	}
+
+	var event ClientEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatalf("Decoding event failed: %v", err)
+	}
+	if event.Type != ClientEventBudgetExceeded || event.SessionID != "sess-1" || event.ID == "" {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if event.Tags["tenant"] != "acme" {
+		t.Errorf("Expected the session tags, got %v", event.Tags)
+	}
+	if req.Header.Get(WebhookDeliveryHeader) != event.ID {
+		t.Errorf("Expected the delivery header to carry the event ID, got %q", req.Header.Get(WebhookDeliveryHeader))
+	}
+	if event.Data["total_cost_usd"] != 1.25 || event.Data["max_budget_usd"] != 1.0 {
+		t.Errorf("Expected the cost and budget, got %v", event.Data)
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	requests := make(chan *http.Request, 10)
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	var finalized int
+	options := NewOptions(
+		WithFinalizer(func(SessionSummary) { finalized++ }),
+		WithWebhook(server.URL, ClientEventSessionStart, ClientEventSessionEnd, ClientEventPermissionDenied, ClientEventCompaction),
+	)
+	observe := options.MessageObservers[0]
+	init := &SystemMessage{Subtype: SystemSubtypeInit, Data: map[string]any{"session_id": "sess-1", "model": "claude-test"}}
+
+	tests := []struct {
+		name string
+		emit func()
+		want ClientEventType
+		data map[string]any
+	}{
+		{"session start", func() { observe(init) }, ClientEventSessionStart, map[string]any{"model": "claude-test"}},
+		{"compaction", func() {
+			observe(&SystemMessage{Subtype: "compact_boundary", Data: map[string]any{
+				"session_id":       "sess-1",
+				"compact_metadata": map[string]any{"trigger": "auto", "pre_tokens": float64(150000)},
+			}})
+		}, ClientEventCompaction, map[string]any{"trigger": "auto", "pre_tokens": float64(150000)}},
+		{"permission denied", func() {
+			observe(&ResultMessage{Subtype: "success", SessionID: "sess-1", PermissionDenials: []PermissionDenial{
+				{ToolName: "Bash", ToolUseID: "toolu_1", ToolInput: map[string]any{"command": "rm -rf /"}},
+			}})
+		}, ClientEventPermissionDenied, map[string]any{"tool_name": "Bash", "tool_use_id": "toolu_1"}},
+		{"session end", func() {
+			options.Finalizer(SessionSummary{SessionID: "sess-1", Reason: SessionEndProcessExit, NumTurns: 2})
+		}, ClientEventSessionEnd, map[string]any{"reason": "process_exit", "num_turns": float64(2)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.emit()
+			_, body := receiveWebhook(t, requests, bodies)
+			var event ClientEvent
+			if err := json.Unmarshal(body, &event); err != nil {
+				t.Fatalf("Decoding event failed: %v", err)
+			}
+			if event.Type != test.want || event.SessionID != "sess-1" {
+				t.Errorf("Expected a %s event for sess-1, got %+v", test.want, event)
+			}
+			for key, want := range test.data {
+				if event.Data[key] != want {
+					t.Errorf("Expected data %s=%v, got %v", key, want, event.Data[key])
+				}
+			}
+			if _, ok := event.Data["tool_input"]; ok {
+				t.Error("Expected tool input to be left out")
+			}
+		})
+	}
+	if finalized != 1 {
+		t.Errorf("Expected the earlier finalizer to run, got %d calls", finalized)
+	}
+
+	// A repeated init and an unselected event send nothing
+	observe(init)
+	observe(&ResultMessage{Subtype: "error_max_budget_usd", SessionID: "sess-1"})
+	observe(&SystemMessage{Subtype: SystemSubtypeInit, Data: map[string]any{"session_id": "sess-1"}})
+	_, body := receiveWebhook(t, requests, bodies)
+	var event ClientEvent
+	_ = json.Unmarshal(body, &event)
+	if event.Type != ClientEventSessionStart {
+		t.Errorf("Expected a start event after the session ended, got %s", event.Type)
+	}
+	select {
+	case r := <-requests:
+		t.Errorf("Expected no further events, got %s", r.Header.Get(WebhookEventHeader))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{"retries server errors", []int{500, 503, 200}, 3, false},
+		{"retries rate limits", []int{429, 200}, 2, false},
+		{"gives up after max retries", []int{500, 500, 500}, 3, true},
+		{"does not retry client errors", []int{400}, 1, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(test.statuses[int(n)-1])
+			}))
+			defer server.Close()
+
+			w := newWebhook(WebhookConfig{URL: server.URL, MaxRetries: 2, RetryDelay: time.Millisecond}, &Options{})
+
+			err := w.deliver(ClientEvent{ID: "evt_1", Type: ClientEventSessionEnd})
+			if (err != nil) != test.wantErr {
+				t.Errorf("Expected error %v, got %v", test.wantErr, err)
+			}
+			if got := atomic.LoadInt32(&attempts); got != test.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", test.wantAttempts, got)
+			}
+		})
+	}
+}
+
+func TestWebhookOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	failed := make(chan ClientEvent, 1)
+	options := NewOptions(WithWebhookConfig(WebhookConfig{
+		URL:     server.URL,
+		OnError: func(event ClientEvent, _ error) { failed <- event },
+	}))
+	options.Finalizer(SessionSummary{SessionID: "sess-1"})
+
+	select {
+	case event := <-failed:
+		if event.Type != ClientEventSessionEnd {
+			t.Errorf("Expected the failed session end event, got %s", event.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected OnError to be called")
+	}
+}
+
+// receiveWebhook waits for the next webhook request.
+func receiveWebhook(t *testing.T, requests <-chan *http.Request, bodies <-chan []byte) (*http.Request, []byte) {
+	t.Helper()
+	select {
+	case req := <-requests:
+		return req, <-bodies
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a webhook request")
+		return nil, nil
+	}
+}
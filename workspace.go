@@ -0,0 +1,141 @@
+package claudecode
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// workspacePattern names the temporary directories of ephemeral
+// workspaces.
+const workspacePattern = "claude-workspace-*"
+
+// workspace is the temporary working directory of one connection, created
+// with WithEphemeralWorkspace.
+type workspace struct {
+	dir string
+
+	// The client's cwd and added directories, restored when the connection
+	// ends so the next one gets a fresh workspace
+	prevCwd     *string
+	prevAddDirs []string
+
+	mu     sync.Mutex
+	failed bool
+}
+
+// newWorkspace creates a temporary directory, copying template into it
+// unless template is empty, and points options at it.
+func newWorkspace(template string, options *Options) (*workspace, error) {
+	if template != "" {
+		info, err := os.Stat(template)
+		if err != nil {
+			return nil, fmt.Errorf("workspace template: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("workspace template %s is not a directory", template)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", workspacePattern)
+	if err != nil {
+		return nil, fmt.Errorf("creating workspace: %w", err)
+	}
+	// Resolve links such as /tmp on macOS, so paths the CLI reports match
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	if template != "" {
+		if err := copyTree(template, dir); err != nil {
+			_ = os.RemoveAll(dir)
+			return nil, fmt.Errorf("copying workspace template: %w", err)
+		}
+	}
+
+	ws := &workspace{dir: dir, prevCwd: options.Cwd, prevAddDirs: options.AddDirs}
+	options.Cwd = &ws.dir
+	options.AddDirs = append(append([]string(nil), options.AddDirs...), dir)
+	return ws, nil
+}
+
+// track marks the workspace failed when a turn ends in an error.
+func (ws *workspace) track(msg Message) {
+	if result, ok := msg.(*ResultMessage); ok && result.IsError {
+		ws.mu.Lock()
+		ws.failed = true
+		ws.mu.Unlock()
+	}
+}
+
+// restore points options back at the client's own directories.
+func (ws *workspace) restore(options *Options) {
+	options.Cwd = ws.prevCwd
+	options.AddDirs = ws.prevAddDirs
+}
+
+// remove deletes the directory, unless keep is set and the session failed:
+// a turn ended in an error or the session ended other than by Disconnect.
+func (ws *workspace) remove(reason SessionEndReason, keep bool) error {
+	ws.mu.Lock()
+	failed := ws.failed || reason != SessionEndDisconnect
+	ws.mu.Unlock()
+	if keep && failed {
+		return nil
+	}
+	return os.RemoveAll(ws.dir)
+}
+
+// copyTree copies the files, directories and symbolic links under src into
+// dst, keeping their permissions.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		// Sockets, devices and pipes are not copied
+		return nil
+	})
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,161 @@
+package claudecode
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientEphemeralWorkspace(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	template := t.TempDir()
+	writeTestFile(t, filepath.Join(template, "go.mod"), "module fixture\n")
+	writeTestFile(t, filepath.Join(template, "pkg", "pkg.go"), "package pkg\n")
+	callerDir := t.TempDir()
+
+	var ws, finalizedIn string
+	client := NewClientWithTransport(newClientMockTransport(),
+		WithCwd(callerDir),
+		WithAddDirs("/shared"),
+		WithEphemeralWorkspace(template),
+		WithFinalizer(func(SessionSummary) {
+			finalizedIn = readWorkspaceFile(t, filepath.Join(ws, "go.mod"))
+		}),
+	).(*ClientImpl)
+	connectClientSafely(ctx, t, client)
+	ws = client.Workspace()
+
+	if ws == "" || strings.HasPrefix(ws, template) || strings.HasPrefix(ws, callerDir) {
+		t.Fatalf("Expected a new workspace directory, got %q", ws)
+	}
+	if got := readWorkspaceFile(t, filepath.Join(ws, "pkg", "pkg.go")); got != "package pkg\n" {
+		t.Errorf("Expected the template to be copied, got %q", got)
+	}
+	options := client.options
+	if options.Cwd == nil || *options.Cwd != ws {
+		t.Errorf("Expected the workspace as cwd, got %v", options.Cwd)
+	}
+	if len(options.AddDirs) != 2 || options.AddDirs[0] != "/shared" || options.AddDirs[1] != ws {
+		t.Errorf("Expected the workspace to be an allowed directory, got %v", options.AddDirs)
+	}
+
+	disconnectClientSafely(t, client)
+	if finalizedIn != "module fixture\n" {
+		t.Errorf("Expected the finalizer to run before the workspace is removed, got %q", finalizedIn)
+	}
+	if _, err := os.Stat(ws); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the workspace to be removed, got %v", err)
+	}
+	if options.Cwd == nil || *options.Cwd != callerDir || len(options.AddDirs) != 1 {
+		t.Errorf("Expected the caller's directories restored, got %v %v", options.Cwd, options.AddDirs)
+	}
+	if _, err := os.Stat(filepath.Join(template, "go.mod")); err != nil {
+		t.Errorf("Expected the template to be left alone, got %v", err)
+	}
+}
+
+func TestClientEphemeralWorkspaceKeepOnFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		keep     bool
+		isError  bool
+		wantKept bool
+	}{
+		{"failed session kept", true, true, true},
+		{"failed session removed without keep", false, true, false},
+		{"successful session removed", true, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := setupClientTestContext(t, 5*time.Second)
+			defer cancel()
+
+			transport := newClientMockTransportWithOptions(WithClientResponseMessages([]Message{
+				&ResultMessage{Subtype: "error_during_execution", IsError: test.isError},
+			}))
+			client := NewClientWithTransport(transport, WithEphemeralWorkspace(""), WithKeepWorkspaceOnFailure(test.keep)).(*ClientImpl)
+			connectClientSafely(ctx, t, client)
+			ws := client.Workspace()
+			awaitClientResult(ctx, t, client)
+			disconnectClientSafely(t, client)
+
+			_, err := os.Stat(ws)
+			if kept := err == nil; kept != test.wantKept {
+				t.Errorf("Expected kept %v, got stat error %v", test.wantKept, err)
+			}
+			if test.wantKept {
+				if client.Workspace() != ws {
+					t.Errorf("Expected the kept workspace to be reported, got %q", client.Workspace())
+				}
+				_ = os.RemoveAll(ws)
+			}
+		})
+	}
+}
+
+func TestClientEphemeralWorkspaceFailedConnect(t *testing.T) {
+	ctx, cancel := setupClientTestContext(t, 5*time.Second)
+	defer cancel()
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	client := NewClientWithTransport(newClientMockTransport(), WithEphemeralWorkspace(missing)).(*ClientImpl)
+	if err := client.Connect(ctx); err == nil || !strings.Contains(err.Error(), "workspace template") {
+		t.Errorf("Expected a template error, got %v", err)
+	}
+
+	client = NewClientWithTransport(newMockTransportWithError("connect", errors.New("boom")), WithEphemeralWorkspace("")).(*ClientImpl)
+	if err := client.Connect(ctx); err == nil {
+		t.Fatal("Expected the connect error")
+	}
+	if client.Workspace() != "" || client.options.Cwd != nil {
+		t.Errorf("Expected the workspace of a failed connect to be dropped, got %q", client.Workspace())
+	}
+}
+
+func TestQueryRejectsEphemeralWorkspace(t *testing.T) {
+	err := validateQueryOptions(NewOptions(WithEphemeralWorkspace("")))
+	if err == nil || !strings.Contains(err.Error(), "requires a Client") {
+		t.Errorf("Expected ephemeral workspaces to be rejected, got %v", err)
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeTestFile(t, filepath.Join(src, "a", "b", "c.txt"), "c")
+	if err := os.Chmod(filepath.Join(src, "a", "b", "c.txt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("a", "b", "c.txt"), filepath.Join(src, "link")); err != nil {
+		t.Logf("Symlinks unavailable: %v", err)
+	}
+
+	if err := copyTree(src, dst); err != nil {
+		t.Fatalf("copyTree failed: %v", err)
+	}
+	if got := readWorkspaceFile(t, filepath.Join(dst, "a", "b", "c.txt")); got != "c" {
+		t.Errorf("Expected the nested file copied, got %q", got)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "a", "b", "c.txt")); err == nil && info.Mode().Perm() != 0o600 && os.PathSeparator == '/' {
+		t.Errorf("Expected the file mode kept, got %v", info.Mode().Perm())
+	}
+	if link, err := os.Readlink(filepath.Join(src, "link")); err == nil {
+		if got, err := os.Readlink(filepath.Join(dst, "link")); err != nil || got != link {
+			t.Errorf("Expected the link recreated as %q, got %q, %v", link, got, err)
+		}
+	}
+}
+
+func readWorkspaceFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("Reading %s failed: %v", path, err)
+	}
+	return string(data)
+}